@@ -0,0 +1,199 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package annotator
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/contentstream/draw"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+	"github.com/unidoc/unipdf/v3/render"
+)
+
+// makeFilledAppearance returns an appearance stream filling `bbox` with
+// `color`, transformed by `matrix` if specified.
+func makeFilledAppearance(t *testing.T, bbox model.PdfRectangle, matrix []float64,
+	color *model.PdfColorDeviceRGB) *core.PdfObjectStream {
+	cc := contentstream.NewContentCreator()
+	cc.SetNonStrokingColor(color).
+		Add_re(bbox.Llx, bbox.Lly, bbox.Width(), bbox.Height()).
+		Add_f()
+
+	form := model.NewXObjectForm()
+	form.Resources = model.NewPdfPageResources()
+	form.BBox = bbox.ToPdfObject()
+	if matrix != nil {
+		form.Matrix = core.MakeArrayFromFloats(matrix)
+	}
+	require.NoError(t, form.SetContentStream(cc.Bytes(), defStreamEncoder()))
+
+	stream, ok := core.GetStream(form.ToPdfObject())
+	require.True(t, ok)
+	return stream
+}
+
+// makeAppearanceAnnotation returns a square annotation covering `rect`, with
+// the specified normal appearance (stream or state dictionary).
+func makeAppearanceAnnotation(rect model.PdfRectangle, n core.PdfObject) *model.PdfAnnotation {
+	annot := model.NewPdfAnnotationSquare()
+	annot.Rect = rect.ToPdfObject()
+	apDict := core.MakeDict()
+	apDict.Set("N", n)
+	annot.AP = apDict
+	return annot.PdfAnnotation
+}
+
+// pixelRGB returns the 8-bit RGB color of the pixel of `img` located at the
+// page coordinates (`x`,`y`).
+func pixelRGB(img image.Image, x, y float64) [3]uint32 {
+	height := img.Bounds().Dy()
+	r, g, b, _ := img.At(int(x), height-int(y)).RGBA()
+	return [3]uint32{r >> 8, g >> 8, b >> 8}
+}
+
+func TestFlattenAnnotations(t *testing.T) {
+	red := model.NewPdfColorDeviceRGB(1, 0, 0)
+	green := model.NewPdfColorDeviceRGB(0, 1, 0)
+	blue := model.NewPdfColorDeviceRGB(0, 0, 1)
+
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 200, Ury: 200}
+
+	// Appearance drawn in page coordinates.
+	polygon, err := CreatePolygonAnnotation(PolygonAnnotationDef{
+		Vertices: []draw.Point{
+			draw.NewPoint(20, 20), draw.NewPoint(60, 20),
+			draw.NewPoint(60, 60), draw.NewPoint(20, 60),
+		},
+		LineColor: red,
+		FillColor: red,
+	})
+	require.NoError(t, err)
+	page.AddAnnotation(polygon)
+
+	// Appearance with a rotation matrix and a bounding box scaled to the
+	// annotation rectangle.
+	rotated := makeFilledAppearance(t, model.PdfRectangle{Urx: 10, Ury: 20}, []float64{0, 1, -1, 0, 0, 0}, blue)
+	page.AddAnnotation(makeAppearanceAnnotation(model.PdfRectangle{Llx: 120, Lly: 120, Urx: 180, Ury: 150}, rotated))
+
+	// Appearance states: the On state is selected.
+	states := core.MakeDict()
+	states.Set("On", makeFilledAppearance(t, model.PdfRectangle{Urx: 10, Ury: 10}, nil, green))
+	states.Set("Off", makeFilledAppearance(t, model.PdfRectangle{Urx: 10, Ury: 10}, nil, red))
+	stateAnnot := makeAppearanceAnnotation(model.PdfRectangle{Llx: 120, Lly: 20, Urx: 160, Ury: 60}, states)
+	stateAnnot.AS = core.MakeName("On")
+	page.AddAnnotation(stateAnnot)
+
+	// Hidden and NoView annotations are not drawn.
+	for i, flags := range []int64{2, 32} {
+		llx := 20 + float64(i)*40
+		hidden := makeAppearanceAnnotation(model.PdfRectangle{Llx: llx, Lly: 120, Urx: llx + 30, Ury: 150},
+			makeFilledAppearance(t, model.PdfRectangle{Urx: 10, Ury: 10}, nil, red))
+		hidden.F = core.MakeInteger(flags)
+		page.AddAnnotation(hidden)
+	}
+
+	link, err := CreateLinkAnnotation(LinkAnnotationDef{
+		Rect:   model.PdfRectangle{Llx: 0, Lly: 180, Urx: 20, Ury: 200},
+		Action: NewURIAction("https://example.com", false),
+	})
+	require.NoError(t, err)
+	page.AddAnnotation(link)
+
+	require.NoError(t, page.FlattenAnnotations(&model.AnnotationFlattenOptions{KeepLinks: true}))
+
+	// Write and reload the flattened page.
+	w := model.NewPdfWriter()
+	require.NoError(t, w.AddPage(page))
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, w.Write(buf))
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	page, err = reader.GetPage(1)
+	require.NoError(t, err)
+
+	annots, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 1)
+	_, isLink := annots[0].GetContext().(*model.PdfAnnotationLink)
+	require.True(t, isLink)
+
+	img, err := render.NewImageDevice().Render(page)
+	require.NoError(t, err)
+
+	white := [3]uint32{255, 255, 255}
+	testcases := []struct {
+		x, y     float64
+		expected [3]uint32
+	}{
+		{40, 40, [3]uint32{255, 0, 0}},   // Polygon.
+		{122, 122, [3]uint32{0, 0, 255}}, // Rotated appearance, lower left corner.
+		{178, 148, [3]uint32{0, 0, 255}}, // Rotated appearance, upper right corner.
+		{150, 160, white},                // Above the rotated appearance.
+		{140, 40, [3]uint32{0, 255, 0}},  // Selected appearance state.
+		{35, 135, white},                 // Hidden.
+		{75, 135, white},                 // NoView.
+		{100, 100, white},
+	}
+	for _, tcase := range testcases {
+		require.Equal(t, tcase.expected, pixelRGB(img, tcase.x, tcase.y), "(%v, %v)", tcase.x, tcase.y)
+	}
+}
+
+func TestFlattenAnnotationsFormFields(t *testing.T) {
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 200, Ury: 200}
+	page.AddAnnotation(makeAppearanceAnnotation(model.PdfRectangle{Llx: 20, Lly: 20, Urx: 60, Ury: 60},
+		makeFilledAppearance(t, model.PdfRectangle{Urx: 10, Ury: 10}, nil, model.NewPdfColorDeviceRGB(1, 0, 0))))
+
+	field, err := NewTextField(page, "text1", []float64{120, 120, 180, 150}, TextFieldOptions{Value: "value"})
+	require.NoError(t, err)
+	widget := field.Annotations[0]
+	apDict := core.MakeDict()
+	apDict.Set("N", makeFilledAppearance(t, model.PdfRectangle{Urx: 60, Ury: 30}, nil,
+		model.NewPdfColorDeviceRGB(0, 0, 1)))
+	widget.AP = apDict
+	page.AddAnnotation(widget.PdfAnnotation)
+	form := model.NewPdfAcroForm()
+	*form.Fields = append(*form.Fields, field.PdfField)
+
+	w := model.NewPdfWriter()
+	require.NoError(t, w.AddPage(page))
+	require.NoError(t, w.SetForms(form))
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, w.Write(buf))
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	// Widgets are kept by the page, as the form fields reference them.
+	page, err = reader.GetPage(1)
+	require.NoError(t, err)
+	require.NoError(t, page.FlattenAnnotations(nil))
+	annots, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 1)
+	_, isWidget := annots[0].GetContext().(*model.PdfAnnotationWidget)
+	require.True(t, isWidget)
+	require.NotNil(t, reader.AcroForm)
+
+	// The document flattens the fields along with their widgets.
+	require.NoError(t, reader.FlattenAnnotations(nil))
+	require.Nil(t, reader.AcroForm)
+	annots, err = page.GetAnnotations()
+	require.NoError(t, err)
+	require.Empty(t, annots)
+
+	img, err := render.NewImageDevice().Render(page)
+	require.NoError(t, err)
+	require.Equal(t, [3]uint32{255, 0, 0}, pixelRGB(img, 40, 40))
+	require.Equal(t, [3]uint32{0, 0, 255}, pixelRGB(img, 150, 135))
+}
@@ -70,16 +70,14 @@ func makeCircleAnnotationAppearanceStream(circDef CircleAnnotationDef) (*pdfcore
 	gsName := ""
 	if circDef.Opacity < 1.0 {
 		// Create graphics state with right opacity.
-		gsState := pdfcore.MakeDict()
-		gsState.Set("ca", pdfcore.MakeFloat(circDef.Opacity))
-		gsState.Set("CA", pdfcore.MakeFloat(circDef.Opacity))
-		err := form.Resources.AddExtGState("gs1", gsState)
+		gsState := pdf.NewPdfExtGState().SetAlpha(circDef.Opacity)
+		name, err := pdf.NewResourceManager(form.Resources).AddExtGState(gsState.ToPdfObject())
 		if err != nil {
-			common.Log.Debug("Unable to add extgstate gs1")
+			common.Log.Debug("Unable to add extgstate")
 			return nil, nil, err
 		}
 
-		gsName = "gs1"
+		gsName = string(name)
 	}
 
 	content, localBbox, globalBbox, err := drawPdfCircle(circDef, gsName)
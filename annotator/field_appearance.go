@@ -8,6 +8,7 @@ package annotator
 import (
 	"errors"
 	"math"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -26,10 +27,13 @@ import (
 // If `OnlyIfMissing` is true, the field appearance is generated only for fields that do not have an
 // appearance stream specified.
 // If `RegenerateTextFields` is true, all text fields are regenerated (even if OnlyIfMissing is true).
+// If `RegenerateButtonFields` is true, all checkbox and radio button fields are regenerated (even if
+// OnlyIfMissing is true).
 type FieldAppearance struct {
-	OnlyIfMissing        bool
-	RegenerateTextFields bool
-	style                *AppearanceStyle
+	OnlyIfMissing          bool
+	RegenerateTextFields   bool
+	RegenerateButtonFields bool
+	style                  *AppearanceStyle
 }
 
 // AppearanceStyle defines style parameters for appearance stream generation.
@@ -56,6 +60,10 @@ type AppearanceStyle struct {
 
 	// Fonts holds appearance styles for fonts.
 	Fonts *AppearanceFontStyle
+
+	// SelectionColor is the background color of the selected options of list boxes.
+	// Defaults to light blue if not set.
+	SelectionColor model.PdfColor
 }
 
 // AppearanceFontStyle defines font style characteristics for form fields,
@@ -96,6 +104,19 @@ type AppearanceFont struct {
 	Size float64
 }
 
+// Font size bounds used when auto sizing multi line text fields.
+const (
+	autoMultilineFontSize = 12.0
+	autoMinFontSize       = 4.0
+)
+
+// autoListboxFontSize is the font size used for list boxes with auto sized text.
+const autoListboxFontSize = 12.0
+
+// radioCircleRune is the ZapfDingbats filled circle glyph (a71), drawn as a
+// vector circle for radio buttons.
+const radioCircleRune = '●'
+
 type quadding int
 
 const (
@@ -133,9 +154,11 @@ func (fa FieldAppearance) Style() AppearanceStyle {
 func (fa FieldAppearance) GenerateAppearanceDict(form *model.PdfAcroForm, field *model.PdfField, wa *model.PdfAnnotationWidget) (*core.PdfObjectDictionary, error) {
 	common.Log.Trace("GenerateAppearanceDict for %v  V: %+v", field.PartialName(), field.V)
 	_, isText := field.GetContext().(*model.PdfFieldText)
+	_, isButton := field.GetContext().(*model.PdfFieldButton)
+	regenerate := isText && fa.RegenerateTextFields || isButton && fa.RegenerateButtonFields
 
 	appDict, has := core.GetDict(wa.AP)
-	if has && fa.OnlyIfMissing && (!isText || !fa.RegenerateTextFields) {
+	if has && fa.OnlyIfMissing && !regenerate {
 		common.Log.Trace("Already populated - ignoring")
 		return appDict, nil
 	}
@@ -175,7 +198,7 @@ func (fa FieldAppearance) GenerateAppearanceDict(form *model.PdfAcroForm, field
 		return appDict, nil
 	case *model.PdfFieldButton:
 		fbtn := t
-		if fbtn.IsCheckbox() {
+		if fbtn.IsCheckbox() || fbtn.IsRadio() {
 			appDict, err := genFieldCheckboxAppearance(wa, fbtn, form.DR, fa.Style())
 			if err != nil {
 				return nil, err
@@ -186,17 +209,20 @@ func (fa FieldAppearance) GenerateAppearanceDict(form *model.PdfAcroForm, field
 		common.Log.Debug("TODO: UNHANDLED button type: %+v", fbtn.GetType())
 	case *model.PdfFieldChoice:
 		fch := t
-		switch {
-		case fch.Flags().Has(model.FieldFlagCombo):
+		if fch.IsCombo() {
 			appDict, err := genFieldComboboxAppearance(form, wa, fch, fa.Style())
 			if err != nil {
 				return nil, err
 			}
 			return appDict, nil
-		default:
-			common.Log.Debug("TODO: UNHANDLED choice field with flags: %s", fch.Flags().String())
 		}
 
+		appDict, err := genFieldListboxAppearance(form, wa, fch, fa.Style())
+		if err != nil {
+			return nil, err
+		}
+		return appDict, nil
+
 	default:
 		common.Log.Debug("TODO: UNHANDLED field type: %T", t)
 	}
@@ -237,10 +263,7 @@ func genFieldTextAppearance(wa *model.PdfAnnotationWidget, ftxt *model.PdfFieldT
 	}
 
 	cc := contentstream.NewContentCreator()
-
-	if style.BorderSize > 0 {
-		drawRect(cc, style, width, height)
-	}
+	drawBorderAndBackground(cc, wa, style, width, height)
 
 	if style.DrawAlignmentReticle {
 		// Alignment reticle.
@@ -273,6 +296,9 @@ func genFieldTextAppearance(wa *model.PdfAnnotationWidget, ftxt *model.PdfFieldT
 		cc.Translate(bbox.X, bbox.Y)
 	}
 
+	// Prevent field contents from being drawn over the border.
+	clipContentArea(cc, width, height)
+
 	// Graphic state changes.
 	cc.Add_BT()
 
@@ -311,68 +337,42 @@ func genFieldTextAppearance(wa *model.PdfAnnotationWidget, ftxt *model.PdfFieldT
 		return nil, nil
 	}
 
-	lines := []string{text}
+	tx := 2.0
+	lh := style.MultilineLineHeight
+	paragraphs := []string{text}
 
 	// Handle multi line fields.
-	isMultiline := false
-	if ftxt.Flags().Has(model.FieldFlagMultiline) {
-		isMultiline = true
+	isMultiline := ftxt.Flags().Has(model.FieldFlagMultiline)
+	if isMultiline {
 		text = strings.Replace(text, "\r\n", "\n", -1)
 		text = strings.Replace(text, "\r", "\n", -1)
-		lines = strings.Split(text, "\n")
+		paragraphs = strings.Split(text, "\n")
+
+		// Auto sized multi line fields use the largest font size for which
+		// the wrapped text fits inside the field.
+		if autosize {
+			maxFontsize := math.Min(autoMultilineFontSize, height*style.AutoFontSizeFraction)
+			fontsize = fitMultilineFontSize(font, paragraphs, width-2*tx, height, maxFontsize, lh)
+		}
 	}
 
-	maxLinewidth := 0.0
-	textlines := 0
-	if encoder != nil {
-		l := len(lines)
-		i := 0
-		for i < l {
-			var lastwidth float64
-			lastbreakindex := -1
-			linewidth := 0.0
-			for index, r := range lines[i] {
-				if r == ' ' {
-					lastbreakindex = index
-					lastwidth = linewidth
-				}
-				metrics, has := font.GetRuneMetrics(r)
-				if !has {
-					common.Log.Debug("Font does not have rune metrics for %v - skipping", r)
-					continue
-				}
-				linewidth += metrics.Wx
-
-				if isMultiline && !autosize && fontsize*linewidth/1000.0 > width && lastbreakindex > 0 {
-					part2 := lines[i][lastbreakindex+1:]
-
-					if i < len(lines)-1 {
-						lines[i+1] += part2
-					} else {
-						lines = append(lines, part2)
-						l++
-					}
-					lines[i] = lines[i][0:lastbreakindex]
-					linewidth = lastwidth
-					break
-				}
-			}
-			if linewidth > maxLinewidth {
-				maxLinewidth = linewidth
-			}
+	lines := paragraphs
+	if isMultiline && fontsize > 0 {
+		lines = wrapTextLines(font, paragraphs, fontsize, width-2*tx)
+	}
 
-			lines[i] = string(encoder.Encode(lines[i]))
-			if len(lines[i]) > 0 {
-				textlines++
-			}
-			i++
+	maxLinewidth := 0.0
+	linewidths := make([]float64, len(lines))
+	for i, line := range lines {
+		linewidths[i] = measureText(font, line)
+		if linewidths[i] > maxLinewidth {
+			maxLinewidth = linewidths[i]
 		}
 	}
-
-	tx := 2.0
+	textlines := len(lines)
 
 	// Check if text goes out of bounds, if goes out of bounds, then adjust font size until just within bounds.
-	if fontsize == 0 || autosize && maxLinewidth > 0 && tx+maxLinewidth*fontsize/1000.0 > width {
+	if maxLinewidth > 0 && (fontsize == 0 || autosize && tx+maxLinewidth*fontsize/1000.0 > width) {
 		// TODO(gunnsth): Add to style options.
 		fontsize = 0.95 * 1000.0 * (width - tx) / maxLinewidth
 	}
@@ -394,8 +394,6 @@ func genFieldTextAppearance(wa *model.PdfAnnotationWidget, ftxt *model.PdfFieldT
 		}
 	}
 
-	lh := style.MultilineLineHeight
-
 	lineheight := fontsize
 	if isMultiline && textlines > 1 {
 		lineheight = lh * fontsize
@@ -435,9 +433,8 @@ func genFieldTextAppearance(wa *model.PdfAnnotationWidget, ftxt *model.PdfFieldT
 					b := a + textheight - lineheight
 					ty = b
 				} else {
-					// Top.
-					ty = height - lineheight
-					ty -= fontsize * 0.5
+					// Top, the first baseline is placed the cap height below the padding.
+					ty = height - tx - capheight
 				}
 			} else {
 				ty = (height - capheight) / 2.0
@@ -450,15 +447,7 @@ func genFieldTextAppearance(wa *model.PdfAnnotationWidget, ftxt *model.PdfFieldT
 	tx0 := tx
 	x := tx
 	for i, line := range lines {
-		wLine := 0.0
-		for _, r := range line {
-			metrics, has := font.GetRuneMetrics(r)
-			if !has {
-				continue
-			}
-			wLine += metrics.Wx
-		}
-		linewidth := wLine / 1000.0 * fontsize
+		linewidth := linewidths[i] / 1000.0 * fontsize
 		remaining := width - linewidth
 
 		var xnew float64
@@ -468,18 +457,18 @@ func genFieldTextAppearance(wa *model.PdfAnnotationWidget, ftxt *model.PdfFieldT
 		case quaddingCenter:
 			xnew = remaining / 2
 		case quaddingRight:
-			xnew = remaining
+			xnew = remaining - tx0
 		}
 		tx = xnew - x
-		if tx > 0.0 {
+		if tx != 0.0 {
 			cc.Add_Td(tx, 0)
 		}
 		x = xnew
 
-		cc.Add_Tj(*core.MakeString(line))
+		cc.Add_Tj(*core.MakeStringFromBytes(encoder.Encode(line)))
 
 		if i < len(lines)-1 {
-			cc.Add_Td(0, -lineheight*lh)
+			cc.Add_Td(0, -lineheight)
 		}
 	}
 
@@ -539,8 +528,9 @@ func genFieldTextCombAppearance(wa *model.PdfAnnotationWidget, ftxt *model.PdfFi
 	}
 
 	cc := contentstream.NewContentCreator()
+	drawBorderAndBackground(cc, wa, style, width, height)
 	if style.BorderSize > 0 {
-		drawRect(cc, style, width, height)
+		drawCombSeparators(cc, style, maxLen, width, height)
 	}
 	if style.DrawAlignmentReticle {
 		// Alignment reticle.
@@ -550,6 +540,7 @@ func genFieldTextCombAppearance(wa *model.PdfAnnotationWidget, ftxt *model.PdfFi
 	}
 	cc.Add_BMC("Tx")
 	cc.Add_q()
+	clipContentArea(cc, width, height)
 
 	// Graphic state changes.
 	cc.Add_BT()
@@ -579,6 +570,13 @@ func genFieldTextCombAppearance(wa *model.PdfAnnotationWidget, ftxt *model.PdfFi
 		text = str.Decoded()
 	}
 
+	// Each cell holds exactly one character, anything beyond MaxLen is dropped.
+	runes := []rune(text)
+	if len(runes) > maxLen {
+		runes = runes[:maxLen]
+		text = string(runes)
+	}
+
 	cc.Add_Tf(*fontname, fontsize)
 
 	// Get max glyph height.
@@ -638,40 +636,32 @@ func genFieldTextCombAppearance(wa *model.PdfAnnotationWidget, ftxt *model.PdfFi
 	}
 	cc.Add_Td(0, ty)
 
+	// Horizontal alignment (quadding) shifts the text by whole cells.
+	var offsetCells int
 	if quadding, has := core.GetIntVal(ftxt.Q); has {
 		switch quadding {
+		case 1: // Centered.
+			offsetCells = (maxLen - len(runes)) / 2
 		case 2: // Right justified.
-			if len(text) < maxLen {
-				offset := float64(maxLen-len(text)) * boxwidth
-				cc.Add_Td(offset, 0)
-			}
+			offsetCells = maxLen - len(runes)
 		}
 	}
 
-	for i, r := range text {
-		tx := 2.0
-		encoded := string(r)
-		if encoder != nil {
-			metrics, found := font.GetRuneMetrics(r)
-			if !found {
-				common.Log.Debug("ERROR: Rune not found in font: %v - skipping over", r)
-				continue
-			}
-
-			encoded = string(encoder.Encode(encoded))
-
-			// Calculate indent such that the glyph is positioned in the center.
-			glyphwidth := fontsize * metrics.Wx / 1000.0
-			calcIndent := (boxwidth - glyphwidth) / 2
-			tx = calcIndent
+	x := 0.0
+	for i, r := range runes {
+		metrics, found := font.GetRuneMetrics(r)
+		if !found {
+			common.Log.Debug("ERROR: Rune not found in font: %v - skipping over", r)
+			continue
 		}
 
-		cc.Add_Td(tx, 0)
-		cc.Add_Tj(*core.MakeString(encoded))
+		// Calculate indent such that the glyph is positioned in the center of its cell.
+		glyphwidth := fontsize * metrics.Wx / 1000.0
+		xnew := float64(offsetCells+i)*boxwidth + (boxwidth-glyphwidth)/2
 
-		if i != len(text)-1 {
-			cc.Add_Td(boxwidth-tx, 0)
-		}
+		cc.Add_Td(xnew-x, 0)
+		cc.Add_Tj(*core.MakeStringFromBytes(encoder.Encode(string(r))))
+		x = xnew
 	}
 
 	cc.Add_ET()
@@ -690,7 +680,9 @@ func genFieldTextCombAppearance(wa *model.PdfAnnotationWidget, ftxt *model.PdfFi
 }
 
 // genFieldCheckboxAppearance generates an appearance dictionary for a widget annotation `wa` referenced by
-// a button field `fbtn` with form resources `dr` (DR).
+// a checkbox or radio button field `fbtn` with form resources `dr` (DR).
+// The On state of the appearance is named by the export value of the widget and the appearance state (AS)
+// of the widget is updated to match the value of the field.
 func genFieldCheckboxAppearance(wa *model.PdfAnnotationWidget, fbtn *model.PdfFieldButton, dr *model.PdfPageResources, style AppearanceStyle) (*core.PdfObjectDictionary, error) {
 	// Get bounding Rect.
 	array, ok := core.GetArray(wa.Rect)
@@ -710,6 +702,12 @@ func genFieldCheckboxAppearance(wa *model.PdfAnnotationWidget, fbtn *model.PdfFi
 		return nil, err
 	}
 
+	// Radio buttons are drawn as filled circles, unless the normal caption (MK CA) says otherwise.
+	isRadio := fbtn.IsRadio()
+	if isRadio {
+		style.CheckmarkRune = radioCircleRune
+	}
+
 	if mkDict, has := core.GetDict(wa.MK); has {
 		bsDict, _ := core.GetDict(wa.BS)
 		err := style.applyAppearanceCharacteristics(mkDict, bsDict, zapfdb)
@@ -721,9 +719,7 @@ func genFieldCheckboxAppearance(wa *model.PdfAnnotationWidget, fbtn *model.PdfFi
 	xformOn := model.NewXObjectForm()
 	{
 		cc := contentstream.NewContentCreator()
-		if style.BorderSize > 0 {
-			drawRect(cc, style, width, height)
-		}
+		drawButtonBorderAndBackground(cc, wa, style, width, height, isRadio)
 
 		if style.DrawAlignmentReticle {
 			// Alignment reticle.
@@ -732,41 +728,50 @@ func genFieldCheckboxAppearance(wa *model.PdfAnnotationWidget, fbtn *model.PdfFi
 			drawAlignmentReticle(cc, style2, width, height)
 		}
 
-		fontsize := style.AutoFontSizeFraction * height
-
-		checkmetrics, ok := zapfdb.GetRuneMetrics(style.CheckmarkRune)
-		if !ok {
-			return nil, errors.New("glyph not found")
-		}
-		enc := zapfdb.Encoder()
-		checkstr := enc.Encode(string(style.CheckmarkRune))
+		if isRadio && style.CheckmarkRune == radioCircleRune {
+			// Filled circle centered in the widget.
+			diameter := 0.5 * math.Min(width, height)
+			cc.Add_q().Add_g(0)
+			drawCircle(cc, (width-diameter)/2, (height-diameter)/2, diameter, diameter)
+			cc.Add_f().Add_Q()
+		} else {
+			fontsize := style.AutoFontSizeFraction * height
 
-		checkwidth := checkmetrics.Wx * fontsize / 1000.0
-		// TODO: Get bbox of specific glyph that is chosen.  Choice of specific value will cause slight
-		// deviations for other glyphs, but should be fairly close.
-		fcheckheight := 705.0 // From AFM for code 52.
-		checkheight := fcheckheight / 1000.0 * fontsize
+			checkmetrics, ok := zapfdb.GetRuneMetrics(style.CheckmarkRune)
+			if !ok {
+				return nil, errors.New("glyph not found")
+			}
+			enc := zapfdb.Encoder()
+			checkstr := enc.Encode(string(style.CheckmarkRune))
+
+			checkwidth := checkmetrics.Wx * fontsize / 1000.0
+			// TODO: Get bbox of specific glyph that is chosen.  Choice of specific value will cause slight
+			// deviations for other glyphs, but should be fairly close.
+			fcheckheight := 705.0 // From AFM for code 52.
+			checkheight := fcheckheight / 1000.0 * fontsize
+
+			tx := 2.0
+			ty := 1.0
+			if checkwidth < width {
+				tx = (width - checkwidth) / 2.0
+			}
+			if checkheight < height {
+				ty = (height - checkheight) / 2.0
+			}
 
-		tx := 2.0
-		ty := 1.0
-		if checkwidth < width {
-			tx = (width - checkwidth) / 2.0
-		}
-		if checkheight < height {
-			ty = (height - checkheight) / 2.0
+			cc.Add_q().
+				Add_g(0).
+				Add_BT().
+				Add_Tf("ZaDb", fontsize).
+				Add_Td(tx, ty).
+				Add_Tj(*core.MakeStringFromBytes(checkstr)).
+				Add_ET().
+				Add_Q()
+
+			xformOn.Resources = model.NewPdfPageResources()
+			xformOn.Resources.SetFontByName("ZaDb", zapfdb.ToPdfObject())
 		}
 
-		cc.Add_q().
-			Add_g(0).
-			Add_BT().
-			Add_Tf("ZaDb", fontsize).
-			Add_Td(tx, ty).
-			Add_Tj(*core.MakeStringFromBytes(checkstr)).
-			Add_ET().
-			Add_Q()
-
-		xformOn.Resources = model.NewPdfPageResources()
-		xformOn.Resources.SetFontByName("ZaDb", zapfdb.ToPdfObject())
 		xformOn.BBox = core.MakeArrayFromFloats([]float64{0, 0, width, height})
 		xformOn.SetContentStream(cc.Bytes(), defStreamEncoder())
 	}
@@ -774,25 +779,139 @@ func genFieldCheckboxAppearance(wa *model.PdfAnnotationWidget, fbtn *model.PdfFi
 	xformOff := model.NewXObjectForm()
 	{
 		cc := contentstream.NewContentCreator()
-		if style.BorderSize > 0 {
-			drawRect(cc, style, width, height)
-		}
+		drawButtonBorderAndBackground(cc, wa, style, width, height, isRadio)
 		xformOff.BBox = core.MakeArrayFromFloats([]float64{0, 0, width, height})
 		xformOff.SetContentStream(cc.Bytes(), defStreamEncoder())
 	}
 
+	onState := getButtonOnState(fbtn, wa)
+
 	dchoiceapp := core.MakeDict()
 	dchoiceapp.Set("Off", xformOff.ToPdfObject())
-	dchoiceapp.Set("Yes", xformOn.ToPdfObject())
+	dchoiceapp.Set(onState, xformOn.ToPdfObject())
 
 	appDict := core.MakeDict()
 	appDict.Set("N", dchoiceapp)
 
+	// Keep the appearance state consistent with the field value.
+	wa.AS = core.MakeName("Off")
+	if val, ok := core.GetName(fbtn.V); ok && *val == onState {
+		wa.AS = core.MakeName(string(onState))
+	}
+
 	return appDict, nil
 }
 
+// getButtonOnState returns the name of the On appearance state of the checkbox
+// or radio button widget annotation `wa` of field `fbtn`, i.e. its export
+// value. The state is looked up in the existing appearance dictionaries and in
+// the current appearance state of the widget. Otherwise, radio button widgets
+// get distinct states: the export value of the widget in the Opt array of the
+// field, or the index of the widget in the field if there is no export value
+// or if it is not unique (section 12.7.4.2.4). Defaults to "Yes" for checkboxes.
+func getButtonOnState(fbtn *model.PdfFieldButton, wa *model.PdfAnnotationWidget) core.PdfObjectName {
+	if apDict, has := core.GetDict(wa.AP); has {
+		for _, key := range []core.PdfObjectName{"N", "D"} {
+			stateDict, has := core.GetDict(apDict.Get(key))
+			if !has {
+				continue
+			}
+			for _, state := range stateDict.Keys() {
+				if state != "Off" {
+					return state
+				}
+			}
+		}
+	}
+	if state, has := core.GetName(wa.AS); has && *state != "Off" && *state != "" {
+		return *state
+	}
+	if !fbtn.IsRadio() {
+		return "Yes"
+	}
+
+	index := -1
+	for i, kid := range fbtn.Annotations {
+		if kid == wa {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return "Yes"
+	}
+	opt, ok := core.GetString(fbtn.Opt.Get(index))
+	if !ok || opt.Decoded() == "" || opt.Decoded() == "Off" {
+		return core.PdfObjectName(strconv.Itoa(index))
+	}
+	// Widgets with the same export value would share their On state, the
+	// index is used instead.
+	for i, obj := range fbtn.Opt.Elements() {
+		if other, ok := core.GetString(obj); ok && i != index && other.Decoded() == opt.Decoded() {
+			return core.PdfObjectName(strconv.Itoa(index))
+		}
+	}
+	return core.PdfObjectName(opt.Decoded())
+}
+
+// drawButtonBorderAndBackground draws the border and background of a checkbox or radio button widget.
+// Radio buttons use a circular border, checkboxes a rectangular one.
+func drawButtonBorderAndBackground(cc *contentstream.ContentCreator, wa *model.PdfAnnotationWidget,
+	style AppearanceStyle, width, height float64, isRadio bool) {
+	if !isRadio {
+		drawBorderAndBackground(cc, wa, style, width, height)
+		return
+	}
+
+	hasBG := false
+	if mkDict, has := core.GetDict(wa.MK); has && style.AllowMK {
+		hasBG = mkDict.Get("BG") != nil
+	}
+	if style.BorderSize <= 0 && !hasBG {
+		return
+	}
+
+	diameter := math.Min(width, height) - style.BorderSize
+	cc.Add_q()
+	drawCircle(cc, (width-diameter)/2, (height-diameter)/2, diameter, diameter)
+	switch {
+	case style.BorderSize > 0 && hasBG:
+		cc.Add_w(style.BorderSize).
+			SetStrokingColor(style.BorderColor).
+			SetNonStrokingColor(style.FillColor).
+			Add_B()
+	case style.BorderSize > 0:
+		cc.Add_w(style.BorderSize).
+			SetStrokingColor(style.BorderColor).
+			Add_S()
+	default:
+		cc.SetNonStrokingColor(style.FillColor).Add_f()
+	}
+	cc.Add_Q()
+}
+
+// drawCircle adds a closed ellipse path with lower left corner at (`x`, `y`) fitting in `width`x`height`.
+// The path is not painted.
+func drawCircle(cc *contentstream.ContentCreator, x, y, width, height float64) {
+	xRad := width / 2
+	yRad := height / 2
+	xMagic := xRad * 0.551784
+	yMagic := yRad * 0.551784
+
+	bpath := draw.NewCubicBezierPath()
+	bpath = bpath.AppendCurve(draw.NewCubicBezierCurve(-xRad, 0, -xRad, yMagic, -xMagic, yRad, 0, yRad))
+	bpath = bpath.AppendCurve(draw.NewCubicBezierCurve(0, yRad, xMagic, yRad, xRad, yMagic, xRad, 0))
+	bpath = bpath.AppendCurve(draw.NewCubicBezierCurve(xRad, 0, xRad, -yMagic, xMagic, -yRad, 0, -yRad))
+	bpath = bpath.AppendCurve(draw.NewCubicBezierCurve(0, -yRad, -xMagic, -yRad, -xRad, -yMagic, -xRad, 0))
+	bpath = bpath.Offset(x+xRad, y+yRad)
+
+	draw.DrawBezierPathWithCreator(bpath, cc)
+	cc.Add_h()
+}
+
 // genFieldComboboxAppearance generates an appearance dictionary for a widget annotation `wa` referenced by a
-// combobox choice field `fch` with form resources (DR) `dr`.
+// combobox choice field `fch` with form resources (DR) `dr`. The appearance shows the display text of the
+// selected option, or the value of the field for custom values of editable combo boxes.
 func genFieldComboboxAppearance(form *model.PdfAcroForm, wa *model.PdfAnnotationWidget, fch *model.PdfFieldChoice, style AppearanceStyle) (*core.PdfObjectDictionary, error) {
 	// Get bounding Rect.
 	array, ok := core.GetArray(wa.Rect)
@@ -822,48 +941,178 @@ func genFieldComboboxAppearance(form *model.PdfAcroForm, wa *model.PdfAnnotation
 	}
 
 	// See section 12.7.4.4 "Choice Fields" (pp. 444-446 PDF32000_2008).
-	dchoiceapp := core.MakeDict()
-	for _, optObj := range fch.Opt.Elements() {
-		if optArr, ok := core.GetArray(optObj); ok && optArr.Len() == 2 {
-			optObj = optArr.Get(1)
+	var text string
+	if values := fch.Values(); len(values) > 0 {
+		text = values[0]
+		for _, opt := range fch.Options() {
+			if opt.Export == text {
+				text = opt.Display
+				break
+			}
 		}
+	}
 
-		var optstr string
-		if opt, ok := core.GetString(optObj); ok {
-			optstr = opt.Decoded()
-		} else if opt, ok := core.GetName(optObj); ok {
-			optstr = opt.String()
-		} else {
-			common.Log.Debug("ERROR: Opt not a name/string - %T", optObj)
-			return nil, errors.New("not a name/string")
+	xform, err := makeComboboxTextXObjForm(wa, fch.PdfField, width, height, text, style, daOps, form.DR)
+	if err != nil {
+		return nil, err
+	}
+	if xform == nil {
+		// No selection, only draw the border and background.
+		cc := contentstream.NewContentCreator()
+		drawBorderAndBackground(cc, wa, style, width, height)
+
+		xform = model.NewXObjectForm()
+		xform.BBox = core.MakeArrayFromFloats([]float64{0, 0, width, height})
+		xform.SetContentStream(cc.Bytes(), defStreamEncoder())
+	}
+
+	appDict := core.MakeDict()
+	appDict.Set("N", xform.ToPdfObject())
+
+	return appDict, nil
+}
+
+// genFieldListboxAppearance generates an appearance dictionary for a widget annotation `wa` referenced by a
+// list box choice field `fch` with form resources (DR) in `form`. The appearance shows the options which fit
+// in the widget, starting with the option at the top index (TI), with the selected options highlighted.
+func genFieldListboxAppearance(form *model.PdfAcroForm, wa *model.PdfAnnotationWidget, fch *model.PdfFieldChoice, style AppearanceStyle) (*core.PdfObjectDictionary, error) {
+	// Get bounding Rect.
+	array, ok := core.GetArray(wa.Rect)
+	if !ok {
+		return nil, errors.New("invalid Rect")
+	}
+	rect, err := model.NewPdfRectangle(*array)
+	if err != nil {
+		return nil, err
+	}
+	width, height := rect.Width(), rect.Height()
+
+	// Get and process the default appearance string (DA) operands.
+	daOps, err := contentstream.NewContentStreamParser(getDA(fch.PdfField)).Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	if mkDict, has := core.GetDict(wa.MK); has {
+		bsDict, _ := core.GetDict(wa.BS)
+		err := style.applyAppearanceCharacteristics(mkDict, bsDict, nil)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		if len(optstr) > 0 {
-			xform, err := makeComboboxTextXObjForm(fch.PdfField, width, height, optstr, style, daOps, form.DR)
-			if err != nil {
-				return nil, err
-			}
+	// Process DA operands separately, as the selection highlights must be
+	// drawn before the text.
+	resources := model.NewPdfPageResources()
+	daCC := contentstream.NewContentCreator()
+	apFont, _, err := style.processDA(fch.PdfField, daOps, form.DR, resources, daCC)
+	if err != nil {
+		return nil, err
+	}
+
+	font := apFont.Font
+	fontname := core.MakeName(apFont.Name)
+	fontsize := apFont.Size
+	if fontsize <= 0 {
+		fontsize = autoListboxFontSize
+	}
+	lineheight := style.MultilineLineHeight * fontsize
 
-			dchoiceapp.Set(*core.MakeName(optstr), xform.ToPdfObject())
+	encoder := font.Encoder()
+	if encoder == nil {
+		common.Log.Debug("WARN: font encoder is nil. Assuming identity encoder. Output may be incorrect.")
+		encoder = textencoding.NewIdentityTextEncoder("Identity-H")
+	}
+
+	fcapheight := 1000.0
+	if fdescriptor, err := font.GetFontDescriptor(); err == nil && fdescriptor != nil {
+		if capheight, err := fdescriptor.GetCapHeight(); err == nil && int(capheight) > 0 {
+			fcapheight = capheight
 		}
 	}
+	capheight := fcapheight / 1000.0 * fontsize
+
+	// Determine the visible rows.
+	opts := fch.Options()
+	top := 0
+	if ti, has := core.GetIntVal(fch.TI); has && ti > 0 && ti < len(opts) {
+		top = ti
+	}
+	rows := int((height - 2) / lineheight)
+	if rows < 1 {
+		rows = 1
+	}
+	if top+rows > len(opts) {
+		rows = len(opts) - top
+	}
+
+	selected := map[int]bool{}
+	for _, idx := range fch.SelectedIndices() {
+		selected[idx] = true
+	}
+
+	cc := contentstream.NewContentCreator()
+	drawBorderAndBackground(cc, wa, style, width, height)
+	cc.Add_BMC("Tx")
+	cc.Add_q()
+	clipContentArea(cc, width, height)
+
+	selectionColor := style.SelectionColor
+	if selectionColor == nil {
+		selectionColor = model.NewPdfColorDeviceRGB(0.6, 0.75, 0.87)
+	}
+	for i := 0; i < rows; i++ {
+		if !selected[top+i] {
+			continue
+		}
+		rowTop := height - 1 - float64(i)*lineheight
+		cc.Add_q().
+			SetNonStrokingColor(selectionColor).
+			Add_re(1, rowTop-lineheight, width-2, lineheight).
+			Add_f().
+			Add_Q()
+	}
+
+	cc.Add_BT()
+	for _, op := range *daCC.Operations() {
+		cc.AddOperand(*op)
+	}
+	cc.Add_Tf(*fontname, fontsize)
+
+	tx := 2.0
+	x, y := 0.0, 0.0
+	for i := 0; i < rows; i++ {
+		rowTop := height - 1 - float64(i)*lineheight
+		ty := rowTop - (lineheight+capheight)/2
+
+		cc.Add_Td(tx-x, ty-y)
+		cc.Add_Tj(*core.MakeStringFromBytes(encoder.Encode(opts[top+i].Display)))
+		x, y = tx, ty
+	}
+
+	cc.Add_ET()
+	cc.Add_Q()
+	cc.Add_EMC()
+
+	xform := model.NewXObjectForm()
+	xform.Resources = resources
+	xform.BBox = core.MakeArrayFromFloats([]float64{0, 0, width, height})
+	xform.SetContentStream(cc.Bytes(), defStreamEncoder())
 
 	appDict := core.MakeDict()
-	appDict.Set("N", dchoiceapp)
+	appDict.Set("N", xform.ToPdfObject())
 
 	return appDict, nil
 }
 
 // Make a text-based XObj Form.
-func makeComboboxTextXObjForm(field *model.PdfField, width, height float64,
+func makeComboboxTextXObjForm(wa *model.PdfAnnotationWidget, field *model.PdfField, width, height float64,
 	text string, style AppearanceStyle, daOps *contentstream.ContentStreamOperations,
 	dr *model.PdfPageResources) (*model.XObjectForm, error) {
 	resources := model.NewPdfPageResources()
 
 	cc := contentstream.NewContentCreator()
-	if style.BorderSize > 0 {
-		drawRect(cc, style, width, height)
-	}
+	drawBorderAndBackground(cc, wa, style, width, height)
 	if style.DrawAlignmentReticle {
 		// Alignment reticle.
 		style2 := style
@@ -872,6 +1121,7 @@ func makeComboboxTextXObjForm(field *model.PdfField, width, height float64,
 	}
 	cc.Add_BMC("Tx")
 	cc.Add_q()
+	clipContentArea(cc, width, height)
 	// Graphic state changes.
 	cc.Add_BT()
 
@@ -964,23 +1214,26 @@ func getDA(field *model.PdfField) string {
 		return ""
 	}
 
-	ftxt, ok := field.GetContext().(*model.PdfFieldText)
-	if !ok {
-		return getDA(field.Parent)
+	var da *core.PdfObjectString
+	switch t := field.GetContext().(type) {
+	case *model.PdfFieldText:
+		da = t.DA
+	case *model.PdfFieldChoice:
+		da = t.DA
 	}
-
-	if ftxt.DA != nil {
-		return ftxt.DA.Str()
+	if da != nil {
+		return da.Str()
 	}
 
-	return getDA(ftxt.Parent)
+	return getDA(field.Parent)
 }
 
 // drawRect draws the annotation Rectangle.
-// TODO(gunnsth): Apply clipping so annotation contents cannot go outside Rect.
 func drawRect(cc *contentstream.ContentCreator, style AppearanceStyle, width, height float64) {
+	// The border is stroked inside the Rect, so that it is not clipped by the BBox.
+	inset := style.BorderSize / 2
 	cc.Add_q().
-		Add_re(0, 0, width, height).
+		Add_re(inset, inset, width-2*inset, height-2*inset).
 		Add_w(style.BorderSize).
 		SetStrokingColor(style.BorderColor).
 		SetNonStrokingColor(style.FillColor).
@@ -988,6 +1241,108 @@ func drawRect(cc *contentstream.ContentCreator, style AppearanceStyle, width, he
 		Add_Q()
 }
 
+// drawBorderAndBackground draws the border of the annotation Rect when the style has a border,
+// otherwise only fills the background when the widget `wa` specifies a background color (MK BG entry).
+func drawBorderAndBackground(cc *contentstream.ContentCreator, wa *model.PdfAnnotationWidget, style AppearanceStyle, width, height float64) {
+	if style.BorderSize > 0 {
+		drawRect(cc, style, width, height)
+		return
+	}
+
+	mkDict, has := core.GetDict(wa.MK)
+	if !has || !style.AllowMK || mkDict.Get("BG") == nil {
+		return
+	}
+	cc.Add_q().
+		Add_re(0, 0, width, height).
+		SetNonStrokingColor(style.FillColor).
+		Add_f().
+		Add_Q()
+}
+
+// drawCombSeparators draws the vertical lines separating the `maxLen` cells of a comb field.
+func drawCombSeparators(cc *contentstream.ContentCreator, style AppearanceStyle, maxLen int, width, height float64) {
+	boxwidth := width / float64(maxLen)
+
+	cc.Add_q().
+		Add_w(style.BorderSize).
+		SetStrokingColor(style.BorderColor)
+	for i := 1; i < maxLen; i++ {
+		x := float64(i) * boxwidth
+		cc.Add_m(x, 0).Add_l(x, height)
+	}
+	cc.Add_S().Add_Q()
+}
+
+// clipContentArea sets a clipping path 1 unit inside the annotation Rect of
+// size `width`x`height`, so that field contents cannot go outside the Rect.
+func clipContentArea(cc *contentstream.ContentCreator, width, height float64) {
+	if width <= 2 || height <= 2 {
+		return
+	}
+	cc.Add_re(1, 1, width-2, height-2).Add_W().Add_n()
+}
+
+// measureText returns the width of `text` in glyph space units (1/1000 of
+// the font size), when drawn with `font`.
+func measureText(font *model.PdfFont, text string) float64 {
+	var width float64
+	for _, r := range text {
+		metrics, has := font.GetRuneMetrics(r)
+		if !has {
+			continue
+		}
+		width += metrics.Wx
+	}
+	return width
+}
+
+// wrapTextLines splits each of the `paragraphs` into lines which fit `maxWidth`
+// when drawn using `font` at size `fontsize`. Lines are broken at spaces.
+// Words wider than `maxWidth` are placed on a line of their own.
+func wrapTextLines(font *model.PdfFont, paragraphs []string, fontsize, maxWidth float64) []string {
+	var lines []string
+	for _, paragraph := range paragraphs {
+		words := strings.Split(paragraph, " ")
+
+		line := words[0]
+		for _, word := range words[1:] {
+			candidate := line + " " + word
+			if line != "" && measureText(font, candidate)*fontsize/1000.0 > maxWidth {
+				lines = append(lines, line)
+				line = word
+				continue
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// fitMultilineFontSize returns the largest font size, not greater than
+// `maxFontsize`, for which `paragraphs` wrapped to `width` fit inside `height`,
+// given the line height multiplier `lh`. The size is decreased in steps of 0.5
+// and never goes below autoMinFontSize.
+func fitMultilineFontSize(font *model.PdfFont, paragraphs []string, width, height, maxFontsize, lh float64) float64 {
+	fontsize := maxFontsize
+	for ; fontsize > autoMinFontSize; fontsize -= 0.5 {
+		lines := wrapTextLines(font, paragraphs, fontsize, width)
+
+		fits := float64(len(lines))*fontsize*lh <= height
+		for _, line := range lines {
+			if measureText(font, line)*fontsize/1000.0 > width {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			return fontsize
+		}
+	}
+	return autoMinFontSize
+}
+
 // drawAlignmentReticle draws the Rect box with a reticle on top for alignment guidance.
 func drawAlignmentReticle(cc *contentstream.ContentCreator, style AppearanceStyle, width, height float64) {
 	cc.Add_q().
@@ -1009,9 +1364,11 @@ func (style *AppearanceStyle) applyAppearanceCharacteristics(mkDict *core.PdfObj
 		return nil
 	}
 
+	hasBorderWidth := false
 	if bsDict != nil {
 		if w, err := core.GetNumberAsFloat(bsDict.Get("W")); err == nil {
 			style.BorderSize = w
+			hasBorderWidth = true
 		}
 	}
 
@@ -1043,6 +1400,11 @@ func (style *AppearanceStyle) applyAppearanceCharacteristics(mkDict *core.PdfObj
 		default:
 			common.Log.Debug("ERROR: BC - Invalid number of color components (%d)", len(bc))
 		}
+
+		// The border width defaults to 1 when the border style does not specify it.
+		if !hasBorderWidth && (len(bc) == 1 || len(bc) == 3 || len(bc) == 4) {
+			style.BorderSize = 1
+		}
 	}
 
 	// Border background (fill).
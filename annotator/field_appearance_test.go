@@ -0,0 +1,406 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package annotator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/extractor"
+	"github.com/unidoc/unipdf/v3/internal/pdftest"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// parseAppearanceOps returns the content stream operations of the appearance
+// stream stored under `key` in `apDict`. For appearance state dictionaries,
+// `state` selects the appearance state.
+func parseAppearanceOps(t *testing.T, apDict *core.PdfObjectDictionary, key, state string) *contentstream.ContentStreamOperations {
+	obj := apDict.Get(core.PdfObjectName(key))
+	if state != "" {
+		stateDict, ok := core.GetDict(obj)
+		require.True(t, ok)
+		obj = stateDict.Get(core.PdfObjectName(state))
+	}
+
+	stream, ok := core.GetStream(obj)
+	require.True(t, ok)
+	data, err := core.DecodeStream(stream)
+	require.NoError(t, err)
+
+	ops, err := contentstream.NewContentStreamParser(string(data)).Parse()
+	require.NoError(t, err)
+	return ops
+}
+
+// filterOps returns the operations in `ops` with operand `operand`.
+func filterOps(ops *contentstream.ContentStreamOperations, operand string) []*contentstream.ContentStreamOperation {
+	var res []*contentstream.ContentStreamOperation
+	for _, op := range *ops {
+		if op.Operand == operand {
+			res = append(res, op)
+		}
+	}
+	return res
+}
+
+func newTestTextField(t *testing.T, rect []float64, value, da string) *model.PdfFieldText {
+	field, err := NewTextField(model.NewPdfPage(), "text1", rect, TextFieldOptions{Value: value})
+	require.NoError(t, err)
+	field.DA = core.MakeString(da)
+	return field
+}
+
+func TestTextFieldMultilineAutosize(t *testing.T) {
+	text := "The quick brown fox jumps over the lazy dog. The quick brown fox jumps over the lazy dog."
+	field := newTestTextField(t, []float64{0, 0, 100, 40}, text, "/Helv 0 Tf 0 g")
+	field.SetFlag(model.FieldFlagMultiline)
+
+	form := model.NewPdfAcroForm()
+	apDict, err := FieldAppearance{}.GenerateAppearanceDict(form, field.PdfField, field.Annotations[0])
+	require.NoError(t, err)
+	require.NotNil(t, apDict)
+
+	ops := parseAppearanceOps(t, apDict, "N", "")
+
+	tfOps := filterOps(ops, "Tf")
+	require.Len(t, tfOps, 1)
+	fontsize, err := core.GetNumberAsFloat(tfOps[0].Params[1])
+	require.NoError(t, err)
+	require.True(t, fontsize <= autoMultilineFontSize && fontsize >= autoMinFontSize)
+
+	// The text must be wrapped and each line must fit the field width.
+	font, err := model.NewStandard14Font("Helvetica")
+	require.NoError(t, err)
+
+	tjOps := filterOps(ops, "Tj")
+	require.True(t, len(tjOps) > 1)
+	for _, op := range tjOps {
+		str, ok := core.GetString(op.Params[0])
+		require.True(t, ok)
+		require.True(t, measureText(font, str.String())*fontsize/1000.0 <= 96)
+	}
+	require.True(t, float64(len(tjOps))*fontsize <= 40)
+
+	// The font used must be available in the form resources.
+	require.True(t, form.DR.HasFontByName("Helv"))
+}
+
+func TestTextFieldComb(t *testing.T) {
+	field := newTestTextField(t, []float64{0, 0, 100, 20}, "abc", "/Helv 12 Tf 0 g")
+	field.SetFlag(model.FieldFlagComb)
+	field.MaxLen = core.MakeInteger(5)
+	field.Q = core.MakeInteger(1)
+
+	wa := field.Annotations[0]
+	wa.MK = core.MakeDict()
+	wa.MK.(*core.PdfObjectDictionary).Set("BC", core.MakeArrayFromFloats([]float64{0}))
+	bs := core.MakeDict()
+	bs.Set("W", core.MakeInteger(1))
+	wa.BS = bs
+
+	form := model.NewPdfAcroForm()
+	apDict, err := FieldAppearance{}.GenerateAppearanceDict(form, field.PdfField, wa)
+	require.NoError(t, err)
+
+	ops := parseAppearanceOps(t, apDict, "N", "")
+
+	// One separator between each of the 5 cells.
+	require.Len(t, filterOps(ops, "l"), 4)
+	require.Len(t, filterOps(ops, "Tj"), 3)
+
+	// Centered text starts in the second cell.
+	tdOps := filterOps(ops, "Td")
+	require.True(t, len(tdOps) >= 2)
+	x, err := core.GetNumberAsFloat(tdOps[1].Params[0])
+	require.NoError(t, err)
+	require.True(t, x > 20 && x < 40)
+}
+
+func TestTextFieldBackground(t *testing.T) {
+	field := newTestTextField(t, []float64{0, 0, 100, 20}, "Value", "/Helv 10 Tf 0 g")
+	field.Q = core.MakeInteger(2)
+
+	wa := field.Annotations[0]
+	mk := core.MakeDict()
+	mk.Set("BG", core.MakeArrayFromFloats([]float64{1, 0, 0}))
+	wa.MK = mk
+
+	form := model.NewPdfAcroForm()
+	apDict, err := FieldAppearance{}.GenerateAppearanceDict(form, field.PdfField, wa)
+	require.NoError(t, err)
+
+	ops := parseAppearanceOps(t, apDict, "N", "")
+	require.Len(t, filterOps(ops, "f"), 1)
+	require.Len(t, filterOps(ops, "W"), 1)
+
+	// Right aligned text must end before the right edge of the field.
+	font, err := model.NewStandard14Font("Helvetica")
+	require.NoError(t, err)
+	x := 0.0
+	for _, op := range filterOps(ops, "Td") {
+		dx, err := core.GetNumberAsFloat(op.Params[0])
+		require.NoError(t, err)
+		x += dx
+	}
+	require.InDelta(t, 98, x+measureText(font, "Value")*10/1000.0, 0.01)
+}
+
+func TestTextFieldBorder(t *testing.T) {
+	text := "The quick brown fox jumps over the lazy dog."
+	field := newTestTextField(t, []float64{0, 0, 150, 44}, text, "/Helv 12 Tf 0 g")
+	field.SetFlag(model.FieldFlagMultiline)
+
+	wa := field.Annotations[0]
+	mk := core.MakeDict()
+	mk.Set("BC", core.MakeArrayFromFloats([]float64{0}))
+	wa.MK = mk
+
+	form := model.NewPdfAcroForm()
+	apDict, err := FieldAppearance{}.GenerateAppearanceDict(form, field.PdfField, wa)
+	require.NoError(t, err)
+
+	ops := parseAppearanceOps(t, apDict, "N", "")
+
+	// Without border style, the border has width 1 and is stroked inside the Rect.
+	reOps := filterOps(ops, "re")
+	require.True(t, len(reOps) >= 1)
+	rect, err := core.GetNumbersAsFloat(reOps[0].Params)
+	require.NoError(t, err)
+	require.Equal(t, []float64{0.5, 0.5, 149, 43}, rect)
+	wOps := filterOps(ops, "w")
+	require.Len(t, wOps, 1)
+	w, err := core.GetNumberAsFloat(wOps[0].Params[0])
+	require.NoError(t, err)
+	require.Equal(t, 1.0, w)
+
+	// Multiline text is top aligned: the first baseline is placed the cap
+	// height of Helvetica below the padding of 2 units.
+	tdOps := filterOps(ops, "Td")
+	require.True(t, len(tdOps) >= 1)
+	y, err := core.GetNumberAsFloat(tdOps[0].Params[1])
+	require.NoError(t, err)
+	require.InDelta(t, 44-2-0.718*12, y, 0.001)
+}
+
+// writeAndReload writes a document containing `pages` and `form` and loads it back.
+func writeAndReload(t *testing.T, form *model.PdfAcroForm, pages ...*model.PdfPage) *model.PdfReader {
+	w := model.NewPdfWriter()
+	for _, page := range pages {
+		require.NoError(t, w.AddPage(page))
+	}
+	require.NoError(t, w.SetForms(form))
+
+	return pdftest.WriteAndRead(t, &w)
+}
+
+// newTestRadioField returns a radio button field with a widget for each of the
+// export values in `states`, placed on `page`, with value `value`.
+func newTestRadioField(t *testing.T, page *model.PdfPage, value string, states ...string) *model.PdfFieldButton {
+	field := model.NewPdfField()
+	radio := &model.PdfFieldButton{}
+	field.SetContext(radio)
+	radio.PdfField = field
+	radio.T = core.MakeString("radio1")
+	radio.SetType(model.ButtonTypeRadio)
+	radio.V = core.MakeName(value)
+
+	for i, state := range states {
+		stateDict := core.MakeDict()
+		stateDict.Set(core.PdfObjectName(state), model.NewXObjectForm().ToPdfObject())
+		stateDict.Set("Off", model.NewXObjectForm().ToPdfObject())
+		apDict := core.MakeDict()
+		apDict.Set("N", stateDict)
+
+		x := 100 + 30*float64(i)
+		widget := model.NewPdfAnnotationWidget()
+		widget.Rect = core.MakeArrayFromFloats([]float64{x, 500, x + 20, 520})
+		widget.P = page.ToPdfObject()
+		widget.F = core.MakeInteger(4)
+		widget.Parent = radio.ToPdfObject()
+		widget.AP = apDict
+		widget.AS = core.MakeName("Off")
+
+		radio.Annotations = append(radio.Annotations, widget)
+		page.AddAnnotation(widget.PdfAnnotation)
+	}
+	return radio
+}
+
+func TestCheckboxAppearanceFlatten(t *testing.T) {
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+
+	checkbox, err := NewCheckboxField(page, "check1", []float64{100, 600, 120, 620}, CheckboxFieldOptions{Checked: true})
+	require.NoError(t, err)
+	page.AddAnnotation(checkbox.Annotations[0].PdfAnnotation)
+
+	form := model.NewPdfAcroForm()
+	*form.Fields = append(*form.Fields, checkbox.PdfField)
+
+	r := writeAndReload(t, form, page)
+	require.NoError(t, r.FlattenFields(false, FieldAppearance{RegenerateButtonFields: true}))
+
+	page, err = r.GetPage(1)
+	require.NoError(t, err)
+	ex, err := extractor.New(page)
+	require.NoError(t, err)
+	pageText, _, _, err := ex.ExtractPageText()
+	require.NoError(t, err)
+
+	// The check mark glyph must be drawn inside the widget Rect.
+	var found bool
+	for _, mark := range pageText.Marks().Elements() {
+		bbox := mark.BBox
+		cx, cy := (bbox.Llx+bbox.Urx)/2, (bbox.Lly+bbox.Ury)/2
+		if cx > 100 && cx < 120 && cy > 600 && cy < 620 {
+			require.Equal(t, "✔", mark.Text)
+			found = true
+		}
+	}
+	require.True(t, found)
+}
+
+func TestRadioAppearance(t *testing.T) {
+	page := model.NewPdfPage()
+	radio := newTestRadioField(t, page, "Opt2", "Opt1", "Opt2")
+
+	form := model.NewPdfAcroForm()
+	*form.Fields = append(*form.Fields, radio.PdfField)
+
+	// Existing appearances are kept unless regeneration is requested.
+	fa := FieldAppearance{OnlyIfMissing: true}
+	apDict, err := fa.GenerateAppearanceDict(form, radio.PdfField, radio.Annotations[0])
+	require.NoError(t, err)
+	require.Equal(t, radio.Annotations[0].AP, apDict)
+
+	fa.RegenerateButtonFields = true
+	for i, wa := range radio.Annotations {
+		apDict, err := fa.GenerateAppearanceDict(form, radio.PdfField, wa)
+		require.NoError(t, err)
+		wa.AP = apDict
+
+		state := []string{"Opt1", "Opt2"}[i]
+		ops := parseAppearanceOps(t, apDict, "N", state)
+		require.Len(t, filterOps(ops, "f"), 1)
+		require.Len(t, filterOps(ops, "Tj"), 0)
+		parseAppearanceOps(t, apDict, "N", "Off")
+	}
+
+	// The appearance state must match the value of the field.
+	require.Equal(t, "Off", radio.Annotations[0].AS.String())
+	require.Equal(t, "Opt2", radio.Annotations[1].AS.String())
+
+	// Filling the form selects the widget with the matching export value.
+	require.NoError(t, form.Fill(testFieldValues{"radio1": core.MakeName("Opt1")}))
+	require.Equal(t, "Opt1", radio.Annotations[0].AS.String())
+	require.Equal(t, "Off", radio.Annotations[1].AS.String())
+}
+
+func TestRadioAppearanceOnStates(t *testing.T) {
+	// Widgets without appearances get distinct On states, from the Opt array
+	// of the field or from their index if their export values are not unique.
+	testcases := []struct {
+		opt      *core.PdfObjectArray
+		value    string
+		expected []string
+	}{
+		{nil, "1", []string{"0", "1", "2"}},
+		{core.MakeArray(core.MakeString("a"), core.MakeString("b")), "b", []string{"a", "b", "2"}},
+		{core.MakeArray(core.MakeString("a"), core.MakeString("a"), core.MakeString("b")), "1", []string{"0", "1", "b"}},
+	}
+	for _, tcase := range testcases {
+		page := model.NewPdfPage()
+		radio := newTestRadioField(t, page, tcase.value, "", "", "")
+		radio.Opt = tcase.opt
+		expected := tcase.expected
+
+		form := model.NewPdfAcroForm()
+		*form.Fields = append(*form.Fields, radio.PdfField)
+		for i, wa := range radio.Annotations {
+			wa.AP = nil
+			wa.AS = nil
+			apDict, err := FieldAppearance{}.GenerateAppearanceDict(form, radio.PdfField, wa)
+			require.NoError(t, err)
+			parseAppearanceOps(t, apDict, "N", expected[i])
+			parseAppearanceOps(t, apDict, "N", "Off")
+		}
+		require.Equal(t, "Off", radio.Annotations[0].AS.String())
+		require.Equal(t, expected[1], radio.Annotations[1].AS.String())
+		require.Equal(t, "Off", radio.Annotations[2].AS.String())
+	}
+}
+
+// testFieldValues implements model.FieldValueProvider.
+type testFieldValues map[string]core.PdfObject
+
+func (v testFieldValues) FieldValues() (map[string]core.PdfObject, error) {
+	return v, nil
+}
+
+func newTestChoiceField(t *testing.T, rect []float64, flags model.FieldFlag, opts ...*model.PdfFieldChoiceOption) *model.PdfFieldChoice {
+	field, err := NewComboboxField(model.NewPdfPage(), "choice1", rect, ComboboxFieldOptions{})
+	require.NoError(t, err)
+	field.SetFlag(flags)
+	field.SetOptions(opts)
+	field.DA = core.MakeString("/Helv 10 Tf 0 g")
+	return field
+}
+
+func TestComboboxAppearance(t *testing.T) {
+	field := newTestChoiceField(t, []float64{0, 0, 100, 20}, model.FieldFlagCombo,
+		&model.PdfFieldChoiceOption{Export: "no", Display: "Norway"},
+		&model.PdfFieldChoiceOption{Export: "se", Display: "Sweden"},
+	)
+	require.NoError(t, field.SetValues("se"))
+
+	form := model.NewPdfAcroForm()
+	apDict, err := FieldAppearance{}.GenerateAppearanceDict(form, field.PdfField, field.Annotations[0])
+	require.NoError(t, err)
+
+	// The display text of the selected option is shown.
+	ops := parseAppearanceOps(t, apDict, "N", "")
+	tjOps := filterOps(ops, "Tj")
+	require.Len(t, tjOps, 1)
+	str, ok := core.GetString(tjOps[0].Params[0])
+	require.True(t, ok)
+	require.Equal(t, "Sweden", str.String())
+
+	tfOps := filterOps(ops, "Tf")
+	require.Len(t, tfOps, 1)
+	require.Equal(t, "Helv", tfOps[0].Params[0].String())
+}
+
+func TestListboxAppearance(t *testing.T) {
+	var opts []*model.PdfFieldChoiceOption
+	for _, text := range []string{"Apple", "Banana", "Cherry", "Date", "Elderberry", "Fig"} {
+		opts = append(opts, &model.PdfFieldChoiceOption{Export: text, Display: text})
+	}
+	field := newTestChoiceField(t, []float64{0, 0, 100, 50}, model.FieldFlagMultiSelect, opts...)
+	field.TI = core.MakeInteger(1)
+	require.NoError(t, field.SetValues("Banana", "Date", "Fig"))
+
+	form := model.NewPdfAcroForm()
+	apDict, err := FieldAppearance{}.GenerateAppearanceDict(form, field.PdfField, field.Annotations[0])
+	require.NoError(t, err)
+
+	ops := parseAppearanceOps(t, apDict, "N", "")
+
+	// 4 rows of 12 units fit in the list box, starting from the top index.
+	var shown []string
+	for _, op := range filterOps(ops, "Tj") {
+		str, ok := core.GetString(op.Params[0])
+		require.True(t, ok)
+		shown = append(shown, str.String())
+	}
+	require.Equal(t, []string{"Banana", "Cherry", "Date", "Elderberry"}, shown)
+
+	// Only the visible selected rows are highlighted (clipping path not included).
+	require.Len(t, filterOps(ops, "f"), 2)
+	require.Len(t, filterOps(ops, "re"), 3)
+}
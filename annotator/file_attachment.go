@@ -0,0 +1,172 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package annotator
+
+import (
+	"errors"
+	"time"
+
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/textstring"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// FileAttachmentIcon represents the icon displayed for file attachment
+// annotations.
+type FileAttachmentIcon int
+
+// File attachment icons (Section 12.5.6.15 p. 418).
+const (
+	FileAttachmentIconPushPin FileAttachmentIcon = iota
+	FileAttachmentIconPaperclip
+	FileAttachmentIconGraph
+	FileAttachmentIconTag
+)
+
+// String returns the name of the icon (Name entry).
+func (icon FileAttachmentIcon) String() string {
+	switch icon {
+	case FileAttachmentIconPaperclip:
+		return "Paperclip"
+	case FileAttachmentIconGraph:
+		return "Graph"
+	case FileAttachmentIconTag:
+		return "Tag"
+	}
+	return "PushPin"
+}
+
+// fileAttachmentIconSize is the width and height of file attachment icons.
+const fileAttachmentIconSize = 20.0
+
+// FileAttachmentAnnotationDef defines a file attachment annotation embedding
+// File, displayed as an icon with the lower left corner at (X,Y).
+type FileAttachmentAnnotationDef struct {
+	X        float64
+	Y        float64
+	File     *model.EmbeddedFile
+	Icon     FileAttachmentIcon
+	Color    *model.PdfColorDeviceRGB // Icon color. Defaults to yellow if not specified.
+	Contents string                   // Defaults to the file description or name if not specified.
+	Author   string                   // Author of the annotation (T entry).
+}
+
+// CreateFileAttachmentAnnotation creates a file attachment annotation object
+// that can be added to page PDF annotations.
+func CreateFileAttachmentAnnotation(def FileAttachmentAnnotationDef) (*model.PdfAnnotation, error) {
+	if def.File == nil {
+		return nil, errors.New("attached file not specified")
+	}
+
+	annot := model.NewPdfAnnotationFileAttachment()
+	if err := annot.SetEmbeddedFile(def.File); err != nil {
+		return nil, err
+	}
+	annot.Name = core.MakeName(def.Icon.String())
+
+	color := def.Color
+	if color == nil {
+		color = model.NewPdfColorDeviceRGB(1, 1, 0)
+	}
+	annot.C = core.MakeArrayFromFloats([]float64{color.R(), color.G(), color.B()})
+
+	contents := def.Contents
+	if contents == "" {
+		contents = def.File.Description
+	}
+	if contents == "" {
+		contents = def.File.Name
+	}
+	annot.Contents = textstring.Make(contents)
+	if def.Author != "" {
+		annot.T = textstring.Make(def.Author)
+	}
+
+	annot.Rect = core.MakeArrayFromFloats([]float64{
+		def.X, def.Y, def.X + fileAttachmentIconSize, def.Y + fileAttachmentIconSize,
+	})
+	annot.F = core.MakeInteger(4) // 4 (100 -> Print/show annotations).
+	if date, err := model.NewPdfDateFromTime(time.Now()); err == nil {
+		annot.M = date.ToPdfObject()
+		annot.CreationDate = date.ToPdfObject()
+	}
+
+	apDict, err := makeFileAttachmentAppearanceStream(def.Icon, color)
+	if err != nil {
+		return nil, err
+	}
+	annot.AP = apDict
+
+	return annot.PdfAnnotation, nil
+}
+
+// AddFileAttachmentAnnotation creates a file attachment annotation with the
+// specified definition and adds it to the annotations of `page`.
+func AddFileAttachmentAnnotation(page *model.PdfPage, def FileAttachmentAnnotationDef) (*model.PdfAnnotation, error) {
+	if page == nil {
+		return nil, errors.New("page not specified")
+	}
+
+	annot, err := CreateFileAttachmentAnnotation(def)
+	if err != nil {
+		return nil, err
+	}
+	annot.P = page.ToPdfObject()
+	page.AddAnnotation(annot)
+
+	return annot, nil
+}
+
+func makeFileAttachmentAppearanceStream(icon FileAttachmentIcon, color *model.PdfColorDeviceRGB) (*core.PdfObjectDictionary, error) {
+	cc := contentstream.NewContentCreator()
+	cc.Add_q().
+		Add_w(1).
+		SetStrokingColor(model.NewPdfColorDeviceRGB(0, 0, 0)).
+		SetNonStrokingColor(color)
+
+	switch icon {
+	case FileAttachmentIconPaperclip:
+		cc.Add_m(8, 15).
+			Add_l(8, 4).
+			Add_l(13, 4).
+			Add_l(13, 18).
+			Add_l(5, 18).
+			Add_l(5, 7).
+			Add_S()
+	case FileAttachmentIconGraph:
+		cc.Add_re(1, 1, 18, 18).Add_B().
+			Add_re(4, 3, 3, 6).Add_re(8.5, 3, 3, 10).Add_re(13, 3, 3, 14).
+			SetNonStrokingColor(model.NewPdfColorDeviceRGB(0, 0, 0)).
+			Add_f()
+	case FileAttachmentIconTag:
+		cc.Add_m(1, 10).
+			Add_l(7, 17).
+			Add_l(19, 17).
+			Add_l(19, 3).
+			Add_l(7, 3).
+			Add_h().
+			Add_B()
+		drawCircle(cc, 6, 8.5, 3, 3)
+		cc.Add_S()
+	default:
+		cc.Add_m(10, 9).Add_l(10, 1).Add_S()
+		drawCircle(cc, 5, 9, 10, 10)
+		cc.Add_B()
+	}
+	cc.Add_Q()
+
+	form := model.NewXObjectForm()
+	form.Resources = model.NewPdfPageResources()
+	form.BBox = core.MakeArrayFromFloats([]float64{0, 0, fileAttachmentIconSize, fileAttachmentIconSize})
+	if err := form.SetContentStream(cc.Bytes(), defStreamEncoder()); err != nil {
+		return nil, err
+	}
+
+	apDict := core.MakeDict()
+	apDict.Set("N", form.ToPdfObject())
+	return apDict, nil
+}
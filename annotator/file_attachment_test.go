@@ -0,0 +1,72 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package annotator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+func TestFileAttachmentAnnotation(t *testing.T) {
+	file := &model.EmbeddedFile{
+		Name:        "data.json",
+		Content:     []byte(`{"key": "value"}`),
+		FileType:    "application/json",
+		Description: "Source data",
+	}
+
+	page := model.NewPdfPage()
+	_, err := AddFileAttachmentAnnotation(page, FileAttachmentAnnotationDef{
+		X:    100,
+		Y:    200,
+		File: file,
+		Icon: FileAttachmentIconPaperclip,
+	})
+	require.NoError(t, err)
+
+	w := model.NewPdfWriter()
+	require.NoError(t, w.AddPage(page))
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, w.Write(buf))
+
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	page, err = reader.GetPage(1)
+	require.NoError(t, err)
+	annots, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 1)
+
+	attachment, ok := annots[0].GetContext().(*model.PdfAnnotationFileAttachment)
+	require.True(t, ok)
+	require.Equal(t, "Paperclip", attachment.Name.String())
+	str, ok := core.GetString(attachment.Contents)
+	require.True(t, ok)
+	require.Equal(t, "Source data", str.Decoded())
+
+	rectArr, ok := core.GetArray(attachment.Rect)
+	require.True(t, ok)
+	rect, err := rectArr.ToFloat64Array()
+	require.NoError(t, err)
+	require.Equal(t, []float64{100, 200, 120, 220}, rect)
+
+	extracted, err := attachment.GetEmbeddedFile()
+	require.NoError(t, err)
+	require.Equal(t, file.Name, extracted.Name)
+	require.Equal(t, file.Content, extracted.Content)
+	require.Equal(t, file.FileType, extracted.FileType)
+
+	counts := countAppearanceOps(t, attachment.PdfAnnotation)
+	require.Equal(t, 1, counts["S"])
+
+	_, err = CreateFileAttachmentAnnotation(FileAttachmentAnnotationDef{})
+	require.Error(t, err)
+}
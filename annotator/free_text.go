@@ -0,0 +1,423 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package annotator
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/contentstream/draw"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/textencoding"
+	"github.com/unidoc/unipdf/v3/internal/textstring"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// FreeTextAnnotationDef defines a free text annotation displaying Text inside
+// the text box Rect. The text is wrapped to fit the width of the box.
+// Optionally, the annotation can have a callout line pointing from the text
+// box to a location on the page.
+type FreeTextAnnotationDef struct {
+	Rect      model.PdfRectangle       // Text box, in page coordinates.
+	Text      string                   // Text to display. Line breaks start new paragraphs.
+	Font      *model.PdfFont           // Defaults to Helvetica if not specified.
+	FontSize  float64                  // Defaults to 12 if not specified.
+	TextColor *model.PdfColorDeviceRGB // Defaults to black if not specified.
+
+	FillColor   *model.PdfColorDeviceRGB // No fill if not specified.
+	BorderColor *model.PdfColorDeviceRGB // No border if not specified.
+	BorderWidth float64                  // Defaults to 1 if a border color is specified.
+
+	// Callout line points, starting with the point the callout points at,
+	// followed by an optional knee point and the point the line is attached to
+	// the text box. Either no points, 2 or 3 points must be specified.
+	Callout           []draw.Point
+	CalloutLineEnding draw.LineEndingStyle // Line ending style at the start point.
+
+	Rotation int    // Rotation of the text, in degrees. Must be a multiple of 90.
+	Author   string // Author of the annotation (T entry).
+
+	// Form is the interactive form of the document the annotation is added
+	// to. If specified, the font of the default appearance (DA) is added to
+	// the default resources of the form (DR), in which viewers look it up
+	// when regenerating the appearance.
+	Form *model.PdfAcroForm
+}
+
+// freeTextPadding is the distance between the text and the text box edges.
+const freeTextPadding = 2.0
+
+// CreateFreeTextAnnotation creates a free text annotation object, including
+// its normal appearance, that can be added to page PDF annotations.
+func CreateFreeTextAnnotation(def FreeTextAnnotationDef) (*model.PdfAnnotation, error) {
+	box := def.Rect
+	if box.Width() <= 0 || box.Height() <= 0 {
+		return nil, errors.New("invalid free text rectangle")
+	}
+	if n := len(def.Callout); n != 0 && n != 2 && n != 3 {
+		return nil, errors.New("callout line requires 2 or 3 points")
+	}
+	if def.Rotation%90 != 0 {
+		return nil, errors.New("rotation must be a multiple of 90")
+	}
+
+	font := def.Font
+	if font == nil {
+		var err error
+		font, err = model.NewStandard14Font(model.HelveticaName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fontsize := def.FontSize
+	if fontsize <= 0 {
+		fontsize = 12
+	}
+	textColor := def.TextColor
+	if textColor == nil {
+		textColor = model.NewPdfColorDeviceRGB(0, 0, 0)
+	}
+	borderWidth := 0.0
+	if def.BorderColor != nil {
+		borderWidth = def.BorderWidth
+		if borderWidth <= 0 {
+			borderWidth = 1
+		}
+	}
+
+	annot := model.NewPdfAnnotationFreeText()
+
+	// Default appearance of the text (DA) and default style string (DS).
+	fontname := core.PdfObjectName("Helv")
+	if def.Font != nil {
+		fontname = "FT0"
+	}
+	if def.Form != nil {
+		var err error
+		fontname, err = addFreeTextFormFont(def.Form, font, def.Font == nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	da := fmt.Sprintf("/%s %s Tf %s rg", fontname, formatFloat(fontsize),
+		formatRGB(textColor))
+	if def.BorderColor != nil {
+		da += fmt.Sprintf(" %s RG", formatRGB(def.BorderColor))
+	}
+	annot.DA = core.MakeString(da)
+	style := fmt.Sprintf("font: %s %spt; text-align:left; color:%s",
+		freeTextFontFamily(font), formatFloat(fontsize), formatHexRGB(textColor))
+	annot.DS = core.MakeString(style)
+	annot.RC = textstring.Make(freeTextRichText(def.Text, style))
+	annot.Q = core.MakeInteger(0)
+	if rotation := (def.Rotation%360 + 360) % 360; rotation != 0 {
+		annot.Rotate = core.MakeInteger(int64(rotation))
+	}
+
+	if def.FillColor != nil {
+		annot.C = core.MakeArrayFromFloats([]float64{
+			def.FillColor.R(), def.FillColor.G(), def.FillColor.B(),
+		})
+	}
+	bs := model.NewBorderStyle()
+	bs.SetBorderWidth(borderWidth)
+	annot.BS = bs.ToPdfObject()
+
+	// Annotation rectangle, including the callout line.
+	rect := box
+	if len(def.Callout) > 0 {
+		cl := make([]float64, 0, 2*len(def.Callout))
+		for _, p := range def.Callout {
+			cl = append(cl, p.X, p.Y)
+		}
+		annot.CL = core.MakeArrayFromFloats(cl)
+		annot.IT = core.MakeName("FreeTextCallout")
+		annot.LE = core.MakeName(freeTextLineEndingName(def.CalloutLineEnding))
+
+		margin := math.Max(borderWidth, 1)
+		if def.CalloutLineEnding != draw.LineEndingStyleNone {
+			margin *= 4
+		}
+		for _, p := range def.Callout {
+			rect.Llx = math.Min(rect.Llx, p.X-margin)
+			rect.Lly = math.Min(rect.Lly, p.Y-margin)
+			rect.Urx = math.Max(rect.Urx, p.X+margin)
+			rect.Ury = math.Max(rect.Ury, p.Y+margin)
+		}
+	}
+	annot.Rect = rect.ToPdfObject()
+	annot.RD = core.MakeArrayFromFloats([]float64{
+		box.Llx - rect.Llx, box.Lly - rect.Lly,
+		rect.Urx - box.Urx, rect.Ury - box.Ury,
+	})
+
+	apDict, err := makeFreeTextAppearanceStream(def, &rect, font, fontname, fontsize, textColor, borderWidth)
+	if err != nil {
+		return nil, err
+	}
+	annot.AP = apDict
+
+	annot.Contents = textstring.Make(def.Text)
+	annot.F = core.MakeInteger(4) // 4 (100 -> Print/show annotations).
+	if date, err := model.NewPdfDateFromTime(time.Now()); err == nil {
+		annot.M = date.ToPdfObject()
+		annot.CreationDate = date.ToPdfObject()
+	}
+	if def.Author != "" {
+		annot.T = textstring.Make(def.Author)
+	}
+
+	return annot.PdfAnnotation, nil
+}
+
+func makeFreeTextAppearanceStream(def FreeTextAnnotationDef, rect *model.PdfRectangle, font *model.PdfFont,
+	fontname core.PdfObjectName, fontsize float64, textColor *model.PdfColorDeviceRGB, borderWidth float64) (*core.PdfObjectDictionary, error) {
+	form := model.NewXObjectForm()
+	form.Resources = model.NewPdfPageResources()
+	if err := form.Resources.SetFontByName(fontname, font.ToPdfObject()); err != nil {
+		common.Log.Debug("Unable to add font %s", fontname)
+		return nil, err
+	}
+
+	box := def.Rect
+	cc := contentstream.NewContentCreator()
+
+	// Callout line.
+	if len(def.Callout) > 0 {
+		cc.Add_q().
+			Add_w(math.Max(borderWidth, 1)).
+			SetStrokingColor(freeTextLineColor(def)).
+			SetNonStrokingColor(freeTextLineColor(def))
+		drawFreeTextCallout(cc, def.Callout, def.CalloutLineEnding, math.Max(borderWidth, 1))
+		cc.Add_Q()
+	}
+
+	// Text box background and border.
+	if def.FillColor != nil || borderWidth > 0 {
+		cc.Add_q().Add_re(box.Llx+borderWidth/2, box.Lly+borderWidth/2,
+			box.Width()-borderWidth, box.Height()-borderWidth)
+		switch {
+		case def.FillColor != nil && borderWidth > 0:
+			cc.Add_w(borderWidth).
+				SetStrokingColor(def.BorderColor).
+				SetNonStrokingColor(def.FillColor).
+				Add_B()
+		case def.FillColor != nil:
+			cc.SetNonStrokingColor(def.FillColor).Add_f()
+		default:
+			cc.Add_w(borderWidth).SetStrokingColor(def.BorderColor).Add_S()
+		}
+		cc.Add_Q()
+	}
+
+	// Text.
+	cc.Add_q().Translate(box.Llx, box.Lly)
+	width, height := box.Width(), box.Height()
+	if def.Rotation != 0 {
+		// Calculate bounding box before rotation.
+		rotation := float64(def.Rotation)
+		bbox := draw.Path{Points: []draw.Point{
+			draw.NewPoint(0, 0).Rotate(-rotation),
+			draw.NewPoint(width, 0).Rotate(-rotation),
+			draw.NewPoint(0, height).Rotate(-rotation),
+			draw.NewPoint(width, height).Rotate(-rotation),
+		}}.GetBoundingBox()
+
+		width = bbox.Width
+		height = bbox.Height
+
+		cc.RotateDeg(rotation)
+		cc.Translate(bbox.X, bbox.Y)
+	}
+
+	inset := borderWidth + freeTextPadding
+	cc.Add_re(borderWidth, borderWidth, width-2*borderWidth, height-2*borderWidth).
+		Add_W().
+		Add_n()
+
+	text := strings.Replace(def.Text, "\r\n", "\n", -1)
+	text = strings.Replace(text, "\r", "\n", -1)
+	lines := wrapTextLines(font, strings.Split(text, "\n"), fontsize, width-2*inset)
+
+	encoder := font.Encoder()
+	if encoder == nil {
+		common.Log.Debug("WARN: font encoder is nil. Assuming identity encoder. Output may be incorrect.")
+		encoder = textencoding.NewIdentityTextEncoder("Identity-H")
+	}
+
+	lineheight := 1.2 * fontsize
+	cc.Add_BT().
+		SetNonStrokingColor(textColor).
+		Add_Tf(fontname, fontsize).
+		Add_TL(lineheight).
+		Add_Td(inset, height-inset-fontsize)
+	for i, line := range lines {
+		if i > 0 {
+			cc.Add_Td(0, -lineheight)
+		}
+		cc.Add_Tj(*core.MakeStringFromBytes(encoder.Encode(line)))
+	}
+	cc.Add_ET().Add_Q()
+
+	if err := form.SetContentStream(cc.Bytes(), defStreamEncoder()); err != nil {
+		return nil, err
+	}
+
+	// The callout and the text box are in page coordinates, inside the annotation
+	// rectangle.
+	form.BBox = rect.ToPdfObject()
+
+	apDict := core.MakeDict()
+	apDict.Set("N", form.ToPdfObject())
+	return apDict, nil
+}
+
+// addFreeTextFormFont adds `font` to the default resources (DR) of `form` and
+// returns its name in the resources. If `isDefault` is true, `font` is the
+// default Helvetica font, named Helv, which is not added if the form already
+// has a font named Helv, as the name conventionally refers to Helvetica.
+// Otherwise, the font is named FT followed by the first number not used by
+// another font.
+func addFreeTextFormFont(form *model.PdfAcroForm, font *model.PdfFont, isDefault bool) (core.PdfObjectName, error) {
+	if form.DR == nil {
+		form.DR = model.NewPdfPageResources()
+	}
+
+	fontObj := font.ToPdfObject()
+	fontname := core.PdfObjectName("Helv")
+	for i := 0; !isDefault; i++ {
+		fontname = core.PdfObjectName(fmt.Sprintf("FT%d", i))
+		if obj, has := form.DR.GetFontByName(fontname); !has || obj == fontObj {
+			break
+		}
+	}
+	if form.DR.HasFontByName(fontname) {
+		return fontname, nil
+	}
+
+	if err := form.DR.SetFontByName(fontname, fontObj); err != nil {
+		common.Log.Debug("Unable to add font %s to the form resources", fontname)
+		return "", err
+	}
+	return fontname, nil
+}
+
+// drawFreeTextCallout draws the callout line through `points`, with the
+// specified line ending at the first point.
+func drawFreeTextCallout(cc *contentstream.ContentCreator, points []draw.Point,
+	ending draw.LineEndingStyle, lineWidth float64) {
+	cc.Add_m(points[0].X, points[0].Y)
+	for _, p := range points[1:] {
+		cc.Add_l(p.X, p.Y)
+	}
+	cc.Add_S()
+
+	// Direction of the first segment, pointing at the start point.
+	dx, dy := points[0].X-points[1].X, points[0].Y-points[1].Y
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return
+	}
+	dx, dy = dx/length, dy/length
+
+	size := 4 * lineWidth
+	p := points[0]
+	switch ending {
+	case draw.LineEndingStyleArrow:
+		cc.Add_m(p.X, p.Y).
+			Add_l(p.X-size*dx-size/2*dy, p.Y-size*dy+size/2*dx).
+			Add_l(p.X-size*dx+size/2*dy, p.Y-size*dy-size/2*dx).
+			Add_h().
+			Add_B()
+	case draw.LineEndingStyleButt:
+		cc.Add_m(p.X-size/2*dy, p.Y+size/2*dx).
+			Add_l(p.X+size/2*dy, p.Y-size/2*dx).
+			Add_S()
+	}
+}
+
+// freeTextLineColor returns the color of the callout line.
+func freeTextLineColor(def FreeTextAnnotationDef) *model.PdfColorDeviceRGB {
+	if def.BorderColor != nil {
+		return def.BorderColor
+	}
+	if def.TextColor != nil {
+		return def.TextColor
+	}
+	return model.NewPdfColorDeviceRGB(0, 0, 0)
+}
+
+// freeTextLineEndingName returns the name of the line ending style (LE entry).
+func freeTextLineEndingName(style draw.LineEndingStyle) string {
+	switch style {
+	case draw.LineEndingStyleArrow:
+		return "ClosedArrow"
+	case draw.LineEndingStyleButt:
+		return "Butt"
+	}
+	return "None"
+}
+
+// freeTextFontFamily returns the font family used in the default style
+// string, derived from the base font name.
+func freeTextFontFamily(font *model.PdfFont) string {
+	family := font.BaseFont()
+	if i := strings.IndexByte(family, '+'); i == 6 {
+		family = family[i+1:] // Subset tag.
+	}
+	if i := strings.IndexAny(family, "-,"); i > 0 {
+		family = family[:i]
+	}
+	if family == "" {
+		family = "Helvetica"
+	}
+	return family
+}
+
+// freeTextRichText returns the rich text string (RC entry) containing the
+// paragraphs of `text` as a minimal XHTML body.
+func freeTextRichText(text, style string) string {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0"?><body xmlns="http://www.w3.org/1999/xhtml" ` +
+		`xmlns:xfa="http://www.xfa.org/schema/xfa-data/1.0/" xfa:APIVersion="Acrobat:11.0.0" xfa:spec="2.0.2" style="`)
+	xml.EscapeText(&buf, []byte(style))
+	buf.WriteString(`">`)
+
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	text = strings.Replace(text, "\r", "\n", -1)
+	for _, paragraph := range strings.Split(text, "\n") {
+		buf.WriteString("<p>")
+		xml.EscapeText(&buf, []byte(paragraph))
+		buf.WriteString("</p>")
+	}
+	buf.WriteString("</body>")
+	return buf.String()
+}
+
+// formatFloat formats `val` with up to 4 decimals and no trailing zeros.
+func formatFloat(val float64) string {
+	s := fmt.Sprintf("%.4f", val)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// formatRGB formats the components of `color` as PDF color operands.
+func formatRGB(color *model.PdfColorDeviceRGB) string {
+	return fmt.Sprintf("%s %s %s", formatFloat(color.R()), formatFloat(color.G()), formatFloat(color.B()))
+}
+
+// formatHexRGB formats `color` as a CSS hexadecimal color.
+func formatHexRGB(color *model.PdfColorDeviceRGB) string {
+	return fmt.Sprintf("#%02x%02x%02x",
+		int(math.Round(color.R()*255)), int(math.Round(color.G()*255)), int(math.Round(color.B()*255)))
+}
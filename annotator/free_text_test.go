@@ -0,0 +1,269 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package annotator
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/contentstream/draw"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/creator"
+	"github.com/unidoc/unipdf/v3/extractor"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+var updateGolden = flag.Bool("annotator-update-goldens", false, "update the golden files")
+
+// checkGolden compares the content stream `data`, normalized and indented
+// for readable diffs, with the contents of the specified golden file in the
+// testdata directory. The golden file is rewritten when running the tests
+// with the -annotator-update-goldens flag.
+func checkGolden(t *testing.T, name string, data []byte) {
+	data, err := contentstream.Normalize(string(data), &contentstream.NormalizeOptions{
+		Precision: -1,
+		Indent:    "  ",
+		Strict:    true,
+	})
+	require.NoError(t, err)
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		require.NoError(t, ioutil.WriteFile(path, data, 0644))
+	}
+	golden, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(golden), string(data))
+}
+
+func TestFreeTextCallout(t *testing.T) {
+	c := creator.New()
+	c.NewPage()
+	p := c.NewParagraph("The quick brown fox jumps over the lazy dog")
+	p.SetPos(100, 100)
+	require.NoError(t, c.Draw(p))
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, c.Write(buf))
+
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	page, err := reader.GetPage(1)
+	require.NoError(t, err)
+
+	// Locate the word the callout points at.
+	quads := mergeTextMarkupQuads(findPhraseQuads(t, page, "fox"))
+	require.Len(t, quads, 1)
+	word := textMarkupQuadsBBox(quads)
+	target := draw.NewPoint((word.Llx+word.Urx)/2, word.Ury)
+
+	box := model.PdfRectangle{Llx: 300, Lly: 600, Urx: 400, Ury: 660}
+	annot, err := CreateFreeTextAnnotation(FreeTextAnnotationDef{
+		Rect:              box,
+		Text:              "The fox is quick & brown.\nIt jumps.",
+		FontSize:          10,
+		TextColor:         model.NewPdfColorDeviceRGB(0, 0, 1),
+		FillColor:         model.NewPdfColorDeviceRGB(1, 1, 0.8),
+		BorderColor:       model.NewPdfColorDeviceRGB(1, 0, 0),
+		Callout:           []draw.Point{target, draw.NewPoint(target.X, 630), draw.NewPoint(box.Llx, 630)},
+		CalloutLineEnding: draw.LineEndingStyleArrow,
+		Author:            "Reviewer",
+	})
+	require.NoError(t, err)
+	page.AddAnnotation(annot)
+
+	// Write and reload the annotated page.
+	w := model.NewPdfWriter()
+	require.NoError(t, w.AddPage(page))
+	buf.Reset()
+	require.NoError(t, w.Write(buf))
+	reader, err = model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	page, err = reader.GetPage(1)
+	require.NoError(t, err)
+	annots, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 1)
+
+	freeText, ok := annots[0].GetContext().(*model.PdfAnnotationFreeText)
+	require.True(t, ok)
+	require.Equal(t, "/Helv 10 Tf 0 0 1 rg 1 0 0 RG", freeText.DA.(*core.PdfObjectString).Decoded())
+	require.Equal(t, "font: Helvetica 10pt; text-align:left; color:#0000ff", freeText.DS.(*core.PdfObjectString).Decoded())
+	require.Equal(t, "FreeTextCallout", freeText.IT.String())
+	require.Equal(t, "ClosedArrow", freeText.LE.String())
+
+	rc, ok := core.GetString(freeText.RC)
+	require.True(t, ok)
+	require.True(t, strings.HasSuffix(rc.Decoded(),
+		`"><p>The fox is quick &amp; brown.</p><p>It jumps.</p></body>`), rc.Decoded())
+
+	// The callout starts at the word and the rectangle covers both the text
+	// box and the callout line.
+	clArr, ok := core.GetArray(freeText.CL)
+	require.True(t, ok)
+	cl, err := clArr.ToFloat64Array()
+	require.NoError(t, err)
+	require.Len(t, cl, 6)
+	require.Equal(t, []float64{target.X, target.Y}, cl[:2])
+
+	rectArr, ok := core.GetArray(freeText.Rect)
+	require.True(t, ok)
+	rect, err := model.NewPdfRectangle(*rectArr)
+	require.NoError(t, err)
+	require.True(t, rect.Ury > target.Y && rect.Lly == box.Lly && rect.Urx == box.Urx)
+	rdArr, ok := core.GetArray(freeText.RD)
+	require.True(t, ok)
+	rd, err := rdArr.ToFloat64Array()
+	require.NoError(t, err)
+	require.Equal(t, box.Ury, rect.Ury-rd[3])
+
+	// The normal appearance matches the golden content stream.
+	apDict, ok := core.GetDict(freeText.AP)
+	require.True(t, ok)
+	stream, ok := core.GetStream(apDict.Get("N"))
+	require.True(t, ok)
+	form, err := model.NewXObjectFormFromStream(stream)
+	require.NoError(t, err)
+	_, has := form.Resources.GetFontByName("Helv")
+	require.True(t, has)
+	data, err := core.DecodeStream(stream)
+	require.NoError(t, err)
+	checkGolden(t, "freetext_callout.golden", data)
+
+	ops := parseAppearanceOps(t, apDict, "N", "")
+	var text []string
+	for _, op := range *ops {
+		if op.Operand == "Tj" {
+			str, ok := core.GetString(op.Params[0])
+			require.True(t, ok)
+			text = append(text, str.String())
+		}
+	}
+	require.Equal(t, []string{"The fox is quick &", "brown.", "It jumps."}, text)
+
+	// The text is readable from the appearance.
+	ex, err := extractor.New(page)
+	require.NoError(t, err)
+	pageText, _, _, err := ex.ExtractPageText()
+	require.NoError(t, err)
+	require.Contains(t, pageText.Text(), "fox")
+}
+
+func TestFreeTextRotation(t *testing.T) {
+	box := model.PdfRectangle{Llx: 10, Lly: 10, Urx: 40, Ury: 110}
+	annot, err := CreateFreeTextAnnotation(FreeTextAnnotationDef{
+		Rect:     box,
+		Text:     "Rotated",
+		Rotation: 90,
+	})
+	require.NoError(t, err)
+
+	rectArr, ok := core.GetArray(annot.Rect)
+	require.True(t, ok)
+	rect, err := model.NewPdfRectangle(*rectArr)
+	require.NoError(t, err)
+	require.Equal(t, box, *rect)
+
+	apDict, ok := core.GetDict(annot.AP)
+	require.True(t, ok)
+	ops := parseAppearanceOps(t, apDict, "N", "")
+	var numCm int
+	for _, op := range *ops {
+		if op.Operand == "cm" {
+			numCm++
+		}
+	}
+	require.Equal(t, 3, numCm)
+
+	// The rotation is stored in the Rotate entry, as done by Acrobat.
+	annotDict, ok := core.GetDict(annot.GetContext().ToPdfObject())
+	require.True(t, ok)
+	require.Equal(t, core.MakeInteger(90), annotDict.Get("Rotate"))
+
+	annot, err = CreateFreeTextAnnotation(FreeTextAnnotationDef{Rect: box, Rotation: -90})
+	require.NoError(t, err)
+	require.Equal(t, core.MakeInteger(270), annot.GetContext().(*model.PdfAnnotationFreeText).Rotate)
+	annot, err = CreateFreeTextAnnotation(FreeTextAnnotationDef{Rect: box})
+	require.NoError(t, err)
+	require.Nil(t, annot.GetContext().(*model.PdfAnnotationFreeText).Rotate)
+
+	_, err = CreateFreeTextAnnotation(FreeTextAnnotationDef{Rect: box, Rotation: 45})
+	require.Error(t, err)
+	_, err = CreateFreeTextAnnotation(FreeTextAnnotationDef{
+		Rect:    box,
+		Callout: []draw.Point{draw.NewPoint(0, 0)},
+	})
+	require.Error(t, err)
+}
+
+func TestFreeTextFormFont(t *testing.T) {
+	box := model.PdfRectangle{Llx: 10, Lly: 10, Urx: 110, Ury: 40}
+
+	// checkFont checks that the default appearance of `annot` uses the font
+	// `name`, defined in the form and appearance resources.
+	checkFont := func(form *model.PdfAcroForm, annot *model.PdfAnnotation, name core.PdfObjectName,
+		font *model.PdfFont) {
+		ft := annot.GetContext().(*model.PdfAnnotationFreeText)
+		da, ok := core.GetString(ft.DA)
+		require.True(t, ok)
+		require.True(t, strings.HasPrefix(da.Str(), "/"+string(name)+" "), da.Str())
+
+		obj, has := form.DR.GetFontByName(name)
+		require.True(t, has)
+		if font != nil {
+			require.True(t, obj == font.ToPdfObject())
+		}
+
+		apDict, ok := core.GetDict(ft.AP)
+		require.True(t, ok)
+		stream, ok := core.GetStream(apDict.Get("N"))
+		require.True(t, ok)
+		xform, err := model.NewXObjectFormFromStream(stream)
+		require.NoError(t, err)
+		require.True(t, xform.Resources.HasFontByName(name))
+	}
+
+	form := model.NewPdfAcroForm()
+	annot, err := CreateFreeTextAnnotation(FreeTextAnnotationDef{Rect: box, Text: "Helvetica", Form: form})
+	require.NoError(t, err)
+	checkFont(form, annot, "Helv", nil)
+	helv, _ := form.DR.GetFontByName("Helv")
+
+	// The Helv font of the form is reused.
+	annot, err = CreateFreeTextAnnotation(FreeTextAnnotationDef{Rect: box, Text: "Again", Form: form})
+	require.NoError(t, err)
+	checkFont(form, annot, "Helv", nil)
+	obj, _ := form.DR.GetFontByName("Helv")
+	require.True(t, obj == helv)
+
+	// Other fonts are added under unused names.
+	courier, err := model.NewStandard14Font(model.CourierName)
+	require.NoError(t, err)
+	times, err := model.NewStandard14Font(model.TimesRomanName)
+	require.NoError(t, err)
+	for _, tc := range []struct {
+		font *model.PdfFont
+		name core.PdfObjectName
+	}{
+		{courier, "FT0"},
+		{times, "FT1"},
+		{courier, "FT0"},
+	} {
+		annot, err = CreateFreeTextAnnotation(FreeTextAnnotationDef{
+			Rect: box,
+			Text: "Font",
+			Font: tc.font,
+			Form: form,
+		})
+		require.NoError(t, err)
+		checkFont(form, annot, tc.name, tc.font)
+	}
+}
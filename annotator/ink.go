@@ -0,0 +1,56 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package annotator
+
+import (
+	"errors"
+
+	"github.com/unidoc/unipdf/v3/contentstream/draw"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// InkAnnotationDef defines an ink annotation (freehand drawing) consisting of
+// one or more stroked paths through the specified points, in page coordinates.
+type InkAnnotationDef struct {
+	Paths     [][]draw.Point
+	Color     *model.PdfColorDeviceRGB // Defaults to black if not specified.
+	LineWidth float64                  // Defaults to 1 if not specified.
+	Opacity   float64                  // Alpha value (0-1). Ignored if not in (0, 1).
+	Smooth    bool                     // Draw smooth curves through the points.
+	Author    string                   // Author of the annotation (T entry).
+}
+
+// CreateInkAnnotation creates an ink annotation object that can be added to
+// page PDF annotations.
+func CreateInkAnnotation(def InkAnnotationDef) (*model.PdfAnnotation, error) {
+	var numPoints int
+	inkList := make([][]float64, 0, len(def.Paths))
+	for _, path := range def.Paths {
+		inkList = append(inkList, pointsToFloats(path))
+		numPoints += len(path)
+	}
+	if numPoints == 0 {
+		return nil, errors.New("ink paths not specified")
+	}
+
+	annot := model.NewPdfAnnotationInk()
+	annot.SetInkList(inkList)
+
+	style := pathAnnotationStyle{
+		paths:     def.Paths,
+		smooth:    def.Smooth,
+		lineColor: def.Color,
+		lineWidth: def.LineWidth,
+		opacity:   def.Opacity,
+	}
+	bs, err := style.apply(annot.PdfAnnotation, annot.PdfAnnotationMarkup, def.Author)
+	if err != nil {
+		return nil, err
+	}
+	annot.BS = bs
+
+	return annot.PdfAnnotation, nil
+}
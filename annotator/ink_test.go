@@ -0,0 +1,106 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package annotator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/contentstream/draw"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// countAppearanceOps returns the number of occurrences of each operator in
+// the normal appearance of `annot`.
+func countAppearanceOps(t *testing.T, annot *model.PdfAnnotation) map[string]int {
+	apDict, ok := core.GetDict(annot.AP)
+	require.True(t, ok)
+
+	counts := map[string]int{}
+	for _, op := range *parseAppearanceOps(t, apDict, "N", "") {
+		counts[op.Operand]++
+	}
+	return counts
+}
+
+func TestInkAnnotation(t *testing.T) {
+	paths := [][]draw.Point{
+		{draw.NewPoint(10, 10), draw.NewPoint(20, 40), draw.NewPoint(30, 10), draw.NewPoint(40, 40)},
+		{draw.NewPoint(50, 20), draw.NewPoint(60, 20)},
+	}
+
+	annot, err := CreateInkAnnotation(InkAnnotationDef{
+		Paths:     paths,
+		Color:     model.NewPdfColorDeviceRGB(0, 0, 1),
+		LineWidth: 2,
+		Opacity:   0.8,
+	})
+	require.NoError(t, err)
+	ink, ok := annot.GetContext().(*model.PdfAnnotationInk)
+	require.True(t, ok)
+	inkList, err := ink.GetInkList()
+	require.NoError(t, err)
+	require.Equal(t, [][]float64{{10, 10, 20, 40, 30, 10, 40, 40}, {50, 20, 60, 20}}, inkList)
+
+	rectArr, ok := core.GetArray(annot.Rect)
+	require.True(t, ok)
+	rect, err := rectArr.ToFloat64Array()
+	require.NoError(t, err)
+	require.Equal(t, []float64{8, 8, 62, 42}, rect)
+
+	counts := countAppearanceOps(t, annot)
+	require.Equal(t, 2, counts["S"])
+	require.Equal(t, 4, counts["l"])
+	require.Equal(t, 1, counts["gs"])
+	require.Equal(t, 0, counts["c"])
+
+	// Smoothed strokes are drawn as curves within the annotation rectangle.
+	annot, err = CreateInkAnnotation(InkAnnotationDef{Paths: paths, Smooth: true})
+	require.NoError(t, err)
+	counts = countAppearanceOps(t, annot)
+	require.Equal(t, 4, counts["c"])
+	require.Equal(t, 0, counts["l"])
+
+	_, err = CreateInkAnnotation(InkAnnotationDef{})
+	require.Error(t, err)
+}
+
+func TestPolygonAnnotation(t *testing.T) {
+	vertices := []draw.Point{draw.NewPoint(10, 10), draw.NewPoint(50, 10), draw.NewPoint(30, 40)}
+
+	annot, err := CreatePolygonAnnotation(PolygonAnnotationDef{
+		Vertices:  vertices,
+		FillColor: model.NewPdfColorDeviceRGB(1, 0, 0),
+	})
+	require.NoError(t, err)
+	polygon, ok := annot.GetContext().(*model.PdfAnnotationPolygon)
+	require.True(t, ok)
+	coords, err := polygon.GetVertices()
+	require.NoError(t, err)
+	require.Equal(t, []float64{10, 10, 50, 10, 30, 40}, coords)
+	require.NotNil(t, polygon.IC)
+
+	counts := countAppearanceOps(t, annot)
+	require.Equal(t, 1, counts["h"])
+	require.Equal(t, 1, counts["B"])
+
+	annot, err = CreatePolyLineAnnotation(PolygonAnnotationDef{Vertices: vertices})
+	require.NoError(t, err)
+	polyline, ok := annot.GetContext().(*model.PdfAnnotationPolyLine)
+	require.True(t, ok)
+	coords, err = polyline.GetVertices()
+	require.NoError(t, err)
+	require.Equal(t, []float64{10, 10, 50, 10, 30, 40}, coords)
+
+	counts = countAppearanceOps(t, annot)
+	require.Equal(t, 0, counts["h"])
+	require.Equal(t, 1, counts["S"])
+
+	_, err = CreatePolyLineAnnotation(PolygonAnnotationDef{Vertices: vertices[:1]})
+	require.Error(t, err)
+}
@@ -78,15 +78,14 @@ func makeLineAnnotationAppearanceStream(lineDef LineAnnotationDef) (*pdfcore.Pdf
 	gsName := ""
 	if lineDef.Opacity < 1.0 {
 		// Create graphics state with right opacity.
-		gsState := pdfcore.MakeDict()
-		gsState.Set("ca", pdfcore.MakeFloat(lineDef.Opacity))
-		err := form.Resources.AddExtGState("gs1", gsState)
+		gsState := pdf.NewPdfExtGState().SetNonStrokingAlpha(lineDef.Opacity)
+		name, err := pdf.NewResourceManager(form.Resources).AddExtGState(gsState.ToPdfObject())
 		if err != nil {
-			common.Log.Debug("Unable to add extgstate gs1")
+			common.Log.Debug("Unable to add extgstate")
 			return nil, nil, err
 		}
 
-		gsName = "gs1"
+		gsName = string(name)
 	}
 
 	content, localBbox, globalBbox, err := drawPdfLine(lineDef, gsName)
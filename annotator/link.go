@@ -0,0 +1,105 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package annotator
+
+import (
+	"errors"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// LinkAnnotationDef defines a link annotation covering Rect. Exactly one of
+// Dest, NamedDest and Action must be specified.
+type LinkAnnotationDef struct {
+	Rect        model.PdfRectangle
+	Dest        *model.PdfDestination // Explicit destination in the document.
+	NamedDest   string                // Named destination (Dests name tree).
+	Action      *model.PdfAction      // Action performed when the link is activated.
+	BorderWidth float64               // No border is drawn if not specified.
+}
+
+// CreateLinkAnnotation creates a link annotation object that can be added to
+// page PDF annotations. Named destinations must be added to the document
+// using PdfWriter.AddNamedDestination.
+func CreateLinkAnnotation(def LinkAnnotationDef) (*model.PdfAnnotation, error) {
+	targets := 0
+	for _, set := range []bool{def.Dest != nil, def.NamedDest != "", def.Action != nil} {
+		if set {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return nil, errors.New("link requires exactly one destination or action")
+	}
+
+	annot := model.NewPdfAnnotationLink()
+	annot.Rect = def.Rect.ToPdfObject()
+	annot.F = core.MakeInteger(4) // 4 (100 -> Print/show annotations).
+
+	bs := model.NewBorderStyle()
+	bs.SetBorderWidth(def.BorderWidth)
+	annot.BS = bs.ToPdfObject()
+
+	switch {
+	case def.Dest != nil:
+		annot.Dest = def.Dest.ToPdfObject()
+	case def.NamedDest != "":
+		annot.Dest = core.MakeString(def.NamedDest)
+	default:
+		annot.SetAction(def.Action)
+	}
+
+	return annot.PdfAnnotation, nil
+}
+
+// NewGoToRAction returns a "go to remote" action, opening the document at
+// `filePath` at the explicit destination `dest` or at the named destination
+// `namedDest`. The page of explicit destinations must be specified through
+// their PageIndex. If `newWindow` is true, the document is opened in a new
+// window.
+func NewGoToRAction(filePath string, dest *model.PdfDestination, namedDest string, newWindow bool) (*model.PdfAction, error) {
+	if filePath == "" {
+		return nil, errors.New("remote file not specified")
+	}
+
+	action := model.NewPdfActionGoToR()
+	fs := model.NewPdfFilespec()
+	fs.F = core.MakeString(filePath)
+	fs.UF = core.MakeEncodedString(filePath, true)
+	action.F = fs
+
+	switch {
+	case dest != nil && namedDest != "":
+		return nil, errors.New("only one destination can be specified")
+	case dest != nil:
+		if dest.Page != nil {
+			return nil, errors.New("remote destinations must specify a page index")
+		}
+		action.D = dest.ToPdfObject()
+	case namedDest != "":
+		action.D = core.MakeString(namedDest)
+	default:
+		action.D = (&model.PdfDestination{Mode: model.DestinationModeFit}).ToPdfObject()
+	}
+	if newWindow {
+		action.NewWindow = core.MakeBool(true)
+	}
+
+	return action.PdfAction, nil
+}
+
+// NewURIAction returns an action resolving `uri`. If `isMap` is true, the
+// coordinates of the mouse position are appended to the URI when the link
+// is activated.
+func NewURIAction(uri string, isMap bool) *model.PdfAction {
+	action := model.NewPdfActionURI()
+	action.URI = core.MakeString(uri)
+	if isMap {
+		action.IsMap = core.MakeBool(true)
+	}
+	return action.PdfAction
+}
@@ -0,0 +1,137 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package annotator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+func TestLinkAnnotations(t *testing.T) {
+	page1 := model.NewPdfPage()
+	page2 := model.NewPdfPage()
+	top := 500.0
+
+	w := model.NewPdfWriter()
+	require.NoError(t, w.AddNamedDestination("chapter2",
+		model.NewPdfDestinationFitH(page2.GetPageAsIndirectObject(), &top)))
+
+	var defs []LinkAnnotationDef
+	for i := 0; i < 2; i++ {
+		defs = append(defs, LinkAnnotationDef{
+			Rect:      model.PdfRectangle{Llx: 50, Lly: 700 - float64(i)*50, Urx: 200, Ury: 720 - float64(i)*50},
+			NamedDest: "chapter2",
+		})
+	}
+	defs = append(defs, LinkAnnotationDef{
+		Rect: model.PdfRectangle{Llx: 50, Lly: 500, Urx: 200, Ury: 520},
+		Dest: model.NewPdfDestinationXYZ(page2.GetPageAsIndirectObject(), nil, &top, nil),
+	})
+
+	remoteDest := &model.PdfDestination{PageIndex: 2, Mode: model.DestinationModeFitB}
+	goToR, err := NewGoToRAction("other.pdf", remoteDest, "", true)
+	require.NoError(t, err)
+	defs = append(defs, LinkAnnotationDef{
+		Rect:   model.PdfRectangle{Llx: 50, Lly: 400, Urx: 200, Ury: 420},
+		Action: goToR,
+	})
+	defs = append(defs, LinkAnnotationDef{
+		Rect:   model.PdfRectangle{Llx: 50, Lly: 300, Urx: 200, Ury: 320},
+		Action: NewURIAction("https://example.com/map", true),
+	})
+
+	for _, def := range defs {
+		annot, err := CreateLinkAnnotation(def)
+		require.NoError(t, err)
+		page1.AddAnnotation(annot)
+	}
+	require.NoError(t, w.AddPage(page1))
+	require.NoError(t, w.AddPage(page2))
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, w.Write(buf))
+
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	// The shared destination is stored once in the Dests name tree.
+	namesObj, err := reader.GetNamedDestinations()
+	require.NoError(t, err)
+	names, ok := core.GetDict(namesObj)
+	require.True(t, ok)
+	dests, ok := core.GetDict(names.Get("Dests"))
+	require.True(t, ok)
+	entries, ok := core.GetArray(dests.Get("Names"))
+	require.True(t, ok)
+	require.Equal(t, 2, entries.Len())
+
+	page, err := reader.GetPage(1)
+	require.NoError(t, err)
+	annots, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 5)
+
+	var links []*model.PdfAnnotationLink
+	for _, annot := range annots {
+		link, ok := annot.GetContext().(*model.PdfAnnotationLink)
+		require.True(t, ok)
+		links = append(links, link)
+	}
+
+	for _, link := range links[:2] {
+		dest, err := link.GetDestination()
+		require.NoError(t, err)
+		require.NotNil(t, dest)
+		require.Equal(t, model.DestinationModeFitH, dest.Mode)
+		require.Equal(t, int64(1), dest.PageIndex)
+		require.NotNil(t, dest.Top)
+		require.Equal(t, top, *dest.Top)
+	}
+
+	dest, err := links[2].GetDestination()
+	require.NoError(t, err)
+	require.Equal(t, model.DestinationModeXYZ, dest.Mode)
+	require.Equal(t, int64(1), dest.PageIndex)
+	require.Nil(t, dest.Left)
+	require.Nil(t, dest.Zoom)
+	require.Equal(t, top, *dest.Top)
+
+	action, err := links[3].GetAction()
+	require.NoError(t, err)
+	remote, ok := action.GetContext().(*model.PdfActionGoToR)
+	require.True(t, ok)
+	fileName, ok := core.GetString(remote.F.F)
+	require.True(t, ok)
+	require.Equal(t, "other.pdf", fileName.Str())
+	require.Equal(t, core.MakeBool(true), core.TraceToDirectObject(remote.NewWindow))
+	remoteArr, ok := core.GetArray(remote.D)
+	require.True(t, ok)
+	require.Equal(t, "[2 /FitB]", remoteArr.WriteString())
+	dest, err = links[3].GetDestination()
+	require.NoError(t, err)
+	require.Nil(t, dest)
+
+	action, err = links[4].GetAction()
+	require.NoError(t, err)
+	uri, ok := action.GetContext().(*model.PdfActionURI)
+	require.True(t, ok)
+	uriStr, ok := core.GetString(uri.URI)
+	require.True(t, ok)
+	require.Equal(t, "https://example.com/map", uriStr.Str())
+	isMap, ok := core.GetBoolVal(uri.IsMap)
+	require.True(t, ok)
+	require.True(t, isMap)
+
+	_, err = CreateLinkAnnotation(LinkAnnotationDef{})
+	require.Error(t, err)
+	_, err = NewGoToRAction("other.pdf", model.NewPdfDestinationFit(page1.GetPageAsIndirectObject()), "", false)
+	require.Error(t, err)
+}
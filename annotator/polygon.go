@@ -0,0 +1,262 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package annotator
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/contentstream/draw"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/textstring"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// PolygonAnnotationDef defines a polygon or polyline annotation through the
+// specified vertices, in page coordinates. Polygons are closed and can
+// optionally be filled, polylines are open paths.
+type PolygonAnnotationDef struct {
+	Vertices  []draw.Point
+	LineColor *model.PdfColorDeviceRGB // Defaults to black if not specified.
+	FillColor *model.PdfColorDeviceRGB // No fill if not specified. Ignored for polylines.
+	LineWidth float64                  // Defaults to 1 if not specified.
+	Opacity   float64                  // Alpha value (0-1). Ignored if not in (0, 1).
+	Author    string                   // Author of the annotation (T entry).
+}
+
+// CreatePolygonAnnotation creates a polygon annotation object that can be
+// added to page PDF annotations.
+func CreatePolygonAnnotation(def PolygonAnnotationDef) (*model.PdfAnnotation, error) {
+	if len(def.Vertices) < 2 {
+		return nil, errors.New("polygon requires at least 2 vertices")
+	}
+
+	annot := model.NewPdfAnnotationPolygon()
+	annot.SetVertices(pointsToFloats(def.Vertices))
+	if def.FillColor != nil {
+		annot.IC = core.MakeArrayFromFloats([]float64{def.FillColor.R(), def.FillColor.G(), def.FillColor.B()})
+	}
+
+	path := pathAnnotationStyle{
+		paths:     [][]draw.Point{def.Vertices},
+		closed:    true,
+		lineColor: def.LineColor,
+		fillColor: def.FillColor,
+		lineWidth: def.LineWidth,
+		opacity:   def.Opacity,
+	}
+	bs, err := path.apply(annot.PdfAnnotation, annot.PdfAnnotationMarkup, def.Author)
+	if err != nil {
+		return nil, err
+	}
+	annot.BS = bs
+
+	return annot.PdfAnnotation, nil
+}
+
+// CreatePolyLineAnnotation creates a polyline annotation object that can be
+// added to page PDF annotations.
+func CreatePolyLineAnnotation(def PolygonAnnotationDef) (*model.PdfAnnotation, error) {
+	if len(def.Vertices) < 2 {
+		return nil, errors.New("polyline requires at least 2 vertices")
+	}
+
+	annot := model.NewPdfAnnotationPolyLine()
+	annot.SetVertices(pointsToFloats(def.Vertices))
+
+	path := pathAnnotationStyle{
+		paths:     [][]draw.Point{def.Vertices},
+		lineColor: def.LineColor,
+		lineWidth: def.LineWidth,
+		opacity:   def.Opacity,
+	}
+	bs, err := path.apply(annot.PdfAnnotation, annot.PdfAnnotationMarkup, def.Author)
+	if err != nil {
+		return nil, err
+	}
+	annot.BS = bs
+
+	return annot.PdfAnnotation, nil
+}
+
+// pathAnnotationStyle contains the paths and the style of the path based
+// annotations (ink, polygon and polyline).
+type pathAnnotationStyle struct {
+	paths     [][]draw.Point
+	closed    bool // Close and optionally fill the paths.
+	smooth    bool // Draw smooth curves through the points.
+	lineColor *model.PdfColorDeviceRGB
+	fillColor *model.PdfColorDeviceRGB
+	lineWidth float64
+	opacity   float64
+}
+
+// apply sets the common entries of the annotation, including its normal
+// appearance, and returns the border style dictionary of the annotation.
+func (style pathAnnotationStyle) apply(annot *model.PdfAnnotation, markup *model.PdfAnnotationMarkup,
+	author string) (core.PdfObject, error) {
+	if style.lineColor == nil {
+		style.lineColor = model.NewPdfColorDeviceRGB(0, 0, 0)
+	}
+	if style.lineWidth <= 0 {
+		style.lineWidth = 1
+	}
+	if style.opacity <= 0 || style.opacity > 1 {
+		style.opacity = 1
+	}
+
+	apDict, rect, err := style.makeAppearanceStream()
+	if err != nil {
+		return nil, err
+	}
+
+	annot.Rect = rect.ToPdfObject()
+	annot.AP = apDict
+	c := style.lineColor
+	annot.C = core.MakeArrayFromFloats([]float64{c.R(), c.G(), c.B()})
+	annot.F = core.MakeInteger(4) // 4 (100 -> Print/show annotations).
+	if date, err := model.NewPdfDateFromTime(time.Now()); err == nil {
+		annot.M = date.ToPdfObject()
+		markup.CreationDate = date.ToPdfObject()
+	}
+	if author != "" {
+		markup.T = textstring.Make(author)
+	}
+	if style.opacity < 1 {
+		markup.CA = core.MakeFloat(style.opacity)
+	}
+
+	bs := model.NewBorderStyle()
+	bs.SetBorderWidth(style.lineWidth)
+	return bs.ToPdfObject(), nil
+}
+
+func (style pathAnnotationStyle) makeAppearanceStream() (*core.PdfObjectDictionary, *model.PdfRectangle, error) {
+	form := model.NewXObjectForm()
+	form.Resources = model.NewPdfPageResources()
+
+	cc := contentstream.NewContentCreator()
+	cc.Add_q()
+	if style.opacity < 1 {
+		gsState := model.NewPdfExtGState().SetAlpha(style.opacity)
+		gsName, err := model.NewResourceManager(form.Resources).AddExtGState(gsState.ToPdfObject())
+		if err != nil {
+			common.Log.Debug("Unable to add extgstate")
+			return nil, nil, err
+		}
+		cc.Add_gs(gsName)
+	}
+
+	// Round line caps and joins.
+	cc.Add_w(style.lineWidth).
+		AddOperand(contentstream.ContentStreamOperation{
+			Operand: "J", Params: []core.PdfObject{core.MakeInteger(1)},
+		}).
+		AddOperand(contentstream.ContentStreamOperation{
+			Operand: "j", Params: []core.PdfObject{core.MakeInteger(1)},
+		}).
+		SetStrokingColor(style.lineColor)
+	if style.fillColor != nil {
+		cc.SetNonStrokingColor(style.fillColor)
+	}
+
+	bbox := &model.PdfRectangle{
+		Llx: math.Inf(1), Lly: math.Inf(1),
+		Urx: math.Inf(-1), Ury: math.Inf(-1),
+	}
+	extend := func(points ...draw.Point) {
+		for _, p := range points {
+			bbox.Llx = math.Min(bbox.Llx, p.X)
+			bbox.Lly = math.Min(bbox.Lly, p.Y)
+			bbox.Urx = math.Max(bbox.Urx, p.X)
+			bbox.Ury = math.Max(bbox.Ury, p.Y)
+		}
+	}
+
+	for _, points := range style.paths {
+		if len(points) == 0 {
+			continue
+		}
+		extend(points...)
+
+		cc.Add_m(points[0].X, points[0].Y)
+		if len(points) == 1 {
+			// Draw a dot for single points.
+			cc.Add_l(points[0].X, points[0].Y)
+		}
+		for i := 1; i < len(points); i++ {
+			if !style.smooth {
+				cc.Add_l(points[i].X, points[i].Y)
+				continue
+			}
+
+			c1, c2 := smoothControlPoints(points, i-1)
+			extend(c1, c2)
+			cc.Add_c(c1.X, c1.Y, c2.X, c2.Y, points[i].X, points[i].Y)
+		}
+
+		switch {
+		case style.closed && style.fillColor != nil:
+			cc.Add_h().Add_B()
+		case style.closed:
+			cc.Add_h().Add_S()
+		default:
+			cc.Add_S()
+		}
+	}
+	cc.Add_Q()
+
+	if math.IsInf(bbox.Llx, 0) {
+		return nil, nil, errors.New("no points specified")
+	}
+
+	// Account for the line width.
+	bbox.Llx -= style.lineWidth
+	bbox.Lly -= style.lineWidth
+	bbox.Urx += style.lineWidth
+	bbox.Ury += style.lineWidth
+
+	if err := form.SetContentStream(cc.Bytes(), defStreamEncoder()); err != nil {
+		return nil, nil, err
+	}
+
+	// The vertices are in page coordinates, so the form is bounded by their
+	// bounding box, widened by the line width.
+	form.BBox = bbox.ToPdfObject()
+
+	apDict := core.MakeDict()
+	apDict.Set("N", form.ToPdfObject())
+	return apDict, bbox, nil
+}
+
+// smoothControlPoints returns the Bezier control points of the curve segment
+// between points `i` and `i+1`, based on a Catmull-Rom spline through
+// `points`.
+func smoothControlPoints(points []draw.Point, i int) (draw.Point, draw.Point) {
+	p0, p1, p2, p3 := points[i], points[i], points[i+1], points[i+1]
+	if i > 0 {
+		p0 = points[i-1]
+	}
+	if i+2 < len(points) {
+		p3 = points[i+2]
+	}
+
+	c1 := draw.NewPoint(p1.X+(p2.X-p0.X)/6, p1.Y+(p2.Y-p0.Y)/6)
+	c2 := draw.NewPoint(p2.X-(p3.X-p1.X)/6, p2.Y-(p3.Y-p1.Y)/6)
+	return c1, c2
+}
+
+// pointsToFloats returns the alternating x and y coordinates of `points`.
+func pointsToFloats(points []draw.Point) []float64 {
+	coords := make([]float64, 0, 2*len(points))
+	for _, p := range points {
+		coords = append(coords, p.X, p.Y)
+	}
+	return coords
+}
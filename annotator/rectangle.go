@@ -72,16 +72,14 @@ func makeRectangleAnnotationAppearanceStream(rectDef RectangleAnnotationDef) (*p
 	gsName := ""
 	if rectDef.Opacity < 1.0 {
 		// Create graphics state with right opacity.
-		gsState := pdfcore.MakeDict()
-		gsState.Set("ca", pdfcore.MakeFloat(rectDef.Opacity))
-		gsState.Set("CA", pdfcore.MakeFloat(rectDef.Opacity))
-		err := form.Resources.AddExtGState("gs1", gsState)
+		gsState := pdf.NewPdfExtGState().SetAlpha(rectDef.Opacity)
+		name, err := pdf.NewResourceManager(form.Resources).AddExtGState(gsState.ToPdfObject())
 		if err != nil {
-			common.Log.Debug("Unable to add extgstate gs1")
+			common.Log.Debug("Unable to add extgstate")
 			return nil, nil, err
 		}
 
-		gsName = "gs1"
+		gsName = string(name)
 	}
 
 	content, localBbox, globalBbox, err := drawPdfRectangle(rectDef, gsName)
@@ -0,0 +1,382 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package annotator
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/contentstream/draw"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/creator"
+	"github.com/unidoc/unipdf/v3/internal/textstring"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// Standard stamp names (Table 181 p. 405).
+const (
+	StampApproved            = "Approved"
+	StampExperimental        = "Experimental"
+	StampNotApproved         = "NotApproved"
+	StampAsIs                = "AsIs"
+	StampExpired             = "Expired"
+	StampNotForPublicRelease = "NotForPublicRelease"
+	StampConfidential        = "Confidential"
+	StampFinal               = "Final"
+	StampSold                = "Sold"
+	StampDepartmental        = "Departmental"
+	StampForComment          = "ForComment"
+	StampTopSecret           = "TopSecret"
+	StampDraft               = "Draft"
+	StampForPublicRelease    = "ForPublicRelease"
+)
+
+var standardStampNames = map[string]struct{}{
+	StampApproved: {}, StampExperimental: {}, StampNotApproved: {}, StampAsIs: {},
+	StampExpired: {}, StampNotForPublicRelease: {}, StampConfidential: {},
+	StampFinal: {}, StampSold: {}, StampDepartmental: {}, StampForComment: {},
+	StampTopSecret: {}, StampDraft: {}, StampForPublicRelease: {},
+}
+
+// StampAnnotationDef defines a rubber stamp annotation. The appearance of the
+// stamp is either generated from one of the standard stamp names, or built
+// from a custom Image, Block or Appearance form (at most one can be
+// specified). The appearance is scaled to fit Rect, preserving its aspect
+// ratio.
+type StampAnnotationDef struct {
+	Rect model.PdfRectangle // Stamp rectangle, in page coordinates.
+
+	// Name of the stamp. Must be one of the standard stamp names if no custom
+	// appearance is specified, in which case it defaults to Draft.
+	Name string
+
+	Image      *model.Image       // Custom image appearance.
+	Block      *creator.Block     // Custom appearance drawn by a creator block.
+	Appearance *model.XObjectForm // Custom appearance form, e.g. copied from an existing stamp.
+
+	Color    *model.PdfColorDeviceRGB // Color of the standard stamps. Defaults to red.
+	Rotation float64                  // Rotation of the appearance, in degrees (counter-clockwise).
+	Opacity  float64                  // Defaults to 1 if not specified.
+	Contents string                   // Text of the annotation (Contents entry).
+	Author   string                   // Author of the annotation (T entry).
+}
+
+// CreateStampAnnotation creates a stamp annotation object, including its
+// normal appearance, that can be added to page PDF annotations.
+func CreateStampAnnotation(def StampAnnotationDef) (*model.PdfAnnotation, error) {
+	rect := def.Rect
+	if rect.Width() <= 0 || rect.Height() <= 0 {
+		return nil, errors.New("invalid stamp rectangle")
+	}
+	custom := 0
+	if def.Image != nil {
+		custom++
+	}
+	if def.Block != nil {
+		custom++
+	}
+	if def.Appearance != nil {
+		custom++
+	}
+	if custom > 1 {
+		return nil, errors.New("only one custom stamp appearance can be specified")
+	}
+
+	name := def.Name
+	if custom == 0 {
+		if name == "" {
+			name = StampDraft
+		}
+		if _, ok := standardStampNames[name]; !ok {
+			return nil, errors.New("unsupported stamp name")
+		}
+	}
+	opacity := def.Opacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = 1
+	}
+
+	annot := model.NewPdfAnnotationStamp()
+	annot.Rect = rect.ToPdfObject()
+	if name != "" {
+		annot.Name = core.MakeName(name)
+	}
+
+	apDict, err := makeStampAppearanceStream(def, name, opacity)
+	if err != nil {
+		return nil, err
+	}
+	annot.AP = apDict
+
+	if def.Contents != "" {
+		annot.Contents = textstring.Make(def.Contents)
+	}
+	annot.F = core.MakeInteger(4) // 4 (100 -> Print/show annotations).
+	if date, err := model.NewPdfDateFromTime(time.Now()); err == nil {
+		annot.M = date.ToPdfObject()
+		annot.CreationDate = date.ToPdfObject()
+	}
+	if def.Author != "" {
+		annot.T = textstring.Make(def.Author)
+	}
+	if opacity < 1 {
+		annot.CA = core.MakeFloat(opacity)
+	}
+
+	return annot.PdfAnnotation, nil
+}
+
+func makeStampAppearanceStream(def StampAnnotationDef, name string, opacity float64) (*core.PdfObjectDictionary, error) {
+	form := model.NewXObjectForm()
+	form.Resources = model.NewPdfPageResources()
+	rm := model.NewResourceManager(form.Resources)
+
+	cc := contentstream.NewContentCreator()
+	cc.Add_q()
+	if opacity < 1 {
+		gsState := model.NewPdfExtGState().SetAlpha(opacity)
+		gsName, err := rm.AddExtGState(gsState.ToPdfObject())
+		if err != nil {
+			common.Log.Debug("Unable to add extgstate")
+			return nil, err
+		}
+		cc.Add_gs(gsName)
+	}
+
+	// The stamp content is drawn in a local coordinate system with the origin
+	// at its lower left corner, sized `width`x`height`.
+	var (
+		content       *contentstream.ContentCreator
+		width, height float64
+	)
+	switch {
+	case def.Image != nil:
+		ximg, err := model.NewXObjectImageFromImage(def.Image, nil, core.NewFlateEncoder())
+		if err != nil {
+			return nil, err
+		}
+		imgName, err := addXObject(rm, ximg.ToPdfObject())
+		if err != nil {
+			return nil, err
+		}
+		width, height = float64(def.Image.Width), float64(def.Image.Height)
+		content = contentstream.NewContentCreator()
+		content.Add_cm(width, 0, 0, height, 0, 0).Add_Do(imgName)
+	case def.Block != nil:
+		xform, err := def.Block.ToXObjectForm()
+		if err != nil {
+			return nil, err
+		}
+		formName, err := addXObject(rm, xform.ToPdfObject())
+		if err != nil {
+			return nil, err
+		}
+		width, height = def.Block.Width(), def.Block.Height()
+		content = contentstream.NewContentCreator()
+		content.Add_Do(formName)
+	case def.Appearance != nil:
+		bounds, err := stampFormBounds(def.Appearance)
+		if err != nil {
+			return nil, err
+		}
+		formName, err := addXObject(rm, def.Appearance.ToPdfObject())
+		if err != nil {
+			return nil, err
+		}
+		width, height = bounds.Width(), bounds.Height()
+		content = contentstream.NewContentCreator()
+		content.Translate(-bounds.Llx, -bounds.Lly).Add_Do(formName)
+	default:
+		var err error
+		content, width, height, err = makeStandardStampContent(form.Resources, name, def.Color)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if width <= 0 || height <= 0 {
+		return nil, errors.New("invalid stamp appearance size")
+	}
+
+	// Rotated content dimensions.
+	rotWidth, rotHeight := width, height
+	if def.Rotation != 0 {
+		bbox := draw.Path{Points: []draw.Point{
+			draw.NewPoint(0, 0).Rotate(def.Rotation),
+			draw.NewPoint(width, 0).Rotate(def.Rotation),
+			draw.NewPoint(0, height).Rotate(def.Rotation),
+			draw.NewPoint(width, height).Rotate(def.Rotation),
+		}}.GetBoundingBox()
+		rotWidth, rotHeight = bbox.Width, bbox.Height
+	}
+
+	// Center the content in the rectangle, preserving its aspect ratio.
+	rect := def.Rect
+	scale := math.Min(rect.Width()/rotWidth, rect.Height()/rotHeight)
+	cc.Translate(rect.Llx+rect.Width()/2, rect.Lly+rect.Height()/2)
+	if def.Rotation != 0 {
+		cc.RotateDeg(def.Rotation)
+	}
+	cc.Scale(scale, scale).
+		Translate(-width/2, -height/2)
+	for _, op := range *content.Operations() {
+		cc.AddOperand(*op)
+	}
+	cc.Add_Q()
+
+	if err := form.SetContentStream(cc.Bytes(), defStreamEncoder()); err != nil {
+		return nil, err
+	}
+
+	// The stamp is centered in the annotation rectangle, which bounds the form.
+	form.BBox = rect.ToPdfObject()
+
+	apDict := core.MakeDict()
+	apDict.Set("N", form.ToPdfObject())
+	return apDict, nil
+}
+
+// stampFormBounds returns the bounding box of `xform`, transformed by its
+// matrix.
+func stampFormBounds(xform *model.XObjectForm) (*model.PdfRectangle, error) {
+	bboxArr, ok := core.GetArray(xform.BBox)
+	if !ok {
+		return nil, errors.New("appearance form missing bounding box")
+	}
+	bbox, err := model.NewPdfRectangle(*bboxArr)
+	if err != nil {
+		return nil, err
+	}
+	if xform.Matrix == nil {
+		return bbox, nil
+	}
+
+	mArr, ok := core.GetArray(xform.Matrix)
+	if !ok || mArr.Len() != 6 {
+		return nil, errors.New("invalid appearance form matrix")
+	}
+	vals, err := mArr.ToFloat64Array()
+	if err != nil {
+		return nil, err
+	}
+	m := transform.NewMatrix(vals[0], vals[1], vals[2], vals[3], vals[4], vals[5])
+
+	bounds := &model.PdfRectangle{
+		Llx: math.Inf(1), Lly: math.Inf(1),
+		Urx: math.Inf(-1), Ury: math.Inf(-1),
+	}
+	for _, p := range [][2]float64{
+		{bbox.Llx, bbox.Lly}, {bbox.Urx, bbox.Lly},
+		{bbox.Llx, bbox.Ury}, {bbox.Urx, bbox.Ury},
+	} {
+		x, y := m.Transform(p[0], p[1])
+		bounds.Llx = math.Min(bounds.Llx, x)
+		bounds.Lly = math.Min(bounds.Lly, y)
+		bounds.Urx = math.Max(bounds.Urx, x)
+		bounds.Ury = math.Max(bounds.Ury, y)
+	}
+	return bounds, nil
+}
+
+// addXObject adds the XObject stream `obj` to the resources of `rm` and
+// returns its name.
+func addXObject(rm *model.ResourceManager, obj core.PdfObject) (core.PdfObjectName, error) {
+	stream, ok := obj.(*core.PdfObjectStream)
+	if !ok {
+		return "", core.ErrTypeError
+	}
+	return rm.AddXObject(stream)
+}
+
+// makeStandardStampContent draws the standard stamp `name` as the uppercase
+// label inside a rounded border. The font used by the label is added to
+// `resources`. Returns the content and its dimensions.
+func makeStandardStampContent(resources *model.PdfPageResources, name string,
+	color *model.PdfColorDeviceRGB) (*contentstream.ContentCreator, float64, float64, error) {
+	if color == nil {
+		color = model.NewPdfColorDeviceRGB(0.8, 0, 0)
+	}
+	font, err := model.NewStandard14Font(model.HelveticaBoldName)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	fontname := core.PdfObjectName("HeBo")
+	if err := resources.SetFontByName(fontname, font.ToPdfObject()); err != nil {
+		common.Log.Debug("Unable to add font %s", fontname)
+		return nil, 0, 0, err
+	}
+
+	label := stampLabel(name)
+	const (
+		fontsize    = 24.0
+		borderWidth = 3.0
+		padding     = 10.0
+	)
+	textWidth := 0.0
+	for _, r := range label {
+		metrics, found := font.GetRuneMetrics(r)
+		if !found {
+			common.Log.Debug("Font missing rune %c", r)
+			continue
+		}
+		textWidth += metrics.Wx
+	}
+	textWidth *= fontsize / 1000.0
+
+	width := textWidth + 2*(padding+borderWidth)
+	height := fontsize + 2*(padding+borderWidth)
+
+	cc := contentstream.NewContentCreator()
+	cc.Add_q().
+		Add_w(borderWidth).
+		SetStrokingColor(color)
+	drawRoundedRect(cc, borderWidth/2, borderWidth/2, width-borderWidth, height-borderWidth, 2*borderWidth)
+	cc.Add_S().Add_Q()
+
+	// Cap height of Helvetica-Bold is about 0.72 of the font size.
+	cc.Add_BT().
+		SetNonStrokingColor(color).
+		Add_Tf(fontname, fontsize).
+		Add_Td(padding+borderWidth, (height-0.72*fontsize)/2).
+		Add_Tj(*core.MakeStringFromBytes(font.Encoder().Encode(label))).
+		Add_ET()
+	return cc, width, height, nil
+}
+
+// stampLabel returns the text displayed by the standard stamp `name`, e.g.
+// "NOT APPROVED" for NotApproved.
+func stampLabel(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteRune(' ')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// drawRoundedRect adds the path of a rectangle with rounded corners of
+// radius `r` to `cc`.
+func drawRoundedRect(cc *contentstream.ContentCreator, x, y, width, height, r float64) {
+	// Control point distance for approximating quarter circles.
+	k := r * 0.5523
+	cc.Add_m(x+r, y).
+		Add_l(x+width-r, y).
+		Add_c(x+width-r+k, y, x+width, y+r-k, x+width, y+r).
+		Add_l(x+width, y+height-r).
+		Add_c(x+width, y+height-r+k, x+width-r+k, y+height, x+width-r, y+height).
+		Add_l(x+r, y+height).
+		Add_c(x+r-k, y+height, x, y+height-r+k, x, y+height-r).
+		Add_l(x, y+r).
+		Add_c(x, y+r-k, x+r-k, y, x+r, y).
+		Add_h()
+}
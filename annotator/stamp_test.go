@@ -0,0 +1,221 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package annotator
+
+import (
+	"bytes"
+	goimage "image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/creator"
+	"github.com/unidoc/unipdf/v3/model"
+	"github.com/unidoc/unipdf/v3/render"
+)
+
+// makePNGImage returns a `width`x`height` image filled with `c`, decoded from
+// its PNG encoding.
+func makePNGImage(t *testing.T, width, height int, c color.Color) *model.Image {
+	goimg := goimage.NewRGBA(goimage.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			goimg.Set(x, y, c)
+		}
+	}
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, png.Encode(buf, goimg))
+	img, err := model.ImageHandling.Read(buf)
+	require.NoError(t, err)
+	return img
+}
+
+// writeStampPage adds the annotations to a new page and returns the page,
+// written and read back.
+func writeStampPage(t *testing.T, annots ...*model.PdfAnnotation) *model.PdfPage {
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 200, Ury: 200}
+	for _, annot := range annots {
+		page.AddAnnotation(annot)
+	}
+
+	w := model.NewPdfWriter()
+	require.NoError(t, w.AddPage(page))
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, w.Write(buf))
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	page, err = reader.GetPage(1)
+	require.NoError(t, err)
+	return page
+}
+
+// renderStampPage flattens the annotations of `page` and renders it.
+func renderStampPage(t *testing.T, page *model.PdfPage) goimage.Image {
+	require.NoError(t, page.FlattenAnnotations(nil))
+	img, err := render.NewImageDevice().Render(page)
+	require.NoError(t, err)
+	return img
+}
+
+// pageStamps returns the stamp annotations of `page`.
+func pageStamps(t *testing.T, page *model.PdfPage) []*model.PdfAnnotationStamp {
+	annots, err := page.GetAnnotations()
+	require.NoError(t, err)
+	var stamps []*model.PdfAnnotationStamp
+	for _, annot := range annots {
+		stamp, ok := annot.GetContext().(*model.PdfAnnotationStamp)
+		require.True(t, ok)
+		stamps = append(stamps, stamp)
+	}
+	return stamps
+}
+
+func TestCreateStampAnnotationImage(t *testing.T) {
+	// 2:1 image stamp, fitted in a square rectangle.
+	img := makePNGImage(t, 40, 20, color.RGBA{R: 0, G: 0, B: 255, A: 255})
+	annot, err := CreateStampAnnotation(StampAnnotationDef{
+		Rect:     model.PdfRectangle{Llx: 20, Lly: 20, Urx: 120, Ury: 120},
+		Name:     "Logo",
+		Image:    img,
+		Contents: "Reviewed",
+	})
+	require.NoError(t, err)
+
+	page := writeStampPage(t, annot)
+
+	// The image is only referenced from the appearance stream.
+	require.Nil(t, page.Resources.XObject)
+
+	stamps := pageStamps(t, page)
+	require.Len(t, stamps, 1)
+	name, ok := core.GetName(stamps[0].Name)
+	require.True(t, ok)
+	require.Equal(t, "Logo", name.String())
+
+	xform, err := stamps[0].GetAppearance()
+	require.NoError(t, err)
+	require.NotNil(t, xform)
+	require.NotNil(t, xform.Resources)
+	ximg, err := xform.Resources.GetXObjectImageByName("Im0")
+	require.NoError(t, err)
+	require.NotNil(t, ximg)
+	require.Equal(t, int64(40), *ximg.Width)
+
+	rendered := renderStampPage(t, page)
+	blue := [3]uint32{0, 0, 255}
+	white := [3]uint32{255, 255, 255}
+	require.Equal(t, blue, pixelRGB(rendered, 70, 70))
+	require.Equal(t, blue, pixelRGB(rendered, 22, 55))
+	require.Equal(t, blue, pixelRGB(rendered, 118, 85))
+	// The aspect ratio is preserved: the image covers y in [45, 95].
+	require.Equal(t, white, pixelRGB(rendered, 70, 30))
+	require.Equal(t, white, pixelRGB(rendered, 70, 110))
+}
+
+func TestCreateStampAnnotationRotation(t *testing.T) {
+	img := makePNGImage(t, 40, 20, color.RGBA{R: 0, G: 0, B: 255, A: 255})
+	annot, err := CreateStampAnnotation(StampAnnotationDef{
+		Rect:     model.PdfRectangle{Llx: 20, Lly: 20, Urx: 120, Ury: 120},
+		Image:    img,
+		Rotation: 90,
+		Opacity:  0.5,
+	})
+	require.NoError(t, err)
+
+	page := writeStampPage(t, annot)
+	stamps := pageStamps(t, page)
+	require.Len(t, stamps, 1)
+	require.Nil(t, stamps[0].Name)
+	ca, err := core.GetNumberAsFloat(stamps[0].CA)
+	require.NoError(t, err)
+	require.Equal(t, 0.5, ca)
+
+	// The rotated image covers x in [45, 95].
+	rendered := renderStampPage(t, page)
+	white := [3]uint32{255, 255, 255}
+	require.NotEqual(t, white, pixelRGB(rendered, 70, 22))
+	require.NotEqual(t, white, pixelRGB(rendered, 70, 118))
+	require.Equal(t, white, pixelRGB(rendered, 30, 70))
+	require.Equal(t, white, pixelRGB(rendered, 110, 70))
+}
+
+func TestCreateStampAnnotationBlock(t *testing.T) {
+	blk := creator.NewBlock(50, 50)
+	rect := creator.New().NewRectangle(0, 0, 50, 50)
+	rect.SetFillColor(creator.ColorRGBFrom8bit(0, 255, 0))
+	rect.SetBorderWidth(0)
+	require.NoError(t, blk.Draw(rect))
+
+	annot, err := CreateStampAnnotation(StampAnnotationDef{
+		Rect:  model.PdfRectangle{Llx: 100, Lly: 100, Urx: 150, Ury: 150},
+		Block: blk,
+	})
+	require.NoError(t, err)
+
+	page := writeStampPage(t, annot)
+	rendered := renderStampPage(t, page)
+	require.Equal(t, [3]uint32{0, 255, 0}, pixelRGB(rendered, 125, 125))
+}
+
+func TestStampAnnotationCopy(t *testing.T) {
+	annot, err := CreateStampAnnotation(StampAnnotationDef{
+		Rect: model.PdfRectangle{Llx: 20, Lly: 20, Urx: 180, Ury: 60},
+		Name: StampNotApproved,
+	})
+	require.NoError(t, err)
+
+	page := writeStampPage(t, annot)
+	stamps := pageStamps(t, page)
+	require.Len(t, stamps, 1)
+	name, ok := core.GetName(stamps[0].Name)
+	require.True(t, ok)
+	require.Equal(t, StampNotApproved, name.String())
+
+	// Copy the stamp appearance to another document.
+	xform, err := stamps[0].GetAppearance()
+	require.NoError(t, err)
+	require.NotNil(t, xform)
+	annot, err = CreateStampAnnotation(StampAnnotationDef{
+		Rect:       model.PdfRectangle{Llx: 20, Lly: 100, Urx: 100, Ury: 120},
+		Name:       StampNotApproved,
+		Appearance: xform,
+	})
+	require.NoError(t, err)
+
+	page = writeStampPage(t, annot)
+	stamps = pageStamps(t, page)
+	require.Len(t, stamps, 1)
+	copied, err := stamps[0].GetAppearance()
+	require.NoError(t, err)
+	nested, err := copied.Resources.GetXObjectFormByName("Fm0")
+	require.NoError(t, err)
+	require.NotNil(t, nested)
+	font, has := nested.Resources.GetFontByName("HeBo")
+	require.True(t, has)
+	require.NotNil(t, font)
+}
+
+func TestCreateStampAnnotationInvalid(t *testing.T) {
+	_, err := CreateStampAnnotation(StampAnnotationDef{
+		Rect: model.PdfRectangle{Urx: 10, Ury: 10},
+		Name: "Custom",
+	})
+	require.Error(t, err)
+
+	_, err = CreateStampAnnotation(StampAnnotationDef{Name: StampDraft})
+	require.Error(t, err)
+
+	_, err = CreateStampAnnotation(StampAnnotationDef{
+		Rect:  model.PdfRectangle{Urx: 10, Ury: 10},
+		Image: makePNGImage(t, 2, 2, color.Black),
+		Block: creator.NewBlock(10, 10),
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,314 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package annotator
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/contentstream/draw"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/textstring"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// TextMarkupType represents the subtype of a text markup annotation.
+type TextMarkupType int
+
+// Text markup annotation subtypes (Section 12.5.6.10 p. 405).
+const (
+	TextMarkupHighlight TextMarkupType = iota
+	TextMarkupUnderline
+	TextMarkupStrikeOut
+	TextMarkupSquiggly
+)
+
+// TextMarkupQuad is a quadrilateral enclosing a piece of marked up text.
+// The points are specified in page coordinates, counterclockwise starting
+// with the lower left corner of the text (relative to the text direction).
+type TextMarkupQuad [4]draw.Point
+
+// NewTextMarkupQuad returns the quadrilateral of the specified rectangle,
+// such as the bounding box of an extracted text mark.
+func NewTextMarkupQuad(rect model.PdfRectangle) TextMarkupQuad {
+	return TextMarkupQuad{
+		draw.NewPoint(rect.Llx, rect.Lly),
+		draw.NewPoint(rect.Urx, rect.Lly),
+		draw.NewPoint(rect.Urx, rect.Ury),
+		draw.NewPoint(rect.Llx, rect.Ury),
+	}
+}
+
+// TextMarkupAnnotationDef defines a text markup annotation (highlight,
+// underline, strikeout or squiggly) covering the text enclosed by Quads.
+// The quadrilaterals of adjacent glyphs on the same line are merged, so the
+// glyph boxes of a match spanning multiple lines can be passed directly.
+type TextMarkupAnnotationDef struct {
+	Type     TextMarkupType
+	Quads    []TextMarkupQuad
+	Color    *model.PdfColorDeviceRGB // Defaults to yellow if not specified.
+	Opacity  float64                  // Alpha value (0-1). Ignored if not in (0, 1).
+	Contents string                   // Text displayed for the annotation.
+	Author   string                   // Author of the annotation (T entry).
+}
+
+// CreateTextMarkupAnnotation creates a text markup annotation object that can
+// be added to page PDF annotations.
+func CreateTextMarkupAnnotation(def TextMarkupAnnotationDef) (*model.PdfAnnotation, error) {
+	if len(def.Quads) == 0 {
+		return nil, errors.New("text markup quadrilaterals not specified")
+	}
+
+	quads := mergeTextMarkupQuads(def.Quads)
+	quadPoints := make([]float64, 0, 8*len(quads))
+	for _, q := range quads {
+		// Viewers expect the upper edge first, followed by the lower edge,
+		// both in the text direction.
+		quadPoints = append(quadPoints,
+			q[3].X, q[3].Y, q[2].X, q[2].Y,
+			q[0].X, q[0].Y, q[1].X, q[1].Y)
+	}
+
+	var annot *model.PdfAnnotation
+	var markup *model.PdfAnnotationMarkup
+	switch def.Type {
+	case TextMarkupHighlight:
+		a := model.NewPdfAnnotationHighlight()
+		a.QuadPoints = core.MakeArrayFromFloats(quadPoints)
+		annot, markup = a.PdfAnnotation, a.PdfAnnotationMarkup
+	case TextMarkupUnderline:
+		a := model.NewPdfAnnotationUnderline()
+		a.QuadPoints = core.MakeArrayFromFloats(quadPoints)
+		annot, markup = a.PdfAnnotation, a.PdfAnnotationMarkup
+	case TextMarkupStrikeOut:
+		a := model.NewPdfAnnotationStrikeOut()
+		a.QuadPoints = core.MakeArrayFromFloats(quadPoints)
+		annot, markup = a.PdfAnnotation, a.PdfAnnotationMarkup
+	case TextMarkupSquiggly:
+		a := model.NewPdfAnnotationSquiggly()
+		a.QuadPoints = core.MakeArrayFromFloats(quadPoints)
+		annot, markup = a.PdfAnnotation, a.PdfAnnotationMarkup
+	default:
+		return nil, errors.New("unsupported text markup type")
+	}
+
+	color := def.Color
+	if color == nil {
+		color = model.NewPdfColorDeviceRGB(1, 1, 0)
+	}
+	opacity := def.Opacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = 1
+	}
+
+	rect := textMarkupQuadsBBox(quads)
+	apDict, err := makeTextMarkupAppearanceStream(def.Type, quads, rect, color, opacity)
+	if err != nil {
+		return nil, err
+	}
+
+	annot.Rect = rect.ToPdfObject()
+	annot.AP = apDict
+	annot.C = core.MakeArrayFromFloats([]float64{color.R(), color.G(), color.B()})
+	annot.F = core.MakeInteger(4) // 4 (100 -> Print/show annotations).
+	if def.Contents != "" {
+		annot.Contents = textstring.Make(def.Contents)
+	}
+	if date, err := model.NewPdfDateFromTime(time.Now()); err == nil {
+		annot.M = date.ToPdfObject()
+		markup.CreationDate = date.ToPdfObject()
+	}
+
+	if def.Author != "" {
+		markup.T = textstring.Make(def.Author)
+	}
+	if opacity < 1 {
+		markup.CA = core.MakeFloat(opacity)
+	}
+
+	return annot, nil
+}
+
+// AddTextMarkupAnnotation creates a text markup annotation with the
+// specified definition and adds it to the annotations of `page`.
+func AddTextMarkupAnnotation(page *model.PdfPage, def TextMarkupAnnotationDef) (*model.PdfAnnotation, error) {
+	if page == nil {
+		return nil, errors.New("page not specified")
+	}
+
+	annot, err := CreateTextMarkupAnnotation(def)
+	if err != nil {
+		return nil, err
+	}
+	annot.P = page.ToPdfObject()
+	page.AddAnnotation(annot)
+
+	return annot, nil
+}
+
+// mergeTextMarkupQuads merges the axis aligned quadrilaterals of consecutive
+// glyphs on the same line, resulting in a single quadrilateral per line.
+// Rotated quadrilaterals are preserved as they are.
+func mergeTextMarkupQuads(quads []TextMarkupQuad) []TextMarkupQuad {
+	var merged []TextMarkupQuad
+	var last *model.PdfRectangle
+	for _, q := range quads {
+		rect, ok := q.axisAlignedRect()
+		if !ok {
+			merged = append(merged, q)
+			last = nil
+			continue
+		}
+
+		if last != nil && isSameTextLine(*last, rect) {
+			last.Llx = math.Min(last.Llx, rect.Llx)
+			last.Lly = math.Min(last.Lly, rect.Lly)
+			last.Urx = math.Max(last.Urx, rect.Urx)
+			last.Ury = math.Max(last.Ury, rect.Ury)
+			merged[len(merged)-1] = NewTextMarkupQuad(*last)
+			continue
+		}
+
+		merged = append(merged, NewTextMarkupQuad(rect))
+		last = &rect
+	}
+
+	return merged
+}
+
+// axisAlignedRect returns the rectangle of the quadrilateral if it is an
+// upright axis aligned rectangle.
+func (q TextMarkupQuad) axisAlignedRect() (model.PdfRectangle, bool) {
+	const tol = 0.01
+	if math.Abs(q[0].Y-q[1].Y) > tol || math.Abs(q[2].Y-q[3].Y) > tol ||
+		math.Abs(q[0].X-q[3].X) > tol || math.Abs(q[1].X-q[2].X) > tol ||
+		q[1].X < q[0].X || q[3].Y < q[0].Y {
+		return model.PdfRectangle{}, false
+	}
+
+	return model.PdfRectangle{Llx: q[0].X, Lly: q[0].Y, Urx: q[2].X, Ury: q[2].Y}, true
+}
+
+// isSameTextLine returns true if rectangle `r` continues the text line
+// enclosed by rectangle `line`.
+func isSameTextLine(line, r model.PdfRectangle) bool {
+	height := math.Min(line.Ury-line.Lly, r.Ury-r.Lly)
+	overlap := math.Min(line.Ury, r.Ury) - math.Max(line.Lly, r.Lly)
+	if height <= 0 || overlap < 0.5*height {
+		return false
+	}
+
+	// Allow for gaps between words.
+	return r.Llx <= line.Urx+2*height && r.Urx >= line.Llx-2*height
+}
+
+// textMarkupQuadsBBox returns the union of the bounding boxes of `quads`.
+func textMarkupQuadsBBox(quads []TextMarkupQuad) *model.PdfRectangle {
+	bbox := &model.PdfRectangle{
+		Llx: math.Inf(1), Lly: math.Inf(1),
+		Urx: math.Inf(-1), Ury: math.Inf(-1),
+	}
+	for _, q := range quads {
+		for _, p := range q {
+			bbox.Llx = math.Min(bbox.Llx, p.X)
+			bbox.Lly = math.Min(bbox.Lly, p.Y)
+			bbox.Urx = math.Max(bbox.Urx, p.X)
+			bbox.Ury = math.Max(bbox.Ury, p.Y)
+		}
+	}
+
+	return bbox
+}
+
+func makeTextMarkupAppearanceStream(typ TextMarkupType, quads []TextMarkupQuad, rect *model.PdfRectangle,
+	color *model.PdfColorDeviceRGB, opacity float64) (*core.PdfObjectDictionary, error) {
+	form := model.NewXObjectForm()
+	form.Resources = model.NewPdfPageResources()
+
+	cc := contentstream.NewContentCreator()
+	cc.Add_q()
+	if typ == TextMarkupHighlight || opacity < 1 {
+		gsState := model.NewPdfExtGState()
+		if typ == TextMarkupHighlight {
+			// Highlights are blended with the underlying text, so that the
+			// text remains readable.
+			gsState.SetBlendMode("Multiply")
+		}
+		if opacity < 1 {
+			gsState.SetAlpha(opacity)
+		}
+		gsName, err := model.NewResourceManager(form.Resources).AddExtGState(gsState.ToPdfObject())
+		if err != nil {
+			common.Log.Debug("Unable to add extgstate")
+			return nil, err
+		}
+		cc.Add_gs(gsName)
+	}
+
+	r, g, b := color.R(), color.G(), color.B()
+	cc.Add_rg(r, g, b).Add_RG(r, g, b)
+	for _, q := range quads {
+		// Height of the quadrilateral and unit vectors along (u) and across
+		// (v) the text direction.
+		ux, uy := q[1].X-q[0].X, q[1].Y-q[0].Y
+		vx, vy := q[3].X-q[0].X, q[3].Y-q[0].Y
+		length, height := math.Hypot(ux, uy), math.Hypot(vx, vy)
+		if length == 0 || height == 0 {
+			continue
+		}
+		ux, uy = ux/length, uy/length
+		vx, vy = vx/height, vy/height
+
+		// point returns the point at distance `s` along and `t` across the
+		// text direction from the lower left corner.
+		point := func(s, t float64) (float64, float64) {
+			return q[0].X + s*ux + t*vx, q[0].Y + s*uy + t*vy
+		}
+
+		lineWidth := math.Max(height/16, 0.5)
+		switch typ {
+		case TextMarkupHighlight:
+			cc.Add_m(q[0].X, q[0].Y).
+				Add_l(q[1].X, q[1].Y).
+				Add_l(q[2].X, q[2].Y).
+				Add_l(q[3].X, q[3].Y).
+				Add_h().
+				Add_f()
+		case TextMarkupUnderline, TextMarkupStrikeOut:
+			t := lineWidth
+			if typ == TextMarkupStrikeOut {
+				t = height / 2
+			}
+			cc.Add_w(lineWidth).
+				Add_m(point(0, t)).
+				Add_l(point(length, t)).
+				Add_S()
+		case TextMarkupSquiggly:
+			amplitude := height / 8
+			period := height / 2
+			cc.Add_w(lineWidth).Add_m(point(0, lineWidth))
+			for i, s := 1, period/2; s < length; i, s = i+1, s+period/2 {
+				cc.Add_l(point(s, lineWidth+float64(i%2)*amplitude))
+			}
+			cc.Add_l(point(length, lineWidth)).Add_S()
+		}
+	}
+	cc.Add_Q()
+
+	if err := form.SetContentStream(cc.Bytes(), defStreamEncoder()); err != nil {
+		return nil, err
+	}
+
+	// The quadrilaterals are in page coordinates, inside the annotation rectangle.
+	form.BBox = rect.ToPdfObject()
+
+	apDict := core.MakeDict()
+	apDict.Set("N", form.ToPdfObject())
+	return apDict, nil
+}
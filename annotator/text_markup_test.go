@@ -0,0 +1,185 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package annotator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/creator"
+	"github.com/unidoc/unipdf/v3/extractor"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// findPhraseQuads returns the glyph quadrilaterals of the first occurrence of
+// `phrase` in the text of `page`.
+func findPhraseQuads(t *testing.T, page *model.PdfPage, phrase string) []TextMarkupQuad {
+	ex, err := extractor.New(page)
+	require.NoError(t, err)
+	pageText, _, _, err := ex.ExtractPageText()
+	require.NoError(t, err)
+
+	text := pageText.Text()
+	start := strings.Index(text, phrase)
+	require.NotEqual(t, -1, start, text)
+	end := start + len(phrase)
+
+	var quads []TextMarkupQuad
+	for _, mark := range pageText.Marks().Elements() {
+		if mark.Meta || mark.Offset < start || mark.Offset >= end {
+			continue
+		}
+		quads = append(quads, NewTextMarkupQuad(mark.BBox))
+	}
+	return quads
+}
+
+func TestTextMarkupHighlight(t *testing.T) {
+	c := creator.New()
+	c.NewPage()
+	p := c.NewParagraph("The quick brown fox\njumps over the lazy dog")
+	p.SetPos(100, 100)
+	require.NoError(t, c.Draw(p))
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, c.Write(buf))
+
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	page, err := reader.GetPage(1)
+	require.NoError(t, err)
+
+	// The phrase spans two lines.
+	quads := findPhraseQuads(t, page, "brown fox\njumps")
+	require.True(t, len(quads) > 2)
+
+	annot, err := AddTextMarkupAnnotation(page, TextMarkupAnnotationDef{
+		Type:     TextMarkupHighlight,
+		Quads:    quads,
+		Color:    model.NewPdfColorDeviceRGB(1, 1, 0),
+		Opacity:  0.5,
+		Contents: "Note",
+		Author:   "Jürgen",
+	})
+	require.NoError(t, err)
+	_, isHighlight := annot.GetContext().(*model.PdfAnnotationHighlight)
+	require.True(t, isHighlight)
+
+	// Write and reload the annotated page.
+	w := model.NewPdfWriter()
+	require.NoError(t, w.AddPage(page))
+	buf.Reset()
+	require.NoError(t, w.Write(buf))
+	reader, err = model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	page, err = reader.GetPage(1)
+	require.NoError(t, err)
+	annots, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 1)
+
+	highlight, ok := annots[0].GetContext().(*model.PdfAnnotationHighlight)
+	require.True(t, ok)
+	str, ok := core.GetString(highlight.Contents)
+	require.True(t, ok)
+	require.Equal(t, "Note", str.Decoded())
+	str, ok = core.GetString(highlight.T)
+	require.True(t, ok)
+	require.Equal(t, "Jürgen", str.Decoded())
+	require.Equal(t, 0.5, mustFloat(t, highlight.CA))
+
+	// One quadrilateral per line, with the upper edge first.
+	quadArr, ok := core.GetArray(highlight.QuadPoints)
+	require.True(t, ok)
+	points, err := quadArr.ToFloat64Array()
+	require.NoError(t, err)
+	require.Len(t, points, 16)
+	for i := 0; i < len(points); i += 8 {
+		require.Equal(t, points[i+1], points[i+3])
+		require.Equal(t, points[i+5], points[i+7])
+		require.True(t, points[i+1] > points[i+5])
+		require.True(t, points[i+2] > points[i])
+	}
+	require.True(t, points[1] > points[9]) // The first line is above the second.
+
+	// The annotation rectangle is the union of the quadrilaterals.
+	rectArr, ok := core.GetArray(highlight.Rect)
+	require.True(t, ok)
+	rect, err := model.NewPdfRectangle(*rectArr)
+	require.NoError(t, err)
+	bbox := textMarkupQuadsBBox(mergeTextMarkupQuads(quads))
+	require.Equal(t, *bbox, *rect)
+	for i := 0; i < len(points); i += 2 {
+		require.True(t, points[i] >= rect.Llx && points[i] <= rect.Urx)
+		require.True(t, points[i+1] >= rect.Lly && points[i+1] <= rect.Ury)
+	}
+
+	// The appearance fills the quadrilaterals in multiply blend mode.
+	apDict, ok := core.GetDict(highlight.AP)
+	require.True(t, ok)
+	stream, ok := core.GetStream(apDict.Get("N"))
+	require.True(t, ok)
+	form, err := model.NewXObjectFormFromStream(stream)
+	require.NoError(t, err)
+	require.NotNil(t, form.Resources)
+	gsObj, ok := form.Resources.GetExtGState("GS0")
+	require.True(t, ok)
+	gsDict, ok := core.GetDict(gsObj)
+	require.True(t, ok)
+	require.Equal(t, "Multiply", gsDict.Get("BM").String())
+	require.Equal(t, 0.5, mustFloat(t, gsDict.Get("ca")))
+
+	ops := parseAppearanceOps(t, apDict, "N", "")
+	var numFills int
+	var hasGS bool
+	for _, op := range *ops {
+		switch op.Operand {
+		case "f":
+			numFills++
+		case "gs":
+			hasGS = true
+		}
+	}
+	require.True(t, hasGS)
+	require.Equal(t, 2, numFills)
+}
+
+func TestTextMarkupLines(t *testing.T) {
+	quads := []TextMarkupQuad{
+		NewTextMarkupQuad(model.PdfRectangle{Llx: 10, Lly: 10, Urx: 20, Ury: 22}),
+		NewTextMarkupQuad(model.PdfRectangle{Llx: 20, Lly: 10, Urx: 30, Ury: 22}),
+	}
+
+	for _, typ := range []TextMarkupType{TextMarkupUnderline, TextMarkupStrikeOut, TextMarkupSquiggly} {
+		annot, err := CreateTextMarkupAnnotation(TextMarkupAnnotationDef{Type: typ, Quads: quads})
+		require.NoError(t, err)
+
+		apDict, ok := core.GetDict(annot.AP)
+		require.True(t, ok)
+		ops := parseAppearanceOps(t, apDict, "N", "")
+		var numStrokes int
+		for _, op := range *ops {
+			require.NotEqual(t, "f", op.Operand)
+			if op.Operand == "S" {
+				numStrokes++
+			}
+		}
+		require.Equal(t, 1, numStrokes)
+	}
+
+	_, err := CreateTextMarkupAnnotation(TextMarkupAnnotationDef{Type: TextMarkupHighlight})
+	require.Error(t, err)
+}
+
+// mustFloat returns the numeric value of `obj`.
+func mustFloat(t *testing.T, obj core.PdfObject) float64 {
+	val, err := core.GetNumberAsFloat(obj)
+	require.NoError(t, err)
+	return val
+}
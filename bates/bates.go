@@ -0,0 +1,309 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package bates
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/pageutil"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// Position is the corner of the pages the stamps are drawn in.
+type Position int
+
+// Positions of the stamps, in the pages as displayed.
+const (
+	PositionBottomRight Position = iota
+	PositionBottomLeft
+	PositionTopRight
+	PositionTopLeft
+)
+
+// Options defines the numbers and the appearance of the stamps.
+type Options struct {
+	// Prefix is the text preceding the numbers, e.g. "ABC".
+	Prefix string
+
+	// Start is the number of the first page. Defaults to 1.
+	Start int
+
+	// Digits is the minimum number of digits of the numbers, which are padded with zeros.
+	// Defaults to 6.
+	Digits int
+
+	// Position is the corner of the pages the stamps are drawn in. Defaults to
+	// PositionBottomRight.
+	Position Position
+
+	// Margin is the distance between the stamps, including their padding, and the edges of the
+	// crop boxes of the pages. Defaults to 18 points.
+	Margin float64
+
+	// Font is the font of the stamps. Defaults to Helvetica.
+	Font *model.PdfFont
+
+	// FontSize is the font size of the stamps. Defaults to 10.
+	FontSize float64
+
+	// Color is the color of the text of the stamps. Defaults to black.
+	Color *model.PdfColorDeviceRGB
+
+	// BackgroundColor, if set, is the color of the opaque box drawn behind the stamps, hiding the
+	// content of the pages below.
+	BackgroundColor *model.PdfColorDeviceRGB
+
+	// Padding is the space between the text of the stamps and the edges of their boxes.
+	// Defaults to 2 points.
+	Padding float64
+
+	// RecordIndex records the numbers given to the pages in the Index of the Result.
+	RecordIndex bool
+}
+
+// Document is a document to number.
+type Document struct {
+	// Name identifies the document in the index, e.g. its file name.
+	Name string
+
+	// Pages are the pages of the document, which are modified by Stamp.
+	Pages []*model.PdfPage
+}
+
+// NewDocument returns a Document named `name` with the pages of the document read by `r`.
+func NewDocument(name string, r *model.PdfReader) (*Document, error) {
+	numPages, err := r.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{Name: name}
+	for i := 1; i <= numPages; i++ {
+		page, err := r.GetPage(i)
+		if err != nil {
+			return nil, err
+		}
+		doc.Pages = append(doc.Pages, page)
+	}
+	return doc, nil
+}
+
+// IndexEntry is the number given to a page.
+type IndexEntry struct {
+	Document string // Name of the document.
+	Page     int    // Page number in the document, starting from 1.
+	Number   int
+	Label    string // Text of the stamp, e.g. "ABC000123".
+}
+
+// Result is the result of the numbering of documents.
+type Result struct {
+	// Next is the number following the number of the last page, from which the numbering of
+	// further documents continues.
+	Next int
+
+	// Index are the numbers given to the pages, if recorded (see Options.RecordIndex).
+	Index []IndexEntry
+}
+
+// WriteIndex writes the index of the numbered pages to `w`, in CSV format, with the label, the
+// document name and the page number of each page.
+func (r *Result) WriteIndex(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"label", "document", "page"}); err != nil {
+		return err
+	}
+	for _, e := range r.Index {
+		if err := cw.Write([]string{e.Label, e.Document, strconv.Itoa(e.Page)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Label returns the text of the stamp of the page numbered `number` with the prefix and the
+// number of digits of `opts`, which can be nil for the defaults.
+func Label(number int, opts *Options) string {
+	prefix, digits := "", 6
+	if opts != nil {
+		prefix = opts.Prefix
+		if opts.Digits > 0 {
+			digits = opts.Digits
+		}
+	}
+	return fmt.Sprintf("%s%0*d", prefix, digits, number)
+}
+
+// Text extent of the stamps, as fractions of the font size above and below the baseline.
+const (
+	stampAscent  = 0.8
+	stampDescent = 0.2
+)
+
+// Stamp stamps the pages of `docs` with sequential numbers, in order, as specified by `opts`,
+// which can be nil for the defaults. The content of the pages is wrapped in a save/restore of the
+// graphics state and the stamps are drawn after it. The stamps are placed and oriented according
+// to the crop boxes and the rotations of the pages.
+// Returns the number following the last page and the index of the pages, to be passed as
+// Options.Start when numbering further documents.
+func Stamp(docs []*Document, opts *Options) (*Result, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	s := stamper{opts: opts, number: opts.Start, font: opts.Font, fontSize: opts.FontSize,
+		margin: opts.Margin, padding: opts.Padding}
+	if s.number == 0 {
+		s.number = 1
+	}
+	if s.font == nil {
+		font, err := model.NewStandard14Font(model.HelveticaName)
+		if err != nil {
+			return nil, err
+		}
+		s.font = font
+	}
+	if s.fontSize <= 0 {
+		s.fontSize = 10
+	}
+	if s.margin == 0 {
+		s.margin = 18
+	}
+	if s.padding == 0 {
+		s.padding = 2
+	}
+	if opts.Digits < 0 {
+		return nil, errors.New("negative number of digits")
+	}
+	if s.margin < 0 || s.padding < 0 {
+		return nil, errors.New("negative margin or padding")
+	}
+	s.fontObj = s.font.ToPdfObject()
+	s.color = opts.Color
+	if s.color == nil {
+		s.color = model.NewPdfColorDeviceRGB(0, 0, 0)
+	}
+
+	result := &Result{}
+	for _, doc := range docs {
+		for i, page := range doc.Pages {
+			label := Label(s.number, opts)
+			if err := s.stampPage(page, label); err != nil {
+				return nil, fmt.Errorf("document %q page %d: %w", doc.Name, i+1, err)
+			}
+			if opts.RecordIndex {
+				result.Index = append(result.Index, IndexEntry{
+					Document: doc.Name,
+					Page:     i + 1,
+					Number:   s.number,
+					Label:    label,
+				})
+			}
+			s.number++
+		}
+	}
+	result.Next = s.number
+	return result, nil
+}
+
+// stamper holds the settings of the stamps, the defaults being applied.
+type stamper struct {
+	opts     *Options
+	number   int
+	font     *model.PdfFont
+	fontObj  core.PdfObject
+	fontSize float64
+	margin   float64
+	padding  float64
+	color    *model.PdfColorDeviceRGB
+}
+
+// stampPage draws `label` on `page`.
+func (s *stamper) stampPage(page *model.PdfPage, label string) error {
+	box, err := page.GetCropBox()
+	if err != nil {
+		return err
+	}
+	rect := box.Normalized()
+	rotate, err := page.GetRotation()
+	if err != nil {
+		return err
+	}
+	encoded, numMisses := s.font.StringToCharcodeBytes(label)
+	if numMisses > 0 {
+		return fmt.Errorf("font %s cannot encode %q", s.font.BaseFont(), label)
+	}
+
+	// The stamp is laid out in the page as displayed, with its lower left corner at the origin.
+	width, height := rect.Width(), rect.Height()
+	if rotate == 90 || rotate == 270 {
+		width, height = height, width
+	}
+	textWidth := 0.0
+	for _, r := range label {
+		metrics, _ := s.font.GetRuneMetrics(r)
+		textWidth += metrics.Wx * s.fontSize / 1000
+	}
+	boxWidth := textWidth + 2*s.padding
+	boxHeight := (stampAscent+stampDescent)*s.fontSize + 2*s.padding
+	x, y := s.margin, s.margin
+	switch s.opts.Position {
+	case PositionBottomRight, PositionTopRight:
+		x = width - s.margin - boxWidth
+	}
+	switch s.opts.Position {
+	case PositionTopRight, PositionTopLeft:
+		y = height - s.margin - boxHeight
+	}
+
+	if page.Resources == nil {
+		page.Resources = model.NewPdfPageResources()
+	}
+	fontName := pageutil.GenerateFontName(page.Resources, "Bates")
+	if err := page.Resources.SetFontByName(fontName, s.fontObj); err != nil {
+		return err
+	}
+
+	m := pageutil.DisplayMatrix(rect, rotate)
+	cc := contentstream.NewContentCreator()
+	if page.Contents != nil {
+		cc.Add_Q()
+	}
+	cc.Add_q().Add_cm(m[0], m[1], m[3], m[4], m[6], m[7])
+	if s.opts.BackgroundColor != nil {
+		cc.SetNonStrokingColor(s.opts.BackgroundColor).
+			Add_re(x, y, boxWidth, boxHeight).
+			Add_f()
+	}
+	cc.SetNonStrokingColor(s.color).
+		Add_BT().
+		Add_Tf(fontName, s.fontSize).
+		Add_Td(x+s.padding, y+s.padding+stampDescent*s.fontSize).
+		Add_Tj(*core.MakeStringFromBytes(encoded)).
+		Add_ET().
+		Add_Q()
+
+	if page.Contents != nil {
+		// The content is wrapped so that the graphics state it leaves does not affect the stamp.
+		prefix, err := core.MakeStream([]byte("q\n"), core.NewFlateEncoder())
+		if err != nil {
+			return err
+		}
+		contents := core.MakeArray(prefix)
+		if arr, ok := core.GetArray(page.Contents); ok {
+			contents.Append(arr.Elements()...)
+		} else {
+			contents.Append(page.Contents)
+		}
+		page.Contents = contents
+	}
+	return page.AddContentStreamByString(cc.String())
+}
@@ -0,0 +1,167 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package bates
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/extractor"
+	"github.com/unidoc/unipdf/v3/internal/pageutil"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// newDocument returns a document named `name` with letter pages rotated by `rotations`, read
+// back from its PDF file.
+func newDocument(t *testing.T, name string, rotations ...int64) *Document {
+	w := model.NewPdfWriter()
+	for i, rotate := range rotations {
+		page := model.NewPdfPage()
+		page.MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+		rotate := rotate
+		page.Rotate = &rotate
+		font := model.NewStandard14FontMustCompile(model.TimesRomanName)
+		require.NoError(t, page.Resources.SetFontByName("F1", font.ToPdfObject()))
+		// The content leaves a modified graphics state, which must not affect the stamps.
+		content := "0.5 0 0 0.5 0 0 cm 1 0 0 rg BT /F1 20 Tf 100 600 Td (Content " +
+			string(rune('A'+i)) + ") Tj ET"
+		require.NoError(t, page.SetContentStreams([]string{content}, core.NewRawEncoder()))
+		require.NoError(t, w.AddPage(page))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	doc, err := NewDocument(name, reader)
+	require.NoError(t, err)
+	return doc
+}
+
+// stampedText returns the text of the pages of the stamped document `doc`.
+func stampedText(t *testing.T, doc *Document) []*extractor.PageText {
+	var texts []*extractor.PageText
+	for _, page := range doc.Pages {
+		ex, err := extractor.New(page)
+		require.NoError(t, err)
+		text, _, _, err := ex.ExtractPageText()
+		require.NoError(t, err)
+		texts = append(texts, text)
+	}
+	return texts
+}
+
+// labelBBox returns the bounding box of `label` in `text`, in the coordinates of the page as
+// displayed, `m` mapping them to the coordinates of the page.
+func labelBBox(t *testing.T, text *extractor.PageText, label string, m transform.Matrix) model.PdfRectangle {
+	offset := strings.Index(text.Text(), label)
+	require.True(t, offset >= 0, "label %q not found", label)
+	var marks extractor.TextMarkArray
+	for _, mark := range text.Marks().Elements() {
+		if mark.Offset >= offset && mark.Offset < offset+len(label) {
+			marks.Append(mark)
+		}
+	}
+	bbox, ok := marks.BBox()
+	require.True(t, ok)
+
+	x0, y0 := inverseTransform(m, bbox.Llx, bbox.Lly)
+	x1, y1 := inverseTransform(m, bbox.Urx, bbox.Ury)
+	return model.PdfRectangle{Llx: x0, Lly: y0, Urx: x1, Ury: y1}.Normalized()
+}
+
+// inverseTransform returns the point transformed to (`x`, `y`) by `m`.
+func inverseTransform(m transform.Matrix, x, y float64) (float64, float64) {
+	a, b, c, d := m[0], m[1], m[3], m[4]
+	x, y = x-m[6], y-m[7]
+	det := a*d - b*c
+	return (d*x - c*y) / det, (a*y - b*x) / det
+}
+
+func TestStamp(t *testing.T) {
+	docs := []*Document{
+		newDocument(t, "first.pdf", 0, 0),
+		newDocument(t, "second.pdf", 90, 180, 270),
+	}
+	opts := &Options{
+		Prefix:          "ABC",
+		Start:           123,
+		BackgroundColor: model.NewPdfColorDeviceRGB(1, 1, 1),
+		RecordIndex:     true,
+	}
+	result, err := Stamp(docs, opts)
+	require.NoError(t, err)
+	require.Equal(t, 128, result.Next)
+	require.Equal(t, []IndexEntry{
+		{"first.pdf", 1, 123, "ABC000123"},
+		{"first.pdf", 2, 124, "ABC000124"},
+		{"second.pdf", 1, 125, "ABC000125"},
+		{"second.pdf", 2, 126, "ABC000126"},
+		{"second.pdf", 3, 127, "ABC000127"},
+	}, result.Index)
+
+	var index bytes.Buffer
+	require.NoError(t, result.WriteIndex(&index))
+	require.Equal(t, "label,document,page\nABC000123,first.pdf,1\nABC000124,first.pdf,2\n"+
+		"ABC000125,second.pdf,1\nABC000126,second.pdf,2\nABC000127,second.pdf,3\n", index.String())
+
+	entry := 0
+	for _, doc := range docs {
+		texts := stampedText(t, doc)
+		for i, text := range texts {
+			label := result.Index[entry].Label
+			entry++
+			// The stamps are upright, so extracted as words.
+			require.Contains(t, text.Text(), label)
+			require.Contains(t, text.Text(), "Content "+string(rune('A'+i)))
+
+			// The stamps are in the bottom right corner of the pages as displayed, within the
+			// margin. The tolerance of a font size allows for the approximate bounding boxes of
+			// the rotated text.
+			rotate, err := doc.Pages[i].GetRotation()
+			require.NoError(t, err)
+			width := 612.0
+			if rotate == 90 || rotate == 270 {
+				width = 792
+			}
+			bbox := labelBBox(t, text, label, pageutil.DisplayMatrix(model.PdfRectangle{Urx: 612, Ury: 792}, rotate))
+			region := model.PdfRectangle{Llx: width - 18 - 60 - 10, Lly: 18 - 10, Urx: width - 18 + 10, Ury: 18 + 14 + 10}
+			require.True(t, bbox.Llx > region.Llx && bbox.Lly > region.Lly && bbox.Urx < region.Urx &&
+				bbox.Ury < region.Ury, "page %d rotate %d bbox %+v", i+1, rotate, bbox)
+		}
+	}
+}
+
+// TestStampOptions checks the positions of the stamps, the continuation of the numbering and the
+// font size.
+func TestStampOptions(t *testing.T) {
+	doc := newDocument(t, "doc.pdf", 0)
+	result, err := Stamp([]*Document{doc}, &Options{
+		Prefix:   "X-",
+		Start:    7,
+		Digits:   3,
+		Position: PositionTopLeft,
+		Margin:   36,
+		FontSize: 20,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 8, result.Next)
+	require.Empty(t, result.Index)
+
+	texts := stampedText(t, doc)
+	require.Contains(t, texts[0].Text(), "X-007")
+	bbox := labelBBox(t, texts[0], "X-007", pageutil.DisplayMatrix(model.PdfRectangle{Urx: 612, Ury: 792}, 0))
+	require.InDelta(t, 38, bbox.Llx, 1, "bbox %+v", bbox)
+	require.InDelta(t, 792-36-2-16, bbox.Lly, 1, "bbox %+v", bbox)
+
+	require.Equal(t, "000042", Label(42, nil))
+	_, err = Stamp([]*Document{doc}, &Options{Digits: -1})
+	require.Error(t, err)
+}
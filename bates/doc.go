@@ -0,0 +1,12 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package bates is used for Bates numbering: stamping the pages of a set of
+// PDF documents with sequential identifiers, e.g. "ABC000123", the counter
+// continuing from document to document. The stamps are drawn above the
+// content of the pages, upright as the pages are displayed, in a corner of
+// the pages. The numbers given to the pages can be recorded for an index of
+// the documents.
+package bates
@@ -0,0 +1,56 @@
+//go:build go1.21
+// +build go1.21
+
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package common
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogLogger returns a StructuredLogger writing to the slog logger `logger`, or to the default
+// slog logger if nil. The fields are passed as slog attributes.
+func NewSlogLogger(logger *slog.Logger) StructuredLogger {
+	return slogLogger{logger: logger}
+}
+
+// slogLogger is a StructuredLogger writing to a slog logger.
+type slogLogger struct {
+	logger *slog.Logger // slog.Default() is used if nil.
+}
+
+func (l slogLogger) log(level slog.Level, msg string, keyvals []interface{}) {
+	logger := l.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	ctx := context.Background()
+	if logger.Enabled(ctx, level) {
+		logger.Log(ctx, level, msg, keyvals...)
+	}
+}
+
+// Debug logs `msg` with the fields `keyvals` at debug level.
+func (l slogLogger) Debug(msg string, keyvals ...interface{}) {
+	l.log(slog.LevelDebug, msg, keyvals)
+}
+
+// Info logs `msg` with the fields `keyvals` at info level.
+func (l slogLogger) Info(msg string, keyvals ...interface{}) {
+	l.log(slog.LevelInfo, msg, keyvals)
+}
+
+// Warn logs `msg` with the fields `keyvals` at warning level.
+func (l slogLogger) Warn(msg string, keyvals ...interface{}) {
+	l.log(slog.LevelWarn, msg, keyvals)
+}
+
+// Error logs `msg` with the fields `keyvals` at error level.
+func (l slogLogger) Error(msg string, keyvals ...interface{}) {
+	l.log(slog.LevelError, msg, keyvals)
+}
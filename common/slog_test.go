@@ -0,0 +1,41 @@
+//go:build go1.21
+// +build go1.21
+
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSlogLogger checks that the messages are logged to the slog logger with their fields as
+// attributes, at its level.
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})
+	logger := WithFields(NewSlogLogger(slog.New(handler)), "page", 3)
+	logger.Debug("hidden")
+	logger.Warn("font not found in resources", "name", "F1")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1)
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	require.Equal(t, "WARN", record["level"])
+	require.Equal(t, "font not found in resources", record["msg"])
+	require.Equal(t, float64(3), record["page"])
+	require.Equal(t, "F1", record["name"])
+
+	// *slog.Logger implements StructuredLogger.
+	var _ StructuredLogger = slog.New(handler)
+}
@@ -0,0 +1,228 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// StructuredLogger is the interface of the loggers logging constant messages with key-value
+// fields, e.g. Warn("recovering from syntax error", "object", 12, "generation", 0). The fields
+// are passed as alternating keys and values, the keys being strings.
+// Keeping the messages constant and the variable parts in the fields makes the logs easy to
+// filter, aggregate and rate-limit. *slog.Logger implements StructuredLogger (see NewSlogLogger).
+//
+// A StructuredLogger can be set per PdfReader, PdfWriter and Creator instance. The global
+// Log is used when none is set.
+type StructuredLogger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// NewLogAdapter returns a StructuredLogger writing to `logger`, the fields being appended to the
+// messages as key=value pairs. The global Log, as set when logging, is written to if `logger` is
+// nil.
+func NewLogAdapter(logger Logger) StructuredLogger {
+	return logAdapter{logger: logger}
+}
+
+// StructuredOrGlobal returns `logger`, or a StructuredLogger writing to the global Log if
+// `logger` is nil.
+func StructuredOrGlobal(logger StructuredLogger) StructuredLogger {
+	if logger == nil {
+		return logAdapter{}
+	}
+	return logger
+}
+
+// WithFields returns a StructuredLogger logging to `logger`, or to the global Log if nil, with the
+// fields `keyvals` prepended to the fields of each message, e.g. the page number of the messages
+// logged when processing a page.
+func WithFields(logger StructuredLogger, keyvals ...interface{}) StructuredLogger {
+	logger = StructuredOrGlobal(logger)
+	if f, ok := logger.(fieldsLogger); ok {
+		return fieldsLogger{logger: f.logger, fields: concatFields(f.fields, keyvals)}
+	}
+	return fieldsLogger{logger: logger, fields: keyvals}
+}
+
+// logAdapter is a StructuredLogger writing to a Logger.
+type logAdapter struct {
+	logger Logger // The global Log is used if nil.
+}
+
+func (l logAdapter) get() Logger {
+	if l.logger == nil {
+		return Log
+	}
+	return l.logger
+}
+
+// Debug logs `msg` with the fields `keyvals` at debug level.
+func (l logAdapter) Debug(msg string, keyvals ...interface{}) {
+	if logger := l.get(); logger.IsLogLevel(LogLevelDebug) {
+		logger.Debug("%s", FormatFields(msg, keyvals...))
+	}
+}
+
+// Info logs `msg` with the fields `keyvals` at info level.
+func (l logAdapter) Info(msg string, keyvals ...interface{}) {
+	if logger := l.get(); logger.IsLogLevel(LogLevelInfo) {
+		logger.Info("%s", FormatFields(msg, keyvals...))
+	}
+}
+
+// Warn logs `msg` with the fields `keyvals` at warning level.
+func (l logAdapter) Warn(msg string, keyvals ...interface{}) {
+	if logger := l.get(); logger.IsLogLevel(LogLevelWarning) {
+		logger.Warning("%s", FormatFields(msg, keyvals...))
+	}
+}
+
+// Error logs `msg` with the fields `keyvals` at error level.
+func (l logAdapter) Error(msg string, keyvals ...interface{}) {
+	if logger := l.get(); logger.IsLogLevel(LogLevelError) {
+		logger.Error("%s", FormatFields(msg, keyvals...))
+	}
+}
+
+// fieldsLogger is a StructuredLogger prepending fields to the fields of the messages.
+type fieldsLogger struct {
+	logger StructuredLogger
+	fields []interface{}
+}
+
+// Debug logs `msg` with the fields of `l` and `keyvals` at debug level.
+func (l fieldsLogger) Debug(msg string, keyvals ...interface{}) {
+	l.logger.Debug(msg, concatFields(l.fields, keyvals)...)
+}
+
+// Info logs `msg` with the fields of `l` and `keyvals` at info level.
+func (l fieldsLogger) Info(msg string, keyvals ...interface{}) {
+	l.logger.Info(msg, concatFields(l.fields, keyvals)...)
+}
+
+// Warn logs `msg` with the fields of `l` and `keyvals` at warning level.
+func (l fieldsLogger) Warn(msg string, keyvals ...interface{}) {
+	l.logger.Warn(msg, concatFields(l.fields, keyvals)...)
+}
+
+// Error logs `msg` with the fields of `l` and `keyvals` at error level.
+func (l fieldsLogger) Error(msg string, keyvals ...interface{}) {
+	l.logger.Error(msg, concatFields(l.fields, keyvals)...)
+}
+
+// concatFields returns the fields `a` followed by the fields `b`, without modifying `a`.
+func concatFields(a, b []interface{}) []interface{} {
+	fields := make([]interface{}, 0, len(a)+len(b))
+	fields = append(fields, a...)
+	return append(fields, b...)
+}
+
+// FormatFields returns `msg` followed by the fields `keyvals` formatted as key=value pairs, the
+// values containing spaces, quotes or '=' characters being quoted. A missing value is shown as
+// MISSING.
+func FormatFields(msg string, keyvals ...interface{}) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		b.WriteByte(' ')
+		b.WriteString(fmt.Sprint(keyvals[i]))
+		b.WriteByte('=')
+		if i+1 == len(keyvals) {
+			b.WriteString("MISSING")
+			break
+		}
+		val := fmt.Sprint(keyvals[i+1])
+		if val == "" || strings.ContainsAny(val, " \t\r\n\"=") {
+			val = strconv.Quote(val)
+		}
+		b.WriteString(val)
+	}
+	return b.String()
+}
+
+// LogRecord is a message logged to a RecordingLogger.
+type LogRecord struct {
+	Level   LogLevel
+	Message string
+	Fields  []interface{} // Alternating keys and values.
+}
+
+// Field returns the value of the field `key` of `rec`, and false if it has no such field.
+func (rec LogRecord) Field(key string) (interface{}, bool) {
+	for i := 0; i+1 < len(rec.Fields); i += 2 {
+		if k, ok := rec.Fields[i].(string); ok && k == key {
+			return rec.Fields[i+1], true
+		}
+	}
+	return nil, false
+}
+
+// RecordingLogger is a StructuredLogger keeping the messages logged in memory, e.g. to check the
+// messages logged in tests. It is safe for concurrent use.
+type RecordingLogger struct {
+	mu      sync.Mutex
+	records []LogRecord
+}
+
+// NewRecordingLogger returns a new RecordingLogger.
+func NewRecordingLogger() *RecordingLogger {
+	return &RecordingLogger{}
+}
+
+// Records returns the messages logged to `l`.
+func (l *RecordingLogger) Records() []LogRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	records := make([]LogRecord, len(l.records))
+	copy(records, l.records)
+	return records
+}
+
+// Find returns the messages logged to `l` with the message `msg`.
+func (l *RecordingLogger) Find(msg string) []LogRecord {
+	var records []LogRecord
+	for _, rec := range l.Records() {
+		if rec.Message == msg {
+			records = append(records, rec)
+		}
+	}
+	return records
+}
+
+func (l *RecordingLogger) record(level LogLevel, msg string, keyvals []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fields := make([]interface{}, len(keyvals))
+	copy(fields, keyvals)
+	l.records = append(l.records, LogRecord{Level: level, Message: msg, Fields: fields})
+}
+
+// Debug records `msg` with the fields `keyvals` at debug level.
+func (l *RecordingLogger) Debug(msg string, keyvals ...interface{}) {
+	l.record(LogLevelDebug, msg, keyvals)
+}
+
+// Info records `msg` with the fields `keyvals` at info level.
+func (l *RecordingLogger) Info(msg string, keyvals ...interface{}) {
+	l.record(LogLevelInfo, msg, keyvals)
+}
+
+// Warn records `msg` with the fields `keyvals` at warning level.
+func (l *RecordingLogger) Warn(msg string, keyvals ...interface{}) {
+	l.record(LogLevelWarning, msg, keyvals)
+}
+
+// Error records `msg` with the fields `keyvals` at error level.
+func (l *RecordingLogger) Error(msg string, keyvals ...interface{}) {
+	l.record(LogLevelError, msg, keyvals)
+}
@@ -0,0 +1,67 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package common
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestFormatFields checks the formatting of the fields appended to the messages of the global
+// logger.
+func TestFormatFields(t *testing.T) {
+	require.Equal(t, "message", FormatFields("message"))
+	require.Equal(t, `unable to load font page=3 name="Times Roman" error="not found" empty="" odd=MISSING`,
+		FormatFields("unable to load font", "page", 3, "name", "Times Roman",
+			"error", errors.New("not found"), "empty", "", "odd"))
+}
+
+// TestLogAdapter checks that the structured logs are written to the printf style logger, at its
+// log level.
+func TestLogAdapter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogAdapter(NewWriterLogger(LogLevelWarning, &buf))
+	logger.Debug("hidden", "object", 1)
+	logger.Warn("recovering from syntax error", "object", 12, "generation", 0)
+	logger.Error("failed", "error", "bad xref")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	require.True(t, strings.HasPrefix(lines[0], "[WARNING]"), lines[0])
+	require.True(t, strings.HasSuffix(lines[0], "recovering from syntax error object=12 generation=0"), lines[0])
+	require.True(t, strings.HasPrefix(lines[1], "[ERROR]"), lines[1])
+	require.True(t, strings.HasSuffix(lines[1], `failed error="bad xref"`), lines[1])
+
+	// The global logger is used for nil loggers, as set when logging.
+	defer SetLogger(Log)
+	buf.Reset()
+	SetLogger(NewWriterLogger(LogLevelDebug, &buf))
+	StructuredOrGlobal(nil).Info("loaded", "pages", 2)
+	require.Contains(t, buf.String(), "loaded pages=2")
+}
+
+// TestWithFields checks that the fields are prepended to the fields of the messages.
+func TestWithFields(t *testing.T) {
+	rec := NewRecordingLogger()
+	logger := WithFields(WithFields(rec, "page", 2), "level", 1)
+	logger.Warn("font not found", "name", "F1")
+	logger.Debug("done")
+	require.Equal(t, []LogRecord{
+		{LogLevelWarning, "font not found", []interface{}{"page", 2, "level", 1, "name", "F1"}},
+		{LogLevelDebug, "done", []interface{}{"page", 2, "level", 1}},
+	}, rec.Records())
+
+	records := rec.Find("font not found")
+	require.Len(t, records, 1)
+	name, ok := records[0].Field("name")
+	require.True(t, ok)
+	require.Equal(t, "F1", name)
+	_, ok = records[0].Field("object")
+	require.False(t, ok)
+}
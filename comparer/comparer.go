@@ -0,0 +1,430 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package comparer
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/extractor"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// DifferenceType represents the type of a difference between two documents.
+type DifferenceType int
+
+// Types of differences.
+const (
+	// DifferencePageCount is a difference in the number of pages.
+	DifferencePageCount DifferenceType = iota
+
+	// DifferencePageSize is a difference in the size or the rotation of a page.
+	DifferencePageSize
+
+	// DifferenceText is a word of a page changed, added or removed.
+	DifferenceText
+
+	// DifferenceFont is a font used by the text of a page in one document only.
+	DifferenceFont
+
+	// DifferenceImage is an image of a page changed, added or removed, by content.
+	DifferenceImage
+
+	// DifferenceAnnotation is an annotation of a page changed, added or removed.
+	DifferenceAnnotation
+)
+
+// String returns a string representation of the difference type.
+func (t DifferenceType) String() string {
+	switch t {
+	case DifferencePageCount:
+		return "PageCount"
+	case DifferencePageSize:
+		return "PageSize"
+	case DifferenceText:
+		return "Text"
+	case DifferenceFont:
+		return "Font"
+	case DifferenceImage:
+		return "Image"
+	case DifferenceAnnotation:
+		return "Annotation"
+	}
+	return fmt.Sprintf("DifferenceType(%d)", int(t))
+}
+
+// Difference represents a difference between two documents A and B.
+type Difference struct {
+	// Type is the type of the difference.
+	Type DifferenceType
+
+	// Page is the number of the page, starting from 1, or 0 for the differences of documents.
+	Page int
+
+	// A and B describe the content in documents A and B, e.g. the text of a word. One of them is
+	// empty for the content added or removed.
+	A, B string
+
+	// Rect is the location of the content on the page, in document A if it is found there and in
+	// document B otherwise. It is empty for the differences of documents, page sizes and fonts.
+	Rect model.PdfRectangle
+}
+
+// String returns a string describing `d`.
+func (d Difference) String() string {
+	var loc string
+	if d.Rect != (model.PdfRectangle{}) {
+		loc = fmt.Sprintf(" at (%.1f, %.1f) (%.1f, %.1f)", d.Rect.Llx, d.Rect.Lly, d.Rect.Urx, d.Rect.Ury)
+	}
+	return fmt.Sprintf("%s difference on page %d%s: %q -> %q", d.Type, d.Page, loc, d.A, d.B)
+}
+
+// Report is the result of the comparison of two documents.
+type Report struct {
+	// Differences are the differences found, ordered by page, then by type.
+	Differences []Difference
+}
+
+// Equal returns true if no difference was found.
+func (r *Report) Equal() bool {
+	return len(r.Differences) == 0
+}
+
+// Options defines the comparison of documents.
+type Options struct {
+	// PositionTolerance is the maximum distance, in points, the positions of the words,
+	// images and annotations may be moved by. The default tolerance of 1 point is used if it
+	// is 0.
+	PositionTolerance float64
+
+	// IgnoreRegions are the regions of the pages ignored, by page number, starting from 1. The
+	// content whose center is in one of the regions of its page is ignored in both documents.
+	IgnoreRegions map[int][]model.PdfRectangle
+
+	// IgnoreText, if not nil, is matched with the text of the pages as extracted by the
+	// extractor package. The words overlapping its matches are ignored, e.g. dates.
+	IgnoreText *regexp.Regexp
+}
+
+// Compare compares the pages `pagesA` of document A with the pages `pagesB` of document B and
+// returns the differences found.
+func Compare(pagesA, pagesB []*model.PdfPage, opts *Options) (*Report, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	tol := opts.PositionTolerance
+	if tol <= 0 {
+		tol = 1
+	}
+
+	report := &Report{}
+	if len(pagesA) != len(pagesB) {
+		report.Differences = append(report.Differences, Difference{
+			Type: DifferencePageCount,
+			A:    fmt.Sprintf("%d pages", len(pagesA)),
+			B:    fmt.Sprintf("%d pages", len(pagesB)),
+		})
+	}
+
+	for i := 0; i < len(pagesA) && i < len(pagesB); i++ {
+		pageNum := i + 1
+		pc := &pageComparison{
+			pageNum: pageNum,
+			tol:     tol,
+			regions: opts.IgnoreRegions[pageNum],
+			regexp:  opts.IgnoreText,
+		}
+		a, err := pc.loadPage(pagesA[i])
+		if err != nil {
+			return nil, fmt.Errorf("page %d of document A: %v", pageNum, err)
+		}
+		b, err := pc.loadPage(pagesB[i])
+		if err != nil {
+			return nil, fmt.Errorf("page %d of document B: %v", pageNum, err)
+		}
+		report.Differences = append(report.Differences, pc.compare(a, b)...)
+	}
+	return report, nil
+}
+
+// item is a word, an image or an annotation of a page, compared by its key and its position.
+type item struct {
+	key  string // The compared content, e.g. the text of a word or the hash of an image.
+	desc string // Description of the item in the report.
+	rect model.PdfRectangle
+}
+
+// pageContent is the compared content of a page.
+type pageContent struct {
+	size   string
+	words  []item
+	fonts  map[string]bool
+	images []item
+	annots []item
+}
+
+// pageComparison compares the content of a page of the two documents.
+type pageComparison struct {
+	pageNum int
+	tol     float64
+	regions []model.PdfRectangle
+	regexp  *regexp.Regexp
+}
+
+// ignored returns true if the center of `rect` is in one of the ignored regions.
+func (pc *pageComparison) ignored(rect model.PdfRectangle) bool {
+	x, y := (rect.Llx+rect.Urx)/2, (rect.Lly+rect.Ury)/2
+	for _, r := range pc.regions {
+		if x >= r.Llx && x <= r.Urx && y >= r.Lly && y <= r.Ury {
+			return true
+		}
+	}
+	return false
+}
+
+// loadPage returns the compared content of `page`.
+func (pc *pageComparison) loadPage(page *model.PdfPage) (*pageContent, error) {
+	mbox, err := page.GetMediaBox()
+	if err != nil {
+		return nil, err
+	}
+	var rotate int64
+	if page.Rotate != nil {
+		rotate = *page.Rotate
+	}
+	content := &pageContent{
+		size:  fmt.Sprintf("%.2fx%.2f rotated %d", mbox.Width(), mbox.Height(), rotate),
+		fonts: make(map[string]bool),
+	}
+
+	ex, err := extractor.New(page)
+	if err != nil {
+		return nil, err
+	}
+	pageText, _, _, err := ex.ExtractPageText()
+	if err != nil {
+		return nil, err
+	}
+	pc.loadWords(pageText.Marks().Elements(), content)
+
+	pageImages, err := ex.ExtractPageImages(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, mark := range pageImages.Images {
+		rect := model.PdfRectangle{Llx: mark.X, Lly: mark.Y, Urx: mark.X + mark.Width, Ury: mark.Y + mark.Height}
+		if pc.ignored(rect) {
+			continue
+		}
+		hash := imageHash(mark.Image)
+		content.images = append(content.images, item{
+			key:  hash,
+			desc: fmt.Sprintf("%dx%d image %s", mark.Image.Width, mark.Image.Height, hash[:16]),
+			rect: rect,
+		})
+	}
+
+	annotations, err := page.GetAnnotations()
+	if err != nil {
+		return nil, err
+	}
+	for _, annot := range annotations {
+		arr, ok := core.GetArray(annot.Rect)
+		if !ok {
+			continue
+		}
+		rect, err := model.NewPdfRectangle(*arr)
+		if err != nil {
+			continue
+		}
+		if pc.ignored(*rect) {
+			continue
+		}
+		desc := annotationDescription(annot)
+		content.annots = append(content.annots, item{key: desc, desc: desc, rect: *rect})
+	}
+	return content, nil
+}
+
+// loadWords adds the words of the text marks `marks` of a page, with the fonts they are drawn
+// with, to `content`. The words are delimited by the spaces and the line breaks.
+func (pc *pageComparison) loadWords(marks []extractor.TextMark, content *pageContent) {
+	// The text of the marks, whose offsets are those of the marks. It is the extracted text of
+	// the page.
+	var b strings.Builder
+	for _, tm := range marks {
+		b.WriteString(tm.Text)
+	}
+	text := b.String()
+	var ignoredRanges [][]int
+	if pc.regexp != nil {
+		ignoredRanges = pc.regexp.FindAllStringIndex(text, -1)
+	}
+	inIgnoredRange := func(tm extractor.TextMark) bool {
+		for _, r := range ignoredRanges {
+			if tm.Offset < r[1] && tm.Offset+len(tm.Text) > r[0] {
+				return true
+			}
+		}
+		return false
+	}
+
+	var word []extractor.TextMark
+	addWord := func() {
+		if len(word) == 0 {
+			return
+		}
+		marks := word
+		word = nil
+		var text strings.Builder
+		rect := marks[0].BBox
+		fonts := make(map[string]bool)
+		for _, tm := range marks {
+			if inIgnoredRange(tm) {
+				return
+			}
+			text.WriteString(tm.Text)
+			rect = rect.Union(tm.BBox)
+			if tm.Font != nil {
+				fonts[tm.Font.BaseFont()] = true
+			}
+		}
+		if pc.ignored(rect) {
+			return
+		}
+		content.words = append(content.words, item{key: text.String(), desc: text.String(), rect: rect})
+		for font := range fonts {
+			content.fonts[font] = true
+		}
+	}
+	for _, tm := range marks {
+		if strings.TrimFunc(tm.Text, unicode.IsSpace) == "" {
+			addWord()
+			continue
+		}
+		word = append(word, tm)
+	}
+	addWord()
+}
+
+// compare returns the differences between the content `a` and `b` of the page in documents A and
+// B.
+func (pc *pageComparison) compare(a, b *pageContent) []Difference {
+	var diffs []Difference
+	if a.size != b.size {
+		diffs = append(diffs, Difference{Type: DifferencePageSize, Page: pc.pageNum, A: a.size, B: b.size})
+	}
+	diffs = append(diffs, pc.compareItems(DifferenceText, a.words, b.words)...)
+	for _, font := range sortedKeys(a.fonts) {
+		if !b.fonts[font] {
+			diffs = append(diffs, Difference{Type: DifferenceFont, Page: pc.pageNum, A: font})
+		}
+	}
+	for _, font := range sortedKeys(b.fonts) {
+		if !a.fonts[font] {
+			diffs = append(diffs, Difference{Type: DifferenceFont, Page: pc.pageNum, B: font})
+		}
+	}
+	diffs = append(diffs, pc.compareItems(DifferenceImage, a.images, b.images)...)
+	diffs = append(diffs, pc.compareItems(DifferenceAnnotation, a.annots, b.annots)...)
+	return diffs
+}
+
+// compareItems returns the differences of type `typ` between the items `a` and `b` of the page
+// in documents A and B. The items with the same keys within the position tolerance match. The
+// unmatched items at the same positions are reported as changed and the others as removed or
+// added.
+func (pc *pageComparison) compareItems(typ DifferenceType, a, b []item) []Difference {
+	matchedB := make([]bool, len(b))
+	var unmatchedA []item
+	for _, itemA := range a {
+		matched := false
+		for j, itemB := range b {
+			if !matchedB[j] && itemA.key == itemB.key && pc.near(itemA.rect, itemB.rect) {
+				matchedB[j] = true
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			unmatchedA = append(unmatchedA, itemA)
+		}
+	}
+
+	var diffs []Difference
+	for _, itemA := range unmatchedA {
+		diff := Difference{Type: typ, Page: pc.pageNum, A: itemA.desc, Rect: itemA.rect}
+		for j, itemB := range b {
+			if !matchedB[j] && pc.near(itemA.rect, itemB.rect) {
+				matchedB[j] = true
+				diff.B = itemB.desc
+				break
+			}
+		}
+		diffs = append(diffs, diff)
+	}
+	for j, itemB := range b {
+		if !matchedB[j] {
+			diffs = append(diffs, Difference{Type: typ, Page: pc.pageNum, B: itemB.desc, Rect: itemB.rect})
+		}
+	}
+	return diffs
+}
+
+// near returns true if the lower left corners of `r1` and `r2` are within the position tolerance.
+func (pc *pageComparison) near(r1, r2 model.PdfRectangle) bool {
+	return math.Abs(r1.Llx-r2.Llx) <= pc.tol && math.Abs(r1.Lly-r2.Lly) <= pc.tol
+}
+
+// imageHash returns the hash of the dimensions and the samples of `img`.
+func imageHash(img *model.Image) string {
+	h := sha256.New()
+	binary.Write(h, binary.BigEndian, []int64{img.Width, img.Height, img.BitsPerComponent,
+		int64(img.ColorComponents)})
+	h.Write(img.Data)
+	h.Write(img.AlphaData())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// annotationDescription returns the description of `annot`: its subtype followed by its
+// contents and the URI of its action, if any.
+func annotationDescription(annot *model.PdfAnnotation) string {
+	parts := []string{"Annotation"}
+	if ctx := annot.GetContext(); ctx != nil {
+		if dict, ok := core.GetDict(ctx.ToPdfObject()); ok {
+			if subtype, ok := core.GetNameVal(dict.Get("Subtype")); ok {
+				parts[0] = subtype
+			}
+			if action, ok := core.GetDict(dict.Get("A")); ok {
+				if uri, ok := core.GetStringVal(action.Get("URI")); ok {
+					parts = append(parts, uri)
+				}
+			}
+		}
+	}
+	if contents, ok := core.GetStringVal(annot.Contents); ok && contents != "" {
+		parts = append(parts, fmt.Sprintf("%q", contents))
+	}
+	return strings.Join(parts, " ")
+}
+
+// sortedKeys returns the keys of `m` in sorted order.
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,186 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package comparer
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/creator"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// fixture describes the variable content of the test documents.
+type fixture struct {
+	date       string
+	total      string
+	signedFont model.StdFontName
+	draft      string
+	imageGray  uint8
+	annotation string
+	page2Size  creator.PageSize
+	extraPage  bool
+}
+
+// referenceFixture is the content of the reference document.
+var referenceFixture = fixture{
+	date:       "2020-05-01 10:00",
+	total:      "120",
+	signedFont: model.TimesRomanName,
+	draft:      "Draft 7",
+	imageGray:  0x40,
+	annotation: "Reviewed",
+	page2Size:  creator.PageSizeLetter,
+}
+
+// makeDocument returns the pages of the test document with the content of `f`.
+func makeDocument(t *testing.T, f fixture) []*model.PdfPage {
+	c := creator.New()
+	c.SetPageSize(creator.PageSizeLetter)
+	page := c.NewPage()
+
+	drawText := func(text string, x, y float64, fontName model.StdFontName) {
+		p := c.NewParagraph(text)
+		p.SetFont(model.NewStandard14FontMustCompile(fontName))
+		p.SetPos(x, y)
+		require.NoError(t, c.Draw(p))
+	}
+	drawText("Report generated on "+f.date, 50, 50, model.HelveticaName)
+	drawText("Total amount is "+f.total+" dollars", 50, 80, model.HelveticaName)
+	drawText("Approved by the director", 50, 110, model.TimesRomanName)
+	drawText("Signature", 50, 140, f.signedFont)
+	drawText(f.draft, 500, 20, model.HelveticaName)
+
+	goimg := image.NewGray(image.Rect(0, 0, 8, 8))
+	for i := range goimg.Pix {
+		goimg.Pix[i] = f.imageGray
+	}
+	goimg.SetGray(0, 0, color.Gray{Y: 0xff})
+	img, err := c.NewImageFromGoImage(goimg)
+	require.NoError(t, err)
+	img.SetPos(50, 200)
+	img.SetWidth(40)
+	img.SetHeight(40)
+	require.NoError(t, c.Draw(img))
+
+	annot := model.NewPdfAnnotationText()
+	annot.Rect = core.MakeArrayFromIntegers([]int{300, 500, 320, 520})
+	annot.Contents = core.MakeString(f.annotation)
+	page.AddAnnotation(annot.PdfAnnotation)
+
+	c.SetPageSize(f.page2Size)
+	c.NewPage()
+	if f.extraPage {
+		c.NewPage()
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	var pages []*model.PdfPage
+	for i := 1; i <= numPages; i++ {
+		page, err := reader.GetPage(i)
+		require.NoError(t, err)
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// TestCompareSame checks that a document compared with a copy of itself is equal.
+func TestCompareSame(t *testing.T) {
+	pagesA := makeDocument(t, referenceFixture)
+	pagesB := makeDocument(t, referenceFixture)
+	report, err := Compare(pagesA, pagesB, nil)
+	require.NoError(t, err)
+	require.True(t, report.Equal(), "%v", report.Differences)
+}
+
+// TestComparePerturbed checks that the differences injected in a copy of a document are reported,
+// except for those in the ignored regions and text.
+func TestComparePerturbed(t *testing.T) {
+	perturbed := referenceFixture
+	perturbed.date = "2021-06-02 11:30"
+	perturbed.total = "125"
+	perturbed.signedFont = model.CourierName
+	perturbed.draft = "Draft 8"
+	perturbed.imageGray = 0x80
+	perturbed.annotation = "Rejected"
+	perturbed.page2Size = creator.PageSizeA4
+	perturbed.extraPage = true
+
+	pagesA := makeDocument(t, referenceFixture)
+	pagesB := makeDocument(t, perturbed)
+	opts := &Options{
+		IgnoreRegions: map[int][]model.PdfRectangle{
+			// The draft number, at the top right of the first page.
+			1: {{Llx: 480, Lly: 740, Urx: 612, Ury: 792}},
+		},
+		IgnoreText: regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}`),
+	}
+	report, err := Compare(pagesA, pagesB, opts)
+	require.NoError(t, err)
+	require.False(t, report.Equal())
+
+	type diff struct {
+		typ  DifferenceType
+		page int
+		a, b string
+	}
+	var diffs []diff
+	for _, d := range report.Differences {
+		diffs = append(diffs, diff{d.Type, d.Page, d.A, d.B})
+	}
+	require.Equal(t, []diff{
+		{DifferencePageCount, 0, "2 pages", "3 pages"},
+		{DifferenceText, 1, "120", "125"},
+		{DifferenceFont, 1, "", "Courier"},
+		{DifferenceImage, 1, diffs[3].a, diffs[3].b},
+		{DifferenceAnnotation, 1, `Text "Reviewed"`, `Text "Rejected"`},
+		{DifferencePageSize, 2, "612.00x792.00 rotated 0", "595.28x841.89 rotated 0"},
+	}, diffs)
+	require.NotEqual(t, diffs[3].a, diffs[3].b)
+	require.Contains(t, diffs[3].a, "8x8 image")
+
+	// The words are located on the page.
+	rect := report.Differences[1].Rect
+	require.True(t, rect.Llx > 50 && rect.Urx < 300 && rect.Lly > 680 && rect.Ury < 720, "%+v", rect)
+
+	// Without the ignore options, the date and the draft number differ as well.
+	report, err = Compare(pagesA, pagesB, nil)
+	require.NoError(t, err)
+	var texts []string
+	for _, d := range report.Differences {
+		if d.Type == DifferenceText {
+			texts = append(texts, d.A+" -> "+d.B)
+		}
+	}
+	require.Equal(t, []string{"7 -> 8", "2020-05-01 -> 2021-06-02", "10:00 -> 11:30", "120 -> 125"}, texts)
+}
+
+// TestComparePositionTolerance checks that the words moved by less than the tolerance match.
+func TestComparePositionTolerance(t *testing.T) {
+	pc := &pageComparison{pageNum: 1, tol: 1}
+	a := []item{{key: "word", desc: "word", rect: model.PdfRectangle{Llx: 10, Lly: 10, Urx: 30, Ury: 20}}}
+	b := []item{{key: "word", desc: "word", rect: model.PdfRectangle{Llx: 10.5, Lly: 9.5, Urx: 30.5, Ury: 19.5}}}
+	require.Empty(t, pc.compareItems(DifferenceText, a, b))
+
+	b[0].rect.Llx = 12
+	diffs := pc.compareItems(DifferenceText, a, b)
+	require.Len(t, diffs, 2)
+	require.Equal(t, "word", diffs[0].A)
+	require.Equal(t, "", diffs[0].B)
+	require.Equal(t, "", diffs[1].A)
+	require.Equal(t, "word", diffs[1].B)
+}
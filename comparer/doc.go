@@ -0,0 +1,12 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package comparer is used for comparing PDF documents, e.g. checking that a
+// regenerated document matches a reference document. The pages are compared
+// by their sizes, the words of their text and their positions, the fonts of
+// the text, the content of their images and their annotations. Regions of the
+// pages and text matching a regular expression, e.g. timestamps, can be
+// ignored.
+package comparer
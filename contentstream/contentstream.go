@@ -8,6 +8,7 @@ package contentstream
 import (
 	"bytes"
 	"fmt"
+	"strings"
 
 	"github.com/unidoc/unipdf/v3/core"
 )
@@ -79,6 +80,13 @@ func (ops *ContentStreamOperations) WrapIfNeeded() *ContentStreamOperations {
 // Bytes converts a set of content stream operations to a content stream byte presentation,
 // i.e. the kind that can be stored as a PDF stream or string format.
 func (ops *ContentStreamOperations) Bytes() []byte {
+	return ops.BytesWithPrecision(-1)
+}
+
+// BytesWithPrecision is the same as Bytes, except that the real number operands, including the
+// numbers of array operands, are written with at most `precision` decimal places (see
+// core.FormatFloat). The numbers are written as they are if `precision` is negative.
+func (ops *ContentStreamOperations) BytesWithPrecision(precision int) []byte {
 	var buf bytes.Buffer
 
 	for _, op := range *ops {
@@ -94,7 +102,7 @@ func (ops *ContentStreamOperations) Bytes() []byte {
 		} else {
 			// Default handler.
 			for _, param := range op.Params {
-				buf.WriteString(param.WriteString())
+				buf.WriteString(formatOperand(param, precision))
 				buf.WriteString(" ")
 
 			}
@@ -106,6 +114,25 @@ func (ops *ContentStreamOperations) Bytes() []byte {
 	return buf.Bytes()
 }
 
+// formatOperand returns the representation of the operand `param`, its real numbers being
+// written with at most `precision` decimal places.
+func formatOperand(param core.PdfObject, precision int) string {
+	if precision < 0 {
+		return param.WriteString()
+	}
+	switch t := param.(type) {
+	case *core.PdfObjectFloat:
+		return core.FormatFloat(float64(*t), precision)
+	case *core.PdfObjectArray:
+		elems := make([]string, t.Len())
+		for i, elem := range t.Elements() {
+			elems[i] = formatOperand(elem, precision)
+		}
+		return "[" + strings.Join(elems, " ") + "]"
+	}
+	return param.WriteString()
+}
+
 // String returns `ops.Bytes()` as a string.
 func (ops *ContentStreamOperations) String() string {
 	return string(ops.Bytes())
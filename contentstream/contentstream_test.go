@@ -1,7 +1,14 @@
 package contentstream
 
 import (
+	"errors"
+	"math"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
 )
 
 func TestOperandTJSpacing(t *testing.T) {
@@ -23,3 +30,149 @@ func TestOperandTJSpacing(t *testing.T) {
 	}
 
 }
+
+func TestContentCreatorPrecision(t *testing.T) {
+	coords := []float64{0.30000000000000004, 1.0 / 3, 72, -2.0000001, 595.2755905511812}
+	build := func(precision int) []byte {
+		cc := NewContentCreator()
+		cc.SetPrecision(precision)
+		cc.Add_q().
+			Add_cm(coords[0], coords[1], coords[2], coords[3], coords[4], 0).
+			Add_m(coords[0], coords[1]).
+			Add_l(coords[3], coords[4]).
+			Add_d([]int64{3, 2}, 0).
+			Add_S().
+			Add_Q()
+		return cc.Bytes()
+	}
+
+	full := build(-1)
+	rounded := build(2)
+	if len(rounded) >= len(full) {
+		t.Fatalf("%d >= %d", len(rounded), len(full))
+	}
+	if !strings.Contains(string(rounded), "0.3 0.33 72 -2 595.28 0 cm") {
+		t.Fatalf("Unexpected content: %s", rounded)
+	}
+
+	// The coordinates read back are within the precision.
+	for _, precision := range []int{0, 2, 4} {
+		ops, err := NewContentStreamParser(string(build(precision))).Parse()
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		var cm *ContentStreamOperation
+		for _, op := range *ops {
+			if op.Operand == "cm" {
+				cm = op
+			}
+		}
+		if cm == nil {
+			t.Fatalf("Missing cm operation")
+		}
+		vals, err := core.GetNumbersAsFloat(cm.Params)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		for i, val := range vals[:5] {
+			if math.Abs(val-coords[i]) > 0.5*math.Pow10(-precision) {
+				t.Fatalf("%d: %v != %v", precision, val, coords[i])
+			}
+		}
+	}
+}
+
+// TestContentCreatorOperators tests that the operations added with the ContentCreator methods
+// are serialized as written by hand.
+func TestContentCreatorOperators(t *testing.T) {
+	ops, err := NewContentStreamParser("BI /W 2 /H 1 /CS /G /BPC 8 ID \x00\xff EI").Parse()
+	require.NoError(t, err)
+	require.Len(t, *ops, 1)
+	img := (*ops)[0].Params[0].(*ContentStreamInlineImage)
+
+	properties := core.MakeDict()
+	properties.Set("MCID", core.MakeInteger(0))
+
+	cc := NewContentCreator()
+	cc.Add_d0(500, 0).
+		Add_d1(500, 0, 0, -10, 480, 700).
+		Add_J("1").
+		Add_j("2").
+		Add_d([]int64{3, 2}, 1).
+		Add_ri("Perceptual").
+		Add_i(50).
+		Add_Tr(7).
+		Add_SC(0.5).
+		Add_sc(1, 0, 0).
+		Add_SCN(0, 0, 0, 1).
+		Add_scn(0.25).
+		Add_m(0, 0).Add_l(10, 10).Add_F().
+		Add_BX().Add_EX().
+		Add_MP("Tag").
+		Add_DP("Tag", core.MakeName("MC0")).
+		Add_BDC("Span", properties).
+		Add_EMC().
+		Add_BI(img).
+		AddCheckedOperand(ContentStreamOperation{Operand: "Tc", Params: []core.PdfObject{core.MakeFloat(1.5)}})
+	require.NoError(t, cc.Err())
+
+	expected := "500 0 d0\n" +
+		"500 0 0 -10 480 700 d1\n" +
+		"1 J\n" +
+		"2 j\n" +
+		"[3 2] 1 d\n" +
+		"/Perceptual ri\n" +
+		"50 i\n" +
+		"7 Tr\n" +
+		"0.5 SC\n" +
+		"1 0 0 sc\n" +
+		"0 0 0 1 SCN\n" +
+		"0.25 scn\n" +
+		"0 0 m\n" +
+		"10 10 l\n" +
+		"F\n" +
+		"BX\n" +
+		"EX\n" +
+		"/Tag MP\n" +
+		"/Tag /MC0 DP\n" +
+		"/Span <</MCID 0>> BDC\n" +
+		"EMC\n" +
+		"BI\n/BPC 8\n/CS /G\n/H 1\n/W 2\nID \x00\xff\nEI\n" +
+		"1.5 Tc\n"
+	require.Equal(t, expected, cc.String())
+}
+
+// TestContentCreatorInvalidOperands tests that operations with invalid operands are not added by
+// the ContentCreator and that the first error is returned by Err.
+func TestContentCreatorInvalidOperands(t *testing.T) {
+	testCases := []struct {
+		name string
+		add  func(cc *ContentCreator)
+	}{
+		{"intent", func(cc *ContentCreator) { cc.Add_ri("Vivid") }},
+		{"line cap", func(cc *ContentCreator) { cc.Add_J("5") }},
+		{"line join", func(cc *ContentCreator) { cc.Add_j("Round") }},
+		{"render mode", func(cc *ContentCreator) { cc.Add_Tr(9) }},
+		{"flatness", func(cc *ContentCreator) { cc.Add_i(101) }},
+		{"dash", func(cc *ContentCreator) { cc.Add_d([]int64{-1}, 0) }},
+		{"components", func(cc *ContentCreator) { cc.Add_sc(0, 0, 0, 0, 0) }},
+		{"properties", func(cc *ContentCreator) { cc.Add_DP("Tag", core.MakeInteger(1)) }},
+		{"inline image", func(cc *ContentCreator) { cc.Add_BI(&ContentStreamInlineImage{}) }},
+		{"operator", func(cc *ContentCreator) { cc.AddCheckedOperand(ContentStreamOperation{Operand: "XY"}) }},
+		{"count", func(cc *ContentCreator) {
+			cc.AddCheckedOperand(ContentStreamOperation{Operand: "m", Params: []core.PdfObject{core.MakeFloat(1)}})
+		}},
+		{"type", func(cc *ContentCreator) {
+			cc.AddCheckedOperand(ContentStreamOperation{Operand: "Tf",
+				Params: []core.PdfObject{core.MakeString("F0"), core.MakeInteger(12)}})
+		}},
+	}
+	for _, tc := range testCases {
+		cc := NewContentCreator()
+		cc.Add_q()
+		tc.add(cc)
+		cc.Add_Q()
+		require.True(t, errors.Is(cc.Err(), ErrInvalidOperand), "%s: err=%v", tc.name, cc.Err())
+		require.Equal(t, "q\nQ\n", cc.String(), tc.name)
+	}
+}
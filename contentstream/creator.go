@@ -6,7 +6,9 @@
 package contentstream
 
 import (
+	"fmt"
 	"math"
+	"strconv"
 
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
@@ -16,15 +18,30 @@ import (
 // ContentCreator is a builder for PDF content streams.
 type ContentCreator struct {
 	operands ContentStreamOperations
+
+	// Maximum number of decimal places of the real numbers, negative if not limited.
+	precision int
+
+	// The first error of the operations added with invalid operands.
+	err error
 }
 
 // NewContentCreator returns a new initialized ContentCreator.
 func NewContentCreator() *ContentCreator {
 	creator := &ContentCreator{}
 	creator.operands = ContentStreamOperations{}
+	creator.precision = -1
 	return creator
 }
 
+// SetPrecision sets the maximum number of decimal places of the real numbers written by Bytes
+// and String, e.g. the coordinates of paths and the matrices of cm operations. The numbers are
+// rounded, without trailing zeros, and numbers equal to integers are written as integers. The
+// number of decimal places is not limited if `precision` is negative, which is the default.
+func (cc *ContentCreator) SetPrecision(precision int) {
+	cc.precision = precision
+}
+
 // Operations returns the list of operations.
 func (cc *ContentCreator) Operations() *ContentStreamOperations {
 	return &cc.operands
@@ -33,12 +50,12 @@ func (cc *ContentCreator) Operations() *ContentStreamOperations {
 // Bytes converts the content stream operations to a content stream byte presentation, i.e. the kind that can be
 // stored as a PDF stream or string format.
 func (cc *ContentCreator) Bytes() []byte {
-	return cc.operands.Bytes()
+	return cc.operands.BytesWithPrecision(cc.precision)
 }
 
 // String is same as Bytes() except returns as a string for convenience.
 func (cc *ContentCreator) String() string {
-	return string(cc.operands.Bytes())
+	return string(cc.Bytes())
 }
 
 // Wrap ensures that the contentstream is wrapped within a balanced q ... Q expression.
@@ -52,6 +69,48 @@ func (cc *ContentCreator) AddOperand(op ContentStreamOperation) *ContentCreator
 	return cc
 }
 
+// AddCheckedOperand adds operation `op` if its operator is a valid PDF content stream operator and
+// its operands have the number, types and values the operator requires. Otherwise, the operation
+// is not added and the error is returned by Err.
+func (cc *ContentCreator) AddCheckedOperand(op ContentStreamOperation) *ContentCreator {
+	return cc.addChecked(&op)
+}
+
+// Err returns the error of the first operation that was not added because of invalid operands,
+// or nil if all operations were added.
+func (cc *ContentCreator) Err() error {
+	return cc.err
+}
+
+// addChecked adds `op` if valid, otherwise it sets the error of `cc`, if not already set.
+func (cc *ContentCreator) addChecked(op *ContentStreamOperation) *ContentCreator {
+	if err := checkOperation(op); err != nil {
+		common.Log.Debug("ERROR: invalid operation %s: %v", op.Operand, err)
+		if cc.err == nil {
+			cc.err = err
+		}
+		return cc
+	}
+	cc.operands = append(cc.operands, op)
+	return cc
+}
+
+// addStyle adds operation `operand` setting the line cap or join style `style`, an integer.
+func (cc *ContentCreator) addStyle(operand, style string) *ContentCreator {
+	val, err := strconv.ParseInt(style, 10, 64)
+	if err != nil {
+		common.Log.Debug("ERROR: invalid %s style %q", operand, style)
+		if cc.err == nil {
+			cc.err = fmt.Errorf("%w: invalid %s style %q", ErrInvalidOperand, operand, style)
+		}
+		return cc
+	}
+	op := ContentStreamOperation{}
+	op.Operand = operand
+	op.Params = makeParamsFromInts([]int64{val})
+	return cc.addChecked(&op)
+}
+
 // Graphics state operators.
 
 // Add_q adds 'q' operand to the content stream: Pushes the current graphics state on the stack.
@@ -117,25 +176,19 @@ func (cc *ContentCreator) Add_w(lineWidth float64) *ContentCreator {
 }
 
 // Add_J adds 'J' operand to the content stream: Set the line cap style (graphics state).
+// `lineCapStyle` is "0" for butt caps, "1" for round caps or "2" for projecting square caps.
 //
 // See section 8.4.4 "Graphic State Operators" and Table 57 (pp. 135-136 PDF32000_2008).
 func (cc *ContentCreator) Add_J(lineCapStyle string) *ContentCreator {
-	op := ContentStreamOperation{}
-	op.Operand = "J"
-	op.Params = makeParamsFromNames([]core.PdfObjectName{core.PdfObjectName(lineCapStyle)})
-	cc.operands = append(cc.operands, &op)
-	return cc
+	return cc.addStyle("J", lineCapStyle)
 }
 
 // Add_j adds 'j' operand to the content stream: Set the line join style (graphics state).
+// `lineJoinStyle` is "0" for miter joins, "1" for round joins or "2" for bevel joins.
 //
 // See section 8.4.4 "Graphic State Operators" and Table 57 (pp. 135-136 PDF32000_2008).
 func (cc *ContentCreator) Add_j(lineJoinStyle string) *ContentCreator {
-	op := ContentStreamOperation{}
-	op.Operand = "j"
-	op.Params = makeParamsFromNames([]core.PdfObjectName{core.PdfObjectName(lineJoinStyle)})
-	cc.operands = append(cc.operands, &op)
-	return cc
+	return cc.addStyle("j", lineJoinStyle)
 }
 
 // Add_M adds 'M' operand to the content stream: Set the miter limit (graphics state).
@@ -159,30 +212,29 @@ func (cc *ContentCreator) Add_d(dashArray []int64, dashPhase int64) *ContentCrea
 	op.Params = []core.PdfObject{}
 	op.Params = append(op.Params, core.MakeArrayFromIntegers64(dashArray))
 	op.Params = append(op.Params, core.MakeInteger(dashPhase))
-	cc.operands = append(cc.operands, &op)
-	return cc
+	return cc.addChecked(&op)
 }
 
-// Add_ri adds 'ri' operand to the content stream, which sets the color rendering intent.
+// Add_ri adds 'ri' operand to the content stream, which sets the color rendering intent:
+// AbsoluteColorimetric, RelativeColorimetric, Saturation or Perceptual.
 //
 // See section 8.4.4 "Graphic State Operators" and Table 57 (pp. 135-136 PDF32000_2008).
 func (cc *ContentCreator) Add_ri(intent core.PdfObjectName) *ContentCreator {
 	op := ContentStreamOperation{}
 	op.Operand = "ri"
 	op.Params = makeParamsFromNames([]core.PdfObjectName{intent})
-	cc.operands = append(cc.operands, &op)
-	return cc
+	return cc.addChecked(&op)
 }
 
-// Add_i adds 'i' operand to the content stream: Set the flatness tolerance in the graphics state.
+// Add_i adds 'i' operand to the content stream: Set the flatness tolerance in the graphics state,
+// in [0, 100].
 //
 // See section 8.4.4 "Graphic State Operators" and Table 57 (pp. 135-136 PDF32000_2008).
 func (cc *ContentCreator) Add_i(flatness float64) *ContentCreator {
 	op := ContentStreamOperation{}
 	op.Operand = "i"
 	op.Params = makeParamsFromFloats([]float64{flatness})
-	cc.operands = append(cc.operands, &op)
-	return cc
+	return cc.addChecked(&op)
 }
 
 // Add_gs adds 'gs' operand to the content stream: Set the graphics state.
@@ -327,6 +379,17 @@ func (cc *ContentCreator) Add_f() *ContentCreator {
 	return cc
 }
 
+// Add_F appends 'F' operand to the content stream:
+// Equivalent to f, included for compatibility.
+//
+// See section 8.5.3 "Path Painting Operators" and Table 60 (p. 143 PDF32000_2008).
+func (cc *ContentCreator) Add_F() *ContentCreator {
+	op := ContentStreamOperation{}
+	op.Operand = "F"
+	cc.operands = append(cc.operands, &op)
+	return cc
+}
+
 // Add_f_starred appends 'f*' operand to the content stream.
 // f*: Fill the path using the even-odd rule to determine fill region.
 //
@@ -451,8 +514,7 @@ func (cc *ContentCreator) Add_SC(c ...float64) *ContentCreator {
 	op := ContentStreamOperation{}
 	op.Operand = "SC"
 	op.Params = makeParamsFromFloats(c)
-	cc.operands = append(cc.operands, &op)
-	return cc
+	return cc.addChecked(&op)
 }
 
 // Add_SCN appends 'SCN' operand to the content stream:
@@ -463,8 +525,7 @@ func (cc *ContentCreator) Add_SCN(c ...float64) *ContentCreator {
 	op := ContentStreamOperation{}
 	op.Operand = "SCN"
 	op.Params = makeParamsFromFloats(c)
-	cc.operands = append(cc.operands, &op)
-	return cc
+	return cc.addChecked(&op)
 }
 
 // Add_SCN_pattern appends 'SCN' operand to the content stream for pattern `name`:
@@ -480,6 +541,17 @@ func (cc *ContentCreator) Add_SCN_pattern(name core.PdfObjectName, c ...float64)
 	return cc
 }
 
+// Add_sc appends 'sc' operand to the content stream:
+// Same as SC but for nonstroking operations.
+//
+// See section 8.6.8 "Colour Operators" and Table 74 (p. 179-180 PDF32000_2008).
+func (cc *ContentCreator) Add_sc(c ...float64) *ContentCreator {
+	op := ContentStreamOperation{}
+	op.Operand = "sc"
+	op.Params = makeParamsFromFloats(c)
+	return cc.addChecked(&op)
+}
+
 // Add_scn appends 'scn' operand to the content stream:
 // Same as SC but for nonstroking operations.
 //
@@ -488,8 +560,7 @@ func (cc *ContentCreator) Add_scn(c ...float64) *ContentCreator {
 	op := ContentStreamOperation{}
 	op.Operand = "scn"
 	op.Params = makeParamsFromFloats(c)
-	cc.operands = append(cc.operands, &op)
-	return cc
+	return cc.addChecked(&op)
 }
 
 // Add_scn_pattern appends 'scn' operand to the content stream for pattern `name`:
@@ -727,8 +798,7 @@ func (cc *ContentCreator) Add_Tr(render int64) *ContentCreator {
 	op := ContentStreamOperation{}
 	op.Operand = "Tr"
 	op.Params = makeParamsFromInts([]int64{render})
-	cc.operands = append(cc.operands, &op)
-	return cc
+	return cc.addChecked(&op)
 }
 
 // Add_Ts appends 'Ts' operand to the content stream:
@@ -869,6 +939,20 @@ func (cc *ContentCreator) Add_BMC(tag core.PdfObjectName) *ContentCreator {
 	return cc
 }
 
+// Add_BDC appends 'BDC' operand to the content stream:
+// Begins a marked-content sequence with an associated property list,
+// terminated by a balancing EMC operator. `properties` shall be either an
+// inline dictionary or the name of a property list in the Properties
+// resource dictionary.
+//
+// See section 14.6 "Marked Content" and Table 320 (p. 561 PDF32000_2008).
+func (cc *ContentCreator) Add_BDC(tag core.PdfObjectName, properties core.PdfObject) *ContentCreator {
+	op := ContentStreamOperation{}
+	op.Operand = "BDC"
+	op.Params = append(makeParamsFromNames([]core.PdfObjectName{tag}), properties)
+	return cc.addChecked(&op)
+}
+
 // Add_EMC appends 'EMC' operand to the content stream:
 // Ends a marked-content sequence.
 //
@@ -879,3 +963,95 @@ func (cc *ContentCreator) Add_EMC() *ContentCreator {
 	cc.operands = append(cc.operands, &op)
 	return cc
 }
+
+// Add_MP appends 'MP' operand to the content stream:
+// Designates a marked-content point. `tag` shall be a name object indicating
+// the role or significance of the point.
+//
+// See section 14.6 "Marked Content" and Table 320 (p. 561 PDF32000_2008).
+func (cc *ContentCreator) Add_MP(tag core.PdfObjectName) *ContentCreator {
+	op := ContentStreamOperation{}
+	op.Operand = "MP"
+	op.Params = makeParamsFromNames([]core.PdfObjectName{tag})
+	cc.operands = append(cc.operands, &op)
+	return cc
+}
+
+// Add_DP appends 'DP' operand to the content stream:
+// Designates a marked-content point with an associated property list.
+// `properties` shall be either an inline dictionary or the name of a property
+// list in the Properties resource dictionary.
+//
+// See section 14.6 "Marked Content" and Table 320 (p. 561 PDF32000_2008).
+func (cc *ContentCreator) Add_DP(tag core.PdfObjectName, properties core.PdfObject) *ContentCreator {
+	op := ContentStreamOperation{}
+	op.Operand = "DP"
+	op.Params = append(makeParamsFromNames([]core.PdfObjectName{tag}), properties)
+	return cc.addChecked(&op)
+}
+
+/* Compatibility operators (7.8.2 p. 82 PDF32000_2008). */
+
+// Add_BX appends 'BX' operand to the content stream:
+// Begins a compatibility section, in which unrecognized operators are ignored.
+//
+// See section 7.8.2 "Content Streams" and Table 32 (p. 82 PDF32000_2008).
+func (cc *ContentCreator) Add_BX() *ContentCreator {
+	op := ContentStreamOperation{}
+	op.Operand = "BX"
+	cc.operands = append(cc.operands, &op)
+	return cc
+}
+
+// Add_EX appends 'EX' operand to the content stream:
+// Ends a compatibility section begun by a balancing BX operator.
+//
+// See section 7.8.2 "Content Streams" and Table 32 (p. 82 PDF32000_2008).
+func (cc *ContentCreator) Add_EX() *ContentCreator {
+	op := ContentStreamOperation{}
+	op.Operand = "EX"
+	cc.operands = append(cc.operands, &op)
+	return cc
+}
+
+/* Type 3 font operators (9.6.5 p. 258 PDF32000_2008). */
+
+// Add_d0 appends 'd0' operand to the content stream:
+// Sets the width (`wx`, `wy`) of the glyph of a Type 3 font, declaring that the glyph
+// description specifies both its shape and its color. `wy` shall be 0.
+//
+// See section 9.6.5 "Type 3 Fonts" and Table 113 (p. 260 PDF32000_2008).
+func (cc *ContentCreator) Add_d0(wx, wy float64) *ContentCreator {
+	op := ContentStreamOperation{}
+	op.Operand = "d0"
+	op.Params = makeParamsFromFloats([]float64{wx, wy})
+	cc.operands = append(cc.operands, &op)
+	return cc
+}
+
+// Add_d1 appends 'd1' operand to the content stream:
+// Sets the width (`wx`, `wy`) and the bounding box (`llx`, `lly`, `urx`, `ury`) of the glyph
+// of a Type 3 font, declaring that the glyph description specifies only its shape. `wy` shall
+// be 0.
+//
+// See section 9.6.5 "Type 3 Fonts" and Table 113 (p. 260 PDF32000_2008).
+func (cc *ContentCreator) Add_d1(wx, wy, llx, lly, urx, ury float64) *ContentCreator {
+	op := ContentStreamOperation{}
+	op.Operand = "d1"
+	op.Params = makeParamsFromFloats([]float64{wx, wy, llx, lly, urx, ury})
+	cc.operands = append(cc.operands, &op)
+	return cc
+}
+
+/* Inline image operators (8.9.7 p. 214 PDF32000_2008). */
+
+// Add_BI appends 'BI' operand to the content stream, followed by the parameters and the data of
+// inline image `img` and the 'ID' and 'EI' operands. See NewInlineImageFromImage.
+//
+// See section 8.9.7 "Inline Images" and Table 92 (p. 214 PDF32000_2008).
+func (cc *ContentCreator) Add_BI(img *ContentStreamInlineImage) *ContentCreator {
+	op := ContentStreamOperation{}
+	op.Operand = "BI"
+	op.Params = []core.PdfObject{img}
+	return cc.addChecked(&op)
+}
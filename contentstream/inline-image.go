@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
@@ -180,7 +181,7 @@ func (img *ContentStreamInlineImage) GetColorSpace(resources *model.PdfPageResou
 	} else if *name == "I" || *name == "Indexed" {
 		return nil, errors.New("unsupported Index colorspace")
 	} else {
-		if resources.ColorSpace == nil {
+		if resources == nil || resources.ColorSpace == nil {
 			// Can also refer to a name in the PDF page resources...
 			common.Log.Debug("Error, unsupported inline image colorspace: %s", *name)
 			return nil, errors.New("unknown colorspace")
@@ -298,15 +299,387 @@ func (img *ContentStreamInlineImage) ToImage(resources *model.PdfPageResources)
 
 	image.Data = decoded
 
+	if decode, ok := core.GetArray(img.Decode); ok {
+		values, err := decode.ToFloat64Array()
+		if err != nil {
+			return nil, err
+		}
+		image.SetDecodeArray(values)
+	}
+	image.Interpolate, _ = core.GetBoolVal(img.Interpolate)
+
 	return image, nil
 }
 
+// inlineImageNames maps the abbreviated colorspace and filter names of inline images to their
+// full names.
+// See Table 94 "Additional Abbreviations in an Inline Image Object" (p. 224 PDF32000_2008).
+var inlineImageNames = map[core.PdfObjectName]core.PdfObjectName{
+	"G":    "DeviceGray",
+	"RGB":  "DeviceRGB",
+	"CMYK": "DeviceCMYK",
+	"I":    "Indexed",
+	"AHx":  core.StreamEncodingFilterNameASCIIHex,
+	"A85":  core.StreamEncodingFilterNameASCII85,
+	"LZW":  core.StreamEncodingFilterNameLZW,
+	"Fl":   core.StreamEncodingFilterNameFlate,
+	"RL":   core.StreamEncodingFilterNameRunLength,
+	"CCF":  core.StreamEncodingFilterNameCCITTFax,
+	"DCT":  core.StreamEncodingFilterNameDCT,
+}
+
+// inlineImageFilters are the filters which can be used by inline images, i.e. all the standard
+// filters except JBIG2Decode, JPXDecode and Crypt (8.9.7 "Inline Images" p. 223 PDF32000_2008).
+var inlineImageFilters = map[core.PdfObjectName]struct{}{
+	core.StreamEncodingFilterNameASCIIHex:  {},
+	core.StreamEncodingFilterNameASCII85:   {},
+	core.StreamEncodingFilterNameLZW:       {},
+	core.StreamEncodingFilterNameFlate:     {},
+	core.StreamEncodingFilterNameRunLength: {},
+	core.StreamEncodingFilterNameCCITTFax:  {},
+	core.StreamEncodingFilterNameDCT:       {},
+}
+
+// expandInlineImageNames returns `obj` with the abbreviated names of inline images expanded, for
+// names and the names of arrays, e.g. the filters of the F entry or the base of an indexed
+// colorspace.
+func expandInlineImageNames(obj core.PdfObject) core.PdfObject {
+	switch t := obj.(type) {
+	case *core.PdfObjectName:
+		if name, ok := inlineImageNames[*t]; ok {
+			return core.MakeName(string(name))
+		}
+	case *core.PdfObjectArray:
+		arr := core.MakeArray()
+		for _, elem := range t.Elements() {
+			arr.Append(expandInlineImageNames(elem))
+		}
+		return arr
+	}
+	return obj
+}
+
+// numComponents returns the number of color components of the image for the device and indexed
+// colorspaces, and image masks. Returns 0 for other colorspaces, which are specified by name in
+// the page resources.
+func (img *ContentStreamInlineImage) numComponents() int {
+	if isMask, err := img.IsMask(); err != nil || isMask {
+		return 1
+	}
+	cs := expandInlineImageNames(img.ColorSpace)
+	if arr, ok := cs.(*core.PdfObjectArray); ok && arr.Len() > 0 {
+		cs = arr.Get(0)
+	}
+	name, _ := core.GetNameVal(cs)
+	switch name {
+	case "DeviceGray", "Indexed":
+		return 1
+	case "DeviceRGB":
+		return 3
+	case "DeviceCMYK":
+		return 4
+	}
+	return 0
+}
+
+// getParamsDict returns the image parameters used by the encoders of the image data.
+func (img *ContentStreamInlineImage) getParamsDict() *core.PdfObjectDictionary {
+	params := core.MakeDict()
+	params.SetIfNotNil("Width", img.Width)
+	params.SetIfNotNil("Height", img.Height)
+	if isMask, err := img.IsMask(); err == nil && isMask {
+		params.Set("BitsPerComponent", core.MakeInteger(1))
+	} else {
+		params.SetIfNotNil("BitsPerComponent", img.BitsPerComponent)
+	}
+	if n := img.numComponents(); n > 0 {
+		params.Set("ColorComponents", core.MakeInteger(int64(n)))
+	}
+	return params
+}
+
+// dataLength returns the length of the image data computed from the image parameters, when the
+// data is not encoded and the number of color components is known. Returns -1 otherwise.
+func (img *ContentStreamInlineImage) dataLength() int {
+	if img.Filter != nil {
+		if arr, ok := img.Filter.(*core.PdfObjectArray); !ok || arr.Len() > 0 {
+			return -1
+		}
+	}
+	params := img.getParamsDict()
+	width, ok1 := core.GetIntVal(params.Get("Width"))
+	height, ok2 := core.GetIntVal(params.Get("Height"))
+	bpc, ok3 := core.GetIntVal(params.Get("BitsPerComponent"))
+	colors, ok4 := core.GetIntVal(params.Get("ColorComponents"))
+	if !ok1 || !ok2 || !ok3 || !ok4 || width <= 0 || height <= 0 || bpc <= 0 {
+		return -1
+	}
+	rowLength := (int64(width)*int64(colors)*int64(bpc) + 7) / 8
+	if length := int64(height) * rowLength; length <= maxInlineImageLookahead {
+		return int(length)
+	}
+	return -1
+}
+
+// SetFilter sets the compression filter of the image data. Decodes the data with the current
+// filters and encodes it with `encoder`, replacing the filter and the decode parameters of the
+// image. The filters available for inline images are the standard filters except JBIG2Decode,
+// JPXDecode and Crypt.
+func (img *ContentStreamInlineImage) SetFilter(encoder core.StreamEncoder) error {
+	if encoder == nil {
+		encoder = core.NewRawEncoder()
+	}
+	for _, name := range strings.Fields(encoder.GetFilterName()) {
+		_, ok := inlineImageFilters[core.PdfObjectName(name)]
+		if !ok && name != core.StreamEncodingFilterNameRaw {
+			return fmt.Errorf("filter %s not available for inline images", name)
+		}
+	}
+
+	decoder, err := newEncoderFromInlineImage(img)
+	if err != nil {
+		return err
+	}
+	decoded, err := decoder.DecodeBytes(img.stream)
+	if err != nil {
+		return err
+	}
+	encoder.UpdateParams(img.getParamsDict())
+	encoded, err := encoder.EncodeBytes(decoded)
+	if err != nil {
+		return err
+	}
+
+	dict := encoder.MakeStreamDict()
+	img.Filter = dict.Get("Filter")
+	img.DecodeParms = dict.Get("DecodeParms")
+	img.stream = encoded
+	return nil
+}
+
+// ToXObject converts the inline image to an image XObject with the same encoded data and
+// parameters, the abbreviated names being expanded. Page `resources` are needed to look up the
+// colorspace information.
+func (img *ContentStreamInlineImage) ToXObject(resources *model.PdfPageResources) (*model.XObjectImage, error) {
+	if img.Width == nil || img.Height == nil {
+		return nil, errors.New("image dimensions missing")
+	}
+	isMask, err := img.IsMask()
+	if err != nil {
+		return nil, err
+	}
+
+	dict := core.MakeDict()
+	dict.Set("Type", core.MakeName("XObject"))
+	dict.Set("Subtype", core.MakeName("Image"))
+	dict.Set("Width", img.Width)
+	dict.Set("Height", img.Height)
+	if !isMask {
+		cs, err := img.GetColorSpace(resources)
+		if err != nil {
+			return nil, err
+		}
+		dict.Set("ColorSpace", cs.ToPdfObject())
+		dict.SetIfNotNil("BitsPerComponent", img.BitsPerComponent)
+	} else {
+		dict.Set("BitsPerComponent", core.MakeInteger(1))
+	}
+	if img.Filter != nil {
+		dict.Set("Filter", expandInlineImageNames(img.Filter))
+	}
+	dict.SetIfNotNil("DecodeParms", img.DecodeParms)
+	dict.SetIfNotNil("Decode", img.Decode)
+	dict.SetIfNotNil("ImageMask", img.ImageMask)
+	dict.SetIfNotNil("Intent", img.Intent)
+	dict.SetIfNotNil("Interpolate", img.Interpolate)
+	dict.Set("Length", core.MakeInteger(int64(len(img.stream))))
+
+	ximg, err := model.NewXObjectImageFromStream(&core.PdfObjectStream{
+		PdfObjectDictionary: dict,
+		Stream:              img.stream,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if isMask {
+		// Image masks have no colorspace.
+		ximg.ColorSpace = nil
+	}
+	return ximg, nil
+}
+
+// NewInlineImageFromXObject makes a new content stream inline image from the image XObject `ximg`,
+// with the same encoded data and parameters. Returns an error if the image cannot be represented
+// as an inline image, i.e. if it has a mask or a soft mask, uses a filter not available for inline
+// images or a colorspace other than the device colorspaces and indexed colorspaces based on them.
+func NewInlineImageFromXObject(ximg *model.XObjectImage) (*ContentStreamInlineImage, error) {
+	if ximg.Width == nil || ximg.Height == nil {
+		return nil, errors.New("image dimensions missing")
+	}
+	if ximg.Mask != nil || ximg.SMask != nil {
+		return nil, errors.New("masked images cannot be inline images")
+	}
+
+	img := &ContentStreamInlineImage{
+		Width:       core.MakeInteger(*ximg.Width),
+		Height:      core.MakeInteger(*ximg.Height),
+		Decode:      core.TraceToDirectObject(ximg.Decode),
+		ImageMask:   core.TraceToDirectObject(ximg.ImageMask),
+		Intent:      core.TraceToDirectObject(ximg.Intent),
+		Interpolate: core.TraceToDirectObject(ximg.Interpolate),
+		stream:      ximg.Stream,
+	}
+	isMask, err := img.IsMask()
+	if err != nil {
+		return nil, err
+	}
+	if !isMask {
+		if ximg.BitsPerComponent != nil {
+			img.BitsPerComponent = core.MakeInteger(*ximg.BitsPerComponent)
+		}
+		cs, err := inlineColorspace(ximg.ColorSpace)
+		if err != nil {
+			return nil, err
+		}
+		img.ColorSpace = cs
+	}
+
+	if ximg.Filter != nil {
+		dict := ximg.Filter.MakeStreamDict()
+		filter := core.TraceToDirectObject(dict.Get("Filter"))
+		names := []core.PdfObject{filter}
+		if arr, ok := filter.(*core.PdfObjectArray); ok {
+			names = arr.Elements()
+		}
+		for _, obj := range names {
+			if name, ok := core.GetName(obj); ok {
+				if _, ok := inlineImageFilters[*name]; !ok {
+					return nil, fmt.Errorf("filter %s not available for inline images", *name)
+				}
+			}
+		}
+		img.Filter = filter
+		img.DecodeParms = core.TraceToDirectObject(dict.Get("DecodeParms"))
+	}
+	return img, nil
+}
+
+// inlineColorspace returns the colorspace entry of inline images representing `cs`, with the
+// abbreviated names.
+func inlineColorspace(cs model.PdfColorspace) (core.PdfObject, error) {
+	switch t := cs.(type) {
+	case nil:
+		return nil, nil
+	case *model.PdfColorspaceDeviceGray:
+		return core.MakeName("G"), nil
+	case *model.PdfColorspaceDeviceRGB:
+		return core.MakeName("RGB"), nil
+	case *model.PdfColorspaceDeviceCMYK:
+		return core.MakeName("CMYK"), nil
+	case *model.PdfColorspaceSpecialIndexed:
+		base, err := inlineColorspace(t.Base)
+		if err != nil || base == nil {
+			return nil, errors.New("indexed colorspace base not available for inline images")
+		}
+		lookup := core.TraceToDirectObject(t.Lookup)
+		if stream, ok := lookup.(*core.PdfObjectStream); ok {
+			data, err := core.DecodeStream(stream)
+			if err != nil {
+				return nil, err
+			}
+			lookup = core.MakeHexString(string(data))
+		}
+		return core.MakeArray(core.MakeName("I"), base, core.MakeInteger(int64(t.HiVal)), lookup), nil
+	}
+	return nil, fmt.Errorf("colorspace %s not available for inline images", cs)
+}
+
+// InlineImagesToXObjects replaces the inline images of `ops` by image XObjects, added to
+// `resources` and painted with the Do operator. Returns the number of replaced inline images.
+func InlineImagesToXObjects(ops *ContentStreamOperations, resources *model.PdfPageResources) (int, error) {
+	count := 0
+	for _, op := range *ops {
+		if op == nil || op.Operand != "BI" || len(op.Params) != 1 {
+			continue
+		}
+		img, ok := op.Params[0].(*ContentStreamInlineImage)
+		if !ok {
+			continue
+		}
+		ximg, err := img.ToXObject(resources)
+		if err != nil {
+			return count, err
+		}
+		name := resources.GenerateXObjectName()
+		if err := resources.SetXObjectImageByName(name, ximg); err != nil {
+			return count, err
+		}
+		op.Operand = "Do"
+		op.Params = []core.PdfObject{core.MakeName(string(name))}
+		count++
+	}
+	return count, nil
+}
+
+// XObjectImagesToInline replaces the image XObjects of `resources` painted by the Do operators of
+// `ops` by inline images, for the images with encoded data of at most `maxSize` bytes which can
+// be represented as inline images (see NewInlineImageFromXObject). The XObjects are kept in the
+// resources. Returns the number of replaced Do operators.
+// NOTE: The PDF specification recommends inline images of at most 4 KB.
+func XObjectImagesToInline(ops *ContentStreamOperations, resources *model.PdfPageResources, maxSize int) (int, error) {
+	images := map[core.PdfObjectName]*ContentStreamInlineImage{}
+	count := 0
+	for _, op := range *ops {
+		if op == nil || op.Operand != "Do" || len(op.Params) != 1 {
+			continue
+		}
+		name, ok := core.GetName(op.Params[0])
+		if !ok {
+			continue
+		}
+		img, ok := images[*name]
+		if !ok {
+			stream, xtype := resources.GetXObjectByName(*name)
+			if xtype == model.XObjectTypeImage {
+				ximg, err := model.NewXObjectImageFromStream(stream)
+				if err != nil {
+					return count, err
+				}
+				if len(ximg.Stream) <= maxSize {
+					img, err = NewInlineImageFromXObject(ximg)
+					if err != nil {
+						common.Log.Debug("Image %s not converted to inline image: %v", *name, err)
+					}
+				}
+			}
+			images[*name] = img
+		}
+		if img == nil {
+			continue
+		}
+		op.Operand = "BI"
+		op.Params = []core.PdfObject{img}
+		count++
+	}
+	return count, nil
+}
+
+// maxInlineImageLookahead is the maximum number of bytes of inline image data which are read ahead
+// in order to check that the EI operator follows the data with the length computed from the image
+// parameters.
+const maxInlineImageLookahead = 1 << 16
+
+// inlineImageEndPeek is the number of bytes following the EI operator checked to determine whether
+// the operator ends the image data.
+const inlineImageEndPeek = 20
+
 // ParseInlineImage parses an inline image from a content stream, both reading its properties and binary data.
 // When called, "BI" has already been read from the stream.  This function
 // finishes reading through "EI" and then returns the ContentStreamInlineImage.
 func (csp *ContentStreamParser) ParseInlineImage() (*ContentStreamInlineImage, error) {
 	// Reading parameters.
 	im := ContentStreamInlineImage{}
+	length := -1
 
 	for {
 		csp.skipSpaces()
@@ -340,6 +713,7 @@ func (csp *ContentStreamParser) ParseInlineImage() (*ContentStreamInlineImage, e
 			// entries normally found in a stream or image dictionary are unnecessary.
 			// For convenience, the abbreviations shown in the table may be used in place of the fully spelled-out keys.
 			// Table 94 shows additional abbreviations that can be used for the names of colour spaces and filters.
+			// PDF 2.0 adds the Length (L) entry, giving the length of the image data.
 
 			switch *param {
 			case "BPC", "BitsPerComponent":
@@ -362,10 +736,12 @@ func (csp *ContentStreamParser) ParseInlineImage() (*ContentStreamInlineImage, e
 				im.Interpolate = valueObj
 			case "W", "Width":
 				im.Width = valueObj
-			case "Length", "Subtype", "Type":
-				common.Log.Debug("Ignoring inline parameter %s", *param)
+			case "L", "Length":
+				if val, ok := core.GetIntVal(valueObj); ok && val >= 0 && val <= maxInlineImageLookahead {
+					length = val
+				}
 			default:
-				return nil, fmt.Errorf("unknown inline image parameter %s", *param)
+				common.Log.Debug("Ignoring inline parameter %s", *param)
 			}
 		}
 
@@ -385,114 +761,136 @@ func (csp *ContentStreamParser) ParseInlineImage() (*ContentStreamInlineImage, e
 				common.Log.Trace("ID start")
 
 				// Skip the space if its there.
-				b, err := csp.reader.Peek(1)
+				if csp.pos == len(csp.data) {
+					return nil, io.EOF
+				}
+				if core.IsWhiteSpace(csp.data[csp.pos]) {
+					csp.pos++
+				}
+
+				if length < 0 {
+					length = im.dataLength()
+				}
+				im.stream, err = csp.readInlineImageData(length)
 				if err != nil {
 					return nil, err
 				}
-				if core.IsWhiteSpace(b[0]) {
-					csp.reader.Discard(1)
+				if len(im.stream) > 100 {
+					common.Log.Trace("Image stream (%d): % x ...", len(im.stream), im.stream[:100])
+				} else {
+					common.Log.Trace("Image stream (%d): % x", len(im.stream), im.stream)
 				}
+				return &im, nil
+			}
+		}
+	}
+}
 
-				// Unfortunately there is no good way to know how many bytes to read since it
-				// depends on the Filter and encoding etc.
-				// Therefore we will simply read until we find "<ws>EI<ws>" where <ws> is whitespace
-				// although of course that could be a part of the data (even if unlikely).
-				im.stream = []byte{}
-				state := 0
-				var skipBytes []byte
-				for {
-					c, err := csp.reader.ReadByte()
-					if err != nil {
-						common.Log.Debug("Unable to find end of image EI in inline image data")
-						return nil, err
-					}
+// readInlineImageData reads the data of an inline image through the EI operator and returns it.
+// When the `length` of the data is known (not negative) and the data is followed by the EI
+// operator, the data is read at once. Otherwise the data is scanned for the EI operator, followed
+// by whitespace or the end of the content stream, and by valid objects and operators.
+func (csp *ContentStreamParser) readInlineImageData(length int) ([]byte, error) {
+	if length >= 0 {
+		b, err := csp.peek(length + inlineImageEndPeek)
+		if len(b) >= length {
+			if n, ok := inlineImageEnd(b[length:], err == io.EOF); ok {
+				data := make([]byte, length)
+				copy(data, b)
+				csp.pos += length + n
+				return data, nil
+			}
+		}
+		common.Log.Debug("Inline image data of length %d not followed by EI - scanning for EI", length)
+	}
 
-					if state == 0 {
-						if core.IsWhiteSpace(c) {
-							skipBytes = []byte{}
-							skipBytes = append(skipBytes, c)
-							state = 1
-						} else if c == 'E' {
-							// Allow cases where EI is not preceded by whitespace.
-							// The extra parsing after EI<ws> should be sufficient
-							// in order to decide if the image stream ended.
-							skipBytes = append(skipBytes, c)
-							state = 2
-						} else {
-							im.stream = append(im.stream, c)
-						}
-					} else if state == 1 {
-						skipBytes = append(skipBytes, c)
-						if c == 'E' {
-							state = 2
-						} else {
-							im.stream = append(im.stream, skipBytes...)
-							skipBytes = []byte{} // Clear.
-							// Need an extra check to decide if we fall back to state 0 or 1.
-							if core.IsWhiteSpace(c) {
-								state = 1
-							} else {
-								state = 0
-							}
-						}
-					} else if state == 2 {
-						skipBytes = append(skipBytes, c)
-						if c == 'I' {
-							state = 3
-						} else {
-							im.stream = append(im.stream, skipBytes...)
-							skipBytes = []byte{} // Clear.
-							state = 0
-						}
-					} else if state == 3 {
-						skipBytes = append(skipBytes, c)
-						if core.IsWhiteSpace(c) {
-							// Whitspace after EI.
-							// To ensure that is not a part of encoded image data: Peek up to 20 bytes ahead
-							// and check that the following data is valid objects/operands.
-							peekbytes, err := csp.reader.Peek(20)
-							if err != nil && err != io.EOF {
-								return nil, err
-							}
-							dummyParser := NewContentStreamParser(string(peekbytes))
-
-							// Assume is done, check that the following 3 objects/operands are valid.
-							isDone := true
-							for i := 0; i < 3; i++ {
-								op, isOp, err := dummyParser.parseObject()
-								if err != nil {
-									if err == io.EOF {
-										break
-									}
-									continue
-								}
-								if isOp && !isValidOperand(op.String()) {
-									isDone = false
-									break
-								}
-							}
-
-							if isDone {
-								// Valid object or operand found, i.e. the EI marks the end of the data.
-								// -> image data finished.
-								if len(im.stream) > 100 {
-									common.Log.Trace("Image stream (%d): % x ...", len(im.stream), im.stream[:100])
-								} else {
-									common.Log.Trace("Image stream (%d): % x", len(im.stream), im.stream)
-								}
-								// Exit point.
-								return &im, nil
-							}
-						}
-
-						// Seems like "<ws>EI" was part of the data.
-						im.stream = append(im.stream, skipBytes...)
-						skipBytes = []byte{} // Clear.
-						state = 0
-					}
-				}
-				// Never reached (exit point is at end of EI).
+	// Unfortunately there is no good way to know how many bytes to read since it
+	// depends on the Filter and encoding etc.
+	// Therefore we will simply read until we find "EI<ws>" where <ws> is whitespace
+	// although of course that could be a part of the data (even if unlikely).
+	start := csp.pos
+	for {
+		if csp.pos == len(csp.data) {
+			common.Log.Debug("Unable to find end of image EI in inline image data")
+			return nil, io.EOF
+		}
+		csp.pos++
+
+		// Allow cases where EI is not preceded by whitespace.
+		// The extra parsing after EI<ws> should be sufficient
+		// in order to decide if the image stream ended.
+		data := csp.data[start:csp.pos]
+		n := len(data)
+		if n < 2 || data[n-2] != 'E' || data[n-1] != 'I' {
+			continue
+		}
+		b, err := csp.peek(inlineImageEndPeek + 1)
+		if !isInlineImageEnd(b, err == io.EOF) {
+			// Seems like "EI" was part of the data.
+			continue
+		}
+
+		data = data[:n-2]
+		if n > 2 && core.IsWhiteSpace(data[n-3]) {
+			// Whitespace preceding EI.
+			data = data[:n-3]
+		}
+		stream := make([]byte, len(data))
+		copy(stream, data)
+		return stream, nil
+	}
+}
+
+// inlineImageEnd checks whether `b`, the bytes following the data of an inline image, start with
+// the EI operator, optionally preceded by whitespace, and followed by whitespace or the end of the
+// content stream (`eof` is true if `b` reaches the end). Returns the number of bytes through the
+// EI operator.
+func inlineImageEnd(b []byte, eof bool) (int, bool) {
+	n := 0
+	for n < len(b) && core.IsWhiteSpace(b[n]) {
+		n++
+	}
+	if !bytes.HasPrefix(b[n:], []byte("EI")) {
+		return 0, false
+	}
+	n += 2
+	if n == len(b) {
+		return n, eof
+	}
+	return n, core.IsWhiteSpace(b[n])
+}
+
+// isInlineImageEnd returns true if the EI operator, followed by the bytes `b`, ends the data of an
+// inline image, i.e. if it is followed by whitespace or the end of the content stream (`eof` is
+// true if `b` reaches the end), and the next objects and operators are valid.
+func isInlineImageEnd(b []byte, eof bool) bool {
+	if len(b) == 0 {
+		return eof
+	}
+	if !core.IsWhiteSpace(b[0]) {
+		return false
+	}
+
+	// Whitespace after EI.
+	// To ensure that is not a part of encoded image data: check that the following data is valid
+	// objects/operands.
+	data := make([]byte, len(b))
+	copy(data, b[1:])
+	data[len(data)-1] = '\n'
+	dummyParser := newContentStreamParser(data)
+
+	// Assume is done, check that the following 3 objects/operands are valid.
+	for i := 0; i < 3; i++ {
+		op, isOp, err := dummyParser.parseObject()
+		if err != nil {
+			if err == io.EOF {
+				break
 			}
+			continue
+		}
+		if isOp && !isValidOperand(op.String()) {
+			return false
 		}
 	}
+	return true
 }
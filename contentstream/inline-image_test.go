@@ -0,0 +1,221 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package contentstream
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// Data of 4x3 RGB test images. The EI sequences of eiImageData cannot be distinguished from the
+// end of the image data by scanning, unless encoded.
+const (
+	testImageData = "0123456789abcdefghijklmnopqrstuvwxyz"
+	eiImageData   = "0123456789abcdefgh\x00 EI Q\nEI q 1 0 EI"
+)
+
+// testInlineImage returns a 4x3 RGB image with `data`.
+func testInlineImage(data string) model.Image {
+	return model.Image{
+		Width:            4,
+		Height:           3,
+		BitsPerComponent: 8,
+		ColorComponents:  3,
+		Data:             []byte(data),
+	}
+}
+
+// parseInlineImage parses `content` and returns its only inline image.
+func parseInlineImage(t *testing.T, content string) *ContentStreamInlineImage {
+	ops, err := NewContentStreamParser(content).Parse()
+	require.NoError(t, err)
+	var images []*ContentStreamInlineImage
+	for _, op := range *ops {
+		if op.Operand == "BI" {
+			images = append(images, op.Params[0].(*ContentStreamInlineImage))
+		}
+	}
+	require.Len(t, images, 1)
+	return images[0]
+}
+
+func TestInlineImageRoundTrip(t *testing.T) {
+	testcases := []struct {
+		data    string
+		encoder core.StreamEncoder
+	}{
+		{testImageData, core.NewRawEncoder()},
+		{eiImageData, core.NewRawEncoder()},
+		{eiImageData, core.NewASCIIHexEncoder()},
+		{testImageData, core.NewFlateEncoder()},
+	}
+	for _, tc := range testcases {
+		img, encoder := testInlineImage(tc.data), tc.encoder
+		inlineImage, err := NewInlineImageFromImage(img, encoder)
+		require.NoError(t, err)
+
+		ops := ContentStreamOperations{
+			{Operand: "q"},
+			{Operand: "cm", Params: makeParamsFromInts([]int64{4, 0, 0, 3, 0, 0})},
+			{Operand: "BI", Params: []core.PdfObject{inlineImage}},
+			{Operand: "Q"},
+		}
+		content := ops.String()
+
+		parsed, err := NewContentStreamParser(content).Parse()
+		require.NoError(t, err, encoder.GetFilterName())
+		require.Len(t, *parsed, 4, encoder.GetFilterName())
+		require.Equal(t, "Q", (*parsed)[3].Operand)
+		require.Equal(t, content, parsed.String())
+
+		decoded, err := (*parsed)[2].Params[0].(*ContentStreamInlineImage).ToImage(nil)
+		require.NoError(t, err)
+		require.Equal(t, img.Data, decoded.Data, encoder.GetFilterName())
+	}
+}
+
+func TestInlineImageAbbreviations(t *testing.T) {
+	encode := func(data string, encoders ...core.StreamEncoder) []byte {
+		encoded := []byte(data)
+		for _, encoder := range encoders {
+			var err error
+			encoded, err = encoder.EncodeBytes(encoded)
+			require.NoError(t, err)
+		}
+		return encoded
+	}
+	flateData := encode(eiImageData, core.NewFlateEncoder())
+
+	testcases := []struct {
+		params string
+		data   string
+		stream []byte
+	}{
+		{"/W 4 /H 3 /CS /RGB /BPC 8", eiImageData, []byte(eiImageData)},
+		{"/Width 4 /Height 3 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter []", eiImageData, []byte(eiImageData)},
+		{"/W 4 /H 3 /CS /RGB /BPC 8 /F /AHx", eiImageData, encode(eiImageData, core.NewASCIIHexEncoder())},
+		{"/W 4 /H 3 /CS /RGB /BPC 8 /F [/AHx]", eiImageData, encode(eiImageData, core.NewASCIIHexEncoder())},
+		{"/W 4 /H 3 /CS /RGB /BPC 8 /F /Fl /DP null", testImageData, encode(testImageData, core.NewFlateEncoder())},
+		{"/W 4 /H 3 /CS /RGB /BPC 8 /F [/AHx /Fl]", eiImageData,
+			encode(eiImageData, core.NewFlateEncoder(), core.NewASCIIHexEncoder())},
+		// PDF 2.0 Length entry.
+		{fmt.Sprintf("/W 4 /H 3 /CS /RGB /BPC 8 /F /Fl /L %d", len(flateData)), eiImageData, flateData},
+		// Unknown entries are ignored.
+		{"/W 4 /H 3 /CS /RGB /BPC 8 /Unknown 1", testImageData, []byte(testImageData)},
+	}
+	for _, tc := range testcases {
+		content := fmt.Sprintf("q BI %s ID %s\nEI Q", tc.params, tc.stream)
+		img := parseInlineImage(t, content)
+		require.Equal(t, tc.stream, img.stream, tc.params)
+
+		decoded, err := img.ToImage(nil)
+		require.NoError(t, err, tc.params)
+		require.Equal(t, []byte(tc.data), decoded.Data, tc.params)
+		require.EqualValues(t, 3, decoded.ColorComponents)
+	}
+}
+
+func TestInlineImageEnd(t *testing.T) {
+	// Image data ending with EI at the end of the content stream.
+	img := parseInlineImage(t, "BI /W 1 /H 1 /F /AHx ID 00>EI")
+	require.Equal(t, []byte("00>"), img.stream)
+
+	// Image data not having the length computed from the parameters.
+	img = parseInlineImage(t, "BI /W 2 /H 1 /CS /G /BPC 8 ID abc EI Q")
+	require.Equal(t, []byte("abc"), img.stream)
+
+	// Image mask data.
+	img = parseInlineImage(t, "BI /W 12 /H 2 /IM true ID EIEI\nEI Q")
+	require.Equal(t, []byte("EIEI"), img.stream)
+}
+
+func TestInlineImageSetFilter(t *testing.T) {
+	img := testInlineImage(testImageData)
+	inlineImage, err := NewInlineImageFromImage(img, core.NewFlateEncoder())
+	require.NoError(t, err)
+
+	for _, encoder := range []core.StreamEncoder{core.NewASCIIHexEncoder(), nil, core.NewRunLengthEncoder()} {
+		require.NoError(t, inlineImage.SetFilter(encoder))
+		parsed := parseInlineImage(t, "BI\n"+inlineImage.WriteString())
+		decoded, err := parsed.ToImage(nil)
+		require.NoError(t, err)
+		require.Equal(t, img.Data, decoded.Data)
+	}
+	require.Nil(t, inlineImage.DecodeParms)
+	require.Equal(t, core.MakeName(core.StreamEncodingFilterNameRunLength), inlineImage.Filter)
+
+	require.Error(t, inlineImage.SetFilter(core.NewJBIG2Encoder()))
+}
+
+func TestInlineImageXObjectConversion(t *testing.T) {
+	img := testInlineImage(testImageData)
+	mask := model.Image{Width: 8, Height: 2, BitsPerComponent: 1, ColorComponents: 1, Data: []byte{0x0f, 0xf0}}
+	var content string
+	for _, tc := range []struct {
+		img     model.Image
+		encoder core.StreamEncoder
+		isMask  bool
+	}{
+		{img, core.NewFlateEncoder(), false},
+		{img, core.NewASCIIHexEncoder(), false},
+		{mask, nil, true},
+	} {
+		inlineImage, err := NewInlineImageFromImage(tc.img, tc.encoder)
+		require.NoError(t, err)
+		if tc.isMask {
+			inlineImage.ColorSpace = nil
+			inlineImage.BitsPerComponent = nil
+			inlineImage.ImageMask = core.MakeBool(true)
+		}
+		content += "q BI\n" + inlineImage.WriteString() + "Q\n"
+	}
+	ops, err := NewContentStreamParser(content).Parse()
+	require.NoError(t, err)
+	original := ops.String()
+
+	resources := model.NewPdfPageResources()
+	count, err := InlineImagesToXObjects(ops, resources)
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+	var names []core.PdfObjectName
+	for _, op := range *ops {
+		require.NotEqual(t, "BI", op.Operand)
+		if op.Operand == "Do" {
+			names = append(names, *op.Params[0].(*core.PdfObjectName))
+		}
+	}
+	require.Len(t, names, 3)
+
+	for i, name := range names {
+		ximg, err := resources.GetXObjectImageByName(name)
+		require.NoError(t, err)
+		decoded, err := ximg.ToImage()
+		require.NoError(t, err)
+		if i < 2 {
+			require.Equal(t, img.Data, decoded.Data)
+			require.Equal(t, model.NewPdfColorspaceDeviceRGB(), ximg.ColorSpace)
+		} else {
+			require.Equal(t, mask.Data, decoded.Data)
+			stream, _ := resources.GetXObjectByName(name)
+			require.Nil(t, stream.Get("ColorSpace"))
+		}
+	}
+
+	// Images larger than the maximum size are not converted back.
+	count, err = XObjectImagesToInline(ops, resources, 8)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	count, err = XObjectImagesToInline(ops, resources, 4096)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	require.Equal(t, original, ops.String())
+}
@@ -0,0 +1,157 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package contentstream
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// ErrUnbalancedOperators is returned by Normalize in strict mode when the q/Q or BT/ET operators of
+// a content stream are not balanced.
+var ErrUnbalancedOperators = errors.New("unbalanced operators")
+
+// NormalizeOptions define how Normalize writes content streams.
+type NormalizeOptions struct {
+	// Precision is the maximum number of decimal places the real numbers are written with (see
+	// core.FormatFloat). The numbers are written as they are if Precision is negative.
+	Precision int
+
+	// Indent is written before each operation once for each enclosing q ... Q pair and BT ... ET
+	// block. The operations are not indented if Indent is empty.
+	Indent string
+
+	// Strict makes Normalize fail with ErrUnbalancedOperators when a Q operator does not restore a
+	// graphics state saved by a q operator, when the text objects are nested or not terminated or
+	// when the graphics states saved are not restored at the end of the content stream.
+	Strict bool
+}
+
+// DefaultNormalizeOptions returns the options writing numbers as they are, without indentation
+// and accepting unbalanced operators.
+func DefaultNormalizeOptions() *NormalizeOptions {
+	return &NormalizeOptions{Precision: -1}
+}
+
+// Normalize parses the content stream `content` and writes it back deterministically, one operation
+// per line, according to `opts`, which are the default options if nil. This makes content streams
+// readable and comparable, e.g. in golden tests.
+// The operations parsed from the normalized content stream are the same as those parsed from
+// `content` unless their real numbers are rounded by the precision of `opts`.
+func Normalize(content string, opts *NormalizeOptions) ([]byte, error) {
+	ops, err := NewContentStreamParser(content).Parse()
+	if err != nil {
+		return nil, err
+	}
+	return ops.Normalize(opts)
+}
+
+// Normalize writes the content stream operations `ops` as Normalize does.
+func (ops *ContentStreamOperations) Normalize(opts *NormalizeOptions) ([]byte, error) {
+	if opts == nil {
+		opts = DefaultNormalizeOptions()
+	}
+
+	var buf bytes.Buffer
+	qDepth := 0
+	inText := false
+	for i, op := range *ops {
+		if op == nil {
+			continue
+		}
+
+		switch op.Operand {
+		case "Q":
+			if qDepth == 0 {
+				if opts.Strict {
+					return nil, fmt.Errorf("operation %d: Q without q: %w", i, ErrUnbalancedOperators)
+				}
+			} else {
+				qDepth--
+			}
+		case "ET":
+			if !inText && opts.Strict {
+				return nil, fmt.Errorf("operation %d: ET without BT: %w", i, ErrUnbalancedOperators)
+			}
+			inText = false
+		case "BT":
+			if inText && opts.Strict {
+				return nil, fmt.Errorf("operation %d: nested BT: %w", i, ErrUnbalancedOperators)
+			}
+		}
+
+		depth := qDepth
+		if inText {
+			depth++
+		}
+		buf.WriteString(strings.Repeat(opts.Indent, depth))
+		if op.Operand == "BI" && len(op.Params) == 1 {
+			// The inline image data is written as is, whatever the indentation.
+			buf.WriteString("BI\n")
+			buf.WriteString(op.Params[0].WriteString())
+		} else {
+			for _, param := range op.Params {
+				buf.WriteString(normalizeOperand(param, opts.Precision))
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(op.Operand)
+			buf.WriteByte('\n')
+		}
+
+		switch op.Operand {
+		case "q":
+			qDepth++
+		case "BT":
+			inText = true
+		}
+	}
+
+	if opts.Strict {
+		if inText {
+			return nil, fmt.Errorf("BT without ET: %w", ErrUnbalancedOperators)
+		}
+		if qDepth > 0 {
+			return nil, fmt.Errorf("%d q without Q: %w", qDepth, ErrUnbalancedOperators)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// normalizeOperand returns the representation of the operand `param`, its real numbers being
+// written with at most `precision` decimal places. Unlike formatOperand, real numbers are always
+// written with a decimal point so that they are not parsed back as integers.
+func normalizeOperand(param core.PdfObject, precision int) string {
+	switch t := param.(type) {
+	case *core.PdfObjectFloat:
+		s := strconv.FormatFloat(core.RoundFloat(float64(*t), precision), 'f', -1, 64)
+		if !strings.ContainsRune(s, '.') {
+			s += ".0"
+		}
+		return s
+	case *core.PdfObjectArray:
+		elems := make([]string, t.Len())
+		for i, elem := range t.Elements() {
+			elems[i] = normalizeOperand(elem, precision)
+		}
+		return "[" + strings.Join(elems, " ") + "]"
+	case *core.PdfObjectDictionary:
+		var sb strings.Builder
+		sb.WriteString("<<")
+		for _, key := range t.Keys() {
+			sb.WriteString(key.WriteString())
+			sb.WriteByte(' ')
+			sb.WriteString(normalizeOperand(t.Get(key), precision))
+		}
+		sb.WriteString(">>")
+		return sb.String()
+	}
+	return param.WriteString()
+}
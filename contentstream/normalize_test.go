@@ -0,0 +1,120 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package contentstream
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	content := "q 1 0 0 1 72.5 700 cm BT/F1 12 Tf 1.0 0 Td[(A)-2.25(B)]TJ ET q 0.333333 g Q " +
+		"/Span<</MCID 2/BBox[0 0.125 1 1]>>BDC 1 2 m 3 4 l S EMC Q"
+	data, err := Normalize(content, nil)
+	require.NoError(t, err)
+	require.Equal(t, `q
+1 0 0 1 72.5 700 cm
+BT
+/F1 12 Tf
+1.0 0 Td
+[(A) -2.25 (B)] TJ
+ET
+q
+0.333333 g
+Q
+/Span <</MCID 2/BBox [0 0.125 1 1]>> BDC
+1 2 m
+3 4 l
+S
+EMC
+Q
+`, string(data))
+
+	data, err = Normalize(content, &NormalizeOptions{Precision: 1, Indent: "  "})
+	require.NoError(t, err)
+	require.Equal(t, `q
+  1 0 0 1 72.5 700 cm
+  BT
+    /F1 12 Tf
+    1.0 0 Td
+    [(A) -2.3 (B)] TJ
+  ET
+  q
+    0.3 g
+  Q
+  /Span <</MCID 2/BBox [0 0.1 1 1]>> BDC
+  1 2 m
+  3 4 l
+  S
+  EMC
+Q
+`, string(data))
+
+	// Inline image data is not indented.
+	data, err = Normalize("q BI /W 2 /H 1 /CS /G /BPC 8 ID \x00 EI Q", &NormalizeOptions{Indent: "\t"})
+	require.NoError(t, err)
+	require.Equal(t, "q\n\tBI\n/BPC 8\n/CS /G\n/H 1\n/W 2\nID \x00 \nEI\nQ\n", string(data))
+
+	// Unbalanced operators are only accepted when not strict.
+	for _, content := range []string{"Q", "q", "q q Q", "BT", "ET", "BT BT ET ET", "q BT Q"} {
+		data, err := Normalize(content, nil)
+		require.NoError(t, err, content)
+		require.NotEmpty(t, data, content)
+		_, err = Normalize(content, &NormalizeOptions{Strict: true})
+		require.True(t, errors.Is(err, ErrUnbalancedOperators), content)
+	}
+	_, err = Normalize("q BT ET Q q Q", &NormalizeOptions{Strict: true})
+	require.NoError(t, err)
+}
+
+// TestNormalizeRoundTrip tests that the operations parsed from the normalized content streams of
+// the test PDF files, the parser corner cases and random content streams are the same as those
+// parsed from the original content streams.
+func TestNormalizeRoundTrip(t *testing.T) {
+	contents := append([]string{}, parserCases...)
+	contents = append(contents, corpusContents(t)...)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		contents = append(contents, randomContent(r))
+	}
+
+	opts := &NormalizeOptions{Precision: -1, Indent: " "}
+	for i, content := range contents {
+		expected, err := NewContentStreamParser(content).Parse()
+		if err != nil {
+			continue
+		}
+		desc := fmt.Sprintf("content %d: %q", i, truncate(content, 200))
+		data, err := expected.Normalize(opts)
+		require.NoError(t, err, desc)
+		ops, err := NewContentStreamParser(string(data)).Parse()
+		require.NoError(t, err, desc)
+		requireSameOperations(t, *expected, *ops, desc)
+
+		// Normalizing is idempotent.
+		again, err := ops.Normalize(opts)
+		require.NoError(t, err, desc)
+		require.Equal(t, string(data), string(again), desc)
+	}
+}
+
+// requireSameOperations requires the operations `expected` and `actual` to have the same operators
+// and operands of the same types with the same representations.
+func requireSameOperations(t *testing.T, expected, actual ContentStreamOperations, desc string) {
+	require.Len(t, actual, len(expected), desc)
+	for i, op := range expected {
+		require.Equal(t, op.Operand, actual[i].Operand, desc)
+		require.Len(t, actual[i].Params, len(op.Params), desc)
+		for j, param := range op.Params {
+			require.IsType(t, param, actual[i].Params[j], desc)
+			require.Equal(t, param.WriteString(), actual[i].Params[j].WriteString(), desc)
+		}
+	}
+}
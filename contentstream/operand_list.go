@@ -5,6 +5,12 @@
 
 package contentstream
 
+import (
+	"fmt"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
 // isValidOperand returns true if `operand` is a valid PDF contentstream operand.
 func isValidOperand(operand string) bool {
 	_, has := validOperands[operand]
@@ -88,3 +94,223 @@ var validOperands = map[string]struct{}{
 	`'`:   struct{}{},
 	`"`:   struct{}{},
 }
+
+// operandKind is the kind of an operand of a content stream operator.
+type operandKind int
+
+// Kinds of operands of content stream operators.
+const (
+	kindNumber operandKind = iota
+	kindInteger
+	kindName
+	kindString
+	kindArray
+	kindNameOrDict // Name of a resource or inline dictionary.
+)
+
+// String returns a description of the operand kind `k`.
+func (k operandKind) String() string {
+	switch k {
+	case kindNumber:
+		return "number"
+	case kindInteger:
+		return "integer"
+	case kindName:
+		return "name"
+	case kindString:
+		return "string"
+	case kindArray:
+		return "array"
+	case kindNameOrDict:
+		return "name or dictionary"
+	}
+	return "unknown"
+}
+
+// Shorthands for the operands of operators taking several numbers.
+var (
+	numbers2 = []operandKind{kindNumber, kindNumber}
+	numbers3 = []operandKind{kindNumber, kindNumber, kindNumber}
+	numbers4 = []operandKind{kindNumber, kindNumber, kindNumber, kindNumber}
+	numbers6 = []operandKind{kindNumber, kindNumber, kindNumber, kindNumber, kindNumber, kindNumber}
+)
+
+// operandKinds are the kinds of the operands of the content stream operators with a fixed number
+// of operands (Table A.1 PDF32000_2008). The operands of SC, sc, SCN, scn and BI are variable.
+var operandKinds = map[string][]operandKind{
+	"b": nil, "B": nil, "b*": nil, "B*": nil, "BT": nil, "BX": nil, "EI": nil, "EMC": nil,
+	"ET": nil, "EX": nil, "f": nil, "F": nil, "f*": nil, "h": nil, "ID": nil, "n": nil, "q": nil,
+	"Q": nil, "s": nil, "S": nil, "T*": nil, "W": nil, "W*": nil,
+
+	"BDC": {kindName, kindNameOrDict},
+	"BMC": {kindName},
+	"c":   numbers6,
+	"cm":  numbers6,
+	"CS":  {kindName},
+	"cs":  {kindName},
+	"d":   {kindArray, kindNumber},
+	"d0":  numbers2,
+	"d1":  numbers6,
+	"Do":  {kindName},
+	"DP":  {kindName, kindNameOrDict},
+	"G":   {kindNumber},
+	"g":   {kindNumber},
+	"gs":  {kindName},
+	"i":   {kindNumber},
+	"j":   {kindInteger},
+	"J":   {kindInteger},
+	"K":   numbers4,
+	"k":   numbers4,
+	"l":   numbers2,
+	"m":   numbers2,
+	"M":   {kindNumber},
+	"MP":  {kindName},
+	"re":  numbers4,
+	"RG":  numbers3,
+	"rg":  numbers3,
+	"ri":  {kindName},
+	"sh":  {kindName},
+	"Tc":  {kindNumber},
+	"Td":  numbers2,
+	"TD":  numbers2,
+	"Tf":  {kindName, kindNumber},
+	"Tj":  {kindString},
+	"TJ":  {kindArray},
+	"TL":  {kindNumber},
+	"Tm":  numbers6,
+	"Tr":  {kindInteger},
+	"Ts":  {kindNumber},
+	"Tw":  {kindNumber},
+	"Tz":  {kindNumber},
+	"v":   numbers4,
+	"w":   {kindNumber},
+	"y":   numbers4,
+	`'`:   {kindString},
+	`"`:   {kindNumber, kindNumber, kindString},
+}
+
+// renderingIntents are the standard color rendering intents (Table 70 p. 152 PDF32000_2008).
+var renderingIntents = map[core.PdfObjectName]struct{}{
+	"AbsoluteColorimetric": {},
+	"RelativeColorimetric": {},
+	"Saturation":           {},
+	"Perceptual":           {},
+}
+
+// checkOperation returns an error wrapping ErrInvalidOperand if the operator of `op` is not a
+// valid content stream operator or if its operands do not have the number, types and values the
+// operator requires.
+func checkOperation(op *ContentStreamOperation) error {
+	if !isValidOperand(op.Operand) {
+		return fmt.Errorf("%w: unknown operator %q", ErrInvalidOperand, op.Operand)
+	}
+
+	switch op.Operand {
+	case "BI":
+		if len(op.Params) != 1 {
+			return fmt.Errorf("%w: BI takes an inline image", ErrInvalidOperand)
+		}
+		img, ok := op.Params[0].(*ContentStreamInlineImage)
+		if !ok || img == nil {
+			return fmt.Errorf("%w: BI operand is not an inline image (%T)", ErrInvalidOperand, op.Params[0])
+		}
+		if img.Width == nil || img.Height == nil {
+			return fmt.Errorf("%w: inline image without dimensions", ErrInvalidOperand)
+		}
+		return nil
+	case "SC", "sc", "SCN", "scn":
+		// SC and sc take the 1 to 4 components of colors in device, CIE-based and Indexed
+		// colorspaces. SCN and scn also support Separation and DeviceN colorspaces, of at most 32
+		// components (Table C.1 p. 652 PDF32000_2008), and Pattern colorspaces, whose colors are
+		// given by the name of the pattern, following the components of uncolored patterns.
+		params := op.Params
+		maxComponents := 4
+		if op.Operand == "SCN" || op.Operand == "scn" {
+			maxComponents = 32
+			if n := len(params); n > 0 && isKind(params[n-1], kindName) {
+				params = params[:n-1]
+			}
+		}
+		if len(op.Params) == 0 || len(params) > maxComponents {
+			return fmt.Errorf("%w: %s takes 1 to %d color components, got %d", ErrInvalidOperand,
+				op.Operand, maxComponents, len(params))
+		}
+		for _, param := range params {
+			if !isKind(param, kindNumber) {
+				return fmt.Errorf("%w: %s color component is not a number (%T)", ErrInvalidOperand,
+					op.Operand, param)
+			}
+		}
+		return nil
+	}
+
+	kinds := operandKinds[op.Operand]
+	if len(op.Params) != len(kinds) {
+		return fmt.Errorf("%w: %s takes %d operands, got %d", ErrInvalidOperand, op.Operand,
+			len(kinds), len(op.Params))
+	}
+	for i, kind := range kinds {
+		if !isKind(op.Params[i], kind) {
+			return fmt.Errorf("%w: %s operand %d is not a %s (%T)", ErrInvalidOperand, op.Operand,
+				i+1, kind, op.Params[i])
+		}
+	}
+
+	// Operands with restricted values.
+	switch op.Operand {
+	case "J", "j":
+		// Line cap styles and line join styles (Tables 54 and 55 p. 126 PDF32000_2008).
+		if style, _ := core.GetIntVal(op.Params[0]); style < 0 || style > 2 {
+			return fmt.Errorf("%w: invalid %s style %d", ErrInvalidOperand, op.Operand, style)
+		}
+	case "Tr":
+		if mode, _ := core.GetIntVal(op.Params[0]); mode < 0 || mode > 7 {
+			return fmt.Errorf("%w: invalid text rendering mode %d", ErrInvalidOperand, mode)
+		}
+	case "ri":
+		intent, _ := core.GetName(op.Params[0])
+		if _, ok := renderingIntents[*intent]; !ok {
+			return fmt.Errorf("%w: invalid rendering intent %s", ErrInvalidOperand, *intent)
+		}
+	case "i":
+		if flatness, _ := core.GetNumberAsFloat(op.Params[0]); flatness < 0 || flatness > 100 {
+			return fmt.Errorf("%w: flatness %g not in [0, 100]", ErrInvalidOperand, flatness)
+		}
+	case "d":
+		dashes, _ := core.GetArray(op.Params[0])
+		for _, dash := range dashes.Elements() {
+			if length, err := core.GetNumberAsFloat(dash); err != nil || length < 0 {
+				return fmt.Errorf("%w: invalid dash array %s", ErrInvalidOperand, dashes)
+			}
+		}
+	}
+	return nil
+}
+
+// isKind returns true if `obj` is an operand of kind `kind`.
+func isKind(obj core.PdfObject, kind operandKind) bool {
+	switch kind {
+	case kindNumber:
+		_, err := core.GetNumberAsFloat(obj)
+		return err == nil
+	case kindInteger:
+		_, ok := core.GetIntVal(obj)
+		return ok
+	case kindName:
+		_, ok := core.GetName(obj)
+		return ok
+	case kindString:
+		_, ok := core.GetString(obj)
+		return ok
+	case kindArray:
+		_, ok := core.GetArray(obj)
+		return ok
+	case kindNameOrDict:
+		if _, ok := core.GetName(obj); ok {
+			return true
+		}
+		_, ok := core.GetDict(obj)
+		return ok
+	}
+	return false
+}
@@ -9,41 +9,103 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"io"
-	"strconv"
+	"sync"
 
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
 )
 
 // ContentStreamParser represents a content stream parser for parsing content streams in PDFs.
+//
+// The parser reads the content stream from a byte slice. The operations, numbers and names it
+// parses are allocated in chunks rather than one by one, and the operands of each operation share
+// the backing array of a chunk. The capacity of the operand slices is limited to their length, so
+// that appending to them does not overwrite the operands of other operations.
 type ContentStreamParser struct {
-	reader *bufio.Reader
+	data []byte // Content stream data, terminated by a newline.
+	pos  int    // Offset of the next byte of `data` to read.
+
+	// Chunks the parsed objects are allocated from and their size.
+	chunkSize  int
+	operations []ContentStreamOperation
+	params     []core.PdfObject
+	ints       []core.PdfObjectInteger
+	floats     []core.PdfObjectFloat
+	names      []core.PdfObjectName
+
+	// Strings of the names parsed, to share the strings of repeated names.
+	nameStrings map[string]string
+
+	buf []byte // Scratch buffer for strings.
 }
 
 // NewContentStreamParser creates a new instance of the content stream parser from an input content
 // stream string.
 func NewContentStreamParser(contentStr string) *ContentStreamParser {
-	// Each command has parameters and an operand (command).
-	parser := ContentStreamParser{}
+	// Add newline at end to get last operand without EOF error.
+	data := make([]byte, len(contentStr)+1)
+	copy(data, contentStr)
+	data[len(contentStr)] = '\n'
+	return newContentStreamParser(data)
+}
 
-	buffer := bytes.NewBufferString(contentStr + "\n") // Add newline at end to get last operand without EOF error.
-	parser.reader = bufio.NewReader(buffer)
+// newContentStreamParser returns a parser of content stream `data`, which must end with whitespace.
+func newContentStreamParser(data []byte) *ContentStreamParser {
+	// Roughly one object for every 8 bytes of content.
+	chunkSize := len(data) / 8
+	if chunkSize < minChunkSize {
+		chunkSize = minChunkSize
+	} else if chunkSize > maxChunkSize {
+		chunkSize = maxChunkSize
+	}
+	return &ContentStreamParser{data: data, chunkSize: chunkSize}
+}
 
-	return &parser
+// Limits of the number of objects allocated at once by the parser.
+const (
+	minChunkSize = 8
+	maxChunkSize = 512
+)
+
+// operandsPool holds the slices the operands of operations are collected in while parsing.
+var operandsPool = sync.Pool{
+	New: func() interface{} {
+		operands := make([]core.PdfObject, 0, 16)
+		return &operands
+	},
 }
 
+// operatorNames maps the valid content stream operators to themselves, in order to share the
+// strings of the operators parsed.
+var operatorNames = func() map[string]string {
+	names := make(map[string]string, len(validOperands))
+	for operator := range validOperands {
+		names[operator] = operator
+	}
+	return names
+}()
+
 // Parse parses all commands in content stream, returning a list of operation data.
 func (csp *ContentStreamParser) Parse() (*ContentStreamOperations, error) {
 	operations := ContentStreamOperations{}
 
-	for {
-		operation := ContentStreamOperation{}
+	pooled := operandsPool.Get().(*[]core.PdfObject)
+	operands := (*pooled)[:0]
+	defer func() {
+		// Do not keep the objects parsed alive.
+		for i := range operands {
+			operands[i] = nil
+		}
+		*pooled = operands[:0]
+		operandsPool.Put(pooled)
+	}()
 
+	for {
+		var operation *ContentStreamOperation
 		for {
-			obj, isOperand, err := csp.parseObject()
+			obj, operator, err := csp.parseToken()
 			if err != nil {
 				if err == io.EOF {
 					// End of data. Successful exit point.
@@ -51,13 +113,16 @@ func (csp *ContentStreamParser) Parse() (*ContentStreamOperations, error) {
 				}
 				return &operations, err
 			}
-			if isOperand {
-				operation.Operand, _ = core.GetStringVal(obj)
-				operations = append(operations, &operation)
+			if operator != "" {
+				operation = csp.newOperation(operator, operands)
+				operations = append(operations, operation)
+				for i := range operands {
+					operands[i] = nil
+				}
+				operands = operands[:0]
 				break
-			} else {
-				operation.Params = append(operation.Params, obj)
 			}
+			operands = append(operands, obj)
 		}
 
 		if operation.Operand == "BI" {
@@ -72,102 +137,171 @@ func (csp *ContentStreamParser) Parse() (*ContentStreamOperations, error) {
 	}
 }
 
+// newOperation returns a new operation with operator `operator` and a copy of `operands`.
+func (csp *ContentStreamParser) newOperation(operator string, operands []core.PdfObject) *ContentStreamOperation {
+	if len(csp.operations) == 0 {
+		// Operations have several operands on average.
+		csp.operations = make([]ContentStreamOperation, csp.chunkSize/2)
+	}
+	op := &csp.operations[0]
+	csp.operations = csp.operations[1:]
+	op.Operand = operator
+
+	if n := len(operands); n > 0 {
+		if len(csp.params) < n {
+			size := csp.chunkSize
+			if size < n {
+				size = n
+			}
+			csp.params = make([]core.PdfObject, size)
+		}
+		op.Params = csp.params[:n:n]
+		csp.params = csp.params[n:]
+		copy(op.Params, operands)
+	}
+	return op
+}
+
+// newInteger returns a new integer object of value `val`.
+func (csp *ContentStreamParser) newInteger(val int64) *core.PdfObjectInteger {
+	if len(csp.ints) == 0 {
+		csp.ints = make([]core.PdfObjectInteger, csp.chunkSize)
+	}
+	obj := &csp.ints[0]
+	csp.ints = csp.ints[1:]
+	*obj = core.PdfObjectInteger(val)
+	return obj
+}
+
+// newFloat returns a new float object of value `val`.
+func (csp *ContentStreamParser) newFloat(val float64) *core.PdfObjectFloat {
+	if len(csp.floats) == 0 {
+		csp.floats = make([]core.PdfObjectFloat, csp.chunkSize)
+	}
+	obj := &csp.floats[0]
+	csp.floats = csp.floats[1:]
+	*obj = core.PdfObjectFloat(val)
+	return obj
+}
+
+// newName returns a new name object of value `name`.
+func (csp *ContentStreamParser) newName(name core.PdfObjectName) *core.PdfObjectName {
+	if len(csp.names) == 0 {
+		csp.names = make([]core.PdfObjectName, csp.chunkSize)
+	}
+	obj := &csp.names[0]
+	csp.names = csp.names[1:]
+	*obj = name
+	return obj
+}
+
+// peek returns the next `n` bytes without advancing the parser, or the remaining bytes and io.EOF
+// if fewer than `n` bytes remain.
+func (csp *ContentStreamParser) peek(n int) ([]byte, error) {
+	if rest := csp.data[csp.pos:]; len(rest) < n {
+		return rest, io.EOF
+	}
+	return csp.data[csp.pos : csp.pos+n], nil
+}
+
 // Skip over any spaces.  Returns the number of spaces skipped and
 // an error if any.
 func (csp *ContentStreamParser) skipSpaces() (int, error) {
-	cnt := 0
-	for {
-		bb, err := csp.reader.Peek(1)
-		if err != nil {
-			return 0, err
-		}
-		if core.IsWhiteSpace(bb[0]) {
-			csp.reader.ReadByte()
-			cnt++
-		} else {
-			break
-		}
+	start := csp.pos
+	for csp.pos < len(csp.data) && core.IsWhiteSpace(csp.data[csp.pos]) {
+		csp.pos++
 	}
-
-	return cnt, nil
+	if csp.pos == len(csp.data) {
+		return 0, io.EOF
+	}
+	return csp.pos - start, nil
 }
 
 // Skip over comments and spaces. Can handle multi-line comments.
 func (csp *ContentStreamParser) skipComments() error {
-	if _, err := csp.skipSpaces(); err != nil {
-		return err
-	}
-
-	isFirst := true
 	for {
-		bb, err := csp.reader.Peek(1)
-		if err != nil {
-			common.Log.Debug("Error %s", err.Error())
+		if _, err := csp.skipSpaces(); err != nil {
 			return err
 		}
-		if isFirst && bb[0] != '%' {
+		if csp.data[csp.pos] != '%' {
 			// Not a comment clearly.
 			return nil
 		}
-		isFirst = false
-
-		if (bb[0] != '\r') && (bb[0] != '\n') {
-			csp.reader.ReadByte()
-		} else {
-			break
+		for csp.data[csp.pos] != '\r' && csp.data[csp.pos] != '\n' {
+			csp.pos++
+			if csp.pos == len(csp.data) {
+				return io.EOF
+			}
 		}
 	}
+}
 
-	// Call recursively to handle multiline comments.
-	return csp.skipComments()
+// isNameEnd returns true if `c` ends a name.
+func isNameEnd(c byte) bool {
+	switch c {
+	case '/', '[', '(', ']', '<', '>':
+		// Looks like start of next statement.
+		return true
+	}
+	return core.IsWhiteSpace(c)
 }
 
 // Parse a name starting with '/'.
 func (csp *ContentStreamParser) parseName() (core.PdfObjectName, error) {
-	name := ""
-	nameStarted := false
-	for {
-		bb, err := csp.reader.Peek(1)
-		if err == io.EOF {
-			break // Can happen when loading from object stream.
+	if csp.pos == len(csp.data) {
+		return "", nil
+	}
+	if c := csp.data[csp.pos]; c != '/' {
+		// Should always start with '/', otherwise not valid.
+		common.Log.Error("Name starting with %c (% x)", c, c)
+		return "", fmt.Errorf("invalid name: (%c)", c)
+	}
+	csp.pos++
+
+	start := csp.pos
+	for csp.pos < len(csp.data) && !isNameEnd(csp.data[csp.pos]) && csp.data[csp.pos] != '#' {
+		csp.pos++
+	}
+	if csp.pos == len(csp.data) || csp.data[csp.pos] != '#' {
+		return core.PdfObjectName(csp.internName(csp.data[start:csp.pos])), nil
+	}
+
+	// Names with hexadecimal codes.
+	name := append(csp.buf[:0], csp.data[start:csp.pos]...)
+	defer func() { csp.buf = name[:0] }()
+	for csp.pos < len(csp.data) && !isNameEnd(csp.data[csp.pos]) {
+		c := csp.data[csp.pos]
+		if c != '#' {
+			name = append(name, c)
+			csp.pos++
+			continue
 		}
+		hexcode, err := csp.peek(3)
 		if err != nil {
 			return core.PdfObjectName(name), err
 		}
+		csp.pos += 3
 
-		if !nameStarted {
-			// Should always start with '/', otherwise not valid.
-			if bb[0] == '/' {
-				nameStarted = true
-				csp.reader.ReadByte()
-			} else {
-				common.Log.Error("Name starting with %s (% x)", bb, bb)
-				return core.PdfObjectName(name), fmt.Errorf("invalid name: (%c)", bb[0])
-			}
-		} else {
-			if core.IsWhiteSpace(bb[0]) {
-				break
-			} else if (bb[0] == '/') || (bb[0] == '[') || (bb[0] == '(') || (bb[0] == ']') || (bb[0] == '<') || (bb[0] == '>') {
-				break // Looks like start of next statement.
-			} else if bb[0] == '#' {
-				hexcode, err := csp.reader.Peek(3)
-				if err != nil {
-					return core.PdfObjectName(name), err
-				}
-				csp.reader.Discard(3)
-
-				code, err := hex.DecodeString(string(hexcode[1:3]))
-				if err != nil {
-					return core.PdfObjectName(name), err
-				}
-				name += string(code)
-			} else {
-				b, _ := csp.reader.ReadByte()
-				name += string(b)
-			}
+		code, err := hex.DecodeString(string(hexcode[1:3]))
+		if err != nil {
+			return core.PdfObjectName(name), err
 		}
+		name = append(name, code...)
 	}
-	return core.PdfObjectName(name), nil
+	return core.PdfObjectName(csp.internName(name)), nil
+}
+
+// internName returns name `b` as a string, shared by the names of the same value.
+func (csp *ContentStreamParser) internName(b []byte) string {
+	if name, ok := csp.nameStrings[string(b)]; ok {
+		return name
+	}
+	if csp.nameStrings == nil {
+		csp.nameStrings = make(map[string]string)
+	}
+	name := string(b)
+	csp.nameStrings[name] = name
+	return name
 }
 
 // Numeric objects.
@@ -190,51 +324,131 @@ func (csp *ContentStreamParser) parseName() (core.PdfObjectName, error) {
 // Nonetheless, we sometimes get numbers with exponential format, so
 // we will support it in the reader (no confusion with other types, so
 // no compromise).
+//
+// Well-formed numbers of up to 15 digits, which are exactly represented, are converted directly.
+// Other numbers are parsed by core.ParseNumber.
 func (csp *ContentStreamParser) parseNumber() (core.PdfObject, error) {
-	return core.ParseNumber(csp.reader)
+	data := csp.data
+	i := csp.pos
+	neg, signed := false, false
+	if c := data[i]; c == '-' || c == '+' {
+		neg, signed = c == '-', true
+		i++
+	}
+	var mantissa int64
+	digits, fracDigits := 0, 0
+	hasPeriod := false
+	for ; i < len(data); i++ {
+		c := data[i]
+		if core.IsDecimalDigit(c) {
+			mantissa = 10*mantissa + int64(c-'0')
+			digits++
+			if hasPeriod {
+				fracDigits++
+			}
+			continue
+		}
+		if c != '.' || hasPeriod {
+			break
+		}
+		hasPeriod = true
+	}
+	exact := digits > 0 && digits <= maxExactDigits
+	if i < len(data) {
+		switch data[i] {
+		case '.', 'e', 'E':
+			// Multiple periods or exponential format.
+			exact = false
+		case '+', '-':
+			// Signs are only delimiters after a leading sign.
+			exact = exact && signed
+		}
+	}
+	if !exact {
+		return csp.parseNumberSlow()
+	}
+	csp.pos = i
+
+	if neg {
+		mantissa = -mantissa
+	}
+	if !hasPeriod {
+		return csp.newInteger(mantissa), nil
+	}
+	// Same as strconv.ParseFloat, as `mantissa` and the power of 10 are exactly represented.
+	val := float64(mantissa)
+	if mantissa == 0 && neg {
+		val = -val
+	}
+	return csp.newFloat(val / pow10[fracDigits]), nil
+}
+
+// maxExactDigits is the maximum number of digits of the numbers parsed by parseNumber, whose
+// values are exactly represented as float64 values.
+const maxExactDigits = 15
+
+// pow10 are the powers of 10 exactly represented as float64 values.
+var pow10 = [...]float64{1e0, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9, 1e10, 1e11, 1e12, 1e13,
+	1e14, 1e15}
+
+// parseNumberSlow parses numbers with core.ParseNumber.
+func (csp *ContentStreamParser) parseNumberSlow() (core.PdfObject, error) {
+	rest := bytes.NewReader(csp.data[csp.pos:])
+	reader := bufio.NewReader(rest)
+	obj, err := core.ParseNumber(reader)
+	csp.pos = len(csp.data) - rest.Len() - reader.Buffered()
+	return obj, err
 }
 
 // A string starts with '(' and ends with ')'.
 func (csp *ContentStreamParser) parseString() (*core.PdfObjectString, error) {
-	csp.reader.ReadByte()
+	csp.pos++
+
+	// Strings without escape sequences, end-of-line markers and nested parentheses.
+	data := csp.data
+	for i := csp.pos; i < len(data); i++ {
+		c := data[i]
+		if c == ')' {
+			str := core.MakeString(string(data[csp.pos:i]))
+			csp.pos = i + 1
+			return str, nil
+		}
+		if c == '\\' || c == '\r' || c == '(' {
+			break
+		}
+	}
 
-	var bytes []byte
+	bytes := csp.buf[:0]
+	defer func() { csp.buf = bytes[:0] }()
 	count := 1
 	for {
-		bb, err := csp.reader.Peek(1)
-		if err != nil {
-			return core.MakeString(string(bytes)), err
+		if csp.pos == len(data) {
+			return core.MakeString(string(bytes)), io.EOF
 		}
+		c := data[csp.pos]
 
-		if bb[0] == '\\' { // Escape sequence.
-			csp.reader.ReadByte() // Skip the escape \ byte.
-			b, err := csp.reader.ReadByte()
-			if err != nil {
-				return core.MakeString(string(bytes)), err
+		if c == '\\' { // Escape sequence.
+			csp.pos++ // Skip the escape \ byte.
+			if csp.pos == len(data) {
+				return core.MakeString(string(bytes)), io.EOF
 			}
+			b := data[csp.pos]
+			csp.pos++
 
 			// Octal '\ddd' number (base 8).
 			if core.IsOctalDigit(b) {
-				bb, err := csp.reader.Peek(2)
+				bb, err := csp.peek(2)
 				if err != nil {
 					return core.MakeString(string(bytes)), err
 				}
 
-				var numeric []byte
-				numeric = append(numeric, b)
+				code := uint(b - '0')
 				for _, val := range bb {
-					if core.IsOctalDigit(val) {
-						numeric = append(numeric, val)
-					} else {
+					if !core.IsOctalDigit(val) {
 						break
 					}
-				}
-				csp.reader.Discard(len(numeric) - 1)
-
-				common.Log.Trace("Numeric string \"%s\"", numeric)
-				code, err := strconv.ParseUint(string(numeric), 8, 32)
-				if err != nil {
-					return core.MakeString(string(bytes)), err
+					code = 8*code + uint(val-'0')
+					csp.pos++
 				}
 				bytes = append(bytes, byte(code))
 				continue
@@ -257,21 +471,39 @@ func (csp *ContentStreamParser) parseString() (*core.PdfObjectString, error) {
 				bytes = append(bytes, ')')
 			case '\\':
 				bytes = append(bytes, '\\')
+			case '\r':
+				// Line continuation: the end-of-line marker is not part of the string.
+				if csp.pos < len(data) && data[csp.pos] == '\n' {
+					csp.pos++
+				}
+			case '\n':
+				// Line continuation.
+			default:
+				// The backslash is ignored for other characters.
+				bytes = append(bytes, b)
 			}
 
 			continue
-		} else if bb[0] == '(' {
+		} else if c == '\r' {
+			// End-of-line markers are read as line feeds.
+			csp.pos++
+			if csp.pos < len(data) && data[csp.pos] == '\n' {
+				csp.pos++
+			}
+			bytes = append(bytes, '\n')
+			continue
+		} else if c == '(' {
 			count++
-		} else if bb[0] == ')' {
+		} else if c == ')' {
 			count--
 			if count == 0 {
-				csp.reader.ReadByte()
+				csp.pos++
 				break
 			}
 		}
 
-		b, _ := csp.reader.ReadByte()
-		bytes = append(bytes, b)
+		bytes = append(bytes, c)
+		csp.pos++
 	}
 
 	return core.MakeString(string(bytes)), nil
@@ -279,54 +511,69 @@ func (csp *ContentStreamParser) parseString() (*core.PdfObjectString, error) {
 
 // Starts with '<' ends with '>'.
 func (csp *ContentStreamParser) parseHexString() (*core.PdfObjectString, error) {
-	csp.reader.ReadByte()
-
-	hextable := []byte("0123456789abcdefABCDEF")
+	csp.pos++
 
-	var tmp []byte
+	// Decoded bytes and the high nibble of the byte being decoded, if odd.
+	decoded := csp.buf[:0]
+	defer func() { csp.buf = decoded[:0] }()
+	var high byte
+	odd := false
 	for {
 		csp.skipSpaces()
-
-		bb, err := csp.reader.Peek(1)
-		if err != nil {
-			return core.MakeString(""), err
+		if csp.pos == len(csp.data) {
+			return core.MakeString(""), io.EOF
 		}
 
-		if bb[0] == '>' {
-			csp.reader.ReadByte()
+		c := csp.data[csp.pos]
+		csp.pos++
+		if c == '>' {
 			break
 		}
-
-		b, _ := csp.reader.ReadByte()
-		if bytes.IndexByte(hextable, b) >= 0 {
-			tmp = append(tmp, b)
+		nibble, ok := hexValue(c)
+		if !ok {
+			continue
 		}
+		if odd {
+			decoded = append(decoded, high<<4|nibble)
+		} else {
+			high = nibble
+		}
+		odd = !odd
 	}
 
-	if len(tmp)%2 == 1 {
-		tmp = append(tmp, '0')
+	if odd {
+		decoded = append(decoded, high<<4)
 	}
+	return core.MakeHexString(string(decoded)), nil
+}
 
-	buf, _ := hex.DecodeString(string(tmp))
-	return core.MakeHexString(string(buf)), nil
+// hexValue returns the value of hexadecimal digit `c`.
+func hexValue(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
 }
 
 // Starts with '[' ends with ']'.  Can contain any kinds of direct objects.
 func (csp *ContentStreamParser) parseArray() (*core.PdfObjectArray, error) {
 	arr := core.MakeArray()
 
-	csp.reader.ReadByte()
+	csp.pos++
 
 	for {
 		csp.skipSpaces()
-
-		bb, err := csp.reader.Peek(1)
-		if err != nil {
-			return arr, err
+		if csp.pos == len(csp.data) {
+			return arr, io.EOF
 		}
 
-		if bb[0] == ']' {
-			csp.reader.ReadByte()
+		if csp.data[csp.pos] == ']' {
+			csp.pos++
 			break
 		}
 
@@ -340,69 +587,26 @@ func (csp *ContentStreamParser) parseArray() (*core.PdfObjectArray, error) {
 	return arr, nil
 }
 
-// Parse bool object.
-func (csp *ContentStreamParser) parseBool() (core.PdfObjectBool, error) {
-	bb, err := csp.reader.Peek(4)
-	if err != nil {
-		return core.PdfObjectBool(false), err
-	}
-	if (len(bb) >= 4) && (string(bb[:4]) == "true") {
-		csp.reader.Discard(4)
-		return core.PdfObjectBool(true), nil
-	}
-
-	bb, err = csp.reader.Peek(5)
-	if err != nil {
-		return core.PdfObjectBool(false), err
-	}
-	if (len(bb) >= 5) && (string(bb[:5]) == "false") {
-		csp.reader.Discard(5)
-		return core.PdfObjectBool(false), nil
-	}
-
-	return core.PdfObjectBool(false), errors.New("unexpected boolean string")
-}
-
-// Parse null object.
-func (csp *ContentStreamParser) parseNull() (core.PdfObjectNull, error) {
-	_, err := csp.reader.Discard(4)
-	return core.PdfObjectNull{}, err
-}
-
 func (csp *ContentStreamParser) parseDict() (*core.PdfObjectDictionary, error) {
-	common.Log.Trace("Reading content stream dict!")
-
 	dict := core.MakeDict()
 
 	// Pass the '<<'
-	c, _ := csp.reader.ReadByte()
-	if c != '<' {
-		return nil, errors.New("invalid dict")
-	}
-	c, _ = csp.reader.ReadByte()
-	if c != '<' {
-		return nil, errors.New("invalid dict")
-	}
+	csp.pos += 2
 
 	for {
 		csp.skipSpaces()
 
-		bb, err := csp.reader.Peek(2)
+		bb, err := csp.peek(2)
 		if err != nil {
 			return nil, err
 		}
 
-		common.Log.Trace("Dict peek: %s (% x)!", string(bb), string(bb))
 		if (bb[0] == '>') && (bb[1] == '>') {
-			common.Log.Trace("EOF dictionary")
-			csp.reader.ReadByte()
-			csp.reader.ReadByte()
+			csp.pos += 2
 			break
 		}
-		common.Log.Trace("Parse the name!")
 
 		keyName, err := csp.parseName()
-		common.Log.Trace("Key: %s", keyName)
 		if err != nil {
 			common.Log.Debug("ERROR Returning name err %s", err)
 			return nil, err
@@ -413,10 +617,8 @@ func (csp *ContentStreamParser) parseDict() (*core.PdfObjectDictionary, error) {
 			// space.  For example "\Boundsnull"
 			newKey := keyName[0 : len(keyName)-4]
 			common.Log.Trace("Taking care of null bug (%s)", keyName)
-			common.Log.Trace("New key \"%s\" = null", newKey)
 			csp.skipSpaces()
-			bb, _ := csp.reader.Peek(1)
-			if bb[0] == '/' {
+			if csp.pos < len(csp.data) && csp.data[csp.pos] == '/' {
 				dict.Set(newKey, core.MakeNull())
 				continue
 			}
@@ -429,105 +631,96 @@ func (csp *ContentStreamParser) parseDict() (*core.PdfObjectDictionary, error) {
 			return nil, err
 		}
 		dict.Set(keyName, val)
-
-		common.Log.Trace("dict[%s] = %s", keyName, val.String())
 	}
 
 	return dict, nil
 }
 
 // An operand is a text command represented by a word.
-func (csp *ContentStreamParser) parseOperand() (*core.PdfObjectString, error) {
-	var bytes []byte
-	for {
-		bb, err := csp.reader.Peek(1)
-		if err != nil {
-			return core.MakeString(string(bytes)), err
-		}
-		if core.IsDelimiter(bb[0]) {
-			break
-		}
-		if core.IsWhiteSpace(bb[0]) {
-			break
+// Returns the operand and whether it is terminated by a delimiter or whitespace.
+func (csp *ContentStreamParser) parseOperand() (string, bool) {
+	start := csp.pos
+	for csp.pos < len(csp.data) {
+		c := csp.data[csp.pos]
+		if core.IsDelimiter(c) || core.IsWhiteSpace(c) {
+			b := csp.data[start:csp.pos]
+			if operator, ok := operatorNames[string(b)]; ok {
+				return operator, true
+			}
+			return string(b), true
 		}
-
-		b, _ := csp.reader.ReadByte()
-		bytes = append(bytes, b)
+		csp.pos++
 	}
-
-	return core.MakeString(string(bytes)), nil
+	return string(csp.data[start:]), false
 }
 
 // Parse a generic object.  Returns the object, an error code, and a bool
 // value indicating whether the object is an operand.  An operand
 // is contained in a pdf string object.
 func (csp *ContentStreamParser) parseObject() (obj core.PdfObject, isop bool, err error) {
-	// Determine the kind of object.
-	// parse it!
-	// make a list of operands, then once operand arrives put into a package.
+	obj, operator, err := csp.parseToken()
+	if operator != "" {
+		return core.MakeString(operator), true, nil
+	}
+	return obj, false, err
+}
 
+// parseToken parses the next object or operator. Returns the object, or the operator if the next
+// token is an operator.
+func (csp *ContentStreamParser) parseToken() (obj core.PdfObject, operator string, err error) {
 	csp.skipSpaces()
 	for {
-		bb, err := csp.reader.Peek(2)
+		bb, err := csp.peek(2)
 		if err != nil {
-			return nil, false, err
+			return nil, "", err
 		}
 
-		common.Log.Trace("Peek string: %s", string(bb))
 		// Determine type.
-		if bb[0] == '%' {
+		switch c := bb[0]; {
+		case c == '%':
 			csp.skipComments()
 			continue
-		} else if bb[0] == '/' {
+		case c == '/':
 			name, err := csp.parseName()
-			common.Log.Trace("->Name: '%s'", name)
-			return &name, false, err
-		} else if bb[0] == '(' {
-			common.Log.Trace("->String!")
+			return csp.newName(name), "", err
+		case c == '(':
 			str, err := csp.parseString()
-			return str, false, err
-		} else if bb[0] == '<' && bb[1] != '<' {
-			common.Log.Trace("->Hex String!")
+			return str, "", err
+		case c == '<' && bb[1] != '<':
 			str, err := csp.parseHexString()
-			return str, false, err
-		} else if bb[0] == '[' {
-			common.Log.Trace("->Array!")
+			return str, "", err
+		case c == '[':
 			arr, err := csp.parseArray()
-			return arr, false, err
-		} else if core.IsFloatDigit(bb[0]) || (bb[0] == '-' && core.IsFloatDigit(bb[1])) {
-			common.Log.Trace("->Number!")
+			return arr, "", err
+		case core.IsFloatDigit(c) || (c == '-' && core.IsFloatDigit(bb[1])):
 			number, err := csp.parseNumber()
-			return number, false, err
-		} else if bb[0] == '<' && bb[1] == '<' {
+			return number, "", err
+		case c == '<' && bb[1] == '<':
 			dict, err := csp.parseDict()
-			return dict, false, err
-		} else {
-			// Otherwise, can be: keyword such as "null", "false", "true" or an operand...
-			common.Log.Trace("->Operand or bool?")
-			// Let's peek farther to find out.
-			bb, _ = csp.reader.Peek(5)
-			peekStr := string(bb)
-			common.Log.Trace("cont Peek str: %s", peekStr)
-
-			if (len(peekStr) > 3) && (peekStr[:4] == "null") {
-				null, err := csp.parseNull()
-				return &null, false, err
-			} else if (len(peekStr) > 4) && (peekStr[:5] == "false") {
-				b, err := csp.parseBool()
-				return &b, false, err
-			} else if (len(peekStr) > 3) && (peekStr[:4] == "true") {
-				b, err := csp.parseBool()
-				return &b, false, err
-			}
+			return dict, "", err
+		}
 
-			operand, err := csp.parseOperand()
-			if err != nil {
-				return operand, false, err
-			}
-			if len(operand.String()) < 1 {
-				return operand, false, ErrInvalidOperand
-			}
-			return operand, true, nil
+		// Otherwise, can be: keyword such as "null", "false", "true" or an operand...
+		// Let's peek farther to find out.
+		bb, _ = csp.peek(5)
+		if bytes.HasPrefix(bb, []byte("null")) {
+			csp.pos += 4
+			return core.MakeNull(), "", nil
+		} else if bytes.HasPrefix(bb, []byte("false")) {
+			csp.pos += 5
+			return core.MakeBool(false), "", nil
+		} else if bytes.HasPrefix(bb, []byte("true")) {
+			csp.pos += 4
+			return core.MakeBool(true), "", nil
+		}
+
+		operand, ok := csp.parseOperand()
+		if !ok {
+			return core.MakeString(operand), "", io.EOF
+		}
+		if len(operand) < 1 {
+			return core.MakeString(operand), "", ErrInvalidOperand
 		}
+		return nil, operand, nil
 	}
 }
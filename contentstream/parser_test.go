@@ -6,11 +6,20 @@
 package contentstream
 
 import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
 )
 
 type dictKeyVal struct {
@@ -248,3 +257,252 @@ EI (Very long string that is going to be only partially parser 12345678901234567
 		require.Equal(t, tcase.Expected, *ops)
 	}
 }
+
+// parserCases are content streams exercising the corner cases of the content stream syntax.
+var parserCases = []string{
+	"",
+	"q Q",
+	"q\r\n1 0 0 1 72 720 cm\rQ",
+	"% Comment\nq % Comment\r\n%Comment\n Q%",
+	"1 -2 +3 .5 -.5 5. 0.000001 -0 -0.0 007 123456789012345 1234567890123456 99999999999999999999 d0",
+	"1.2.3 --5 4-5 1e5 -2E-3 6.02e23 . -. 1.0e m",
+	"/Name /A#20B /#41#42 /Empty/ /N#4 /N#zz Tf",
+	"/N#4",
+	"(Hello) Tj (Nested (paren) \\(s\\)) Tj (Esc\\n\\r\\t\\b\\f\\(\\)\\\\\\q) Tj",
+	"(Octal \\101\\7\\0123\\777\\8) Tj (Cont\\\r\nin\\\nued\\\rx) Tj (CR\rLF\r\nEnd) Tj",
+	"(Unterminated",
+	"(Octal at end\\1",
+	"<48656C6C6F> Tj <4 8 6\n5 7> Tj <zz41> Tj <> Tj <486",
+	"[(A) -120 (B) 3.5 [1 2] <<>> /N true false null foo] TJ",
+	"[1 2",
+	"/GS0 gs /P <</MCID 3 /Nested <</A [1 2] /B (s)>> /Boundsnull /Next 1>> BDC EMC",
+	"/P <</Anull>> BDC",
+	"/P <</A 1 /B",
+	"/P <<1 2>> BDC",
+	"true false null truex nullable falsey op",
+	") Tj",
+	"q ] Q",
+	"q {} Q",
+	"BT /F1 12 Tf 72 712 Td (A) Tj T* [(B) 2 (C)] TJ 1 2 (D) \" (E) ' ET",
+	"q 1 0 0 1 0 0 cm BI /W 2 /H 1 /CS /G /BPC 8 ID \x00\xff EI Q",
+	"BI /W 2 /H 1 /CS /RGB /BPC 8 /L 6 ID \x00EI \x01\x02\x03 EI Q",
+	"BI /W 4 /H 1 /CS /G /BPC 8 /F /AHx ID 00FF80EI> EI Q",
+	"BI /W 1 /H 1 /CS /G /BPC 8 ID \x00\x00 EI q EI Q q",
+	"BI /W 1 /H 1 /CS /G /BPC 8 /F /Fl ID xyzEI\nq Q",
+	"BI /W 1 /H 1 /Foo 3 /CS /G /BPC 8 ID \x00 EI",
+	"BI /W 1 /H 1 /CS /G /BPC 8 ID \x00",
+	"BI 1 ID",
+	"BI /W EI",
+}
+
+var updateGolden = flag.Bool("contentstream-update-goldens", false, "update the golden operation lists")
+
+// TestParserGolden tests that the operations parsed from the corner cases and from random content
+// streams match the golden operation lists of testdata/parser_cases.golden. The golden file is
+// rewritten when running the tests with the -contentstream-update-goldens flag.
+func TestParserGolden(t *testing.T) {
+	contents := append([]string{}, parserCases...)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 300; i++ {
+		contents = append(contents, randomContent(r))
+	}
+
+	var sb strings.Builder
+	for i, content := range contents {
+		ops, err := NewContentStreamParser(content).Parse()
+		fmt.Fprintf(&sb, "=== %d %q\n", i, content)
+		if err != nil {
+			fmt.Fprintf(&sb, "error: %v\n", err)
+		}
+		sb.WriteString(describeOperations(ops))
+	}
+
+	path := filepath.Join("testdata", "parser_cases.golden")
+	if *updateGolden {
+		require.NoError(t, ioutil.WriteFile(path, []byte(sb.String()), 0644))
+	}
+	golden, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(golden), sb.String())
+}
+
+// TestParserCorpus tests that the operations parsed from the content streams of the test PDF
+// files are written out the same once parsed back.
+func TestParserCorpus(t *testing.T) {
+	for i, content := range corpusContents(t) {
+		desc := fmt.Sprintf("content %d: %q", i, truncate(content, 200))
+		ops, err := NewContentStreamParser(content).Parse()
+		if err != nil {
+			continue
+		}
+		reparsed, err := NewContentStreamParser(ops.String()).Parse()
+		require.NoError(t, err, desc)
+		require.Equal(t, ops.String(), reparsed.String(), desc)
+	}
+}
+
+// describeOperations returns the descriptions of `ops`, one per line.
+func describeOperations(ops *ContentStreamOperations) string {
+	var sb strings.Builder
+	for _, op := range *ops {
+		sb.WriteString(describeOperation(op))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// describeOperation returns a description of `op` listing the types and the values of its
+// operands.
+func describeOperation(op *ContentStreamOperation) string {
+	desc := strconv.Quote(op.Operand)
+	for _, param := range op.Params {
+		desc += " " + describeObject(param)
+	}
+	return desc
+}
+
+// describeObject returns a description of the type and the value of `obj`.
+func describeObject(obj core.PdfObject) string {
+	switch t := obj.(type) {
+	case nil:
+		return "nil"
+	case *core.PdfObjectInteger:
+		return fmt.Sprintf("int(%d)", *t)
+	case *core.PdfObjectFloat:
+		return fmt.Sprintf("real(%s)", strconv.FormatFloat(float64(*t), 'g', -1, 64))
+	case *core.PdfObjectBool:
+		return fmt.Sprintf("bool(%t)", bool(*t))
+	case *core.PdfObjectNull:
+		return "null"
+	case *core.PdfObjectName:
+		return fmt.Sprintf("name(%q)", string(*t))
+	case *core.PdfObjectString:
+		if t.IsHex() {
+			return fmt.Sprintf("hex(%q)", t.Str())
+		}
+		return fmt.Sprintf("string(%q)", t.Str())
+	case *core.PdfObjectArray:
+		elems := make([]string, t.Len())
+		for i, elem := range t.Elements() {
+			elems[i] = describeObject(elem)
+		}
+		return "[" + strings.Join(elems, " ") + "]"
+	case *core.PdfObjectDictionary:
+		var entries []string
+		for _, key := range t.Keys() {
+			entries = append(entries, fmt.Sprintf("%q: %s", string(key), describeObject(t.Get(key))))
+		}
+		return "<<" + strings.Join(entries, " ") + ">>"
+	case *ContentStreamInlineImage:
+		return fmt.Sprintf("image(%q %q)", t.WriteString(), t.stream)
+	}
+	return fmt.Sprintf("%T(%v)", obj, obj)
+}
+
+// corpusContents returns the page content streams and the form XObject streams of the PDF files
+// in the testdata directories of the repository.
+func corpusContents(t testing.TB) []string {
+	paths, err := filepath.Glob(filepath.Join("..", "*", "testdata", "*.pdf"))
+	require.NoError(t, err)
+
+	var contents []string
+	for _, path := range paths {
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		reader, err := model.NewPdfReader(f)
+		if err == nil {
+			contents = append(contents, pdfContents(reader)...)
+		}
+		f.Close()
+	}
+	return contents
+}
+
+// pdfContents returns the page content streams and the form XObject streams of `reader`.
+func pdfContents(reader *model.PdfReader) []string {
+	if encrypted, err := reader.IsEncrypted(); err != nil || encrypted {
+		if ok, err := reader.Decrypt(nil); err != nil || !ok {
+			return nil
+		}
+	}
+	var contents []string
+	for _, page := range reader.PageList {
+		if content, err := page.GetAllContentStreams(); err == nil {
+			contents = append(contents, content)
+		}
+	}
+	for _, num := range reader.GetObjectNums() {
+		obj, err := reader.GetIndirectObjectByNumber(num)
+		if err != nil {
+			continue
+		}
+		stream, ok := core.GetStream(obj)
+		if !ok {
+			continue
+		}
+		if subtype, ok := core.GetName(stream.Get("Subtype")); !ok || *subtype != "Form" {
+			continue
+		}
+		if data, err := core.DecodeStream(stream); err == nil {
+			contents = append(contents, string(data))
+		}
+	}
+	return contents
+}
+
+// randomTokens are the tokens random content streams are made of.
+var randomTokens = []string{
+	" ", "\n", "\r", "\r\n", "\t", "\x00", "%", "/", "(", ")", "\\", "<", ">", "<<", ">>", "[", "]",
+	"{", "}", "#", "#4", "#41", "0", "1", "-", "+", ".", "e", "E", "12.5", "-3", "true", "false",
+	"null", "q", "Q", "cm", "Tj", "TJ", "BT", "ET", "/F1", "(str)", "\\101", "\\\r", "<414>",
+	"BI", "ID", "EI", " EI ", "/W 1 /H 1 /CS /G /BPC 8", "\xff", "xyz",
+}
+
+// randomContent returns a random content stream made of `randomTokens`.
+func randomContent(r *rand.Rand) string {
+	var sb strings.Builder
+	n := r.Intn(60)
+	for i := 0; i < n; i++ {
+		sb.WriteString(randomTokens[r.Intn(len(randomTokens))])
+		if r.Intn(3) == 0 {
+			sb.WriteByte(' ')
+		}
+	}
+	return sb.String()
+}
+
+// truncate returns the first `n` bytes of `s`.
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// BenchmarkParse benchmarks the parsing of the content streams of the test PDF files.
+func BenchmarkParse(b *testing.B) {
+	contents := corpusContents(b)
+	// A text-heavy page.
+	var sb strings.Builder
+	sb.WriteString("BT /F1 10 Tf 12 TL 72 720 Td\n")
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&sb, "[(Line) -250 (%d) -250.5 (of text)] TJ 0 -12 Td /Span <</MCID %d>> BDC (x) Tj EMC\n", i, i)
+	}
+	sb.WriteString("ET\n")
+	contents = append(contents, sb.String())
+
+	size := 0
+	for _, content := range contents {
+		size += len(content)
+	}
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, content := range contents {
+			if _, err := NewContentStreamParser(content).Parse(); err != nil {
+				b.Fatalf("Error: %v", err)
+			}
+		}
+	}
+}
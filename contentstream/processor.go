@@ -6,8 +6,10 @@
 package contentstream
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
 
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
@@ -23,6 +25,33 @@ type GraphicsState struct {
 	ColorStroking         model.PdfColor
 	ColorNonStroking      model.PdfColor
 	CTM                   transform.Matrix
+
+	// PatternStroking and PatternNonStroking are the patterns of the current colors, when set
+	// with the SCN and scn operators in a Pattern colorspace, nil otherwise.
+	PatternStroking    *model.PdfPattern
+	PatternNonStroking *model.PdfPattern
+
+	// Clip is the region of the current clipping path in the coordinates of the content stream,
+	// i.e. the coordinates the CTM maps to, or nil if unbounded. Clipping paths are approximated
+	// by their bounding boxes, so that Clip contains the clipping path.
+	Clip *model.PdfRectangle
+
+	// Transparency parameters set with the gs operator (Table 58 p. 128): the constant alphas of
+	// the stroking (CA) and non-stroking (ca) operations, the blend mode (BM) and the soft mask
+	// (SMask), nil for None. SoftMaskCTM is the CTM when the soft mask was set, which maps the
+	// coordinates of the mask's transparency group to those of the content stream.
+	AlphaStroking    float64
+	AlphaNonStroking float64
+	BlendMode        core.PdfObjectName
+	SoftMask         *model.PdfSoftMask
+	SoftMaskCTM      transform.Matrix
+
+	// InCharProc is true while the glyph description (CharProc) of a glyph shown with a Type3
+	// font is processed. The CTM then maps the glyph space to the coordinates of the content
+	// stream showing the glyph.
+	InCharProc bool
+
+	text textState
 }
 
 // GraphicStateStack represents a stack of GraphicsState.
@@ -55,6 +84,27 @@ type ContentStreamProcessor struct {
 
 	handlers     []handlerEntry
 	currentIndex int
+
+	// Bounding box of the current path, in the coordinates of the content stream, nil if there is
+	// no current path, and whether the path is to be intersected with the clip when it is ended.
+	path     *model.PdfRectangle
+	clipPath bool
+
+	// Text matrix and text line matrix of the current text object.
+	tm  transform.Matrix
+	tlm transform.Matrix
+
+	// State of the processing of Type3 glyph descriptions, see type3.go.
+	type3 *type3Context
+	glyph glyphState
+
+	// Validation policy and counts of invalid operations, see validation.go, and nesting level of
+	// compatibility sections (BX ... EX).
+	validation         *validationContext
+	compatibilityLevel int
+
+	// Context checked before each operation, see ProcessWithContext.
+	ctx context.Context
 }
 
 // HandlerFunc is the function syntax that the ContentStreamProcessor handler must implement.
@@ -99,6 +149,8 @@ func NewContentStreamProcessor(ops []*ContentStreamOperation) *ContentStreamProc
 	csp.handlers = []handlerEntry{}
 	csp.currentIndex = 0
 	csp.operations = ops
+	csp.type3 = &type3Context{}
+	csp.validation = &validationContext{}
 
 	return &csp
 }
@@ -125,9 +177,11 @@ func (proc *ContentStreamProcessor) getColorspace(name string, resources *model.
 	}
 
 	// Next check the colorspace dictionary.
-	cs, has := resources.GetColorspaceByName(core.PdfObjectName(name))
-	if has {
-		return cs, nil
+	if resources != nil {
+		cs, has := resources.GetColorspaceByName(core.PdfObjectName(name))
+		if has {
+			return cs, nil
+		}
 	}
 
 	// Lastly check other potential colormaps.
@@ -159,16 +213,14 @@ func (proc *ContentStreamProcessor) getInitialColor(cs model.PdfColorspace) (mod
 	case *model.PdfColorspaceCalRGB:
 		return model.NewPdfColorCalRGB(0.0, 0.0, 0.0), nil
 	case *model.PdfColorspaceLab:
-		l := 0.0
+		// The a* and b* components are zero, clipped to the Range of the colorspace.
 		a := 0.0
 		b := 0.0
-		if cs.Range[0] > 0 {
-			l = cs.Range[0]
+		if len(cs.Range) == 4 {
+			a = math.Min(math.Max(a, cs.Range[0]), cs.Range[1])
+			b = math.Min(math.Max(b, cs.Range[2]), cs.Range[3])
 		}
-		if cs.Range[2] > 0 {
-			a = cs.Range[2]
-		}
-		return model.NewPdfColorLab(l, a, b), nil
+		return model.NewPdfColorLab(0, a, b), nil
 	case *model.PdfColorspaceICCBased:
 		if cs.Alternate == nil {
 			// Alternate not defined.
@@ -192,17 +244,29 @@ func (proc *ContentStreamProcessor) getInitialColor(cs model.PdfColorspace) (mod
 		if cs.Base == nil {
 			return nil, errors.New("indexed base not specified")
 		}
-		return proc.getInitialColor(cs.Base)
+		// The initial color is the color of index 0.
+		color, err := cs.ColorFromFloats([]float64{0})
+		if err != nil {
+			common.Log.Debug("ERROR: invalid indexed colorspace lookup: %v", err)
+			return proc.getInitialColor(cs.Base)
+		}
+		return color, nil
 	case *model.PdfColorspaceSpecialSeparation:
-		if cs.AlternateSpace == nil {
+		if cs.AlternateSpace == nil || cs.TintTransform == nil {
 			return nil, errors.New("alternate space not specified")
 		}
-		return proc.getInitialColor(cs.AlternateSpace)
+		// The initial tint is 1.0.
+		return cs.ColorFromFloats([]float64{1})
 	case *model.PdfColorspaceDeviceN:
-		if cs.AlternateSpace == nil {
+		if cs.AlternateSpace == nil || cs.TintTransform == nil {
 			return nil, errors.New("alternate space not specified")
 		}
-		return proc.getInitialColor(cs.AlternateSpace)
+		// The initial tints are 1.0.
+		tints := make([]float64, cs.GetNumComponents())
+		for i := range tints {
+			tints[i] = 1
+		}
+		return cs.ColorFromFloats(tints)
 	case *model.PdfColorspaceSpecialPattern:
 		// FIXME/check: A pattern does not have an initial color...
 		return nil, nil
@@ -214,19 +278,55 @@ func (proc *ContentStreamProcessor) getInitialColor(cs model.PdfColorspace) (mod
 
 // Process processes the entire list of operations. Maintains the graphics state that is passed to any
 // handlers that are triggered during processing (either on specific operators or all).
+// The operations with invalid operands are handled according to the validation policy of the
+// processor, see SetValidationPolicy.
 func (proc *ContentStreamProcessor) Process(resources *model.PdfPageResources) error {
+	return proc.ProcessWithContext(context.Background(), resources)
+}
+
+// ProcessWithContext processes the operations as Process, checking `ctx` before each operation,
+// including those of the Type3 glyph descriptions. Returns an error wrapping the error of `ctx`
+// if it is cancelled or its deadline is exceeded.
+func (proc *ContentStreamProcessor) ProcessWithContext(ctx context.Context, resources *model.PdfPageResources) error {
+	proc.ctx = ctx
 	// Initialize graphics state
 	proc.graphicsState.ColorspaceStroking = model.NewPdfColorspaceDeviceGray()
 	proc.graphicsState.ColorspaceNonStroking = model.NewPdfColorspaceDeviceGray()
 	proc.graphicsState.ColorStroking = model.NewPdfColorDeviceGray(0)
 	proc.graphicsState.ColorNonStroking = model.NewPdfColorDeviceGray(0)
 	proc.graphicsState.CTM = transform.IdentityMatrix()
+	proc.graphicsState.Clip = nil
+	proc.graphicsState.AlphaStroking = 1
+	proc.graphicsState.AlphaNonStroking = 1
+	proc.graphicsState.BlendMode = "Normal"
+	proc.graphicsState.SoftMask = nil
+	proc.graphicsState.text = newTextState()
+	return proc.process(resources)
+}
 
+// process processes the operations from the current graphics state.
+func (proc *ContentStreamProcessor) process(resources *model.PdfPageResources) error {
 	for _, op := range proc.operations {
-		var err error
+		if proc.ctx != nil {
+			if err := proc.ctx.Err(); err != nil {
+				return fmt.Errorf("content stream processing cancelled: %w", err)
+			}
+		}
+		op, err := proc.validate(op)
+		if err != nil {
+			return err
+		}
+		if op == nil {
+			continue
+		}
 
 		// Internal handling.
-		switch op.Operand {
+		operand := op.Operand
+		if proc.glyph.uncolored && isColorOperator(operand) {
+			// The color operators are ignored in the glyph descriptions of uncolored glyphs.
+			operand = ""
+		}
+		switch operand {
 		case "q":
 			proc.graphicsStack.Push(proc.graphicsState)
 		case "Q":
@@ -263,11 +363,50 @@ func (proc *ContentStreamProcessor) Process(resources *model.PdfPageResources) e
 			err = proc.handleCommand_k(op, resources)
 		case "cm":
 			err = proc.handleCommand_cm(op, resources)
+		case "gs":
+			err = proc.handleCommand_gs(op, resources)
+
+		// Path construction, clipping and painting operations (Tables 59-61 pp. 133-135).
+		case "m", "l", "c", "v", "y":
+			proc.handlePathPoints(op)
+		case "re":
+			proc.handleCommand_re(op)
+		case "W", "W*":
+			proc.clipPath = true
+		case "S", "s", "f", "F", "f*", "B", "B*", "b", "b*", "n":
+			proc.endPath(op.Operand != "n")
+
+		// Text object and text state operators (Tables 105-108 pp. 243-251).
+		case "BT":
+			proc.tm = transform.IdentityMatrix()
+			proc.tlm = transform.IdentityMatrix()
+		case "Tf":
+			proc.handleCommand_Tf(op, resources)
+		case "Tc", "Tw", "Tz", "TL", "Ts":
+			proc.handleTextStateCommand(op)
+		case "Td", "TD", "Tm", "T*":
+			proc.handleTextPositioningCommand(op)
+		case "Tj", "TJ", "'", `"`:
+			err = proc.handleTextShowingCommand(op, resources)
+
+		// Type3 glyph descriptions.
+		case "d0", "d1":
+			proc.handleGlyphMetrics(op)
+		case "BI":
+			proc.paintUnitSquare()
+		case "Do":
+			proc.handleCommand_Do(op, resources)
 		}
 		if err != nil {
 			common.Log.Debug("Processor handling error (%s): %v", op.Operand, err)
 			common.Log.Debug("Operand: %#v", op.Operand)
-			return err
+			if proc.validation.policy == ValidationStrict {
+				return err
+			}
+			// Operations whose operands are invalid in the current graphics state, e.g. colors
+			// with the wrong number of components, are skipped like invalid operations.
+			proc.validation.stats.Skipped++
+			continue
 		}
 
 		// Check if have external handler also, and process if so.
@@ -288,6 +427,82 @@ func (proc *ContentStreamProcessor) Process(resources *model.PdfPageResources) e
 	return nil
 }
 
+// addPathPoint adds point (`x`, `y`), transformed by the CTM, to the current path.
+func (proc *ContentStreamProcessor) addPathPoint(x, y float64) {
+	x, y = proc.graphicsState.Transform(x, y)
+	if proc.path == nil {
+		proc.path = &model.PdfRectangle{Llx: x, Lly: y, Urx: x, Ury: y}
+		return
+	}
+	proc.path = &model.PdfRectangle{
+		Llx: math.Min(proc.path.Llx, x),
+		Lly: math.Min(proc.path.Lly, y),
+		Urx: math.Max(proc.path.Urx, x),
+		Ury: math.Max(proc.path.Ury, y),
+	}
+}
+
+// handlePathPoints adds the points of path construction operators m, l, c, v and y to the
+// current path. The bounding box of the control points of curves contains the curves.
+func (proc *ContentStreamProcessor) handlePathPoints(op *ContentStreamOperation) {
+	points, err := core.GetNumbersAsFloat(op.Params)
+	if err != nil || len(points)%2 != 0 {
+		common.Log.Debug("ERROR: Invalid parameters for %s: %v", op.Operand, op.Params)
+		return
+	}
+	for i := 0; i < len(points); i += 2 {
+		proc.addPathPoint(points[i], points[i+1])
+	}
+}
+
+// re: Append a rectangle to the current path.
+// x y width height re
+func (proc *ContentStreamProcessor) handleCommand_re(op *ContentStreamOperation) {
+	f, err := core.GetNumbersAsFloat(op.Params)
+	if err != nil || len(f) != 4 {
+		common.Log.Debug("ERROR: Invalid parameters for re: %v", op.Params)
+		return
+	}
+	x, y, w, h := f[0], f[1], f[2], f[3]
+	proc.addPathPoint(x, y)
+	proc.addPathPoint(x+w, y)
+	proc.addPathPoint(x+w, y+h)
+	proc.addPathPoint(x, y+h)
+}
+
+// endPath ends the current path with a path painting operator, which paints the path if `paint`
+// is true. If the W or W* operator was used, the clip is intersected with the path.
+func (proc *ContentStreamProcessor) endPath(paint bool) {
+	if paint && proc.path != nil {
+		proc.addPainted(*proc.path)
+	}
+	if proc.clipPath {
+		clip := proc.graphicsState.Clip
+		path := proc.path
+		if path == nil {
+			// An empty clipping path clips everything.
+			path = &model.PdfRectangle{}
+			if clip != nil {
+				path = &model.PdfRectangle{Llx: clip.Llx, Lly: clip.Lly, Urx: clip.Llx, Ury: clip.Lly}
+			}
+		}
+		if clip != nil {
+			path = &model.PdfRectangle{
+				Llx: math.Max(clip.Llx, path.Llx),
+				Lly: math.Max(clip.Lly, path.Lly),
+				Urx: math.Min(clip.Urx, path.Urx),
+				Ury: math.Min(clip.Ury, path.Ury),
+			}
+			// Empty intersections are represented by an empty rectangle.
+			path.Urx = math.Max(path.Llx, path.Urx)
+			path.Ury = math.Max(path.Lly, path.Ury)
+		}
+		proc.graphicsState.Clip = path
+	}
+	proc.path = nil
+	proc.clipPath = false
+}
+
 // CS: Set the current color space for stroking operations.
 func (proc *ContentStreamProcessor) handleCommand_CS(op *ContentStreamOperation, resources *model.PdfPageResources) error {
 	if len(op.Params) < 1 {
@@ -317,6 +532,7 @@ func (proc *ContentStreamProcessor) handleCommand_CS(op *ContentStreamOperation,
 		return err
 	}
 	proc.graphicsState.ColorStroking = color
+	proc.graphicsState.PatternStroking = nil
 
 	return nil
 }
@@ -350,6 +566,7 @@ func (proc *ContentStreamProcessor) handleCommand_cs(op *ContentStreamOperation,
 		return err
 	}
 	proc.graphicsState.ColorNonStroking = color
+	proc.graphicsState.PatternNonStroking = nil
 
 	return nil
 }
@@ -398,6 +615,7 @@ func (proc *ContentStreamProcessor) handleCommand_SCN(op *ContentStreamOperation
 	}
 
 	proc.graphicsState.ColorStroking = color
+	proc.graphicsState.PatternStroking = proc.getPattern(color, resources)
 
 	return nil
 }
@@ -443,10 +661,26 @@ func (proc *ContentStreamProcessor) handleCommand_scn(op *ContentStreamOperation
 	}
 
 	proc.graphicsState.ColorNonStroking = color
+	proc.graphicsState.PatternNonStroking = proc.getPattern(color, resources)
 
 	return nil
 }
 
+// getPattern returns the pattern of `color`, if it is a pattern color, looked up in `resources`.
+func (proc *ContentStreamProcessor) getPattern(color model.PdfColor,
+	resources *model.PdfPageResources) *model.PdfPattern {
+	patternColor, ok := color.(*model.PdfColorPattern)
+	if !ok || resources == nil {
+		return nil
+	}
+	pattern, has := resources.GetPatternByName(patternColor.PatternName)
+	if !has {
+		common.Log.Debug("Pattern %s not found in resources", patternColor.PatternName)
+		return nil
+	}
+	return pattern
+}
+
 // G: Set the stroking colorspace to DeviceGray, and the color to the specified graylevel (range [0-1]).
 // gray G
 func (proc *ContentStreamProcessor) handleCommand_G(op *ContentStreamOperation, resources *model.PdfPageResources) error {
@@ -464,6 +698,7 @@ func (proc *ContentStreamProcessor) handleCommand_G(op *ContentStreamOperation,
 
 	proc.graphicsState.ColorspaceStroking = cs
 	proc.graphicsState.ColorStroking = color
+	proc.graphicsState.PatternStroking = nil
 
 	return nil
 }
@@ -486,6 +721,7 @@ func (proc *ContentStreamProcessor) handleCommand_g(op *ContentStreamOperation,
 
 	proc.graphicsState.ColorspaceNonStroking = cs
 	proc.graphicsState.ColorNonStroking = color
+	proc.graphicsState.PatternNonStroking = nil
 
 	return nil
 }
@@ -507,6 +743,7 @@ func (proc *ContentStreamProcessor) handleCommand_RG(op *ContentStreamOperation,
 
 	proc.graphicsState.ColorspaceStroking = cs
 	proc.graphicsState.ColorStroking = color
+	proc.graphicsState.PatternStroking = nil
 
 	return nil
 }
@@ -527,6 +764,7 @@ func (proc *ContentStreamProcessor) handleCommand_rg(op *ContentStreamOperation,
 
 	proc.graphicsState.ColorspaceNonStroking = cs
 	proc.graphicsState.ColorNonStroking = color
+	proc.graphicsState.PatternNonStroking = nil
 
 	return nil
 }
@@ -548,6 +786,7 @@ func (proc *ContentStreamProcessor) handleCommand_K(op *ContentStreamOperation,
 
 	proc.graphicsState.ColorspaceStroking = cs
 	proc.graphicsState.ColorStroking = color
+	proc.graphicsState.PatternStroking = nil
 
 	return nil
 }
@@ -568,6 +807,7 @@ func (proc *ContentStreamProcessor) handleCommand_k(op *ContentStreamOperation,
 
 	proc.graphicsState.ColorspaceNonStroking = cs
 	proc.graphicsState.ColorNonStroking = color
+	proc.graphicsState.PatternNonStroking = nil
 
 	return nil
 }
@@ -589,3 +829,70 @@ func (proc *ContentStreamProcessor) handleCommand_cm(op *ContentStreamOperation,
 
 	return nil
 }
+
+// gs: Set the parameters of the graphics state dictionary named by the operand in the ExtGState
+// resources. Only the transparency parameters are tracked.
+func (proc *ContentStreamProcessor) handleCommand_gs(op *ContentStreamOperation,
+	resources *model.PdfPageResources) error {
+	if len(op.Params) != 1 {
+		common.Log.Debug("ERROR: Invalid number of parameters for gs: %d", len(op.Params))
+		return errors.New("invalid number of parameters")
+	}
+	name, ok := core.GetName(op.Params[0])
+	if !ok {
+		common.Log.Debug("ERROR: gs command with invalid parameter, skipping over")
+		return errors.New("type check error")
+	}
+	if resources == nil {
+		common.Log.Debug("ERROR: gs %s without resources", *name)
+		return nil
+	}
+	obj, has := resources.GetExtGState(*name)
+	if !has {
+		common.Log.Debug("ERROR: ExtGState %s not found", *name)
+		return nil
+	}
+	dict, ok := core.GetDict(obj)
+	if !ok {
+		common.Log.Debug("ERROR: ExtGState %s not a dictionary (%T)", *name, obj)
+		return nil
+	}
+
+	gs := &proc.graphicsState
+	if obj := dict.Get("CA"); obj != nil {
+		if alpha, err := core.GetNumberAsFloat(obj); err == nil {
+			gs.AlphaStroking = math.Max(0, math.Min(1, alpha))
+		} else {
+			common.Log.Debug("ERROR: Invalid CA in ExtGState %s: %v", *name, obj)
+		}
+	}
+	if obj := dict.Get("ca"); obj != nil {
+		if alpha, err := core.GetNumberAsFloat(obj); err == nil {
+			gs.AlphaNonStroking = math.Max(0, math.Min(1, alpha))
+		} else {
+			common.Log.Debug("ERROR: Invalid ca in ExtGState %s: %v", *name, obj)
+		}
+	}
+	if obj := dict.Get("BM"); obj != nil {
+		// BM may be an array of blend modes, of which the first one recognized is used.
+		if arr, ok := core.GetArray(obj); ok && arr.Len() > 0 {
+			obj = arr.Get(0)
+		}
+		if mode, ok := core.GetName(obj); ok {
+			gs.BlendMode = *mode
+		} else {
+			common.Log.Debug("ERROR: Invalid BM in ExtGState %s: %v", *name, obj)
+		}
+	}
+	if obj := dict.Get("SMask"); obj != nil {
+		if smaskName, ok := core.GetName(obj); ok && *smaskName == "None" {
+			gs.SoftMask = nil
+		} else if smask, err := model.NewPdfSoftMaskFromPdfObject(obj); err == nil {
+			gs.SoftMask = smask
+			gs.SoftMaskCTM = gs.CTM
+		} else {
+			common.Log.Debug("ERROR: Invalid SMask in ExtGState %s: %v", *name, err)
+		}
+	}
+	return nil
+}
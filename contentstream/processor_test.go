@@ -0,0 +1,331 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package contentstream
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// TestProcessorClip tests the tracking of the clip in the graphics state.
+func TestProcessorClip(t *testing.T) {
+	contents := `
+		q
+		10 10 100 100 re W n
+		2 0 0 2 0 0 cm
+		20 20 m 40 30 l 40 80 l h W* n
+		0 0 0 rg
+		q 0 0 10 10 re W n 0 g Q
+		1 g
+		Q
+		0.5 g
+		q 200 200 10 10 re W S 0 0 1 rg Q
+		q W n 1 0 0 rg Q
+	`
+	operations, err := NewContentStreamParser(contents).Parse()
+	require.NoError(t, err)
+
+	var clips []*model.PdfRectangle
+	processor := NewContentStreamProcessor(*operations)
+	processor.AddHandler(HandlerConditionEnumAllOperands, "",
+		func(op *ContentStreamOperation, gs GraphicsState, resources *model.PdfPageResources) error {
+			switch op.Operand {
+			case "g", "rg":
+				clips = append(clips, gs.Clip)
+			}
+			return nil
+		})
+	require.NoError(t, processor.Process(model.NewPdfPageResources()))
+
+	expected := []*model.PdfRectangle{
+		// The clip is intersected with the transformed path.
+		{Llx: 40, Lly: 40, Urx: 80, Ury: 110},
+		// Empty intersections clip everything.
+		{Llx: 40, Lly: 40, Urx: 40, Ury: 40},
+		// The clip is restored by Q.
+		{Llx: 40, Lly: 40, Urx: 80, Ury: 110},
+		nil,
+		{Llx: 200, Lly: 200, Urx: 210, Ury: 210},
+		// Empty paths clip everything.
+		{},
+	}
+	require.Equal(t, expected, clips)
+}
+
+// TestProcessorTransparency tests the tracking of the transparency parameters set with the gs
+// operator in the graphics state.
+func TestProcessorTransparency(t *testing.T) {
+	resources := model.NewPdfPageResources()
+	gs0 := core.MakeDict()
+	gs0.Set("CA", core.MakeFloat(0.25))
+	gs0.Set("ca", core.MakeFloat(0.5))
+	gs0.Set("BM", core.MakeArray(core.MakeName("Multiply"), core.MakeName("Normal")))
+	require.NoError(t, resources.AddExtGState("GS0", gs0))
+	gs1 := core.MakeDict()
+	gs1.Set("ca", core.MakeInteger(2))
+	gs1.Set("SMask", core.MakeName("None"))
+	require.NoError(t, resources.AddExtGState("GS1", gs1))
+
+	contents := `
+		q /GS0 gs 0 g
+		q /GS1 gs 0 g Q
+		0 g Q
+		0 g
+	`
+	operations, err := NewContentStreamParser(contents).Parse()
+	require.NoError(t, err)
+
+	type params struct {
+		alphaStroking, alphaNonStroking float64
+		blendMode                       core.PdfObjectName
+	}
+	var states []params
+	processor := NewContentStreamProcessor(*operations)
+	processor.AddHandler(HandlerConditionEnumOperand, "g",
+		func(op *ContentStreamOperation, gs GraphicsState, resources *model.PdfPageResources) error {
+			require.Nil(t, gs.SoftMask)
+			states = append(states, params{gs.AlphaStroking, gs.AlphaNonStroking, gs.BlendMode})
+			return nil
+		})
+	require.NoError(t, processor.Process(resources))
+
+	expected := []params{
+		{0.25, 0.5, "Multiply"},
+		// Alphas are clipped to [0, 1].
+		{0.25, 1, "Multiply"},
+		{0.25, 0.5, "Multiply"},
+		{1, 1, "Normal"},
+	}
+	require.Equal(t, expected, states)
+}
+
+// makeType3Font returns a Type3 font with glyph space units of 1/100 text space units, whose
+// glyph A is a 80x80 square painted in red (d0), and glyph B an uncolored 50x100 triangle (d1).
+// `withWidths` adds a Widths array matching the widths set by d0 and d1.
+func makeType3Font(t *testing.T, withWidths bool) *core.PdfObjectDictionary {
+	square, err := core.MakeStream([]byte("100 0 d0 1 0 0 rg 0 0 m 80 0 l 80 80 l 0 80 l f"), nil)
+	require.NoError(t, err)
+	triangle, err := core.MakeStream([]byte("50 0 0 0 50 100 d1 0 1 0 rg 0 0 m 50 0 l 25 100 l f"), nil)
+	require.NoError(t, err)
+	charProcs := core.MakeDict()
+	charProcs.Set("A", square)
+	charProcs.Set("B", triangle)
+	encoding := core.MakeDict()
+	encoding.Set("Differences", core.MakeArray(core.MakeInteger(65), core.MakeName("A"), core.MakeName("B")))
+
+	font := core.MakeDict()
+	font.Set("Type", core.MakeName("Font"))
+	font.Set("Subtype", core.MakeName("Type3"))
+	font.Set("FontBBox", core.MakeArrayFromIntegers([]int{0, 0, 100, 100}))
+	font.Set("FontMatrix", core.MakeArrayFromFloats([]float64{0.01, 0, 0, 0.01, 0, 0}))
+	font.Set("CharProcs", charProcs)
+	font.Set("Encoding", encoding)
+	font.Set("FirstChar", core.MakeInteger(65))
+	font.Set("LastChar", core.MakeInteger(66))
+	if withWidths {
+		font.Set("Widths", core.MakeArrayFromIntegers([]int{100, 50}))
+	}
+	font.Set("Resources", core.MakeDict())
+	return font
+}
+
+// TestProcessorType3 tests that the glyph descriptions of Type3 fonts are executed with the
+// handlers, positioned by the widths set by d0 and d1.
+func TestProcessorType3(t *testing.T) {
+	resources := model.NewPdfPageResources()
+	require.NoError(t, resources.SetFontByName("T3", makeType3Font(t, false)))
+	require.NoError(t, resources.SetFontByName("F1",
+		model.NewStandard14FontMustCompile(model.HelveticaName).ToPdfObject()))
+	contents := `
+		0 0 1 rg
+		BT /T3 20 Tf 100 500 Td (ABA) Tj
+		2 Tc [(B) -500 (A)] TJ
+		/F1 10 Tf (AB) Tj /T3 10 Tf 50 Tz (A) Tj ET
+		q 2 0 0 2 0 0 cm BT /T3 20 Tf 100 Tz 10 TL 0 20 Td (A) ' ET Q
+	`
+	operations, err := NewContentStreamParser(contents).Parse()
+	require.NoError(t, err)
+
+	type fill struct {
+		bbox  model.PdfRectangle
+		color model.PdfColor
+	}
+	var fills []fill
+	var operators []string
+	processor := NewContentStreamProcessor(*operations)
+	processor.AddHandler(HandlerConditionEnumAllOperands, "",
+		func(op *ContentStreamOperation, gs GraphicsState, resources *model.PdfPageResources) error {
+			if !gs.InCharProc {
+				operators = append(operators, op.Operand)
+				return nil
+			}
+			if op.Operand == "m" {
+				x, y := gs.Transform(0, 0)
+				x1, y1 := gs.Transform(80, 80)
+				fills = append(fills, fill{model.PdfRectangle{Llx: x, Lly: y, Urx: x1, Ury: y1},
+					gs.ColorNonStroking})
+			}
+			return nil
+		})
+	require.NoError(t, processor.Process(resources))
+	require.Equal(t, []string{"rg", "BT", "Tf", "Td", "Tj", "Tc", "TJ", "Tf", "Tj", "Tf", "Tz", "Tj",
+		"ET", "q", "cm", "BT", "Tf", "Tz", "TL", "Td", "'", "ET", "Q"}, operators)
+
+	red := model.NewPdfColorDeviceRGB(1, 0, 0)
+	blue := model.NewPdfColorDeviceRGB(0, 0, 1)
+	square := func(x, y, scaleX, scaleY float64) fill {
+		return fill{model.PdfRectangle{Llx: x, Lly: y, Urx: x + 80*scaleX, Ury: y + 80*scaleY}, red}
+	}
+	triangle := func(x, y float64) fill {
+		// The color set in uncolored glyphs is ignored.
+		return fill{model.PdfRectangle{Llx: x, Lly: y, Urx: x + 16, Ury: y + 16}, blue}
+	}
+	expected := []fill{
+		// A is 20 points wide and B 10 points wide.
+		square(100, 500, 0.2, 0.2),
+		triangle(120, 500),
+		square(130, 500, 0.2, 0.2),
+		// The character spacing and the TJ adjustment are applied.
+		triangle(150, 500),
+		square(150+10+2+10, 500, 0.2, 0.2),
+		// The Helvetica glyphs are 6.67 points wide. The horizontal scaling halves the width of
+		// the glyphs.
+		square(172+20+2+6.67+2+6.67+2, 500, 0.1*0.5, 0.1),
+		// The CTM and the leading are applied.
+		square(0, 20, 0.4, 0.4),
+	}
+	require.Len(t, fills, len(expected))
+	for i, f := range fills {
+		require.InDelta(t, expected[i].bbox.Llx, f.bbox.Llx, 1e-6, "fill %d", i)
+		require.InDelta(t, expected[i].bbox.Lly, f.bbox.Lly, 1e-6, "fill %d", i)
+		require.InDelta(t, expected[i].bbox.Urx, f.bbox.Urx, 1e-6, "fill %d", i)
+		require.InDelta(t, expected[i].bbox.Ury, f.bbox.Ury, 1e-6, "fill %d", i)
+		require.Equal(t, expected[i].color, f.color, "fill %d", i)
+	}
+
+	// The metrics of the glyphs are those set by d0 and d1, in glyph space.
+	font, err := model.NewPdfFontFromPdfObject(makeType3Font(t, false))
+	require.NoError(t, err)
+	metrics, ok := GetType3GlyphMetrics(font, 'A')
+	require.True(t, ok)
+	require.Equal(t, Type3GlyphMetrics{Width: 100, BBox: &model.PdfRectangle{Urx: 80, Ury: 80}}, metrics)
+	metrics, ok = GetType3GlyphMetrics(font, 'B')
+	require.True(t, ok)
+	require.Equal(t, Type3GlyphMetrics{Width: 50, BBox: &model.PdfRectangle{Urx: 50, Ury: 100}}, metrics)
+	_, ok = GetType3GlyphMetrics(font, 'C')
+	require.False(t, ok)
+}
+
+// TestProcessorValidation tests the handling of the operations with invalid operands according to
+// the validation policy of the processor.
+func TestProcessorValidation(t *testing.T) {
+	contents := `
+		1 0 0 2 0 0 cm
+		0.5 g
+		2.0 j
+		5 0.2 0.4 0.6 rg
+		(x) g
+		0 0 1 1 re
+		foo
+		BX 1 2 bar EX
+		0 0 0 1 sc
+		f
+	`
+	operations, err := NewContentStreamParser(contents).Parse()
+	require.NoError(t, err)
+
+	testcases := []struct {
+		policy   ValidationPolicy
+		operands []string
+		color    model.PdfColor
+		stats    ValidationStats
+	}{
+		{
+			policy:   ValidationSkipOperation,
+			operands: []string{"cm", "g", "re", "BX", "EX", "f"},
+			color:    model.NewPdfColorDeviceGray(0.5),
+			stats:    ValidationStats{Skipped: 5},
+		},
+		{
+			policy:   ValidationBestEffort,
+			operands: []string{"cm", "g", "j", "rg", "re", "BX", "EX", "f"},
+			color:    model.NewPdfColorDeviceRGB(0.2, 0.4, 0.6),
+			stats:    ValidationStats{Skipped: 3, Coerced: 2},
+		},
+	}
+	for _, tc := range testcases {
+		var operands []string
+		var color model.PdfColor
+		processor := NewContentStreamProcessor(*operations)
+		processor.SetValidationPolicy(tc.policy)
+		processor.AddHandler(HandlerConditionEnumAllOperands, "",
+			func(op *ContentStreamOperation, gs GraphicsState, resources *model.PdfPageResources) error {
+				operands = append(operands, op.Operand)
+				switch op.Operand {
+				case "j":
+					// The operands are coerced to the types the operator requires.
+					require.Equal(t, []core.PdfObject{core.MakeInteger(2)}, op.Params)
+				case "f":
+					color = gs.ColorNonStroking
+				}
+				return nil
+			})
+		require.NoError(t, processor.Process(model.NewPdfPageResources()))
+		require.Equal(t, tc.operands, operands)
+		require.Equal(t, tc.color, color)
+		require.Equal(t, tc.stats, processor.ValidationStats())
+	}
+
+	processor := NewContentStreamProcessor(*operations)
+	processor.SetValidationPolicy(ValidationStrict)
+	err = processor.Process(model.NewPdfPageResources())
+	require.True(t, errors.Is(err, ErrInvalidOperand), "err=%v", err)
+}
+
+// TestProcessWithContext checks that processing stops at the operation where the context is
+// cancelled.
+func TestProcessWithContext(t *testing.T) {
+	contents := strings.Repeat("BT /F1 12 Tf 10 10 Td (text) Tj ET\n", 50000)
+	operations, err := NewContentStreamParser(contents).Parse()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var count int
+	processor := NewContentStreamProcessor(*operations)
+	processor.AddHandler(HandlerConditionEnumAllOperands, "",
+		func(op *ContentStreamOperation, gs GraphicsState, resources *model.PdfPageResources) error {
+			count++
+			return nil
+		})
+	err = processor.ProcessWithContext(ctx, model.NewPdfPageResources())
+	require.True(t, errors.Is(err, context.Canceled), "err=%v", err)
+	require.Zero(t, count)
+
+	// Cancelling from a handler stops before the next operation.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	count = 0
+	processor = NewContentStreamProcessor(*operations)
+	processor.AddHandler(HandlerConditionEnumAllOperands, "",
+		func(op *ContentStreamOperation, gs GraphicsState, resources *model.PdfPageResources) error {
+			count++
+			if count == 100 {
+				cancel()
+			}
+			return nil
+		})
+	err = processor.ProcessWithContext(ctx, model.NewPdfPageResources())
+	require.True(t, errors.Is(err, context.Canceled), "err=%v", err)
+	require.Equal(t, 100, count)
+}
@@ -0,0 +1,215 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package contentstream
+
+import (
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// textState holds the text state parameters of the graphics state (Table 104 p. 243), which the
+// processor tracks to position the glyphs of Type3 fonts.
+type textState struct {
+	font         *model.PdfFont
+	fontSize     float64
+	charSpacing  float64
+	wordSpacing  float64
+	horizScaling float64 // In percent.
+	leading      float64
+	rise         float64
+}
+
+// newTextState returns the initial text state parameters.
+func newTextState() textState {
+	return textState{horizScaling: 100}
+}
+
+// Tf: Set the text font and size. The font is unset if it cannot be loaded.
+func (proc *ContentStreamProcessor) handleCommand_Tf(op *ContentStreamOperation, resources *model.PdfPageResources) {
+	text := &proc.graphicsState.text
+	if len(op.Params) != 2 {
+		common.Log.Debug("ERROR: Invalid number of parameters for Tf: %d", len(op.Params))
+		return
+	}
+	name, ok := core.GetName(op.Params[0])
+	if !ok {
+		common.Log.Debug("ERROR: Invalid font name for Tf: %v", op.Params[0])
+		return
+	}
+	size, err := core.GetNumberAsFloat(op.Params[1])
+	if err != nil {
+		common.Log.Debug("ERROR: Invalid font size for Tf: %v", op.Params[1])
+		return
+	}
+	text.fontSize = size
+	text.font = nil
+	if resources == nil {
+		common.Log.Debug("ERROR: Tf %s without resources", *name)
+		return
+	}
+	fontObj, has := resources.GetFontByName(*name)
+	if !has {
+		common.Log.Debug("ERROR: Font %s not found", *name)
+		return
+	}
+	text.font = proc.type3.loadFont(fontObj)
+}
+
+// handleTextStateCommand handles the text state operators Tc, Tw, Tz, TL and Ts, which set a
+// single text state parameter.
+func (proc *ContentStreamProcessor) handleTextStateCommand(op *ContentStreamOperation) {
+	if len(op.Params) != 1 {
+		common.Log.Debug("ERROR: Invalid number of parameters for %s: %d", op.Operand, len(op.Params))
+		return
+	}
+	val, err := core.GetNumberAsFloat(op.Params[0])
+	if err != nil {
+		common.Log.Debug("ERROR: Invalid parameter for %s: %v", op.Operand, op.Params[0])
+		return
+	}
+	text := &proc.graphicsState.text
+	switch op.Operand {
+	case "Tc":
+		text.charSpacing = val
+	case "Tw":
+		text.wordSpacing = val
+	case "Tz":
+		text.horizScaling = val
+	case "TL":
+		text.leading = val
+	case "Ts":
+		text.rise = val
+	}
+}
+
+// handleTextPositioningCommand handles the text positioning operators Td, TD, Tm and T*
+// (Table 108 p. 249).
+func (proc *ContentStreamProcessor) handleTextPositioningCommand(op *ContentStreamOperation) {
+	if op.Operand == "T*" {
+		proc.nextLine()
+		return
+	}
+	f, err := core.GetNumbersAsFloat(op.Params)
+	if err != nil {
+		common.Log.Debug("ERROR: Invalid parameters for %s: %v", op.Operand, op.Params)
+		return
+	}
+	switch op.Operand {
+	case "Td", "TD":
+		if len(f) != 2 {
+			common.Log.Debug("ERROR: Invalid number of parameters for %s: %d", op.Operand, len(f))
+			return
+		}
+		if op.Operand == "TD" {
+			proc.graphicsState.text.leading = -f[1]
+		}
+		proc.moveText(f[0], f[1])
+	case "Tm":
+		if len(f) != 6 {
+			common.Log.Debug("ERROR: Invalid number of parameters for Tm: %d", len(f))
+			return
+		}
+		proc.tlm = transform.NewMatrix(f[0], f[1], f[2], f[3], f[4], f[5])
+		proc.tm = proc.tlm
+	}
+}
+
+// moveText moves to the start of the next line, offset from the start of the current line by
+// (`tx`, `ty`).
+func (proc *ContentStreamProcessor) moveText(tx, ty float64) {
+	proc.tlm.Concat(transform.TranslationMatrix(tx, ty))
+	proc.tm = proc.tlm
+}
+
+// nextLine moves to the start of the next line.
+func (proc *ContentStreamProcessor) nextLine() {
+	proc.moveText(0, -proc.graphicsState.text.leading)
+}
+
+// handleTextShowingCommand handles the text showing operators Tj, TJ, ' and " (Table 109
+// p. 250), advancing the text matrix by the glyphs shown and executing the glyph descriptions of
+// Type3 fonts.
+func (proc *ContentStreamProcessor) handleTextShowingCommand(op *ContentStreamOperation,
+	resources *model.PdfPageResources) error {
+	switch op.Operand {
+	case "Tj", "'":
+		if len(op.Params) != 1 {
+			common.Log.Debug("ERROR: Invalid number of parameters for %s: %d", op.Operand, len(op.Params))
+			return nil
+		}
+		if op.Operand == "'" {
+			proc.nextLine()
+		}
+		return proc.showText(op.Params[0], resources)
+	case `"`:
+		if len(op.Params) != 3 {
+			common.Log.Debug("ERROR: Invalid number of parameters for \": %d", len(op.Params))
+			return nil
+		}
+		spacing, err := core.GetNumbersAsFloat(op.Params[:2])
+		if err != nil {
+			common.Log.Debug("ERROR: Invalid parameters for \": %v", op.Params)
+			return nil
+		}
+		text := &proc.graphicsState.text
+		text.wordSpacing, text.charSpacing = spacing[0], spacing[1]
+		proc.nextLine()
+		return proc.showText(op.Params[2], resources)
+	case "TJ":
+		if len(op.Params) != 1 {
+			common.Log.Debug("ERROR: Invalid number of parameters for TJ: %d", len(op.Params))
+			return nil
+		}
+		arr, ok := core.GetArray(op.Params[0])
+		if !ok {
+			common.Log.Debug("ERROR: Invalid parameter for TJ: %v", op.Params[0])
+			return nil
+		}
+		text := proc.graphicsState.text
+		for _, obj := range arr.Elements() {
+			if adjustment, err := core.GetNumberAsFloat(obj); err == nil {
+				tx := -adjustment / 1000 * text.fontSize * text.horizScaling / 100
+				proc.tm.Concat(transform.TranslationMatrix(tx, 0))
+				continue
+			}
+			if err := proc.showText(obj, resources); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// showText shows the string `obj` with the current font, advancing the text matrix by each glyph.
+func (proc *ContentStreamProcessor) showText(obj core.PdfObject, resources *model.PdfPageResources) error {
+	data, ok := core.GetStringBytes(obj)
+	if !ok {
+		common.Log.Debug("ERROR: Invalid string for %v", obj)
+		return nil
+	}
+	text := proc.graphicsState.text
+	if text.font == nil {
+		common.Log.Debug("ERROR: Text shown without font")
+		return nil
+	}
+
+	th := text.horizScaling / 100
+	stateMatrix := transform.NewMatrix(text.fontSize*th, 0, 0, text.fontSize, 0, text.rise)
+	for _, code := range text.font.BytesToCharcodes(data) {
+		width, err := proc.showGlyph(text.font, code, stateMatrix, resources)
+		if err != nil {
+			return err
+		}
+		tx := width*text.fontSize + text.charSpacing
+		if code == ' ' && !text.font.IsCID() {
+			tx += text.wordSpacing
+		}
+		proc.tm.Concat(transform.TranslationMatrix(tx*th, 0))
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package contentstream
+
+import (
+	"math"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/textencoding"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// Glyphs of Type3 fonts are painted by executing their glyph descriptions (CharProcs), content
+// streams in glyph space, with the graphics state of the text showing operator and a CTM mapping
+// the glyph space to the coordinates of the content stream (section 9.6.5 "Type 3 Fonts" p. 258).
+// The processor executes them with the handlers of the content stream, so that the handlers see
+// the marks painted by the glyphs. The glyph descriptions start with the d0 or d1 operator, which
+// set the width of the glyph used to position the next glyph.
+
+// maxType3Level is the maximum nesting level of glyph descriptions, which can show glyphs of
+// Type3 fonts themselves, guarding against self-referencing fonts.
+const maxType3Level = 4
+
+// type3Context holds the fonts loaded and the glyph descriptions parsed by a processor and by the
+// processors of the glyph descriptions it executes.
+type type3Context struct {
+	fonts     map[core.PdfObject]*model.PdfFont
+	charProcs map[*core.PdfObjectStream]*ContentStreamOperations
+}
+
+// glyphState is the state of the processing of a glyph description.
+type glyphState struct {
+	// level is the nesting level of the glyph description, 0 for content streams which are not
+	// glyph descriptions.
+	level int
+
+	// Width and bounding box of the glyph set by d0 or d1, and whether the glyph is uncolored
+	// (d1), in which case the color operators are ignored.
+	hasWidth  bool
+	width     float64
+	bbox      *model.PdfRectangle
+	uncolored bool
+
+	// measure is true if the bounding box of the areas painted is tracked in painted.
+	measure bool
+	painted *model.PdfRectangle
+}
+
+// loadFont returns the font of font dictionary `fontObj`, or nil if it cannot be loaded.
+func (ctx *type3Context) loadFont(fontObj core.PdfObject) *model.PdfFont {
+	if font, ok := ctx.fonts[fontObj]; ok {
+		return font
+	}
+	font, err := model.NewPdfFontFromPdfObject(fontObj)
+	if err != nil {
+		// Fonts which are not fully supported can still be used to position the glyphs.
+		common.Log.Debug("ERROR: Unable to load font %v: %v", fontObj, err)
+	}
+	if ctx.fonts == nil {
+		ctx.fonts = map[core.PdfObject]*model.PdfFont{}
+	}
+	ctx.fonts[fontObj] = font
+	return font
+}
+
+// charProc returns the operations of glyph description `stream`.
+func (ctx *type3Context) charProc(stream *core.PdfObjectStream) (*ContentStreamOperations, error) {
+	if ops, ok := ctx.charProcs[stream]; ok {
+		return ops, nil
+	}
+	data, err := core.DecodeStream(stream)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := NewContentStreamParser(string(data)).Parse()
+	if err != nil {
+		return nil, err
+	}
+	if ctx.charProcs == nil {
+		ctx.charProcs = map[*core.PdfObjectStream]*ContentStreamOperations{}
+	}
+	ctx.charProcs[stream] = ops
+	return ops, nil
+}
+
+// showGlyph shows the glyph of character code `code` of `font` at the current text position,
+// `stateMatrix` mapping the text space to the text matrix space, and returns the width of the
+// glyph in unscaled text space units. The glyph description is executed if `font` is a Type3
+// font, and the width set by its d0 or d1 operator returned.
+func (proc *ContentStreamProcessor) showGlyph(font *model.PdfFont, code textencoding.CharCode,
+	stateMatrix transform.Matrix, resources *model.PdfPageResources) (float64, error) {
+	width := 0.0
+	if metrics, ok := font.GetCharMetrics(code); ok {
+		width = metrics.Wx / 1000
+	}
+	stream, ok := font.CharProc(code)
+	if !ok {
+		return width, nil
+	}
+	if proc.glyph.level >= maxType3Level {
+		common.Log.Debug("ERROR: Type3 glyphs nested too deeply. level=%d", proc.glyph.level)
+		return width, nil
+	}
+	ops, err := proc.type3.charProc(stream)
+	if err != nil {
+		common.Log.Debug("ERROR: Invalid glyph description of code %d: %v", code, err)
+		return width, nil
+	}
+
+	fm := font.FontMatrix()
+	fontMatrix := transform.NewMatrix(fm[0], fm[1], fm[2], fm[3], fm[4], fm[5])
+	gs := proc.graphicsState
+	gs.CTM = gs.CTM.Mult(proc.tm).Mult(stateMatrix).Mult(fontMatrix)
+	gs.InCharProc = true
+
+	glyphProc := NewContentStreamProcessor(*ops)
+	glyphProc.handlers = proc.handlers
+	glyphProc.type3 = proc.type3
+	glyphProc.validation = proc.validation
+	glyphProc.ctx = proc.ctx
+	glyphProc.glyph.level = proc.glyph.level + 1
+	glyphProc.graphicsState = gs
+	glyphResources := font.CharProcResources()
+	if glyphResources == nil {
+		glyphResources = resources
+	}
+	if err := glyphProc.process(glyphResources); err != nil {
+		return 0, err
+	}
+	if glyphProc.glyph.hasWidth {
+		width = glyphProc.glyph.width * fm[0]
+	}
+	return width, nil
+}
+
+// d0, d1: Set the width, and for d1 the bounding box, of the glyph of a glyph description.
+// wx wy d0
+// wx wy llx lly urx ury d1
+func (proc *ContentStreamProcessor) handleGlyphMetrics(op *ContentStreamOperation) {
+	f, err := core.GetNumbersAsFloat(op.Params)
+	if err != nil || (op.Operand == "d0" && len(f) != 2) || (op.Operand == "d1" && len(f) != 6) {
+		common.Log.Debug("ERROR: Invalid parameters for %s: %v", op.Operand, op.Params)
+		return
+	}
+	proc.glyph.hasWidth = true
+	proc.glyph.width = f[0]
+	if op.Operand == "d1" {
+		proc.glyph.bbox = &model.PdfRectangle{
+			Llx: math.Min(f[2], f[4]),
+			Lly: math.Min(f[3], f[5]),
+			Urx: math.Max(f[2], f[4]),
+			Ury: math.Max(f[3], f[5]),
+		}
+		proc.glyph.uncolored = true
+	}
+}
+
+// isColorOperator returns true if `operand` is a color operator (Table 74 p. 179) or the
+// shading operator sh, which are ignored in the glyph descriptions of uncolored glyphs.
+func isColorOperator(operand string) bool {
+	switch operand {
+	case "CS", "cs", "SC", "SCN", "sc", "scn", "G", "g", "RG", "rg", "K", "k", "sh":
+		return true
+	}
+	return false
+}
+
+// addPainted adds the bounding box `bbox` of an area painted to the areas painted by the glyph
+// description, if they are measured.
+func (proc *ContentStreamProcessor) addPainted(bbox model.PdfRectangle) {
+	if !proc.glyph.measure {
+		return
+	}
+	if painted := proc.glyph.painted; painted != nil {
+		bbox = model.PdfRectangle{
+			Llx: math.Min(painted.Llx, bbox.Llx),
+			Lly: math.Min(painted.Lly, bbox.Lly),
+			Urx: math.Max(painted.Urx, bbox.Urx),
+			Ury: math.Max(painted.Ury, bbox.Ury),
+		}
+	}
+	proc.glyph.painted = &bbox
+}
+
+// paintUnitSquare adds the unit square, transformed by the CTM, to the areas painted, as images do.
+func (proc *ContentStreamProcessor) paintUnitSquare() {
+	if !proc.glyph.measure {
+		return
+	}
+	var bbox *model.PdfRectangle
+	for _, p := range [][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}} {
+		x, y := proc.graphicsState.Transform(p[0], p[1])
+		if bbox == nil {
+			bbox = &model.PdfRectangle{Llx: x, Lly: y, Urx: x, Ury: y}
+			continue
+		}
+		bbox.Llx, bbox.Lly = math.Min(bbox.Llx, x), math.Min(bbox.Lly, y)
+		bbox.Urx, bbox.Ury = math.Max(bbox.Urx, x), math.Max(bbox.Ury, y)
+	}
+	proc.addPainted(*bbox)
+}
+
+// Do: Paint the XObject named by the operand. Only the areas painted by images are tracked.
+func (proc *ContentStreamProcessor) handleCommand_Do(op *ContentStreamOperation, resources *model.PdfPageResources) {
+	if !proc.glyph.measure || len(op.Params) != 1 || resources == nil {
+		return
+	}
+	name, ok := core.GetName(op.Params[0])
+	if !ok {
+		return
+	}
+	if _, xtype := resources.GetXObjectByName(*name); xtype == model.XObjectTypeImage {
+		proc.paintUnitSquare()
+	}
+}
+
+// Type3GlyphMetrics represents the metrics of the glyph description of a glyph of a Type3 font,
+// in glyph space.
+type Type3GlyphMetrics struct {
+	// Width is the horizontal displacement of the glyph set by the d0 or d1 operator.
+	Width float64
+
+	// BBox is the bounding box of the glyph set by the d1 operator or, for glyphs set with d0,
+	// the bounding box of the areas painted by the glyph description. It is nil if the glyph
+	// paints nothing.
+	BBox *model.PdfRectangle
+}
+
+// GetType3GlyphMetrics returns the metrics of the glyph of character code `code` of the Type3 font
+// `font`, found by processing its glyph description. The bool return flag is false if `font` has
+// no glyph description for `code` or if the glyph description does not start with d0 or d1.
+func GetType3GlyphMetrics(font *model.PdfFont, code textencoding.CharCode) (Type3GlyphMetrics, bool) {
+	stream, ok := font.CharProc(code)
+	if !ok {
+		return Type3GlyphMetrics{}, false
+	}
+	proc := NewContentStreamProcessor(nil)
+	ops, err := proc.type3.charProc(stream)
+	if err != nil {
+		common.Log.Debug("ERROR: Invalid glyph description of code %d: %v", code, err)
+		return Type3GlyphMetrics{}, false
+	}
+	proc.operations = *ops
+	proc.glyph.level = 1
+	proc.glyph.measure = true
+	if err := proc.Process(font.CharProcResources()); err != nil {
+		common.Log.Debug("ERROR: Processing glyph description of code %d: %v", code, err)
+	}
+	if !proc.glyph.hasWidth {
+		return Type3GlyphMetrics{}, false
+	}
+	metrics := Type3GlyphMetrics{Width: proc.glyph.width, BBox: proc.glyph.bbox}
+	if metrics.BBox == nil {
+		metrics.BBox = proc.glyph.painted
+	}
+	return metrics, true
+}
@@ -0,0 +1,123 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package contentstream
+
+import (
+	"math"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// ValidationPolicy defines how ContentStreamProcessor handles the operations whose operands do not
+// match the signature of their operator (Table A.1 PDF32000_2008), e.g. with a wrong number of
+// operands or a string where a number is expected.
+type ValidationPolicy int
+
+const (
+	// ValidationSkipOperation logs the invalid operations and skips them: they are neither
+	// processed nor passed to the handlers. This is the default policy.
+	ValidationSkipOperation ValidationPolicy = iota
+
+	// ValidationStrict makes processing fail at the first invalid operation with an error
+	// wrapping ErrInvalidOperand.
+	ValidationStrict
+
+	// ValidationBestEffort coerces the operands of invalid operations where it is safe: real
+	// numbers with integral values are converted to the integers expected, and extra operands
+	// left on the operand stack by previous operations are dropped. The operations which cannot
+	// be coerced are skipped as with ValidationSkipOperation.
+	ValidationBestEffort
+)
+
+// ValidationStats counts the invalid operations found while processing content streams.
+type ValidationStats struct {
+	// Skipped is the number of operations skipped.
+	Skipped int
+
+	// Coerced is the number of operations processed with coerced operands.
+	Coerced int
+}
+
+// validationContext holds the validation policy of a processor and the counts of invalid
+// operations, shared with the processors of the Type3 glyph descriptions it executes.
+type validationContext struct {
+	policy ValidationPolicy
+	stats  ValidationStats
+}
+
+// SetValidationPolicy sets the policy applied to the operations with invalid operands.
+func (proc *ContentStreamProcessor) SetValidationPolicy(policy ValidationPolicy) {
+	proc.validation.policy = policy
+}
+
+// ValidationStats returns the counts of the invalid operations found by the processor, including
+// those of the glyph descriptions of Type3 fonts.
+func (proc *ContentStreamProcessor) ValidationStats() ValidationStats {
+	return proc.validation.stats
+}
+
+// validate checks the operands of `op` and returns the operation to process: `op` itself if it
+// is valid, an operation with coerced operands, or nil if it is to be skipped. An error is
+// returned for invalid operations with the ValidationStrict policy.
+// Unknown operators are ignored in compatibility sections (BX ... EX) whatever the policy.
+func (proc *ContentStreamProcessor) validate(op *ContentStreamOperation) (*ContentStreamOperation, error) {
+	switch op.Operand {
+	case "BX":
+		proc.compatibilityLevel++
+	case "EX":
+		if proc.compatibilityLevel > 0 {
+			proc.compatibilityLevel--
+		}
+	}
+	if proc.compatibilityLevel > 0 && !isValidOperand(op.Operand) {
+		return nil, nil
+	}
+
+	err := checkOperation(op)
+	if err == nil {
+		return op, nil
+	}
+	ctx := proc.validation
+	switch ctx.policy {
+	case ValidationStrict:
+		return nil, err
+	case ValidationBestEffort:
+		if coerced, ok := coerceOperation(op); ok {
+			common.Log.Debug("Coerced operands of invalid operation %s: %v", op, err)
+			ctx.stats.Coerced++
+			return coerced, nil
+		}
+	}
+	common.Log.Debug("ERROR: Skipping invalid operation %s: %v", op, err)
+	ctx.stats.Skipped++
+	return nil, nil
+}
+
+// coerceOperation returns an operation with the operands of `op` coerced to the signature of its
+// operator, if this is safe.
+func coerceOperation(op *ContentStreamOperation) (*ContentStreamOperation, bool) {
+	kinds, ok := operandKinds[op.Operand]
+	if !ok || len(op.Params) < len(kinds) {
+		return nil, false
+	}
+	// The operands are the last ones on the operand stack.
+	params := make([]core.PdfObject, len(kinds))
+	copy(params, op.Params[len(op.Params)-len(kinds):])
+	for i, kind := range kinds {
+		if kind != kindInteger || isKind(params[i], kind) {
+			continue
+		}
+		if val, err := core.GetNumberAsFloat(params[i]); err == nil && val == math.Trunc(val) {
+			params[i] = core.MakeInteger(int64(val))
+		}
+	}
+	coerced := &ContentStreamOperation{Operand: op.Operand, Params: params}
+	if checkOperation(coerced) != nil {
+		return nil, false
+	}
+	return coerced, true
+}
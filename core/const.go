@@ -5,7 +5,10 @@
 
 package core
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // Common errors that may occur on PDF parsing/writing.
 var (
@@ -21,4 +24,18 @@ var (
 	ErrRangeError                    = errors.New("range check error")
 	ErrNotSupported                  = errors.New("feature not currently supported")
 	ErrNotANumber                    = errors.New("not a number")
+
+	// ErrDCTUnsupportedBitsPerComponent is returned when DCT encoding images with more than 8 bits
+	// per component.
+	ErrDCTUnsupportedBitsPerComponent = errors.New("DCT encoding supports up to 8 bits per component")
+
+	// ErrEncrypted indicates that the objects of an encrypted document are accessed before the
+	// document is decrypted.
+	ErrEncrypted = errors.New("file needs to be decrypted first")
+	// ErrWrongPassword indicates that an encrypted document could not be decrypted with the
+	// passwords provided. It is an ErrEncrypted error.
+	ErrWrongPassword = fmt.Errorf("wrong password: %w", ErrEncrypted)
+	// ErrInvalidXref indicates that the cross-reference information of a document is invalid and
+	// could not be repaired.
+	ErrInvalidXref = errors.New("invalid cross-reference table")
 )
@@ -66,113 +66,116 @@ type objectStreams map[int]objectStream
 // have already been parsed.
 type objectCache map[int]PdfObject
 
-// lookupObjectViaOS returns an object from an object stream.
-func (parser *PdfParser) lookupObjectViaOS(sobjNumber int, objNum int) (PdfObject, error) {
-	var bufReader *bytes.Reader
-	var objstm objectStream
-	var cached bool
+// loadObjectStream returns the object stream `sobjNumber`, loading its offset
+// map on first use.
+func (parser *PdfParser) loadObjectStream(sobjNumber int) (objectStream, error) {
+	if objstm, cached := parser.objstms[sobjNumber]; cached {
+		return objstm, nil
+	}
 
-	objstm, cached = parser.objstms[sobjNumber]
-	if !cached {
-		soi, err := parser.LookupByNumber(sobjNumber)
-		if err != nil {
-			common.Log.Debug("Missing object stream with number %d", sobjNumber)
-			return nil, err
-		}
+	soi, _, err := parser.lookupByNumberWrapper(sobjNumber, true)
+	if err != nil {
+		common.Log.Debug("Missing object stream with number %d", sobjNumber)
+		return objectStream{}, err
+	}
 
-		so, ok := soi.(*PdfObjectStream)
-		if !ok {
-			return nil, errors.New("invalid object stream")
-		}
+	so, ok := soi.(*PdfObjectStream)
+	if !ok {
+		return objectStream{}, errors.New("invalid object stream")
+	}
 
-		if parser.crypter != nil && !parser.crypter.isDecrypted(so) {
-			return nil, errors.New("need to decrypt the stream")
-		}
+	if parser.crypter != nil && !parser.crypter.isDecrypted(so) {
+		return objectStream{}, errors.New("need to decrypt the stream")
+	}
 
-		sod := so.PdfObjectDictionary
-		common.Log.Trace("so d: %s\n", sod.String())
-		name, ok := sod.Get("Type").(*PdfObjectName)
-		if !ok {
-			common.Log.Debug("ERROR: Object stream should always have a Type")
-			return nil, errors.New("object stream missing Type")
-		}
-		if strings.ToLower(string(*name)) != "objstm" {
-			common.Log.Debug("ERROR: Object stream type shall always be ObjStm !")
-			return nil, errors.New("object stream type != ObjStm")
-		}
+	sod := so.PdfObjectDictionary
+	common.Log.Trace("so d: %s\n", sod.String())
+	name, ok := sod.Get("Type").(*PdfObjectName)
+	if !ok {
+		common.Log.Debug("ERROR: Object stream should always have a Type")
+		return objectStream{}, errors.New("object stream missing Type")
+	}
+	if strings.ToLower(string(*name)) != "objstm" {
+		common.Log.Debug("ERROR: Object stream type shall always be ObjStm !")
+		return objectStream{}, errors.New("object stream type != ObjStm")
+	}
 
-		N, ok := sod.Get("N").(*PdfObjectInteger)
-		if !ok {
-			return nil, errors.New("invalid N in stream dictionary")
+	N, ok := sod.Get("N").(*PdfObjectInteger)
+	if !ok {
+		return objectStream{}, errors.New("invalid N in stream dictionary")
+	}
+	firstOffset, ok := sod.Get("First").(*PdfObjectInteger)
+	if !ok {
+		return objectStream{}, errors.New("invalid First in stream dictionary")
+	}
+
+	common.Log.Trace("type: %s number of objects: %d", name, *N)
+	ds, err := DecodeStream(so)
+	if err != nil {
+		return objectStream{}, err
+	}
+
+	common.Log.Trace("Decoded: %s", ds)
+
+	// Temporarily change the reader object to this decoded buffer.
+	// Change back afterwards.
+	bakOffset := parser.GetFileOffset()
+	defer func() { parser.SetFileOffset(bakOffset) }()
+
+	parser.reader = bufio.NewReader(bytes.NewReader(ds))
+
+	common.Log.Trace("Parsing offset map")
+	// Load the offset map (relative to the beginning of the stream...)
+	offsets := map[int]int64{}
+	// Object list and offsets.
+	for i := 0; i < int(*N); i++ {
+		parser.skipSpaces()
+		// Object number.
+		obj, err := parser.parseNumber()
+		if err != nil {
+			return objectStream{}, err
 		}
-		firstOffset, ok := sod.Get("First").(*PdfObjectInteger)
+		onum, ok := obj.(*PdfObjectInteger)
 		if !ok {
-			return nil, errors.New("invalid First in stream dictionary")
+			return objectStream{}, errors.New("invalid object stream offset table")
 		}
 
-		common.Log.Trace("type: %s number of objects: %d", name, *N)
-		ds, err := DecodeStream(so)
+		parser.skipSpaces()
+		// Offset.
+		obj, err = parser.parseNumber()
 		if err != nil {
-			return nil, err
+			return objectStream{}, err
+		}
+		offset, ok := obj.(*PdfObjectInteger)
+		if !ok {
+			return objectStream{}, errors.New("invalid object stream offset table")
 		}
 
-		common.Log.Trace("Decoded: %s", ds)
-
-		// Temporarily change the reader object to this decoded buffer.
-		// Change back afterwards.
-		bakOffset := parser.GetFileOffset()
-		defer func() { parser.SetFileOffset(bakOffset) }()
-
-		bufReader = bytes.NewReader(ds)
-		parser.reader = bufio.NewReader(bufReader)
-
-		common.Log.Trace("Parsing offset map")
-		// Load the offset map (relative to the beginning of the stream...)
-		offsets := map[int]int64{}
-		// Object list and offsets.
-		for i := 0; i < int(*N); i++ {
-			parser.skipSpaces()
-			// Object number.
-			obj, err := parser.parseNumber()
-			if err != nil {
-				return nil, err
-			}
-			onum, ok := obj.(*PdfObjectInteger)
-			if !ok {
-				return nil, errors.New("invalid object stream offset table")
-			}
-
-			parser.skipSpaces()
-			// Offset.
-			obj, err = parser.parseNumber()
-			if err != nil {
-				return nil, err
-			}
-			offset, ok := obj.(*PdfObjectInteger)
-			if !ok {
-				return nil, errors.New("invalid object stream offset table")
-			}
+		common.Log.Trace("obj %d offset %d", *onum, *offset)
+		offsets[int(*onum)] = int64(*firstOffset + *offset)
+	}
 
-			common.Log.Trace("obj %d offset %d", *onum, *offset)
-			offsets[int(*onum)] = int64(*firstOffset + *offset)
-		}
+	objstm := objectStream{N: int(*N), ds: ds, offsets: offsets}
+	parser.cacheObjectStream(sobjNumber, objstm)
+	return objstm, nil
+}
 
-		objstm = objectStream{N: int(*N), ds: ds, offsets: offsets}
-		parser.objstms[sobjNumber] = objstm
-	} else {
-		// Temporarily change the reader object to this decoded buffer.
-		// Point back afterwards.
-		bakOffset := parser.GetFileOffset()
-		defer func() { parser.SetFileOffset(bakOffset) }()
-
-		bufReader = bytes.NewReader(objstm.ds)
-		// Temporarily change the reader object to this decoded buffer.
-		parser.reader = bufio.NewReader(bufReader)
+// lookupObjectViaOS returns an object from an object stream.
+func (parser *PdfParser) lookupObjectViaOS(sobjNumber int, objNum int) (PdfObject, error) {
+	objstm, err := parser.loadObjectStream(sobjNumber)
+	if err != nil {
+		return nil, err
 	}
 
+	// Temporarily change the reader object to this decoded buffer.
+	// Point back afterwards.
+	bakOffset := parser.GetFileOffset()
+	defer func() { parser.SetFileOffset(bakOffset) }()
+
 	offset := objstm.offsets[objNum]
 	common.Log.Trace("ACTUAL offset[%d] = %d", objNum, offset)
 
+	bufReader := bytes.NewReader(objstm.ds)
 	bufReader.Seek(offset, os.SEEK_SET)
 	parser.reader = bufio.NewReader(bufReader)
 
@@ -197,7 +200,11 @@ func (parser *PdfParser) lookupObjectViaOS(sobjNumber int, objNum int) (PdfObjec
 }
 
 // LookupByNumber looks up a PdfObject by object number.  Returns an error on failure.
+// It is safe for concurrent use.
 func (parser *PdfParser) LookupByNumber(objNumber int) (PdfObject, error) {
+	parser.mu.Lock()
+	defer parser.mu.Unlock()
+
 	// Outside interface for lookupByNumberWrapper.  Default attempts repairs of bad xref tables.
 	obj, _, err := parser.lookupByNumberWrapper(objNumber, true)
 	return obj, err
@@ -213,6 +220,9 @@ func (parser *PdfParser) lookupByNumberWrapper(objNumber int, attemptRepairs boo
 	// If encrypted, decrypt it prior to returning.
 	// Do not attempt to decrypt objects within object streams.
 	if !inObjStream && parser.crypter != nil && !parser.crypter.isDecrypted(obj) {
+		if !parser.crypter.authenticated {
+			return nil, inObjStream, ErrEncrypted
+		}
 		err := parser.crypter.Decrypt(obj, 0, 0)
 		if err != nil {
 			return nil, inObjStream, err
@@ -268,15 +278,13 @@ func (parser *PdfParser) lookupByNumber(objNumber int, attemptRepairs bool) (Pdf
 			// Offset pointing to a non-object.  Try to repair the file.
 			if attemptRepairs {
 				common.Log.Debug("Attempting to repair xrefs (top down)")
-				xrefTable, err := parser.repairRebuildXrefsTopDown()
-				if err != nil {
+				if err := parser.repairRebuildXrefs(); err != nil {
 					common.Log.Debug("ERROR Failed repair (%s)", err)
-					return nil, false, err
+					return nil, false, wrapError(ErrInvalidXref, err)
 				}
-				parser.xrefs = *xrefTable
 				return parser.lookupByNumber(objNumber, false)
 			}
-			return nil, false, err
+			return nil, false, ErrObjectNotFound{Num: objNumber, Err: err}
 		}
 
 		if attemptRepairs {
@@ -288,7 +296,7 @@ func (parser *PdfParser) lookupByNumber(objNumber int, attemptRepairs bool) (Pdf
 				common.Log.Debug("Invalid xrefs: Rebuilding")
 				err := parser.rebuildXrefTable()
 				if err != nil {
-					return nil, false, err
+					return nil, false, wrapError(ErrInvalidXref, err)
 				}
 				// Empty the cache.
 				parser.ObjCache = objectCache{}
@@ -297,8 +305,13 @@ func (parser *PdfParser) lookupByNumber(objNumber int, attemptRepairs bool) (Pdf
 			}
 		}
 
+		if stream, isStream := obj.(*PdfObjectStream); isStream {
+			parser.resolveStreamFilters(stream)
+		}
+
 		common.Log.Trace("Returning obj")
 		parser.ObjCache[objNumber] = obj
+		parser.objectLoaded()
 		return obj, false, nil
 	} else if xref.XType == XrefTypeObjectStream {
 		common.Log.Trace("xref from object stream!")
@@ -307,7 +320,7 @@ func (parser *PdfParser) lookupByNumber(objNumber int, attemptRepairs bool) (Pdf
 
 		if xref.OsObjNumber == objNumber {
 			common.Log.Debug("ERROR Circular reference!?!")
-			return nil, true, errors.New("xref circular reference")
+			return nil, true, wrapError(ErrInvalidXref, errors.New("xref circular reference"))
 		}
 
 		if _, exists := parser.xrefs.ObjectMap[xref.OsObjNumber]; exists {
@@ -318,6 +331,7 @@ func (parser *PdfParser) lookupByNumber(objNumber int, attemptRepairs bool) (Pdf
 			}
 			common.Log.Trace("<Loaded via OS")
 			parser.ObjCache[objNumber] = optr
+			parser.objectLoaded()
 			if parser.crypter != nil {
 				// Mark as decrypted (inside object stream) for caching.
 				// and avoid decrypting decrypted object.
@@ -327,19 +341,31 @@ func (parser *PdfParser) lookupByNumber(objNumber int, attemptRepairs bool) (Pdf
 		}
 
 		common.Log.Debug("?? Belongs to a non-cross referenced object ...!")
-		return nil, true, errors.New("os belongs to a non cross referenced object")
+		return nil, true, ErrObjectNotFound{Num: objNumber, Err: errors.New("os belongs to a non cross referenced object")}
 	}
-	return nil, false, errors.New("unknown xref type")
+	return nil, false, wrapError(ErrInvalidXref, errors.New("unknown xref type"))
 }
 
-// LookupByReference looks up a PdfObject by a reference.
+// LookupByReference looks up a PdfObject by a reference. It is safe for concurrent use.
 func (parser *PdfParser) LookupByReference(ref PdfObjectReference) (PdfObject, error) {
 	common.Log.Trace("Looking up reference %s", ref.String())
 	return parser.LookupByNumber(int(ref.ObjectNumber))
 }
 
 // Resolve resolves a PdfObject to direct object, looking up and resolving references as needed (unlike TraceToDirect).
+// It is safe for concurrent use.
 func (parser *PdfParser) Resolve(obj PdfObject) (PdfObject, error) {
+	if _, isRef := obj.(*PdfObjectReference); !isRef {
+		// Direct object already.
+		return obj, nil
+	}
+	parser.mu.Lock()
+	defer parser.mu.Unlock()
+	return parser.resolve(obj)
+}
+
+// resolve is used by Resolve and by the lookups in progress, the parser being locked.
+func (parser *PdfParser) resolve(obj PdfObject) (PdfObject, error) {
 	ref, isRef := obj.(*PdfObjectReference)
 	if !isRef {
 		// Direct object already.
@@ -349,7 +375,7 @@ func (parser *PdfParser) Resolve(obj PdfObject) (PdfObject, error) {
 	bakOffset := parser.GetFileOffset()
 	defer func() { parser.SetFileOffset(bakOffset) }()
 
-	o, err := parser.LookupByReference(*ref)
+	o, _, err := parser.lookupByNumberWrapper(int(ref.ObjectNumber), true)
 	if err != nil {
 		return nil, err
 	}
@@ -368,6 +394,45 @@ func (parser *PdfParser) Resolve(obj PdfObject) (PdfObject, error) {
 	return o, nil
 }
 
+// resolveStreamFilters replaces the references in the Filter and DecodeParms entries of `stream`
+// with the objects referred to, so that decrypting and decoding the stream, e.g. when loading an
+// object stream, does not look up objects while the parser is locked.
+func (parser *PdfParser) resolveStreamFilters(stream *PdfObjectStream) {
+	var resolveDeep func(obj PdfObject, depth int) PdfObject
+	resolveDeep = func(obj PdfObject, depth int) PdfObject {
+		if depth > traceMaxDepth {
+			return obj
+		}
+		switch t := obj.(type) {
+		case *PdfObjectReference:
+			o, _, err := parser.lookupByNumberWrapper(int(t.ObjectNumber), true)
+			if err != nil {
+				common.Log.Debug("ERROR: Unable to resolve stream filter reference %s: %v", t, err)
+				return obj
+			}
+			if io, isInd := o.(*PdfIndirectObject); isInd {
+				io.PdfObject = resolveDeep(io.PdfObject, depth+1)
+			}
+			return o
+		case *PdfObjectArray:
+			for i, elem := range t.Elements() {
+				t.Set(i, resolveDeep(elem, depth+1))
+			}
+		case *PdfObjectDictionary:
+			for _, key := range t.Keys() {
+				t.Set(key, resolveDeep(t.Get(key), depth+1))
+			}
+		}
+		return obj
+	}
+
+	for _, key := range []PdfObjectName{"Filter", "DecodeParms"} {
+		if obj := stream.Get(key); obj != nil {
+			stream.Set(key, resolveDeep(obj, 0))
+		}
+	}
+}
+
 func printXrefTable(xrefTable XrefTable) {
 	common.Log.Debug("=X=X=X=")
 	common.Log.Debug("Xref table:")
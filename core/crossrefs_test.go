@@ -6,6 +6,9 @@
 package core
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"testing"
 
@@ -46,3 +49,287 @@ func TestParseXRefs(t *testing.T) {
 
 	require.Equal(t, expected, p.xrefs)
 }
+
+func TestParseXrefStreamWideOffsets(t *testing.T) {
+	// Offsets beyond 4 GB and 10 digits, with 5 and 8 bytes wide fields.
+	rawText := `99 0 obj
+<<  /Type /XRef
+    /Index [0 4]
+    /W [1 %d 2]
+    /Filter /ASCIIHexDecode
+    /Size 4
+    /Length 100
+>>
+stream
+%s>
+endstream
+endobj`
+	for _, tc := range []struct {
+		width   int
+		entries string
+		offsets map[int]int64
+	}{
+		{5, "00 0000000000 FFFF 01 0100000000 0000 01 02540BE400 0000 02 0000000001 0003",
+			map[int]int64{1: 1 << 32, 2: 10000000000}},
+		{8, "00 0000000000000000 FFFF 01 7FFFFFFFFFFFFFFF 0000 01 0000000100000000 0001 02 0000000000000001 0003",
+			map[int]int64{1: 1<<63 - 1, 2: 1 << 32}},
+	} {
+		p := NewParserFromString(fmt.Sprintf(rawText, tc.width, tc.entries))
+		_, err := p.parseXrefStream(nil)
+		require.NoError(t, err)
+		require.Len(t, p.xrefs.ObjectMap, 3)
+		for num, offset := range tc.offsets {
+			require.Equal(t, XrefTypeTableEntry, p.xrefs.ObjectMap[num].XType)
+			require.Equal(t, offset, p.xrefs.ObjectMap[num].Offset)
+		}
+		require.Equal(t, XrefObject{XType: XrefTypeObjectStream, ObjectNumber: 3, OsObjNumber: 1, OsObjIndex: 3},
+			p.xrefs.ObjectMap[3])
+	}
+
+	// Fields wider than 64 bits are not supported.
+	p := NewParserFromString(fmt.Sprintf(rawText, 9, "00 000000000000000000 FFFF"))
+	_, err := p.parseXrefStream(nil)
+	require.Equal(t, ErrRangeError, err)
+}
+
+func TestDecodeXrefStreamField(t *testing.T) {
+	require.Equal(t, int64(0), decodeXrefStreamField(nil))
+	require.Equal(t, int64(0xFFFF), decodeXrefStreamField([]byte{0xFF, 0xFF}))
+	require.Equal(t, int64(1<<32), decodeXrefStreamField([]byte{1, 0, 0, 0, 0}))
+	require.Equal(t, int64(9999999999+1), decodeXrefStreamField([]byte{0x02, 0x54, 0x0B, 0xE4, 0x00}))
+	require.Equal(t, int64(1<<63-1), decodeXrefStreamField([]byte{0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}))
+}
+
+// makeHybridTestFile returns a hybrid-reference file made of two sections, as written by word
+// processors for compatibility with readers not supporting cross-reference streams. The fonts
+// are only referenced by the cross-reference streams (XRefStm), object 4 being compressed in the
+// object stream 5 of the original section and object 10 being added by the update section, which
+// also updates the page 3, marked as free in the stream of the update section.
+func makeHybridTestFile() []byte {
+	var buf bytes.Buffer
+	offsets := map[int]int{}
+	writeObj := func(num int, obj string) {
+		offsets[num] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", num, obj)
+	}
+	// writeXrefStm writes the cross-reference stream `num` with the entries of `nums`, the type 2
+	// entries being specified by `compressed`.
+	writeXrefStm := func(num int, nums []int, compressed map[int][2]int) {
+		var index, data string
+		for _, n := range nums {
+			index += fmt.Sprintf("%d 1 ", n)
+			if c, ok := compressed[n]; ok {
+				data += fmt.Sprintf("02 %.4X %.2X ", c[0], c[1])
+			} else if offset, ok := offsets[n]; ok {
+				data += fmt.Sprintf("01 %.4X 00 ", offset)
+			} else {
+				data += "00 0000 00 "
+			}
+		}
+		data += ">"
+		writeObj(num, fmt.Sprintf("<< /Type /XRef /Size %d /Index [%s] /W [1 2 1] /Filter /ASCIIHexDecode "+
+			"/Length %d >>\nstream\n%s\nendstream", num+1, index, len(data), data))
+	}
+	// writeTable writes a cross-reference table with the entries of `nums`, marking the objects
+	// not in use in the table as free.
+	writeTable := func(nums []int, inUse map[int]bool, trailer string) int {
+		start := buf.Len()
+		buf.WriteString("xref\n")
+		for _, n := range nums {
+			fmt.Fprintf(&buf, "%d 1\n", n)
+			if inUse[n] {
+				fmt.Fprintf(&buf, "%.10d 00000 n\r\n", offsets[n])
+			} else {
+				buf.WriteString("0000000000 65535 f\r\n")
+			}
+		}
+		fmt.Fprintf(&buf, "trailer\n%s\nstartxref\n%d\n%%%%EOF\n", trailer, start)
+		return start
+	}
+
+	buf.WriteString("%PDF-1.5\n")
+	writeObj(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	writeObj(2, "<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	writeObj(3, "<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 4 0 R >> >> >>")
+	font := "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"
+	writeObj(5, fmt.Sprintf("<< /Type /ObjStm /N 1 /First 4 /Length %d >>\nstream\n4 0 %s\nendstream",
+		len(font)+4, font))
+	writeXrefStm(6, []int{4, 5, 6}, map[int][2]int{4: {5, 0}})
+	prev := writeTable([]int{0, 1, 2, 3, 4, 5, 6}, map[int]bool{1: true, 2: true, 3: true},
+		fmt.Sprintf("<< /Size 7 /Root 1 0 R /XRefStm %d >>", offsets[6]))
+
+	// Update section.
+	writeObj(3, "<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 4 0 R /F2 10 0 R >> >> >>")
+	writeObj(10, "<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>")
+	writeXrefStm(11, []int{3, 10, 11}, nil)
+	delete(offsets, 3)
+	writeTable([]int{3, 10, 11}, map[int]bool{3: true},
+		fmt.Sprintf("<< /Size 12 /Root 1 0 R /Prev %d /XRefStm %d >>", prev, offsets[11]))
+	return buf.Bytes()
+}
+
+func TestParseHybridXrefs(t *testing.T) {
+	data := makeHybridTestFile()
+	parser, err := NewParser(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, XrefTypeTableEntry, *parser.GetXrefType())
+
+	// Page 3 is loaded from the table of the update section, which takes precedence over the
+	// free entry of its stream.
+	page, err := parser.LookupByNumber(3)
+	require.NoError(t, err)
+	resources, ok := GetDict(page)
+	require.True(t, ok)
+	resources, ok = GetDict(resources.Get("Resources"))
+	require.True(t, ok)
+	fonts, ok := GetDict(resources.Get("Font"))
+	require.True(t, ok)
+	require.Equal(t, []PdfObjectName{"F1", "F2"}, fonts.Keys())
+
+	// The fonts are only referenced by the cross-reference streams.
+	for name, baseFont := range map[PdfObjectName]string{"F1": "Helvetica", "F2": "Courier"} {
+		obj, err := parser.Resolve(fonts.Get(name))
+		require.NoError(t, err)
+		font, ok := GetDict(obj)
+		require.True(t, ok)
+		val, ok := GetNameVal(font.Get("BaseFont"))
+		require.True(t, ok)
+		require.Equal(t, baseFont, val)
+	}
+	require.Equal(t, XrefTypeObjectStream, parser.xrefs.ObjectMap[4].XType)
+	require.Equal(t, XrefTypeTableEntry, parser.xrefs.ObjectMap[10].XType)
+	require.Empty(t, parser.GetRecoveries())
+}
+
+// TestConcurrentLookups tests looking up objects concurrently, with objects compressed in an
+// object stream whose filter is an indirect object. Run with -race.
+func TestConcurrentLookups(t *testing.T) {
+	const numObjects = 16
+
+	var buf bytes.Buffer
+	offsets := map[int]int{}
+	writeObj := func(num int, obj string) {
+		offsets[num] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", num, obj)
+	}
+	buf.WriteString("%PDF-1.5\n")
+	writeObj(1, "<< /Type /Catalog >>")
+	writeObj(3, "/ASCIIHexDecode")
+	var header, body string
+	for i := 0; i < numObjects; i++ {
+		obj := fmt.Sprintf("(compressed %d) ", i)
+		header += fmt.Sprintf("%d %d ", 100+i, len(body))
+		body += obj
+		writeObj(200+i, fmt.Sprintf("<< /Length %d >>\nstream\nstream %d\nendstream", len(fmt.Sprintf("stream %d", i)), i))
+	}
+	data := fmt.Sprintf("%X>", header+body)
+	writeObj(2, fmt.Sprintf("<< /Type /ObjStm /N %d /First %d /Filter 3 0 R /Length %d >>\nstream\n%s\nendstream",
+		numObjects, len(header), len(data), data))
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 4\n0000000000 65535 f\r\n")
+	for i := 1; i <= 3; i++ {
+		fmt.Fprintf(&buf, "%.10d 00000 n\r\n", offsets[i])
+	}
+	fmt.Fprintf(&buf, "200 %d\n", numObjects)
+	for i := 0; i < numObjects; i++ {
+		fmt.Fprintf(&buf, "%.10d 00000 n\r\n", offsets[200+i])
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", 200+numObjects, xref)
+
+	// The compressed objects are added to the cross-reference information, as loaded from a
+	// cross-reference stream.
+	parser, err := NewParser(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	for i := 0; i < numObjects; i++ {
+		parser.xrefs.ObjectMap[100+i] = XrefObject{XType: XrefTypeObjectStream, ObjectNumber: 100 + i,
+			OsObjNumber: 2, OsObjIndex: i}
+	}
+
+	errs := make(chan error, 2*numObjects)
+	for i := 0; i < numObjects; i++ {
+		go func(i int) {
+			ref := &PdfObjectReference{ObjectNumber: int64(100 + i), parser: parser}
+			str, ok := GetString(ref.Resolve())
+			if !ok || str.Str() != fmt.Sprintf("compressed %d", i) {
+				errs <- fmt.Errorf("object %d: %v", 100+i, ref.Resolve())
+				return
+			}
+			errs <- nil
+		}(i)
+		go func(i int) {
+			obj, err := parser.LookupByNumber(200 + i)
+			if err != nil {
+				errs <- err
+				return
+			}
+			stream, ok := GetStream(obj)
+			if !ok || string(stream.Stream) != fmt.Sprintf("stream %d", i) {
+				errs <- fmt.Errorf("object %d: %v", 200+i, obj)
+				return
+			}
+			errs <- nil
+		}(i)
+	}
+	for i := 0; i < 2*numObjects; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	// The filter of the object stream was resolved.
+	obj, err := parser.LookupByNumber(2)
+	require.NoError(t, err)
+	stream, ok := GetStream(obj)
+	require.True(t, ok)
+	_, isRef := stream.Get("Filter").(*PdfObjectReference)
+	require.False(t, isRef)
+}
+
+// TestConcurrentLoadData tests decoding a stream parsed in lazy-loading mode concurrently, the
+// data being loaded once. Run with -race.
+func TestConcurrentLoadData(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n")
+	offsets := []int{buf.Len()}
+	buf.WriteString("1 0 obj\n<< /Type /Catalog >>\nendobj\n")
+	offsets = append(offsets, buf.Len())
+	buf.WriteString("2 0 obj\n<< /Length 11 /Filter /ASCIIHexDecode >>\nstream\n6C617A7921>\nendstream\nendobj\n")
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 3\n0000000000 65535 f\r\n")
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%.10d 00000 n\r\n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size 3 /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", xref)
+
+	lookup := func(rs io.ReadSeeker) *PdfObjectStream {
+		parser, err := NewParser(rs)
+		require.NoError(t, err)
+		parser.SetLazyLoading(true)
+		obj, err := parser.LookupByNumber(2)
+		require.NoError(t, err)
+		stream, ok := GetStream(obj)
+		require.True(t, ok)
+		return stream
+	}
+
+	stream := lookup(bytes.NewReader(buf.Bytes()))
+	require.False(t, stream.IsDataLoaded())
+	copied := DeepCopy(stream, nil).(*PdfObjectStream)
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		go func(stream *PdfObjectStream) {
+			data, err := DecodeStream(stream)
+			if err == nil && string(data) != "lazy!" {
+				err = fmt.Errorf("unexpected data: %q", data)
+			}
+			errs <- err
+		}([]*PdfObjectStream{stream, copied}[i%2])
+	}
+	for i := 0; i < 16; i++ {
+		require.NoError(t, <-errs)
+	}
+	require.True(t, stream.IsDataLoaded())
+
+	// Without io.ReaderAt, the data is loaded when parsed.
+	stream = lookup(struct{ io.ReadSeeker }{bytes.NewReader(buf.Bytes())})
+	require.True(t, stream.IsDataLoaded())
+}
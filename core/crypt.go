@@ -30,12 +30,20 @@ type EncryptInfo struct {
 
 // PdfCryptNewEncrypt makes the document crypt handler based on a specified crypt filter.
 func PdfCryptNewEncrypt(cf crypto.Filter, userPass, ownerPass []byte, perm security.Permissions) (*PdfCrypt, *EncryptInfo, error) {
+	return PdfCryptNewEncryptMetadata(cf, userPass, ownerPass, perm, true)
+}
+
+// PdfCryptNewEncryptMetadata makes the document crypt handler based on a specified crypt filter.
+// The metadata streams of the document are left unencrypted if `encryptMetadata` is false, which
+// is only supported by crypt filters of V4 and later.
+func PdfCryptNewEncryptMetadata(cf crypto.Filter, userPass, ownerPass []byte, perm security.Permissions,
+	encryptMetadata bool) (*PdfCrypt, *EncryptInfo, error) {
 	crypter := &PdfCrypt{
 		encryptedObjects: make(map[PdfObject]bool),
 		cryptFilters:     make(cryptFilters),
 		encryptStd: security.StdEncryptDict{
 			P:               perm,
-			EncryptMetadata: true,
+			EncryptMetadata: encryptMetadata,
 		},
 	}
 	var vers Version
@@ -49,6 +57,9 @@ func PdfCryptNewEncrypt(cf crypto.Filter, userPass, ownerPass []byte, perm secur
 
 		crypter.encrypt.Length = cf.KeyLength() * 8
 	}
+	if !encryptMetadata && crypter.encrypt.V < 4 {
+		return nil, nil, errors.New("unencrypted metadata requires a crypt filter of V4 or later")
+	}
 	const (
 		defaultFilter = stdCryptFilter
 	)
@@ -60,17 +71,8 @@ func PdfCryptNewEncrypt(cf crypto.Filter, userPass, ownerPass []byte, perm secur
 	ed := crypter.newEncryptDict()
 
 	// Prepare the ID object for the trailer.
-	hashcode := md5.Sum([]byte(time.Now().Format(time.RFC850)))
-	id0 := string(hashcode[:])
-	b := make([]byte, 100)
-	rand.Read(b)
-	hashcode = md5.Sum(b)
-	id1 := string(hashcode[:])
-	common.Log.Trace("Random b: % x", b)
-
-	common.Log.Trace("Gen Id 0: % x", id0)
-
-	crypter.id0 = string(id0)
+	id0, id1 := newEncryptIDs()
+	crypter.id0 = id0
 
 	err := crypter.generateParams(userPass, ownerPass)
 	if err != nil {
@@ -91,11 +93,27 @@ func PdfCryptNewEncrypt(cf crypto.Filter, userPass, ownerPass []byte, perm secur
 	}, nil
 }
 
+// newEncryptIDs generates the IDs of the trailer of a document being encrypted.
+func newEncryptIDs() (id0, id1 string) {
+	hashcode := md5.Sum([]byte(time.Now().Format(time.RFC850)))
+	id0 = string(hashcode[:])
+	b := make([]byte, 100)
+	rand.Read(b)
+	hashcode = md5.Sum(b)
+	id1 = string(hashcode[:])
+	common.Log.Trace("Random b: % x", b)
+
+	common.Log.Trace("Gen Id 0: % x", id0)
+	return id0, id1
+}
+
 // PdfCrypt provides PDF encryption/decryption support.
 // The PDF standard supports encryption of strings and streams (Section 7.6).
 type PdfCrypt struct {
 	encrypt    encryptDict
 	encryptStd security.StdEncryptDict
+	// Public-key security handler parameters (Adobe.PubSec).
+	encryptPubKey security.PubKeyEncryptDict
 
 	id0              string
 	encryptionKey    []byte
@@ -103,9 +121,10 @@ type PdfCrypt struct {
 	encryptedObjects map[PdfObject]bool
 	authenticated    bool
 	// Crypt filters (V4).
-	cryptFilters cryptFilters
-	streamFilter string
-	stringFilter string
+	cryptFilters   cryptFilters
+	streamFilter   string
+	stringFilter   string
+	embeddedFilter string // Embedded file streams filter, StmF if empty.
 
 	parser *PdfParser
 
@@ -126,6 +145,8 @@ func encodeEncryptStd(d *security.StdEncryptDict, ed *PdfObjectDictionary) {
 		if d.R > 5 {
 			ed.Set("Perms", MakeStringFromBytes(d.Perms))
 		}
+	} else if d.R == 4 && !d.EncryptMetadata {
+		ed.Set("EncryptMetadata", MakeBool(false))
 	}
 }
 
@@ -246,7 +267,11 @@ func decodeCryptFilter(cf *crypto.FilterDict, d *PdfObjectDictionary) error {
 func (crypt *PdfCrypt) newEncryptDict() *PdfObjectDictionary {
 	// Generate the encryption dictionary.
 	ed := MakeDict()
-	ed.Set("Filter", MakeName("Standard"))
+	filter := crypt.encrypt.Filter
+	if filter == "" {
+		filter = "Standard"
+	}
+	ed.Set("Filter", MakeName(filter))
 	ed.Set("V", MakeInteger(int64(crypt.encrypt.V)))
 	ed.Set("Length", MakeInteger(int64(crypt.encrypt.Length)))
 	return ed
@@ -283,6 +308,49 @@ func (crypt *PdfCrypt) String() string {
 	return str
 }
 
+// EncryptionDetails describes the encryption of a document.
+type EncryptionDetails struct {
+	Filter    string // Security handler, e.g. Standard or Adobe.PubSec.
+	SubFilter string // Format of the encryption dictionary of public-key security handlers.
+	V         int    // Version of the encryption algorithm.
+	R         int    // Revision of the standard security handler, 0 for other handlers.
+
+	// StreamFilter is the crypt filter method used for streams, e.g. V2 (RC4), AESV2 or AESV3.
+	StreamFilter string
+	// KeyLength is the length of the encryption key in bits.
+	KeyLength int
+
+	// Permissions are the access permissions granted to users. The permissions of public-key
+	// encrypted documents are those of the recipient and are only known after decryption.
+	Permissions security.Permissions
+}
+
+// GetEncryptionDetails returns the details of the encryption method used.
+func (crypt *PdfCrypt) GetEncryptionDetails() EncryptionDetails {
+	d := EncryptionDetails{
+		Filter:       crypt.encrypt.Filter,
+		SubFilter:    crypt.encrypt.SubFilter,
+		V:            crypt.encrypt.V,
+		StreamFilter: "V2",
+		KeyLength:    crypt.encrypt.Length,
+		Permissions:  crypt.GetAccessPermissions(),
+	}
+	if !crypt.isPubKey() {
+		d.R = crypt.encryptStd.R
+	}
+	if d.V == 1 || d.KeyLength == 0 {
+		d.KeyLength = 40
+	}
+	if d.V >= 4 {
+		d.StreamFilter = ""
+		if cf, ok := crypt.cryptFilters[crypt.streamFilter]; ok {
+			d.StreamFilter = cf.Name()
+			d.KeyLength = cf.KeyLength() * 8
+		}
+	}
+	return d
+}
+
 // encryptDict is a set of field common to all encryption dictionaries.
 type encryptDict struct {
 	Filter    string // (Required) The name of the preferred security handler for this document.
@@ -300,6 +368,9 @@ type encryptDict struct {
 // stdCryptFilter is a default name for a standard crypt filter.
 const stdCryptFilter = "StdCF"
 
+// cryptStreamFilter is the name of the stream filter selecting a crypt filter for the stream.
+const cryptStreamFilter = StreamEncodingFilterNameCrypt
+
 func newCryptFiltersV2(length int) cryptFilters {
 	return cryptFilters{
 		stdCryptFilter: crypto.NewFilterV2(length),
@@ -383,6 +454,15 @@ func (crypt *PdfCrypt) loadCryptFilters(ed *PdfObjectDictionary) error {
 		crypt.streamFilter = string(*stmf)
 	}
 
+	// EFF embedded files filter, StmF by default.
+	crypt.embeddedFilter = ""
+	if eff, ok := ed.Get("EFF").(*PdfObjectName); ok {
+		if _, exists := crypt.cryptFilters[string(*eff)]; !exists {
+			return fmt.Errorf("crypt filter for EFF not specified in CF dictionary (%s)", *eff)
+		}
+		crypt.embeddedFilter = string(*eff)
+	}
+
 	return nil
 }
 
@@ -415,6 +495,9 @@ func (crypt *PdfCrypt) saveCryptFilters(ed *PdfObjectDictionary) error {
 	}
 	ed.Set("StrF", MakeName(crypt.stringFilter))
 	ed.Set("StmF", MakeName(crypt.streamFilter))
+	if crypt.embeddedFilter != "" {
+		ed.Set("EFF", MakeName(crypt.embeddedFilter))
+	}
 	return nil
 }
 
@@ -434,15 +517,19 @@ func PdfCryptNewDecrypt(parser *PdfParser, ed, trailer *PdfObjectDictionary) (*P
 		common.Log.Debug("ERROR Crypt dictionary missing required Filter field!")
 		return crypter, errors.New("required crypt field Filter missing")
 	}
-	if *filter != "Standard" {
+	if *filter != "Standard" && *filter != pubKeyFilter {
 		common.Log.Debug("ERROR Unsupported filter (%s)", *filter)
 		return crypter, errors.New("unsupported Filter")
 	}
 	crypter.encrypt.Filter = string(*filter)
 
-	if subfilter, ok := ed.Get("SubFilter").(*PdfObjectString); ok {
+	switch subfilter := ed.Get("SubFilter").(type) {
+	case *PdfObjectString:
 		crypter.encrypt.SubFilter = subfilter.Str()
 		common.Log.Debug("Using subfilter %s", subfilter)
+	case *PdfObjectName:
+		crypter.encrypt.SubFilter = string(*subfilter)
+		common.Log.Debug("Using subfilter %s", subfilter)
 	}
 
 	if L, ok := ed.Get("Length").(*PdfObjectInteger); ok {
@@ -472,9 +559,16 @@ func PdfCryptNewDecrypt(parser *PdfParser, ed, trailer *PdfObjectDictionary) (*P
 		}
 	}
 
-	// decode Standard security handler parameters
-	if err := decodeEncryptStd(&crypter.encryptStd, ed); err != nil {
-		return crypter, err
+	if crypter.isPubKey() {
+		// decode public-key security handler parameters
+		if err := crypter.decodeEncryptPubKey(ed); err != nil {
+			return crypter, err
+		}
+	} else {
+		// decode Standard security handler parameters
+		if err := decodeEncryptStd(&crypter.encryptStd, ed); err != nil {
+			return crypter, err
+		}
 	}
 
 	// Default: empty ID.
@@ -497,6 +591,9 @@ func PdfCryptNewDecrypt(parser *PdfParser, ed, trailer *PdfObjectDictionary) (*P
 
 // GetAccessPermissions returns the PDF access permissions as an AccessPermissions object.
 func (crypt *PdfCrypt) GetAccessPermissions() security.Permissions {
+	if crypt.isPubKey() {
+		return crypt.encryptPubKey.P
+	}
 	return crypt.encryptStd.P
 }
 
@@ -511,6 +608,9 @@ func (crypt *PdfCrypt) securityHandler() security.StdHandler {
 // Also build the encryption/decryption key.
 func (crypt *PdfCrypt) authenticate(password []byte) (bool, error) {
 	crypt.authenticated = false
+	if crypt.isPubKey() {
+		return false, errors.New("public-key encrypted document requires a recipient certificate")
+	}
 	h := crypt.securityHandler()
 	fkey, perm, err := h.Authenticate(&crypt.encryptStd, password)
 	if err != nil {
@@ -530,6 +630,12 @@ func (crypt *PdfCrypt) authenticate(password []byte) (bool, error) {
 // The AccessPermissions shows what access the user has for editing etc.
 // An error is returned if there was a problem performing the authentication.
 func (crypt *PdfCrypt) checkAccessRights(password []byte) (bool, security.Permissions, error) {
+	if crypt.isPubKey() {
+		if !crypt.authenticated {
+			return false, 0, nil
+		}
+		return true, crypt.encryptPubKey.P, nil
+	}
 	h := crypt.securityHandler()
 	// TODO(dennwc): it computes an encryption key as well; if necessary, define a new interface method to optimize this
 	fkey, perm, err := h.Authenticate(&crypt.encryptStd, password)
@@ -596,6 +702,63 @@ func (crypt *PdfCrypt) isDecrypted(obj PdfObject) bool {
 	return false
 }
 
+// streamCryptFilter returns the name of the crypt filter used for the stream with dictionary `dict`
+// (V>=4). A Crypt filter, which can only be the first entry of the stream filters, selects the crypt
+// filter named in its decode parameters, or Identity if none is specified. Otherwise embedded files
+// use the EFF filter, metadata streams are left unencrypted if EncryptMetadata is false and other
+// streams use the StmF filter.
+func (crypt *PdfCrypt) streamCryptFilter(dict *PdfObjectDictionary) string {
+	var first, decodeParams PdfObject
+	switch filters := TraceToDirectObject(dict.Get("Filter")).(type) {
+	case *PdfObjectName:
+		first = filters
+		decodeParams = dict.Get("DecodeParms")
+	case *PdfObjectArray:
+		first = filters.Get(0)
+		decodeParams = dict.Get("DecodeParms")
+		if params, ok := GetArray(decodeParams); ok {
+			decodeParams = params.Get(0)
+		}
+	}
+	if name, ok := GetNameVal(first); ok && name == cryptStreamFilter {
+		// Crypt filter overriding the default.
+		// Default option is Identity.
+		if params, ok := GetDict(decodeParams); ok {
+			if name, ok := GetNameVal(params.Get("Name")); ok {
+				if _, ok := crypt.cryptFilters[name]; ok {
+					common.Log.Trace("Using stream filter %s", name)
+					return name
+				}
+				common.Log.Debug("ERROR: Crypt filter %s not specified in CF dictionary - using Identity", name)
+			}
+		}
+		return "Identity"
+	}
+
+	if !crypt.encryptMetadata() && isMetadataStream(dict) {
+		return "Identity"
+	}
+	if typ, ok := GetNameVal(dict.Get("Type")); ok && typ == "EmbeddedFile" && crypt.embeddedFilter != "" {
+		return crypt.embeddedFilter
+	}
+	return crypt.streamFilter
+}
+
+// encryptMetadata returns true if the document-level metadata streams are encrypted.
+func (crypt *PdfCrypt) encryptMetadata() bool {
+	if crypt.isPubKey() {
+		return crypt.encryptPubKey.EncryptMetadata
+	}
+	return crypt.encryptStd.EncryptMetadata
+}
+
+// isMetadataStream returns true if `dict` is the dictionary of an XML metadata stream.
+func isMetadataStream(dict *PdfObjectDictionary) bool {
+	typ, _ := GetNameVal(dict.Get("Type"))
+	subtype, _ := GetNameVal(dict.Get("Subtype"))
+	return typ == "Metadata" && subtype == "XML"
+}
+
 // Decrypt a buffer with a selected crypt filter.
 func (crypt *PdfCrypt) decryptBytes(buf []byte, filter string, okey []byte) ([]byte, error) {
 	common.Log.Trace("Decrypt bytes")
@@ -646,35 +809,9 @@ func (crypt *PdfCrypt) Decrypt(obj PdfObject, parentObjNum, parentGenNum int64)
 		genNum := obj.GenerationNumber
 		common.Log.Trace("Decrypting stream %d %d !", objNum, genNum)
 
-		// TODO: Check for crypt filter (V4).
-		// The Crypt filter shall be the first filter in the Filter array entry.
-
 		streamFilter := stdCryptFilter // Default RC4.
 		if crypt.encrypt.V >= 4 {
-			streamFilter = crypt.streamFilter
-			common.Log.Trace("this.streamFilter = %s", crypt.streamFilter)
-
-			if filters, ok := dict.Get("Filter").(*PdfObjectArray); ok {
-				// Crypt filter can only be the first entry.
-				if firstFilter, ok := GetName(filters.Get(0)); ok {
-					if *firstFilter == "Crypt" {
-						// Crypt filter overriding the default.
-						// Default option is Identity.
-						streamFilter = "Identity"
-
-						// Check if valid crypt filter specified in the decode params.
-						if decodeParams, ok := dict.Get("DecodeParms").(*PdfObjectDictionary); ok {
-							if filterName, ok := decodeParams.Get("Name").(*PdfObjectName); ok {
-								if _, ok := crypt.cryptFilters[string(*filterName)]; ok {
-									common.Log.Trace("Using stream filter %s", *filterName)
-									streamFilter = string(*filterName)
-								}
-							}
-						}
-					}
-				}
-			}
-
+			streamFilter = crypt.streamCryptFilter(dict)
 			common.Log.Trace("with %s filter", streamFilter)
 			if streamFilter == "Identity" {
 				// Identity: pass unchanged.
@@ -692,6 +829,9 @@ func (crypt *PdfCrypt) Decrypt(obj PdfObject, parentObjNum, parentGenNum int64)
 			return err
 		}
 
+		if err := obj.LoadData(); err != nil {
+			return err
+		}
 		obj.Stream, err = crypt.decryptBytes(obj.Stream, streamFilter, okey)
 		if err != nil {
 			return err
@@ -829,37 +969,9 @@ func (crypt *PdfCrypt) Encrypt(obj PdfObject, parentObjNum, parentGenNum int64)
 		genNum := obj.GenerationNumber
 		common.Log.Trace("Encrypting stream %d %d !", objNum, genNum)
 
-		// TODO: Check for crypt filter (V4).
-		// The Crypt filter shall be the first filter in the Filter array entry.
-
 		streamFilter := stdCryptFilter // Default RC4.
 		if crypt.encrypt.V >= 4 {
-			// For now.  Need to change when we add support for more than
-			// Identity / RC4.
-			streamFilter = crypt.streamFilter
-			common.Log.Trace("this.streamFilter = %s", crypt.streamFilter)
-
-			if filters, ok := dict.Get("Filter").(*PdfObjectArray); ok {
-				// Crypt filter can only be the first entry.
-				if firstFilter, ok := GetName(filters.Get(0)); ok {
-					if *firstFilter == "Crypt" {
-						// Crypt filter overriding the default.
-						// Default option is Identity.
-						streamFilter = "Identity"
-
-						// Check if valid crypt filter specified in the decode params.
-						if decodeParams, ok := dict.Get("DecodeParms").(*PdfObjectDictionary); ok {
-							if filterName, ok := decodeParams.Get("Name").(*PdfObjectName); ok {
-								if _, ok := crypt.cryptFilters[string(*filterName)]; ok {
-									common.Log.Trace("Using stream filter %s", *filterName)
-									streamFilter = string(*filterName)
-								}
-							}
-						}
-					}
-				}
-			}
-
+			streamFilter = crypt.streamCryptFilter(dict)
 			common.Log.Trace("with %s filter", streamFilter)
 			if streamFilter == "Identity" {
 				// Identity: pass unchanged.
@@ -877,6 +989,9 @@ func (crypt *PdfCrypt) Encrypt(obj PdfObject, parentObjNum, parentGenNum int64)
 			return err
 		}
 
+		if err := obj.LoadData(); err != nil {
+			return err
+		}
 		obj.Stream, err = crypt.encryptBytes(obj.Stream, streamFilter, okey)
 		if err != nil {
 			return err
@@ -8,12 +8,22 @@
 package core_test
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
 
 	pdfcontent "github.com/unidoc/unipdf/v3/contentstream"
 	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/core/security"
 	pdf "github.com/unidoc/unipdf/v3/model"
 )
 
@@ -135,3 +145,334 @@ func TestDecryptAES3(t *testing.T) {
 		})
 	}
 }
+
+func TestEncryptAES3RoundTrip(t *testing.T) {
+	const content = "BT /F1 12 Tf 10 10 Td (Encrypted with AES-256) Tj ET"
+	// The passwords are opened in other forms, as they are normalized with
+	// SASLprep.
+	userPass, ownerPass := "us\u00E9r", "\u212Bwner"
+
+	w := pdf.NewPdfWriter()
+	page := pdf.NewPdfPage()
+	page.MediaBox = &pdf.PdfRectangle{Urx: 200, Ury: 200}
+	require.NoError(t, page.AddContentStreamByString(content))
+	require.NoError(t, w.AddPage(page))
+	perms := security.PermPrinting | security.PermExtractGraphics
+	err := w.Encrypt([]byte(userPass), []byte(ownerPass), &pdf.EncryptOptions{
+		Permissions: perms,
+		Algorithm:   pdf.AES_256bit,
+	})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	open := func(pass string) *pdf.PdfReader {
+		reader, err := pdf.NewPdfReader(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		encrypted, err := reader.IsEncrypted()
+		require.NoError(t, err)
+		require.True(t, encrypted)
+		ok, err := reader.Decrypt([]byte(pass))
+		require.NoError(t, err)
+		require.True(t, ok)
+		return reader
+	}
+	checkContent := func(reader *pdf.PdfReader) {
+		page, err := reader.GetPage(1)
+		require.NoError(t, err)
+		contents, err := page.GetAllContentStreams()
+		require.NoError(t, err)
+		require.Contains(t, contents, content)
+	}
+
+	// Encryption dictionary, with the same layout as the one of the AES-256
+	// fixtures.
+	reader := open("usér")
+	checkContent(reader)
+	trailer, err := reader.GetTrailer()
+	require.NoError(t, err)
+	ed, ok := core.GetDict(trailer.Get("Encrypt"))
+	require.True(t, ok)
+	for key, val := range map[string]int{"V": 5, "R": 6, "Length": 256} {
+		v, ok := core.GetIntVal(ed.Get(core.PdfObjectName(key)))
+		require.True(t, ok, key)
+		require.Equal(t, val, v, key)
+	}
+	for key, length := range map[string]int{"O": 48, "U": 48, "OE": 32, "UE": 32, "Perms": 16} {
+		s, ok := core.GetString(ed.Get(core.PdfObjectName(key)))
+		require.True(t, ok, key)
+		require.Len(t, s.Bytes(), length, key)
+	}
+	cf, ok := core.GetDict(ed.Get("CF"))
+	require.True(t, ok)
+	stdCF, ok := core.GetDict(cf.Get("StdCF"))
+	require.True(t, ok)
+	require.Equal(t, "AESV3", stdCF.Get("CFM").String())
+	require.Equal(t, "StdCF", ed.Get("StmF").String())
+	require.Equal(t, "StdCF", ed.Get("StrF").String())
+
+	require.Contains(t, reader.GetEncryptionMethod(), "AESV3")
+	ok, userPerms, err := reader.CheckAccessRights([]byte(userPass))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, perms, userPerms)
+
+	// Owner password.
+	reader = open("\u00C5wner")
+	checkContent(reader)
+	ok, ownerPerms, err := reader.CheckAccessRights([]byte(ownerPass))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, security.PermOwner, ownerPerms)
+
+	// Wrong password.
+	reader, err = pdf.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	ok, err = reader.Decrypt([]byte("wrong"))
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+// newTestRecipient returns a self-signed certificate with a new RSA key pair.
+func newTestRecipient(t *testing.T, name string, serial int64) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func TestEncryptPubKeyRoundTrip(t *testing.T) {
+	const content = "BT /F1 12 Tf 10 10 Td (Encrypted to certificates) Tj ET"
+	userCert, userKey := newTestRecipient(t, "User", 1)
+	ownerCert, ownerKey := newTestRecipient(t, "Owner", 2)
+	otherCert, otherKey := newTestRecipient(t, "Other", 3)
+	userPerms := security.PermPrinting | security.PermExtractGraphics
+
+	for _, tcase := range []struct {
+		algo pdf.EncryptionAlgorithm
+		V    int
+		CFM  string
+	}{
+		{pdf.AES_128bit, 4, "AESV2"},
+		{pdf.AES_256bit, 5, "AESV3"},
+	} {
+		t.Run(tcase.CFM, func(t *testing.T) {
+			w := pdf.NewPdfWriter()
+			page := pdf.NewPdfPage()
+			page.MediaBox = &pdf.PdfRectangle{Urx: 200, Ury: 200}
+			require.NoError(t, page.AddContentStreamByString(content))
+			require.NoError(t, w.AddPage(page))
+			err := w.EncryptWithCertificates([]security.PubKeyRecipientGroup{
+				{Certificates: []*x509.Certificate{userCert}, Permissions: userPerms},
+				{Certificates: []*x509.Certificate{ownerCert}, Permissions: security.PermOwner},
+			}, tcase.algo)
+			require.NoError(t, err)
+			var buf bytes.Buffer
+			require.NoError(t, w.Write(&buf))
+
+			open := func() *pdf.PdfReader {
+				reader, err := pdf.NewPdfReader(bytes.NewReader(buf.Bytes()))
+				require.NoError(t, err)
+				encrypted, err := reader.IsEncrypted()
+				require.NoError(t, err)
+				require.True(t, encrypted)
+				return reader
+			}
+			decrypt := func(cert *x509.Certificate, key *rsa.PrivateKey) *pdf.PdfReader {
+				reader := open()
+				ok, err := reader.DecryptWithCertificate(cert, key)
+				require.NoError(t, err)
+				require.True(t, ok)
+				page, err := reader.GetPage(1)
+				require.NoError(t, err)
+				contents, err := page.GetAllContentStreams()
+				require.NoError(t, err)
+				require.Contains(t, contents, content)
+				return reader
+			}
+
+			// Encryption dictionary.
+			reader := decrypt(userCert, userKey)
+			trailer, err := reader.GetTrailer()
+			require.NoError(t, err)
+			ed, ok := core.GetDict(trailer.Get("Encrypt"))
+			require.True(t, ok)
+			require.Equal(t, "Adobe.PubSec", ed.Get("Filter").String())
+			require.Equal(t, "adbe.pkcs7.s5", ed.Get("SubFilter").String())
+			V, ok := core.GetIntVal(ed.Get("V"))
+			require.True(t, ok)
+			require.Equal(t, tcase.V, V)
+			cf, ok := core.GetDict(ed.Get("CF"))
+			require.True(t, ok)
+			defaultCF, ok := core.GetDict(cf.Get("DefaultCryptFilter"))
+			require.True(t, ok)
+			require.Equal(t, tcase.CFM, defaultCF.Get("CFM").String())
+			recipients, ok := core.GetArray(defaultCF.Get("Recipients"))
+			require.True(t, ok)
+			require.Equal(t, 2, recipients.Len())
+			require.Equal(t, "DefaultCryptFilter", ed.Get("StmF").String())
+			require.Equal(t, "DefaultCryptFilter", ed.Get("StrF").String())
+
+			// Each recipient group has its own permissions.
+			ok, perms, err := reader.CheckAccessRights(nil)
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.Equal(t, userPerms, perms)
+			reader = decrypt(ownerCert, ownerKey)
+			ok, perms, err = reader.CheckAccessRights(nil)
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.Equal(t, security.PermOwner, perms)
+
+			// Not a recipient.
+			reader = open()
+			ok, err = reader.DecryptWithCertificate(otherCert, otherKey)
+			require.NoError(t, err)
+			require.False(t, ok)
+
+			// The certificate of a recipient with another key.
+			reader = open()
+			_, err = reader.DecryptWithCertificate(userCert, otherKey)
+			require.Error(t, err)
+
+			// Passwords cannot be used.
+			reader = open()
+			_, err = reader.Decrypt([]byte(""))
+			require.Error(t, err)
+		})
+	}
+
+	// The metadata can be left unencrypted.
+	const title = "Unencrypted metadata title"
+	w := pdf.NewPdfWriter()
+	page := pdf.NewPdfPage()
+	page.MediaBox = &pdf.PdfRectangle{Urx: 200, Ury: 200}
+	require.NoError(t, page.AddContentStreamByString(content))
+	require.NoError(t, w.AddPage(page))
+	xmp := pdf.NewXMPMetadata()
+	xmp.Title = title
+	require.NoError(t, w.SetXMPMetadata(xmp))
+	err := w.EncryptWithCertificatesOptions([]security.PubKeyRecipientGroup{
+		{Certificates: []*x509.Certificate{userCert}, Permissions: userPerms},
+	}, &pdf.EncryptOptions{UnencryptedMetadata: true, Algorithm: pdf.AES_128bit})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	require.True(t, bytes.Contains(buf.Bytes(), []byte(title)))
+	require.False(t, bytes.Contains(buf.Bytes(), []byte(content)))
+
+	reader, err := pdf.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	ok, err := reader.DecryptWithCertificate(userCert, userKey)
+	require.NoError(t, err)
+	require.True(t, ok)
+	xmp, err = reader.GetXMPMetadata()
+	require.NoError(t, err)
+	require.Equal(t, title, xmp.Title)
+}
+
+func TestEncryptCryptFilterStreams(t *testing.T) {
+	const (
+		identityContent = "BT /F1 12 Tf 10 10 Td (Identity crypt filter) Tj ET"
+		namedContent    = "BT /F1 12 Tf 10 30 Td (Named crypt filter) Tj ET"
+		title           = "Unencrypted metadata title"
+		userPass        = "user"
+	)
+	identityData, err := core.NewFlateEncoder().EncodeBytes([]byte(identityContent))
+	require.NoError(t, err)
+
+	// write encrypts the pages and the XMP metadata, leaving the metadata unencrypted.
+	write := func(pages []*pdf.PdfPage, xmp *pdf.XMPMetadata, algo pdf.EncryptionAlgorithm) []byte {
+		w := pdf.NewPdfWriter()
+		for _, page := range pages {
+			require.NoError(t, w.AddPage(page))
+		}
+		require.NoError(t, w.SetXMPMetadata(xmp))
+		require.NoError(t, w.Encrypt([]byte(userPass), []byte("owner"), &pdf.EncryptOptions{
+			Permissions:         security.PermOwner,
+			Algorithm:           algo,
+			UnencryptedMetadata: true,
+		}))
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+		return buf.Bytes()
+	}
+	// check checks the encrypted file `data` and returns its decrypted reader.
+	check := func(data []byte) *pdf.PdfReader {
+		// Identity crypt filter and metadata streams are stored as is, the
+		// stream with the named crypt filter is encrypted.
+		require.True(t, bytes.Contains(data, identityData))
+		require.True(t, bytes.Contains(data, []byte(title)))
+		require.False(t, bytes.Contains(data, []byte(namedContent)))
+
+		reader, err := pdf.NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		ok, err := reader.Decrypt([]byte(userPass))
+		require.NoError(t, err)
+		require.True(t, ok)
+		trailer, err := reader.GetTrailer()
+		require.NoError(t, err)
+		ed, ok := core.GetDict(trailer.Get("Encrypt"))
+		require.True(t, ok)
+		encryptMetadata, ok := core.GetBoolVal(ed.Get("EncryptMetadata"))
+		require.True(t, ok)
+		require.False(t, encryptMetadata)
+
+		page, err := reader.GetPage(1)
+		require.NoError(t, err)
+		contents, err := page.GetAllContentStreams()
+		require.NoError(t, err)
+		require.Contains(t, contents, identityContent)
+		require.Contains(t, contents, namedContent)
+		xmp, err := reader.GetXMPMetadata()
+		require.NoError(t, err)
+		require.NotNil(t, xmp)
+		require.Equal(t, title, xmp.Title)
+		return reader
+	}
+
+	for _, algo := range []pdf.EncryptionAlgorithm{pdf.AES_128bit, pdf.AES_256bit} {
+		page := pdf.NewPdfPage()
+		page.MediaBox = &pdf.PdfRectangle{Urx: 200, Ury: 200}
+		identity, err := core.MakeStream(identityData, core.NewRawEncoder())
+		require.NoError(t, err)
+		params := core.MakeDict()
+		params.Set("Name", core.MakeName("Identity"))
+		identity.Set("Filter", core.MakeArray(core.MakeName("Crypt"), core.MakeName("FlateDecode")))
+		identity.Set("DecodeParms", core.MakeArray(params, core.MakeNull()))
+		named, err := core.MakeStream([]byte(namedContent), core.NewRawEncoder())
+		require.NoError(t, err)
+		params = core.MakeDict()
+		params.Set("Name", core.MakeName("StdCF"))
+		named.Set("Filter", core.MakeName("Crypt"))
+		named.Set("DecodeParms", params)
+		page.Contents = core.MakeArray(identity, named)
+		xmp := pdf.NewXMPMetadata()
+		xmp.Title = title
+
+		reader := check(write([]*pdf.PdfPage{page}, xmp, algo))
+
+		// Re-encode the decrypted document.
+		xmp, err = reader.GetXMPMetadata()
+		require.NoError(t, err)
+		check(write(reader.PageList, xmp, algo))
+	}
+
+	// Metadata can only be left unencrypted with crypt filters.
+	w := pdf.NewPdfWriter()
+	err = w.Encrypt([]byte(userPass), nil, &pdf.EncryptOptions{
+		Algorithm:           pdf.RC4_128bit,
+		UnencryptedMetadata: true,
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,182 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package core
+
+import (
+	gocrypto "crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core/security"
+	crypto "github.com/unidoc/unipdf/v3/core/security/crypt"
+)
+
+const (
+	// pubKeyFilter is the name of the public-key security handler.
+	pubKeyFilter = "Adobe.PubSec"
+	// pubKeySubFilterS5 stores the recipients in the crypt filters (V>=4).
+	pubKeySubFilterS5 = "adbe.pkcs7.s5"
+	// pubKeyCryptFilter is a default name for a public-key crypt filter.
+	pubKeyCryptFilter = "DefaultCryptFilter"
+)
+
+// PdfCryptNewEncryptPubKey makes the document crypt handler of the public-key security handler
+// based on a specified crypt filter. The document is encrypted to the certificates of the
+// recipient `groups`, with the access permissions of each group. Only AESV2 and AESV3 crypt
+// filters are supported.
+func PdfCryptNewEncryptPubKey(cf crypto.Filter, groups []security.PubKeyRecipientGroup) (*PdfCrypt, *EncryptInfo, error) {
+	return PdfCryptNewEncryptPubKeyMetadata(cf, groups, true)
+}
+
+// PdfCryptNewEncryptPubKeyMetadata makes the document crypt handler of the public-key security
+// handler, as PdfCryptNewEncryptPubKey. The metadata streams of the document are left unencrypted
+// if `encryptMetadata` is false.
+func PdfCryptNewEncryptPubKeyMetadata(cf crypto.Filter, groups []security.PubKeyRecipientGroup,
+	encryptMetadata bool) (*PdfCrypt, *EncryptInfo, error) {
+	if cf == nil {
+		return nil, nil, errors.New("crypt filter required")
+	}
+	V, _ := cf.HandlerVersion()
+	if V < 4 {
+		return nil, nil, fmt.Errorf("unsupported crypt filter for public-key encryption: %s", cf.Name())
+	}
+	crypter := &PdfCrypt{
+		encryptedObjects: make(map[PdfObject]bool),
+		cryptFilters:     cryptFilters{pubKeyCryptFilter: cf},
+		encryptPubKey: security.PubKeyEncryptDict{
+			EncryptMetadata: encryptMetadata,
+		},
+		streamFilter: pubKeyCryptFilter,
+		stringFilter: pubKeyCryptFilter,
+	}
+	crypter.encrypt.Filter = pubKeyFilter
+	crypter.encrypt.SubFilter = pubKeySubFilterS5
+	crypter.encrypt.V = V
+	crypter.encrypt.Length = cf.KeyLength() * 8
+	var vers Version
+	v := cf.PDFVersion()
+	vers.Major, vers.Minor = v[0], v[1]
+
+	ekey, err := crypter.pubKeyHandler().GenerateParams(&crypter.encryptPubKey, groups)
+	if err != nil {
+		return nil, nil, err
+	}
+	crypter.encryptionKey = ekey
+
+	ed := crypter.newEncryptDict()
+	ed.Set("SubFilter", MakeName(crypter.encrypt.SubFilter))
+	if err := crypter.saveCryptFilters(ed); err != nil {
+		return nil, nil, err
+	}
+	cfDict, ok := GetDict(ed.Get("CF"))
+	if !ok {
+		return nil, nil, errors.New("missing CF dictionary")
+	}
+	filterDict, ok := GetDict(cfDict.Get(pubKeyCryptFilter))
+	if !ok {
+		return nil, nil, errors.New("missing public-key crypt filter")
+	}
+	recipients := MakeArray()
+	for _, env := range crypter.encryptPubKey.Recipients {
+		recipients.Append(MakeHexString(string(env)))
+	}
+	filterDict.Set("Recipients", recipients)
+	filterDict.Set("EncryptMetadata", MakeBool(crypter.encryptPubKey.EncryptMetadata))
+
+	id0, id1 := newEncryptIDs()
+	crypter.id0 = id0
+	return crypter, &EncryptInfo{
+		Version: vers,
+		Encrypt: ed,
+		ID0:     id0, ID1: id1,
+	}, nil
+}
+
+// isPubKey returns true if the document is encrypted with the public-key security handler.
+func (crypt *PdfCrypt) isPubKey() bool {
+	return crypt.encrypt.Filter == pubKeyFilter
+}
+
+// pubKeyHandler returns the public-key security handler for the document's key length.
+func (crypt *PdfCrypt) pubKeyHandler() security.PubKeyHandler {
+	length := crypt.encrypt.Length / 8
+	if cf, ok := crypt.cryptFilters[crypt.streamFilter]; ok && cf.KeyLength() > 0 {
+		length = cf.KeyLength()
+	}
+	return security.PubKeyHandler{KeyLength: length}
+}
+
+// decodeEncryptPubKey decodes fields of public-key security handler from an Encrypt dictionary.
+// The recipients are loaded from the Encrypt dictionary (adbe.pkcs7.s4), or from the default
+// stream crypt filter if they are stored in crypt filters (adbe.pkcs7.s5, V>=4).
+func (crypt *PdfCrypt) decodeEncryptPubKey(ed *PdfObjectDictionary) error {
+	d := &crypt.encryptPubKey
+	src := ed
+	if crypt.encrypt.V >= 4 && ed.Get("Recipients") == nil {
+		cf, ok := GetDict(crypt.resolve(ed.Get("CF")))
+		if !ok {
+			return errors.New("invalid CF")
+		}
+		src, ok = GetDict(crypt.resolve(cf.Get(PdfObjectName(crypt.streamFilter))))
+		if !ok {
+			return fmt.Errorf("crypt filter for StmF not specified in CF dictionary (%s)", crypt.streamFilter)
+		}
+	}
+
+	recipients, ok := GetArray(crypt.resolve(src.Get("Recipients")))
+	if !ok {
+		return errors.New("encrypt dictionary missing Recipients")
+	}
+	d.Recipients = nil
+	for _, obj := range recipients.Elements() {
+		env, ok := GetString(crypt.resolve(obj))
+		if !ok {
+			return errors.New("invalid Recipients entry")
+		}
+		d.Recipients = append(d.Recipients, env.Bytes())
+	}
+
+	if em, ok := GetBool(src.Get("EncryptMetadata")); ok {
+		d.EncryptMetadata = bool(*em)
+	} else {
+		d.EncryptMetadata = true // True by default.
+	}
+	return nil
+}
+
+// resolve returns the direct object of `obj`, looking up references with the parser.
+func (crypt *PdfCrypt) resolve(obj PdfObject) PdfObject {
+	if ref, isRef := obj.(*PdfObjectReference); isRef && crypt.parser != nil {
+		o, err := crypt.parser.LookupByReference(*ref)
+		if err != nil {
+			common.Log.Debug("Error looking up reference %s: %v", ref, err)
+			return nil
+		}
+		obj = o
+	}
+	return TraceToDirectObject(obj)
+}
+
+// authenticatePubKey checks whether the recipient certificate `cert` and its private key `key`
+// can be used to decrypt the document. Also builds the encryption/decryption key.
+func (crypt *PdfCrypt) authenticatePubKey(cert *x509.Certificate, key gocrypto.Decrypter) (bool, error) {
+	crypt.authenticated = false
+	if !crypt.isPubKey() {
+		return false, errors.New("document is not encrypted with the public-key security handler")
+	}
+	fkey, perm, err := crypt.pubKeyHandler().Authenticate(&crypt.encryptPubKey, cert, key)
+	if err != nil {
+		return false, err
+	} else if perm == 0 || len(fkey) == 0 {
+		return false, nil
+	}
+	crypt.authenticated = true
+	crypt.encryptionKey = fkey
+	crypt.encryptPubKey.P = perm
+	return true, nil
+}
@@ -0,0 +1,116 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ParsePdfDate parses the PDF date string `s` (see 7.9.4 Dates), of the form
+// D:YYYYMMDDHHmmSSOHH'mm', where all fields after the year are optional, O being the relationship
+// of the local time to UT: '+', '-' or 'Z'. The missing month and day default to 01, and the
+// missing time fields to 00. Dates without UT relationship are considered to be UT.
+//
+// Common deviations are tolerated: missing D: prefix or apostrophes, whitespace, offsets written
+// as +HHmm or +HH:mm, fractional seconds and trailing garbage. An error is returned if the year is
+// missing or if a field is out of range.
+func ParsePdfDate(s string) (time.Time, error) {
+	str := strings.TrimSpace(s)
+	if strings.HasPrefix(str, "D") {
+		if rest := strings.TrimSpace(str[1:]); strings.HasPrefix(rest, ":") {
+			str = strings.TrimSpace(rest[1:])
+		}
+	}
+
+	digits := 0
+	for digits < len(str) && str[digits] >= '0' && str[digits] <= '9' {
+		digits++
+	}
+	if digits < 4 {
+		return time.Time{}, fmt.Errorf("invalid date string (%s): missing year", s)
+	}
+
+	// Year, month, day, hour, minute and second. An odd trailing digit is ignored.
+	fields := []int{0, 1, 1, 0, 0, 0}
+	fields[0] = atoiDigits(str[:4])
+	for i, pos := 1, 4; i < len(fields) && pos+2 <= digits; i, pos = i+1, pos+2 {
+		fields[i] = atoiDigits(str[pos : pos+2])
+	}
+	year, month, day, hour, minute, second := fields[0], fields[1], fields[2], fields[3], fields[4], fields[5]
+	if month < 1 || month > 12 ||
+		day < 1 || day > time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day() ||
+		hour > 23 || minute > 59 || second > 60 {
+		return time.Time{}, fmt.Errorf("invalid date string (%s): field out of range", s)
+	}
+	if second == 60 {
+		// Leap second.
+		second = 59
+	}
+
+	// Skip the digits beyond the seconds and the fractional seconds.
+	rest := str[digits:]
+	if strings.HasPrefix(rest, ".") {
+		rest = strings.TrimLeft(rest[1:], "0123456789")
+	}
+	rest = strings.TrimSpace(rest)
+
+	loc := time.UTC
+	if len(rest) > 0 && (rest[0] == '+' || rest[0] == '-') {
+		sign := 1
+		if rest[0] == '-' {
+			sign = -1
+		}
+		rest = rest[1:]
+		var offset [2]int
+		for i := range offset {
+			rest = strings.TrimLeft(rest, "':")
+			n := 0
+			for n < len(rest) && n < 2 && rest[n] >= '0' && rest[n] <= '9' {
+				n++
+			}
+			if n == 0 {
+				break
+			}
+			offset[i] = atoiDigits(rest[:n])
+			rest = rest[n:]
+		}
+		if offset[0] > 23 || offset[1] > 59 {
+			return time.Time{}, fmt.Errorf("invalid date string (%s): offset out of range", s)
+		}
+		loc = time.FixedZone("", sign*(offset[0]*3600+offset[1]*60))
+	}
+
+	return time.Date(year, time.Month(month), day, hour, minute, second, 0, loc), nil
+}
+
+// FormatPdfDate returns the PDF date string (see 7.9.4 Dates) of `t`, in the time zone of `t`,
+// e.g. D:20230115120000+05'30', or D:20230115063000Z for UT. The fractional seconds and the
+// seconds of the offset, if any, are dropped.
+func FormatPdfDate(t time.Time) string {
+	_, offset := t.Zone()
+	date := fmt.Sprintf("D:%.4d%.2d%.2d%.2d%.2d%.2d",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second())
+	if offset == 0 {
+		return date + "Z"
+	}
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return date + fmt.Sprintf("%c%.2d'%.2d'", sign, offset/3600, offset%3600/60)
+}
+
+// atoiDigits returns the value of the decimal digits `s`.
+func atoiDigits(s string) int {
+	val := 0
+	for i := 0; i < len(s); i++ {
+		val = val*10 + int(s[i]-'0')
+	}
+	return val
+}
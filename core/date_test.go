@@ -0,0 +1,93 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePdfDate(t *testing.T) {
+	ist := time.FixedZone("", 5*3600+30*60)
+	pst := time.FixedZone("", -8*3600)
+	testcases := []struct {
+		date     string
+		expected time.Time
+	}{
+		// Spec grammar.
+		{"D:20230115120000+05'30'", time.Date(2023, 1, 15, 12, 0, 0, 0, ist)},
+		{"D:20230115120000-08'00'", time.Date(2023, 1, 15, 12, 0, 0, 0, pst)},
+		{"D:20230115120000Z", time.Date(2023, 1, 15, 12, 0, 0, 0, time.UTC)},
+		{"D:20230115120000Z00'00'", time.Date(2023, 1, 15, 12, 0, 0, 0, time.UTC)},
+		{"D:20230115120000+05'30", time.Date(2023, 1, 15, 12, 0, 0, 0, ist)},
+		{"D:20230115120000+05", time.Date(2023, 1, 15, 12, 0, 0, 0, time.FixedZone("", 5*3600))},
+		{"D:202301151200", time.Date(2023, 1, 15, 12, 0, 0, 0, time.UTC)},
+		{"D:20230115", time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"D:202301", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"D:2023", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+
+		// Real-world deviations.
+		{"D:20230115120000+0530", time.Date(2023, 1, 15, 12, 0, 0, 0, ist)},
+		{"D:20230115120000+05:30", time.Date(2023, 1, 15, 12, 0, 0, 0, ist)},
+		{"D:20061023115457-04'", time.Date(2006, 10, 23, 11, 54, 57, 0, time.FixedZone("", -4*3600))},
+		{"20230115120000+05'30'", time.Date(2023, 1, 15, 12, 0, 0, 0, ist)},
+		{" D: 20230115120000 +05'30' ", time.Date(2023, 1, 15, 12, 0, 0, 0, ist)},
+		{"D:20230115120000.123-08'00'", time.Date(2023, 1, 15, 12, 0, 0, 0, pst)},
+		{"D:20230115120000-08'00'garbage", time.Date(2023, 1, 15, 12, 0, 0, 0, pst)},
+		{"D:20230115120000 GMT", time.Date(2023, 1, 15, 12, 0, 0, 0, time.UTC)},
+		{"D:2023011512000", time.Date(2023, 1, 15, 12, 0, 0, 0, time.UTC)},
+		{"D:20161231235960Z", time.Date(2016, 12, 31, 23, 59, 59, 0, time.UTC)},
+		{"D:20240229000000Z", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range testcases {
+		date, err := ParsePdfDate(tc.date)
+		require.NoError(t, err, tc.date)
+		require.True(t, tc.expected.Equal(date), "%s: %v != %v", tc.date, date, tc.expected)
+		_, offset := date.Zone()
+		_, expectedOffset := tc.expected.Zone()
+		require.Equal(t, expectedOffset, offset, tc.date)
+	}
+
+	for _, date := range []string{
+		"", "D:", "D:202", "garbage", "D:20231315120000Z", "D:20230132120000Z",
+		"D:20230229120000Z", "D:20230115250000Z", "D:20230115126000Z", "D:20230115120000+24'00'",
+	} {
+		_, err := ParsePdfDate(date)
+		require.Error(t, err, date)
+	}
+}
+
+func TestFormatPdfDate(t *testing.T) {
+	require.Equal(t, "D:20230115120000+05'30'",
+		FormatPdfDate(time.Date(2023, 1, 15, 12, 0, 0, 0, time.FixedZone("IST", 5*3600+30*60))))
+	require.Equal(t, "D:20230115120000-03'30'",
+		FormatPdfDate(time.Date(2023, 1, 15, 12, 0, 0, 0, time.FixedZone("NST", -3*3600-30*60))))
+	require.Equal(t, "D:20230115120000Z", FormatPdfDate(time.Date(2023, 1, 15, 12, 0, 0, 999, time.UTC)))
+
+	// Round trip across time zones.
+	base := time.Date(2023, 3, 26, 1, 30, 15, 0, time.UTC)
+	for _, name := range []string{"UTC", "Asia/Kolkata", "America/St_Johns", "Europe/London",
+		"Pacific/Chatham", "Pacific/Kiritimati", "Pacific/Pago_Pago"} {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Logf("Skipping %s: %v", name, err)
+			continue
+		}
+		for _, offset := range []time.Duration{0, time.Hour, 183 * 24 * time.Hour} {
+			expected := base.Add(offset).In(loc)
+			formatted := FormatPdfDate(expected)
+			date, err := ParsePdfDate(formatted)
+			require.NoError(t, err)
+			require.True(t, expected.Equal(date), "%s: %v != %v", formatted, date, expected)
+			_, parsedOffset := date.Zone()
+			_, expectedOffset := expected.Zone()
+			require.Equal(t, expectedOffset, parsedOffset, formatted)
+			require.Equal(t, formatted, FormatPdfDate(date))
+		}
+	}
+}
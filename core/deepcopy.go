@@ -0,0 +1,361 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package core
+
+import (
+	"bytes"
+)
+
+// DeepCopy returns a deep copy of `obj`, so that either `obj` or its copy can be modified without
+// affecting the other. Objects occurring several times in the object graph of `obj`, including
+// cyclic graphs, are copied once, preserving the structure of the graph.
+//
+// The references, indirect objects and streams whose object numbers are in `refRemap` are replaced
+// with the mapped objects, which are not copied. This allows copying objects to another document
+// in which some of the objects they refer to (e.g. pages) already have counterparts. The other
+// references refer to the same objects as the original references, and the copies of indirect
+// objects and streams keep the object numbers of the originals. Pass nil for `refRemap` in order
+// to copy the objects without remapping.
+//
+// NOTE: Objects of types not defined in this package are not copied.
+func DeepCopy(obj PdfObject, refRemap map[int64]PdfObject) PdfObject {
+	c := deepCopier{
+		copies:   map[PdfObject]PdfObject{},
+		refRemap: refRemap,
+	}
+	return c.copy(obj)
+}
+
+// deepCopier holds the state of a deep copy.
+type deepCopier struct {
+	copies   map[PdfObject]PdfObject
+	refRemap map[int64]PdfObject
+}
+
+// remap returns the object mapped to the object number of `ref`, if any.
+func (c deepCopier) remap(ref PdfObjectReference) (PdfObject, bool) {
+	if ref.ObjectNumber == 0 {
+		return nil, false
+	}
+	obj, ok := c.refRemap[ref.ObjectNumber]
+	return obj, ok
+}
+
+// copy returns the copy of `obj`, copying it if not copied yet.
+func (c deepCopier) copy(obj PdfObject) PdfObject {
+	if obj == nil {
+		return nil
+	}
+	if cp, ok := c.copies[obj]; ok {
+		return cp
+	}
+
+	var cp PdfObject
+	switch t := obj.(type) {
+	case *PdfObjectReference:
+		if mapped, ok := c.remap(*t); ok {
+			return mapped
+		}
+		ref := *t
+		cp = &ref
+	case *PdfIndirectObject:
+		if mapped, ok := c.remap(t.PdfObjectReference); ok {
+			return mapped
+		}
+		ind := &PdfIndirectObject{PdfObjectReference: t.PdfObjectReference}
+		c.copies[obj] = ind
+		ind.PdfObject = c.copy(t.PdfObject)
+		return ind
+	case *PdfObjectStream:
+		if mapped, ok := c.remap(t.PdfObjectReference); ok {
+			return mapped
+		}
+		stream := &PdfObjectStream{PdfObjectReference: t.PdfObjectReference, lazy: t.copyLazyData()}
+		if t.Stream != nil {
+			stream.Stream = make([]byte, len(t.Stream))
+			copy(stream.Stream, t.Stream)
+		}
+		c.copies[obj] = stream
+		if t.PdfObjectDictionary != nil {
+			stream.PdfObjectDictionary = c.copy(t.PdfObjectDictionary).(*PdfObjectDictionary)
+		}
+		return stream
+	case *PdfObjectStreams:
+		if mapped, ok := c.remap(t.PdfObjectReference); ok {
+			return mapped
+		}
+		streams := &PdfObjectStreams{PdfObjectReference: t.PdfObjectReference}
+		c.copies[obj] = streams
+		for _, elem := range t.vec {
+			streams.vec = append(streams.vec, c.copy(elem))
+		}
+		return streams
+	case *PdfObjectDictionary:
+		dict := MakeDict()
+		dict.parser = t.parser
+		c.copies[obj] = dict
+		for _, key := range t.keys {
+			dict.Set(key, c.copy(t.dict[key]))
+		}
+		return dict
+	case *PdfObjectArray:
+		arr := &PdfObjectArray{}
+		c.copies[obj] = arr
+		for _, elem := range t.vec {
+			arr.vec = append(arr.vec, c.copy(elem))
+		}
+		return arr
+	case *PdfObjectString:
+		str := *t
+		cp = &str
+	case *PdfObjectName:
+		name := *t
+		cp = &name
+	case *PdfObjectInteger:
+		val := *t
+		cp = &val
+	case *PdfObjectFloat:
+		val := *t
+		cp = &val
+	case *PdfObjectBool:
+		val := *t
+		cp = &val
+	case *PdfObjectNull:
+		cp = MakeNull()
+	default:
+		return obj
+	}
+	c.copies[obj] = cp
+	return cp
+}
+
+// Equal returns true if `a` and `b` are semantically equal, i.e. represent the same PDF object
+// regardless of how it is written:
+//   - numbers are compared by value, regardless of their types (1 equals 1.0),
+//   - strings are compared by value, regardless of their literal or hexadecimal form,
+//   - dictionaries are compared regardless of the order of their keys,
+//   - streams are compared by decoded data, their dictionaries being compared without the Length,
+//     Filter and DecodeParms entries. Streams which cannot be decoded are equal only if their
+//     encoded data and dictionaries are.
+//
+// References are compared by object and generation numbers. So are the indirect objects and
+// streams nested in `a` and `b` which have object numbers (e.g. parsed from a document), the
+// object numbers identifying the objects of a document. The other indirect objects are compared by
+// contents, including cyclic object graphs.
+func Equal(a, b PdfObject) bool {
+	c := objectComparer{visited: map[[2]PdfObject]struct{}{}}
+	return c.equal(a, b, true)
+}
+
+// streamEncodingKeys are the entries of stream dictionaries describing the encoding of the data.
+var streamEncodingKeys = map[PdfObjectName]struct{}{"Length": {}, "Filter": {}, "DecodeParms": {}}
+
+// objectComparer holds the state of a comparison of object graphs.
+type objectComparer struct {
+	// visited contains the pairs of objects compared by contents. Pairs which are compared again
+	// (cycles) are assumed equal, as their comparison is in progress or completed successfully.
+	visited map[[2]PdfObject]struct{}
+}
+
+// objectIdentity returns the reference identifying `obj`, if it is a reference or a numbered
+// indirect object or stream.
+func objectIdentity(obj PdfObject) (PdfObjectReference, bool) {
+	var ref PdfObjectReference
+	switch t := obj.(type) {
+	case *PdfObjectReference:
+		return *t, true
+	case *PdfIndirectObject:
+		ref = t.PdfObjectReference
+	case *PdfObjectStream:
+		ref = t.PdfObjectReference
+	case *PdfObjectStreams:
+		ref = t.PdfObjectReference
+	default:
+		return ref, false
+	}
+	return ref, ref.ObjectNumber != 0
+}
+
+// visit marks the pair (`a`, `b`) as visited and returns true if it was visited already.
+func (c objectComparer) visit(a, b PdfObject) bool {
+	key := [2]PdfObject{a, b}
+	if _, ok := c.visited[key]; ok {
+		return true
+	}
+	c.visited[key] = struct{}{}
+	return false
+}
+
+// equal returns true if `a` and `b` are semantically equal. `top` is true for the objects passed
+// to Equal, which are compared by contents even when numbered.
+func (c objectComparer) equal(a, b PdfObject, top bool) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if a == b {
+		return true
+	}
+
+	refA, idA := objectIdentity(a)
+	refB, idB := objectIdentity(b)
+	_, isRefA := a.(*PdfObjectReference)
+	_, isRefB := b.(*PdfObjectReference)
+	if isRefA || isRefB || (!top && (idA || idB)) {
+		if top && !(isRefA && isRefB) {
+			// Indirect objects passed to Equal are compared by contents.
+			return false
+		}
+		return idA && idB && refA.ObjectNumber == refB.ObjectNumber &&
+			refA.GenerationNumber == refB.GenerationNumber
+	}
+
+	switch t := a.(type) {
+	case *PdfObjectInteger, *PdfObjectFloat:
+		switch b.(type) {
+		case *PdfObjectInteger, *PdfObjectFloat:
+			valA, _ := GetNumberAsFloat(a)
+			valB, _ := GetNumberAsFloat(b)
+			return valA == valB
+		}
+		return false
+	case *PdfObjectString:
+		s, ok := b.(*PdfObjectString)
+		return ok && t.val == s.val
+	case *PdfObjectName:
+		name, ok := b.(*PdfObjectName)
+		return ok && *t == *name
+	case *PdfObjectBool:
+		val, ok := b.(*PdfObjectBool)
+		return ok && *t == *val
+	case *PdfObjectNull:
+		_, ok := b.(*PdfObjectNull)
+		return ok
+	case *PdfIndirectObject:
+		ind, ok := b.(*PdfIndirectObject)
+		if !ok {
+			return false
+		}
+		if c.visit(a, b) {
+			return true
+		}
+		return c.equal(t.PdfObject, ind.PdfObject, false)
+	case *PdfObjectArray:
+		arr, ok := b.(*PdfObjectArray)
+		if !ok {
+			return false
+		}
+		if c.visit(a, b) {
+			return true
+		}
+		return c.equalElements(t.vec, arr.vec)
+	case *PdfObjectStreams:
+		streams, ok := b.(*PdfObjectStreams)
+		if !ok {
+			return false
+		}
+		if c.visit(a, b) {
+			return true
+		}
+		return c.equalElements(t.vec, streams.vec)
+	case *PdfObjectDictionary:
+		dict, ok := b.(*PdfObjectDictionary)
+		if !ok {
+			return false
+		}
+		if c.visit(a, b) {
+			return true
+		}
+		return c.equalDicts(t, dict, nil)
+	case *PdfObjectStream:
+		stream, ok := b.(*PdfObjectStream)
+		if !ok {
+			return false
+		}
+		if c.visit(a, b) {
+			return true
+		}
+		return c.equalStreams(t, stream)
+	}
+
+	// Objects of types not defined in this package.
+	return a.WriteString() == b.WriteString()
+}
+
+// equalElements returns true if the arrays of objects `a` and `b` are semantically equal.
+func (c objectComparer) equalElements(a, b []PdfObject) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !c.equal(a[i], b[i], false) {
+			return false
+		}
+	}
+	return true
+}
+
+// equalDicts returns true if the dictionaries `a` and `b` are semantically equal, except for the
+// entries in `skip`.
+func (c objectComparer) equalDicts(a, b *PdfObjectDictionary, skip map[PdfObjectName]struct{}) bool {
+	count := 0
+	for _, key := range a.keys {
+		if _, ok := skip[key]; ok {
+			continue
+		}
+		valB, ok := b.dict[key]
+		if !ok || !c.equal(a.dict[key], valB, false) {
+			return false
+		}
+		count++
+	}
+	for _, key := range b.keys {
+		if _, ok := skip[key]; !ok {
+			count--
+		}
+	}
+	return count == 0
+}
+
+// equalStreams returns true if the streams `a` and `b` are semantically equal.
+func (c objectComparer) equalStreams(a, b *PdfObjectStream) bool {
+	if a.LoadData() != nil || b.LoadData() != nil {
+		return false
+	}
+	dictA, dictB := a.PdfObjectDictionary, b.PdfObjectDictionary
+	if dictA == nil {
+		dictA = MakeDict()
+	}
+	if dictB == nil {
+		dictB = MakeDict()
+	}
+	if !c.equalDicts(dictA, dictB, streamEncodingKeys) {
+		return false
+	}
+
+	// Streams with the same encoded data and encoding need not be decoded.
+	if bytes.Equal(a.Stream, b.Stream) && c.equal(dictA.Get("Filter"), dictB.Get("Filter"), false) &&
+		c.equal(dictA.Get("DecodeParms"), dictB.Get("DecodeParms"), false) {
+		return true
+	}
+	dataA, err := decodeStreamData(a)
+	if err != nil {
+		return false
+	}
+	dataB, err := decodeStreamData(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(dataA, dataB)
+}
+
+// decodeStreamData returns the decoded data of `stream`, which is not encoded if the stream has no
+// dictionary.
+func decodeStreamData(stream *PdfObjectStream) ([]byte, error) {
+	if stream.PdfObjectDictionary == nil {
+		return stream.Stream, nil
+	}
+	return DecodeStream(stream)
+}
@@ -0,0 +1,415 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package core
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// graphGenerator generates random object graphs. The indirect objects are referred to from
+// their descendants and from other branches, making cyclic graphs and shared objects.
+type graphGenerator struct {
+	rnd     *rand.Rand
+	objects []*PdfIndirectObject
+}
+
+func newGraphGenerator(seed int64) *graphGenerator {
+	return &graphGenerator{rnd: rand.New(rand.NewSource(seed))}
+}
+
+// graph returns a random object graph rooted at a dictionary.
+func (g *graphGenerator) graph() *PdfObjectDictionary {
+	g.objects = nil
+	return g.dict(0)
+}
+
+func (g *graphGenerator) dict(depth int) *PdfObjectDictionary {
+	dict := MakeDict()
+	for i, n := 0, 1+g.rnd.Intn(5); i < n; i++ {
+		dict.Set(PdfObjectName(fmt.Sprintf("K%d", g.rnd.Intn(10))), g.object(depth+1))
+	}
+	return dict
+}
+
+func (g *graphGenerator) object(depth int) PdfObject {
+	kind := g.rnd.Intn(12)
+	if depth > 4 {
+		kind = g.rnd.Intn(7)
+	}
+	switch kind {
+	case 0:
+		return MakeInteger(g.rnd.Int63n(100) - 50)
+	case 1:
+		return MakeFloat(float64(g.rnd.Intn(1000)) / 8)
+	case 2:
+		return MakeString(fmt.Sprintf("s%d", g.rnd.Intn(100)))
+	case 3:
+		return MakeName(fmt.Sprintf("N%d", g.rnd.Intn(100)))
+	case 4:
+		return MakeBool(g.rnd.Intn(2) == 1)
+	case 5:
+		return MakeNull()
+	case 6:
+		return &PdfObjectReference{ObjectNumber: 1 + g.rnd.Int63n(20)}
+	case 7:
+		arr := MakeArray()
+		for i, n := 0, g.rnd.Intn(5); i < n; i++ {
+			arr.Append(g.object(depth + 1))
+		}
+		return arr
+	case 8:
+		return g.dict(depth)
+	case 9:
+		if len(g.objects) > 0 {
+			return g.objects[g.rnd.Intn(len(g.objects))]
+		}
+		fallthrough
+	case 10:
+		ind := MakeIndirectObject(MakeNull())
+		g.objects = append(g.objects, ind)
+		ind.PdfObject = g.dict(depth)
+		return ind
+	}
+
+	data := make([]byte, g.rnd.Intn(64))
+	g.rnd.Read(data)
+	var encoder StreamEncoder
+	if g.rnd.Intn(2) == 1 {
+		encoder = NewFlateEncoder()
+	}
+	stream, err := MakeStream(data, encoder)
+	if err != nil {
+		panic(err)
+	}
+	stream.Set("Subtype", g.object(depth+1))
+	return stream
+}
+
+// makeTestDict returns a dictionary containing `entries`.
+func makeTestDict(entries map[PdfObjectName]PdfObject) *PdfObjectDictionary {
+	dict := MakeDict()
+	for key, val := range entries {
+		dict.Set(key, val)
+	}
+	return dict
+}
+
+// visitObjects calls `fn` once for each object reachable from `obj`, prior to visiting the
+// objects it contains.
+func visitObjects(obj PdfObject, fn func(obj PdfObject)) {
+	visited := map[PdfObject]struct{}{}
+	var visit func(obj PdfObject)
+	visit = func(obj PdfObject) {
+		if obj == nil {
+			return
+		}
+		if _, ok := visited[obj]; ok {
+			return
+		}
+		visited[obj] = struct{}{}
+		fn(obj)
+		switch t := obj.(type) {
+		case *PdfIndirectObject:
+			visit(t.PdfObject)
+		case *PdfObjectStream:
+			visit(t.PdfObjectDictionary)
+		case *PdfObjectDictionary:
+			for _, key := range t.Keys() {
+				visit(t.Get(key))
+			}
+		case *PdfObjectArray:
+			for _, elem := range t.Elements() {
+				visit(elem)
+			}
+		}
+	}
+	visit(obj)
+}
+
+// reachableObjects returns the set of the objects reachable from `obj`, except the null objects
+// (pointers to distinct zero-size variables may be equal).
+func reachableObjects(obj PdfObject) map[PdfObject]struct{} {
+	objects := map[PdfObject]struct{}{}
+	visitObjects(obj, func(obj PdfObject) {
+		if _, isNull := obj.(*PdfObjectNull); !isNull {
+			objects[obj] = struct{}{}
+		}
+	})
+	return objects
+}
+
+// rewriteObject returns `obj` written differently: integers as floats and conversely, literal
+// strings as hexadecimal strings and conversely.
+func rewriteObject(obj PdfObject) PdfObject {
+	switch t := obj.(type) {
+	case *PdfObjectInteger:
+		return MakeFloat(float64(*t))
+	case *PdfObjectFloat:
+		if float64(*t) == float64(int64(*t)) {
+			return MakeInteger(int64(*t))
+		}
+	case *PdfObjectString:
+		if t.isHex {
+			return MakeString(t.Str())
+		}
+		return MakeHexString(t.Str())
+	}
+	return obj
+}
+
+// rewriteGraph returns a copy of the graph `obj` which is semantically equal, but written
+// differently: numbers and strings are rewritten, the keys of dictionaries are reversed and
+// streams are encoded differently.
+func rewriteGraph(t *testing.T, obj PdfObject) PdfObject {
+	cp := DeepCopy(obj, nil)
+	visitObjects(cp, func(obj PdfObject) {
+		switch t := obj.(type) {
+		case *PdfIndirectObject:
+			t.PdfObject = rewriteObject(t.PdfObject)
+		case *PdfObjectArray:
+			for i, elem := range t.Elements() {
+				t.Set(i, rewriteObject(elem))
+			}
+		case *PdfObjectDictionary:
+			keys := append([]PdfObjectName{}, t.Keys()...)
+			values := map[PdfObjectName]PdfObject{}
+			for _, key := range keys {
+				values[key] = rewriteObject(t.Get(key))
+			}
+			t.Clear()
+			for i := len(keys) - 1; i >= 0; i-- {
+				t.Set(keys[i], values[keys[i]])
+			}
+		}
+	})
+	visitObjects(cp, func(obj PdfObject) {
+		stream, ok := obj.(*PdfObjectStream)
+		if !ok {
+			return
+		}
+		data, err := DecodeStream(stream)
+		require.NoError(t, err)
+		if stream.Get("Filter") != nil {
+			stream.Remove("Filter")
+			stream.Stream = data
+		} else {
+			encoder := NewFlateEncoder()
+			stream.Stream, err = encoder.EncodeBytes(data)
+			require.NoError(t, err)
+			stream.Set("Filter", MakeName(encoder.GetFilterName()))
+		}
+		stream.Set("Length", MakeInteger(int64(len(stream.Stream))))
+	})
+	return cp
+}
+
+// mutateGraph modifies all the objects reachable from `obj`.
+func mutateGraph(obj PdfObject) {
+	visitObjects(obj, func(obj PdfObject) {
+		switch t := obj.(type) {
+		case *PdfObjectDictionary:
+			t.Set("Mutated", MakeBool(true))
+		case *PdfObjectArray:
+			t.Append(MakeNull())
+		case *PdfObjectStream:
+			t.Stream = append(t.Stream, 0)
+		case *PdfObjectInteger:
+			*t++
+		case *PdfObjectFloat:
+			*t++
+		case *PdfObjectBool:
+			*t = !*t
+		case *PdfObjectName:
+			*t += "Mutated"
+		case *PdfObjectString:
+			*t = *MakeString(t.Str() + "mutated")
+		case *PdfObjectReference:
+			t.ObjectNumber++
+		}
+	})
+}
+
+// TestDeepCopyGraphs checks that the copies of random object graphs are equal to the originals,
+// have the same structure and share no objects with them.
+func TestDeepCopyGraphs(t *testing.T) {
+	for seed := int64(0); seed < 200; seed++ {
+		g := newGraphGenerator(seed)
+		obj := g.graph()
+		snapshot := DeepCopy(obj, nil)
+		cp := DeepCopy(obj, nil)
+		require.True(t, Equal(obj, cp), "seed %d", seed)
+
+		objects := reachableObjects(obj)
+		copies := reachableObjects(cp)
+		require.Equal(t, len(objects), len(copies), "seed %d", seed)
+		for obj := range copies {
+			_, shared := objects[obj]
+			require.False(t, shared, "seed %d: %s shared", seed, obj)
+		}
+
+		mutateGraph(cp)
+		require.True(t, Equal(obj, snapshot), "seed %d", seed)
+		require.False(t, Equal(obj, cp), "seed %d", seed)
+	}
+}
+
+// TestEqualLaws checks that Equal is reflexive, symmetric and transitive over random object
+// graphs and their semantically equal rewrites.
+func TestEqualLaws(t *testing.T) {
+	var graphs []PdfObject
+	for seed := int64(0); seed < 100; seed++ {
+		g := newGraphGenerator(seed)
+		obj := g.graph()
+		rewritten := rewriteGraph(t, obj)
+		twice := rewriteGraph(t, rewritten)
+
+		require.True(t, Equal(obj, obj), "seed %d", seed)
+		require.True(t, Equal(obj, rewritten), "seed %d", seed)
+		require.True(t, Equal(rewritten, obj), "seed %d", seed)
+		require.True(t, Equal(rewritten, twice), "seed %d", seed)
+		require.True(t, Equal(obj, twice), "seed %d", seed)
+		graphs = append(graphs, obj, rewritten)
+	}
+
+	for i := range graphs {
+		for j := range graphs {
+			require.Equal(t, Equal(graphs[i], graphs[j]), Equal(graphs[j], graphs[i]), "graphs %d %d", i, j)
+			if i/2 == j/2 {
+				require.True(t, Equal(graphs[i], graphs[j]), "graphs %d %d", i, j)
+			}
+		}
+	}
+}
+
+// TestEqual checks the semantic comparison of objects.
+func TestEqual(t *testing.T) {
+	flateStream, err := MakeStream([]byte("BT /F1 12 Tf (Hello) Tj ET"), NewFlateEncoder())
+	require.NoError(t, err)
+	rawStream, err := MakeStream([]byte("BT /F1 12 Tf (Hello) Tj ET"), nil)
+	require.NoError(t, err)
+	otherStream, err := MakeStream([]byte("BT /F1 12 Tf (World) Tj ET"), nil)
+	require.NoError(t, err)
+	invalidStream1, err := MakeStream([]byte("invalid"), nil)
+	require.NoError(t, err)
+	invalidStream1.Set("Filter", MakeName("FlateDecode"))
+	invalidStream2, err := MakeStream([]byte("invalid!"), nil)
+	require.NoError(t, err)
+	invalidStream2.Set("Filter", MakeName("FlateDecode"))
+
+	numbered := func(num int64, obj PdfObject) *PdfIndirectObject {
+		ind := MakeIndirectObject(obj)
+		ind.ObjectNumber = num
+		return ind
+	}
+
+	testcases := []struct {
+		a, b  PdfObject
+		equal bool
+	}{
+		{nil, nil, true},
+		{MakeNull(), nil, false},
+		{MakeInteger(1), MakeFloat(1), true},
+		{MakeInteger(1), MakeFloat(1.5), false},
+		{MakeInteger(1), MakeBool(true), false},
+		{MakeString("abc"), MakeHexString("abc"), true},
+		{MakeString("abc"), MakeName("abc"), false},
+		{MakeDict(), MakeArray(), false},
+		{
+			makeTestDict(map[PdfObjectName]PdfObject{"A": MakeInteger(1), "B": MakeName("X")}),
+			makeTestDict(map[PdfObjectName]PdfObject{"B": MakeName("X"), "A": MakeFloat(1)}),
+			true,
+		},
+		{
+			makeTestDict(map[PdfObjectName]PdfObject{"A": MakeInteger(1)}),
+			makeTestDict(map[PdfObjectName]PdfObject{"A": MakeInteger(1), "B": MakeNull()}),
+			false,
+		},
+		{MakeArray(MakeInteger(1), MakeName("A")), MakeArray(MakeFloat(1), MakeName("A")), true},
+		{MakeArray(MakeInteger(1)), MakeArray(MakeInteger(1), MakeInteger(1)), false},
+		{flateStream, rawStream, true},
+		{flateStream, otherStream, false},
+		{invalidStream1, invalidStream1, true},
+		{invalidStream1, invalidStream2, false},
+		{&PdfObjectReference{ObjectNumber: 3}, &PdfObjectReference{ObjectNumber: 3}, true},
+		{&PdfObjectReference{ObjectNumber: 3}, &PdfObjectReference{ObjectNumber: 3, GenerationNumber: 1}, false},
+		{&PdfObjectReference{ObjectNumber: 3}, numbered(3, MakeNull()), false},
+		// Numbered objects passed to Equal are compared by contents, and by reference when nested.
+		{numbered(3, MakeInteger(1)), numbered(4, MakeFloat(1)), true},
+		{MakeArray(numbered(3, MakeInteger(1))), MakeArray(numbered(4, MakeInteger(1))), false},
+		{MakeArray(numbered(3, MakeInteger(1))), MakeArray(numbered(3, MakeInteger(2))), true},
+		{MakeArray(numbered(3, MakeNull())), MakeArray(&PdfObjectReference{ObjectNumber: 3}), true},
+		{MakeArray(MakeIndirectObject(MakeInteger(1))), MakeArray(MakeIndirectObject(MakeFloat(1))), true},
+	}
+	for i, tc := range testcases {
+		require.Equal(t, tc.equal, Equal(tc.a, tc.b), "case %d: %v %v", i, tc.a, tc.b)
+		require.Equal(t, tc.equal, Equal(tc.b, tc.a), "case %d: %v %v", i, tc.b, tc.a)
+	}
+}
+
+// TestEqualCycles checks that cyclic object graphs of different lengths are compared.
+func TestEqualCycles(t *testing.T) {
+	// makeCycle returns a cycle of `n` indirect objects, the dictionaries of which have the value
+	// `last` for the last object.
+	makeCycle := func(n int, last PdfObject) *PdfIndirectObject {
+		first := MakeIndirectObject(nil)
+		ind := first
+		for i := 1; i < n; i++ {
+			next := MakeIndirectObject(nil)
+			ind.PdfObject = makeTestDict(map[PdfObjectName]PdfObject{"Next": next, "Value": MakeInteger(1)})
+			ind = next
+		}
+		ind.PdfObject = makeTestDict(map[PdfObjectName]PdfObject{"Next": first, "Value": last})
+		return first
+	}
+
+	require.True(t, Equal(makeCycle(1, MakeInteger(1)), makeCycle(1, MakeFloat(1))))
+	require.True(t, Equal(makeCycle(3, MakeInteger(1)), makeCycle(3, MakeFloat(1))))
+	// Cycles of the same values are equal regardless of their lengths.
+	require.True(t, Equal(makeCycle(2, MakeInteger(1)), makeCycle(3, MakeInteger(1))))
+	require.False(t, Equal(makeCycle(3, MakeInteger(2)), makeCycle(3, MakeInteger(1))))
+	require.False(t, Equal(makeCycle(2, MakeInteger(2)), makeCycle(3, MakeInteger(2))))
+
+	cycle := makeCycle(3, MakeInteger(2))
+	cp := DeepCopy(cycle, nil).(*PdfIndirectObject)
+	require.True(t, Equal(cycle, cp))
+	last := TraceToDirectObject(TraceToDirectObject(cp).(*PdfObjectDictionary).Get("Next"))
+	last = TraceToDirectObject(last.(*PdfObjectDictionary).Get("Next"))
+	require.Equal(t, cp, last.(*PdfObjectDictionary).Get("Next"))
+}
+
+// TestDeepCopyRemap checks the remapping of references and numbered objects.
+func TestDeepCopyRemap(t *testing.T) {
+	page := MakeIndirectObject(MakeDict())
+	page.ObjectNumber = 7
+	font := MakeIndirectObject(MakeName("Font"))
+	font.ObjectNumber = 8
+	target := MakeIndirectObject(MakeDict())
+
+	obj := makeTestDict(map[PdfObjectName]PdfObject{
+		"P":     &PdfObjectReference{ObjectNumber: 7},
+		"Page":  page,
+		"Font":  font,
+		"Other": &PdfObjectReference{ObjectNumber: 9},
+	})
+	cp := DeepCopy(obj, map[int64]PdfObject{7: target}).(*PdfObjectDictionary)
+	require.Equal(t, target, cp.Get("P"))
+	require.Equal(t, target, cp.Get("Page"))
+
+	fontCopy, ok := cp.Get("Font").(*PdfIndirectObject)
+	require.True(t, ok)
+	require.False(t, fontCopy == font)
+	require.Equal(t, int64(8), fontCopy.ObjectNumber)
+	require.True(t, Equal(font, fontCopy))
+
+	other, ok := cp.Get("Other").(*PdfObjectReference)
+	require.True(t, ok)
+	require.Equal(t, int64(9), other.ObjectNumber)
+	require.False(t, other == obj.Get("Other"))
+}
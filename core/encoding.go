@@ -13,9 +13,9 @@ package core
 // - RunLength
 // - ASCII Hex
 // - ASCII85
-// - CCITT Fax (dummy)
-// - JBIG2 (dummy)
-// - JPX (dummy)
+// - CCITT Fax (Group 3 and Group 4)
+// - JBIG2
+// - JPX (JPEG 2000, decoding only)
 
 import (
 	"bytes"
@@ -49,6 +49,7 @@ const (
 	StreamEncodingFilterNameCCITTFax  = "CCITTFaxDecode"
 	StreamEncodingFilterNameJBIG2     = "JBIG2Decode"
 	StreamEncodingFilterNameJPX       = "JPXDecode"
+	StreamEncodingFilterNameCrypt     = "Crypt"
 	StreamEncodingFilterNameRaw       = "Raw"
 )
 
@@ -76,6 +77,9 @@ type FlateEncoder struct {
 	// For predictors
 	Columns int
 	Colors  int
+
+	// Maximum size of the decoded data, DefaultMaxDecodedStreamSize if 0.
+	maxDecodedSize int64
 }
 
 // NewFlateEncoder makes a new flate encoder with default parameters, predictor 1 and bits per component 8.
@@ -98,7 +102,7 @@ func NewFlateEncoder() *FlateEncoder {
 // The columns indicates the number of samples per row.
 // Used for grouping data together for compression.
 func (enc *FlateEncoder) SetPredictor(columns int) {
-	// Only supporting PNG sub predictor for encoding.
+	// PNG sub predictor.
 	enc.Predictor = 11
 	enc.Columns = columns
 }
@@ -172,6 +176,7 @@ func (enc *FlateEncoder) UpdateParams(params *PdfObjectDictionary) {
 // from the DecodeParms stream object dictionary entry.
 func newFlateEncoderFromStream(streamObj *PdfObjectStream, decodeParams *PdfObjectDictionary) (*FlateEncoder, error) {
 	encoder := NewFlateEncoder()
+	encoder.maxDecodedSize = streamConfig(streamObj).MaxDecodedStreamSize
 
 	encDict := streamObj.PdfObjectDictionary
 	if encDict == nil {
@@ -187,7 +192,7 @@ func newFlateEncoderFromStream(streamObj *PdfObjectStream, decodeParams *PdfObje
 			arr := t
 			if arr.Len() != 1 {
 				common.Log.Debug("Error: DecodeParms array length != 1 (%d)", arr.Len())
-				return nil, errors.New("range check error")
+				return nil, ErrRangeError
 			}
 			obj = TraceToDirectObject(arr.Get(0))
 		case *PdfObjectDictionary:
@@ -274,10 +279,13 @@ func (enc *FlateEncoder) DecodeBytes(encoded []byte) ([]byte, error) {
 	}
 	defer r.Close()
 
-	var outBuf bytes.Buffer
-	outBuf.ReadFrom(r)
-
-	return outBuf.Bytes(), nil
+	// Read errors are ignored, returning the data decoded before the error.
+	decoded, err := readAllLimited(r, enc.maxDecodedSize)
+	if _, ok := err.(ErrLimitExceeded); ok {
+		common.Log.Debug("ERROR: Flate decoded data too large: %v", err)
+		return nil, err
+	}
+	return decoded, nil
 }
 
 // Prediction filters for PNG predictors.
@@ -312,7 +320,7 @@ func (enc *FlateEncoder) postDecodePredict(outData []byte) ([]byte, error) {
 			}
 			if rowLength > len(outData) {
 				common.Log.Debug("Row length cannot be longer than data length (%d/%d)", rowLength, len(outData))
-				return nil, errors.New("range check error")
+				return nil, ErrRangeError
 			}
 			common.Log.Trace("inp outData (%d): % x", len(outData), outData)
 
@@ -335,14 +343,19 @@ func (enc *FlateEncoder) postDecodePredict(outData []byte) ([]byte, error) {
 			common.Log.Trace("PNG Encoding")
 			// Columns represents the number of samples per row; Each sample can contain multiple color
 			// components.
-			rowLength := int(enc.Columns*enc.Colors + 1) // 1 byte to specify predictor algorithms per row.
+			rowBytes, bytesPerPixel := enc.pngRowBytes()
+			rowLength := rowBytes + 1 // 1 byte to specify predictor algorithms per row.
+			if rowBytes < 1 {
+				common.Log.Debug("ERROR: Invalid predictor row length (%d)", rowBytes)
+				return nil, errors.New("invalid row length")
+			}
 			rows := len(outData) / rowLength
 			if len(outData)%rowLength != 0 {
 				return nil, fmt.Errorf("invalid row length (%d/%d)", len(outData), rowLength)
 			}
 			if rowLength > len(outData) {
 				common.Log.Debug("Row length cannot be longer than data length (%d/%d)", rowLength, len(outData))
-				return nil, errors.New("range check error")
+				return nil, ErrRangeError
 			}
 
 			pOutBuffer := bytes.NewBuffer(nil)
@@ -354,8 +367,6 @@ func (enc *FlateEncoder) postDecodePredict(outData []byte) ([]byte, error) {
 				prevRowData[i] = 0
 			}
 
-			bytesPerPixel := enc.Colors // Assuming BPC = 8.
-
 			for i := 0; i < rows; i++ {
 				rowData := outData[rowLength*i : rowLength*(i+1)]
 
@@ -410,13 +421,101 @@ func (enc *FlateEncoder) postDecodePredict(outData []byte) ([]byte, error) {
 	return outData, nil
 }
 
+// pngRowBytes returns the number of bytes per row of the samples predicted by the PNG predictors,
+// and the number of bytes per pixel (at least 1) used for the predictions.
+func (enc *FlateEncoder) pngRowBytes() (rowBytes, bytesPerPixel int) {
+	bpc := enc.BitsPerComponent
+	if bpc < 1 {
+		bpc = 8
+	}
+	rowBytes = (enc.Columns*enc.Colors*bpc + 7) / 8
+	bytesPerPixel = (enc.Colors*bpc + 7) / 8
+	if bytesPerPixel < 1 {
+		bytesPerPixel = 1
+	}
+	return rowBytes, bytesPerPixel
+}
+
+// pngPredict applies the PNG prediction filters to the rows of `data`, each output row starting
+// with the filter type byte. With the predictor 15, the filter of each row is chosen like libpng
+// does, minimizing the sum of the absolute values of the filtered bytes taken as signed.
+func (enc *FlateEncoder) pngPredict(data []byte) ([]byte, error) {
+	rowBytes, bytesPerPixel := enc.pngRowBytes()
+	if rowBytes < 1 || len(data)%rowBytes != 0 {
+		common.Log.Debug("ERROR: Invalid row length (%d/%d)", len(data), rowBytes)
+		return nil, errors.New("invalid row length")
+	}
+	rows := len(data) / rowBytes
+
+	predicted := make([]byte, 0, len(data)+rows)
+	prevRow := make([]byte, rowBytes)
+	filtered := make([]byte, rowBytes)
+	best := make([]byte, rowBytes)
+	for i := 0; i < rows; i++ {
+		row := data[rowBytes*i : rowBytes*(i+1)]
+
+		filter := byte(enc.Predictor - 10)
+		if enc.Predictor == 15 {
+			minSum := -1
+			for f := byte(pfNone); f <= pfPaeth; f++ {
+				pngFilterRow(filtered, row, prevRow, f, bytesPerPixel)
+				sum := 0
+				for _, b := range filtered {
+					if b < 128 {
+						sum += int(b)
+					} else {
+						sum += 256 - int(b)
+					}
+				}
+				if minSum < 0 || sum < minSum {
+					minSum = sum
+					filter = f
+					best, filtered = filtered, best
+				}
+			}
+		} else {
+			pngFilterRow(best, row, prevRow, filter, bytesPerPixel)
+		}
+
+		predicted = append(predicted, filter)
+		predicted = append(predicted, best...)
+		prevRow = row
+	}
+	return predicted, nil
+}
+
+// pngFilterRow writes to `dst` the bytes of `row` predicted with the PNG prediction `filter`,
+// `prevRow` being the previous row.
+func pngFilterRow(dst, row, prevRow []byte, filter byte, bytesPerPixel int) {
+	for j := range row {
+		var a, c byte
+		if j >= bytesPerPixel {
+			a = row[j-bytesPerPixel]
+			c = prevRow[j-bytesPerPixel]
+		}
+		b := prevRow[j]
+		switch filter {
+		case pfNone:
+			dst[j] = row[j]
+		case pfSub:
+			dst[j] = row[j] - a
+		case pfUp:
+			dst[j] = row[j] - b
+		case pfAvg:
+			dst[j] = row[j] - byte((int(a)+int(b))/2)
+		case pfPaeth:
+			dst[j] = row[j] - paeth(a, b, c)
+		}
+	}
+}
+
 // DecodeStream decodes a FlateEncoded stream object and give back decoded bytes.
 func (enc *FlateEncoder) DecodeStream(streamObj *PdfObjectStream) ([]byte, error) {
 	// TODO: Handle more filter bytes and support more values of BitsPerComponent.
 
 	common.Log.Trace("FlateDecode stream")
 	common.Log.Trace("Predictor: %d", enc.Predictor)
-	if enc.BitsPerComponent != 8 {
+	if enc.Predictor == 2 && enc.BitsPerComponent != 8 {
 		return nil, fmt.Errorf("invalid BitsPerComponent=%d (only 8 supported)", enc.BitsPerComponent)
 	}
 
@@ -429,42 +528,20 @@ func (enc *FlateEncoder) DecodeStream(streamObj *PdfObjectStream) ([]byte, error
 }
 
 // EncodeBytes encodes a bytes array and return the encoded value based on the encoder parameters.
+// The PNG predictors 10 to 14 apply the same prediction filter to all the rows, while the filter
+// of each row is chosen with the predictor 15.
 func (enc *FlateEncoder) EncodeBytes(data []byte) ([]byte, error) {
-	if enc.Predictor != 1 && enc.Predictor != 11 {
-		common.Log.Debug("Encoding error: FlateEncoder Predictor = 1, 11 only supported")
+	if enc.Predictor != 1 && (enc.Predictor < 10 || enc.Predictor > 15) {
+		common.Log.Debug("Encoding error: FlateEncoder Predictor = 1, 10-15 only supported")
 		return nil, ErrUnsupportedEncodingParameters
 	}
 
-	if enc.Predictor == 11 {
-		// The length of each output row in number of samples.
-		// N.B. Each output row has one extra sample as compared to the input to indicate the
-		// predictor type.
-		rowLength := int(enc.Columns)
-		rows := len(data) / rowLength
-		if len(data)%rowLength != 0 {
-			common.Log.Error("Invalid row length")
-			return nil, errors.New("invalid row length")
-		}
-
-		pOutBuffer := bytes.NewBuffer(nil)
-
-		tmpData := make([]byte, rowLength)
-
-		for i := 0; i < rows; i++ {
-			rowData := data[rowLength*i : rowLength*(i+1)]
-
-			// PNG SUB method.
-			// Sub: Predicts the same as the sample to the left.
-			tmpData[0] = rowData[0]
-			for j := 1; j < rowLength; j++ {
-				tmpData[j] = byte(int(rowData[j]-rowData[j-1]) % 256)
-			}
-
-			pOutBuffer.WriteByte(1) // sub method
-			pOutBuffer.Write(tmpData)
+	if enc.Predictor >= 10 {
+		predicted, err := enc.pngPredict(data)
+		if err != nil {
+			return nil, err
 		}
-
-		data = pOutBuffer.Bytes()
+		data = predicted
 	}
 
 	var b bytes.Buffer
@@ -484,6 +561,9 @@ type LZWEncoder struct {
 	Colors  int
 	// LZW algorithm setting.
 	EarlyChange int
+
+	// Maximum size of the decoded data, DefaultMaxDecodedStreamSize if 0.
+	maxDecodedSize int64
 }
 
 // NewLZWEncoder makes a new LZW encoder with default parameters.
@@ -511,8 +591,8 @@ func (enc *LZWEncoder) GetFilterName() string {
 // MakeDecodeParams makes a new instance of an encoding dictionary based on
 // the current encoder settings.
 func (enc *LZWEncoder) MakeDecodeParams() PdfObject {
+	decodeParams := MakeDict()
 	if enc.Predictor > 1 {
-		decodeParams := MakeDict()
 		decodeParams.Set("Predictor", MakeInteger(int64(enc.Predictor)))
 
 		// Only add if not default option.
@@ -525,9 +605,14 @@ func (enc *LZWEncoder) MakeDecodeParams() PdfObject {
 		if enc.Colors != 1 {
 			decodeParams.Set("Colors", MakeInteger(int64(enc.Colors)))
 		}
-		return decodeParams
 	}
-	return nil
+	if enc.EarlyChange != 1 {
+		decodeParams.Set("EarlyChange", MakeInteger(int64(enc.EarlyChange)))
+	}
+	if len(decodeParams.Keys()) == 0 {
+		return nil
+	}
+	return decodeParams
 }
 
 // MakeStreamDict makes a new instance of an encoding dictionary for a stream object.
@@ -542,8 +627,6 @@ func (enc *LZWEncoder) MakeStreamDict() *PdfObjectDictionary {
 		dict.Set("DecodeParms", decodeParams)
 	}
 
-	dict.Set("EarlyChange", MakeInteger(int64(enc.EarlyChange)))
-
 	return dict
 }
 
@@ -580,6 +663,7 @@ func (enc *LZWEncoder) UpdateParams(params *PdfObjectDictionary) {
 func newLZWEncoderFromStream(streamObj *PdfObjectStream, decodeParams *PdfObjectDictionary) (*LZWEncoder, error) {
 	// Start with default settings.
 	encoder := NewLZWEncoder()
+	encoder.maxDecodedSize = streamConfig(streamObj).MaxDecodedStreamSize
 
 	encDict := streamObj.PdfObjectDictionary
 	if encDict == nil {
@@ -611,7 +695,14 @@ func newLZWEncoderFromStream(streamObj *PdfObjectStream, decodeParams *PdfObject
 	// implementations use a different mechanisms. Essentially this chooses
 	// which LZW implementation to use.
 	// The default is 1 (one code early)
-	obj := encDict.Get("EarlyChange")
+	// It is a decode parameter, but some writers put it in the stream dictionary.
+	var obj PdfObject
+	if decodeParams != nil {
+		obj = decodeParams.Get("EarlyChange")
+	}
+	if obj == nil {
+		obj = encDict.Get("EarlyChange")
+	}
 	if obj != nil {
 		earlyChange, ok := obj.(*PdfObjectInteger)
 		if !ok {
@@ -686,7 +777,6 @@ func newLZWEncoderFromStream(streamObj *PdfObjectStream, decodeParams *PdfObject
 
 // DecodeBytes decodes a slice of LZW encoded bytes and returns the result.
 func (enc *LZWEncoder) DecodeBytes(encoded []byte) ([]byte, error) {
-	var outBuf bytes.Buffer
 	bufReader := bytes.NewReader(encoded)
 
 	var r io.ReadCloser
@@ -699,12 +789,12 @@ func (enc *LZWEncoder) DecodeBytes(encoded []byte) ([]byte, error) {
 	}
 	defer r.Close()
 
-	_, err := outBuf.ReadFrom(r)
+	decoded, err := readAllLimited(r, enc.maxDecodedSize)
 	if err != nil {
 		return nil, err
 	}
 
-	return outBuf.Bytes(), nil
+	return decoded, nil
 }
 
 // DecodeStream decodes a LZW encoded stream and returns the result as a
@@ -748,7 +838,7 @@ func (enc *LZWEncoder) DecodeStream(streamObj *PdfObjectStream) ([]byte, error)
 
 			if rowLength > len(outData) {
 				common.Log.Debug("Row length cannot be longer than data length (%d/%d)", rowLength, len(outData))
-				return nil, errors.New("range check error")
+				return nil, ErrRangeError
 			}
 			common.Log.Trace("inp outData (%d): % x", len(outData), outData)
 
@@ -784,7 +874,7 @@ func (enc *LZWEncoder) DecodeStream(streamObj *PdfObjectStream) ([]byte, error)
 			}
 			if rowLength > len(outData) {
 				common.Log.Debug("Row length cannot be longer than data length (%d/%d)", rowLength, len(outData))
-				return nil, errors.New("range check error")
+				return nil, ErrRangeError
 			}
 
 			pOutBuffer := bytes.NewBuffer(nil)
@@ -834,25 +924,88 @@ func (enc *LZWEncoder) DecodeStream(streamObj *PdfObjectStream) ([]byte, error)
 	return outData, nil
 }
 
-// EncodeBytes implements support for LZW encoding.  Currently not supporting predictors (raw compressed data only).
-// Only supports the Early change = 1 algorithm (compress/lzw) as the other implementation
-// does not have a write method.
-// TODO: Consider refactoring compress/lzw to allow both.
+// EncodeBytes implements support for LZW encoding with both the EarlyChange values.
+// Currently not supporting predictors (raw compressed data only).
 func (enc *LZWEncoder) EncodeBytes(data []byte) ([]byte, error) {
 	if enc.Predictor != 1 {
-		return nil, fmt.Errorf("LZW Predictor = 1 only supported yet")
+		common.Log.Debug("Encoding error: LZWEncoder Predictor = 1 only supported")
+		return nil, ErrUnsupportedEncodingParameters
 	}
 
-	if enc.EarlyChange == 1 {
-		return nil, fmt.Errorf("LZW Early Change = 0 only supported yet")
+	if enc.EarlyChange != 0 && enc.EarlyChange != 1 {
+		return nil, fmt.Errorf("invalid EarlyChange value (not 0 or 1)")
 	}
 
-	var b bytes.Buffer
-	w := lzw0.NewWriter(&b, lzw0.MSB, 8)
-	w.Write(data)
-	w.Close()
+	return lzwEncode(data, enc.EarlyChange), nil
+}
 
-	return b.Bytes(), nil
+// lzwEncode LZW encodes `data` with the codes of 9 to 12 bits, written most significant bit first.
+// The code length is increased one code early if `earlyChange` is 1, as done by the decoders of
+// the EarlyChange 1 streams. The code table is cleared when full, as done by compress/lzw.
+func lzwEncode(data []byte, earlyChange int) []byte {
+	const (
+		clearCode = 256
+		eodCode   = 257
+		maxCode   = 4095
+	)
+
+	var out bytes.Buffer
+	var bits uint32
+	var nbits uint
+	width := uint(9)
+	write := func(code int) {
+		bits = bits<<width | uint32(code)
+		nbits += width
+		for nbits >= 8 {
+			nbits -= 8
+			out.WriteByte(byte(bits >> nbits))
+		}
+	}
+
+	// The table maps the code of a sequence followed by a byte to the code of the new sequence.
+	table := map[int]int{}
+	hi := eodCode
+	// incHi assigns the next code, as the decoder does after each code, increasing the code
+	// length or clearing the table when needed. Returns false when the table was cleared.
+	incHi := func() bool {
+		hi++
+		if hi == maxCode {
+			write(clearCode)
+			width = 9
+			hi = eodCode
+			table = map[int]int{}
+			return false
+		}
+		if hi+earlyChange == 1<<width {
+			width++
+		}
+		return true
+	}
+
+	write(clearCode)
+	if len(data) > 0 {
+		code := int(data[0])
+		for _, b := range data[1:] {
+			key := code<<8 | int(b)
+			if c, ok := table[key]; ok {
+				code = c
+				continue
+			}
+			write(code)
+			code = int(b)
+			if incHi() {
+				table[key] = hi
+			}
+		}
+		write(code)
+		incHi()
+	}
+	write(eodCode)
+	if nbits > 0 {
+		out.WriteByte(byte(bits << (8 - nbits)))
+	}
+
+	return out.Bytes()
 }
 
 // DCTEncoder provides a DCT (JPG) encoding/decoding functionality for images.
@@ -862,6 +1015,18 @@ type DCTEncoder struct {
 	Width            int
 	Height           int
 	Quality          int
+
+	// ColorTransform is the color transformation of the encoded samples: 0 for none, 1 for YCbCr
+	// (3 color components) or YCCK (4 color components). It is specified by the Adobe APP14 marker
+	// of the image if present, or by the ColorTransform decode parameter otherwise.
+	ColorTransform int
+
+	// AdobeInverted is true for the 4 color component images with the Adobe APP14 marker. By the
+	// convention of Adobe, the CMYK samples of these images are inverted, 0 meaning full ink.
+	AdobeInverted bool
+
+	// Maximum number of pixels of the decoded image, DefaultMaxImagePixels if 0.
+	maxImagePixels int64
 }
 
 // NewDCTEncoder makes a new DCT encoder with default parameters.
@@ -870,6 +1035,7 @@ func NewDCTEncoder() *DCTEncoder {
 
 	encoder.ColorComponents = 3
 	encoder.BitsPerComponent = 8
+	encoder.ColorTransform = 1
 
 	encoder.Quality = DefaultJPEGQuality
 
@@ -928,11 +1094,12 @@ func (enc *DCTEncoder) UpdateParams(params *PdfObjectDictionary) {
 
 // Create a new DCT encoder/decoder from a stream object, getting all the encoding parameters
 // from the stream object dictionary entry and the image data itself.
-// TODO: Support if used with other filters [ASCII85Decode FlateDecode DCTDecode]...
-// need to apply the other filters prior to this one...
-func newDCTEncoderFromStream(streamObj *PdfObjectStream, multiEnc *MultiEncoder) (*DCTEncoder, error) {
+// If `multiEnc` is not nil, its filters are applied to the stream data prior to this one and
+// `decodeParams` are the decode parameters of the filter.
+func newDCTEncoderFromStream(streamObj *PdfObjectStream, multiEnc *MultiEncoder, decodeParams *PdfObjectDictionary) (*DCTEncoder, error) {
 	// Start with default settings.
 	encoder := NewDCTEncoder()
+	encoder.maxImagePixels = streamConfig(streamObj).MaxImagePixels
 
 	encDict := streamObj.PdfObjectDictionary
 	if encDict == nil {
@@ -986,6 +1153,28 @@ func newDCTEncoderFromStream(streamObj *PdfObjectStream, multiEnc *MultiEncoder)
 	}
 	encoder.Width = cfg.Width
 	encoder.Height = cfg.Height
+
+	// The color transform specified by the Adobe marker takes precedence over the decode
+	// parameter, the default being 1 for the 3 color component images and 0 otherwise.
+	if encoder.ColorComponents != 3 {
+		encoder.ColorTransform = 0
+	}
+	if multiEnc == nil {
+		decodeParams, _ = GetDict(encDict.Get("DecodeParms"))
+	}
+	if decodeParams != nil {
+		if transform, err := GetNumberAsInt64(decodeParams.Get("ColorTransform")); err == nil {
+			encoder.ColorTransform = int(transform)
+		}
+	}
+	if info := readJPEGInfo(encoded); info.adobe {
+		encoder.ColorTransform = 0
+		if info.adobeTransform != 0 {
+			encoder.ColorTransform = 1
+		}
+		encoder.AdobeInverted = encoder.ColorComponents == 4
+	}
+
 	common.Log.Trace("DCT Encoder: %+v", encoder)
 	encoder.Quality = DefaultJPEGQuality
 
@@ -994,6 +1183,19 @@ func newDCTEncoderFromStream(streamObj *PdfObjectStream, multiEnc *MultiEncoder)
 
 // DecodeBytes decodes a slice of DCT encoded bytes and returns the result.
 func (enc *DCTEncoder) DecodeBytes(encoded []byte) ([]byte, error) {
+	// The color transform is specified by the Adobe marker if present. Otherwise the marker of
+	// the ColorTransform parameter is inserted, so that the samples are decoded accordingly.
+	info := readJPEGInfo(encoded)
+	if !info.adobe && (info.components == 4 || info.components == 3 && enc.ColorTransform == 0) {
+		encoded = insertJPEGAdobeMarker(encoded, info.components, enc.ColorTransform)
+	}
+
+	if cfg, err := jpeg.DecodeConfig(bytes.NewReader(encoded)); err == nil {
+		if err := CheckImagePixels(int64(cfg.Width), int64(cfg.Height), enc.maxImagePixels); err != nil {
+			return nil, err
+		}
+	}
+
 	bufReader := bytes.NewReader(encoded)
 	//img, _, err := goimage.Decode(bufReader)
 	img, err := jpeg.Decode(bufReader)
@@ -1089,8 +1291,9 @@ func (enc *DCTEncoder) DecodeBytes(encoded []byte) ([]byte, error) {
 				if !ok {
 					return nil, errors.New("color type error")
 				}
-				// TODO: Is the inversion not handled right in the JPEG package for APP14?
-				// Should not need to invert here...
+				// The jpeg package inverts the decoded samples assuming the Adobe
+				// convention, the YCCK images being converted to the same inverted CMYK.
+				// The samples are returned as stored in the image.
 				decoded[index] = 255 - val.C&0xff
 				index++
 				decoded[index] = 255 - val.M&0xff
@@ -1106,6 +1309,84 @@ func (enc *DCTEncoder) DecodeBytes(encoded []byte) ([]byte, error) {
 	return decoded, nil
 }
 
+// jpegInfo holds the information of the JPEG markers relevant to decoding.
+type jpegInfo struct {
+	components     int  // Number of components of the frame.
+	adobe          bool // Has the Adobe APP14 marker.
+	adobeTransform int  // Transform of the Adobe APP14 marker.
+}
+
+// readJPEGInfo reads the markers of the JPEG `data` preceding the image data.
+func readJPEGInfo(data []byte) jpegInfo {
+	var info jpegInfo
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return info
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			break
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			// Fill byte.
+			pos++
+			continue
+		}
+		if marker == 0x01 || marker >= 0xD0 && marker <= 0xD7 {
+			// Markers without segment.
+			pos += 2
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			// Start of scan or end of image.
+			break
+		}
+
+		length := int(data[pos+2])<<8 | int(data[pos+3])
+		if length < 2 || pos+2+length > len(data) {
+			break
+		}
+		segment := data[pos+4 : pos+2+length]
+		switch {
+		case marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC:
+			// Start of frame.
+			if len(segment) >= 6 {
+				info.components = int(segment[5])
+			}
+		case marker == 0xEE:
+			// APP14.
+			if len(segment) >= 12 && string(segment[:5]) == "Adobe" {
+				info.adobe = true
+				info.adobeTransform = int(segment[11])
+			}
+		}
+		pos += 2 + length
+	}
+
+	return info
+}
+
+// insertJPEGAdobeMarker returns the JPEG `data` with the Adobe APP14 marker specifying the color
+// `transform` (0 or 1) of the image having the number of `components`.
+func insertJPEGAdobeMarker(data []byte, components, transform int) []byte {
+	code := byte(0)
+	if transform != 0 {
+		code = 1
+		if components == 4 {
+			// YCCK.
+			code = 2
+		}
+	}
+
+	marker := []byte{0xFF, 0xEE, 0x00, 0x0E, 'A', 'd', 'o', 'b', 'e', 0x00, 0x64, 0, 0, 0, 0, code}
+	buf := make([]byte, 0, len(data)+len(marker))
+	buf = append(buf, data[:2]...)
+	buf = append(buf, marker...)
+	return append(buf, data[2:]...)
+}
+
 // DecodeStream decodes a DCT encoded stream and returns the result as a
 // slice of bytes.
 func (enc *DCTEncoder) DecodeStream(streamObj *PdfObjectStream) ([]byte, error) {
@@ -1122,20 +1403,18 @@ type DrawableImage interface {
 
 // EncodeBytes DCT encodes the passed in slice of bytes.
 func (enc *DCTEncoder) EncodeBytes(data []byte) ([]byte, error) {
+	if enc.BitsPerComponent > 8 {
+		// The baseline JPEG images have 8 bits per component.
+		common.Log.Debug("ERROR: DCT encoding of %d bits per component images", enc.BitsPerComponent)
+		return nil, ErrDCTUnsupportedBitsPerComponent
+	}
+
 	bounds := goimage.Rect(0, 0, enc.Width, enc.Height)
 	var img DrawableImage
 	if enc.ColorComponents == 1 {
-		if enc.BitsPerComponent == 16 {
-			img = goimage.NewGray16(bounds)
-		} else {
-			img = goimage.NewGray(bounds)
-		}
+		img = goimage.NewGray(bounds)
 	} else if enc.ColorComponents == 3 {
-		if enc.BitsPerComponent == 16 {
-			img = goimage.NewRGBA64(bounds)
-		} else {
-			img = goimage.NewRGBA(bounds)
-		}
+		img = goimage.NewRGBA(bounds)
 	} else if enc.ColorComponents == 4 {
 		img = goimage.NewCMYK(bounds)
 	} else {
@@ -1157,25 +1436,13 @@ func (enc *DCTEncoder) EncodeBytes(data []byte) ([]byte, error) {
 	for i := 0; i+bytesPerColor-1 < len(data); i += bytesPerColor {
 		var c gocolor.Color
 		if enc.ColorComponents == 1 {
-			if enc.BitsPerComponent == 16 {
-				val := uint16(data[i])<<8 | uint16(data[i+1])
-				c = gocolor.Gray16{val}
-			} else {
-				val := uint8(data[i] & 0xff)
-				c = gocolor.Gray{val}
-			}
+			val := uint8(data[i] & 0xff)
+			c = gocolor.Gray{val}
 		} else if enc.ColorComponents == 3 {
-			if enc.BitsPerComponent == 16 {
-				r := uint16(data[i])<<8 | uint16(data[i+1])
-				g := uint16(data[i+2])<<8 | uint16(data[i+3])
-				b := uint16(data[i+4])<<8 | uint16(data[i+5])
-				c = gocolor.RGBA64{R: r, G: g, B: b, A: 0}
-			} else {
-				r := uint8(data[i] & 0xff)
-				g := uint8(data[i+1] & 0xff)
-				b := uint8(data[i+2] & 0xff)
-				c = gocolor.RGBA{R: r, G: g, B: b, A: 0}
-			}
+			r := uint8(data[i] & 0xff)
+			g := uint8(data[i+1] & 0xff)
+			b := uint8(data[i+2] & 0xff)
+			c = gocolor.RGBA{R: r, G: g, B: b, A: 0}
 		} else if enc.ColorComponents == 4 {
 			c1 := uint8(data[i] & 0xff)
 			m1 := uint8(data[i+1] & 0xff)
@@ -1210,6 +1477,8 @@ func (enc *DCTEncoder) EncodeBytes(data []byte) ([]byte, error) {
 
 // RunLengthEncoder represents Run length encoding.
 type RunLengthEncoder struct {
+	// Maximum size of the decoded data, DefaultMaxDecodedStreamSize if 0.
+	maxDecodedSize int64
 }
 
 // NewRunLengthEncoder makes a new run length encoder
@@ -1225,7 +1494,9 @@ func (enc *RunLengthEncoder) GetFilterName() string {
 // Create a new run length decoder from a stream object.
 func newRunLengthEncoderFromStream(streamObj *PdfObjectStream, decodeParams *PdfObjectDictionary) (*RunLengthEncoder, error) {
 	// TODO(dennwc): unused paramaters; check if it can have any in PDF spec
-	return NewRunLengthEncoder(), nil
+	encoder := NewRunLengthEncoder()
+	encoder.maxDecodedSize = streamConfig(streamObj).MaxDecodedStreamSize
+	return encoder, nil
 }
 
 // DecodeBytes decodes a byte slice from Run length encoding.
@@ -1253,6 +1524,9 @@ func (enc *RunLengthEncoder) DecodeBytes(encoded []byte) ([]byte, error) {
 			for i := 0; i < 257-int(b); i++ {
 				inb = append(inb, v)
 			}
+			if err := checkDecodedSize(len(inb), enc.maxDecodedSize); err != nil {
+				return nil, err
+			}
 		} else if b < 128 {
 			for i := 0; i < int(b)+1; i++ {
 				v, err := bufReader.ReadByte()
@@ -1275,68 +1549,30 @@ func (enc *RunLengthEncoder) DecodeStream(streamObj *PdfObjectStream) ([]byte, e
 }
 
 // EncodeBytes encodes a bytes array and return the encoded value based on the encoder parameters.
+// The runs of 2 to 128 identical bytes are encoded as repeated bytes, the other bytes as literal
+// bytes, and the EOD marker is appended.
 func (enc *RunLengthEncoder) EncodeBytes(data []byte) ([]byte, error) {
-	bufReader := bytes.NewReader(data)
 	var inb []byte
-	var literal []byte
-
-	b0, err := bufReader.ReadByte()
-	if err == io.EOF {
-		return []byte{}, nil
-	} else if err != nil {
-		return nil, err
-	}
-	runLen := 1
-
-	for {
-		b, err := bufReader.ReadByte()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return nil, err
+	for i := 0; i < len(data); {
+		// Repeated bytes.
+		run := 1
+		for i+run < len(data) && run < 128 && data[i+run] == data[i] {
+			run++
+		}
+		if run > 1 {
+			inb = append(inb, byte(257-run), data[i])
+			i += run
+			continue
 		}
 
-		if b == b0 {
-			if len(literal) > 0 {
-				literal = literal[:len(literal)-1]
-				if len(literal) > 0 {
-					inb = append(inb, byte(len(literal)-1))
-					inb = append(inb, literal...)
-				}
-				runLen = 1
-				literal = []byte{}
-			}
-			runLen++
-			if runLen >= 127 {
-				inb = append(inb, byte(257-runLen), b0)
-				runLen = 0
-			}
-
-		} else {
-			if runLen > 0 {
-				if runLen == 1 {
-					literal = []byte{b0}
-				} else {
-					inb = append(inb, byte(257-runLen), b0)
-				}
-
-				runLen = 0
-			}
-			literal = append(literal, b)
-			if len(literal) >= 127 {
-				inb = append(inb, byte(len(literal)-1))
-				inb = append(inb, literal...)
-				literal = []byte{}
-			}
+		// Literal bytes, up to the next repeated bytes.
+		j := i + 1
+		for j < len(data) && j-i < 128 && (j+1 == len(data) || data[j] != data[j+1]) {
+			j++
 		}
-		b0 = b
-	}
-
-	if len(literal) > 0 {
-		inb = append(inb, byte(len(literal)-1))
-		inb = append(inb, literal...)
-	} else if runLen > 0 {
-		inb = append(inb, byte(257-runLen), b0)
+		inb = append(inb, byte(j-i-1))
+		inb = append(inb, data[i:j]...)
+		i = j
 	}
 	inb = append(inb, 128)
 	return inb, nil
@@ -1604,7 +1840,8 @@ func (enc *ASCII85Encoder) EncodeBytes(data []byte) ([]byte, error) {
 
 		// Convert to a uint32 number.
 		base256 := (uint32(b1) << 24) | (uint32(b2) << 16) | (uint32(b3) << 8) | uint32(b4)
-		if base256 == 0 {
+		// The 'z' abbreviation is only allowed for full groups.
+		if base256 == 0 && n == 4 {
 			encoded.WriteByte('z')
 		} else {
 			base85vals := enc.base256Tobase85(base256)
@@ -1665,7 +1902,74 @@ func (enc *RawEncoder) EncodeBytes(data []byte) ([]byte, error) {
 	return data, nil
 }
 
+// CryptEncoder represents the Crypt filter, selecting the crypt filter of the security handler
+// used for the stream instead of the default one. The data are encrypted and decrypted by the
+// security handler of the document, the encoder passing them through unchanged.
+// The Crypt filter can only be the first filter of the stream filters.
+type CryptEncoder struct {
+	// Name is the name of the crypt filter in the CF dictionary of the encryption dictionary,
+	// the default Identity filter leaving the data unencrypted.
+	Name string
+}
+
+// NewCryptEncoder returns a new instance of CryptEncoder selecting the crypt filter `name`.
+func NewCryptEncoder(name string) *CryptEncoder {
+	return &CryptEncoder{Name: name}
+}
+
+// GetFilterName returns the name of the encoding filter.
+func (enc *CryptEncoder) GetFilterName() string {
+	return StreamEncodingFilterNameCrypt
+}
+
+// MakeDecodeParams makes a new instance of an encoding dictionary based on
+// the current encoder settings.
+func (enc *CryptEncoder) MakeDecodeParams() PdfObject {
+	if enc.Name == "" || enc.Name == "Identity" {
+		return nil
+	}
+	decodeParams := MakeDict()
+	decodeParams.Set("Type", MakeName("CryptFilterDecodeParms"))
+	decodeParams.Set("Name", MakeName(enc.Name))
+	return decodeParams
+}
+
+// MakeStreamDict makes a new instance of an encoding dictionary for a stream object.
+func (enc *CryptEncoder) MakeStreamDict() *PdfObjectDictionary {
+	dict := MakeDict()
+	dict.Set("Filter", MakeName(enc.GetFilterName()))
+
+	decodeParams := enc.MakeDecodeParams()
+	if decodeParams != nil {
+		dict.Set("DecodeParms", decodeParams)
+	}
+
+	return dict
+}
+
+// UpdateParams updates the parameter values of the encoder.
+func (enc *CryptEncoder) UpdateParams(params *PdfObjectDictionary) {
+}
+
+// DecodeBytes returns the passed in slice of bytes, decrypted by the security handler.
+func (enc *CryptEncoder) DecodeBytes(encoded []byte) ([]byte, error) {
+	return encoded, nil
+}
+
+// DecodeStream returns the passed in stream as a slice of bytes, decrypted by the security
+// handler.
+func (enc *CryptEncoder) DecodeStream(streamObj *PdfObjectStream) ([]byte, error) {
+	return streamObj.Stream, nil
+}
+
+// EncodeBytes returns the passed in slice of bytes, encrypted by the security handler when
+// writing.
+func (enc *CryptEncoder) EncodeBytes(data []byte) ([]byte, error) {
+	return data, nil
+}
+
 // CCITTFaxEncoder implements Group3 and Group4 facsimile (fax) encoder/decoder.
+// The decoded data are the 1 bit image samples, each row starting at a byte boundary.
 type CCITTFaxEncoder struct {
 	K                      int
 	EndOfLine              bool
@@ -1675,6 +1979,15 @@ type CCITTFaxEncoder struct {
 	EndOfBlock             bool
 	BlackIs1               bool
 	DamagedRowsBeforeError int
+
+	// BitsPerComponent is the number of bits per pixel of the data passed to EncodeBytes:
+	// 1 for the bilevel image samples, in the same format as the decoded data, or 8 (the default)
+	// for the grayscale data, where the 255 values are white pixels. Data of Columns*Rows bytes
+	// or more are grayscale data when BitsPerComponent is 1, as set by images with 1 bit samples.
+	BitsPerComponent int
+
+	// Maximum number of pixels of the decoded image, DefaultMaxImagePixels if 0.
+	maxImagePixels int64
 }
 
 // NewCCITTFaxEncoder makes a new CCITTFax encoder.
@@ -1741,6 +2054,7 @@ func (enc *CCITTFaxEncoder) MakeStreamDict() *PdfObjectDictionary {
 // from the DecodeParms stream object dictionary entry.
 func newCCITTFaxEncoderFromStream(streamObj *PdfObjectStream, decodeParams *PdfObjectDictionary) (*CCITTFaxEncoder, error) {
 	encoder := NewCCITTFaxEncoder()
+	encoder.maxImagePixels = streamConfig(streamObj).MaxImagePixels
 
 	encDict := streamObj.PdfObjectDictionary
 	if encDict == nil {
@@ -1768,8 +2082,8 @@ func newCCITTFaxEncoderFromStream(streamObj *PdfObjectStream, decodeParams *PdfO
 			}
 		}
 		if decodeParams == nil {
-			common.Log.Error("DecodeParms not specified %#v", obj)
-			return nil, errors.New("invalid DecodeParms")
+			// All the parameters have the default values.
+			return encoder, nil
 		}
 	}
 
@@ -1827,7 +2141,7 @@ func newCCITTFaxEncoderFromStream(streamObj *PdfObjectStream, decodeParams *PdfO
 		}
 	}
 
-	if rows, err := GetNumberAsInt64(decodeParams.Get("DamagedRowsBeforeError")); err != nil {
+	if rows, err := GetNumberAsInt64(decodeParams.Get("DamagedRowsBeforeError")); err == nil {
 		encoder.DamagedRowsBeforeError = int(rows)
 	}
 
@@ -1892,13 +2206,24 @@ func (enc *CCITTFaxEncoder) UpdateParams(params *PdfObjectDictionary) {
 		}
 	}
 
-	if rows, err := GetNumberAsInt64(params.Get("DamagedRowsBeforeError")); err != nil {
+	if rows, err := GetNumberAsInt64(params.Get("DamagedRowsBeforeError")); err == nil {
 		enc.DamagedRowsBeforeError = int(rows)
 	}
+
+	if bpc, err := GetNumberAsInt64(params.Get("BitsPerComponent")); err == nil {
+		enc.BitsPerComponent = int(bpc)
+	}
 }
 
 // DecodeBytes decodes the CCITTFax encoded image data.
 func (enc *CCITTFaxEncoder) DecodeBytes(encoded []byte) ([]byte, error) {
+	rows := enc.Rows
+	if rows <= 0 {
+		rows = 1
+	}
+	if err := CheckImagePixels(int64(enc.Columns), int64(rows), enc.maxImagePixels); err != nil {
+		return nil, err
+	}
 	encoder := &ccittfax.Encoder{
 		K:                      enc.K,
 		Columns:                enc.Columns,
@@ -1915,32 +2240,16 @@ func (enc *CCITTFaxEncoder) DecodeBytes(encoded []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	// reassemble image
-	var decoded []byte
-	decodedIdx := 0
-	var bitPos byte
-	var currentByte byte
+	// reassemble image, each row starting at a byte boundary
+	rowSize := (enc.Columns + 7) / 8
+	decoded := make([]byte, rowSize*len(pixels))
 	for i := range pixels {
-		for j := range pixels[i] {
-			currentByte |= pixels[i][j] << (7 - bitPos)
-
-			bitPos++
-
-			if bitPos == 8 {
-				decoded = append(decoded, currentByte)
-				currentByte = 0
-
-				decodedIdx++
-
-				bitPos = 0
-			}
+		row := decoded[i*rowSize:]
+		for j, pixel := range pixels[i] {
+			row[j/8] |= pixel << uint(7-j%8)
 		}
 	}
 
-	if bitPos > 0 {
-		decoded = append(decoded, currentByte)
-	}
-
 	return decoded, nil
 }
 
@@ -1950,25 +2259,44 @@ func (enc *CCITTFaxEncoder) DecodeStream(streamObj *PdfObjectStream) ([]byte, er
 }
 
 // EncodeBytes encodes the image data using either Group3 or Group4 CCITT facsimile (fax) encoding.
-// `data` is expected to be 1 color component, 1 byte per component.
+// `data` is expected to be 1 color component, with BitsPerComponent bits per component.
 func (enc *CCITTFaxEncoder) EncodeBytes(data []byte) ([]byte, error) {
-	var pixels [][]byte
+	if enc.Columns <= 0 {
+		return nil, errors.New("invalid number of columns")
+	}
 
-	for i := 0; i < len(data); i += enc.Columns {
-		pixelsRow := make([]byte, enc.Columns)
+	bpc := enc.BitsPerComponent
+	if bpc == 1 && enc.Rows > 0 && len(data) >= enc.Columns*enc.Rows {
+		// 8 bit samples of an image with BitsPerComponent set to 1, as
+		// passed before the 1 bit samples were supported.
+		bpc = 8
+	}
 
-		pixel := 0
-		for j := 0; j < enc.Columns; j++ {
-			if data[i+j] == 255 {
-				pixelsRow[pixel] = 1
-			} else {
-				pixelsRow[pixel] = 0
+	var pixels [][]byte
+	if bpc == 1 {
+		rowSize := (enc.Columns + 7) / 8
+		for i := 0; i+rowSize <= len(data); i += rowSize {
+			pixelsRow := make([]byte, enc.Columns)
+			for j := range pixelsRow {
+				pixelsRow[j] = (data[i+j/8] >> uint(7-j%8)) & 1
 			}
 
-			pixel++
+			pixels = append(pixels, pixelsRow)
 		}
+	} else {
+		for i := 0; i+enc.Columns <= len(data); i += enc.Columns {
+			pixelsRow := make([]byte, enc.Columns)
+			for j := range pixelsRow {
+				if data[i+j] == 255 {
+					pixelsRow[j] = 1
+				}
+			}
 
-		pixels = append(pixels, pixelsRow)
+			pixels = append(pixels, pixelsRow)
+		}
+	}
+	if len(pixels) == 0 {
+		return nil, errors.New("not enough data to encode")
 	}
 
 	encoder := &ccittfax.Encoder{
@@ -1985,54 +2313,6 @@ func (enc *CCITTFaxEncoder) EncodeBytes(data []byte) ([]byte, error) {
 	return encoder.Encode(pixels), nil
 }
 
-// JPXEncoder implements JPX encoder/decoder (dummy, for now)
-// FIXME: implement
-type JPXEncoder struct{}
-
-// NewJPXEncoder returns a new instance of JPXEncoder.
-func NewJPXEncoder() *JPXEncoder {
-	return &JPXEncoder{}
-}
-
-// GetFilterName returns the name of the encoding filter.
-func (enc *JPXEncoder) GetFilterName() string {
-	return StreamEncodingFilterNameJPX
-}
-
-// MakeDecodeParams makes a new instance of an encoding dictionary based on
-// the current encoder settings.
-func (enc *JPXEncoder) MakeDecodeParams() PdfObject {
-	return nil
-}
-
-// MakeStreamDict makes a new instance of an encoding dictionary for a stream object.
-func (enc *JPXEncoder) MakeStreamDict() *PdfObjectDictionary {
-	return MakeDict()
-}
-
-// UpdateParams updates the parameter values of the encoder.
-func (enc *JPXEncoder) UpdateParams(params *PdfObjectDictionary) {
-}
-
-// DecodeBytes decodes a slice of JPX encoded bytes and returns the result.
-func (enc *JPXEncoder) DecodeBytes(encoded []byte) ([]byte, error) {
-	common.Log.Debug("Error: Attempting to use unsupported encoding %s", enc.GetFilterName())
-	return encoded, ErrNoJPXDecode
-}
-
-// DecodeStream decodes a JPX encoded stream and returns the result as a
-// slice of bytes.
-func (enc *JPXEncoder) DecodeStream(streamObj *PdfObjectStream) ([]byte, error) {
-	common.Log.Debug("Error: Attempting to use unsupported encoding %s", enc.GetFilterName())
-	return streamObj.Stream, ErrNoJPXDecode
-}
-
-// EncodeBytes JPX encodes the passed in slice of bytes.
-func (enc *JPXEncoder) EncodeBytes(data []byte) ([]byte, error) {
-	common.Log.Debug("Error: Attempting to use unsupported encoding %s", enc.GetFilterName())
-	return data, ErrNoJPXDecode
-}
-
 // MultiEncoder supports serial encoding.
 type MultiEncoder struct {
 	// Encoders in the order that they are to be applied.
@@ -2047,6 +2327,62 @@ func NewMultiEncoder() *MultiEncoder {
 	return &encoder
 }
 
+// ChainEncoders returns a MultiEncoder chaining the `encoders`, in the order of the Filter array
+// of the encoded streams, i.e. the order in which they are applied when decoding. The data are
+// encoded in the reverse order. The MultiEncoders are flattened and the raw encoders skipped.
+// An error is returned if the encoders cannot be chained in this order (see Validate).
+//
+// Example, for ASCII85 encoded Flate data:
+//	encoder, err := core.ChainEncoders(core.NewASCII85Encoder(), core.NewFlateEncoder())
+func ChainEncoders(encoders ...StreamEncoder) (*MultiEncoder, error) {
+	mencoder := NewMultiEncoder()
+	for _, encoder := range encoders {
+		switch e := encoder.(type) {
+		case nil:
+			return nil, errors.New("nil encoder")
+		case *RawEncoder:
+		case *MultiEncoder:
+			mencoder.encoders = append(mencoder.encoders, e.encoders...)
+		default:
+			mencoder.AddEncoder(e)
+		}
+	}
+	if err := mencoder.Validate(); err != nil {
+		return nil, err
+	}
+	return mencoder, nil
+}
+
+// Validate checks that the encoders can be chained in their order. The Crypt filter can only be
+// the first filter, and the image filters (DCTDecode, JPXDecode, JBIG2Decode and CCITTFaxDecode),
+// which decode to the image samples, only the last one.
+func (enc *MultiEncoder) Validate() error {
+	for i, encoder := range enc.encoders {
+		switch encoder.GetFilterName() {
+		case StreamEncodingFilterNameRaw:
+			return errors.New("raw encoder in filter chain")
+		case StreamEncodingFilterNameCrypt:
+			if i != 0 {
+				return fmt.Errorf("filter %s must be the first filter", StreamEncodingFilterNameCrypt)
+			}
+		case StreamEncodingFilterNameDCT, StreamEncodingFilterNameJPX, StreamEncodingFilterNameJBIG2,
+			StreamEncodingFilterNameCCITTFax:
+			if i != len(enc.encoders)-1 {
+				return fmt.Errorf("image filter %s must be the last filter", encoder.GetFilterName())
+			}
+		}
+		if _, ok := encoder.(*MultiEncoder); ok {
+			return errors.New("nested multi encoder in filter chain")
+		}
+	}
+	return nil
+}
+
+// GetEncoders returns the underlying encoders, in the order of the Filter array.
+func (enc *MultiEncoder) GetEncoders() []StreamEncoder {
+	return enc.encoders
+}
+
 func newMultiEncoderFromStream(streamObj *PdfObjectStream) (*MultiEncoder, error) {
 	mencoder := NewMultiEncoder()
 
@@ -2120,7 +2456,10 @@ func newMultiEncoderFromStream(streamObj *PdfObjectStream) (*MultiEncoder, error
 		}
 
 		common.Log.Trace("Next name: %s, dp: %v, dParams: %v", *name, dp, dParams)
-		if *name == StreamEncodingFilterNameFlate {
+		if *name == cryptStreamFilter {
+			// Already decrypted by the crypt handler.
+			continue
+		} else if *name == StreamEncodingFilterNameFlate {
 			// TODO: need to separate out the DecodeParms..
 			encoder, err := newFlateEncoderFromStream(streamObj, dParams)
 			if err != nil {
@@ -2133,6 +2472,12 @@ func newMultiEncoderFromStream(streamObj *PdfObjectStream) (*MultiEncoder, error
 				return nil, err
 			}
 			mencoder.AddEncoder(encoder)
+		} else if *name == StreamEncodingFilterNameRunLength {
+			encoder, err := newRunLengthEncoderFromStream(streamObj, dParams)
+			if err != nil {
+				return nil, err
+			}
+			mencoder.AddEncoder(encoder)
 		} else if *name == StreamEncodingFilterNameASCIIHex {
 			encoder := NewASCIIHexEncoder()
 			mencoder.AddEncoder(encoder)
@@ -2140,16 +2485,22 @@ func newMultiEncoderFromStream(streamObj *PdfObjectStream) (*MultiEncoder, error
 			encoder := NewASCII85Encoder()
 			mencoder.AddEncoder(encoder)
 		} else if *name == StreamEncodingFilterNameDCT {
-			encoder, err := newDCTEncoderFromStream(streamObj, mencoder)
+			encoder, err := newDCTEncoderFromStream(streamObj, mencoder, dParams)
 			if err != nil {
 				return nil, err
 			}
 			mencoder.AddEncoder(encoder)
 			common.Log.Trace("Added DCT encoder...")
 			common.Log.Trace("Multi encoder: %#v", mencoder)
+		} else if *name == StreamEncodingFilterNameJPX {
+			encoder, err := newJPXEncoderFromStream(streamObj, mencoder)
+			if err != nil {
+				return nil, err
+			}
+			mencoder.AddEncoder(encoder)
 		} else {
 			common.Log.Error("Unsupported filter %s", *name)
-			return nil, fmt.Errorf("invalid filter in multi filter array")
+			return nil, ErrUnsupportedFilter{Name: string(*name)}
 		}
 	}
 
@@ -2181,14 +2532,19 @@ func (enc *MultiEncoder) MakeDecodeParams() PdfObject {
 	}
 
 	array := MakeArray()
+	hasParams := false
 	for _, encoder := range enc.encoders {
 		decodeParams := encoder.MakeDecodeParams()
 		if decodeParams == nil {
 			array.Append(MakeNull())
 		} else {
 			array.Append(decodeParams)
+			hasParams = true
 		}
 	}
+	if !hasParams {
+		return nil
+	}
 
 	return array
 }
@@ -2260,8 +2616,13 @@ func (enc *MultiEncoder) DecodeStream(streamObj *PdfObjectStream) ([]byte, error
 }
 
 // EncodeBytes encodes the passed in slice of bytes by passing it through the
-// EncodeBytes method of the underlying encoders.
+// EncodeBytes method of the underlying encoders. An error is returned if the encoders cannot be
+// chained in their order.
 func (enc *MultiEncoder) EncodeBytes(data []byte) ([]byte, error) {
+	if err := enc.Validate(); err != nil {
+		return nil, err
+	}
+
 	encoded := data
 	var err error
 
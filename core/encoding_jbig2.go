@@ -296,7 +296,7 @@ func newJBIG2DecoderFromStream(streamObj *PdfObjectStream, decodeParams *PdfObje
 
 	// If decodeParams not provided, see if we can get from the stream.
 	if decodeParams == nil {
-		obj := encDict.Get("DecodeParms")
+		obj := TraceToDirectObject(encDict.Get("DecodeParms"))
 		if obj != nil {
 			switch t := obj.(type) {
 			case *PdfObjectDictionary:
@@ -323,14 +323,18 @@ func newJBIG2DecoderFromStream(streamObj *PdfObjectStream, decodeParams *PdfObje
 	if globals == nil {
 		return encoder, nil
 	}
-	// decode and set JBIG2 Globals.
+	// decode and set JBIG2 Globals. The globals stream is usually shared by several images
+	// and referenced indirectly.
 	var err error
-	globalsStream, ok := globals.(*PdfObjectStream)
+	globalsStream, ok := GetStream(globals)
 	if !ok {
 		err = errors.Error(processName, "jbig2.Globals stream should be an Object Stream")
 		common.Log.Debug("ERROR: %v", err)
 		return nil, err
 	}
+	if err = globalsStream.LoadData(); err != nil {
+		return nil, err
+	}
 	encoder.Globals, err = jbig2.DecodeGlobals(globalsStream.Stream)
 	if err != nil {
 		err = errors.Wrap(err, processName, "corrupted jbig2 encoded data")
@@ -8,6 +8,8 @@ package core
 import (
 	"image"
 	"image/color"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -50,3 +52,79 @@ func TestImageToJBIG2Image(t *testing.T) {
 		assert.Equal(t, jb2.Data, bm.Data)
 	})
 }
+
+// TestJBIG2DecodeStream tests decoding JBIG2 image streams with DecodeStream, with and without
+// globals.
+func TestJBIG2DecodeStream(t *testing.T) {
+	readFixture := func(name string) []byte {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		require.NoError(t, err)
+		return data
+	}
+	makeStream := func(data []byte, decodeParms PdfObject) *PdfObjectStream {
+		dict := MakeDict()
+		dict.Set("Filter", MakeName(StreamEncodingFilterNameJBIG2))
+		if decodeParms != nil {
+			dict.Set("DecodeParms", decodeParms)
+		}
+		return &PdfObjectStream{PdfObjectDictionary: dict, Stream: data}
+	}
+	// isBlack returns true if the pixel at (x,y) of the 1-bit image `data` of width `w` is black.
+	isBlack := func(data []byte, w, x, y int) bool {
+		i := y*w + x
+		return data[i/8]&(0x80>>uint(i%8)) == 0
+	}
+
+	t.Run("Generic", func(t *testing.T) {
+		// Generic region of a 13x9 image with a vertical line and two diagonals.
+		const w, h = 13, 9
+		decoded, err := DecodeStream(makeStream(readFixture("jbig2_generic.jb2"), nil))
+		require.NoError(t, err)
+		require.Len(t, decoded, (w*h+7)/8)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				expected := x == 0 || x == y || x == w-1-y
+				assert.Equal(t, expected, isBlack(decoded, w, x, y), "(%d,%d)", x, y)
+			}
+		}
+	})
+
+	t.Run("Globals", func(t *testing.T) {
+		// Text region of a 52x66 image referring to the symbols of a symbol dictionary
+		// stored in a globals stream shared by several images.
+		const w, h = 52, 66
+		text := readFixture("jbig2_text.jb2")
+		globals := &PdfObjectStream{PdfObjectDictionary: MakeDict(), Stream: readFixture("jbig2_globals.jb2")}
+
+		parser := NewParserFromString("")
+		parser.ObjCache[5] = globals
+		ref := &PdfObjectReference{parser: parser, ObjectNumber: 5}
+
+		var results [][]byte
+		for _, g := range []PdfObject{globals, ref} {
+			decodeParms := MakeDict()
+			decodeParms.Set("JBIG2Globals", g)
+			decoded, err := DecodeStream(makeStream(text, decodeParms))
+			require.NoError(t, err)
+			require.Len(t, decoded, (w*h+7)/8)
+			results = append(results, decoded)
+		}
+		assert.Equal(t, results[0], results[1])
+
+		var black int
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				if isBlack(results[0], w, x, y) {
+					black++
+				}
+			}
+		}
+		assert.True(t, black > 0 && black < w*h/2, "%d black pixels", black)
+		assert.False(t, isBlack(results[0], w, 0, 0))
+		assert.True(t, isBlack(results[0], w, 17, 22))
+
+		// The symbols are missing without the globals.
+		_, err := DecodeStream(makeStream(text, nil))
+		assert.Error(t, err)
+	})
+}
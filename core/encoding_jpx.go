@@ -0,0 +1,137 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package core
+
+import (
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/internal/jpeg2000"
+)
+
+// JPXEncoder implements the JPX (JPEG 2000) decoder. Both the raw codestreams and the JP2 files
+// are decoded into the image samples, the color components being interleaved and each row
+// starting at a byte boundary. Encoding is not supported.
+type JPXEncoder struct {
+	// Format of the decoded image, set from the encoded data when the encoder is created from
+	// a stream, and updated when the data is decoded.
+	ColorComponents  int // 1 (gray), 3 (rgb), 4 (cmyk)
+	BitsPerComponent int // 1, 2, 4, 8 or 16 bit
+	Width            int
+	Height           int
+
+	// ColorSpace is the colorspace of the image specified by the JPEG 2000 data, or implied by
+	// its number of color components: DeviceGray, DeviceRGB, DeviceCMYK or empty if not known.
+	// It is used when the image dictionary has no ColorSpace entry.
+	ColorSpace PdfObjectName
+
+	// ReduceResolution is the number of the highest resolution levels discarded when decoding,
+	// each one halving the width and the height of the decoded image. It allows decoding
+	// thumbnails of the images quickly.
+	ReduceResolution int
+
+	// Maximum number of pixels of the decoded image, DefaultMaxImagePixels if 0.
+	maxImagePixels int64
+}
+
+// NewJPXEncoder returns a new instance of JPXEncoder.
+func NewJPXEncoder() *JPXEncoder {
+	return &JPXEncoder{}
+}
+
+// newJPXEncoderFromStream creates a new JPX decoder from the stream object, reading the format of
+// the image from the JPEG 2000 data.
+func newJPXEncoderFromStream(streamObj *PdfObjectStream, multiEnc *MultiEncoder) (*JPXEncoder, error) {
+	encoder := NewJPXEncoder()
+	encoder.maxImagePixels = streamConfig(streamObj).MaxImagePixels
+
+	// If using JPXDecode in combination with other filters, make sure to decode that first...
+	encoded := streamObj.Stream
+	if multiEnc != nil {
+		e, err := multiEnc.DecodeBytes(encoded)
+		if err != nil {
+			return nil, err
+		}
+		encoded = e
+	}
+
+	cfg, err := jpeg2000.DecodeConfig(encoded)
+	if err != nil {
+		// The error is reported when decoding.
+		common.Log.Debug("ERROR: Invalid JPX data: %v", err)
+		return encoder, nil
+	}
+	encoder.setConfig(cfg)
+	common.Log.Trace("JPX Encoder: %+v", encoder)
+	return encoder, nil
+}
+
+// setConfig sets the format of the decoded image.
+func (enc *JPXEncoder) setConfig(cfg *jpeg2000.Config) {
+	enc.ColorComponents = cfg.ColorComponents
+	enc.BitsPerComponent = cfg.BitsPerComponent
+	enc.Width = cfg.Width
+	enc.Height = cfg.Height
+	switch cfg.ColorSpace {
+	case jpeg2000.ColorSpaceGray:
+		enc.ColorSpace = "DeviceGray"
+	case jpeg2000.ColorSpaceRGB:
+		enc.ColorSpace = "DeviceRGB"
+	case jpeg2000.ColorSpaceCMYK:
+		enc.ColorSpace = "DeviceCMYK"
+	default:
+		enc.ColorSpace = ""
+	}
+}
+
+// GetFilterName returns the name of the encoding filter.
+func (enc *JPXEncoder) GetFilterName() string {
+	return StreamEncodingFilterNameJPX
+}
+
+// MakeDecodeParams makes a new instance of an encoding dictionary based on
+// the current encoder settings.
+func (enc *JPXEncoder) MakeDecodeParams() PdfObject {
+	// Does not have decode params.
+	return nil
+}
+
+// MakeStreamDict makes a new instance of an encoding dictionary for a stream object.
+func (enc *JPXEncoder) MakeStreamDict() *PdfObjectDictionary {
+	dict := MakeDict()
+	dict.Set("Filter", MakeName(enc.GetFilterName()))
+	return dict
+}
+
+// UpdateParams updates the parameter values of the encoder.
+func (enc *JPXEncoder) UpdateParams(params *PdfObjectDictionary) {
+}
+
+// DecodeBytes decodes a slice of JPX encoded bytes and returns the result.
+func (enc *JPXEncoder) DecodeBytes(encoded []byte) ([]byte, error) {
+	if cfg, err := jpeg2000.DecodeConfig(encoded); err == nil {
+		if err := CheckImagePixels(int64(cfg.Width), int64(cfg.Height), enc.maxImagePixels); err != nil {
+			return nil, err
+		}
+	}
+	img, err := jpeg2000.Decode(encoded, &jpeg2000.DecodeOptions{ReduceResolution: enc.ReduceResolution})
+	if err != nil {
+		common.Log.Debug("ERROR: JPX decoding failed: %v", err)
+		return nil, err
+	}
+	enc.setConfig(&img.Config)
+	return img.Data, nil
+}
+
+// DecodeStream decodes a JPX encoded stream and returns the result as a
+// slice of bytes.
+func (enc *JPXEncoder) DecodeStream(streamObj *PdfObjectStream) ([]byte, error) {
+	return enc.DecodeBytes(streamObj.Stream)
+}
+
+// EncodeBytes JPX encodes the passed in slice of bytes.
+func (enc *JPXEncoder) EncodeBytes(data []byte) ([]byte, error) {
+	common.Log.Debug("Error: Attempting to use unsupported encoding %s", enc.GetFilterName())
+	return data, ErrNoJPXDecode
+}
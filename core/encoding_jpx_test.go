@@ -0,0 +1,72 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package core
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestJPXDecodeStream tests decoding a JP2 image stream, alone and following another filter.
+func TestJPXDecodeStream(t *testing.T) {
+	encoded, err := ioutil.ReadFile(filepath.Join("testdata", "jpx_rgb.jp2"))
+	require.NoError(t, err)
+	expected, err := ioutil.ReadFile(filepath.Join("testdata", "jpx_rgb.raw"))
+	require.NoError(t, err)
+
+	checkEncoder := func(t *testing.T, jpx *JPXEncoder) {
+		assert.Equal(t, 40, jpx.Width)
+		assert.Equal(t, 30, jpx.Height)
+		assert.Equal(t, 3, jpx.ColorComponents)
+		assert.Equal(t, 8, jpx.BitsPerComponent)
+		assert.Equal(t, PdfObjectName("DeviceRGB"), jpx.ColorSpace)
+	}
+
+	t.Run("JPX", func(t *testing.T) {
+		dict := MakeDict()
+		dict.Set("Filter", MakeName(StreamEncodingFilterNameJPX))
+		stream := &PdfObjectStream{PdfObjectDictionary: dict, Stream: encoded}
+
+		encoder, err := NewEncoderFromStream(stream)
+		require.NoError(t, err)
+		jpx, ok := encoder.(*JPXEncoder)
+		require.True(t, ok)
+		checkEncoder(t, jpx)
+
+		decoded, err := DecodeStream(stream)
+		require.NoError(t, err)
+		assert.Equal(t, expected, decoded)
+
+		// Thumbnail decoded at a quarter of the resolution.
+		jpx.ReduceResolution = 2
+		decoded, err = jpx.DecodeStream(stream)
+		require.NoError(t, err)
+		assert.Equal(t, 10, jpx.Width)
+		assert.Equal(t, 8, jpx.Height)
+		assert.Len(t, decoded, 10*8*3)
+	})
+
+	t.Run("FlateJPX", func(t *testing.T) {
+		flated, err := NewFlateEncoder().EncodeBytes(encoded)
+		require.NoError(t, err)
+		dict := MakeDict()
+		dict.Set("Filter", MakeArray(MakeName(StreamEncodingFilterNameFlate), MakeName(StreamEncodingFilterNameJPX)))
+		stream := &PdfObjectStream{PdfObjectDictionary: dict, Stream: flated}
+
+		decoded, err := DecodeStream(stream)
+		require.NoError(t, err)
+		assert.Equal(t, expected, decoded)
+	})
+
+	t.Run("Encode", func(t *testing.T) {
+		_, err := NewJPXEncoder().EncodeBytes(expected)
+		assert.Equal(t, ErrNoJPXDecode, err)
+	})
+}
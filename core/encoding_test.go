@@ -6,9 +6,18 @@
 package core
 
 import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
 	"encoding/base64"
+	"encoding/hex"
+	"math/rand"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
 	"github.com/unidoc/unipdf/v3/common"
 )
 
@@ -165,31 +174,82 @@ func TestPostDecodingPredictors(t *testing.T) {
 	}
 }
 
+// TestFlateEncodingPNGPredictors checks that the data encoded with the PNG predictors is decoded
+// identically from the stream dictionary of the encoder.
+func TestFlateEncodingPNGPredictors(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for predictor := 10; predictor <= 15; predictor++ {
+		for _, colors := range []int{1, 3, 4} {
+			for _, bpc := range []int{1, 2, 8, 16} {
+				encoder := NewFlateEncoder()
+				encoder.Predictor = predictor
+				encoder.Colors = colors
+				encoder.BitsPerComponent = bpc
+				encoder.Columns = 13
+
+				rowBytes := (encoder.Columns*colors*bpc + 7) / 8
+				data := make([]byte, 7*rowBytes)
+				for i := range data {
+					// Smooth data with some noise.
+					data[i] = byte(i%rowBytes*3 + rnd.Intn(4))
+				}
+
+				encoded, err := encoder.EncodeBytes(data)
+				require.NoError(t, err)
+				stream := &PdfObjectStream{PdfObjectDictionary: encoder.MakeStreamDict(), Stream: encoded}
+				decoded, err := DecodeStream(stream)
+				require.NoError(t, err, "predictor %d colors %d bpc %d", predictor, colors, bpc)
+				require.Equal(t, data, decoded, "predictor %d colors %d bpc %d", predictor, colors, bpc)
+			}
+		}
+	}
+
+	// The data length must be a multiple of the row length.
+	encoder := NewFlateEncoder()
+	encoder.Predictor = 15
+	encoder.Columns = 10
+	_, err := encoder.EncodeBytes(make([]byte, 25))
+	require.Error(t, err)
+
+	// Smooth data is compressed better with the predictors.
+	encoder.Columns = 256
+	data := make([]byte, 256*256)
+	for i := range data {
+		data[i] = byte(i/256 + i%256 + rnd.Intn(3))
+	}
+	predicted, err := encoder.EncodeBytes(data)
+	require.NoError(t, err)
+	plain, err := NewFlateEncoder().EncodeBytes(data)
+	require.NoError(t, err)
+	require.True(t, len(predicted) < len(plain)*2/3, "%d >= %d*2/3", len(predicted), len(plain))
+}
+
 // Test LZW encoding.
 func TestLZWEncoding(t *testing.T) {
 	rawStream := []byte("this is a dummy text with some \x01\x02\x03 binary data")
 
-	encoder := NewLZWEncoder()
-	// Only supporitng early change 0 for encoding at the moment.
-	encoder.EarlyChange = 0
+	for _, earlyChange := range []int{0, 1} {
+		encoder := NewLZWEncoder()
+		encoder.EarlyChange = earlyChange
 
-	encoded, err := encoder.EncodeBytes(rawStream)
-	if err != nil {
-		t.Errorf("Failed to encode data: %v", err)
-		return
-	}
+		encoded, err := encoder.EncodeBytes(rawStream)
+		if err != nil {
+			t.Errorf("Failed to encode data: %v", err)
+			return
+		}
 
-	decoded, err := encoder.DecodeBytes(encoded)
-	if err != nil {
-		t.Errorf("Failed to decode data: %v", err)
-		return
-	}
+		decoded, err := encoder.DecodeBytes(encoded)
+		if err != nil {
+			t.Errorf("Failed to decode data: %v", err)
+			return
+		}
 
-	if !compareSlices(decoded, rawStream) {
-		t.Errorf("Slices not matching")
-		t.Errorf("Decoded (%d): % x", len(encoded), encoded)
-		t.Errorf("Raw     (%d): % x", len(rawStream), rawStream)
-		return
+		if !compareSlices(decoded, rawStream) {
+			t.Errorf("Slices not matching (EarlyChange %d)", earlyChange)
+			t.Errorf("Decoded (%d): % x", len(encoded), encoded)
+			t.Errorf("Raw     (%d): % x", len(rawStream), rawStream)
+			return
+		}
 	}
 }
 
@@ -367,3 +427,446 @@ func TestMultiEncoder(t *testing.T) {
 		return
 	}
 }
+
+// TestChainEncoders checks the chaining of the encoders, the stream dictionaries of the chains
+// and the validation of the filter orders.
+func TestChainEncoders(t *testing.T) {
+	rawStream := []byte("this is a dummy text with some \x01\x02\x03 binary data")
+
+	lzw := NewLZWEncoder()
+	lzw.EarlyChange = 0
+	flate := NewFlateEncoder()
+	flate.Predictor = 12
+	flate.Columns = 4
+	inner := NewMultiEncoder()
+	inner.AddEncoder(lzw)
+	inner.AddEncoder(flate)
+
+	encoder, err := ChainEncoders(NewCryptEncoder("StdCF"), NewASCII85Encoder(), NewRawEncoder(), inner)
+	require.NoError(t, err)
+	require.Len(t, encoder.GetEncoders(), 4)
+	assert.Equal(t, "Crypt ASCII85Decode LZWDecode FlateDecode", encoder.GetFilterName())
+	assert.Equal(t,
+		"<</Filter [/Crypt /ASCII85Decode /LZWDecode /FlateDecode]"+
+			"/DecodeParms [<</Type /CryptFilterDecodeParms/Name /StdCF>> null <</EarlyChange 0>> <</Predictor 12/Columns 4>>]>>",
+		encoder.MakeStreamDict().WriteString())
+
+	// The data are encoded in the reverse order.
+	encoded, err := encoder.EncodeBytes(rawStream[:44])
+	require.NoError(t, err)
+	decoded, err := NewASCII85Encoder().DecodeBytes(encoded)
+	require.NoError(t, err)
+	decoded, err = lzw.DecodeBytes(decoded)
+	require.NoError(t, err)
+	decoded, err = flate.DecodeStream(&PdfObjectStream{Stream: decoded})
+	require.NoError(t, err)
+	require.Equal(t, rawStream[:44], decoded)
+
+	// No decode parameters.
+	encoder, err = ChainEncoders(NewASCIIHexEncoder(), NewFlateEncoder())
+	require.NoError(t, err)
+	assert.Equal(t, "<</Filter [/ASCIIHexDecode /FlateDecode]>>", encoder.MakeStreamDict().WriteString())
+
+	// Unsupported orders.
+	_, err = ChainEncoders(NewFlateEncoder(), NewCryptEncoder(""))
+	require.Error(t, err)
+	_, err = ChainEncoders(NewDCTEncoder(), NewASCII85Encoder())
+	require.Error(t, err)
+	_, err = ChainEncoders(NewASCII85Encoder(), nil)
+	require.Error(t, err)
+	_, err = ChainEncoders(NewASCII85Encoder(), NewDCTEncoder())
+	require.NoError(t, err)
+
+	invalid := NewMultiEncoder()
+	invalid.AddEncoder(NewJBIG2Encoder())
+	invalid.AddEncoder(NewFlateEncoder())
+	_, err = invalid.EncodeBytes(rawStream)
+	require.Error(t, err)
+}
+
+// TestMultiFilterDecoding decodes a stream encoded with three filters independently.
+func TestMultiFilterDecoding(t *testing.T) {
+	rawStream := []byte(strings.Repeat("this is a dummy text with some \x01\x02\x03 binary data\n", 10))
+
+	var flate bytes.Buffer
+	w := zlib.NewWriter(&flate)
+	_, err := w.Write(rawStream)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	a85 := make([]byte, ascii85.MaxEncodedLen(flate.Len()))
+	a85 = append(a85[:ascii85.Encode(a85, flate.Bytes())], '~', '>')
+	encoded := []byte(hex.EncodeToString(a85) + ">")
+
+	dict := MakeDict()
+	dict.Set("Filter", MakeArray(
+		MakeName(StreamEncodingFilterNameASCIIHex),
+		MakeName(StreamEncodingFilterNameASCII85),
+		MakeName(StreamEncodingFilterNameFlate)))
+	dict.Set("DecodeParms", MakeArray(MakeNull(), MakeNull(), MakeDict()))
+	dict.Set("Length", MakeInteger(int64(len(encoded))))
+
+	decoded, err := DecodeStream(&PdfObjectStream{PdfObjectDictionary: dict, Stream: encoded})
+	require.NoError(t, err)
+	require.Equal(t, rawStream, decoded)
+
+	// The stream is encoded again with the same filters.
+	stream := &PdfObjectStream{PdfObjectDictionary: dict, Stream: rawStream}
+	require.NoError(t, EncodeStream(stream))
+	decoded, err = DecodeStream(stream)
+	require.NoError(t, err)
+	require.Equal(t, rawStream, decoded)
+}
+
+// encodingTestData returns the random and structured data for the encoding round trip tests.
+func encodingTestData() map[string][]byte {
+	rnd := rand.New(rand.NewSource(1))
+	random := make([]byte, 50000)
+	rnd.Read(random)
+
+	// Runs of random lengths, up to longer than the maximum run length encoding ones.
+	var runs []byte
+	for len(runs) < 20000 {
+		n := 1 + rnd.Intn(300)
+		b := byte(rnd.Intn(4))
+		for i := 0; i < n; i++ {
+			runs = append(runs, b)
+		}
+	}
+
+	// Random data from a small alphabet, filling the LZW tables several times.
+	small := make([]byte, 200000)
+	for i := range small {
+		small[i] = "abcd"[rnd.Intn(4)]
+	}
+
+	return map[string][]byte{
+		"empty":   {},
+		"byte":    {0x42},
+		"zeros":   make([]byte, 1001),
+		"text":    []byte(strings.Repeat("this is a dummy text with some \x01\x02\x03 binary data\n", 200)),
+		"random":  random,
+		"runs":    runs,
+		"small":   small,
+		"partial": {0, 0, 0, 0, 0xFF, 0, 0},
+	}
+}
+
+// TestEncodingRoundTrip checks that the data encoded with each encoder is decoded identically,
+// both by the encoder and by the decoder created from the stream dictionary of the encoder.
+func TestEncodingRoundTrip(t *testing.T) {
+	lzw0 := NewLZWEncoder()
+	lzw0.EarlyChange = 0
+
+	multi := NewMultiEncoder()
+	multi.AddEncoder(NewASCII85Encoder())
+	multi.AddEncoder(NewLZWEncoder())
+	multi.AddEncoder(NewRunLengthEncoder())
+
+	multiHex := NewMultiEncoder()
+	multiHex.AddEncoder(NewASCIIHexEncoder())
+	multiHex.AddEncoder(lzw0)
+
+	encoders := map[string]StreamEncoder{
+		"LZW":                   NewLZWEncoder(),
+		"LZW EarlyChange 0":     lzw0,
+		"RunLength":             NewRunLengthEncoder(),
+		"ASCIIHex":              NewASCIIHexEncoder(),
+		"ASCII85":               NewASCII85Encoder(),
+		"ASCII85 LZW RunLength": multi,
+		"ASCIIHex LZW":          multiHex,
+	}
+
+	for encName, encoder := range encoders {
+		for dataName, data := range encodingTestData() {
+			t.Run(encName+"/"+dataName, func(t *testing.T) {
+				encoded, err := encoder.EncodeBytes(data)
+				require.NoError(t, err)
+
+				decoded, err := encoder.DecodeBytes(encoded)
+				require.NoError(t, err)
+				require.Equal(t, len(data), len(decoded))
+				require.True(t, bytes.Equal(data, decoded))
+
+				stream := &PdfObjectStream{PdfObjectDictionary: encoder.MakeStreamDict(), Stream: encoded}
+				decoded, err = DecodeStream(stream)
+				require.NoError(t, err)
+				require.Equal(t, len(data), len(decoded))
+				require.True(t, bytes.Equal(data, decoded))
+			})
+		}
+	}
+}
+
+// TestEncodingStreamDict checks the stream dictionaries of the encoders.
+func TestEncodingStreamDict(t *testing.T) {
+	lzw := NewLZWEncoder()
+	assert.Equal(t, "<</Filter /LZWDecode>>", lzw.MakeStreamDict().WriteString())
+
+	lzw.EarlyChange = 0
+	assert.Equal(t, "<</Filter /LZWDecode/DecodeParms <</EarlyChange 0>>>>", lzw.MakeStreamDict().WriteString())
+
+	multi := NewMultiEncoder()
+	multi.AddEncoder(NewASCII85Encoder())
+	multi.AddEncoder(lzw)
+	multi.AddEncoder(NewRunLengthEncoder())
+	assert.Equal(t,
+		"<</Filter [/ASCII85Decode /LZWDecode /RunLengthDecode]/DecodeParms [null <</EarlyChange 0>> null]>>",
+		multi.MakeStreamDict().WriteString())
+
+	// The EarlyChange parameter is also accepted in the stream dictionary.
+	dict := MakeDict()
+	dict.Set("Filter", MakeName(StreamEncodingFilterNameLZW))
+	dict.Set("EarlyChange", MakeInteger(0))
+	encoder, err := NewEncoderFromStream(&PdfObjectStream{PdfObjectDictionary: dict})
+	require.NoError(t, err)
+	require.Equal(t, 0, encoder.(*LZWEncoder).EarlyChange)
+}
+
+// TestLZWEncodingInterop checks the LZW encoded data against reference data.
+func TestLZWEncodingInterop(t *testing.T) {
+	// The example of the PDF specification (7.4.4.2 Details of LZW Encoding), with EarlyChange 1.
+	encoded, err := NewLZWEncoder().EncodeBytes([]byte{45, 45, 45, 45, 45, 65, 45, 45, 45, 66})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x80, 0x0B, 0x60, 0x50, 0x22, 0x0C, 0x0C, 0x85, 0x01}, encoded)
+
+	// The same codes are written with EarlyChange 0 for short data, as written by compress/lzw.
+	lzw := NewLZWEncoder()
+	lzw.EarlyChange = 0
+	encoded, err = lzw.EncodeBytes([]byte{45, 45, 45, 45, 45, 65, 45, 45, 45, 66})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x80, 0x0B, 0x60, 0x50, 0x22, 0x0C, 0x0C, 0x85, 0x01}, encoded)
+}
+
+// TestRunLengthEncodingRuns checks the encoding of the repeated and the literal bytes.
+func TestRunLengthEncodingRuns(t *testing.T) {
+	encoder := NewRunLengthEncoder()
+	encoded, err := encoder.EncodeBytes([]byte("abbbbc"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 'a', 253, 'b', 0, 'c', 128}, encoded)
+
+	encoded, err = encoder.EncodeBytes(make([]byte, 130))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{129, 0, 255, 0, 128}, encoded)
+
+	encoded, err = encoder.EncodeBytes(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{128}, encoded)
+}
+
+// TestASCII85EncodingZeros checks that only the full groups of zeros are abbreviated.
+func TestASCII85EncodingZeros(t *testing.T) {
+	encoder := NewASCII85Encoder()
+	encoded, err := encoder.EncodeBytes([]byte{0, 0, 0, 0, 0, 0})
+	require.NoError(t, err)
+	assert.Equal(t, "z!!!~>", string(encoded))
+}
+
+// bitsToBytes packs the bits of the string of 0s and 1s into bytes, ignoring the spaces.
+// The last byte is padded with 0s.
+func bitsToBytes(bits string) []byte {
+	var data []byte
+	var n int
+	for _, c := range bits {
+		if c != '0' && c != '1' {
+			continue
+		}
+		if n%8 == 0 {
+			data = append(data, 0)
+		}
+		if c == '1' {
+			data[n/8] |= 0x80 >> uint(n%8)
+		}
+		n++
+	}
+	return data
+}
+
+// TestCCITTFaxDecoding tests decoding 8x4 fax images of rows WWBBBBWW, WWWBBBWW, BBBBBBBB and
+// WWWWWWWW encoded with the T.4 and T.6 codes.
+func TestCCITTFaxDecoding(t *testing.T) {
+	const (
+		eol  = "000000000001 "
+		eol1 = "0000000000011 "
+		eol0 = "0000000000010 "
+		// 1D encoded rows.
+		row0 = "0111 011 0111 "
+		row1 = "1000 10 0111 "
+		row2 = "00110101 000101 "
+		row3 = "10011 "
+		// 2D encoded rows, each one with the previous one as the reference line.
+		row0r = "001 0111 011 1 "
+		row1r = "011 1 1 "
+		row2r = "0000010 000011 "
+		row3r = "001 10011 0000110111 "
+	)
+	expected := []byte{0xC3, 0xE3, 0x00, 0xFF}
+	rtc := strings.Repeat(eol, 6)
+	rtc2D := strings.Repeat(eol1, 6)
+
+	testcases := []struct {
+		name     string
+		params   map[string]PdfObject
+		bits     string
+		expected []byte
+	}{
+		{
+			name:   "G3 1D",
+			params: map[string]PdfObject{"Columns": MakeInteger(8)},
+			bits:   eol + row0 + eol + row1 + eol + row2 + eol + row3 + rtc,
+		},
+		{
+			name: "G3 1D EndOfLine Rows",
+			params: map[string]PdfObject{"Columns": MakeInteger(8), "EndOfLine": MakeBool(true),
+				"EndOfBlock": MakeBool(false), "Rows": MakeInteger(4)},
+			bits: eol + row0 + eol + row1 + eol + row2 + eol + row3,
+		},
+		{
+			// The fill bits make the EOL codes end on a byte boundary.
+			name:   "G3 1D EncodedByteAlign",
+			params: map[string]PdfObject{"Columns": MakeInteger(8), "EncodedByteAlign": MakeBool(true)},
+			bits: "0000" + eol + row0 + "0" + eol + row1 + "00" + eol + row2 + "000000" + eol + row3 +
+				"000" + rtc,
+		},
+		{
+			name:   "G3 1D BlackIs1",
+			params: map[string]PdfObject{"Columns": MakeInteger(8), "BlackIs1": MakeBool(true)},
+			bits:   eol + row0 + eol + row1 + eol + row2 + eol + row3 + rtc,
+			// The samples of the white pixels are 0.
+			expected: []byte{0x3C, 0x1C, 0xFF, 0x00},
+		},
+		{
+			name:   "G3 2D",
+			params: map[string]PdfObject{"Columns": MakeInteger(8), "K": MakeInteger(2)},
+			bits:   eol1 + row0 + eol0 + row1r + eol1 + row2 + eol0 + row3r + rtc2D,
+		},
+		{
+			// The 1D encoded rows are indicated by the tag bits, K being only their maximum distance.
+			name:   "G3 2D mixed",
+			params: map[string]PdfObject{"Columns": MakeInteger(8), "K": MakeInteger(4)},
+			bits:   eol1 + row0 + eol0 + row1r + eol1 + row2 + eol1 + row3 + rtc2D,
+		},
+		{
+			// No EOL codes, only the tag bits.
+			name: "G3 2D without EOL",
+			params: map[string]PdfObject{"Columns": MakeInteger(8), "K": MakeInteger(2),
+				"EndOfBlock": MakeBool(false), "Rows": MakeInteger(4)},
+			bits: "1" + row0 + "0" + row1r + "1" + row2 + "0" + row3r,
+		},
+		{
+			name: "G3 2D EncodedByteAlign",
+			params: map[string]PdfObject{"Columns": MakeInteger(8), "K": MakeInteger(2),
+				"EncodedByteAlign": MakeBool(true), "EndOfBlock": MakeBool(false), "Rows": MakeInteger(4)},
+			bits: "1" + row0 + "0000" + "0" + row1r + "00" + "1" + row2 + "0" + "0" + row3r,
+		},
+		{
+			name:   "G4",
+			params: map[string]PdfObject{"Columns": MakeInteger(8), "K": MakeInteger(-1)},
+			bits:   row0r + row1r + row2r + row3r + eol + eol,
+		},
+		{
+			name: "G4 EncodedByteAlign",
+			params: map[string]PdfObject{"Columns": MakeInteger(8), "K": MakeInteger(-1),
+				"EncodedByteAlign": MakeBool(true)},
+			bits: row0r + "00000" + row1r + "000" + row2r + "000" + row3r + "000000" + eol + eol,
+		},
+		{
+			// No EOFB, the data being over after the last row.
+			name:   "G4 without EOFB",
+			params: map[string]PdfObject{"Columns": MakeInteger(8), "K": MakeInteger(-1)},
+			bits:   row0r + row1r + row2r + row3r,
+		},
+		{
+			// The second row is replaced with the first one.
+			name: "G3 1D DamagedRowsBeforeError",
+			params: map[string]PdfObject{"Columns": MakeInteger(8), "EndOfLine": MakeBool(true),
+				"DamagedRowsBeforeError": MakeInteger(1)},
+			bits:     eol + row0 + eol + "000000001 01" + eol + row2 + eol + row3 + rtc,
+			expected: []byte{0xC3, 0xC3, 0x00, 0xFF},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			decodeParms := MakeDict()
+			for key, val := range tc.params {
+				decodeParms.Set(PdfObjectName(key), val)
+			}
+			dict := MakeDict()
+			dict.Set("Filter", MakeName(StreamEncodingFilterNameCCITTFax))
+			dict.Set("DecodeParms", decodeParms)
+			stream := &PdfObjectStream{PdfObjectDictionary: dict, Stream: bitsToBytes(tc.bits)}
+
+			decoded, err := DecodeStream(stream)
+			require.NoError(t, err)
+			if tc.expected != nil {
+				assert.Equal(t, tc.expected, decoded)
+			} else {
+				assert.Equal(t, expected, decoded)
+			}
+		})
+	}
+
+	// Too many damaged rows.
+	encoder := NewCCITTFaxEncoder()
+	encoder.Columns = 8
+	encoder.EndOfLine = true
+	_, err := encoder.DecodeBytes(bitsToBytes(eol + row0 + eol + "000000001 01" + eol + row2 + eol + row3 + rtc))
+	assert.Error(t, err)
+}
+
+// TestCCITTFaxEncoding tests encoding the bilevel images with the CCITT fax encodings and
+// decoding them back.
+func TestCCITTFaxEncoding(t *testing.T) {
+	// 13x5 image, each row padded to the byte boundary.
+	data := []byte{
+		0xFF, 0xF8,
+		0xF0, 0x38,
+		0x0F, 0xC0,
+		0x00, 0x00,
+		0xAA, 0xA8,
+	}
+
+	for _, k := range []int{-1, 0, 3} {
+		for _, align := range []bool{false, true} {
+			params := MakeDict()
+			params.Set("Width", MakeInteger(13))
+			params.Set("Height", MakeInteger(5))
+			params.Set("BitsPerComponent", MakeInteger(1))
+
+			encoder := NewCCITTFaxEncoder()
+			encoder.UpdateParams(params)
+			encoder.K = k
+			encoder.EncodedByteAlign = align
+
+			encoded, err := encoder.EncodeBytes(data)
+			require.NoError(t, err)
+
+			// Decode with the parameters of the stream dictionary.
+			stream := &PdfObjectStream{PdfObjectDictionary: encoder.MakeStreamDict(), Stream: encoded}
+			decoded, err := DecodeStream(stream)
+			require.NoError(t, err)
+			assert.Equal(t, data, decoded, "K=%d EncodedByteAlign=%t", k, align)
+		}
+	}
+
+	// The 8 bit data of images with BitsPerComponent set to 1 are encoded as
+	// the samples they contain.
+	var gray []byte
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 13; x++ {
+			gray = append(gray, 255*(data[2*y+x/8]>>uint(7-x%8)&1))
+		}
+	}
+	params := MakeDict()
+	params.Set("Width", MakeInteger(13))
+	params.Set("Height", MakeInteger(5))
+	params.Set("BitsPerComponent", MakeInteger(1))
+	encoder := NewCCITTFaxEncoder()
+	encoder.UpdateParams(params)
+	expected, err := encoder.EncodeBytes(data)
+	require.NoError(t, err)
+	encoded, err := encoder.EncodeBytes(gray)
+	require.NoError(t, err)
+	assert.Equal(t, expected, encoded)
+}
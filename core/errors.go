@@ -0,0 +1,87 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package core
+
+import (
+	"fmt"
+)
+
+// ErrUnsupportedFilter is the error returned when decoding streams encoded with a filter which is
+// not supported.
+type ErrUnsupportedFilter struct {
+	Name string // Name of the filter.
+}
+
+// Error implements the error interface.
+func (e ErrUnsupportedFilter) Error() string {
+	return fmt.Sprintf("unsupported encoding method (%s)", e.Name)
+}
+
+// ErrObjectNotFound is the error returned when an object cannot be loaded from the location given
+// by the cross-reference information of the file, even after attempting to repair it.
+type ErrObjectNotFound struct {
+	Num int   // Object number.
+	Err error // Underlying error, if any.
+}
+
+// Error implements the error interface.
+func (e ErrObjectNotFound) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("object %d not found", e.Num)
+	}
+	return fmt.Sprintf("object %d not found: %v", e.Num, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e ErrObjectNotFound) Unwrap() error {
+	return e.Err
+}
+
+// wrappedError is an error of a kind identified by a sentinel error (e.g. ErrInvalidXref), caused
+// by another error. errors.Is matches both the sentinel and the cause.
+type wrappedError struct {
+	kind  error
+	cause error
+}
+
+// wrapError returns an error of kind `kind` caused by `cause`, or `cause` if it is of kind `kind`
+// already.
+func wrapError(kind, cause error) error {
+	if cause == nil || cause == kind {
+		return kind
+	}
+	if w, ok := cause.(*wrappedError); ok && w.kind == kind {
+		return cause
+	}
+	return &wrappedError{kind: kind, cause: cause}
+}
+
+// Error implements the error interface.
+func (e *wrappedError) Error() string {
+	return fmt.Sprintf("%v: %v", e.kind, e.cause)
+}
+
+// Is returns true if `target` is the kind of the error.
+func (e *wrappedError) Is(target error) bool {
+	return target == e.kind
+}
+
+// Unwrap returns the cause of the error.
+func (e *wrappedError) Unwrap() error {
+	return e.cause
+}
+
+// ErrLimitExceeded is the error returned when processing a file exceeds one of the limits of the
+// ParserConfig of the parser, e.g. because of deeply nested objects or huge images.
+type ErrLimitExceeded struct {
+	Limit string // Name of the ParserConfig field.
+	Max   int64  // Value of the limit.
+}
+
+// Error implements the error interface.
+func (e ErrLimitExceeded) Error() string {
+	return fmt.Sprintf("limit exceeded: %s (%d)", e.Limit, e.Max)
+}
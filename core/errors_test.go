@@ -0,0 +1,114 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// makeErrorTestPdf returns a minimal document whose cross-reference table has an entry for object
+// 3 pointing to the table itself.
+func makeErrorTestPdf() string {
+	body := "%PDF-1.4\n" +
+		"1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+		"2 0 obj\n<< /Type /Pages /Kids [] /Count 0 >>\nendobj\n"
+	return body + fmt.Sprintf("xref\n0 4\n0000000000 65535 f\r\n%010d 00000 n\r\n%010d 00000 n\r\n"+
+		"%010d 00000 n\r\ntrailer\n<< /Size 4 /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		strings.Index(body, "1 0 obj"), strings.Index(body, "2 0 obj"), len(body), len(body))
+}
+
+func TestParserErrors(t *testing.T) {
+	t.Run("invalid xref", func(t *testing.T) {
+		_, err := NewParser(strings.NewReader("%PDF-1.4\nnot a pdf file\n%%EOF\n"))
+		require.Error(t, err)
+		require.True(t, errors.Is(err, ErrInvalidXref), "%v", err)
+	})
+
+	t.Run("no version", func(t *testing.T) {
+		_, err := NewParser(strings.NewReader("not a pdf file\n%%EOF\n"))
+		require.True(t, errors.Is(err, ErrNoPdfVersion), "%v", err)
+	})
+
+	t.Run("object not found", func(t *testing.T) {
+		parser, err := NewParser(strings.NewReader(makeErrorTestPdf()))
+		require.NoError(t, err)
+
+		// Without repairs, the entry pointing to the cross-reference table is an error.
+		_, _, err = parser.lookupByNumber(3, false)
+		var notFound ErrObjectNotFound
+		require.True(t, errors.As(err, &notFound), "%v", err)
+		require.Equal(t, 3, notFound.Num)
+
+		// Objects in object streams which are not in the file.
+		parser.xrefs.ObjectMap[4] = XrefObject{XType: XrefTypeObjectStream, ObjectNumber: 4, OsObjNumber: 10}
+		_, err = parser.LookupByNumber(4)
+		require.True(t, errors.As(err, &notFound), "%v", err)
+		require.Equal(t, 4, notFound.Num)
+
+		// Undefined objects are null objects.
+		obj, err := parser.LookupByNumber(20)
+		require.NoError(t, err)
+		require.True(t, IsNullObject(obj))
+	})
+
+	t.Run("encrypted", func(t *testing.T) {
+		f, err := os.Open(filepath.Join("testdata", "testcase_encry.pdf"))
+		require.NoError(t, err)
+		defer f.Close()
+		parser, err := NewParser(f)
+		require.NoError(t, err)
+		encrypted, err := parser.IsEncrypted()
+		require.NoError(t, err)
+		require.True(t, encrypted)
+
+		_, err = parser.LookupByNumber(1)
+		require.Equal(t, ErrEncrypted, err)
+		require.False(t, errors.Is(err, ErrWrongPassword))
+		require.True(t, errors.Is(ErrWrongPassword, ErrEncrypted))
+
+		ok, err := parser.Decrypt([]byte("456"))
+		require.NoError(t, err)
+		require.True(t, ok)
+		_, err = parser.LookupByNumber(1)
+		require.NoError(t, err)
+	})
+
+	t.Run("unsupported filter", func(t *testing.T) {
+		for _, filter := range []PdfObject{MakeName("FooDecode"), MakeArray(MakeName("FlateDecode"), MakeName("FooDecode"))} {
+			stream := &PdfObjectStream{PdfObjectDictionary: MakeDict(), Stream: []byte("data")}
+			stream.Set("Filter", filter)
+			_, err := DecodeStream(stream)
+			var unsupported ErrUnsupportedFilter
+			require.True(t, errors.As(err, &unsupported), "%v", err)
+			require.Equal(t, "FooDecode", unsupported.Name)
+		}
+	})
+
+	t.Run("range check", func(t *testing.T) {
+		stream := &PdfObjectStream{PdfObjectDictionary: MakeDict(), Stream: []byte("data")}
+		stream.Set("Filter", MakeName("FlateDecode"))
+		stream.Set("DecodeParms", MakeArray(MakeDict(), MakeDict()))
+		_, err := DecodeStream(stream)
+		require.True(t, errors.Is(err, ErrRangeError), "%v", err)
+	})
+}
+
+func TestWrapError(t *testing.T) {
+	cause := errors.New("cause")
+	err := wrapError(ErrInvalidXref, cause)
+	require.True(t, errors.Is(err, ErrInvalidXref))
+	require.True(t, errors.Is(err, cause))
+	require.Equal(t, "invalid cross-reference table: cause", err.Error())
+	require.Equal(t, err, wrapError(ErrInvalidXref, err))
+	require.Equal(t, ErrInvalidXref, wrapError(ErrInvalidXref, nil))
+}
@@ -92,7 +92,21 @@ func (parser *PdfParser) SetFileOffset(offset int64) {
 }
 
 // ReadBytesAt reads byte content at specific offset and length within the PDF.
+// When the underlying reader implements io.ReaderAt, the content is read without changing
+// the position of the parser.
 func (parser *PdfParser) ReadBytesAt(offset, len int64) ([]byte, error) {
+	if ra, ok := parser.rs.(io.ReaderAt); ok {
+		bb := make([]byte, len)
+		n, err := ra.ReadAt(bb, offset)
+		if int64(n) < len {
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return bb, nil
+	}
+
 	curPos := parser.GetFileOffset()
 
 	_, err := parser.rs.Seek(offset, io.SeekStart)
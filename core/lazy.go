@@ -0,0 +1,129 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package core
+
+import (
+	"io"
+	"sync"
+
+	"github.com/unidoc/unipdf/v3/common"
+)
+
+// lazyObjectStreamsCacheSize is the maximum number of decoded object streams kept in memory
+// by a parser in lazy-loading mode.
+const lazyObjectStreamsCacheSize = 16
+
+// lazyStreamData is the location of the data of a stream object parsed in lazy-loading mode.
+// The data is loaded once, under the lock, so that streams shared by the pages of a document
+// can be decoded concurrently.
+type lazyStreamData struct {
+	mu     sync.Mutex
+	offset int64
+	length int64
+	loaded bool
+}
+
+// SetLazyLoading enables or disables lazy-loading mode of the parser. In lazy-loading mode, the
+// data of stream objects is not read when the objects are parsed, only their location is kept
+// and the data is read from the underlying io.ReadSeeker on first use (see LoadData). The number
+// of decoded object streams kept in memory is also bounded.
+// The stream data of encrypted documents is always loaded when parsed, as it has to be decrypted,
+// as is the stream data of documents read from an io.ReadSeeker which does not implement
+// io.ReaderAt, as reading it would move the offset of the parser.
+func (parser *PdfParser) SetLazyLoading(lazy bool) {
+	parser.lazy = lazy
+	if !lazy {
+		parser.objstmsOrder = nil
+	}
+}
+
+// IsLazyLoading returns true if the parser is in lazy-loading mode.
+func (parser *PdfParser) IsLazyLoading() bool {
+	return parser.lazy
+}
+
+// LoadData reads the data of the stream from the file it was parsed from, if it has not
+// been loaded yet. It is called by the stream decoding functions, and needs only be called
+// prior to accessing the Stream field of stream objects parsed in lazy-loading mode.
+// Data assigned to the Stream field before loading takes precedence over the file data.
+// It is safe for concurrent use.
+func (stream *PdfObjectStream) LoadData() error {
+	lazy := stream.lazy
+	if lazy == nil {
+		return nil
+	}
+	lazy.mu.Lock()
+	defer lazy.mu.Unlock()
+	if lazy.loaded {
+		return nil
+	}
+	if stream.Stream == nil {
+		ra := stream.PdfObjectReference.parser.rs.(io.ReaderAt)
+		data := make([]byte, lazy.length)
+		if n, err := ra.ReadAt(data, lazy.offset); int64(n) < lazy.length {
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			common.Log.Debug("ERROR: Failed to load stream %d data: %v", stream.ObjectNumber, err)
+			return err
+		}
+		stream.Stream = data
+	}
+	lazy.loaded = true
+	return nil
+}
+
+// IsDataLoaded returns false if the data of the stream has not been read from the file yet,
+// nor assigned to its Stream field.
+func (stream *PdfObjectStream) IsDataLoaded() bool {
+	lazy := stream.lazy
+	if lazy == nil {
+		return true
+	}
+	lazy.mu.Lock()
+	defer lazy.mu.Unlock()
+	return lazy.loaded || stream.Stream != nil
+}
+
+// copyLazyData returns the lazy-loading information of a copy of `stream`, whose Stream field is
+// set to a copy of the data of `stream`. Returns nil if the data of `stream` is loaded already.
+func (stream *PdfObjectStream) copyLazyData() *lazyStreamData {
+	lazy := stream.lazy
+	if lazy == nil {
+		return nil
+	}
+	lazy.mu.Lock()
+	defer lazy.mu.Unlock()
+	if lazy.loaded {
+		return nil
+	}
+	return &lazyStreamData{offset: lazy.offset, length: lazy.length}
+}
+
+// canLoadLazily returns true if the stream data can be loaded on first use, which requires
+// reading the data without moving the offset of the parser.
+func (parser *PdfParser) canLoadLazily() bool {
+	if !parser.lazy || parser.crypter != nil {
+		return false
+	}
+	_, ok := parser.rs.(io.ReaderAt)
+	return ok
+}
+
+// cacheObjectStream caches the decoded object stream `objstm`. In lazy-loading mode, the least
+// recently loaded object streams are evicted from the cache to bound its size. The objects parsed
+// from the evicted streams stay in the object cache.
+func (parser *PdfParser) cacheObjectStream(sobjNumber int, objstm objectStream) {
+	parser.objstms[sobjNumber] = objstm
+	if !parser.lazy {
+		return
+	}
+	parser.objstmsOrder = append(parser.objstmsOrder, sobjNumber)
+	for len(parser.objstmsOrder) > lazyObjectStreamsCacheSize {
+		delete(parser.objstms, parser.objstmsOrder[0])
+		parser.objstmsOrder = parser.objstmsOrder[1:]
+	}
+}
@@ -0,0 +1,164 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package core
+
+import (
+	"bytes"
+	"io"
+	"math"
+
+	"github.com/unidoc/unipdf/v3/common"
+)
+
+// Default limits of ParserConfig.
+const (
+	DefaultMaxNestingDepth      = 1000
+	DefaultMaxXrefSections      = 1000
+	DefaultMaxReferenceDepth    = 1000
+	DefaultMaxDecodedStreamSize = 1 << 30 // 1 GiB.
+	DefaultMaxImagePixels       = 1 << 28 // e.g. 16384 x 16384.
+)
+
+// ParserConfig defines the limits enforced by the parser, and by the decoders of the streams it
+// parses, so that malicious or broken files cannot exhaust the stack or the memory, or cause
+// infinite loops. Exceeding a limit results in an ErrLimitExceeded error.
+// Zero fields are set to the default limits, which are generous but finite.
+type ParserConfig struct {
+	// MaxNestingDepth is the maximum nesting depth of arrays and dictionaries.
+	MaxNestingDepth int
+
+	// MaxXrefSections is the maximum number of cross-reference sections chained with Prev entries,
+	// e.g. by incremental updates.
+	MaxXrefSections int
+
+	// MaxReferenceDepth is the maximum depth of the chains of references resolved recursively,
+	// e.g. by ResolveReferencesDeep or when walking the page tree.
+	MaxReferenceDepth int
+
+	// MaxDecodedStreamSize is the maximum size in bytes of the data of decoded streams.
+	MaxDecodedStreamSize int64
+
+	// MaxImagePixels is the maximum number of pixels (width x height) of decoded images.
+	MaxImagePixels int64
+
+	// Logger receives the logs of the parser, e.g. the syntax errors recovered from and the
+	// repairs of the cross-reference information. The global common.Log is used if nil.
+	Logger common.StructuredLogger
+}
+
+// DefaultParserConfig returns the configuration with the default limits.
+func DefaultParserConfig() ParserConfig {
+	return ParserConfig{
+		MaxNestingDepth:      DefaultMaxNestingDepth,
+		MaxXrefSections:      DefaultMaxXrefSections,
+		MaxReferenceDepth:    DefaultMaxReferenceDepth,
+		MaxDecodedStreamSize: DefaultMaxDecodedStreamSize,
+		MaxImagePixels:       DefaultMaxImagePixels,
+	}
+}
+
+// withDefaults returns `cfg` with the zero fields set to the default limits.
+func (cfg ParserConfig) withDefaults() ParserConfig {
+	def := DefaultParserConfig()
+	if cfg.MaxNestingDepth <= 0 {
+		cfg.MaxNestingDepth = def.MaxNestingDepth
+	}
+	if cfg.MaxXrefSections <= 0 {
+		cfg.MaxXrefSections = def.MaxXrefSections
+	}
+	if cfg.MaxReferenceDepth <= 0 {
+		cfg.MaxReferenceDepth = def.MaxReferenceDepth
+	}
+	if cfg.MaxDecodedStreamSize <= 0 {
+		cfg.MaxDecodedStreamSize = def.MaxDecodedStreamSize
+	}
+	if cfg.MaxImagePixels <= 0 {
+		cfg.MaxImagePixels = def.MaxImagePixels
+	}
+	return cfg
+}
+
+// GetConfig returns the limits enforced by the parser. The default limits are returned for nil
+// parsers, so that the limits of objects not parsed from a file can be obtained with
+// obj.GetParser().GetConfig().
+func (parser *PdfParser) GetConfig() ParserConfig {
+	if parser == nil {
+		return DefaultParserConfig()
+	}
+	return parser.config.withDefaults()
+}
+
+// log returns the logger of the parser, see ParserConfig.Logger.
+func (parser *PdfParser) log() common.StructuredLogger {
+	if parser == nil {
+		return common.StructuredOrGlobal(nil)
+	}
+	return common.StructuredOrGlobal(parser.config.Logger)
+}
+
+// enterNested increments the nesting depth of the object being parsed, returning an error if
+// it exceeds the maximum. Each call must be followed by a call to leaveNested.
+func (parser *PdfParser) enterNested() error {
+	parser.nestingDepth++
+	if max := parser.GetConfig().MaxNestingDepth; parser.nestingDepth > max {
+		return ErrLimitExceeded{Limit: "MaxNestingDepth", Max: int64(max)}
+	}
+	return nil
+}
+
+// leaveNested decrements the nesting depth of the object being parsed.
+func (parser *PdfParser) leaveNested() {
+	parser.nestingDepth--
+}
+
+// streamConfig returns the limits of the parser of `streamObj`.
+func streamConfig(streamObj *PdfObjectStream) ParserConfig {
+	if streamObj == nil {
+		return DefaultParserConfig()
+	}
+	return streamObj.GetParser().GetConfig()
+}
+
+// readAllLimited reads `r` until EOF, returning ErrLimitExceeded if more than `max` bytes are read
+// (DefaultMaxDecodedStreamSize if 0). Other read errors are returned with the data read so far.
+func readAllLimited(r io.Reader, max int64) ([]byte, error) {
+	if max <= 0 {
+		max = DefaultMaxDecodedStreamSize
+	}
+	var buf bytes.Buffer
+	_, err := buf.ReadFrom(io.LimitReader(r, max+1))
+	if int64(buf.Len()) > max {
+		return nil, ErrLimitExceeded{Limit: "MaxDecodedStreamSize", Max: max}
+	}
+	return buf.Bytes(), err
+}
+
+// checkDecodedSize returns ErrLimitExceeded if `size` bytes exceed `max`
+// (DefaultMaxDecodedStreamSize if 0).
+func checkDecodedSize(size int, max int64) error {
+	if max <= 0 {
+		max = DefaultMaxDecodedStreamSize
+	}
+	if int64(size) > max {
+		return ErrLimitExceeded{Limit: "MaxDecodedStreamSize", Max: max}
+	}
+	return nil
+}
+
+// CheckImagePixels returns ErrLimitExceeded if an image of `width` x `height` pixels exceeds the
+// maximum number of pixels `max` (DefaultMaxImagePixels if 0).
+func CheckImagePixels(width, height, max int64) error {
+	if max <= 0 {
+		max = DefaultMaxImagePixels
+	}
+	if width <= 0 || height <= 0 {
+		return nil
+	}
+	if width > math.MaxInt64/height || width*height > max {
+		return ErrLimitExceeded{Limit: "MaxImagePixels", Max: max}
+	}
+	return nil
+}
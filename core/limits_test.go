@@ -0,0 +1,170 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package core
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// makeXrefChainPdf returns a minimal document with two cross-reference sections, the Prev entry
+// of the last one pointing to the first one, and the Prev entry of the first one set to `prev`
+// (-1 for the offset of the last section).
+func makeXrefChainPdf(prev int) string {
+	body := "%PDF-1.4\n" +
+		"1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+		"2 0 obj\n<< /Type /Pages /Kids [] /Count 0 >>\nendobj\n"
+	xref1 := len(body)
+	body += fmt.Sprintf("xref\n0 3\n0000000000 65535 f\r\n%010d 00000 n\r\n%010d 00000 n\r\n",
+		strings.Index(body, "1 0 obj"), strings.Index(body, "2 0 obj"))
+	trailer1 := "trailer\n<< /Size 3 /Root 1 0 R /Prev %d >>\n"
+	xref2 := len(body) + len(fmt.Sprintf(trailer1, 1000000000))
+	if prev < 0 {
+		prev = xref2
+	}
+	// Pad the Prev offset so that the length of the first trailer does not depend on it.
+	body += fmt.Sprintf("trailer\n<< /Size 3 /Root 1 0 R /Prev %010d >>\n", prev)
+	body += fmt.Sprintf("xref\n0 1\n0000000000 65535 f\r\ntrailer\n<< /Size 3 /Root 1 0 R /Prev %d >>\n", xref1)
+	return body + fmt.Sprintf("startxref\n%d\n%%%%EOF\n", xref2)
+}
+
+func TestParserNestingLimit(t *testing.T) {
+	// Minimized crashers: deeply nested arrays and dictionaries exhausting the stack.
+	crashers := []string{
+		strings.Repeat("[", 100000),
+		strings.Repeat("<</A ", 100000),
+		"1 0 obj\n" + strings.Repeat("[<</A ", 50000),
+	}
+	for _, txt := range crashers {
+		parser := makeParserForText(txt)
+		var err error
+		if strings.HasPrefix(txt, "1 0 obj") {
+			_, err = parser.ParseIndirectObject()
+		} else {
+			_, err = parser.parseObject()
+		}
+		var limitErr ErrLimitExceeded
+		require.True(t, errors.As(err, &limitErr), "%v", err)
+		require.Equal(t, "MaxNestingDepth", limitErr.Limit)
+		require.Equal(t, int64(DefaultMaxNestingDepth), limitErr.Max)
+	}
+
+	// Custom limit.
+	parser := makeParserForText(strings.Repeat("[", 5) + strings.Repeat("]", 5))
+	parser.config.MaxNestingDepth = 5
+	_, err := parser.parseObject()
+	require.NoError(t, err)
+
+	parser = makeParserForText(strings.Repeat("[", 6) + strings.Repeat("]", 6))
+	parser.config.MaxNestingDepth = 5
+	_, err = parser.parseObject()
+	require.Equal(t, ErrLimitExceeded{Limit: "MaxNestingDepth", Max: 5}, err)
+}
+
+func TestParserXrefChainLimit(t *testing.T) {
+	// Circular Prev chains are followed once.
+	for _, prev := range []int{-1, 0} {
+		txt := makeXrefChainPdf(prev)
+		if prev == 0 {
+			// The first section pointing to itself.
+			prev = strings.Index(txt, "xref\n0 3")
+			txt = makeXrefChainPdf(prev)
+		}
+		parser, err := NewParser(strings.NewReader(txt))
+		require.NoError(t, err)
+		require.Len(t, parser.xrefs.ObjectMap, 2)
+		require.Empty(t, parser.GetRecoveries())
+	}
+
+	// Too many sections, the xrefs are rebuilt instead.
+	txt := makeXrefChainPdf(-1)
+	parser, err := NewParserWithConfig(strings.NewReader(txt), ParserConfig{MaxXrefSections: 1})
+	require.NoError(t, err)
+	require.Equal(t, 1, parser.GetConfig().MaxXrefSections)
+	_, err = parser.loadXrefs()
+	require.Equal(t, ErrLimitExceeded{Limit: "MaxXrefSections", Max: 1}, err)
+
+	// Minimized crasher: xref stream with a huge Size.
+	parser = makeParserForText("1 0 obj\n<< /Type /XRef /Size 2000000000 /W [1 2 1] /Length 0 >>\nstream\n\nendstream\nendobj\n")
+	_, err = parser.parseXrefStream(nil)
+	require.Error(t, err)
+}
+
+func TestDecodedStreamLimit(t *testing.T) {
+	// Minimized crasher: small Flate stream decoding to a lot of data.
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(make([]byte, 1<<20))
+	w.Close()
+
+	parser := &PdfParser{config: ParserConfig{MaxDecodedStreamSize: 1 << 16}}
+	stream := &PdfObjectStream{PdfObjectDictionary: MakeDict(), Stream: buf.Bytes()}
+	stream.PdfObjectReference.parser = parser
+	stream.Set("Filter", MakeName("FlateDecode"))
+	_, err := DecodeStream(stream)
+	require.Equal(t, ErrLimitExceeded{Limit: "MaxDecodedStreamSize", Max: 1 << 16}, err)
+
+	parser.config.MaxDecodedStreamSize = 1 << 20
+	decoded, err := DecodeStream(stream)
+	require.NoError(t, err)
+	require.Len(t, decoded, 1<<20)
+
+	// Run length: each pair of bytes decodes to 128 bytes.
+	parser.config.MaxDecodedStreamSize = 1000
+	stream = &PdfObjectStream{PdfObjectDictionary: MakeDict(), Stream: bytes.Repeat([]byte{129, 0}, 100)}
+	stream.PdfObjectReference.parser = parser
+	stream.Set("Filter", MakeName("RunLengthDecode"))
+	_, err = DecodeStream(stream)
+	require.Equal(t, ErrLimitExceeded{Limit: "MaxDecodedStreamSize", Max: 1000}, err)
+}
+
+func TestImagePixelsLimit(t *testing.T) {
+	require.NoError(t, CheckImagePixels(16384, 16384, 0))
+	require.NoError(t, CheckImagePixels(0, 1<<62, 0))
+	require.Equal(t, ErrLimitExceeded{Limit: "MaxImagePixels", Max: DefaultMaxImagePixels},
+		CheckImagePixels(16385, 16384, 0))
+	require.Equal(t, ErrLimitExceeded{Limit: "MaxImagePixels", Max: 100},
+		CheckImagePixels(1<<62, 1<<62, 100))
+
+	// Minimized crasher: CCITT image with absurd dimensions.
+	parser := &PdfParser{config: ParserConfig{MaxImagePixels: 1000}}
+	stream := &PdfObjectStream{PdfObjectDictionary: MakeDict(), Stream: []byte{0}}
+	stream.PdfObjectReference.parser = parser
+	stream.Set("Filter", MakeName("CCITTFaxDecode"))
+	decodeParms := MakeDict()
+	decodeParms.Set("Columns", MakeInteger(1<<30))
+	decodeParms.Set("Rows", MakeInteger(1<<30))
+	stream.Set("DecodeParms", decodeParms)
+	_, err := DecodeStream(stream)
+	require.Equal(t, ErrLimitExceeded{Limit: "MaxImagePixels", Max: 1000}, err)
+}
+
+func TestReferenceDepthLimit(t *testing.T) {
+	// Chain of indirect objects, each referring to the next one, starting with the catalog. The
+	// cross-reference table is rebuilt.
+	const n = 50
+	var sb strings.Builder
+	sb.WriteString("1 0 obj\n<< /Type /Catalog /Pages 1 0 R /Next 2 0 R >>\nendobj\n")
+	for i := 2; i <= n; i++ {
+		fmt.Fprintf(&sb, "%d 0 obj\n<< /Next %d 0 R >>\nendobj\n", i, i+1)
+	}
+	parser, err := NewParserWithConfig(strings.NewReader("%PDF-1.4\n"+sb.String()+
+		"trailer\n<< /Root 1 0 R >>\n%%EOF\n"), ParserConfig{MaxReferenceDepth: 10})
+	require.NoError(t, err)
+	obj, err := parser.LookupByNumber(1)
+	require.NoError(t, err)
+	err = ResolveReferencesDeep(obj, nil)
+	require.Equal(t, ErrLimitExceeded{Limit: "MaxReferenceDepth", Max: 10}, err)
+
+	parser.config.MaxReferenceDepth = 1000
+	require.NoError(t, ResolveReferencesDeep(obj, nil))
+}
@@ -8,6 +8,8 @@ package core
 import (
 	"bufio"
 	"bytes"
+	"crypto"
+	"crypto/x509"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -17,6 +19,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core/security"
@@ -31,13 +34,24 @@ var reNumeric = regexp.MustCompile(`^[\+-.]*([0-9.]+)`)
 var reExponential = regexp.MustCompile(`^[\+-.]*([0-9.]+)[eE][\+-.]*([0-9.]+)`)
 var reReference = regexp.MustCompile(`^\s*[-]*(\d+)\s+(\d+)\s+R`)
 var reIndirectObject = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj`)
+var reIndirectObjectStart = regexp.MustCompile(`^\d+\s+\d+\s+obj`)
 var reXrefSubsection = regexp.MustCompile(`(\d+)\s+(\d+)\s*$`)
-var reXrefEntry = regexp.MustCompile(`(\d+)\s+(\d+)\s+([nf])\s*$`)
+var reXrefEntry = regexp.MustCompile(`(\d+)\s+(\d+)\s*([nfNF])\s*$`)
+var reXrefEntryStrict = regexp.MustCompile(`^\d{10} \d{5} [nf] ?$`)
 
 // PdfParser parses a PDF file and provides access to the object structure of the PDF.
+//
+// Once the parser is created and the document decrypted if needed, objects can be looked up
+// concurrently from multiple goroutines with LookupByNumber, LookupByReference, Resolve and the
+// Resolve method of the references: the lookups, which seek the underlying reader, are serialized
+// and the caches protected. The other methods, as well as the ObjCache field, must not be used
+// concurrently with lookups.
 type PdfParser struct {
 	version Version
 
+	// Guards the reader, the caches and the cross-reference information while looking up objects.
+	mu sync.Mutex
+
 	rs               io.ReadSeeker
 	reader           *bufio.Reader
 	fileSize         int64
@@ -48,6 +62,18 @@ type PdfParser struct {
 	trailer          *PdfObjectDictionary
 	crypter          *PdfCrypt
 	repairsAttempted bool // Avoid multiple attempts for repair.
+	repaired         bool // Whether the cross-reference information was repaired.
+	lazy             bool // Lazy-loading mode, see SetLazyLoading.
+	objstmsOrder     []int
+
+	// Number of syntax errors recovered from, by kind (see GetRecoveries).
+	recoveries map[RecoveryType]int
+
+	// Called when objects are loaded, see SetObjectLoadedCallback.
+	onObjectLoaded func(numLoaded int)
+
+	config       ParserConfig // Limits, see GetConfig.
+	nestingDepth int          // Nesting depth of the arrays and dictionaries being parsed.
 
 	ObjCache objectCache
 
@@ -84,6 +110,14 @@ func (parser *PdfParser) IsAuthenticated() bool {
 	return parser.crypter.authenticated
 }
 
+// WasRepaired returns true if the cross-reference information of the file was
+// broken and has been repaired, e.g. rebuilt by scanning the file for objects.
+func (parser *PdfParser) WasRepaired() bool {
+	parser.mu.Lock()
+	defer parser.mu.Unlock()
+	return parser.repaired
+}
+
 // GetTrailer returns the PDFs trailer dictionary. The trailer dictionary is typically the starting point for a PDF,
 // referencing other key objects that are important in the document structure.
 func (parser *PdfParser) GetTrailer() *PdfObjectDictionary {
@@ -237,13 +271,16 @@ func (parser *PdfParser) parseName() (PdfObjectName, error) {
 				break // Looks like start of next statement.
 			} else if bb[0] == '#' {
 				hexcode, err := parser.reader.Peek(3)
-				if err != nil {
+				if err != nil && err != io.EOF {
 					return PdfObjectName(r.String()), err
 				}
 
-				code, err := hex.DecodeString(string(hexcode[1:3]))
+				var code []byte
+				if err == nil {
+					code, err = hex.DecodeString(string(hexcode[1:3]))
+				}
 				if err != nil {
-					common.Log.Debug("ERROR: Invalid hex following '#', continuing using literal - Output may be incorrect")
+					parser.recover(RecoveryInvalidNameEscape, "name", r.String())
 
 					// Treat as literal '#' rather than hex code.
 					r.WriteByte('#')
@@ -286,7 +323,11 @@ func (parser *PdfParser) parseName() (PdfObjectName, error) {
 // we will support it in the reader (no confusion with other types, so
 // no compromise).
 func (parser *PdfParser) parseNumber() (PdfObject, error) {
-	return ParseNumber(parser.reader)
+	num, raw, err := parseNumber(parser.reader)
+	if err == nil && raw != "" {
+		parser.recover(RecoveryMalformedNumber, "number", raw, "normalized", num)
+	}
+	return num, err
 }
 
 // A string starts with '(' and ends with ')'.
@@ -352,8 +393,26 @@ func (parser *PdfParser) parseString() (*PdfObjectString, error) {
 				r.WriteRune(')')
 			case '\\':
 				r.WriteRune('\\')
+			case '\r':
+				// Line continuation: the end-of-line marker is not part of the string.
+				if bb, _ := parser.reader.Peek(1); len(bb) == 1 && bb[0] == '\n' {
+					parser.reader.ReadByte()
+				}
+			case '\n':
+				// Line continuation.
+			default:
+				// The backslash is ignored for other characters.
+				r.WriteByte(b)
 			}
 
+			continue
+		} else if bb[0] == '\r' {
+			// End-of-line markers are read as line feeds.
+			parser.reader.ReadByte()
+			if bb, _ := parser.reader.Peek(1); len(bb) == 1 && bb[0] == '\n' {
+				parser.reader.ReadByte()
+			}
+			r.WriteByte('\n')
 			continue
 		} else if bb[0] == '(' {
 			count++
@@ -406,6 +465,10 @@ func (parser *PdfParser) parseHexString() (*PdfObjectString, error) {
 // Starts with '[' ends with ']'.  Can contain any kinds of direct objects.
 func (parser *PdfParser) parseArray() (*PdfObjectArray, error) {
 	arr := MakeArray()
+	if err := parser.enterNested(); err != nil {
+		return arr, err
+	}
+	defer parser.leaveNested()
 
 	parser.reader.ReadByte()
 
@@ -580,6 +643,10 @@ func (parser *PdfParser) ParseDict() (*PdfObjectDictionary, error) {
 
 	dict := MakeDict()
 	dict.parser = parser
+	if err := parser.enterNested(); err != nil {
+		return nil, err
+	}
+	defer parser.leaveNested()
 
 	// Pass the '<<'
 	c, _ := parser.reader.ReadByte()
@@ -607,6 +674,11 @@ func (parser *PdfParser) ParseDict() (*PdfObjectDictionary, error) {
 			parser.reader.ReadByte()
 			break
 		}
+		if bb[0] != '/' && parser.isObjectBoundary() {
+			// Resume parsing at the end of the object.
+			parser.recover(RecoveryMalformedObject)
+			break
+		}
 		common.Log.Trace("Parse the name!")
 
 		keyName, err := parser.parseName()
@@ -648,6 +720,20 @@ func (parser *PdfParser) ParseDict() (*PdfObjectDictionary, error) {
 	return dict, nil
 }
 
+// hasKeyword returns true if the reader is at the keyword `keyword`.
+func (parser *PdfParser) hasKeyword(keyword string) bool {
+	bb, _ := parser.reader.Peek(len(keyword))
+	return string(bb) == keyword
+}
+
+// isObjectBoundary returns true if the reader is at the end of an indirect
+// object, i.e. at the endobj or stream keyword, or at the header of the next
+// object.
+func (parser *PdfParser) isObjectBoundary() bool {
+	bb, _ := parser.reader.Peek(20)
+	return parser.hasKeyword("endobj") || parser.hasKeyword("stream") || reIndirectObjectStart.Match(bb)
+}
+
 // Parse the pdf version from the beginning of the file.
 // Returns the major and minor parts of the version.
 // E.g. for "PDF-1.7" would return 1 and 7.
@@ -665,7 +751,7 @@ func (parser *PdfParser) parsePdfVersion() (int, int, error) {
 
 	if match := rePdfVersion.FindStringSubmatch(string(b)); len(match) < 3 {
 		if major, minor, err = parser.seekPdfVersionTopDown(); err != nil {
-			common.Log.Debug("Failed recovery - unable to find version")
+			parser.log().Debug("unable to find pdf version", "error", err)
 			return 0, 0, err
 		}
 
@@ -748,6 +834,16 @@ func (parser *PdfParser) parseXrefTable() (*PdfObjectDictionary, error) {
 				return nil, errors.New("xref invalid format")
 			}
 
+			// Entries are 20 bytes long, including the end-of-line marker.
+			eol, _ := parser.reader.Peek(2)
+			lineLen := len(txt) + 1
+			if string(eol) == "\r\n" {
+				lineLen++
+			}
+			if lineLen != 20 || !reXrefEntryStrict.MatchString(txt) {
+				parser.recover(RecoveryMalformedXrefEntry, "object", curObjNum, "entry", txt)
+			}
+
 			first, _ := strconv.ParseInt(result2[1], 10, 64)
 			gen, _ := strconv.Atoi(result2[2])
 			third := result2[3]
@@ -853,7 +949,7 @@ func (parser *PdfParser) parseXrefStream(xstm *PdfObjectInteger) (*PdfObjectDict
 	// Sanity check to avoid DoS attacks. Maximum number of indirect objects on 32 bit system.
 	if int64(*sizeObj) > 8388607 {
 		common.Log.Debug("ERROR: xref Size exceeded limit, over 8388607 (%d)", *sizeObj)
-		return nil, errors.New("range check error")
+		return nil, ErrRangeError
 	}
 
 	wObj := xs.PdfObjectDictionary.Get("W")
@@ -875,6 +971,11 @@ func (parser *PdfParser) parseXrefStream(xstm *PdfObjectInteger) (*PdfObjectDict
 			return nil, errors.New("invalid w object type")
 		}
 
+		// The fields are read into 64-bit integers, allowing offsets of large files.
+		if *wVal < 0 || *wVal > 8 {
+			common.Log.Debug("ERROR: Unsupported xref stm field width (%d)", *wVal)
+			return nil, ErrRangeError
+		}
 		b = append(b, int64(*wVal))
 	}
 
@@ -891,7 +992,7 @@ func (parser *PdfParser) parseXrefStream(xstm *PdfObjectInteger) (*PdfObjectDict
 
 	if s0 < 0 || s1 < 0 || s2 < 0 {
 		common.Log.Debug("Error s value < 0 (%d,%d,%d)", s0, s1, s2)
-		return nil, errors.New("range check error")
+		return nil, ErrRangeError
 	}
 	if deltab == 0 {
 		common.Log.Debug("No xref objects in stream (deltab == 0)")
@@ -926,7 +1027,7 @@ func (parser *PdfParser) parseXrefStream(xstm *PdfObjectInteger) (*PdfObjectDict
 		// Expect indLen to be a multiple of 2.
 		if indicesArray.Len()%2 != 0 {
 			common.Log.Debug("WARNING Failure loading xref stm index not multiple of 2.")
-			return nil, errors.New("range check error")
+			return nil, ErrRangeError
 		}
 
 		objCount = 0
@@ -942,6 +1043,10 @@ func (parser *PdfParser) parseXrefStream(xstm *PdfObjectInteger) (*PdfObjectDict
 
 			startIdx := indices[i]
 			numObjs := indices[i+1]
+			if numObjs > entries+1-objCount {
+				common.Log.Debug("ERROR: xref stm: Index covering more objects than entries (%d)", entries)
+				return nil, errors.New("xref stm num entries != len(indices)")
+			}
 			for j := 0; j < numObjs; j++ {
 				indexList = append(indexList, startIdx+j)
 			}
@@ -949,6 +1054,10 @@ func (parser *PdfParser) parseXrefStream(xstm *PdfObjectInteger) (*PdfObjectDict
 		}
 	} else {
 		// If no Index, then assume [0 Size]
+		if int64(*sizeObj) > int64(entries+1) {
+			common.Log.Debug("ERROR: xref stm: Size larger than entries (%d > %d)", *sizeObj, entries)
+			return nil, errors.New("xref stm num entries != len(indices)")
+		}
 		for i := 0; i < int(*sizeObj); i++ {
 			indexList = append(indexList, i)
 		}
@@ -977,14 +1086,6 @@ func (parser *PdfParser) parseXrefStream(xstm *PdfObjectInteger) (*PdfObjectDict
 	common.Log.Trace("Objects count %d", objCount)
 	common.Log.Trace("Indices: % d", indexList)
 
-	// Convert byte array to a larger integer, little-endian.
-	convertBytes := func(v []byte) int64 {
-		var tmp int64
-		for i := 0; i < len(v); i++ {
-			tmp += int64(v[i]) * (1 << uint(8*(len(v)-i-1)))
-		}
-		return tmp
-	}
 
 	common.Log.Trace("Decoded stream length: %d", len(ds))
 	objIndex := 0
@@ -1010,9 +1111,9 @@ func (parser *PdfParser) parseXrefStream(xstm *PdfObjectInteger) (*PdfObjectDict
 		}
 		p3 := ds[i+s1 : i+s2]
 
-		ftype := convertBytes(p1)
-		n2 := convertBytes(p2)
-		n3 := convertBytes(p3)
+		ftype := decodeXrefStreamField(p1)
+		n2 := decodeXrefStreamField(p2)
+		n3 := decodeXrefStreamField(p3)
 
 		if b[0] == 0 {
 			// If first entry in W is 0, then default to to type 1.
@@ -1103,6 +1204,10 @@ func (parser *PdfParser) parseXref() (*PdfObjectDictionary, error) {
 		}
 		if reXrefTable.Match(bb) {
 			common.Log.Trace("Standard xref section table!")
+			if i > 0 {
+				// The xref offset is off by a few bytes.
+				parser.repaired = true
+			}
 			return parser.parseXrefTable()
 		}
 
@@ -1120,14 +1225,19 @@ func (parser *PdfParser) parseXref() (*PdfObjectDictionary, error) {
 		bb = append(lbb, bb...)
 	}
 
-	common.Log.Debug("Warning: Unable to find xref table or stream. Repair attempted: Looking for earliest xref from bottom.")
+	parser.log().Debug("xref table or stream not found, looking for the earliest xref from the bottom",
+		"offset", parser.GetFileOffset())
 	if err := parser.repairSeekXrefMarker(); err != nil {
-		common.Log.Debug("Repair failed - %v", err)
+		parser.log().Debug("xref repair failed", "error", err)
 		return nil, err
 	}
 	return parser.parseXrefTable()
 }
 
+// maxEOFMarkerLookBehind is the maximum number of bytes from the end of the file searched for the
+// EOF marker, so that files with a missing marker are not read entirely.
+const maxEOFMarkerLookBehind = 1024 * 1024
+
 // Look for EOF marker and seek to its beginning.
 // Define an offset position from the end of the file.
 func (parser *PdfParser) seekToEOFMarker(fSize int64) error {
@@ -1137,7 +1247,7 @@ func (parser *PdfParser) seekToEOFMarker(fSize int64) error {
 	// Define an buffer length in terms of how many bytes to read from the end of the file.
 	var buflen int64 = 2048
 
-	for offset < fSize-4 {
+	for offset < fSize-4 && offset < maxEOFMarkerLookBehind {
 		if fSize <= (buflen + offset) {
 			buflen = fSize - offset
 		}
@@ -1182,7 +1292,7 @@ func (parser *PdfParser) seekToEOFMarker(fSize int64) error {
 // Multiple xref table handling:
 // 1. Check main xref table (primary)
 // 2. Check the Xref stream object (PDF >=1.5)
-// 3. Check the Prev xref
+// 3. Check the Prev xref, and its Xref stream object if any
 // 4. Continue looking for Prev until not found.
 //
 // The earlier xrefs have higher precedence.  If objects already
@@ -1244,11 +1354,10 @@ func (parser *PdfParser) loadXrefs() (*PdfObjectDictionary, error) {
 	common.Log.Trace("startxref at %d", offsetXref)
 
 	if offsetXref > fSize {
-		common.Log.Debug("ERROR: Xref offset outside of file")
-		common.Log.Debug("Attempting repair")
+		parser.log().Debug("xref offset outside of file, attempting repair", "offset", offsetXref, "size", fSize)
 		offsetXref, err = parser.repairLocateXref()
 		if err != nil {
-			common.Log.Debug("ERROR: Repair attempt failed (%s)")
+			parser.log().Debug("xref repair failed", "error", err)
 			return nil, err
 		}
 	}
@@ -1262,32 +1371,17 @@ func (parser *PdfParser) loadXrefs() (*PdfObjectDictionary, error) {
 	}
 
 	// Check the XrefStm object also from the trailer.
-	xx := trailerDict.Get("XRefStm")
-	if xx != nil {
-		xo, ok := xx.(*PdfObjectInteger)
-		if !ok {
-			return nil, errors.New("XRefStm != int")
-		}
-		_, err = parser.parseXrefStream(xo)
-		if err != nil {
-			return nil, err
-		}
+	if err = parser.loadHybridXrefStream(trailerDict); err != nil {
+		return nil, err
 	}
 
 	// Load old objects also.  Only if not already specified.
-	var prevList []int64
-	intInSlice := func(val int64, list []int64) bool {
-		for _, b := range list {
-			if b == val {
-				return true
-			}
-		}
-		return false
-	}
+	visited := map[int64]struct{}{offsetXref: {}}
+	maxSections := parser.GetConfig().MaxXrefSections
 
 	// Load any Previous xref tables (old versions), which can
 	// refer to objects also.
-	xx = trailerDict.Get("Prev")
+	xx := trailerDict.Get("Prev")
 	for xx != nil {
 		prevInt, ok := xx.(*PdfObjectInteger)
 		if !ok {
@@ -1297,11 +1391,21 @@ func (parser *PdfParser) loadXrefs() (*PdfObjectDictionary, error) {
 			return trailerDict, nil
 		}
 
-		off := *prevInt
+		off := int64(*prevInt)
+		if _, ok := visited[off]; ok {
+			// Prevent circular reference!
+			common.Log.Debug("Preventing circular xref referencing")
+			break
+		}
+		visited[off] = struct{}{}
+		if len(visited) > maxSections {
+			common.Log.Debug("ERROR: Too many xref sections (> %d)", maxSections)
+			return nil, ErrLimitExceeded{Limit: "MaxXrefSections", Max: int64(maxSections)}
+		}
 		common.Log.Trace("Another Prev xref table object at %d", off)
 
 		// Can be either regular table, or an xref object...
-		parser.rs.Seek(int64(off), os.SEEK_SET)
+		parser.rs.Seek(off, os.SEEK_SET)
 		parser.reader = bufio.NewReader(parser.rs)
 
 		ptrailerDict, err := parser.parseXref()
@@ -1310,22 +1414,49 @@ func (parser *PdfParser) loadXrefs() (*PdfObjectDictionary, error) {
 			common.Log.Debug("Attempting to continue by ignoring it")
 			break
 		}
+		// Sections of hybrid-reference files can follow sections with cross-reference streams
+		// and vice versa.
+		if err = parser.loadHybridXrefStream(ptrailerDict); err != nil {
+			common.Log.Debug("Warning: Error - Failed loading the XRefStm of a Prev section: %v", err)
+			common.Log.Debug("Attempting to continue by ignoring it")
+			break
+		}
 
 		xx = ptrailerDict.Get("Prev")
-		if xx != nil {
-			prevoff := *(xx.(*PdfObjectInteger))
-			if intInSlice(int64(prevoff), prevList) {
-				// Prevent circular reference!
-				common.Log.Debug("Preventing circular xref referencing")
-				break
-			}
-			prevList = append(prevList, int64(prevoff))
-		}
 	}
 
 	return trailerDict, nil
 }
 
+// loadHybridXrefStream loads the cross-reference stream referred to by the XRefStm entry of the
+// trailer `trailerDict` of a hybrid-reference file section (see 7.5.8.4), if any. It must be
+// loaded after the cross-reference table of the section and before the previous sections: the
+// objects of the table take precedence, the entries of the stream being used for the objects not
+// in use in the table (typically marked as free for readers not supporting object streams).
+// The Prev entry of the stream is ignored, the Prev entry of the trailer being followed instead.
+func (parser *PdfParser) loadHybridXrefStream(trailerDict *PdfObjectDictionary) error {
+	xx := trailerDict.Get("XRefStm")
+	if xx == nil {
+		return nil
+	}
+	xo, ok := xx.(*PdfObjectInteger)
+	if !ok {
+		return errors.New("XRefStm != int")
+	}
+	_, err := parser.parseXrefStream(xo)
+	return err
+}
+
+// decodeXrefStreamField returns the value of the field `v` of a cross-reference stream entry,
+// a big-endian unsigned integer of up to 8 bytes.
+func decodeXrefStreamField(v []byte) int64 {
+	var val uint64
+	for _, b := range v {
+		val = val<<8 | uint64(b)
+	}
+	return int64(val)
+}
+
 // Return the closest object following offset from the xrefs table.
 func (parser *PdfParser) xrefNextObjectOffset(offset int64) int64 {
 	nextOffset := int64(0)
@@ -1385,7 +1516,7 @@ func (parser *PdfParser) traceStreamLength(lengthObj PdfObject) (PdfObject, erro
 		parser.streamLengthReferenceLookupInProgress[lengthRef.ObjectNumber] = true
 	}
 
-	slo, err := parser.Resolve(lengthObj)
+	slo, err := parser.resolve(lengthObj)
 	if err != nil {
 		return nil, err
 	}
@@ -1451,6 +1582,11 @@ func (parser *PdfParser) ParseIndirectObject() (PdfObject, error) {
 	for {
 		bb, err := parser.reader.Peek(2)
 		if err != nil {
+			if err == io.EOF && indirect.PdfObject != nil {
+				parser.recover(RecoveryMissingEndobj, "object", indirect.ObjectNumber,
+					"generation", indirect.GenerationNumber)
+				break
+			}
 			return &indirect, err
 		}
 		common.Log.Trace("Ind. peek: %s (% x)!", string(bb), string(bb))
@@ -1459,6 +1595,16 @@ func (parser *PdfParser) ParseIndirectObject() (PdfObject, error) {
 			parser.skipSpaces()
 		} else if bb[0] == '%' {
 			parser.skipComments()
+		} else if bb[0] == ']' {
+			// ']' not used as an array object ending marker, or array object
+			// terminated multiple times. Discarding the character.
+			common.Log.Debug("WARNING: ']' character not being used as an array ending marker. Skipping.")
+			parser.reader.Discard(1)
+		} else if indirect.PdfObject != nil && !parser.hasKeyword("endobj") && !parser.hasKeyword("stream") {
+			// Resume parsing at the end of the object, e.g. the header of the next object.
+			parser.recover(RecoveryMissingEndobj, "object", indirect.ObjectNumber,
+				"generation", indirect.GenerationNumber)
+			break
 		} else if (bb[0] == '<') && (bb[1] == '<') {
 			common.Log.Trace("Call ParseDict")
 			indirect.PdfObject, err = parser.ParseDict()
@@ -1473,11 +1619,6 @@ func (parser *PdfParser) ParseIndirectObject() (PdfObject, error) {
 				return &indirect, err
 			}
 			common.Log.Trace("Parsed object ... finished.")
-		} else if bb[0] == ']' {
-			// ']' not used as an array object ending marker, or array object
-			// terminated multiple times. Discarding the character.
-			common.Log.Debug("WARNING: ']' character not being used as an array ending marker. Skipping.")
-			parser.reader.Discard(1)
 		} else {
 			if bb[0] == 'e' {
 				lineStr, err := parser.readTextLine()
@@ -1517,62 +1658,67 @@ func (parser *PdfParser) ParseIndirectObject() (PdfObject, error) {
 					common.Log.Trace("Stream dict %s", dict)
 
 					// Special stream length tracing function used to avoid endless recursive looping.
+					var streamLength PdfObjectInteger = -1
 					slo, err := parser.traceStreamLength(dict.Get("Length"))
 					if err != nil {
 						common.Log.Debug("Fail to trace stream length: %v", err)
 						return nil, err
 					}
-					common.Log.Trace("Stream length? %s", slo)
-
-					pstreamLength, ok := slo.(*PdfObjectInteger)
-					if !ok {
-						return nil, errors.New("stream length needs to be an integer")
-					}
-					streamLength := *pstreamLength
-					if streamLength < 0 {
-						return nil, errors.New("stream needs to be longer than 0")
+					if slo != nil {
+						// The Length entry, if present, must be an integer.
+						pstreamLength, ok := slo.(*PdfObjectInteger)
+						if !ok {
+							return nil, errors.New("stream length needs to be an integer")
+						}
+						streamLength = *pstreamLength
 					}
+					common.Log.Trace("Stream length? %s", slo)
 
-					// Validate the stream length based on the cross references.
-					// Find next object with closest offset to current object and calculate
-					// the expected stream length based on that.
+					// Validate the stream length based on the position of the endstream
+					// keyword, which is searched for if it does not follow the data or if
+					// the data overlaps the next object in the cross references.
 					streamStartOffset := parser.GetFileOffset()
+					streamEndOffset := streamStartOffset + int64(streamLength)
 					nextObjectOffset := parser.xrefNextObjectOffset(streamStartOffset)
-					if streamStartOffset+int64(streamLength) > nextObjectOffset && nextObjectOffset > streamStartOffset {
-						common.Log.Debug("Expected ending at %d", streamStartOffset+int64(streamLength))
-						common.Log.Debug("Next object starting at %d", nextObjectOffset)
-						// endstream + "\n" endobj + "\n" (17)
-						newLength := nextObjectOffset - streamStartOffset - 17
-						if newLength < 0 {
-							return nil, errors.New("invalid stream length, going past boundaries")
+					if streamLength < 0 || streamEndOffset > parser.fileSize ||
+						(streamEndOffset > nextObjectOffset && nextObjectOffset > streamStartOffset) ||
+						!parser.hasEndstreamAt(streamEndOffset) {
+						newLength, found := parser.findStreamLength(streamStartOffset)
+						switch {
+						case found:
+							parser.recover(RecoveryStreamLength, "object", indirect.ObjectNumber,
+								"generation", indirect.GenerationNumber, "length", int64(streamLength), "corrected", newLength)
+							streamLength = PdfObjectInteger(newLength)
+							dict.Set("Length", MakeInteger(newLength))
+						case streamLength < 0:
+							return nil, errors.New("invalid stream length and stream end not found")
+						case streamEndOffset > parser.fileSize:
+							common.Log.Debug("ERROR: Stream length cannot be larger than file size")
+							return nil, errors.New("invalid stream length, larger than file size")
 						}
-
-						common.Log.Debug("Attempting a length correction to %d...", newLength)
-						streamLength = PdfObjectInteger(newLength)
-						dict.Set("Length", MakeInteger(newLength))
-					}
-
-					// Make sure is less than actual file size.
-					if int64(streamLength) > parser.fileSize {
-						common.Log.Debug("ERROR: Stream length cannot be larger than file size")
-						return nil, errors.New("invalid stream length, larger than file size")
-					}
-
-					stream := make([]byte, streamLength)
-					_, err = parser.ReadAtLeast(stream, int(streamLength))
-					if err != nil {
-						common.Log.Debug("ERROR stream (%d): %X", len(stream), stream)
-						common.Log.Debug("ERROR: %v", err)
-						return nil, err
 					}
 
 					streamobj := PdfObjectStream{}
-					streamobj.Stream = stream
 					streamobj.PdfObjectDictionary = indirect.PdfObject.(*PdfObjectDictionary)
 					streamobj.ObjectNumber = indirect.ObjectNumber
 					streamobj.GenerationNumber = indirect.GenerationNumber
 					streamobj.PdfObjectReference.parser = parser
 
+					if parser.canLoadLazily() {
+						// Keep the location of the data only, it is read on first use.
+						streamobj.lazy = &lazyStreamData{offset: streamStartOffset, length: int64(streamLength)}
+						parser.SetFileOffset(streamStartOffset + int64(streamLength))
+					} else {
+						stream := make([]byte, streamLength)
+						_, err = parser.ReadAtLeast(stream, int(streamLength))
+						if err != nil {
+							common.Log.Debug("ERROR stream (%d): %X", len(stream), stream)
+							common.Log.Debug("ERROR: %v", err)
+							return nil, err
+						}
+						streamobj.Stream = stream
+					}
+
 					parser.skipSpaces()
 					parser.reader.Discard(9) // endstream
 					parser.skipSpaces()
@@ -1612,13 +1758,28 @@ func NewParserFromString(txt string) *PdfParser {
 	return parser
 }
 
+// NewParserFromReaderAt creates a new parser for a PDF file of `size` bytes read via the ReaderAt
+// `r`. Only the byte ranges needed are read, which makes it suitable for files accessed with range
+// requests. Loads the cross reference stream and trailer. An error is returned on failure.
+func NewParserFromReaderAt(r io.ReaderAt, size int64) (*PdfParser, error) {
+	return NewParser(io.NewSectionReader(r, 0, size))
+}
+
 // NewParser creates a new parser for a PDF file via ReadSeeker. Loads the cross reference stream and trailer.
 // An error is returned on failure.
 func NewParser(rs io.ReadSeeker) (*PdfParser, error) {
+	return NewParserWithConfig(rs, DefaultParserConfig())
+}
+
+// NewParserWithConfig creates a new parser for a PDF file via ReadSeeker, enforcing the limits of
+// `cfg`, whose zero fields are set to the default limits. Loads the cross reference stream and
+// trailer. An error is returned on failure.
+func NewParserWithConfig(rs io.ReadSeeker, cfg ParserConfig) (*PdfParser, error) {
 	parser := &PdfParser{
 		rs:                                    rs,
 		ObjCache:                              make(objectCache),
 		streamLengthReferenceLookupInProgress: map[int64]bool{},
+		config:                                cfg.withDefaults(),
 	}
 
 	// Parse PDF version.
@@ -1630,27 +1791,51 @@ func NewParser(rs io.ReadSeeker) (*PdfParser, error) {
 	parser.version.Major = majorVersion
 	parser.version.Minor = minorVersion
 
-	// Start by reading the xrefs (from bottom).
-	if parser.trailer, err = parser.loadXrefs(); err != nil {
-		common.Log.Debug("ERROR: Failed to load xref table! %s", err)
-		return nil, err
+	// Start by reading the xrefs (from bottom). When missing or broken, they
+	// are rebuilt by scanning the file.
+	parser.trailer, err = parser.loadXrefs()
+	if err == nil && len(parser.xrefs.ObjectMap) == 0 {
+		err = errors.New("empty XREF table")
 	}
-	common.Log.Trace("Trailer: %s", parser.trailer)
-
-	if len(parser.xrefs.ObjectMap) == 0 {
-		return nil, fmt.Errorf("empty XREF table - Invalid")
+	if err == nil && (parser.trailer == nil || parser.trailer.Get("Root") == nil) {
+		err = errors.New("trailer missing Root")
 	}
+	if err != nil {
+		parser.log().Warn("unable to load the cross-reference table, attempting repair", "error", err)
+		if err := parser.repairXrefs(); err != nil {
+			parser.log().Error("cross-reference table repair failed", "error", err)
+			return nil, wrapError(ErrInvalidXref, err)
+		}
+	}
+	common.Log.Trace("Trailer: %s", parser.trailer)
 
 	return parser, nil
 }
 
+// SetObjectLoadedCallback sets the function called with the number of objects loaded each time an
+// object is loaded from the file, e.g. to report the progress of the loading of a document.
+// `fn` is called with the parser locked and must not use it.
+func (parser *PdfParser) SetObjectLoadedCallback(fn func(numLoaded int)) {
+	parser.onObjectLoaded = fn
+}
+
+// objectLoaded calls the callback set by SetObjectLoadedCallback, if any.
+func (parser *PdfParser) objectLoaded() {
+	if parser.onObjectLoaded != nil {
+		parser.onObjectLoaded(len(parser.ObjCache))
+	}
+}
+
 // Resolves a reference, returning the object and indicates whether or not it was cached.
 func (parser *PdfParser) resolveReference(ref *PdfObjectReference) (PdfObject, bool, error) {
+	parser.mu.Lock()
+	defer parser.mu.Unlock()
+
 	cachedObj, isCached := parser.ObjCache[int(ref.ObjectNumber)]
 	if isCached {
 		return cachedObj, true, nil
 	}
-	obj, err := parser.LookupByReference(*ref)
+	obj, _, err := parser.lookupByNumberWrapper(int(ref.ObjectNumber), true)
 	if err != nil {
 		return nil, false, err
 	}
@@ -1692,7 +1877,7 @@ func (parser *PdfParser) IsEncrypted() (bool, error) {
 		encIndObj, ok := encObj.(*PdfIndirectObject)
 		if !ok {
 			common.Log.Debug("Encryption object not an indirect object")
-			return false, errors.New("type check error")
+			return false, ErrTypeError
 		}
 		encDict, ok := encIndObj.PdfObject.(*PdfObjectDictionary)
 
@@ -1713,7 +1898,7 @@ func (parser *PdfParser) IsEncrypted() (bool, error) {
 		return false, err
 	}
 	// list objects that should never be decrypted
-	for _, key := range []string{"Info", "Encrypt"} {
+	for _, key := range []string{"Encrypt"} {
 		f := parser.trailer.Get(PdfObjectName(key))
 		if f == nil {
 			continue
@@ -1753,6 +1938,17 @@ func (parser *PdfParser) Decrypt(password []byte) (bool, error) {
 	return authenticated, err
 }
 
+// DecryptWithCertificate attempts to decrypt a PDF file encrypted with the public-key security
+// handler, with the recipient certificate `cert` and its private key `key`. Returns true if
+// successful, false if `cert` is not a recipient of the document.
+// An error is returned when there is a problem with decrypting.
+func (parser *PdfParser) DecryptWithCertificate(cert *x509.Certificate, key crypto.Decrypter) (bool, error) {
+	if parser.crypter == nil {
+		return false, errors.New("check encryption first")
+	}
+	return parser.crypter.authenticatePubKey(cert, key)
+}
+
 // CheckAccessRights checks access rights and permissions for a specified password. If either user/owner password is
 // specified, full rights are granted, otherwise the access rights are specified by the Permissions flag.
 //
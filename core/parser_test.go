@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -869,6 +870,42 @@ func TestMinimalPDFFile(t *testing.T) {
 	require.Equal(t, "Times-Roman", baseFont.String())
 }
 
+// TestRepairXrefs tests the reconstruction of the cross-reference table and
+// of the trailer of a file without them.
+func TestRepairXrefs(t *testing.T) {
+	objStm := "2 0 << /Type /Pages /Kids [] /Count 0 >>"
+	data := "%PDF-1.5\n" +
+		"1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+		fmt.Sprintf("3 0 obj\n<< /Type /ObjStm /N 1 /First 4 /Length %d >>\nstream\n%s\nendstream\nendobj\n",
+			len(objStm), objStm) +
+		"4 0 obj\n(Old)\nendobj\n" +
+		"4 0 obj\n(New)\nendobj\n" +
+		"xref\n0 5\n0000000000 65535 f\r\n00000"
+
+	parser, err := NewParser(bytes.NewReader([]byte(data)))
+	require.NoError(t, err)
+	require.True(t, parser.WasRepaired())
+
+	root, ok := parser.GetTrailer().Get("Root").(*PdfObjectReference)
+	require.True(t, ok)
+	require.Equal(t, int64(1), root.ObjectNumber)
+
+	// Objects of object streams are recovered.
+	require.Equal(t, XrefTypeObjectStream, parser.xrefs.ObjectMap[2].XType)
+	pages, err := parser.LookupByNumber(2)
+	require.NoError(t, err)
+	pagesDict, ok := GetDict(pages)
+	require.True(t, ok)
+	require.Equal(t, "Pages", pagesDict.Get("Type").String())
+
+	// The last definition of an object wins.
+	obj, err := parser.LookupByNumber(4)
+	require.NoError(t, err)
+	str, ok := GetStringVal(obj)
+	require.True(t, ok)
+	require.Equal(t, "New", str)
+}
+
 // Test PDF version parsing.
 func TestPDFVersionParse(t *testing.T) {
 	// Test parsing when the version is at the start of the file.
@@ -919,3 +956,154 @@ func TestPDFVersionParse(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, string(b), expected)
 }
+
+// TestParserRecoveries checks the recovery from common syntax errors of
+// malformed files, each fixture containing one kind of error.
+func TestParserRecoveries(t *testing.T) {
+	testcases := []struct {
+		name       string
+		recoveries map[RecoveryType]int
+		check      func(t *testing.T, parser *PdfParser)
+	}{
+		{
+			name:       "recovery_stream_length.pdf",
+			recoveries: map[RecoveryType]int{RecoveryStreamLength: 1},
+			check: func(t *testing.T, parser *PdfParser) {
+				obj, err := parser.LookupByNumber(4)
+				require.NoError(t, err)
+				stream, ok := GetStream(obj)
+				require.True(t, ok)
+				require.Equal(t, "BT /F1 24 Tf 72 720 Td (Hello World) Tj ET", string(stream.Stream))
+				length, _ := GetIntVal(stream.Get("Length"))
+				require.Equal(t, len(stream.Stream), length)
+			},
+		},
+		{
+			name:       "recovery_missing_endobj.pdf",
+			recoveries: map[RecoveryType]int{RecoveryMissingEndobj: 2, RecoveryMalformedObject: 1},
+			check: func(t *testing.T, parser *PdfParser) {
+				obj, err := parser.LookupByNumber(1)
+				require.NoError(t, err)
+				dict, ok := GetDict(obj)
+				require.True(t, ok)
+				require.Equal(t, "Catalog", dict.Get("Type").String())
+
+				obj, err = parser.LookupByNumber(2)
+				require.NoError(t, err)
+				dict, ok = GetDict(obj)
+				require.True(t, ok)
+				count, _ := GetIntVal(dict.Get("Count"))
+				require.Equal(t, 1, count)
+			},
+		},
+		{
+			name:       "recovery_numbers.pdf",
+			recoveries: map[RecoveryType]int{RecoveryMalformedNumber: 3},
+			check: func(t *testing.T, parser *PdfParser) {
+				obj, err := parser.LookupByNumber(3)
+				require.NoError(t, err)
+				dict, ok := GetDict(obj)
+				require.True(t, ok)
+				mediaBox, ok := GetArray(dict.Get("MediaBox"))
+				require.True(t, ok)
+				values, err := mediaBox.ToFloat64Array()
+				require.NoError(t, err)
+				require.Equal(t, []float64{0, 0, 612, 792}, values)
+			},
+		},
+		{
+			name:       "recovery_name_escapes.pdf",
+			recoveries: map[RecoveryType]int{RecoveryInvalidNameEscape: 2},
+			check: func(t *testing.T, parser *PdfParser) {
+				obj, err := parser.LookupByNumber(1)
+				require.NoError(t, err)
+				dict, ok := GetDict(obj)
+				require.True(t, ok)
+				require.Equal(t, []PdfObjectName{"Type", "Pages", "Lang#zz", "PieceInfo#4"}, dict.Keys())
+			},
+		},
+		{
+			name:       "recovery_xref_entries.pdf",
+			recoveries: map[RecoveryType]int{RecoveryMalformedXrefEntry: 5},
+			check: func(t *testing.T, parser *PdfParser) {
+				require.Len(t, parser.xrefs.ObjectMap, 4)
+				require.Equal(t, int64(9), parser.xrefs.ObjectMap[1].Offset)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tc.name))
+			require.NoError(t, err)
+			defer f.Close()
+
+			parser, err := NewParser(f)
+			require.NoError(t, err)
+			for _, objNum := range parser.GetObjectNums() {
+				_, err := parser.LookupByNumber(objNum)
+				require.NoError(t, err)
+			}
+			tc.check(t, parser)
+			require.False(t, parser.WasRepaired())
+			require.Equal(t, tc.recoveries, parser.GetRecoveries())
+		})
+	}
+}
+
+// TestParserLogs checks that the recoveries and repairs are logged to the logger of the parser
+// with the fields locating them.
+func TestParserLogs(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "recovery_stream_length.pdf"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	logger := common.NewRecordingLogger()
+	parser, err := NewParserWithConfig(f, ParserConfig{Logger: logger})
+	require.NoError(t, err)
+	_, err = parser.LookupByNumber(4)
+	require.NoError(t, err)
+	records := logger.Find("recovering from syntax error")
+	require.Len(t, records, 1)
+	require.Equal(t, common.LogLevelDebug, records[0].Level)
+	require.Equal(t, []interface{}{"recovery", "StreamLength", "object", int64(4), "generation", int64(0),
+		"length", int64(20), "corrected", int64(42)}, records[0].Fields)
+
+	data := "%PDF-1.5\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+		"2 0 obj\n<< /Type /Pages /Kids [] /Count 0 >>\nendobj\n"
+	logger = common.NewRecordingLogger()
+	parser, err = NewParserWithConfig(bytes.NewReader([]byte(data)), ParserConfig{Logger: logger})
+	require.NoError(t, err)
+	require.True(t, parser.WasRepaired())
+	records = logger.Find("repaired broken cross-reference table by scanning the file")
+	require.Len(t, records, 1)
+	require.Equal(t, common.LogLevelWarning, records[0].Level)
+	objects, ok := records[0].Field("objects")
+	require.True(t, ok)
+	require.Equal(t, 2, objects)
+}
+
+// TestParseMalformedNumbers checks the normalization of malformed numbers.
+func TestParseMalformedNumbers(t *testing.T) {
+	testcases := []struct {
+		raw      string
+		expected PdfObject
+	}{
+		{"--5", MakeInteger(-5)},
+		{"+-5", MakeInteger(5)},
+		{".5.", MakeFloat(0.5)},
+		{"1.2.3", MakeFloat(1.2)},
+		{"-.5.5e2", MakeFloat(-0.5)},
+		{"1e--2", MakeFloat(0.01)},
+	}
+	for _, tc := range testcases {
+		parser := makeParserForText(tc.raw + " ")
+		obj, err := parser.parseObject()
+		require.NoError(t, err, tc.raw)
+		require.Equal(t, tc.expected, obj, tc.raw)
+		require.Equal(t, map[RecoveryType]int{RecoveryMalformedNumber: 1}, parser.GetRecoveries(), tc.raw)
+
+		// The rest of the input is not affected.
+		require.True(t, parser.hasKeyword(" "), tc.raw)
+	}
+}
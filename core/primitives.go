@@ -10,6 +10,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 
@@ -81,6 +82,9 @@ type PdfObjectStream struct {
 	PdfObjectReference
 	*PdfObjectDictionary
 	Stream []byte
+
+	// Location of the stream data in the file when loaded lazily, nil once the data is loaded.
+	lazy *lazyStreamData
 }
 
 // PdfObjectStreams represents the primitive PDF object streams.
@@ -182,6 +186,12 @@ func MakeHexString(s string) *PdfObjectString {
 	return &str
 }
 
+// MakeHexStringFromBytes creates an PdfObjectString from a byte array intended for output as a
+// hexadecimal string, e.g. binary data such as the document ID.
+func MakeHexStringFromBytes(data []byte) *PdfObjectString {
+	return MakeHexString(string(data))
+}
+
 // MakeEncodedString creates a PdfObjectString with encoded content, which can be either
 // UTF-16BE or PDFDocEncoding depending on whether `utf16BE` is true or false respectively.
 func MakeEncodedString(s string, utf16BE bool) *PdfObjectString {
@@ -296,6 +306,33 @@ func (float *PdfObjectFloat) WriteString() string {
 	return strconv.FormatFloat(float64(*float), 'f', -1, 64)
 }
 
+// RoundFloat returns `val` rounded to `precision` decimal places. `val` is returned unchanged if
+// `precision` is negative.
+func RoundFloat(val float64, precision int) float64 {
+	if precision < 0 || math.IsInf(val, 0) || math.IsNaN(val) {
+		return val
+	}
+	pow := math.Pow10(precision)
+	if math.Abs(val*pow) >= 1<<53 {
+		// Too large to have decimal places beyond `precision`.
+		return val
+	}
+	rounded := math.Round(val*pow) / pow
+	if rounded == 0 {
+		// Avoids -0.
+		return 0
+	}
+	return rounded
+}
+
+// FormatFloat returns the representation of `val` written with at most `precision` decimal
+// places, without trailing zeros, e.g. 0.30000000000000004 is written as 0.3 and 2.0000001 as 2
+// with a precision of 4. The shortest representation reading back as `val` is returned if
+// `precision` is negative, as for PdfObjectFloat.WriteString.
+func FormatFloat(val float64, precision int) string {
+	return strconv.FormatFloat(RoundFloat(val, precision), 'f', -1, 64)
+}
+
 // String returns a string representation of the *PdfObjectString.
 func (str *PdfObjectString) String() string {
 	return str.val
@@ -329,7 +366,16 @@ func (str *PdfObjectString) Bytes() []byte {
 	return []byte(str.val)
 }
 
-// WriteString outputs the object as it is to be written to file.
+// IsHex returns true if the string is written as a hexadecimal string, as when it was read in
+// hexadecimal form or created with MakeHexString, and false if it is written as a literal string.
+func (str *PdfObjectString) IsHex() bool {
+	return str.isHex
+}
+
+// WriteString outputs the object as it is to be written to file. The string is written in the
+// form it was read or created in (see IsHex). Literal strings can contain any byte value: the
+// parentheses, whether balanced or not, the backslashes and the end-of-line characters, which
+// would otherwise be read as a single line feed, are escaped.
 func (str *PdfObjectString) WriteString() string {
 	var output bytes.Buffer
 
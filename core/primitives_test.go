@@ -81,6 +81,72 @@ func TestHexStringMulti(t *testing.T) {
 	}
 }
 
+// Test byte-level round trip of strings containing all byte values, in both forms.
+func TestStringAllBytesRoundTrip(t *testing.T) {
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	testcases := []string{
+		string(all),
+		"((", "))(", "a)b(c", "\\", "\\(",
+		"\r", "\r\n", "\n\r", "line1\r\nline2\rline3\n",
+		"",
+	}
+
+	for _, testcase := range testcases {
+		for _, str := range []*PdfObjectString{MakeString(testcase), MakeHexString(testcase)} {
+			written := str.WriteString()
+			obj, err := makeParserForText(written).parseObject()
+			if err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+			parsed, ok := obj.(*PdfObjectString)
+			if !ok {
+				t.Fatalf("Type incorrect: %T", obj)
+			}
+			if parsed.Str() != testcase {
+				t.Fatalf("String mismatch: % X != % X", parsed.Str(), testcase)
+			}
+			if parsed.IsHex() != str.IsHex() {
+				t.Fatalf("Form mismatch: %v != %v", parsed.IsHex(), str.IsHex())
+			}
+			if rewritten := parsed.WriteString(); rewritten != written {
+				t.Fatalf("Rewrite mismatch: %q != %q", rewritten, written)
+			}
+		}
+	}
+}
+
+// Test parsing of literal strings with end-of-line markers and escape sequences.
+func TestParseLiteralString(t *testing.T) {
+	testcases := map[string]string{
+		"(a\r\nb)":        "a\nb",
+		"(a\rb)":          "a\nb",
+		"(a\nb)":          "a\nb",
+		"(a\\\r\nb)":      "ab",
+		"(a\\\rb)":        "ab",
+		"(a\\\nb)":        "ab",
+		"(\\q\\/)":        "q/",
+		"(\\0\\12\\1234)": "\x00\n\x534",
+		"((a)\\))":        "(a))",
+	}
+
+	for src, expected := range testcases {
+		obj, err := makeParserForText(src).parseObject()
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		str, ok := obj.(*PdfObjectString)
+		if !ok {
+			t.Fatalf("Type incorrect: %T", obj)
+		}
+		if str.Str() != expected || str.IsHex() {
+			t.Fatalf("%q: %q != %q", src, str.Str(), expected)
+		}
+	}
+}
+
 func TestPdfDocEncodingDecode(t *testing.T) {
 	testcases := []struct {
 		Encoded  PdfObjectString
@@ -115,6 +181,33 @@ func TestUTF16StringEncodeDecode(t *testing.T) {
 	}
 }
 
+func TestFormatFloat(t *testing.T) {
+	testcases := []struct {
+		val       float64
+		precision int
+		expected  string
+	}{
+		{0.30000000000000004, -1, "0.30000000000000004"},
+		{0.30000000000000004, 4, "0.3"},
+		{0.30000000000000004, 0, "0"},
+		{2.0000001, 4, "2"},
+		{612, 2, "612"},
+		{1.23456, 2, "1.23"},
+		{1.25, 1, "1.3"},
+		{-0.00001, 3, "0"},
+		{-12.5, 0, "-13"},
+		{-1.05, 3, "-1.05"},
+		{1e20, 5, "100000000000000000000"},
+		{1e300, 10, strconv.FormatFloat(1e300, 'f', -1, 64)},
+	}
+
+	for _, tc := range testcases {
+		if s := FormatFloat(tc.val, tc.precision); s != tc.expected {
+			t.Fatalf("%v (%d): %s != %s", tc.val, tc.precision, s, tc.expected)
+		}
+	}
+}
+
 func BenchmarkPdfObjectIntegerWriteString(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		i := MakeInteger(int64(n))
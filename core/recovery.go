@@ -0,0 +1,148 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/unidoc/unipdf/v3/common"
+)
+
+// RecoveryType represents a kind of syntax error the parser recovers from when parsing
+// malformed files.
+type RecoveryType int
+
+// Kinds of syntax errors recovered from.
+const (
+	// RecoveryStreamLength is the recovery of streams whose Length entry is missing, invalid or
+	// does not match the position of the endstream keyword, which is searched for instead.
+	RecoveryStreamLength RecoveryType = iota
+
+	// RecoveryMissingEndobj is the recovery of indirect objects not terminated by the endobj
+	// keyword.
+	RecoveryMissingEndobj
+
+	// RecoveryMalformedObject is the recovery of objects truncated by the header of the next
+	// object, e.g. dictionaries without the closing '>>'. Parsing resumes at the next object.
+	RecoveryMalformedObject
+
+	// RecoveryMalformedNumber is the recovery of malformed numbers, e.g. --5 or .5., which are
+	// normalized (to -5 and 0.5).
+	RecoveryMalformedNumber
+
+	// RecoveryInvalidNameEscape is the recovery of names containing '#' characters not followed
+	// by two hexadecimal digits, which are kept as is.
+	RecoveryInvalidNameEscape
+
+	// RecoveryMalformedXrefEntry is the recovery of cross-reference table entries not having the
+	// fixed 20 bytes format, e.g. 19-byte lines or missing separators.
+	RecoveryMalformedXrefEntry
+)
+
+// String returns the name of the recovery type.
+func (t RecoveryType) String() string {
+	switch t {
+	case RecoveryStreamLength:
+		return "StreamLength"
+	case RecoveryMissingEndobj:
+		return "MissingEndobj"
+	case RecoveryMalformedObject:
+		return "MalformedObject"
+	case RecoveryMalformedNumber:
+		return "MalformedNumber"
+	case RecoveryInvalidNameEscape:
+		return "InvalidNameEscape"
+	case RecoveryMalformedXrefEntry:
+		return "MalformedXrefEntry"
+	}
+	return fmt.Sprintf("RecoveryType(%d)", int(t))
+}
+
+// GetRecoveries returns the number of syntax errors the parser has recovered from, by kind.
+// Objects are parsed on demand, so the counts can increase while the document is read.
+func (parser *PdfParser) GetRecoveries() map[RecoveryType]int {
+	parser.mu.Lock()
+	defer parser.mu.Unlock()
+	recoveries := make(map[RecoveryType]int, len(parser.recoveries))
+	for t, count := range parser.recoveries {
+		recoveries[t] = count
+	}
+	return recoveries
+}
+
+// recover logs and counts the recovery from a syntax error of kind `t`, with the fields `keyvals`
+// locating the error, e.g. the object number.
+func (parser *PdfParser) recover(t RecoveryType, keyvals ...interface{}) {
+	parser.log().Debug("recovering from syntax error", append([]interface{}{"recovery", t.String()}, keyvals...)...)
+	if parser.recoveries == nil {
+		parser.recoveries = map[RecoveryType]int{}
+	}
+	parser.recoveries[t]++
+}
+
+// endstreamKeyword is the keyword terminating the data of streams.
+var endstreamKeyword = []byte("endstream")
+
+// hasEndstreamAt returns true if the endstream keyword, optionally preceded by white space, is
+// at `offset` in the file.
+func (parser *PdfParser) hasEndstreamAt(offset int64) bool {
+	n := parser.fileSize - offset
+	if n > 32 {
+		n = 32
+	}
+	if n < int64(len(endstreamKeyword)) {
+		return false
+	}
+	bb, err := parser.ReadBytesAt(offset, n)
+	if err != nil {
+		return false
+	}
+	return bytes.HasPrefix(bytes.TrimLeft(bb, "\x00\t\n\f\r "), endstreamKeyword)
+}
+
+// findStreamLength returns the length of the data of the stream starting at `offset`, searching
+// for the following endstream keyword. The end-of-line marker preceding the keyword is not part
+// of the data. Returns false if the keyword is not found.
+func (parser *PdfParser) findStreamLength(offset int64) (int64, bool) {
+	const chunkSize = 4096
+	overlap := int64(len(endstreamKeyword) - 1)
+	for pos := offset; pos < parser.fileSize; pos += chunkSize - overlap {
+		n := parser.fileSize - pos
+		if n > chunkSize {
+			n = chunkSize
+		}
+		bb, err := parser.ReadBytesAt(pos, n)
+		if err != nil {
+			common.Log.Debug("ERROR: Failed to read stream data: %v", err)
+			return 0, false
+		}
+		if i := bytes.Index(bb, endstreamKeyword); i >= 0 {
+			length := pos + int64(i) - offset
+			if length >= 2 {
+				eol, err := parser.ReadBytesAt(offset+length-2, 2)
+				if err != nil {
+					return 0, false
+				}
+				switch {
+				case bytes.Equal(eol, []byte("\r\n")):
+					length -= 2
+				case eol[1] == '\n' || eol[1] == '\r':
+					length--
+				}
+			} else if length == 1 {
+				if eol, err := parser.ReadBytesAt(offset, 1); err == nil && (eol[0] == '\n' || eol[0] == '\r') {
+					length--
+				}
+			}
+			return length, true
+		}
+		if n < chunkSize {
+			break
+		}
+	}
+	return 0, false
+}
@@ -8,10 +8,12 @@
 package core
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
 	"regexp"
+	"sort"
 
 	"bufio"
 	"io"
@@ -37,12 +39,13 @@ func (parser *PdfParser) repairLocateXref() (int64, error) {
 
 	results := repairReXrefTable.FindAllStringIndex(string(b2), -1)
 	if len(results) < 1 {
-		common.Log.Debug("ERROR: Repair: xref not found!")
+		parser.log().Debug("xref repair failed: xref not found", "offset", curOffset)
 		return 0, errors.New("repair: xref not found")
 	}
 
 	localOffset := int64(results[len(results)-1][0])
 	xrefOffset := curOffset + localOffset
+	parser.repaired = true
 	return xrefOffset, nil
 }
 
@@ -55,15 +58,12 @@ func (parser *PdfParser) rebuildXrefTable() error {
 	for objNum, xref := range parser.xrefs.ObjectMap {
 		obj, _, err := parser.lookupByNumberWrapper(objNum, false)
 		if err != nil {
-			common.Log.Debug("ERROR: Unable to look up object (%s)", err)
-			common.Log.Debug("ERROR: Xref table completely broken - attempting to repair ")
-			xrefTable, err := parser.repairRebuildXrefsTopDown()
-			if err != nil {
-				common.Log.Debug("ERROR: Failed xref rebuild repair (%s)", err)
+			parser.log().Warn("xref table broken, rebuilding it", "object", objNum, "error", err)
+			if err := parser.repairRebuildXrefs(); err != nil {
+				parser.log().Error("xref table rebuild failed", "error", err)
 				return err
 			}
-			parser.xrefs = *xrefTable
-			common.Log.Debug("Repaired xref table built")
+			parser.log().Debug("xref table rebuilt", "objects", len(parser.xrefs.ObjectMap))
 			return nil
 		}
 		actObjNum, actGenNum, err := getObjectNumber(obj)
@@ -77,6 +77,7 @@ func (parser *PdfParser) rebuildXrefTable() error {
 	}
 
 	parser.xrefs = newXrefs
+	parser.repaired = true
 	common.Log.Debug("New xref table built")
 	printXrefTable(parser.xrefs)
 	return nil
@@ -168,8 +169,9 @@ func (parser *PdfParser) repairRebuildXrefsTopDown() (*XrefTable, error) {
 				return nil, err
 			}
 
-			// Create and insert the XREF entry if not existing, or the generation number is higher.
-			if curXref, has := xrefTable.ObjectMap[objNum]; !has || curXref.Generation < genNum {
+			// Create and insert the XREF entry if not existing, or the generation number is not
+			// lower: the last definition of an object wins, as with incremental updates.
+			if curXref, has := xrefTable.ObjectMap[objNum]; !has || curXref.Generation <= genNum {
 				// Make the entry for the cross ref table.
 				xrefEntry := XrefObject{}
 				xrefEntry.XType = XrefTypeTableEntry
@@ -186,6 +188,228 @@ func (parser *PdfParser) repairRebuildXrefsTopDown() (*XrefTable, error) {
 	return &xrefTable, nil
 }
 
+// repairRebuildXrefs replaces the cross-reference table by one rebuilt by
+// scanning the file for objects, including the objects of object streams.
+func (parser *PdfParser) repairRebuildXrefs() error {
+	xrefTable, err := parser.repairRebuildXrefsTopDown()
+	if err != nil {
+		return err
+	}
+	parser.xrefs = *xrefTable
+	if parser.objstms == nil {
+		parser.objstms = make(objectStreams)
+	}
+	parser.repairObjectStreams()
+	parser.repaired = true
+	parser.log().Warn("repaired broken cross-reference table by scanning the file",
+		"objects", len(parser.xrefs.ObjectMap))
+	return nil
+}
+
+// repairObjectStreams adds the objects of the object streams found in the
+// cross-reference table, unless they are also defined outside of object
+// streams.
+func (parser *PdfParser) repairObjectStreams() {
+	var streams []int
+	for objNum, xref := range parser.xrefs.ObjectMap {
+		if xref.XType != XrefTypeTableEntry {
+			continue
+		}
+		obj, _, err := parser.lookupByNumberWrapper(objNum, false)
+		if err != nil {
+			continue
+		}
+		if stream, ok := obj.(*PdfObjectStream); ok {
+			if name, _ := GetNameVal(stream.Get("Type")); name == "ObjStm" {
+				streams = append(streams, objNum)
+			}
+		}
+	}
+	sort.Ints(streams)
+
+	for _, sobjNum := range streams {
+		objstm, err := parser.loadObjectStream(sobjNum)
+		if err != nil {
+			common.Log.Debug("Unable to load object stream %d: %v", sobjNum, err)
+			continue
+		}
+		if parser.crypter == nil {
+			// The stream might need to be decrypted, load it again on use.
+			delete(parser.objstms, sobjNum)
+		}
+		for objNum := range objstm.offsets {
+			if _, has := parser.xrefs.ObjectMap[objNum]; has {
+				continue
+			}
+			parser.xrefs.ObjectMap[objNum] = XrefObject{
+				XType:        XrefTypeObjectStream,
+				ObjectNumber: objNum,
+				OsObjNumber:  sobjNum,
+			}
+		}
+	}
+}
+
+// repairFindKeyword returns the offsets of the occurrences of `keyword` in
+// the file.
+func (parser *PdfParser) repairFindKeyword(keyword []byte) ([]int64, error) {
+	if _, err := parser.rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var offsets []int64
+	var pos int64
+	buf := make([]byte, 64*1024)
+	var carry []byte
+	for {
+		n, err := parser.rs.Read(buf)
+		data := append(carry, buf[:n]...)
+		base := pos - int64(len(carry))
+		for i := 0; ; {
+			j := bytes.Index(data[i:], keyword)
+			if j < 0 {
+				break
+			}
+			offsets = append(offsets, base+int64(i+j))
+			i += j + len(keyword)
+		}
+		pos += int64(n)
+
+		// Keep the end of the data, in case the keyword spans two reads.
+		if keep := len(keyword) - 1; len(data) > keep {
+			carry = append([]byte{}, data[len(data)-keep:]...)
+		} else {
+			carry = data
+		}
+		if err == io.EOF || n == 0 {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return offsets, nil
+}
+
+// repairTrailer returns the last trailer dictionary of the file referring to
+// the catalog, either following a trailer keyword or the dictionary of a
+// cross-reference stream. When none is found, the trailer is reconstructed
+// from the catalog object.
+func (parser *PdfParser) repairTrailer() (*PdfObjectDictionary, error) {
+	isCatalog := func(obj PdfObject) bool {
+		ref, ok := obj.(*PdfObjectReference)
+		if !ok {
+			return false
+		}
+		obj, _, err := parser.lookupByNumberWrapper(int(ref.ObjectNumber), false)
+		if err != nil {
+			return false
+		}
+		dict, ok := GetDict(obj)
+		return ok && dict.Get("Pages") != nil
+	}
+
+	var trailer *PdfObjectDictionary
+	trailerOffset := int64(-1)
+	keywordOffsets, err := parser.repairFindKeyword([]byte("trailer"))
+	if err != nil {
+		return nil, err
+	}
+	for _, offset := range keywordOffsets {
+		parser.SetFileOffset(offset + int64(len("trailer")))
+		parser.skipSpaces()
+		dict, err := parser.ParseDict()
+		if err != nil || dict.Get("Root") == nil {
+			continue
+		}
+		trailer, trailerOffset = dict, offset
+	}
+	for objNum, xref := range parser.xrefs.ObjectMap {
+		if xref.XType != XrefTypeTableEntry || xref.Offset <= trailerOffset {
+			continue
+		}
+		obj, _, err := parser.lookupByNumberWrapper(objNum, false)
+		if err != nil {
+			continue
+		}
+		stream, ok := obj.(*PdfObjectStream)
+		if !ok || stream.Get("Root") == nil {
+			continue
+		}
+		if name, _ := GetNameVal(stream.Get("Type")); name == "XRef" {
+			trailer, trailerOffset = stream.PdfObjectDictionary, xref.Offset
+		}
+	}
+
+	if trailer != nil && isCatalog(trailer.Get("Root")) {
+		return trailer, nil
+	}
+	if trailer == nil {
+		trailer = MakeDict()
+	}
+
+	// Reconstruct the trailer from the last catalog of the file.
+	var objNums []int
+	maxNum := 0
+	for objNum := range parser.xrefs.ObjectMap {
+		objNums = append(objNums, objNum)
+		if objNum > maxNum {
+			maxNum = objNum
+		}
+	}
+	sort.Ints(objNums)
+	offset := func(xref XrefObject) int64 {
+		if xref.XType == XrefTypeObjectStream {
+			return parser.xrefs.ObjectMap[xref.OsObjNumber].Offset
+		}
+		return xref.Offset
+	}
+	var root *PdfObjectReference
+	var rootOffset int64
+	for _, objNum := range objNums {
+		xref := parser.xrefs.ObjectMap[objNum]
+		obj, _, err := parser.lookupByNumberWrapper(objNum, false)
+		if err != nil {
+			continue
+		}
+		dict, ok := GetDict(obj)
+		if !ok || dict.Get("Pages") == nil {
+			continue
+		}
+		if name, _ := GetNameVal(dict.Get("Type")); name != "Catalog" {
+			continue
+		}
+		if root == nil || offset(xref) >= rootOffset {
+			root = &PdfObjectReference{parser: parser, ObjectNumber: int64(objNum), GenerationNumber: int64(xref.Generation)}
+			rootOffset = offset(xref)
+		}
+	}
+	if root == nil {
+		return nil, errors.New("repair: catalog not found")
+	}
+	parser.log().Warn("reconstructed the trailer", "catalog", root.ObjectNumber)
+	trailer.Set("Root", root)
+	trailer.Set("Size", MakeInteger(int64(maxNum+1)))
+	return trailer, nil
+}
+
+// repairXrefs rebuilds the cross-reference table and the trailer of a file
+// whose cross-reference information cannot be loaded.
+func (parser *PdfParser) repairXrefs() error {
+	parser.ObjCache = objectCache{}
+	parser.objstms = make(objectStreams)
+	parser.objstmsOrder = nil
+	if err := parser.repairRebuildXrefs(); err != nil {
+		return err
+	}
+	trailer, err := parser.repairTrailer()
+	if err != nil {
+		return err
+	}
+	parser.trailer = trailer
+	return nil
+}
+
 // Look for first sign of xref table from end of file.
 func (parser *PdfParser) repairSeekXrefMarker() error {
 	// Get the file size.
@@ -225,6 +449,7 @@ func (parser *PdfParser) repairSeekXrefMarker() error {
 			common.Log.Trace("Ind: % d", ind)
 			parser.rs.Seek(-offset-buflen+int64(lastInd[0]), os.SEEK_END)
 			parser.reader = bufio.NewReader(parser.rs)
+			parser.repaired = true
 			// Go past whitespace, finish at 'x'.
 			for {
 				bb, err := parser.reader.Peek(1)
@@ -283,5 +508,5 @@ func (parser *PdfParser) seekPdfVersionTopDown() (int, int, error) {
 		last = append(last[1:bufLen], b)
 	}
 
-	return 0, 0, errors.New("version not found")
+	return 0, 0, ErrNoPdfVersion
 }
@@ -0,0 +1,242 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package security
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidAES128CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC    = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+// cmsContentInfo is a CMS ContentInfo (RFC 5652 section 3).
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue // [0] EXPLICIT
+}
+
+// cmsEnvelopedData is a CMS EnvelopedData (RFC 5652 section 6.1). Only the
+// recipient infos of the key transport type are supported, the other ones are
+// kept as raw values and skipped.
+type cmsEnvelopedData struct {
+	Version              int
+	RecipientInfos       []asn1.RawValue `asn1:"set"`
+	EncryptedContentInfo cmsEncryptedContentInfo
+}
+
+// cmsKeyTransRecipientInfo identifies a recipient by the issuer and serial
+// number of its certificate (RFC 5652 section 6.2.1).
+type cmsKeyTransRecipientInfo struct {
+	Version                int
+	IssuerAndSerialNumber  cmsIssuerAndSerialNumber
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type cmsIssuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type cmsEncryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           asn1.RawValue `asn1:"optional"` // [0] IMPLICIT
+}
+
+// cmsEnvelope encrypts `content` with a random AES-256 key, and encrypts the
+// key to each of the RSA certificates `recipients`. Returns the DER encoded
+// CMS EnvelopedData content info.
+func cmsEnvelope(content []byte, recipients []*x509.Certificate) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipients")
+	}
+
+	// Content encryption.
+	key := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	pad := aes.BlockSize - len(content)%aes.BlockSize
+	data := append(append([]byte{}, content...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+
+	// Key transport.
+	var infos []asn1.RawValue
+	for _, cert := range recipients {
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("unsupported recipient public key type: %T", cert.PublicKey)
+		}
+		encKey, err := rsa.EncryptPKCS1v15(rand.Reader, pub, key)
+		if err != nil {
+			return nil, err
+		}
+		info, err := asn1.Marshal(cmsKeyTransRecipientInfo{
+			IssuerAndSerialNumber: cmsIssuerAndSerialNumber{
+				Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+				SerialNumber: cert.SerialNumber,
+			},
+			KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{
+				Algorithm:  oidRSAEncryption,
+				Parameters: asn1.NullRawValue,
+			},
+			EncryptedKey: encKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, asn1.RawValue{FullBytes: info})
+	}
+
+	env, err := asn1.Marshal(cmsEnvelopedData{
+		RecipientInfos: infos,
+		EncryptedContentInfo: cmsEncryptedContentInfo{
+			ContentType: oidData,
+			ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{
+				Algorithm:  oidAES256CBC,
+				Parameters: asn1.RawValue{FullBytes: ivParam},
+			},
+			EncryptedContent: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: data},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(cmsContentInfo{
+		ContentType: oidEnvelopedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: env},
+	})
+}
+
+// errCMSNotRecipient is returned by cmsOpen when the certificate is not one of
+// the recipients of the enveloped data.
+var errCMSNotRecipient = errors.New("certificate is not a recipient")
+
+// cmsOpen decrypts the DER encoded CMS EnvelopedData content info `der` for the
+// recipient certificate `cert`, using its private key `key`. Returns
+// errCMSNotRecipient if `cert` is not one of the recipients.
+func cmsOpen(der []byte, cert *x509.Certificate, key crypto.Decrypter) ([]byte, error) {
+	var ci cmsContentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, err
+	}
+	if !ci.ContentType.Equal(oidEnvelopedData) {
+		return nil, fmt.Errorf("unsupported CMS content type: %v", ci.ContentType)
+	}
+	var env cmsEnvelopedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &env); err != nil {
+		return nil, err
+	}
+
+	var encKey []byte
+	for _, raw := range env.RecipientInfos {
+		var info cmsKeyTransRecipientInfo
+		if _, err := asn1.Unmarshal(raw.FullBytes, &info); err != nil {
+			continue
+		}
+		ias := info.IssuerAndSerialNumber
+		if ias.SerialNumber != nil && ias.SerialNumber.Cmp(cert.SerialNumber) == 0 &&
+			bytes.Equal(ias.Issuer.FullBytes, cert.RawIssuer) {
+			encKey = info.EncryptedKey
+			break
+		}
+	}
+	if encKey == nil {
+		return nil, errCMSNotRecipient
+	}
+	cek, err := key.Decrypt(rand.Reader, encKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	eci := env.EncryptedContentInfo
+	data, err := cmsEncryptedContent(eci.EncryptedContent)
+	if err != nil {
+		return nil, err
+	}
+	var block cipher.Block
+	alg := eci.ContentEncryptionAlgorithm.Algorithm
+	switch {
+	case alg.Equal(oidAES128CBC), alg.Equal(oidAES192CBC), alg.Equal(oidAES256CBC):
+		block, err = aes.NewCipher(cek)
+	case alg.Equal(oidDESEDE3CBC):
+		block, err = des.NewTripleDESCipher(cek)
+	default:
+		return nil, fmt.Errorf("unsupported CMS content encryption algorithm: %v", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(eci.ContentEncryptionAlgorithm.Parameters.FullBytes, &iv); err != nil {
+		return nil, err
+	}
+	bs := block.BlockSize()
+	if len(iv) != bs || len(data) == 0 || len(data)%bs != 0 {
+		return nil, errors.New("invalid CMS encrypted content")
+	}
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, data)
+	pad := int(data[len(data)-1])
+	if pad == 0 || pad > bs {
+		return nil, errors.New("invalid CMS content padding")
+	}
+	return data[:len(data)-pad], nil
+}
+
+// cmsEncryptedContent returns the bytes of the [0] IMPLICIT encrypted content,
+// which can also be split into several octet strings in BER encodings.
+func cmsEncryptedContent(v asn1.RawValue) ([]byte, error) {
+	if v.Class != asn1.ClassContextSpecific || v.Tag != 0 {
+		return nil, errors.New("missing CMS encrypted content")
+	}
+	if !v.IsCompound {
+		return append([]byte{}, v.Bytes...), nil
+	}
+	var data []byte
+	for rest := v.Bytes; len(rest) > 0; {
+		var chunk []byte
+		var err error
+		rest, err = asn1.Unmarshal(rest, &chunk)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+	}
+	return data, nil
+}
@@ -0,0 +1,127 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package security
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+)
+
+// pubKeySeedLength is the length of the random seed used to derive the file
+// encryption key of the public-key security handler.
+const pubKeySeedLength = 20
+
+// PubKeyRecipientGroup is a group of recipients of a document encrypted with the
+// public-key security handler. All the recipients of a group are granted the
+// same access permissions.
+type PubKeyRecipientGroup struct {
+	Certificates []*x509.Certificate
+	Permissions  Permissions
+}
+
+// PubKeyEncryptDict is a set of additional fields used in encryption
+// dictionaries of the public-key security handler.
+type PubKeyEncryptDict struct {
+	// Recipients are the DER encoded CMS enveloped data objects, one for each
+	// recipient group. They contain the seed and the permissions of the group.
+	Recipients      [][]byte
+	EncryptMetadata bool // Indicates whether the document-level metadata stream shall be encrypted.
+
+	// set by the security handler:
+
+	P Permissions // Permissions granted to the authenticated recipient.
+}
+
+// PubKeyHandler is the public-key security handler (Adobe.PubSec).
+// See 7.6.5 Public-Key Security Handlers (page 89).
+type PubKeyHandler struct {
+	// KeyLength is the length of the file encryption key in bytes. Keys of
+	// 32 bytes (AESV3) are derived with SHA-256, other ones with SHA-1.
+	KeyLength int
+}
+
+// GenerateParams encrypts a random seed and the permissions of each of the
+// recipient `groups` to their certificates, and stores the results as the
+// Recipients of `d`. Returns the file encryption key.
+func (h PubKeyHandler) GenerateParams(d *PubKeyEncryptDict, groups []PubKeyRecipientGroup) ([]byte, error) {
+	if len(groups) == 0 {
+		return nil, errors.New("no recipients")
+	}
+	seed := make([]byte, pubKeySeedLength)
+	if _, err := io.ReadFull(rand.Reader, seed); err != nil {
+		return nil, err
+	}
+
+	d.Recipients = nil
+	for _, group := range groups {
+		content := make([]byte, pubKeySeedLength+4)
+		copy(content, seed)
+		binary.BigEndian.PutUint32(content[pubKeySeedLength:], uint32(group.Permissions))
+		env, err := cmsEnvelope(content, group.Certificates)
+		if err != nil {
+			return nil, err
+		}
+		d.Recipients = append(d.Recipients, env)
+	}
+	d.P = PermOwner
+	return h.fileKey(d, seed), nil
+}
+
+// Authenticate looks for the recipient certificate `cert` in the Recipients of
+// `d`, and decrypts the seed and the permissions of its group with the private
+// key `key`. Returns the file encryption key and the permissions granted to the
+// recipient. If `cert` is not a recipient of the document, it returns an empty
+// key and zero permissions with no error.
+func (h PubKeyHandler) Authenticate(d *PubKeyEncryptDict, cert *x509.Certificate, key crypto.Decrypter) ([]byte, Permissions, error) {
+	if cert == nil || key == nil {
+		return nil, 0, errors.New("certificate and private key required")
+	}
+	for _, env := range d.Recipients {
+		content, err := cmsOpen(env, cert, key)
+		if err == errCMSNotRecipient {
+			continue
+		} else if err != nil {
+			return nil, 0, err
+		}
+		if len(content) != pubKeySeedLength+4 {
+			return nil, 0, errors.New("invalid public-key security handler recipient data")
+		}
+		perm := Permissions(binary.BigEndian.Uint32(content[pubKeySeedLength:]))
+		return h.fileKey(d, content[:pubKeySeedLength]), perm, nil
+	}
+	return nil, 0, nil
+}
+
+// fileKey computes the file encryption key from the `seed` and the Recipients
+// of `d`.
+// See 7.6.5.3 Public-key encryption algorithms (page 92).
+func (h PubKeyHandler) fileKey(d *PubKeyEncryptDict, seed []byte) []byte {
+	var sum hash.Hash
+	if h.KeyLength == 32 {
+		sum = sha256.New()
+	} else {
+		sum = sha1.New()
+	}
+	sum.Write(seed)
+	for _, env := range d.Recipients {
+		sum.Write(env)
+	}
+	if !d.EncryptMetadata {
+		sum.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	}
+	key := sum.Sum(nil)
+	if h.KeyLength > 0 && h.KeyLength < len(key) {
+		key = key[:h.KeyLength]
+	}
+	return key
+}
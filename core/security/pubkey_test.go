@@ -0,0 +1,103 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package security
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newTestCertificate(t *testing.T, serial int64) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "Recipient"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestPubKeyHandler(t *testing.T) {
+	cert1, key1 := newTestCertificate(t, 1)
+	cert2, key2 := newTestCertificate(t, 2)
+	cert3, key3 := newTestCertificate(t, 3)
+	groups := []PubKeyRecipientGroup{
+		{Certificates: []*x509.Certificate{cert1, cert2}, Permissions: PermPrinting},
+		{Certificates: []*x509.Certificate{cert3}, Permissions: PermOwner},
+	}
+
+	for _, length := range []int{16, 32} {
+		h := PubKeyHandler{KeyLength: length}
+		d := &PubKeyEncryptDict{EncryptMetadata: true}
+		fkey, err := h.GenerateParams(d, groups)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(fkey) != length {
+			t.Fatalf("expected a key of %d bytes, got %d", length, len(fkey))
+		} else if len(d.Recipients) != len(groups) {
+			t.Fatalf("expected %d recipients, got %d", len(groups), len(d.Recipients))
+		}
+
+		for i, c := range []struct {
+			cert *x509.Certificate
+			key  *rsa.PrivateKey
+			perm Permissions
+		}{
+			{cert1, key1, PermPrinting},
+			{cert2, key2, PermPrinting},
+			{cert3, key3, PermOwner},
+		} {
+			key, perm, err := h.Authenticate(d, c.cert, c.key)
+			if err != nil {
+				t.Fatalf("recipient %d: %v", i, err)
+			} else if !bytes.Equal(fkey, key) {
+				t.Fatalf("recipient %d: wrong file key", i)
+			} else if perm != c.perm {
+				t.Fatalf("recipient %d: expected permissions %v, got %v", i, c.perm, perm)
+			}
+		}
+
+		// The metadata flag is part of the file key.
+		d.EncryptMetadata = false
+		key, _, err := h.Authenticate(d, cert1, key1)
+		if err != nil {
+			t.Fatal(err)
+		} else if bytes.Equal(fkey, key) {
+			t.Fatal("expected a different file key")
+		}
+	}
+
+	// Not a recipient.
+	other, otherKey := newTestCertificate(t, 4)
+	d := &PubKeyEncryptDict{EncryptMetadata: true}
+	if _, err := (PubKeyHandler{KeyLength: 16}).GenerateParams(d, groups[:1]); err != nil {
+		t.Fatal(err)
+	}
+	key, perm, err := PubKeyHandler{KeyLength: 16}.Authenticate(d, other, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	} else if key != nil || perm != 0 {
+		t.Fatal("expected authentication to fail")
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package security
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/bidi"
+	"golang.org/x/text/unicode/norm"
+)
+
+// runeRange is an inclusive range of code points.
+type runeRange struct {
+	lo, hi rune
+}
+
+// inRanges returns true if `r` is in one of `ranges`.
+func inRanges(r rune, ranges []runeRange) bool {
+	for _, rr := range ranges {
+		if r >= rr.lo && r <= rr.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// Non-ASCII space characters, mapped to SPACE (RFC 3454 table C.1.2).
+var saslNonASCIISpaces = []runeRange{
+	{0x00A0, 0x00A0}, {0x1680, 0x1680}, {0x2000, 0x200B}, {0x202F, 0x202F},
+	{0x205F, 0x205F}, {0x3000, 0x3000},
+}
+
+// Characters commonly mapped to nothing (RFC 3454 table B.1).
+var saslMappedToNothing = []runeRange{
+	{0x00AD, 0x00AD}, {0x034F, 0x034F}, {0x1806, 0x1806}, {0x180B, 0x180D},
+	{0x200B, 0x200D}, {0x2060, 0x2060}, {0xFE00, 0xFE0F}, {0xFEFF, 0xFEFF},
+}
+
+// Prohibited output characters (RFC 3454 tables C.1.2, C.2.1, C.2.2, C.3,
+// C.4, C.5, C.6, C.7, C.8 and C.9).
+var saslProhibited = []runeRange{
+	// Control characters.
+	{0x0000, 0x001F}, {0x007F, 0x009F}, {0x06DD, 0x06DD}, {0x070F, 0x070F},
+	{0x180E, 0x180E}, {0x200C, 0x200D}, {0x2028, 0x2029}, {0x2060, 0x2063},
+	{0x206A, 0x206F}, {0xFEFF, 0xFEFF}, {0xFFF9, 0xFFFC}, {0x1D173, 0x1D17A},
+	// Private use.
+	{0xE000, 0xF8FF}, {0xF0000, 0xFFFFD}, {0x100000, 0x10FFFD},
+	// Non-character code points.
+	{0xFDD0, 0xFDEF}, {0xFFFE, 0xFFFF}, {0x1FFFE, 0x1FFFF}, {0x2FFFE, 0x2FFFF},
+	{0x3FFFE, 0x3FFFF}, {0x4FFFE, 0x4FFFF}, {0x5FFFE, 0x5FFFF}, {0x6FFFE, 0x6FFFF},
+	{0x7FFFE, 0x7FFFF}, {0x8FFFE, 0x8FFFF}, {0x9FFFE, 0x9FFFF}, {0xAFFFE, 0xAFFFF},
+	{0xBFFFE, 0xBFFFF}, {0xCFFFE, 0xCFFFF}, {0xDFFFE, 0xDFFFF}, {0xEFFFE, 0xEFFFF},
+	{0xFFFFE, 0xFFFFF}, {0x10FFFE, 0x10FFFF},
+	// Surrogate codes, inappropriate for plain text and canonical
+	// representation, ideographic description characters.
+	{0xD800, 0xDFFF}, {0xFFF9, 0xFFFD}, {0x2FF0, 0x2FFB},
+	// Change display properties or deprecated.
+	{0x0340, 0x0341}, {0x200E, 0x200F}, {0x202A, 0x202E},
+	// Tagging characters.
+	{0xE0001, 0xE0001}, {0xE0020, 0xE007F},
+}
+
+// saslprep prepares the UTF-8 password `pass` with the SASLprep profile of
+// stringprep (RFC 4013), as required by the standard security handler for
+// revisions 5 and 6. Unassigned code points are allowed, and passwords which
+// are not valid UTF-8 are returned unchanged.
+// See 7.6.4.3.3 Algorithm 2.A (page 83).
+func saslprep(pass []byte) ([]byte, error) {
+	if !utf8.Valid(pass) {
+		return pass, nil
+	}
+
+	// Mapping.
+	mapped := make([]rune, 0, len(pass))
+	for _, r := range string(pass) {
+		switch {
+		case inRanges(r, saslNonASCIISpaces):
+			mapped = append(mapped, ' ')
+		case inRanges(r, saslMappedToNothing):
+		default:
+			mapped = append(mapped, r)
+		}
+	}
+
+	// Normalization.
+	prepped := norm.NFKC.String(string(mapped))
+
+	// Prohibited output and bidirectional characters.
+	var hasRandAL, hasL bool
+	var first, last bidi.Class
+	i := 0
+	for _, r := range prepped {
+		if inRanges(r, saslProhibited) || inRanges(r, saslNonASCIISpaces) {
+			return nil, fmt.Errorf("prohibited character U+%04X in password", r)
+		}
+		props, _ := bidi.LookupRune(r)
+		class := props.Class()
+		switch class {
+		case bidi.R, bidi.AL:
+			hasRandAL = true
+		case bidi.L:
+			hasL = true
+		}
+		if i == 0 {
+			first = class
+		}
+		last = class
+		i++
+	}
+	if hasRandAL {
+		isRandAL := func(c bidi.Class) bool { return c == bidi.R || c == bidi.AL }
+		if hasL || !isRandAL(first) || !isRandAL(last) {
+			return nil, errors.New("invalid bidirectional text in password")
+		}
+	}
+	return []byte(prepped), nil
+}
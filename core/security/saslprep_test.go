@@ -0,0 +1,39 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSASLprep(t *testing.T) {
+	// Examples of RFC 4013, section 3.
+	valid := []struct {
+		in, out string
+	}{
+		{"I\u00ADX", "IX"},
+		{"user", "user"},
+		{"USER", "USER"},
+		{"\u00AA", "a"},
+		{"\u2168", "IX"},
+		{"a\u00A0b", "a b"},
+		{"e\u0301", "\u00E9"},
+		{"\u0627\u0628", "\u0627\u0628"},
+		{"\xff\xfe", "\xff\xfe"},
+	}
+	for _, c := range valid {
+		out, err := saslprep([]byte(c.in))
+		require.NoError(t, err, c.in)
+		require.Equal(t, c.out, string(out))
+	}
+
+	for _, in := range []string{"\u0007", "a\uE000", "\u06271", "\u0627a\u0628"} {
+		_, err := saslprep([]byte(in))
+		require.Error(t, err, in)
+	}
+}
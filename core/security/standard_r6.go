@@ -55,7 +55,11 @@ func (sh stdHandlerR6) alg2a(d *StdEncryptDict, pass []byte) ([]byte, Permission
 	}
 
 	// step a: Unicode normalization
-	// TODO(dennwc): make sure that UTF-8 strings are normalized
+	if prepped, err := saslprep(pass); err != nil {
+		common.Log.Debug("Unable to prepare password, using it as is: %v", err)
+	} else {
+		pass = prepped
+	}
 
 	// step b: truncate to 127 bytes
 	if len(pass) > 127 {
@@ -517,6 +521,14 @@ func (sh stdHandlerR6) GenerateParams(d *StdEncryptDict, opass, upass []byte) ([
 	d.OE = nil
 	d.Perms = nil // populated only for R=6
 
+	upass, err := saslprep(upass)
+	if err != nil {
+		return nil, err
+	}
+	opass, err = saslprep(opass)
+	if err != nil {
+		return nil, err
+	}
 	if len(upass) > 127 {
 		upass = upass[:127]
 	}
@@ -12,7 +12,11 @@ import (
 )
 
 // NewEncoderFromStream creates a StreamEncoder based on the stream's dictionary.
+// The data of streams parsed in lazy-loading mode is loaded prior to creating the encoder.
 func NewEncoderFromStream(streamObj *PdfObjectStream) (StreamEncoder, error) {
+	if err := streamObj.LoadData(); err != nil {
+		return nil, err
+	}
 	filterObj := TraceToDirectObject(streamObj.PdfObjectDictionary.Get("Filter"))
 	if filterObj == nil {
 		// No filter, return raw data back.
@@ -61,7 +65,7 @@ func NewEncoderFromStream(streamObj *PdfObjectStream) (StreamEncoder, error) {
 	case StreamEncodingFilterNameLZW:
 		return newLZWEncoderFromStream(streamObj, nil)
 	case StreamEncodingFilterNameDCT:
-		return newDCTEncoderFromStream(streamObj, nil)
+		return newDCTEncoderFromStream(streamObj, nil, nil)
 	case StreamEncodingFilterNameRunLength:
 		return newRunLengthEncoderFromStream(streamObj, nil)
 	case StreamEncodingFilterNameASCIIHex:
@@ -73,10 +77,13 @@ func NewEncoderFromStream(streamObj *PdfObjectStream) (StreamEncoder, error) {
 	case StreamEncodingFilterNameJBIG2:
 		return newJBIG2DecoderFromStream(streamObj, nil)
 	case StreamEncodingFilterNameJPX:
-		return NewJPXEncoder(), nil
+		return newJPXEncoderFromStream(streamObj, nil)
+	case cryptStreamFilter:
+		// Already decrypted by the crypt handler.
+		return NewRawEncoder(), nil
 	}
 	common.Log.Debug("ERROR: Unsupported encoding method!")
-	return nil, fmt.Errorf("unsupported encoding method (%s)", *method)
+	return nil, ErrUnsupportedFilter{Name: string(*method)}
 }
 
 // DecodeStream decodes the stream data and returns the decoded data.
@@ -110,13 +117,6 @@ func EncodeStream(streamObj *PdfObjectStream) error {
 		return err
 	}
 
-	if lzwenc, is := encoder.(*LZWEncoder); is {
-		// If LZW:
-		// Make sure to use EarlyChange 0.. We do not have write support for 1 yet.
-		lzwenc.EarlyChange = 0
-		streamObj.PdfObjectDictionary.Set("EarlyChange", MakeInteger(0))
-	}
-
 	common.Log.Trace("Encoder: %+v\n", encoder)
 	encoded, err := encoder.EncodeBytes(streamObj.Stream)
 	if err != nil {
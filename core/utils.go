@@ -42,6 +42,9 @@ func (parser *PdfParser) Inspect() (map[string]int, error) {
 
 // GetObjectNums returns a sorted list of object numbers of the PDF objects in the file.
 func (parser *PdfParser) GetObjectNums() []int {
+	parser.mu.Lock()
+	defer parser.mu.Unlock()
+
 	var objNums []int
 	for _, x := range parser.xrefs.ObjectMap {
 		objNums = append(objNums, x.ObjectNumber)
@@ -97,6 +100,10 @@ func resolveReferencesDeep(o PdfObject, depth int, traversed map[PdfObject]struc
 		for _, name := range dict.Keys() {
 			v := dict.Get(name)
 			if ref, isRef := v.(*PdfObjectReference); isRef {
+				if max := ref.GetParser().GetConfig().MaxReferenceDepth; depth >= max {
+					common.Log.Debug("ERROR: Reference depth exceeds the limit (%d)", max)
+					return ErrLimitExceeded{Limit: "MaxReferenceDepth", Max: int64(max)}
+				}
 				resolvedObj := ref.Resolve()
 				dict.Set(name, resolvedObj)
 				err := resolveReferencesDeep(resolvedObj, depth+1, traversed)
@@ -116,6 +123,10 @@ func resolveReferencesDeep(o PdfObject, depth int, traversed map[PdfObject]struc
 		common.Log.Trace("- array: %s", arr)
 		for idx, v := range arr.Elements() {
 			if ref, isRef := v.(*PdfObjectReference); isRef {
+				if max := ref.GetParser().GetConfig().MaxReferenceDepth; depth >= max {
+					common.Log.Debug("ERROR: Reference depth exceeds the limit (%d)", max)
+					return ErrLimitExceeded{Limit: "MaxReferenceDepth", Max: int64(max)}
+				}
 				resolvedObj := ref.Resolve()
 				arr.Set(idx, resolvedObj)
 				err := resolveReferencesDeep(resolvedObj, depth+1, traversed)
@@ -399,10 +410,26 @@ func flattenObject(obj PdfObject, depth int) PdfObject {
 // Nonetheless, we sometimes get numbers with exponential format, so
 // we will support it in the reader (no confusion with other types, so
 // no compromise).
+//
+// Malformed numbers are normalized: repeated signs are ignored (--5 is -5) and
+// the characters following a second period are ignored (.5. is 0.5).
 func ParseNumber(buf *bufio.Reader) (PdfObject, error) {
+	num, raw, err := parseNumber(buf)
+	if err == nil && raw != "" {
+		common.Log.Debug("WARN: Malformed number %q normalized to %s", raw, num)
+	}
+	return num, err
+}
+
+// parseNumber parses a numeric object from `buf`. Returns the characters of
+// the number read if it is malformed, or an empty string otherwise.
+func parseNumber(buf *bufio.Reader) (PdfObject, string, error) {
 	isFloat := false
 	allowSigns := true
-	var r bytes.Buffer
+	hasPeriod := false
+	truncated := false // Set after a second period: the remaining characters are ignored.
+	malformed := false
+	var r, raw bytes.Buffer
 	for {
 		if common.Log.IsLogLevel(common.LogLevelTrace) {
 			common.Log.Trace("Parsing number \"%s\"", r.String())
@@ -416,28 +443,48 @@ func ParseNumber(buf *bufio.Reader) (PdfObject, error) {
 		}
 		if err != nil {
 			common.Log.Debug("ERROR %s", err)
-			return nil, err
+			return nil, "", err
 		}
-		if allowSigns && (bb[0] == '-' || bb[0] == '+') {
+		c := bb[0]
+		isSign := c == '-' || c == '+'
+		repeatedSign := false
+		if isSign && !allowSigns {
+			// Signs following other signs are ignored (e.g. --5), otherwise
+			// they serve as delimiters.
+			last := r.Bytes()
+			if r.Len() == 0 || (last[len(last)-1] != '-' && last[len(last)-1] != '+') {
+				break
+			}
+			repeatedSign = true
+			malformed = true
+		} else if !isSign && !IsDecimalDigit(c) && c != '.' && c != 'e' && c != 'E' {
+			break
+		}
+		buf.ReadByte()
+		raw.WriteByte(c)
+
+		switch {
+		case truncated, repeatedSign:
+		case isSign:
 			// Only appear in the beginning, otherwise serves as a delimiter.
-			b, _ := buf.ReadByte()
-			r.WriteByte(b)
+			r.WriteByte(c)
 			allowSigns = false // Only allowed in beginning, and after e (exponential).
-		} else if IsDecimalDigit(bb[0]) {
-			b, _ := buf.ReadByte()
-			r.WriteByte(b)
-		} else if bb[0] == '.' {
-			b, _ := buf.ReadByte()
-			r.WriteByte(b)
+		case c == '.':
+			if hasPeriod {
+				truncated = true
+				malformed = true
+				break
+			}
+			r.WriteByte(c)
 			isFloat = true
-		} else if bb[0] == 'e' || bb[0] == 'E' {
+			hasPeriod = true
+		case c == 'e' || c == 'E':
 			// Exponential number format.
-			b, _ := buf.ReadByte()
-			r.WriteByte(b)
+			r.WriteByte(c)
 			isFloat = true
 			allowSigns = true
-		} else {
-			break
+		default:
+			r.WriteByte(c)
 		}
 	}
 
@@ -447,7 +494,7 @@ func ParseNumber(buf *bufio.Reader) (PdfObject, error) {
 		if err != nil {
 			common.Log.Debug("Error parsing number %v err=%v. Using 0.0. Output may be incorrect", r.String(), err)
 			fVal = 0.0
-			err = nil
+			malformed = true
 		}
 
 		objFloat := PdfObjectFloat(fVal)
@@ -457,12 +504,15 @@ func ParseNumber(buf *bufio.Reader) (PdfObject, error) {
 		if err != nil {
 			common.Log.Debug("Error parsing number %v err=%v. Using 0. Output may be incorrect", r.String(), err)
 			intVal = 0
-			err = nil
+			malformed = true
 		}
 
 		objInt := PdfObjectInteger(intVal)
 		o = &objInt
 	}
 
-	return o, nil
+	if !malformed {
+		return o, "", nil
+	}
+	return o, raw.String(), nil
 }
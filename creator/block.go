@@ -44,6 +44,9 @@ type Block struct {
 
 	// Block annotations.
 	annotations []*model.PdfAnnotation
+
+	// Optional content group or membership dictionary of the block contents.
+	optionalContent model.PdfModel
 }
 
 // NewBlock creates a new Block with specified width and height.
@@ -58,46 +61,147 @@ func NewBlock(width float64, height float64) *Block {
 
 // NewBlockFromPage creates a Block from a PDF Page.  Useful for loading template pages as blocks
 // from a PDF document and additional content with the creator.
+// The page contents are wrapped in a form XObject clipped to the crop box of the page, so that
+// content outside of it (e.g. bleed) is not drawn. The block has the size of the crop box of the
+// page as displayed, i.e. the rotation of the page is applied to the block contents.
+// See Creator.NewBlockFromPage for sharing the form XObject between multiple imports of the
+// same page.
 func NewBlockFromPage(page *model.PdfPage) (*Block, error) {
-	b := &Block{}
+	return NewBlockFromPageWithOptions(page, nil)
+}
 
-	content, err := page.GetAllContentStreams()
+// PageBlockOptions defines how a page is imported as a Block.
+type PageBlockOptions struct {
+	// Crop, if set, is the region of the page to import, in the coordinates of the crop box of the
+	// page as displayed, i.e. rotated, with its lower left corner at the origin. The contents
+	// outside of the region are clipped.
+	Crop *model.PdfRectangle
+
+	// Width and Height, if set, are the dimensions the imported region is scaled to. If only one
+	// of them is set, the aspect ratio of the region is maintained.
+	Width  float64
+	Height float64
+}
+
+// NewBlockFromPageWithOptions creates a Block from a PDF Page (see NewBlockFromPage), cropped and
+// scaled as specified by `opts`. Pass nil for the opts parameter in order to import the whole
+// page unscaled.
+func NewBlockFromPageWithOptions(page *model.PdfPage, opts *PageBlockOptions) (*Block, error) {
+	form, err := newPageForm(page)
 	if err != nil {
 		return nil, err
 	}
+	return newBlockFromPageForm(form, opts)
+}
 
-	contentParser := contentstream.NewContentStreamParser(content)
-	operations, err := contentParser.Parse()
+// pageForm is a page converted to a form XObject.
+type pageForm struct {
+	form *model.XObjectForm
+
+	// Dimensions of the crop box of the page as displayed.
+	width, height float64
+}
+
+// newPageForm converts `page` to a form XObject clipped to the crop box of the page. The form
+// matrix maps the crop box, rotated as the page is displayed, to the origin.
+func newPageForm(page *model.PdfPage) (*pageForm, error) {
+	cropBox, err := page.GetCropBox()
 	if err != nil {
 		return nil, err
 	}
-	operations.WrapIfNeeded()
-
-	b.contents = operations
+	box := *cropBox
+	box.Normalize()
+	w, h := box.Width(), box.Height()
+	if w == 0 || h == 0 {
+		return nil, errors.New("empty page crop box")
+	}
 
-	if page.Resources != nil {
-		b.resources = page.Resources
-	} else {
-		b.resources = model.NewPdfPageResources()
+	rotate, err := page.GetRotation()
+	if err != nil {
+		return nil, err
+	}
+	var matrix []float64
+	switch rotate {
+	case 90:
+		matrix = []float64{0, -1, 1, 0, -box.Lly, box.Llx + w}
+	case 180:
+		matrix = []float64{-1, 0, 0, -1, box.Llx + w, box.Lly + h}
+	case 270:
+		matrix = []float64{0, 1, -1, 0, box.Lly + h, -box.Llx}
+	default:
+		matrix = []float64{1, 0, 0, 1, -box.Llx, -box.Lly}
 	}
 
-	mbox, err := page.GetMediaBox()
+	content, err := page.GetAllContentStreams()
 	if err != nil {
 		return nil, err
 	}
 
-	if mbox.Llx != 0 || mbox.Lly != 0 {
-		// Account for media box offset if any.
-		b.translate(-mbox.Llx, mbox.Lly)
+	form := model.NewXObjectForm()
+	form.Resources = page.Resources
+	if form.Resources == nil {
+		form.Resources = model.NewPdfPageResources()
+	}
+	form.BBox = box.ToPdfObject()
+	form.Matrix = core.MakeArrayFromFloats(matrix)
+	form.Group = page.Group
+	if err := form.SetContentStream([]byte(content), core.NewFlateEncoder()); err != nil {
+		return nil, err
+	}
+
+	pf := &pageForm{form: form, width: w, height: h}
+	if rotate == 90 || rotate == 270 {
+		pf.width, pf.height = h, w
+	}
+	return pf, nil
+}
+
+// newBlockFromPageForm returns a Block drawing the page form `pf`, cropped and scaled as
+// specified by `opts`.
+func newBlockFromPageForm(pf *pageForm, opts *PageBlockOptions) (*Block, error) {
+	if opts == nil {
+		opts = &PageBlockOptions{}
+	}
+	crop := model.PdfRectangle{Urx: pf.width, Ury: pf.height}
+	if opts.Crop != nil {
+		crop = *opts.Crop
+		crop.Normalize()
+		if crop.Width() == 0 || crop.Height() == 0 {
+			return nil, errors.New("empty crop region")
+		}
+	}
+	if opts.Width < 0 || opts.Height < 0 {
+		return nil, errors.New("negative block dimensions")
 	}
-	b.width = mbox.Urx - mbox.Llx
-	b.height = mbox.Ury - mbox.Lly
 
-	// Inherit page rotation angle.
-	if page.Rotate != nil {
-		b.angle = -float64(*page.Rotate)
+	sx, sy := 1.0, 1.0
+	switch {
+	case opts.Width > 0 && opts.Height > 0:
+		sx, sy = opts.Width/crop.Width(), opts.Height/crop.Height()
+	case opts.Width > 0:
+		sx = opts.Width / crop.Width()
+		sy = sx
+	case opts.Height > 0:
+		sy = opts.Height / crop.Height()
+		sx = sy
 	}
 
+	b := NewBlock(crop.Width()*sx, crop.Height()*sy)
+	name := core.PdfObjectName("Page")
+	if err := b.resources.SetXObjectFormByName(name, pf.form); err != nil {
+		return nil, err
+	}
+
+	cc := contentstream.NewContentCreator().
+		Add_q().
+		Scale(sx, sy).
+		Add_re(0, 0, crop.Width(), crop.Height()).
+		Add_W().
+		Add_n().
+		Translate(-crop.Llx, -crop.Lly).
+		Add_Do(name).
+		Add_Q()
+	b.contents = cc.Operations()
 	return b, nil
 }
 
@@ -123,6 +227,39 @@ func (blk *Block) AddAnnotation(annotation *model.PdfAnnotation) {
 	blk.annotations = append(blk.annotations, annotation)
 }
 
+// SetOptionalContent marks the contents of the block as optional content
+// belonging to `oc`, which can be either a *model.PdfOptionalContentGroup or
+// a *model.PdfOptionalContentMembership. The contents are wrapped in an
+// optional content marked-content sequence when the block is drawn.
+func (blk *Block) SetOptionalContent(oc model.PdfModel) {
+	blk.optionalContent = oc
+}
+
+// ResourceManager returns a ResourceManager adding resources to the block
+// resources under generated names, reusing the names of the resources added
+// already.
+func (blk *Block) ResourceManager() *model.ResourceManager {
+	return model.NewResourceManager(blk.resources)
+}
+
+// optionalContentName returns the name of the property list of the block
+// optional content in the block resources, adding it if needed.
+func (blk *Block) optionalContentName() (core.PdfObjectName, error) {
+	obj := blk.optionalContent.ToPdfObject()
+	if props, ok := core.GetDict(blk.resources.Properties); ok {
+		for _, key := range props.Keys() {
+			if props.Get(key) == obj {
+				return key, nil
+			}
+		}
+	}
+	name := model.NextOptionalContentName(blk.resources)
+	if err := blk.resources.SetPropertiesByName(name, obj); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
 // duplicate duplicates the block with a new copy of the operations list.
 func (blk *Block) duplicate() *Block {
 	dup := &Block{}
@@ -172,6 +309,18 @@ func (blk *Block) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext, er
 	dup := blk.duplicate()
 	contents := append(*cc.Operations(), *dup.contents...)
 	contents.WrapIfNeeded()
+	if blk.optionalContent != nil {
+		// Wrap the contents in an optional content marked-content sequence.
+		name, err := blk.optionalContentName()
+		if err != nil {
+			return nil, ctx, err
+		}
+		ops := contentstream.NewContentCreator().
+			Add_BDC("OC", core.MakeName(string(name))).
+			Operations()
+		contents = append(*ops, contents...)
+		contents = append(contents, *contentstream.NewContentCreator().Add_EMC().Operations()...)
+	}
 	dup.contents = &contents
 
 	return []*Block{dup}, ctx, nil
@@ -351,6 +500,22 @@ func (blk *Block) Draw(d Drawable) error {
 	return nil
 }
 
+// ToXObjectForm returns a form XObject containing the contents of the block,
+// with a bounding box covering the block dimensions. The block annotations are
+// not included.
+func (blk *Block) ToXObjectForm() (*model.XObjectForm, error) {
+	form := model.NewXObjectForm()
+	form.Resources = blk.resources
+	form.BBox = core.MakeArrayFromFloats([]float64{0, 0, blk.width, blk.height})
+	if blk.optionalContent != nil {
+		form.OC = blk.optionalContent.ToPdfObject()
+	}
+	if err := form.SetContentStream(blk.contents.Bytes(), core.NewFlateEncoder()); err != nil {
+		return nil, err
+	}
+	return form, nil
+}
+
 // DrawWithContext draws the Block using the specified drawing context.
 func (blk *Block) DrawWithContext(d Drawable, ctx DrawContext) error {
 	blocks, _, err := d.GeneratePageBlocks(ctx)
@@ -408,6 +573,7 @@ func mergeContents(contents *contentstream.ContentStreamOperations, resources *m
 	patternMap := map[core.PdfObjectName]core.PdfObjectName{}
 	shadingMap := map[core.PdfObjectName]core.PdfObjectName{}
 	gstateMap := map[core.PdfObjectName]core.PdfObjectName{}
+	propertiesMap := map[core.PdfObjectName]core.PdfObjectName{}
 
 	for _, op := range *contentsToAdd {
 		switch op.Operand {
@@ -563,28 +729,57 @@ func mergeContents(contents *contentstream.ContentStreamOperations, resources *m
 			if len(op.Params) == 1 {
 				if name, ok := op.Params[0].(*core.PdfObjectName); ok {
 					if _, processed := gstateMap[*name]; !processed {
+						// Process if not already processed. Equal graphics states share
+						// the same name.
+						useName := *name
+						if gs, found := resourcesToAdd.GetExtGState(*name); found {
+							var err error
+							useName, err = model.NewResourceManager(resources).AddExtGState(gs)
+							if err != nil {
+								return err
+							}
+						} else {
+							common.Log.Debug("ExtGState not found")
+						}
+						gstateMap[*name] = useName
+					}
+
+					useName := gstateMap[*name]
+					op.Params[0] = &useName
+				}
+			}
+		case "BDC":
+			// Marked content property list.
+			if len(op.Params) == 2 {
+				if name, ok := op.Params[1].(*core.PdfObjectName); ok {
+					if _, processed := propertiesMap[*name]; !processed {
 						var useName core.PdfObjectName
 						// Process if not already processed.
-						gs, found := resourcesToAdd.GetExtGState(*name)
+						props, found := resourcesToAdd.GetPropertiesByName(*name)
 						if found {
 							useName = *name
 							i := 1
 							for {
-								gs2, found := resources.GetExtGState(useName)
-								if !found || gs == gs2 {
+								props2, found := resources.GetPropertiesByName(useName)
+								if !found || props == props2 {
 									break
 								}
-								useName = core.PdfObjectName(fmt.Sprintf("GS%d", i))
+								useName = core.PdfObjectName(fmt.Sprintf("MC%d", i))
 								i++
 							}
-						}
 
-						resources.AddExtGState(useName, gs)
-						gstateMap[*name] = useName
+							if err := resources.SetPropertiesByName(useName, props); err != nil {
+								return err
+							}
+							propertiesMap[*name] = useName
+						} else {
+							common.Log.Debug("Properties not found")
+						}
 					}
 
-					useName := gstateMap[*name]
-					op.Params[0] = &useName
+					if useName, has := propertiesMap[*name]; has {
+						op.Params[1] = &useName
+					}
 				}
 			}
 		}
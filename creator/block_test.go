@@ -0,0 +1,262 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/extractor"
+	"github.com/unidoc/unipdf/v3/internal/pdftest"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+func TestBlockOptionalContent(t *testing.T) {
+	c := New()
+	walls := model.NewPdfOptionalContentGroup("Walls")
+	wiring := model.NewPdfOptionalContentGroup("Wiring")
+	props := model.NewPdfOptionalContentProperties()
+	props.AddGroup(walls, true)
+	props.AddGroup(wiring, false)
+	c.SetOptionalContentProperties(props)
+
+	for i, ocg := range []*model.PdfOptionalContentGroup{walls, wiring} {
+		blk := NewBlock(100, 100)
+		rect := c.NewRectangle(0, 0, 50, 50)
+		rect.SetFillColor(ColorRGBFrom8bit(0, 0, 255))
+		require.NoError(t, blk.Draw(rect))
+		blk.SetOptionalContent(ocg)
+		blk.SetPos(float64(i)*100, 0)
+		require.NoError(t, c.Draw(blk))
+	}
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, c.Write(buf))
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	props, err = reader.GetOptionalContentProperties()
+	require.NoError(t, err)
+	require.NotNil(t, props)
+	require.Len(t, props.OCGs, 2)
+	require.Equal(t, "Walls", props.OCGs[0].Name)
+	require.Equal(t, "Wiring", props.OCGs[1].Name)
+	require.False(t, props.IsVisible(props.OCGs[1]))
+
+	page, err := reader.GetPage(1)
+	require.NoError(t, err)
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+
+	// Each block is wrapped in a marked-content sequence referencing the
+	// property list of its group.
+	for i, name := range []string{"MC0", "MC1"} {
+		bdc := "/OC /" + name + " BDC"
+		require.Equal(t, 1, strings.Count(contents, bdc), contents)
+		tail := contents[strings.Index(contents, bdc):]
+		require.Contains(t, tail, "0 0 1 rg", contents)
+		require.Contains(t, tail, "EMC", contents)
+
+		obj, found := page.Resources.GetPropertiesByName(core.PdfObjectName(name))
+		require.True(t, found)
+		require.Equal(t, props.OCGs[i].GetContainingPdfObject(), core.ResolveReference(obj))
+	}
+}
+
+func TestBlockSharedResources(t *testing.T) {
+	c := New()
+	img, err := c.NewImage(&model.Image{Width: 1, Height: 1, BitsPerComponent: 8, ColorComponents: 1,
+		Data: []byte{128}})
+	require.NoError(t, err)
+	img.SetOpacity(0.5)
+	opaque, err := c.NewImage(&model.Image{Width: 1, Height: 1, BitsPerComponent: 8, ColorComponents: 1,
+		Data: []byte{0}})
+	require.NoError(t, err)
+
+	// The images and paragraphs drawn many times share their resources.
+	for i := 0; i < 20; i++ {
+		img.SetPos(float64(i)*10, 0)
+		require.NoError(t, c.Draw(img))
+		opaque.SetPos(float64(i)*10, 20)
+		require.NoError(t, c.Draw(opaque))
+		p := c.NewParagraph("text")
+		p.SetPos(float64(i)*10, 40)
+		require.NoError(t, c.Draw(p))
+	}
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, c.Write(buf))
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	page, err := reader.GetPage(1)
+	require.NoError(t, err)
+
+	names := func(obj core.PdfObject) []core.PdfObjectName {
+		dict, ok := core.GetDict(obj)
+		require.True(t, ok)
+		return dict.Keys()
+	}
+	require.Len(t, names(page.Resources.ExtGState), 2)
+	require.Len(t, names(page.Resources.XObject), 2)
+	// The unlicensed watermark adds its own font.
+	require.Contains(t, names(page.Resources.Font), core.PdfObjectName("Font1"))
+	require.NotContains(t, names(page.Resources.Font), core.PdfObjectName("Font2"))
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	require.Equal(t, 40, strings.Count(contents, " gs"))
+}
+
+// newTemplatePage returns a 400 x 600 page with a 300 x 500 crop box offset by 50, the text
+// "Inside" at (100, 100), the text "Bleed" outside of the crop box and an image with the raw
+// data `imageData`.
+func newTemplatePage(t *testing.T, rotate int64, imageData string) *model.PdfPage {
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 400, Ury: 600}
+	page.CropBox = &model.PdfRectangle{Llx: 50, Lly: 50, Urx: 350, Ury: 550}
+	page.Rotate = &rotate
+	font := model.NewStandard14FontMustCompile(model.HelveticaName)
+	require.NoError(t, page.Resources.SetFontByName("F1", font.ToPdfObject()))
+
+	image, err := core.MakeStream([]byte(imageData), nil)
+	require.NoError(t, err)
+	image.Set("Type", core.MakeName("XObject"))
+	image.Set("Subtype", core.MakeName("Image"))
+	image.Set("Width", core.MakeInteger(int64(len(imageData))))
+	image.Set("Height", core.MakeInteger(1))
+	image.Set("BitsPerComponent", core.MakeInteger(8))
+	image.Set("ColorSpace", core.MakeName("DeviceGray"))
+	require.NoError(t, page.Resources.SetXObjectByName("Im1", image))
+
+	content := "BT /F1 12 Tf 100 100 Td (Inside) Tj ET BT /F1 12 Tf 10 10 Td (Bleed) Tj ET " +
+		"q 10 0 0 10 200 200 cm /Im1 Do Q"
+	require.NoError(t, page.SetContentStreams([]string{content}, core.NewRawEncoder()))
+	return page
+}
+
+// wordBBox returns the bounding box of the first character of `word` on `page`.
+func wordBBox(t *testing.T, page *model.PdfPage, word string) model.PdfRectangle {
+	ex, err := extractor.New(page)
+	require.NoError(t, err)
+	pageText, _, _, err := ex.ExtractPageText()
+	require.NoError(t, err)
+	offset := strings.Index(pageText.Text(), word)
+	require.True(t, offset >= 0, "%q not found in %q", word, pageText.Text())
+	for _, mark := range pageText.Marks().Elements() {
+		if mark.Offset == offset {
+			return mark.BBox
+		}
+	}
+	t.Fatalf("no mark for %q", word)
+	return model.PdfRectangle{}
+}
+
+// TestBlockFromPage checks the placement of the blocks created from pages, clipped to the crop
+// boxes of the pages, rotated as the pages are displayed and cropped and scaled.
+func TestBlockFromPage(t *testing.T) {
+	c := New()
+	pageHeight := c.Height()
+
+	// Unrotated page: the origin of the block is the lower left corner of the crop box.
+	blk, err := NewBlockFromPage(newTemplatePage(t, 0, "data"))
+	require.NoError(t, err)
+	require.Equal(t, 300.0, blk.Width())
+	require.Equal(t, 500.0, blk.Height())
+	blk.SetPos(20, 30)
+	require.NoError(t, c.Draw(blk))
+
+	// Page rotated by 90 degrees: (100, 100) is displayed at (50, 250).
+	c.NewPage()
+	blk, err = NewBlockFromPage(newTemplatePage(t, 90, "data"))
+	require.NoError(t, err)
+	require.Equal(t, 500.0, blk.Width())
+	require.Equal(t, 300.0, blk.Height())
+	blk.SetPos(20, 30)
+	require.NoError(t, c.Draw(blk))
+
+	// Cropped region scaled by 2: (100, 100) is at (50, 50) in the region.
+	c.NewPage()
+	blk, err = NewBlockFromPageWithOptions(newTemplatePage(t, 0, "data"), &PageBlockOptions{
+		Crop:  &model.PdfRectangle{Llx: 0, Lly: 0, Urx: 150, Ury: 100},
+		Width: 300,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 300.0, blk.Width())
+	require.Equal(t, 200.0, blk.Height())
+	blk.SetPos(20, 30)
+	require.NoError(t, c.Draw(blk))
+
+	pages := pdftest.Pages(t, pdftest.WriteAndRead(t, c))
+	require.Len(t, pages, 3)
+
+	bbox := wordBBox(t, pages[0], "Inside")
+	require.InDelta(t, 20+50, bbox.Llx, 1, "bbox %+v", bbox)
+	require.InDelta(t, pageHeight-30-500+50, bbox.Lly, 1, "bbox %+v", bbox)
+
+	// The bounding boxes of rotated text are approximate, but start at the text origin.
+	bbox = wordBBox(t, pages[1], "Inside")
+	require.InDelta(t, 20+50, bbox.Llx, 1, "bbox %+v", bbox)
+	require.InDelta(t, pageHeight-30-300+250, bbox.Lly, 1, "bbox %+v", bbox)
+
+	bbox = wordBBox(t, pages[2], "Inside")
+	require.InDelta(t, 20+100, bbox.Llx, 1, "bbox %+v", bbox)
+	require.InDelta(t, pageHeight-30-200+100, bbox.Lly, 1, "bbox %+v", bbox)
+	require.InDelta(t, 24, bbox.Height(), 6, "bbox %+v", bbox)
+
+	// The page contents are clipped to the crop box by the bounding box of the form.
+	for i, page := range pages {
+		obj, xtype := page.Resources.GetXObjectByName("Page")
+		require.Equal(t, model.XObjectTypeForm, xtype, "page %d", i+1)
+		form, err := model.NewXObjectFormFromStream(obj)
+		require.NoError(t, err)
+		bbox, err := model.NewPdfRectangle(*form.BBox.(*core.PdfObjectArray))
+		require.NoError(t, err)
+		require.Equal(t, model.PdfRectangle{Llx: 50, Lly: 50, Urx: 350, Ury: 550}, *bbox)
+	}
+
+	_, err = NewBlockFromPageWithOptions(newTemplatePage(t, 0, "data"), &PageBlockOptions{
+		Crop: &model.PdfRectangle{Urx: 100},
+	})
+	require.Error(t, err)
+}
+
+// TestBlockFromPageShared checks that the page imported by the creator on many pages is embedded
+// once.
+func TestBlockFromPageShared(t *testing.T) {
+	const imageData = "unique letterhead image data"
+	c := New()
+	letterhead := newTemplatePage(t, 0, imageData)
+	for i := 0; i < 100; i++ {
+		c.NewPage()
+		blk, err := c.NewBlockFromPage(letterhead, nil)
+		require.NoError(t, err)
+		blk.SetPos(0, 0)
+		require.NoError(t, c.Draw(blk))
+		p := c.NewParagraph("Letter")
+		p.SetPos(100, 600)
+		require.NoError(t, c.Draw(p))
+	}
+
+	data := pdftest.Write(t, c)
+	pages := pdftest.Pages(t, pdftest.Read(t, data))
+	require.Len(t, pages, 100)
+	require.Equal(t, 1, bytes.Count(data, []byte(imageData)))
+
+	var form *core.PdfObjectStream
+	for i, page := range pages {
+		obj, xtype := page.Resources.GetXObjectByName("Page")
+		require.Equal(t, model.XObjectTypeForm, xtype, "page %d", i+1)
+		if form == nil {
+			form = obj
+		}
+		require.True(t, form == obj, "page %d", i+1)
+	}
+	bbox := wordBBox(t, pages[99], "Inside")
+	require.InDelta(t, 50, bbox.Llx, 1, "bbox %+v", bbox)
+}
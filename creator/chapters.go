@@ -193,11 +193,24 @@ func (chap *Chapter) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 		ctx.Height -= chap.margins.top
 	}
 
+	// The chapter is tagged as a section containing its heading and contents.
+	if ctx.tags != nil {
+		sect := ctx.tags.addElem("Sect")
+		level := chap.level
+		if level > 6 {
+			level = 6
+		}
+		ctx.tags = ctx.tags.withParent(sect).withLeafType(fmt.Sprintf("H%d", level))
+	}
+
 	blocks, c, err := chap.heading.GeneratePageBlocks(ctx)
 	if err != nil {
 		return blocks, ctx, err
 	}
 	ctx = c
+	if ctx.tags != nil {
+		ctx.tags = ctx.tags.withParent(ctx.tags.parent)
+	}
 
 	// Generate chapter title and number.
 	posX := ctx.X
@@ -254,6 +267,7 @@ func (chap *Chapter) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 		// Move back X to same start of line.
 		ctx.X = origCtx.X
 	}
+	ctx.tags = origCtx.tags
 
 	if chap.positioning.isAbsolute() {
 		// If absolute: return original context.
@@ -7,6 +7,7 @@ package creator
 
 import (
 	"errors"
+	"fmt"
 	goimage "image"
 	"io"
 	"os"
@@ -66,15 +67,35 @@ type Creator struct {
 	// Page labels.
 	pageLabels core.PdfObject
 
+	// Optional content properties.
+	ocProperties *model.PdfOptionalContentProperties
+
+	// Display settings applied when the document is opened.
+	pageLayout        model.PdfPageLayout
+	pageMode          model.PdfPageMode
+	viewerPreferences *model.PdfViewerPreferences
+	openPage          int
+	openZoom          *float64
+	openAction        *model.PdfAction
+
+	// Natural language of the document.
+	language string
+
 	// Optimizer.
 	optimizer model.Optimizer
 
+	// Logger of the creator and of its writer, see SetLogger.
+	logger common.StructuredLogger
+
 	// Fonts that have been enabled for subsetting prior to write.
 	subsetFonts []*model.PdfFont
 
 	// Default fonts used by all components instantiated through the creator.
 	defaultFontRegular *model.PdfFont
 	defaultFontBold    *model.PdfFont
+
+	// Form XObjects of the imported pages, see NewBlockFromPage.
+	pageForms map[*model.PdfPage]*pageForm
 }
 
 // SetForms adds an Acroform to a PDF file.  Sets the specified form for writing.
@@ -166,6 +187,12 @@ func New() *Creator {
 	return c
 }
 
+// SetLogger sets the logger receiving the logs of the creator and of the writer of the document.
+// The global common.Log is used if `logger` is nil, which is the default.
+func (c *Creator) SetLogger(logger common.StructuredLogger) {
+	c.logger = logger
+}
+
 // SetOptimizer sets the optimizer to optimize PDF before writing.
 func (c *Creator) SetOptimizer(optimizer model.Optimizer) {
 	c.optimizer = optimizer
@@ -283,8 +310,7 @@ func (c *Creator) newPage() *model.PdfPage {
 	width := c.pagesize[0]
 	height := c.pagesize[1]
 
-	bbox := model.PdfRectangle{Llx: 0, Lly: 0, Urx: width, Ury: height}
-	page.MediaBox = &bbox
+	page.SetMediaBox(&model.PdfRectangle{Llx: 0, Lly: 0, Urx: width, Ury: height})
 
 	c.pageWidth = width
 	c.pageHeight = height
@@ -418,6 +444,13 @@ func (c *Creator) Finalize() error {
 		}
 	}
 
+	// The structure elements of the front page and of the table of contents
+	// are moved in front of the elements of the other pages.
+	var frontElems int
+	if c.context.tags != nil {
+		frontElems = len(c.context.tags.tags.root.Kids)
+	}
+
 	hasFrontPage := false
 	// Generate the front Page.
 	if c.genFrontPageFunc != nil {
@@ -475,6 +508,12 @@ func (c *Creator) Finalize() error {
 		}
 	}
 
+	if tags := c.context.tags; tags != nil {
+		root := tags.tags.root
+		kids := append([]*model.PdfStructElem{}, root.Kids[frontElems:]...)
+		root.Kids = append(kids, root.Kids[:frontElems]...)
+	}
+
 	// Account for the front page and the table of content pages.
 	if c.outline != nil && c.AddOutlines {
 		var adjustOutlineDest func(item *model.OutlineItem)
@@ -539,6 +578,9 @@ func (c *Creator) Finalize() error {
 			}
 			c.drawHeaderFunc(headerBlock, args)
 			headerBlock.SetPos(0, 0)
+			if c.context.tags != nil {
+				markArtifact(headerBlock, paginationArtifact("Header"))
+			}
 
 			if err := c.Draw(headerBlock); err != nil {
 				common.Log.Debug("ERROR: drawing header: %v", err)
@@ -558,6 +600,9 @@ func (c *Creator) Finalize() error {
 			}
 			c.drawFooterFunc(footerBlock, args)
 			footerBlock.SetPos(0, c.pageHeight-footerBlock.height)
+			if c.context.tags != nil {
+				markArtifact(footerBlock, paginationArtifact("Footer"))
+			}
 
 			if err := c.Draw(footerBlock); err != nil {
 				common.Log.Debug("ERROR: drawing footer: %v", err)
@@ -570,6 +615,12 @@ func (c *Creator) Finalize() error {
 		if !ok {
 			continue
 		}
+		if tags := c.context.tags; tags != nil {
+			if err := tags.tags.assignMCIDs(page, block); err != nil {
+				common.Log.Debug("ERROR: tagging page %d contents: %v", idx+1, err)
+				return err
+			}
+		}
 		if err := block.drawToPage(page); err != nil {
 			common.Log.Debug("ERROR: drawing page %d blocks: %v", idx+1, err)
 			return err
@@ -649,6 +700,67 @@ func (c *Creator) Draw(d Drawable) error {
 	return nil
 }
 
+// SetOptionalContentProperties sets the optional content properties (layers)
+// of the PDF file generated by the creator. Blocks can be associated with the
+// optional content groups using Block.SetOptionalContent.
+func (c *Creator) SetOptionalContentProperties(props *model.PdfOptionalContentProperties) {
+	c.ocProperties = props
+}
+
+// SetPageLayout sets the page layout used when the document is opened.
+func (c *Creator) SetPageLayout(layout model.PdfPageLayout) {
+	c.pageLayout = layout
+}
+
+// SetPageMode sets the way the document is displayed when opened, e.g. with
+// the outlines panel visible or in full screen mode.
+func (c *Creator) SetPageMode(mode model.PdfPageMode) {
+	c.pageMode = mode
+}
+
+// SetViewerPreferences sets the viewer preferences of the document.
+func (c *Creator) SetViewerPreferences(prefs *model.PdfViewerPreferences) {
+	c.viewerPreferences = prefs
+}
+
+// SetOpenPage sets the page (1-based) displayed when the document is opened,
+// at the specified `zoom` factor. A zoom of 0 fits the page in the window.
+// Replaces any action set using SetOpenAction.
+func (c *Creator) SetOpenPage(pageNum int, zoom float64) {
+	c.openPage = pageNum
+	c.openZoom = nil
+	if zoom > 0 {
+		c.openZoom = &zoom
+	}
+	c.openAction = nil
+}
+
+// SetOpenAction sets the action performed when the document is opened.
+// Replaces any page set using SetOpenPage.
+func (c *Creator) SetOpenAction(action *model.PdfAction) {
+	c.openAction = action
+	c.openPage = 0
+}
+
+// SetTagged sets whether the creator generates a tagged document, in which
+// the drawn components are marked with structure elements (headings,
+// paragraphs, tables, lists, figures, etc.) describing the logical structure
+// of the document, as needed by assistive technologies. Decorations, such as
+// lines, shapes, table borders and page headers and footers, are marked as
+// artifacts. Must be called before drawing any components.
+func (c *Creator) SetTagged(tagged bool) {
+	if tagged {
+		c.context.tags = newTagContext()
+	} else {
+		c.context.tags = nil
+	}
+}
+
+// SetLanguage sets the natural language of the document (e.g. "en-US").
+func (c *Creator) SetLanguage(lang string) {
+	c.language = lang
+}
+
 // Write output of creator to io.Writer interface.
 func (c *Creator) Write(ws io.Writer) error {
 	if err := c.Finalize(); err != nil {
@@ -657,6 +769,7 @@ func (c *Creator) Write(ws io.Writer) error {
 
 	pdfWriter := model.NewPdfWriter()
 	pdfWriter.SetOptimizer(c.optimizer)
+	pdfWriter.SetLogger(c.logger)
 
 	// Form fields.
 	if c.acroForm != nil {
@@ -674,6 +787,14 @@ func (c *Creator) Write(ws io.Writer) error {
 		pdfWriter.AddOutlineTree(&c.outline.ToPdfOutline().PdfOutlineTreeNode)
 	}
 
+	// Optional content.
+	if c.ocProperties != nil {
+		if err := pdfWriter.SetOptionalContentProperties(c.ocProperties); err != nil {
+			common.Log.Debug("ERROR: Could not set optional content properties: %v", err)
+			return err
+		}
+	}
+
 	// Page labels.
 	if c.pageLabels != nil {
 		if err := pdfWriter.SetPageLabels(c.pageLabels); err != nil {
@@ -686,7 +807,8 @@ func (c *Creator) Write(ws io.Writer) error {
 		for _, font := range c.subsetFonts {
 			err := font.SubsetRegistered()
 			if err != nil {
-				common.Log.Debug("ERROR: Could not subset font: %v", err)
+				common.StructuredOrGlobal(c.logger).Error("unable to subset font",
+					"font", font.BaseFont(), "error", err)
 				return err
 			}
 		}
@@ -709,6 +831,22 @@ func (c *Creator) Write(ws io.Writer) error {
 		}
 	}
 
+	if err := c.setDisplaySettings(&pdfWriter); err != nil {
+		common.Log.Debug("ERROR: Could not set display settings: %v", err)
+		return err
+	}
+
+	// Structure tree of tagged documents.
+	if tags := c.context.tags; tags != nil {
+		if err := pdfWriter.SetStructTreeRoot(tags.tags.root); err != nil {
+			common.Log.Debug("ERROR: Could not set structure tree: %v", err)
+			return err
+		}
+	}
+	if c.language != "" {
+		pdfWriter.SetLanguage(c.language)
+	}
+
 	err := pdfWriter.Write(ws)
 	if err != nil {
 		return err
@@ -717,6 +855,42 @@ func (c *Creator) Write(ws io.Writer) error {
 	return nil
 }
 
+// setDisplaySettings sets the page layout, page mode, viewer preferences and
+// open action of the document written by `w`.
+func (c *Creator) setDisplaySettings(w *model.PdfWriter) error {
+	if c.pageLayout != "" {
+		if err := w.SetPageLayout(c.pageLayout); err != nil {
+			return err
+		}
+	}
+	if c.pageMode != "" {
+		if err := w.SetPageMode(c.pageMode); err != nil {
+			return err
+		}
+	}
+	if c.viewerPreferences != nil {
+		if err := w.SetViewerPreferences(c.viewerPreferences); err != nil {
+			return err
+		}
+	}
+
+	if c.openAction != nil {
+		return w.SetOpenAction(c.openAction)
+	}
+	if c.openPage != 0 {
+		if c.openPage < 1 || c.openPage > len(c.pages) {
+			return fmt.Errorf("open page out of range: %d", c.openPage)
+		}
+		page := c.pages[c.openPage-1].GetPageAsIndirectObject()
+		dest := model.NewPdfDestinationFit(page)
+		if c.openZoom != nil {
+			dest = model.NewPdfDestinationXYZ(page, nil, nil, c.openZoom)
+		}
+		return w.SetOpenActionDestination(dest)
+	}
+	return nil
+}
+
 // SetPdfWriterAccessFunc sets a PdfWriter access function/hook.
 // Exposes the PdfWriter just prior to writing the PDF.  Can be used to encrypt the output PDF, etc.
 //
@@ -889,3 +1063,23 @@ func (c *Creator) NewImageFromFile(path string) (*Image, error) {
 func (c *Creator) NewImageFromGoImage(goimg goimage.Image) (*Image, error) {
 	return newImageFromGoImage(goimg)
 }
+
+// NewBlockFromPage creates a Block from a PDF Page, cropped and scaled as specified by `opts`
+// (see NewBlockFromPageWithOptions). Pass nil for the opts parameter in order to import the whole
+// page unscaled. The form XObject wrapping the page contents is created once for each page and
+// shared by the blocks, so that the page contents and resources are embedded once in the output,
+// however many times the page is imported and the blocks are drawn.
+func (c *Creator) NewBlockFromPage(page *model.PdfPage, opts *PageBlockOptions) (*Block, error) {
+	pf, ok := c.pageForms[page]
+	if !ok {
+		var err error
+		if pf, err = newPageForm(page); err != nil {
+			return nil, err
+		}
+		if c.pageForms == nil {
+			c.pageForms = map[*model.PdfPage]*pageForm{}
+		}
+		c.pageForms[page] = pf
+	}
+	return newBlockFromPageForm(pf, opts)
+}
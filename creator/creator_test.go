@@ -17,10 +17,12 @@ import (
 	"errors"
 	"fmt"
 	goimage "image"
+	"image/color"
 	"image/png"
 	"io"
 	"io/ioutil"
 	"math"
+	"math/rand"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -32,11 +34,13 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/contentstream"
 	"github.com/unidoc/unipdf/v3/contentstream/draw"
 	"github.com/unidoc/unipdf/v3/core"
 	"github.com/unidoc/unipdf/v3/extractor"
 	"github.com/unidoc/unipdf/v3/model"
 	"github.com/unidoc/unipdf/v3/model/optimize"
+	"github.com/unidoc/unipdf/v3/render"
 )
 
 func init() {
@@ -206,6 +210,67 @@ func TestImageWithCCITTFaxEncoder(t *testing.T) {
 	testWriteAndRender(t, creator, "1_ccitt.pdf")
 }
 
+// TestImageFlatePredictor checks that the images are smaller when encoded with the PNG predictors
+// by default, and that the encoded images are decoded identically.
+func TestImageFlatePredictor(t *testing.T) {
+	encode := func(img *Image, encoder core.StreamEncoder) *core.PdfObjectStream {
+		img.SetEncoder(encoder)
+		require.NoError(t, img.makeXObject())
+
+		stream, ok := img.xobj.ToPdfObject().(*core.PdfObjectStream)
+		require.True(t, ok)
+		data, err := core.DecodeStream(stream)
+		require.NoError(t, err)
+		require.Equal(t, img.img.Data, data)
+		return stream
+	}
+
+	// A photo like image, with smooth gradients and noise.
+	rnd := rand.New(rand.NewSource(1))
+	photo := goimage.NewRGBA(goimage.Rect(0, 0, 300, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			v := 100 + 80*math.Sin(float64(x)/40)*math.Cos(float64(y)/30)
+			photo.Set(x, y, color.RGBA{
+				R: uint8(v + float64(rnd.Intn(6))),
+				G: uint8(v/2 + float64(x)/4 + float64(rnd.Intn(6))),
+				B: uint8(255 - v + float64(rnd.Intn(6))),
+				A: 255,
+			})
+		}
+	}
+	var photoData bytes.Buffer
+	require.NoError(t, png.Encode(&photoData, photo))
+
+	images := map[string][]byte{"photo": photoData.Bytes()}
+	for _, path := range []string{testImageFile1, testImageFile2, testImageFileCCITT} {
+		data, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		images[filepath.Base(path)] = data
+	}
+
+	var sizeNoPredictor, sizePredictor int
+	for name, data := range images {
+		img, err := newImageFromData(data)
+		require.NoError(t, err)
+		noPredictor := encode(img, core.NewFlateEncoder())
+		predictor := encode(img, nil)
+		t.Logf("%s: size without predictor %d, with predictor %d", name, len(noPredictor.Stream), len(predictor.Stream))
+		sizeNoPredictor += len(noPredictor.Stream)
+		sizePredictor += len(predictor.Stream)
+
+		decodeParams, ok := core.GetDict(predictor.Get("DecodeParms"))
+		require.True(t, ok)
+		predictorVal, _ := core.GetIntVal(decodeParams.Get("Predictor"))
+		require.Equal(t, 15, predictorVal)
+
+		if name == "photo" {
+			require.True(t, len(predictor.Stream) < len(noPredictor.Stream)*3/4)
+		}
+	}
+	require.True(t, sizePredictor < sizeNoPredictor*9/10)
+}
+
 func TestShapes1(t *testing.T) {
 	creator := New()
 
@@ -334,6 +399,128 @@ func TestShapesOnBlock(t *testing.T) {
 }
 
 // Test image wrapping between pages when using relative context mode.
+// TestImageSoftMask tests that the alpha channel of the images is stored as a soft mask, from both
+// non-premultiplied and premultiplied sources, and that the images are blended with the page.
+func TestImageSoftMask(t *testing.T) {
+	// Opaque, half transparent, transparent and mostly transparent pixels.
+	nrgba := goimage.NewNRGBA(goimage.Rect(0, 0, 4, 1))
+	nrgba.SetNRGBA(0, 0, color.NRGBA{R: 255, A: 255})
+	nrgba.SetNRGBA(1, 0, color.NRGBA{R: 255, A: 128})
+	nrgba.SetNRGBA(2, 0, color.NRGBA{R: 255, A: 0})
+	nrgba.SetNRGBA(3, 0, color.NRGBA{G: 255, A: 64})
+	var pngData bytes.Buffer
+	require.NoError(t, png.Encode(&pngData, nrgba))
+
+	// The same image with premultiplied colors.
+	rgba := goimage.NewRGBA(goimage.Rect(0, 0, 4, 1))
+	rgba.SetRGBA(0, 0, color.RGBA{R: 255, A: 255})
+	rgba.SetRGBA(1, 0, color.RGBA{R: 128, A: 128})
+	rgba.SetRGBA(2, 0, color.RGBA{R: 0, A: 0})
+	rgba.SetRGBA(3, 0, color.RGBA{G: 64, A: 64})
+
+	newImages := map[string]func(c *Creator) (*Image, error){
+		"png": func(c *Creator) (*Image, error) {
+			return c.NewImageFromData(pngData.Bytes())
+		},
+		"premultiplied": func(c *Creator) (*Image, error) {
+			return c.NewImageFromGoImage(rgba)
+		},
+	}
+	for name, newImage := range newImages {
+		t.Run(name, func(t *testing.T) {
+			c := New()
+			rect := c.NewRectangle(100, 300, 400, 200)
+			rect.SetFillColor(ColorRGBFrom8bit(0, 0, 255))
+			rect.SetBorderWidth(0)
+			require.NoError(t, c.Draw(rect))
+
+			img, err := newImage(c)
+			require.NoError(t, err)
+			img.SetPos(150, 350)
+			img.ScaleToWidth(200)
+			require.NoError(t, c.Draw(img))
+
+			var buf bytes.Buffer
+			require.NoError(t, c.Write(&buf))
+			reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+			page, err := reader.GetPage(1)
+			require.NoError(t, err)
+
+			var ximg *model.XObjectImage
+			xobjects, ok := core.GetDict(page.Resources.XObject)
+			require.True(t, ok)
+			for _, key := range xobjects.Keys() {
+				if ximg, err = page.Resources.GetXObjectImageByName(key); err == nil && ximg != nil {
+					break
+				}
+			}
+			require.NotNil(t, ximg)
+
+			// The colors are not premultiplied.
+			data, err := core.DecodeStream(ximg.ToPdfObject().(*core.PdfObjectStream))
+			require.NoError(t, err)
+			require.Equal(t, []byte{255, 0, 0, 255, 0, 0}, data[:6])
+			require.Equal(t, []byte{0, 255, 0}, data[9:])
+
+			smask, ok := core.GetStream(ximg.SMask)
+			require.True(t, ok)
+			require.Equal(t, "FlateDecode", smask.Get("Filter").String())
+			require.Equal(t, "DeviceGray", smask.Get("ColorSpace").String())
+			require.Equal(t, "8", smask.Get("BitsPerComponent").String())
+			require.Equal(t, "4", smask.Get("Width").String())
+			require.Equal(t, "1", smask.Get("Height").String())
+			alpha, err := core.DecodeStream(smask)
+			require.NoError(t, err)
+			require.Equal(t, []byte{255, 128, 0, 64}, alpha)
+
+			// The image is blended with the blue rectangle.
+			rendered, err := render.NewImageDevice().Render(page)
+			require.NoError(t, err)
+			expected := [][3]int{{255, 0, 0}, {128, 0, 127}, {0, 0, 255}, {0, 64, 191}}
+			for i, rgb := range expected {
+				r, g, b, _ := rendered.At(175+50*i, 375).RGBA()
+				actual := [3]int{int(r >> 8), int(g >> 8), int(b >> 8)}
+				for j := range rgb {
+					d := actual[j] - rgb[j]
+					require.True(t, d >= -3 && d <= 3, "pixel %d: expected %v, got %v", i, rgb, actual)
+				}
+			}
+		})
+	}
+}
+
+// TestImageSoftMaskOmitted tests that no soft mask is added for the fully opaque images, and that
+// the soft masks are Flate encoded whatever the encoder of the image.
+func TestImageSoftMaskOmitted(t *testing.T) {
+	opaque := goimage.NewNRGBA(goimage.Rect(0, 0, 8, 8))
+	for i := range opaque.Pix {
+		opaque.Pix[i] = 255
+	}
+	img, err := newImageFromGoImage(opaque)
+	require.NoError(t, err)
+	require.NoError(t, img.makeXObject())
+	require.Nil(t, img.xobj.SMask)
+
+	// An alpha channel set explicitly is omitted too if it is fully opaque.
+	img.img.SetAlphaData(bytes.Repeat([]byte{255}, 64))
+	require.NoError(t, img.makeXObject())
+	require.Nil(t, img.xobj.SMask)
+
+	img.img.AlphaData()[10] = 100
+	encoder := core.NewDCTEncoder()
+	encoder.Width = 8
+	encoder.Height = 8
+	img.SetEncoder(encoder)
+	require.NoError(t, img.makeXObject())
+	smask, ok := core.GetStream(img.xobj.SMask)
+	require.True(t, ok)
+	require.Equal(t, "FlateDecode", smask.Get("Filter").String())
+	alpha, err := core.DecodeStream(smask)
+	require.NoError(t, err)
+	require.Equal(t, img.img.AlphaData(), alpha)
+}
+
 func TestImageWrapping(t *testing.T) {
 	creator := New()
 
@@ -3067,6 +3254,71 @@ func TestPageLabels(t *testing.T) {
 	require.Equal(t, core.EqualObjects(genPageLabels, pageLabels), true)
 }
 
+func TestDisplaySettings(t *testing.T) {
+	write := func(c *Creator) *model.PdfReader {
+		outBuf := bytes.NewBuffer(nil)
+		require.NoError(t, c.Write(outBuf))
+		reader, err := model.NewPdfReader(bytes.NewReader(outBuf.Bytes()))
+		require.NoError(t, err)
+		return reader
+	}
+
+	c := New()
+	for i := 0; i < 3; i++ {
+		c.NewPage()
+	}
+	yes := true
+	c.SetPageLayout(model.PageLayoutOneColumn)
+	c.SetPageMode(model.PageModeUseOutlines)
+	c.SetViewerPreferences(&model.PdfViewerPreferences{
+		DisplayDocTitle: &yes,
+		Duplex:          model.DuplexSimplex,
+	})
+	c.SetOpenPage(2, 1.5)
+	reader := write(c)
+
+	layout, err := reader.GetPageLayout()
+	require.NoError(t, err)
+	require.Equal(t, model.PageLayoutOneColumn, layout)
+	mode, err := reader.GetPageMode()
+	require.NoError(t, err)
+	require.Equal(t, model.PageModeUseOutlines, mode)
+	prefs, err := reader.GetViewerPreferences()
+	require.NoError(t, err)
+	require.NotNil(t, prefs)
+	require.Equal(t, &yes, prefs.DisplayDocTitle)
+	require.Equal(t, model.DuplexSimplex, prefs.Duplex)
+	require.Nil(t, prefs.HideToolbar)
+	dest, action, err := reader.GetOpenAction()
+	require.NoError(t, err)
+	require.Nil(t, action)
+	require.NotNil(t, dest)
+	require.Equal(t, int64(1), dest.PageIndex)
+	require.NotNil(t, dest.Zoom)
+	require.Equal(t, 1.5, *dest.Zoom)
+
+	// Open action.
+	c = New()
+	c.NewPage()
+	named := model.NewPdfActionNamed()
+	named.N = core.MakeName("Print")
+	c.SetOpenAction(named.PdfAction)
+	dest, action, err = write(c).GetOpenAction()
+	require.NoError(t, err)
+	require.Nil(t, dest)
+	require.NotNil(t, action)
+	require.IsType(t, &model.PdfActionNamed{}, action.GetContext())
+
+	// Invalid settings.
+	c = New()
+	c.NewPage()
+	c.SetOpenPage(2, 0)
+	require.Error(t, c.Write(bytes.NewBuffer(nil)))
+	c.SetPageMode("Invalid")
+	c.SetOpenPage(1, 0)
+	require.Error(t, c.Write(bytes.NewBuffer(nil)))
+}
+
 var errRenderNotSupported = errors.New("rendering pdf is not supported on this system")
 
 // renderPDFToPNGs uses ghostscript (gs) to render specified PDF file into a set of PNG images (one per page).
@@ -3240,3 +3492,109 @@ func copyFile(src, dst string) error {
 	_, err = io.Copy(out, in)
 	return err
 }
+
+func TestTaggedDocument(t *testing.T) {
+	c := New()
+	c.SetTagged(true)
+	c.SetLanguage("en-US")
+	c.DrawHeader(func(block *Block, args HeaderFunctionArgs) {
+		p := c.NewParagraph(fmt.Sprintf("Page %d", args.PageNum))
+		p.SetPos(50, 20)
+		block.Draw(p)
+	})
+
+	chap := c.NewChapter("Introduction")
+	chap.Add(c.NewParagraph("First paragraph."))
+
+	table := c.NewTable(2)
+	table.SetHeaderRows(1, 1)
+	for _, text := range []string{"Name", "Value", "Width", "10"} {
+		cell := table.NewCell()
+		cell.SetBorder(CellBorderSideAll, CellBorderStyleSingle, 1)
+		cell.SetContent(c.NewParagraph(text))
+	}
+	chap.Add(table)
+
+	img, err := c.NewImageFromFile(testImageFile1)
+	require.NoError(t, err)
+	img.SetAltText("Logo")
+	chap.Add(img)
+	require.NoError(t, c.Draw(chap))
+
+	list := c.NewList()
+	_, _, err = list.AddTextItem("Item")
+	require.NoError(t, err)
+	require.NoError(t, c.Draw(list))
+	require.NoError(t, c.Draw(c.NewLine(50, 700, 200, 700)))
+
+	outBuf := bytes.NewBuffer(nil)
+	require.NoError(t, c.Write(outBuf))
+	reader, err := model.NewPdfReader(bytes.NewReader(outBuf.Bytes()))
+	require.NoError(t, err)
+	require.True(t, reader.IsTagged())
+	require.Equal(t, "en-US", reader.GetLanguage())
+
+	root, err := reader.GetStructTreeRoot()
+	require.NoError(t, err)
+	require.NotNil(t, root)
+
+	// Check the structure and collect the MCIDs of the marked content.
+	var describe func(elem *model.PdfStructElem) string
+	var mcids []int
+	describe = func(elem *model.PdfStructElem) string {
+		var kids []string
+		for _, kid := range elem.Kids {
+			if kid.Elem != nil {
+				kids = append(kids, describe(kid.Elem))
+			} else {
+				mcids = append(mcids, kid.MCID)
+			}
+		}
+		if len(kids) == 0 {
+			return elem.Type
+		}
+		return elem.Type + "(" + strings.Join(kids, " ") + ")"
+	}
+	require.Len(t, root.Kids, 2)
+	require.Equal(t,
+		"Sect(H1 P Table(TR(TH(P) TH(P)) TR(TD(P) TD(P))) Figure)",
+		describe(root.Kids[0]))
+	require.Equal(t, "L(LI(Lbl LBody(P)))", describe(root.Kids[1]))
+	sect := root.Kids[0]
+	require.Equal(t, "Logo", sect.Kids[len(sect.Kids)-1].Elem.Alt)
+
+	// Check the marked content of the page.
+	page, err := reader.GetPage(1)
+	require.NoError(t, err)
+	pageDict := page.GetPageDict()
+	_, ok := core.GetIntVal(pageDict.Get("StructParents"))
+	require.True(t, ok)
+
+	content, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	ops, err := contentstream.NewContentStreamParser(content).Parse()
+	require.NoError(t, err)
+	var pageMCIDs []int
+	var artifacts, pagination int
+	for _, op := range *ops {
+		switch op.Operand {
+		case "BDC":
+			tag, _ := core.GetNameVal(op.Params[0])
+			props, _ := core.GetDict(op.Params[1])
+			if tag == "Artifact" {
+				artifacts++
+				pagination++
+				continue
+			}
+			mcid, ok := core.GetIntVal(props.Get("MCID"))
+			require.True(t, ok)
+			pageMCIDs = append(pageMCIDs, mcid)
+		case "BMC":
+			artifacts++
+		}
+	}
+	require.Equal(t, mcids, pageMCIDs)
+	// Header, line and the borders of the table and list cells.
+	require.Equal(t, 1, pagination)
+	require.Equal(t, 8, artifacts)
+}
@@ -67,5 +67,6 @@ func (c *Curve) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext, erro
 	if err != nil {
 		return nil, ctx, err
 	}
+	ctx.tags.markArtifacts([]*Block{block})
 	return []*Block{block}, ctx, nil
 }
@@ -118,6 +118,11 @@ func (div *Division) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 	// Set the inline mode of the division to the context.
 	ctx.Inline = div.inline
 
+	// The components are tagged as content of a division element.
+	if ctx.tags != nil {
+		ctx.tags = ctx.tags.withParent(ctx.tags.addElem("Div"))
+	}
+
 	// Draw.
 	divCtx := ctx
 	tmpCtx := ctx
@@ -181,8 +186,9 @@ func (div *Division) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 		ctx = updCtx
 	}
 
-	// Restore the original inline mode of the context.
+	// Restore the original inline mode and tagging state of the context.
 	ctx.Inline = origCtx.Inline
+	ctx.tags = origCtx.tags
 
 	if div.positioning.isRelative() {
 		// Move back X to same start of line.
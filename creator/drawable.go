@@ -46,4 +46,7 @@ type DrawContext struct {
 
 	// Controls whether the components are stacked horizontally
 	Inline bool
+
+	// Tagging state of tagged documents. Nil if tagging is disabled.
+	tags *tagContext
 }
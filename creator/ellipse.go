@@ -90,5 +90,6 @@ func (ell *Ellipse) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 		return nil, ctx, err
 	}
 
+	ctx.tags.markArtifacts([]*Block{block})
 	return []*Block{block}, ctx, nil
 }
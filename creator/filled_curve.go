@@ -108,5 +108,6 @@ func (fc *FilledCurve) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContex
 	if err != nil {
 		return nil, ctx, err
 	}
+	ctx.tags.markArtifacts([]*Block{block})
 	return []*Block{block}, ctx, nil
 }
@@ -7,7 +7,6 @@ package creator
 
 import (
 	"bytes"
-	"fmt"
 	goimage "image"
 	"os"
 
@@ -53,6 +52,9 @@ type Image struct {
 
 	// Encoder
 	encoder core.StreamEncoder
+
+	// Alternate description of the image in tagged documents.
+	altText string
 }
 
 // newImage create a new image from a unidoc image (model.Image).
@@ -135,6 +137,12 @@ func (img *Image) SetOpacity(opacity float64) {
 	img.opacity = opacity
 }
 
+// SetAltText sets the alternate description of the image, used by
+// assistive technologies in tagged documents (see Creator.SetTagged).
+func (img *Image) SetAltText(text string) {
+	img.altText = text
+}
+
 // GetHorizontalAlignment returns the horizontal alignment of the image.
 func (img *Image) GetHorizontalAlignment() HorizontalAlignment {
 	return img.hAlignment
@@ -169,8 +177,10 @@ func (img *Image) ConvertToBinary() error {
 func (img *Image) makeXObject() error {
 	encoder := img.encoder
 	if encoder == nil {
-		// Default: Use flate encoder.
-		encoder = core.NewFlateEncoder()
+		// Default: Use flate encoder, with the PNG predictors chosen per row.
+		flate := core.NewFlateEncoder()
+		flate.Predictor = 15
+		encoder = flate
 	}
 
 	// Create the XObject image.
@@ -233,6 +243,9 @@ func (img *Image) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext, er
 	}
 
 	blocks = append(blocks, blk)
+	if elem := ctx.tags.tagContents(blocks, "Figure"); elem != nil && !ctx.tags.inline {
+		elem.Alt = img.altText
+	}
 
 	if img.positioning.isAbsolute() {
 		// Absolute drawing should not affect context.
@@ -314,37 +327,23 @@ func (img *Image) rotatedSize() (float64, float64) {
 func drawImageOnBlock(blk *Block, img *Image, ctx DrawContext) (DrawContext, error) {
 	origCtx := ctx
 
-	// Find a free name for the image.
-	num := 1
-	imgName := core.PdfObjectName(fmt.Sprintf("Img%d", num))
-	for blk.resources.HasXObjectByName(imgName) {
-		num++
-		imgName = core.PdfObjectName(fmt.Sprintf("Img%d", num))
+	// Add the image to the Page resources.
+	rm := blk.ResourceManager()
+	stream, ok := img.xobj.ToPdfObject().(*core.PdfObjectStream)
+	if !ok {
+		return ctx, core.ErrTypeError
 	}
-
-	// Add to the Page resources.
-	err := blk.resources.SetXObjectImageByName(imgName, img.xobj)
+	imgName, err := rm.AddXObject(stream)
 	if err != nil {
 		return ctx, err
 	}
 
-	// Find an available GS name.
-	i := 0
-	gsName := core.PdfObjectName(fmt.Sprintf("GS%d", i))
-	for blk.resources.HasExtGState(gsName) {
-		i++
-		gsName = core.PdfObjectName(fmt.Sprintf("GS%d", i))
-	}
-
 	// Graphics state with normal blend mode.
-	gs0 := core.MakeDict()
-	gs0.Set("BM", core.MakeName("Normal"))
+	gs := model.NewPdfExtGState().SetBlendMode("Normal")
 	if img.opacity < 1.0 {
-		gs0.Set("CA", core.MakeFloat(img.opacity))
-		gs0.Set("ca", core.MakeFloat(img.opacity))
+		gs.SetAlpha(img.opacity)
 	}
-
-	err = blk.resources.AddExtGState(gsName, core.MakeIndirectObject(gs0))
+	gsName, err := rm.AddExtGState(core.MakeIndirectObject(gs.ToPdfObject()))
 	if err != nil {
 		return ctx, err
 	}
@@ -0,0 +1,329 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	goimage "image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+
+	"golang.org/x/image/tiff"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// ImageFitMode represents how the images of an ImageDocument are sized on their pages.
+type ImageFitMode int
+
+// Image fit modes.
+const (
+	// ImageFitModeFit scales the images to fit within the page margins, preserving their aspect
+	// ratio.
+	ImageFitModeFit ImageFitMode = iota
+
+	// ImageFitModeFill scales the images to fill the area within the page margins, preserving
+	// their aspect ratio. The parts of the images outside of the margins are clipped.
+	ImageFitModeFill
+
+	// ImageFitModeActualSize draws the images at their size at the document resolution, centered
+	// within the page margins. The parts of the images outside of the margins are clipped.
+	ImageFitModeActualSize
+)
+
+// ImageDocument builds PDF documents with one page per image, e.g. from scanned pages. The images
+// are embedded with a compression suited to their format: the JPEG images are embedded as is,
+// without recompression, the bilevel images are encoded with CCITT group 4 encoding and the other
+// images with Flate encoding and the PNG predictors, in an Indexed colorspace for the palette
+// images. The pages of multi-page TIFF images are added as separate pages, and their CCITT group 4
+// data, e.g. of faxes, are embedded as is.
+type ImageDocument struct {
+	// DPI is the resolution of the images, in pixels per inch, which determines their size on the
+	// pages. If it is 0, the resolution specified by the image files, e.g. by the TIFF resolution
+	// tags, is used, or else the default resolution of 72 DPI.
+	DPI float64
+
+	// PageSize is the size of the pages. If it is not set, each page is sized to its image and its
+	// margins, and FitMode is ignored.
+	PageSize PageSize
+
+	// FitMode is the way the images are sized on the pages of size PageSize.
+	FitMode ImageFitMode
+
+	margins margins
+	images  []imageDocumentImage
+}
+
+// imageDocumentImage is an image of an ImageDocument, with its horizontal and vertical resolutions
+// specified by its image file, or 0 if not specified.
+type imageDocumentImage struct {
+	ximg       *model.XObjectImage
+	xdpi, ydpi float64
+}
+
+// NewImageDocument returns a new ImageDocument, without margins, whose pages are sized to their
+// images.
+func NewImageDocument() *ImageDocument {
+	return &ImageDocument{}
+}
+
+// SetMargins sets the margins of the pages: left, right, top, bottom.
+func (d *ImageDocument) SetMargins(left, right, top, bottom float64) {
+	d.margins = margins{left: left, right: right, top: top, bottom: bottom}
+}
+
+// AddImageFile adds a page for each image of the JPEG, PNG or TIFF file at `path`.
+func (d *ImageDocument) AddImageFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return d.AddImage(data)
+}
+
+// AddImage adds a page for each image of the JPEG, PNG or TIFF image file `data`.
+func (d *ImageDocument) AddImage(data []byte) error {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		ximg, err := newXObjectImageFromJPEG(data)
+		if err != nil {
+			return err
+		}
+		d.images = append(d.images, imageDocumentImage{ximg: ximg})
+	case bytes.HasPrefix(data, []byte("\x89PNG")):
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		return d.addGoImage(img, 0, 0)
+	case bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")):
+		offsets, order, err := tiffPageOffsets(data)
+		if err != nil {
+			return err
+		}
+		// The TIFF decoder decodes the first page, so the pages are decoded from a copy of the
+		// file whose header points to their IFD.
+		page := make([]byte, len(data))
+		copy(page, data)
+		for i, offset := range offsets {
+			ifd, err := readTIFFIFD(data, offset, order)
+			if err != nil {
+				return fmt.Errorf("TIFF page %d: %v", i+1, err)
+			}
+			xdpi, ydpi := ifd.resolution()
+			ximg, err := newXObjectImageFromTIFFFax(data, ifd)
+			if err != nil {
+				return fmt.Errorf("TIFF page %d: %v", i+1, err)
+			}
+			if ximg != nil {
+				d.images = append(d.images, imageDocumentImage{ximg: ximg, xdpi: xdpi, ydpi: ydpi})
+				continue
+			}
+
+			order.PutUint32(page[4:], offset)
+			img, err := tiff.Decode(bytes.NewReader(page))
+			if err != nil {
+				return fmt.Errorf("TIFF page %d: %v", i+1, err)
+			}
+			if err := d.addGoImage(img, xdpi, ydpi); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New("unsupported image format")
+	}
+	return nil
+}
+
+// addGoImage adds a page for image `goimg`, with horizontal and vertical resolutions `xdpi` and
+// `ydpi`, 0 if not specified.
+func (d *ImageDocument) addGoImage(goimg goimage.Image, xdpi, ydpi float64) error {
+	var ximg *model.XObjectImage
+	if bilevel, ok := toBilevel(goimg); ok {
+		img, err := newBilevelXObjectImage(bilevel)
+		if err != nil {
+			return err
+		}
+		ximg = img
+	} else {
+		var img *model.Image
+		var cs model.PdfColorspace
+		var err error
+		if _, ok := goimg.(*goimage.Paletted); ok {
+			var indexed *model.PdfColorspaceSpecialIndexed
+			img, indexed, err = model.NewIndexedImageFromGoImage(goimg, false)
+			cs = indexed
+		} else {
+			img, err = model.ImageHandling.NewImageFromGoImage(goimg)
+		}
+		if err != nil {
+			return err
+		}
+		encoder := core.NewFlateEncoder()
+		encoder.Predictor = 15
+		if ximg, err = model.NewXObjectImageFromImage(img, cs, encoder); err != nil {
+			return err
+		}
+	}
+	d.images = append(d.images, imageDocumentImage{ximg: ximg, xdpi: xdpi, ydpi: ydpi})
+	return nil
+}
+
+// Write writes the document to `w`.
+func (d *ImageDocument) Write(w io.Writer) error {
+	if len(d.images) == 0 {
+		return errors.New("no images")
+	}
+	writer := model.NewPdfWriter()
+	for _, image := range d.images {
+		ximg := image.ximg
+		xdpi, ydpi := d.DPI, d.DPI
+		if xdpi <= 0 {
+			xdpi, ydpi = image.xdpi, image.ydpi
+			if xdpi <= 0 || ydpi <= 0 {
+				xdpi, ydpi = 72, 72
+			}
+		}
+		// Size of the image at the document resolution.
+		imgWidth := float64(*ximg.Width) * 72 / xdpi
+		imgHeight := float64(*ximg.Height) * 72 / ydpi
+
+		pageWidth, pageHeight := d.PageSize[0], d.PageSize[1]
+		fitMode := d.FitMode
+		if pageWidth <= 0 || pageHeight <= 0 {
+			pageWidth = imgWidth + d.margins.left + d.margins.right
+			pageHeight = imgHeight + d.margins.top + d.margins.bottom
+			fitMode = ImageFitModeActualSize
+		}
+		areaWidth := pageWidth - d.margins.left - d.margins.right
+		areaHeight := pageHeight - d.margins.top - d.margins.bottom
+		if areaWidth <= 0 || areaHeight <= 0 {
+			return errors.New("margins larger than the page")
+		}
+
+		scale := 1.0
+		switch fitMode {
+		case ImageFitModeFit:
+			scale = math.Min(areaWidth/imgWidth, areaHeight/imgHeight)
+		case ImageFitModeFill:
+			scale = math.Max(areaWidth/imgWidth, areaHeight/imgHeight)
+		}
+		width, height := imgWidth*scale, imgHeight*scale
+		x := d.margins.left + (areaWidth-width)/2
+		y := d.margins.bottom + (areaHeight-height)/2
+
+		page := model.NewPdfPage()
+		page.MediaBox = &model.PdfRectangle{Urx: pageWidth, Ury: pageHeight}
+		if err := page.Resources.SetXObjectImageByName("Im1", ximg); err != nil {
+			return err
+		}
+		var content bytes.Buffer
+		content.WriteString("q\n")
+		if width > areaWidth+1e-6 || height > areaHeight+1e-6 {
+			fmt.Fprintf(&content, "%.4f %.4f %.4f %.4f re W n\n", d.margins.left, d.margins.bottom,
+				areaWidth, areaHeight)
+		}
+		fmt.Fprintf(&content, "%.4f 0 0 %.4f %.4f %.4f cm\n/Im1 Do\nQ", width, height, x, y)
+		if err := page.AddContentStreamByString(content.String()); err != nil {
+			return err
+		}
+		if err := writer.AddPage(page); err != nil {
+			return err
+		}
+	}
+	return writer.Write(w)
+}
+
+// WriteToFile writes the document to the file at `outputPath`.
+func (d *ImageDocument) WriteToFile(outputPath string) error {
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return d.Write(f)
+}
+
+// newXObjectImageFromJPEG returns an image XObject embedding JPEG image `data` as is.
+func newXObjectImageFromJPEG(data []byte) (*model.XObjectImage, error) {
+	stream := &core.PdfObjectStream{PdfObjectDictionary: core.MakeDict(), Stream: data}
+	stream.Set("Filter", core.MakeName(core.StreamEncodingFilterNameDCT))
+	encoder, err := core.NewEncoderFromStream(stream)
+	if err != nil {
+		return nil, err
+	}
+	dct, ok := encoder.(*core.DCTEncoder)
+	if !ok {
+		return nil, core.ErrTypeError
+	}
+
+	ximg := model.NewXObjectImage()
+	width, height, bpc := int64(dct.Width), int64(dct.Height), int64(dct.BitsPerComponent)
+	ximg.Width = &width
+	ximg.Height = &height
+	ximg.BitsPerComponent = &bpc
+	switch dct.ColorComponents {
+	case 1:
+		ximg.ColorSpace = model.NewPdfColorspaceDeviceGray()
+	case 3:
+		ximg.ColorSpace = model.NewPdfColorspaceDeviceRGB()
+	case 4:
+		ximg.ColorSpace = model.NewPdfColorspaceDeviceCMYK()
+		if dct.AdobeInverted {
+			// The CMYK samples of the JPEG images with the Adobe marker are inverted.
+			ximg.Decode = core.MakeArrayFromIntegers([]int{1, 0, 1, 0, 1, 0, 1, 0})
+		}
+	default:
+		return nil, errors.New("unsupported JPEG color components")
+	}
+	ximg.Filter = dct
+	ximg.Stream = data
+	return ximg, nil
+}
+
+// newBilevelXObjectImage returns an image XObject encoding 1 bit grayscale image `img` with CCITT
+// group 4 encoding.
+func newBilevelXObjectImage(img *model.Image) (*model.XObjectImage, error) {
+	encoder := core.NewCCITTFaxEncoder()
+	encoder.K = -1
+	encoder.Columns = int(img.Width)
+	encoder.Rows = int(img.Height)
+	encoder.BitsPerComponent = 1
+	return model.NewXObjectImageFromImage(img, model.NewPdfColorspaceDeviceGray(), encoder)
+}
+
+// toBilevel returns a 1 bit grayscale image with the pixels of `img` if they are all opaque black
+// or white pixels.
+func toBilevel(img goimage.Image) (*model.Image, bool) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	rowSize := (width + 7) / 8
+	data := make([]byte, rowSize*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			if a != 0xffff || r != g || g != b || r != 0 && r != 0xffff {
+				return nil, false
+			}
+			if r != 0 {
+				data[y*rowSize+x/8] |= 1 << uint(7-x%8)
+			}
+		}
+	}
+	return &model.Image{
+		Width:            int64(width),
+		Height:           int64(height),
+		BitsPerComponent: 1,
+		ColorComponents:  1,
+		Data:             data,
+	}, true
+}
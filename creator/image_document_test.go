@@ -0,0 +1,425 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"bytes"
+	"encoding/binary"
+	goimage "image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/pdftest"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+func TestImageDocumentJPEG(t *testing.T) {
+	photo := goimage.NewRGBA(goimage.Rect(0, 0, 800, 600))
+	for y := 0; y < 600; y++ {
+		for x := 0; x < 800; x++ {
+			v := 128 + 100*math.Sin(float64(x)/30)*math.Cos(float64(y)/20)
+			photo.Set(x, y, color.RGBA{R: uint8(v), G: uint8(x / 4), B: uint8(255 - v), A: 255})
+		}
+	}
+	var jpegData bytes.Buffer
+	require.NoError(t, jpeg.Encode(&jpegData, photo, &jpeg.Options{Quality: 90}))
+
+	d := NewImageDocument()
+	d.DPI = 300
+	require.NoError(t, d.AddImage(jpegData.Bytes()))
+	var buf bytes.Buffer
+	require.NoError(t, d.Write(&buf))
+
+	// The JPEG data is embedded as is.
+	require.True(t, buf.Len() < jpegData.Len()*103/100, "%d bytes for a %d bytes JPEG",
+		buf.Len(), jpegData.Len())
+	pages := pdftest.Pages(t, pdftest.Read(t, buf.Bytes()))
+	require.Len(t, pages, 1)
+	require.Equal(t, model.PdfRectangle{Urx: 192, Ury: 144}, *pages[0].MediaBox)
+
+	ximg, err := pages[0].Resources.GetXObjectImageByName("Im1")
+	require.NoError(t, err)
+	require.Equal(t, core.StreamEncodingFilterNameDCT, ximg.Filter.GetFilterName())
+	require.Equal(t, jpegData.Bytes(), ximg.Stream)
+	require.Equal(t, "DeviceRGB", ximg.ColorSpace.String())
+	require.Equal(t, int64(800), *ximg.Width)
+}
+
+func TestImageDocumentPNG(t *testing.T) {
+	gray := goimage.NewGray(goimage.Rect(0, 0, 300, 200))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i % 300 * 255 / 300)
+	}
+	rgb := goimage.NewNRGBA(goimage.Rect(0, 0, 100, 100))
+	for i := range rgb.Pix {
+		rgb.Pix[i] = uint8(i)
+		if i%4 == 3 {
+			rgb.Pix[i] = 255
+		}
+	}
+	// A scanned page, with black and white pixels only.
+	scan := goimage.NewPaletted(goimage.Rect(0, 0, 200, 100), color.Palette{color.Black, color.White})
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			if (x/10+y/10)%2 == 0 {
+				scan.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+	// A chart, with a few colors.
+	chart := goimage.NewPaletted(goimage.Rect(0, 0, 30, 20),
+		color.Palette{color.White, color.NRGBA{R: 255, A: 255}, color.NRGBA{B: 255, A: 255}})
+	for i := range chart.Pix {
+		chart.Pix[i] = uint8(i % 30 / 10)
+	}
+
+	d := NewImageDocument()
+	for _, img := range []goimage.Image{gray, rgb, scan, chart} {
+		var data bytes.Buffer
+		require.NoError(t, png.Encode(&data, img))
+		require.NoError(t, d.AddImage(data.Bytes()))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, d.Write(&buf))
+	pages := pdftest.Pages(t, pdftest.Read(t, buf.Bytes()))
+	require.Len(t, pages, 4)
+
+	testcases := []struct {
+		filter     string
+		colorspace string
+		bpc        int64
+	}{
+		{core.StreamEncodingFilterNameFlate, "DeviceGray", 8},
+		{core.StreamEncodingFilterNameFlate, "DeviceRGB", 8},
+		{core.StreamEncodingFilterNameCCITTFax, "DeviceGray", 1},
+		{core.StreamEncodingFilterNameFlate, "Indexed", 2},
+	}
+	for i, tcase := range testcases {
+		ximg, err := pages[i].Resources.GetXObjectImageByName("Im1")
+		require.NoError(t, err)
+		require.Equal(t, tcase.filter, ximg.Filter.GetFilterName())
+		require.Equal(t, tcase.colorspace, ximg.ColorSpace.String())
+		require.Equal(t, tcase.bpc, *ximg.BitsPerComponent)
+		if flate, ok := ximg.Filter.(*core.FlateEncoder); ok {
+			require.Equal(t, 15, flate.Predictor)
+		}
+	}
+
+	// The bilevel image is decoded identically.
+	ximg, err := pages[2].Resources.GetXObjectImageByName("Im1")
+	require.NoError(t, err)
+	img, err := ximg.ToImage()
+	require.NoError(t, err)
+	require.Len(t, img.Data, 25*100)
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			white := img.Data[y*25+x/8]>>uint(7-x%8)&1 == 1
+			require.Equal(t, (x/10+y/10)%2 == 0, white, "x=%d y=%d", x, y)
+		}
+	}
+}
+
+func TestImageDocumentTIFF(t *testing.T) {
+	page1 := goimage.NewGray(goimage.Rect(0, 0, 40, 30))
+	page2 := goimage.NewGray(goimage.Rect(0, 0, 20, 50))
+	for i := range page2.Pix {
+		page2.Pix[i] = uint8(i)
+	}
+
+	d := NewImageDocument()
+	require.NoError(t, d.AddImage(makeGrayTIFF(page1, page2)))
+	var buf bytes.Buffer
+	require.NoError(t, d.Write(&buf))
+	pages := pdftest.Pages(t, pdftest.Read(t, buf.Bytes()))
+	require.Len(t, pages, 2)
+	require.Equal(t, model.PdfRectangle{Urx: 40, Ury: 30}, *pages[0].MediaBox)
+	require.Equal(t, model.PdfRectangle{Urx: 20, Ury: 50}, *pages[1].MediaBox)
+
+	// The black page is bilevel.
+	ximg, err := pages[0].Resources.GetXObjectImageByName("Im1")
+	require.NoError(t, err)
+	require.Equal(t, core.StreamEncodingFilterNameCCITTFax, ximg.Filter.GetFilterName())
+	ximg, err = pages[1].Resources.GetXObjectImageByName("Im1")
+	require.NoError(t, err)
+	img, err := ximg.ToImage()
+	require.NoError(t, err)
+	require.Equal(t, page2.Pix, img.Data)
+}
+
+func TestImageDocumentTIFFFax(t *testing.T) {
+	// Fax pages with different patterns, whose white pixels have bit 1.
+	const width, height = 100, 40
+	rowSize := (width + 7) / 8
+	makePage := func(k int) []byte {
+		data := make([]byte, rowSize*height)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				if (x/(k+2)+y/(k+3))%2 == 0 {
+					data[y*rowSize+x/8] |= 1 << uint(7-x%8)
+				}
+			}
+		}
+		return data
+	}
+	// encodeG4 encodes `data` with TIFF group 4 encoding, whose 0 bits are coded as white runs.
+	encodeG4 := func(data []byte, rows int, invert bool) []byte {
+		samples := append([]byte{}, data...)
+		if invert {
+			for i := range samples {
+				samples[i] = ^samples[i]
+			}
+		}
+		encoder := core.NewCCITTFaxEncoder()
+		encoder.K = -1
+		encoder.Columns = width
+		encoder.Rows = rows
+		encoder.BlackIs1 = true
+		encoder.BitsPerComponent = 1
+		encoded, err := encoder.EncodeBytes(samples)
+		require.NoError(t, err)
+		return encoded
+	}
+	entries := func(photometric, rowsPerStrip uint32) []testTIFFEntry {
+		return []testTIFFEntry{
+			{256, 3, []uint32{width}},        // ImageWidth.
+			{257, 3, []uint32{height}},       // ImageLength.
+			{258, 3, []uint32{1}},            // BitsPerSample.
+			{259, 3, []uint32{4}},            // Compression: CCITT group 4.
+			{262, 3, []uint32{photometric}},  // PhotometricInterpretation.
+			{278, 4, []uint32{rowsPerStrip}}, // RowsPerStrip.
+			{282, 5, []uint32{204, 1}},       // XResolution.
+			{283, 5, []uint32{196, 1}},       // YResolution.
+			{296, 3, []uint32{2}},            // ResolutionUnit: inch.
+		}
+	}
+
+	// 3 WhiteIsZero pages, whose G4 data are embedded as is.
+	var pages [][]byte
+	var tiffPages []testTIFFPage
+	for k := 0; k < 3; k++ {
+		page := makePage(k)
+		pages = append(pages, page)
+		tiffPages = append(tiffPages, testTIFFPage{
+			entries: entries(0, height),
+			strips:  [][]byte{encodeG4(page, height, true)},
+		})
+	}
+	// A BlackIsZero page in 2 strips, which is decoded and reencoded.
+	page := makePage(3)
+	pages = append(pages, page)
+	tiffPages = append(tiffPages, testTIFFPage{
+		entries: entries(1, height/2),
+		strips: [][]byte{
+			encodeG4(page[:rowSize*height/2], height/2, false),
+			encodeG4(page[rowSize*height/2:], height/2, false),
+		},
+	})
+
+	d := NewImageDocument()
+	require.NoError(t, d.AddImage(makeTIFF(tiffPages)))
+	var buf bytes.Buffer
+	require.NoError(t, d.Write(&buf))
+	pdfPages := pdftest.Pages(t, pdftest.Read(t, buf.Bytes()))
+	require.Len(t, pdfPages, 4)
+
+	for i, pdfPage := range pdfPages {
+		// The pages are sized with the resolution of the TIFF file.
+		require.InDelta(t, width*72/204.0, pdfPage.MediaBox.Urx, 1e-6)
+		require.InDelta(t, height*72/196.0, pdfPage.MediaBox.Ury, 1e-6)
+
+		ximg, err := pdfPage.Resources.GetXObjectImageByName("Im1")
+		require.NoError(t, err)
+		require.Equal(t, core.StreamEncodingFilterNameCCITTFax, ximg.Filter.GetFilterName())
+		encoder := ximg.Filter.(*core.CCITTFaxEncoder)
+		require.Equal(t, -1, encoder.K)
+		require.Equal(t, width, encoder.Columns)
+		if i < 3 {
+			require.Equal(t, tiffPages[i].strips[0], ximg.Stream, "page %d", i+1)
+			require.False(t, encoder.BlackIs1)
+		}
+
+		img, err := ximg.ToImage()
+		require.NoError(t, err)
+		require.Equal(t, pages[i], img.Data, "page %d", i+1)
+	}
+
+	// The document resolution overrides the resolution of the TIFF file.
+	d = NewImageDocument()
+	d.DPI = 100
+	require.NoError(t, d.AddImage(makeTIFF(tiffPages[:1])))
+	buf.Reset()
+	require.NoError(t, d.Write(&buf))
+	pdfPages = pdftest.Pages(t, pdftest.Read(t, buf.Bytes()))
+	require.Equal(t, model.PdfRectangle{Urx: 72, Ury: 28.8}, *pdfPages[0].MediaBox)
+}
+
+func TestImageDocumentFitModes(t *testing.T) {
+	img := goimage.NewGray(goimage.Rect(0, 0, 400, 200))
+	for i := range img.Pix {
+		img.Pix[i] = 128
+	}
+	var data bytes.Buffer
+	require.NoError(t, png.Encode(&data, img))
+
+	testcases := []struct {
+		mode     ImageFitMode
+		expected string
+	}{
+		{ImageFitModeFit, "q\n500.0000 0 0 250.0000 50.0000 275.0000 cm\n/Im1 Do\nQ"},
+		{ImageFitModeFill, "q\n50.0000 50.0000 500.0000 700.0000 re W n\n" +
+			"1400.0000 0 0 700.0000 -400.0000 50.0000 cm\n/Im1 Do\nQ"},
+		{ImageFitModeActualSize, "q\n400.0000 0 0 200.0000 100.0000 300.0000 cm\n/Im1 Do\nQ"},
+	}
+	for _, tcase := range testcases {
+		d := NewImageDocument()
+		d.PageSize = PageSize{600, 800}
+		d.FitMode = tcase.mode
+		d.SetMargins(50, 50, 50, 50)
+		require.NoError(t, d.AddImage(data.Bytes()))
+		var buf bytes.Buffer
+		require.NoError(t, d.Write(&buf))
+
+		pages := pdftest.Pages(t, pdftest.Read(t, buf.Bytes()))
+		require.Equal(t, model.PdfRectangle{Urx: 600, Ury: 800}, *pages[0].MediaBox)
+		contents, err := pages[0].GetContentStreams()
+		require.NoError(t, err)
+		require.Equal(t, tcase.expected, contents[0])
+	}
+
+	// Without page size, the pages are sized to the images and the margins.
+	d := NewImageDocument()
+	d.DPI = 144
+	d.SetMargins(10, 20, 30, 40)
+	require.NoError(t, d.AddImage(data.Bytes()))
+	var buf bytes.Buffer
+	require.NoError(t, d.Write(&buf))
+	pages := pdftest.Pages(t, pdftest.Read(t, buf.Bytes()))
+	require.Equal(t, model.PdfRectangle{Urx: 230, Ury: 170}, *pages[0].MediaBox)
+}
+
+// makeGrayTIFF returns an uncompressed multi-page TIFF file with `pages`.
+func makeGrayTIFF(pages ...*goimage.Gray) []byte {
+	var tiffPages []testTIFFPage
+	for _, page := range pages {
+		w, h := page.Rect.Dx(), page.Rect.Dy()
+		tiffPages = append(tiffPages, testTIFFPage{
+			entries: []testTIFFEntry{
+				{256, 3, []uint32{uint32(w)}}, // ImageWidth.
+				{257, 3, []uint32{uint32(h)}}, // ImageLength.
+				{258, 3, []uint32{8}},         // BitsPerSample.
+				{259, 3, []uint32{1}},         // Compression: none.
+				{262, 3, []uint32{1}},         // PhotometricInterpretation: BlackIsZero.
+				{277, 3, []uint32{1}},         // SamplesPerPixel.
+				{278, 3, []uint32{uint32(h)}}, // RowsPerStrip.
+			},
+			strips: [][]byte{page.Pix},
+		})
+	}
+	return makeTIFF(tiffPages)
+}
+
+// testTIFFEntry is an IFD entry of a TIFF file written by makeTIFF: its tag, its type and its
+// values, with numerator, denominator pairs for the RATIONAL type. The SHORT entries have one
+// value.
+type testTIFFEntry struct {
+	tag    uint16
+	typ    uint16
+	values []uint32
+}
+
+// testTIFFPage is a page of a TIFF file written by makeTIFF: its IFD entries, to which the strip
+// entries are added, and its strips.
+type testTIFFPage struct {
+	entries []testTIFFEntry
+	strips  [][]byte
+}
+
+// makeTIFF returns a little endian multi-page TIFF file with `pages`.
+func makeTIFF(pages []testTIFFPage) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("II*\x00")
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+	align := func() {
+		if buf.Len()%2 == 1 {
+			buf.WriteByte(0)
+		}
+	}
+
+	// The strips and the values which do not fit in the entries are written first.
+	var ifdEntries [][]testTIFFEntry
+	var ifdValues [][]uint32
+	for _, page := range pages {
+		var offsets, counts []uint32
+		for _, strip := range page.strips {
+			offsets = append(offsets, uint32(buf.Len()))
+			counts = append(counts, uint32(len(strip)))
+			buf.Write(strip)
+			align()
+		}
+		entries := append([]testTIFFEntry{}, page.entries...)
+		entries = append(entries, testTIFFEntry{273, 4, offsets}, testTIFFEntry{279, 4, counts})
+		sort.Slice(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+
+		values := make([]uint32, len(entries))
+		for i, e := range entries {
+			if len(e.values) == 1 {
+				values[i] = e.values[0]
+				continue
+			}
+			values[i] = uint32(buf.Len())
+			for _, v := range e.values {
+				if e.typ == 3 {
+					binary.Write(&buf, binary.LittleEndian, uint16(v))
+				} else {
+					binary.Write(&buf, binary.LittleEndian, v)
+				}
+			}
+			align()
+		}
+		ifdEntries = append(ifdEntries, entries)
+		ifdValues = append(ifdValues, values)
+	}
+
+	ifdOffsets := []uint32{uint32(buf.Len())}
+	for _, entries := range ifdEntries {
+		last := ifdOffsets[len(ifdOffsets)-1]
+		ifdOffsets = append(ifdOffsets, last+uint32(2+12*len(entries)+4))
+	}
+	for i, entries := range ifdEntries {
+		binary.Write(&buf, binary.LittleEndian, uint16(len(entries)))
+		for j, e := range entries {
+			count := len(e.values)
+			if e.typ == 5 {
+				count /= 2
+			}
+			binary.Write(&buf, binary.LittleEndian, e.tag)
+			binary.Write(&buf, binary.LittleEndian, e.typ)
+			binary.Write(&buf, binary.LittleEndian, uint32(count))
+			if count == 1 && e.typ == 3 {
+				binary.Write(&buf, binary.LittleEndian, uint16(ifdValues[i][j]))
+				binary.Write(&buf, binary.LittleEndian, uint16(0))
+			} else {
+				binary.Write(&buf, binary.LittleEndian, ifdValues[i][j])
+			}
+		}
+		next := uint32(0)
+		if i+1 < len(ifdEntries) {
+			next = ifdOffsets[i+1]
+		}
+		binary.Write(&buf, binary.LittleEndian, next)
+	}
+
+	data := buf.Bytes()
+	binary.LittleEndian.PutUint32(data[4:], ifdOffsets[0])
+	return data
+}
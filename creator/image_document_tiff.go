@@ -0,0 +1,283 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// TIFF tags used by the image documents.
+const (
+	tiffTagImageWidth      = 256
+	tiffTagImageLength     = 257
+	tiffTagBitsPerSample   = 258
+	tiffTagCompression     = 259
+	tiffTagPhotometric     = 262
+	tiffTagFillOrder       = 266
+	tiffTagStripOffsets    = 273
+	tiffTagRowsPerStrip    = 278
+	tiffTagStripByteCounts = 279
+	tiffTagXResolution     = 282
+	tiffTagYResolution     = 283
+	tiffTagT4Options       = 292
+	tiffTagT6Options       = 293
+	tiffTagResolutionUnit  = 296
+)
+
+// TIFF compressions of the CCITT encoded images.
+const (
+	tiffCompressionCCITTRLE = 2 // Modified Huffman run length encoding.
+	tiffCompressionG3       = 3
+	tiffCompressionG4       = 4
+)
+
+// tiffIFD represents the entries of an image file directory (IFD) of a TIFF file, describing one
+// page. The values of the RATIONAL entries are stored as numerator, denominator pairs.
+type tiffIFD map[uint16][]uint32
+
+// tiffPageOffsets returns the offsets of the image file directories (IFD) of TIFF file `data`,
+// one per page, and the byte order of the file.
+func tiffPageOffsets(data []byte) ([]uint32, binary.ByteOrder, error) {
+	if len(data) < 8 {
+		return nil, nil, errors.New("invalid TIFF header")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if data[0] == 'M' {
+		order = binary.BigEndian
+	}
+
+	var offsets []uint32
+	visited := make(map[uint32]struct{})
+	for offset := order.Uint32(data[4:]); offset != 0; {
+		if _, ok := visited[offset]; ok {
+			common.Log.Debug("TIFF IFD loop at offset %d", offset)
+			break
+		}
+		visited[offset] = struct{}{}
+		if int64(offset)+2 > int64(len(data)) {
+			return nil, nil, errors.New("invalid TIFF IFD offset")
+		}
+		numEntries := int64(order.Uint16(data[offset:]))
+		next := int64(offset) + 2 + 12*numEntries
+		if next+4 > int64(len(data)) {
+			return nil, nil, errors.New("invalid TIFF IFD")
+		}
+		offsets = append(offsets, offset)
+		offset = order.Uint32(data[next:])
+	}
+	if len(offsets) == 0 {
+		return nil, nil, errors.New("TIFF without images")
+	}
+	return offsets, order, nil
+}
+
+// readTIFFIFD reads the entries of the IFD at `offset` of TIFF file `data` with byte order
+// `order`, validated by tiffPageOffsets. The entries of types other than BYTE, SHORT, LONG and
+// RATIONAL are skipped.
+func readTIFFIFD(data []byte, offset uint32, order binary.ByteOrder) (tiffIFD, error) {
+	ifd := make(tiffIFD)
+	numEntries := int(order.Uint16(data[offset:]))
+	for i := 0; i < numEntries; i++ {
+		entry := data[int(offset)+2+12*i:]
+		tag, typ, count := order.Uint16(entry), order.Uint16(entry[2:]), order.Uint32(entry[4:])
+
+		var size, numValues uint32
+		switch typ {
+		case 1: // BYTE.
+			size, numValues = 1, count
+		case 3: // SHORT.
+			size, numValues = 2, count
+		case 4: // LONG.
+			size, numValues = 4, count
+		case 5: // RATIONAL.
+			size, numValues = 8, 2*count
+		default:
+			continue
+		}
+		if count > uint32(len(data))/size {
+			return nil, fmt.Errorf("invalid TIFF tag %d count %d", tag, count)
+		}
+		valueData := entry[8:12]
+		if size*count > 4 {
+			start := int64(order.Uint32(entry[8:]))
+			if start+int64(size*count) > int64(len(data)) {
+				return nil, fmt.Errorf("invalid TIFF tag %d offset %d", tag, start)
+			}
+			valueData = data[start : start+int64(size*count)]
+		}
+
+		values := make([]uint32, numValues)
+		for j := range values {
+			switch typ {
+			case 1:
+				values[j] = uint32(valueData[j])
+			case 3:
+				values[j] = uint32(order.Uint16(valueData[2*j:]))
+			default:
+				values[j] = order.Uint32(valueData[4*j:])
+			}
+		}
+		ifd[tag] = values
+	}
+	return ifd, nil
+}
+
+// value returns the first value of the entry of `tag`, or `def` if there is none.
+func (ifd tiffIFD) value(tag uint16, def uint32) uint32 {
+	if values := ifd[tag]; len(values) > 0 {
+		return values[0]
+	}
+	return def
+}
+
+// resolution returns the horizontal and vertical resolutions of the page, in pixels per inch, or
+// 0 if they are not specified.
+func (ifd tiffIFD) resolution() (float64, float64) {
+	var scale float64
+	switch ifd.value(tiffTagResolutionUnit, 2) {
+	case 2: // Inch.
+		scale = 1
+	case 3: // Centimeter.
+		scale = 2.54
+	default:
+		return 0, 0
+	}
+	rational := func(tag uint16) float64 {
+		values := ifd[tag]
+		if len(values) < 2 || values[1] == 0 {
+			return 0
+		}
+		return scale * float64(values[0]) / float64(values[1])
+	}
+	xdpi, ydpi := rational(tiffTagXResolution), rational(tiffTagYResolution)
+	if xdpi <= 0 || ydpi <= 0 {
+		return 0, 0
+	}
+	return xdpi, ydpi
+}
+
+// newXObjectImageFromTIFFFax returns an image XObject for the CCITT encoded page of TIFF file
+// `data` described by `ifd`, or nil if the page is not CCITT encoded. The CCITT group 4 data in
+// a single strip are embedded as is, with the matching CCITTFaxDecode parameters. The other
+// pages are decoded and encoded with CCITT group 4 encoding.
+func newXObjectImageFromTIFFFax(data []byte, ifd tiffIFD) (*model.XObjectImage, error) {
+	compression := ifd.value(tiffTagCompression, 1)
+	if compression != tiffCompressionCCITTRLE && compression != tiffCompressionG3 &&
+		compression != tiffCompressionG4 {
+		return nil, nil
+	}
+	width, height := int(ifd.value(tiffTagImageWidth, 0)), int(ifd.value(tiffTagImageLength, 0))
+	if width <= 0 || height <= 0 {
+		return nil, errors.New("invalid TIFF image size")
+	}
+	if bpc := ifd.value(tiffTagBitsPerSample, 1); bpc != 1 {
+		return nil, fmt.Errorf("invalid TIFF CCITT image bits per sample %d", bpc)
+	}
+	offsets, counts := ifd[tiffTagStripOffsets], ifd[tiffTagStripByteCounts]
+	if len(offsets) == 0 || len(offsets) != len(counts) {
+		return nil, errors.New("invalid TIFF strips")
+	}
+	strips := make([][]byte, len(offsets))
+	for i, offset := range offsets {
+		if int64(offset)+int64(counts[i]) > int64(len(data)) {
+			return nil, errors.New("invalid TIFF strip")
+		}
+		strips[i] = data[offset : offset+counts[i]]
+	}
+
+	// The 0 samples are coded as white runs: they are black pixels if the photometric
+	// interpretation is BlackIsZero.
+	blackIs1 := ifd.value(tiffTagPhotometric, 0) == 1
+	lsbFirst := ifd.value(tiffTagFillOrder, 1) == 2
+
+	if compression == tiffCompressionG4 && len(strips) == 1 && !lsbFirst &&
+		ifd.value(tiffTagT6Options, 0)&2 == 0 {
+		encoder := core.NewCCITTFaxEncoder()
+		encoder.K = -1
+		encoder.Columns = width
+		encoder.Rows = height
+		encoder.BlackIs1 = blackIs1
+		encoder.EndOfBlock = false
+
+		ximg := model.NewXObjectImage()
+		w, h, bpc := int64(width), int64(height), int64(1)
+		ximg.Width = &w
+		ximg.Height = &h
+		ximg.BitsPerComponent = &bpc
+		ximg.ColorSpace = model.NewPdfColorspaceDeviceGray()
+		ximg.Filter = encoder
+		ximg.Stream = strips[0]
+		return ximg, nil
+	}
+
+	rowsPerStrip := int(ifd.value(tiffTagRowsPerStrip, uint32(height)))
+	if rowsPerStrip <= 0 || rowsPerStrip > height {
+		rowsPerStrip = height
+	}
+	rowSize := (width + 7) / 8
+	samples := make([]byte, 0, rowSize*height)
+	for i, strip := range strips {
+		rows := height - i*rowsPerStrip
+		if rows <= 0 {
+			break
+		}
+		if rows > rowsPerStrip {
+			rows = rowsPerStrip
+		}
+		if lsbFirst {
+			reversed := make([]byte, len(strip))
+			for j, b := range strip {
+				reversed[j] = bits.Reverse8(b)
+			}
+			strip = reversed
+		}
+
+		decoder := core.NewCCITTFaxEncoder()
+		decoder.Columns = width
+		decoder.Rows = rows
+		decoder.BlackIs1 = blackIs1
+		decoder.EndOfBlock = false
+		switch compression {
+		case tiffCompressionCCITTRLE:
+			decoder.EncodedByteAlign = true
+		case tiffCompressionG3:
+			options := ifd.value(tiffTagT4Options, 0)
+			if options&1 != 0 {
+				decoder.K = 1
+			}
+			decoder.EncodedByteAlign = options&4 != 0
+			decoder.EndOfLine = true
+		default:
+			decoder.K = -1
+		}
+		decoded, err := decoder.DecodeBytes(strip)
+		if err != nil {
+			return nil, fmt.Errorf("TIFF strip %d: %v", i+1, err)
+		}
+		if len(decoded) < rows*rowSize {
+			return nil, fmt.Errorf("TIFF strip %d: missing rows", i+1)
+		}
+		samples = append(samples, decoded[:rows*rowSize]...)
+	}
+	if len(samples) != rowSize*height {
+		return nil, errors.New("TIFF strips missing rows")
+	}
+
+	return newBilevelXObjectImage(&model.Image{
+		Width:            int64(width),
+		Height:           int64(height),
+		BitsPerComponent: 1,
+		ColorComponents:  1,
+		Data:             samples,
+	})
+}
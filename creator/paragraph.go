@@ -7,7 +7,6 @@ package creator
 
 import (
 	"errors"
-	"strconv"
 
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/contentstream"
@@ -324,6 +323,7 @@ func (p *Paragraph) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 	}
 
 	blocks = append(blocks, blk)
+	ctx.tags.tagContents(blocks, "P")
 	if p.positioning.isRelative() {
 		ctx.X -= p.margins.left // Move back.
 		ctx.Width = origContext.Width
@@ -336,16 +336,8 @@ func (p *Paragraph) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 // drawParagraphOnBlock draws Paragraph `p` on Block `blk` at the specified location on the page,
 // adding it to the content stream.
 func drawParagraphOnBlock(blk *Block, p *Paragraph, ctx DrawContext) (DrawContext, error) {
-	// Find a free name for the font.
-	num := 1
-	fontName := core.PdfObjectName("Font" + strconv.Itoa(num))
-	for blk.resources.HasFontByName(fontName) {
-		num++
-		fontName = core.PdfObjectName("Font" + strconv.Itoa(num))
-	}
-
-	// Add to the Page resources.
-	err := blk.resources.SetFontByName(fontName, p.textFont.ToPdfObject())
+	// Add the font to the Page resources.
+	fontName, err := blk.ResourceManager().AddFont(p.textFont.ToPdfObject())
 	if err != nil {
 		return ctx, err
 	}
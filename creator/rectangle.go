@@ -89,5 +89,6 @@ func (rect *Rectangle) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContex
 		return nil, ctx, err
 	}
 
+	ctx.tags.markArtifacts([]*Block{block})
 	return []*Block{block}, ctx, nil
 }
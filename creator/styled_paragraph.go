@@ -7,7 +7,6 @@ package creator
 
 import (
 	"errors"
-	"fmt"
 	"strings"
 	"unicode"
 
@@ -569,6 +568,7 @@ func (p *StyledParagraph) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawCon
 		newCtx.Width = ctx.PageWidth - ctx.Margins.left - ctx.Margins.right - p.margins.left - p.margins.right
 		ctx = newCtx
 	}
+	ctx.tags.tagContents(blocks, "P")
 
 	if p.positioning.isRelative() {
 		ctx.X -= p.margins.left // Move back.
@@ -581,22 +581,12 @@ func (p *StyledParagraph) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawCon
 
 // Draw block on specified location on Page, adding to the content stream.
 func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextChunk, ctx DrawContext) (DrawContext, [][]*TextChunk, error) {
-	// Find first free index for the font resources of the paragraph.
-	num := 1
-	fontName := core.PdfObjectName(fmt.Sprintf("Font%d", num))
-	for blk.resources.HasFontByName(fontName) {
-		num++
-		fontName = core.PdfObjectName(fmt.Sprintf("Font%d", num))
-	}
-
 	// Add default font to the page resources.
-	err := blk.resources.SetFontByName(fontName, p.defaultStyle.Font.ToPdfObject())
+	rm := blk.ResourceManager()
+	defaultFontName, err := rm.AddFont(p.defaultStyle.Font.ToPdfObject())
 	if err != nil {
 		return ctx, nil, err
 	}
-	num++
-
-	defaultFontName := fontName
 	defaultFontSize := p.defaultStyle.FontSize
 
 	// Add the fonts of all chunks to the page resources.
@@ -617,15 +607,12 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 				height = style.FontSize
 			}
 
-			fontName = core.PdfObjectName(fmt.Sprintf("Font%d", num))
-
-			err := blk.resources.SetFontByName(fontName, style.Font.ToPdfObject())
+			fontName, err := rm.AddFont(style.Font.ToPdfObject())
 			if err != nil {
 				return ctx, nil, err
 			}
 
 			fontLine = append(fontLine, fontName)
-			num++
 		}
 
 		// Check if line fits on the current block.
@@ -54,6 +54,10 @@ type Table struct {
 	// Header rows.
 	headerStartRow int
 	headerEndRow   int
+
+	// Specifies whether the table lays out the items of a list, which changes
+	// the structure elements of a tagged document.
+	list bool
 }
 
 // newTable create a new Table with a specified number of columns.
@@ -274,6 +278,18 @@ func (table *Table) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 	ctx.Height = ctx.PageHeight - ctx.Y - ctx.Margins.bottom
 	origHeight := ctx.Height
 
+	// Structure elements of the table and its rows, if tagging is enabled.
+	tags := ctx.tags
+	var tableElem *model.PdfStructElem
+	rowElems := map[int]*model.PdfStructElem{}
+	if tags != nil {
+		if table.list {
+			tableElem = tags.addElem("L")
+		} else {
+			tableElem = tags.addElem("Table")
+		}
+	}
+
 	// Start row keeps track of starting row (wraps to 0 on new page).
 	startrow := 0
 
@@ -377,6 +393,7 @@ func (table *Table) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 			c.X = xrel
 			c.Y = yrel
 			c.Width = w
+			c.tags = nil
 
 			// Mock call to generate page blocks.
 			divBlocks, _, err := div.GeneratePageBlocks(c)
@@ -508,11 +525,17 @@ func (table *Table) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 		border.SetWidthRight(cell.borderWidthRight)
 		border.SetWidthTop(cell.borderWidthTop)
 
-		err := block.Draw(border)
+		err := tags.drawArtifact(block, border)
 		if err != nil {
 			common.Log.Debug("ERROR: %v", err)
 		}
 
+		// The contents of the cell are tagged as content of a cell element,
+		// except for the repeated headers which are artifacts.
+		if tags != nil && !drawingHeaders {
+			ctx.tags = table.cellTags(tags, tableElem, rowElems, cell)
+		}
+
 		if cell.content != nil {
 			cw := cell.content.Width()  // content width.
 			ch := cell.content.Height() // content height.
@@ -592,7 +615,11 @@ func (table *Table) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 				}
 			}
 
-			err := block.DrawWithContext(cell.content, ctx)
+			if drawingHeaders {
+				err = tags.drawArtifactWithContext(block, cell.content, ctx)
+			} else {
+				err = block.DrawWithContext(cell.content, ctx)
+			}
 			if err != nil {
 				common.Log.Debug("ERROR: %v", err)
 			}
@@ -616,6 +643,7 @@ func (table *Table) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 		}
 	}
 	blocks = append(blocks, block)
+	ctx.tags = origCtx.tags
 
 	if table.positioning.isAbsolute() {
 		return blocks, origCtx, nil
@@ -632,6 +660,43 @@ func (table *Table) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 	return blocks, ctx, nil
 }
 
+// cellTags returns the tagging context of the contents of `cell`, adding the
+// structure elements of the cell and of its row, if not already added, to
+// `tableElem`. The items of lists are tagged as list items composed of a
+// label and a body.
+func (table *Table) cellTags(tags *tagContext, tableElem *model.PdfStructElem,
+	rowElems map[int]*model.PdfStructElem, cell *TableCell) *tagContext {
+	rowElem, ok := rowElems[cell.row]
+	if !ok {
+		rowType := "TR"
+		if table.list {
+			rowType = "LI"
+		}
+		rowElem = model.NewPdfStructElem(rowType)
+		tableElem.AddElem(rowElem)
+		rowElems[cell.row] = rowElem
+	}
+
+	if table.list {
+		if cell.col == 1 {
+			lbl := model.NewPdfStructElem("Lbl")
+			rowElem.AddElem(lbl)
+			return tags.withParent(lbl).withInline()
+		}
+		body := model.NewPdfStructElem("LBody")
+		rowElem.AddElem(body)
+		return tags.withParent(body)
+	}
+
+	cellType := "TD"
+	if table.hasHeader && cell.row >= table.headerStartRow && cell.row <= table.headerEndRow {
+		cellType = "TH"
+	}
+	cellElem := model.NewPdfStructElem(cellType)
+	rowElem.AddElem(cellElem)
+	return tags.withParent(cellElem)
+}
+
 // CellBorderStyle defines the table cell's border style.
 type CellBorderStyle int
 
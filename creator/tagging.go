@@ -0,0 +1,245 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// structTags contains the structure tree of a tagged document generated by
+// the creator, along with the marked-content sequences of the structure
+// elements waiting to be assigned an MCID.
+type structTags struct {
+	root *model.PdfStructTreeRoot
+
+	// Property lists of the marked-content sequences of the generated blocks,
+	// mapped to their structure elements. The sequences are assigned an MCID
+	// when the blocks are drawn on the pages.
+	marked map[*core.PdfObjectDictionary]*model.PdfStructElem
+}
+
+// tagContext is the tagging state passed to the components through the draw
+// context. A nil context disables tagging.
+type tagContext struct {
+	tags *structTags
+
+	// Structure element the elements of the components are added to. The
+	// elements are added to the structure tree root if nil.
+	parent *model.PdfStructElem
+
+	// Structure type of the element of the next content component, used
+	// instead of its default type if set.
+	leafType string
+
+	// Mark the contents of the next content component directly as content of
+	// the parent element, without creating an element.
+	inline bool
+}
+
+// newTagContext returns a new tagging context for a new document.
+func newTagContext() *tagContext {
+	return &tagContext{
+		tags: &structTags{
+			root:   model.NewPdfStructTreeRoot(),
+			marked: map[*core.PdfObjectDictionary]*model.PdfStructElem{},
+		},
+	}
+}
+
+// addElem adds a new structure element of the specified type to the parent
+// element of the context.
+func (t *tagContext) addElem(structType string) *model.PdfStructElem {
+	elem := model.NewPdfStructElem(structType)
+	if t.parent != nil {
+		t.parent.AddElem(elem)
+	} else {
+		t.tags.root.Kids = append(t.tags.root.Kids, elem)
+	}
+	return elem
+}
+
+// withParent returns a copy of the context adding the structure elements to
+// `parent`.
+func (t *tagContext) withParent(parent *model.PdfStructElem) *tagContext {
+	if t == nil {
+		return nil
+	}
+	return &tagContext{tags: t.tags, parent: parent}
+}
+
+// withLeafType returns a copy of the context creating an element of the
+// specified type for the next content component.
+func (t *tagContext) withLeafType(structType string) *tagContext {
+	if t == nil {
+		return nil
+	}
+	return &tagContext{tags: t.tags, parent: t.parent, leafType: structType}
+}
+
+// withInline returns a copy of the context marking the contents of the next
+// content component as content of the parent element.
+func (t *tagContext) withInline() *tagContext {
+	if t == nil {
+		return nil
+	}
+	return &tagContext{tags: t.tags, parent: t.parent, inline: true}
+}
+
+// tagContents marks the non-empty contents of `blocks`, generated by a
+// content component, as content of a new structure element of type
+// `structType` (unless overridden by the context). Returns the element, or
+// nil if tagging is disabled.
+func (t *tagContext) tagContents(blocks []*Block, structType string) *model.PdfStructElem {
+	if t == nil {
+		return nil
+	}
+
+	var elem *model.PdfStructElem
+	switch {
+	case t.inline && t.parent != nil:
+		elem = t.parent
+	case t.leafType != "":
+		elem = t.addElem(t.leafType)
+	default:
+		elem = t.addElem(structType)
+	}
+
+	for _, blk := range blocks {
+		if len(*blk.contents) == 0 {
+			continue
+		}
+		props := core.MakeDict()
+		props.Set("MCID", core.MakeInteger(0))
+		t.tags.marked[props] = elem
+		wrapContents(blk, contentstream.NewContentCreator().Add_BDC(core.PdfObjectName(elem.Type), props))
+	}
+	return elem
+}
+
+// markArtifacts marks the contents of `blocks` as artifacts, if tagging is
+// enabled. Artifacts, such as decorations and page headers, are not part of
+// the logical structure of the document.
+func (t *tagContext) markArtifacts(blocks []*Block) {
+	if t == nil {
+		return
+	}
+	for _, blk := range blocks {
+		markArtifact(blk, nil)
+	}
+}
+
+// drawArtifact draws `d` on `blk`, marking the generated contents as an
+// artifact if tagging is enabled.
+func (t *tagContext) drawArtifact(blk *Block, d Drawable) error {
+	if t == nil {
+		return blk.Draw(d)
+	}
+	artifact := NewBlock(blk.width, blk.height)
+	if err := artifact.Draw(d); err != nil {
+		return err
+	}
+	markArtifact(artifact, nil)
+	return blk.mergeBlocks(artifact)
+}
+
+// drawArtifactWithContext draws `d` on `blk` using `ctx`, marking the
+// generated contents as an artifact if tagging is enabled.
+func (t *tagContext) drawArtifactWithContext(blk *Block, d Drawable, ctx DrawContext) error {
+	if t == nil {
+		return blk.DrawWithContext(d, ctx)
+	}
+	ctx.tags = nil
+	artifact := NewBlock(blk.width, blk.height)
+	if err := artifact.DrawWithContext(d, ctx); err != nil {
+		return err
+	}
+	markArtifact(artifact, nil)
+	return blk.mergeBlocks(artifact)
+}
+
+// markArtifact marks the contents of `blk` as an artifact, with the property
+// list `props` if not nil.
+func markArtifact(blk *Block, props *core.PdfObjectDictionary) {
+	cc := contentstream.NewContentCreator()
+	if props != nil {
+		cc.Add_BDC("Artifact", props)
+	} else {
+		cc.Add_BMC("Artifact")
+	}
+	wrapContents(blk, cc)
+}
+
+// paginationArtifact returns the property list of the pagination artifacts
+// of the specified subtype (e.g. "Header" or "Footer").
+func paginationArtifact(subtype string) *core.PdfObjectDictionary {
+	props := core.MakeDict()
+	props.Set("Type", core.MakeName("Pagination"))
+	props.Set("Subtype", core.MakeName(subtype))
+	return props
+}
+
+// wrapContents surrounds the contents of `blk` with the marked-content
+// sequence started by the operations of `begin`.
+func wrapContents(blk *Block, begin *contentstream.ContentCreator) {
+	blk.contents.WrapIfNeeded()
+	contents := append(*begin.Operations(), *blk.contents...)
+	contents = append(contents, *contentstream.NewContentCreator().Add_EMC().Operations()...)
+	blk.contents = &contents
+}
+
+// assignMCIDs assigns MCIDs to the marked-content sequences of the structure
+// elements contained in `blk`, which is drawn on `page`. The MCIDs follow
+// the ones already used in the contents of the page.
+func (s *structTags) assignMCIDs(page *model.PdfPage, blk *Block) error {
+	content, err := page.GetAllContentStreams()
+	if err != nil {
+		return err
+	}
+	ops, err := contentstream.NewContentStreamParser(content).Parse()
+	if err != nil {
+		return err
+	}
+	var mcid int
+	for _, op := range *ops {
+		if op.Operand != "BDC" || len(op.Params) != 2 {
+			continue
+		}
+		if props, ok := core.GetDict(op.Params[1]); ok {
+			if val, ok := core.GetIntVal(props.Get("MCID")); ok && val >= mcid {
+				mcid = val + 1
+			}
+		}
+	}
+
+	pageObj := page.GetPageAsIndirectObject()
+	for i, op := range *blk.contents {
+		if op.Operand != "BDC" || len(op.Params) != 2 {
+			continue
+		}
+		props, ok := op.Params[1].(*core.PdfObjectDictionary)
+		if !ok {
+			continue
+		}
+		elem, ok := s.marked[props]
+		if !ok {
+			continue
+		}
+
+		// The operation is replaced, as the generated contents can be drawn
+		// more than once.
+		marked := core.MakeDict()
+		marked.Set("MCID", core.MakeInteger(int64(mcid)))
+		(*blk.contents)[i] = &contentstream.ContentStreamOperation{
+			Operand: op.Operand,
+			Params:  []core.PdfObject{op.Params[0], marked},
+		}
+		elem.AddMarkedContent(pageObj, mcid)
+		mcid++
+	}
+	return nil
+}
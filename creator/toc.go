@@ -215,6 +215,11 @@ func (t *TOC) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext, error)
 		return blocks, ctx, err
 	}
 
+	// The lines are tagged as items of a table of contents element.
+	if ctx.tags != nil {
+		ctx.tags = ctx.tags.withParent(ctx.tags.addElem("TOC"))
+	}
+
 	// Generate blocks for the table of contents lines.
 	for _, line := range t.lines {
 		linkPage := line.linkPage
@@ -238,6 +243,7 @@ func (t *TOC) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext, error)
 
 		ctx = c
 	}
+	ctx.tags = origCtx.tags
 
 	if t.positioning.isRelative() {
 		// Move back X to same start of line.
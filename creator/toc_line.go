@@ -245,11 +245,15 @@ func (tl *TOCLine) prepareParagraph(sp *StyledParagraph, ctx DrawContext) {
 // if the contents wrap over multiple pages.
 func (tl *TOCLine) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext, error) {
 	origCtx := ctx
+	if ctx.tags != nil {
+		ctx.tags = ctx.tags.withParent(ctx.tags.addElem("TOCI"))
+	}
 
 	blocks, ctx, err := tl.sp.GeneratePageBlocks(ctx)
 	if err != nil {
 		return blocks, ctx, err
 	}
+	ctx.tags = origCtx.tags
 
 	if tl.positioning.isRelative() {
 		// Move back X to same start of line.
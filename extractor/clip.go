@@ -0,0 +1,92 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"math"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// pageClip returns the clip of the page of `e`, its media box, or nil if unknown.
+func (e *Extractor) pageClip() *model.PdfRectangle {
+	if e.mediaBox.Width() == 0 || e.mediaBox.Height() == 0 {
+		return nil
+	}
+	mediaBox := e.mediaBox
+	mediaBox.Normalize()
+	return &mediaBox
+}
+
+// deviceClip returns the clip of graphics state clip `clip`, in the coordinates of a content
+// stream mapped to device coordinates by `parentCTM`, intersected with the device clip
+// `parentClip` of the content stream. nil clips are unbounded.
+func deviceClip(parentCTM transform.Matrix, clip, parentClip *model.PdfRectangle) *model.PdfRectangle {
+	if clip == nil {
+		return parentClip
+	}
+	return intersectClip(parentClip, clip.Transform(parentCTM))
+}
+
+// formClip returns `clip` intersected with the bounding box of XObject Form `xform`, mapped to
+// device coordinates by `formCTM`.
+func formClip(xform *model.XObjectForm, formCTM transform.Matrix, clip *model.PdfRectangle) *model.PdfRectangle {
+	arr, ok := core.GetArray(xform.BBox)
+	if !ok {
+		return clip
+	}
+	bbox, err := model.NewPdfRectangle(*arr)
+	if err != nil {
+		common.Log.Debug("ERROR: invalid form BBox: %v", err)
+		return clip
+	}
+	return intersectClip(clip, bbox.Transform(formCTM))
+}
+
+// isClipped returns true if `bbox` is entirely outside `clip`.
+func isClipped(bbox model.PdfRectangle, clip *model.PdfRectangle) bool {
+	if clip == nil {
+		return false
+	}
+	bbox.Normalize()
+	return bbox.Urx < clip.Llx || bbox.Llx > clip.Urx || bbox.Ury < clip.Lly || bbox.Lly > clip.Ury ||
+		clip.Llx == clip.Urx || clip.Lly == clip.Ury
+}
+
+// intersectClip returns the intersection of `clip` and `bbox`. An empty intersection is
+// represented by an empty rectangle.
+func intersectClip(clip *model.PdfRectangle, bbox model.PdfRectangle) *model.PdfRectangle {
+	if clip != nil {
+		bbox = model.PdfRectangle{
+			Llx: math.Max(clip.Llx, bbox.Llx),
+			Lly: math.Max(clip.Lly, bbox.Lly),
+			Urx: math.Min(clip.Urx, bbox.Urx),
+			Ury: math.Min(clip.Ury, bbox.Ury),
+		}
+		if bbox.Llx > bbox.Urx || bbox.Lly > bbox.Ury {
+			bbox = model.PdfRectangle{Llx: bbox.Llx, Lly: bbox.Lly, Urx: bbox.Llx, Ury: bbox.Lly}
+		}
+	}
+	return &bbox
+}
+
+// toMatrix returns the matrix of the array of 6 numbers `obj`, or the identity matrix if `obj` is
+// not set or invalid.
+func toMatrix(obj core.PdfObject) transform.Matrix {
+	arr, ok := core.GetArray(obj)
+	if !ok {
+		return transform.IdentityMatrix()
+	}
+	vals, err := arr.ToFloat64Array()
+	if err != nil || len(vals) != 6 {
+		common.Log.Debug("ERROR: invalid matrix %s", arr)
+		return transform.IdentityMatrix()
+	}
+	return transform.NewMatrix(vals[0], vals[1], vals[2], vals[3], vals[4], vals[5])
+}
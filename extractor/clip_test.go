@@ -0,0 +1,107 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/testutils"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// clipResources are the resources of the clipping tests: a Courier font F0 and a form Fm0 whose
+// bounding box excludes its text.
+const clipResources = `
+1 0 obj
+<<
+	/Font << /F0 2 0 R >>
+	/XObject << /Fm0 3 0 R >>
+>>
+endobj
+2 0 obj
+<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>
+endobj
+3 0 obj
+<<
+	/Type /XObject /Subtype /Form
+	/BBox [0 0 50 50]
+	/Resources << /Font << /F0 2 0 R >> >>
+	/Length 36
+>>
+stream
+BT /F0 24 Tf 100 100 Td (Form) Tj ET
+endstream
+endobj
+`
+
+// TestDiscardClipped tests that text marks and images outside the clip are discarded with the
+// DiscardClipped option.
+func TestDiscardClipped(t *testing.T) {
+	objMap, err := testutils.ParseIndirectObjects(clipResources)
+	require.NoError(t, err)
+	resourceDict, ok := core.GetDict(objMap[1])
+	require.True(t, ok)
+	// The references of stream dictionaries are not resolved.
+	formResources, ok := core.GetDict(objMap[3].(*core.PdfObjectStream).Get("Resources"))
+	require.True(t, ok)
+	formFonts, ok := core.GetDict(formResources.Get("Font"))
+	require.True(t, ok)
+	formFonts.Set("F0", objMap[2])
+	resources, err := model.NewPdfPageResourcesFromDict(resourceDict)
+	require.NoError(t, err)
+
+	contents := `
+		q
+		0 0 10 10 re W n
+		BT /F0 24 Tf 100 500 Td (Hidden) Tj ET
+		q 10 0 0 10 300 300 cm
+		BI /W 1 /H 1 /CS /G /BPC 8 /F /AHx ID ff> EI
+		Q
+		Q
+		q
+		200 200 m 300 200 l 300 300 l h W n
+		2 0 0 2 0 0 cm
+		/Fm0 Do
+		Q
+		q
+		400 400 100 100 re W* n
+		q 10 0 0 10 450 450 cm
+		BI /W 1 /H 1 /CS /G /BPC 8 /F /AHx ID 00> EI
+		Q
+		Q
+	`
+	mediaBox := model.PdfRectangle{Urx: 612, Ury: 792}
+
+	testCases := []struct {
+		options Options
+		text    []string
+		images  int
+	}{
+		{Options{}, []string{"Hidden", "Form"}, 2},
+		{Options{DiscardClipped: true}, nil, 1},
+	}
+	for _, tc := range testCases {
+		e := Extractor{resources: resources, contents: contents, mediaBox: mediaBox, options: tc.options,
+			formResults: map[string]textResult{}}
+		pageText, _, _, err := e.ExtractPageText()
+		require.NoError(t, err)
+		text := pageText.Text()
+		for _, word := range tc.text {
+			require.Contains(t, text, word, "options=%+v", tc.options)
+		}
+		if len(tc.text) == 0 {
+			require.Empty(t, strings.TrimSpace(text), "options=%+v", tc.options)
+		}
+
+		pageImages, err := e.ExtractPageImages(nil)
+		require.NoError(t, err)
+		require.Len(t, pageImages.Images, tc.images, "options=%+v", tc.options)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+	"github.com/unidoc/unipdf/v3/model/optimize"
+)
+
+// concurrencyFixture returns a PDF file with `numPages` pages sharing their fonts, written with
+// compressed content streams and object streams.
+func concurrencyFixture(t *testing.T, numPages int) []byte {
+	w := model.NewPdfWriter()
+	ttf, err := model.NewPdfFontFromTTFFile("../model/testdata/font/OpenSans-Regular.ttf")
+	require.NoError(t, err)
+	fonts := []*model.PdfFont{model.NewStandard14FontMustCompile(model.HelveticaName), ttf}
+	for i := 0; i < numPages; i++ {
+		page := model.NewPdfPage()
+		page.MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+		for j, font := range fonts {
+			require.NoError(t, page.Resources.SetFontByName(core.PdfObjectName(fmt.Sprintf("F%d", j+1)), font.ToPdfObject()))
+		}
+		var content bytes.Buffer
+		for line := 0; line < 20; line++ {
+			fmt.Fprintf(&content, "BT /F%d 10 Tf 72 %d Td (Page %d line %d) Tj ET\n", line%2+1, 720-line*12, i+1, line+1)
+		}
+		require.NoError(t, page.SetContentStreams([]string{content.String()}, core.NewFlateEncoder()))
+		require.NoError(t, w.AddPage(page))
+	}
+	w.SetOptimizer(optimize.New(optimize.Options{UseObjectStreams: true}))
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	return buf.Bytes()
+}
+
+// pageText returns the text of the page `pageNum` of `reader`.
+func pageText(reader *model.PdfReader, pageNum int) (string, error) {
+	page, err := reader.GetPage(pageNum)
+	if err != nil {
+		return "", err
+	}
+	ex, err := New(page)
+	if err != nil {
+		return "", err
+	}
+	return ex.ExtractText()
+}
+
+// TestConcurrentTextExtraction tests that the pages of a document can be extracted concurrently
+// from a single reader, with the same results as when extracted sequentially. Run with -race.
+func TestConcurrentTextExtraction(t *testing.T) {
+	const numPages = 8
+	data := concurrencyFixture(t, numPages)
+
+	for _, lazy := range []bool{false, true} {
+		reader, err := openPdfReader(bytes.NewReader(data), lazy)
+		require.NoError(t, err)
+		expected := make([]string, numPages)
+		for i := range expected {
+			expected[i], err = pageText(reader, i+1)
+			require.NoError(t, err)
+			require.Contains(t, expected[i], fmt.Sprintf("Page %d line 20", i+1))
+		}
+
+		reader, err = openPdfReader(bytes.NewReader(data), lazy)
+		require.NoError(t, err)
+		texts := make([]string, numPages)
+		errs := make([]error, numPages)
+		var wg sync.WaitGroup
+		for i := 0; i < numPages; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				texts[i], errs[i] = pageText(reader, i+1)
+			}(i)
+		}
+		wg.Wait()
+		require.Equal(t, make([]error, numPages), errs, "lazy: %t", lazy)
+		require.Equal(t, expected, texts, "lazy: %t", lazy)
+	}
+}
@@ -0,0 +1,68 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// DocumentOptions define the options of the extraction of the text of documents.
+type DocumentOptions struct {
+	// Options are the options of the extraction of each page.
+	Options
+
+	// Progress, if set, is called with the number of pages extracted out of the number of pages
+	// of the document (see model.ProgressFunc).
+	Progress model.ProgressFunc
+}
+
+// ExtractDocumentText returns the text of the pages of the document read by `reader`, extracted
+// with the options `opts`, which can be nil for the default options.
+func ExtractDocumentText(reader *model.PdfReader, opts *DocumentOptions) ([]*PageText, error) {
+	return ExtractDocumentTextWithContext(context.Background(), reader, opts)
+}
+
+// ExtractDocumentTextWithContext works like ExtractDocumentText but stops the extraction when
+// `ctx` is cancelled or its deadline is exceeded, returning an error wrapping the error of `ctx`.
+func ExtractDocumentTextWithContext(ctx context.Context, reader *model.PdfReader,
+	opts *DocumentOptions) ([]*PageText, error) {
+	if opts == nil {
+		opts = &DocumentOptions{}
+	}
+	numPages, err := reader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = reader.Logger()
+	}
+	progress := model.NewProgressReporter(opts.Progress, model.ProgressExtracting, int64(numPages), logger)
+	progress.Update(0, 0)
+
+	pages := make([]*PageText, 0, numPages)
+	for i := 1; i <= numPages; i++ {
+		page, err := reader.GetPage(i)
+		if err != nil {
+			return nil, err
+		}
+		e, err := NewWithOptions(page, &opts.Options)
+		if err != nil {
+			return nil, err
+		}
+		pageText, _, _, err := e.ExtractPageTextWithContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", i, err)
+		}
+		pages = append(pages, pageText)
+		progress.Update(int64(i), 0)
+	}
+	progress.Complete(0)
+	return pages, nil
+}
@@ -0,0 +1,45 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// TestExtractDocumentText checks that the text of all the pages is extracted, with the progress
+// reported per page, and that the extraction stops when its context is cancelled.
+func TestExtractDocumentText(t *testing.T) {
+	const numPages = 5
+	reader, err := model.NewPdfReader(bytes.NewReader(concurrencyFixture(t, numPages)))
+	require.NoError(t, err)
+
+	var reports []model.Progress
+	pages, err := ExtractDocumentText(reader, &DocumentOptions{
+		Progress: func(p model.Progress) { reports = append(reports, p) },
+	})
+	require.NoError(t, err)
+	require.Len(t, pages, numPages)
+	for i, page := range pages {
+		require.Contains(t, page.Text(), fmt.Sprintf("Page %d line 20", i+1))
+	}
+	require.Len(t, reports, numPages+1)
+	for i, p := range reports {
+		require.Equal(t, model.Progress{Stage: model.ProgressExtracting, Done: int64(i), Total: numPages}, p)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = ExtractDocumentTextWithContext(ctx, reader, nil)
+	require.True(t, errors.Is(err, context.Canceled), "err=%v", err)
+}
@@ -6,6 +6,11 @@
 package extractor
 
 import (
+	"context"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
 	"github.com/unidoc/unipdf/v3/model"
 )
 
@@ -14,10 +19,14 @@ type Extractor struct {
 	// stream contents and resources for page
 	contents  string
 	resources *model.PdfPageResources
+	mediaBox  model.PdfRectangle
+	options   Options
 
 	// fontCache is a simple LRU cache that is used to prevent redundant constructions of PdfFont's from
 	// PDF objects. NOTE: This is not a conventional glyph cache. It only caches PdfFont's.
-	fontCache map[string]fontEntry
+	// The fonts are keyed by font object, as the same name can refer to different fonts in the
+	// resources of the page and of its forms.
+	fontCache map[core.PdfObject]fontEntry
 
 	// text results from running extractXYText on forms within the page.
 	// TODO(peterwilliams): Cache this map accross all pages in a PDF to speed up processig.
@@ -28,10 +37,51 @@ type Extractor struct {
 
 	// textCount is an incrementing number used to identify XYTest objects.
 	textCount int64
+
+	// glyphHandler is called for each glyph shown when processing glyphs, see ProcessGlyphs.
+	glyphHandler GlyphHandler
+
+	// type3Metrics caches the metrics of the glyphs of Type3 fonts.
+	type3Metrics map[type3GlyphKey]type3MetricsEntry
+
+	// validationStats counts the invalid operations found by the extractions.
+	validationStats contentstream.ValidationStats
+
+	// ctx is checked when processing content streams, see ExtractTextWithContext.
+	ctx context.Context
+
+	// logger receives the logs of the extraction, see Options.Logger.
+	logger common.StructuredLogger
+}
+
+// Options define the options of the extraction of content from PDF pages.
+type Options struct {
+	// DiscardClipped discards the text marks and images whose bounds are entirely outside the
+	// current clipping path, e.g. text hidden by clipping paths or placed outside the page.
+	DiscardClipped bool
+
+	// DiscardInvisible discards the text marks and images that are painted with an effective
+	// alpha of 0, i.e. those drawn with a constant alpha of 0 and images whose soft masks are
+	// entirely transparent.
+	DiscardInvisible bool
+
+	// ValidationPolicy defines how the operations with invalid operands are handled. By default,
+	// they are skipped so that a broken operation does not prevent extracting the rest of the page.
+	ValidationPolicy contentstream.ValidationPolicy
+
+	// Logger receives the logs of the extraction. The logger of the page is used if nil, i.e. the
+	// logger of its reader with the page number as "page" field (see model.PdfPage.Logger).
+	Logger common.StructuredLogger
 }
 
 // New returns an Extractor instance for extracting content from the input PDF page.
 func New(page *model.PdfPage) (*Extractor, error) {
+	return NewWithOptions(page, nil)
+}
+
+// NewWithOptions returns an Extractor instance for extracting content from the input PDF page
+// with `options`. `options` can be nil for the default options.
+func NewWithOptions(page *model.PdfPage, options *Options) (*Extractor, error) {
 	contents, err := page.GetAllContentStreams()
 	if err != nil {
 		return nil, err
@@ -42,11 +92,57 @@ func New(page *model.PdfPage) (*Extractor, error) {
 	// fmt.Printf("%s\n", contents)
 	// fmt.Println("========================= ::: =========================")
 
+	mediaBox, err := page.GetMediaBox()
+	if err != nil {
+		common.Log.Debug("ERROR: page has no MediaBox: %v", err)
+		mediaBox = &model.PdfRectangle{}
+	}
+
 	e := &Extractor{
 		contents:    contents,
 		resources:   page.Resources,
-		fontCache:   map[string]fontEntry{},
+		mediaBox:    *mediaBox,
+		fontCache:   map[core.PdfObject]fontEntry{},
 		formResults: map[string]textResult{},
 	}
+	if options != nil {
+		e.options = *options
+	}
+	e.logger = e.options.Logger
+	if e.logger == nil {
+		e.logger = page.Logger()
+	}
 	return e, nil
 }
+
+// log returns the logger of `e`, see Options.Logger.
+func (e *Extractor) log() common.StructuredLogger {
+	return common.StructuredOrGlobal(e.logger)
+}
+
+// ValidationStats returns the counts of the operations with invalid operands skipped or coerced by
+// the extractions done with `e`.
+func (e *Extractor) ValidationStats() contentstream.ValidationStats {
+	return e.validationStats
+}
+
+// newProcessor returns a processor of `operations` applying the validation policy of `e`.
+func (e *Extractor) newProcessor(operations []*contentstream.ContentStreamOperation) *contentstream.ContentStreamProcessor {
+	processor := contentstream.NewContentStreamProcessor(operations)
+	processor.SetValidationPolicy(e.options.ValidationPolicy)
+	return processor
+}
+
+// process runs `processor` with `resources` and adds its counts of invalid operations to those
+// of `e`.
+func (e *Extractor) process(processor *contentstream.ContentStreamProcessor, resources *model.PdfPageResources) error {
+	ctx := e.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	err := processor.ProcessWithContext(ctx, resources)
+	stats := processor.ValidationStats()
+	e.validationStats.Skipped += stats.Skipped
+	e.validationStats.Coerced += stats.Coerced
+	return err
+}
@@ -0,0 +1,305 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"image/color"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// maxFillLevel is the maximum nesting level of forms and tiling patterns processed when
+// extracting fills, guarding against self-referencing resources.
+const maxFillLevel = 10
+
+// ExtractPageFills returns the areas of the page painted by the path filling operators and by
+// the sh (shading) operator, with their colors, patterns and shadings.
+// The contents of the cells of tiling patterns are processed recursively: their fills are
+// reported once per pattern fill, in the position given by the pattern matrix, not for each tile.
+func (e *Extractor) ExtractPageFills() (*PageFills, error) {
+	ctx := &fillExtractContext{e: e}
+	err := ctx.extractContentStreamFills(e.contents, e.resources, transform.IdentityMatrix(), e.pageClip(), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PageFills{
+		Fills: ctx.fills,
+	}, nil
+}
+
+// PageFills represents the areas painted on a PDF page by filling paths and shadings.
+type PageFills struct {
+	Fills []FillMark
+}
+
+// FillMark represents an area painted on a page with a color, a pattern or a shading.
+type FillMark struct {
+	// BBox is the bounding box of the painted area in device coordinates, clipped by the bounding
+	// box of the current clipping path. The sh operator paints the whole clipping path.
+	BBox model.PdfRectangle
+
+	// Color is the color of the area converted to RGB. For shadings and shading patterns, it is
+	// the average color of the shading. For uncolored tiling patterns, it is the color the
+	// pattern is painted with. It is nil for colored tiling patterns and for colors that cannot
+	// be converted.
+	Color color.Color
+
+	// Pattern is the pattern the area is filled with, or nil.
+	Pattern *model.PdfPattern
+
+	// Shading is the shading the area is painted with, by the sh operator or a shading pattern,
+	// or nil.
+	Shading *model.PdfShading
+
+	// PatternCell is true for the areas filled in the cell of a tiling pattern.
+	PatternCell bool
+
+	shadingCTM transform.Matrix // Maps the shading space of Shading to device coordinates.
+}
+
+// fillExtractContext provides the context for fill extraction content stream processing.
+type fillExtractContext struct {
+	e     *Extractor
+	fills []FillMark
+}
+
+// fillState is the state of the path construction of a content stream.
+type fillState struct {
+	path *model.PdfRectangle // Bounding box of the current path in device coordinates, nil if empty.
+}
+
+// extractContentStreamFills processes `contents` using `resources`. The coordinates of the content
+// are mapped to device coordinates by `baseCTM` and painting is clipped to `parentClip`.
+func (ctx *fillExtractContext) extractContentStreamFills(contents string, resources *model.PdfPageResources,
+	baseCTM transform.Matrix, parentClip *model.PdfRectangle, level int) error {
+	if level > maxFillLevel {
+		common.Log.Debug("ERROR: fills nested too deeply. level=%d", level)
+		return nil
+	}
+
+	cstreamParser := contentstream.NewContentStreamParser(contents)
+	operations, err := cstreamParser.Parse()
+	if err != nil {
+		return err
+	}
+
+	state := &fillState{}
+	processor := ctx.e.newProcessor(*operations)
+	processor.AddHandler(contentstream.HandlerConditionEnumAllOperands, "",
+		func(op *contentstream.ContentStreamOperation, gs contentstream.GraphicsState, resources *model.PdfPageResources) error {
+			gs.CTM = baseCTM.Mult(gs.CTM)
+			gs.Clip = deviceClip(baseCTM, gs.Clip, parentClip)
+			return ctx.processOperand(op, gs, resources, baseCTM, state, level)
+		})
+
+	return ctx.e.process(processor, resources)
+}
+
+// processOperand processes the individual content stream operands for fill extraction.
+// `baseCTM` is the CTM of the start of the content stream, `gs.CTM` maps to device coordinates and
+// `gs.Clip` is in device coordinates.
+func (ctx *fillExtractContext) processOperand(op *contentstream.ContentStreamOperation, gs contentstream.GraphicsState,
+	resources *model.PdfPageResources, baseCTM transform.Matrix, state *fillState, level int) error {
+	switch op.Operand {
+	case "m", "l", "c", "v", "y":
+		// The bounding box of the control points of curves contains the curves.
+		points, err := core.GetNumbersAsFloat(op.Params)
+		if err != nil || len(points)%2 != 0 {
+			common.Log.Debug("ERROR: invalid path operand %s", op)
+			return nil
+		}
+		for i := 0; i < len(points); i += 2 {
+			state.addPoint(gs.CTM, points[i], points[i+1])
+		}
+	case "re":
+		vals, err := core.GetNumbersAsFloat(op.Params)
+		if err != nil || len(vals) != 4 {
+			common.Log.Debug("ERROR: invalid path operand %s", op)
+			return nil
+		}
+		x, y, w, h := vals[0], vals[1], vals[2], vals[3]
+		state.addPoint(gs.CTM, x, y)
+		state.addPoint(gs.CTM, x+w, y)
+		state.addPoint(gs.CTM, x+w, y+h)
+		state.addPoint(gs.CTM, x, y+h)
+	case "f", "F", "f*", "B", "B*", "b", "b*":
+		if state.path != nil {
+			if err := ctx.fillPath(*state.path, gs, resources, baseCTM, level); err != nil {
+				return err
+			}
+		}
+		state.path = nil
+	case "S", "s", "n":
+		state.path = nil
+	case "sh":
+		if len(op.Params) != 1 {
+			common.Log.Debug("ERROR: invalid sh operand %s", op)
+			return nil
+		}
+		name, ok := core.GetName(op.Params[0])
+		if !ok {
+			common.Log.Debug("ERROR: Type")
+			return errTypeCheck
+		}
+		shading, has := resources.GetShadingByName(*name)
+		if !has {
+			common.Log.Debug("ERROR: shading %s not found", *name)
+			return nil
+		}
+		return ctx.paintShading(shading, gs.CTM, nil, gs.Clip)
+	case "Do":
+		if len(op.Params) != 1 {
+			return nil
+		}
+		name, ok := core.GetName(op.Params[0])
+		if !ok {
+			common.Log.Debug("ERROR: Type")
+			return errTypeCheck
+		}
+		if _, xtype := resources.GetXObjectByName(*name); xtype == model.XObjectTypeForm {
+			return ctx.extractFormFills(name, gs, resources, level)
+		}
+	}
+	return nil
+}
+
+// addPoint adds the point (`x`, `y`), transformed by `ctm`, to the current path of `state`.
+func (state *fillState) addPoint(ctm transform.Matrix, x, y float64) {
+	x, y = ctm.Transform(x, y)
+	point := model.PdfRectangle{Llx: x, Lly: y, Urx: x, Ury: y}
+	if state.path == nil {
+		state.path = &point
+		return
+	}
+	*state.path = state.path.Union(point)
+}
+
+// fillPath reports the fill of the path with bounding box `path` with the non-stroking color of
+// `gs`. The cells of tiling patterns are processed recursively.
+func (ctx *fillExtractContext) fillPath(path model.PdfRectangle, gs contentstream.GraphicsState,
+	resources *model.PdfPageResources, baseCTM transform.Matrix, level int) error {
+	pattern := gs.PatternNonStroking
+	if pattern == nil {
+		ctx.fills = append(ctx.fills, FillMark{
+			BBox:  *intersectClip(gs.Clip, path),
+			Color: toRGBColor(gs.ColorspaceNonStroking, gs.ColorNonStroking),
+		})
+		return nil
+	}
+
+	// The pattern matrix maps the pattern space to the default coordinate space of the content
+	// stream the pattern is used in.
+	if pattern.IsShading() {
+		shadingPattern := pattern.GetAsShadingPattern()
+		if shadingPattern.Shading == nil {
+			return nil
+		}
+		patternCTM := baseCTM.Mult(toMatrix(shadingPattern.Matrix))
+		clip := intersectClip(gs.Clip, path)
+		return ctx.paintShading(shadingPattern.Shading, patternCTM, pattern, clip)
+	}
+	if !pattern.IsTiling() {
+		return nil
+	}
+
+	tilingPattern := pattern.GetAsTilingPattern()
+	fill := FillMark{
+		BBox:    *intersectClip(gs.Clip, path),
+		Pattern: pattern,
+	}
+	if !tilingPattern.IsColored() {
+		fill.Color = toRGBColor(gs.ColorspaceNonStroking, gs.ColorNonStroking)
+	}
+	ctx.fills = append(ctx.fills, fill)
+
+	content, err := tilingPattern.GetContentStream()
+	if err != nil {
+		return err
+	}
+	patternResources := tilingPattern.Resources
+	if patternResources == nil {
+		patternResources = resources
+	}
+	patternCTM := baseCTM.Mult(toMatrix(tilingPattern.Matrix))
+	var cellClip *model.PdfRectangle
+	if tilingPattern.BBox != nil {
+		bbox := tilingPattern.BBox.Transform(patternCTM)
+		cellClip = &bbox
+	}
+
+	start := len(ctx.fills)
+	err = ctx.extractContentStreamFills(string(content), patternResources, patternCTM, cellClip, level+1)
+	if err != nil {
+		return err
+	}
+	for i := start; i < len(ctx.fills); i++ {
+		ctx.fills[i].PatternCell = true
+		// The colors of the cells of uncolored patterns are those the patterns are painted with.
+		if !tilingPattern.IsColored() {
+			ctx.fills[i].Color = fill.Color
+		}
+	}
+	return nil
+}
+
+// paintShading reports the area `clip` painted with `shading` by the sh operator or with
+// `pattern`, if set. `ctm` maps the shading space to device coordinates.
+func (ctx *fillExtractContext) paintShading(shading *model.PdfShading, ctm transform.Matrix,
+	pattern *model.PdfPattern, clip *model.PdfRectangle) error {
+	if shading.BBox != nil {
+		clip = intersectClip(clip, shading.BBox.Transform(ctm))
+	}
+	if clip == nil {
+		common.Log.Debug("ERROR: unbounded shading")
+		return nil
+	}
+
+	fill := FillMark{
+		BBox:       *clip,
+		Pattern:    pattern,
+		Shading:    shading,
+		shadingCTM: ctm,
+	}
+	rgb, err := shading.AverageColor()
+	if err != nil {
+		common.Log.Debug("ERROR: could not compute the average color of the shading: %v", err)
+	} else {
+		fill.Color = toRGBColor(model.NewPdfColorspaceDeviceRGB(), rgb)
+	}
+	ctx.fills = append(ctx.fills, fill)
+	return nil
+}
+
+// extractFormFills processes the content stream of XObject Form `name` recursively.
+func (ctx *fillExtractContext) extractFormFills(name *core.PdfObjectName, gs contentstream.GraphicsState,
+	resources *model.PdfPageResources, level int) error {
+	xform, err := resources.GetXObjectFormByName(*name)
+	if err != nil {
+		return err
+	}
+	if xform == nil {
+		return nil
+	}
+
+	formContent, err := xform.GetContentStream()
+	if err != nil {
+		return err
+	}
+
+	formResources := xform.Resources
+	if formResources == nil {
+		formResources = resources
+	}
+
+	formCTM := gs.CTM.Mult(toMatrix(xform.Matrix))
+	clip := formClip(xform, formCTM, gs.Clip)
+	return ctx.extractContentStreamFills(string(formContent), formResources, formCTM, clip, level+1)
+}
@@ -0,0 +1,239 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/testutils"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// fillResources are the resources of the fill extraction tests: a colored tiling pattern P0, an
+// uncolored tiling pattern P1, a shading pattern P2 and an axial shading Sh0 from red to blue.
+const fillResources = `
+1 0 obj
+<<
+	/Pattern << /P0 10 0 R /P1 11 0 R /P2 12 0 R >>
+	/Shading << /Sh0 20 0 R >>
+	/ColorSpace << /CS0 [/Pattern /DeviceRGB] >>
+>>
+endobj
+10 0 obj
+<<
+	/PatternType 1 /PaintType 1 /TilingType 1
+	/BBox [0 0 10 10] /XStep 10 /YStep 10
+	/Matrix [1 0 0 1 10 20]
+	/Resources << >>
+	/Length 22
+>>
+stream
+1 0 0 rg 0 0 5 5 re f
+endstream
+endobj
+11 0 obj
+<<
+	/PatternType 1 /PaintType 2 /TilingType 1
+	/BBox [0 0 4 4] /XStep 4 /YStep 4
+	/Resources << >>
+	/Length 13
+>>
+stream
+0 0 2 2 re f
+endstream
+endobj
+12 0 obj
+<<
+	/PatternType 2
+	/Shading 20 0 R
+	/Matrix [2 0 0 2 0 0]
+>>
+endobj
+20 0 obj
+<<
+	/ShadingType 2
+	/ColorSpace /DeviceRGB
+	/Coords [0 0 1 0]
+	/Function << /FunctionType 2 /Domain [0 1] /C0 [1 0 0] /C1 [0 0 1] /N 1 >>
+>>
+endobj
+`
+
+func TestExtractPageFills(t *testing.T) {
+	objMap, err := testutils.ParseIndirectObjects(fillResources)
+	require.NoError(t, err)
+	resourceDict, ok := core.GetDict(objMap[1])
+	require.True(t, ok)
+	resources, err := model.NewPdfPageResourcesFromDict(resourceDict)
+	require.NoError(t, err)
+
+	// The colors set after filling with patterns are kept.
+	contents := `
+		q
+		/Pattern cs /P0 scn
+		0 0 200 100 re f
+		0 0 1 rg
+		300 300 10 20 re f
+		Q
+		q
+		/CS0 cs 0 1 0 /P1 scn
+		2 0 0 2 0 0 cm
+		0 200 50 50 re f
+		Q
+		q
+		0 0 1 rg
+		1 0 0 1 0 100 cm
+		10 10 20 20 re f
+		/Pattern cs /P2 scn
+		50 400 100 50 re f
+		Q
+		q
+		400 400 100 100 re W n
+		/Sh0 sh
+		Q
+		0.5 g
+		0 50 m 100 50 l 50 30 50 30 10 40 c h f
+		/Sh0 sh
+	`
+	e := Extractor{resources: resources, contents: contents,
+		mediaBox: model.PdfRectangle{Urx: 612, Ury: 792}}
+	pageFills, err := e.ExtractPageFills()
+	require.NoError(t, err)
+
+	red := color.RGBA{R: 255, A: 255}
+	green := color.RGBA{G: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	purple := color.RGBA{R: 128, B: 128, A: 255}
+	gray := color.RGBA{R: 128, G: 128, B: 128, A: 255}
+	p0, _ := resources.GetPatternByName("P0")
+	p1, _ := resources.GetPatternByName("P1")
+	p2, _ := resources.GetPatternByName("P2")
+	expected := []struct {
+		bbox        model.PdfRectangle
+		color       color.Color
+		pattern     *model.PdfPattern
+		shading     bool
+		patternCell bool
+	}{
+		// The tiling pattern cell is mapped by the pattern matrix to the page.
+		{model.PdfRectangle{Llx: 0, Lly: 0, Urx: 200, Ury: 100}, nil, p0, false, false},
+		{model.PdfRectangle{Llx: 10, Lly: 20, Urx: 15, Ury: 25}, red, nil, false, true},
+		{model.PdfRectangle{Llx: 300, Lly: 300, Urx: 310, Ury: 320}, blue, nil, false, false},
+		// The pattern matrix is not affected by the CTM.
+		{model.PdfRectangle{Llx: 0, Lly: 400, Urx: 100, Ury: 500}, green, p1, false, false},
+		{model.PdfRectangle{Llx: 0, Lly: 0, Urx: 2, Ury: 2}, green, nil, false, true},
+		{model.PdfRectangle{Llx: 10, Lly: 110, Urx: 30, Ury: 130}, blue, nil, false, false},
+		{model.PdfRectangle{Llx: 50, Lly: 500, Urx: 150, Ury: 550}, purple, p2, true, false},
+		// The sh operator paints the clip.
+		{model.PdfRectangle{Llx: 400, Lly: 400, Urx: 500, Ury: 500}, purple, nil, true, false},
+		{model.PdfRectangle{Llx: 0, Lly: 30, Urx: 100, Ury: 50}, gray, nil, false, false},
+		{model.PdfRectangle{Llx: 0, Lly: 0, Urx: 612, Ury: 792}, purple, nil, true, false},
+	}
+	require.Len(t, pageFills.Fills, len(expected))
+	for i, exp := range expected {
+		fill := pageFills.Fills[i]
+		require.Equal(t, exp.bbox, fill.BBox, "fill %d", i)
+		require.Equal(t, exp.color, fill.Color, "fill %d", i)
+		require.Equal(t, exp.patternCell, fill.PatternCell, "fill %d", i)
+		require.Equal(t, exp.shading, fill.Shading != nil, "fill %d", i)
+		if exp.pattern == nil {
+			require.Nil(t, fill.Pattern, "fill %d", i)
+		} else {
+			require.NotNil(t, fill.Pattern, "fill %d", i)
+			require.Equal(t, exp.pattern.GetContainingPdfObject(), fill.Pattern.GetContainingPdfObject(), "fill %d", i)
+		}
+	}
+}
+
+// type3Resources are the resources of the Type3 font tests: a Type3 font T3 with glyph space units
+// of 1/100 text space units, whose glyph A is a 80x80 square (d0) and glyph B a 50x100 triangle
+// (d1).
+const type3Resources = `
+1 0 obj
+<< /Font << /T3 2 0 R >> >>
+endobj
+2 0 obj
+<<
+	/Type /Font
+	/Subtype /Type3
+	/FontBBox [0 0 100 100]
+	/FontMatrix [0.01 0 0 0.01 0 0]
+	/CharProcs << /A 3 0 R /B 4 0 R >>
+	/Encoding << /Type /Encoding /Differences [65 /A /B] >>
+	/FirstChar 65
+	/LastChar 66
+	/Widths [100 50]
+>>
+endobj
+3 0 obj
+<< /Length 38 >>
+stream
+100 0 d0 0 0 m 80 0 l 80 80 l 0 80 l f
+endstream
+endobj
+4 0 obj
+<< /Length 42 >>
+stream
+50 0 0 0 50 100 d1 0 0 m 50 0 l 25 100 l f
+endstream
+endobj
+`
+
+// TestType3Glyphs tests that the glyphs of Type3 fonts are extracted as fills and as text marks
+// with the bounding boxes of their glyph descriptions.
+func TestType3Glyphs(t *testing.T) {
+	objMap, err := testutils.ParseIndirectObjects(type3Resources)
+	require.NoError(t, err)
+	resourceDict, ok := core.GetDict(objMap[1])
+	require.True(t, ok)
+	resources, err := model.NewPdfPageResourcesFromDict(resourceDict)
+	require.NoError(t, err)
+
+	contents := "BT /T3 20 Tf 100 500 Td (ABA) Tj 0 -30 Td (B) Tj ET"
+	e := Extractor{resources: resources, contents: contents,
+		mediaBox: model.PdfRectangle{Urx: 612, Ury: 792}}
+
+	// A is 20 points wide and B 10 points wide.
+	expected := []model.PdfRectangle{
+		{Llx: 100, Lly: 500, Urx: 116, Ury: 516},
+		{Llx: 120, Lly: 500, Urx: 130, Ury: 520},
+		{Llx: 130, Lly: 500, Urx: 146, Ury: 516},
+		{Llx: 100, Lly: 470, Urx: 110, Ury: 490},
+	}
+	pageFills, err := e.ExtractPageFills()
+	require.NoError(t, err)
+	require.Len(t, pageFills.Fills, len(expected))
+	for i, fill := range pageFills.Fills {
+		requireRectInDelta(t, expected[i], fill.BBox)
+		require.Equal(t, color.RGBA{A: 255}, fill.Color)
+	}
+
+	pageText, _, _, err := e.ExtractPageText()
+	require.NoError(t, err)
+	require.Equal(t, "ABA\nB", pageText.Text())
+	var bboxes []model.PdfRectangle
+	for _, mark := range pageText.Marks().Elements() {
+		if !mark.Meta {
+			bboxes = append(bboxes, mark.BBox)
+		}
+	}
+	require.Len(t, bboxes, len(expected))
+	for i, bbox := range bboxes {
+		requireRectInDelta(t, expected[i], bbox)
+	}
+}
+
+// requireRectInDelta requires the rectangles `expected` and `actual` to be equal within 1e-6.
+func requireRectInDelta(t *testing.T, expected, actual model.PdfRectangle) {
+	require.InDelta(t, expected.Llx, actual.Llx, 1e-6, "%+v != %+v", expected, actual)
+	require.InDelta(t, expected.Lly, actual.Lly, 1e-6, "%+v != %+v", expected, actual)
+	require.InDelta(t, expected.Urx, actual.Urx, 1e-6, "%+v != %+v", expected, actual)
+	require.InDelta(t, expected.Ury, actual.Ury, 1e-6, "%+v != %+v", expected, actual)
+}
@@ -0,0 +1,111 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/textencoding"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// TextState represents the text state parameters a glyph is shown with.
+// See section 9.3 "Text State Parameters and Operators" (p. 243 PDF32000_2008).
+type TextState struct {
+	// FontName is the name of the font in the font resources of the content stream and Font the
+	// font, as set by Tf.
+	FontName core.PdfObjectName
+	Font     *model.PdfFont
+
+	// FontSize is the font size set by Tf.
+	FontSize float64
+
+	// CharSpacing (Tc), WordSpacing (Tw), Leading (TL) and Rise (Ts) are in unscaled text space
+	// units.
+	CharSpacing float64
+	WordSpacing float64
+	Leading     float64
+	Rise        float64
+
+	// HorizScaling is the horizontal scaling set by Tz, in percent.
+	HorizScaling float64
+
+	// RenderMode is the text rendering mode set by Tr.
+	RenderMode RenderMode
+}
+
+// Glyph represents a glyph shown by a text showing operator.
+type Glyph struct {
+	// Code is the character code of the glyph and Text its decoding to Unicode.
+	Code textencoding.CharCode
+	Text string
+
+	// State is the text state the glyph is shown with.
+	State TextState
+
+	// Tm is the text matrix, which maps the text space of the glyph to the user space of the
+	// content stream showing it, and CTM maps the user space of the content stream to device
+	// space. CTM is the composition of the CTMs of the content stream and of the form XObjects it
+	// is drawn in.
+	Tm  transform.Matrix
+	CTM transform.Matrix
+
+	// TextOrigin is the origin of the glyph in the user space of the content stream showing it,
+	// i.e. the translation of Tm.
+	TextOrigin transform.Point
+
+	// Origin is the origin of the glyph in device space, displaced by the text rise.
+	Origin transform.Point
+
+	// Advance is the horizontal displacement of the text matrix applied after showing the glyph,
+	// in text space units. It includes the character and word spacing and the horizontal
+	// scaling, but not the adjustments of TJ arrays.
+	Advance float64
+}
+
+// GlyphHandler is called for each glyph shown by the content streams processed by
+// Extractor.ProcessGlyphs. Processing stops when it returns an error.
+type GlyphHandler func(glyph Glyph) error
+
+// ProcessGlyphs processes the content streams of the page of `e` and of the form XObjects it
+// draws, calling `handler` for each glyph shown, in the order they are shown. The glyphs of a form
+// XObject are processed each time the form is drawn. The glyphs discarded from the text marks
+// with the options of `e` are processed as well.
+func (e *Extractor) ProcessGlyphs(handler GlyphHandler) error {
+	e.glyphHandler = handler
+	defer func() {
+		e.glyphHandler = nil
+	}()
+	_, _, _, err := e.extractPageText(e.contents, e.resources, transform.IdentityMatrix(), e.pageClip(), 1, 0)
+	return err
+}
+
+// newGlyph returns the glyph with character code `code`, decoded to `text`, shown with `font` and
+// text rendering matrix `trm`, and advancing the text matrix by `advance`.
+func (to *textObject) newGlyph(code textencoding.CharCode, text string, font *model.PdfFont,
+	trm transform.Matrix, advance float64) Glyph {
+	state := to.state
+	return Glyph{
+		Code: code,
+		Text: text,
+		State: TextState{
+			FontName:     state.fontNames[font],
+			Font:         font,
+			FontSize:     state.tfs,
+			CharSpacing:  state.tc,
+			WordSpacing:  state.tw,
+			Leading:      state.tl,
+			Rise:         state.trise,
+			HorizScaling: state.th,
+			RenderMode:   state.tmode,
+		},
+		Tm:         to.tm,
+		CTM:        to.gs.CTM,
+		TextOrigin: translation(to.tm),
+		Origin:     translation(trm),
+		Advance:    advance,
+	}
+}
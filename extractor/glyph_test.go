@@ -0,0 +1,158 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// glyphPage returns a page showing text with various text states, and a form XObject showing
+// text, drawn twice with different CTMs if `drawTwice` is true.
+func glyphPage(t *testing.T, drawTwice bool) *model.PdfPage {
+	helvetica := model.NewStandard14FontMustCompile(model.HelveticaName).ToPdfObject()
+	courier := model.NewStandard14FontMustCompile(model.CourierName).ToPdfObject()
+
+	form := model.NewXObjectForm()
+	form.BBox = core.MakeArrayFromFloats([]float64{0, 0, 200, 50})
+	form.Resources = model.NewPdfPageResources()
+	require.NoError(t, form.Resources.SetFontByName("F2", courier))
+	require.NoError(t, form.SetContentStream([]byte("BT /F2 10 Tf 0 0 Td (Form text) Tj ET"), nil))
+
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+	require.NoError(t, page.Resources.SetFontByName("F1", helvetica))
+	require.NoError(t, page.Resources.SetXObjectFormByName("Fm0", form))
+	contents := `BT /F1 12 Tf 72 700 Td (Hello World) Tj
+14 TL 0.5 Tc 1 Tw 90 Tz 2 Ts 1 Tr (Second line) ' ET
+q 1 0 0 1 72 600 cm /Fm0 Do Q
+`
+	if drawTwice {
+		contents += "q 2 0 0 2 72 500 cm /Fm0 Do Q\n"
+	}
+	require.NoError(t, page.SetContentStreams([]string{contents}, nil))
+	return page
+}
+
+// glyphText returns the text of `glyphs` made of lines of glyphs with the same device space
+// baseline, sorted from top to bottom and from left to right.
+func glyphText(glyphs []Glyph) string {
+	lines := map[float64][]Glyph{}
+	for _, g := range glyphs {
+		y := math.Round(g.Origin.Y - g.State.Rise*g.CTM.ScalingFactorY())
+		lines[y] = append(lines[y], g)
+	}
+	var ys []float64
+	for y := range lines {
+		ys = append(ys, y)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(ys)))
+
+	var text []string
+	for _, y := range ys {
+		line := lines[y]
+		sort.SliceStable(line, func(i, j int) bool { return line[i].Origin.X < line[j].Origin.X })
+		var b strings.Builder
+		for _, g := range line {
+			b.WriteString(g.Text)
+		}
+		text = append(text, b.String())
+	}
+	return strings.Join(text, "\n")
+}
+
+// processGlyphs returns the glyphs processed by `e`.
+func processGlyphs(t *testing.T, e *Extractor) []Glyph {
+	var glyphs []Glyph
+	err := e.ProcessGlyphs(func(glyph Glyph) error {
+		glyphs = append(glyphs, glyph)
+		return nil
+	})
+	require.NoError(t, err)
+	return glyphs
+}
+
+// TestProcessGlyphs tests that the glyphs shown by a page and the forms it draws are processed
+// with their text states and positions, and that they make up the extracted text.
+func TestProcessGlyphs(t *testing.T) {
+	// The text reconstructed from the glyphs matches the extracted text.
+	e, err := New(glyphPage(t, false))
+	require.NoError(t, err)
+	glyphs := processGlyphs(t, e)
+	require.Len(t, glyphs, len("Hello World")+len("Second line")+len("Form text"))
+	text, err := e.ExtractText()
+	require.NoError(t, err)
+	require.Equal(t, "Hello World\nSecond line\nForm text", text)
+	require.Equal(t, text, glyphText(glyphs))
+
+	// The glyphs match the text marks, in content stream order.
+	pageText, _, _, err := e.extractPageText(e.contents, e.resources, transform.IdentityMatrix(),
+		e.pageClip(), 1, 0)
+	require.NoError(t, err)
+	require.Len(t, pageText.marks, len(glyphs))
+	for i, mark := range pageText.marks {
+		require.Equal(t, mark.text, glyphs[i].Text)
+		require.InDelta(t, mark.bbox.Llx, glyphs[i].Origin.X, 1e-6)
+	}
+
+	e, err = New(glyphPage(t, true))
+	require.NoError(t, err)
+	glyphs = processGlyphs(t, e)
+	require.Len(t, glyphs, len("Hello World")+len("Second line")+2*len("Form text"))
+
+	first := glyphs[0]
+	require.Equal(t, "H", first.Text)
+	require.EqualValues(t, 'H', first.Code)
+	require.Equal(t, TextState{FontName: "F1", Font: first.State.Font, FontSize: 12, HorizScaling: 100,
+		RenderMode: 0}, first.State)
+	require.Equal(t, 72.0, first.Origin.X)
+	require.Equal(t, 700.0, first.Origin.Y)
+	require.InDelta(t, 0.722*12, first.Advance, 1e-6)
+
+	// The space of the second line is shown with the text state set before it.
+	second := glyphs[len("Hello World")+len("Second")]
+	require.Equal(t, " ", second.Text)
+	require.Equal(t, TextState{FontName: "F1", Font: first.State.Font, FontSize: 12, CharSpacing: 0.5,
+		WordSpacing: 1, Leading: 14, Rise: 2, HorizScaling: 90, RenderMode: 1}, second.State)
+	require.InDelta(t, (0.278*12+0.5+1)*0.9, second.Advance, 1e-6)
+	require.InDelta(t, 686.0, second.TextOrigin.Y, 1e-6)
+	require.InDelta(t, 688.0, second.Origin.Y, 1e-6)
+
+	// The glyphs of the form are processed each time it is drawn, with the composed CTMs.
+	formGlyphs := glyphs[len(glyphs)-2*len("Form text"):]
+	for i, g := range formGlyphs {
+		require.Equal(t, core.PdfObjectName("F2"), g.State.FontName)
+		require.Equal(t, 10.0, g.State.FontSize)
+		scale, y := 1.0, 600.0
+		if i >= len("Form text") {
+			scale, y = 2.0, 500.0
+		}
+		require.Equal(t, scale, g.CTM.ScalingFactorX())
+		require.InDelta(t, y, g.Origin.Y, 1e-6)
+		// Courier glyphs are 600 units wide.
+		require.InDelta(t, 72+scale*6*float64(i%len("Form text")), g.Origin.X, 1e-6)
+		require.InDelta(t, 6*float64(i%len("Form text")), g.TextOrigin.X, 1e-6)
+	}
+
+	// Processing stops at the first error of the handler.
+	errStop := errors.New("stop")
+	count := 0
+	err = e.ProcessGlyphs(func(glyph Glyph) error {
+		count++
+		return errStop
+	})
+	require.Equal(t, errStop, err)
+	require.Equal(t, 1, count)
+}
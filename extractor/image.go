@@ -6,9 +6,12 @@
 package extractor
 
 import (
+	"image/color"
+
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/contentstream"
 	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/transform"
 	"github.com/unidoc/unipdf/v3/model"
 )
 
@@ -16,6 +19,11 @@ import (
 // PDF pages.
 type ImageExtractOptions struct {
 	IncludeInlineStencilMasks bool
+
+	// AttachICCProfiles makes the images in ICCBased colorspaces, including the indexed images
+	// over an ICCBased base colorspace, extracted in the colorspace of their ICC profile rather
+	// than converted to RGB. The profile is attached to their ImageMark.
+	AttachICCProfiles bool
 }
 
 // ExtractPageImages returns the image contents of the page extractor, including data
@@ -25,10 +33,14 @@ type ImageExtractOptions struct {
 // are not extracted.
 func (e *Extractor) ExtractPageImages(options *ImageExtractOptions) (*PageImages, error) {
 	ctx := &imageExtractContext{
-		options: options,
+		e:                e,
+		options:          options,
+		discardClipped:   e.options.DiscardClipped,
+		discardInvisible: e.options.DiscardInvisible,
 	}
 
-	err := ctx.extractContentStreamImages(e.contents, e.resources)
+	err := ctx.extractContentStreamImages(e.contents, e.resources, transform.IdentityMatrix(), e.pageClip(),
+		pageTransparency)
 	if err != nil {
 		return nil, err
 	}
@@ -47,8 +59,20 @@ type PageImages struct {
 // ImageMark represents an image drawn on a page and its position in device coordinates.
 // All coordinates are in device coordinates.
 type ImageMark struct {
+	// Image is the image converted to sRGB, using the ICC profile of the images in ICCBased
+	// colorspaces. Its alpha channel combines the soft mask of the image and the soft mask of the
+	// graphics state it is painted with, if any.
 	Image *model.Image
 
+	// ICCProfile is the ICC profile of the colorspace of Image, if the AttachICCProfiles option
+	// is set and the image is in an ICCBased colorspace. Image is not converted to RGB then.
+	ICCProfile []byte
+
+	// StencilColor is the fill color, converted to RGB, stencil masks (ImageMask) are painted
+	// with, or nil for the other images. Image is then an image of this color whose alpha channel
+	// is the stencil mask.
+	StencilColor color.Color
+
 	// Dimensions of the image as displayed in the PDF.
 	Width  float64
 	Height float64
@@ -59,10 +83,22 @@ type ImageMark struct {
 
 	// Angle in degrees, if rotated.
 	Angle float64
+
+	// Alpha is the constant alpha the image is painted with, combined with those of the forms it
+	// is drawn in. It is not applied to the alpha channel of Image.
+	Alpha float64
+
+	// BlendMode is the blend mode the image is painted with.
+	BlendMode core.PdfObjectName
+
+	// Groups are the transparency groups of the forms the image is drawn in, outermost first.
+	// The effects of their isolated and knockout flags are not applied to Image.
+	Groups []*model.PdfTransparencyGroup
 }
 
 // Provide context for image extraction content stream processing.
 type imageExtractContext struct {
+	e               *Extractor
 	extractedImages []ImageMark
 	inlineImages    int
 	xObjectImages   int
@@ -72,15 +108,23 @@ type imageExtractContext struct {
 	cacheXObjectImages map[*core.PdfObjectStream]*cachedImage
 
 	// Extract options.
-	options *ImageExtractOptions
+	options          *ImageExtractOptions
+	discardClipped   bool
+	discardInvisible bool
 }
 
 type cachedImage struct {
-	image *model.Image
-	cs    model.PdfColorspace
+	image   *model.Image
+	cs      model.PdfColorspace
+	smask   *model.Image // The soft mask image of the image, or nil.
+	stencil bool         // The image is a stencil mask.
 }
 
-func (ctx *imageExtractContext) extractContentStreamImages(contents string, resources *model.PdfPageResources) error {
+// extractContentStreamImages extracts the images of `contents`. `parentCTM` maps the content stream
+// coordinates to device coordinates, `parentClip` is the device clip of the content stream and
+// `parentTr` the transparency state it is painted with.
+func (ctx *imageExtractContext) extractContentStreamImages(contents string, resources *model.PdfPageResources,
+	parentCTM transform.Matrix, parentClip *model.PdfRectangle, parentTr transparency) error {
 	cstreamParser := contentstream.NewContentStreamParser(contents)
 	operations, err := cstreamParser.Parse()
 	if err != nil {
@@ -94,17 +138,23 @@ func (ctx *imageExtractContext) extractContentStreamImages(contents string, reso
 		ctx.options = &ImageExtractOptions{}
 	}
 
-	processor := contentstream.NewContentStreamProcessor(*operations)
+	processor := ctx.e.newProcessor(*operations)
 	processor.AddHandler(contentstream.HandlerConditionEnumAllOperands, "",
 		func(op *contentstream.ContentStreamOperation, gs contentstream.GraphicsState, resources *model.PdfPageResources) error {
-			return ctx.processOperand(op, gs, resources)
+			tr := parentTr.paint(gs, parentCTM)
+			gs.CTM = parentCTM.Mult(gs.CTM)
+			gs.Clip = deviceClip(parentCTM, gs.Clip, parentClip)
+			return ctx.processOperand(op, gs, tr, resources)
 		})
 
-	return processor.Process(resources)
+	return ctx.e.process(processor, resources)
 }
 
 // Process individual content stream operands for image extraction.
-func (ctx *imageExtractContext) processOperand(op *contentstream.ContentStreamOperation, gs contentstream.GraphicsState, resources *model.PdfPageResources) error {
+// `gs.CTM` maps to device coordinates, `gs.Clip` is in device coordinates and `tr` is the
+// transparency state images are painted with.
+func (ctx *imageExtractContext) processOperand(op *contentstream.ContentStreamOperation, gs contentstream.GraphicsState,
+	tr transparency, resources *model.PdfPageResources) error {
 	if op.Operand == "BI" && len(op.Params) == 1 {
 		// BI: Inline image.
 		iimg, ok := op.Params[0].(*contentstream.ContentStreamInlineImage)
@@ -118,7 +168,10 @@ func (ctx *imageExtractContext) processOperand(op *contentstream.ContentStreamOp
 			}
 		}
 
-		return ctx.extractInlineImage(iimg, gs, resources)
+		if ctx.isClipped(gs) {
+			return nil
+		}
+		return ctx.extractInlineImage(iimg, gs, tr, resources)
 	} else if op.Operand == "Do" && len(op.Params) == 1 {
 		// Do: XObject.
 		name, ok := core.GetName(op.Params[0])
@@ -130,15 +183,26 @@ func (ctx *imageExtractContext) processOperand(op *contentstream.ContentStreamOp
 		_, xtype := resources.GetXObjectByName(*name)
 		switch xtype {
 		case model.XObjectTypeImage:
-			return ctx.extractXObjectImage(name, gs, resources)
+			if ctx.isClipped(gs) {
+				return nil
+			}
+			return ctx.extractXObjectImage(name, gs, tr, resources)
 		case model.XObjectTypeForm:
-			return ctx.extractFormImages(name, gs, resources)
+			return ctx.extractFormImages(name, gs, tr, resources)
 		}
 	}
 	return nil
 }
 
-func (ctx *imageExtractContext) extractInlineImage(iimg *contentstream.ContentStreamInlineImage, gs contentstream.GraphicsState, resources *model.PdfPageResources) error {
+// isClipped returns true if images drawn with graphics state `gs` are to be discarded, being
+// entirely outside the clip.
+func (ctx *imageExtractContext) isClipped(gs contentstream.GraphicsState) bool {
+	// Images are drawn in the unit square of user space.
+	return ctx.discardClipped && isClipped(model.PdfRectangle{Urx: 1, Ury: 1}.Transform(gs.CTM), gs.Clip)
+}
+
+func (ctx *imageExtractContext) extractInlineImage(iimg *contentstream.ContentStreamInlineImage, gs contentstream.GraphicsState,
+	tr transparency, resources *model.PdfPageResources) error {
 	img, err := iimg.ToImage(resources)
 	if err != nil {
 		return err
@@ -153,25 +217,129 @@ func (ctx *imageExtractContext) extractInlineImage(iimg *contentstream.ContentSt
 		cs = model.NewPdfColorspaceDeviceGray()
 	}
 
-	rgbImg, err := cs.ImageToRGB(*img)
+	isMask, err := iimg.IsMask()
+	if err != nil {
+		return err
+	}
+	if isMask {
+		smask := newAlphaImage(img.Width, img.Height, img.StencilMaskAlpha(model.IsDecodeInverted(iimg.Decode)))
+		if ctx.addStencilMark(img, smask, gs, tr) {
+			ctx.inlineImages++
+		}
+		return nil
+	}
+
+	rgbImg, profile, err := ctx.convertImage(*img, cs)
 	if err != nil {
 		return err
 	}
 
+	if ctx.addImageMark(&rgbImg, profile, nil, nil, gs, tr) {
+		ctx.inlineImages++
+	}
+	return nil
+}
+
+// addStencilMark adds the mark of stencil mask image `img`, whose alpha channel is `smask`,
+// painted with the fill color of graphics state `gs` and transparency state `tr`, unless it is
+// invisible and invisible images are discarded. It returns true if the mark is added.
+func (ctx *imageExtractContext) addStencilMark(img, smask *model.Image, gs contentstream.GraphicsState,
+	tr transparency) bool {
+	fillColor := toRGBColor(gs.ColorspaceNonStroking, gs.ColorNonStroking)
+	if fillColor == nil {
+		// The patterns are not supported: the stencil is painted black.
+		fillColor = color.Black
+	}
+	r, g, b, _ := fillColor.RGBA()
+	numPixels := int(img.Width * img.Height)
+	data := make([]byte, 0, 3*numPixels)
+	for i := 0; i < numPixels; i++ {
+		data = append(data, byte(r>>8), byte(g>>8), byte(b>>8))
+	}
+	rgbImg := model.Image{
+		Width:            img.Width,
+		Height:           img.Height,
+		BitsPerComponent: 8,
+		ColorComponents:  3,
+		Data:             data,
+		Interpolate:      img.Interpolate,
+	}
+	return ctx.addImageMark(&rgbImg, nil, smask, fillColor, gs, tr)
+}
+
+// newAlphaImage returns the 8 bit one component image of size `width`x`height` with samples
+// `alpha`, used as the soft mask of the images with other types of masks.
+func newAlphaImage(width, height int64, alpha []byte) *model.Image {
+	return &model.Image{
+		Width:            width,
+		Height:           height,
+		BitsPerComponent: 8,
+		ColorComponents:  1,
+		Data:             alpha,
+	}
+}
+
+// convertImage converts image `img` in colorspace `cs` to sRGB or, if the AttachICCProfiles option
+// is set and `cs` is ICCBased, to the colorspace of its ICC profile, which is returned.
+func (ctx *imageExtractContext) convertImage(img model.Image, cs model.PdfColorspace) (model.Image, []byte, error) {
+	if ctx.options.AttachICCProfiles {
+		base := cs
+		indexed, isIndexed := cs.(*model.PdfColorspaceSpecialIndexed)
+		if isIndexed {
+			base = indexed.Base
+		}
+		if iccCS, ok := base.(*model.PdfColorspaceICCBased); ok && len(iccCS.Data) > 0 {
+			if isIndexed {
+				baseImg, err := indexed.ImageToBase(img)
+				return baseImg, iccCS.Data, err
+			}
+			return img, iccCS.Data, nil
+		}
+	}
+	rgbImg, err := model.ImageToSRGB(cs, img)
+	return rgbImg, nil, err
+}
+
+// addImageMark adds the mark of image `rgbImg`, in the colorspace of ICC profile `profile` if not
+// nil or RGB otherwise, with soft mask image `smask`, if not nil, painted with graphics state
+// `gs` and transparency state `tr`, unless it is invisible and invisible images are discarded.
+// `stencilColor` is the fill color of the stencil masks, nil for the other images.
+// It returns true if the mark is added.
+func (ctx *imageExtractContext) addImageMark(rgbImg *model.Image, profile []byte, smask *model.Image,
+	stencilColor color.Color, gs contentstream.GraphicsState, tr transparency) bool {
+	if ctx.discardInvisible && tr.alpha == 0 {
+		return false
+	}
+	alpha, err := imageAlpha(rgbImg, smask, gs.CTM, tr)
+	if err != nil {
+		common.Log.Debug("ERROR: could not compute the image alpha channel: %v", err)
+	}
+	if alpha != nil {
+		if ctx.discardInvisible && allTransparent(alpha) {
+			return false
+		}
+		setImageAlpha(rgbImg, alpha)
+	}
+
 	imgMark := ImageMark{
-		Image:  &rgbImg,
-		Width:  gs.CTM.ScalingFactorX(),
-		Height: gs.CTM.ScalingFactorY(),
-		Angle:  gs.CTM.Angle(),
+		Image:        rgbImg,
+		ICCProfile:   profile,
+		StencilColor: stencilColor,
+		Width:        gs.CTM.ScalingFactorX(),
+		Height:       gs.CTM.ScalingFactorY(),
+		Angle:        gs.CTM.Angle(),
+		Alpha:        tr.alpha,
+		BlendMode:    tr.blendMode,
+		Groups:       tr.groups,
 	}
 	imgMark.X, imgMark.Y = gs.CTM.Translation()
 
 	ctx.extractedImages = append(ctx.extractedImages, imgMark)
-	ctx.inlineImages++
-	return nil
+	return true
 }
 
-func (ctx *imageExtractContext) extractXObjectImage(name *core.PdfObjectName, gs contentstream.GraphicsState, resources *model.PdfPageResources) error {
+func (ctx *imageExtractContext) extractXObjectImage(name *core.PdfObjectName, gs contentstream.GraphicsState,
+	tr transparency, resources *model.PdfPageResources) error {
 	stream, _ := resources.GetXObjectByName(*name)
 	if stream == nil {
 		return nil
@@ -194,35 +362,84 @@ func (ctx *imageExtractContext) extractXObjectImage(name *core.PdfObjectName, gs
 		}
 
 		cimg = &cachedImage{
-			image: img,
-			cs:    ximg.ColorSpace,
+			image:   img,
+			cs:      ximg.ColorSpace,
+			stencil: ximg.IsImageMask(),
 		}
+		cimg.smask = imageMask(ximg, img)
 		ctx.cacheXObjectImages[stream] = cimg
 	}
 	img := cimg.image
 	cs := cimg.cs
 
-	rgbImg, err := cs.ImageToRGB(*img)
+	common.Log.Debug("@Do CTM: %s", gs.CTM.String())
+	if cimg.stencil {
+		if ctx.addStencilMark(img, cimg.smask, gs, tr) {
+			ctx.xObjectImages++
+		}
+		return nil
+	}
+
+	rgbImg, profile, err := ctx.convertImage(*img, cs)
 	if err != nil {
 		return err
 	}
 
-	common.Log.Debug("@Do CTM: %s", gs.CTM.String())
-	imgMark := ImageMark{
-		Image:  &rgbImg,
-		Width:  gs.CTM.ScalingFactorX(),
-		Height: gs.CTM.ScalingFactorY(),
-		Angle:  gs.CTM.Angle(),
+	if ctx.addImageMark(&rgbImg, profile, cimg.smask, nil, gs, tr) {
+		ctx.xObjectImages++
 	}
-	imgMark.X, imgMark.Y = gs.CTM.Translation()
+	return nil
+}
 
-	ctx.extractedImages = append(ctx.extractedImages, imgMark)
-	ctx.xObjectImages++
+// imageMask returns the image giving the alpha channel of image `img` of image XObject `ximg`:
+// its soft mask (SMask), its explicit stencil mask (Mask) or the mask of the samples matching its
+// color key mask (Mask), or the stencil mask itself for stencil masks (ImageMask). It returns nil
+// for the images without masks.
+func imageMask(ximg *model.XObjectImage, img *model.Image) *model.Image {
+	if ximg.IsImageMask() {
+		alpha := img.StencilMaskAlpha(model.IsDecodeInverted(ximg.Decode))
+		return newAlphaImage(img.Width, img.Height, alpha)
+	}
+
+	if smaskStream, ok := core.GetStream(ximg.SMask); ok {
+		smask, err := model.NewXObjectImageFromStream(smaskStream)
+		if err == nil {
+			var smaskImg *model.Image
+			if smaskImg, err = smask.ToImage(); err == nil {
+				return smaskImg
+			}
+		}
+		common.Log.Debug("ERROR: could not load the image soft mask: %v", err)
+		return nil
+	}
+
+	if maskStream, ok := core.GetStream(ximg.Mask); ok {
+		mask, err := model.NewXObjectImageFromStream(maskStream)
+		if err == nil {
+			var maskImg *model.Image
+			if maskImg, err = mask.ToImage(); err == nil {
+				alpha := maskImg.StencilMaskAlpha(model.IsDecodeInverted(mask.Decode))
+				return newAlphaImage(maskImg.Width, maskImg.Height, alpha)
+			}
+		}
+		common.Log.Debug("ERROR: could not load the image stencil mask: %v", err)
+		return nil
+	}
+
+	if ranges := ximg.GetColorKeyMask(); ranges != nil {
+		alpha, err := img.ColorKeyMaskAlpha(ranges)
+		if err != nil {
+			common.Log.Debug("ERROR: could not apply the image color key mask: %v", err)
+			return nil
+		}
+		return newAlphaImage(img.Width, img.Height, alpha)
+	}
 	return nil
 }
 
 // Go through the XObject Form content stream (recursive processing).
-func (ctx *imageExtractContext) extractFormImages(name *core.PdfObjectName, gs contentstream.GraphicsState, resources *model.PdfPageResources) error {
+func (ctx *imageExtractContext) extractFormImages(name *core.PdfObjectName, gs contentstream.GraphicsState,
+	tr transparency, resources *model.PdfPageResources) error {
 	xform, err := resources.GetXObjectFormByName(*name)
 	if err != nil {
 		return err
@@ -242,8 +459,17 @@ func (ctx *imageExtractContext) extractFormImages(name *core.PdfObjectName, gs c
 		formResources = resources
 	}
 
+	group, err := xform.GetTransparencyGroup()
+	if err != nil {
+		common.Log.Debug("ERROR: invalid form transparency group: %v", err)
+	} else if group != nil {
+		tr = tr.enterGroup(group)
+	}
+
 	// Process the content stream in the Form object too:
-	err = ctx.extractContentStreamImages(string(formContent), formResources)
+	formCTM := gs.CTM.Mult(toMatrix(xform.Matrix))
+	err = ctx.extractContentStreamImages(string(formContent), formResources, formCTM,
+		formClip(xform, formCTM, gs.Clip), tr)
 	if err != nil {
 		return err
 	}
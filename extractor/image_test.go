@@ -6,7 +6,14 @@
 package extractor
 
 import (
+	"bytes"
+	"fmt"
+	goimage "image"
+	"image/color"
+	"image/draw"
+	"io/ioutil"
 	"math"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"testing"
@@ -39,12 +46,14 @@ func TestImageExtractionBasic(t *testing.T) {
 			"./testdata/basic_xobject.pdf",
 			[]ImageMark{
 				{
-					Image:  nil,
-					X:      0,
-					Y:      294.865385,
-					Width:  612,
-					Height: 197.134615,
-					Angle:  0,
+					Image:     nil,
+					X:         0,
+					Y:         294.865385,
+					Width:     612,
+					Height:    197.134615,
+					Angle:     0,
+					Alpha:     1,
+					BlendMode: "Normal",
 				},
 			},
 		},
@@ -54,12 +63,14 @@ func TestImageExtractionBasic(t *testing.T) {
 			"./testdata/inline.pdf",
 			[]ImageMark{
 				{
-					Image:  nil,
-					X:      0,
-					Y:      -0.000000358,
-					Width:  12,
-					Height: 12,
-					Angle:  0,
+					Image:     nil,
+					X:         0,
+					Y:         -0.000000358,
+					Width:     12,
+					Height:    12,
+					Angle:     0,
+					Alpha:     1,
+					BlendMode: "Normal",
 				},
 			},
 		},
@@ -109,12 +120,14 @@ func TestImageExtractionNestedCM(t *testing.T) {
 			"Q",
 			[]ImageMark{
 				{
-					Image:  nil,
-					X:      0 + 100.0,
-					Y:      294.865385 + 50.0,
-					Width:  612,
-					Height: 197.134615,
-					Angle:  0,
+					Image:     nil,
+					X:         0 + 100.0,
+					Y:         294.865385 + 50.0,
+					Width:     612,
+					Height:    197.134615,
+					Angle:     0,
+					Alpha:     1,
+					BlendMode: "Normal",
 				},
 			},
 		},
@@ -126,12 +139,14 @@ func TestImageExtractionNestedCM(t *testing.T) {
 			"Q",
 			[]ImageMark{
 				{
-					Image:  nil,
-					X:      0,
-					Y:      294.865385 * 2.0,
-					Width:  612 * 1.5,
-					Height: 197.134615 * 2.0,
-					Angle:  0,
+					Image:     nil,
+					X:         0,
+					Y:         294.865385 * 2.0,
+					Width:     612 * 1.5,
+					Height:    197.134615 * 2.0,
+					Angle:     0,
+					Alpha:     1,
+					BlendMode: "Normal",
 				},
 			},
 		},
@@ -143,12 +158,14 @@ func TestImageExtractionNestedCM(t *testing.T) {
 			"Q Q",
 			[]ImageMark{
 				{
-					Image:  nil,
-					X:      100.0 * 1.5,
-					Y:      (294.865385 + 50.0) * 2.0,
-					Width:  612 * 1.5,
-					Height: 197.134615 * 2.0,
-					Angle:  0,
+					Image:     nil,
+					X:         100.0 * 1.5,
+					Y:         (294.865385 + 50.0) * 2.0,
+					Width:     612 * 1.5,
+					Height:    197.134615 * 2.0,
+					Angle:     0,
+					Alpha:     1,
+					BlendMode: "Normal",
 				},
 			},
 		},
@@ -268,28 +285,34 @@ func TestImageExtractionRealWorld(t *testing.T) {
 			"icnp12-qinghua.pdf",
 			[]ImageMark{
 				{
-					Image:  nil,
-					Width:  2.877,
-					Height: 22.344,
-					X:      236.508,
-					Y:      685.248,
-					Angle:  0.0,
+					Image:     nil,
+					Width:     2.877,
+					Height:    22.344,
+					X:         236.508,
+					Y:         685.248,
+					Angle:     0.0,
+					Alpha:     1,
+					BlendMode: "Normal",
 				},
 				{
-					Image:  nil,
-					Width:  247.44,
-					Height: 0.48,
-					X:      313.788,
-					Y:      715.248,
-					Angle:  0.0,
+					Image:     nil,
+					Width:     247.44,
+					Height:    0.48,
+					X:         313.788,
+					Y:         715.248,
+					Angle:     0.0,
+					Alpha:     1,
+					BlendMode: "Normal",
 				},
 				{
-					Image:  nil,
-					Width:  247.44,
-					Height: 0.48,
-					X:      313.788,
-					Y:      594.648,
-					Angle:  0.0,
+					Image:     nil,
+					Width:     247.44,
+					Height:    0.48,
+					X:         313.788,
+					Y:         594.648,
+					Angle:     0.0,
+					Alpha:     1,
+					BlendMode: "Normal",
 				},
 			},
 		},
@@ -333,6 +356,110 @@ func TestImageExtractionRealWorld(t *testing.T) {
 	}
 }
 
+// iccImageResources returns the resources of the ICC image tests: an Adobe RGB image Im0, a CMYK
+// image Im1 with a press profile, an indexed image Im2 over the same CMYK colorspace and a
+// DeviceCMYK image Im3, along with the ICC profiles.
+func iccImageResources(t *testing.T) (*model.PdfPageResources, []byte, []byte) {
+	loadICC := func(name string, n int64, alternate string) ([]byte, core.PdfObject) {
+		data, err := ioutil.ReadFile(filepath.Join("..", "internal", "icc", "testdata", name))
+		require.NoError(t, err)
+		stream, err := core.MakeStream(data, nil)
+		require.NoError(t, err)
+		stream.Set("N", core.MakeInteger(n))
+		stream.Set("Alternate", core.MakeName(alternate))
+		return data, core.MakeArray(core.MakeName("ICCBased"), stream)
+	}
+	adobeRGB, adobeCS := loadICC("AdobeRGB1998.icc", 3, "DeviceRGB")
+	press, pressCS := loadICC("CMYKPress.icc", 4, "DeviceCMYK")
+
+	resources := model.NewPdfPageResources()
+	addImage := func(name string, width int64, cs core.PdfObject, data []byte) {
+		stream, err := core.MakeStream(data, nil)
+		require.NoError(t, err)
+		stream.Set("Type", core.MakeName("XObject"))
+		stream.Set("Subtype", core.MakeName("Image"))
+		stream.Set("Width", core.MakeInteger(width))
+		stream.Set("Height", core.MakeInteger(1))
+		stream.Set("BitsPerComponent", core.MakeInteger(8))
+		stream.Set("ColorSpace", cs)
+		require.NoError(t, resources.SetXObjectByName(core.PdfObjectName(name), stream))
+	}
+	addImage("Im0", 2, adobeCS, []byte{200, 100, 50, 30, 160, 220})
+	addImage("Im1", 3, pressCS, []byte{255, 0, 0, 0, 0, 0, 0, 255, 0, 0, 0, 0})
+	indexed := core.MakeArray(core.MakeName("Indexed"), pressCS, core.MakeInteger(1),
+		core.MakeStringFromBytes([]byte{255, 0, 0, 0, 0, 0, 0, 255}))
+	addImage("Im2", 2, indexed, []byte{0, 1})
+	addImage("Im3", 1, core.MakeName("DeviceCMYK"), []byte{255, 0, 0, 0})
+	return resources, adobeRGB, press
+}
+
+// TestImageExtractionICC tests that the images in ICCBased colorspaces are converted with their
+// profiles, comparing them with reference colors computed independently, and that the DeviceCMYK
+// images are converted as printed on a press.
+func TestImageExtractionICC(t *testing.T) {
+	resources, _, _ := iccImageResources(t)
+	contents := `
+		q 100 0 0 100 0 0 cm /Im0 Do Q
+		q 100 0 0 100 0 100 cm /Im1 Do Q
+		q 100 0 0 100 0 200 cm /Im2 Do Q
+		q 100 0 0 100 0 300 cm /Im3 Do Q
+	`
+	e := Extractor{resources: resources, contents: contents}
+	pageImages, err := e.ExtractPageImages(nil)
+	require.NoError(t, err)
+	require.Len(t, pageImages.Images, 4)
+
+	expected := [][][3]int{
+		{{227, 100, 42}, {0, 161, 223}},
+		{{70, 160, 210}, {60, 58, 60}, {255, 255, 255}},
+		{{70, 160, 210}, {60, 58, 60}},
+		{{0, 174, 239}},
+	}
+	for i, mark := range pageImages.Images {
+		require.Nil(t, mark.ICCProfile)
+		require.Equal(t, 3, mark.Image.ColorComponents)
+		require.Len(t, mark.Image.Data, 3*len(expected[i]))
+		for j, rgb := range expected[i] {
+			for c := range rgb {
+				d := int(mark.Image.Data[3*j+c]) - rgb[c]
+				require.True(t, d >= -4 && d <= 4, "image %d pixel %d: got %v expected %v",
+					i, j, mark.Image.Data[3*j:3*j+3], rgb)
+			}
+		}
+	}
+}
+
+// TestImageExtractionAttachICC tests that the images in ICCBased colorspaces are extracted with
+// their ICC profiles, without conversion, with the AttachICCProfiles option.
+func TestImageExtractionAttachICC(t *testing.T) {
+	resources, adobeRGB, press := iccImageResources(t)
+	contents := `
+		q 100 0 0 100 0 0 cm /Im0 Do Q
+		q 100 0 0 100 0 200 cm /Im2 Do Q
+		q 100 0 0 100 0 300 cm /Im3 Do Q
+	`
+	e := Extractor{resources: resources, contents: contents}
+	pageImages, err := e.ExtractPageImages(&ImageExtractOptions{AttachICCProfiles: true})
+	require.NoError(t, err)
+	require.Len(t, pageImages.Images, 3)
+
+	mark := pageImages.Images[0]
+	require.Equal(t, adobeRGB, mark.ICCProfile)
+	require.Equal(t, 3, mark.Image.ColorComponents)
+	require.Equal(t, []byte{200, 100, 50, 30, 160, 220}, mark.Image.Data)
+
+	// The indexed image is resolved through its color table.
+	mark = pageImages.Images[1]
+	require.Equal(t, press, mark.ICCProfile)
+	require.Equal(t, 4, mark.Image.ColorComponents)
+	require.Equal(t, []byte{255, 0, 0, 0, 0, 0, 0, 255}, mark.Image.Data)
+
+	// Images without ICC profile are still converted.
+	mark = pageImages.Images[2]
+	require.Nil(t, mark.ICCProfile)
+	require.Equal(t, 3, mark.Image.ColorComponents)
+}
+
 func BenchmarkImageExtraction(b *testing.B) {
 	cnt := 0
 	for i := 0; i < b.N; i++ {
@@ -357,3 +484,358 @@ func BenchmarkImageExtraction(b *testing.B) {
 
 	assert.Equal(b, b.N, cnt)
 }
+
+// TestImageExtractionIndexed tests that the images written in Indexed colorspaces, with 1, 2, 4
+// and 8 bits per component and padded rows, are extracted with their original colors.
+func TestImageExtractionIndexed(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	makeImage := func(width, height, numColors int, transparent bool) goimage.Image {
+		palette := make(color.Palette, numColors)
+		for i := range palette {
+			palette[i] = color.NRGBA{R: uint8(rnd.Intn(256)), G: uint8(rnd.Intn(256)), B: uint8(i), A: 255}
+		}
+		if transparent {
+			palette[0] = color.NRGBA{R: 10, G: 20, B: 30, A: 0}
+		}
+		img := goimage.NewPaletted(goimage.Rect(0, 0, width, height), palette)
+		for i := range img.Pix {
+			img.Pix[i] = uint8(rnd.Intn(numColors))
+		}
+		return img
+	}
+	toNRGBA := func(img goimage.Image) *goimage.NRGBA {
+		m := goimage.NewNRGBA(img.Bounds())
+		draw.Draw(m, m.Bounds(), img, goimage.Point{}, draw.Src)
+		return m
+	}
+
+	testcases := []struct {
+		img goimage.Image
+		bpc int64
+	}{
+		{makeImage(13, 5, 2, false), 1},
+		{makeImage(7, 3, 4, true), 2},
+		{toNRGBA(makeImage(5, 4, 12, false)), 4},
+		{toNRGBA(makeImage(21, 9, 200, false)), 8},
+	}
+
+	w := model.NewPdfWriter()
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+	var contents string
+	for i, tcase := range testcases {
+		img, cs, err := model.NewIndexedImageFromGoImage(tcase.img, false)
+		require.NoError(t, err)
+		require.Equal(t, tcase.bpc, img.BitsPerComponent)
+		rowSize := (tcase.img.Bounds().Dx()*int(tcase.bpc) + 7) / 8
+		require.Len(t, img.Data, rowSize*tcase.img.Bounds().Dy())
+
+		encoder := core.NewFlateEncoder()
+		encoder.Predictor = 15
+		ximg, err := model.NewXObjectImageFromImage(img, cs, encoder)
+		require.NoError(t, err)
+		name := fmt.Sprintf("Im%d", i)
+		require.NoError(t, page.Resources.SetXObjectImageByName(core.PdfObjectName(name), ximg))
+		contents += fmt.Sprintf("q 50 0 0 50 %d 100 cm /%s Do Q\n", 60*i+50, name)
+	}
+	require.NoError(t, page.AddContentStreamByString(contents))
+	require.NoError(t, w.AddPage(page))
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	r, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	page, err = r.GetPage(1)
+	require.NoError(t, err)
+	for i, tcase := range testcases {
+		ximg, err := page.Resources.GetXObjectImageByName(core.PdfObjectName(fmt.Sprintf("Im%d", i)))
+		require.NoError(t, err)
+		require.Equal(t, "Indexed", ximg.ColorSpace.String())
+		require.Equal(t, tcase.bpc, *ximg.BitsPerComponent)
+	}
+
+	e, err := New(page)
+	require.NoError(t, err)
+	pageImages, err := e.ExtractPageImages(nil)
+	require.NoError(t, err)
+	require.Len(t, pageImages.Images, len(testcases))
+	for i, tcase := range testcases {
+		expected := toNRGBA(tcase.img)
+		extracted := pageImages.Images[i].Image
+		require.Equal(t, 3, extracted.ColorComponents)
+		alpha := extracted.AlphaData()
+		for j := 0; j < len(expected.Pix)/4; j++ {
+			if expected.Pix[4*j+3] != 0 {
+				require.Equal(t, expected.Pix[4*j:4*j+3], extracted.Data[3*j:3*j+3], "image %d pixel %d", i, j)
+			}
+			if alpha != nil {
+				require.Equal(t, expected.Pix[4*j+3], alpha[j], "image %d pixel %d", i, j)
+			}
+		}
+		// Only the image with a transparent color has an alpha channel.
+		require.Equal(t, i == 1, alpha != nil, "image %d", i)
+	}
+}
+
+// TestImageExtractionQuantized tests that the images with more than 256 colors are indexed only if
+// they are quantized, and that they are extracted with colors close to their original colors.
+func TestImageExtractionQuantized(t *testing.T) {
+	img := goimage.NewNRGBA(goimage.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(4 * x), G: uint8(4 * y), B: uint8(2 * (x + y)), A: 255})
+		}
+	}
+	_, _, err := model.NewIndexedImageFromGoImage(img, false)
+	require.Equal(t, model.ErrTooManyColors, err)
+
+	indexed, cs, err := model.NewIndexedImageFromGoImage(img, true)
+	require.NoError(t, err)
+	require.Equal(t, 255, cs.HiVal)
+	require.Equal(t, int64(8), indexed.BitsPerComponent)
+
+	resources := model.NewPdfPageResources()
+	ximg, err := model.NewXObjectImageFromImage(indexed, cs, core.NewFlateEncoder())
+	require.NoError(t, err)
+	require.NoError(t, resources.SetXObjectImageByName("Im0", ximg))
+	e := Extractor{resources: resources, contents: "q 64 0 0 64 0 0 cm /Im0 Do Q"}
+	pageImages, err := e.ExtractPageImages(nil)
+	require.NoError(t, err)
+	require.Len(t, pageImages.Images, 1)
+
+	extracted := pageImages.Images[0].Image
+	var sum float64
+	for j := 0; j < 64*64; j++ {
+		for c := 0; c < 3; c++ {
+			sum += math.Abs(float64(img.Pix[4*j+c]) - float64(extracted.Data[3*j+c]))
+		}
+	}
+	mean := sum / (3 * 64 * 64)
+	require.True(t, mean < 4, "mean difference %.2f", mean)
+}
+
+func TestImageExtractionMasks(t *testing.T) {
+	// A 10x3 stamp, whose pixels with x < y+3 are black, painted by stencil masks.
+	stamp := goimage.NewGray(goimage.Rect(0, 0, 10, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 10; x++ {
+			if x >= y+3 {
+				stamp.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	painted := func(i int) bool { return i%10 < i/10+3 }
+
+	maskImg := model.NewImageMaskFromGoImage(stamp)
+	require.Equal(t, int64(1), maskImg.BitsPerComponent)
+	require.Len(t, maskImg.Data, 2*3)
+	stencil, err := model.NewXObjectImageMaskFromImage(maskImg, core.NewFlateEncoder())
+	require.NoError(t, err)
+	inverted, err := model.NewXObjectImageMaskFromImage(maskImg, nil)
+	require.NoError(t, err)
+	inverted.Decode = core.MakeArrayFromIntegers([]int{1, 0})
+
+	rgb := goimage.NewNRGBA(goimage.Rect(0, 0, 10, 3))
+	for i := 0; i < 30; i++ {
+		rgb.Pix[4*i], rgb.Pix[4*i+1], rgb.Pix[4*i+2], rgb.Pix[4*i+3] = uint8(10*i), 0, 200, 255
+	}
+	img, err := model.ImageHandling.NewImageFromGoImage(rgb)
+	require.NoError(t, err)
+	// The image with an explicit stencil mask is shown where the stencil mask samples are 0.
+	masked, err := model.NewXObjectImageFromImage(img, nil, core.NewFlateEncoder())
+	require.NoError(t, err)
+	mask, err := model.NewXObjectImageMaskFromImage(maskImg, nil)
+	require.NoError(t, err)
+	masked.Mask = mask.ToPdfObject()
+	// The pixels whose red component is within 50..100 are masked out by the color key mask.
+	colorKey, err := model.NewXObjectImageFromImage(img, nil, core.NewFlateEncoder())
+	require.NoError(t, err)
+	colorKey.Mask = core.MakeArrayFromIntegers([]int{50, 100, 0, 255, 0, 255})
+
+	w := model.NewPdfWriter()
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+	for i, ximg := range []*model.XObjectImage{stencil, inverted, masked, colorKey} {
+		name := core.PdfObjectName(fmt.Sprintf("Im%d", i))
+		require.NoError(t, page.Resources.SetXObjectImageByName(name, ximg))
+	}
+	contents := "q 1 0 0 rg 100 0 0 30 50 100 cm /Im0 Do Q\n" +
+		"q 0 0 1 rg 100 0 0 30 50 200 cm /Im1 Do Q\n" +
+		"q 100 0 0 30 50 300 cm /Im2 Do Q\n" +
+		"q 100 0 0 30 50 400 cm /Im3 Do Q\n"
+	require.NoError(t, page.AddContentStreamByString(contents))
+	require.NoError(t, w.AddPage(page))
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	r, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	page, err = r.GetPage(1)
+	require.NoError(t, err)
+	ximg, err := page.Resources.GetXObjectImageByName("Im0")
+	require.NoError(t, err)
+	require.True(t, ximg.IsImageMask())
+	require.Nil(t, ximg.ToPdfObject().(*core.PdfObjectStream).Get("ColorSpace"))
+	require.Equal(t, int64(1), *ximg.BitsPerComponent)
+
+	e, err := New(page)
+	require.NoError(t, err)
+	pageImages, err := e.ExtractPageImages(nil)
+	require.NoError(t, err)
+	require.Len(t, pageImages.Images, 4)
+
+	testcases := []struct {
+		stencilColor color.Color
+		visible      func(i int) bool
+	}{
+		{color.RGBA{R: 255, A: 255}, painted},
+		{color.RGBA{B: 255, A: 255}, func(i int) bool { return !painted(i) }},
+		{nil, painted},
+		{nil, func(i int) bool { return 10*i < 50 || 10*i > 100 }},
+	}
+	for k, tcase := range testcases {
+		mark := pageImages.Images[k]
+		require.Equal(t, tcase.stencilColor, mark.StencilColor, "image %d", k)
+		extracted := mark.Image
+		require.Equal(t, int64(10), extracted.Width)
+		require.Equal(t, 3, extracted.ColorComponents)
+		alpha := extracted.AlphaData()
+		require.Len(t, alpha, 30, "image %d", k)
+		for i := 0; i < 30; i++ {
+			expected := uint8(0)
+			if tcase.visible(i) {
+				expected = 255
+			}
+			require.Equal(t, expected, alpha[i], "image %d pixel %d", k, i)
+			if tcase.stencilColor != nil {
+				r, g, b, _ := tcase.stencilColor.RGBA()
+				require.Equal(t, []byte{byte(r >> 8), byte(g >> 8), byte(b >> 8)}, extracted.Data[3*i:3*i+3])
+			}
+		}
+	}
+}
+
+func TestImageExtractionDecode(t *testing.T) {
+	const width, height = 40, 10
+	numPixels := width * height
+	rowSize := (width + 7) / 8
+
+	// Bilevel scan, CCITT group 4 encoded, whose samples with value 1 are black pixels.
+	scan := &model.Image{Width: width, Height: height, BitsPerComponent: 1, ColorComponents: 1,
+		Data: make([]byte, rowSize*height)}
+	black := func(i int) bool { return (i%width/5+i/width/2)%2 == 0 }
+	for i := 0; i < numPixels; i++ {
+		if black(i) {
+			scan.Data[i/width*rowSize+i%width/8] |= 1 << uint(7-i%width%8)
+		}
+	}
+	scan.SetDecodeArray([]float64{1, 0})
+	ccitt := core.NewCCITTFaxEncoder()
+	ccitt.K = -1
+	ccitt.Columns = width
+	ccitt.Rows = height
+	ccitt.BitsPerComponent = 1
+
+	// Grayscale image whose samples are mapped to 0.2 - 0.8.
+	gray := &model.Image{Width: width, Height: height, BitsPerComponent: 8, ColorComponents: 1,
+		Data: make([]byte, numPixels)}
+	for i := range gray.Data {
+		gray.Data[i] = uint8(i * 7)
+	}
+	gray.SetDecodeArray([]float64{0.2, 0.8})
+
+	// RGB image whose red component is inverted, interpolated.
+	rgb := &model.Image{Width: width, Height: height, BitsPerComponent: 8, ColorComponents: 3,
+		Data: make([]byte, 3*numPixels), Interpolate: true}
+	for i := range rgb.Data {
+		rgb.Data[i] = uint8(i * 3)
+	}
+	rgb.SetDecodeArray([]float64{1, 0, 0, 1, 0, 1})
+
+	// Indexed image whose decode array reverses its 4 colors.
+	palette := color.Palette{
+		color.NRGBA{R: 255, A: 255}, color.NRGBA{G: 255, A: 255},
+		color.NRGBA{B: 255, A: 255}, color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+	}
+	paletted := goimage.NewPaletted(goimage.Rect(0, 0, width, height), palette)
+	for i := range paletted.Pix {
+		paletted.Pix[i] = uint8(i % 4)
+	}
+	indexed, cs, err := model.NewIndexedImageFromGoImage(paletted, false)
+	require.NoError(t, err)
+	indexed.SetDecodeArray([]float64{3, 0})
+
+	testcases := []struct {
+		img      *model.Image
+		cs       model.PdfColorspace
+		encoder  core.StreamEncoder
+		expected func(i int) [3]byte
+	}{
+		{scan, nil, ccitt, func(i int) [3]byte {
+			if black(i) {
+				return [3]byte{0, 0, 0}
+			}
+			return [3]byte{255, 255, 255}
+		}},
+		{gray, nil, core.NewFlateEncoder(), func(i int) [3]byte {
+			v := uint8(math.Round(255 * (0.2 + 0.6*float64(uint8(i*7))/255)))
+			return [3]byte{v, v, v}
+		}},
+		{rgb, nil, core.NewFlateEncoder(), func(i int) [3]byte {
+			return [3]byte{255 - uint8(9*i), uint8(9*i + 3), uint8(9*i + 6)}
+		}},
+		{indexed, cs, core.NewFlateEncoder(), func(i int) [3]byte {
+			c := palette[3-i%4].(color.NRGBA)
+			return [3]byte{c.R, c.G, c.B}
+		}},
+	}
+
+	w := model.NewPdfWriter()
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+	var contents string
+	for i, tcase := range testcases {
+		ximg, err := model.NewXObjectImageFromImage(tcase.img, tcase.cs, tcase.encoder)
+		require.NoError(t, err)
+		name := fmt.Sprintf("Im%d", i)
+		require.NoError(t, page.Resources.SetXObjectImageByName(core.PdfObjectName(name), ximg))
+		contents += fmt.Sprintf("q 80 0 0 20 50 %d cm /%s Do Q\n", 50*i+100, name)
+	}
+	require.NoError(t, page.AddContentStreamByString(contents))
+	require.NoError(t, w.AddPage(page))
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	r, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	page, err = r.GetPage(1)
+	require.NoError(t, err)
+	for i, tcase := range testcases {
+		ximg, err := page.Resources.GetXObjectImageByName(core.PdfObjectName(fmt.Sprintf("Im%d", i)))
+		require.NoError(t, err)
+		decode, ok := core.GetArray(ximg.Decode)
+		require.True(t, ok, "image %d", i)
+		values, err := decode.ToFloat64Array()
+		require.NoError(t, err)
+		require.Equal(t, tcase.img.DecodeArray(), values)
+		interpolate, _ := core.GetBoolVal(ximg.Interpolate)
+		require.Equal(t, tcase.img.Interpolate, interpolate, "image %d", i)
+	}
+
+	e, err := New(page)
+	require.NoError(t, err)
+	pageImages, err := e.ExtractPageImages(nil)
+	require.NoError(t, err)
+	require.Len(t, pageImages.Images, len(testcases))
+	for k, tcase := range testcases {
+		extracted := pageImages.Images[k].Image
+		require.Equal(t, 3, extracted.ColorComponents, "image %d", k)
+		require.Equal(t, int64(8), extracted.BitsPerComponent, "image %d", k)
+		require.Nil(t, extracted.DecodeArray(), "image %d", k)
+		require.Equal(t, tcase.img.Interpolate, extracted.Interpolate, "image %d", k)
+		for i := 0; i < numPixels; i++ {
+			expected := tcase.expected(i)
+			require.Equal(t, expected[:], extracted.Data[3*i:3*i+3], "image %d pixel %d", k, i)
+		}
+	}
+}
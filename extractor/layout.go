@@ -0,0 +1,159 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// LayoutOptions defines the layout mode text export.
+type LayoutOptions struct {
+	// FormFeeds adds a form feed character after the text of each page, as in the page breaks of
+	// the pdftotext -layout output.
+	FormFeeds bool
+}
+
+// LayoutText returns the text of the pages `pages` in layout mode, as in pdftotext -layout: the
+// words are placed in a fixed width character grid matching their horizontal positions, so that
+// the columns and tables stay aligned. The text of each page ends with a line break, followed by
+// a form feed if set in `opts`.
+func LayoutText(pages []*PageText, opts *LayoutOptions) string {
+	if opts == nil {
+		opts = &LayoutOptions{}
+	}
+	var b strings.Builder
+	for _, pt := range pages {
+		b.WriteString(pt.LayoutText())
+		if opts.FormFeeds {
+			b.WriteString("\f")
+		}
+	}
+	return b.String()
+}
+
+// LayoutText returns the text of the page in layout mode, as in pdftotext -layout, ending with a
+// line break if the page has text. The character cell width of the grid is the median width of the
+// characters of the page. The words are placed in the columns closest to their left edges, unless
+// they are close to the previous words of their lines, e.g. in phrases, or would overlap them:
+// they are then separated from the previous words by a single space. The vertical gaps
+// between the lines are kept with blank lines. The text is assumed to be upright.
+func (pt PageText) LayoutText() string {
+	lines := layoutLines(pt.viewMarks)
+	if len(lines) == 0 {
+		return ""
+	}
+
+	var widths, heights []float64
+	minX := math.MaxFloat64
+	for _, l := range lines {
+		for _, w := range l.words {
+			minX = math.Min(minX, w.x)
+			if n := utf8.RuneCountInString(w.text); w.width > 0 && n > 0 {
+				widths = append(widths, w.width/float64(n))
+			}
+		}
+		if l.height > 0 {
+			heights = append(heights, l.height)
+		}
+	}
+	cellWidth := median(widths)
+	lineHeight := median(heights)
+	if cellWidth <= 0 {
+		cellWidth = 1
+	}
+
+	var b strings.Builder
+	for i, l := range lines {
+		if i > 0 && lineHeight > 0 {
+			// Lines more than a line and a half apart are separated by blank lines.
+			gap := lines[i-1].y - l.y
+			for n := int(math.Round(gap/(1.5*lineHeight))) - 1; n > 0; n-- {
+				b.WriteString("\n")
+			}
+		}
+
+		var row []rune
+		for j, w := range l.words {
+			col := int(math.Round((w.x - minX) / cellWidth))
+			if j > 0 {
+				// The words of a phrase are separated by single spaces and the overlapping words
+				// are nudged to the right, after a space.
+				prev := l.words[j-1]
+				if w.x-(prev.x+prev.width) < 2*cellWidth || col <= len(row) {
+					col = len(row) + 1
+				}
+			}
+			for len(row) < col {
+				row = append(row, ' ')
+			}
+			row = append(row, []rune(w.text)...)
+		}
+		b.WriteString(strings.TrimRight(string(row), " "))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// layoutWord is a word of a line of text in layout mode.
+type layoutWord struct {
+	text  string
+	x     float64 // Left edge of the word.
+	width float64 // Width of the word.
+}
+
+// layoutLine is a line of text in layout mode.
+type layoutLine struct {
+	words  []layoutWord
+	y      float64 // Baseline of the line, the bottom of its first mark.
+	height float64 // Maximum height of the marks of the line.
+}
+
+// layoutLines returns the lines of words of the text marks `marks`, as ordered by computeViews:
+// the lines are separated by line break marks and the words by space marks or marks of spaces.
+func layoutLines(marks []TextMark) []layoutLine {
+	var lines []layoutLine
+	var line *layoutLine
+	var word *layoutWord
+	for _, tm := range marks {
+		if tm.Meta && tm.Text == lineJoiner {
+			line, word = nil, nil
+			continue
+		}
+		if isTextSpace(tm.Text) {
+			word = nil
+			continue
+		}
+		if line == nil {
+			lines = append(lines, layoutLine{y: tm.BBox.Lly})
+			line = &lines[len(lines)-1]
+		}
+		line.height = math.Max(line.height, tm.BBox.Ury-tm.BBox.Lly)
+		if word == nil {
+			line.words = append(line.words, layoutWord{x: tm.BBox.Llx})
+			word = &line.words[len(line.words)-1]
+		}
+		word.text += tm.Text
+		word.width = tm.BBox.Urx - word.x
+	}
+	return lines
+}
+
+// median returns the median of `values`, or 0 if there are none.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
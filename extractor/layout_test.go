@@ -0,0 +1,162 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// updateLayoutGolden is set to regenerate the golden files of the layout mode tests.
+var updateLayoutGolden = flag.Bool("extractor-update-goldens", false, "update the layout mode golden files")
+
+// layoutRow returns the content stream drawing the texts `cells` at the x positions `xs` on the
+// line with baseline `y`.
+func layoutRow(y float64, xs []float64, cells ...string) string {
+	var b strings.Builder
+	for i, cell := range cells {
+		if cell == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "BT /Helv 10 Tf 1 0 0 1 %g %g Tm (%s) Tj ET\n", xs[i], y, cell)
+	}
+	return b.String()
+}
+
+// layoutTableContents returns the content stream of a table with a left aligned text column and
+// right aligned number columns, drawn with a proportional font.
+func layoutTableContents() string {
+	rows := [][]string{
+		{"Item", "Quantity", "Price"},
+		{"Apples", "12", "3.50"},
+		{"Pears", "7", "12.25"},
+		{"Watermelons", "1", "4.00"},
+		{"", "", "19.75"},
+	}
+	font := model.NewStandard14FontMustCompile(model.HelveticaName)
+	width := func(s string) float64 {
+		var w float64
+		for _, r := range s {
+			m, _ := font.GetRuneMetrics(r)
+			w += m.Wx * 10 / 1000
+		}
+		return w
+	}
+
+	var b strings.Builder
+	b.WriteString("BT /Helv 14 Tf 1 0 0 1 72 720 Tm (Order summary) Tj ET\n")
+	y := 690.0
+	for _, row := range rows {
+		// The numbers are right aligned at x=260 and x=340.
+		xs := []float64{72, 260 - width(row[1]), 340 - width(row[2])}
+		b.WriteString(layoutRow(y, xs, row...))
+		y -= 12
+	}
+	return b.String()
+}
+
+// layoutColumnsContents returns the content stream of a page with a title spanning two columns
+// of text of different lengths.
+func layoutColumnsContents() string {
+	left := []string{
+		"The left column starts",
+		"here and runs for",
+		"four short lines of",
+		"text.",
+	}
+	right := []string{
+		"The right column is",
+		"shorter.",
+	}
+	var b strings.Builder
+	b.WriteString("BT /Helv 10 Tf 1 0 0 1 72 720 Tm (Two columns) Tj ET\n")
+	for i := range left {
+		var r string
+		if i < len(right) {
+			r = right[i]
+		}
+		b.WriteString(layoutRow(690-12*float64(i), []float64{72, 252}, left[i], r))
+	}
+	b.WriteString("BT /Helv 10 Tf 1 0 0 1 72 600 Tm (Footer after a gap) Tj ET\n")
+	return b.String()
+}
+
+// TestLayoutText checks the layout mode text of the table and two column fixtures against the
+// golden files testdata/layout_*.txt.
+func TestLayoutText(t *testing.T) {
+	resources := model.NewPdfPageResources()
+	helvetica := model.NewStandard14FontMustCompile(model.HelveticaName)
+	resources.SetFontByName("Helv", helvetica.ToPdfObject())
+
+	testcases := []struct {
+		name     string
+		contents string
+	}{
+		{"table", layoutTableContents()},
+		{"columns", layoutColumnsContents()},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := Extractor{resources: resources, contents: tc.contents}
+			pt, _, _, err := e.ExtractPageText()
+			require.NoError(t, err)
+			text := pt.LayoutText()
+
+			golden := filepath.Join("testdata", "layout_"+tc.name+".txt")
+			if *updateLayoutGolden {
+				require.NoError(t, ioutil.WriteFile(golden, []byte(text), 0644))
+			}
+			expected, err := ioutil.ReadFile(golden)
+			require.NoError(t, err)
+			require.Equal(t, string(expected), text)
+		})
+	}
+}
+
+// TestLayoutTextPages checks the page breaks of the layout mode text of several pages.
+func TestLayoutTextPages(t *testing.T) {
+	resources := model.NewPdfPageResources()
+	helvetica := model.NewStandard14FontMustCompile(model.HelveticaName)
+	resources.SetFontByName("Helv", helvetica.ToPdfObject())
+
+	var pages []*PageText
+	for _, contents := range []string{
+		"BT /Helv 10 Tf 1 0 0 1 72 720 Tm (Page one) Tj ET",
+		"",
+		"BT /Helv 10 Tf 1 0 0 1 72 720 Tm (Page three) Tj ET",
+	} {
+		e := Extractor{resources: resources, contents: contents}
+		pt, _, _, err := e.ExtractPageText()
+		require.NoError(t, err)
+		pages = append(pages, pt)
+	}
+
+	require.Equal(t, "Page one\nPage three\n", LayoutText(pages, nil))
+	require.Equal(t, "Page one\n\f\fPage three\n\f", LayoutText(pages, &LayoutOptions{FormFeeds: true}))
+}
+
+// TestLayoutTextOverlap checks that the words whose columns would overlap the previous words of
+// their lines, e.g. after narrow characters, are moved to the right.
+func TestLayoutTextOverlap(t *testing.T) {
+	resources := model.NewPdfPageResources()
+	helvetica := model.NewStandard14FontMustCompile(model.HelveticaName)
+	resources.SetFontByName("Helv", helvetica.ToPdfObject())
+
+	contents := layoutRow(700, []float64{72, 110}, "iiiiiiiiiiii", "far") +
+		layoutRow(688, []float64{72, 110}, "Next", "line")
+	e := Extractor{resources: resources, contents: contents}
+	pt, _, _, err := e.ExtractPageText()
+	require.NoError(t, err)
+	require.Equal(t, "iiiiiiiiiiii far\nNext      line\n", pt.LayoutText())
+}
@@ -0,0 +1,97 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// repairFixture returns a two-page PDF file with text on each page, and the
+// offsets of its cross-reference table and of its startxref value.
+func repairFixture() ([]byte, int, int) {
+	content := func(text string) string {
+		stream := fmt.Sprintf("BT /F1 24 Tf 72 700 Td (%s) Tj ET", text)
+		return fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream)
+	}
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 5 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 7 0 R >> >> >>",
+		content("Hello repaired world"),
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 6 0 R /Resources << /Font << /F1 7 0 R >> >> >>",
+		content("Second page"),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f\r\n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n\r\n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n", len(objects)+1)
+	startxref := buf.Len()
+	fmt.Fprintf(&buf, "%d\n%%%%EOF\n", xref)
+	return buf.Bytes(), xref, startxref
+}
+
+// TestRepairedTextExtraction tests that files with broken cross-reference
+// tables are repaired when read, and that their text can be extracted.
+func TestRepairedTextExtraction(t *testing.T) {
+	data, xref, startxref := repairFixture()
+	offByN := func(n int) []byte {
+		return []byte(fmt.Sprintf("%s%d\n%%%%EOF\n", data[:startxref], xref+n))
+	}
+
+	testcases := []struct {
+		name     string
+		data     []byte
+		repaired bool
+	}{
+		{"intact", data, false},
+		{"truncated xref", data[:xref+60], true},
+		{"startxref off by 5", offByN(5), true},
+		{"startxref off by -40", offByN(-40), true},
+		{"shifted objects", bytes.Replace(data, []byte("%PDF-1.4\n"),
+			[]byte("%PDF-1.4\n%"+strings.Repeat("garbage ", 20)+"\n"), 1), true},
+		{"missing trailer", bytes.Replace(data, []byte("trailer"), []byte("xxxxxxx"), 1), true},
+	}
+	for _, tcase := range testcases {
+		t.Run(tcase.name, func(t *testing.T) {
+			reader, err := model.NewPdfReader(bytes.NewReader(tcase.data))
+			require.NoError(t, err)
+
+			var texts []string
+			numPages, err := reader.GetNumPages()
+			require.NoError(t, err)
+			require.Equal(t, 2, numPages)
+			for i := 1; i <= numPages; i++ {
+				page, err := reader.GetPage(i)
+				require.NoError(t, err)
+				ex, err := New(page)
+				require.NoError(t, err)
+				text, err := ex.ExtractText()
+				require.NoError(t, err)
+				texts = append(texts, text)
+			}
+			require.Equal(t, "Hello repaired world\nSecond page", strings.Join(texts, "\n"))
+			require.Equal(t, tcase.repaired, reader.WasRepaired())
+		})
+	}
+}
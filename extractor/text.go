@@ -6,8 +6,10 @@
 package extractor
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"image/color"
 	"math"
 	"sort"
 	"strings"
@@ -16,6 +18,7 @@ import (
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/contentstream"
 	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/textencoding"
 	"github.com/unidoc/unipdf/v3/internal/transform"
 	"github.com/unidoc/unipdf/v3/model"
 	"golang.org/x/text/unicode/norm"
@@ -35,6 +38,16 @@ func (e *Extractor) ExtractText() (string, error) {
 	return text, err
 }
 
+// ExtractTextWithContext works like ExtractText but stops processing the content streams when
+// `ctx` is cancelled or its deadline is exceeded, returning an error wrapping the error of `ctx`.
+func (e *Extractor) ExtractTextWithContext(ctx context.Context) (string, error) {
+	pageText, _, _, err := e.ExtractPageTextWithContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return pageText.Text(), nil
+}
+
 // ExtractTextWithStats works like ExtractText but returns the number of characters in the output
 // (`numChars`) and the number of characters that were not decoded (`numMisses`).
 func (e *Extractor) ExtractTextWithStats() (extracted string, numChars int, numMisses int, err error) {
@@ -47,7 +60,17 @@ func (e *Extractor) ExtractTextWithStats() (extracted string, numChars int, numM
 
 // ExtractPageText returns the text contents of `e` (an Extractor for a page) as a PageText.
 func (e *Extractor) ExtractPageText() (*PageText, int, int, error) {
-	pt, numChars, numMisses, err := e.extractPageText(e.contents, e.resources, transform.IdentityMatrix(), 0)
+	return e.ExtractPageTextWithContext(context.Background())
+}
+
+// ExtractPageTextWithContext works like ExtractPageText but stops processing the content streams,
+// including those of the form XObjects and Type3 glyphs, when `ctx` is cancelled or its deadline
+// is exceeded, returning an error wrapping the error of `ctx`.
+func (e *Extractor) ExtractPageTextWithContext(ctx context.Context) (*PageText, int, int, error) {
+	e.ctx = ctx
+	defer func() { e.ctx = nil }()
+	pt, numChars, numMisses, err := e.extractPageText(e.contents, e.resources, transform.IdentityMatrix(),
+		e.pageClip(), 1, 0)
 	if err != nil {
 		return nil, numChars, numMisses, err
 	}
@@ -58,10 +81,11 @@ func (e *Extractor) ExtractPageText() (*PageText, int, int, error) {
 }
 
 // extractPageText returns the text contents of content stream `e` and resouces `resources` as a
-// PageText.
+// PageText. `parentCTM` maps the content stream coordinates to device coordinates, `parentClip`
+// is the device clip of the content stream and `parentAlpha` the constant alpha it is drawn with.
 // This can be called on a page or a form XObject.
-func (e *Extractor) extractPageText(contents string, resources *model.PdfPageResources, parentCTM transform.Matrix, level int) (
-	*PageText, int, int, error) {
+func (e *Extractor) extractPageText(contents string, resources *model.PdfPageResources, parentCTM transform.Matrix,
+	parentClip *model.PdfRectangle, parentAlpha float64, level int) (*PageText, int, int, error) {
 	common.Log.Trace("extractPageText: level=%d", level)
 	pageText := &PageText{}
 	state := newTextState()
@@ -72,18 +96,31 @@ func (e *Extractor) extractPageText(contents string, resources *model.PdfPageRes
 	cstreamParser := contentstream.NewContentStreamParser(contents)
 	operations, err := cstreamParser.Parse()
 	if err != nil {
-		common.Log.Debug("ERROR: extractPageText parse failed. err=%v", err)
+		e.log().Warn("unable to parse content stream", "level", level, "error", err)
 		return pageText, state.numChars, state.numMisses, err
 	}
 
-	processor := contentstream.NewContentStreamProcessor(*operations)
+	processor := e.newProcessor(*operations)
 
 	processor.AddHandler(contentstream.HandlerConditionEnumAllOperands, "",
 		func(op *contentstream.ContentStreamOperation, gs contentstream.GraphicsState,
 			resources *model.PdfPageResources) error {
+			if gs.InCharProc {
+				// The text of Type3 glyphs is that of the text showing operators.
+				return nil
+			}
 
 			operand := op.Operand
 
+			// The colors can be set inside text objects.
+			to.gs.ColorspaceStroking = gs.ColorspaceStroking
+			to.gs.ColorspaceNonStroking = gs.ColorspaceNonStroking
+			to.gs.ColorStroking = gs.ColorStroking
+			to.gs.ColorNonStroking = gs.ColorNonStroking
+			// So can the constant alphas, which are combined with that of the parent.
+			to.gs.AlphaStroking = parentAlpha * gs.AlphaStroking
+			to.gs.AlphaNonStroking = parentAlpha * gs.AlphaNonStroking
+
 			switch operand {
 			case "q":
 				if !fontStack.empty() {
@@ -121,6 +158,7 @@ func (e *Extractor) extractPageText(contents string, resources *model.PdfPageRes
 
 				graphicsState := gs
 				graphicsState.CTM = parentCTM.Mult(graphicsState.CTM)
+				graphicsState.Clip = deviceClip(parentCTM, gs.Clip, parentClip)
 				to = newTextObject(e, resources, graphicsState, &state, &fontStack)
 			case "ET": // End Text
 				// End text object, discarding text matrix. If the current
@@ -317,17 +355,19 @@ func (e *Extractor) extractPageText(contents string, resources *model.PdfPageRes
 				if xtype != model.XObjectTypeForm {
 					break
 				}
-				// Only process each form once.
+				// Only process each form once, unless the text is clipped or discarded when invisible,
+				// which depends on where and how the form is drawn, or the glyphs are processed.
 				formResult, ok := e.formResults[name.String()]
-				if !ok {
+				if !ok || e.options.DiscardClipped || e.options.DiscardInvisible || e.glyphHandler != nil {
 					xform, err := resources.GetXObjectFormByName(*name)
 					if err != nil {
-						common.Log.Debug("ERROR: %v", err)
+						e.log().Warn("unable to load form XObject", "name", name.String(), "error", err)
 						return err
 					}
 					formContent, err := xform.GetContentStream()
 					if err != nil {
-						common.Log.Debug("ERROR: %v", err)
+						e.log().Warn("unable to decode form XObject content stream", "name", name.String(),
+							"error", err)
 						return err
 					}
 					formResources := xform.Resources
@@ -335,14 +375,20 @@ func (e *Extractor) extractPageText(contents string, resources *model.PdfPageRes
 						formResources = resources
 					}
 
+					formCTM := parentCTM.Mult(gs.CTM).Mult(toMatrix(xform.Matrix))
+					clip := formClip(xform, formCTM, deviceClip(parentCTM, gs.Clip, parentClip))
 					tList, numChars, numMisses, err := e.extractPageText(string(formContent),
-						formResources, parentCTM.Mult(gs.CTM), level+1)
+						formResources, formCTM, clip, parentAlpha*gs.AlphaNonStroking, level+1)
 					if err != nil {
-						common.Log.Debug("ERROR: %v", err)
+						e.log().Debug("unable to extract form XObject text", "name", name.String(),
+							"level", level+1, "error", err)
 						return err
 					}
 					formResult = textResult{*tList, numChars, numMisses}
-					e.formResults[name.String()] = formResult
+					if e.glyphHandler == nil {
+						// Processing glyphs does not change the results of the text extraction.
+						e.formResults[name.String()] = formResult
+					}
 				}
 
 				pageText.marks = append(pageText.marks, formResult.pageText.marks...)
@@ -352,9 +398,9 @@ func (e *Extractor) extractPageText(contents string, resources *model.PdfPageRes
 			return nil
 		})
 
-	err = processor.Process(resources)
+	err = e.process(processor, resources)
 	if err != nil {
-		common.Log.Debug("ERROR: Processing: err=%v", err)
+		e.log().Debug("unable to process content stream", "level", level, "error", err)
 	}
 	return pageText, state.numChars, state.numMisses, err
 }
@@ -472,6 +518,12 @@ func (to *textObject) setFont(name string, size float64) error {
 	font, err := to.getFont(name)
 	if err == nil {
 		to.state.tfont = font
+		if to.e.glyphHandler != nil {
+			if to.state.fontNames == nil {
+				to.state.fontNames = map[*model.PdfFont]core.PdfObjectName{}
+			}
+			to.state.fontNames[font] = core.PdfObjectName(name)
+		}
 		if len(*to.fontStack) == 0 {
 			to.fontStack.push(font)
 		} else {
@@ -633,9 +685,11 @@ type textState struct {
 	th    float64        // Horizontal scaling.
 	tl    float64        // Leading. Unscaled text space units. Used by TD,T*,'," see Table 108.
 	tfs   float64        // Text font size.
-	tmode RenderMode     // Text rendering mode.
+	tmode RenderMode     // Text rendering mode, as the operand of Tr.
 	trise float64        // Text rise. Unscaled text space units. Set by Ts.
 	tfont *model.PdfFont // Text font.
+	// Names of the fonts in the font resources, as the operands of Tf, when processing glyphs.
+	fontNames map[*model.PdfFont]core.PdfObjectName
 	// For debugging
 	numChars  int
 	numMisses int
@@ -671,8 +725,8 @@ type textObject struct {
 // newTextState returns a default textState.
 func newTextState() textState {
 	return textState{
-		th:    100,
-		tmode: RenderModeFill,
+		th: 100,
+		// tmode is set by the Tr operator, whose initial value 0 is fill.
 	}
 }
 
@@ -751,8 +805,15 @@ func (to *textObject) renderText(data []byte) error {
 		}
 
 		m, ok := font.GetCharMetrics(code)
+		type3, isType3 := to.e.getType3GlyphMetrics(font, code)
+		if isType3 {
+			// The width set by the glyph description is used to position the next glyph.
+			m.Wx = type3.Width * font.FontMatrix()[0] / glyphTextRatio
+			ok = true
+		}
 		if !ok {
-			common.Log.Debug("ERROR: No metric for code=%d r=0x%04x=%+q %s", code, r, r, font)
+			to.e.log().Warn("glyph metrics not found", "font", font.BaseFont(), "code", code,
+				"rune", fmt.Sprintf("%+q", r))
 			return fmt.Errorf("no char metrics: font=%s code=%d", font.String(), code)
 		}
 
@@ -780,10 +841,16 @@ func (to *textObject) renderText(data []byte) error {
 			math.Abs(spaceWidth*trm.ScalingFactorX()),
 			font,
 			to.state.tc)
+		if isType3 && type3.BBox != nil {
+			// The glyph bounding boxes are in glyph space.
+			fm := font.FontMatrix()
+			fontMatrix := transform.NewMatrix(fm[0], fm[1], fm[2], fm[3], fm[4], fm[5])
+			mark.bbox = type3.BBox.Transform(trm.Mult(fontMatrix))
+		}
 		if font == nil {
-			common.Log.Debug("ERROR: No font.")
+			to.e.log().Debug("no font for text")
 		} else if font.Encoder() == nil {
-			common.Log.Debug("ERROR: No encoding. font=%s", font)
+			to.e.log().Debug("no encoding for font", "font", font.BaseFont())
 		} else {
 			original, ok := font.Encoder().CharcodeToRune(code)
 			if ok {
@@ -791,7 +858,15 @@ func (to *textObject) renderText(data []byte) error {
 			}
 		}
 		common.Log.Trace("i=%d code=%d mark=%s trm=%s", i, code, mark, trm)
-		to.marks = append(to.marks, mark)
+		if (!to.e.options.DiscardClipped || !isClipped(mark.bbox, to.gs.Clip)) &&
+			(!to.e.options.DiscardInvisible || !to.isTransparent()) {
+			to.marks = append(to.marks, mark)
+		}
+		if to.e.glyphHandler != nil {
+			if err := to.e.glyphHandler(to.newGlyph(code, text, font, trm, t.X)); err != nil {
+				return err
+			}
+		}
 
 		// update the text matrix by the displacement of the text location.
 		to.tm.Concat(td)
@@ -801,6 +876,20 @@ func (to *textObject) renderText(data []byte) error {
 	return nil
 }
 
+// isTransparent returns true if the text is painted with a constant alpha of 0 in the current
+// text rendering mode. Text rendered invisible (Tr 3 and 7) is not transparent.
+func (to *textObject) isTransparent() bool {
+	switch to.state.tmode % 4 {
+	case 0:
+		return to.gs.AlphaNonStroking == 0
+	case 1:
+		return to.gs.AlphaStroking == 0
+	case 2:
+		return to.gs.AlphaNonStroking == 0 && to.gs.AlphaStroking == 0
+	}
+	return false
+}
+
 // glyphTextRatio converts Glyph metrics units to unscaled text space units.
 const glyphTextRatio = 1.0 / 1000.0
 
@@ -838,6 +927,8 @@ type textMark struct {
 	font          *model.PdfFont     // The font the mark was drawn with.
 	fontsize      float64            // The font size the mark was drawn with.
 	charspacing   float64            // TODO (peterwilliams97: Should this be exposed in TextMark?
+	fillColor     color.Color        // The fill color the mark was drawn with, converted to RGB.
+	strokeColor   color.Color        // The stroke color the mark was drawn with, converted to RGB.
 	trm           transform.Matrix   // The current text rendering matrix (TRM above).
 	end           transform.Point    // The end of character device coordinates.
 	count         int64              // To help with reading debug logs.
@@ -881,6 +972,8 @@ func (to *textObject) newTextMark(text string, trm transform.Matrix, end transfo
 		font:          font,
 		fontsize:      to.state.tfs,
 		charspacing:   charspacing,
+		fillColor:     toRGBColor(to.gs.ColorspaceNonStroking, to.gs.ColorNonStroking),
+		strokeColor:   toRGBColor(to.gs.ColorspaceStroking, to.gs.ColorStroking),
 		trm:           trm,
 		end:           end,
 		count:         to.e.textCount,
@@ -891,6 +984,27 @@ func (to *textObject) newTextMark(text string, trm transform.Matrix, end transfo
 	return tm
 }
 
+// toRGBColor returns color `col` of colorspace `cs` converted to RGB, or nil if it cannot be
+// converted, e.g. for pattern colors.
+func toRGBColor(cs model.PdfColorspace, col model.PdfColor) color.Color {
+	if cs == nil || col == nil {
+		return nil
+	}
+	rgbColor, err := cs.ColorToRGB(col)
+	if err != nil {
+		common.Log.Debug("ERROR: could not convert color %v of %s to RGB. err=%v", col, cs, err)
+		return nil
+	}
+	rgb, ok := rgbColor.(*model.PdfColorDeviceRGB)
+	if !ok {
+		return nil
+	}
+	toByte := func(v float64) uint8 {
+		return uint8(math.Round(255 * math.Max(0, math.Min(1, v))))
+	}
+	return color.RGBA{R: toByte(rgb.R()), G: toByte(rgb.G()), B: toByte(rgb.B()), A: 255}
+}
+
 // isTextSpace returns true if `text` contains nothing but space code points.
 func isTextSpace(text string) bool {
 	for _, r := range text {
@@ -925,11 +1039,13 @@ func (tm textMark) Width() float64 {
 // ToTextMark returns the public view of `tm`.
 func (tm textMark) ToTextMark() TextMark {
 	return TextMark{
-		Text:     tm.text,
-		Original: tm.original,
-		BBox:     tm.bbox,
-		Font:     tm.font,
-		FontSize: tm.fontsize,
+		Text:        tm.text,
+		Original:    tm.original,
+		BBox:        tm.bbox,
+		Font:        tm.font,
+		FontSize:    tm.fontsize,
+		FillColor:   tm.fillColor,
+		StrokeColor: tm.strokeColor,
 	}
 }
 
@@ -1055,21 +1171,11 @@ func (ma *TextMarkArray) BBox() (model.PdfRectangle, bool) {
 		if isTextSpace(tm.Text) {
 			continue
 		}
-		bbox = rectUnion(bbox, tm.BBox)
+		bbox = bbox.Union(tm.BBox)
 	}
 	return bbox, true
 }
 
-// rectUnion returns the smallest axis-aligned rectangle that contains `b1` and `b2`.
-func rectUnion(b1, b2 model.PdfRectangle) model.PdfRectangle {
-	return model.PdfRectangle{
-		Llx: math.Min(b1.Llx, b2.Llx),
-		Lly: math.Min(b1.Lly, b2.Lly),
-		Urx: math.Max(b1.Urx, b2.Urx),
-		Ury: math.Max(b1.Ury, b2.Ury),
-	}
-}
-
 // TextMark represents extracted text on a page with information regarding both textual content,
 // formatting (font and size) and positioning.
 // It is the smallest unit of text on a PDF page, typically a single character.
@@ -1102,6 +1208,11 @@ type TextMark struct {
 	Font *model.PdfFont
 	// FontSize is the font size the text was drawn with.
 	FontSize float64
+	// FillColor is the fill color the text was drawn with, converted to RGB. It is nil if the
+	// color could not be converted, e.g. for pattern colors.
+	FillColor color.Color
+	// StrokeColor is the stroke color the text was drawn with, converted to RGB.
+	StrokeColor color.Color
 	// Offset is the offset of the start of TextMark.Text in the extracted text. If you do this
 	//   text, textMarks := pageText.Text(), pageText.Marks()
 	//   marks := textMarks.Elements()
@@ -1617,9 +1728,13 @@ func (to *textObject) getCurrentFont() *model.PdfFont {
 // getFont returns the font named `name` if it exists in the page's resources or an error if it
 // doesn't. It caches the returned fonts.
 func (to *textObject) getFont(name string) (*model.PdfFont, error) {
+	fontObj, err := to.getFontDict(name)
+	if err != nil {
+		return nil, err
+	}
 	if to.e.fontCache != nil {
 		to.e.accessCount++
-		entry, ok := to.e.fontCache[name]
+		entry, ok := to.e.fontCache[fontObj]
 		if ok {
 			entry.access = to.e.accessCount
 			return entry.font, nil
@@ -1627,8 +1742,9 @@ func (to *textObject) getFont(name string) (*model.PdfFont, error) {
 	}
 
 	// Font not in cache. Load it.
-	font, err := to.getFontDirect(name)
+	font, err := model.NewPdfFontFromPdfObject(fontObj)
 	if err != nil {
+		common.Log.Debug("getFont: NewPdfFontFromPdfObject failed. name=%#q err=%v", name, err)
 		return nil, err
 	}
 
@@ -1637,21 +1753,51 @@ func (to *textObject) getFont(name string) (*model.PdfFont, error) {
 
 		// Eject a victim if the cache is full.
 		if len(to.e.fontCache) >= maxFontCache {
-			var names []string
-			for name := range to.e.fontCache {
-				names = append(names, name)
+			var victim core.PdfObject
+			for obj, e := range to.e.fontCache {
+				if victim == nil || e.access < to.e.fontCache[victim].access {
+					victim = obj
+				}
 			}
-			sort.Slice(names, func(i, j int) bool {
-				return to.e.fontCache[names[i]].access < to.e.fontCache[names[j]].access
-			})
-			delete(to.e.fontCache, names[0])
+			delete(to.e.fontCache, victim)
 		}
-		to.e.fontCache[name] = entry
+		to.e.fontCache[fontObj] = entry
 	}
 
 	return font, nil
 }
 
+// type3GlyphKey identifies a glyph of a Type3 font.
+type type3GlyphKey struct {
+	font *model.PdfFont
+	code textencoding.CharCode
+}
+
+// getType3GlyphMetrics returns the metrics of the glyph of character code `code` of `font` if it
+// is a Type3 font with a glyph description for `code`.
+func (e *Extractor) getType3GlyphMetrics(font *model.PdfFont, code textencoding.CharCode) (
+	contentstream.Type3GlyphMetrics, bool) {
+	if font.Subtype() != "Type3" {
+		return contentstream.Type3GlyphMetrics{}, false
+	}
+	key := type3GlyphKey{font: font, code: code}
+	if entry, ok := e.type3Metrics[key]; ok {
+		return entry.metrics, entry.ok
+	}
+	metrics, ok := contentstream.GetType3GlyphMetrics(font, code)
+	if e.type3Metrics == nil {
+		e.type3Metrics = map[type3GlyphKey]type3MetricsEntry{}
+	}
+	e.type3Metrics[key] = type3MetricsEntry{metrics: metrics, ok: ok}
+	return metrics, ok
+}
+
+// type3MetricsEntry is an entry in the cache of the metrics of Type3 glyphs.
+type type3MetricsEntry struct {
+	metrics contentstream.Type3GlyphMetrics
+	ok      bool
+}
+
 // fontEntry is a entry in the font cache.
 type fontEntry struct {
 	font   *model.PdfFont // The font being cached.
@@ -1661,20 +1807,6 @@ type fontEntry struct {
 // maxFontCache is the maximum number of PdfFont's in fontCache.
 const maxFontCache = 10
 
-// getFontDirect returns the font named `name` if it exists in the page's resources or an error if
-// it doesn't. Accesses page resources directly (not cached).
-func (to *textObject) getFontDirect(name string) (*model.PdfFont, error) {
-	fontObj, err := to.getFontDict(name)
-	if err != nil {
-		return nil, err
-	}
-	font, err := model.NewPdfFontFromPdfObject(fontObj)
-	if err != nil {
-		common.Log.Debug("getFontDirect: NewPdfFontFromPdfObject failed. name=%#q err=%v", name, err)
-	}
-	return font, err
-}
-
 // getFontDict returns the font dict with key `name` if it exists in the page's or form's Font
 // resources or an error if it doesn't.
 func (to *textObject) getFontDict(name string) (fontObj core.PdfObject, err error) {
@@ -1685,7 +1817,7 @@ func (to *textObject) getFontDict(name string) (fontObj core.PdfObject, err erro
 	}
 	fontObj, found := resources.GetFontByName(core.PdfObjectName(name))
 	if !found {
-		common.Log.Debug("ERROR: getFontDict: Font not found: name=%#q", name)
+		to.e.log().Warn("font not found in resources", "name", name)
 		return nil, errors.New("font not in resources")
 	}
 	return fontObj, nil
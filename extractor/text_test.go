@@ -6,21 +6,27 @@
 package extractor
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"image/color"
 	"io"
 	"io/ioutil"
 	"math"
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"sort"
 	"strings"
 	"testing"
 
 	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
 	"github.com/unidoc/unipdf/v3/creator"
 	"github.com/unidoc/unipdf/v3/internal/transform"
 	"github.com/unidoc/unipdf/v3/model"
@@ -139,6 +145,211 @@ func TestTextExtractionFragments(t *testing.T) {
 	}
 }
 
+// TestTextColors tests that the text marks report the colors the text is drawn with, converted to
+// RGB, including colors set inside text objects and in Separation and Indexed colorspaces.
+func TestTextColors(t *testing.T) {
+	tintTransform := core.MakeDict()
+	tintTransform.Set("FunctionType", core.MakeInteger(2))
+	tintTransform.Set("Domain", core.MakeArrayFromFloats([]float64{0, 1}))
+	tintTransform.Set("C0", core.MakeArrayFromFloats([]float64{1, 1, 1}))
+	tintTransform.Set("C1", core.MakeArrayFromFloats([]float64{0, 0.5, 0}))
+	tintTransform.Set("N", core.MakeInteger(1))
+	separation, err := model.NewPdfColorspaceFromPdfObject(core.MakeArray(core.MakeName("Separation"),
+		core.MakeName("Green"), core.MakeName("DeviceRGB"), tintTransform))
+	if err != nil {
+		t.Fatalf("Error loading Separation colorspace: %v", err)
+	}
+	indexed, err := model.NewPdfColorspaceFromPdfObject(core.MakeArray(core.MakeName("Indexed"),
+		core.MakeName("DeviceRGB"), core.MakeInteger(1), core.MakeHexString("\xff\x00\x00\x00\x00\xff")))
+	if err != nil {
+		t.Fatalf("Error loading Indexed colorspace: %v", err)
+	}
+
+	resources := model.NewPdfPageResources()
+	resources.SetFontByName("UniDocCourier", model.NewStandard14FontMustCompile(model.CourierName).ToPdfObject())
+	colorspaces := model.NewPdfPageResourcesColorspaces()
+	colorspaces.Set("CS0", separation)
+	colorspaces.Set("CS1", indexed)
+	resources.SetColorSpace(colorspaces)
+
+	contents := `
+        /CS0 cs 0.5 scn
+        BT
+        /UniDocCourier 24 Tf
+        (A)Tj
+        1 scn 0 0 1 RG
+        (B)Tj
+        ET
+        /CS1 cs
+        BT
+        /UniDocCourier 24 Tf
+        (C)Tj
+        1 scn
+        (D)Tj
+        0.2 0.4 0.6 0 k
+        (E)Tj
+        ET
+        `
+	e := Extractor{resources: resources, contents: contents}
+	pageText, _, _, err := e.ExtractPageText()
+	if err != nil {
+		t.Fatalf("Error extracting text: %v", err)
+	}
+
+	// The stroke color set in the first text object is kept in the graphics state.
+	black, blue := color.RGBA{A: 255}, color.RGBA{B: 255, A: 255}
+	expected := map[string][2]color.Color{
+		"A": {color.RGBA{R: 128, G: 191, B: 128, A: 255}, black},
+		"B": {color.RGBA{G: 128, A: 255}, blue},
+		"C": {color.RGBA{R: 255, A: 255}, blue},
+		"D": {blue, blue},
+		"E": {color.RGBA{R: 204, G: 153, B: 102, A: 255}, blue},
+	}
+	for _, mark := range pageText.Marks().Elements() {
+		colors, ok := expected[mark.Text]
+		if !ok {
+			continue
+		}
+		if mark.FillColor != colors[0] || mark.StrokeColor != colors[1] {
+			t.Fatalf("Color mismatch: %q Got %v %v. Expected %v %v", mark.Text,
+				mark.FillColor, mark.StrokeColor, colors[0], colors[1])
+		}
+		delete(expected, mark.Text)
+	}
+	if len(expected) != 0 {
+		t.Fatalf("Marks not found: %v", expected)
+	}
+}
+
+// TestTextInvalidOperations tests that the text shown around operations with invalid operands is
+// extracted with the validation policies skipping or coercing them.
+func TestTextInvalidOperations(t *testing.T) {
+	resources := model.NewPdfPageResources()
+	resources.SetFontByName("UniDocCourier", model.NewStandard14FontMustCompile(model.CourierName).ToPdfObject())
+
+	contents := `
+        BT
+        /UniDocCourier 24 Tf
+        100 500 Td
+        (Hello)Tj
+        1 0 0 cm
+        /F1 Tf
+        7 0 -30 Td
+        (broken) 12 Tj
+        2.0 Tr
+        (World)Tj
+        ET
+        `
+	testcases := []struct {
+		policy   contentstream.ValidationPolicy
+		text     string
+		stats    contentstream.ValidationStats
+		hasError bool
+	}{
+		{contentstream.ValidationSkipOperation, "HelloWorld", contentstream.ValidationStats{Skipped: 5}, false},
+		{contentstream.ValidationBestEffort, "Hello\nWorld", contentstream.ValidationStats{Skipped: 3, Coerced: 2}, false},
+		{contentstream.ValidationStrict, "", contentstream.ValidationStats{}, true},
+	}
+	for _, tc := range testcases {
+		e := Extractor{resources: resources, contents: contents,
+			options: Options{ValidationPolicy: tc.policy}}
+		pageText, _, _, err := e.ExtractPageText()
+		if tc.hasError {
+			if !errors.Is(err, contentstream.ErrInvalidOperand) {
+				t.Fatalf("policy=%d: Expected ErrInvalidOperand. Got %v", tc.policy, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("policy=%d: Error extracting text: %v", tc.policy, err)
+		}
+		if text := pageText.Text(); text != tc.text {
+			t.Fatalf("policy=%d: Text mismatch. Got %q. Expected %q", tc.policy, text, tc.text)
+		}
+		if stats := e.ValidationStats(); stats != tc.stats {
+			t.Fatalf("policy=%d: Stats mismatch. Got %+v. Expected %+v", tc.policy, stats, tc.stats)
+		}
+	}
+}
+
+// TestTextExtractionContext checks that text extraction returns the error of a cancelled context
+// without processing a large content stream, and extracts the text otherwise.
+func TestTextExtractionContext(t *testing.T) {
+	resources := model.NewPdfPageResources()
+	resources.SetFontByName("UniDocCourier", model.NewStandard14FontMustCompile(model.CourierName).ToPdfObject())
+	contents := strings.Repeat("BT /UniDocCourier 12 Tf 100 500 Td (Hello) Tj ET\n", 100000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e := Extractor{resources: resources, contents: contents}
+	text, err := e.ExtractTextWithContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled. Got %v", err)
+	}
+	if text != "" {
+		t.Fatalf("Expected no text. Got %q", text)
+	}
+
+	e = Extractor{resources: resources, contents: contents[:len(contents)/10000]}
+	text, err = e.ExtractTextWithContext(context.Background())
+	if err != nil {
+		t.Fatalf("Error extracting text: %v", err)
+	}
+	if text != "Hello" {
+		t.Fatalf("Text mismatch. Got %q. Expected %q", text, "Hello")
+	}
+}
+
+// TestTextExtractionLogger checks that the extraction logs to the logger of the reader of the
+// page, with the page number, or to the logger of the options.
+func TestTextExtractionLogger(t *testing.T) {
+	contents := "BT /F9 12 Tf 10 10 Td (Hello) Tj ET"
+	data := "%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+		"2 0 obj\n<< /Type /Pages /Kids [3 0 R] /Count 1 >>\nendobj\n" +
+		"3 0 obj\n<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] /Resources << >> /Contents 4 0 R >>\nendobj\n" +
+		fmt.Sprintf("4 0 obj\n<< /Length %d >>\nstream\n%s\nendstream\nendobj\n%%%%EOF\n", len(contents), contents)
+
+	logger := common.NewRecordingLogger()
+	reader, err := model.NewPdfReaderWithOpts(strings.NewReader(data), &model.ReaderOpts{Logger: logger})
+	if err != nil {
+		t.Fatalf("Error reading PDF: %v", err)
+	}
+	page, err := reader.GetPage(1)
+	if err != nil {
+		t.Fatalf("Error getting page: %v", err)
+	}
+	e, err := New(page)
+	if err != nil {
+		t.Fatalf("Error creating extractor: %v", err)
+	}
+	if _, err := e.ExtractText(); err == nil {
+		t.Fatalf("Expected error for missing font")
+	}
+	records := logger.Find("font not found in resources")
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record. Got %+v", logger.Records())
+	}
+	expected := []interface{}{"page", 1, "name", "F9"}
+	if fields := records[0].Fields; !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("Fields mismatch. Got %v. Expected %v", fields, expected)
+	}
+
+	optsLogger := common.NewRecordingLogger()
+	e, err = NewWithOptions(page, &Options{Logger: optsLogger})
+	if err != nil {
+		t.Fatalf("Error creating extractor: %v", err)
+	}
+	if _, err := e.ExtractText(); err == nil {
+		t.Fatalf("Expected error for missing font")
+	}
+	if n := len(optsLogger.Find("font not found in resources")); n != 1 {
+		t.Fatalf("Expected 1 record. Got %d", n)
+	}
+	if n := len(logger.Find("font not found in resources")); n != 1 {
+		t.Fatalf("Expected 1 record. Got %d", n)
+	}
+}
+
 // TestTextExtractionFiles tests text extraction on a set of PDF files.
 // It checks for the existence of specified strings of words on specified pages.
 // We currently only check within lines as our line order is still improving.
@@ -0,0 +1,269 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"image/color"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// transparency is the transparency state content is painted with.
+// The constant alpha of forms is combined with that of their contents, as for transparency groups.
+type transparency struct {
+	alpha       float64                       // Constant alpha of non-stroking operations.
+	blendMode   core.PdfObjectName            // Blend mode.
+	softMask    *model.PdfSoftMask            // Soft mask, nil for none.
+	softMaskCTM transform.Matrix              // Maps the soft mask group to device coordinates.
+	groups      []*model.PdfTransparencyGroup // Groups of the enclosing forms, outermost first.
+}
+
+// pageTransparency is the transparency state of page content streams.
+var pageTransparency = transparency{alpha: 1, blendMode: "Normal"}
+
+// paint returns the transparency state content drawn with graphics state `gs` is painted with,
+// in a content stream mapped to device coordinates by `parentCTM` and painted with `t`.
+func (t transparency) paint(gs contentstream.GraphicsState, parentCTM transform.Matrix) transparency {
+	t.alpha *= gs.AlphaNonStroking
+	if gs.BlendMode != "Normal" {
+		t.blendMode = gs.BlendMode
+	}
+	if gs.SoftMask != nil {
+		t.softMask = gs.SoftMask
+		t.softMaskCTM = parentCTM.Mult(gs.SoftMaskCTM)
+	}
+	return t
+}
+
+// enterGroup returns the transparency state of the content of transparency group `group` painted
+// with `t`.
+func (t transparency) enterGroup(group *model.PdfTransparencyGroup) transparency {
+	groups := make([]*model.PdfTransparencyGroup, len(t.groups), len(t.groups)+1)
+	copy(groups, t.groups)
+	t.groups = append(groups, group)
+	return t
+}
+
+// imageAlpha returns the alpha values in [0, 1] of the samples of image `img` with soft mask image
+// `smask`, if not nil, painted with device CTM `ctm` and transparency state `tr`, or nil if the
+// image is opaque. The constant alpha of `tr` is not applied.
+func imageAlpha(img, smask *model.Image, ctm transform.Matrix, tr transparency) ([]float64, error) {
+	width, height := int(img.Width), int(img.Height)
+	if width <= 0 || height <= 0 {
+		return nil, nil
+	}
+
+	var alpha []float64
+	if smask != nil {
+		// The soft mask image is mapped to the unit square, as the image is.
+		smaskWidth, smaskHeight := int(smask.Width), int(smask.Height)
+		samples := smask.GetSamples()
+		if smaskWidth > 0 && smaskHeight > 0 && len(samples) >= smaskWidth*smaskHeight {
+			maxVal := float64(uint32(1)<<uint32(smask.BitsPerComponent) - 1)
+			alpha = make([]float64, width*height)
+			for j := 0; j < height; j++ {
+				y := j * smaskHeight / height
+				for i := 0; i < width; i++ {
+					x := i * smaskWidth / width
+					alpha[j*width+i] = float64(samples[y*smaskWidth+x]) / maxVal
+				}
+			}
+		} else {
+			common.Log.Debug("ERROR: invalid image soft mask %dx%d samples=%d",
+				smaskWidth, smaskHeight, len(samples))
+		}
+	}
+
+	if tr.softMask != nil {
+		values, err := softMaskValues(tr.softMask, tr.softMaskCTM, ctm, width, height)
+		if err != nil {
+			return alpha, err
+		}
+		if alpha == nil {
+			return values, nil
+		}
+		for i, val := range values {
+			alpha[i] *= val
+		}
+	}
+	return alpha, nil
+}
+
+// setImageAlpha sets the alpha channel of RGB image `img` to the values in [0, 1] of `alpha`.
+// Images with less than 8 bits per component are resampled to 8 bits per component.
+func setImageAlpha(img *model.Image, alpha []float64) {
+	if img.BitsPerComponent == 16 {
+		alphaData := make([]byte, 2*len(alpha))
+		for i, a := range alpha {
+			val := uint16(a*0xffff + 0.5)
+			alphaData[2*i], alphaData[2*i+1] = byte(val>>8), byte(val)
+		}
+		img.SetAlphaData(alphaData)
+		return
+	}
+	if img.BitsPerComponent != 8 {
+		img.Resample(8)
+	}
+	alphaData := make([]byte, len(alpha))
+	for i, a := range alpha {
+		alphaData[i] = byte(a*0xff + 0.5)
+	}
+	img.SetAlphaData(alphaData)
+}
+
+// allTransparent returns true if all the values of `alpha` are 0.
+func allTransparent(alpha []float64) bool {
+	for _, a := range alpha {
+		if a != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// softMaskValues returns the values of soft mask `smask`, whose transparency group is mapped to
+// device coordinates by `smaskCTM`, at the centers of the pixels of a `width` x `height` image
+// painted with device CTM `ctm`, in the order of the image samples.
+// The group is not rendered: the value at each point is derived from the last fill or shading of
+// the group that contains it, from its luminosity for luminosity masks and its coverage for alpha
+// masks. The backdrop color of luminosity masks is used where nothing is painted.
+func softMaskValues(smask *model.PdfSoftMask, smaskCTM, ctm transform.Matrix, width, height int) ([]float64, error) {
+	fills, err := softMaskFills(smask, smaskCTM)
+	if err != nil {
+		return nil, err
+	}
+	shadingInverses := make([]transform.Matrix, len(fills))
+	for i, fill := range fills {
+		if fill.Shading == nil {
+			continue
+		}
+		inv, ok := fill.shadingCTM.Inverse()
+		if !ok {
+			fills[i].Shading = nil
+		}
+		shadingInverses[i] = inv
+	}
+
+	luminosity := smask.S == "Luminosity"
+	var backdrop float64
+	if luminosity {
+		backdrop = softMaskBackdrop(smask)
+	}
+
+	values := make([]float64, width*height)
+	for j := 0; j < height; j++ {
+		for i := 0; i < width; i++ {
+			// Images are painted in the unit square with their first row at the top.
+			x, y := ctm.Transform((float64(i)+0.5)/float64(width), 1-(float64(j)+0.5)/float64(height))
+			val := backdrop
+			for k := len(fills) - 1; k >= 0; k-- {
+				fill := fills[k]
+				if x < fill.BBox.Llx || x > fill.BBox.Urx || y < fill.BBox.Lly || y > fill.BBox.Ury {
+					continue
+				}
+				col := fill.Color
+				if fill.Shading != nil {
+					sx, sy := shadingInverses[k].Transform(x, y)
+					rgb, err := fill.Shading.ColorAt(sx, sy)
+					if err != nil {
+						return nil, err
+					}
+					if rgb == nil {
+						// The shading does not paint the point.
+						continue
+					}
+					col = toRGBColor(model.NewPdfColorspaceDeviceRGB(), rgb)
+				}
+				if !luminosity {
+					val = 1
+					break
+				}
+				if col == nil {
+					continue
+				}
+				val = colorLuminosity(col)
+				break
+			}
+			if smask.TR != nil {
+				out, err := smask.TR.Evaluate([]float64{val})
+				if err != nil {
+					return nil, err
+				}
+				if len(out) > 0 {
+					val = out[0]
+				}
+			}
+			values[j*width+i] = val
+		}
+	}
+	return values, nil
+}
+
+// softMaskFills returns the fills of the transparency group of soft mask `smask`, mapped to
+// device coordinates by `smaskCTM`.
+func softMaskFills(smask *model.PdfSoftMask, smaskCTM transform.Matrix) ([]FillMark, error) {
+	content, err := smask.G.GetContentStream()
+	if err != nil {
+		return nil, err
+	}
+	resources := smask.G.Resources
+	if resources == nil {
+		resources = model.NewPdfPageResources()
+	}
+	formCTM := smaskCTM.Mult(toMatrix(smask.G.Matrix))
+	// The invalid operations of soft masks are skipped and not counted.
+	ctx := &fillExtractContext{e: &Extractor{}}
+	err = ctx.extractContentStreamFills(string(content), resources, formCTM, formClip(smask.G, formCTM, nil), 0)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.fills, nil
+}
+
+// softMaskBackdrop returns the luminosity of the backdrop color of luminosity soft mask `smask`,
+// black if not set.
+func softMaskBackdrop(smask *model.PdfSoftMask) float64 {
+	if len(smask.BC) == 0 {
+		return 0
+	}
+	var cs model.PdfColorspace
+	if smask.Group != nil && smask.Group.CS != nil {
+		cs = smask.Group.CS
+	} else {
+		switch len(smask.BC) {
+		case 1:
+			cs = model.NewPdfColorspaceDeviceGray()
+		case 3:
+			cs = model.NewPdfColorspaceDeviceRGB()
+		case 4:
+			cs = model.NewPdfColorspaceDeviceCMYK()
+		}
+	}
+	if cs == nil {
+		common.Log.Debug("ERROR: invalid soft mask backdrop %v", smask.BC)
+		return 0
+	}
+	col, err := cs.ColorFromFloats(smask.BC)
+	if err != nil {
+		common.Log.Debug("ERROR: invalid soft mask backdrop %v: %v", smask.BC, err)
+		return 0
+	}
+	rgb := toRGBColor(cs, col)
+	if rgb == nil {
+		return 0
+	}
+	return colorLuminosity(rgb)
+}
+
+// colorLuminosity returns the luminosity of `col` in [0, 1] (section 11.5.3 p. 336).
+func colorLuminosity(col color.Color) float64 {
+	r, g, b, _ := col.RGBA()
+	return (0.30*float64(r) + 0.59*float64(g) + 0.11*float64(b)) / 0xffff
+}
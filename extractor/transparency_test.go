@@ -0,0 +1,167 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/testutils"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// transparencyResources are the resources of the transparency tests: a gray image Im0, an RGB
+// image Im1 with soft mask image 12, a graphics state GS0 with a luminosity soft mask whose group
+// 10 is painted with a horizontal gradient from black to white, and a graphics state GS1 with a
+// constant alpha of 0.
+const transparencyResources = `
+1 0 obj
+<<
+	/Font << /F0 << /Type /Font /Subtype /Type1 /BaseFont /Courier >> >>
+	/XObject << /Im0 2 0 R /Im1 3 0 R >>
+	/ExtGState <<
+		/GS0 << /SMask << /S /Luminosity /G 10 0 R >> /BM /Multiply >>
+		/GS1 << /ca 0 >>
+	>>
+>>
+endobj
+2 0 obj
+<<
+	/Type /XObject /Subtype /Image
+	/Width 4 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8
+	/Filter /ASCIIHexDecode
+	/Length 9
+>>
+stream
+80808080>
+endstream
+endobj
+3 0 obj
+<<
+	/Type /XObject /Subtype /Image
+	/Width 2 /Height 1 /ColorSpace /DeviceRGB /BitsPerComponent 8
+	/Filter /ASCIIHexDecode
+	/Length 13
+>>
+stream
+FF0000FF0000>
+endstream
+endobj
+10 0 obj
+<<
+	/Type /XObject /Subtype /Form
+	/BBox [0 0 100 100]
+	/Group << /S /Transparency /CS /DeviceGray >>
+	/Resources <<
+		/Shading << /Sh0 <<
+			/ShadingType 2 /ColorSpace /DeviceGray /Coords [0 0 100 0]
+			/Function << /FunctionType 2 /Domain [0 1] /C0 [0] /C1 [1] /N 1 >>
+		>> >>
+	>>
+	/Length 7
+>>
+stream
+/Sh0 sh
+endstream
+endobj
+12 0 obj
+<<
+	/Type /XObject /Subtype /Image
+	/Width 2 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8
+	/Filter /ASCIIHexDecode
+	/Length 5
+>>
+stream
+00FF>
+endstream
+endobj
+`
+
+// loadTransparencyResources returns the resources of `transparencyResources`.
+func loadTransparencyResources(t *testing.T) *model.PdfPageResources {
+	objMap, err := testutils.ParseIndirectObjects(transparencyResources)
+	require.NoError(t, err)
+	resourceDict, ok := core.GetDict(objMap[1])
+	require.True(t, ok)
+	// The references of stream dictionaries are not resolved.
+	objMap[3].(*core.PdfObjectStream).Set("SMask", objMap[12])
+	resources, err := model.NewPdfPageResourcesFromDict(resourceDict)
+	require.NoError(t, err)
+	return resources
+}
+
+// TestImageSoftMasks tests that the soft masks of images and graphics states are extracted as the
+// alpha channels of images.
+func TestImageSoftMasks(t *testing.T) {
+	resources := loadTransparencyResources(t)
+	contents := `
+		q /GS0 gs 100 0 0 100 0 0 cm /Im0 Do Q
+		q 100 0 0 100 0 200 cm /Im1 Do Q
+		q /GS1 gs 100 0 0 100 0 400 cm /Im0 Do Q
+	`
+	e := Extractor{resources: resources, contents: contents}
+	pageImages, err := e.ExtractPageImages(nil)
+	require.NoError(t, err)
+	require.Len(t, pageImages.Images, 3)
+
+	// The luminosity of the gradient at the centers of the pixels.
+	mark := pageImages.Images[0]
+	require.Equal(t, []byte{32, 96, 159, 223}, mark.Image.AlphaData())
+	require.Equal(t, 1.0, mark.Alpha)
+	require.Equal(t, core.PdfObjectName("Multiply"), mark.BlendMode)
+	for i, alpha := range []uint32{32, 96, 159, 223} {
+		col, err := mark.Image.ColorAt(i, 0)
+		require.NoError(t, err)
+		_, _, _, a := col.RGBA()
+		require.Equal(t, alpha*0x101, a)
+	}
+
+	mark = pageImages.Images[1]
+	require.Equal(t, []byte{0, 255}, mark.Image.AlphaData())
+	require.Equal(t, core.PdfObjectName("Normal"), mark.BlendMode)
+
+	mark = pageImages.Images[2]
+	require.Nil(t, mark.Image.AlphaData())
+	require.Equal(t, 0.0, mark.Alpha)
+}
+
+// TestDiscardInvisible tests that text marks and images painted with an alpha of 0 are discarded
+// with the DiscardInvisible option.
+func TestDiscardInvisible(t *testing.T) {
+	resources := loadTransparencyResources(t)
+	contents := `
+		BT /F0 24 Tf 100 700 Td (Visible) Tj ET
+		q /GS1 gs
+		BT /F0 24 Tf 100 600 Td (Ghost) Tj ET
+		100 0 0 100 0 400 cm /Im0 Do
+		Q
+		q 100 0 0 100 0 200 cm /Im1 Do Q
+	`
+	testCases := []struct {
+		options Options
+		ghost   bool
+		images  int
+	}{
+		{Options{}, true, 2},
+		{Options{DiscardInvisible: true}, false, 1},
+	}
+	for _, tc := range testCases {
+		e := Extractor{resources: resources, contents: contents, options: tc.options,
+			formResults: map[string]textResult{}}
+		pageText, _, _, err := e.ExtractPageText()
+		require.NoError(t, err)
+		text := pageText.Text()
+		require.Contains(t, text, "Visible", "options=%+v", tc.options)
+		require.Equal(t, tc.ghost, strings.Contains(text, "Ghost"), "options=%+v text=%q", tc.options, text)
+
+		pageImages, err := e.ExtractPageImages(nil)
+		require.NoError(t, err)
+		require.Len(t, pageImages.Images, tc.images, "options=%+v", tc.options)
+	}
+}
@@ -4,4 +4,6 @@
  */
 
 // Package fdf provides support for loading form field data from Form Field Data (FDF) files.
+// Form field data can also be exported from PDF forms and written as FDF or XFDF (XML Forms Data
+// Format) files, as well as loaded from XFDF files.
 package fdf
@@ -10,8 +10,10 @@ import (
 	"io"
 	"os"
 	"sort"
+	"strings"
 
 	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
 )
 
 // Data represents forms data format (FDF) file data.
@@ -55,6 +57,8 @@ func LoadFromPath(fdfPath string) (*Data, error) {
 }
 
 // FieldDictionaries returns a map of field names to field dictionaries.
+// Only the top-level fields are included. QualifiedFieldDictionaries includes
+// the fields in hierarchies as well.
 func (fdf *Data) FieldDictionaries() (map[string]*core.PdfObjectDictionary, error) {
 	fieldDataMap := map[string]*core.PdfObjectDictionary{}
 
@@ -72,13 +76,70 @@ func (fdf *Data) FieldDictionaries() (map[string]*core.PdfObjectDictionary, erro
 	return fieldDataMap, nil
 }
 
+// QualifiedFieldDictionaries returns a map of the fully qualified names of the
+// fields (e.g. "parent.child") to field dictionaries. The fields in a hierarchy
+// (Kids) are included. The fields having kids are only included if they have
+// a value.
+func (fdf *Data) QualifiedFieldDictionaries() (map[string]*core.PdfObjectDictionary, error) {
+	fieldDataMap := map[string]*core.PdfObjectDictionary{}
+	addFieldDictionaries(fieldDataMap, fdf.fields, "", 0)
+	return fieldDataMap, nil
+}
+
+// addFieldDictionaries adds the field dictionaries of the specified field
+// array to the map, prefixing their names with the name of the parent.
+func addFieldDictionaries(fieldDataMap map[string]*core.PdfObjectDictionary, fields *core.PdfObjectArray, parent string, depth int) {
+	if fields == nil || depth > 32 {
+		return
+	}
+
+	for i := 0; i < fields.Len(); i++ {
+		fieldDict, has := core.GetDict(fields.Get(i))
+		if !has {
+			continue
+		}
+
+		// Key value field data.
+		name := parent
+		if t, ok := core.GetString(fieldDict.Get("T")); ok {
+			if name != "" {
+				name += "."
+			}
+			name += t.Decoded()
+		}
+
+		kids, hasKids := core.GetArray(fieldDict.Get("Kids"))
+		if (!hasKids || fieldDict.Get("V") != nil) && name != "" {
+			fieldDataMap[name] = fieldDict
+		}
+		if hasKids {
+			addFieldDictionaries(fieldDataMap, kids, name, depth+1)
+		}
+	}
+}
+
 // FieldValues implements interface model.FieldValueProvider.
-// Returns a map of field names to values (PdfObjects).
+// Returns a map of field names to values (PdfObjects). The values of the fields
+// in hierarchies (Kids) are included under their fully qualified names.
+// NOTE: The strings are returned as stored in the FDF file. The fill functions
+// of model.PdfAcroForm expect UTF-8 encoded strings, which are returned by
+// DecodedFieldValues and provided by Decoded.
 func (fdf *Data) FieldValues() (map[string]core.PdfObject, error) {
 	fieldDictMap, err := fdf.FieldDictionaries()
 	if err != nil {
 		return nil, err
 	}
+	qualifiedDictMap, err := fdf.QualifiedFieldDictionaries()
+	if err != nil {
+		return nil, err
+	}
+	for fieldName, fieldDict := range qualifiedDictMap {
+		// The top-level fields, whose names contain no period, are included
+		// as returned by FieldDictionaries.
+		if strings.Contains(fieldName, ".") {
+			fieldDictMap[fieldName] = fieldDict
+		}
+	}
 
 	var keys []string
 	for fieldName := range fieldDictMap {
@@ -95,3 +156,54 @@ func (fdf *Data) FieldValues() (map[string]core.PdfObject, error) {
 
 	return fieldValMap, nil
 }
+
+// DecodedFieldValues returns a map of field names to values, like FieldValues,
+// with the strings converted to UTF-8. The fields without values are skipped.
+func (fdf *Data) DecodedFieldValues() (map[string]core.PdfObject, error) {
+	fieldValMap, err := fdf.FieldValues()
+	if err != nil {
+		return nil, err
+	}
+
+	decodedValMap := map[string]core.PdfObject{}
+	for fieldName, val := range fieldValMap {
+		if val == nil {
+			continue
+		}
+		decodedValMap[fieldName] = decodeFieldValue(val)
+	}
+	return decodedValMap, nil
+}
+
+// Decoded returns a model.FieldValueProvider which provides the
+// DecodedFieldValues of the form data, in order to fill forms with them,
+// e.g. form.Fill(fdfData.Decoded()).
+func (fdf *Data) Decoded() model.FieldValueProvider {
+	return decodedFieldValues{fdf}
+}
+
+// decodedFieldValues provides the DecodedFieldValues of form data.
+type decodedFieldValues struct {
+	fdf *Data
+}
+
+// FieldValues implements interface model.FieldValueProvider.
+func (v decodedFieldValues) FieldValues() (map[string]core.PdfObject, error) {
+	return v.fdf.DecodedFieldValues()
+}
+
+// decodeFieldValue returns a copy of the specified value, with the strings
+// converted to UTF-8.
+func decodeFieldValue(val core.PdfObject) core.PdfObject {
+	switch t := val.(type) {
+	case *core.PdfObjectString:
+		return core.MakeString(t.Decoded())
+	case *core.PdfObjectArray:
+		arr := core.MakeArray()
+		for _, obj := range t.Elements() {
+			arr.Append(decodeFieldValue(core.TraceToDirectObject(obj)))
+		}
+		return arr
+	}
+	return val
+}
@@ -0,0 +1,173 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fdf
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// LoadFromPDF loads form field data from a PDF.
+func LoadFromPDF(rs io.ReadSeeker) (*Data, error) {
+	pdfReader, err := model.NewPdfReader(rs)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFromAcroForm(pdfReader.AcroForm)
+}
+
+// LoadFromPDFFile loads form field data from a PDF file.
+func LoadFromPDFFile(filePath string) (*Data, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return LoadFromPDF(f)
+}
+
+// NewFromAcroForm returns the form field data of the specified form. The
+// field hierarchy of the form is preserved. Signature fields are skipped.
+func NewFromAcroForm(form *model.PdfAcroForm) (*Data, error) {
+	fields := core.MakeArray()
+	if form != nil && form.Fields != nil {
+		for _, field := range *form.Fields {
+			for _, fieldDict := range newFieldDicts(field, 0) {
+				fields.Append(fieldDict)
+			}
+		}
+	}
+
+	root := core.MakeDict()
+	root.Set("Fields", fields)
+	return &Data{
+		root:   root,
+		fields: fields,
+	}, nil
+}
+
+// newFieldDicts returns the FDF field dictionaries of the specified field.
+// The kids of fields without partial names are promoted to the parent level.
+func newFieldDicts(field *model.PdfField, depth int) []*core.PdfObjectDictionary {
+	if depth > 32 {
+		common.Log.Debug("ERROR: field hierarchy too deep")
+		return nil
+	}
+	if _, isSig := field.GetContext().(*model.PdfFieldSignature); isSig {
+		return nil
+	}
+
+	var kids []*core.PdfObjectDictionary
+	for _, kid := range field.Kids {
+		kids = append(kids, newFieldDicts(kid, depth+1)...)
+	}
+	if field.T == nil {
+		return kids
+	}
+
+	fieldDict := core.MakeDict()
+	fieldDict.Set("T", field.T)
+	if len(kids) > 0 {
+		arr := core.MakeArray()
+		for _, kid := range kids {
+			arr.Append(kid)
+		}
+		fieldDict.Set("Kids", arr)
+	}
+	if val := inlineValue(field.V, true); val != nil {
+		fieldDict.Set("V", val)
+	}
+
+	return []*core.PdfObjectDictionary{fieldDict}
+}
+
+// inlineValue returns field value `val` without indirect objects, as only the
+// field dictionaries are written to the FDF file. Text streams are converted to
+// strings. Nil is returned for the values of other types, and for arrays if
+// `allowArray` is false.
+func inlineValue(val core.PdfObject, allowArray bool) core.PdfObject {
+	switch t := core.TraceToDirectObject(val).(type) {
+	case *core.PdfObjectString, *core.PdfObjectName, *core.PdfObjectInteger,
+		*core.PdfObjectFloat, *core.PdfObjectBool:
+		return t
+	case *core.PdfObjectStream:
+		data, err := core.DecodeStream(t)
+		if err != nil {
+			common.Log.Debug("ERROR: unable to decode field value stream: %v", err)
+			return nil
+		}
+		return core.MakeString(string(data))
+	case *core.PdfObjectArray:
+		if !allowArray {
+			return nil
+		}
+		arr := core.MakeArray()
+		for _, elem := range t.Elements() {
+			if v := inlineValue(elem, false); v != nil {
+				arr.Append(v)
+			}
+		}
+		return arr
+	}
+	return nil
+}
+
+// SetFile sets the file specification of the PDF document the form data
+// applies to (F entry). An empty path removes the file specification.
+func (fdf *Data) SetFile(path string) {
+	if path == "" {
+		fdf.root.Remove("F")
+		return
+	}
+	fdf.root.Set("F", core.MakeString(path))
+}
+
+// File returns the file specification of the PDF document the form data
+// applies to, if set.
+func (fdf *Data) File() string {
+	if f, ok := core.GetString(fdf.root.Get("F")); ok {
+		return f.Decoded()
+	}
+	return ""
+}
+
+// Write writes the form data to `w` as an FDF file.
+func (fdf *Data) Write(w io.Writer) error {
+	if fdf.root == nil {
+		return errors.New("FDF dictionary not set")
+	}
+
+	catalog := core.MakeDict()
+	catalog.Set("FDF", fdf.root)
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("%FDF-1.2\n%\xe2\xe3\xcf\xd3\n")
+	bw.WriteString("1 0 obj\n")
+	bw.WriteString(catalog.WriteString())
+	bw.WriteString("\nendobj\n")
+	bw.WriteString("trailer\n<</Root 1 0 R>>\n")
+	bw.WriteString("%%EOF\n")
+	return bw.Flush()
+}
+
+// WriteToFile writes the form data to the specified path as an FDF file.
+func (fdf *Data) WriteToFile(outputPath string) error {
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return fdf.Write(f)
+}
@@ -0,0 +1,262 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fdf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+const testFormFile = "./testdata/form.pdf"
+
+// loadTestForm loads the test form, with its list box converted to a
+// multiple selection choice field.
+func loadTestForm(t *testing.T) *model.PdfAcroForm {
+	f, err := os.Open(testFormFile)
+	require.NoError(t, err)
+	defer f.Close()
+
+	reader, err := model.NewPdfReader(f)
+	require.NoError(t, err)
+	require.NotNil(t, reader.AcroForm)
+
+	for _, field := range reader.AcroForm.AllFields() {
+		if field.PartialName() == "Favourite Colour List Box" {
+			field.SetFlag(model.FieldFlagMultiSelect)
+		}
+	}
+	return reader.AcroForm
+}
+
+// getFormValues returns the values of the form fields, keyed by their
+// full names.
+func getFormValues(t *testing.T, form *model.PdfAcroForm) map[string]string {
+	values := map[string]string{}
+	for _, field := range form.AllFields() {
+		name, err := field.FullName()
+		require.NoError(t, err)
+
+		switch v := core.TraceToDirectObject(field.V).(type) {
+		case *core.PdfObjectString:
+			values[name] = v.Decoded()
+		case *core.PdfObjectName:
+			values[name] = v.String()
+		case *core.PdfObjectArray:
+			var vals []string
+			for _, obj := range v.Elements() {
+				if str, ok := core.GetString(obj); ok {
+					vals = append(vals, str.Decoded())
+				}
+			}
+			values[name] = strings.Join(vals, "|")
+		}
+	}
+	return values
+}
+
+// testFieldValues implements model.FieldValueProvider.
+type testFieldValues map[string]core.PdfObject
+
+func (v testFieldValues) FieldValues() (map[string]core.PdfObject, error) {
+	return v, nil
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	form := loadTestForm(t)
+	err := form.Fill(testFieldValues{
+		"Given Name Text Box":       core.MakeString("Zoë"),
+		"Family Name Text Box":      core.MakeString(`Doe & <Sons> "Ltd"`),
+		"Country Combo Box":         core.MakeString("France"),
+		"Driving License Check Box": core.MakeName("Yes"),
+		"Language 2 Check Box":      core.MakeName("Off"),
+		"Favourite Colour List Box": core.MakeArray(core.MakeString("Red"), core.MakeString("Blue")),
+	})
+	require.NoError(t, err)
+	expected := getFormValues(t, form)
+	require.Equal(t, "Red|Blue", expected["Favourite Colour List Box"])
+	require.Equal(t, "Yes", expected["Driving License Check Box"])
+
+	data, err := NewFromAcroForm(form)
+	require.NoError(t, err)
+	data.SetFile("form.pdf")
+
+	// FDF.
+	var fdfBuf bytes.Buffer
+	require.NoError(t, data.Write(&fdfBuf))
+	require.True(t, bytes.HasPrefix(fdfBuf.Bytes(), []byte("%FDF-1.2")))
+
+	fdfData, err := Load(bytes.NewReader(fdfBuf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, "form.pdf", fdfData.File())
+
+	blank := loadTestForm(t)
+	require.NoError(t, blank.Fill(fdfData.Decoded()))
+	require.Equal(t, expected, getFormValues(t, blank))
+
+	// XFDF.
+	var xfdfBuf bytes.Buffer
+	require.NoError(t, data.WriteXFDF(&xfdfBuf))
+	require.True(t, strings.HasPrefix(xfdfBuf.String(), xml.Header))
+	require.Contains(t, xfdfBuf.String(), `<f href="form.pdf"></f>`)
+	require.Contains(t, xfdfBuf.String(), "Zoë")
+	require.Contains(t, xfdfBuf.String(), "Doe &amp; &lt;Sons&gt; &#34;Ltd&#34;")
+	require.NoError(t, xml.Unmarshal(xfdfBuf.Bytes(), new(interface{})))
+
+	xfdfData, err := LoadXFDF(bytes.NewReader(xfdfBuf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, "form.pdf", xfdfData.File())
+
+	blank = loadTestForm(t)
+	require.NoError(t, blank.Fill(xfdfData.Decoded()))
+	require.Equal(t, expected, getFormValues(t, blank))
+
+	// The values of the buttons of the form are loaded as names.
+	blank = loadTestForm(t)
+	xfdfData, err = LoadXFDFForForm(bytes.NewReader(xfdfBuf.Bytes()), blank)
+	require.NoError(t, err)
+	values, err := xfdfData.FieldValues()
+	require.NoError(t, err)
+	require.Equal(t, core.MakeName("Yes"), values["Driving License Check Box"])
+	require.Equal(t, core.MakeName("Off"), values["Language 2 Check Box"])
+	require.Equal(t, "France", values["Country Combo Box"].(*core.PdfObjectString).Decoded())
+	require.NoError(t, blank.Fill(xfdfData.Decoded()))
+	require.Equal(t, expected, getFormValues(t, blank))
+}
+
+func TestExportFieldHierarchy(t *testing.T) {
+	newTextField := func(name string, val string) *model.PdfField {
+		text := &model.PdfFieldText{}
+		text.PdfField = model.NewPdfField()
+		text.PdfField.SetContext(text)
+		text.T = core.MakeString(name)
+		if val != "" {
+			text.V = core.MakeString(val)
+		}
+		return text.PdfField
+	}
+
+	first := newTextField("first", "John")
+	last := newTextField("last", "")
+	parent := model.NewPdfField()
+	parent.T = core.MakeString("person")
+	parent.Kids = []*model.PdfField{first, last}
+	first.Parent, last.Parent = parent, parent
+
+	form := model.NewPdfAcroForm()
+	*form.Fields = append(*form.Fields, parent, newTextField("note", "a < b"))
+
+	data, err := NewFromAcroForm(form)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, data.WriteXFDF(&buf))
+	expected := xml.Header + `<xfdf xmlns="http://ns.adobe.com/xfdf/">
+  <fields>
+    <field name="person">
+      <field name="first">
+        <value>John</value>
+      </field>
+      <field name="last"></field>
+    </field>
+    <field name="note">
+      <value>a &lt; b</value>
+    </field>
+  </fields>
+</xfdf>
+`
+	require.Equal(t, expected, buf.String())
+
+	for _, load := range []func() (*Data, error){
+		func() (*Data, error) {
+			return LoadXFDF(bytes.NewReader(buf.Bytes()))
+		},
+		func() (*Data, error) {
+			var fdfBuf bytes.Buffer
+			if err := data.Write(&fdfBuf); err != nil {
+				return nil, err
+			}
+			return Load(bytes.NewReader(fdfBuf.Bytes()))
+		},
+	} {
+		loaded, err := load()
+		require.NoError(t, err)
+		values, err := loaded.DecodedFieldValues()
+		require.NoError(t, err)
+		require.Len(t, values, 2)
+		require.Equal(t, "John", values["person.first"].String())
+		require.Equal(t, "a < b", values["note"].String())
+
+		// Only the top-level fields are keyed by their partial names.
+		dicts, err := loaded.FieldDictionaries()
+		require.NoError(t, err)
+		require.Len(t, dicts, 2)
+		require.Contains(t, dicts, "person")
+		require.Contains(t, dicts, "note")
+
+		dicts, err = loaded.QualifiedFieldDictionaries()
+		require.NoError(t, err)
+		require.Len(t, dicts, 3)
+		require.Contains(t, dicts, "person.first")
+		require.Contains(t, dicts, "person.last")
+		require.Contains(t, dicts, "note")
+	}
+}
+
+func TestExportIndirectValues(t *testing.T) {
+	newField := func(name string, val core.PdfObject) *model.PdfField {
+		field := model.NewPdfField()
+		field.SetContext(&model.PdfFieldText{PdfField: field})
+		field.T = core.MakeString(name)
+		field.V = val
+		return field
+	}
+
+	stream, err := core.MakeStream([]byte("streamed"), core.NewFlateEncoder())
+	require.NoError(t, err)
+
+	form := model.NewPdfAcroForm()
+	*form.Fields = append(*form.Fields,
+		newField("name", core.MakeIndirectObject(core.MakeEncodedString("Zoë", true))),
+		newField("colours", core.MakeArray(
+			core.MakeIndirectObject(core.MakeString("Red")),
+			core.MakeString("Blue"),
+			core.MakeIndirectObject(core.MakeDict()),
+		)),
+		newField("text", stream),
+	)
+
+	data, err := NewFromAcroForm(form)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, data.Write(&buf))
+	require.Equal(t, 1, bytes.Count(buf.Bytes(), []byte(" 0 R")), buf.String())
+
+	loaded, err := Load(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	// The strings are returned as stored.
+	values, err := loaded.FieldValues()
+	require.NoError(t, err)
+	name, ok := core.GetString(values["name"])
+	require.True(t, ok)
+	require.Equal(t, "\xfe\xff\x00Z\x00o\x00\xeb", name.Str())
+	require.Equal(t, "Zoë", name.Decoded())
+	require.Equal(t, "[(Red) (Blue)]", values["colours"].WriteString())
+	require.Equal(t, "streamed", values["text"].String())
+
+	values, err = loaded.DecodedFieldValues()
+	require.NoError(t, err)
+	require.Equal(t, "Zoë", values["name"].String())
+	require.Equal(t, "[(Red) (Blue)]", values["colours"].WriteString())
+}
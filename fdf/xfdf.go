@@ -0,0 +1,237 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fdf
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/textstring"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// xfdfNamespace is the namespace of the XFDF documents.
+const xfdfNamespace = "http://ns.adobe.com/xfdf/"
+
+// xfdfDocument represents the root element of an XFDF document.
+// See "XML Forms Data Format Specification" (Adobe, 2009).
+type xfdfDocument struct {
+	XMLName xml.Name     `xml:"xfdf"`
+	XMLNS   string       `xml:"xmlns,attr,omitempty"`
+	File    *xfdfFile    `xml:"f,omitempty"`
+	Fields  []*xfdfField `xml:"fields>field"`
+}
+
+// xfdfFile represents the file specification of an XFDF document.
+type xfdfFile struct {
+	Href string `xml:"href,attr"`
+}
+
+// xfdfField represents a field element of an XFDF document. Multiple values
+// represent the selected options of multiple selection choice fields.
+type xfdfField struct {
+	Name   string       `xml:"name,attr"`
+	Fields []*xfdfField `xml:"field"`
+	Values []string     `xml:"value"`
+}
+
+// LoadXFDF loads XFDF form data from `r`. The values are loaded as text
+// strings, see LoadXFDFForForm for loading the values of button fields.
+func LoadXFDF(r io.Reader) (*Data, error) {
+	return loadXFDF(r, nil)
+}
+
+// LoadXFDFForForm loads XFDF form data from `r` for filling `form`. As XFDF
+// values are text, the values of the fields which are buttons (check boxes
+// and radio buttons) in `form` are loaded as names, matching the names of
+// their appearance states.
+func LoadXFDFForForm(r io.Reader, form *model.PdfAcroForm) (*Data, error) {
+	return loadXFDF(r, buttonFieldNames(form))
+}
+
+// loadXFDF loads XFDF form data from `r`. The values of the fields whose fully
+// qualified names are in `buttons` are loaded as names.
+func loadXFDF(r io.Reader, buttons map[string]struct{}) (*Data, error) {
+	var doc xfdfDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	fields := core.MakeArray()
+	for _, field := range doc.Fields {
+		fields.Append(field.toFieldDict("", buttons))
+	}
+
+	root := core.MakeDict()
+	root.Set("Fields", fields)
+	if doc.File != nil && doc.File.Href != "" {
+		root.Set("F", textstring.Make(doc.File.Href))
+	}
+
+	return &Data{
+		root:   root,
+		fields: fields,
+	}, nil
+}
+
+// LoadXFDFFromPath loads XFDF form data from file path `xfdfPath`.
+func LoadXFDFFromPath(xfdfPath string) (*Data, error) {
+	f, err := os.Open(xfdfPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return LoadXFDF(f)
+}
+
+// buttonFieldNames returns the fully qualified names of the button fields of
+// `form`.
+func buttonFieldNames(form *model.PdfAcroForm) map[string]struct{} {
+	buttons := map[string]struct{}{}
+	if form == nil {
+		return buttons
+	}
+	for _, field := range form.AllFields() {
+		if _, ok := field.GetContext().(*model.PdfFieldButton); !ok {
+			continue
+		}
+		if name, err := field.FullName(); err == nil {
+			buttons[name] = struct{}{}
+		}
+	}
+	return buttons
+}
+
+// toFieldDict returns the FDF field dictionary of the XFDF field, whose parent
+// has the fully qualified name `parent`. The value is a name if the field is
+// in `buttons`.
+func (f *xfdfField) toFieldDict(parent string, buttons map[string]struct{}) *core.PdfObjectDictionary {
+	fieldDict := core.MakeDict()
+	fieldDict.Set("T", textstring.Make(f.Name))
+
+	name := f.Name
+	if parent != "" {
+		name = parent + "." + name
+	}
+	_, isButton := buttons[name]
+
+	switch {
+	case len(f.Values) == 0:
+	case isButton:
+		fieldDict.Set("V", core.MakeName(f.Values[0]))
+	case len(f.Values) == 1:
+		fieldDict.Set("V", textstring.Make(f.Values[0]))
+	default:
+		arr := core.MakeArray()
+		for _, val := range f.Values {
+			arr.Append(textstring.Make(val))
+		}
+		fieldDict.Set("V", arr)
+	}
+
+	if len(f.Fields) > 0 {
+		kids := core.MakeArray()
+		for _, kid := range f.Fields {
+			kids.Append(kid.toFieldDict(name, buttons))
+		}
+		fieldDict.Set("Kids", kids)
+	}
+
+	return fieldDict
+}
+
+// newXFDFField returns the XFDF field of the specified FDF field dictionary.
+func newXFDFField(fieldDict *core.PdfObjectDictionary, depth int) (*xfdfField, error) {
+	if depth > 32 {
+		return nil, errors.New("field hierarchy too deep")
+	}
+
+	field := &xfdfField{}
+	if t, ok := core.GetString(fieldDict.Get("T")); ok {
+		field.Name = t.Decoded()
+	}
+
+	switch val := core.TraceToDirectObject(fieldDict.Get("V")).(type) {
+	case *core.PdfObjectString:
+		field.Values = []string{val.Decoded()}
+	case *core.PdfObjectName:
+		field.Values = []string{val.String()}
+	case *core.PdfObjectArray:
+		for _, obj := range val.Elements() {
+			switch t := core.TraceToDirectObject(obj).(type) {
+			case *core.PdfObjectString:
+				field.Values = append(field.Values, t.Decoded())
+			case *core.PdfObjectName:
+				field.Values = append(field.Values, t.String())
+			}
+		}
+	}
+
+	if kids, ok := core.GetArray(fieldDict.Get("Kids")); ok {
+		for _, obj := range kids.Elements() {
+			kidDict, ok := core.GetDict(obj)
+			if !ok {
+				continue
+			}
+			kid, err := newXFDFField(kidDict, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			field.Fields = append(field.Fields, kid)
+		}
+	}
+
+	return field, nil
+}
+
+// WriteXFDF writes the form data to `w` as an UTF-8 encoded XFDF document.
+func (fdf *Data) WriteXFDF(w io.Writer) error {
+	doc := xfdfDocument{XMLNS: xfdfNamespace}
+	if file := fdf.File(); file != "" {
+		doc.File = &xfdfFile{Href: file}
+	}
+
+	if fdf.fields != nil {
+		for _, obj := range fdf.fields.Elements() {
+			fieldDict, ok := core.GetDict(obj)
+			if !ok {
+				continue
+			}
+			field, err := newXFDFField(fieldDict, 0)
+			if err != nil {
+				return err
+			}
+			doc.Fields = append(doc.Fields, field)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteXFDFToFile writes the form data to the specified path as an XFDF
+// document.
+func (fdf *Data) WriteXFDFToFile(outputPath string) error {
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return fdf.WriteXFDF(f)
+}
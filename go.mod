@@ -1,6 +1,6 @@
 module github.com/unidoc/unipdf/v3
 
-go 1.11
+go 1.13
 
 require (
 	github.com/adrg/sysfont v0.1.0
@@ -13,6 +13,7 @@ require (
 	github.com/unidoc/unitype v0.2.0
 	golang.org/x/crypto v0.0.0-20190605123033-f99c8df09eb5
 	golang.org/x/image v0.0.0-20181116024801-cd38e8056d9b
+	golang.org/x/net v0.0.0-20190404232315-eb5bcb51f2a3
 	golang.org/x/sys v0.0.0-20200523222454-059865788121 // indirect
 	golang.org/x/text v0.3.2
 )
@@ -0,0 +1,579 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package htmlconverter
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/creator"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// Options defines the conversion of HTML documents.
+type Options struct {
+	// ImageResolver returns the data of the JPEG, PNG or TIFF image referenced by the src
+	// attribute `src` of an img element, e.g. read from a path relative to the HTML document.
+	// The images are skipped with a warning if it is nil.
+	ImageResolver func(src string) ([]byte, error)
+
+	// FontSize is the size of the text of the document, in points. The default size of 10
+	// points is used if it is 0.
+	FontSize float64
+}
+
+// Report describes the conversion of an HTML document.
+type Report struct {
+	// Warnings describe the unsupported content of the document, which is ignored, e.g.
+	// unsupported elements or CSS properties, in the order they are found. Each warning is
+	// reported once.
+	Warnings []string
+}
+
+// Convert draws the content of the HTML document read from `r` with creator `c`, after the content
+// already drawn. The document is written out with the creator.
+func Convert(c *creator.Creator, r io.Reader, opts *Options) (*Report, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	conv := &converter{
+		c:      c,
+		opts:   opts,
+		fonts:  make(map[model.StdFontName]*model.PdfFont),
+		warned: make(map[string]bool),
+	}
+	s := style{size: opts.FontSize}
+	if s.size <= 0 {
+		s.size = 10
+	}
+
+	b := &blockBuilder{conv: conv}
+	conv.convertChildren(doc, s, b)
+	b.flush()
+	for _, block := range b.blocks {
+		if err := c.Draw(block); err != nil {
+			return nil, err
+		}
+	}
+	return &Report{Warnings: conv.warnings}, nil
+}
+
+// converter converts the HTML elements to creator components.
+type converter struct {
+	c        *creator.Creator
+	opts     *Options
+	fonts    map[model.StdFontName]*model.PdfFont
+	warnings []string
+	warned   map[string]bool
+}
+
+// warn adds a warning to the report, unless it has already been added.
+func (conv *converter) warn(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if conv.warned[msg] {
+		return
+	}
+	conv.warned[msg] = true
+	conv.warnings = append(conv.warnings, msg)
+	common.Log.Debug("HTML conversion: %s", msg)
+}
+
+// textStyle returns the creator text style of style `s`.
+func (conv *converter) textStyle(s style) creator.TextStyle {
+	textStyle := conv.c.NewTextStyle()
+	index := 0
+	if s.bold {
+		index++
+	}
+	if s.italic {
+		index += 2
+	}
+	name := stdFontNames[s.family][index]
+	font, ok := conv.fonts[name]
+	if !ok {
+		var err error
+		if font, err = model.NewStandard14Font(name); err != nil {
+			common.Log.Debug("ERROR: could not load font %s: %v", name, err)
+			font = textStyle.Font
+		}
+		conv.fonts[name] = font
+	}
+	textStyle.Font = font
+	textStyle.FontSize = s.size
+	if s.color != nil {
+		textStyle.Color = s.color
+	}
+	return textStyle
+}
+
+// headingSizes are the font sizes of the h1 to h6 headings, relative to the document font size.
+var headingSizes = map[atom.Atom]float64{
+	atom.H1: 2, atom.H2: 1.5, atom.H3: 1.17, atom.H4: 1, atom.H5: 0.83, atom.H6: 0.67,
+}
+
+// convertChildren converts the children of node `n`, whose style is `s`, adding them to `b`.
+func (conv *converter) convertChildren(n *html.Node, s style, b *blockBuilder) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		conv.convertNode(child, s, b)
+	}
+}
+
+// convertNode converts node `n`, whose parent style is `s`, adding it to `b`.
+func (conv *converter) convertNode(n *html.Node, s style, b *blockBuilder) {
+	switch n.Type {
+	case html.TextNode:
+		b.addText(n.Data, s)
+		return
+	case html.ElementNode:
+	default:
+		conv.convertChildren(n, s, b)
+		return
+	}
+
+	if decls := attr(n, "style"); decls != "" {
+		s = applyCSS(s, decls, conv.warn)
+	}
+
+	switch n.DataAtom {
+	case atom.Head, atom.Script, atom.Style, atom.Title, atom.Noscript, atom.Template:
+		// Not displayed.
+	case atom.Html, atom.Body, atom.Div, atom.Section, atom.Article, atom.Header, atom.Footer,
+		atom.Main, atom.Nav, atom.Aside, atom.Blockquote, atom.Figure, atom.Figcaption, atom.Address:
+		b.convertBlock(n, s, 0, 0)
+	case atom.P:
+		b.convertBlock(n, s, 0, s.size/2)
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		s.bold = true
+		s.size *= headingSizes[n.DataAtom]
+		b.convertBlock(n, s, s.size/2, s.size/4)
+	case atom.Pre:
+		s.family = familyMono
+		s.pre = true
+		b.convertBlock(n, s, 0, s.size/2)
+	case atom.Br:
+		b.addBreak(s)
+	case atom.B, atom.Strong:
+		s.bold = true
+		conv.convertChildren(n, s, b)
+	case atom.I, atom.Em, atom.Cite, atom.Var:
+		s.italic = true
+		conv.convertChildren(n, s, b)
+	case atom.Code, atom.Tt, atom.Kbd, atom.Samp:
+		s.family = familyMono
+		conv.convertChildren(n, s, b)
+	case atom.Small:
+		s.size *= 0.83
+		conv.convertChildren(n, s, b)
+	case atom.A:
+		if href := attr(n, "href"); href != "" {
+			s.href = href
+		}
+		conv.convertChildren(n, s, b)
+	case atom.Span, atom.Font, atom.Abbr, atom.Label:
+		conv.convertChildren(n, s, b)
+	case atom.Ul, atom.Ol:
+		b.flush()
+		b.addBlock(conv.convertList(n, s))
+	case atom.Table:
+		b.flush()
+		if table := conv.convertTable(n, s); table != nil {
+			b.addBlock(table)
+		}
+	case atom.Img:
+		if img := conv.convertImage(n); img != nil {
+			b.flush()
+			b.addBlock(img)
+		}
+	default:
+		conv.warn("unsupported element <%s>", n.Data)
+		conv.convertChildren(n, s, b)
+	}
+}
+
+// convertList returns the list of ul or ol element `n`, whose style is `s`.
+func (conv *converter) convertList(n *html.Node, s style) *creator.List {
+	list := conv.c.NewList()
+	list.SetMargins(0, 0, 0, s.size/2)
+	ordered := n.DataAtom == atom.Ol
+	number := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+		number = start
+	}
+	list.Marker().Style = conv.textStyle(s)
+
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.DataAtom != atom.Li {
+			continue
+		}
+		liStyle := s
+		if decls := attr(li, "style"); decls != "" {
+			liStyle = applyCSS(liStyle, decls, conv.warn)
+		}
+		item := &blockBuilder{conv: conv}
+		item.convertBlock(li, liStyle, 0, 0)
+		if len(item.blocks) == 0 {
+			item.blocks = append(item.blocks, conv.c.NewStyledParagraph())
+		}
+
+		for i, block := range item.blocks {
+			marker, err := list.Add(block)
+			if err != nil {
+				conv.warn("unsupported content of list items: %T", block)
+				continue
+			}
+			switch {
+			case i > 0:
+				// The other blocks of the item are not marked.
+				marker.Text = ""
+			case ordered:
+				marker.Text = fmt.Sprintf("%d. ", number)
+			}
+		}
+		number++
+	}
+	return list
+}
+
+// convertTable returns the table of table element `n`, whose style is `s`, or nil if it has no
+// cells.
+func (conv *converter) convertTable(n *html.Node, s style) *creator.Table {
+	// The rows of the table, in its thead, tbody or tfoot sections.
+	var rows []*html.Node
+	var findRows func(n *html.Node)
+	findRows = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.DataAtom {
+			case atom.Tr:
+				rows = append(rows, child)
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				findRows(child)
+			case atom.Caption, atom.Colgroup:
+			default:
+				conv.warn("unsupported element <%s> in table", child.Data)
+			}
+		}
+	}
+	findRows(n)
+
+	cols := 0
+	for _, row := range rows {
+		numCols := 0
+		for _, cell := range cells(row) {
+			numCols += colspan(cell)
+		}
+		if numCols > cols {
+			cols = numCols
+		}
+	}
+	if cols == 0 {
+		return nil
+	}
+
+	table := conv.c.NewTable(cols)
+	table.SetMargins(0, 0, 0, s.size/2)
+	for _, row := range rows {
+		rowStyle := s
+		if decls := attr(row, "style"); decls != "" {
+			rowStyle = applyCSS(rowStyle, decls, conv.warn)
+		}
+		numCols := 0
+		for _, cell := range cells(row) {
+			cellStyle := rowStyle
+			if cell.DataAtom == atom.Th {
+				cellStyle.bold = true
+			}
+			if decls := attr(cell, "style"); decls != "" {
+				cellStyle = applyCSS(cellStyle, decls, conv.warn)
+			}
+			span := colspan(cell)
+			if numCols+span > cols {
+				span = cols - numCols
+			}
+			numCols += span
+
+			content := &blockBuilder{conv: conv}
+			content.convertBlock(cell, cellStyle, 0, 0)
+
+			tableCell := table.MultiColCell(span)
+			tableCell.SetBorder(creator.CellBorderSideAll, creator.CellBorderStyleSingle, 0.5)
+			tableCell.SetIndent(2)
+			if cellStyle.background != nil {
+				tableCell.SetBackgroundColor(cellStyle.background)
+			}
+			if drawable := conv.cellContent(content.blocks); drawable != nil {
+				if err := tableCell.SetContent(drawable); err != nil {
+					conv.warn("unsupported content of table cells: %T", drawable)
+				}
+			}
+		}
+		if numCols < cols {
+			table.SkipCells(cols - numCols)
+		}
+	}
+	return table
+}
+
+// cellContent returns the content of a table cell with `blocks`, or nil if there are none.
+func (conv *converter) cellContent(blocks []creator.VectorDrawable) creator.VectorDrawable {
+	switch len(blocks) {
+	case 0:
+		return nil
+	case 1:
+		return blocks[0]
+	}
+	div := conv.c.NewDivision()
+	for _, block := range blocks {
+		if err := div.Add(block); err != nil {
+			conv.warn("unsupported content of table cells: %T", block)
+		}
+	}
+	return div
+}
+
+// convertImage returns the image of img element `n`, or nil if it cannot be loaded.
+func (conv *converter) convertImage(n *html.Node) *creator.Image {
+	src := attr(n, "src")
+	if src == "" {
+		return nil
+	}
+	if conv.opts.ImageResolver == nil {
+		conv.warn("image %q skipped without image resolver", src)
+		return nil
+	}
+	data, err := conv.opts.ImageResolver(src)
+	if err != nil {
+		conv.warn("could not resolve image %q: %v", src, err)
+		return nil
+	}
+	img, err := conv.c.NewImageFromData(data)
+	if err != nil {
+		conv.warn("could not load image %q: %v", src, err)
+		return nil
+	}
+	if alt := attr(n, "alt"); alt != "" {
+		img.SetAltText(alt)
+	}
+
+	// The images are sized at 96 pixels per inch, as in the browsers, unless their size is
+	// specified by their attributes or by their style.
+	width, height := 0.75*img.Width(), 0.75*img.Height()
+	widthAttr, heightAttr := attr(n, "width"), attr(n, "height")
+	for _, m := range cssSizeRegexp.FindAllStringSubmatch(attr(n, "style"), -1) {
+		if m[1] == "width" {
+			widthAttr = m[2]
+		} else {
+			heightAttr = m[2]
+		}
+	}
+	w, errW := parseLength(widthAttr)
+	h, errH := parseLength(heightAttr)
+	switch {
+	case errW == nil && errH == nil:
+		width, height = w, h
+	case errW == nil:
+		width, height = w, height*w/width
+	case errH == nil:
+		width, height = width*h/height, h
+	}
+
+	// The images wider than the page content are scaled to fit.
+	if maxWidth := conv.c.Context().Width; maxWidth > 0 && width > maxWidth {
+		width, height = maxWidth, height*maxWidth/width
+	}
+	img.SetWidth(width)
+	img.SetHeight(height)
+	return img
+}
+
+// cssSizeRegexp matches the width and height declarations of the style attributes.
+var cssSizeRegexp = regexp.MustCompile(`(?i)(?:^|;)\s*(width|height)\s*:\s*([^;]+)`)
+
+// blockBuilder builds the blocks of an element: its text, in paragraphs, and its child blocks.
+type blockBuilder struct {
+	conv   *converter
+	blocks []creator.VectorDrawable
+
+	// The paragraph of the text of the current block, nil if there is no text.
+	para *creator.StyledParagraph
+	// The last text chunk of the paragraph.
+	last *creator.TextChunk
+	// Alignment and margins of the paragraphs of the current block.
+	align                   creator.TextAlignment
+	marginTop, marginBottom float64
+	// The text of the paragraph ends with a space, or a line break.
+	space bool
+}
+
+// convertBlock converts block element `n`, whose style is `s`, whose text is drawn in paragraphs
+// with margins `marginTop` and `marginBottom`.
+func (b *blockBuilder) convertBlock(n *html.Node, s style, marginTop, marginBottom float64) {
+	b.flush()
+	align, top, bottom := b.align, b.marginTop, b.marginBottom
+	b.align, b.marginTop, b.marginBottom = s.align, marginTop, marginBottom
+	b.conv.convertChildren(n, s, b)
+	b.flush()
+	b.align, b.marginTop, b.marginBottom = align, top, bottom
+}
+
+// addBlock adds `block` after the current blocks.
+func (b *blockBuilder) addBlock(block creator.VectorDrawable) {
+	b.blocks = append(b.blocks, block)
+}
+
+// addText adds `text`, with style `s`, to the current paragraph. The whitespace is collapsed
+// unless it is preserved by `s`.
+func (b *blockBuilder) addText(text string, s style) {
+	if !s.pre {
+		collapsed := strings.Join(strings.Fields(text), " ")
+		if collapsed == "" {
+			collapsed = " "
+		} else {
+			if leadingSpace(text) {
+				collapsed = " " + collapsed
+			}
+			if trailingSpace(text) {
+				collapsed += " "
+			}
+		}
+		text = collapsed
+		if b.para == nil || b.space {
+			text = strings.TrimLeft(text, " ")
+		}
+	} else {
+		text = strings.Replace(text, "\r\n", "\n", -1)
+	}
+	if text == "" {
+		return
+	}
+	b.appendChunk(text, s)
+	b.space = strings.HasSuffix(text, " ") || strings.HasSuffix(text, "\n")
+}
+
+// addBreak adds a line break to the current paragraph.
+func (b *blockBuilder) addBreak(s style) {
+	b.appendChunk("\n", s)
+	b.space = true
+}
+
+// appendChunk appends the text chunk `text`, with style `s`, to the current paragraph.
+func (b *blockBuilder) appendChunk(text string, s style) {
+	if b.para == nil {
+		b.para = b.conv.c.NewStyledParagraph()
+	}
+	textStyle := b.conv.textStyle(s)
+	text = b.conv.encodable(text, textStyle.Font)
+	if s.href == "" {
+		b.last = b.para.Append(text)
+		b.last.Style = textStyle
+		return
+	}
+	b.last = b.para.AddExternalLink(text, s.href)
+	b.last.Style.Font = textStyle.Font
+	b.last.Style.FontSize = textStyle.FontSize
+	if s.color != nil {
+		b.last.Style.Color = s.color
+	}
+}
+
+// flush adds the current paragraph, if any, to the blocks, without its trailing space.
+func (b *blockBuilder) flush() {
+	para, last := b.para, b.last
+	b.para, b.last = nil, nil
+	b.space = false
+	if para == nil {
+		return
+	}
+	last.Text = strings.TrimRight(last.Text, " ")
+	para.SetTextAlignment(b.align)
+	para.SetMargins(0, 0, b.marginTop, b.marginBottom)
+	b.blocks = append(b.blocks, para)
+}
+
+// encodable returns `text` with the characters which cannot be drawn with `font` replaced by
+// question marks.
+func (conv *converter) encodable(text string, font *model.PdfFont) string {
+	encoder := font.Encoder()
+	if encoder == nil {
+		return text
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '\n' {
+			return r
+		}
+		if _, ok := encoder.RuneToCharcode(r); !ok {
+			conv.warn("unsupported character %q", r)
+			return '?'
+		}
+		return r
+	}, text)
+}
+
+// attr returns the value of attribute `key` of node `n`, or "" if it does not have it.
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// cells returns the td and th cells of table row `tr`.
+func cells(tr *html.Node) []*html.Node {
+	var cells []*html.Node
+	for child := tr.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && (child.DataAtom == atom.Td || child.DataAtom == atom.Th) {
+			cells = append(cells, child)
+		}
+	}
+	return cells
+}
+
+// maxColspan is the maximum number of columns spanned by a table cell, as clamped by the HTML
+// specification.
+const maxColspan = 1000
+
+// colspan returns the number of columns spanned by table cell `cell`.
+func colspan(cell *html.Node) int {
+	span, err := strconv.Atoi(attr(cell, "colspan"))
+	switch {
+	case err != nil || span <= 1:
+		return 1
+	case span > maxColspan:
+		return maxColspan
+	}
+	return span
+}
+
+// isSpace returns true if `r` is an HTML whitespace character.
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f'
+}
+
+// leadingSpace returns true if `text` starts with whitespace.
+func leadingSpace(text string) bool {
+	return strings.IndexFunc(text, isSpace) == 0
+}
+
+// trailingSpace returns true if `text` ends with whitespace.
+func trailingSpace(text string) bool {
+	return text != "" && strings.LastIndexFunc(text, isSpace) == len(text)-1
+}
@@ -0,0 +1,226 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package htmlconverter
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/creator"
+	"github.com/unidoc/unipdf/v3/extractor"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// TestConvertSample converts testdata/sample.html and checks that its text is extracted in the
+// order of the document, that its image is drawn and that the unsupported content is reported.
+func TestConvertSample(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "sample.html"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	var resolved []string
+	opts := &Options{
+		ImageResolver: func(src string) ([]byte, error) {
+			resolved = append(resolved, src)
+			img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+			for i := range img.Pix {
+				img.Pix[i] = 0x80
+			}
+			var buf bytes.Buffer
+			err := png.Encode(&buf, img)
+			return buf.Bytes(), err
+		},
+	}
+
+	c := creator.New()
+	report, err := Convert(c, f, opts)
+	require.NoError(t, err)
+	require.Equal(t, []string{"logo.png"}, resolved)
+	require.Equal(t, []string{
+		`unsupported CSS property "float"`,
+		"unsupported element <marquee>",
+	}, report.Warnings)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 1, numPages)
+	page, err := reader.GetPage(1)
+	require.NoError(t, err)
+
+	// The beginning of the text is extracted with the spaces between the words.
+	ex, err := extractor.New(page)
+	require.NoError(t, err)
+	text, err := ex.ExtractText()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(text, "Quarterly report\nSales grew in every region, led by "+
+		"the northern stores."), text)
+
+	// The text is drawn in the order of the HTML document. The spaces between the words are
+	// drawn as offsets.
+	expected := []string{
+		"Quarterly report",
+		"Sales grew in every region, led by the northern stores. Details are on the website.",
+		"Highlights",
+		"New customers",
+		"Lower costs",
+		"3. Third step",
+		"4. Fourth step",
+		"Region", "Revenue",
+		"North", "120",
+		"Total 120",
+		"Closing remarks",
+	}
+	drawn := drawnText(t, page)
+	pos := 0
+	for _, s := range expected {
+		s = strings.Replace(s, " ", "", -1)
+		i := strings.Index(drawn[pos:], s)
+		require.Truef(t, i >= 0, "%q not found after %q in %q", s, drawn[:pos], drawn)
+		pos += i + len(s)
+	}
+	require.Equal(t, 1, strings.Count(drawn, "Quarterlyreport"))
+
+	images, err := extractor.New(page)
+	require.NoError(t, err)
+	pageImages, err := images.ExtractPageImages(nil)
+	require.NoError(t, err)
+	require.Len(t, pageImages.Images, 1)
+	mark := pageImages.Images[0]
+	require.InDelta(t, 40*0.75, mark.Width, 0.01)
+	require.InDelta(t, 20*0.75, mark.Height, 0.01)
+}
+
+// TestConvertText checks the whitespace handling, the line breaks and the characters which are
+// missing from the fonts.
+func TestConvertText(t *testing.T) {
+	testcases := []struct {
+		html     string
+		expected string
+		warnings []string
+	}{
+		{"<p>  one \n two <b> three</b> </p>", "one two three", nil},
+		{"<p>one<br>two</p>", "one\ntwo", nil},
+		{"<pre>a  b\n  c</pre>", "a b\nc", nil},
+		{"<p>中 x</p>", "? x", []string{`unsupported character '中'`}},
+		{`<p style="color: nocolor">x</p>`, "x", []string{`unsupported value "nocolor" of CSS property "color"`}},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.html, func(t *testing.T) {
+			c := creator.New()
+			report, err := Convert(c, strings.NewReader(tc.html), nil)
+			require.NoError(t, err)
+			require.Equal(t, tc.warnings, report.Warnings)
+
+			var buf bytes.Buffer
+			require.NoError(t, c.Write(&buf))
+			reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+			page, err := reader.GetPage(1)
+			require.NoError(t, err)
+			ex, err := extractor.New(page)
+			require.NoError(t, err)
+			text, err := ex.ExtractText()
+			require.NoError(t, err)
+			require.Contains(t, text, tc.expected)
+		})
+	}
+}
+
+// TestParseColor checks the parsing of the CSS colors.
+func TestParseColor(t *testing.T) {
+	testcases := []struct {
+		value string
+		rgb   [3]float64
+		ok    bool
+	}{
+		{"red", [3]float64{1, 0, 0}, true},
+		{"#0f0", [3]float64{0, 1, 0}, true},
+		{"#0000ff", [3]float64{0, 0, 1}, true},
+		{"rgb(255, 255, 0)", [3]float64{1, 1, 0}, true},
+		{"rgb(256, 0, 0)", [3]float64{}, false},
+		{"#12345", [3]float64{}, false},
+		{"unknown", [3]float64{}, false},
+	}
+	for _, tc := range testcases {
+		c, ok := parseColor(tc.value)
+		require.Equal(t, tc.ok, ok, tc.value)
+		if !ok {
+			continue
+		}
+		r, g, b := c.ToRGB()
+		require.Equal(t, tc.rgb, [3]float64{r, g, b}, tc.value)
+	}
+}
+
+// TestColspan checks that the number of columns spanned by table cells is clamped.
+func TestColspan(t *testing.T) {
+	testcases := []struct {
+		value    string
+		expected int
+	}{
+		{"", 1},
+		{"abc", 1},
+		{"-3", 1},
+		{"0", 1},
+		{"4", 4},
+		{"1000", maxColspan},
+		{"2147483647", maxColspan},
+	}
+	for _, tc := range testcases {
+		cell := &html.Node{Type: html.ElementNode, DataAtom: atom.Td, Data: "td",
+			Attr: []html.Attribute{{Key: "colspan", Val: tc.value}}}
+		require.Equal(t, tc.expected, colspan(cell), tc.value)
+	}
+
+	// A huge colspan does not allocate a huge table.
+	c := creator.New()
+	doc := `<table><tr><td colspan="2147483647">wide</td></tr><tr><td>a</td><td>b</td></tr></table>`
+	_, err := Convert(c, strings.NewReader(doc), nil)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+}
+
+// drawnText returns the strings drawn on `page` with the TJ operator, concatenated in the order
+// they are drawn.
+func drawnText(t *testing.T, page *model.PdfPage) string {
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	ops, err := contentstream.NewContentStreamParser(contents).Parse()
+	require.NoError(t, err)
+
+	var text strings.Builder
+	for _, op := range *ops {
+		if op.Operand != "TJ" || len(op.Params) != 1 {
+			continue
+		}
+		arr, ok := core.GetArray(op.Params[0])
+		if !ok {
+			continue
+		}
+		for _, obj := range arr.Elements() {
+			if str, ok := core.GetString(obj); ok {
+				text.WriteString(strings.Replace(str.Str(), " ", "", -1))
+			}
+		}
+	}
+	return text.String()
+}
@@ -0,0 +1,11 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package htmlconverter is used for converting simple HTML documents to PDF
+// with the creator: headings, paragraphs, bold, italic and monospace text,
+// links, lists, tables and images, styled with a small set of inline CSS
+// properties. The unsupported elements and properties are ignored and
+// reported as warnings.
+package htmlconverter
@@ -0,0 +1,246 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package htmlconverter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unipdf/v3/creator"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// Font families of the converted text, drawn with the standard 14 fonts.
+const (
+	familySans = iota
+	familySerif
+	familyMono
+)
+
+// style is the style of the HTML content, inherited by the child elements.
+type style struct {
+	family     int
+	bold       bool
+	italic     bool
+	size       float64
+	color      creator.Color // nil for the default color.
+	background creator.Color // Background color of the table cells, nil if not set.
+	align      creator.TextAlignment
+	href       string // Target of the links.
+	pre        bool   // The whitespace is preserved.
+}
+
+// stdFontNames maps the font families to the names of their regular, bold, italic and bold italic
+// standard 14 fonts.
+var stdFontNames = map[int][4]model.StdFontName{
+	familySans: {model.HelveticaName, model.HelveticaBoldName, model.HelveticaObliqueName,
+		model.HelveticaBoldObliqueName},
+	familySerif: {model.TimesRomanName, model.TimesBoldName, model.TimesItalicName,
+		model.TimesBoldItalicName},
+	familyMono: {model.CourierName, model.CourierBoldName, model.CourierObliqueName,
+		model.CourierBoldObliqueName},
+}
+
+// namedColors are the supported CSS color names.
+var namedColors = map[string]string{
+	"black":   "#000000",
+	"white":   "#ffffff",
+	"gray":    "#808080",
+	"grey":    "#808080",
+	"silver":  "#c0c0c0",
+	"red":     "#ff0000",
+	"maroon":  "#800000",
+	"orange":  "#ffa500",
+	"yellow":  "#ffff00",
+	"green":   "#008000",
+	"lime":    "#00ff00",
+	"teal":    "#008080",
+	"blue":    "#0000ff",
+	"navy":    "#000080",
+	"purple":  "#800080",
+	"fuchsia": "#ff00ff",
+}
+
+// applyCSS returns style `s` with the declarations of the style attribute `decls` applied. The
+// unsupported properties and values are reported with `warn`.
+func applyCSS(s style, decls string, warn func(format string, args ...interface{})) style {
+	for _, decl := range strings.Split(decls, ";") {
+		parts := strings.SplitN(decl, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		property := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.ToLower(strings.TrimSpace(parts[1]))
+		value = strings.TrimSpace(strings.TrimSuffix(value, "!important"))
+
+		ok := true
+		switch property {
+		case "color":
+			var c creator.Color
+			if c, ok = parseColor(value); ok {
+				s.color = c
+			}
+		case "background-color", "background":
+			var c creator.Color
+			if c, ok = parseColor(value); ok {
+				s.background = c
+			}
+		case "font-size":
+			var size float64
+			if size, ok = parseFontSize(value, s.size); ok {
+				s.size = size
+			}
+		case "font-weight":
+			switch value {
+			case "bold", "bolder":
+				s.bold = true
+			case "normal", "lighter":
+				s.bold = false
+			default:
+				weight, err := strconv.Atoi(value)
+				ok = err == nil
+				s.bold = weight >= 600
+			}
+		case "font-style":
+			switch value {
+			case "italic", "oblique":
+				s.italic = true
+			case "normal":
+				s.italic = false
+			default:
+				ok = false
+			}
+		case "font-family":
+			var family int
+			if family, ok = parseFontFamily(value); ok {
+				s.family = family
+			}
+		case "text-align":
+			switch value {
+			case "left", "start":
+				s.align = creator.TextAlignmentLeft
+			case "right", "end":
+				s.align = creator.TextAlignmentRight
+			case "center":
+				s.align = creator.TextAlignmentCenter
+			case "justify":
+				s.align = creator.TextAlignmentJustify
+			default:
+				ok = false
+			}
+		default:
+			warn("unsupported CSS property %q", property)
+			continue
+		}
+		if !ok {
+			warn("unsupported value %q of CSS property %q", value, property)
+		}
+	}
+	return s
+}
+
+// parseColor returns the color of CSS color `value`: a supported color name, #rgb, #rrggbb or
+// rgb(r, g, b).
+func parseColor(value string) (creator.Color, bool) {
+	if hex, ok := namedColors[value]; ok {
+		value = hex
+	}
+	switch {
+	case strings.HasPrefix(value, "#") && len(value) == 4:
+		value = string([]byte{'#', value[1], value[1], value[2], value[2], value[3], value[3]})
+		fallthrough
+	case strings.HasPrefix(value, "#") && len(value) == 7:
+		if _, err := strconv.ParseUint(value[1:], 16, 32); err != nil {
+			return nil, false
+		}
+		return creator.ColorRGBFromHex(value), true
+	case strings.HasPrefix(value, "rgb(") && strings.HasSuffix(value, ")"):
+		parts := strings.Split(value[4:len(value)-1], ",")
+		if len(parts) != 3 {
+			return nil, false
+		}
+		var rgb [3]byte
+		for i, part := range parts {
+			v, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || v < 0 || v > 255 {
+				return nil, false
+			}
+			rgb[i] = byte(v)
+		}
+		return creator.ColorRGBFrom8bit(rgb[0], rgb[1], rgb[2]), true
+	}
+	return nil, false
+}
+
+// parseFontSize returns the font size in points of CSS font size `value`, relative to the font
+// size `parent` of the parent element for relative sizes.
+func parseFontSize(value string, parent float64) (float64, bool) {
+	keywords := map[string]float64{
+		"xx-small": 0.6, "x-small": 0.75, "small": 0.89, "medium": 1,
+		"large": 1.2, "x-large": 1.5, "xx-large": 2,
+		"smaller": 0.83, "larger": 1.2,
+	}
+	if factor, ok := keywords[value]; ok {
+		return factor * parent, true
+	}
+
+	units := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"pt", 1},
+		{"px", 0.75},
+		{"em", parent},
+		{"%", parent / 100},
+	}
+	for _, unit := range units {
+		if !strings.HasSuffix(value, unit.suffix) {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSuffix(value, unit.suffix), 64)
+		if err != nil || v <= 0 {
+			return 0, false
+		}
+		return v * unit.scale, true
+	}
+	return 0, false
+}
+
+// parseFontFamily returns the font family of the first supported family of the CSS font family
+// list `value`.
+func parseFontFamily(value string) (int, bool) {
+	for _, name := range strings.Split(value, ",") {
+		switch strings.Trim(strings.TrimSpace(name), `"'`) {
+		case "sans-serif", "helvetica", "arial", "verdana":
+			return familySans, true
+		case "serif", "times", "times new roman", "georgia":
+			return familySerif, true
+		case "monospace", "courier", "courier new":
+			return familyMono, true
+		}
+	}
+	return 0, false
+}
+
+// parseLength returns the length in points of HTML or CSS length `value`, in pixels if it has no
+// unit.
+func parseLength(value string) (float64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	scale := 0.75
+	switch {
+	case strings.HasSuffix(value, "px"):
+		value = strings.TrimSuffix(value, "px")
+	case strings.HasSuffix(value, "pt"):
+		value = strings.TrimSuffix(value, "pt")
+		scale = 1
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid length %q", value)
+	}
+	return v * scale, nil
+}
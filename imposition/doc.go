@@ -0,0 +1,11 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package imposition is used for laying out multiple PDF pages on the pages of
+// a new document (N-up), for ordering pages for booklet printing, for resizing
+// pages and for overlaying the pages of a document on the pages of another,
+// e.g. generated data on a form background. The content of the pages is kept
+// intact, only scaled and positioned.
+package imposition
@@ -0,0 +1,410 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package imposition
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// AnnotationMode specifies how the annotations of the source pages are
+// handled.
+type AnnotationMode int
+
+// Annotation modes.
+const (
+	// AnnotationsDrop removes the annotations of the source pages.
+	AnnotationsDrop AnnotationMode = iota
+
+	// AnnotationsTransform moves the annotations of the source pages to the
+	// output pages, transforming their rectangles and quadrilaterals to the
+	// placement of the source pages. Links to imposed pages are redirected to
+	// the output pages containing them, other local links are removed.
+	AnnotationsTransform
+)
+
+// Options defines the layout of the imposed pages.
+type Options struct {
+	// Columns and Rows specify the grid of source pages placed on each output
+	// page. The pages are placed in reading order (left to right, top to
+	// bottom). Columns defaults to 2 and Rows to 1 if not specified.
+	Columns int
+	Rows    int
+
+	// PageSize is the media box of the output pages. If not specified, the
+	// size of the first source page is used, oriented in landscape if the grid
+	// has more columns than rows and in portrait if it has more rows than
+	// columns.
+	PageSize *model.PdfRectangle
+
+	// Margin is the space between the edges of the output pages and the grid.
+	Margin float64
+
+	// Gutter is the space between the cells of the grid.
+	Gutter float64
+
+	// Booklet specifies whether the pages are reordered for printing as a
+	// saddle-stitched booklet (see BookletOrder). Requires a 2x1 grid. The
+	// output pages are the front and back sides of the sheets, in order.
+	Booklet bool
+
+	// Creep is the distance by which the pages of each sheet are shifted
+	// towards the spine, relative to the previous (outer) sheet, in order to
+	// compensate for the paper thickness when folding. Booklet mode only.
+	Creep float64
+
+	// Annotations specifies how the annotations of the source pages are
+	// handled. The annotations are dropped by default.
+	Annotations AnnotationMode
+}
+
+// sourcePage holds a source page prepared for placement.
+type sourcePage struct {
+	page   *model.PdfPage
+	form   *model.XObjectForm
+	box    model.PdfRectangle // Crop box, normalized.
+	rotate int64              // Rotation, normalized to 0, 90, 180 or 270.
+}
+
+// displaySize returns the size of the source page as displayed, i.e. after
+// rotation.
+func (src *sourcePage) displaySize() (float64, float64) {
+	w, h := src.box.Width(), src.box.Height()
+	if src.rotate == 90 || src.rotate == 270 {
+		return h, w
+	}
+	return w, h
+}
+
+// Impose lays out `pages` on new pages according to `opts` and returns the
+// new pages. Pass nil for the opts parameter in order to use the default
+// options. Each source page is converted to a Form XObject, clipped to its
+// crop box, which is rotated according to the Rotate entry of the page,
+// uniformly scaled to fit its cell of the grid and centered in it. Blank
+// cells are left empty.
+//
+// NOTE: The resources of the source pages are shared with the output pages.
+// With the AnnotationsTransform mode, the annotations of the source pages are
+// modified and moved to the output pages.
+func Impose(pages []*model.PdfPage, opts *Options) ([]*model.PdfPage, error) {
+	if len(pages) == 0 {
+		return nil, errors.New("no pages to impose")
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+	cols, rows := opts.Columns, opts.Rows
+	if cols == 0 {
+		cols = 2
+	}
+	if rows == 0 {
+		rows = 1
+	}
+	if cols <= 0 || rows <= 0 {
+		return nil, fmt.Errorf("invalid grid: %dx%d", cols, rows)
+	}
+	if opts.Booklet && (cols != 2 || rows != 1) {
+		return nil, fmt.Errorf("booklet mode requires a 2x1 grid, got %dx%d", cols, rows)
+	}
+
+	sources := make([]*sourcePage, len(pages))
+	for i, page := range pages {
+		src, err := newSourcePage(page)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %v", i+1, err)
+		}
+		sources[i] = src
+	}
+
+	var order []int
+	if opts.Booklet {
+		order = BookletOrder(len(pages))
+	} else {
+		for i := range pages {
+			order = append(order, i+1)
+		}
+	}
+
+	var mediaBox model.PdfRectangle
+	if opts.PageSize != nil {
+		mediaBox = opts.PageSize.Normalized()
+	} else {
+		w, h := sources[0].displaySize()
+		if (cols > rows && w < h) || (rows > cols && w > h) {
+			w, h = h, w
+		}
+		mediaBox = model.PdfRectangle{Urx: w, Ury: h}
+	}
+	cellW := (mediaBox.Width() - 2*opts.Margin - float64(cols-1)*opts.Gutter) / float64(cols)
+	cellH := (mediaBox.Height() - 2*opts.Margin - float64(rows-1)*opts.Gutter) / float64(rows)
+	if cellW <= 0 || cellH <= 0 {
+		return nil, errors.New("margin and gutter exceed the page size")
+	}
+
+	var outPages []*model.PdfPage
+	placements := map[*core.PdfIndirectObject]*model.PdfPage{}
+	var placed []placement
+	perPage := cols * rows
+	for start := 0; start < len(order); start += perPage {
+		outPage := model.NewPdfPage()
+		box := mediaBox
+		outPage.MediaBox = &box
+
+		cc := contentstream.NewContentCreator()
+		for k := 0; k < perPage && start+k < len(order); k++ {
+			num := order[start+k]
+			if num == 0 {
+				continue
+			}
+			src := sources[num-1]
+
+			col, row := k%cols, k/cols
+			cellX := mediaBox.Llx + opts.Margin + float64(col)*(cellW+opts.Gutter)
+			cellY := mediaBox.Ury - opts.Margin - float64(row+1)*cellH - float64(row)*opts.Gutter
+			if opts.Booklet {
+				shift := opts.Creep * float64(len(outPages)/2)
+				if col == 0 {
+					cellX += shift
+				} else {
+					cellX -= shift
+				}
+			}
+			m := placementMatrix(src, cellX, cellY, cellW, cellH, ResizeFit)
+
+			name := core.PdfObjectName(fmt.Sprintf("Pg%d", k))
+			if err := outPage.Resources.SetXObjectFormByName(name, src.form); err != nil {
+				return nil, err
+			}
+			cc.Add_q().
+				Add_cm(m[0], m[1], m[3], m[4], m[6], m[7]).
+				Add_Do(name).
+				Add_Q()
+
+			if _, ok := placements[src.page.GetPageAsIndirectObject()]; !ok {
+				placements[src.page.GetPageAsIndirectObject()] = outPage
+			}
+			placed = append(placed, placement{src: src, page: outPage, m: m})
+		}
+		if err := outPage.SetContentStreams([]string{cc.String()}, core.NewFlateEncoder()); err != nil {
+			return nil, err
+		}
+		outPages = append(outPages, outPage)
+	}
+
+	if opts.Annotations == AnnotationsTransform {
+		if err := transformAnnotations(placed, placements); err != nil {
+			return nil, err
+		}
+	}
+	return outPages, nil
+}
+
+// BookletOrder returns the order in which the pages of a document with
+// `numPages` pages are placed on the sides of the sheets of a saddle-stitched
+// booklet, 2 pages per side: n, 1, 2, n-1, n-2, 3, ... where n is `numPages`
+// rounded up to a multiple of 4. The page numbers are 1-based and the blank
+// pages padding the document are 0.
+func BookletOrder(numPages int) []int {
+	if numPages <= 0 {
+		return nil
+	}
+	n := (numPages + 3) / 4 * 4
+	page := func(num int) int {
+		if num > numPages {
+			return 0
+		}
+		return num
+	}
+
+	order := make([]int, 0, n)
+	for i := 0; i < n/4; i++ {
+		order = append(order,
+			page(n-2*i), page(2*i+1), // Front side.
+			page(2*i+2), page(n-2*i-1), // Back side.
+		)
+	}
+	return order
+}
+
+// newSourcePage converts `page` to a Form XObject.
+func newSourcePage(page *model.PdfPage) (*sourcePage, error) {
+	box, rotate, err := getPageBox(page)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := page.GetAllContentStreams()
+	if err != nil {
+		return nil, err
+	}
+	src := &sourcePage{page: page, box: box, rotate: rotate}
+	form := model.NewXObjectForm()
+	form.Resources = page.Resources
+	form.BBox = src.box.ToPdfObject()
+	form.Group = page.Group
+	if err := form.SetContentStream([]byte(content), core.NewFlateEncoder()); err != nil {
+		return nil, err
+	}
+	src.form = form
+	return src, nil
+}
+
+// getPageBox returns the crop box of `page` (see PdfPage.GetCropBox) and its
+// rotation (see PdfPage.GetRotation).
+func getPageBox(page *model.PdfPage) (model.PdfRectangle, int64, error) {
+	box, err := page.GetCropBox()
+	if err != nil {
+		return model.PdfRectangle{}, 0, err
+	}
+
+	rotate, err := page.GetRotation()
+	if err != nil {
+		return model.PdfRectangle{}, 0, err
+	}
+	return *box, rotate, nil
+}
+
+// placementMatrix returns the matrix mapping the crop box of `src` to the
+// cell with the lower left corner at (`x`, `y`) and the size `w` x `h`,
+// scaled according to `mode` and centered in the cell.
+func placementMatrix(src *sourcePage, x, y, w, h float64, mode ResizeMode) transform.Matrix {
+	dispW, dispH := src.displaySize()
+	sx, sy := w/dispW, h/dispH
+	switch mode {
+	case ResizeFit:
+		sx = math.Min(sx, sy)
+		sy = sx
+	case ResizeFill:
+		sx = math.Max(sx, sy)
+		sy = sx
+	}
+	m := transform.TranslationMatrix(x+(w-sx*dispW)/2, y+(h-sy*dispH)/2)
+	m.Concat(transform.ScaleMatrix(sx, sy))
+	m.Concat(rotationMatrix(src.box, src.rotate))
+	return m
+}
+
+// rotationMatrix returns the matrix rotating `box` clockwise by `rotate`
+// degrees (0, 90, 180 or 270), with the lower left corner of the rotated box
+// at the origin.
+func rotationMatrix(box model.PdfRectangle, rotate int64) transform.Matrix {
+	w, h := box.Width(), box.Height()
+	var m transform.Matrix
+	switch rotate {
+	case 90:
+		m = transform.NewMatrix(0, -1, 1, 0, 0, w)
+	case 180:
+		m = transform.NewMatrix(-1, 0, 0, -1, w, h)
+	case 270:
+		m = transform.NewMatrix(0, 1, -1, 0, h, 0)
+	default:
+		m = transform.IdentityMatrix()
+	}
+	m.Concat(transform.TranslationMatrix(-box.Llx, -box.Lly))
+	return m
+}
+
+// placement represents a source page placed on an output page.
+type placement struct {
+	src  *sourcePage
+	page *model.PdfPage
+	m    transform.Matrix
+}
+
+// transformAnnotations moves the annotations of the placed source pages to
+// the output pages. `placements` maps the source pages to the first output
+// pages they are placed on.
+func transformAnnotations(placed []placement, placements map[*core.PdfIndirectObject]*model.PdfPage) error {
+	moved := map[*model.PdfAnnotation]struct{}{}
+	for _, p := range placed {
+		annotations, err := p.src.page.GetAnnotations()
+		if err != nil {
+			return err
+		}
+		for _, annot := range annotations {
+			if _, ok := moved[annot]; ok {
+				// Source pages placed more than once keep their annotations
+				// on the first placement only.
+				continue
+			}
+			moved[annot] = struct{}{}
+
+			if link, ok := annot.GetContext().(*model.PdfAnnotationLink); ok {
+				dest, err := link.GetDestination()
+				if err != nil {
+					common.Log.Debug("WARN: invalid link destination, dropping link: %v", err)
+					continue
+				}
+				if dest != nil {
+					target, ok := placements[dest.Page]
+					if dest.Page == nil || !ok {
+						continue
+					}
+					link.Dest = model.NewPdfDestinationFit(target.GetPageAsIndirectObject()).ToPdfObject()
+					link.A = nil
+				}
+			}
+
+			if rect, ok := core.GetArray(annot.Rect); ok {
+				if r, err := model.NewPdfRectangle(*rect); err == nil {
+					tr := r.Transform(p.m)
+					annot.Rect = tr.ToPdfObject()
+				}
+			}
+			transformQuadPoints(annot.GetContext(), p.m)
+			annot.P = p.page.GetPageAsIndirectObject()
+			p.page.AddAnnotation(annot)
+		}
+	}
+	return nil
+}
+
+// transformPoint returns the point (`x`, `y`) transformed by `m`.
+func transformPoint(m transform.Matrix, x, y float64) (float64, float64) {
+	return x*m[0] + y*m[3] + m[6], x*m[1] + y*m[4] + m[7]
+}
+
+// transformQuadPoints transforms the QuadPoints of the link and text markup
+// annotations `ctx` by `m`.
+func transformQuadPoints(ctx interface{}, m transform.Matrix) {
+	var quadPoints *core.PdfObject
+	switch t := ctx.(type) {
+	case *model.PdfAnnotationLink:
+		quadPoints = &t.QuadPoints
+	case *model.PdfAnnotationHighlight:
+		quadPoints = &t.QuadPoints
+	case *model.PdfAnnotationUnderline:
+		quadPoints = &t.QuadPoints
+	case *model.PdfAnnotationSquiggly:
+		quadPoints = &t.QuadPoints
+	case *model.PdfAnnotationStrikeOut:
+		quadPoints = &t.QuadPoints
+	case *model.PdfAnnotationRedact:
+		quadPoints = &t.QuadPoints
+	default:
+		return
+	}
+
+	arr, ok := core.GetArray(*quadPoints)
+	if !ok {
+		return
+	}
+	coords, err := arr.ToFloat64Array()
+	if err != nil || len(coords)%2 != 0 {
+		return
+	}
+	for i := 0; i < len(coords); i += 2 {
+		coords[i], coords[i+1] = transformPoint(m, coords[i], coords[i+1])
+	}
+	*quadPoints = core.MakeArrayFromFloats(coords)
+}
@@ -0,0 +1,221 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package imposition
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// makePages returns `n` A4 portrait pages. The content of page i (1-based)
+// sets the line width to i, identifying the page.
+func makePages(t *testing.T, n int) []*model.PdfPage {
+	var pages []*model.PdfPage
+	for i := 1; i <= n; i++ {
+		page := model.NewPdfPage()
+		page.MediaBox = &model.PdfRectangle{Urx: 595, Ury: 842}
+		require.NoError(t, page.AddContentStreamByString(fmt.Sprintf("%d w", i)))
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// placedForm describes a Form XObject drawn on an imposed page.
+type placedForm struct {
+	matrix []float64
+	page   int // Number of the source page.
+}
+
+// getPlacedForms returns the forms drawn on `page`, in drawing order.
+func getPlacedForms(t *testing.T, page *model.PdfPage) []placedForm {
+	content, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	ops, err := contentstream.NewContentStreamParser(content).Parse()
+	require.NoError(t, err)
+
+	var forms []placedForm
+	var matrix []float64
+	for _, op := range *ops {
+		switch op.Operand {
+		case "cm":
+			matrix, err = core.GetNumbersAsFloat(op.Params)
+			require.NoError(t, err)
+		case "Do":
+			name, ok := core.GetName(op.Params[0])
+			require.True(t, ok)
+			form, err := page.Resources.GetXObjectFormByName(*name)
+			require.NoError(t, err)
+			formContent, err := form.GetContentStream()
+			require.NoError(t, err)
+			var num int
+			_, err = fmt.Sscanf(string(formContent), "%d w", &num)
+			require.NoError(t, err)
+			forms = append(forms, placedForm{matrix: matrix, page: num})
+		}
+	}
+	return forms
+}
+
+// requireMatrix checks that the values of the matrix (or rectangle) `actual`
+// are approximately `expected`.
+func requireMatrix(t *testing.T, expected, actual []float64) {
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		require.InDelta(t, expected[i], actual[i], 0.01, "matrix %v", actual)
+	}
+}
+
+func TestImpose2Up(t *testing.T) {
+	pages := makePages(t, 3)
+	rotate := int64(90)
+	pages[2].Rotate = &rotate
+	pages[2].CropBox = &model.PdfRectangle{Llx: 10, Lly: 20, Urx: 305, Ury: 441}
+
+	outPages, err := Impose(pages, nil)
+	require.NoError(t, err)
+	require.Len(t, outPages, 2)
+	for _, page := range outPages {
+		require.Equal(t, model.PdfRectangle{Urx: 842, Ury: 595}, *page.MediaBox)
+	}
+
+	// A4 portrait pages, scaled to fit the halves of an A4 landscape page.
+	scale := 595.0 / 842.0
+	offset := (421 - 595*scale) / 2
+	forms := getPlacedForms(t, outPages[0])
+	require.Len(t, forms, 2)
+	require.Equal(t, 1, forms[0].page)
+	requireMatrix(t, []float64{scale, 0, 0, scale, offset, 0}, forms[0].matrix)
+	require.Equal(t, 2, forms[1].page)
+	requireMatrix(t, []float64{scale, 0, 0, scale, 421 + offset, 0}, forms[1].matrix)
+
+	// The rotated crop box (421x295 as displayed) fits its cell unscaled.
+	forms = getPlacedForms(t, outPages[1])
+	require.Len(t, forms, 1)
+	requireMatrix(t, []float64{0, -1, 1, 0, -20, 455}, forms[0].matrix)
+	form, err := outPages[1].Resources.GetXObjectFormByName("Pg0")
+	require.NoError(t, err)
+	bbox, ok := core.GetArray(form.BBox)
+	require.True(t, ok)
+	values, err := bbox.ToFloat64Array()
+	require.NoError(t, err)
+	require.Equal(t, []float64{10, 20, 305, 441}, values)
+
+	_, err = Impose(nil, nil)
+	require.Error(t, err)
+	_, err = Impose(pages, &Options{Columns: 2, Rows: 2, Booklet: true})
+	require.Error(t, err)
+	_, err = Impose(pages, &Options{Margin: 500})
+	require.Error(t, err)
+}
+
+func TestImposeGrid(t *testing.T) {
+	pages := makePages(t, 4)
+	outPages, err := Impose(pages, &Options{
+		Columns:  2,
+		Rows:     2,
+		PageSize: &model.PdfRectangle{Urx: 600, Ury: 850},
+		Margin:   10,
+		Gutter:   20,
+	})
+	require.NoError(t, err)
+	require.Len(t, outPages, 1)
+
+	// Cells of 280x405, filled in reading order.
+	scale := 280.0 / 595.0
+	offsetY := (405 - 842*scale) / 2
+	forms := getPlacedForms(t, outPages[0])
+	require.Len(t, forms, 4)
+	for i, form := range forms {
+		x := 10 + float64(i%2)*300
+		y := 10 + float64(1-i/2)*425 + offsetY
+		require.Equal(t, i+1, form.page)
+		requireMatrix(t, []float64{scale, 0, 0, scale, x, y}, form.matrix)
+	}
+
+	// The unspecified dimension of the grid has its default value.
+	for _, opts := range []*Options{{Rows: 2}, {Columns: 4}} {
+		outPages, err = Impose(pages, opts)
+		require.NoError(t, err)
+		require.Len(t, outPages, 1)
+		require.Len(t, getPlacedForms(t, outPages[0]), 4)
+	}
+	_, err = Impose(pages, &Options{Columns: -1})
+	require.Error(t, err)
+}
+
+func TestBookletOrder(t *testing.T) {
+	require.Nil(t, BookletOrder(0))
+	require.Equal(t, []int{4, 1, 2, 3}, BookletOrder(4))
+	require.Equal(t, []int{0, 1, 2, 0, 0, 3, 4, 5}, BookletOrder(5))
+	require.Equal(t, []int{8, 1, 2, 7, 6, 3, 4, 5}, BookletOrder(8))
+}
+
+func TestImposeBooklet(t *testing.T) {
+	pages := makePages(t, 5)
+	outPages, err := Impose(pages, &Options{Booklet: true, Creep: 2})
+	require.NoError(t, err)
+	require.Len(t, outPages, 4)
+
+	expected := [][]int{{1}, {2}, {3}, {4, 5}}
+	for i, page := range outPages {
+		var nums []int
+		for _, form := range getPlacedForms(t, page) {
+			nums = append(nums, form.page)
+		}
+		require.Equal(t, expected[i], nums)
+	}
+
+	// The pages of the inner sheet are shifted towards the spine.
+	scale := 595.0 / 842.0
+	offset := (421 - 595*scale) / 2
+	forms := getPlacedForms(t, outPages[0])
+	requireMatrix(t, []float64{scale, 0, 0, scale, 421 + offset, 0}, forms[0].matrix)
+	forms = getPlacedForms(t, outPages[3])
+	requireMatrix(t, []float64{scale, 0, 0, scale, offset + 2, 0}, forms[0].matrix)
+	requireMatrix(t, []float64{scale, 0, 0, scale, 421 + offset - 2, 0}, forms[1].matrix)
+}
+
+func TestImposeAnnotations(t *testing.T) {
+	makeAnnotatedPages := func() []*model.PdfPage {
+		pages := makePages(t, 2)
+		link := model.NewPdfAnnotationLink()
+		link.Rect = core.MakeArrayFromFloats([]float64{0, 0, 100, 100})
+		link.Dest = model.NewPdfDestinationFit(pages[1].GetPageAsIndirectObject()).ToPdfObject()
+		pages[0].AddAnnotation(link.PdfAnnotation)
+		return pages
+	}
+
+	outPages, err := Impose(makeAnnotatedPages(), nil)
+	require.NoError(t, err)
+	annots, err := outPages[0].GetAnnotations()
+	require.NoError(t, err)
+	require.Empty(t, annots)
+
+	outPages, err = Impose(makeAnnotatedPages(), &Options{Annotations: AnnotationsTransform})
+	require.NoError(t, err)
+	annots, err = outPages[0].GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 1)
+
+	scale := 595.0 / 842.0
+	offset := (421 - 595*scale) / 2
+	rect, ok := core.GetArray(annots[0].Rect)
+	require.True(t, ok)
+	values, err := rect.ToFloat64Array()
+	require.NoError(t, err)
+	requireMatrix(t, []float64{offset, 0, offset + 100*scale, 100 * scale}, values)
+	require.Equal(t, outPages[0].GetPageAsIndirectObject(), annots[0].P)
+
+	dest, err := annots[0].GetContext().(*model.PdfAnnotationLink).GetDestination()
+	require.NoError(t, err)
+	require.Equal(t, outPages[0].GetPageAsIndirectObject(), dest.Page)
+}
@@ -0,0 +1,137 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package imposition
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/internal/pageutil"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// OverlayLayer specifies whether the overlay pages are drawn above or below
+// the content of the base pages.
+type OverlayLayer int
+
+// Overlay layers.
+const (
+	// OverlayForeground draws the overlay pages above the content of the base
+	// pages.
+	OverlayForeground OverlayLayer = iota
+
+	// OverlayBackground draws the overlay pages below the content of the base
+	// pages (underlay), e.g. pre-printed form backgrounds.
+	OverlayBackground
+)
+
+// OverlayOptions defines how the overlay pages are drawn on the base pages.
+type OverlayOptions struct {
+	// Layer specifies whether the overlay pages are drawn above or below the
+	// content of the base pages. Defaults to OverlayForeground.
+	Layer OverlayLayer
+
+	// Fit specifies how the overlay pages are scaled to the base pages of
+	// different sizes. The overlay pages are centered on the base pages.
+	// Defaults to ResizeFit.
+	Fit ResizeMode
+
+	// PageOffset is the number of base pages left unchanged before the first
+	// overlay page: overlay page i is drawn on base page i+PageOffset.
+	PageOffset int
+
+	// RepeatLastPage specifies whether the last overlay page is drawn on the
+	// base pages following it. These pages are left unchanged otherwise.
+	RepeatLastPage bool
+}
+
+// Overlay draws the pages of `overlay` on the pages of `base`, page by page,
+// according to `opts`. Pass nil for the opts parameter in order to use the
+// default options. Each overlay page is converted to a Form XObject, clipped
+// to its crop box, and drawn on the crop box of its base page, both pages
+// oriented as displayed, i.e. according to their Rotate entries. The content
+// of the base pages is wrapped so that the graphics state it leaves does not
+// affect the overlay pages, which keep their own resources.
+//
+// NOTE: The resources of the overlay pages are shared with the base pages.
+// The annotations of the overlay pages are not copied.
+func Overlay(base, overlay []*model.PdfPage, opts *OverlayOptions) error {
+	if len(overlay) == 0 {
+		return errors.New("no overlay pages")
+	}
+	if opts == nil {
+		opts = &OverlayOptions{}
+	}
+	if opts.PageOffset < 0 {
+		return fmt.Errorf("invalid page offset: %d", opts.PageOffset)
+	}
+
+	sources := make([]*sourcePage, len(overlay))
+	for i := opts.PageOffset; i < len(base); i++ {
+		k := i - opts.PageOffset
+		if k >= len(overlay) {
+			if !opts.RepeatLastPage {
+				break
+			}
+			k = len(overlay) - 1
+		}
+		if sources[k] == nil {
+			src, err := newSourcePage(overlay[k])
+			if err != nil {
+				return fmt.Errorf("overlay page %d: %v", k+1, err)
+			}
+			sources[k] = src
+		}
+		if err := overlayPage(base[i], sources[k], opts); err != nil {
+			return fmt.Errorf("page %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// overlayPage draws the overlay page `src` on `page`.
+func overlayPage(page *model.PdfPage, src *sourcePage, opts *OverlayOptions) error {
+	box, rotate, err := getPageBox(page)
+	if err != nil {
+		return err
+	}
+	box = box.Normalized()
+	srcW, srcH := src.displaySize()
+	if box.Width() == 0 || box.Height() == 0 || srcW == 0 || srcH == 0 {
+		return errors.New("empty page box")
+	}
+	w, h := box.Width(), box.Height()
+	if rotate == 90 || rotate == 270 {
+		w, h = h, w
+	}
+	// The overlay page is placed on the base page as displayed, which is then
+	// rotated back.
+	m := pageutil.DisplayMatrix(box, rotate)
+	m.Concat(placementMatrix(src, 0, 0, w, h, opts.Fit))
+
+	if page.Resources == nil {
+		page.Resources = model.NewPdfPageResources()
+	}
+	name := page.Resources.GenerateXObjectName()
+	if err := page.Resources.SetXObjectFormByName(name, src.form); err != nil {
+		return err
+	}
+	cc := contentstream.NewContentCreator()
+	if opts.Layer == OverlayForeground {
+		// Restores the graphics state saved before the content of the page.
+		cc.Add_Q()
+	}
+	cc.Add_q().
+		Add_cm(m[0], m[1], m[3], m[4], m[6], m[7]).
+		Add_Do(name).
+		Add_Q()
+
+	if opts.Layer == OverlayBackground {
+		return wrapContents(page, cc.Bytes(), nil)
+	}
+	return wrapContents(page, []byte("q\n"), cc.Bytes())
+}
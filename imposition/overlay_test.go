@@ -0,0 +1,141 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package imposition
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/extractor"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// makeTextPages returns `n` pages of size `box` with the text "<label> <i>" (1-based) at (100,
+// `y`), in the font `font` named F1 in the resources of the pages.
+func makeTextPages(t *testing.T, n int, box model.PdfRectangle, y float64, label string,
+	font model.StdFontName) []*model.PdfPage {
+	var pages []*model.PdfPage
+	for i := 1; i <= n; i++ {
+		page := model.NewPdfPage()
+		mediaBox := box
+		page.MediaBox = &mediaBox
+		require.NoError(t, page.Resources.SetFontByName("F1", model.NewStandard14FontMustCompile(font).ToPdfObject()))
+		// The content leaves a modified graphics state, which must not affect the overlay.
+		content := fmt.Sprintf("2 0 0 2 0 0 cm BT /F1 5 Tf 50 %g Td (%s %d) Tj ET", y/2, label, i)
+		require.NoError(t, page.SetContentStreams([]string{content}, core.NewRawEncoder()))
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// wordMarks returns the text of `page` and the marks of the first character of the occurrences of
+// `word` on it.
+func wordMarks(t *testing.T, page *model.PdfPage, word string) (string, []extractor.TextMark) {
+	ex, err := extractor.New(page)
+	require.NoError(t, err)
+	pageText, _, _, err := ex.ExtractPageText()
+	require.NoError(t, err)
+	text := pageText.Text()
+
+	var marks []extractor.TextMark
+	for start := 0; ; {
+		i := strings.Index(text[start:], word)
+		if i < 0 {
+			break
+		}
+		for _, mark := range pageText.Marks().Elements() {
+			if !mark.Meta && mark.Offset == start+i {
+				marks = append(marks, mark)
+			}
+		}
+		start += i + len(word)
+	}
+	return text, marks
+}
+
+func TestOverlay(t *testing.T) {
+	letter := model.PdfRectangle{Urx: 612, Ury: 792}
+	base := makeTextPages(t, 4, letter, 100, "Base", model.TimesRomanName)
+	rotate := int64(90)
+	base[3].Rotate = &rotate
+	overlay := makeTextPages(t, 2, a4, 300, "Overlay", model.HelveticaName)
+
+	require.NoError(t, Overlay(base, overlay, &OverlayOptions{RepeatLastPage: true}))
+	for i, page := range base {
+		text, marks := wordMarks(t, page, "Overlay")
+		require.Contains(t, text, fmt.Sprintf("Base %d", i+1))
+		// The last overlay page is repeated.
+		num := i + 1
+		if num > 2 {
+			num = 2
+		}
+		require.Contains(t, text, fmt.Sprintf("Overlay %d", num))
+		require.Len(t, marks, 1)
+		// The overlay pages use a different font named F1, kept in their own resources.
+		require.Equal(t, "Helvetica", marks[0].Font.BaseFont())
+		_, baseMarks := wordMarks(t, page, "Base")
+		require.Equal(t, "Times-Roman", baseMarks[0].Font.BaseFont())
+
+		// The A4 overlay page is fitted to the letter base page, by height, centered
+		// horizontally, and oriented as the base page is displayed.
+		scale := 792 / a4.Height()
+		x, y := (612-scale*a4.Width())/2+scale*100, scale*300
+		bbox := marks[0].BBox
+		if i == 3 {
+			// The page is displayed rotated: the overlay is placed on the 792 x 612 display.
+			scale = 612 / a4.Height()
+			dx, dy := (792-scale*a4.Width())/2+scale*100, scale*300
+			x, y = 612-dy, dx
+		}
+		require.InDelta(t, x, bbox.Llx, 1, "bbox %+v", bbox)
+		require.InDelta(t, y, bbox.Lly, 1, "bbox %+v", bbox)
+		require.InDelta(t, 100, baseMarks[0].BBox.Llx, 1)
+		require.InDelta(t, 100, baseMarks[0].BBox.Lly, 1)
+	}
+}
+
+// TestOverlayOffset checks that the base pages before the page offset and after the last overlay
+// page are left unchanged, and the underlay of the overlay pages.
+func TestOverlayOffset(t *testing.T) {
+	letter := model.PdfRectangle{Urx: 612, Ury: 792}
+	base := makeTextPages(t, 4, letter, 100, "Base", model.TimesRomanName)
+	overlay := makeTextPages(t, 2, letter, 300, "Overlay", model.HelveticaName)
+
+	require.NoError(t, Overlay(base, overlay, &OverlayOptions{
+		PageOffset: 1,
+		Layer:      OverlayBackground,
+		Fit:        ResizeStretch,
+	}))
+	expected := []string{"", "Overlay 1", "Overlay 2", ""}
+	for i, page := range base {
+		text, marks := wordMarks(t, page, "Overlay")
+		require.Contains(t, text, fmt.Sprintf("Base %d", i+1))
+		if expected[i] == "" {
+			require.Empty(t, marks, text)
+			_, ok := core.GetStream(page.Contents)
+			require.True(t, ok, "page %d content changed", i+1)
+			continue
+		}
+		require.Contains(t, text, expected[i])
+		// The overlay pages of the same size are not scaled.
+		require.InDelta(t, 100, marks[0].BBox.Llx, 1)
+		require.InDelta(t, 300, marks[0].BBox.Lly, 1)
+
+		// The overlay page is drawn before the content of the base page.
+		contents, err := page.GetContentStreams()
+		require.NoError(t, err)
+		require.Len(t, contents, 2)
+		require.Contains(t, contents[0], "Do")
+		require.Contains(t, contents[1], "Base")
+	}
+
+	require.Error(t, Overlay(base, nil, nil))
+	require.Error(t, Overlay(base, overlay, &OverlayOptions{PageOffset: -1}))
+}
@@ -0,0 +1,245 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package imposition
+
+import (
+	"errors"
+	"math"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// ResizeMode specifies how the content of a page is scaled to a new page
+// size.
+type ResizeMode int
+
+// Resize modes.
+const (
+	// ResizeFit scales the content uniformly to fit in the page, centered.
+	ResizeFit ResizeMode = iota
+
+	// ResizeFill scales the content uniformly to cover the page, centered.
+	// The content exceeding the page is clipped.
+	ResizeFill
+
+	// ResizeStretch scales the content non-uniformly to the page.
+	ResizeStretch
+)
+
+// ResizeOptions defines how the content of the resized pages is scaled.
+type ResizeOptions struct {
+	// Mode is the scaling mode. Defaults to ResizeFit.
+	Mode ResizeMode
+
+	// Margin is the space left between the edges of the pages and the scaled
+	// content.
+	Margin float64
+}
+
+// ResizePage resizes `page` to `size`. See ResizePages.
+func ResizePage(page *model.PdfPage, size model.PdfRectangle, opts *ResizeOptions) error {
+	return ResizePages([]*model.PdfPage{page}, size, opts)
+}
+
+// ResizePages sets the media box of `pages` to `size` and scales their
+// content to the new size according to `opts`. Pass nil for the opts
+// parameter in order to use the default options. The size applies to the
+// pages as displayed: the media box of pages rotated by 90 or 270 degrees is
+// transposed, so that the rotation is preserved.
+//
+// The content of the pages is wrapped in a transformation, clipped to the
+// previous crop box of the pages, which is removed. The trim, bleed and art
+// boxes, the rectangles and quadrilaterals of the annotations, and the
+// explicit destinations of the links on `pages` pointing to `pages` are
+// transformed accordingly.
+func ResizePages(pages []*model.PdfPage, size model.PdfRectangle, opts *ResizeOptions) error {
+	if opts == nil {
+		opts = &ResizeOptions{}
+	}
+
+	matrices := map[*core.PdfIndirectObject]transform.Matrix{}
+	for _, page := range pages {
+		m, err := resizePage(page, size.Normalized(), opts)
+		if err != nil {
+			return err
+		}
+		matrices[page.GetPageAsIndirectObject()] = m
+	}
+
+	for _, page := range pages {
+		annotations, err := page.GetAnnotations()
+		if err != nil {
+			return err
+		}
+		m := matrices[page.GetPageAsIndirectObject()]
+		for _, annot := range annotations {
+			if rect, ok := core.GetArray(annot.Rect); ok {
+				if r, err := model.NewPdfRectangle(*rect); err == nil {
+					tr := r.Transform(m)
+					annot.Rect = tr.ToPdfObject()
+				}
+			}
+			transformQuadPoints(annot.GetContext(), m)
+			if link, ok := annot.GetContext().(*model.PdfAnnotationLink); ok {
+				if err := transformLinkDestination(link, matrices); err != nil {
+					common.Log.Debug("WARN: could not transform link destination: %v", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resizePage resizes `page` to `size` and returns the matrix transforming its
+// content.
+func resizePage(page *model.PdfPage, size model.PdfRectangle, opts *ResizeOptions) (transform.Matrix, error) {
+	box, rotate, err := getPageBox(page)
+	if err != nil {
+		return transform.Matrix{}, err
+	}
+	if box.Width() == 0 || box.Height() == 0 {
+		return transform.Matrix{}, errors.New("empty page box")
+	}
+
+	width, height := size.Width(), size.Height()
+	if rotate == 90 || rotate == 270 {
+		width, height = height, width
+	}
+	inner := model.PdfRectangle{
+		Llx: opts.Margin,
+		Lly: opts.Margin,
+		Urx: width - opts.Margin,
+		Ury: height - opts.Margin,
+	}
+	if inner.Urx <= inner.Llx || inner.Ury <= inner.Lly {
+		return transform.Matrix{}, errors.New("margin exceeds the page size")
+	}
+
+	sx, sy := inner.Width()/box.Width(), inner.Height()/box.Height()
+	switch opts.Mode {
+	case ResizeFit:
+		sx = math.Min(sx, sy)
+		sy = sx
+	case ResizeFill:
+		sx = math.Max(sx, sy)
+		sy = sx
+	}
+	m := transform.NewMatrix(sx, 0, 0, sy,
+		inner.Llx+(inner.Width()-sx*box.Width())/2-sx*box.Llx,
+		inner.Lly+(inner.Height()-sy*box.Height())/2-sy*box.Lly)
+
+	clip := box.Transform(m)
+	clip.Llx, clip.Lly = math.Max(clip.Llx, inner.Llx), math.Max(clip.Lly, inner.Lly)
+	clip.Urx, clip.Ury = math.Min(clip.Urx, inner.Urx), math.Min(clip.Ury, inner.Ury)
+
+	if page.Contents != nil {
+		prefix := contentstream.NewContentCreator().
+			Add_q().
+			Add_re(clip.Llx, clip.Lly, clip.Width(), clip.Height()).
+			Add_W().
+			Add_n().
+			Add_cm(m[0], m[1], m[3], m[4], m[6], m[7])
+		suffix := contentstream.NewContentCreator().Add_Q()
+		if err := wrapContents(page, prefix.Bytes(), suffix.Bytes()); err != nil {
+			return transform.Matrix{}, err
+		}
+	}
+
+	page.MediaBox = &model.PdfRectangle{Urx: width, Ury: height}
+	page.CropBox = nil
+	for _, pbox := range []**model.PdfRectangle{&page.TrimBox, &page.BleedBox, &page.ArtBox} {
+		if *pbox != nil {
+			tr := (*pbox).Transform(m)
+			*pbox = &tr
+		}
+	}
+	return m, nil
+}
+
+// wrapContents surrounds the content streams of `page` with the streams
+// `prefix` and `suffix`, which are omitted if nil. The existing streams are
+// not re-encoded.
+func wrapContents(page *model.PdfPage, prefix, suffix []byte) error {
+	contents := core.MakeArray()
+	if prefix != nil {
+		prefixStream, err := core.MakeStream(prefix, core.NewFlateEncoder())
+		if err != nil {
+			return err
+		}
+		contents.Append(prefixStream)
+	}
+	if arr, ok := core.GetArray(page.Contents); ok {
+		contents.Append(arr.Elements()...)
+	} else if page.Contents != nil {
+		contents.Append(page.Contents)
+	}
+	if suffix != nil {
+		suffixStream, err := core.MakeStream(suffix, core.NewFlateEncoder())
+		if err != nil {
+			return err
+		}
+		contents.Append(suffixStream)
+	}
+	page.Contents = contents
+	return nil
+}
+
+// transformLinkDestination transforms the coordinates of the explicit
+// destination of `link`, specified either by its Dest entry or by the D entry
+// of its GoTo action, if it points to a page in `matrices`.
+func transformLinkDestination(link *model.PdfAnnotationLink, matrices map[*core.PdfIndirectObject]transform.Matrix) error {
+	var goTo *model.PdfActionGoTo
+	destObj := link.Dest
+	if destObj == nil {
+		action, err := link.GetAction()
+		if err != nil || action == nil {
+			return err
+		}
+		t, ok := action.GetContext().(*model.PdfActionGoTo)
+		if !ok {
+			return nil
+		}
+		goTo, destObj = t, t.D
+	}
+	if _, ok := core.GetArray(destObj); !ok {
+		// Named destinations are not transformed.
+		return nil
+	}
+
+	dest, err := link.GetDestination()
+	if err != nil || dest == nil || dest.Page == nil {
+		return err
+	}
+	m, ok := matrices[dest.Page]
+	if !ok {
+		return nil
+	}
+
+	// The resize matrices only scale and translate, so that the coordinates
+	// can be transformed independently.
+	transformCoord := func(v *float64, scale, offset float64) *float64 {
+		if v == nil {
+			return nil
+		}
+		tv := *v*scale + offset
+		return &tv
+	}
+	dest.Left = transformCoord(dest.Left, m[0], m[6])
+	dest.Right = transformCoord(dest.Right, m[0], m[6])
+	dest.Bottom = transformCoord(dest.Bottom, m[4], m[7])
+	dest.Top = transformCoord(dest.Top, m[4], m[7])
+
+	if goTo != nil {
+		goTo.D = dest.ToPdfObject()
+	} else {
+		link.Dest = dest.ToPdfObject()
+	}
+	return nil
+}
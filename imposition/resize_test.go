@@ -0,0 +1,140 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package imposition
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/creator"
+	"github.com/unidoc/unipdf/v3/extractor"
+	"github.com/unidoc/unipdf/v3/internal/pdftest"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// a4 is the A4 page size, in points.
+var a4 = model.PdfRectangle{Urx: creator.PageSizeA4[0], Ury: creator.PageSizeA4[1]}
+
+// writeLetterPage returns a letter page containing the text "Hello" at
+// (100, 100), read back.
+func writeLetterPage(t *testing.T) *model.PdfPage {
+	c := creator.New()
+	c.NewPage()
+	p := c.NewParagraph("Hello")
+	p.SetPos(100, 100)
+	require.NoError(t, c.Draw(p))
+	return pdftest.FirstPage(t, c)
+}
+
+// textBBox returns the bounding box of the first character of "Hello" on
+// `page`.
+func textBBox(t *testing.T, page *model.PdfPage) model.PdfRectangle {
+	ex, err := extractor.New(page)
+	require.NoError(t, err)
+	pageText, _, _, err := ex.ExtractPageText()
+	require.NoError(t, err)
+	start := strings.Index(pageText.Text(), "Hello")
+	require.NotEqual(t, -1, start, pageText.Text())
+	for _, mark := range pageText.Marks().Elements() {
+		if !mark.Meta && mark.Offset == start {
+			return mark.BBox
+		}
+	}
+	require.Fail(t, "mark not found")
+	return model.PdfRectangle{}
+}
+
+func TestResizePage(t *testing.T) {
+	page := writeLetterPage(t)
+	before := textBBox(t, page)
+
+	top := 500.0
+	link := model.NewPdfAnnotationLink()
+	link.Rect = core.MakeArrayFromFloats([]float64{100, 100, 200, 120})
+	link.Dest = model.NewPdfDestinationXYZ(page.GetPageAsIndirectObject(), nil, &top, nil).ToPdfObject()
+	page.AddAnnotation(link.PdfAnnotation)
+
+	require.NoError(t, ResizePage(page, a4, nil))
+	require.Equal(t, a4, *page.MediaBox)
+
+	// Letter to A4: the width is the limiting dimension.
+	scale := a4.Urx / 612
+	offsetY := (a4.Ury - 792*scale) / 2
+
+	resized := pdftest.ReloadPage(t, page)
+	after := textBBox(t, resized)
+	require.InDelta(t, before.Llx*scale, after.Llx, 0.1)
+	require.InDelta(t, before.Lly*scale+offsetY, after.Lly, 0.1)
+	require.InDelta(t, before.Width()*scale, after.Width(), 0.1)
+	require.InDelta(t, before.Height()*scale, after.Height(), 0.1)
+
+	annots, err := resized.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 1)
+	rect, ok := core.GetArray(annots[0].Rect)
+	require.True(t, ok)
+	values, err := rect.ToFloat64Array()
+	require.NoError(t, err)
+	requireMatrix(t, []float64{100 * scale, 100*scale + offsetY, 200 * scale, 120*scale + offsetY}, values)
+	dest, err := annots[0].GetContext().(*model.PdfAnnotationLink).GetDestination()
+	require.NoError(t, err)
+	require.NotNil(t, dest.Top)
+	require.InDelta(t, top*scale+offsetY, *dest.Top, 0.01)
+	require.Nil(t, dest.Left)
+}
+
+func TestResizePageModes(t *testing.T) {
+	newPage := func() *model.PdfPage {
+		page := model.NewPdfPage()
+		page.MediaBox = &model.PdfRectangle{Urx: 100, Ury: 200}
+		page.CropBox = &model.PdfRectangle{Llx: 10, Lly: 20, Urx: 60, Ury: 120}
+		require.NoError(t, page.AddContentStreamByString("0 0 m 100 200 l S"))
+		return page
+	}
+	size := model.PdfRectangle{Urx: 220, Ury: 220}
+
+	cases := []struct {
+		opts   *ResizeOptions
+		matrix []float64
+	}{
+		{&ResizeOptions{Mode: ResizeFit, Margin: 10}, []float64{2, 0, 0, 2, 40, -30}},
+		{&ResizeOptions{Mode: ResizeFill}, []float64{4.4, 0, 0, 4.4, -44, -198}},
+		{&ResizeOptions{Mode: ResizeStretch}, []float64{4.4, 0, 0, 2.2, -44, -44}},
+	}
+	for _, tc := range cases {
+		page := newPage()
+		require.NoError(t, ResizePage(page, size, tc.opts))
+		require.Equal(t, size, *page.MediaBox)
+		require.Nil(t, page.CropBox)
+
+		content, err := page.GetContentStreams()
+		require.NoError(t, err)
+		require.Len(t, content, 3)
+		require.Equal(t, "0 0 m 100 200 l S", content[1])
+		fields := strings.Fields(content[0])
+		require.Equal(t, "cm", fields[len(fields)-1])
+		var matrix []float64
+		for _, f := range fields[len(fields)-7 : len(fields)-1] {
+			v, err := strconv.ParseFloat(f, 64)
+			require.NoError(t, err)
+			matrix = append(matrix, v)
+		}
+		requireMatrix(t, tc.matrix, matrix)
+	}
+
+	// The size of rotated pages is transposed.
+	page := newPage()
+	rotate := int64(270)
+	page.Rotate = &rotate
+	require.NoError(t, ResizePage(page, model.PdfRectangle{Urx: 300, Ury: 200}, nil))
+	require.Equal(t, model.PdfRectangle{Urx: 200, Ury: 300}, *page.MediaBox)
+
+	require.Error(t, ResizePage(newPage(), size, &ResizeOptions{Margin: 110}))
+}
@@ -0,0 +1,138 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package imposition
+
+import (
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// annotationFlagNoRotate is the NoRotate annotation flag (bit position 5).
+const annotationFlagNoRotate = 1 << 4
+
+// NormalizeRotation rewrites `page` so that its rotation (inherited from the
+// page tree if not set) is 0, without changing the way the page is displayed.
+// The content of the page is wrapped in a transformation rotating it, the page
+// boxes are rotated (swapping their dimensions for rotations of 90 and 270
+// degrees) and the rectangles, quadrilaterals and appearance streams of the
+// annotations are rotated accordingly. Annotations with the NoRotate flag keep
+// their appearance unrotated, as displayed by viewers.
+//
+// NOTE: The appearance streams shared with annotations of other pages are
+// rotated as well.
+func NormalizeRotation(page *model.PdfPage) error {
+	rotate, err := page.GetRotation()
+	if err != nil {
+		return err
+	}
+	if rotate == 0 {
+		return nil
+	}
+
+	mediaBox, err := page.GetMediaBox()
+	if err != nil {
+		return err
+	}
+	m := rotationMatrix(mediaBox.Normalized(), rotate)
+
+	if page.Contents != nil {
+		prefix := contentstream.NewContentCreator().
+			Add_q().
+			Add_cm(m[0], m[1], m[3], m[4], m[6], m[7])
+		suffix := contentstream.NewContentCreator().Add_Q()
+		if err := wrapContents(page, prefix.Bytes(), suffix.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	rotated := mediaBox.Transform(m)
+	page.MediaBox = &rotated
+	for _, pbox := range []**model.PdfRectangle{&page.CropBox, &page.TrimBox, &page.BleedBox, &page.ArtBox} {
+		if *pbox != nil {
+			tr := (*pbox).Transform(m)
+			*pbox = &tr
+		}
+	}
+	var noRotation int64
+	page.Rotate = &noRotation
+
+	annotations, err := page.GetAnnotations()
+	if err != nil {
+		return err
+	}
+	// The appearances are only rotated, the translation and scaling being
+	// determined by the annotation rectangles.
+	apMatrix := transform.NewMatrix(m[0], m[1], m[3], m[4], 0, 0)
+	rotatedAppearances := map[*core.PdfObjectStream]struct{}{}
+	for _, annot := range annotations {
+		if rect, ok := core.GetArray(annot.Rect); ok {
+			if r, err := model.NewPdfRectangle(*rect); err == nil {
+				tr := r.Transform(m)
+				annot.Rect = tr.ToPdfObject()
+			}
+		}
+		transformQuadPoints(annot.GetContext(), m)
+
+		if flags, ok := core.GetIntVal(annot.F); ok && flags&annotationFlagNoRotate != 0 {
+			continue
+		}
+		for _, stream := range getAppearanceStreams(annot.AP) {
+			if _, ok := rotatedAppearances[stream]; ok {
+				continue
+			}
+			rotatedAppearances[stream] = struct{}{}
+			rotateAppearance(stream, apMatrix)
+		}
+
+		if widget, ok := annot.GetContext().(*model.PdfAnnotationWidget); ok {
+			if mk, ok := core.GetDict(widget.MK); ok {
+				r, _ := core.GetIntVal(mk.Get("R"))
+				mk.Set("R", core.MakeInteger(((int64(r)-rotate)%360+360)%360))
+			}
+		}
+	}
+	return nil
+}
+
+// getAppearanceStreams returns the streams of the appearance dictionary `ap`.
+func getAppearanceStreams(ap core.PdfObject) []*core.PdfObjectStream {
+	apDict, ok := core.GetDict(ap)
+	if !ok {
+		return nil
+	}
+
+	var streams []*core.PdfObjectStream
+	for _, key := range []core.PdfObjectName{"N", "R", "D"} {
+		obj := apDict.Get(key)
+		if stream, ok := core.GetStream(obj); ok {
+			streams = append(streams, stream)
+			continue
+		}
+		if states, ok := core.GetDict(obj); ok {
+			for _, state := range states.Keys() {
+				if stream, ok := core.GetStream(states.Get(state)); ok {
+					streams = append(streams, stream)
+				}
+			}
+		}
+	}
+	return streams
+}
+
+// rotateAppearance applies the rotation matrix `m` after the matrix of the
+// appearance stream `stream`.
+func rotateAppearance(stream *core.PdfObjectStream, m transform.Matrix) {
+	matrix := transform.IdentityMatrix()
+	if arr, ok := core.GetArray(stream.Get("Matrix")); ok {
+		if values, err := arr.ToFloat64Array(); err == nil && len(values) == 6 {
+			matrix = transform.NewMatrix(values[0], values[1], values[2], values[3], values[4], values[5])
+		}
+	}
+	m.Concat(matrix)
+	stream.Set("Matrix", core.MakeArrayFromFloats([]float64{m[0], m[1], m[3], m[4], m[6], m[7]}))
+}
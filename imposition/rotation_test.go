@@ -0,0 +1,99 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package imposition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// newLetterPage returns a letter page containing the text "Hello" at
+// (100, 100).
+func newLetterPage(t *testing.T) *model.PdfPage {
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+	font, err := model.NewStandard14Font(model.HelveticaName)
+	require.NoError(t, err)
+	require.NoError(t, page.AddFont("F1", font.ToPdfObject()))
+	require.NoError(t, page.AddContentStreamByString("BT /F1 12 Tf 100 100 Td (Hello) Tj ET"))
+	return page
+}
+
+// requireCorner checks that (`x`, `y`) is approximately a corner of `rect`.
+func requireCorner(t *testing.T, rect model.PdfRectangle, x, y float64) {
+	near := func(a, b float64) bool { return a-b < 0.01 && b-a < 0.01 }
+	require.True(t, (near(x, rect.Llx) || near(x, rect.Urx)) && (near(y, rect.Lly) || near(y, rect.Ury)),
+		"(%g, %g) is not a corner of %v", x, y, rect)
+}
+
+func TestNormalizeRotation(t *testing.T) {
+	for _, rotate := range []int64{0, 90, 180, 270} {
+		page := newLetterPage(t)
+		before := textBBox(t, page)
+		letter := *page.MediaBox
+
+		// The rotation of the last case is inherited from the page tree.
+		if rotate == 270 {
+			parent := core.MakeDict()
+			parent.Set("Type", core.MakeName("Pages"))
+			parent.Set("Rotate", core.MakeInteger(rotate))
+			page.Parent = parent
+		} else {
+			page.Rotate = &rotate
+		}
+
+		stamp := model.NewPdfAnnotationStamp()
+		stamp.Rect = core.MakeArrayFromFloats([]float64{100, 200, 300, 250})
+		appearance, err := core.MakeStream([]byte("0 0 200 50 re f"), core.NewRawEncoder())
+		require.NoError(t, err)
+		appearance.Set("BBox", core.MakeArrayFromFloats([]float64{0, 0, 200, 50}))
+		ap := core.MakeDict()
+		ap.Set("N", appearance)
+		stamp.AP = ap
+		page.AddAnnotation(stamp.PdfAnnotation)
+
+		require.NoError(t, NormalizeRotation(page))
+		normalized := page
+
+		// The text is where it was displayed before normalization. The ends of
+		// the baseline are compared, the extracted glyph heights of rotated text
+		// being unreliable.
+		display := rotationMatrix(letter, rotate)
+		after := textBBox(t, normalized).Normalized()
+		for _, x := range []float64{before.Llx, before.Urx} {
+			x, y := transformPoint(display, x, before.Lly)
+			requireCorner(t, after, x, y)
+		}
+
+		rotation, err := normalized.GetRotation()
+		require.NoError(t, err)
+		require.Zero(t, rotation, "rotate %d", rotate)
+		require.Equal(t, letter.Transform(display), *normalized.MediaBox)
+
+		annots, err := normalized.GetAnnotations()
+		require.NoError(t, err)
+		require.Len(t, annots, 1)
+		rect, err := model.NewPdfRectangle(*annots[0].Rect.(*core.PdfObjectArray))
+		require.NoError(t, err)
+		require.Equal(t, model.PdfRectangle{Llx: 100, Lly: 200, Urx: 300, Ury: 250}.Transform(display), *rect)
+		if rotate == 0 {
+			continue
+		}
+		apDict, ok := core.GetDict(annots[0].AP)
+		require.True(t, ok)
+		stream, ok := core.GetStream(apDict.Get("N"))
+		require.True(t, ok)
+		matrix, ok := core.GetArray(stream.Get("Matrix"))
+		require.True(t, ok)
+		values, err := matrix.ToFloat64Array()
+		require.NoError(t, err)
+		requireMatrix(t, []float64{display[0], display[1], display[3], display[4], 0, 0}, values)
+	}
+}
@@ -0,0 +1,13 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package inspector is used for profiling PDF documents, e.g. when
+// investigating problem files. The report lists the pages and their sizes,
+// the version and encryption of the file, the fonts and images, the
+// annotations, the interactive and active content (forms, XFA, JavaScript,
+// attachments) and the largest objects. The image data is not decoded, and
+// the content which cannot be read is noted in the report rather than
+// failing the inspection of partially corrupt files.
+package inspector
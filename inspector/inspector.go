@@ -0,0 +1,573 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package inspector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/core/security"
+	"github.com/unidoc/unipdf/v3/internal/filespec"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// Report is the profile of a document.
+type Report struct {
+	// Version is the PDF version of the file, e.g. "1.7".
+	Version string `json:"version"`
+
+	// Repaired is true if the cross-reference table of the file was missing or broken and has been
+	// rebuilt. Recoveries are the numbers of syntax errors recovered from, by kind.
+	Repaired   bool           `json:"repaired"`
+	Recoveries map[string]int `json:"recoveries,omitempty"`
+
+	// Encryption describes the encryption of the file, nil if the file is not encrypted.
+	Encryption *Encryption `json:"encryption,omitempty"`
+
+	// NumPages is the number of pages and PageSizes their distinct sizes, in order of appearance.
+	NumPages  int        `json:"numPages"`
+	PageSizes []PageSize `json:"pageSizes"`
+
+	// Fonts are the fonts of the document, by object number.
+	Fonts []Font `json:"fonts"`
+
+	// Images are the image XObjects of the document.
+	Images Images `json:"images"`
+
+	// Annotations are the numbers of annotations of the pages, by subtype.
+	Annotations map[string]int `json:"annotations"`
+
+	// AcroForm is true if the document has an interactive form, XFA if the form has XFA data and
+	// JavaScript if the document contains JavaScript, at document level or in actions.
+	AcroForm   bool `json:"acroForm"`
+	XFA        bool `json:"xfa"`
+	JavaScript bool `json:"javaScript"`
+
+	// Attachments are the names of the embedded files.
+	Attachments []string `json:"attachments"`
+
+	// Objects describes the objects of the file.
+	Objects Objects `json:"objects"`
+
+	// Errors are the errors met reading the document, the content which could not be read being
+	// missing from the report.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Encryption describes the encryption of a file.
+type Encryption struct {
+	Filter       string `json:"filter"`
+	SubFilter    string `json:"subFilter,omitempty"`
+	V            int    `json:"v"`
+	R            int    `json:"r"`
+	StreamFilter string `json:"streamFilter"`
+	KeyLength    int    `json:"keyLength"`
+
+	// Permissions are the names of the operations allowed to users, e.g. "print".
+	Permissions []string `json:"permissions"`
+
+	// Decrypted is true if the document has been decrypted. The content of documents which are
+	// not decrypted cannot be inspected.
+	Decrypted bool `json:"decrypted"`
+}
+
+// PageSize is a page size along with the number of pages of that size.
+type PageSize struct {
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Rotate int64   `json:"rotate"`
+	Pages  int     `json:"pages"`
+}
+
+// Font describes a font.
+type Font struct {
+	// Object is the number of the font object, or of the object containing the font dictionary.
+	Object int64 `json:"object"`
+
+	// Name is the base font name, e.g. "Helvetica" or "ABCDEF+OpenSans-Regular".
+	Name string `json:"name"`
+
+	// Subtype is the font type, e.g. "Type1", "TrueType", "Type0" or "Type3".
+	Subtype string `json:"subtype"`
+
+	// Embedded is true if the font program is embedded.
+	Embedded bool `json:"embedded"`
+}
+
+// Images describes the image XObjects of a document.
+type Images struct {
+	Count      int     `json:"count"`
+	TotalBytes int64   `json:"totalBytes"`
+	Images     []Image `json:"images"`
+}
+
+// Image describes an image XObject. The image data is not decoded.
+type Image struct {
+	Object           int64  `json:"object"`
+	Width            int    `json:"width"`
+	Height           int    `json:"height"`
+	BitsPerComponent int    `json:"bitsPerComponent"`
+	ColorSpace       string `json:"colorSpace"`
+
+	// Format is the format of the image data, given by its last filter, e.g. "JPEG", "JPEG2000",
+	// "JBIG2", "CCITT", "Flate" or "Raw" if the data is not encoded.
+	Format string `json:"format"`
+
+	// Bytes is the size of the encoded image data.
+	Bytes int64 `json:"bytes"`
+}
+
+// Objects describes the objects of a file.
+type Objects struct {
+	Count   int          `json:"count"`
+	Streams int          `json:"streams"`
+	Largest []ObjectSize `json:"largest"`
+}
+
+// ObjectSize is the size of an object.
+type ObjectSize struct {
+	Object int64 `json:"object"`
+
+	// Type is the type of the object, e.g. "XObject/Image", "Font/TrueType", "Stream" or
+	// "Dictionary".
+	Type string `json:"type"`
+
+	// Bytes is the approximate serialized size of the object, including its stream data.
+	Bytes int64 `json:"bytes"`
+}
+
+// JSON returns the JSON serialization of the report.
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// numLargestObjects is the number of largest objects listed.
+const numLargestObjects = 10
+
+// maxErrors is the maximum number of errors listed.
+const maxErrors = 100
+
+// maxDepth is the maximum nesting level of the objects walked.
+const maxDepth = 100
+
+// permissionNames are the names of the permissions of encrypted documents.
+var permissionNames = []struct {
+	perm security.Permissions
+	name string
+}{
+	{security.PermPrinting, "print"},
+	{security.PermModify, "modify"},
+	{security.PermExtractGraphics, "extractGraphics"},
+	{security.PermAnnotate, "annotate"},
+	{security.PermFillForms, "fillForms"},
+	{security.PermDisabilityExtract, "extractAccessibility"},
+	{security.PermRotateInsert, "assemble"},
+	{security.PermFullPrintQuality, "printHighQuality"},
+}
+
+// imageFormats maps the filters of images to their formats.
+var imageFormats = map[string]string{
+	"DCTDecode":       "JPEG",
+	"DCT":             "JPEG",
+	"JPXDecode":       "JPEG2000",
+	"JBIG2Decode":     "JBIG2",
+	"CCITTFaxDecode":  "CCITT",
+	"CCF":             "CCITT",
+	"FlateDecode":     "Flate",
+	"Fl":              "Flate",
+	"LZWDecode":       "LZW",
+	"LZW":             "LZW",
+	"RunLengthDecode": "RunLength",
+	"RL":              "RunLength",
+}
+
+// Inspect returns the report of the document read by `r`. The document should be decrypted if it
+// is encrypted. The errors met reading the content of the document are listed in the report.
+func Inspect(r *model.PdfReader) (*Report, error) {
+	if r == nil {
+		return nil, errors.New("reader not specified")
+	}
+	in := &inspector{
+		reader: r,
+		report: &Report{
+			Version:     r.PdfVersion().String(),
+			PageSizes:   []PageSize{},
+			Fonts:       []Font{},
+			Images:      Images{Images: []Image{}},
+			Annotations: map[string]int{},
+			Attachments: []string{},
+			Objects:     Objects{Largest: []ObjectSize{}},
+		},
+	}
+	in.inspectEncryption()
+	if in.report.Encryption == nil || in.report.Encryption.Decrypted {
+		in.safely("pages", in.inspectPages)
+		in.safely("catalog", in.inspectCatalog)
+		in.safely("objects", in.inspectObjects)
+	} else {
+		in.addError("document", model.ErrEncrypted)
+	}
+
+	// The objects are loaded on demand, so syntax errors are recovered from while inspecting.
+	in.report.Repaired = r.WasRepaired()
+	for t, n := range r.GetRecoveries() {
+		if in.report.Recoveries == nil {
+			in.report.Recoveries = map[string]int{}
+		}
+		in.report.Recoveries[t.String()] = n
+	}
+	if in.numErrors > maxErrors {
+		in.report.Errors = append(in.report.Errors,
+			fmt.Sprintf("%d more errors", in.numErrors-maxErrors))
+	}
+	return in.report, nil
+}
+
+// inspector holds the state of the inspection of a document.
+type inspector struct {
+	reader    *model.PdfReader
+	report    *Report
+	numErrors int
+}
+
+// addError adds the error `err` met reading `what` to the report.
+func (in *inspector) addError(what string, err error) {
+	in.numErrors++
+	if in.numErrors <= maxErrors {
+		in.report.Errors = append(in.report.Errors, fmt.Sprintf("%s: %v", what, err))
+	}
+}
+
+// safely calls `fn`, recovering from panics caused by malformed content, which are added to the
+// report as errors reading `what`.
+func (in *inspector) safely(what string, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			in.addError(what, fmt.Errorf("%v", r))
+		}
+	}()
+	fn()
+}
+
+// inspectEncryption sets the encryption details of the report.
+func (in *inspector) inspectEncryption() {
+	details := in.reader.GetEncryptionDetails()
+	if details == nil {
+		return
+	}
+	enc := &Encryption{
+		Filter:       details.Filter,
+		SubFilter:    details.SubFilter,
+		V:            details.V,
+		R:            details.R,
+		StreamFilter: details.StreamFilter,
+		KeyLength:    details.KeyLength,
+		Permissions:  []string{},
+		Decrypted:    in.reader.IsDecrypted(),
+	}
+	for _, p := range permissionNames {
+		if details.Permissions.Allowed(p.perm) {
+			enc.Permissions = append(enc.Permissions, p.name)
+		}
+	}
+	in.report.Encryption = enc
+}
+
+// inspectPages sets the page sizes and the annotation counts of the report.
+func (in *inspector) inspectPages() {
+	numPages, err := in.reader.GetNumPages()
+	if err != nil {
+		in.addError("pages", err)
+		return
+	}
+	in.report.NumPages = numPages
+	for i := 1; i <= numPages; i++ {
+		in.safely(fmt.Sprintf("page %d", i), func() {
+			in.inspectPage(i)
+		})
+	}
+}
+
+// inspectPage adds the size and the annotations of page `pageNum` to the report.
+func (in *inspector) inspectPage(pageNum int) {
+	what := fmt.Sprintf("page %d", pageNum)
+	page, err := in.reader.GetPage(pageNum)
+	if err != nil {
+		in.addError(what, err)
+		return
+	}
+
+	if box, err := page.GetMediaBox(); err != nil {
+		in.addError(what, err)
+	} else {
+		size := PageSize{Width: round(box.Width()), Height: round(box.Height())}
+		if page.Rotate != nil {
+			size.Rotate = *page.Rotate
+		}
+		in.addPageSize(size)
+	}
+
+	if page.Annots == nil {
+		return
+	}
+	annots, ok := core.GetArray(page.Annots)
+	if !ok {
+		in.addError(what, errors.New("Annots not an array"))
+		return
+	}
+	for _, obj := range annots.Elements() {
+		annot, ok := core.GetDict(obj)
+		if !ok {
+			in.addError(what, errors.New("annotation not a dictionary"))
+			continue
+		}
+		subtype, ok := core.GetNameVal(annot.Get("Subtype"))
+		if !ok {
+			subtype = "Unknown"
+		}
+		in.report.Annotations[subtype]++
+	}
+}
+
+// addPageSize adds a page of size `size` to the report.
+func (in *inspector) addPageSize(size PageSize) {
+	for i, s := range in.report.PageSizes {
+		if s.Width == size.Width && s.Height == size.Height && s.Rotate == size.Rotate {
+			in.report.PageSizes[i].Pages++
+			return
+		}
+	}
+	size.Pages = 1
+	in.report.PageSizes = append(in.report.PageSizes, size)
+}
+
+// inspectCatalog sets the interactive form and document-level JavaScript flags of the report.
+func (in *inspector) inspectCatalog() {
+	trailer, err := in.reader.GetTrailer()
+	if err != nil {
+		in.addError("catalog", err)
+		return
+	}
+	catalog, ok := core.GetDict(trailer.Get("Root"))
+	if !ok {
+		in.addError("catalog", errors.New("catalog missing"))
+		return
+	}
+	if acroForm, ok := core.GetDict(catalog.Get("AcroForm")); ok {
+		in.report.AcroForm = true
+		in.report.XFA = acroForm.Get("XFA") != nil
+	}
+	if names, ok := core.GetDict(catalog.Get("Names")); ok && names.Get("JavaScript") != nil {
+		in.report.JavaScript = true
+	}
+}
+
+// inspectObjects walks the objects of the file, adding the fonts, images, attachments and
+// JavaScript found and the object statistics to the report.
+func (in *inspector) inspectObjects() {
+	for _, num := range in.reader.GetObjectNums() {
+		in.safely(fmt.Sprintf("object %d", num), func() {
+			in.inspectObject(int64(num))
+		})
+	}
+}
+
+// inspectObject adds the content of object `num` to the report.
+func (in *inspector) inspectObject(num int64) {
+	obj, err := in.reader.GetIndirectObjectByNumber(int(num))
+	if err != nil {
+		in.addError(fmt.Sprintf("object %d", num), err)
+		return
+	}
+	objects := &in.report.Objects
+	objects.Count++
+	in.addObjectSize(ObjectSize{Object: num, Type: objectType(obj), Bytes: objectSize(obj)})
+
+	switch t := obj.(type) {
+	case *core.PdfObjectStream:
+		objects.Streams++
+		if subtype, _ := core.GetNameVal(t.Get("Subtype")); subtype == "Image" {
+			image := imageInfo(num, t)
+			in.report.Images.Count++
+			in.report.Images.TotalBytes += image.Bytes
+			in.report.Images.Images = append(in.report.Images.Images, image)
+		}
+		in.walk(t.PdfObjectDictionary, num, 0)
+	case *core.PdfIndirectObject:
+		in.walk(t.PdfObject, num, 0)
+	}
+}
+
+// addObjectSize adds `size` to the largest objects of the report if it is one of them.
+func (in *inspector) addObjectSize(size ObjectSize) {
+	largest := append(in.report.Objects.Largest, size)
+	sort.SliceStable(largest, func(i, j int) bool {
+		return largest[i].Bytes > largest[j].Bytes
+	})
+	if len(largest) > numLargestObjects {
+		largest = largest[:numLargestObjects]
+	}
+	in.report.Objects.Largest = largest
+}
+
+// walk walks the direct objects of `obj`, contained in object `num`, the indirect objects being
+// walked separately.
+func (in *inspector) walk(obj core.PdfObject, num int64, depth int) {
+	if depth > maxDepth {
+		return
+	}
+	switch t := obj.(type) {
+	case *core.PdfObjectDictionary:
+		in.inspectDict(t, num)
+		for _, key := range t.Keys() {
+			in.walk(t.Get(key), num, depth+1)
+		}
+	case *core.PdfObjectArray:
+		for _, o := range t.Elements() {
+			in.walk(o, num, depth+1)
+		}
+	}
+}
+
+// inspectDict adds the content of dictionary `d`, contained in object `num`, to the report if it
+// is a font, an action or a file specification.
+func (in *inspector) inspectDict(d *core.PdfObjectDictionary, num int64) {
+	if typ, _ := core.GetNameVal(d.Get("Type")); typ == "Font" {
+		subtype, _ := core.GetNameVal(d.Get("Subtype"))
+		// The descendant fonts are described by their Type0 fonts.
+		if subtype != "CIDFontType0" && subtype != "CIDFontType2" {
+			name, ok := core.GetNameVal(d.Get("BaseFont"))
+			if !ok {
+				name, _ = core.GetNameVal(d.Get("Name"))
+			}
+			in.report.Fonts = append(in.report.Fonts, Font{
+				Object:   num,
+				Name:     name,
+				Subtype:  subtype,
+				Embedded: fontEmbedded(d, subtype),
+			})
+		}
+	}
+	if s, _ := core.GetNameVal(d.Get("S")); s == "JavaScript" || d.Get("JS") != nil {
+		in.report.JavaScript = true
+	}
+	if d.Get("EF") != nil {
+		in.report.Attachments = append(in.report.Attachments, filespec.FileName(d))
+	}
+}
+
+// fontEmbedded returns true if the program of the font `font` of subtype `subtype` is embedded.
+func fontEmbedded(font *core.PdfObjectDictionary, subtype string) bool {
+	switch subtype {
+	case "Type3":
+		// The glyphs are described by content streams.
+		return true
+	case "Type0":
+		descendants, ok := core.GetArray(font.Get("DescendantFonts"))
+		if !ok || descendants.Len() == 0 {
+			return false
+		}
+		if font, ok = core.GetDict(descendants.Get(0)); !ok {
+			return false
+		}
+	}
+	descriptor, ok := core.GetDict(font.Get("FontDescriptor"))
+	if !ok {
+		return false
+	}
+	for _, key := range []core.PdfObjectName{"FontFile", "FontFile2", "FontFile3"} {
+		if descriptor.Get(key) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// imageInfo returns the description of the image XObject `stream`, which is object `num`.
+func imageInfo(num int64, stream *core.PdfObjectStream) Image {
+	image := Image{
+		Object: num,
+		Format: "Raw",
+		Bytes:  int64(len(stream.Stream)),
+	}
+	image.Width, _ = core.GetIntVal(stream.Get("Width"))
+	image.Height, _ = core.GetIntVal(stream.Get("Height"))
+	image.BitsPerComponent, _ = core.GetIntVal(stream.Get("BitsPerComponent"))
+	if mask, ok := core.GetBoolVal(stream.Get("ImageMask")); ok && mask {
+		image.ColorSpace = "ImageMask"
+		image.BitsPerComponent = 1
+	} else if name, ok := core.GetNameVal(stream.Get("ColorSpace")); ok {
+		image.ColorSpace = name
+	} else if arr, ok := core.GetArray(stream.Get("ColorSpace")); ok && arr.Len() > 0 {
+		// Parameterized color spaces, e.g. ICCBased or Indexed.
+		image.ColorSpace, _ = core.GetNameVal(arr.Get(0))
+	}
+
+	filter, ok := core.GetNameVal(stream.Get("Filter"))
+	if arr, isArr := core.GetArray(stream.Get("Filter")); isArr && arr.Len() > 0 {
+		filter, ok = core.GetNameVal(arr.Get(arr.Len() - 1))
+	}
+	if ok {
+		image.Format = filter
+		if format, known := imageFormats[filter]; known {
+			image.Format = format
+		}
+	}
+	return image
+}
+
+// objectType returns the type of `obj`, e.g. "XObject/Image" for image XObjects.
+func objectType(obj core.PdfObject) string {
+	var d *core.PdfObjectDictionary
+	kind := "Object"
+	switch t := obj.(type) {
+	case *core.PdfObjectStream:
+		d, kind = t.PdfObjectDictionary, "Stream"
+	case *core.PdfIndirectObject:
+		switch direct := t.PdfObject.(type) {
+		case *core.PdfObjectDictionary:
+			d, kind = direct, "Dictionary"
+		case *core.PdfObjectArray:
+			kind = "Array"
+		}
+	}
+	if d == nil {
+		return kind
+	}
+	typ, hasType := core.GetNameVal(d.Get("Type"))
+	subtype, hasSubtype := core.GetNameVal(d.Get("Subtype"))
+	switch {
+	case hasType && hasSubtype:
+		return typ + "/" + subtype
+	case hasType:
+		return typ
+	case hasSubtype:
+		return kind + "/" + subtype
+	}
+	return kind
+}
+
+// objectSize returns the approximate serialized size of `obj`.
+func objectSize(obj core.PdfObject) int64 {
+	switch t := obj.(type) {
+	case *core.PdfObjectStream:
+		return int64(len(t.PdfObjectDictionary.WriteString()) + len(t.Stream))
+	case *core.PdfIndirectObject:
+		if t.PdfObject != nil {
+			return int64(len(t.PdfObject.WriteString()))
+		}
+	}
+	return 0
+}
+
+// round rounds `x` to 2 decimals.
+func round(x float64) float64 {
+	return math.Round(x*100) / 100
+}
@@ -0,0 +1,153 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package inspector
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core/security"
+	"github.com/unidoc/unipdf/v3/internal/testutils"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+var updateGoldens = flag.Bool("inspector-update-goldens", false, "update the golden reports")
+
+// featuresDocument returns a document with pages of two sizes, standard, embedded TrueType,
+// Type0 and Type3 fonts, JPEG and raw images, link, widget and file attachment annotations, a
+// form with XFA data, JavaScript and embedded files.
+func featuresDocument() []byte {
+	resources := "/Resources << /Font << /F1 6 0 R /F2 7 0 R /F3 10 0 R >> " +
+		"/XObject << /Im1 13 0 R /Im2 14 0 R >> >>"
+	return testutils.BuildRawPdf([]string{
+		"<< /Type /Catalog /Pages 2 0 R /OpenAction << /S /JavaScript /JS (app.alert\\(1\\)) >> " +
+			"/AcroForm << /Fields [16 0 R] /XFA 19 0 R >> " +
+			"/Names << /EmbeddedFiles << /Names [(data.csv) 17 0 R] >> >> >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 3 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] " + resources +
+			" /Annots [15 0 R 16 0 R] >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] " + resources +
+			" /Annots [<< /Type /Annot /Subtype /FileAttachment /Rect [0 0 10 10] /FS 20 0 R >> 15 0 R] >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595.276 841.89] /Rotate 90 " + resources + " >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Font /Subtype /TrueType /BaseFont /ABCDEF+OpenSans /FontDescriptor 8 0 R >>",
+		"<< /Type /FontDescriptor /FontName /ABCDEF+OpenSans /Flags 32 /FontFile2 9 0 R >>",
+		testutils.RawStream("", []byte("fake font program")),
+		"<< /Type /Font /Subtype /Type0 /BaseFont /NotoSans-Identity-H /Encoding /Identity-H " +
+			"/DescendantFonts [<< /Type /Font /Subtype /CIDFontType2 /BaseFont /NotoSans " +
+			"/FontDescriptor << /Type /FontDescriptor /FontName /NotoSans >> >>] >>",
+		"<< /Type /Font /Subtype /Type3 /FontBBox [0 0 1 1] /FontMatrix [1 0 0 1 0 0] " +
+			"/CharProcs << /a 12 0 R >> /Encoding << /Differences [97 /a] >> /FirstChar 97 /LastChar 97 /Widths [1] >>",
+		testutils.RawStream("", []byte("1 0 0 0 1 1 d1 0 0 1 1 re f")),
+		testutils.RawStream("/Type /XObject /Subtype /Image /Width 640 /Height 480 /BitsPerComponent 8 "+
+			"/ColorSpace /DeviceRGB /Filter [/ASCII85Decode /DCTDecode]", []byte("not really a JPEG")),
+		testutils.RawStream("/Type /XObject /Subtype /Image /Width 2 /Height 2 /BitsPerComponent 8 "+
+			"/ColorSpace [/ICCBased 18 0 R]", []byte("abcd")),
+		"<< /Type /Annot /Subtype /Link /Rect [0 0 50 20] /A << /S /URI /URI (https://example.com) >> >>",
+		"<< /Type /Annot /Subtype /Widget /FT /Tx /T (name) /Rect [0 30 50 50] " +
+			"/AA << /K << /S /JavaScript /JS (format) >> >> >>",
+		"<< /Type /Filespec /F (data.csv) /EF << /F 18 0 R >> >>",
+		testutils.RawStream("/Type /EmbeddedFile /N 1", []byte("a,b\n1,2")),
+		testutils.RawStream("", []byte("<xdp:xdp/>")),
+		"<< /Type /Filespec /F (old.txt) /UF (notes.txt) /EF << /F 18 0 R >> >>",
+	}, &testutils.RawPdfOptions{Header: "%PDF-1.6\n"})
+}
+
+// corruptDocument returns a document with a broken cross-reference table, a truncated font, a
+// garbled object, a page with no media box and a page whose annotations are not an array.
+func corruptDocument() []byte {
+	return testutils.BuildRawPdf([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 3 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 6 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << >> >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Annots 7 >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helv",
+		"]]] <<",
+	}, &testutils.RawPdfOptions{Header: "%PDF-1.6\n", OffsetShift: 7})
+}
+
+// checkGolden checks that the JSON serialization of `report` matches the golden file `name`.
+func checkGolden(t *testing.T, name string, report *Report) {
+	data, err := report.JSON()
+	require.NoError(t, err)
+	path := filepath.Join("testdata", name)
+	if *updateGoldens {
+		require.NoError(t, ioutil.WriteFile(path, append(data, '\n'), 0644))
+	}
+	expected, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(data)+"\n")
+}
+
+func TestInspect(t *testing.T) {
+	testcases := []struct {
+		name   string
+		data   []byte
+		golden string
+	}{
+		{"features", featuresDocument(), "features.json"},
+		{"corrupt", corruptDocument(), "corrupt.json"},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			reader, err := model.NewPdfReader(bytes.NewReader(tc.data))
+			require.NoError(t, err)
+			report, err := Inspect(reader)
+			require.NoError(t, err)
+			checkGolden(t, tc.golden, report)
+		})
+	}
+}
+
+// TestInspectEncrypted checks that the encryption details of encrypted documents are reported,
+// and that their content is only inspected once decrypted.
+func TestInspectEncrypted(t *testing.T) {
+	w := model.NewPdfWriter()
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+	require.NoError(t, w.AddPage(page))
+	require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), &model.EncryptOptions{
+		Permissions: security.PermPrinting | security.PermFillForms,
+		Algorithm:   model.AES_256bit,
+	}))
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	report, err := Inspect(reader)
+	require.NoError(t, err)
+	expected := &Encryption{
+		Filter:       "Standard",
+		V:            5,
+		R:            6,
+		StreamFilter: "AESV3",
+		KeyLength:    256,
+		Permissions:  []string{"print", "fillForms"},
+	}
+	require.Equal(t, expected, report.Encryption)
+	require.Zero(t, report.NumPages)
+	require.Equal(t, []string{"document: " + model.ErrEncrypted.Error()}, report.Errors)
+
+	ok, err := reader.Decrypt([]byte("user"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	report, err = Inspect(reader)
+	require.NoError(t, err)
+	expected.Decrypted = true
+	require.Equal(t, expected, report.Encryption)
+	require.Equal(t, 1, report.NumPages)
+	require.Empty(t, report.Errors)
+
+	_, err = Inspect(nil)
+	require.Error(t, err)
+}
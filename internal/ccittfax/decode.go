@@ -24,6 +24,11 @@ var (
 	// errInvalid2DCode is returned when the invalid 2 dimensional code is met. 2 dimensional code
 	// according to the CCITT reccommendations is one of the following: H, P, V0, V1L, V2L, V3L, V1R, V2R, V3R.
 	errInvalid2DCode = errors.New("invalid 2D code")
+	// errInvalid1DCode is returned when the row encoded with the 1 dimensional code is shorter than
+	// the number of the columns.
+	errInvalid1DCode = errors.New("invalid 1D code")
+	// errInvalidColumns is returned when the number of columns of the image is not positive.
+	errInvalidColumns = errors.New("invalid number of columns")
 )
 
 // trees represent the finite state machine for parsing bit sequences and fetching pixel run lengths
@@ -73,8 +78,12 @@ func init() {
 }
 
 // Decode performs decoding operation on the encoded image using the Group3 or Group4
-// CCITT facsimile (fax) algorithm.
+// CCITT facsimile (fax) algorithm. Each of the returned rows is Columns pixels long.
 func (e *Encoder) Decode(encoded []byte) ([][]byte, error) {
+	if e.Columns <= 0 {
+		return nil, errInvalidColumns
+	}
+
 	if e.BlackIs1 {
 		white = 0
 		black = 1
@@ -83,302 +92,249 @@ func (e *Encoder) Decode(encoded []byte) ([][]byte, error) {
 		black = 0
 	}
 
-	if e.K == 0 {
-		// decode Group3 1-dimensional
-		return e.decodeG31D(encoded)
-	}
-
-	if e.K > 0 {
-		// decode Group3 mixed dimensional (1D/2D)
-		return e.decodeG32D(encoded)
-	}
+	// the reference line of the first row is white
+	refLine := drawPixels(nil, true, e.Columns)
 
-	if e.K < 4 {
-		// decode Group4
-		return e.decodeG4(encoded)
-	}
+	var (
+		pixels      [][]byte
+		bitPos      int
+		damaged     int
+		prevDamaged bool
+	)
+	for e.Rows <= 0 || len(pixels) < e.Rows {
+		var (
+			gotEOL bool
+			is1D   bool
+		)
+		if e.K < 0 {
+			if e.EncodedByteAlign {
+				bitPos = alignBitPos(bitPos)
+			}
 
-	return nil, nil
-}
+			var (
+				gotEOFB bool
+				err     error
+			)
+			gotEOFB, bitPos, err = tryFetchEOFB(encoded, skipFillBits(encoded, bitPos))
+			if err != nil {
+				return nil, err
+			}
+			if gotEOFB {
+				break
+			}
+		} else {
+			var gotRTC bool
+			gotEOL, is1D, gotRTC, bitPos = e.fetchLineStart(encoded, bitPos)
+			if gotRTC {
+				break
+			}
 
-// decodeG31D decodes the encoded image data using the Group3 1-dimensional
-// CCITT facsimile (fax) decoding algorithm.
-func (e *Encoder) decodeG31D(encoded []byte) ([][]byte, error) {
-	var pixels [][]byte
+			if !gotEOL && e.EndOfLine && !isEndOfData(encoded, bitPos) {
+				return nil, errNoEOLFound
+			}
+		}
 
-	// do g31d decoding
-	var bitPos int
-	for (bitPos / 8) < len(encoded) {
-		var gotEOL bool
+		if isEndOfData(encoded, bitPos) {
+			// the data is over without the end of block code
+			break
+		}
 
-		gotEOL, bitPos = tryFetchEOL(encoded, bitPos)
-		if !gotEOL {
-			if e.EndOfLine {
-				return nil, errNoEOLFound
+		var (
+			row []byte
+			err error
+		)
+		if is1D {
+			row, bitPos = e.decodeRow1D(encoded, bitPos)
+			if len(row) < e.Columns {
+				err = errInvalid1DCode
 			}
 		} else {
-			// 5 EOLs left to fill RTC
-			for i := 0; i < 5; i++ {
-				gotEOL, bitPos = tryFetchEOL(encoded, bitPos)
-
-				if !gotEOL {
-					if i == 0 {
-						break
-					}
+			row, bitPos, err = e.decodeRow2D(encoded, bitPos, refLine)
+		}
 
-					return nil, errInvalidEOL
-				}
+		if err != nil {
+			// the damaged rows are tolerated only if they are followed by EOL
+			if e.K < 0 || !gotEOL || damaged >= e.DamagedRowsBeforeError {
+				return nil, err
 			}
+			damaged++
 
-			// got RTC
-			if gotEOL {
-				break
+			// the damaged row is replaced with the previous row, or with the white
+			// one if the previous row was damaged too
+			if prevDamaged {
+				row = drawPixels(nil, true, e.Columns)
+			} else {
+				row = make([]byte, e.Columns)
+				copy(row, refLine)
 			}
-		}
+			prevDamaged = true
 
-		var row []byte
-		row, bitPos = e.decodeRow1D(encoded, bitPos)
+			bitPos = seekEOL(encoded, bitPos)
+		} else {
+			prevDamaged = false
+		}
 
-		if e.EncodedByteAlign && bitPos%8 != 0 {
-			// align to byte border
-			bitPos += 8 - bitPos%8
+		if len(row) > e.Columns {
+			row = row[:e.Columns]
 		}
 
 		pixels = append(pixels, row)
-
-		if e.Rows > 0 && !e.EndOfBlock && len(pixels) >= e.Rows {
-			break
-		}
+		refLine = row
 	}
 
 	return pixels, nil
 }
 
-// decodeG32D decodes the encoded image data using the Group3 mixed (1D/2D) dimensional
-// CCITT facsimile (fax) decoding algorithm.
-func (e *Encoder) decodeG32D(encoded []byte) ([][]byte, error) {
-	var (
-		pixels [][]byte
-		bitPos int
-		err    error
-	)
-byteLoop:
-	for (bitPos / 8) < len(encoded) {
-		var gotEOL bool
-		gotEOL, bitPos, err = tryFetchRTC2D(encoded, bitPos)
-		if err != nil {
-			return nil, err
-		}
-
-		if gotEOL {
-			break
-		}
+// fetchLineStart fetches the beginning of the next Group3 encoded line: the optional fill bits, the EOL code and,
+// for the mixed (1D/2D) dimensional encoding, the tag bit telling whether the line is 1D or 2D encoded.
+// Returns whether the EOL code was met, whether the line is 1D encoded, whether the RTC (return-the-carriage)
+// code was met and the moved bit position.
+func (e *Encoder) fetchLineStart(encoded []byte, bitPos int) (bool, bool, bool, int) {
+	gotEOL, pos := tryFetchEOL(encoded, skipFillBits(encoded, bitPos))
+	if !gotEOL && e.EncodedByteAlign {
+		// the line starts on the byte border
+		pos = alignBitPos(bitPos)
+		gotEOL, pos = tryFetchEOL(encoded, skipFillBits(encoded, pos))
+	}
 
-		gotEOL, bitPos = tryFetchEOL1(encoded, bitPos)
+	is1D := true
+	if e.K > 0 {
+		// the tag bit is 1 for the 1D encoded line, 0 for the 2D one
+		is1D = bitAt(encoded, pos) == 1
+		pos++
+	}
 
-		if !gotEOL {
-			if e.EndOfLine {
-				return nil, errNoEOLFound
-			}
+	if gotEOL {
+		// the line can't start with an EOL, so the consecutive EOLs are the RTC code
+		if gotRTC, _ := tryFetchEOL(encoded, skipFillBits(encoded, pos)); gotRTC {
+			return true, is1D, true, pos
 		}
+	}
 
-		// decode 1st of K rows as 1D
-		var row []byte
-		row, bitPos = e.decodeRow1D(encoded, bitPos)
+	return gotEOL, is1D, false, pos
+}
 
-		if e.EncodedByteAlign && bitPos%8 != 0 {
-			// align to byte border
-			bitPos += 8 - bitPos%8
-		}
+// decodeRow2D decodes the next pixels row using the 2-dimensional coding with `refLine` as the
+// reference line. Returns the row and the moved bit position. `errInvalid2DCode` is returned if the
+// row could not be decoded.
+func (e *Encoder) decodeRow2D(encoded []byte, bitPos int, refLine []byte) ([]byte, int, error) {
+	var (
+		twoDimCode code
+		ok         bool
+		err        error
+	)
 
-		if row != nil {
-			pixels = append(pixels, row)
+	pixels := [][]byte{refLine}
+	isWhite := true
+	var pixelsRow []byte
+	a0 := -1
+	for a0 < e.Columns && len(pixelsRow) < e.Columns {
+		twoDimCode, bitPos, ok = fetchNext2DCode(encoded, bitPos)
+		if !ok {
+			return pixelsRow, bitPos, errInvalid2DCode
 		}
 
-		if e.Rows > 0 && !e.EndOfBlock && len(pixels) >= e.Rows {
-			break
+		switch twoDimCode {
+		case p:
+			// do pass mode decoding
+			pixelsRow, a0 = decodePassMode(pixels, pixelsRow, isWhite, a0)
+		case h:
+			// do horizontal mode decoding
+			pixelsRow, bitPos, a0, err = decodeHorizontalMode(encoded, pixelsRow, bitPos, isWhite, a0)
+			if err != nil {
+				return pixelsRow, bitPos, err
+			}
+		case v0:
+			pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, 0)
+			isWhite = !isWhite
+		case v1r:
+			pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, 1)
+			isWhite = !isWhite
+		case v2r:
+			pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, 2)
+			isWhite = !isWhite
+		case v3r:
+			pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, 3)
+			isWhite = !isWhite
+		case v1l:
+			pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, -1)
+			isWhite = !isWhite
+		case v2l:
+			pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, -2)
+			isWhite = !isWhite
+		case v3l:
+			pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, -3)
+			isWhite = !isWhite
 		}
+	}
 
-		// decode K-1 rows as 2D
-		for i := 1; i < e.K && (bitPos/8) < len(encoded); i++ {
-			gotEOL, bitPos = tryFetchEOL0(encoded, bitPos)
-			if !gotEOL {
-				// only EOL0 or RTC should be met here. If neither of these met -
-				// the data is considered corrupt
-				gotEOL, bitPos, err = tryFetchRTC2D(encoded, bitPos)
-				if err != nil {
-					return nil, err
-				}
-
-				if gotEOL {
-					break byteLoop
-				} else {
-					if e.EndOfLine {
-						return nil, errNoEOLFound
-					}
-				}
-			}
+	return pixelsRow, bitPos, nil
+}
 
-			var (
-				twoDimCode code
-				ok         bool
-			)
+// bitAt gets the value of the bit at the `bitPos` position of the encoded data. The bits
+// past the end of the data are 0.
+func bitAt(encoded []byte, bitPos int) byte {
+	if bitPos/8 >= len(encoded) {
+		return 0
+	}
 
-			isWhite := true
-			var pixelsRow []byte
-			a0 := -1
-			for twoDimCode, bitPos, ok = fetchNext2DCode(encoded, bitPos); ok; twoDimCode, bitPos, ok = fetchNext2DCode(encoded, bitPos) {
-				switch twoDimCode {
-				case p:
-					// do pass mode decoding
-					pixelsRow, a0 = decodePassMode(pixels, pixelsRow, isWhite, a0)
-				case h:
-					// do horizontal mode decoding
-					pixelsRow, bitPos, a0, err = decodeHorizontalMode(encoded, pixelsRow, bitPos, isWhite, a0)
-					if err != nil {
-						return nil, err
-					}
-				case v0:
-					pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, 0)
-					isWhite = !isWhite
-				case v1r:
-					pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, 1)
-					isWhite = !isWhite
-				case v2r:
-					pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, 2)
-					isWhite = !isWhite
-				case v3r:
-					pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, 3)
-					isWhite = !isWhite
-				case v1l:
-					pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, -1)
-					isWhite = !isWhite
-				case v2l:
-					pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, -2)
-					isWhite = !isWhite
-				case v3l:
-					pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, -3)
-					isWhite = !isWhite
-				}
-
-				if len(pixelsRow) >= e.Columns {
-					break
-				}
-			}
+	return (encoded[bitPos/8] >> (7 - uint(bitPos%8))) & 1
+}
 
-			if e.EncodedByteAlign && bitPos%8 != 0 {
-				// align to byte border
-				bitPos += 8 - bitPos%8
-			}
+// alignBitPos moves the `bitPos` to the byte border.
+func alignBitPos(bitPos int) int {
+	if bitPos%8 != 0 {
+		bitPos += 8 - bitPos%8
+	}
 
-			if pixelsRow != nil {
-				pixels = append(pixels, pixelsRow)
-			}
+	return bitPos
+}
 
-			if e.Rows > 0 && !e.EndOfBlock && len(pixels) >= e.Rows {
-				break byteLoop
-			}
+// isEndOfData checks whether there is no more data to decode starting from the `bitPos`
+// position, i.e. the rest of the bits are the padding 0s.
+func isEndOfData(encoded []byte, bitPos int) bool {
+	for ; bitPos/8 < len(encoded); bitPos++ {
+		if bitAt(encoded, bitPos) != 0 {
+			return false
 		}
 	}
 
-	return pixels, nil
+	return true
 }
 
-// decodeG4 decodes the encoded image data using the Group4 CCITT facsimile (fax)
-// decoding algorithm.
-func (e *Encoder) decodeG4(encoded []byte) ([][]byte, error) {
-	// append white reference line
-	whiteReferenceLine := make([]byte, e.Columns)
-	for i := range whiteReferenceLine {
-		whiteReferenceLine[i] = white
+// skipFillBits skips the 0 fill bits preceding the EOL code (000000000001). Returns the position
+// of the EOL code, or the original position if the EOL code doesn't follow.
+func skipFillBits(encoded []byte, bitPos int) int {
+	pos := bitPos
+	for pos/8 < len(encoded) && bitAt(encoded, pos) == 0 {
+		pos++
 	}
 
-	pixels := make([][]byte, 1)
-	pixels[0] = whiteReferenceLine
-
-	var (
-		gotEOL bool
-		err    error
-		bitPos int
-	)
-	for (bitPos / 8) < len(encoded) {
-		// try get EOFB
-		gotEOL, bitPos, err = tryFetchEOFB(encoded, bitPos)
-		if err != nil {
-			return nil, err
-		}
-		if gotEOL {
-			break
-		}
-
-		var (
-			twoDimCode code
-			ok         bool
-		)
-
-		isWhite := true
-		var pixelsRow []byte
-		a0 := -1
-		for a0 < e.Columns {
-			twoDimCode, bitPos, ok = fetchNext2DCode(encoded, bitPos)
-			if !ok {
-				return nil, errInvalid2DCode
-			}
+	if pos/8 >= len(encoded) || pos-bitPos < 11 {
+		return bitPos
+	}
 
-			switch twoDimCode {
-			case p:
-				// do pass mode decoding
-				pixelsRow, a0 = decodePassMode(pixels, pixelsRow, isWhite, a0)
-			case h:
-				// do horizontal mode decoding
-				pixelsRow, bitPos, a0, err = decodeHorizontalMode(encoded, pixelsRow, bitPos, isWhite, a0)
-				if err != nil {
-					return nil, err
-				}
-			case v0:
-				pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, 0)
-				isWhite = !isWhite
-			case v1r:
-				pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, 1)
-				isWhite = !isWhite
-			case v2r:
-				pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, 2)
-				isWhite = !isWhite
-			case v3r:
-				pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, 3)
-				isWhite = !isWhite
-			case v1l:
-				pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, -1)
-				isWhite = !isWhite
-			case v2l:
-				pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, -2)
-				isWhite = !isWhite
-			case v3l:
-				pixelsRow, a0 = decodeVerticalMode(pixels, pixelsRow, isWhite, a0, -3)
-				isWhite = !isWhite
-			}
+	return pos - 11
+}
 
-			if len(pixelsRow) >= e.Columns {
-				break
-			}
+// seekEOL searches for the next EOL code (000000000001) starting from the `bitPos` position.
+// Returns the position of the EOL code, or the end of the data if there is no EOL code.
+func seekEOL(encoded []byte, bitPos int) int {
+	zeros := 0
+	for ; bitPos/8 < len(encoded); bitPos++ {
+		if bitAt(encoded, bitPos) == 0 {
+			zeros++
+			continue
 		}
 
-		if e.EncodedByteAlign && bitPos%8 != 0 {
-			// align to byte border
-			bitPos += 8 - bitPos%8
+		if zeros >= 11 {
+			return bitPos - 11
 		}
 
-		pixels = append(pixels, pixelsRow)
-
-		if e.Rows > 0 && !e.EndOfBlock && len(pixels) >= (e.Rows+1) {
-			break
-		}
+		zeros = 0
 	}
 
-	// remove the white reference line
-	pixels = pixels[1:]
-
-	return pixels, nil
+	return bitPos
 }
 
 // decodeVerticalMode decodes the part of data using the vertical mode. Returns the moved `a0` and the
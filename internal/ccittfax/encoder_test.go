@@ -8,6 +8,7 @@ package ccittfax
 import (
 	_ "image/png"
 	"io/ioutil"
+	"math/rand"
 	"os"
 	"testing"
 )
@@ -567,3 +568,77 @@ func TestEncode(t *testing.T) {
 		}
 	}
 }
+
+func TestEncodeDecode(t *testing.T) {
+	// Generate the images having runs of various lengths, rows similar to the previous ones
+	// and the rows of the single color.
+	r := rand.New(rand.NewSource(1))
+	const height = 23
+	makePixels := func(width int) [][]byte {
+		pixels := make([][]byte, height)
+		for y := range pixels {
+			pixels[y] = make([]byte, width)
+			color := byte(1)
+			for x := range pixels[y] {
+				if r.Intn(1+y%4) == 0 {
+					color = 1 - color
+				}
+
+				switch {
+				case y%7 == 3:
+					pixels[y][x] = 0
+				case y%7 == 5:
+					pixels[y][x] = 1
+				case y > 0 && r.Intn(3) > 0:
+					pixels[y][x] = pixels[y-1][x]
+				default:
+					pixels[y][x] = color
+				}
+			}
+		}
+
+		return pixels
+	}
+
+	for _, width := range []int{1, 7, 13, 64, 100, 1731} {
+		pixels := makePixels(width)
+
+		for _, k := range []int{-1, 0, 1, 4} {
+			for i := 0; i < 8; i++ {
+				encoder := &Encoder{
+					K:                k,
+					Columns:          width,
+					EndOfLine:        i&1 != 0,
+					EncodedByteAlign: i&2 != 0,
+					EndOfBlock:       i&4 != 0,
+				}
+				if !encoder.EndOfBlock {
+					encoder.Rows = height
+				}
+
+				gotPixels, err := encoder.Decode(encoder.Encode(pixels))
+				if err != nil {
+					t.Errorf("Error decoding with %+v: %v\n", encoder, err)
+					continue
+				}
+
+				if len(gotPixels) != height {
+					t.Errorf("Wrong rows number with %+v. Got %v, want %v\n", encoder, len(gotPixels), height)
+					continue
+				}
+
+			rowsLoop:
+				for y := range gotPixels {
+					for x := range gotPixels[y] {
+						if gotPixels[y][x] != pixels[y][x] {
+							t.Errorf("Wrong pixel at %v:%v with %+v. Got %v, want %v\n",
+								y, x, encoder, gotPixels[y][x], pixels[y][x])
+
+							break rowsLoop
+						}
+					}
+				}
+			}
+		}
+	}
+}
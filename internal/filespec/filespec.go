@@ -0,0 +1,22 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package filespec provides functions processing the file specification dictionaries internally.
+package filespec
+
+import (
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// FileName returns the name of the file specification dictionary `fs`, preferring the Unicode
+// file name UF over F. An empty string is returned if it has no name.
+func FileName(fs *core.PdfObjectDictionary) string {
+	for _, key := range []core.PdfObjectName{"UF", "F"} {
+		if s, ok := core.GetString(fs.Get(key)); ok {
+			return s.Decoded()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,25 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package filespec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+func TestFileName(t *testing.T) {
+	fs := core.MakeDict()
+	require.Equal(t, "", FileName(fs))
+
+	fs.Set("F", core.MakeString("report.pdf"))
+	require.Equal(t, "report.pdf", FileName(fs))
+
+	fs.Set("UF", core.MakeEncodedString("rapport é.pdf", true))
+	require.Equal(t, "rapport é.pdf", FileName(fs))
+}
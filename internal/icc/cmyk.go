@@ -0,0 +1,75 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package icc
+
+// cmyPrimaries are the approximate sRGB colors of the overprints of solid
+// cyan, magenta and yellow inks printed on coated stock according to SWOP,
+// indexed by the bits 4 (cyan), 2 (magenta) and 1 (yellow).
+var cmyPrimaries = [8][3]float64{
+	{255, 255, 255}, // Paper.
+	{255, 242, 0},   // Yellow.
+	{236, 0, 140},   // Magenta.
+	{237, 28, 36},   // Magenta and yellow.
+	{0, 174, 239},   // Cyan.
+	{0, 166, 81},    // Cyan and yellow.
+	{46, 49, 146},   // Cyan and magenta.
+	{54, 51, 53},    // Cyan, magenta and yellow.
+}
+
+// blackPrimary is the approximate sRGB color of solid black ink.
+var blackPrimary = [3]float64{35, 31, 32}
+
+// cmykLinear holds the primaries in linear sRGB.
+var cmykLinear struct {
+	cmy [8][3]float64
+	k   [3]float64
+}
+
+func init() {
+	for i, p := range cmyPrimaries {
+		for j, v := range p {
+			cmykLinear.cmy[i][j] = srgbLinear(v / 255)
+		}
+	}
+	for j, v := range blackPrimary {
+		cmykLinear.k[j] = srgbLinear(v / 255)
+	}
+}
+
+// CMYKToSRGB converts the CMYK color `c`,`m`,`y`,`k`, whose components are in
+// range 0-1, to sRGB components in range 0-1, approximating a SWOP coated
+// press. It is used for CMYK colors without ICC profile, in place of the
+// naive conversion which renders them too saturated and too light.
+//
+// The colors of the cyan, magenta and yellow overprints are interpolated in
+// linear light with the Neugebauer equations, and then darkened by the black
+// ink.
+func CMYKToSRGB(c, m, y, k float64) (float64, float64, float64) {
+	c, m, y, k = clamp01(c), clamp01(m), clamp01(y), clamp01(k)
+	var rgb [3]float64
+	for i, p := range cmykLinear.cmy {
+		// Demichel weight of the overprint: the fraction of the area
+		// covered by exactly its inks.
+		w := coverage(c, i&4 != 0) * coverage(m, i&2 != 0) * coverage(y, i&1 != 0)
+		for j := range rgb {
+			rgb[j] += w * p[j]
+		}
+	}
+	for j := range rgb {
+		rgb[j] *= 1 - k*(1-cmykLinear.k[j])
+		rgb[j] = srgbGamma(rgb[j])
+	}
+	return rgb[0], rgb[1], rgb[2]
+}
+
+// coverage returns the fraction of the area covered by an ink with tint `t`
+// if `inked` is true, or not covered otherwise.
+func coverage(t float64, inked bool) float64 {
+	if inked {
+		return t
+	}
+	return 1 - t
+}
@@ -0,0 +1,304 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package icc parses ICC color profiles (ICC.1:2004-10 and earlier versions)
+// and converts the colors they describe to sRGB.
+//
+// The device to profile connection space (PCS) transforms supported are the
+// matrix/TRC transforms of RGB profiles, the gray TRC of gray profiles and the
+// lut8, lut16 and lutAToB A2B transforms, used in particular by CMYK output
+// profiles.
+package icc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	errInvalidProfile = errors.New("invalid ICC profile")
+	errUnsupported    = errors.New("unsupported ICC profile")
+)
+
+// Color space signatures of the profile header.
+const (
+	ColorSpaceGray = "GRAY"
+	ColorSpaceRGB  = "RGB "
+	ColorSpaceCMYK = "CMYK"
+	ColorSpaceLab  = "Lab "
+	ColorSpaceXYZ  = "XYZ "
+)
+
+// d50 is the white point of the profile connection space.
+var d50 = [3]float64{0.9642, 1.0, 0.8249}
+
+// Profile represents an ICC profile with a transform from the device color
+// space to the profile connection space.
+type Profile struct {
+	// Version is the profile version, e.g. 0x02100000 for version 2.1.
+	Version uint32
+	// Class is the profile class signature, e.g. "mntr" for display
+	// profiles and "prtr" for output profiles.
+	Class string
+	// ColorSpace is the signature of the device color space.
+	ColorSpace string
+	// PCS is the signature of the profile connection space, ColorSpaceXYZ
+	// or ColorSpaceLab.
+	PCS string
+
+	// toPCS converts device colors to the profile connection space,
+	// encoded in range 0-1 as described by pcsEncoding.
+	toPCS       func(in, out []float64)
+	pcsEncoding pcsEncoding
+}
+
+// pcsEncoding describes how the PCS values in range 0-1 returned by the
+// device to PCS transforms encode XYZ and Lab values.
+type pcsEncoding int
+
+const (
+	// pcsDirect values are XYZ values, as returned by matrix/TRC transforms.
+	pcsDirect pcsEncoding = iota
+	// pcsLegacy16 is the 16 bit encoding of lut16 transforms, where L* 100
+	// is encoded as 0xff00.
+	pcsLegacy16
+	// pcsStandard is the encoding of lut8 and lutAToB transforms, where L*
+	// 100 is encoded as 1.
+	pcsStandard
+)
+
+// Parse parses the ICC profile `data`. An error is returned if the profile is
+// invalid or none of its device to PCS transforms is supported.
+func Parse(data []byte) (*Profile, error) {
+	if len(data) < 132 || string(data[36:40]) != "acsp" {
+		return nil, errInvalidProfile
+	}
+	p := &Profile{
+		Version:    binary.BigEndian.Uint32(data[8:]),
+		Class:      string(data[12:16]),
+		ColorSpace: string(data[16:20]),
+		PCS:        string(data[20:24]),
+	}
+	if p.PCS != ColorSpaceXYZ && p.PCS != ColorSpaceLab {
+		return nil, fmt.Errorf("%v: PCS %q", errUnsupported, p.PCS)
+	}
+	n := p.NumComponents()
+	if n == 0 {
+		return nil, fmt.Errorf("%v: color space %q", errUnsupported, p.ColorSpace)
+	}
+
+	tags, err := parseTagTable(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The perceptual transform is preferred, then the colorimetric and
+	// saturation transforms.
+	for _, sig := range []string{"A2B0", "A2B1", "A2B2"} {
+		tag, ok := tags[sig]
+		if !ok {
+			continue
+		}
+		lut, enc, err := parseLUT(tag)
+		if err != nil || lut.inputs != n || lut.outputs != 3 {
+			continue
+		}
+		p.toPCS, p.pcsEncoding = lut.apply, enc
+		return p, nil
+	}
+
+	switch p.ColorSpace {
+	case ColorSpaceRGB:
+		if p.PCS != ColorSpaceXYZ {
+			break
+		}
+		var m [3][3]float64
+		var trcs [3]curve
+		for i, c := range "rgb" {
+			xyz, err := parseXYZ(tags[string(c)+"XYZ"])
+			if err != nil {
+				return nil, err
+			}
+			for j := range xyz {
+				m[j][i] = xyz[j]
+			}
+			if trcs[i], err = parseCurve(tags[string(c)+"TRC"]); err != nil {
+				return nil, err
+			}
+		}
+		p.toPCS = func(in, out []float64) {
+			r, g, b := trcs[0].eval(in[0]), trcs[1].eval(in[1]), trcs[2].eval(in[2])
+			for i := range m {
+				out[i] = m[i][0]*r + m[i][1]*g + m[i][2]*b
+			}
+		}
+		return p, nil
+	case ColorSpaceGray:
+		trc, err := parseCurve(tags["kTRC"])
+		if err != nil {
+			return nil, err
+		}
+		p.toPCS = func(in, out []float64) {
+			// The gray values are achromatic with the luminance of the TRC.
+			y := trc.eval(in[0])
+			if p.PCS == ColorSpaceLab {
+				out[0], out[1], out[2] = labF(y)*1.16-0.16, 0.5, 0.5
+				return
+			}
+			for i := range out {
+				out[i] = d50[i] * y
+			}
+		}
+		if p.PCS == ColorSpaceLab {
+			p.pcsEncoding = pcsStandard
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("%v: no supported A2B transform", errUnsupported)
+}
+
+// NumComponents returns the number of components of the device color space
+// of the profile, or 0 if the color space is not supported.
+func (p *Profile) NumComponents() int {
+	switch p.ColorSpace {
+	case ColorSpaceGray:
+		return 1
+	case ColorSpaceRGB, ColorSpaceLab, ColorSpaceXYZ:
+		return 3
+	case ColorSpaceCMYK:
+		return 4
+	}
+	return 0
+}
+
+// ToSRGB converts the device color `in`, whose components are in range 0-1,
+// to sRGB components in range 0-1. The colors outside the sRGB gamut are
+// clipped.
+func (p *Profile) ToSRGB(in []float64) (float64, float64, float64) {
+	var dev [4]float64
+	for i := 0; i < p.NumComponents() && i < len(in); i++ {
+		dev[i] = clamp01(in[i])
+	}
+
+	var pcs [3]float64
+	p.toPCS(dev[:p.NumComponents()], pcs[:])
+
+	var x, y, z float64
+	switch {
+	case p.PCS == ColorSpaceLab:
+		l, a, b := pcs[0]*100, pcs[1]*255-128, pcs[2]*255-128
+		if p.pcsEncoding == pcsLegacy16 {
+			l, a, b = pcs[0]*65535/65280*100, pcs[1]*65535/256-128, pcs[2]*65535/256-128
+		}
+		x, y, z = LabToXYZ(l, a, b)
+	case p.pcsEncoding == pcsDirect:
+		x, y, z = pcs[0], pcs[1], pcs[2]
+	default:
+		// u1Fixed15 encoding, where 1 is encoded as 0x8000.
+		const scale = 65535.0 / 32768
+		x, y, z = pcs[0]*scale, pcs[1]*scale, pcs[2]*scale
+	}
+	return XYZToSRGB(x, y, z)
+}
+
+// LabToXYZ converts the CIE L*a*b* color `l`,`a`,`b` to CIE XYZ relative to
+// the D50 white point of the PCS.
+func LabToXYZ(l, a, b float64) (float64, float64, float64) {
+	fy := (l + 16) / 116
+	fx := fy + a/500
+	fz := fy - b/200
+	finv := func(t float64) float64 {
+		if t > 6.0/29 {
+			return t * t * t
+		}
+		return 3 * (6.0 / 29) * (6.0 / 29) * (t - 4.0/29)
+	}
+	return d50[0] * finv(fx), d50[1] * finv(fy), d50[2] * finv(fz)
+}
+
+// labF is the function f(t) of the CIE L*a*b* definition, scaled so that
+// 1.16*labF(Y)-0.16 is L*/100.
+func labF(t float64) float64 {
+	if t > 216.0/24389 {
+		return math.Cbrt(t)
+	}
+	return (24389.0/27*t + 16) / 116
+}
+
+// XYZToSRGB converts the CIE XYZ color `x`,`y`,`z`, relative to the D50
+// white point of the PCS, to sRGB components in range 0-1. The color is
+// adapted to the D65 white point of sRGB with the Bradford transform.
+func XYZToSRGB(x, y, z float64) (float64, float64, float64) {
+	r := 3.1338561*x - 1.6168667*y - 0.4906146*z
+	g := -0.9787684*x + 1.9161415*y + 0.0334540*z
+	b := 0.0719453*x - 0.2289914*y + 1.4052427*z
+	return srgbGamma(r), srgbGamma(g), srgbGamma(b)
+}
+
+// srgbGamma applies the sRGB transfer function to the linear component `v`,
+// clipped to range 0-1.
+func srgbGamma(v float64) float64 {
+	v = clamp01(v)
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// srgbLinear is the inverse of srgbGamma.
+func srgbLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// parseTagTable returns the data of the tags of profile `data` by signature.
+func parseTagTable(data []byte) (map[string][]byte, error) {
+	count := int(binary.BigEndian.Uint32(data[128:]))
+	if count < 0 || 132+12*count > len(data) {
+		return nil, errInvalidProfile
+	}
+	tags := make(map[string][]byte, count)
+	for i := 0; i < count; i++ {
+		entry := data[132+12*i:]
+		offset := int(binary.BigEndian.Uint32(entry[4:]))
+		size := int(binary.BigEndian.Uint32(entry[8:]))
+		if offset < 0 || size < 0 || offset+size > len(data) || offset+size < offset {
+			return nil, errInvalidProfile
+		}
+		tags[string(entry[:4])] = data[offset : offset+size]
+	}
+	return tags, nil
+}
+
+// parseXYZ parses the first value of XYZType tag `tag`.
+func parseXYZ(tag []byte) ([3]float64, error) {
+	var xyz [3]float64
+	if len(tag) < 20 || string(tag[:4]) != "XYZ " {
+		return xyz, errInvalidProfile
+	}
+	for i := range xyz {
+		xyz[i] = s15Fixed16(tag[8+4*i:])
+	}
+	return xyz, nil
+}
+
+// s15Fixed16 returns the signed 15.16 fixed point number at the start of `b`.
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 || math.IsNaN(v) {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,197 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package icc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The reference colors of the fixtures are computed independently: Adobe RGB
+// colors with the D65 matrices of the Adobe RGB (1998) and sRGB color spaces,
+// and the colors of the synthetic CMYK press profile with the Neugebauer model
+// its A2B0 table is sampled from.
+
+func TestAdobeRGBProfile(t *testing.T) {
+	p := loadProfile(t, "AdobeRGB1998.icc")
+	require.Equal(t, ColorSpaceRGB, p.ColorSpace)
+	require.Equal(t, ColorSpaceXYZ, p.PCS)
+	require.Equal(t, 3, p.NumComponents())
+
+	testcases := []struct {
+		in, expected [3]int
+	}{
+		{[3]int{255, 0, 0}, [3]int{255, 0, 0}},
+		{[3]int{0, 255, 0}, [3]int{0, 255, 0}},
+		{[3]int{0, 0, 255}, [3]int{0, 0, 255}},
+		{[3]int{128, 128, 128}, [3]int{129, 129, 129}},
+		{[3]int{200, 100, 50}, [3]int{227, 100, 42}},
+		{[3]int{30, 160, 220}, [3]int{0, 161, 223}},
+		{[3]int{255, 255, 255}, [3]int{255, 255, 255}},
+	}
+	for _, tcase := range testcases {
+		in := []float64{float64(tcase.in[0]) / 255, float64(tcase.in[1]) / 255, float64(tcase.in[2]) / 255}
+		requireRGB(t, tcase.expected, 2, rgb(p.ToSRGB(in)))
+	}
+}
+
+func TestCMYKPressProfile(t *testing.T) {
+	p := loadProfile(t, "CMYKPress.icc")
+	require.Equal(t, ColorSpaceCMYK, p.ColorSpace)
+	require.Equal(t, ColorSpaceLab, p.PCS)
+	require.Equal(t, 4, p.NumComponents())
+
+	testcases := []struct {
+		in       []float64
+		expected [3]int
+	}{
+		{[]float64{0, 0, 0, 0}, [3]int{255, 255, 255}},
+		{[]float64{1, 0, 0, 0}, [3]int{70, 160, 210}},
+		{[]float64{0, 1, 0, 0}, [3]int{214, 60, 130}},
+		{[]float64{0, 0, 1, 0}, [3]int{249, 237, 80}},
+		{[]float64{0, 0, 0, 1}, [3]int{60, 58, 60}},
+		{[]float64{0.5, 0.25, 0.1, 0.2}, [3]int{169, 173, 188}},
+		{[]float64{0.3, 0.7, 0.6, 0}, [3]int{197, 140, 129}},
+		{[]float64{0.2, 0.2, 0.2, 0.5}, [3]int{169, 161, 157}},
+	}
+	for _, tcase := range testcases {
+		// The colors between the grid points of the table are interpolated.
+		requireRGB(t, tcase.expected, 4, rgb(p.ToSRGB(tcase.in)))
+	}
+}
+
+func TestCMYKToSRGB(t *testing.T) {
+	testcases := []struct {
+		c, m, y, k float64
+		expected   [3]int
+	}{
+		{0, 0, 0, 0, [3]int{255, 255, 255}},
+		{1, 0, 0, 0, [3]int{0, 174, 239}},
+		{0, 1, 1, 0, [3]int{237, 28, 36}},
+		{0, 0, 0, 1, [3]int{35, 31, 32}},
+		{1, 1, 1, 1, [3]int{2, 1, 2}},
+	}
+	for _, tcase := range testcases {
+		requireRGB(t, tcase.expected, 1, rgb(CMYKToSRGB(tcase.c, tcase.m, tcase.y, tcase.k)))
+	}
+
+	// Unlike the naive conversion, 50% cyan is not full intensity green and
+	// blue.
+	_, g, b := CMYKToSRGB(0.5, 0, 0, 0)
+	require.True(t, g < 0.9 && b < 0.98, "g=%v b=%v", g, b)
+}
+
+func TestParametricCurve(t *testing.T) {
+	// sRGB transfer function, parametric curve type 3.
+	var b bytes.Buffer
+	b.WriteString("para\x00\x00\x00\x00")
+	binary.Write(&b, binary.BigEndian, uint16(3))
+	b.Write([]byte{0, 0})
+	for _, v := range []float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045} {
+		binary.Write(&b, binary.BigEndian, int32(math.Round(v*65536)))
+	}
+	c, size, err := parseCurveN(b.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, b.Len(), size)
+	for _, x := range []float64{0, 0.02, 0.2, 0.5, 0.9, 1} {
+		require.InDelta(t, srgbLinear(x), c.eval(x), 1e-4, "x=%v", x)
+	}
+}
+
+func TestLUTAToB(t *testing.T) {
+	// RGB to XYZ transform with identity A curves and a 2 point CLUT
+	// mapping the inputs to halved values, then a matrix swapping the first
+	// two outputs.
+	identity := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x00")
+	var clut bytes.Buffer
+	grid := make([]byte, 16)
+	grid[0], grid[1], grid[2] = 2, 2, 2
+	clut.Write(grid)
+	clut.Write([]byte{1, 0, 0, 0})
+	for i := 0; i < 8; i++ {
+		r, g, b := i>>2&1, i>>1&1, i&1
+		clut.Write([]byte{byte(r * 128), byte(g * 128), byte(b * 128)})
+	}
+	clut.WriteByte(0)
+
+	var matrix bytes.Buffer
+	for _, v := range []float64{0, 1, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0} {
+		binary.Write(&matrix, binary.BigEndian, int32(math.Round(v*65536)))
+	}
+
+	var tag bytes.Buffer
+	tag.WriteString("mAB \x00\x00\x00\x00")
+	tag.Write([]byte{3, 3, 0, 0})
+	curvesB := 32
+	offMatrix := curvesB + 3*len(identity)
+	curvesM := offMatrix + matrix.Len()
+	offCLUT := curvesM + 3*len(identity)
+	curvesA := offCLUT + clut.Len()
+	for _, off := range []int{curvesB, offMatrix, curvesM, offCLUT, curvesA} {
+		binary.Write(&tag, binary.BigEndian, uint32(off))
+	}
+	tag.Write(bytes.Repeat(identity, 3))
+	tag.Write(matrix.Bytes())
+	tag.Write(bytes.Repeat(identity, 3))
+	tag.Write(clut.Bytes())
+	tag.Write(bytes.Repeat(identity, 3))
+
+	l, enc, err := parseLUT(tag.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, pcsStandard, enc)
+	out := make([]float64, 3)
+	l.apply([]float64{1, 0.5, 0}, out)
+	require.InDeltaSlice(t, []float64{0.25, 0.5, 0}, out, 0.01)
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse(nil)
+	require.Error(t, err)
+
+	data := loadData(t, "AdobeRGB1998.icc")
+	// Truncated tag table.
+	_, err = Parse(data[:140])
+	require.Error(t, err)
+
+	// Missing TRC tags.
+	_, err = Parse(data[:len(data)-16])
+	require.Error(t, err)
+}
+
+func loadData(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return data
+}
+
+func loadProfile(t *testing.T, name string) *Profile {
+	p, err := Parse(loadData(t, name))
+	require.NoError(t, err)
+	return p
+}
+
+func rgb(r, g, b float64) [3]float64 {
+	return [3]float64{r, g, b}
+}
+
+// requireRGB checks that the sRGB color `c`, in range 0-1, matches the 8 bit
+// color `expected` within `delta`.
+func requireRGB(t *testing.T, expected [3]int, delta int, c [3]float64) {
+	var actual [3]int
+	for i, v := range c {
+		actual[i] = int(math.Round(v * 255))
+	}
+	for i := range actual {
+		d := actual[i] - expected[i]
+		require.True(t, d >= -delta && d <= delta, "expected %v, got %v", expected, actual)
+	}
+}
@@ -0,0 +1,450 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package icc
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// curve is a one-dimensional transform of values in range 0-1, given by a
+// curveType or parametricCurveType tag.
+type curve struct {
+	// table holds the samples of sampled curves, evenly spaced over 0-1.
+	table []float64
+	// gamma is the exponent of the curves with no table, if params is nil.
+	gamma float64
+	// params are the parameters g, a, b, c, d, e, f of parametric curves.
+	params []float64
+	// funcType is the function type, 0 to 4, of parametric curves.
+	funcType int
+}
+
+// identityCurve is the curve of curveType tags with no entries.
+var identityCurve = curve{gamma: 1}
+
+// eval returns the value of the curve at `x`.
+func (c curve) eval(x float64) float64 {
+	x = clamp01(x)
+	switch {
+	case c.table != nil:
+		return interpolateTable(c.table, x)
+	case c.params == nil:
+		return math.Pow(x, c.gamma)
+	}
+
+	p := c.params
+	g := p[0]
+	var y float64
+	switch c.funcType {
+	case 0:
+		y = math.Pow(x, g)
+	case 1:
+		if x >= -p[2]/p[1] {
+			y = math.Pow(p[1]*x+p[2], g)
+		}
+	case 2:
+		y = p[3]
+		if x >= -p[2]/p[1] {
+			y += math.Pow(p[1]*x+p[2], g)
+		}
+	case 3:
+		if x >= p[4] {
+			y = math.Pow(p[1]*x+p[2], g)
+		} else {
+			y = p[3] * x
+		}
+	case 4:
+		if x >= p[4] {
+			y = math.Pow(p[1]*x+p[2], g) + p[5]
+		} else {
+			y = p[3]*x + p[6]
+		}
+	}
+	return clamp01(y)
+}
+
+// interpolateTable returns the value at `x` of the curve sampled by `table`,
+// linearly interpolated between the samples.
+func interpolateTable(table []float64, x float64) float64 {
+	if len(table) == 1 {
+		return table[0]
+	}
+	pos := x * float64(len(table)-1)
+	i := int(pos)
+	if i >= len(table)-1 {
+		return table[len(table)-1]
+	}
+	frac := pos - float64(i)
+	return table[i] + frac*(table[i+1]-table[i])
+}
+
+// parseCurve parses the curveType or parametricCurveType tag `tag`.
+func parseCurve(tag []byte) (curve, error) {
+	c, _, err := parseCurveN(tag)
+	return c, err
+}
+
+// parseCurveN parses the curveType or parametricCurveType tag at the start of
+// `data` and returns it with its size in bytes.
+func parseCurveN(data []byte) (curve, int, error) {
+	if len(data) < 12 {
+		return curve{}, 0, errInvalidProfile
+	}
+	switch string(data[:4]) {
+	case "curv":
+		count := int(binary.BigEndian.Uint32(data[8:]))
+		size := 12 + 2*count
+		if count < 0 || size > len(data) || size < 12 {
+			return curve{}, 0, errInvalidProfile
+		}
+		switch count {
+		case 0:
+			return identityCurve, size, nil
+		case 1:
+			// u8Fixed8Number gamma.
+			return curve{gamma: float64(binary.BigEndian.Uint16(data[12:])) / 256}, size, nil
+		}
+		table := make([]float64, count)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(data[12+2*i:])) / 65535
+		}
+		return curve{table: table}, size, nil
+	case "para":
+		funcType := int(binary.BigEndian.Uint16(data[8:]))
+		numParams := []int{1, 3, 4, 5, 7}
+		if funcType >= len(numParams) {
+			return curve{}, 0, errUnsupported
+		}
+		size := 12 + 4*numParams[funcType]
+		if size > len(data) {
+			return curve{}, 0, errInvalidProfile
+		}
+		params := make([]float64, 7)
+		for i := 0; i < numParams[funcType]; i++ {
+			params[i] = s15Fixed16(data[12+4*i:])
+		}
+		if funcType > 0 && params[1] == 0 {
+			return curve{}, 0, errInvalidProfile
+		}
+		return curve{params: params, funcType: funcType}, size, nil
+	}
+	return curve{}, 0, errUnsupported
+}
+
+// lut is a device to PCS transform given by a lut8Type, lut16Type or
+// lutAToBType tag. The values are transformed by the input curves, the
+// multidimensional color lookup table (CLUT), the output curves and, for
+// lutAToBType tags, a matrix and final curves.
+type lut struct {
+	inputs, outputs int
+
+	inCurves  []curve
+	grid      []int     // Number of CLUT grid points of each input.
+	clut      []float64 // CLUT values, in range 0-1, with the first input varying slowest.
+	outCurves []curve
+
+	// matrix is the 3x3 matrix followed by 3 offsets of lutAToBType
+	// transforms, applied to the output of outCurves, or nil.
+	matrix     []float64
+	postCurves []curve
+}
+
+// parseLUT parses the lut8Type, lut16Type or lutAToBType tag `tag` and
+// returns it with the encoding of its PCS values.
+func parseLUT(tag []byte) (*lut, pcsEncoding, error) {
+	if len(tag) < 12 {
+		return nil, 0, errInvalidProfile
+	}
+	switch string(tag[:4]) {
+	case "mft1":
+		l, err := parseLUT8(tag)
+		return l, pcsStandard, err
+	case "mft2":
+		l, err := parseLUT16(tag)
+		return l, pcsLegacy16, err
+	case "mAB ":
+		l, err := parseLUTAToB(tag)
+		return l, pcsStandard, err
+	}
+	return nil, 0, errUnsupported
+}
+
+// newLUT returns a lut with `inputs` inputs and `outputs` outputs, whose
+// CLUT has `points` grid points in each dimension.
+func newLUT(inputs, outputs int, points []int) (*lut, int, error) {
+	if inputs < 1 || inputs > 15 || outputs < 1 || outputs > 15 {
+		return nil, 0, errInvalidProfile
+	}
+	size := outputs
+	for _, n := range points[:inputs] {
+		if n < 2 {
+			return nil, 0, errInvalidProfile
+		}
+		size *= n
+		if size > 1<<24 {
+			return nil, 0, errInvalidProfile
+		}
+	}
+	return &lut{
+		inputs:  inputs,
+		outputs: outputs,
+		grid:    points[:inputs],
+	}, size, nil
+}
+
+// parseLUT8 parses lut8Type tag `tag`.
+func parseLUT8(tag []byte) (*lut, error) {
+	if len(tag) < 48 {
+		return nil, errInvalidProfile
+	}
+	inputs, outputs, points := int(tag[8]), int(tag[9]), int(tag[10])
+	l, clutSize, err := newLUT(inputs, outputs, repeat(points, 15))
+	if err != nil {
+		return nil, err
+	}
+	pos := 48
+	if pos+256*inputs+clutSize+256*outputs > len(tag) {
+		return nil, errInvalidProfile
+	}
+	table := func(n int) []float64 {
+		t := make([]float64, n)
+		for i := range t {
+			t[i] = float64(tag[pos+i]) / 255
+		}
+		pos += n
+		return t
+	}
+	for i := 0; i < inputs; i++ {
+		l.inCurves = append(l.inCurves, curve{table: table(256)})
+	}
+	l.clut = table(clutSize)
+	for i := 0; i < outputs; i++ {
+		l.outCurves = append(l.outCurves, curve{table: table(256)})
+	}
+	return l, nil
+}
+
+// parseLUT16 parses lut16Type tag `tag`.
+func parseLUT16(tag []byte) (*lut, error) {
+	if len(tag) < 52 {
+		return nil, errInvalidProfile
+	}
+	inputs, outputs, points := int(tag[8]), int(tag[9]), int(tag[10])
+	l, clutSize, err := newLUT(inputs, outputs, repeat(points, 15))
+	if err != nil {
+		return nil, err
+	}
+	inEntries := int(binary.BigEndian.Uint16(tag[48:]))
+	outEntries := int(binary.BigEndian.Uint16(tag[50:]))
+	if inEntries < 2 || outEntries < 2 {
+		return nil, errInvalidProfile
+	}
+	pos := 52
+	if pos+2*(inEntries*inputs+clutSize+outEntries*outputs) > len(tag) {
+		return nil, errInvalidProfile
+	}
+	table := func(n int) []float64 {
+		t := make([]float64, n)
+		for i := range t {
+			t[i] = float64(binary.BigEndian.Uint16(tag[pos+2*i:])) / 65535
+		}
+		pos += 2 * n
+		return t
+	}
+	for i := 0; i < inputs; i++ {
+		l.inCurves = append(l.inCurves, curve{table: table(inEntries)})
+	}
+	l.clut = table(clutSize)
+	for i := 0; i < outputs; i++ {
+		l.outCurves = append(l.outCurves, curve{table: table(outEntries)})
+	}
+	return l, nil
+}
+
+// parseLUTAToB parses lutAToBType tag `tag`.
+func parseLUTAToB(tag []byte) (*lut, error) {
+	if len(tag) < 32 {
+		return nil, errInvalidProfile
+	}
+	inputs, outputs := int(tag[8]), int(tag[9])
+	offset := func(i int) int {
+		return int(binary.BigEndian.Uint32(tag[12+4*i:]))
+	}
+	offB, offMatrix, offM, offCLUT, offA := offset(0), offset(1), offset(2), offset(3), offset(4)
+
+	curves := func(off, n int) ([]curve, error) {
+		if off == 0 {
+			return nil, nil
+		}
+		var cs []curve
+		for i := 0; i < n; i++ {
+			if off < 0 || off >= len(tag) {
+				return nil, errInvalidProfile
+			}
+			c, size, err := parseCurveN(tag[off:])
+			if err != nil {
+				return nil, err
+			}
+			cs = append(cs, c)
+			// The curves are aligned on 4 bytes.
+			off += (size + 3) &^ 3
+		}
+		return cs, nil
+	}
+
+	var l *lut
+	if offCLUT != 0 {
+		if offCLUT+20 > len(tag) {
+			return nil, errInvalidProfile
+		}
+		points := make([]int, 16)
+		for i := range points {
+			points[i] = int(tag[offCLUT+i])
+		}
+		var clutSize int
+		var err error
+		if l, clutSize, err = newLUT(inputs, outputs, points); err != nil {
+			return nil, err
+		}
+		precision := int(tag[offCLUT+16])
+		pos := offCLUT + 20
+		if (precision != 1 && precision != 2) || pos+precision*clutSize > len(tag) {
+			return nil, errInvalidProfile
+		}
+		l.clut = make([]float64, clutSize)
+		for i := range l.clut {
+			if precision == 1 {
+				l.clut[i] = float64(tag[pos+i]) / 255
+			} else {
+				l.clut[i] = float64(binary.BigEndian.Uint16(tag[pos+2*i:])) / 65535
+			}
+		}
+	} else {
+		// Without CLUT, the number of inputs and outputs must match.
+		if inputs != outputs {
+			return nil, errInvalidProfile
+		}
+		var err error
+		if l, _, err = newLUT(inputs, outputs, repeat(2, 15)); err != nil {
+			return nil, err
+		}
+		l.grid = nil
+	}
+
+	var err error
+	if l.inCurves, err = curves(offA, inputs); err != nil {
+		return nil, err
+	}
+	if l.outCurves, err = curves(offM, outputs); err != nil {
+		return nil, err
+	}
+	if l.postCurves, err = curves(offB, outputs); err != nil {
+		return nil, err
+	}
+	if offMatrix != 0 && outputs == 3 {
+		if offMatrix+48 > len(tag) {
+			return nil, errInvalidProfile
+		}
+		l.matrix = make([]float64, 12)
+		for i := range l.matrix {
+			l.matrix[i] = s15Fixed16(tag[offMatrix+4*i:])
+		}
+	}
+	return l, nil
+}
+
+// apply transforms the input values `in` to the output values `out`.
+func (l *lut) apply(in, out []float64) {
+	vals := make([]float64, l.inputs)
+	for i := range vals {
+		vals[i] = in[i]
+		if l.inCurves != nil {
+			vals[i] = l.inCurves[i].eval(vals[i])
+		}
+	}
+
+	res := make([]float64, l.outputs)
+	if l.grid != nil {
+		l.interpolate(vals, res)
+	} else {
+		copy(res, vals)
+	}
+
+	if l.outCurves != nil {
+		for i := range res {
+			res[i] = l.outCurves[i].eval(res[i])
+		}
+	}
+	if l.matrix != nil {
+		m := l.matrix
+		r0, r1, r2 := res[0], res[1], res[2]
+		for i := 0; i < 3; i++ {
+			res[i] = clamp01(m[3*i]*r0 + m[3*i+1]*r1 + m[3*i+2]*r2 + m[9+i])
+		}
+	}
+	if l.postCurves != nil {
+		for i := range res {
+			res[i] = l.postCurves[i].eval(res[i])
+		}
+	}
+	copy(out, res)
+}
+
+// interpolate sets `out` to the CLUT values at `in`, multilinearly
+// interpolated between the grid points surrounding it.
+func (l *lut) interpolate(in, out []float64) {
+	n := len(l.grid)
+	base := 0
+	idx := make([]int, n)
+	frac := make([]float64, n)
+	strides := make([]int, n)
+	stride := l.outputs
+	for i := n - 1; i >= 0; i-- {
+		strides[i] = stride
+		pos := clamp01(in[i]) * float64(l.grid[i]-1)
+		idx[i] = int(pos)
+		if idx[i] >= l.grid[i]-1 {
+			idx[i] = l.grid[i] - 2
+		}
+		frac[i] = pos - float64(idx[i])
+		base += idx[i] * stride
+		stride *= l.grid[i]
+	}
+
+	for i := range out {
+		out[i] = 0
+	}
+	// Sum the values of the 2^n corners of the surrounding cell, weighted by
+	// their distance.
+	for corner := 0; corner < 1<<uint(n); corner++ {
+		w := 1.0
+		off := base
+		for i := 0; i < n; i++ {
+			if corner&(1<<uint(i)) != 0 {
+				w *= frac[i]
+				off += strides[i]
+			} else {
+				w *= 1 - frac[i]
+			}
+		}
+		if w == 0 {
+			continue
+		}
+		for j := range out {
+			out[j] += w * l.clut[off+j]
+		}
+	}
+}
+
+// repeat returns a slice of `n` values `v`.
+func repeat(v, n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = v
+	}
+	return s
+}
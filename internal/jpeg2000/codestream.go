@@ -0,0 +1,654 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpeg2000
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Markers of the codestream (A.2).
+const (
+	markerSOC = 0xFF4F // Start of codestream.
+	markerSOT = 0xFF90 // Start of tile-part.
+	markerSOD = 0xFF93 // Start of data.
+	markerEOC = 0xFFD9 // End of codestream.
+	markerSIZ = 0xFF51 // Image and tile size.
+	markerCOD = 0xFF52 // Coding style default.
+	markerCOC = 0xFF53 // Coding style component.
+	markerRGN = 0xFF5E // Region-of-interest.
+	markerQCD = 0xFF5C // Quantization default.
+	markerQCC = 0xFF5D // Quantization component.
+	markerPOC = 0xFF5F // Progression order change.
+	markerTLM = 0xFF55 // Tile-part lengths.
+	markerPLM = 0xFF57 // Packet length, main header.
+	markerPLT = 0xFF58 // Packet length, tile-part header.
+	markerPPM = 0xFF60 // Packed packet headers, main header.
+	markerPPT = 0xFF61 // Packed packet headers, tile-part header.
+	markerCRG = 0xFF63 // Component registration.
+	markerCOM = 0xFF64 // Comment.
+	markerSOP = 0xFF91 // Start of packet.
+	markerEPH = 0xFF92 // End of packet header.
+)
+
+// Progression orders (Table A.16).
+const (
+	progressionLRCP = iota
+	progressionRLCP
+	progressionRPCL
+	progressionPCRL
+	progressionCPRL
+)
+
+// Code-block coding style flags (Table A.19).
+const (
+	cblkStyleBypass    = 0x01 // Selective arithmetic coding bypass.
+	cblkStyleReset     = 0x02 // Reset context probabilities on coding pass boundaries.
+	cblkStyleTermAll   = 0x04 // Termination on each coding pass.
+	cblkStyleVCausal   = 0x08 // Vertically causal context.
+	cblkStylePredTerm  = 0x10 // Predictable termination.
+	cblkStyleSegSymbol = 0x20 // Segmentation symbols are used.
+)
+
+// Quantization styles (Table A.28).
+const (
+	quantNone            = 0
+	quantScalarDerived   = 1
+	quantScalarExpounded = 2
+)
+
+var (
+	errInvalidCodestream = errors.New("jpeg2000: invalid codestream")
+	errUnexpectedEOF     = errors.New("jpeg2000: unexpected end of data")
+)
+
+// siz is the content of the image and tile size marker segment (A.5.1).
+type siz struct {
+	xsiz, ysiz   int // Size of the reference grid.
+	x0, y0       int // Offset of the image area on the reference grid.
+	xtsiz, ytsiz int // Size of the tiles.
+	xt0, yt0     int // Offset of the first tile on the reference grid.
+	comps        []componentSiz
+}
+
+// componentSiz describes an image component.
+type componentSiz struct {
+	precision int
+	signed    bool
+	dx, dy    int // Sub-sampling factors.
+}
+
+// numTiles returns the number of tiles in both directions.
+func (s *siz) numTiles() (int, int) {
+	return ceilDiv(s.xsiz-s.xt0, s.xtsiz), ceilDiv(s.ysiz-s.yt0, s.ytsiz)
+}
+
+// codingStyle is the coding style of a tile-component, defined by COD and COC marker segments
+// (A.6.1 and A.6.2).
+type codingStyle struct {
+	// Defined by COD only.
+	progression int
+	numLayers   int
+	mct         bool
+	sop, eph    bool
+
+	levels      int // Number of decomposition levels.
+	xcb, ycb    int // Code-block size exponents.
+	cblkStyle   int
+	reversible  bool     // Reversible 5-3 wavelet transform, otherwise irreversible 9-7.
+	precincts   [][2]int // Precinct size exponents for each resolution level.
+	hasPrecinct bool
+}
+
+// precinctSize returns the precinct size exponents of resolution level `r`.
+func (cs *codingStyle) precinctSize(r int) (int, int) {
+	if !cs.hasPrecinct {
+		return 15, 15
+	}
+	if r < len(cs.precincts) {
+		return cs.precincts[r][0], cs.precincts[r][1]
+	}
+	p := cs.precincts[len(cs.precincts)-1]
+	return p[0], p[1]
+}
+
+// quantization is the quantization of a tile-component, defined by QCD and QCC marker segments
+// (A.6.4 and A.6.5).
+type quantization struct {
+	style     int
+	guardBits int
+	exponents []int
+	mantissas []int
+}
+
+// stepSize returns the exponent and the mantissa of the quantization step of subband `b`,
+// where bands are numbered in the order LL, HL1, LH1, HH1, HL2... of Table A.30.
+// `level` is the decomposition level of the subband, from 1 for the highest resolution.
+func (q *quantization) stepSize(b, level, levels int) (int, int) {
+	if q.style == quantScalarDerived {
+		// Derived from the LL subband (E-5).
+		if len(q.exponents) == 0 {
+			return 0, 0
+		}
+		return q.exponents[0] - (levels - level), q.mantissas[0]
+	}
+	if b < len(q.exponents) {
+		return q.exponents[b], q.mantissas[b]
+	}
+	if len(q.exponents) == 0 {
+		return 0, 0
+	}
+	return q.exponents[len(q.exponents)-1], q.mantissas[len(q.mantissas)-1]
+}
+
+// tileParams are the coding parameters of a tile, initialized from the main header and updated
+// by the tile-part headers.
+type tileParams struct {
+	cod    codingStyle
+	coc    []*codingStyle
+	qcd    quantization
+	qcc    []*quantization
+	roi    []int
+	ppt    []byte
+	hasCOD bool
+	hasQCD bool
+}
+
+// clone returns a copy of the parameters for a tile.
+func (p *tileParams) clone() *tileParams {
+	c := *p
+	c.coc = append([]*codingStyle{}, p.coc...)
+	c.qcc = append([]*quantization{}, p.qcc...)
+	c.roi = append([]int{}, p.roi...)
+	c.ppt = nil
+	return &c
+}
+
+// component returns the coding style and the quantization of component `c`.
+func (p *tileParams) component(c int) (*codingStyle, *quantization) {
+	cs, q := &p.cod, &p.qcd
+	if c < len(p.coc) && p.coc[c] != nil {
+		cs = p.coc[c]
+	}
+	if c < len(p.qcc) && p.qcc[c] != nil {
+		q = p.qcc[c]
+	}
+	return cs, q
+}
+
+// codestream is a parsed JPEG 2000 codestream.
+type codestream struct {
+	siz   siz
+	main  tileParams
+	tiles []*tileData
+	ppm   []byte // Packed packet headers of the main header.
+}
+
+// tileData is the header and data of a tile, concatenated from its tile-parts.
+type tileData struct {
+	params *tileParams
+	data   []byte
+	// Packed packet headers of the tile, from PPT or PPM marker segments.
+	headers []byte
+	hasPPx  bool
+}
+
+// reader reads the marker segments of a codestream.
+type reader struct {
+	data []byte
+	pos  int
+}
+
+func (r *reader) u8() (int, error) {
+	if r.pos+1 > len(r.data) {
+		return 0, errUnexpectedEOF
+	}
+	v := r.data[r.pos]
+	r.pos++
+	return int(v), nil
+}
+
+func (r *reader) u16() (int, error) {
+	if r.pos+2 > len(r.data) {
+		return 0, errUnexpectedEOF
+	}
+	v := binary.BigEndian.Uint16(r.data[r.pos:])
+	r.pos += 2
+	return int(v), nil
+}
+
+func (r *reader) u32() (int, error) {
+	if r.pos+4 > len(r.data) {
+		return 0, errUnexpectedEOF
+	}
+	v := binary.BigEndian.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return int(v), nil
+}
+
+// segment returns the content of the marker segment at the current position, which starts
+// with its length.
+func (r *reader) segment() ([]byte, error) {
+	length, err := r.u16()
+	if err != nil {
+		return nil, err
+	}
+	if length < 2 || r.pos+length-2 > len(r.data) {
+		return nil, errUnexpectedEOF
+	}
+	seg := r.data[r.pos : r.pos+length-2]
+	r.pos += length - 2
+	return seg, nil
+}
+
+// parseCodestream parses the headers of the codestream `data`. If `headerOnly` is true, only
+// the main header is parsed.
+func parseCodestream(data []byte, headerOnly bool) (*codestream, error) {
+	r := &reader{data: data}
+	if m, err := r.u16(); err != nil || m != markerSOC {
+		return nil, errors.New("jpeg2000: missing SOC marker")
+	}
+	cs := &codestream{}
+
+	// Main header (A.4.1).
+	var hasSIZ bool
+	for {
+		m, err := r.u16()
+		if err != nil {
+			return nil, err
+		}
+		if m == markerSOT {
+			r.pos -= 2
+			break
+		}
+		if m == markerEOC {
+			return nil, errors.New("jpeg2000: no tiles in codestream")
+		}
+		seg, err := r.segment()
+		if err != nil {
+			return nil, err
+		}
+		if !hasSIZ && m != markerSIZ {
+			return nil, errors.New("jpeg2000: missing SIZ marker")
+		}
+		switch m {
+		case markerSIZ:
+			if err := cs.parseSIZ(seg); err != nil {
+				return nil, err
+			}
+			hasSIZ = true
+			cs.main.coc = make([]*codingStyle, len(cs.siz.comps))
+			cs.main.qcc = make([]*quantization, len(cs.siz.comps))
+			cs.main.roi = make([]int, len(cs.siz.comps))
+			if headerOnly {
+				return cs, nil
+			}
+		case markerPPM:
+			// Zppm, then Nppm and Ippm for each tile-part.
+			if len(seg) < 1 {
+				return nil, errInvalidCodestream
+			}
+			cs.ppm = append(cs.ppm, seg[1:]...)
+		default:
+			if err := cs.parseParam(&cs.main, m, seg); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !cs.main.hasCOD || !cs.main.hasQCD {
+		return nil, errors.New("jpeg2000: missing COD or QCD marker")
+	}
+
+	numX, numY := cs.siz.numTiles()
+	if numX <= 0 || numY <= 0 || numX*numY > 65535 {
+		return nil, errInvalidCodestream
+	}
+	cs.tiles = make([]*tileData, numX*numY)
+	ppm := cs.ppm
+
+	// Tile-parts (A.4.2).
+	for r.pos < len(data) {
+		start := r.pos
+		m, err := r.u16()
+		if err != nil {
+			return nil, err
+		}
+		if m == markerEOC {
+			break
+		}
+		if m != markerSOT {
+			return nil, fmt.Errorf("jpeg2000: unexpected marker %04X", m)
+		}
+		seg, err := r.segment()
+		if err != nil {
+			return nil, err
+		}
+		if len(seg) < 8 {
+			return nil, errInvalidCodestream
+		}
+		tileIndex := int(binary.BigEndian.Uint16(seg))
+		psot := int(binary.BigEndian.Uint32(seg[2:]))
+		tilePart := int(seg[6])
+		if tileIndex >= len(cs.tiles) {
+			return nil, fmt.Errorf("jpeg2000: invalid tile index %d", tileIndex)
+		}
+		end := len(data)
+		if psot != 0 {
+			end = start + psot
+			if end > len(data) {
+				// Truncated codestream, decode what is available.
+				end = len(data)
+			}
+		}
+		tile := cs.tiles[tileIndex]
+		if tile == nil {
+			tile = &tileData{params: cs.main.clone()}
+			cs.tiles[tileIndex] = tile
+		}
+		for {
+			m, err := r.u16()
+			if err != nil {
+				return nil, err
+			}
+			if m == markerSOD {
+				break
+			}
+			seg, err := r.segment()
+			if err != nil {
+				return nil, err
+			}
+			switch m {
+			case markerPPT:
+				if len(seg) < 1 {
+					return nil, errInvalidCodestream
+				}
+				tile.headers = append(tile.headers, seg[1:]...)
+				tile.hasPPx = true
+			case markerCOD, markerCOC, markerQCD, markerQCC:
+				if tilePart != 0 {
+					// Only allowed in the first tile-part.
+					continue
+				}
+				fallthrough
+			default:
+				if err := cs.parseParam(tile.params, m, seg); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if len(ppm) > 0 {
+			// Packed packet headers of the tile-part, in the order of the tile-parts.
+			if len(ppm) < 4 {
+				return nil, errInvalidCodestream
+			}
+			n := int(binary.BigEndian.Uint32(ppm))
+			if 4+n > len(ppm) {
+				return nil, errInvalidCodestream
+			}
+			tile.headers = append(tile.headers, ppm[4:4+n]...)
+			tile.hasPPx = true
+			ppm = ppm[4+n:]
+		}
+		if end < r.pos {
+			return nil, errInvalidCodestream
+		}
+		tile.data = append(tile.data, data[r.pos:end]...)
+		r.pos = end
+	}
+	return cs, nil
+}
+
+// parseSIZ parses the SIZ marker segment.
+func (cs *codestream) parseSIZ(seg []byte) error {
+	r := &reader{data: seg}
+	var v [11]int
+	if _, err := r.u16(); err != nil { // Rsiz.
+		return err
+	}
+	for i := 0; i < 8; i++ {
+		x, err := r.u32()
+		if err != nil {
+			return err
+		}
+		v[i] = x
+	}
+	ncomp, err := r.u16()
+	if err != nil {
+		return err
+	}
+	s := &cs.siz
+	s.xsiz, s.ysiz, s.x0, s.y0 = v[0], v[1], v[2], v[3]
+	s.xtsiz, s.ytsiz, s.xt0, s.yt0 = v[4], v[5], v[6], v[7]
+	if s.xsiz <= s.x0 || s.ysiz <= s.y0 || s.xtsiz <= 0 || s.ytsiz <= 0 ||
+		s.xt0 > s.x0 || s.yt0 > s.y0 || s.xt0+s.xtsiz <= s.x0 || s.yt0+s.ytsiz <= s.y0 {
+		return errors.New("jpeg2000: invalid image size")
+	}
+	if ncomp < 1 || ncomp > 16384 {
+		return errors.New("jpeg2000: invalid number of components")
+	}
+	for i := 0; i < ncomp; i++ {
+		ssiz, err := r.u8()
+		if err != nil {
+			return err
+		}
+		dx, err := r.u8()
+		if err != nil {
+			return err
+		}
+		dy, err := r.u8()
+		if err != nil {
+			return err
+		}
+		c := componentSiz{precision: ssiz&0x7F + 1, signed: ssiz&0x80 != 0, dx: dx, dy: dy}
+		if c.precision > 38 || c.dx == 0 || c.dy == 0 {
+			return errors.New("jpeg2000: invalid component size")
+		}
+		s.comps = append(s.comps, c)
+	}
+	return nil
+}
+
+// componentIndex reads a component index of a COC, QCC or RGN marker segment.
+func (cs *codestream) componentIndex(r *reader) (int, error) {
+	var c int
+	var err error
+	if len(cs.siz.comps) < 257 {
+		c, err = r.u8()
+	} else {
+		c, err = r.u16()
+	}
+	if err != nil {
+		return 0, err
+	}
+	if c >= len(cs.siz.comps) {
+		return 0, fmt.Errorf("jpeg2000: invalid component index %d", c)
+	}
+	return c, nil
+}
+
+// parseParam parses a coding parameter marker segment into `p`.
+func (cs *codestream) parseParam(p *tileParams, m int, seg []byte) error {
+	r := &reader{data: seg}
+	switch m {
+	case markerCOD:
+		scod, err := r.u8()
+		if err != nil {
+			return err
+		}
+		prog, err := r.u8()
+		if err != nil {
+			return err
+		}
+		layers, err := r.u16()
+		if err != nil {
+			return err
+		}
+		mct, err := r.u8()
+		if err != nil {
+			return err
+		}
+		cod := codingStyle{
+			progression: prog,
+			numLayers:   layers,
+			mct:         mct != 0,
+			sop:         scod&0x02 != 0,
+			eph:         scod&0x04 != 0,
+		}
+		if err := parseSPcod(r, &cod, scod&0x01 != 0); err != nil {
+			return err
+		}
+		if cod.progression > progressionCPRL || cod.numLayers == 0 {
+			return errInvalidCodestream
+		}
+		p.cod = cod
+		p.hasCOD = true
+		// A COD marker segment overrides the COC marker segments of the main header in tiles.
+		for i := range p.coc {
+			if p != &cs.main && p.coc[i] == cs.main.coc[i] {
+				p.coc[i] = nil
+			}
+		}
+	case markerCOC:
+		c, err := cs.componentIndex(r)
+		if err != nil {
+			return err
+		}
+		scoc, err := r.u8()
+		if err != nil {
+			return err
+		}
+		coc := p.cod
+		if err := parseSPcod(r, &coc, scoc&0x01 != 0); err != nil {
+			return err
+		}
+		p.coc[c] = &coc
+	case markerQCD:
+		q, err := parseQuantization(r)
+		if err != nil {
+			return err
+		}
+		p.qcd = *q
+		p.hasQCD = true
+		for i := range p.qcc {
+			if p != &cs.main && p.qcc[i] == cs.main.qcc[i] {
+				p.qcc[i] = nil
+			}
+		}
+	case markerQCC:
+		c, err := cs.componentIndex(r)
+		if err != nil {
+			return err
+		}
+		q, err := parseQuantization(r)
+		if err != nil {
+			return err
+		}
+		p.qcc[c] = q
+	case markerRGN:
+		c, err := cs.componentIndex(r)
+		if err != nil {
+			return err
+		}
+		if style, err := r.u8(); err != nil {
+			return err
+		} else if style != 0 {
+			return errors.New("jpeg2000: unsupported region of interest style")
+		}
+		shift, err := r.u8()
+		if err != nil {
+			return err
+		}
+		p.roi[c] = shift
+	case markerPOC:
+		return errors.New("jpeg2000: progression order changes are not supported")
+	case markerPPT, markerPPM:
+		return errInvalidCodestream
+	}
+	// Other marker segments (TLM, PLM, PLT, CRG, COM...) are informational.
+	return nil
+}
+
+// parseSPcod parses the SPcod or SPcoc parameters (Table A.15) into `cs`.
+func parseSPcod(r *reader, cs *codingStyle, hasPrecincts bool) error {
+	var v [5]int
+	for i := range v {
+		x, err := r.u8()
+		if err != nil {
+			return err
+		}
+		v[i] = x
+	}
+	cs.levels = v[0]
+	cs.xcb, cs.ycb = v[1]+2, v[2]+2
+	cs.cblkStyle = v[3]
+	cs.reversible = v[4] == 1
+	if cs.levels > 32 || cs.xcb > 10 || cs.ycb > 10 || cs.xcb+cs.ycb > 12 {
+		return errors.New("jpeg2000: invalid coding style")
+	}
+	cs.hasPrecinct = hasPrecincts
+	cs.precincts = nil
+	if hasPrecincts {
+		for i := 0; i <= cs.levels; i++ {
+			pp, err := r.u8()
+			if err != nil {
+				return err
+			}
+			cs.precincts = append(cs.precincts, [2]int{pp & 0x0F, pp >> 4})
+		}
+	}
+	return nil
+}
+
+// parseQuantization parses the Sqcx and SPqcx parameters (Table A.27).
+func parseQuantization(r *reader) (*quantization, error) {
+	sq, err := r.u8()
+	if err != nil {
+		return nil, err
+	}
+	q := &quantization{style: sq & 0x1F, guardBits: sq >> 5}
+	switch q.style {
+	case quantNone:
+		for r.pos < len(r.data) {
+			v, _ := r.u8()
+			q.exponents = append(q.exponents, v>>3)
+			q.mantissas = append(q.mantissas, 0)
+		}
+	case quantScalarDerived, quantScalarExpounded:
+		for r.pos+1 < len(r.data) {
+			v, _ := r.u16()
+			q.exponents = append(q.exponents, v>>11)
+			q.mantissas = append(q.mantissas, v&0x7FF)
+			if q.style == quantScalarDerived {
+				break
+			}
+		}
+	default:
+		return nil, errors.New("jpeg2000: invalid quantization style")
+	}
+	if len(q.exponents) == 0 {
+		return nil, errors.New("jpeg2000: missing quantization step sizes")
+	}
+	return q, nil
+}
+
+// ceilDiv returns ceil(a/b) for b > 0.
+func ceilDiv(a, b int) int {
+	if a >= 0 {
+		return (a + b - 1) / b
+	}
+	return -((-a) / b)
+}
+
+// ceilDivPow2 returns ceil(a/2^n).
+func ceilDivPow2(a, n int) int {
+	return ceilDiv(a, 1<<uint(n))
+}
+
+// floorDivPow2 returns floor(a/2^n).
+func floorDivPow2(a, n int) int {
+	return a >> uint(n)
+}
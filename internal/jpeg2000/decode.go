@@ -0,0 +1,451 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpeg2000
+
+import (
+	"errors"
+	"math"
+)
+
+// Image is a decoded image. The samples of the color components are interleaved, each row
+// starting at a byte boundary, and 16 bit samples are stored big-endian.
+type Image struct {
+	Config
+	Data []byte
+}
+
+// Config is the format of a decoded image.
+type Config struct {
+	Width            int
+	Height           int
+	ColorComponents  int
+	BitsPerComponent int
+	// ColorSpace is the colorspace of the image as specified by the JP2 header, or implied by
+	// the number of color components.
+	ColorSpace ColorSpace
+}
+
+// DecodeOptions are the options of the decoder.
+type DecodeOptions struct {
+	// ReduceResolution is the number of the highest resolution levels discarded, each one
+	// halving the width and the height of the decoded image. It is limited by the number of
+	// decomposition levels of the image.
+	ReduceResolution int
+}
+
+// channel is an output channel of the image.
+type channel struct {
+	comp      int // Codestream component.
+	column    int // Palette column, -1 if the component is used directly.
+	precision int
+	signed    bool
+}
+
+// format describes the output of the decoder.
+type format struct {
+	cs         *codestream
+	header     *jp2Header
+	channels   []channel
+	colorSpace ColorSpace
+	bpc        int
+	sycc       bool
+}
+
+// parse parses the headers of the JP2 file or codestream `data`.
+func parse(data []byte, headerOnly bool) (*format, error) {
+	f := &format{}
+	if isJP2(data) {
+		h, err := parseJP2(data)
+		if err != nil {
+			return nil, err
+		}
+		f.header = h
+		data = h.codestream
+	}
+	cs, err := parseCodestream(data, headerOnly)
+	if err != nil {
+		return nil, err
+	}
+	f.cs = cs
+
+	// Output channels.
+	h := f.header
+	comps := cs.siz.comps
+	if h != nil && h.palette != nil {
+		for _, m := range h.palette.mapping {
+			if m[0] >= len(comps) {
+				return nil, errors.New("jpeg2000: invalid component mapping")
+			}
+			ch := channel{comp: m[0], column: m[1], precision: comps[m[0]].precision, signed: comps[m[0]].signed}
+			if m[1] >= 0 {
+				ch.precision = h.palette.bits[m[1]]
+				ch.signed = h.palette.signed[m[1]]
+			}
+			f.channels = append(f.channels, ch)
+		}
+	} else {
+		for i, c := range comps {
+			f.channels = append(f.channels, channel{comp: i, column: -1, precision: c.precision, signed: c.signed})
+		}
+	}
+	if h != nil && len(h.channels) > 0 {
+		// Only the color channels are output, in the order of their association.
+		color := make([]channel, len(f.channels))
+		n := 0
+		for _, def := range h.channels {
+			if def.typ != 0 || def.channel >= len(f.channels) {
+				continue
+			}
+			i := def.assoc - 1
+			if def.assoc == 0 || def.assoc == 65535 || i >= len(color) {
+				i = n
+			}
+			color[i] = f.channels[def.channel]
+			if i+1 > n {
+				n = i + 1
+			}
+		}
+		if n > 0 {
+			f.channels = color[:n]
+		}
+	}
+
+	// Colorspace.
+	if h != nil && !h.iccProfile {
+		switch h.colorSpace {
+		case enumSRGB, enumEsRGB, enumROMM:
+			f.colorSpace = ColorSpaceRGB
+		case enumGray:
+			f.colorSpace = ColorSpaceGray
+		case enumCMYK:
+			f.colorSpace = ColorSpaceCMYK
+		case enumSYCC:
+			f.colorSpace = ColorSpaceRGB
+			f.sycc = len(f.channels) == 3
+		}
+	}
+	if f.colorSpace == ColorSpaceUnknown {
+		switch len(f.channels) {
+		case 1:
+			f.colorSpace = ColorSpaceGray
+		case 3:
+			f.colorSpace = ColorSpaceRGB
+		case 4:
+			f.colorSpace = ColorSpaceCMYK
+		}
+	}
+
+	// Bits per component of the output.
+	maxPrecision := 0
+	same := true
+	for _, ch := range f.channels {
+		if ch.precision > maxPrecision {
+			maxPrecision = ch.precision
+		}
+		same = same && ch.precision == f.channels[0].precision
+	}
+	switch {
+	case same && (maxPrecision == 1 || maxPrecision == 2 || maxPrecision == 4):
+		f.bpc = maxPrecision
+	case maxPrecision <= 8:
+		f.bpc = 8
+	default:
+		f.bpc = 16
+	}
+	return f, nil
+}
+
+// size returns the size of the image with `reduce` resolution levels discarded.
+func (f *format) size(reduce int) (int, int) {
+	s := &f.cs.siz
+	return ceilDivPow2(s.xsiz, reduce) - ceilDivPow2(s.x0, reduce),
+		ceilDivPow2(s.ysiz, reduce) - ceilDivPow2(s.y0, reduce)
+}
+
+// config returns the configuration of the image with `reduce` resolution levels discarded.
+func (f *format) config(reduce int) Config {
+	w, h := f.size(reduce)
+	return Config{
+		Width:            w,
+		Height:           h,
+		ColorComponents:  len(f.channels),
+		BitsPerComponent: f.bpc,
+		ColorSpace:       f.colorSpace,
+	}
+}
+
+// DecodeConfig returns the format of the JPEG 2000 image `data`, a JP2 file or a codestream,
+// parsing only its headers.
+func DecodeConfig(data []byte) (*Config, error) {
+	f, err := parse(data, true)
+	if err != nil {
+		return nil, err
+	}
+	cfg := f.config(0)
+	return &cfg, nil
+}
+
+// Decode decodes the JPEG 2000 image `data`, a JP2 file or a codestream.
+func Decode(data []byte, opts *DecodeOptions) (*Image, error) {
+	if opts == nil {
+		opts = &DecodeOptions{}
+	}
+	f, err := parse(data, false)
+	if err != nil {
+		return nil, err
+	}
+	cs := f.cs
+	s := &cs.siz
+
+	// The reduction is limited by the number of decomposition levels of all the tile-components.
+	reduce := opts.ReduceResolution
+	if reduce < 0 {
+		reduce = 0
+	}
+	for _, td := range cs.tiles {
+		if td == nil {
+			continue
+		}
+		for c := range s.comps {
+			cstyle, _ := td.params.component(c)
+			reduce = minInt(reduce, cstyle.levels)
+		}
+	}
+
+	// Components of the image.
+	planes := make([]*plane, len(s.comps))
+	for c, comp := range s.comps {
+		p := &plane{
+			x0: ceilDivPow2(ceilDiv(s.x0, comp.dx), reduce),
+			y0: ceilDivPow2(ceilDiv(s.y0, comp.dy), reduce),
+			x1: ceilDivPow2(ceilDiv(s.xsiz, comp.dx), reduce),
+			y1: ceilDivPow2(ceilDiv(s.ysiz, comp.dy), reduce),
+		}
+		p.data = make([]float32, p.width()*p.height())
+		// Missing tiles are mid-gray.
+		if !comp.signed {
+			mid := float32(int(1) << uint(comp.precision-1))
+			for i := range p.data {
+				p.data[i] = mid
+			}
+		}
+		planes[c] = p
+	}
+
+	t1 := &t1Decoder{}
+	for i, td := range cs.tiles {
+		if td == nil {
+			continue
+		}
+		t, err := newTile(cs, i, td.params)
+		if err != nil {
+			return nil, err
+		}
+		if err := t.decodePackets(td); err != nil {
+			return nil, err
+		}
+		t.decode(t1, reduce, planes)
+	}
+	return f.output(planes, reduce), nil
+}
+
+// decode decodes the tile into the component planes `planes`, discarding `reduce` resolution
+// levels.
+func (t *tile) decode(t1 *t1Decoder, reduce int, planes []*plane) {
+	var tcPlanes []*plane
+	for _, tc := range t.comps {
+		numRes := len(tc.resolutions) - reduce
+		for _, res := range tc.resolutions[:numRes] {
+			for _, b := range res.bands {
+				b.decode(t1, tc)
+			}
+		}
+		ll := tc.resolutions[0].bands[0]
+		p := &plane{x0: ll.x0, y0: ll.y0, x1: ll.x1, y1: ll.y1, data: ll.data}
+		for _, res := range tc.resolutions[1:numRes] {
+			p = synthesize(p, res, tc.cs.reversible)
+		}
+		tcPlanes = append(tcPlanes, p)
+	}
+
+	// Inverse multiple component transformation (Annex G).
+	if t.params.cod.mct && len(tcPlanes) >= 3 &&
+		len(tcPlanes[0].data) == len(tcPlanes[1].data) && len(tcPlanes[0].data) == len(tcPlanes[2].data) {
+		y0, y1, y2 := tcPlanes[0].data, tcPlanes[1].data, tcPlanes[2].data
+		if t.comps[0].cs.reversible {
+			// Inverse RCT (G-6).
+			for i := range y0 {
+				g := y0[i] - floor32((y1[i]+y2[i])/4)
+				y0[i], y1[i], y2[i] = y2[i]+g, g, y1[i]+g
+			}
+		} else {
+			// Inverse ICT (G-9).
+			for i := range y0 {
+				y, cb, cr := y0[i], y1[i], y2[i]
+				y0[i] = y + 1.402*cr
+				y1[i] = y - 0.34413*cb - 0.71414*cr
+				y2[i] = y + 1.772*cb
+			}
+		}
+	}
+
+	// DC level shifting (G.1.2) and storage in the component planes.
+	for c, tc := range t.comps {
+		src, dst := tcPlanes[c], planes[c]
+		var shift, min, max float32
+		if tc.signed {
+			min = -float32(int64(1) << uint(tc.precision-1))
+			max = -min - 1
+		} else {
+			shift = float32(int64(1) << uint(tc.precision-1))
+			max = float32(int64(1)<<uint(tc.precision) - 1)
+		}
+		w := src.width()
+		for y := maxInt(src.y0, dst.y0); y < minInt(src.y1, dst.y1); y++ {
+			for x := maxInt(src.x0, dst.x0); x < minInt(src.x1, dst.x1); x++ {
+				v := src.data[(y-src.y0)*w+x-src.x0] + shift
+				if !tc.cs.reversible {
+					v = float32(math.Floor(float64(v) + 0.5))
+				}
+				if v < min {
+					v = min
+				} else if v > max {
+					v = max
+				}
+				dst.data[(y-dst.y0)*dst.width()+x-dst.x0] = v
+			}
+		}
+	}
+}
+
+// decode decodes the code-blocks of the band of the tile-component `tc`, and dequantizes the
+// coefficients (E.1).
+func (b *band) decode(t1 *t1Decoder, tc *tileComponent) {
+	w, h := b.x1-b.x0, b.y1-b.y0
+	if w <= 0 || h <= 0 {
+		return
+	}
+	b.coeffs = make([]int32, w*h)
+	for _, prec := range b.precincts {
+		for _, cb := range prec.blocks {
+			if cb.numPasses > 0 {
+				t1.decodeBlock(cb, b, tc.cs.cblkStyle, tc.roi)
+			}
+		}
+	}
+	b.data = make([]float32, w*h)
+	if tc.q.style == quantNone {
+		for i, v := range b.coeffs {
+			b.data[i] = float32(v / 2)
+		}
+	} else {
+		step := b.step / 2
+		for i, v := range b.coeffs {
+			b.data[i] = float32(v) * step
+		}
+	}
+	b.coeffs = nil
+}
+
+// output builds the decoded image from the component planes.
+func (f *format) output(planes []*plane, reduce int) *Image {
+	s := &f.cs.siz
+	img := &Image{Config: f.config(reduce)}
+	w, h := img.Width, img.Height
+	x0, y0 := ceilDivPow2(s.x0, reduce), ceilDivPow2(s.y0, reduce)
+
+	// Sample values of the channels, upsampled to the image size.
+	n := len(f.channels)
+	values := make([][]float32, n)
+	for i, ch := range f.channels {
+		p := planes[ch.comp]
+		comp := s.comps[ch.comp]
+		v := make([]float32, w*h)
+		for y := 0; y < h; y++ {
+			py := clampInt((y0+y)/comp.dy, p.y0, p.y1-1) - p.y0
+			for x := 0; x < w; x++ {
+				px := clampInt((x0+x)/comp.dx, p.x0, p.x1-1) - p.x0
+				sample := p.data[py*p.width()+px]
+				if ch.column >= 0 {
+					entries := f.header.palette.entries[ch.column]
+					sample = float32(entries[clampInt(int(sample), 0, len(entries)-1)])
+				} else if ch.signed {
+					sample += float32(int64(1) << uint(ch.precision-1))
+				}
+				v[y*w+x] = sample
+			}
+		}
+		values[i] = v
+	}
+
+	if f.sycc {
+		// sYCC to sRGB conversion.
+		ps := [3]float32{}
+		for i := range ps {
+			ps[i] = float32(int64(1)<<uint(f.channels[i].precision) - 1)
+		}
+		half := float32(int64(1) << uint(f.channels[0].precision-1))
+		for i := 0; i < w*h; i++ {
+			y, cb, cr := values[0][i], values[1][i]-half, values[2][i]-half
+			rgb := [3]float32{y + 1.402*cr, y - 0.344136*cb - 0.714136*cr, y + 1.772*cb}
+			for c := range rgb {
+				values[c][i] = float32(math.Floor(float64(clamp32(rgb[c], 0, ps[c])) + 0.5))
+			}
+		}
+	}
+
+	// Packing.
+	bpc := f.bpc
+	maxOut := float64(int64(1)<<uint(bpc) - 1)
+	rowBytes := (w*n*bpc + 7) / 8
+	img.Data = make([]byte, rowBytes*h)
+	for c, ch := range f.channels {
+		maxIn := float64(int64(1)<<uint(ch.precision) - 1)
+		scale := ch.precision != bpc
+		for y := 0; y < h; y++ {
+			row := img.Data[y*rowBytes:]
+			for x := 0; x < w; x++ {
+				v := values[c][y*w+x]
+				out := uint32(v)
+				if scale {
+					out = uint32(math.Floor(float64(v)*maxOut/maxIn + 0.5))
+				}
+				i := x*n + c
+				switch bpc {
+				case 16:
+					row[2*i] = byte(out >> 8)
+					row[2*i+1] = byte(out)
+				case 8:
+					row[i] = byte(out)
+				default:
+					bit := i * bpc
+					row[bit/8] |= byte(out << uint(8-bpc-bit%8))
+				}
+			}
+		}
+	}
+	return img
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func clamp32(v, min, max float32) float32 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,133 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpeg2000
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readTestFile(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return data
+}
+
+// TestDecode decodes JPEG 2000 images and compares the decoded samples with the reference
+// samples of the images. The lossless images are decoded exactly.
+func TestDecode(t *testing.T) {
+	testcases := []struct {
+		name      string
+		reference string
+		config    Config
+		tolerance int
+		reduce    int
+	}{
+		{
+			// Reversible transform, 3 decomposition levels, several code-blocks.
+			name:      "gray.j2k",
+			reference: "gray.raw",
+			config:    Config{Width: 37, Height: 29, ColorComponents: 1, BitsPerComponent: 8, ColorSpace: ColorSpaceGray},
+		},
+		{
+			// Reversible color transform, 6 tiles, precincts, 2 quality layers, RPCL progression,
+			// SOP and EPH markers and arithmetic coding bypass.
+			name:      "rgb.jp2",
+			reference: "rgb.raw",
+			config:    Config{Width: 40, Height: 30, ColorComponents: 3, BitsPerComponent: 8, ColorSpace: ColorSpaceRGB},
+		},
+		{
+			// Irreversible transform, image offset on the reference grid, PCRL progression, 2
+			// quality layers, all the passes terminated, context reset, vertically causal contexts
+			// and segmentation symbols.
+			name:      "gray_lossy.j2k",
+			reference: "gray_lossy.raw",
+			config:    Config{Width: 33, Height: 35, ColorComponents: 1, BitsPerComponent: 8, ColorSpace: ColorSpaceGray},
+			tolerance: 2,
+		},
+		{
+			// 12 bit CMYK, CPRL progression, packed packet headers and tiles offset from the image.
+			name:      "cmyk.jp2",
+			reference: "cmyk.raw",
+			config:    Config{Width: 17, Height: 11, ColorComponents: 4, BitsPerComponent: 16, ColorSpace: ColorSpaceCMYK},
+		},
+		{
+			// The highest resolution level discarded.
+			name:      "gray.j2k",
+			reference: "gray_reduce1.raw",
+			config:    Config{Width: 19, Height: 15, ColorComponents: 1, BitsPerComponent: 8, ColorSpace: ColorSpaceGray},
+			reduce:    1,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := readTestFile(t, tc.name)
+			ref := readTestFile(t, tc.reference)
+
+			if tc.reduce == 0 {
+				cfg, err := DecodeConfig(data)
+				require.NoError(t, err)
+				assert.Equal(t, tc.config, *cfg)
+			}
+
+			img, err := Decode(data, &DecodeOptions{ReduceResolution: tc.reduce})
+			require.NoError(t, err)
+			require.Equal(t, tc.config, img.Config)
+			if tc.config.BitsPerComponent == 16 {
+				// The 12 bit samples are scaled to 16 bits.
+				require.Len(t, img.Data, len(ref))
+				for i := 0; i < len(ref); i += 2 {
+					v := int(ref[i])<<8 | int(ref[i+1])
+					expected := (v*65535 + 2047) / 4095
+					got := int(img.Data[i])<<8 | int(img.Data[i+1])
+					require.Equal(t, expected, got, "sample %d", i/2)
+				}
+				return
+			}
+			require.Len(t, img.Data, len(ref))
+			maxDiff := 0
+			for i := range ref {
+				d := int(img.Data[i]) - int(ref[i])
+				if d < 0 {
+					d = -d
+				}
+				if d > maxDiff {
+					maxDiff = d
+				}
+			}
+			require.True(t, maxDiff <= tc.tolerance, "max difference %d", maxDiff)
+		})
+	}
+}
+
+// TestDecodeReduceLimit checks that the reduction of the resolution is limited by the number of
+// decomposition levels.
+func TestDecodeReduceLimit(t *testing.T) {
+	img, err := Decode(readTestFile(t, "rgb.jp2"), &DecodeOptions{ReduceResolution: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 10, img.Width)
+	assert.Equal(t, 8, img.Height)
+	assert.Len(t, img.Data, 10*8*3)
+}
+
+// TestDecodeInvalid checks that invalid and truncated data are handled.
+func TestDecodeInvalid(t *testing.T) {
+	_, err := Decode([]byte{0xFF, 0x4F, 0xFF, 0x51, 0x00}, nil)
+	require.Error(t, err)
+	_, err = Decode([]byte("not an image"), nil)
+	require.Error(t, err)
+
+	// Truncated codestreams are decoded with the available data.
+	data := readTestFile(t, "gray.j2k")
+	img, err := Decode(data[:len(data)*2/3], nil)
+	require.NoError(t, err)
+	assert.Equal(t, 37, img.Width)
+	assert.Len(t, img.Data, 37*29)
+}
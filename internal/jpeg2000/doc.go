@@ -0,0 +1,17 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package jpeg2000 implements a decoder of JPEG 2000 images, as used by the JPXDecode filter of
+// PDF documents. Both the raw codestreams and the codestreams wrapped in the JP2 file format are
+// supported.
+// All the comments reference the 'ISO/IEC 15444-1 Information technology - JPEG 2000 image coding
+// system: Core coding system' (ITU-T T.800) document.
+//
+// The decoder supports the features of the core coding system used in practice: any number of
+// tiles, components, quality layers and resolution levels, all the progression orders, precincts,
+// the code-block coding styles, the reversible (5-3) and irreversible (9-7) wavelet transforms,
+// the multiple component transformations, the packed packet headers and the region of interest
+// (max-shift) decoding. Progression order changes (POC) are not supported.
+package jpeg2000
@@ -0,0 +1,167 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpeg2000
+
+// Lifting parameters of the irreversible 9-7 wavelet transform (Table F.4).
+const (
+	dwtAlpha = -1.586134342059924
+	dwtBeta  = -0.052980118572961
+	dwtGamma = 0.882911075530934
+	dwtDelta = 0.443506852043971
+	dwtK     = 1.230174104914001
+)
+
+// plane is a two dimensional array of samples of the region [x0,x1)x[y0,y1).
+type plane struct {
+	x0, y0, x1, y1 int
+	data           []float32
+}
+
+func (p *plane) width() int {
+	return p.x1 - p.x0
+}
+
+func (p *plane) height() int {
+	return p.y1 - p.y0
+}
+
+// synthesize performs the 2D_SR procedure (F.3.2): composes the resolution level `res` from the
+// lower resolution level `ll` and the high-pass subbands of `res`.
+func synthesize(ll *plane, res *resolution, reversible bool) *plane {
+	out := &plane{x0: res.x0, y0: res.y0, x1: res.x1, y1: res.y1}
+	w, h := out.width(), out.height()
+	out.data = make([]float32, w*h)
+	if w == 0 || h == 0 {
+		return out
+	}
+
+	// 2D_INTERLEAVE (F.3.3).
+	planes := [4]*plane{ll}
+	for i, b := range res.bands {
+		planes[i+1] = &plane{x0: b.x0, y0: b.y0, x1: b.x1, y1: b.y1, data: b.data}
+	}
+	for y := out.y0; y < out.y1; y++ {
+		for x := out.x0; x < out.x1; x++ {
+			p := planes[(x&1)|(y&1)<<1]
+			bx, by := x>>1, y>>1
+			if p == nil {
+				continue
+			}
+			bx -= p.x0
+			by -= p.y0
+			if bx < 0 || by < 0 || bx >= p.width() || by >= p.height() {
+				continue
+			}
+			out.data[(y-out.y0)*w+x-out.x0] = p.data[by*p.width()+bx]
+		}
+	}
+
+	// HOR_SR (F.3.4) then VER_SR (F.3.5).
+	line := make([]float32, maxInt(w, h)+2*dwtExtension)
+	for y := 0; y < h; y++ {
+		row := out.data[y*w : (y+1)*w]
+		synthesize1D(row, line, out.x0, reversible)
+	}
+	col := make([]float32, h)
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			col[y] = out.data[y*w+x]
+		}
+		synthesize1D(col, line, out.y0, reversible)
+		for y := 0; y < h; y++ {
+			out.data[y*w+x] = col[y]
+		}
+	}
+	return out
+}
+
+// dwtExtension is the number of samples of the symmetric extension on each side of a signal.
+const dwtExtension = 4
+
+// synthesize1D performs the 1D_SR procedure (F.3.6) on the signal `x` starting at the coordinate
+// `i0`, using `buf` as a working buffer.
+func synthesize1D(x, buf []float32, i0 int, reversible bool) {
+	n := len(x)
+	if n == 1 {
+		if i0&1 == 1 {
+			x[0] /= 2
+		}
+		return
+	}
+	// 1D_EXTR (F.3.7): periodic symmetric extension.
+	e := dwtExtension
+	buf = buf[:n+2*e]
+	copy(buf[e:], x)
+	period := 2 * (n - 1)
+	for k := 1; k <= e; k++ {
+		buf[e-k] = buf[e+mirror(-k, period)]
+		buf[e+n-1+k] = buf[e+mirror(n-1+k, period)]
+	}
+	// Index of the sample i of the signal in `buf`.
+	at := func(i int) int {
+		return i - i0 + e
+	}
+	start, end := i0-e, i0+n+e
+	if reversible {
+		// 1D_FILTR_5-3R (F.3.8.1).
+		for i := even(start + 1); i+1 < end; i += 2 {
+			buf[at(i)] -= floor32((buf[at(i-1)] + buf[at(i+1)] + 2) / 4)
+		}
+		for i := even(start+1) + 1; i+1 < end; i += 2 {
+			buf[at(i)] += floor32((buf[at(i-1)] + buf[at(i+1)]) / 2)
+		}
+	} else {
+		// 1D_FILTR_9-7I (F.3.8.2).
+		for i := start; i < end; i++ {
+			if i&1 == 0 {
+				buf[at(i)] *= dwtK
+			} else {
+				buf[at(i)] *= 1 / dwtK
+			}
+		}
+		steps := []struct {
+			parity int
+			coeff  float32
+		}{{0, dwtDelta}, {1, dwtGamma}, {0, dwtBeta}, {1, dwtAlpha}}
+		for _, s := range steps {
+			i := even(start+1) + s.parity
+			for ; i+1 < end; i += 2 {
+				buf[at(i)] -= s.coeff * (buf[at(i-1)] + buf[at(i+1)])
+			}
+		}
+	}
+	copy(x, buf[e:e+n])
+}
+
+// mirror returns the index of the sample `i` of a signal periodically and symmetrically extended
+// with the period `period`.
+func mirror(i, period int) int {
+	i %= period
+	if i < 0 {
+		i += period
+	}
+	if i > period/2 {
+		i = period - i
+	}
+	return i
+}
+
+// even returns the smallest even number greater than or equal to `i`.
+func even(i int) int {
+	if i&1 != 0 {
+		return i + 1
+	}
+	return i
+}
+
+// floor32 returns the greatest integer value less than or equal to `v`.
+func floor32(v float32) float32 {
+	f := float32(int64(v))
+	if f > v {
+		f--
+	}
+	return f
+}
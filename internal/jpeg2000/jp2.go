@@ -0,0 +1,239 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpeg2000
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// ColorSpace is the colorspace of a decoded image.
+type ColorSpace int
+
+// Colorspaces of the decoded images.
+const (
+	// ColorSpaceUnknown is used when the colorspace is neither specified by the JP2 header nor
+	// implied by the number of components.
+	ColorSpaceUnknown ColorSpace = iota
+	ColorSpaceGray
+	ColorSpaceRGB
+	ColorSpaceCMYK
+)
+
+// Enumerated colorspaces of the JP2 colour specification box (I.5.3.3), including the ones of the
+// JPX extended file format (ISO/IEC 15444-2 M.11.7.3).
+const (
+	enumCMYK  = 12
+	enumSRGB  = 16
+	enumGray  = 17
+	enumSYCC  = 18
+	enumEsRGB = 20
+	enumROMM  = 21
+)
+
+// jp2Signature is the content of the JPEG 2000 signature box.
+var jp2Signature = []byte{0x0D, 0x0A, 0x87, 0x0A}
+
+// jp2Header holds the information of the JP2 boxes relevant to decoding.
+type jp2Header struct {
+	codestream []byte
+	colorSpace int  // Enumerated colorspace, 0 if not specified.
+	iccProfile bool // Colorspace is specified by an ICC profile.
+	palette    *palette
+	channels   []channelDef
+}
+
+// palette is the content of the palette box (I.5.3.4) and the component mapping box (I.5.3.5).
+type palette struct {
+	entries [][]int // Entries for each palette column.
+	bits    []int   // Bit depth of each palette column.
+	signed  []bool
+	// Mapping from the output channels to the codestream components and the palette columns.
+	mapping [][2]int // Component index and palette column (-1 for direct use).
+}
+
+// channelDef is an entry of the channel definition box (I.5.3.6).
+type channelDef struct {
+	channel int
+	typ     int
+	assoc   int
+}
+
+// isJP2 returns true if `data` starts with a JP2 signature box.
+func isJP2(data []byte) bool {
+	return len(data) >= 12 && binary.BigEndian.Uint32(data) == 12 &&
+		string(data[4:8]) == "jP  " && bytes.Equal(data[8:12], jp2Signature)
+}
+
+// box is a JP2 box.
+type box struct {
+	typ     string
+	content []byte
+}
+
+// readBoxes splits `data` into boxes (I.4).
+func readBoxes(data []byte) ([]box, error) {
+	var boxes []box
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, errUnexpectedEOF
+		}
+		length := uint64(binary.BigEndian.Uint32(data))
+		typ := string(data[4:8])
+		header := uint64(8)
+		switch length {
+		case 0:
+			// The box extends to the end of the data.
+			length = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, errUnexpectedEOF
+			}
+			length = binary.BigEndian.Uint64(data[8:])
+			header = 16
+		}
+		if length < header || length > uint64(len(data)) {
+			if typ != "jp2c" || length < header {
+				return nil, errors.New("jpeg2000: invalid box length")
+			}
+			// Truncated codestream.
+			length = uint64(len(data))
+		}
+		boxes = append(boxes, box{typ: typ, content: data[header:length]})
+		data = data[length:]
+	}
+	return boxes, nil
+}
+
+// parseJP2 parses the boxes of a JP2 file.
+func parseJP2(data []byte) (*jp2Header, error) {
+	boxes, err := readBoxes(data)
+	if err != nil {
+		return nil, err
+	}
+	h := &jp2Header{}
+	for _, b := range boxes {
+		switch b.typ {
+		case "jp2h":
+			if err := h.parseHeader(b.content); err != nil {
+				return nil, err
+			}
+		case "jp2c":
+			if h.codestream == nil {
+				h.codestream = b.content
+			}
+		}
+	}
+	if h.codestream == nil {
+		return nil, errors.New("jpeg2000: missing contiguous codestream box")
+	}
+	return h, nil
+}
+
+// parseHeader parses the boxes of the JP2 header box (I.5.3).
+func (h *jp2Header) parseHeader(data []byte) error {
+	boxes, err := readBoxes(data)
+	if err != nil {
+		return err
+	}
+	var pclr *palette
+	var cmap [][2]int
+	for _, b := range boxes {
+		switch b.typ {
+		case "colr":
+			if len(b.content) < 3 || h.colorSpace != 0 || h.iccProfile {
+				// Only the first colour specification box is used.
+				continue
+			}
+			switch b.content[0] {
+			case 1:
+				if len(b.content) < 7 {
+					return errors.New("jpeg2000: invalid colour specification box")
+				}
+				h.colorSpace = int(binary.BigEndian.Uint32(b.content[3:]))
+			case 2, 3:
+				h.iccProfile = true
+			}
+		case "pclr":
+			pclr, err = parsePalette(b.content)
+			if err != nil {
+				return err
+			}
+		case "cmap":
+			for i := 0; i+4 <= len(b.content); i += 4 {
+				c := int(binary.BigEndian.Uint16(b.content[i:]))
+				column := int(b.content[i+3])
+				if b.content[i+2] == 0 {
+					column = -1
+				}
+				cmap = append(cmap, [2]int{c, column})
+			}
+		case "cdef":
+			if len(b.content) < 2 {
+				return errors.New("jpeg2000: invalid channel definition box")
+			}
+			n := int(binary.BigEndian.Uint16(b.content))
+			if len(b.content) < 2+6*n {
+				return errors.New("jpeg2000: invalid channel definition box")
+			}
+			for i := 0; i < n; i++ {
+				e := b.content[2+6*i:]
+				h.channels = append(h.channels, channelDef{
+					channel: int(binary.BigEndian.Uint16(e)),
+					typ:     int(binary.BigEndian.Uint16(e[2:])),
+					assoc:   int(binary.BigEndian.Uint16(e[4:])),
+				})
+			}
+		}
+	}
+	if pclr != nil {
+		if cmap == nil {
+			return errors.New("jpeg2000: palette without component mapping")
+		}
+		for _, m := range cmap {
+			if m[1] >= len(pclr.bits) {
+				return errors.New("jpeg2000: invalid component mapping")
+			}
+		}
+		pclr.mapping = cmap
+		h.palette = pclr
+	}
+	return nil
+}
+
+// parsePalette parses the palette box (I.5.3.4).
+func parsePalette(data []byte) (*palette, error) {
+	if len(data) < 3 {
+		return nil, errors.New("jpeg2000: invalid palette box")
+	}
+	numEntries := int(binary.BigEndian.Uint16(data))
+	numColumns := int(data[2])
+	if numEntries == 0 || numEntries > 1024 || numColumns == 0 || len(data) < 3+numColumns {
+		return nil, errors.New("jpeg2000: invalid palette box")
+	}
+	p := &palette{entries: make([][]int, numColumns)}
+	for _, b := range data[3 : 3+numColumns] {
+		p.bits = append(p.bits, int(b&0x7F)+1)
+		p.signed = append(p.signed, b&0x80 != 0)
+	}
+	pos := 3 + numColumns
+	for i := 0; i < numEntries; i++ {
+		for j := 0; j < numColumns; j++ {
+			n := (p.bits[j] + 7) / 8
+			if pos+n > len(data) {
+				return nil, errors.New("jpeg2000: invalid palette box")
+			}
+			var v int
+			for k := 0; k < n; k++ {
+				v = v<<8 | int(data[pos+k])
+			}
+			pos += n
+			p.entries[j] = append(p.entries[j], v)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,175 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpeg2000
+
+// qeEntry is an entry of the probability estimation table of the MQ-coder (Table C.2).
+type qeEntry struct {
+	qe   uint32
+	nmps uint8
+	nlps uint8
+	swap bool
+}
+
+var qeTable = [47]qeEntry{
+	{0x5601, 1, 1, true}, {0x3401, 2, 6, false}, {0x1801, 3, 9, false}, {0x0AC1, 4, 12, false},
+	{0x0521, 5, 29, false}, {0x0221, 38, 33, false}, {0x5601, 7, 6, true}, {0x5401, 8, 14, false},
+	{0x4801, 9, 14, false}, {0x3801, 10, 14, false}, {0x3001, 11, 17, false}, {0x2401, 12, 18, false},
+	{0x1C01, 13, 20, false}, {0x1601, 29, 21, false}, {0x5601, 15, 14, true}, {0x5401, 16, 14, false},
+	{0x5101, 17, 15, false}, {0x4801, 18, 16, false}, {0x3801, 19, 17, false}, {0x3401, 20, 18, false},
+	{0x3001, 21, 19, false}, {0x2801, 22, 19, false}, {0x2401, 23, 20, false}, {0x2201, 24, 21, false},
+	{0x1C01, 25, 22, false}, {0x1801, 26, 23, false}, {0x1601, 27, 24, false}, {0x1401, 28, 25, false},
+	{0x1201, 29, 26, false}, {0x1101, 30, 27, false}, {0x0AC1, 31, 28, false}, {0x09C1, 32, 29, false},
+	{0x08A1, 33, 30, false}, {0x0521, 34, 31, false}, {0x0441, 35, 32, false}, {0x02A1, 36, 33, false},
+	{0x0221, 37, 34, false}, {0x0141, 38, 35, false}, {0x0111, 39, 36, false}, {0x0085, 40, 37, false},
+	{0x0049, 41, 38, false}, {0x0025, 42, 39, false}, {0x0015, 43, 40, false}, {0x0009, 44, 41, false},
+	{0x0005, 45, 42, false}, {0x0001, 45, 43, false}, {0x5601, 46, 46, false},
+}
+
+// mqContext is the state of a context of the MQ-coder: the index in the probability estimation
+// table and the sense of the more probable symbol.
+type mqContext struct {
+	index uint8
+	mps   uint8
+}
+
+// mqDecoder is the MQ arithmetic decoder (C.3).
+type mqDecoder struct {
+	data []byte
+	bp   int
+	a    uint32
+	c    uint32
+	ct   int
+}
+
+// byteAt returns the byte at position `i` of the data, bytes past the end of the data are
+// read as 0xFF.
+func (d *mqDecoder) byteAt(i int) uint32 {
+	if i < len(d.data) {
+		return uint32(d.data[i])
+	}
+	return 0xFF
+}
+
+// init initializes the decoder with the coded data `data` (INITDEC, C.3.5).
+func (d *mqDecoder) init(data []byte) {
+	d.data = data
+	d.bp = 0
+	d.c = d.byteAt(0) << 16
+	d.byteIn()
+	d.c <<= 7
+	d.ct -= 7
+	d.a = 0x8000
+}
+
+// byteIn reads the next byte of the coded data (BYTEIN, C.3.4).
+func (d *mqDecoder) byteIn() {
+	if d.byteAt(d.bp) == 0xFF {
+		if b := d.byteAt(d.bp + 1); b > 0x8F {
+			d.c += 0xFF00
+			d.ct = 8
+		} else {
+			d.bp++
+			d.c += b << 9
+			d.ct = 7
+		}
+	} else {
+		d.bp++
+		d.c += d.byteAt(d.bp) << 8
+		d.ct = 8
+	}
+}
+
+// decode decodes a decision in context `cx` (DECODE, C.3.2).
+func (d *mqDecoder) decode(cx *mqContext) int {
+	e := &qeTable[cx.index]
+	d.a -= e.qe
+	var bit uint8
+	if d.c>>16 < e.qe {
+		// LPS_EXCHANGE.
+		if d.a < e.qe {
+			bit = cx.mps
+			cx.index = e.nmps
+		} else {
+			bit = 1 - cx.mps
+			if e.swap {
+				cx.mps = 1 - cx.mps
+			}
+			cx.index = e.nlps
+		}
+		d.a = e.qe
+		d.renormalize()
+		return int(bit)
+	}
+	d.c -= e.qe << 16
+	if d.a&0x8000 != 0 {
+		return int(cx.mps)
+	}
+	// MPS_EXCHANGE.
+	if d.a < e.qe {
+		bit = 1 - cx.mps
+		if e.swap {
+			cx.mps = 1 - cx.mps
+		}
+		cx.index = e.nlps
+	} else {
+		bit = cx.mps
+		cx.index = e.nmps
+	}
+	d.renormalize()
+	return int(bit)
+}
+
+// renormalize is the RENORMD procedure (C.3.3).
+func (d *mqDecoder) renormalize() {
+	for {
+		if d.ct == 0 {
+			d.byteIn()
+		}
+		d.a <<= 1
+		d.c <<= 1
+		d.ct--
+		if d.a&0x8000 != 0 {
+			break
+		}
+	}
+}
+
+// rawDecoder reads the bits of the coding passes in raw mode, when the arithmetic coding bypass is
+// used (D.6).
+type rawDecoder struct {
+	data []byte
+	bp   int
+	c    uint32
+	ct   int
+}
+
+// init initializes the decoder with the coded data `data`.
+func (d *rawDecoder) init(data []byte) {
+	d.data = data
+	d.bp = 0
+	d.c = 0
+	d.ct = 0
+}
+
+// decode reads the next bit.
+func (d *rawDecoder) decode() int {
+	if d.ct == 0 {
+		if d.c == 0xFF {
+			// A bit is stuffed after each 0xFF byte.
+			d.ct = 7
+		} else {
+			d.ct = 8
+		}
+		if d.bp < len(d.data) {
+			d.c = uint32(d.data[d.bp])
+			d.bp++
+		} else {
+			d.c = 0xFF
+		}
+	}
+	d.ct--
+	return int(d.c>>uint(d.ct)) & 1
+}
@@ -0,0 +1,406 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpeg2000
+
+import (
+	"errors"
+	"sort"
+)
+
+// bitReader reads the bits of the packet headers, where a bit is stuffed after each 0xFF
+// byte (B.10.1).
+type bitReader struct {
+	data []byte
+	pos  int
+	buf  byte
+	ct   int
+}
+
+// readBit reads the next bit.
+func (br *bitReader) readBit() (int, error) {
+	if br.ct == 0 {
+		if br.pos >= len(br.data) {
+			return 0, errUnexpectedEOF
+		}
+		br.ct = 8
+		if br.buf == 0xFF {
+			br.ct = 7
+		}
+		br.buf = br.data[br.pos]
+		br.pos++
+	}
+	br.ct--
+	return int(br.buf>>uint(br.ct)) & 1, nil
+}
+
+// readBits reads `n` bits.
+func (br *bitReader) readBits(n int) (int, error) {
+	v := 0
+	for i := 0; i < n; i++ {
+		bit, err := br.readBit()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<1 | bit
+	}
+	return v, nil
+}
+
+// align skips the remaining bits of the packet header. A packet header ending with a 0xFF byte
+// is followed by a stuffed byte.
+func (br *bitReader) align() {
+	if br.buf == 0xFF && br.pos < len(br.data) {
+		br.pos++
+	}
+	br.buf = 0
+	br.ct = 0
+}
+
+// skipMarker skips the marker `marker` if it is present at the current position.
+func (br *bitReader) skipMarker(marker int, length int) {
+	d := br.data[br.pos:]
+	if len(d) >= 2 && int(d[0])<<8|int(d[1]) == marker {
+		br.pos += minInt(length, len(d))
+	}
+}
+
+// packet identifies a packet of a tile.
+type packet struct {
+	layer, res, comp, precinct int
+}
+
+// packets returns the packets of the tile `t` in the order of its progression (B.12).
+func (t *tile) packets() []packet {
+	cod := &t.params.cod
+	maxRes := 0
+	for _, tc := range t.comps {
+		maxRes = maxInt(maxRes, len(tc.resolutions))
+	}
+	var packets []packet
+	switch cod.progression {
+	case progressionLRCP:
+		for l := 0; l < cod.numLayers; l++ {
+			for r := 0; r < maxRes; r++ {
+				for c, tc := range t.comps {
+					if r >= len(tc.resolutions) {
+						continue
+					}
+					for p := 0; p < tc.resolutions[r].numPrecincts(); p++ {
+						packets = append(packets, packet{l, r, c, p})
+					}
+				}
+			}
+		}
+	case progressionRLCP:
+		for r := 0; r < maxRes; r++ {
+			for l := 0; l < cod.numLayers; l++ {
+				for c, tc := range t.comps {
+					if r >= len(tc.resolutions) {
+						continue
+					}
+					for p := 0; p < tc.resolutions[r].numPrecincts(); p++ {
+						packets = append(packets, packet{l, r, c, p})
+					}
+				}
+			}
+		}
+	default:
+		// The position based progressions visit the precincts in the order of their location on
+		// the reference grid, the first precinct of a row or column being located at the tile
+		// origin (B.12.1.3).
+		type position struct {
+			x, y, res, comp, precinct int
+		}
+		var positions []position
+		for c, tc := range t.comps {
+			nl := len(tc.resolutions) - 1
+			for r, res := range tc.resolutions {
+				for p := 0; p < res.numPrecincts(); p++ {
+					i, j := p%res.numPrecW, p/res.numPrecW
+					x := ((res.precX0 + i) << uint(res.ppx+nl-r)) * tc.dx
+					y := ((res.precY0 + j) << uint(res.ppy+nl-r)) * tc.dy
+					positions = append(positions, position{
+						x:        maxInt(x, t.x0),
+						y:        maxInt(y, t.y0),
+						res:      r,
+						comp:     c,
+						precinct: p,
+					})
+				}
+			}
+		}
+		var less func(a, b *position) bool
+		switch cod.progression {
+		case progressionRPCL:
+			less = func(a, b *position) bool {
+				if a.res != b.res {
+					return a.res < b.res
+				}
+				if a.y != b.y {
+					return a.y < b.y
+				}
+				if a.x != b.x {
+					return a.x < b.x
+				}
+				return a.comp < b.comp
+			}
+		case progressionPCRL:
+			less = func(a, b *position) bool {
+				if a.y != b.y {
+					return a.y < b.y
+				}
+				if a.x != b.x {
+					return a.x < b.x
+				}
+				if a.comp != b.comp {
+					return a.comp < b.comp
+				}
+				return a.res < b.res
+			}
+		default:
+			less = func(a, b *position) bool {
+				if a.comp != b.comp {
+					return a.comp < b.comp
+				}
+				if a.y != b.y {
+					return a.y < b.y
+				}
+				if a.x != b.x {
+					return a.x < b.x
+				}
+				return a.res < b.res
+			}
+		}
+		sort.SliceStable(positions, func(i, j int) bool {
+			return less(&positions[i], &positions[j])
+		})
+		for _, pos := range positions {
+			for l := 0; l < cod.numLayers; l++ {
+				packets = append(packets, packet{l, pos.res, pos.comp, pos.precinct})
+			}
+		}
+	}
+	return packets
+}
+
+// decodePackets reads the packets of the tile from the tile data `td`, and collects the coded
+// data of the code-blocks. Decoding stops at the end of the data for truncated codestreams.
+func (t *tile) decodePackets(td *tileData) error {
+	cod := &t.params.cod
+	data := &bitReader{data: td.data}
+	header := data
+	if td.hasPPx {
+		header = &bitReader{data: td.headers}
+	}
+	for _, pkt := range t.packets() {
+		if data.pos >= len(data.data) && !td.hasPPx {
+			break
+		}
+		if cod.sop {
+			data.skipMarker(markerSOP, 6)
+		}
+		err := t.decodePacket(pkt, header, data)
+		if err == errUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packetBlock is a code-block included in a packet, with the lengths of its codeword segments.
+type packetBlock struct {
+	block   *codeBlock
+	lengths []int
+	segs    []*segment
+}
+
+// decodePacket reads the packet `pkt`, its header from `header` and its body from `data` (B.9).
+func (t *tile) decodePacket(pkt packet, header, data *bitReader) error {
+	tc := t.comps[pkt.comp]
+	res := tc.resolutions[pkt.res]
+	cod := &t.params.cod
+
+	nonEmpty, err := header.readBit()
+	if err != nil {
+		return err
+	}
+	var included []packetBlock
+	if nonEmpty == 1 {
+		for _, b := range res.bands {
+			prec := b.precincts[pkt.precinct]
+			for i, cb := range prec.blocks {
+				pb, err := readBlockHeader(header, prec, i%maxInt(prec.cbw, 1), i/maxInt(prec.cbw, 1), cb,
+					pkt.layer, tc.cs.cblkStyle)
+				if err != nil {
+					return err
+				}
+				if pb != nil {
+					included = append(included, *pb)
+				}
+			}
+		}
+	}
+	header.align()
+	if cod.eph {
+		header.skipMarker(markerEPH, 2)
+	}
+
+	// Packet body.
+	for _, pb := range included {
+		for i, length := range pb.lengths {
+			end := data.pos + length
+			truncated := end > len(data.data)
+			if truncated {
+				end = len(data.data)
+			}
+			seg := pb.segs[i]
+			seg.data = append(seg.data, data.data[data.pos:end]...)
+			data.pos = end
+			if truncated {
+				return errUnexpectedEOF
+			}
+		}
+	}
+	return nil
+}
+
+// readBlockHeader reads the information of the code-block `cb` at (x, y) of the precinct `prec`
+// in the header of a packet of layer `layer`. Returns nil if the code-block is not included
+// in the packet.
+func readBlockHeader(br *bitReader, prec *precinct, x, y int, cb *codeBlock, layer int,
+	cblkStyle int) (*packetBlock, error) {
+	// Code-block inclusion.
+	var inc bool
+	var err error
+	if cb.included {
+		bit, err := br.readBit()
+		if err != nil {
+			return nil, err
+		}
+		inc = bit == 1
+	} else {
+		inc, err = prec.inclusion.decode(br, x, y, layer+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !inc {
+		return nil, nil
+	}
+	if !cb.included {
+		// Number of zero bit-planes.
+		for i := 1; ; i++ {
+			known, err := prec.zeroBitplanes.decode(br, x, y, i)
+			if err != nil {
+				return nil, err
+			}
+			if known {
+				break
+			}
+			if i > 64 {
+				return nil, errInvalidCodestream
+			}
+		}
+		cb.zeroBitplanes = prec.zeroBitplanes.value(x, y)
+		cb.included = true
+	}
+
+	// Number of coding passes (Table B.4).
+	numPasses, err := readNumPasses(br)
+	if err != nil {
+		return nil, err
+	}
+	// Lblock increment.
+	for {
+		bit, err := br.readBit()
+		if err != nil {
+			return nil, err
+		}
+		if bit == 0 {
+			break
+		}
+		cb.lblock++
+		if cb.lblock > 32 {
+			return nil, errInvalidCodestream
+		}
+	}
+
+	// Lengths of the codeword segments (B.10.7).
+	pb := &packetBlock{block: cb}
+	for numPasses > 0 {
+		var seg *segment
+		if n := len(cb.segments); n > 0 && cb.segments[n-1].numPasses < cb.segments[n-1].maxPasses {
+			seg = cb.segments[n-1]
+		} else {
+			seg = newSegment(len(cb.segments), cb.numPasses, cblkStyle)
+			cb.segments = append(cb.segments, seg)
+		}
+		passes := minInt(numPasses, seg.maxPasses-seg.numPasses)
+		length, err := br.readBits(cb.lblock + floorLog2(passes))
+		if err != nil {
+			return nil, err
+		}
+		seg.numPasses += passes
+		cb.numPasses += passes
+		numPasses -= passes
+		pb.lengths = append(pb.lengths, length)
+		pb.segs = append(pb.segs, seg)
+	}
+	return pb, nil
+}
+
+// readNumPasses reads the number of coding passes of a code-block included in a packet.
+func readNumPasses(br *bitReader) (int, error) {
+	steps := []struct{ bits, escape, base int }{{1, 1, 1}, {1, 1, 2}, {2, 3, 3}, {5, 31, 6}, {7, 128, 37}}
+	for _, s := range steps {
+		v, err := br.readBits(s.bits)
+		if err != nil {
+			return 0, err
+		}
+		if v != s.escape {
+			if s.bits == 1 {
+				return s.base, nil
+			}
+			return s.base + v, nil
+		}
+	}
+	return 0, errors.New("jpeg2000: invalid number of coding passes")
+}
+
+// newSegment returns the codeword segment of index `index` of a code-block, whose first coding
+// pass is `pass` (D.4.1).
+func newSegment(index, pass, cblkStyle int) *segment {
+	seg := &segment{maxPasses: 1 << 30}
+	switch {
+	case cblkStyle&cblkStyleTermAll != 0:
+		seg.maxPasses = 1
+	case cblkStyle&cblkStyleBypass != 0:
+		switch {
+		case index == 0:
+			seg.maxPasses = 10
+		case index%2 == 1:
+			// Significance propagation and magnitude refinement passes in raw mode.
+			seg.maxPasses = 2
+		default:
+			seg.maxPasses = 1
+		}
+	}
+	seg.raw = cblkStyle&cblkStyleBypass != 0 && pass >= 10 && passType(pass) != passCleanup
+	return seg
+}
+
+// floorLog2 returns floor(log2(n)) for n > 0.
+func floorLog2(n int) int {
+	l := 0
+	for n > 1 {
+		n >>= 1
+		l++
+	}
+	return l
+}
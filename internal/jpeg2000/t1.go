@@ -0,0 +1,422 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpeg2000
+
+// Coding passes (D.3).
+const (
+	passSignificance = iota
+	passRefinement
+	passCleanup
+)
+
+// passType returns the type of the coding pass `pass` of a code-block, the first pass being a
+// cleanup pass.
+func passType(pass int) int {
+	if pass == 0 {
+		return passCleanup
+	}
+	return (pass - 1) % 3
+}
+
+// Contexts of the coefficient bit modeling (Table D.7).
+const (
+	ctxZC      = 0  // Zero coding contexts 0 to 8.
+	ctxSC      = 9  // Sign coding contexts 9 to 13.
+	ctxMR      = 14 // Magnitude refinement contexts 14 to 16.
+	ctxRL      = 17 // Run-length context.
+	ctxUniform = 18 // Uniform context.
+	numCtx     = 19
+)
+
+// Flags of the coefficients.
+const (
+	flagSig     = 1 << iota // Significant.
+	flagNeg                 // Negative.
+	flagVisit               // Coded in the significance propagation pass of the bit-plane.
+	flagRefined             // Coded in a magnitude refinement pass.
+)
+
+// zcContexts are the zero coding contexts (Table D.1) for the LL and LH subbands, the HL subband
+// and the HH subband, indexed by the number of significant horizontal, vertical and diagonal
+// neighbours.
+var zcContexts = func() (t [3][3][3][5]uint8) {
+	for h := 0; h < 3; h++ {
+		for v := 0; v < 3; v++ {
+			for d := 0; d < 5; d++ {
+				t[0][h][v][d] = zcContext(h, v, d)
+				t[1][h][v][d] = zcContext(v, h, d)
+				hv := h + v
+				var ctx uint8
+				switch {
+				case d >= 3:
+					ctx = 8
+				case d == 2 && hv >= 1:
+					ctx = 7
+				case d == 2:
+					ctx = 6
+				case d == 1 && hv >= 2:
+					ctx = 5
+				case d == 1 && hv == 1:
+					ctx = 4
+				case d == 1:
+					ctx = 3
+				case hv >= 2:
+					ctx = 2
+				case hv == 1:
+					ctx = 1
+				}
+				t[2][h][v][d] = ctx
+			}
+		}
+	}
+	return t
+}()
+
+// zcContext returns the zero coding context of the LL and LH subbands.
+func zcContext(h, v, d int) uint8 {
+	switch {
+	case h == 2:
+		return 8
+	case h == 1 && v >= 1:
+		return 7
+	case h == 1 && d >= 1:
+		return 6
+	case h == 1:
+		return 5
+	case v == 2:
+		return 4
+	case v == 1:
+		return 3
+	case d >= 2:
+		return 2
+	case d == 1:
+		return 1
+	}
+	return 0
+}
+
+// t1Decoder decodes the coefficients of code-blocks (Annex D).
+type t1Decoder struct {
+	w, h     int
+	data     []int32 // Magnitudes of the coefficients, in units of half the bit-plane value.
+	flags    []uint8 // Flags of the coefficients, with a border of one coefficient.
+	stride   int
+	zc       *[3][3][5]uint8
+	style    int
+	contexts [numCtx]mqContext
+	mq       mqDecoder
+	raw      rawDecoder
+	isRaw    bool
+}
+
+// decodeBlock decodes the code-block `cb` of the band `b` into the coefficients of the band.
+// `roi` is the region of interest shift of the component.
+func (t1 *t1Decoder) decodeBlock(cb *codeBlock, b *band, style, roi int) {
+	w, h := cb.x1-cb.x0, cb.y1-cb.y0
+	if w <= 0 || h <= 0 {
+		return
+	}
+	t1.reset(w, h)
+	t1.style = style
+	switch b.orientation {
+	case bandHL:
+		t1.zc = &zcContexts[1]
+	case bandHH:
+		t1.zc = &zcContexts[2]
+	default:
+		t1.zc = &zcContexts[0]
+	}
+	t1.resetContexts()
+
+	plane := b.mb + roi - cb.zeroBitplanes - 1
+	pass := 0
+	for _, seg := range cb.segments {
+		t1.isRaw = seg.raw
+		if seg.raw {
+			t1.raw.init(seg.data)
+		} else {
+			t1.mq.init(seg.data)
+		}
+		for i := 0; i < seg.numPasses; i++ {
+			typ := passType(pass)
+			p := plane
+			if pass > 0 {
+				p = plane - 1 - (pass-1)/3
+			}
+			if p < 0 {
+				break
+			}
+			switch typ {
+			case passSignificance:
+				t1.significancePass(p)
+			case passRefinement:
+				t1.refinementPass(p)
+			case passCleanup:
+				t1.cleanupPass(p)
+			}
+			if style&cblkStyleReset != 0 {
+				t1.resetContexts()
+			}
+			pass++
+		}
+	}
+
+	// Store the coefficients in the band.
+	thresh := int32(2) << uint(roi)
+	bw := b.width()
+	for y := 0; y < h; y++ {
+		row := b.coeffs[(cb.y0-b.y0+y)*bw+cb.x0-b.x0:]
+		for x := 0; x < w; x++ {
+			v := t1.data[y*w+x]
+			if roi > 0 && v >= thresh {
+				// Max-shift region of interest (H.2.1).
+				v >>= uint(roi)
+			}
+			if t1.flags[(y+1)*t1.stride+x+1]&flagNeg != 0 {
+				v = -v
+			}
+			row[x] = v
+		}
+	}
+}
+
+// reset prepares the decoder for a code-block of `w`x`h` coefficients.
+func (t1 *t1Decoder) reset(w, h int) {
+	t1.w, t1.h = w, h
+	t1.stride = w + 2
+	n := w * h
+	if cap(t1.data) < n {
+		t1.data = make([]int32, n)
+	}
+	t1.data = t1.data[:n]
+	for i := range t1.data {
+		t1.data[i] = 0
+	}
+	n = (w + 2) * (h + 2)
+	if cap(t1.flags) < n {
+		t1.flags = make([]uint8, n)
+	}
+	t1.flags = t1.flags[:n]
+	for i := range t1.flags {
+		t1.flags[i] = 0
+	}
+}
+
+// resetContexts sets the contexts to their initial states (Table D.7).
+func (t1 *t1Decoder) resetContexts() {
+	for i := range t1.contexts {
+		t1.contexts[i] = mqContext{}
+	}
+	t1.contexts[ctxZC] = mqContext{index: 4}
+	t1.contexts[ctxRL] = mqContext{index: 3}
+	t1.contexts[ctxUniform] = mqContext{index: 46}
+}
+
+// decodeBit decodes a bit in context `ctx`, or reads a raw bit in bypass mode.
+func (t1 *t1Decoder) decodeBit(ctx int) int {
+	if t1.isRaw {
+		return t1.raw.decode()
+	}
+	return t1.mq.decode(&t1.contexts[ctx])
+}
+
+// sig returns 1 if the coefficient at flags index `i` is significant.
+func (t1 *t1Decoder) sig(i int) int {
+	return int(t1.flags[i] & flagSig)
+}
+
+// causal returns true if the coefficients below the coefficient at row `y` must be ignored, in
+// vertically causal mode at the last row of a stripe.
+func (t1 *t1Decoder) causal(y int) bool {
+	return t1.style&cblkStyleVCausal != 0 && y%4 == 3
+}
+
+// zeroContext returns the zero coding context of the coefficient (x, y).
+func (t1 *t1Decoder) zeroContext(x, y int) int {
+	s := t1.stride
+	i := (y+1)*s + x + 1
+	h := t1.sig(i-1) + t1.sig(i+1)
+	v := t1.sig(i - s)
+	d := t1.sig(i-s-1) + t1.sig(i-s+1)
+	if !t1.causal(y) {
+		v += t1.sig(i + s)
+		d += t1.sig(i+s-1) + t1.sig(i+s+1)
+	}
+	return int(t1.zc[h][v][d])
+}
+
+// contribution returns the sign contribution of the coefficient at flags index `i` (Table D.2).
+func (t1 *t1Decoder) contribution(i int) int {
+	f := t1.flags[i]
+	if f&flagSig == 0 {
+		return 0
+	}
+	if f&flagNeg != 0 {
+		return -1
+	}
+	return 1
+}
+
+// decodeSign decodes the sign of the coefficient (x, y) (D.3.2).
+func (t1 *t1Decoder) decodeSign(x, y int) bool {
+	if t1.isRaw {
+		return t1.raw.decode() == 1
+	}
+	s := t1.stride
+	i := (y+1)*s + x + 1
+	h := clampContribution(t1.contribution(i-1) + t1.contribution(i+1))
+	v := t1.contribution(i - s)
+	if !t1.causal(y) {
+		v += t1.contribution(i + s)
+	}
+	v = clampContribution(v)
+	// Table D.3.
+	if h < 0 || (h == 0 && v < 0) {
+		h, v = -h, -v
+		ctx := ctxSC + signContext(h, v)
+		return t1.mq.decode(&t1.contexts[ctx]) == 0
+	}
+	ctx := ctxSC + signContext(h, v)
+	return t1.mq.decode(&t1.contexts[ctx]) == 1
+}
+
+// signContext returns the offset of the sign coding context for the contributions h >= 0 and v.
+func signContext(h, v int) int {
+	if h == 0 {
+		return v
+	}
+	return 3 + v
+}
+
+func clampContribution(v int) int {
+	if v > 1 {
+		return 1
+	}
+	if v < -1 {
+		return -1
+	}
+	return v
+}
+
+// setSignificant makes the coefficient (x, y) significant in bit-plane `p`.
+func (t1 *t1Decoder) setSignificant(x, y, p int, negative bool) {
+	t1.data[y*t1.w+x] = 3 << uint(p)
+	f := &t1.flags[(y+1)*t1.stride+x+1]
+	*f |= flagSig
+	if negative {
+		*f |= flagNeg
+	}
+}
+
+// significancePass decodes a significance propagation pass in bit-plane `p` (D.3.1).
+func (t1 *t1Decoder) significancePass(p int) {
+	for y0 := 0; y0 < t1.h; y0 += 4 {
+		y1 := minInt(y0+4, t1.h)
+		for x := 0; x < t1.w; x++ {
+			for y := y0; y < y1; y++ {
+				f := &t1.flags[(y+1)*t1.stride+x+1]
+				if *f&flagSig != 0 {
+					continue
+				}
+				ctx := t1.zeroContext(x, y)
+				if ctx == 0 {
+					continue
+				}
+				if t1.decodeBit(ctxZC+ctx) == 1 {
+					t1.setSignificant(x, y, p, t1.decodeSign(x, y))
+				}
+				*f |= flagVisit
+			}
+		}
+	}
+}
+
+// refinementPass decodes a magnitude refinement pass in bit-plane `p` (D.3.3).
+func (t1 *t1Decoder) refinementPass(p int) {
+	s := t1.stride
+	for y0 := 0; y0 < t1.h; y0 += 4 {
+		y1 := minInt(y0+4, t1.h)
+		for x := 0; x < t1.w; x++ {
+			for y := y0; y < y1; y++ {
+				i := (y+1)*s + x + 1
+				f := &t1.flags[i]
+				if *f&(flagSig|flagVisit) != flagSig {
+					continue
+				}
+				// Table D.4.
+				ctx := ctxMR + 2
+				if *f&flagRefined == 0 {
+					ctx = ctxMR
+					n := t1.sig(i-1) + t1.sig(i+1) + t1.sig(i-s) + t1.sig(i-s-1) + t1.sig(i-s+1)
+					if !t1.causal(y) {
+						n += t1.sig(i+s) + t1.sig(i+s-1) + t1.sig(i+s+1)
+					}
+					if n > 0 {
+						ctx++
+					}
+				}
+				v := &t1.data[y*t1.w+x]
+				if t1.decodeBit(ctx) == 1 {
+					*v += 1 << uint(p)
+				} else {
+					*v -= 1 << uint(p)
+				}
+				*f |= flagRefined
+			}
+		}
+	}
+}
+
+// cleanupPass decodes a cleanup pass in bit-plane `p` (D.3.4).
+func (t1 *t1Decoder) cleanupPass(p int) {
+	for y0 := 0; y0 < t1.h; y0 += 4 {
+		y1 := minInt(y0+4, t1.h)
+		for x := 0; x < t1.w; x++ {
+			y := y0
+			if y1-y0 == 4 && t1.runLength(x, y0) {
+				// Run-length coding.
+				if t1.decodeBit(ctxRL) == 0 {
+					continue
+				}
+				r := t1.decodeBit(ctxUniform) << 1
+				r |= t1.decodeBit(ctxUniform)
+				y = y0 + r
+				t1.setSignificant(x, y, p, t1.decodeSign(x, y))
+				y++
+			}
+			for ; y < y1; y++ {
+				f := t1.flags[(y+1)*t1.stride+x+1]
+				if f&(flagSig|flagVisit) != 0 {
+					continue
+				}
+				if t1.decodeBit(ctxZC+t1.zeroContext(x, y)) == 1 {
+					t1.setSignificant(x, y, p, t1.decodeSign(x, y))
+				}
+			}
+		}
+	}
+	for i := range t1.flags {
+		t1.flags[i] &^= flagVisit
+	}
+	if t1.style&cblkStyleSegSymbol != 0 {
+		// Segmentation symbol.
+		for i := 0; i < 4; i++ {
+			t1.decodeBit(ctxUniform)
+		}
+	}
+}
+
+// runLength returns true if the column `x` of the stripe starting at row `y0` is coded in
+// run-length mode: its four coefficients are insignificant, not coded yet in the bit-plane and
+// have insignificant neighbours.
+func (t1 *t1Decoder) runLength(x, y0 int) bool {
+	for y := y0; y < y0+4; y++ {
+		if t1.flags[(y+1)*t1.stride+x+1]&(flagSig|flagVisit) != 0 || t1.zeroContext(x, y) != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpeg2000
+
+// tagTreeUnknown is the value of the tag tree nodes which are not decoded yet.
+const tagTreeUnknown = 1 << 30
+
+// tagTree is a tag tree (B.10.2), used to code the inclusion of the code-blocks in the quality
+// layers and their number of zero bit-planes.
+type tagTree struct {
+	nodes  []tagTreeNode
+	leaves int // Number of nodes of the first level, the leaves are the first nodes.
+	width  int
+}
+
+type tagTreeNode struct {
+	parent int // Index of the parent node, -1 for the root.
+	value  int
+	low    int // Lower bound of the value decoded so far.
+}
+
+// newTagTree returns a tag tree of `w`x`h` leaves.
+func newTagTree(w, h int) *tagTree {
+	t := &tagTree{leaves: w * h, width: w}
+	if w == 0 || h == 0 {
+		return t
+	}
+	start := 0
+	for {
+		n := w * h
+		nw, nh := (w+1)/2, (h+1)/2
+		for i := 0; i < n; i++ {
+			t.nodes = append(t.nodes, tagTreeNode{parent: -1, value: tagTreeUnknown})
+		}
+		if n == 1 {
+			break
+		}
+		next := start + n
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				t.nodes[start+y*w+x].parent = next + (y/2)*nw + x/2
+			}
+		}
+		start, w, h = next, nw, nh
+	}
+	return t
+}
+
+// decode decodes the value of the leaf (x, y) up to the threshold `threshold` with the bits read
+// from `br` and returns true if the value is less than the threshold.
+func (t *tagTree) decode(br *bitReader, x, y, threshold int) (bool, error) {
+	var stack [32]int
+	n := 0
+	node := y*t.width + x
+	for t.nodes[node].parent >= 0 {
+		stack[n] = node
+		n++
+		node = t.nodes[node].parent
+	}
+	low := 0
+	for {
+		nd := &t.nodes[node]
+		if low > nd.low {
+			nd.low = low
+		} else {
+			low = nd.low
+		}
+		for low < threshold && low < nd.value {
+			bit, err := br.readBit()
+			if err != nil {
+				return false, err
+			}
+			if bit == 1 {
+				nd.value = low
+			} else {
+				low++
+			}
+		}
+		nd.low = low
+		if n == 0 {
+			break
+		}
+		n--
+		node = stack[n]
+	}
+	return t.nodes[node].value < threshold, nil
+}
+
+// value returns the decoded value of the leaf (x, y).
+func (t *tagTree) value(x, y int) int {
+	return t.nodes[y*t.width+x].value
+}
@@ -0,0 +1,299 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpeg2000
+
+import (
+	"errors"
+)
+
+// Subband orientations.
+const (
+	bandLL = iota
+	bandHL
+	bandLH
+	bandHH
+)
+
+// tile is a tile of the image, partitioned into tile-components (B.3).
+type tile struct {
+	x0, y0, x1, y1 int
+	params         *tileParams
+	comps          []*tileComponent
+}
+
+// tileComponent is a component of a tile, partitioned into resolution levels (B.5).
+type tileComponent struct {
+	x0, y0, x1, y1 int
+	dx, dy         int
+	cs             *codingStyle
+	q              *quantization
+	roi            int
+	precision      int
+	signed         bool
+	resolutions    []*resolution
+}
+
+// resolution is a resolution level of a tile-component, partitioned into precincts (B.6).
+type resolution struct {
+	x0, y0, x1, y1 int
+	ppx, ppy       int // Precinct size exponents.
+	// Index of the first precinct in both directions, the precincts are anchored at the origin of
+	// the reference grid.
+	precX0, precY0     int
+	numPrecW, numPrecH int
+	bands              []*band
+}
+
+// numPrecincts returns the number of precincts of the resolution level.
+func (res *resolution) numPrecincts() int {
+	return res.numPrecW * res.numPrecH
+}
+
+// band is a subband of a resolution level, partitioned into code-blocks (B.7).
+type band struct {
+	x0, y0, x1, y1 int
+	orientation    int
+	level          int // Decomposition level.
+	mb             int // Maximum number of magnitude bit-planes (E-2).
+	step           float32
+	xcb, ycb       int // Code-block size exponents.
+	precincts      []*precinct
+	// Decoded coefficients, in units of half the quantization step.
+	coeffs []int32
+	// Dequantized coefficients.
+	data []float32
+}
+
+// width returns the width of the band.
+func (b *band) width() int {
+	return b.x1 - b.x0
+}
+
+// precinct is the part of a subband which belongs to a precinct.
+type precinct struct {
+	cbx0, cby0    int // Index of the first code-block of the precinct in the band.
+	cbw, cbh      int // Number of code-blocks in both directions.
+	blocks        []*codeBlock
+	inclusion     *tagTree
+	zeroBitplanes *tagTree
+}
+
+// codeBlock is a code-block of a subband.
+type codeBlock struct {
+	x0, y0, x1, y1 int
+	included       bool
+	lblock         int
+	zeroBitplanes  int
+	numPasses      int
+	segments       []*segment
+}
+
+// segment is a codeword segment of a code-block, the coded data of consecutive coding passes
+// terminated by the last of them (D.4.1).
+type segment struct {
+	data      []byte
+	numPasses int
+	maxPasses int
+	raw       bool // Arithmetic coding bypass.
+}
+
+// newTile builds the tile `index` of the codestream `cs` with its parameters `params`. The
+// resolution levels above `numRes` are not used for the output.
+func newTile(cs *codestream, index int, params *tileParams) (*tile, error) {
+	s := &cs.siz
+	numX, _ := s.numTiles()
+	p, q := index%numX, index/numX
+	t := &tile{
+		x0:     maxInt(s.xt0+p*s.xtsiz, s.x0),
+		y0:     maxInt(s.yt0+q*s.ytsiz, s.y0),
+		x1:     minInt(s.xt0+(p+1)*s.xtsiz, s.xsiz),
+		y1:     minInt(s.yt0+(q+1)*s.ytsiz, s.ysiz),
+		params: params,
+	}
+	for c, comp := range s.comps {
+		cstyle, quant := params.component(c)
+		tc := &tileComponent{
+			x0:        ceilDiv(t.x0, comp.dx),
+			y0:        ceilDiv(t.y0, comp.dy),
+			x1:        ceilDiv(t.x1, comp.dx),
+			y1:        ceilDiv(t.y1, comp.dy),
+			dx:        comp.dx,
+			dy:        comp.dy,
+			cs:        cstyle,
+			q:         quant,
+			roi:       params.roi[c],
+			precision: comp.precision,
+			signed:    comp.signed,
+		}
+		if err := tc.build(); err != nil {
+			return nil, err
+		}
+		t.comps = append(t.comps, tc)
+	}
+	return t, nil
+}
+
+// build partitions the tile-component into resolution levels, subbands, precincts and
+// code-blocks.
+func (tc *tileComponent) build() error {
+	cs := tc.cs
+	nl := cs.levels
+	for r := 0; r <= nl; r++ {
+		res := &resolution{
+			x0: ceilDivPow2(tc.x0, nl-r),
+			y0: ceilDivPow2(tc.y0, nl-r),
+			x1: ceilDivPow2(tc.x1, nl-r),
+			y1: ceilDivPow2(tc.y1, nl-r),
+		}
+		res.ppx, res.ppy = cs.precinctSize(r)
+		if r > 0 && (res.ppx == 0 || res.ppy == 0) {
+			return errors.New("jpeg2000: invalid precinct size")
+		}
+		if res.x1 > res.x0 && res.y1 > res.y0 {
+			res.precX0 = floorDivPow2(res.x0, res.ppx)
+			res.precY0 = floorDivPow2(res.y0, res.ppy)
+			res.numPrecW = ceilDivPow2(res.x1, res.ppx) - res.precX0
+			res.numPrecH = ceilDivPow2(res.y1, res.ppy) - res.precY0
+		}
+		if res.numPrecincts() > 1<<20 {
+			return errors.New("jpeg2000: too many precincts")
+		}
+
+		// Code-block size exponents (B-17).
+		xcb, ycb := minInt(cs.xcb, res.ppx), minInt(cs.ycb, res.ppy)
+		orientations := []int{bandLL}
+		if r > 0 {
+			xcb, ycb = minInt(cs.xcb, res.ppx-1), minInt(cs.ycb, res.ppy-1)
+			orientations = []int{bandHL, bandLH, bandHH}
+		}
+		for _, o := range orientations {
+			b := &band{orientation: o, xcb: xcb, ycb: ycb}
+			// Subband bounds (B-15).
+			var xob, yob int
+			if o == bandHL || o == bandHH {
+				xob = 1
+			}
+			if o == bandLH || o == bandHH {
+				yob = 1
+			}
+			if r == 0 {
+				b.level = nl
+			} else {
+				b.level = nl - r + 1
+			}
+			n := b.level
+			if n == 0 {
+				b.x0, b.y0, b.x1, b.y1 = tc.x0, tc.y0, tc.x1, tc.y1
+			} else {
+				offX, offY := xob<<uint(n-1), yob<<uint(n-1)
+				b.x0 = ceilDivPow2(tc.x0-offX, n)
+				b.y0 = ceilDivPow2(tc.y0-offY, n)
+				b.x1 = ceilDivPow2(tc.x1-offX, n)
+				b.y1 = ceilDivPow2(tc.y1-offY, n)
+			}
+			if err := tc.quantize(b, r); err != nil {
+				return err
+			}
+			tc.buildPrecincts(res, b, r)
+			res.bands = append(res.bands, b)
+		}
+		tc.resolutions = append(tc.resolutions, res)
+	}
+	return nil
+}
+
+// quantize sets the number of magnitude bit-planes and the quantization step of the band `b`
+// of resolution level `r` (E.1).
+func (tc *tileComponent) quantize(b *band, r int) error {
+	index := 0
+	if r > 0 {
+		index = 3*(r-1) + b.orientation
+	}
+	exp, mant := tc.q.stepSize(index, b.level, tc.cs.levels)
+	b.mb = tc.q.guardBits + exp - 1
+	if b.mb+tc.roi > 30 {
+		return errors.New("jpeg2000: unsupported number of bit-planes")
+	}
+	if tc.q.style == quantNone {
+		b.step = 1
+		return nil
+	}
+	// The dynamic range is increased by the gain of the subband (E-4).
+	gain := 0
+	switch b.orientation {
+	case bandHL, bandLH:
+		gain = 1
+	case bandHH:
+		gain = 2
+	}
+	rb := tc.precision + gain
+	b.step = float32(pow2(rb-exp) * (1 + float64(mant)/2048))
+	return nil
+}
+
+// buildPrecincts partitions the band `b` of the resolution level `res` into the precincts and
+// code-blocks.
+func (tc *tileComponent) buildPrecincts(res *resolution, b *band, r int) {
+	// Precinct size in the subband.
+	ppx, ppy := res.ppx, res.ppy
+	if r > 0 {
+		ppx--
+		ppy--
+	}
+	for j := 0; j < res.numPrecH; j++ {
+		for i := 0; i < res.numPrecW; i++ {
+			px0 := (res.precX0 + i) << uint(ppx)
+			py0 := (res.precY0 + j) << uint(ppy)
+			px0, px1 := maxInt(px0, b.x0), minInt(px0+1<<uint(ppx), b.x1)
+			py0, py1 := maxInt(py0, b.y0), minInt(py0+1<<uint(ppy), b.y1)
+			prec := &precinct{}
+			if px1 > px0 && py1 > py0 {
+				prec.cbx0 = floorDivPow2(px0, b.xcb)
+				prec.cby0 = floorDivPow2(py0, b.ycb)
+				prec.cbw = ceilDivPow2(px1, b.xcb) - prec.cbx0
+				prec.cbh = ceilDivPow2(py1, b.ycb) - prec.cby0
+			}
+			for y := 0; y < prec.cbh; y++ {
+				for x := 0; x < prec.cbw; x++ {
+					cx0 := (prec.cbx0 + x) << uint(b.xcb)
+					cy0 := (prec.cby0 + y) << uint(b.ycb)
+					prec.blocks = append(prec.blocks, &codeBlock{
+						x0:     maxInt(cx0, px0),
+						y0:     maxInt(cy0, py0),
+						x1:     minInt(cx0+1<<uint(b.xcb), px1),
+						y1:     minInt(cy0+1<<uint(b.ycb), py1),
+						lblock: 3,
+					})
+				}
+			}
+			prec.inclusion = newTagTree(prec.cbw, prec.cbh)
+			prec.zeroBitplanes = newTagTree(prec.cbw, prec.cbh)
+			b.precincts = append(b.precincts, prec)
+		}
+	}
+}
+
+// pow2 returns 2^n.
+func pow2(n int) float64 {
+	if n >= 0 {
+		return float64(uint64(1) << uint(n))
+	}
+	return 1 / float64(uint64(1)<<uint(-n))
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,56 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package pageutil provides functions used internally by the packages which add content to
+// existing pages.
+package pageutil
+
+import (
+	"fmt"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// GenerateFontName returns a font resource name unused in `resources`, made of `prefix`
+// followed by a number if `prefix` is used.
+func GenerateFontName(resources *model.PdfPageResources, prefix string) core.PdfObjectName {
+	name := core.PdfObjectName(prefix)
+	for i := 1; resources.HasFontByName(name); i++ {
+		name = core.PdfObjectName(fmt.Sprintf("%s%d", prefix, i))
+	}
+	return name
+}
+
+// DisplayMatrix returns the matrix mapping the coordinates of `box` as displayed, rotated
+// clockwise by `rotate` degrees with its lower left corner at the origin, to the coordinates
+// of the page.
+func DisplayMatrix(box model.PdfRectangle, rotate int64) transform.Matrix {
+	w, h := box.Width(), box.Height()
+	switch rotate {
+	case 90:
+		return transform.NewMatrix(0, 1, -1, 0, box.Llx+w, box.Lly)
+	case 180:
+		return transform.NewMatrix(-1, 0, 0, -1, box.Llx+w, box.Lly+h)
+	case 270:
+		return transform.NewMatrix(0, -1, 1, 0, box.Llx, box.Lly+h)
+	}
+	return transform.TranslationMatrix(box.Llx, box.Lly)
+}
+
+// AnnotationSubtype returns the subtype of `annot`, or an empty string if it is unknown.
+func AnnotationSubtype(annot *model.PdfAnnotation) string {
+	ctx := annot.GetContext()
+	if ctx == nil {
+		return ""
+	}
+	dict, ok := core.GetDict(ctx.ToPdfObject())
+	if !ok {
+		return ""
+	}
+	subtype, _ := core.GetNameVal(dict.Get("Subtype"))
+	return subtype
+}
@@ -0,0 +1,58 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package pageutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+func TestGenerateFontName(t *testing.T) {
+	resources := model.NewPdfPageResources()
+	require.Equal(t, core.PdfObjectName("F"), GenerateFontName(resources, "F"))
+
+	require.NoError(t, resources.SetFontByName("F", core.MakeDict()))
+	require.NoError(t, resources.SetFontByName("F1", core.MakeDict()))
+	require.Equal(t, core.PdfObjectName("F2"), GenerateFontName(resources, "F"))
+}
+
+// transformPoint returns the point (`x`, `y`) transformed by `m`.
+func transformPoint(m transform.Matrix, x, y float64) [2]float64 {
+	return [2]float64{x*m[0] + y*m[3] + m[6], x*m[1] + y*m[4] + m[7]}
+}
+
+func TestDisplayMatrix(t *testing.T) {
+	box := model.PdfRectangle{Llx: 10, Lly: 20, Urx: 110, Ury: 220}
+	testcases := []struct {
+		rotate   int64
+		corner   [2]float64 // Page coordinates of the displayed lower left corner.
+		opposite [2]float64 // Page coordinates of the displayed upper right corner.
+	}{
+		{0, [2]float64{10, 20}, [2]float64{110, 220}},
+		{90, [2]float64{110, 20}, [2]float64{10, 220}},
+		{180, [2]float64{110, 220}, [2]float64{10, 20}},
+		{270, [2]float64{10, 220}, [2]float64{110, 20}},
+	}
+	for _, tcase := range testcases {
+		m := DisplayMatrix(box, tcase.rotate)
+		w, h := box.Width(), box.Height()
+		if tcase.rotate%180 != 0 {
+			w, h = h, w
+		}
+		require.Equal(t, tcase.corner, transformPoint(m, 0, 0), "rotate=%d", tcase.rotate)
+		require.Equal(t, tcase.opposite, transformPoint(m, w, h), "rotate=%d", tcase.rotate)
+	}
+}
+
+func TestAnnotationSubtype(t *testing.T) {
+	require.Equal(t, "Text", AnnotationSubtype(model.NewPdfAnnotationText().PdfAnnotation))
+	require.Equal(t, "", AnnotationSubtype(model.NewPdfAnnotation()))
+}
@@ -0,0 +1,69 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package pdftest contains helpers for the tests which write documents and read them back.
+// The tests of package model can not use it, as it depends on model.
+package pdftest
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// Writer is implemented by the document writers, such as model.PdfWriter and creator.Creator.
+type Writer interface {
+	Write(w io.Writer) error
+}
+
+// Write returns the document written by `w`.
+func Write(t testing.TB, w Writer) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	return buf.Bytes()
+}
+
+// Read loads the document `data`.
+func Read(t testing.TB, data []byte) *model.PdfReader {
+	reader, err := model.NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	return reader
+}
+
+// WriteAndRead writes the document of `w` and reads it back.
+func WriteAndRead(t testing.TB, w Writer) *model.PdfReader {
+	return Read(t, Write(t, w))
+}
+
+// Pages returns the pages of `reader`.
+func Pages(t testing.TB, reader *model.PdfReader) []*model.PdfPage {
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	pages := make([]*model.PdfPage, 0, numPages)
+	for i := 1; i <= numPages; i++ {
+		page, err := reader.GetPage(i)
+		require.NoError(t, err)
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// FirstPage returns the first page of the document written by `w`, read back.
+func FirstPage(t testing.TB, w Writer) *model.PdfPage {
+	page, err := WriteAndRead(t, w).GetPage(1)
+	require.NoError(t, err)
+	return page
+}
+
+// ReloadPage writes `page` to a new document and returns it, read back.
+func ReloadPage(t testing.TB, page *model.PdfPage) *model.PdfPage {
+	w := model.NewPdfWriter()
+	require.NoError(t, w.AddPage(page))
+	return FirstPage(t, &w)
+}
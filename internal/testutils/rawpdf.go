@@ -0,0 +1,57 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package testutils
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// RawPdfOptions defines the optional parts of the PDF files built by BuildRawPdf.
+type RawPdfOptions struct {
+	// Header is the header of the file. "%PDF-1.7\n" is used if empty.
+	Header string
+
+	// TrailerEntries are extra entries of the trailer dictionary, e.g. "/Info 5 0 R".
+	TrailerEntries string
+
+	// OffsetShift is added to the offsets of the cross-reference table, to make it invalid.
+	OffsetShift int
+}
+
+// BuildRawPdf returns a PDF file made of the objects `objects`, numbered from 1, the first object
+// being the catalog. `opts` can be nil.
+func BuildRawPdf(objects []string, opts *RawPdfOptions) []byte {
+	if opts == nil {
+		opts = &RawPdfOptions{}
+	}
+	header := opts.Header
+	if header == "" {
+		header = "%PDF-1.7\n"
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f\r\n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n\r\n", offset+opts.OffsetShift)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R %s >>\nstartxref\n%d\n%%%%EOF\n",
+		len(objects)+1, opts.TrailerEntries, xref)
+	return buf.Bytes()
+}
+
+// RawStream returns a stream object with the data `data` and the extra dictionary entries
+// `entries`.
+func RawStream(entries string, data []byte) string {
+	return fmt.Sprintf("<< /Length %d %s >>\nstream\n%s\nendstream", len(data), entries, data)
+}
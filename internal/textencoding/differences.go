@@ -87,7 +87,7 @@ func ApplyDifferences(base SimpleEncoder, differences map[CharCode]GlyphName) Si
 		if ok {
 			d.encode[r] = b
 		} else {
-			common.Log.Debug("ERROR: No match for glyph=%q differences=%+v", glyph, differences)
+			logger.Debug("unknown glyph in encoding differences", "glyph", string(glyph), "code", code)
 		}
 		d.decode[b] = r
 	}
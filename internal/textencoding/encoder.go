@@ -7,6 +7,7 @@ package textencoding
 
 import (
 	"encoding/binary"
+	"fmt"
 
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
@@ -43,20 +44,27 @@ type TextEncoder interface {
 	ToPdfObject() core.PdfObject
 }
 
+// logger is the logger of the encoders, writing to the global common.Log. The messages logged for
+// each character, e.g. when encoding or decoding text, are aggregated per string or logged once
+// per encoder, so that text in unsupported characters does not flood the logs.
+var logger = common.NewLogAdapter(nil)
+
 // Convenience functions
 
 // encodeString8bit converts a Go unicode string `raw` to a PDF encoded string using the encoder `enc`.
 // It expects that character codes will fit into a single byte.
 func encodeString8bit(enc TextEncoder, raw string) []byte {
 	encoded := make([]byte, 0, len(raw))
+	var misses missingRunes
 	for _, r := range raw {
 		code, found := enc.RuneToCharcode(r)
 		if !found || code > 0xff {
-			common.Log.Debug("Failed to map rune to charcode for rune 0x%04x", r)
+			misses.add(r)
 			continue
 		}
 		encoded = append(encoded, byte(code))
 	}
+	misses.log(enc)
 	return encoded
 }
 
@@ -66,10 +74,11 @@ func encodeString16bit(enc TextEncoder, raw string) []byte {
 	// runes -> character codes -> bytes
 	runes := []rune(raw)
 	encoded := make([]byte, 0, len(runes)*2)
+	var misses missingRunes
 	for _, r := range runes {
 		code, ok := enc.RuneToCharcode(r)
 		if !ok {
-			common.Log.Debug("Failed to map rune to charcode. rune=%+q", r)
+			misses.add(r)
 			continue
 		}
 
@@ -78,6 +87,7 @@ func encodeString16bit(enc TextEncoder, raw string) []byte {
 		binary.BigEndian.PutUint16(v[:], uint16(code))
 		encoded = append(encoded, v[:]...)
 	}
+	misses.log(enc)
 	return encoded
 }
 
@@ -86,6 +96,8 @@ func encodeString16bit(enc TextEncoder, raw string) []byte {
 func decodeString16bit(enc TextEncoder, raw []byte) string {
 	// bytes -> character codes -> runes
 	runes := make([]rune, 0, len(raw)/2+len(raw)%2)
+	var misses int
+	var first CharCode
 
 	for len(raw) > 0 {
 		if len(raw) == 1 {
@@ -97,10 +109,50 @@ func decodeString16bit(enc TextEncoder, raw []byte) string {
 
 		r, ok := enc.CharcodeToRune(code)
 		if !ok {
-			common.Log.Debug("Failed to map charcode to rune. charcode=%#x", code)
+			if misses == 0 {
+				first = code
+			}
+			misses++
 			continue
 		}
 		runes = append(runes, r)
 	}
+	if misses > 0 {
+		logger.Debug("character codes missing from encoding", "encoding", encodingName(enc),
+			"count", misses, "first", fmt.Sprintf("%#04x", first))
+	}
 	return string(runes)
 }
+
+// missingRunes counts the runes of a string missing from an encoding.
+type missingRunes struct {
+	count int
+	first rune
+}
+
+func (m *missingRunes) add(r rune) {
+	if m.count == 0 {
+		m.first = r
+	}
+	m.count++
+}
+
+// log logs the runes missing from the encoding `enc`, if any, in a single message.
+func (m *missingRunes) log(enc TextEncoder) {
+	if m.count > 0 {
+		logger.Debug("runes missing from encoding", "encoding", encodingName(enc),
+			"count", m.count, "first", fmt.Sprintf("%+q", m.first))
+	}
+}
+
+// encodingName returns the name of the type of `enc`, rather than its description which can be
+// expensive to compute, e.g. for TrueType encoders.
+func encodingName(enc TextEncoder) string {
+	switch enc := enc.(type) {
+	case SimpleEncoder:
+		return enc.BaseName()
+	case *TrueTypeFontEncoder:
+		return "TrueType"
+	}
+	return fmt.Sprintf("%T", enc)
+}
@@ -0,0 +1,62 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package textencoding
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/unidoc/unipdf/v3/common"
+)
+
+// TestEncoderMissingRunesLogs checks that the runes missing from the encodings are logged once per
+// string and once per rune and TrueType encoder, rather than for each occurrence.
+func TestEncoderMissingRunesLogs(t *testing.T) {
+	var buf bytes.Buffer
+	defer common.SetLogger(common.Log)
+	common.SetLogger(common.NewWriterLogger(common.LogLevelDebug, &buf))
+
+	enc := NewTrueTypeFontEncoder(map[rune]GID{'a': 1, 'b': 2})
+	if encoded := enc.Encode("axbxxyab"); !bytes.Equal(encoded, []byte{0, 1, 0, 2, 0, 1, 0, 2}) {
+		t.Fatalf("Encoding mismatch. Got % x", encoded)
+	}
+	enc.Encode("xx")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []string{
+		`rune missing from encoding encoding=TrueType rune='x'`,
+		`rune missing from encoding encoding=TrueType rune='y'`,
+		`runes missing from encoding encoding=TrueType count=4 first='x'`,
+		`runes missing from encoding encoding=TrueType count=2 first='x'`,
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines. Got %q", len(expected), lines)
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, expected[i]) {
+			t.Fatalf("Line %d mismatch. Got %q. Expected suffix %q", i, line, expected[i])
+		}
+	}
+
+	buf.Reset()
+	if decoded := enc.Decode([]byte{0, 1, 0, 7, 0, 7}); decoded != "a" {
+		t.Fatalf("Decoding mismatch. Got %q", decoded)
+	}
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected = []string{
+		"character code missing from encoding encoding=TrueType code=0x0007",
+		"character codes missing from encoding encoding=TrueType count=2 first=0x0007",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines. Got %q", len(expected), lines)
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, expected[i]) {
+			t.Fatalf("Line %d mismatch. Got %q. Expected suffix %q", i, line, expected[i])
+		}
+	}
+}
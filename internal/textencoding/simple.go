@@ -11,7 +11,6 @@ import (
 	"sync"
 	"unicode/utf8"
 
-	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
 	"golang.org/x/text/encoding"
 	xtransform "golang.org/x/text/transform"
@@ -35,7 +34,7 @@ func NewCustomSimpleTextEncoder(encoding, differences map[CharCode]GlyphName) (S
 	for code, glyph := range encoding {
 		r, ok := GlyphToRune(glyph)
 		if !ok {
-			common.Log.Debug("ERROR: Unknown glyph. %q", glyph)
+			logger.Debug("unknown glyph in custom encoding", "glyph", string(glyph), "code", code)
 			continue
 		}
 		baseEncoding[byte(code)] = r
@@ -53,7 +52,7 @@ func NewCustomSimpleTextEncoder(encoding, differences map[CharCode]GlyphName) (S
 func NewSimpleTextEncoder(baseName string, differences map[CharCode]GlyphName) (SimpleEncoder, error) {
 	fnc, ok := simple[baseName]
 	if !ok {
-		common.Log.Debug("ERROR: NewSimpleTextEncoder. Unknown encoding %q", baseName)
+		logger.Debug("unsupported simple encoding", "encoding", baseName)
 		return nil, errors.New("unsupported font encoding")
 	}
 	enc := fnc()
@@ -10,7 +10,6 @@ import (
 	"sort"
 	"strings"
 
-	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
 )
 
@@ -28,12 +27,17 @@ type TrueTypeFontEncoder struct {
 
 	// runes registered by encoder for tracking what runes are used for subsetting.
 	registeredMap map[rune]struct{}
+
+	// runes and character codes missing from the font, logged once.
+	missingMap   map[rune]struct{}
+	missingCodes map[CharCode]struct{}
 }
 
 // SubsetRegistered subsets `enc` to only registered runes (that have been registered via encoding).
 // NOTE: Make sure to call this soon before writing (once all needed runes have been registered).
 func (enc *TrueTypeFontEncoder) SubsetRegistered() {
-	common.Log.Info("TTF Subset: Pruning")
+	logger.Debug("pruning unused runes of TrueType font subset", "runes", len(enc.runeToGIDMap),
+		"registered", len(enc.registeredMap))
 	for r := range enc.runeToGIDMap {
 		if _, has := enc.registeredMap[r]; !has {
 			delete(enc.runeToGIDMap, r)
@@ -118,7 +122,7 @@ func (enc *TrueTypeFontEncoder) GlyphToCharcode(glyph GlyphName) (CharCode, bool
 		return enc.RuneToCharcode(rune)
 	}
 
-	common.Log.Debug("Symbol encoding error: unable to find glyph->charcode entry (%s)", glyph)
+	logger.Debug("glyph missing from encoding", "encoding", "TrueType", "glyph", string(glyph))
 	return 0, false
 }
 
@@ -127,7 +131,13 @@ func (enc *TrueTypeFontEncoder) GlyphToCharcode(glyph GlyphName) (CharCode, bool
 func (enc *TrueTypeFontEncoder) RuneToCharcode(r rune) (CharCode, bool) {
 	glyphIndex, ok := enc.runeToGIDMap[r]
 	if !ok {
-		common.Log.Debug("Missing rune %d (%+q) from encoding", r, r)
+		if _, logged := enc.missingMap[r]; !logged {
+			if enc.missingMap == nil {
+				enc.missingMap = map[rune]struct{}{}
+			}
+			enc.missingMap[r] = struct{}{}
+			logger.Debug("rune missing from encoding", "encoding", "TrueType", "rune", fmt.Sprintf("%+q", r))
+		}
 		return 0, false
 	}
 	if enc.registeredMap == nil {
@@ -153,7 +163,14 @@ func (enc *TrueTypeFontEncoder) CharcodeToRune(code CharCode) (rune, bool) {
 			return r, true
 		}
 	}
-	common.Log.Debug("CharcodeToRune: No match. code=0x%04x enc=%s", code, enc)
+	if _, logged := enc.missingCodes[code]; !logged {
+		if enc.missingCodes == nil {
+			enc.missingCodes = map[CharCode]struct{}{}
+		}
+		enc.missingCodes[code] = struct{}{}
+		logger.Debug("character code missing from encoding", "encoding", "TrueType",
+			"code", fmt.Sprintf("%#04x", code))
+	}
 	return 0, false
 }
 
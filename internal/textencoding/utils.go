@@ -8,8 +8,6 @@ package textencoding
 import (
 	"fmt"
 	"unicode"
-
-	"github.com/unidoc/unipdf/v3/common"
 )
 
 func glyphToRune(glyph GlyphName, glyphToRuneMap map[GlyphName]rune) (rune, bool) {
@@ -18,7 +16,7 @@ func glyphToRune(glyph GlyphName, glyphToRuneMap map[GlyphName]rune) (rune, bool
 		return r, true
 	}
 
-	common.Log.Debug("ERROR: glyphToRune unable to find glyph %q", glyph)
+	logger.Debug("glyph missing from glyph list", "glyph", string(glyph))
 	return 0, false
 }
 
@@ -27,7 +25,7 @@ func runeToGlyph(r rune, runeToGlyphMap map[rune]GlyphName) (GlyphName, bool) {
 	if ok {
 		return glyph, true
 	}
-	common.Log.Debug("ERROR: runeToGlyph unable to find glyph for rune %s", rs(r))
+	logger.Debug("rune missing from glyph list", "rune", rs(r))
 	return "", false
 }
 
@@ -0,0 +1,22 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package textstring provides functions creating the PDF text strings internally.
+package textstring
+
+import (
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// Make returns a PDF text string containing the specified UTF-8 text. Non-ASCII text is encoded
+// as UTF-16BE.
+func Make(s string) *core.PdfObjectString {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return core.MakeEncodedString(s, true)
+		}
+	}
+	return core.MakeString(s)
+}
@@ -0,0 +1,21 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package textstring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMake(t *testing.T) {
+	ascii := Make("Reviewer")
+	require.Equal(t, "Reviewer", ascii.Str())
+
+	unicode := Make("Révision")
+	require.Equal(t, "\xfe\xff", unicode.Str()[:2])
+	require.Equal(t, "Révision", unicode.Decoded())
+}
@@ -0,0 +1,56 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package tjarray provides functions processing the arrays of the TJ text showing operator
+// internally.
+package tjarray
+
+import (
+	"math"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// Merge returns the elements of a TJ array with consecutive strings and consecutive numeric
+// adjustments merged, and the null adjustments removed.
+func Merge(elements []core.PdfObject) []core.PdfObject {
+	var merged []core.PdfObject
+	for _, element := range elements {
+		n := len(merged)
+		if str, ok := core.GetString(element); ok {
+			if n > 0 {
+				if prev, ok := core.GetString(merged[n-1]); ok {
+					merged[n-1] = core.MakeStringFromBytes(append(append([]byte{}, prev.Bytes()...), str.Bytes()...))
+					continue
+				}
+			}
+			merged = append(merged, element)
+			continue
+		}
+		val, err := core.GetNumberAsFloat(element)
+		if err != nil {
+			merged = append(merged, element)
+			continue
+		}
+		if n > 0 {
+			if prev, err := core.GetNumberAsFloat(merged[n-1]); err == nil {
+				merged[n-1] = core.MakeFloat(prev + val)
+				continue
+			}
+		}
+		merged = append(merged, core.MakeFloat(val))
+	}
+
+	// Adjustments of less than a millionth of text space unit result from
+	// rounding errors.
+	result := merged[:0]
+	for _, element := range merged {
+		if val, err := core.GetNumberAsFloat(element); err == nil && math.Abs(val) < 1e-3 {
+			continue
+		}
+		result = append(result, element)
+	}
+	return result
+}
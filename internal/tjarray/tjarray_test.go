@@ -0,0 +1,26 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package tjarray
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+func TestMerge(t *testing.T) {
+	merged := Merge([]core.PdfObject{
+		core.MakeString("a"), core.MakeString("b"), core.MakeInteger(-100), core.MakeFloat(40.5),
+		core.MakeString("c"), core.MakeFloat(0.0001), core.MakeString("d"), core.MakeFloat(10), core.MakeFloat(-10),
+		core.MakeName("invalid"),
+	})
+	require.Equal(t, []core.PdfObject{
+		core.MakeString("ab"), core.MakeFloat(-59.5), core.MakeString("c"), core.MakeString("d"),
+		core.MakeName("invalid"),
+	}, merged)
+}
@@ -129,6 +129,18 @@ func (m *Matrix) Transform(x, y float64) (float64, float64) {
 	return xp, yp
 }
 
+// Inverse returns the inverse of `m`, which transforms the coordinates transformed by `m` back,
+// and true, or false if `m` is not invertible.
+func (m *Matrix) Inverse() (Matrix, bool) {
+	a, b, c, d, tx, ty := m[0], m[1], m[3], m[4], m[6], m[7]
+	det := a*d - b*c
+	if math.Abs(det) < minDeterminant {
+		return Matrix{}, false
+	}
+	aI, bI, cI, dI := d/det, -b/det, -c/det, a/det
+	return NewMatrix(aI, bI, cI, dI, -(aI*tx + bI*ty), -(cI*tx + dI*ty)), true
+}
+
 // ScalingFactorX returns the X scaling of the affine transform.
 func (m *Matrix) ScalingFactorX() float64 {
 	return math.Hypot(m[0], m[1])
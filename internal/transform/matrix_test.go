@@ -61,3 +61,24 @@ func makeAngleCase(r, theta float64) angleCase {
 	d := a
 	return angleCase{params{a, b, c, d, 0, 0}, theta}
 }
+
+// TestInverse tests that Matrix.Inverse() transforms points back.
+func TestInverse(t *testing.T) {
+	const tol = 1.0e-10
+	for _, test := range angleTests {
+		p := test.params
+		m := NewMatrix(2*p.a, 3*p.b, p.c, p.d, 10, -20)
+		inv, ok := m.Inverse()
+		if !ok {
+			t.Fatalf("Matrix not invertible: m=%s", m)
+		}
+		x, y := inv.Transform(m.Transform(5, 7))
+		if math.Abs(x-5) > tol || math.Abs(y-7) > tol {
+			t.Fatalf("Bad inverse: m=%s inv=%s (5, 7) -> (%g, %g)", m, inv, x, y)
+		}
+	}
+	m := NewMatrix(1, 2, 2, 4, 0, 0)
+	if _, ok := m.Inverse(); ok {
+		t.Fatalf("Singular matrix inverted: m=%s", m)
+	}
+}
@@ -8,6 +8,7 @@ package model
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
@@ -50,6 +51,18 @@ func (a *PdfAnnotation) SetContext(ctx PdfModel) {
 	a.context = ctx
 }
 
+// GetModifiedDate returns the date and time when the annotation was most recently modified (M
+// entry). A zero time is returned if the entry is missing, and an error if it is not a valid date.
+func (a *PdfAnnotation) GetModifiedDate() (time.Time, error) {
+	return getDate(a.M)
+}
+
+// SetModifiedDate sets the date and time when the annotation was most recently modified (M
+// entry). The entry is removed if `t` is zero.
+func (a *PdfAnnotation) SetModifiedDate(t time.Time) {
+	a.M = makeDate(t)
+}
+
 func (a *PdfAnnotation) String() string {
 	s := ""
 
@@ -76,6 +89,18 @@ type PdfAnnotationMarkup struct {
 	ExData       core.PdfObject
 }
 
+// GetCreationDate returns the date and time when the annotation was created (CreationDate
+// entry). A zero time is returned if the entry is missing, and an error if it is not a valid date.
+func (markup *PdfAnnotationMarkup) GetCreationDate() (time.Time, error) {
+	return getDate(markup.CreationDate)
+}
+
+// SetCreationDate sets the date and time when the annotation was created (CreationDate entry).
+// The entry is removed if `t` is zero.
+func (markup *PdfAnnotationMarkup) SetCreationDate(t time.Time) {
+	markup.CreationDate = makeDate(t)
+}
+
 // PdfAnnotationText represents Text annotations.
 // (Section 12.5.6.4 p. 402).
 type PdfAnnotationText struct {
@@ -146,6 +171,10 @@ type PdfAnnotationFreeText struct {
 	RD core.PdfObject
 	BS core.PdfObject
 	LE core.PdfObject
+
+	// Rotate is the rotation of the text, in degrees. It is not defined by the PDF specification,
+	// but it is written and used by Acrobat.
+	Rotate core.PdfObject
 }
 
 // PdfAnnotationLine represents Line annotations.
@@ -217,6 +246,50 @@ type PdfAnnotationPolyLine struct {
 	Measure  core.PdfObject
 }
 
+// GetVertices returns the vertices of the polygon as a list of alternating
+// x and y coordinates in default user space.
+func (poly *PdfAnnotationPolygon) GetVertices() ([]float64, error) {
+	return getAnnotationVertices(poly.Vertices)
+}
+
+// SetVertices sets the vertices of the polygon. The `vertices` are specified
+// as a list of alternating x and y coordinates in default user space.
+func (poly *PdfAnnotationPolygon) SetVertices(vertices []float64) {
+	poly.Vertices = core.MakeArrayFromFloats(vertices)
+}
+
+// GetVertices returns the vertices of the polyline as a list of alternating
+// x and y coordinates in default user space.
+func (polyl *PdfAnnotationPolyLine) GetVertices() ([]float64, error) {
+	return getAnnotationVertices(polyl.Vertices)
+}
+
+// SetVertices sets the vertices of the polyline. The `vertices` are specified
+// as a list of alternating x and y coordinates in default user space.
+func (polyl *PdfAnnotationPolyLine) SetVertices(vertices []float64) {
+	polyl.Vertices = core.MakeArrayFromFloats(vertices)
+}
+
+// getAnnotationVertices returns the coordinates contained by the specified
+// vertices array.
+func getAnnotationVertices(obj core.PdfObject) ([]float64, error) {
+	if obj == nil {
+		return nil, nil
+	}
+	arr, ok := core.GetArray(obj)
+	if !ok {
+		return nil, core.ErrTypeError
+	}
+	vertices, err := arr.ToFloat64Array()
+	if err != nil {
+		return nil, err
+	}
+	if len(vertices)%2 != 0 {
+		return nil, errors.New("odd number of vertex coordinates")
+	}
+	return vertices, nil
+}
+
 // PdfAnnotationHighlight represents Highlight annotations.
 // (Section 12.5.6.10).
 type PdfAnnotationHighlight struct {
@@ -275,6 +348,39 @@ type PdfAnnotationInk struct {
 	BS      core.PdfObject
 }
 
+// GetInkList returns the stroked paths of the ink annotation. Each path is a
+// list of alternating x and y coordinates in default user space.
+func (ink *PdfAnnotationInk) GetInkList() ([][]float64, error) {
+	if ink.InkList == nil {
+		return nil, nil
+	}
+	arr, ok := core.GetArray(ink.InkList)
+	if !ok {
+		return nil, core.ErrTypeError
+	}
+
+	paths := make([][]float64, 0, arr.Len())
+	for _, obj := range arr.Elements() {
+		path, err := getAnnotationVertices(obj)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// SetInkList sets the stroked paths of the ink annotation. Each path is
+// specified as a list of alternating x and y coordinates in default user
+// space.
+func (ink *PdfAnnotationInk) SetInkList(paths [][]float64) {
+	arr := core.MakeArray()
+	for _, path := range paths {
+		arr.Append(core.MakeArrayFromFloats(path))
+	}
+	ink.InkList = arr
+}
+
 // PdfAnnotationPopup represents Popup annotations.
 // (Section 12.5.6.14).
 type PdfAnnotationPopup struct {
@@ -1080,7 +1186,7 @@ func (r *PdfReader) newPdfAnnotationTextFromDict(d *core.PdfObjectDictionary) (*
 }
 
 func (r *PdfReader) newPdfAnnotationLinkFromDict(d *core.PdfObjectDictionary) (*PdfAnnotationLink, error) {
-	annot := PdfAnnotationLink{}
+	annot := PdfAnnotationLink{reader: r}
 
 	annot.A = d.Get("A")
 	annot.Dest = d.Get("Dest")
@@ -1124,6 +1230,7 @@ func (r *PdfReader) newPdfAnnotationFreeTextFromDict(d *core.PdfObjectDictionary
 	annot.RD = d.Get("RD")
 	annot.BS = d.Get("BS")
 	annot.LE = d.Get("LE")
+	annot.Rotate = d.Get("Rotate")
 
 	return &annot, nil
 }
@@ -1581,6 +1688,7 @@ func (ft *PdfAnnotationFreeText) ToPdfObject() core.PdfObject {
 	d.SetIfNotNil("RD", ft.RD)
 	d.SetIfNotNil("BS", ft.BS)
 	d.SetIfNotNil("LE", ft.LE)
+	d.SetIfNotNil("Rotate", ft.Rotate)
 
 	return container
 }
@@ -1754,6 +1862,17 @@ func (stamp *PdfAnnotationStamp) ToPdfObject() core.PdfObject {
 	return container
 }
 
+// GetAppearance returns the active normal appearance of the stamp, which can
+// be reused to create the same stamp in another document. Returns nil if the
+// stamp has no appearance stream.
+func (stamp *PdfAnnotationStamp) GetAppearance() (*XObjectForm, error) {
+	if stamp.AP == nil {
+		return nil, nil
+	}
+	xform, _, err := getAnnotationActiveAppearance(stamp.PdfAnnotation)
+	return xform, err
+}
+
 // ToPdfObject implements interface PdfModel.
 func (ink *PdfAnnotationInk) ToPdfObject() core.PdfObject {
 	ink.PdfAnnotation.ToPdfObject()
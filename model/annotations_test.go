@@ -0,0 +1,148 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+func TestAnnotationCoordinatesRoundTrip(t *testing.T) {
+	inkList := [][]float64{
+		{10, 10, 20.5, 30.25, 40, 15},
+		{100, 100, 110, 120},
+	}
+	vertices := []float64{50, 50, 150, 50, 100, 125.125}
+
+	ink := NewPdfAnnotationInk()
+	ink.SetInkList(inkList)
+	polygon := NewPdfAnnotationPolygon()
+	polygon.SetVertices(vertices)
+	polyline := NewPdfAnnotationPolyLine()
+	polyline.SetVertices(vertices[:4])
+
+	page := NewPdfPage()
+	for _, annot := range []*PdfAnnotation{ink.PdfAnnotation, polygon.PdfAnnotation, polyline.PdfAnnotation} {
+		annot.Rect = core.MakeArrayFromFloats([]float64{0, 0, 200, 200})
+		page.AddAnnotation(annot)
+	}
+
+	// Write, load and write the document again.
+	data := bytes.NewBuffer(nil)
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(page))
+	require.NoError(t, w.Write(data))
+
+	for i := 0; i < 2; i++ {
+		reader, err := NewPdfReader(bytes.NewReader(data.Bytes()))
+		require.NoError(t, err)
+		page, err := reader.GetPage(1)
+		require.NoError(t, err)
+		annots, err := page.GetAnnotations()
+		require.NoError(t, err)
+		require.Len(t, annots, 3)
+
+		ink, ok := annots[0].GetContext().(*PdfAnnotationInk)
+		require.True(t, ok)
+		paths, err := ink.GetInkList()
+		require.NoError(t, err)
+		require.Equal(t, inkList, paths)
+
+		polygon, ok := annots[1].GetContext().(*PdfAnnotationPolygon)
+		require.True(t, ok)
+		coords, err := polygon.GetVertices()
+		require.NoError(t, err)
+		require.Equal(t, vertices, coords)
+
+		polyline, ok := annots[2].GetContext().(*PdfAnnotationPolyLine)
+		require.True(t, ok)
+		coords, err = polyline.GetVertices()
+		require.NoError(t, err)
+		require.Equal(t, vertices[:4], coords)
+
+		data = bytes.NewBuffer(nil)
+		w := NewPdfWriter()
+		require.NoError(t, w.AddPage(page))
+		require.NoError(t, w.Write(data))
+	}
+
+	// Invalid coordinates.
+	polygon.Vertices = core.MakeArrayFromFloats([]float64{1, 2, 3})
+	_, err := polygon.GetVertices()
+	require.Error(t, err)
+	ink.InkList = core.MakeName("Invalid")
+	_, err = ink.GetInkList()
+	require.Error(t, err)
+}
+
+func TestAnnotationDatesRoundTrip(t *testing.T) {
+	created := time.Date(2023, 1, 15, 12, 0, 0, 0, time.FixedZone("IST", 5*3600+30*60))
+	modified := time.Date(2023, 7, 1, 8, 15, 30, 0, time.FixedZone("PDT", -7*3600))
+
+	text := NewPdfAnnotationText()
+	text.Rect = core.MakeArrayFromFloats([]float64{0, 0, 20, 20})
+	text.SetCreationDate(created)
+	text.SetModifiedDate(modified)
+	square := NewPdfAnnotationSquare()
+	square.Rect = core.MakeArrayFromFloats([]float64{0, 0, 20, 20})
+	// Malformed date written by some producers.
+	square.M = core.MakeString("D:20230701081530-07garbage")
+	page := NewPdfPage()
+	page.AddAnnotation(text.PdfAnnotation)
+	page.AddAnnotation(square.PdfAnnotation)
+
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(page))
+	w.SetCreationDate(created)
+	w.SetModDate(modified)
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	date, err := reader.GetCreationDate()
+	require.NoError(t, err)
+	require.True(t, created.Equal(date), "%v", date)
+	date, err = reader.GetModDate()
+	require.NoError(t, err)
+	require.True(t, modified.Equal(date), "%v", date)
+
+	page, err = reader.GetPage(1)
+	require.NoError(t, err)
+	annots, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 2)
+	readText, ok := annots[0].GetContext().(*PdfAnnotationText)
+	require.True(t, ok)
+	date, err = readText.GetCreationDate()
+	require.NoError(t, err)
+	require.True(t, created.Equal(date), "%v", date)
+	_, offset := date.Zone()
+	require.Equal(t, 5*3600+30*60, offset)
+	for _, annot := range annots {
+		date, err = annot.GetModifiedDate()
+		require.NoError(t, err)
+		require.True(t, modified.Equal(date), "%v", date)
+	}
+
+	// Missing and invalid dates.
+	square.SetModifiedDate(time.Time{})
+	require.Nil(t, square.M)
+	date, err = square.GetModifiedDate()
+	require.NoError(t, err)
+	require.True(t, date.IsZero())
+	square.M = core.MakeInteger(2023)
+	_, err = square.GetModifiedDate()
+	require.Error(t, err)
+	square.M = core.MakeString("yesterday")
+	_, err = square.GetModifiedDate()
+	require.Error(t, err)
+}
@@ -7,6 +7,7 @@ package model
 
 import (
 	"bytes"
+	"crypto/md5"
 	"errors"
 	"fmt"
 	"io"
@@ -26,6 +27,16 @@ type PdfAppender struct {
 	Reader   *PdfReader
 	pages    []*PdfPage
 	acroForm *PdfAcroForm
+	dss      *DSS
+	perms    *core.PdfObjectDictionary
+
+	embeddedFiles embeddedFileChanges
+	namedDests    map[string]*PdfDestination
+	javaScripts   javaScriptChanges
+	ocProperties  *PdfOptionalContentProperties
+
+	// Catalog entries updated in the new revision. Nil values are removed.
+	catalogUpdates map[core.PdfObjectName]core.PdfObject
 
 	xrefs          core.XrefTable
 	xrefOffset     int64
@@ -142,6 +153,13 @@ func NewPdfAppender(reader *PdfReader) (*PdfAppender, error) {
 			a.greatestObjNum = idx
 		}
 	}
+	// New objects must be numbered after the entries of the original
+	// cross-reference section, which may include trailing free entries.
+	if trailer := a.parser.GetTrailer(); trailer != nil {
+		if size, ok := core.GetIntVal(trailer.Get("Size")); ok && size-1 > a.greatestObjNum {
+			a.greatestObjNum = size - 1
+		}
+	}
 	a.xrefs = a.parser.GetXrefTable()
 	a.xrefOffset = a.parser.GetXrefOffset()
 
@@ -177,18 +195,18 @@ func (a *PdfAppender) updateObjectsDeep(obj core.PdfObject, processed map[core.P
 	case *core.PdfIndirectObject:
 		// Change detection:
 		// - obj same as in read only reader (internal use by appender) - definately no change.
-		// - obj is from original reader (derivative of original document) - mark for update if PdfObj WriteString has changed.
+		// - obj is from original reader (derivative of original document) - mark for update if PdfObj has changed semantically.
 		// - obj is from another source (another file or new) - add as new object.
 		switch {
 		case v.GetParser() == a.roReader.parser:
 			// obj same as in read only reader (internal use by appender) - definitely no change.
 			return
 		case v.GetParser() == a.Reader.parser:
-			// obj is from original reader (derivative of original document) - mark for update if PdfObj WriteString has changed.
+			// obj is from original reader (derivative of original document) - mark for update if PdfObj has changed semantically.
 			origObj, _ := a.roReader.GetIndirectObjectByNumber(int(v.ObjectNumber))
 			origInd, ok := origObj.(*core.PdfIndirectObject)
 			if ok && origInd != nil {
-				if origInd.PdfObject != v.PdfObject && origInd.PdfObject.WriteString() != v.PdfObject.WriteString() {
+				if origInd.PdfObject != v.PdfObject && !core.Equal(origInd.PdfObject, v.PdfObject) {
 					a.addNewObject(obj)
 					a.replaceObjects[obj] = v.ObjectNumber
 				}
@@ -226,11 +244,15 @@ func (a *PdfAppender) updateObjectsDeep(obj core.PdfObject, processed map[core.P
 			// Check if data has changed.
 			if streamObj, err := a.roReader.parser.LookupByReference(v.PdfObjectReference); err == nil {
 				var isNotChanged bool
-				if stream, ok := core.GetStream(streamObj); ok && bytes.Equal(stream.Stream, v.Stream) {
+				if !v.IsDataLoaded() {
+					// The data has not been read, so it cannot have been modified.
+					isNotChanged = true
+				} else if stream, ok := core.GetStream(streamObj); ok && stream.LoadData() == nil &&
+					bytes.Equal(stream.Stream, v.Stream) {
 					isNotChanged = true
 				}
 				if dict, ok := core.GetDict(streamObj); isNotChanged && ok {
-					isNotChanged = dict.WriteString() == v.PdfObjectDictionary.WriteString()
+					isNotChanged = core.Equal(dict, v.PdfObjectDictionary)
 				}
 				if isNotChanged {
 					return
@@ -239,6 +261,7 @@ func (a *PdfAppender) updateObjectsDeep(obj core.PdfObject, processed map[core.P
 			if v.ObjectNumber != 0 {
 				a.replaceObjects[obj] = v.ObjectNumber
 			}
+			a.addNewObject(obj)
 		default:
 			// other source - add new.
 			if _, has := a.hasNewObject[obj]; !has {
@@ -465,7 +488,10 @@ func (a *PdfAppender) ReplacePage(pageNum int, page *PdfPage) {
 
 // Sign signs a specific page with a digital signature.
 // The signature field parameter must have a valid signature dictionary
-// specified by its V field.
+// specified by its V field. Certification signatures (see
+// PdfSignature.SetCertification) can only be applied to documents which are
+// not signed yet. The fields locked by the signature field (see
+// PdfFieldSignature.SetLock) are declared in the signature as well.
 func (a *PdfAppender) Sign(pageNum int, field *PdfFieldSignature) error {
 	if field == nil {
 		return errors.New("signature field cannot be nil")
@@ -483,6 +509,44 @@ func (a *PdfAppender) Sign(pageNum int, field *PdfFieldSignature) error {
 	}
 	page := a.Reader.PageList[pageIndex]
 
+	if a.acroForm == a.roReader.AcroForm {
+		a.acroForm = a.Reader.AcroForm
+	}
+
+	// Certification signatures must be the first signatures of documents
+	// and are referenced by the DocMDP entry of the permissions dictionary.
+	if getDocMDPPermission(signature) > 0 {
+		if a.acroForm != nil {
+			for _, f := range a.acroForm.AllFields() {
+				if sf, ok := f.GetContext().(*PdfFieldSignature); ok && sf.V != nil {
+					return errors.New("certification signature must be the first signature")
+				}
+			}
+		}
+		if a.perms == nil {
+			a.perms = core.MakeDict()
+			if catalog := a.roReader.catalog; catalog != nil {
+				perms, _ := core.GetDict(catalog.Get("Perms"))
+				for _, key := range perms.Keys() {
+					a.perms.Set(key, perms.Get(key))
+				}
+			}
+		}
+		a.perms.Set("DocMDP", signature.ToPdfObject())
+	}
+
+	// Declare the fields locked by the signature field.
+	if field.Lock != nil {
+		if lock, ok := core.GetDict(field.Lock); ok {
+			params := core.MakeDict()
+			params.Set("Type", core.MakeName("TransformParams"))
+			params.Set("Action", lock.Get("Action"))
+			params.SetIfNotNil("Fields", lock.Get("Fields"))
+			params.Set("V", core.MakeName("1.2"))
+			signature.setReference("FieldMDP", params)
+		}
+	}
+
 	// Add signature field annotations to the page annotations.
 	field.P = page.ToPdfObject()
 	if field.T == nil || field.T.String() == "" {
@@ -491,9 +555,6 @@ func (a *PdfAppender) Sign(pageNum int, field *PdfFieldSignature) error {
 	page.AddAnnotation(field.PdfAnnotationWidget.PdfAnnotation)
 
 	// Add signature field to the form.
-	if a.acroForm == a.roReader.AcroForm {
-		a.acroForm = a.Reader.AcroForm
-	}
 	acroForm := a.acroForm
 	if acroForm == nil {
 		acroForm = NewPdfAcroForm()
@@ -520,6 +581,50 @@ func (a *PdfAppender) ReplaceAcroForm(acroForm *PdfAcroForm) {
 	a.acroForm = acroForm
 }
 
+// SetDSS sets the document security store (DSS) of the document. The DSS is
+// written in the new revision, replacing the original one, if any.
+func (a *PdfAppender) SetDSS(dss *DSS) {
+	if dss != nil {
+		a.updateObjectsDeep(dss.ToPdfObject(), nil)
+	}
+	a.dss = dss
+}
+
+// AddEmbeddedFile adds `file` to the document level embedded files
+// (EmbeddedFiles name tree), replacing any embedded file with the same name.
+func (a *PdfAppender) AddEmbeddedFile(file *EmbeddedFile) error {
+	if file == nil || file.Name == "" {
+		return errors.New("embedded file name not specified")
+	}
+	a.embeddedFiles.add(file)
+	return nil
+}
+
+// RemoveEmbeddedFile removes the document level embedded file with the
+// specified name.
+func (a *PdfAppender) RemoveEmbeddedFile(name string) {
+	a.embeddedFiles.remove(name)
+}
+
+// AddNamedDestination adds a named destination to the Dests name tree of the
+// document, replacing any destination with the same name.
+func (a *PdfAppender) AddNamedDestination(name string, dest *PdfDestination) error {
+	if name == "" || dest == nil {
+		return errors.New("named destination not specified")
+	}
+	if a.namedDests == nil {
+		a.namedDests = map[string]*PdfDestination{}
+	}
+	a.namedDests[name] = dest
+	return nil
+}
+
+// SetOptionalContentProperties sets the optional content properties of the
+// document.
+func (a *PdfAppender) SetOptionalContentProperties(props *PdfOptionalContentProperties) {
+	a.ocProperties = props
+}
+
 // Write writes the Appender output to io.Writer.
 // It can only be called once and further invocations will result in an error.
 func (a *PdfAppender) Write(w io.Writer) error {
@@ -569,6 +674,46 @@ func (a *PdfAppender) Write(w io.Writer) error {
 		writer.catalog.Set("AcroForm", a.acroForm.ToPdfObject())
 		a.updateObjectsDeep(a.acroForm.ToPdfObject(), nil)
 	}
+	if a.perms != nil {
+		writer.catalog.Set("Perms", a.perms)
+		a.updateObjectsDeep(a.perms, nil)
+	}
+	if a.dss != nil {
+		writer.catalog.Set("DSS", a.dss.ToPdfObject())
+		a.updateObjectsDeep(a.dss.ToPdfObject(), nil)
+	}
+	if a.ocProperties != nil {
+		ocProperties := a.ocProperties.ToPdfObject()
+		writer.catalog.Set("OCProperties", ocProperties)
+		a.updateObjectsDeep(ocProperties, nil)
+	}
+	if !a.embeddedFiles.isEmpty() || len(a.namedDests) > 0 || !a.javaScripts.isEmpty() {
+		names := catalog.Get("Names")
+		if !a.embeddedFiles.isEmpty() {
+			updated, err := a.embeddedFiles.apply(names)
+			if err != nil {
+				return err
+			}
+			names = updated
+		}
+		if len(a.namedDests) > 0 {
+			names = applyNamedDestinations(names, a.namedDests)
+		}
+		if !a.javaScripts.isEmpty() {
+			names = a.javaScripts.apply(names)
+		}
+		if setCatalogNames(writer.catalog, names) {
+			a.updateObjectsDeep(names, nil)
+		}
+	}
+	for key, obj := range a.catalogUpdates {
+		if obj == nil {
+			writer.catalog.Remove(key)
+			continue
+		}
+		writer.catalog.Set(key, obj)
+		a.updateObjectsDeep(obj, nil)
+	}
 
 	a.addNewObject(writer.infoObj)
 	a.addNewObject(writer.root)
@@ -706,6 +851,7 @@ func (a *PdfAppender) Write(w io.Writer) error {
 	writer.appendPrevRevisionSize = a.prevRevisionSize
 	writer.minorVersion = a.roReader.PdfVersion().Minor
 	writer.appendReplaceMap = a.replaceObjects
+	writer.ids = makeAppendTrailerIDs(trailer, offset)
 
 	xrefType := a.parser.GetXrefType()
 	if xrefType != nil {
@@ -794,6 +940,9 @@ func (a *PdfAppender) Write(w io.Writer) error {
 			}
 			sigDict.signature.ByteRange = byteRange
 			contents := []byte(sigDict.signature.Contents.WriteString())
+			if contentsLen := sigDict.contentsOffsetEnd - sigDict.contentsOffsetStart; len(contents) > contentsLen {
+				return fmt.Errorf("signature contents size %d exceeds reserved size %d", len(contents), contentsLen)
+			}
 
 			// Empty out the ByteRange and Content data.
 			// FIXME(gunnsth): Is this needed?  Seems like the correct data is copied below?  Prefer
@@ -823,6 +972,30 @@ func (a *PdfAppender) Write(w io.Writer) error {
 	return nil
 }
 
+// makeAppendTrailerIDs returns the file identifiers of the appended revision,
+// or nil if the original trailer has none, in which case the appended trailer
+// has none either. The first identifier of the original trailer is preserved,
+// as it identifies the document across revisions. The second one is derived
+// from the previous revision, so that appending the same changes produces the
+// same output (e.g. when the content is signed externally).
+func makeAppendTrailerIDs(trailer *core.PdfObjectDictionary, size int64) *core.PdfObjectArray {
+	ids, ok := core.GetArray(trailer.Get("ID"))
+	if !ok || ids.Len() != 2 {
+		return nil
+	}
+	id0, ok := core.GetString(ids.Get(0))
+	if !ok {
+		return nil
+	}
+	var prevID1 string
+	if str, ok := core.GetString(ids.Get(1)); ok {
+		prevID1 = str.Str()
+	}
+
+	hashcode := md5.Sum([]byte(fmt.Sprintf("%s-%d", prevID1, size)))
+	return core.MakeArray(core.MakeHexString(id0.Str()), core.MakeHexString(string(hashcode[:])))
+}
+
 // WriteToFile writes the Appender output to file specified by path.
 func (a *PdfAppender) WriteToFile(outputPath string) error {
 	fWrite, err := os.Create(outputPath)
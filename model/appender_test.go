@@ -8,17 +8,28 @@ package model_test
 import (
 	"bytes"
 	"crypto"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/unidoc/pkcs7"
+	"github.com/unidoc/timestamp"
+	"golang.org/x/crypto/ocsp"
 	"golang.org/x/crypto/pkcs12"
 
 	"github.com/unidoc/unipdf/v3/annotator"
@@ -26,6 +37,7 @@ import (
 	"github.com/unidoc/unipdf/v3/core"
 	"github.com/unidoc/unipdf/v3/model"
 	"github.com/unidoc/unipdf/v3/model/sighandler"
+	"github.com/unidoc/unipdf/v3/model/sigutil"
 )
 
 // This test file contains multiple tests to generate PDFs from existing Pdf files. The outputs are written
@@ -1533,6 +1545,539 @@ func TestAppenderExternalSignature(t *testing.T) {
 	validateFile(t, outputPath)
 }
 
+// testExternalSigner is an ExternalSigner backed by an in-memory RSA key,
+// standing in for an HSM or a remote signing service.
+type testExternalSigner struct {
+	privateKey  *rsa.PrivateKey
+	certificate *x509.Certificate
+	size        int
+}
+
+func (s *testExternalSigner) HashAlgorithm() crypto.Hash {
+	return crypto.SHA256
+}
+
+func (s *testExternalSigner) EstimateSize() int {
+	return s.size
+}
+
+func (s *testExternalSigner) Sign(data []byte, digest []byte) ([]byte, error) {
+	h := sha256.Sum256(data)
+	if !bytes.Equal(h[:], digest) {
+		return nil, errors.New("digest mismatch")
+	}
+
+	signedData, err := pkcs7.NewSignedData(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := signedData.AddSigner(s.certificate, s.privateKey, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, err
+	}
+	signedData.Detach()
+	return signedData.Finish()
+}
+
+func TestAppenderSignExternalSigner(t *testing.T) {
+	certData, err := ioutil.ReadFile(testPKS12Key)
+	require.NoError(t, err)
+	privateKey, cert, err := pkcs12.Decode(certData, testPKS12KeyPassword)
+	require.NoError(t, err)
+
+	signFile := func(signer sighandler.ExternalSigner) ([]byte, error) {
+		file, err := os.Open(testPdfFile1)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		reader, err := model.NewPdfReader(file)
+		if err != nil {
+			return nil, err
+		}
+		appender, err := model.NewPdfAppender(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		handler, err := sighandler.NewAdobePKCS7External(signer)
+		if err != nil {
+			return nil, err
+		}
+
+		signature := model.NewPdfSignature(handler)
+		signature.SetName("Test External Signer")
+		signature.SetReason("TestAppenderSignExternalSigner")
+		signature.SetDate(time.Now(), "")
+		if err := signature.Initialize(); err != nil {
+			return nil, err
+		}
+
+		sigField := model.NewPdfFieldSignature(signature)
+		sigField.T = core.MakeString("Signature1")
+		sigField.Rect = core.MakeArray(
+			core.MakeInteger(0),
+			core.MakeInteger(0),
+			core.MakeInteger(0),
+			core.MakeInteger(0),
+		)
+		if err := appender.Sign(1, sigField); err != nil {
+			return nil, err
+		}
+
+		buf := bytes.NewBuffer(nil)
+		if err := appender.Write(buf); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	signer := &testExternalSigner{
+		privateKey:  privateKey.(*rsa.PrivateKey),
+		certificate: cert,
+		size:        8192,
+	}
+	data, err := signFile(signer)
+	require.NoError(t, err)
+
+	outputPath := tempFile("appender_sign_external_signer.pdf")
+	require.NoError(t, ioutil.WriteFile(outputPath, data, 0644))
+	validateFile(t, outputPath)
+
+	// The signature container does not fit the estimated size.
+	signer.size = 64
+	_, err = signFile(signer)
+	require.Error(t, err)
+
+	// Invalid estimated size.
+	signer.size = 0
+	_, err = sighandler.NewAdobePKCS7External(signer)
+	require.Error(t, err)
+}
+
+// newTestTimestampServer returns a mock RFC 3161 timestamp server. The
+// responses are created by the modify function, if specified, which can alter
+// the requested timestamp in order to simulate invalid responses.
+func newTestTimestampServer(t *testing.T, modify func(ts *timestamp.Timestamp)) *httptest.Server {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test TSA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+		BasicConstraintsValid: true,
+	}
+	certData, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(certData)
+	require.NoError(t, err)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req, err := timestamp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ts := &timestamp.Timestamp{
+			HashAlgorithm:     req.HashAlgorithm,
+			HashedMessage:     req.HashedMessage,
+			Time:              time.Now().UTC().Truncate(time.Second),
+			Nonce:             req.Nonce,
+			Policy:            asn1.ObjectIdentifier{1, 2, 3, 4, 1},
+			AddTSACertificate: req.Certificates,
+		}
+		if modify != nil {
+			modify(ts)
+		}
+
+		resp, err := ts.CreateResponse(cert, privateKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/timestamp-reply")
+		w.Write(resp)
+	}))
+}
+
+func TestAppenderSignTimestamp(t *testing.T) {
+	server := newTestTimestampServer(t, nil)
+	defer server.Close()
+
+	certData, err := ioutil.ReadFile(testPKS12Key)
+	require.NoError(t, err)
+	privateKey, cert, err := pkcs12.Decode(certData, testPKS12KeyPassword)
+	require.NoError(t, err)
+
+	signFile := func(handler model.SignatureHandler) (*model.PdfSignature, []byte, error) {
+		file, err := os.Open(testPdfFile1)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer file.Close()
+
+		reader, err := model.NewPdfReader(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		appender, err := model.NewPdfAppender(reader)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		signature := model.NewPdfSignature(handler)
+		signature.SetName("Test Timestamp")
+		signature.SetReason("TestAppenderSignTimestamp")
+		signature.SetDate(time.Now(), "")
+		if err := signature.Initialize(); err != nil {
+			return nil, nil, err
+		}
+
+		sigField := model.NewPdfFieldSignature(signature)
+		sigField.T = core.MakeString("Signature1")
+		sigField.Rect = core.MakeArray(
+			core.MakeInteger(0),
+			core.MakeInteger(0),
+			core.MakeInteger(0),
+			core.MakeInteger(0),
+		)
+		if err := appender.Sign(1, sigField); err != nil {
+			return nil, nil, err
+		}
+
+		buf := bytes.NewBuffer(nil)
+		if err := appender.Write(buf); err != nil {
+			return nil, nil, err
+		}
+		return signature, buf.Bytes(), nil
+	}
+
+	validate := func(data []byte) model.SignatureValidationResult {
+		reader, err := model.NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+
+		handler, _ := sighandler.NewAdobePKCS7Detached(nil, nil)
+		handler2, _ := sighandler.NewDocTimeStamp("", 0)
+		res, err := reader.ValidateSignatures([]model.SignatureHandler{handler, handler2})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.True(t, res[0].IsSigned)
+		require.True(t, res[0].IsVerified)
+		require.False(t, res[0].GeneralizedTime.IsZero())
+		return res[0]
+	}
+
+	// Signature with an embedded timestamp token.
+	client := sigutil.NewTimestampClient()
+	handler, err := sighandler.NewAdobePKCS7DetachedWithOpts(privateKey.(*rsa.PrivateKey), cert,
+		&sighandler.AdobePKCS7DetachedOpts{
+			TimestampServerURL: server.URL,
+			TimestampClient:    client,
+		})
+	require.NoError(t, err)
+
+	signature, data, err := signFile(handler)
+	require.NoError(t, err)
+	require.Equal(t, "adbe.pkcs7.detached", signature.SubFilter.String())
+	validate(data)
+
+	// Document timestamp.
+	handler, err = sighandler.NewDocTimeStampWithOpts(server.URL, crypto.SHA256,
+		&sighandler.DocTimeStampOpts{Client: client})
+	require.NoError(t, err)
+
+	signature, data, err = signFile(handler)
+	require.NoError(t, err)
+	require.Equal(t, "ETSI.RFC3161", signature.SubFilter.String())
+	validate(data)
+
+	outputPath := tempFile("appender_sign_doc_timestamp.pdf")
+	require.NoError(t, ioutil.WriteFile(outputPath, data, 0644))
+	validateFile(t, outputPath)
+
+	// Reserved size too small for the timestamp token.
+	handler, err = sighandler.NewDocTimeStampWithOpts(server.URL, crypto.SHA256,
+		&sighandler.DocTimeStampOpts{Client: client, SignatureSize: 64})
+	require.NoError(t, err)
+	_, _, err = signFile(handler)
+	require.Error(t, err)
+}
+
+func TestTimestampClientResponseChecks(t *testing.T) {
+	newRequest := func() *timestamp.Request {
+		req, err := sigutil.NewTimestampRequest(bytes.NewReader([]byte("timestamp data")), crypto.SHA256)
+		require.NoError(t, err)
+		require.NotNil(t, req.Nonce)
+		return req
+	}
+
+	// Valid response.
+	server := newTestTimestampServer(t, nil)
+	client := sigutil.NewTimestampClient()
+	token, err := client.GetEncodedToken(server.URL, newRequest())
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	server.Close()
+
+	// Nonce mismatch.
+	server = newTestTimestampServer(t, func(ts *timestamp.Timestamp) {
+		ts.Nonce = new(big.Int).Add(ts.Nonce, big.NewInt(1))
+	})
+	_, err = client.GetEncodedToken(server.URL, newRequest())
+	require.Error(t, err)
+	server.Close()
+
+	// Message imprint mismatch.
+	server = newTestTimestampServer(t, func(ts *timestamp.Timestamp) {
+		ts.HashedMessage = make([]byte, len(ts.HashedMessage))
+	})
+	_, err = client.GetEncodedToken(server.URL, newRequest())
+	require.Error(t, err)
+	server.Close()
+
+	// Basic authentication and custom headers.
+	tsaServer := newTestTimestampServer(t, nil)
+	defer tsaServer.Close()
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "pass" || r.Header.Get("X-Api-Key") != "key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		tsaServer.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	_, err = client.GetEncodedToken(server.URL, newRequest())
+	require.Error(t, err)
+
+	client.Username = "user"
+	client.Password = "pass"
+	client.Header = http.Header{}
+	client.Header.Set("X-Api-Key", "key")
+	_, err = client.GetEncodedToken(server.URL, newRequest())
+	require.NoError(t, err)
+}
+
+// newTestLTVServer returns a test server acting as the certificate, OCSP
+// and CRL server of a test PKI, along with a root CA certificate and a
+// signing certificate issued by it, which refers to the server.
+func newTestLTVServer(t *testing.T) (*httptest.Server, *x509.Certificate, *rsa.PrivateKey) {
+	rootKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signerKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var rootCert *x509.Certificate
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ca.crt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(rootCert.Raw)
+	})
+	mux.HandleFunc("/ocsp", func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := ocsp.CreateResponse(rootCert, rootCert, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}, rootKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(resp)
+	})
+	mux.HandleFunc("/crl", func(w http.ResponseWriter, r *http.Request) {
+		crl, err := rootCert.CreateCRL(rand.Reader, rootKey, nil, time.Now(), time.Now().Add(time.Hour))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(crl)
+	})
+	server := httptest.NewServer(mux)
+
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	rootData, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	require.NoError(t, err)
+	rootCert, err = x509.ParseCertificate(rootData)
+	require.NoError(t, err)
+
+	signerTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Test Signer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		OCSPServer:            []string{server.URL + "/ocsp"},
+		IssuingCertificateURL: []string{server.URL + "/ca.crt"},
+		CRLDistributionPoints: []string{server.URL + "/crl"},
+	}
+	signerData, err := x509.CreateCertificate(rand.Reader, signerTemplate, rootCert, &signerKey.PublicKey, rootKey)
+	require.NoError(t, err)
+	signerCert, err := x509.ParseCertificate(signerData)
+	require.NoError(t, err)
+
+	return server, signerCert, signerKey
+}
+
+func TestAppenderLTV(t *testing.T) {
+	server, cert, privateKey := newTestLTVServer(t)
+	defer server.Close()
+	tsaServer := newTestTimestampServer(t, nil)
+	defer tsaServer.Close()
+
+	// Applies a signature using the specified handler on a new revision.
+	sign := func(data []byte, handler model.SignatureHandler) []byte {
+		reader, err := model.NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		appender, err := model.NewPdfAppender(reader)
+		require.NoError(t, err)
+
+		signature := model.NewPdfSignature(handler)
+		signature.SetName("Test LTV")
+		signature.SetDate(time.Now(), "")
+		require.NoError(t, signature.Initialize())
+
+		sigField := model.NewPdfFieldSignature(signature)
+		sigField.T = core.MakeString(fmt.Sprintf("Signature%d", len(reader.AcroForm.AllFields())+1))
+		sigField.Rect = core.MakeArray(
+			core.MakeInteger(0),
+			core.MakeInteger(0),
+			core.MakeInteger(0),
+			core.MakeInteger(0),
+		)
+		require.NoError(t, appender.Sign(1, sigField))
+
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, appender.Write(buf))
+		return buf.Bytes()
+	}
+
+	// Adds the validation data of the signatures on a new revision.
+	enableLTV := func(data []byte, useOCSP bool) []byte {
+		reader, err := model.NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		appender, err := model.NewPdfAppender(reader)
+		require.NoError(t, err)
+
+		ltv, err := model.NewLTV(appender)
+		require.NoError(t, err)
+		if !useOCSP {
+			ltv.OCSPClient = nil
+		}
+		require.NoError(t, ltv.EnableAll(nil))
+
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, appender.Write(buf))
+		return buf.Bytes()
+	}
+
+	// Validates the signatures and returns the DSS of the document.
+	validate := func(data []byte, numSignatures int) (*model.DSS, []*model.PdfSignature) {
+		reader, err := model.NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+
+		handler, _ := sighandler.NewAdobePKCS7Detached(nil, nil)
+		handler2, _ := sighandler.NewDocTimeStamp("", 0)
+		res, err := reader.ValidateSignatures([]model.SignatureHandler{handler, handler2})
+		require.NoError(t, err)
+		require.Len(t, res, numSignatures)
+		for _, r := range res {
+			require.True(t, r.IsSigned)
+			require.True(t, r.IsVerified)
+		}
+
+		var sigs []*model.PdfSignature
+		for _, field := range reader.AcroForm.AllFields() {
+			if sigField, ok := field.GetContext().(*model.PdfFieldSignature); ok && sigField.V != nil {
+				sigs = append(sigs, sigField.V)
+			}
+		}
+
+		dss, err := reader.GetDSS()
+		require.NoError(t, err)
+		return dss, sigs
+	}
+
+	original, err := ioutil.ReadFile(testPdfFile1)
+	require.NoError(t, err)
+
+	handler, err := sighandler.NewAdobePKCS7Detached(privateKey, cert)
+	require.NoError(t, err)
+	data := sign(original, handler)
+	dss, _ := validate(data, 1)
+	require.Nil(t, dss)
+
+	// Add the validation data using the OCSP server. The root certificate is
+	// retrieved using the authority information access of the signer.
+	data = enableLTV(data, true)
+	dss, sigs := validate(data, 1)
+	require.NotNil(t, dss)
+	require.Len(t, dss.Certs, 2)
+	require.Len(t, dss.OCSPs, 1)
+	require.Len(t, dss.CRLs, 0)
+
+	key, err := model.GetVRIKey(sigs[0])
+	require.NoError(t, err)
+	require.Len(t, dss.VRI, 1)
+	vri := dss.VRI[key]
+	require.NotNil(t, vri)
+	require.Len(t, vri.Cert, 2)
+	require.Len(t, vri.OCSP, 1)
+	require.NotNil(t, vri.TU)
+
+	// Re-run using the CRL server. The existing DSS must be merged with the
+	// new validation data, without duplicating the existing streams.
+	data = enableLTV(data, false)
+	dss, _ = validate(data, 1)
+	require.Len(t, dss.Certs, 2)
+	require.Len(t, dss.OCSPs, 1)
+	require.Len(t, dss.CRLs, 1)
+	require.Len(t, dss.VRI, 1)
+	vri = dss.VRI[key]
+	require.Len(t, vri.Cert, 2)
+	require.Len(t, vri.OCSP, 1)
+	require.Len(t, vri.CRL, 1)
+
+	// Apply a document timestamp on top of the DSS revision (B-LTA).
+	handler, err = sighandler.NewDocTimeStamp(tsaServer.URL, crypto.SHA256)
+	require.NoError(t, err)
+	data = sign(data, handler)
+	dss, _ = validate(data, 2)
+	require.Len(t, dss.Certs, 2)
+
+	outputPath := tempFile("appender_ltv.pdf")
+	require.NoError(t, ioutil.WriteFile(outputPath, data, 0644))
+}
+
 // Each Appender can only be written out once, further invokations of Write should result in an error.
 func TestAppenderAttemptMultiWrite(t *testing.T) {
 	f1, err := os.Open(testPdfLoremIpsumFile)
@@ -1580,6 +2125,130 @@ func TestAppenderAttemptMultiWrite(t *testing.T) {
 	}
 }
 
+func TestAppenderWithoutTrailerID(t *testing.T) {
+	w := model.NewPdfWriter()
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+	require.NoError(t, w.AddPage(page))
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, w.Write(buf))
+	original := buf.Bytes()
+	require.False(t, bytes.Contains(original, []byte("/ID")))
+
+	reader, err := model.NewPdfReader(bytes.NewReader(original))
+	require.NoError(t, err)
+	appender, err := model.NewPdfAppender(reader)
+	require.NoError(t, err)
+	page = model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+	appender.AddPages(page)
+
+	// The appended trailer has no file identifiers either.
+	buf = bytes.NewBuffer(nil)
+	require.NoError(t, appender.Write(buf))
+	require.True(t, bytes.HasPrefix(buf.Bytes(), original))
+	require.False(t, bytes.Contains(buf.Bytes()[len(original):], []byte("/ID")))
+
+	reader, err = model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 2, numPages)
+	trailer, err := reader.GetTrailer()
+	require.NoError(t, err)
+	require.Nil(t, trailer.Get("ID"))
+}
+
+func TestAppenderIncrementalFormFill(t *testing.T) {
+	server, cert, privateKey := newTestLTVServer(t)
+	defer server.Close()
+
+	original, err := ioutil.ReadFile(testPdfAcroFormFile1)
+	require.NoError(t, err)
+
+	handler, err := sighandler.NewAdobePKCS7Detached(privateKey, cert)
+	require.NoError(t, err)
+	signed := signTestDocument(t, original, handler, nil)
+
+	// Fill in a form field of the signed document.
+	reader, err := model.NewPdfReader(bytes.NewReader(signed))
+	require.NoError(t, err)
+	prevTrailer, err := reader.GetTrailer()
+	require.NoError(t, err)
+	prevSize, ok := core.GetIntVal(prevTrailer.Get("Size"))
+	require.True(t, ok)
+
+	appender, err := model.NewPdfAppender(reader)
+	require.NoError(t, err)
+
+	var filled bool
+	for _, field := range reader.AcroForm.AllFields() {
+		if textField, ok := field.GetContext().(*model.PdfFieldText); ok && field.PartialName() == "Given Name Text Box" {
+			textField.V = core.MakeString("John")
+			filled = true
+		}
+	}
+	require.True(t, filled)
+	appender.ReplaceAcroForm(reader.AcroForm)
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, appender.Write(buf))
+	data := buf.Bytes()
+
+	// The original revision is preserved byte for byte.
+	require.True(t, bytes.HasPrefix(data, signed))
+	update := data[len(signed):]
+	require.Equal(t, bytes.Contains(original, []byte("\ntrailer")), bytes.Contains(update, []byte("\ntrailer")))
+
+	reader, err = model.NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	trailer, err := reader.GetTrailer()
+	require.NoError(t, err)
+
+	// The first file identifier is preserved.
+	prevIDs, ok := core.GetArray(prevTrailer.Get("ID"))
+	require.True(t, ok)
+	ids, ok := core.GetArray(trailer.Get("ID"))
+	require.True(t, ok)
+	require.Equal(t, 2, ids.Len())
+	require.Equal(t, prevIDs.Get(0).String(), ids.Get(0).String())
+	_, ok = core.GetIntVal(trailer.Get("Prev"))
+	require.True(t, ok)
+
+	// New objects are numbered after the original objects.
+	for _, num := range reader.GetObjectNums() {
+		if num < int(prevSize) {
+			continue
+		}
+		obj, err := reader.GetIndirectObjectByNumber(num)
+		require.NoError(t, err)
+		require.True(t, bytes.Contains(update, []byte(fmt.Sprintf("\n%d 0 obj", num))), "object %d (%T)", num, obj)
+	}
+
+	var value string
+	for _, field := range reader.AcroForm.AllFields() {
+		if field.PartialName() == "Given Name Text Box" {
+			value = field.V.String()
+		}
+	}
+	require.Equal(t, "John", value)
+
+	// The existing signature remains valid.
+	root, err := sigutil.NewCertClient().GetIssuer(cert)
+	require.NoError(t, err)
+	validator := model.NewSignatureValidator(handler)
+	validator.TrustedRoots = x509.NewCertPool()
+	validator.TrustedRoots.AddCert(root)
+
+	reports, err := validator.Validate(reader)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	require.True(t, reports[0].DigestValid, reports[0].String())
+	require.False(t, reports[0].CoversWholeDocument)
+	require.True(t, reports[0].ModificationsPermitted, reports[0].String())
+	require.True(t, reports[0].IsValid(true))
+}
+
 func TestAppenderTimestampSign(t *testing.T) {
 	f1, err := os.Open(testPdfFile1)
 	if err != nil {
@@ -8,11 +8,12 @@ package model
 import (
 	"errors"
 	"fmt"
-	"image/color"
 	"math"
+	"sync"
 
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/icc"
 )
 
 // PdfColorspace interface defines the common methods of a PDF colorspace.
@@ -305,7 +306,12 @@ func (cs *PdfColorspaceDeviceGray) ColorToRGB(color PdfColor) (PdfColor, error)
 
 // ImageToRGB convert 1-component grayscale data to 3-component RGB.
 func (cs *PdfColorspaceDeviceGray) ImageToRGB(img Image) (Image, error) {
-	data := make([]byte, 3*img.Width*img.Height)
+	// The 16 bit images are converted to 16 bit RGB images, the others to 8 bit RGB images.
+	bytesPerComponent := 1
+	if img.BitsPerComponent == 16 {
+		bytesPerComponent = 2
+	}
+	data := make([]byte, 3*int(img.Width*img.Height)*bytesPerComponent)
 	for y := 0; y < int(img.Height); y++ {
 		for x := 0; x < int(img.Width); x++ {
 			color, err := img.ColorAt(x, y)
@@ -315,12 +321,18 @@ func (cs *PdfColorspaceDeviceGray) ImageToRGB(img Image) (Image, error) {
 			r, g, b, _ := color.RGBA()
 
 			idx := (y*int(img.Width) + x) * 3
+			if bytesPerComponent == 2 {
+				for k, v := range []uint32{r, g, b} {
+					data[2*(idx+k)], data[2*(idx+k)+1] = uint8(v>>8), uint8(v)
+				}
+				continue
+			}
 			data[idx], data[idx+1], data[idx+2] = uint8(r>>8), uint8(g>>8), uint8(b>>8)
 		}
 	}
 
 	rgbImage := img
-	rgbImage.BitsPerComponent = 8
+	rgbImage.BitsPerComponent = int64(8 * bytesPerComponent)
 	rgbImage.ColorComponents = 3
 	rgbImage.Data = data
 	rgbImage.decode = nil
@@ -467,10 +479,11 @@ func (cs *PdfColorspaceDeviceRGB) ColorToRGB(color PdfColor) (PdfColor, error) {
 	return rgb, nil
 }
 
-// ImageToRGB returns the passed in image. Method exists in order to satisfy
-// the PdfColorspace interface.
+// ImageToRGB returns the passed in image, with its samples mapped by its
+// decode array if set. Method exists in order to satisfy the PdfColorspace
+// interface.
 func (cs *PdfColorspaceDeviceRGB) ImageToRGB(img Image) (Image, error) {
-	return img, nil
+	return applyDecode(img), nil
 }
 
 // ImageToGray returns a new grayscale image based on the passed in RGB image.
@@ -673,36 +686,31 @@ func (cs *PdfColorspaceDeviceCMYK) ImageToRGB(img Image) (Image, error) {
 	maxVal := math.Pow(2, float64(img.BitsPerComponent)) - 1
 	common.Log.Trace("MaxVal: %f", maxVal)
 
-	data := make([]byte, 3*img.Width*img.Height)
-	for l := 0; l < int(img.Height); l++ {
-		for x := 0; x < int(img.Width); x++ {
-			col, err := img.ColorAt(x, l)
-			if err != nil {
-				return img, err
-			}
-			cmyk, ok := col.(color.CMYK)
-			if !ok {
-				return img, errors.New("")
-			}
-
-			// Normalized c, m, y, k values.
-			c := interpolate(float64(cmyk.C), 0, maxVal, decode[0], decode[1])
-			m := interpolate(float64(cmyk.M), 0, maxVal, decode[2], decode[3])
-			y := interpolate(float64(cmyk.Y), 0, maxVal, decode[4], decode[5])
-			k := interpolate(float64(cmyk.K), 0, maxVal, decode[6], decode[7])
-
-			r := uint8(float64(1-(c*(1-k)+k)) * maxVal)
-			g := uint8(float64(1-(m*(1-k)+k)) * maxVal)
-			b := uint8(float64(1-(y*(1-k)+k)) * maxVal)
-
-			idx := (l*int(img.Width) + x) * 3
-			data[idx], data[idx+1], data[idx+2] = r, g, b
+	// The samples of all the bit depths are converted to 8 bit RGB.
+	samples := img.GetSamples()
+	numPixels := int(img.Width * img.Height)
+	if len(samples) < 4*numPixels {
+		return img, errors.New("image data too short")
+	}
+	data := make([]byte, 3*numPixels)
+	for i := 0; i < numPixels; i++ {
+		// Normalized c, m, y, k values.
+		var cmyk [4]float64
+		for j := range cmyk {
+			v := interpolate(float64(samples[4*i+j]), 0, maxVal, decode[2*j], decode[2*j+1])
+			cmyk[j] = math.Min(math.Max(v, 0), 1)
 		}
+		c, m, y, k := cmyk[0], cmyk[1], cmyk[2], cmyk[3]
+
+		data[3*i] = uint8((1 - (c*(1-k) + k)) * 255)
+		data[3*i+1] = uint8((1 - (m*(1-k) + k)) * 255)
+		data[3*i+2] = uint8((1 - (y*(1-k) + k)) * 255)
 	}
 
 	rgbImage.BitsPerComponent = 8
 	rgbImage.ColorComponents = 3
 	rgbImage.Data = data
+	rgbImage.decode = nil
 
 	return rgbImage, nil
 }
@@ -949,6 +957,7 @@ func (cs *PdfColorspaceCalGray) ColorToRGB(color PdfColor) (PdfColor, error) {
 
 // ImageToRGB converts image in CalGray color space to RGB (A, B, C -> X, Y, Z).
 func (cs *PdfColorspaceCalGray) ImageToRGB(img Image) (Image, error) {
+	img = applyDecode(img)
 	rgbImage := img
 
 	samples := img.GetSamples()
@@ -1295,6 +1304,7 @@ func (cs *PdfColorspaceCalRGB) ColorToRGB(color PdfColor) (PdfColor, error) {
 
 // ImageToRGB converts CalRGB colorspace image to RGB and returns the result.
 func (cs *PdfColorspaceCalRGB) ImageToRGB(img Image) (Image, error) {
+	img = applyDecode(img)
 	rgbImage := img
 
 	samples := img.GetSamples()
@@ -1609,23 +1619,25 @@ func (cs *PdfColorspaceLab) ColorFromPdfObjects(objects []core.PdfObject) (PdfCo
 
 // ColorToRGB converts a Lab color to an RGB color.
 func (cs *PdfColorspaceLab) ColorToRGB(color PdfColor) (PdfColor, error) {
-	gFunc := func(x float64) float64 {
-		if x >= 6.0/29 {
-			return x * x * x
-		}
-		return 108.0 / 841 * (x - 4/29)
-	}
-
 	lab, ok := color.(*PdfColorLab)
 	if !ok {
 		common.Log.Debug("input color not lab")
 		return nil, errors.New("type check error")
 	}
 
-	// Get L*, a*, b* values.
-	LStar := lab.L()
-	AStar := lab.A()
-	BStar := lab.B()
+	r, g, b := cs.labToRGB(lab.L(), lab.A(), lab.B())
+	return NewPdfColorDeviceRGB(r, g, b), nil
+}
+
+// labToRGB converts the L*, a*, b* components of a color to sRGB components in the range [0, 1].
+// See section 8.6.5.4 "Lab Colour Spaces" (p. 140 PDF32000_2008).
+func (cs *PdfColorspaceLab) labToRGB(LStar, AStar, BStar float64) (float64, float64, float64) {
+	gFunc := func(x float64) float64 {
+		if x >= 6.0/29 {
+			return x * x * x
+		}
+		return 108.0 / 841 * (x - 4.0/29)
+	}
 
 	// Convert L*,a*,b* -> L, M, N
 	L := (LStar+16)/116 + AStar/500
@@ -1644,23 +1656,19 @@ func (cs *PdfColorspaceLab) ColorToRGB(color PdfColor) (PdfColor, error) {
 	g := -0.969256*X + 1.875992*Y + 0.041556*Z
 	b := 0.055648*X + -0.204043*Y + 1.057311*Z
 
-	// Clip.
-	r = math.Min(math.Max(r, 0), 1.0)
-	g = math.Min(math.Max(g, 0), 1.0)
-	b = math.Min(math.Max(b, 0), 1.0)
-
-	return NewPdfColorDeviceRGB(r, g, b), nil
+	// Linear RGB -> sRGB, clipped.
+	gamma := func(v float64) float64 {
+		v = math.Min(math.Max(v, 0), 1.0)
+		if v <= 0.0031308 {
+			return 12.92 * v
+		}
+		return 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+	return gamma(r), gamma(g), gamma(b)
 }
 
 // ImageToRGB converts Lab colorspace image to RGB and returns the result.
 func (cs *PdfColorspaceLab) ImageToRGB(img Image) (Image, error) {
-	g := func(x float64) float64 {
-		if x >= 6.0/29 {
-			return x * x * x
-		}
-		return 108.0 / 841 * (x - 4/29)
-	}
-
 	rgbImage := img
 
 	// Each n-bit unit within the bit stream shall be interpreted as an unsigned integer in the range 0 to 2n- 1,
@@ -1689,27 +1697,7 @@ func (cs *PdfColorspaceLab) ImageToRGB(img Image) (Image, error) {
 		AStar := interpolate(ANorm, 0.0, 1.0, componentRanges[2], componentRanges[3])
 		BStar := interpolate(BNorm, 0.0, 1.0, componentRanges[4], componentRanges[5])
 
-		// Convert L*,a*,b* -> L, M, N
-		L := (LStar+16)/116 + AStar/500
-		M := (LStar + 16) / 116
-		N := (LStar+16)/116 - BStar/200
-
-		// L, M, N -> X,Y,Z
-		X := cs.WhitePoint[0] * g(L)
-		Y := cs.WhitePoint[1] * g(M)
-		Z := cs.WhitePoint[2] * g(N)
-
-		// Convert to RGB.
-		// X, Y, Z -> R, G, B
-		// http://stackoverflow.com/questions/21576719/how-to-convert-cie-color-space-into-rgb-or-hex-color-code-in-php
-		r := 3.240479*X + -1.537150*Y + -0.498535*Z
-		g := -0.969256*X + 1.875992*Y + 0.041556*Z
-		b := 0.055648*X + -0.204043*Y + 1.057311*Z
-
-		// Clip.
-		r = math.Min(math.Max(r, 0), 1.0)
-		g = math.Min(math.Max(g, 0), 1.0)
-		b = math.Min(math.Max(b, 0), 1.0)
+		r, g, b := cs.labToRGB(LStar, AStar, BStar)
 
 		// Convert to uint32.
 		R := uint32(r * maxVal)
@@ -1720,6 +1708,7 @@ func (cs *PdfColorspaceLab) ImageToRGB(img Image) (Image, error) {
 	}
 	rgbImage.SetSamples(rgbSamples)
 	rgbImage.ColorComponents = 3
+	rgbImage.decode = nil
 
 	return rgbImage, nil
 }
@@ -1763,7 +1752,8 @@ func (this *PdfColorICCBased) ToInteger(bits int) []uint32 {
 // A conforming reader shall support ICC.1:2004:10 as required by PDF 1.7, which will enable it
 // to properly render all embedded ICC profiles regardless of the PDF version
 //
-// In the current implementation, we rely on the alternative colormap provided.
+// Images are converted to RGB with the embedded profile when it is supported, see ImageToRGB.
+// Otherwise, and for colors, we rely on the alternative colormap provided.
 type PdfColorspaceICCBased struct {
 	N         int           // Number of color components (Required). Can be 1,3, or 4.
 	Alternate PdfColorspace // Alternate colorspace for non-conforming readers.
@@ -1775,6 +1765,10 @@ type PdfColorspaceICCBased struct {
 
 	container *core.PdfIndirectObject
 	stream    *core.PdfObjectStream
+
+	// profileOnce guards the parsing of the ICC profile in Data, on first use.
+	profileOnce *sync.Once
+	profile     *icc.Profile
 }
 
 // GetNumComponents returns the number of color components.
@@ -1793,7 +1787,7 @@ func (cs *PdfColorspaceICCBased) String() string {
 
 // NewPdfColorspaceICCBased returns a new ICCBased colorspace object.
 func NewPdfColorspaceICCBased(N int) (*PdfColorspaceICCBased, error) {
-	cs := &PdfColorspaceICCBased{}
+	cs := &PdfColorspaceICCBased{profileOnce: &sync.Once{}}
 
 	if N != 1 && N != 3 && N != 4 {
 		return nil, fmt.Errorf("invalid N (1/3/4)")
@@ -1806,7 +1800,7 @@ func NewPdfColorspaceICCBased(N int) (*PdfColorspaceICCBased, error) {
 
 // Input format [/ICCBased stream]
 func newPdfColorspaceICCBasedFromPdfObject(obj core.PdfObject) (*PdfColorspaceICCBased, error) {
-	cs := &PdfColorspaceICCBased{}
+	cs := &PdfColorspaceICCBased{profileOnce: &sync.Once{}}
 	if indObj, isIndirect := obj.(*core.PdfIndirectObject); isIndirect {
 		cs.container = indObj
 	}
@@ -1842,7 +1836,7 @@ func newPdfColorspaceICCBasedFromPdfObject(obj core.PdfObject) (*PdfColorspaceIC
 
 	dict := stream.PdfObjectDictionary
 
-	n, ok := dict.Get("N").(*core.PdfObjectInteger)
+	n, ok := core.GetInt(dict.Get("N"))
 	if !ok {
 		return nil, fmt.Errorf("ICCBased missing N from stream dict")
 	}
@@ -2016,7 +2010,12 @@ func (cs *PdfColorspaceICCBased) ColorToRGB(color PdfColor) (PdfColor, error) {
 }
 
 // ImageToRGB converts ICCBased colorspace image to RGB and returns the result.
+// The image is converted to sRGB with the ICC profile of the colorspace if it
+// is supported, or with the alternate colorspace otherwise.
 func (cs *PdfColorspaceICCBased) ImageToRGB(img Image) (Image, error) {
+	if profile := cs.iccProfile(); profile != nil {
+		return imageToSRGB(img, cs.N, profile.ToSRGB)
+	}
 	if cs.Alternate == nil {
 		common.Log.Debug("ICC Based colorspace missing alternative")
 		if cs.N == 1 {
@@ -2026,7 +2025,7 @@ func (cs *PdfColorspaceICCBased) ImageToRGB(img Image) (Image, error) {
 		} else if cs.N == 3 {
 			common.Log.Debug("ICC Based colorspace missing alternative - using DeviceRGB (N=3)")
 			// Already in RGB.
-			return img, nil
+			return applyDecode(img), nil
 		} else if cs.N == 4 {
 			common.Log.Debug("ICC Based colorspace missing alternative - using DeviceCMYK (N=4)")
 			// CMYK
@@ -2355,10 +2354,16 @@ func (cs *PdfColorspaceSpecialIndexed) ColorFromFloats(vals []float64) (PdfColor
 		return nil, errors.New("outside range")
 	}
 
+	// The lookup table components are mapped to the ranges of the base colorspace components.
 	cvals := cs.colorLookup[index : index+N]
+	decode := cs.Base.DecodeArray()
 	var floats []float64
-	for _, val := range cvals {
-		floats = append(floats, float64(val)/255.0)
+	for i, val := range cvals {
+		if 2*i+1 < len(decode) {
+			floats = append(floats, interpolate(float64(val), 0, 255, decode[2*i], decode[2*i+1]))
+		} else {
+			floats = append(floats, float64(val)/255.0)
+		}
 	}
 	color, err := cs.Base.ColorFromFloats(floats)
 	if err != nil {
@@ -2394,12 +2399,25 @@ func (cs *PdfColorspaceSpecialIndexed) ColorToRGB(color PdfColor) (PdfColor, err
 
 // ImageToRGB convert an indexed image to RGB.
 func (cs *PdfColorspaceSpecialIndexed) ImageToRGB(img Image) (Image, error) {
+	baseImage, err := cs.ImageToBase(img)
+	if err != nil {
+		return Image{}, err
+	}
+
+	// Convert to rgb.
+	return cs.Base.ImageToRGB(baseImage)
+}
+
+// ImageToBase converts the indexed image `img` to an image in the base
+// colorspace, looking up the colors of its samples in the color table.
+func (cs *PdfColorspaceSpecialIndexed) ImageToBase(img Image) (Image, error) {
 	//baseImage := img
 	// Make a new representation of the image to be converted with the base colorspace.
 	baseImage := Image{}
 	baseImage.Height = img.Height
 	baseImage.Width = img.Width
 	baseImage.alphaData = img.alphaData
+	baseImage.Interpolate = img.Interpolate
 	// TODO(peterwilliams97): Add support for other BitsPerComponent values.
 	// See https://github.com/unidoc/unipdf/issues/260
 	baseImage.BitsPerComponent = 8
@@ -2413,6 +2431,15 @@ func (cs *PdfColorspaceSpecialIndexed) ImageToRGB(img Image) (Image, error) {
 		return Image{}, fmt.Errorf("bad base colorspace NumComponents=%d", N)
 	}
 
+	if len(img.decode) == 2 {
+		// The decode array maps the samples to the indices, e.g. [N 0] reverses the color table.
+		maxVal := float64(uint32(1)<<uint32(img.BitsPerComponent) - 1)
+		for i, v := range samples {
+			index := interpolate(float64(v), 0, maxVal, img.decode[0], img.decode[1])
+			samples[i] = uint32(math.Min(math.Max(math.Round(index), 0), maxVal))
+		}
+	}
+
 	var baseSamples []uint32
 	// Convert the indexed data to base color map data.
 	for i := 0; i < len(samples); i++ {
@@ -2443,8 +2470,7 @@ func (cs *PdfColorspaceSpecialIndexed) ImageToRGB(img Image) (Image, error) {
 	common.Log.Trace("Input samples: %d", samples)
 	common.Log.Trace("-> Output samples: %d", baseSamples)
 
-	// Convert to rgb.
-	return cs.Base.ImageToRGB(baseImage)
+	return baseImage, nil
 }
 
 // ToPdfObject converts colorspace to a PDF object. [/Indexed base hival lookup]
@@ -2627,6 +2653,7 @@ func (cs *PdfColorspaceSpecialSeparation) ColorToRGB(color PdfColor) (PdfColor,
 // ImageToRGB converts an image with samples in Separation CS to an image with samples specified in
 // DeviceRGB CS.
 func (cs *PdfColorspaceSpecialSeparation) ImageToRGB(img Image) (Image, error) {
+	img = applyDecode(img)
 	altImage := img
 
 	samples := img.GetSamples()
@@ -2846,14 +2873,16 @@ func (cs *PdfColorspaceDeviceN) ColorToRGB(color PdfColor) (PdfColor, error) {
 
 // ImageToRGB converts an Image in a given PdfColorspace to an RGB image.
 func (cs *PdfColorspaceDeviceN) ImageToRGB(img Image) (Image, error) {
+	img = applyDecode(img)
 	altImage := img
 
 	samples := img.GetSamples()
 	maxVal := math.Pow(2, float64(img.BitsPerComponent)) - 1
+	altDecode := cs.AlternateSpace.DecodeArray()
 
 	// Convert tints to color data in the alternate colorspace.
 	var altSamples []uint32
-	for i := 0; i < len(samples); i += cs.GetNumComponents() {
+	for i := 0; i+cs.GetNumComponents() <= len(samples); i += cs.GetNumComponents() {
 		// The input to the tint transformation is the tint
 		// for each color component.
 		//
@@ -2871,8 +2900,11 @@ func (cs *PdfColorspaceDeviceN) ImageToRGB(img Image) (Image, error) {
 			return img, err
 		}
 
-		for _, val := range outputs {
-			// Clip.
+		for j, val := range outputs {
+			// Convert component value to 0-1 range, clipped.
+			if 2*j+1 < len(altDecode) {
+				val = interpolate(val, altDecode[2*j], altDecode[2*j+1], 0, 1)
+			}
 			val = math.Min(math.Max(0, val), 1.0)
 			// Rescale to [0, maxVal]
 			altComponent := uint32(val * maxVal)
@@ -2880,6 +2912,10 @@ func (cs *PdfColorspaceDeviceN) ImageToRGB(img Image) (Image, error) {
 		}
 	}
 	altImage.SetSamples(altSamples)
+	altImage.ColorComponents = cs.AlternateSpace.GetNumComponents()
+
+	// Set the image's decode parameters for interpretation in the alternative CS.
+	altImage.decode = altDecode
 
 	// Convert to RGB via the alternate colorspace.
 	return cs.AlternateSpace.ImageToRGB(altImage)
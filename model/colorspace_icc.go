@@ -0,0 +1,147 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"errors"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/internal/icc"
+)
+
+// iccProfile returns the ICC profile of the colorspace, or nil if it is
+// missing, invalid, not supported or does not describe colors with the
+// number of components of the colorspace.
+func (cs *PdfColorspaceICCBased) iccProfile() *icc.Profile {
+	parse := func() *icc.Profile {
+		if len(cs.Data) == 0 {
+			return nil
+		}
+		profile, err := icc.Parse(cs.Data)
+		if err != nil {
+			common.Log.Debug("ICC profile not supported: %v. Using the alternate colorspace", err)
+			return nil
+		}
+		switch profile.ColorSpace {
+		case icc.ColorSpaceGray, icc.ColorSpaceRGB, icc.ColorSpaceCMYK:
+		default:
+			common.Log.Debug("ICC profile color space %q not supported", profile.ColorSpace)
+			return nil
+		}
+		if profile.NumComponents() != cs.N {
+			common.Log.Debug("ICC profile has %d components, expected %d", profile.NumComponents(), cs.N)
+			return nil
+		}
+		return profile
+	}
+
+	if cs.profileOnce == nil {
+		return parse()
+	}
+	cs.profileOnce.Do(func() {
+		cs.profile = parse()
+	})
+	return cs.profile
+}
+
+// ImageToSRGB converts image `img` in colorspace `cs` to an 8 bit sRGB image.
+// It differs from cs.ImageToRGB, which implements the conversions of the PDF
+// specification, for the CMYK images without ICC profile: the DeviceCMYK
+// images, and the ICCBased CMYK images whose profile is not supported, are
+// converted with an approximation of a SWOP coated press rather than with the
+// naive conversion, which renders them too saturated and too light. Indexed
+// images are converted through their color table first.
+func ImageToSRGB(cs PdfColorspace, img Image) (Image, error) {
+	switch t := cs.(type) {
+	case *PdfColorspaceDeviceCMYK:
+		return imageToSRGB(img, 4, cmykToSRGB)
+	case *PdfColorspaceICCBased:
+		if t.N == 4 && t.iccProfile() == nil {
+			return imageToSRGB(img, 4, cmykToSRGB)
+		}
+	case *PdfColorspaceSpecialIndexed:
+		if t.Base == nil {
+			return Image{}, errors.New("indexed base colorspace undefined")
+		}
+		baseImg, err := t.ImageToBase(img)
+		if err != nil {
+			return Image{}, err
+		}
+		return ImageToSRGB(t.Base, baseImg)
+	}
+	return cs.ImageToRGB(img)
+}
+
+// cmykToSRGB converts the CMYK color `in` to sRGB, approximating a SWOP
+// coated press.
+func cmykToSRGB(in []float64) (float64, float64, float64) {
+	return icc.CMYKToSRGB(in[0], in[1], in[2], in[3])
+}
+
+// imageToSRGB converts image `img`, whose samples have `n` components, to an
+// 8 bit RGB image, converting the colors with `convert`. The components of
+// the colors passed to `convert` are the samples mapped to range 0-1 with the
+// decode array of the image.
+func imageToSRGB(img Image, n int, convert func(in []float64) (float64, float64, float64)) (Image, error) {
+	if img.ColorComponents != n {
+		return img, errors.New("image and colorspace components mismatch")
+	}
+	decode := img.decode
+	if len(decode) != 2*n {
+		decode = make([]float64, 2*n)
+		for i := 0; i < n; i++ {
+			decode[2*i+1] = 1
+		}
+	}
+
+	samples := img.GetSamples()
+	numPixels := int(img.Width * img.Height)
+	if len(samples) < n*numPixels {
+		return img, errors.New("image data too short")
+	}
+
+	maxVal := float64(uint32(1)<<uint32(img.BitsPerComponent) - 1)
+
+	// The colors of the images with up to 32 bits per pixel are converted
+	// once.
+	var cache map[uint32][3]byte
+	if int(img.BitsPerComponent)*n <= 32 {
+		cache = map[uint32][3]byte{}
+	}
+
+	in := make([]float64, n)
+	data := make([]byte, 3*numPixels)
+	for i := 0; i < numPixels; i++ {
+		pixel := samples[n*i : n*(i+1)]
+		var key uint32
+		if cache != nil {
+			for _, v := range pixel {
+				key = key<<uint32(img.BitsPerComponent) | v
+			}
+			if rgb, ok := cache[key]; ok {
+				copy(data[3*i:], rgb[:])
+				continue
+			}
+		}
+
+		for j, v := range pixel {
+			in[j] = interpolate(float64(v), 0, maxVal, decode[2*j], decode[2*j+1])
+		}
+		r, g, b := convert(in)
+		rgb := [3]byte{byte(r*255 + 0.5), byte(g*255 + 0.5), byte(b*255 + 0.5)}
+		copy(data[3*i:], rgb[:])
+		if cache != nil {
+			cache[key] = rgb
+		}
+	}
+
+	rgbImage := img
+	rgbImage.BitsPerComponent = 8
+	rgbImage.ColorComponents = 3
+	rgbImage.Data = data
+	rgbImage.decode = nil
+	return rgbImage, nil
+}
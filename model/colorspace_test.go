@@ -11,23 +11,49 @@ import (
 	"io/ioutil"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"github.com/unidoc/unipdf/v3/core"
 	"github.com/unidoc/unipdf/v3/internal/testutils"
 )
 
+// loadColorspace loads the colorspace of object 5 in `rawObject`.
+func loadColorspace(t *testing.T, rawObject string) PdfColorspace {
+	objMap, err := testutils.ParseIndirectObjects(rawObject)
+	require.NoError(t, err)
+	cs, err := NewPdfColorspaceFromPdfObject(objMap[5])
+	require.NoError(t, err)
+	return cs
+}
+
+// testColorToRGB checks that the colors of `cs` with components `vals` convert to RGB colors
+// `expected`.
+func testColorToRGB(t *testing.T, cs PdfColorspace, vals [][]float64, expected [][3]float64) {
+	for i, v := range vals {
+		color, err := cs.ColorFromFloats(v)
+		require.NoError(t, err)
+		rgbColor, err := cs.ColorToRGB(color)
+		require.NoError(t, err)
+		rgb, ok := rgbColor.(*PdfColorDeviceRGB)
+		require.True(t, ok)
+		got := [3]float64{rgb.R(), rgb.G(), rgb.B()}
+		for j := range got {
+			require.InDelta(t, expected[i][j], got[j], 0.005, "%s %v: %v", cs, v, got)
+		}
+	}
+}
+
 func TestSeparationCS1(t *testing.T) {
 	rawObject := `
-% Colour space
 5 0 obj
 [ /Separation /LogoGreen /DeviceCMYK 12 0 R ]
 endobj
-% Tint transformation function
 12 0 obj
 <<
 	/FunctionType 4
 	/Domain [0.0 1.0]
 	/Range [ 0.0 1.0 0.0 1.0 0.0 1.0 0.0 1.0 ]
-	/Length 65
+	/Length 59
 >>
 stream
 { dup 0.84 mul
@@ -35,19 +61,117 @@ exch 0.00 exch dup 0.44 mul exch 0.21 mul
 }
 endstream endobj
 	`
+	cs := loadColorspace(t, rawObject)
+	require.IsType(t, &PdfColorspaceSpecialSeparation{}, cs)
 
-	// Test a few lookups and see if it is accurate.
-	// Test rgb conversion for a few specific values also.
-
-	fmt.Println(rawObject)
+	// The tint t is transformed to the CMYK color (0.84t, 0, 0.44t, 0.21t).
+	testColorToRGB(t, cs,
+		[][]float64{{0}, {0.5}, {1}},
+		[][3]float64{{1, 1, 1}, {0.5191, 0.895, 0.6981}, {0.1264, 0.79, 0.4424}})
 
-	//t.Errorf("Test not implemented yet")
+	// Image samples are converted the same way.
+	img := Image{Width: 3, Height: 1, BitsPerComponent: 8, ColorComponents: 1, Data: []byte{0, 128, 255}}
+	rgbImg, err := cs.ImageToRGB(img)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, rgbImg.ColorComponents)
+	expected := []byte{255, 255, 255, 132, 228, 179, 32, 201, 113}
+	require.Len(t, rgbImg.Data, len(expected))
+	for i := range expected {
+		require.InDelta(t, expected[i], rgbImg.Data[i], 1, "%d: %v", i, rgbImg.Data)
+	}
 }
 
 func TestDeviceNCS1(t *testing.T) {
-	// Implement Example 3 on p. 172
+	// Two colorants mapped to the cyan and magenta components of DeviceCMYK.
+	cs := loadColorspace(t, `
+5 0 obj
+[ /DeviceN [/Cyan /Spot] /DeviceCMYK 12 0 R ]
+endobj
+12 0 obj
+<<
+	/FunctionType 4
+	/Domain [0 1 0 1]
+	/Range [0 1 0 1 0 1 0 1]
+	/Length 7
+>>
+stream
+{ 0 0 }
+endstream endobj
+`)
+	require.IsType(t, &PdfColorspaceDeviceN{}, cs)
+	testColorToRGB(t, cs,
+		[][]float64{{0, 0}, {1, 0.5}},
+		[][3]float64{{1, 1, 1}, {0, 0.5, 1}})
 
-	//t.Errorf("Test not implemented yet")
+	img := Image{Width: 2, Height: 1, BitsPerComponent: 8, ColorComponents: 2, Data: []byte{255, 0, 0, 255}}
+	rgbImg, err := cs.ImageToRGB(img)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0, 255, 255, 255, 0, 255}, rgbImg.Data)
+}
+
+func TestIndexedCS(t *testing.T) {
+	cs := loadColorspace(t, `
+5 0 obj
+[ /Indexed /DeviceRGB 2 <FF000000FF00000080> ]
+endobj
+`)
+	testColorToRGB(t, cs,
+		[][]float64{{0}, {1}, {2}},
+		[][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 128.0 / 255}})
+
+	_, err := cs.ColorFromFloats([]float64{3})
+	require.Error(t, err)
+
+	// The lookup table components are mapped to the ranges of the a* and b* components of Lab.
+	cs = loadColorspace(t, `
+5 0 obj
+[ /Indexed [/Lab << /WhitePoint [0.9505 1.0 1.089] >>] 0 <FF7F7F> ]
+endobj
+`)
+	color, err := cs.ColorFromFloats([]float64{0})
+	require.NoError(t, err)
+	lab, ok := color.(*PdfColorLab)
+	require.True(t, ok)
+	require.InDelta(t, 100, lab.L(), 1e-6)
+	require.InDelta(t, -0.39, lab.A(), 0.01)
+	require.InDelta(t, -0.39, lab.B(), 0.01)
+}
+
+func TestLabCS(t *testing.T) {
+	// D65 white point, so that the Lab colors map to sRGB colors.
+	cs := loadColorspace(t, `
+5 0 obj
+[ /Lab << /WhitePoint [0.9505 1.0 1.089] /Range [-128 127 -128 127] >> ]
+endobj
+`)
+	testColorToRGB(t, cs,
+		[][]float64{{100, 0, 0}, {0, 0, 0}, {53.24, 80.09, 67.2}, {87.73, -86.18, 83.18}, {53.39, 0, 0}},
+		[][3]float64{{1, 1, 1}, {0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {0.502, 0.502, 0.502}})
+}
+
+func TestICCBasedCS(t *testing.T) {
+	// Without Alternate, N selects the device colorspace.
+	for _, tc := range []struct {
+		n        int
+		vals     []float64
+		expected [3]float64
+	}{
+		{1, []float64{0.5}, [3]float64{0.5, 0.5, 0.5}},
+		{3, []float64{0.1, 0.2, 0.3}, [3]float64{0.1, 0.2, 0.3}},
+		{4, []float64{1, 0, 0, 0.5}, [3]float64{0, 0.5, 0.5}},
+	} {
+		cs := loadColorspace(t, fmt.Sprintf(`
+5 0 obj
+[ /ICCBased 6 0 R ]
+endobj
+6 0 obj
+<< /N %d /Length 0 >>
+stream
+endstream endobj
+`, tc.n))
+		require.Equal(t, tc.n, cs.GetNumComponents())
+		testColorToRGB(t, cs, [][]float64{tc.vals}, [][3]float64{tc.expected})
+	}
 }
 
 // Bug with outputing Separation colorspaces using a Function0 function.
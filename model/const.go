@@ -7,6 +7,8 @@ package model
 
 import (
 	"errors"
+
+	"github.com/unidoc/unipdf/v3/core"
 )
 
 // Errors when parsing/loading data in PDF.
@@ -15,8 +17,9 @@ var (
 	ErrRequiredAttributeMissing = errors.New("required attribute missing")
 	ErrInvalidAttribute         = errors.New("invalid attribute")
 	ErrTypeCheck                = errors.New("type check")
-	errRangeError               = errors.New("range check error")
-	ErrEncrypted                = errors.New("file needs to be decrypted first")
+	errRangeError               = core.ErrRangeError
+	ErrEncrypted                = core.ErrEncrypted
+	ErrWrongPassword            = core.ErrWrongPassword
 	ErrNoFont                   = errors.New("font not defined")
 	ErrFontNotSupported         = errors.New("unsupported font")
 	ErrType1CFontNotSupported   = errors.New("Type1C fonts are not currently supported")
@@ -0,0 +1,137 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"io"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// UnrestrictedWriteOptions defines the encryption of the documents written by
+// WriteUnrestricted.
+type UnrestrictedWriteOptions struct {
+	// Encryption, if set, re-encrypts the document with the passwords
+	// UserPassword and OwnerPassword and the permissions and algorithm of the
+	// options. The document is written unencrypted otherwise.
+	Encryption    *EncryptOptions
+	UserPassword  []byte
+	OwnerPassword []byte
+}
+
+// WriteUnrestricted writes the decrypted document to `ws`, without its
+// encryption dictionary and thus without the restrictions of its owner
+// password, or re-encrypted with the passwords and permissions specified by
+// `opts`, which can be nil.
+// The document must have been decrypted, e.g. with the empty user password
+// when opening it or with Decrypt, otherwise ErrEncrypted is returned. All
+// the strings and streams of the document are written decrypted, and the
+// Crypt filters of the streams, which select the crypt filters of the
+// original security handler, are removed. The objects reachable from the
+// catalog and the document information dictionary are written.
+func (r *PdfReader) WriteUnrestricted(ws io.Writer, opts *UnrestrictedWriteOptions) error {
+	if err := r.checkDecrypted(); err != nil {
+		return err
+	}
+	if opts == nil {
+		opts = &UnrestrictedWriteOptions{}
+	}
+
+	// Loading the objects decrypts them.
+	for _, num := range r.GetObjectNums() {
+		obj, err := r.GetIndirectObjectByNumber(num)
+		if err != nil {
+			common.Log.Debug("ERROR: unable to load object %d: %v", num, err)
+			continue
+		}
+		if stream, ok := obj.(*core.PdfObjectStream); ok {
+			removeCryptFilter(stream)
+		}
+	}
+	if err := r.traverseObjectData(r.catalog); err != nil {
+		return err
+	}
+
+	w := NewPdfWriter()
+	version := r.PdfVersion()
+	w.SetVersion(version.Major, version.Minor)
+	numPages, err := r.GetNumPages()
+	if err != nil {
+		return err
+	}
+	for i := 1; i <= numPages; i++ {
+		page, err := r.GetPage(i)
+		if err != nil {
+			return err
+		}
+		if err := w.AddPage(page); err != nil {
+			return err
+		}
+	}
+	for _, key := range r.catalog.Keys() {
+		switch key {
+		case "Type", "Pages":
+			continue
+		}
+		obj := core.ResolveReference(r.catalog.Get(key))
+		w.catalog.Set(key, obj)
+		if err := w.addObjects(obj); err != nil {
+			return err
+		}
+	}
+	info, err := r.GetInfoDict()
+	if err != nil {
+		return err
+	}
+	if info != nil {
+		if err := r.traverseObjectData(info); err != nil {
+			return err
+		}
+		infoDict := w.GetInfoDict()
+		for _, key := range info.Keys() {
+			infoDict.Set(key, info.Get(key))
+		}
+		if err := w.addObjects(infoDict); err != nil {
+			return err
+		}
+	}
+
+	if opts.Encryption != nil {
+		if err := w.Encrypt(opts.UserPassword, opts.OwnerPassword, opts.Encryption); err != nil {
+			return err
+		}
+	}
+	return w.Write(ws)
+}
+
+// removeCryptFilter removes the Crypt filter, which can only be the first
+// filter, from the filters of `stream`.
+func removeCryptFilter(stream *core.PdfObjectStream) {
+	switch filters := core.TraceToDirectObject(stream.Get("Filter")).(type) {
+	case *core.PdfObjectName:
+		if *filters == core.StreamEncodingFilterNameCrypt {
+			stream.Remove("Filter")
+			stream.Remove("DecodeParms")
+		}
+	case *core.PdfObjectArray:
+		name, ok := core.GetNameVal(filters.Get(0))
+		if !ok || name != core.StreamEncodingFilterNameCrypt {
+			return
+		}
+		if filters.Len() == 1 {
+			stream.Remove("Filter")
+			stream.Remove("DecodeParms")
+			return
+		}
+		stream.Set("Filter", core.MakeArray(filters.Elements()[1:]...))
+		if params, ok := core.GetArray(stream.Get("DecodeParms")); ok && params.Len() > 1 {
+			stream.Set("DecodeParms", core.MakeArray(params.Elements()[1:]...))
+		} else {
+			stream.Remove("DecodeParms")
+		}
+	}
+}
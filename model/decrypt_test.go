@@ -0,0 +1,169 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/core/security"
+)
+
+// makeRestrictedPdf returns a document encrypted with `userPass` and `ownerPass` and the options
+// `opts`, with a page showing text, an annotation and a title. The page content stream has a
+// Crypt filter if `identity` is set, leaving it unencrypted, and the document has XMP metadata
+// if `opts` leaves the metadata unencrypted.
+func makeRestrictedPdf(t *testing.T, userPass, ownerPass string, opts *EncryptOptions, identity bool) []byte {
+	w := NewPdfWriter()
+	page := NewPdfPage()
+	page.MediaBox = &PdfRectangle{Urx: 200, Ury: 200}
+	contents := []byte("BT /F1 12 Tf 10 10 Td (Restricted content) Tj ET")
+	var encoder core.StreamEncoder = core.NewFlateEncoder()
+	if identity {
+		encoder = core.NewCryptEncoder("")
+	}
+	stream, err := core.MakeStream(contents, encoder)
+	require.NoError(t, err)
+	page.Contents = stream
+	annot := NewPdfAnnotationText()
+	annot.Rect = core.MakeArrayFromIntegers([]int{0, 0, 20, 20})
+	annot.Contents = core.MakeString("Annotation text")
+	page.AddAnnotation(annot.PdfAnnotation)
+	require.NoError(t, w.AddPage(page))
+	w.GetInfoDict().Set("Title", core.MakeString("Restricted title"))
+	if opts.UnencryptedMetadata {
+		m := NewXMPMetadata()
+		m.Title = "Metadata title"
+		require.NoError(t, w.SetXMPMetadata(m))
+	}
+	require.NoError(t, w.Encrypt([]byte(userPass), []byte(ownerPass), opts))
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	return buf.Bytes()
+}
+
+// checkUnrestrictedPdf checks that the document `data` written by WriteUnrestricted has the
+// content of the restricted document.
+func checkUnrestrictedPdf(t *testing.T, data []byte) *PdfReader {
+	r, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.True(t, r.IsDecrypted())
+	page, err := r.GetPage(1)
+	require.NoError(t, err)
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	require.Contains(t, contents, "(Restricted content) Tj")
+	annots, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 1)
+	text, ok := core.GetString(annots[0].Contents)
+	require.True(t, ok)
+	require.Equal(t, "Annotation text", text.Decoded())
+	info, err := r.GetInfoDict()
+	require.NoError(t, err)
+	title, ok := core.GetString(info.Get("Title"))
+	require.True(t, ok)
+	require.Equal(t, "Restricted title", title.Decoded())
+	return r
+}
+
+func TestWriteUnrestricted(t *testing.T) {
+	perm := security.PermOwner &^ (security.PermPrinting | security.PermExtractGraphics)
+	for _, algo := range []EncryptionAlgorithm{RC4_128bit, AES_128bit, AES_256bit} {
+		data := makeRestrictedPdf(t, "", "owner", &EncryptOptions{Permissions: perm, Algorithm: algo}, false)
+		r, err := NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		require.True(t, r.IsDecrypted())
+		_, perms, err := r.CheckAccessRights(nil)
+		require.NoError(t, err)
+		require.False(t, perms.Allowed(security.PermPrinting))
+
+		var buf bytes.Buffer
+		require.NoError(t, r.WriteUnrestricted(&buf, nil))
+		require.NotContains(t, buf.String(), "/Encrypt")
+
+		unrestricted := checkUnrestrictedPdf(t, buf.Bytes())
+		encrypted, err := unrestricted.IsEncrypted()
+		require.NoError(t, err)
+		require.False(t, encrypted)
+		_, perms, err = unrestricted.CheckAccessRights(nil)
+		require.NoError(t, err)
+		require.Equal(t, security.PermOwner, perms)
+	}
+}
+
+func TestWriteUnrestrictedNotDecrypted(t *testing.T) {
+	data := makeRestrictedPdf(t, "user", "owner", &EncryptOptions{
+		Permissions: security.PermPrinting,
+		Algorithm:   AES_128bit,
+	}, false)
+	r, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.Equal(t, ErrEncrypted, r.WriteUnrestricted(&buf, nil))
+
+	ok, err := r.Decrypt([]byte("wrong"))
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, ErrEncrypted, r.WriteUnrestricted(&buf, nil))
+	require.Zero(t, buf.Len())
+
+	// The user password decrypts the document.
+	ok, err = r.Decrypt([]byte("user"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, r.WriteUnrestricted(&buf, nil))
+	checkUnrestrictedPdf(t, buf.Bytes())
+}
+
+func TestWriteUnrestrictedReencrypt(t *testing.T) {
+	data := makeRestrictedPdf(t, "", "owner", &EncryptOptions{
+		Permissions: security.PermAnnotate,
+		Algorithm:   RC4_128bit,
+	}, false)
+	r, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	perm := security.PermPrinting | security.PermExtractGraphics | security.PermFullPrintQuality
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteUnrestricted(&buf, &UnrestrictedWriteOptions{
+		Encryption:    &EncryptOptions{Permissions: perm, Algorithm: AES_256bit},
+		OwnerPassword: []byte("archive"),
+	}))
+
+	reencrypted := checkUnrestrictedPdf(t, buf.Bytes())
+	encrypted, err := reencrypted.IsEncrypted()
+	require.NoError(t, err)
+	require.True(t, encrypted)
+	_, perms, err := reencrypted.CheckAccessRights(nil)
+	require.NoError(t, err)
+	require.True(t, perms.Allowed(perm))
+	require.False(t, perms.Allowed(security.PermModify))
+}
+
+func TestWriteUnrestrictedCryptFilters(t *testing.T) {
+	data := makeRestrictedPdf(t, "", "owner", &EncryptOptions{
+		Permissions:         security.PermAnnotate,
+		Algorithm:           AES_128bit,
+		UnencryptedMetadata: true,
+	}, true)
+	require.Contains(t, string(data), "/Crypt")
+	r, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteUnrestricted(&buf, nil))
+	require.NotContains(t, buf.String(), "/Crypt")
+
+	unrestricted := checkUnrestrictedPdf(t, buf.Bytes())
+	m, err := unrestricted.GetXMPMetadata()
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	require.Equal(t, "Metadata title", m.Title)
+}
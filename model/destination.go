@@ -0,0 +1,278 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// PdfDestinationMode represents the way a destination page is displayed.
+// See section 12.3.2.2 "Explicit Destinations" (p. 374 PDF32000_2008).
+type PdfDestinationMode string
+
+// Destination display modes.
+const (
+	DestinationModeXYZ   PdfDestinationMode = "XYZ"
+	DestinationModeFit   PdfDestinationMode = "Fit"
+	DestinationModeFitH  PdfDestinationMode = "FitH"
+	DestinationModeFitV  PdfDestinationMode = "FitV"
+	DestinationModeFitR  PdfDestinationMode = "FitR"
+	DestinationModeFitB  PdfDestinationMode = "FitB"
+	DestinationModeFitBH PdfDestinationMode = "FitBH"
+	DestinationModeFitBV PdfDestinationMode = "FitBV"
+)
+
+// PdfDestination represents an explicit destination: a page of a document
+// and the way it is displayed. Nil parameters are written as null, meaning
+// that the current value of the parameter is left unchanged by viewers.
+type PdfDestination struct {
+	// Page is the target page of destinations in the current document.
+	Page *core.PdfIndirectObject
+	// PageIndex is the zero based index of the target page. It is used for
+	// destinations in remote documents (GoToR actions) or if Page is not set.
+	PageIndex int64
+	Mode      PdfDestinationMode
+
+	// Parameters of the display mode. XYZ uses Left, Top and Zoom; FitH and
+	// FitBH use Top; FitV and FitBV use Left; FitR uses Left, Bottom, Right
+	// and Top.
+	Left   *float64
+	Bottom *float64
+	Right  *float64
+	Top    *float64
+	Zoom   *float64
+}
+
+// NewPdfDestinationXYZ returns a destination displaying `page` with the
+// coordinates (`left`, `top`) at the upper left corner of the window, at the
+// specified `zoom` factor. Nil parameters leave the current values unchanged.
+func NewPdfDestinationXYZ(page *core.PdfIndirectObject, left, top, zoom *float64) *PdfDestination {
+	return &PdfDestination{Page: page, Mode: DestinationModeXYZ, Left: left, Top: top, Zoom: zoom}
+}
+
+// NewPdfDestinationFit returns a destination displaying `page` entirely in
+// the window.
+func NewPdfDestinationFit(page *core.PdfIndirectObject) *PdfDestination {
+	return &PdfDestination{Page: page, Mode: DestinationModeFit}
+}
+
+// NewPdfDestinationFitH returns a destination displaying `page` with the
+// vertical coordinate `top` at the top edge of the window, fitting the width
+// of the page in the window.
+func NewPdfDestinationFitH(page *core.PdfIndirectObject, top *float64) *PdfDestination {
+	return &PdfDestination{Page: page, Mode: DestinationModeFitH, Top: top}
+}
+
+// NewPdfDestinationFitB returns a destination displaying `page` with its
+// bounding box fitting entirely in the window.
+func NewPdfDestinationFitB(page *core.PdfIndirectObject) *PdfDestination {
+	return &PdfDestination{Page: page, Mode: DestinationModeFitB}
+}
+
+// ToPdfObject returns the destination array.
+func (dest *PdfDestination) ToPdfObject() core.PdfObject {
+	arr := core.MakeArray()
+	if dest.Page != nil {
+		arr.Append(dest.Page)
+	} else {
+		arr.Append(core.MakeInteger(dest.PageIndex))
+	}
+
+	mode := dest.Mode
+	if mode == "" {
+		mode = DestinationModeFit
+	}
+	arr.Append(core.MakeName(string(mode)))
+
+	var params []*float64
+	switch mode {
+	case DestinationModeXYZ:
+		params = []*float64{dest.Left, dest.Top, dest.Zoom}
+	case DestinationModeFitH, DestinationModeFitBH:
+		params = []*float64{dest.Top}
+	case DestinationModeFitV, DestinationModeFitBV:
+		params = []*float64{dest.Left}
+	case DestinationModeFitR:
+		params = []*float64{dest.Left, dest.Bottom, dest.Right, dest.Top}
+	}
+	for _, param := range params {
+		if param == nil {
+			arr.Append(core.MakeNull())
+			continue
+		}
+		arr.Append(core.MakeFloat(*param))
+	}
+
+	return arr
+}
+
+// newPdfDestinationFromArray returns the explicit destination represented by
+// the specified destination array. Page objects are resolved to page indices
+// using the reader, if specified.
+func newPdfDestinationFromArray(arr *core.PdfObjectArray, r *PdfReader) (*PdfDestination, error) {
+	if arr.Len() < 2 {
+		return nil, fmt.Errorf("invalid destination array length: %d", arr.Len())
+	}
+
+	dest := &PdfDestination{}
+	pageObj := arr.Get(0)
+	if pageInd, ok := core.GetIndirect(pageObj); ok {
+		dest.Page = pageInd
+		if r != nil {
+			if _, pageNum, err := r.PageFromIndirectObject(pageInd); err == nil {
+				dest.PageIndex = int64(pageNum - 1)
+			} else {
+				common.Log.Debug("WARN: could not get page index for page %+v", pageInd)
+			}
+		}
+	} else if pageIdx, ok := core.GetIntVal(pageObj); ok {
+		dest.PageIndex = int64(pageIdx)
+		if r != nil && pageIdx >= 0 && pageIdx < len(r.PageList) {
+			dest.Page = r.PageList[pageIdx].GetPageAsIndirectObject()
+		}
+	} else {
+		return nil, fmt.Errorf("invalid destination page: %T", pageObj)
+	}
+
+	mode, ok := core.GetNameVal(arr.Get(1))
+	if !ok {
+		return nil, fmt.Errorf("invalid destination mode: %v", arr.Get(1))
+	}
+	dest.Mode = PdfDestinationMode(mode)
+
+	// param returns the i-th parameter of the destination or nil if it is
+	// null or missing.
+	param := func(i int) *float64 {
+		val, err := core.GetNumberAsFloat(core.TraceToDirectObject(arr.Get(i + 2)))
+		if err != nil {
+			return nil
+		}
+		return &val
+	}
+
+	switch dest.Mode {
+	case DestinationModeFit, DestinationModeFitB:
+	case DestinationModeXYZ:
+		dest.Left, dest.Top, dest.Zoom = param(0), param(1), param(2)
+	case DestinationModeFitH, DestinationModeFitBH:
+		dest.Top = param(0)
+	case DestinationModeFitV, DestinationModeFitBV:
+		dest.Left = param(0)
+	case DestinationModeFitR:
+		dest.Left, dest.Bottom, dest.Right, dest.Top = param(0), param(1), param(2), param(3)
+	default:
+		return nil, fmt.Errorf("unsupported destination mode: %s", mode)
+	}
+
+	return dest, nil
+}
+
+// GetNamedDestination returns the destination with the specified name, from
+// the Dests name tree of the document or the Dests dictionary of the catalog
+// (PDF 1.1). Returns nil if the destination is not found.
+func (r *PdfReader) GetNamedDestination(name string) (*PdfDestination, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return nil, err
+	}
+	var obj core.PdfObject
+	if names, ok := core.GetDict(r.catalog.Get("Names")); ok {
+		for _, entry := range getNameTreeEntries(names.Get("Dests")) {
+			if entry.key == name {
+				obj = entry.value
+				break
+			}
+		}
+	}
+	if obj == nil {
+		if dests, ok := core.GetDict(r.catalog.Get("Dests")); ok {
+			obj = dests.Get(core.PdfObjectName(name))
+		}
+	}
+	if obj == nil {
+		return nil, nil
+	}
+
+	return r.resolveExplicitDestination(obj)
+}
+
+// ResolveDestination returns the explicit destination referred to by the
+// specified destination object, such as the Dest entry of link annotations
+// or the D entry of GoTo actions. Named destinations are looked up in the
+// document.
+func (r *PdfReader) ResolveDestination(obj core.PdfObject) (*PdfDestination, error) {
+	switch t := core.TraceToDirectObject(obj).(type) {
+	case *core.PdfObjectName:
+		return r.GetNamedDestination(string(*t))
+	case *core.PdfObjectString:
+		return r.GetNamedDestination(t.Str())
+	case *core.PdfObjectArray:
+		return newPdfDestinationFromArray(t, r)
+	}
+	return nil, errors.New("invalid destination")
+}
+
+// resolveExplicitDestination returns the destination array of a named
+// destination, which is either an array or a dictionary containing the
+// array in its D entry.
+func (r *PdfReader) resolveExplicitDestination(obj core.PdfObject) (*PdfDestination, error) {
+	if dict, ok := core.GetDict(obj); ok {
+		obj = dict.Get("D")
+	}
+	arr, ok := core.GetArray(obj)
+	if !ok {
+		return nil, errors.New("invalid named destination")
+	}
+	return newPdfDestinationFromArray(arr, r)
+}
+
+// GetDestination returns the destination of the link annotation, specified
+// either by its Dest entry or by the D entry of its GoTo action. Named
+// destinations are resolved using the reader the annotation was loaded from.
+// Returns nil if the link has no local destination.
+func (a *PdfAnnotationLink) GetDestination() (*PdfDestination, error) {
+	destObj := a.Dest
+	if destObj == nil {
+		action, err := a.GetAction()
+		if err != nil {
+			return nil, err
+		}
+		if action == nil {
+			return nil, nil
+		}
+		goTo, ok := action.GetContext().(*PdfActionGoTo)
+		if !ok {
+			return nil, nil
+		}
+		destObj = goTo.D
+	}
+	if destObj == nil {
+		return nil, nil
+	}
+
+	if a.reader == nil {
+		arr, ok := core.GetArray(destObj)
+		if !ok {
+			return nil, errors.New("named destinations require a reader")
+		}
+		return newPdfDestinationFromArray(arr, nil)
+	}
+	return a.reader.ResolveDestination(destObj)
+}
+
+// applyNamedDestinations returns a copy of the specified catalog Names
+// dictionary, containing a Dests name tree updated with `dests`. The original
+// dictionary is not modified.
+func applyNamedDestinations(names core.PdfObject, dests map[string]*PdfDestination) *core.PdfIndirectObject {
+	var added []nameTreeEntry
+	for name, dest := range dests {
+		added = append(added, nameTreeEntry{key: name, value: dest.ToPdfObject()})
+	}
+	return updateNamesDict(names, "Dests", nil, added)
+}
@@ -0,0 +1,113 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+func TestDestinationRoundTrip(t *testing.T) {
+	left, top, zoom := 10.0, 700.0, 1.5
+	page := NewPdfPage()
+	pageObj := page.GetPageAsIndirectObject()
+
+	testcases := []struct {
+		dest     *PdfDestination
+		expected string
+	}{
+		{NewPdfDestinationFit(nil), "[0 /Fit]"},
+		{NewPdfDestinationFitB(nil), "[0 /FitB]"},
+		{NewPdfDestinationFitH(nil, &top), "[0 /FitH 700]"},
+		{NewPdfDestinationFitH(nil, nil), "[0 /FitH null]"},
+		{NewPdfDestinationXYZ(nil, &left, &top, &zoom), "[0 /XYZ 10 700 1.5]"},
+		{NewPdfDestinationXYZ(nil, nil, &top, nil), "[0 /XYZ null 700 null]"},
+		{NewPdfDestinationXYZ(nil, nil, nil, nil), "[0 /XYZ null null null]"},
+	}
+
+	for _, tcase := range testcases {
+		arr, ok := core.GetArray(tcase.dest.ToPdfObject())
+		require.True(t, ok)
+		require.Equal(t, tcase.expected, arr.WriteString())
+
+		dest, err := newPdfDestinationFromArray(arr, nil)
+		require.NoError(t, err)
+		require.Equal(t, tcase.dest, dest)
+	}
+
+	// Destinations referring to page objects.
+	dest := NewPdfDestinationXYZ(pageObj, nil, &top, nil)
+	arr, ok := core.GetArray(dest.ToPdfObject())
+	require.True(t, ok)
+	require.Equal(t, pageObj, arr.Get(0))
+
+	_, err := newPdfDestinationFromArray(core.MakeArray(core.MakeInteger(0)), nil)
+	require.Error(t, err)
+	_, err = newPdfDestinationFromArray(core.MakeArray(core.MakeInteger(0), core.MakeName("Invalid")), nil)
+	require.Error(t, err)
+}
+
+func TestNamedDestinations(t *testing.T) {
+	top := 400.0
+	pages := []*PdfPage{NewPdfPage(), NewPdfPage()}
+
+	w := NewPdfWriter()
+	for _, page := range pages {
+		require.NoError(t, w.AddPage(page))
+	}
+	require.NoError(t, w.AddNamedDestination("first", NewPdfDestinationFit(pages[0].GetPageAsIndirectObject())))
+	require.NoError(t, w.AddNamedDestination("second", NewPdfDestinationFitH(pages[1].GetPageAsIndirectObject(), nil)))
+	require.Error(t, w.AddNamedDestination("", NewPdfDestinationFit(nil)))
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, w.Write(buf))
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	dest, err := reader.GetNamedDestination("second")
+	require.NoError(t, err)
+	require.NotNil(t, dest)
+	require.Equal(t, DestinationModeFitH, dest.Mode)
+	require.Equal(t, int64(1), dest.PageIndex)
+	require.Nil(t, dest.Top)
+
+	dest, err = reader.GetNamedDestination("missing")
+	require.NoError(t, err)
+	require.Nil(t, dest)
+
+	// Update the destinations incrementally.
+	appender, err := NewPdfAppender(reader)
+	require.NoError(t, err)
+	require.NoError(t, appender.AddNamedDestination("second", NewPdfDestinationFitH(
+		reader.PageList[1].GetPageAsIndirectObject(), &top)))
+	buf.Reset()
+	require.NoError(t, appender.Write(buf))
+
+	reader, err = NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	for name, pageIndex := range map[string]int64{"first": 0, "second": 1} {
+		dest, err := reader.ResolveDestination(core.MakeString(name))
+		require.NoError(t, err)
+		require.NotNil(t, dest)
+		require.Equal(t, pageIndex, dest.PageIndex)
+	}
+	dest, err = reader.GetNamedDestination("second")
+	require.NoError(t, err)
+	require.NotNil(t, dest.Top)
+	require.Equal(t, top, *dest.Top)
+
+	namesObj, err := reader.GetNamedDestinations()
+	require.NoError(t, err)
+	names, ok := core.GetDict(namesObj)
+	require.True(t, ok)
+	require.Len(t, getNameTreeEntries(names.Get("Dests")), 2)
+}
@@ -0,0 +1,324 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// VRI represents a Validation-Related Information dictionary.
+// The VRI dictionary contains the validation data of a single signature.
+// See ETSI EN 319 142-1 "PAdES digital signatures" (section 5.4.2.3).
+type VRI struct {
+	Cert []*core.PdfObjectStream
+	OCSP []*core.PdfObjectStream
+	CRL  []*core.PdfObjectStream
+	TU   *core.PdfObjectString
+	TS   *core.PdfObjectStream
+}
+
+// ToPdfObject implements interface PdfModel.
+func (v *VRI) ToPdfObject() core.PdfObject {
+	dict := core.MakeDict()
+	dict.SetIfNotNil("Cert", makeStreamArray(v.Cert))
+	dict.SetIfNotNil("OCSP", makeStreamArray(v.OCSP))
+	dict.SetIfNotNil("CRL", makeStreamArray(v.CRL))
+	dict.SetIfNotNil("TU", v.TU)
+	dict.SetIfNotNil("TS", v.TS)
+	return dict
+}
+
+// DSS represents a Document Security Store dictionary, which contains the
+// validation data (certificates, OCSP responses, CRLs) used for the long term
+// validation of the signatures in the document.
+// See ETSI EN 319 142-1 "PAdES digital signatures" (section 5.4.2.2).
+type DSS struct {
+	container *core.PdfIndirectObject
+
+	Certs []*core.PdfObjectStream
+	OCSPs []*core.PdfObjectStream
+	CRLs  []*core.PdfObjectStream
+	VRI   map[string]*VRI
+
+	// Maps of the stream data hashes to the streams, used for avoiding
+	// duplicate validation data.
+	certMap map[string]*core.PdfObjectStream
+	ocspMap map[string]*core.PdfObjectStream
+	crlMap  map[string]*core.PdfObjectStream
+}
+
+// NewDSS returns a new empty DSS dictionary.
+func NewDSS() *DSS {
+	return &DSS{
+		container: core.MakeIndirectObject(core.MakeDict()),
+		VRI:       map[string]*VRI{},
+		certMap:   map[string]*core.PdfObjectStream{},
+		ocspMap:   map[string]*core.PdfObjectStream{},
+		crlMap:    map[string]*core.PdfObjectStream{},
+	}
+}
+
+// newDSSFromObject loads a DSS dictionary from the specified object.
+func newDSSFromObject(obj core.PdfObject) (*DSS, error) {
+	dss := NewDSS()
+	if container, ok := core.GetIndirect(obj); ok {
+		dss.container = container
+	}
+
+	dict, ok := core.GetDict(obj)
+	if !ok {
+		return nil, fmt.Errorf("invalid DSS object type: %T", obj)
+	}
+
+	var err error
+	if dss.Certs, err = dss.loadStreams(dict.Get("Certs"), dss.certMap); err != nil {
+		return nil, err
+	}
+	if dss.OCSPs, err = dss.loadStreams(dict.Get("OCSPs"), dss.ocspMap); err != nil {
+		return nil, err
+	}
+	if dss.CRLs, err = dss.loadStreams(dict.Get("CRLs"), dss.crlMap); err != nil {
+		return nil, err
+	}
+
+	if vriDict, ok := core.GetDict(dict.Get("VRI")); ok {
+		for _, key := range vriDict.Keys() {
+			d, ok := core.GetDict(vriDict.Get(key))
+			if !ok {
+				common.Log.Debug("Skipping invalid VRI entry %s", key)
+				continue
+			}
+
+			vri := &VRI{}
+			if vri.Cert, err = dss.loadStreams(d.Get("Cert"), dss.certMap); err != nil {
+				return nil, err
+			}
+			if vri.OCSP, err = dss.loadStreams(d.Get("OCSP"), dss.ocspMap); err != nil {
+				return nil, err
+			}
+			if vri.CRL, err = dss.loadStreams(d.Get("CRL"), dss.crlMap); err != nil {
+				return nil, err
+			}
+			if tu, ok := core.GetString(d.Get("TU")); ok {
+				vri.TU = tu
+			}
+			if ts, ok := core.GetStream(d.Get("TS")); ok {
+				vri.TS = ts
+			}
+			dss.VRI[strings.ToUpper(string(key))] = vri
+		}
+	}
+
+	return dss, nil
+}
+
+// loadStreams returns the streams of the specified array, registering them
+// in the provided map of stream data hashes. Streams which are already
+// registered are reused.
+func (d *DSS) loadStreams(obj core.PdfObject, hashMap map[string]*core.PdfObjectStream) ([]*core.PdfObjectStream, error) {
+	obj = core.ResolveReference(obj)
+	if obj == nil || core.IsNullObject(obj) {
+		return nil, nil
+	}
+
+	arr, ok := core.GetArray(obj)
+	if !ok {
+		return nil, fmt.Errorf("invalid DSS stream array type: %T", obj)
+	}
+
+	var streams []*core.PdfObjectStream
+	for _, o := range arr.Elements() {
+		stream, ok := core.GetStream(o)
+		if !ok {
+			return nil, ErrTypeCheck
+		}
+
+		data, err := core.DecodeStream(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		key := dssHashKey(data)
+		if existing, ok := hashMap[key]; ok {
+			stream = existing
+		} else {
+			hashMap[key] = stream
+		}
+		streams = append(streams, stream)
+	}
+
+	return streams, nil
+}
+
+// add returns the streams containing the specified data, creating new
+// streams for the data which is not already present in the DSS.
+func (d *DSS) add(dst *[]*core.PdfObjectStream, hashMap map[string]*core.PdfObjectStream, data [][]byte) ([]*core.PdfObjectStream, error) {
+	var streams []*core.PdfObjectStream
+	for _, item := range data {
+		key := dssHashKey(item)
+		stream, ok := hashMap[key]
+		if !ok {
+			var err error
+			if stream, err = core.MakeStream(item, core.NewFlateEncoder()); err != nil {
+				return nil, err
+			}
+			hashMap[key] = stream
+			*dst = append(*dst, stream)
+		}
+		streams = append(streams, stream)
+	}
+
+	return streams, nil
+}
+
+// AddCerts adds the specified DER encoded certificates to the DSS and
+// returns their streams. Certificates already present in the DSS are not
+// duplicated.
+func (d *DSS) AddCerts(certs [][]byte) ([]*core.PdfObjectStream, error) {
+	return d.add(&d.Certs, d.certMap, certs)
+}
+
+// AddOCSPs adds the specified DER encoded OCSP responses to the DSS and
+// returns their streams. OCSP responses already present in the DSS are not
+// duplicated.
+func (d *DSS) AddOCSPs(ocsps [][]byte) ([]*core.PdfObjectStream, error) {
+	return d.add(&d.OCSPs, d.ocspMap, ocsps)
+}
+
+// AddCRLs adds the specified DER encoded CRLs to the DSS and returns their
+// streams. CRLs already present in the DSS are not duplicated.
+func (d *DSS) AddCRLs(crls [][]byte) ([]*core.PdfObjectStream, error) {
+	return d.add(&d.CRLs, d.crlMap, crls)
+}
+
+// AddValidationData adds the specified DER encoded certificates, OCSP
+// responses and CRLs to the DSS and references them in the VRI entry of
+// the signature. The data is merged with the existing VRI entry, if any.
+func (d *DSS) AddValidationData(sig *PdfSignature, certs, ocsps, crls [][]byte) error {
+	key, err := GetVRIKey(sig)
+	if err != nil {
+		return err
+	}
+
+	certStreams, err := d.AddCerts(certs)
+	if err != nil {
+		return err
+	}
+	ocspStreams, err := d.AddOCSPs(ocsps)
+	if err != nil {
+		return err
+	}
+	crlStreams, err := d.AddCRLs(crls)
+	if err != nil {
+		return err
+	}
+
+	vri, ok := d.VRI[key]
+	if !ok {
+		vri = &VRI{}
+		d.VRI[key] = vri
+	}
+	vri.Cert = mergeStreams(vri.Cert, certStreams)
+	vri.OCSP = mergeStreams(vri.OCSP, ocspStreams)
+	vri.CRL = mergeStreams(vri.CRL, crlStreams)
+
+	date, err := NewPdfDateFromTime(time.Now())
+	if err != nil {
+		return err
+	}
+	vri.TU = date.ToPdfObject().(*core.PdfObjectString)
+	return nil
+}
+
+// GetContainingPdfObject implements interface PdfModel.
+func (d *DSS) GetContainingPdfObject() core.PdfObject {
+	return d.container
+}
+
+// ToPdfObject implements interface PdfModel.
+func (d *DSS) ToPdfObject() core.PdfObject {
+	dict, ok := d.container.PdfObject.(*core.PdfObjectDictionary)
+	if !ok {
+		dict = core.MakeDict()
+		d.container.PdfObject = dict
+	}
+
+	dict.SetIfNotNil("Certs", makeStreamArray(d.Certs))
+	dict.SetIfNotNil("OCSPs", makeStreamArray(d.OCSPs))
+	dict.SetIfNotNil("CRLs", makeStreamArray(d.CRLs))
+
+	if len(d.VRI) > 0 {
+		keys := make([]string, 0, len(d.VRI))
+		for key := range d.VRI {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		vriDict := core.MakeDict()
+		for _, key := range keys {
+			vriDict.Set(core.PdfObjectName(key), d.VRI[key].ToPdfObject())
+		}
+		dict.Set("VRI", vriDict)
+	}
+
+	return d.container
+}
+
+// GetVRIKey returns the key of the VRI entry of the specified signature,
+// which is the upper case hex encoded SHA1 hash of the signature Contents.
+func GetVRIKey(sig *PdfSignature) (string, error) {
+	if sig == nil || sig.Contents == nil {
+		return "", errors.New("signature contents not set")
+	}
+
+	return dssHashKey(sig.Contents.Bytes()), nil
+}
+
+// dssHashKey returns the upper case hex encoded SHA1 hash of the data.
+func dssHashKey(data []byte) string {
+	h := sha1.Sum(data)
+	return strings.ToUpper(hex.EncodeToString(h[:]))
+}
+
+// mergeStreams appends the streams of src which are not already in dst.
+func mergeStreams(dst, src []*core.PdfObjectStream) []*core.PdfObjectStream {
+	for _, stream := range src {
+		found := false
+		for _, s := range dst {
+			if s == stream {
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst = append(dst, stream)
+		}
+	}
+	return dst
+}
+
+// makeStreamArray returns an array containing the specified streams, or nil
+// if there are no streams.
+func makeStreamArray(streams []*core.PdfObjectStream) *core.PdfObjectArray {
+	if len(streams) == 0 {
+		return nil
+	}
+
+	arr := core.MakeArray()
+	for _, stream := range streams {
+		arr.Append(stream)
+	}
+	return arr
+}
@@ -0,0 +1,310 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// EmbeddedFile represents a file embedded in a PDF document, either as a
+// document level attachment (EmbeddedFiles name tree) or through a file
+// attachment annotation.
+// See section 7.11.4 "Embedded File Streams" (p. 103 PDF32000_2008).
+type EmbeddedFile struct {
+	// Name is the file name of the embedded file.
+	Name string
+	// Content is the uncompressed content of the embedded file.
+	Content []byte
+	// FileType is the MIME type of the embedded file (e.g. "text/plain").
+	FileType string
+	// Description is the description of the embedded file.
+	Description string
+	// CreationTime and ModTime are the creation and modification times of
+	// the embedded file. Zero values are not written.
+	CreationTime time.Time
+	ModTime      time.Time
+	// Checksum is the MD5 checksum of the content, as read from the document.
+	// It is computed from the content when writing.
+	Checksum []byte
+	// ChecksumMismatch is true if the checksum read from the document does
+	// not match the content, which may be corrupted.
+	ChecksumMismatch bool
+	// Encoder is the encoder of the embedded file stream when writing,
+	// e.g. a chain of encoders made with core.ChainEncoders. Flate encoding
+	// is used if not set.
+	Encoder core.StreamEncoder
+}
+
+// NewEmbeddedFile returns a new embedded file with the specified name and
+// content.
+func NewEmbeddedFile(name string, content []byte) *EmbeddedFile {
+	return &EmbeddedFile{
+		Name:    name,
+		Content: content,
+	}
+}
+
+// NewEmbeddedFileFromPath returns a new embedded file containing the file at
+// `filePath`. The modification time is set to that of the file.
+func NewEmbeddedFileFromPath(filePath string) (*EmbeddedFile, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	file := NewEmbeddedFile(filepath.Base(filePath), content)
+	if info, err := os.Stat(filePath); err == nil {
+		file.ModTime = info.ModTime()
+	}
+	return file, nil
+}
+
+// ToFilespec returns an embedded file specification containing the file.
+func (f *EmbeddedFile) ToFilespec() (*PdfFilespec, error) {
+	if f.Name == "" {
+		return nil, errors.New("embedded file name not specified")
+	}
+
+	encoder := f.Encoder
+	if encoder == nil {
+		encoder = core.NewFlateEncoder()
+	}
+	stream, err := core.MakeStream(f.Content, encoder)
+	if err != nil {
+		return nil, err
+	}
+	stream.Set("Type", core.MakeName("EmbeddedFile"))
+	if f.FileType != "" {
+		stream.Set("Subtype", core.MakeName(f.FileType))
+	}
+
+	checksum := md5.Sum(f.Content)
+	params := core.MakeDict()
+	params.Set("Size", core.MakeInteger(int64(len(f.Content))))
+	params.Set("CheckSum", core.MakeHexString(string(checksum[:])))
+	if !f.CreationTime.IsZero() {
+		if date, err := NewPdfDateFromTime(f.CreationTime); err == nil {
+			params.Set("CreationDate", date.ToPdfObject())
+		}
+	}
+	if !f.ModTime.IsZero() {
+		if date, err := NewPdfDateFromTime(f.ModTime); err == nil {
+			params.Set("ModDate", date.ToPdfObject())
+		}
+	}
+	stream.Set("Params", params)
+
+	ef := core.MakeDict()
+	ef.Set("F", stream)
+	ef.Set("UF", stream)
+
+	fs := NewPdfFilespec()
+	fs.F = core.MakeString(f.Name)
+	fs.UF = core.MakeEncodedString(f.Name, true)
+	fs.EF = ef
+	if f.Description != "" {
+		fs.Desc = core.MakeEncodedString(f.Description, true)
+	}
+	return fs, nil
+}
+
+// NewEmbeddedFileFromFilespec returns the embedded file contained by the
+// specified file specification. An error is returned if the file
+// specification does not refer to an embedded file. A checksum which does
+// not match the content of the file is reported by its ChecksumMismatch
+// field.
+func NewEmbeddedFileFromFilespec(fs *PdfFilespec) (*EmbeddedFile, error) {
+	if fs == nil {
+		return nil, errors.New("file specification not specified")
+	}
+	ef, ok := core.GetDict(fs.EF)
+	if !ok {
+		return nil, errors.New("file specification does not contain an embedded file")
+	}
+
+	var stream *core.PdfObjectStream
+	for _, key := range []core.PdfObjectName{"UF", "F", "Unix", "DOS", "Mac"} {
+		if stream, ok = core.GetStream(ef.Get(key)); ok {
+			break
+		}
+	}
+	if stream == nil {
+		return nil, errors.New("embedded file stream not found")
+	}
+
+	content, err := core.DecodeStream(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &EmbeddedFile{Content: content}
+	for _, obj := range []core.PdfObject{fs.UF, fs.F, fs.Unix, fs.DOS, fs.Mac} {
+		if name, ok := core.GetString(obj); ok && name.Str() != "" {
+			file.Name = name.Decoded()
+			break
+		}
+	}
+	if subtype, ok := core.GetName(stream.Get("Subtype")); ok {
+		file.FileType = subtype.String()
+	}
+	if desc, ok := core.GetString(fs.Desc); ok {
+		file.Description = desc.Decoded()
+	}
+
+	if params, ok := core.GetDict(stream.Get("Params")); ok {
+		file.CreationTime = getEmbeddedFileTime(params.Get("CreationDate"))
+		file.ModTime = getEmbeddedFileTime(params.Get("ModDate"))
+		if checksum, ok := core.GetString(params.Get("CheckSum")); ok {
+			file.Checksum = checksum.Bytes()
+			sum := md5.Sum(content)
+			if !bytes.Equal(file.Checksum, sum[:]) {
+				common.Log.Debug("ERROR: embedded file checksum mismatch")
+				file.ChecksumMismatch = true
+			}
+		}
+	}
+
+	return file, nil
+}
+
+// getEmbeddedFileTime returns the time represented by the specified date
+// string or a zero time if the date is missing or invalid.
+func getEmbeddedFileTime(obj core.PdfObject) time.Time {
+	str, ok := core.GetString(obj)
+	if !ok {
+		return time.Time{}
+	}
+	date, err := NewPdfDate(str.Str())
+	if err != nil {
+		common.Log.Debug("ERROR: invalid embedded file date: %v", err)
+		return time.Time{}
+	}
+	return date.ToGoTime()
+}
+
+// embeddedFileKey returns the key of the embedded file with the specified
+// name in the EmbeddedFiles name tree.
+func embeddedFileKey(name string) string {
+	return nameTreeKey(name)
+}
+
+// embeddedFileChanges contains the pending changes to the embedded files of
+// a document.
+type embeddedFileChanges struct {
+	added   []*EmbeddedFile
+	removed []string
+}
+
+// add adds `file` to the changes, replacing any previously added file with
+// the same name.
+func (c *embeddedFileChanges) add(file *EmbeddedFile) {
+	for i, f := range c.added {
+		if f.Name == file.Name {
+			c.added[i] = file
+			return
+		}
+	}
+	c.added = append(c.added, file)
+}
+
+// remove marks the file with the specified name for removal.
+func (c *embeddedFileChanges) remove(name string) {
+	for i, f := range c.added {
+		if f.Name == name {
+			c.added = append(c.added[:i], c.added[i+1:]...)
+			break
+		}
+	}
+	c.removed = append(c.removed, name)
+}
+
+// isEmpty returns true if there are no pending changes.
+func (c *embeddedFileChanges) isEmpty() bool {
+	return len(c.added) == 0 && len(c.removed) == 0
+}
+
+// apply returns a copy of the specified catalog Names dictionary, containing
+// an EmbeddedFiles name tree updated with the pending changes. The original
+// dictionary is not modified.
+func (c *embeddedFileChanges) apply(names core.PdfObject) (*core.PdfIndirectObject, error) {
+	removed := map[string]struct{}{}
+	for _, name := range c.removed {
+		removed[embeddedFileKey(name)] = struct{}{}
+	}
+
+	var added []nameTreeEntry
+	for _, file := range c.added {
+		fs, err := file.ToFilespec()
+		if err != nil {
+			return nil, err
+		}
+		added = append(added, nameTreeEntry{
+			key:   embeddedFileKey(file.Name),
+			value: fs.ToPdfObject(),
+		})
+	}
+
+	return updateNamesDict(names, "EmbeddedFiles", removed, added), nil
+}
+
+// GetEmbeddedFiles returns the document level embedded files (attachments)
+// contained by the EmbeddedFiles name tree of the document. The files whose
+// content does not match their checksum are returned with their
+// ChecksumMismatch field set.
+func (r *PdfReader) GetEmbeddedFiles() ([]*EmbeddedFile, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return nil, err
+	}
+	names, ok := core.GetDict(r.catalog.Get("Names"))
+	if !ok {
+		return nil, nil
+	}
+
+	var files []*EmbeddedFile
+	for _, entry := range getNameTreeEntries(names.Get("EmbeddedFiles")) {
+		fs, err := NewPdfFilespecFromObj(core.ResolveReference(entry.value))
+		if err != nil {
+			return nil, err
+		}
+		file, err := NewEmbeddedFileFromFilespec(fs)
+		if err != nil {
+			return nil, err
+		}
+		if file.Name == "" {
+			file.Name = core.MakeString(entry.key).Decoded()
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// GetEmbeddedFile returns the file embedded by the file attachment annotation.
+func (file *PdfAnnotationFileAttachment) GetEmbeddedFile() (*EmbeddedFile, error) {
+	fs, err := NewPdfFilespecFromObj(core.ResolveReference(file.FS))
+	if err != nil {
+		return nil, err
+	}
+	return NewEmbeddedFileFromFilespec(fs)
+}
+
+// SetEmbeddedFile sets the file embedded by the file attachment annotation.
+func (file *PdfAnnotationFileAttachment) SetEmbeddedFile(f *EmbeddedFile) error {
+	fs, err := f.ToFilespec()
+	if err != nil {
+		return err
+	}
+	file.FS = fs.ToPdfObject()
+	return nil
+}
@@ -0,0 +1,210 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/core/security"
+)
+
+// writeTestDocument writes a single page document with the specified
+// embedded files.
+func writeTestDocument(t *testing.T, files ...*EmbeddedFile) []byte {
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(NewPdfPage()))
+	for _, file := range files {
+		require.NoError(t, w.AddEmbeddedFile(file))
+	}
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, w.Write(buf))
+	return buf.Bytes()
+}
+
+// readEmbeddedFiles returns the document level embedded files of the
+// specified document, by name.
+func readEmbeddedFiles(t *testing.T, data []byte) map[string]*EmbeddedFile {
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	files, err := reader.GetEmbeddedFiles()
+	require.NoError(t, err)
+
+	byName := map[string]*EmbeddedFile{}
+	for _, file := range files {
+		byName[file.Name] = file
+	}
+	require.Len(t, byName, len(files))
+	return byName
+}
+
+func TestEmbeddedFiles(t *testing.T) {
+	modTime := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	report := &EmbeddedFile{
+		Name:         "report.csv",
+		Content:      []byte("a,b,c\n1,2,3\n"),
+		FileType:     "text/csv",
+		Description:  "Quarterly report",
+		CreationTime: modTime.Add(-time.Hour),
+		ModTime:      modTime,
+	}
+	binary := NewEmbeddedFile("données.bin", []byte{0, 1, 2, 0xff, 0xfe})
+
+	data := writeTestDocument(t, report, binary)
+	files := readEmbeddedFiles(t, data)
+	require.Len(t, files, 2)
+
+	file := files["report.csv"]
+	require.NotNil(t, file)
+	require.Equal(t, report.Content, file.Content)
+	require.Equal(t, "text/csv", file.FileType)
+	require.Equal(t, "Quarterly report", file.Description)
+	require.True(t, report.CreationTime.Equal(file.CreationTime))
+	require.True(t, report.ModTime.Equal(file.ModTime))
+	require.Len(t, file.Checksum, 16)
+
+	file = files["données.bin"]
+	require.NotNil(t, file)
+	require.Equal(t, binary.Content, file.Content)
+
+	// Remove and replace attachments in an incremental update.
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	appender, err := NewPdfAppender(reader)
+	require.NoError(t, err)
+	appender.RemoveEmbeddedFile("report.csv")
+	require.NoError(t, appender.AddEmbeddedFile(NewEmbeddedFile("données.bin", []byte("updated"))))
+	require.NoError(t, appender.AddEmbeddedFile(NewEmbeddedFile("notes.txt", []byte("notes"))))
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, appender.Write(buf))
+
+	files = readEmbeddedFiles(t, buf.Bytes())
+	require.Len(t, files, 2)
+	require.Equal(t, []byte("updated"), files["données.bin"].Content)
+	require.Equal(t, []byte("notes"), files["notes.txt"].Content)
+
+	// Corrupted content is reported per file.
+	corrupted := bytes.Replace(writeTestDocument(t, &EmbeddedFile{
+		Name:    "raw.txt",
+		Content: []byte("checksum"),
+		Encoder: core.NewRawEncoder(),
+	}, NewEmbeddedFile("intact.txt", []byte("intact"))), []byte("checksum"), []byte("tampered"), 1)
+	files = readEmbeddedFiles(t, corrupted)
+	require.Len(t, files, 2)
+	require.True(t, files["raw.txt"].ChecksumMismatch)
+	require.Equal(t, []byte("tampered"), files["raw.txt"].Content)
+	require.False(t, files["intact.txt"].ChecksumMismatch)
+	require.Equal(t, []byte("intact"), files["intact.txt"].Content)
+}
+
+func TestEmbeddedFilesNameTree(t *testing.T) {
+	var files []*EmbeddedFile
+	for i := 199; i >= 0; i-- {
+		files = append(files, NewEmbeddedFile(fmt.Sprintf("file%03d.txt", i), []byte(fmt.Sprint(i))))
+	}
+	data := writeTestDocument(t, files...)
+	require.Len(t, readEmbeddedFiles(t, data), 200)
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	names, ok := core.GetDict(reader.catalog.Get("Names"))
+	require.True(t, ok)
+	root, ok := core.GetDict(names.Get("EmbeddedFiles"))
+	require.True(t, ok)
+	require.Nil(t, root.Get("Names"))
+	kids, ok := core.GetArray(root.Get("Kids"))
+	require.True(t, ok)
+	require.Equal(t, 4, kids.Len())
+
+	// The keys are sorted and the limits of the nodes match their keys.
+	var prev string
+	for _, kid := range kids.Elements() {
+		node, ok := core.GetDict(kid)
+		require.True(t, ok)
+		limits, ok := core.GetArray(node.Get("Limits"))
+		require.True(t, ok)
+		entries := getNameTreeEntries(node)
+		require.True(t, len(entries) <= nameTreeMaxNodeSize)
+		require.Equal(t, entries[0].key, limits.Get(0).(*core.PdfObjectString).Str())
+		require.Equal(t, entries[len(entries)-1].key, limits.Get(1).(*core.PdfObjectString).Str())
+		for _, entry := range entries {
+			require.True(t, entry.key > prev)
+			prev = entry.key
+		}
+	}
+
+	// Name trees with intermediate nodes.
+	var entries []nameTreeEntry
+	for i := 0; i < nameTreeMaxNodeSize*nameTreeMaxNodeSize+1; i++ {
+		entries = append(entries, nameTreeEntry{key: fmt.Sprintf("%05d", i), value: core.MakeInteger(int64(i))})
+	}
+	tree := makeNameTree(entries)
+	require.Equal(t, entries, getNameTreeEntries(tree))
+	kids, ok = core.GetArray(tree.PdfObject.(*core.PdfObjectDictionary).Get("Kids"))
+	require.True(t, ok)
+	require.Equal(t, 2, kids.Len())
+}
+
+// TestEmbeddedFilesFilterChain checks writing the embedded files and the content streams encoded
+// with chained filters.
+func TestEmbeddedFilesFilterChain(t *testing.T) {
+	content := bytes.Repeat([]byte("chained filters "), 50)
+
+	ascii85Flate, err := core.ChainEncoders(core.NewASCII85Encoder(), core.NewFlateEncoder())
+	require.NoError(t, err)
+	cryptFlate, err := core.ChainEncoders(core.NewCryptEncoder("Identity"), core.NewFlateEncoder())
+	require.NoError(t, err)
+	hexRunLength, err := core.ChainEncoders(core.NewASCIIHexEncoder(), core.NewRunLengthEncoder())
+	require.NoError(t, err)
+
+	page := NewPdfPage()
+	require.NoError(t, page.SetContentStreams([]string{"BT ET"}, hexRunLength))
+
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(page))
+	require.NoError(t, w.AddEmbeddedFile(&EmbeddedFile{Name: "ascii85.txt", Content: content, Encoder: ascii85Flate}))
+	require.NoError(t, w.AddEmbeddedFile(&EmbeddedFile{Name: "identity.txt", Content: content, Encoder: cryptFlate}))
+	require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), &EncryptOptions{
+		Permissions: security.PermOwner,
+		Algorithm:   AES_128bit,
+	}))
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, w.Write(buf))
+	data := buf.Bytes()
+
+	require.Contains(t, string(data), "/Filter [/ASCII85Decode /FlateDecode]")
+	require.Contains(t, string(data), "/Filter [/Crypt /FlateDecode]")
+	require.Contains(t, string(data), "/Filter [/ASCIIHexDecode /RunLengthDecode]")
+
+	// The stream with the Identity crypt filter is not encrypted.
+	flate, err := core.NewFlateEncoder().EncodeBytes(content)
+	require.NoError(t, err)
+	require.True(t, bytes.Contains(data, flate))
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	ok, err := reader.Decrypt([]byte("user"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	files, err := reader.GetEmbeddedFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	for _, file := range files {
+		require.Equal(t, content, file.Content, file.Name)
+	}
+
+	p, err := reader.GetPage(1)
+	require.NoError(t, err)
+	contents, err := p.GetContentStreams()
+	require.NoError(t, err)
+	require.Equal(t, "BT ET", contents[0])
+}
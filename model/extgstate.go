@@ -0,0 +1,98 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// PdfExtGState is a builder of graphics state parameter dictionaries (ExtGState), which set
+// multiple parameters of the graphics state with the gs operator
+// (section 8.4.5 "Graphics State Parameter Dictionaries" p. 128).
+// The setters return the builder, so that they can be chained:
+//
+//	gs := model.NewPdfExtGState().SetAlpha(0.5).SetBlendMode("Multiply")
+//	name, err := model.NewResourceManager(resources).AddExtGState(gs.ToPdfObject())
+type PdfExtGState struct {
+	dict *core.PdfObjectDictionary
+}
+
+// NewPdfExtGState returns a builder of an empty graphics state parameter dictionary.
+func NewPdfExtGState() *PdfExtGState {
+	return &PdfExtGState{dict: core.MakeDict()}
+}
+
+// SetAlpha sets the constant alpha of both the stroking (CA) and the non-stroking (ca)
+// operations to `alpha`.
+func (gs *PdfExtGState) SetAlpha(alpha float64) *PdfExtGState {
+	return gs.SetStrokingAlpha(alpha).SetNonStrokingAlpha(alpha)
+}
+
+// SetStrokingAlpha sets the constant alpha of the stroking operations (CA).
+func (gs *PdfExtGState) SetStrokingAlpha(alpha float64) *PdfExtGState {
+	gs.dict.Set("CA", core.MakeFloat(alpha))
+	return gs
+}
+
+// SetNonStrokingAlpha sets the constant alpha of the non-stroking operations (ca).
+func (gs *PdfExtGState) SetNonStrokingAlpha(alpha float64) *PdfExtGState {
+	gs.dict.Set("ca", core.MakeFloat(alpha))
+	return gs
+}
+
+// SetBlendMode sets the blend mode (BM), e.g. Normal or Multiply.
+func (gs *PdfExtGState) SetBlendMode(mode core.PdfObjectName) *PdfExtGState {
+	gs.dict.Set("BM", core.MakeName(string(mode)))
+	return gs
+}
+
+// SetSoftMask sets the soft mask (SMask) to the soft-mask dictionary `smask`, or to None if
+// `smask` is nil.
+func (gs *PdfExtGState) SetSoftMask(smask core.PdfObject) *PdfExtGState {
+	if smask == nil {
+		smask = core.MakeName("None")
+	}
+	gs.dict.Set("SMask", smask)
+	return gs
+}
+
+// SetLineWidth sets the line width (LW).
+func (gs *PdfExtGState) SetLineWidth(width float64) *PdfExtGState {
+	gs.dict.Set("LW", core.MakeFloat(width))
+	return gs
+}
+
+// SetLineCap sets the line cap style (LC): 0 for butt caps, 1 for round caps and 2 for projecting
+// square caps.
+func (gs *PdfExtGState) SetLineCap(style int) *PdfExtGState {
+	gs.dict.Set("LC", core.MakeInteger(int64(style)))
+	return gs
+}
+
+// SetLineJoin sets the line join style (LJ): 0 for miter joins, 1 for round joins and 2 for bevel
+// joins.
+func (gs *PdfExtGState) SetLineJoin(style int) *PdfExtGState {
+	gs.dict.Set("LJ", core.MakeInteger(int64(style)))
+	return gs
+}
+
+// SetDash sets the line dash pattern (D) to dash array `array` and dash phase `phase`. An empty
+// array sets solid lines.
+func (gs *PdfExtGState) SetDash(array []float64, phase float64) *PdfExtGState {
+	gs.dict.Set("D", core.MakeArray(core.MakeArrayFromFloats(array), core.MakeFloat(phase)))
+	return gs
+}
+
+// SetFont sets the text font (Font) to `font` with size `size`.
+func (gs *PdfExtGState) SetFont(font *PdfFont, size float64) *PdfExtGState {
+	gs.dict.Set("Font", core.MakeArray(font.ToPdfObject(), core.MakeFloat(size)))
+	return gs
+}
+
+// ToPdfObject returns the graphics state parameter dictionary.
+func (gs *PdfExtGState) ToPdfObject() core.PdfObject {
+	return gs.dict
+}
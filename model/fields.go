@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/unidoc/unipdf/v3/common"
@@ -404,6 +405,8 @@ func (ft *PdfFieldText) ToPdfObject() core.PdfObject {
 // PdfFieldChoice represents a choice field which includes scrollable list boxes and combo boxes.
 type PdfFieldChoice struct {
 	*PdfField
+	DA  *core.PdfObjectString
+	Q   *core.PdfObjectInteger
 	Opt *core.PdfObjectArray
 	TI  *core.PdfObjectInteger
 	I   *core.PdfObjectArray
@@ -418,6 +421,12 @@ func (ch *PdfFieldChoice) ToPdfObject() core.PdfObject {
 	// Handle choice specific attributes
 	d := container.PdfObject.(*core.PdfObjectDictionary)
 	d.Set("FT", core.MakeName("Ch"))
+	if ch.DA != nil {
+		d.Set("DA", ch.DA)
+	}
+	if ch.Q != nil {
+		d.Set("Q", ch.Q)
+	}
 	if ch.Opt != nil {
 		d.Set("Opt", ch.Opt)
 	}
@@ -431,6 +440,245 @@ func (ch *PdfFieldChoice) ToPdfObject() core.PdfObject {
 	return container
 }
 
+// PdfFieldChoiceOption represents an option of a choice field. The export
+// value is the value stored in the field when the option is selected, and the
+// display text is shown to the user. Both are the same for options specified
+// as a single text string.
+type PdfFieldChoiceOption struct {
+	Export  string
+	Display string
+}
+
+// IsCombo returns true if the choice field is a combo box, false if it is a list box.
+func (ch *PdfFieldChoice) IsCombo() bool {
+	return ch.Flags().Has(FieldFlagCombo)
+}
+
+// IsEditable returns true if the choice field is a combo box which allows the
+// user to enter a custom value, in addition to selecting one of the options.
+func (ch *PdfFieldChoice) IsEditable() bool {
+	return ch.IsCombo() && ch.Flags().Has(FieldFlagEdit)
+}
+
+// IsSorted returns true if the options of the choice field are sorted
+// alphabetically by the form authoring tool.
+func (ch *PdfFieldChoice) IsSorted() bool {
+	return ch.Flags().Has(FieldFlagSort)
+}
+
+// IsMultiSelect returns true if more than one option of the choice field may be
+// selected simultaneously.
+func (ch *PdfFieldChoice) IsMultiSelect() bool {
+	return ch.Flags().Has(FieldFlagMultiSelect)
+}
+
+// Options returns the options of the choice field (Opt). Elements of the
+// Opt array which are not text strings or [export display] pairs are skipped.
+func (ch *PdfFieldChoice) Options() []*PdfFieldChoiceOption {
+	if ch.Opt == nil {
+		return nil
+	}
+
+	var opts []*PdfFieldChoiceOption
+	for _, obj := range ch.Opt.Elements() {
+		if arr, ok := core.GetArray(obj); ok {
+			if arr.Len() != 2 {
+				common.Log.Debug("ERROR: invalid choice option pair length: %d", arr.Len())
+				continue
+			}
+			export, ok1 := choiceOptionText(arr.Get(0))
+			display, ok2 := choiceOptionText(arr.Get(1))
+			if !ok1 || !ok2 {
+				common.Log.Debug("ERROR: invalid choice option pair: %s", arr)
+				continue
+			}
+			opts = append(opts, &PdfFieldChoiceOption{Export: export, Display: display})
+			continue
+		}
+
+		text, ok := choiceOptionText(obj)
+		if !ok {
+			common.Log.Debug("ERROR: invalid choice option type: %T", obj)
+			continue
+		}
+		opts = append(opts, &PdfFieldChoiceOption{Export: text, Display: text})
+	}
+	return opts
+}
+
+// SetOptions sets the options of the choice field (Opt). Options with the same
+// export value and display text are stored as single text strings, the others
+// as [export display] pairs. The current selection is cleared.
+func (ch *PdfFieldChoice) SetOptions(opts []*PdfFieldChoiceOption) {
+	ch.Opt = core.MakeArray()
+	for _, opt := range opts {
+		if opt.Export == opt.Display {
+			ch.Opt.Append(core.MakeEncodedString(opt.Export, true))
+			continue
+		}
+		ch.Opt.Append(core.MakeArray(
+			core.MakeEncodedString(opt.Export, true),
+			core.MakeEncodedString(opt.Display, true),
+		))
+	}
+
+	ch.V = nil
+	ch.I = nil
+}
+
+// Values returns the selected values of the choice field (V), i.e. the export
+// values of the selected options, or the custom text of editable combo boxes.
+func (ch *PdfFieldChoice) Values() []string {
+	var values []string
+	switch t := core.TraceToDirectObject(ch.V).(type) {
+	case *core.PdfObjectArray:
+		for _, obj := range t.Elements() {
+			if text, ok := choiceOptionText(obj); ok {
+				values = append(values, text)
+			}
+		}
+	default:
+		if text, ok := choiceOptionText(t); ok && text != "" {
+			values = append(values, text)
+		}
+	}
+	return values
+}
+
+// SelectedIndices returns the sorted indices of the selected options. The
+// indices are read from the I entry of the field, if present. Otherwise, they
+// are determined by matching the field value against the export values of the
+// options.
+func (ch *PdfFieldChoice) SelectedIndices() []int {
+	var indices []int
+	if ch.I != nil {
+		for _, obj := range ch.I.Elements() {
+			if idx, ok := core.GetIntVal(obj); ok {
+				indices = append(indices, idx)
+			}
+		}
+		sort.Ints(indices)
+		return indices
+	}
+
+	opts := ch.Options()
+	for _, value := range ch.Values() {
+		for i, opt := range opts {
+			if opt.Export == value {
+				indices = append(indices, i)
+				break
+			}
+		}
+	}
+	sort.Ints(indices)
+	return indices
+}
+
+// SetSelectedIndices selects the options of the choice field at `indices`.
+// Selecting more than one option is only allowed for multi select fields.
+// Providing no indices clears the selection.
+func (ch *PdfFieldChoice) SetSelectedIndices(indices ...int) error {
+	if len(indices) > 1 && !ch.IsMultiSelect() {
+		return errors.New("multiple selection not allowed")
+	}
+
+	opts := ch.Options()
+	indices = append([]int(nil), indices...)
+	sort.Ints(indices)
+
+	var values []string
+	for i, idx := range indices {
+		if idx < 0 || idx >= len(opts) {
+			return fmt.Errorf("choice option index out of range: %d", idx)
+		}
+		if i > 0 && indices[i-1] == idx {
+			return fmt.Errorf("duplicate choice option index: %d", idx)
+		}
+		values = append(values, opts[idx].Export)
+	}
+
+	ch.setSelection(values, indices)
+	return nil
+}
+
+// SetValues selects the options of the choice field matching `values`. The
+// values can be either export values or display texts of the options. Editable
+// combo boxes also accept a single custom value, not matching any option.
+// Selecting more than one option is only allowed for multi select fields.
+// Providing no values clears the selection.
+func (ch *PdfFieldChoice) SetValues(values ...string) error {
+	if len(values) > 1 && !ch.IsMultiSelect() {
+		return errors.New("multiple selection not allowed")
+	}
+
+	opts := ch.Options()
+	var indices []int
+	for _, value := range values {
+		idx := -1
+		for i, opt := range opts {
+			if opt.Export == value {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			for i, opt := range opts {
+				if opt.Display == value {
+					idx = i
+					break
+				}
+			}
+		}
+
+		if idx < 0 {
+			if len(values) == 1 && ch.IsEditable() {
+				ch.setSelection(values, nil)
+				return nil
+			}
+			return fmt.Errorf("invalid choice value: %s", value)
+		}
+		indices = append(indices, idx)
+	}
+
+	return ch.SetSelectedIndices(indices...)
+}
+
+// setSelection sets the value (V) of the choice field to `values` and the
+// selected indices (I) to `indices`. I is only set for multi select fields.
+func (ch *PdfFieldChoice) setSelection(values []string, indices []int) {
+	switch len(values) {
+	case 0:
+		ch.V = nil
+	case 1:
+		ch.V = core.MakeEncodedString(values[0], true)
+	default:
+		arr := core.MakeArray()
+		for _, value := range values {
+			arr.Append(core.MakeEncodedString(value, true))
+		}
+		ch.V = arr
+	}
+
+	ch.I = nil
+	if ch.IsMultiSelect() && len(indices) > 0 {
+		ch.I = core.MakeArray()
+		for _, idx := range indices {
+			ch.I.Append(core.MakeInteger(int64(idx)))
+		}
+	}
+}
+
+// choiceOptionText returns the text represented by choice option object `obj`.
+func choiceOptionText(obj core.PdfObject) (string, bool) {
+	switch t := core.TraceToDirectObject(obj).(type) {
+	case *core.PdfObjectString:
+		return t.Decoded(), true
+	case *core.PdfObjectName:
+		return t.String(), true
+	}
+	return "", false
+}
+
 // PdfFieldSignature signature field represents digital signatures and optional data for authenticating
 // the name of the signer and verifying document contents.
 type PdfFieldSignature struct {
@@ -458,6 +706,51 @@ func NewPdfFieldSignature(signature *PdfSignature) *PdfFieldSignature {
 	return field
 }
 
+// FieldMDPAction specifies the form fields locked by a signature field
+// (FieldMDP).
+type FieldMDPAction string
+
+// FieldMDP actions.
+const (
+	// FieldMDPAll locks all the form fields.
+	FieldMDPAll FieldMDPAction = "All"
+
+	// FieldMDPInclude locks the specified form fields only.
+	FieldMDPInclude FieldMDPAction = "Include"
+
+	// FieldMDPExclude locks all the form fields except the specified ones.
+	FieldMDPExclude FieldMDPAction = "Exclude"
+)
+
+// SetLock sets the Lock dictionary of the signature field, which specifies
+// the form fields locked once the field is signed. The fields are specified
+// by their full names and are ignored for FieldMDPAll.
+// When the field is signed (PdfAppender.Sign), a FieldMDP reference with the
+// same parameters is added to its signature.
+func (sig *PdfFieldSignature) SetLock(action FieldMDPAction, fields ...string) error {
+	lock := core.MakeDict()
+	lock.Set("Type", core.MakeName("SigFieldLock"))
+	switch action {
+	case FieldMDPAll:
+		lock.Set("Action", core.MakeName(string(action)))
+	case FieldMDPInclude, FieldMDPExclude:
+		if len(fields) == 0 {
+			return fmt.Errorf("no fields specified for the %s action", action)
+		}
+		lock.Set("Action", core.MakeName(string(action)))
+		names := core.MakeArray()
+		for _, name := range fields {
+			names.Append(core.MakeString(name))
+		}
+		lock.Set("Fields", names)
+	default:
+		return fmt.Errorf("invalid FieldMDP action: %q", action)
+	}
+
+	sig.Lock = core.MakeIndirectObject(lock)
+	return nil
+}
+
 // ToPdfObject returns an indirect object containing the signature field dictionary.
 func (sig *PdfFieldSignature) ToPdfObject() core.PdfObject {
 	// Set general field attributes.
@@ -527,7 +820,7 @@ func (f *PdfField) Flags() FieldFlag {
 	var flags FieldFlag
 	found, err := f.inherit(func(node *PdfField) bool {
 		if node.Ff != nil {
-			flags = FieldFlag(*f.Ff)
+			flags = FieldFlag(*node.Ff)
 			return true
 		}
 		return false
@@ -748,6 +1041,8 @@ func newPdfFieldTextFromDict(d *core.PdfObjectDictionary) (*PdfFieldText, error)
 // This function loads only choice-field specific fields (called by a more generic field loader).
 func newPdfFieldChoiceFromDict(d *core.PdfObjectDictionary) (*PdfFieldChoice, error) {
 	choicef := &PdfFieldChoice{}
+	choicef.DA, _ = core.GetString(d.Get("DA"))
+	choicef.Q, _ = core.GetInt(d.Get("Q"))
 	choicef.Opt, _ = core.GetArray(d.Get("Opt"))
 	choicef.TI, _ = core.GetInt(d.Get("TI"))
 	choicef.I, _ = core.GetArray(d.Get("I"))
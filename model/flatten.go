@@ -36,6 +36,9 @@ type FieldAppearanceGenerator interface {
 // annotations intact.
 // When `appgen` is not nil, it will be used to generate appearance streams for the field annotations.
 func (r *PdfReader) FlattenFields(allannots bool, appgen FieldAppearanceGenerator) error {
+	if err := r.checkDecrypted(); err != nil {
+		return err
+	}
 	// Load all target widget annotations to be flattened into a map.
 	// The bool value indicates whether the annotation has value content.
 	ftargets := map[*PdfAnnotation]bool{}
@@ -249,3 +252,184 @@ func getAnnotationActiveAppearance(annot *PdfAnnotation) (*XObjectForm, *PdfRect
 	common.Log.Debug("Invalid type for N: %T", nobj)
 	return nil, nil, errors.New("type check error")
 }
+
+// Annotation flags (Table 165 p. 385).
+const (
+	annotationFlagHidden = 1 << 1
+	annotationFlagNoView = 1 << 5
+)
+
+// AnnotationFlattenOptions defines the annotations kept when flattening
+// annotations.
+type AnnotationFlattenOptions struct {
+	// KeepPopups keeps the popup annotations of the pages.
+	KeepPopups bool
+	// KeepLinks keeps the link annotations of the pages, which remain active.
+	KeepLinks bool
+}
+
+// FlattenAnnotations flattens the annotations of all the pages of the
+// document. See PdfPage.FlattenAnnotations. The form fields are flattened
+// first with FlattenFields, which removes the AcroForm along with the field
+// widgets.
+func (r *PdfReader) FlattenAnnotations(opts *AnnotationFlattenOptions) error {
+	if r.AcroForm != nil {
+		if err := r.FlattenFields(false, nil); err != nil {
+			return err
+		}
+	}
+	for _, page := range r.PageList {
+		if err := page.FlattenAnnotations(opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FlattenAnnotations draws the normal appearance of the annotations of the
+// page in the page content and removes the annotations from the page.
+// The appearance streams are mapped from their transformed bounding boxes to
+// the annotation rectangles, as viewers do (section 12.5.5 p. 389).
+// Hidden and NoView annotations are removed without being drawn. Popup and
+// link annotations are kept if specified by `opts`, which can be nil.
+// Widget annotations are kept, as they are referenced by the form fields of
+// the document: use PdfReader.FlattenFields to flatten them.
+func (p *PdfPage) FlattenAnnotations(opts *AnnotationFlattenOptions) error {
+	if opts == nil {
+		opts = &AnnotationFlattenOptions{}
+	}
+
+	annotations, err := p.GetAnnotations()
+	if err != nil {
+		return err
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	var kept []*PdfAnnotation
+	var ops []string
+	for _, annot := range annotations {
+		switch annot.GetContext().(type) {
+		case *PdfAnnotationWidget:
+			kept = append(kept, annot)
+			continue
+		case *PdfAnnotationPopup:
+			if opts.KeepPopups {
+				kept = append(kept, annot)
+			}
+			continue
+		case *PdfAnnotationLink:
+			if opts.KeepLinks {
+				kept = append(kept, annot)
+			}
+			continue
+		}
+
+		if flags, ok := core.GetIntVal(annot.F); ok && flags&(annotationFlagHidden|annotationFlagNoView) != 0 {
+			continue
+		}
+
+		xform, rect, err := getAnnotationActiveAppearance(annot)
+		if err != nil {
+			common.Log.Debug("WARN: annotation without appearance stream: %v - skipping over", err)
+			continue
+		}
+		if xform == nil {
+			continue
+		}
+
+		matrix, ok := getAppearanceToRectMatrix(xform, rect)
+		if !ok {
+			common.Log.Debug("WARN: invalid appearance bounding box - skipping over")
+			continue
+		}
+
+		if p.Resources == nil {
+			p.Resources = NewPdfPageResources()
+		}
+		name := p.Resources.GenerateXObjectName()
+		if err := p.Resources.SetXObjectFormByName(name, xform); err != nil {
+			return err
+		}
+		ops = append(ops,
+			"q",
+			fmt.Sprintf("%.6f %.6f %.6f %.6f %.6f %.6f cm",
+				matrix[0], matrix[1], matrix[2], matrix[3], matrix[4], matrix[5]),
+			fmt.Sprintf("/%s Do", name.String()),
+			"Q",
+		)
+	}
+
+	if len(ops) > 0 {
+		// Wrap the page content so that the appearances are drawn in the
+		// default graphics state.
+		cstreams, err := p.GetContentStreams()
+		if err != nil {
+			return err
+		}
+		cstreams = append([]string{"q"}, cstreams...)
+		cstreams = append(cstreams, "Q\n"+strings.Join(ops, "\n"))
+		if err := p.SetContentStreams(cstreams, core.NewFlateEncoder()); err != nil {
+			return err
+		}
+	}
+
+	if len(kept) > 0 {
+		p.annotations = kept
+	} else {
+		p.annotations = []*PdfAnnotation{}
+	}
+	return nil
+}
+
+// getAppearanceToRectMatrix returns the matrix mapping the bounding box of
+// the appearance `xform`, transformed by its Matrix, to `rect`. The matrix is
+// returned as an array of the 6 values [a b c d e f].
+func getAppearanceToRectMatrix(xform *XObjectForm, rect *PdfRectangle) ([6]float64, bool) {
+	var m [6]float64
+	bboxArr, ok := core.GetArray(xform.BBox)
+	if !ok {
+		return m, false
+	}
+	bbox, err := NewPdfRectangle(*bboxArr)
+	if err != nil {
+		return m, false
+	}
+
+	formMatrix := [6]float64{1, 0, 0, 1, 0, 0}
+	if arr, ok := core.GetArray(xform.Matrix); ok {
+		if vals, err := arr.ToFloat64Array(); err == nil && len(vals) == 6 {
+			copy(formMatrix[:], vals)
+		}
+	}
+
+	// Bounding box of the transformed appearance bounding box.
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, pt := range [][2]float64{
+		{bbox.Llx, bbox.Lly}, {bbox.Urx, bbox.Lly},
+		{bbox.Urx, bbox.Ury}, {bbox.Llx, bbox.Ury},
+	} {
+		x := formMatrix[0]*pt[0] + formMatrix[2]*pt[1] + formMatrix[4]
+		y := formMatrix[1]*pt[0] + formMatrix[3]*pt[1] + formMatrix[5]
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+
+	rectLlx, rectLly := math.Min(rect.Llx, rect.Urx), math.Min(rect.Lly, rect.Ury)
+	sx, sy := 1.0, 1.0
+	if w := maxX - minX; w > 0 {
+		sx = rect.Width() / w
+	} else if rect.Width() > 0 {
+		return m, false
+	}
+	if h := maxY - minY; h > 0 {
+		sy = rect.Height() / h
+	} else if rect.Height() > 0 {
+		return m, false
+	}
+
+	m = [6]float64{sx, 0, 0, sy, rectLlx - minX*sx, rectLly - minY*sy}
+	return m, true
+}
@@ -322,6 +322,8 @@ func newPdfFontFromPdfObject(fontObj core.PdfObject, allowType0 bool) (*PdfFont,
 	case "Type1", "Type3", "MMType1", "TrueType":
 		var simplefont *pdfFontSimple
 		fnt, builtin := fonts.NewStdFontByName(fonts.StdFontName(base.basefont))
+		// Type3 fonts are defined by their glyph descriptions, whatever their names.
+		builtin = builtin && base.subtype != "Type3"
 		if builtin {
 			std := stdFontToSimpleFont(fnt)
 			font.context = &std
@@ -345,6 +347,7 @@ func newPdfFontFromPdfObject(fontObj core.PdfObject, allowType0 bool) (*PdfFont,
 
 			simplefont.charWidths = std.charWidths
 			simplefont.fontMetrics = std.fontMetrics
+			simplefont.std14Descriptor = std.std14Descriptor
 		} else {
 			simplefont, err = newSimpleFontFromPdfObject(d, base, nil)
 			if err != nil {
@@ -359,6 +362,12 @@ func newPdfFontFromPdfObject(fontObj core.PdfObject, allowType0 bool) (*PdfFont,
 		if builtin {
 			simplefont.updateStandard14Font()
 		}
+		if base.subtype == "Type3" {
+			if err := simplefont.loadType3(d); err != nil {
+				common.Log.Debug("ERROR: While loading Type3 font: font=%s err=%v", base, err)
+				return nil, err
+			}
+		}
 		if builtin && simplefont.encoder == nil && simplefont.std14Encoder == nil {
 			// This is not possible.
 			common.Log.Error("simplefont=%s", simplefont)
@@ -695,7 +704,9 @@ func (base fontCommon) asPdfObjectDictionary(subtype string) *core.PdfObjectDict
 
 	d := core.MakeDict()
 	d.Set("Type", core.MakeName("Font"))
-	d.Set("BaseFont", core.MakeName(base.basefont))
+	if base.basefont != "" || base.subtype != "Type3" {
+		d.Set("BaseFont", core.MakeName(base.basefont))
+	}
 	d.Set("Subtype", core.MakeName(base.subtype))
 
 	if base.fontDescriptor != nil {
@@ -791,13 +802,9 @@ func newFontBaseFieldsFromPdfObject(fontObj core.PdfObject) (*core.PdfObjectDict
 		font.name = name
 	}
 
-	if subtype == "Type3" {
-		common.Log.Debug("ERROR: Type 3 font not supported. d=%s", d)
-		return d, font, ErrType3FontNotSupported
-	}
-
+	// BaseFont is optional in Type3 fonts.
 	basefont, ok := core.GetNameVal(d.Get("BaseFont"))
-	if !ok {
+	if !ok && subtype != "Type3" {
 		common.Log.Debug("ERROR: Font Incompatibility. BaseFont (Required) missing")
 		return d, font, ErrRequiredAttributeMissing
 	}
@@ -63,6 +63,13 @@ type pdfFontSimple struct {
 
 	// Standard 14 fonts metrics
 	fontMetrics map[rune]fonts.CharMetrics
+
+	// Fields specific to Type3 fonts (see font_type3.go), nil for the other simple fonts.
+	FontMatrix core.PdfObject
+	FontBBox   core.PdfObject
+	CharProcs  core.PdfObject
+	Resources  core.PdfObject
+	type3      *type3Glyphs
 }
 
 // pdfCIDFontType0FromSkeleton returns a pdfFontSimple with its common fields initalized.
@@ -378,6 +385,18 @@ func (font *pdfFontSimple) ToPdfObject() core.PdfObject {
 			d.Set("Encoding", encObj)
 		}
 	}
+	if font.FontMatrix != nil {
+		d.Set("FontMatrix", font.FontMatrix)
+	}
+	if font.FontBBox != nil {
+		d.Set("FontBBox", font.FontBBox)
+	}
+	if font.CharProcs != nil {
+		d.Set("CharProcs", font.CharProcs)
+	}
+	if font.Resources != nil {
+		d.Set("Resources", font.Resources)
+	}
 
 	return font.container
 }
@@ -246,6 +246,16 @@ func TestLoadStandardFontEncodings(t *testing.T) {
 			t.Fatalf("Loaded simple font not having glyph char metrics for %v", r)
 		}
 	}
+
+	// The builtin font descriptor is used, as the font has no FontDescriptor.
+	descriptor, err := font.GetFontDescriptor()
+	if err != nil || descriptor == nil {
+		t.Fatalf("Loaded simple font not having the builtin font descriptor: %v", err)
+	}
+	capHeight, err := descriptor.GetCapHeight()
+	if err != nil || capHeight != 562 {
+		t.Fatalf("Loaded simple font CapHeight %v != 562 (%v)", capHeight, err)
+	}
 }
 
 // TestCompositeFonts checks that we correctly recreate composite fonts that we parse.
@@ -884,3 +894,86 @@ func TestNewFontFromFile(t *testing.T) {
 		t.Fatalf("Failed to load font from file. err=%v", err)
 	}
 }
+
+// type3Font is a Type3 font with glyph space units of 1/100 text space units, a custom glyph
+// name and glyph descriptions using resources.
+const type3Font = `
+1 0 obj
+<<
+	/Type /Font
+	/Subtype /Type3
+	/FontBBox [0 0 100 100]
+	/FontMatrix [0.01 0 0 0.01 0 0]
+	/CharProcs << /A 2 0 R /g1 3 0 R >>
+	/Encoding << /Type /Encoding /Differences [65 /A /g1] >>
+	/FirstChar 65
+	/LastChar 67
+	/Widths [100 50 0]
+	/Resources << /XObject << /Im0 4 0 R >> >>
+>>
+endobj
+2 0 obj
+<< /Length 8 >>
+stream
+100 0 d0
+endstream
+endobj
+3 0 obj
+<< /Length 17 >>
+stream
+50 0 0 0 50 50 d1
+endstream
+endobj
+4 0 obj
+<< /Type /XObject /Subtype /Image /Width 1 /Height 1 /BitsPerComponent 8
+   /ColorSpace /DeviceGray /Length 1 >>
+stream
+0
+endstream
+endobj
+`
+
+func TestType3Font(t *testing.T) {
+	objects, err := testutils.ParseIndirectObjects(type3Font)
+	require.NoError(t, err)
+	font, err := model.NewPdfFontFromPdfObject(objects[1])
+	require.NoError(t, err)
+	require.Equal(t, "Type3", font.Subtype())
+	require.Equal(t, []float64{0.01, 0, 0, 0.01, 0, 0}, font.FontMatrix())
+
+	// The widths are converted from glyph space to thousandths of text space units.
+	metrics, ok := font.GetCharMetrics('A')
+	require.True(t, ok)
+	require.Equal(t, 1000.0, metrics.Wx)
+	metrics, ok = font.GetCharMetrics('B')
+	require.True(t, ok)
+	require.Equal(t, 500.0, metrics.Wx)
+
+	// The glyph descriptions are found by glyph name.
+	stream, ok := font.CharProc('A')
+	require.True(t, ok)
+	require.Equal(t, objects[2], stream)
+	stream, ok = font.CharProc('B')
+	require.True(t, ok)
+	require.Equal(t, objects[3], stream)
+	_, ok = font.CharProc('C')
+	require.False(t, ok)
+	resources := font.CharProcResources()
+	require.NotNil(t, resources)
+	require.True(t, resources.HasXObjectByName("Im0"))
+
+	// The Type3 entries are written back, without BaseFont.
+	dict, ok := core.GetDict(font.ToPdfObject())
+	require.True(t, ok)
+	for _, key := range []core.PdfObjectName{"FontMatrix", "FontBBox", "CharProcs", "Resources", "Widths"} {
+		require.NotNil(t, dict.Get(key), key)
+	}
+	require.Nil(t, dict.Get("BaseFont"))
+
+	// The other fonts have 1000 glyph space units per text space unit.
+	helvetica := model.NewStandard14FontMustCompile(model.HelveticaName)
+	require.Equal(t, []float64{0.001, 0, 0, 0.001, 0, 0}, helvetica.FontMatrix())
+	_, ok = helvetica.CharProc('A')
+	require.False(t, ok)
+	require.Nil(t, helvetica.CharProcResources())
+}
@@ -0,0 +1,136 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/textencoding"
+)
+
+// 9.6.5 Type 3 Fonts (page 258)
+// In Type 3 fonts, glyphs shall be defined by streams of PDF graphics operators. These streams
+// shall be associated with glyph names. A font encoding shall map character codes to glyph names,
+// rather than directly to glyph procedures.
+// The glyph descriptions (CharProcs) are executed with the FontMatrix, which maps glyph space to
+// text space, so their widths are in glyph space rather than in thousandths of text space units.
+
+// type3Glyphs holds the glyph descriptions of a Type3 font.
+type type3Glyphs struct {
+	fontMatrix []float64
+	charProcs  map[textencoding.CharCode]*core.PdfObjectStream
+	resources  *PdfPageResources
+}
+
+// defaultFontMatrix is the FontMatrix of the fonts other than Type3 fonts, with glyph spaces of
+// 1000 units per text space unit.
+var defaultFontMatrix = []float64{0.001, 0, 0, 0.001, 0, 0}
+
+// loadType3 loads the fields specific to Type3 fonts from font dictionary `d`: FontMatrix,
+// FontBBox, CharProcs and Resources. The glyph widths are converted from glyph space to thousandths
+// of text space units, like the widths of the other fonts.
+func (font *pdfFontSimple) loadType3(d *core.PdfObjectDictionary) error {
+	font.FontMatrix = d.Get("FontMatrix")
+	font.FontBBox = d.Get("FontBBox")
+	font.CharProcs = d.Get("CharProcs")
+	font.Resources = d.Get("Resources")
+
+	type3 := &type3Glyphs{fontMatrix: defaultFontMatrix}
+	if font.FontMatrix != nil {
+		arr, ok := core.GetArray(font.FontMatrix)
+		if !ok || arr.Len() != 6 {
+			common.Log.Debug("ERROR: Invalid FontMatrix: %v", font.FontMatrix)
+			return core.ErrTypeError
+		}
+		matrix, err := arr.ToFloat64Array()
+		if err != nil {
+			return err
+		}
+		type3.fontMatrix = matrix
+	} else {
+		common.Log.Debug("ERROR: Type3 font without FontMatrix. font=%s", font)
+	}
+	for code, width := range font.charWidths {
+		font.charWidths[code] = width * type3.fontMatrix[0] * 1000
+	}
+
+	if font.Resources != nil {
+		dict, ok := core.GetDict(font.Resources)
+		if !ok {
+			common.Log.Debug("ERROR: Type3 font Resources not a dictionary (%T)", font.Resources)
+			return core.ErrTypeError
+		}
+		resources, err := NewPdfPageResourcesFromDict(dict)
+		if err != nil {
+			return err
+		}
+		type3.resources = resources
+	}
+
+	type3.charProcs = map[textencoding.CharCode]*core.PdfObjectStream{}
+	charProcs, ok := core.GetDict(font.CharProcs)
+	if !ok {
+		common.Log.Debug("ERROR: Type3 font without CharProcs. font=%s", font)
+		font.type3 = type3
+		return nil
+	}
+	baseName, differences, err := font.getFontEncoding()
+	if err != nil {
+		return err
+	}
+	baseEncoder, err := textencoding.NewSimpleTextEncoder(baseName, nil)
+	if err != nil {
+		return err
+	}
+	for code := textencoding.CharCode(0); code < 256; code++ {
+		glyph, ok := differences[code]
+		if !ok {
+			r, ok := baseEncoder.CharcodeToRune(code)
+			if !ok {
+				continue
+			}
+			if glyph, ok = textencoding.RuneToGlyph(r); !ok {
+				continue
+			}
+		}
+		if stream, ok := core.GetStream(charProcs.Get(core.PdfObjectName(glyph))); ok {
+			type3.charProcs[code] = stream
+		}
+	}
+	font.type3 = type3
+	return nil
+}
+
+// FontMatrix returns the matrix [a b c d e f] mapping the glyph space of `font` to text space: the
+// FontMatrix of Type3 fonts, and a scaling by 1/1000 for the other fonts.
+func (font *PdfFont) FontMatrix() []float64 {
+	if simple, ok := font.context.(*pdfFontSimple); ok && simple.type3 != nil {
+		return append([]float64(nil), simple.type3.fontMatrix...)
+	}
+	return append([]float64(nil), defaultFontMatrix...)
+}
+
+// CharProc returns the glyph description (CharProc) of character code `code` of the Type3 font
+// `font`, a content stream painting the glyph in glyph space. The bool return flag is false if
+// `font` is not a Type3 font or has no glyph description for `code`.
+func (font *PdfFont) CharProc(code textencoding.CharCode) (*core.PdfObjectStream, bool) {
+	simple, ok := font.context.(*pdfFontSimple)
+	if !ok || simple.type3 == nil {
+		return nil, false
+	}
+	stream, ok := simple.type3.charProcs[code]
+	return stream, ok
+}
+
+// CharProcResources returns the resources used by the glyph descriptions of the Type3 font
+// `font`, or nil if it has none, in which case the glyph descriptions use the resources of the
+// content streams showing them.
+func (font *PdfFont) CharProcResources() *PdfPageResources {
+	if simple, ok := font.context.(*pdfFontSimple); ok && simple.type3 != nil {
+		return simple.type3.resources
+	}
+	return nil
+}
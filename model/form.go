@@ -323,12 +323,12 @@ func fillFieldValue(f *PdfField, val core.PdfObject) error {
 		case *core.PdfObjectName:
 			if len(val.String()) > 0 {
 				f.V = val
-				setFieldAnnotAS(f, val)
+				setButtonAnnotAS(f, val.String())
 			}
 		case *core.PdfObjectString:
 			if len(val.String()) > 0 {
 				f.V = core.MakeName(val.String())
-				setFieldAnnotAS(f, f.V)
+				setButtonAnnotAS(f, val.String())
 			}
 		default:
 			common.Log.Debug("ERROR: UNEXPECTED %s -> %v", f.PartialName(), val)
@@ -336,16 +336,32 @@ func fillFieldValue(f *PdfField, val core.PdfObject) error {
 		}
 	case *PdfFieldChoice:
 		// See section 12.7.4.4 "Choice Fields" (pp. 444-446 PDF32000_2008).
-		switch val.(type) {
+		ch := f.GetContext().(*PdfFieldChoice)
+		switch t := val.(type) {
 		case *core.PdfObjectName:
 			if len(val.String()) > 0 {
-				f.V = core.MakeString(val.String())
-				setFieldAnnotAS(f, val)
+				if err := ch.SetValues(t.String()); err != nil {
+					common.Log.Debug("WARN: %s: %v - setting value as is", f.PartialName(), err)
+					f.V = core.MakeString(val.String())
+				}
 			}
 		case *core.PdfObjectString:
 			if len(val.String()) > 0 {
+				if err := ch.SetValues(t.Decoded()); err != nil {
+					common.Log.Debug("WARN: %s: %v - setting value as is", f.PartialName(), err)
+					f.V = val
+				}
+			}
+		case *core.PdfObjectArray:
+			var values []string
+			for _, obj := range t.Elements() {
+				if text, ok := choiceOptionText(obj); ok {
+					values = append(values, text)
+				}
+			}
+			if err := ch.SetValues(values...); err != nil {
+				common.Log.Debug("WARN: %s: %v - setting value as is", f.PartialName(), err)
 				f.V = val
-				setFieldAnnotAS(f, core.MakeName(val.String()))
 			}
 		default:
 			common.Log.Debug("ERROR: UNEXPECTED %s -> %v", f.PartialName(), val)
@@ -358,10 +374,20 @@ func fillFieldValue(f *PdfField, val core.PdfObject) error {
 	return nil
 }
 
-// setFieldAnnotAS sets the appearance stream of the field annotations to `val`.
-func setFieldAnnotAS(f *PdfField, val core.PdfObject) {
+// setButtonAnnotAS sets the appearance state of the button field annotations
+// to `state` for the widgets which have an appearance for it (i.e. `state` is
+// their export value) and to Off for the others. Widgets without appearance
+// states are set to `state`.
+func setButtonAnnotAS(f *PdfField, state string) {
 	for _, wa := range f.Annotations {
-		wa.AS = val
+		as := core.MakeName(state)
+		if apDict, has := core.GetDict(wa.AP); has {
+			if stateDict, has := core.GetDict(apDict.Get("N")); has && stateDict.Get(*as) == nil {
+				as = core.MakeName("Off")
+			}
+		}
+
+		wa.AS = as
 		wa.ToPdfObject()
 	}
 }
@@ -209,3 +209,146 @@ func TestAcroFormNeedsRepair(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, needsRepair, true)
 }
+
+// testFieldValues implements FieldValueProvider.
+type testFieldValues map[string]core.PdfObject
+
+func (v testFieldValues) FieldValues() (map[string]core.PdfObject, error) {
+	return v, nil
+}
+
+// loadTestField loads the field from object number `num` of the indirect objects in `rawText`.
+func loadTestField(t *testing.T, rawText string, num int) *PdfField {
+	r := NewReaderForText(rawText)
+	require.NoError(t, r.ParseIndObjSeries())
+
+	obj, err := r.parser.LookupByNumber(num)
+	require.NoError(t, err)
+	ind, ok := obj.(*core.PdfIndirectObject)
+	require.True(t, ok)
+
+	field, err := r.newPdfFieldFromIndirectObject(ind, nil)
+	require.NoError(t, err)
+	return field
+}
+
+func TestChoiceFieldSingleSelect(t *testing.T) {
+	rawText := `
+1 0 obj
+<<
+/Type /Annot
+/Subtype /Widget
+/Rect [100 100 200 120]
+/FT /Ch
+/Ff 393216
+/T (Country)
+/DA (/Helv 10 Tf 0 g)
+/Opt [[(no) (Norway)] [(se) (Sweden)] (Other)]
+/V (se)
+>>
+endobj
+`
+	field := loadTestField(t, rawText, 1)
+	ch, ok := field.GetContext().(*PdfFieldChoice)
+	require.True(t, ok)
+
+	require.True(t, ch.IsCombo())
+	require.True(t, ch.IsEditable())
+	require.False(t, ch.IsMultiSelect())
+	require.False(t, ch.IsSorted())
+	require.Equal(t, "/Helv 10 Tf 0 g", ch.DA.Str())
+	require.Equal(t, []*PdfFieldChoiceOption{
+		{Export: "no", Display: "Norway"},
+		{Export: "se", Display: "Sweden"},
+		{Export: "Other", Display: "Other"},
+	}, ch.Options())
+	require.Equal(t, []string{"se"}, ch.Values())
+	require.Equal(t, []int{1}, ch.SelectedIndices())
+
+	// Select by display value.
+	require.NoError(t, ch.SetValues("Norway"))
+	require.Equal(t, []string{"no"}, ch.Values())
+	require.Equal(t, []int{0}, ch.SelectedIndices())
+	require.Nil(t, ch.I)
+
+	// Multiple selection is not allowed.
+	require.Error(t, ch.SetValues("no", "se"))
+	require.Error(t, ch.SetSelectedIndices(0, 1))
+	require.Error(t, ch.SetSelectedIndices(3))
+
+	// Custom values are accepted by editable combo boxes only.
+	require.NoError(t, ch.SetValues("Denmark"))
+	require.Equal(t, []string{"Denmark"}, ch.Values())
+	require.Empty(t, ch.SelectedIndices())
+
+	ch.SetFlag(FieldFlagCombo)
+	require.Error(t, ch.SetValues("Finland"))
+
+	// Fill accepts both export and display values.
+	form := NewPdfAcroForm()
+	*form.Fields = append(*form.Fields, field)
+	require.NoError(t, form.Fill(testFieldValues{"Country": core.MakeString("Sweden")}))
+	require.Equal(t, []string{"se"}, ch.Values())
+	// Choice fields have no appearance states.
+	require.Nil(t, ch.Annotations[0].AS)
+
+	require.NoError(t, form.Fill(testFieldValues{"Country": core.MakeName("no")}))
+	require.Equal(t, []string{"no"}, ch.Values())
+}
+
+func TestChoiceFieldMultiSelect(t *testing.T) {
+	rawText := `
+1 0 obj
+<<
+/FT /Ch
+/Ff 2621440
+/T (Fruits)
+/Opt [(Apple) (Banana) (Cherry) (Date)]
+/V [(Banana) (Date)]
+/I [3 1]
+/TI 1
+/Kids [2 0 R]
+>>
+endobj
+
+2 0 obj
+<<
+/Type /Annot
+/Subtype /Widget
+/Rect [100 100 200 160]
+/Parent 1 0 R
+>>
+endobj
+`
+	field := loadTestField(t, rawText, 1)
+	ch, ok := field.GetContext().(*PdfFieldChoice)
+	require.True(t, ok)
+
+	require.False(t, ch.IsCombo())
+	require.True(t, ch.IsMultiSelect())
+	require.True(t, ch.IsSorted())
+	require.Len(t, ch.Options(), 4)
+	require.Equal(t, []string{"Banana", "Date"}, ch.Values())
+	require.Equal(t, []int{1, 3}, ch.SelectedIndices())
+
+	require.NoError(t, ch.SetSelectedIndices(2, 0))
+	require.Equal(t, []string{"Apple", "Cherry"}, ch.Values())
+	require.Equal(t, []int{0, 2}, ch.SelectedIndices())
+	require.Equal(t, 2, ch.I.Len())
+
+	require.Error(t, ch.SetSelectedIndices(1, 1))
+	require.Error(t, ch.SetValues("Apple", "Kiwi"))
+
+	// Fill with an array of values.
+	form := NewPdfAcroForm()
+	*form.Fields = append(*form.Fields, field)
+	values := core.MakeArray(core.MakeString("Date"), core.MakeString("Apple"))
+	require.NoError(t, form.Fill(testFieldValues{"Fruits": values}))
+	require.Equal(t, []string{"Apple", "Date"}, ch.Values())
+	require.Equal(t, []int{0, 3}, ch.SelectedIndices())
+
+	// Clear the selection.
+	require.NoError(t, ch.SetValues())
+	require.Nil(t, ch.V)
+	require.Nil(t, ch.I)
+}
@@ -301,56 +301,56 @@ func (f *PdfFunctionType0) Evaluate(x []float64) ([]float64, error) {
 		decode = f.Range
 	}
 
-	var indices []int
-	// Start with nearest neighbour interpolation.
-	for i := 0; i < len(x); i++ {
-		xi := x[i]
-
-		// See section 7.10.2 Type 0 (Sampled) Functions (pp. 93-94 PDF32000_2008).
+	// Coordinates into the sample table.
+	// See section 7.10.2 Type 0 (Sampled) Functions (pp. 93-94 PDF32000_2008).
+	coords := make([]float64, len(x))
+	for i, xi := range x {
 		xip := math.Min(math.Max(xi, f.Domain[2*i]), f.Domain[2*i+1])
 		ei := interpolate(xip, f.Domain[2*i], f.Domain[2*i+1], encode[2*i], encode[2*i+1])
-		eip := math.Min(math.Max(ei, 0), float64(f.Size[i]-1))
-		// eip represents coordinate into the data table.
-		// At this point it is real values.
-
-		// Interpolation shall be used to to determine output values
-		// from the nearest surrounding values in the sample table.
-
-		// Initial implementation is simply nearest neighbour.
-		// Then will add the linear and possibly bicubic/spline.
-		index := int(math.Floor(eip + 0.5))
-		if index < 0 {
-			index = 0
-		} else if index > f.Size[i] {
-			index = f.Size[i] - 1
-		}
-		indices = append(indices, index)
-	}
-
-	// Calculate the index
-	m := indices[0]
-	for i := 1; i < f.NumInputs; i++ {
-		add := indices[i]
-		for j := 0; j < i; j++ {
-			add *= f.Size[j]
+		coords[i] = math.Min(math.Max(ei, 0), float64(f.Size[i]-1))
+	}
+
+	// Interpolation shall be used to to determine output values from the nearest surrounding
+	// values in the sample table. Multilinear interpolation is used for both orders, the cubic
+	// spline interpolation of order 3 is not supported.
+	samples := make([]float64, f.NumOutputs)
+	for corner := 0; corner < 1<<uint(len(coords)); corner++ {
+		// The weight of the surrounding sample at `corner` and its index in the table.
+		weight := 1.0
+		index, stride := 0, 1
+		for i, ei := range coords {
+			lo := math.Floor(ei)
+			frac := ei - lo
+			idx := int(lo)
+			if corner&(1<<uint(i)) != 0 {
+				idx++
+				weight *= frac
+			} else {
+				weight *= 1 - frac
+			}
+			index += idx * stride
+			stride *= f.Size[i]
+		}
+		if weight == 0 {
+			continue
 		}
-		m += add
-	}
-	m *= f.NumOutputs
 
-	// Output values.
-	var outputs []float64
-	for j := 0; j < f.NumOutputs; j++ {
-		rjIdx := m + j
-		if rjIdx >= len(f.data) {
+		m := index * f.NumOutputs
+		if m+f.NumOutputs > len(f.data) {
 			common.Log.Debug("WARN: not enough input samples to determine output values. Output may be incorrect.")
 			continue
 		}
+		for j := range samples {
+			samples[j] += weight * float64(f.data[m+j])
+		}
+	}
 
-		rj := f.data[rjIdx]
-		rjp := interpolate(float64(rj), 0, math.Pow(2, float64(f.BitsPerSample)), decode[2*j], decode[2*j+1])
-		yj := math.Min(math.Max(rjp, f.Range[2*j]), f.Range[2*j+1])
-		outputs = append(outputs, yj)
+	// Output values.
+	maxSample := math.Pow(2, float64(f.BitsPerSample)) - 1
+	outputs := make([]float64, f.NumOutputs)
+	for j, rj := range samples {
+		rjp := interpolate(rj, 0, maxSample, decode[2*j], decode[2*j+1])
+		outputs[j] = math.Min(math.Max(rjp, f.Range[2*j]), f.Range[2*j+1])
 	}
 
 	return outputs, nil
@@ -538,13 +538,30 @@ func (f *PdfFunctionType2) Evaluate(x []float64) ([]float64, error) {
 		c1 = f.C1
 	}
 
+	// See section 7.10.3 Type 2 (Exponential Interpolation) Functions (p. 95 PDF32000_2008).
+	x0 := x[0]
+	if len(f.Domain) >= 2 {
+		x0 = math.Min(math.Max(x0, f.Domain[0]), f.Domain[1])
+	}
+
 	var y []float64
 	for i := 0; i < len(c0); i++ {
-		yi := c0[i] + math.Pow(x[0], f.N)*(c1[i]-c0[i])
+		yi := c0[i] + math.Pow(x0, f.N)*(c1[i]-c0[i])
 		y = append(y, yi)
 	}
 
-	return y, nil
+	return clipToRange(y, f.Range), nil
+}
+
+// clipToRange clips the values of `y` to the ranges of `rng`, the pairs of minimum and maximum
+// values, if specified.
+func clipToRange(y, rng []float64) []float64 {
+	for i := range y {
+		if 2*i+1 < len(rng) {
+			y[i] = math.Min(math.Max(y[i], rng[2*i]), rng[2*i+1])
+		}
+	}
+	return y
 }
 
 // PdfFunctionType3 defines stitching of the subdomains of several 1-input functions to produce
@@ -567,11 +584,36 @@ func (f *PdfFunctionType3) Evaluate(x []float64) ([]float64, error) {
 		return nil, errors.New("range check")
 	}
 
-	// Determine which function to use
+	// See section 7.10.4 Type 3 (Stitching) Functions (pp. 96-97 PDF32000_2008).
+	x0 := math.Min(math.Max(x[0], f.Domain[0]), f.Domain[1])
+
+	// Determine which function to use. The subdomains are
+	// [Domain0 Bounds0), [Bounds0 Bounds1), ..., [Bounds(k-2) Domain1], except that the first one
+	// is [Domain0 Bounds0] if Domain0 = Bounds0.
+	k := len(f.Functions)
+	i := 0
+	for i < k-1 && x0 >= f.Bounds[i] {
+		if i == 0 && x0 == f.Domain[0] && f.Bounds[0] == f.Domain[0] {
+			break
+		}
+		i++
+	}
+	low, high := f.Domain[0], f.Domain[1]
+	if i > 0 {
+		low = f.Bounds[i-1]
+	}
+	if i < k-1 {
+		high = f.Bounds[i]
+	}
 
-	// Encode
+	// Encode the input value for the subdomain function.
+	e := interpolate(x0, low, high, f.Encode[2*i], f.Encode[2*i+1])
+	y, err := f.Functions[i].Evaluate([]float64{e})
+	if err != nil {
+		return nil, err
+	}
 
-	return nil, errors.New("not implemented yet")
+	return clipToRange(y, f.Range), nil
 }
 
 func newPdfFunctionType3FromPdfObject(obj core.PdfObject) (*PdfFunctionType3, error) {
@@ -746,8 +788,13 @@ func (f *PdfFunctionType4) Evaluate(xVec []float64) ([]float64, error) {
 		f.executor = ps.NewPSExecutor(f.Program)
 	}
 
+	// The inputs are clipped to the domain and the outputs to the range.
+	// See section 7.10.5 Type 4 (PostScript Calculator) Functions (p. 97 PDF32000_2008).
 	var inputs []ps.PSObject
-	for _, val := range xVec {
+	for i, val := range xVec {
+		if 2*i+1 < len(f.Domain) {
+			val = math.Min(math.Max(val, f.Domain[2*i]), f.Domain[2*i+1])
+		}
 		inputs = append(inputs, ps.MakeReal(val))
 	}
 
@@ -763,7 +810,7 @@ func (f *PdfFunctionType4) Evaluate(xVec []float64) ([]float64, error) {
 		return nil, err
 	}
 
-	return yVec, nil
+	return clipToRange(yVec, f.Range), nil
 }
 
 // Load a type 4 function from a PDF stream object.
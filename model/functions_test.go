@@ -1,6 +1,4 @@
 // Test functions
-// TODO: We need tests of type 0, type 2, type 3, type 4 functions.  Particularly type 0 is complex and
-// needs comprehensive tests.
 
 package model
 
@@ -8,8 +6,11 @@ import (
 	"math"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/testutils"
 )
 
 func init() {
@@ -100,3 +101,192 @@ endobj
 
 	t.Logf("%s", stream.Stream)
 }
+
+// functionTestCase is a test case of the evaluation of a function.
+type functionTestCase struct {
+	inputs   []float64
+	expected []float64
+}
+
+// testFunction loads the function of object 10 in `rawText` and checks that it evaluates to the
+// expected outputs of `testcases`.
+func testFunction(t *testing.T, rawText string, testcases []functionTestCase) {
+	objMap, err := testutils.ParseIndirectObjects(rawText)
+	require.NoError(t, err)
+	fun, err := newPdfFunctionFromPdfObject(objMap[10])
+	require.NoError(t, err)
+
+	for _, tc := range testcases {
+		outputs, err := fun.Evaluate(tc.inputs)
+		require.NoError(t, err)
+		require.Len(t, outputs, len(tc.expected))
+		for i := range outputs {
+			require.InDelta(t, tc.expected[i], outputs[i], 1e-6, "inputs: %v outputs: %v", tc.inputs, outputs)
+		}
+	}
+}
+
+func TestType0Function(t *testing.T) {
+	// 2x2 samples of one output. The first input varies fastest:
+	//   f(0, 0) = 0, f(1, 0) = 255, f(0, 1) = 51, f(1, 1) = 102
+	testFunction(t, `
+10 0 obj
+<<
+	/FunctionType 0
+	/Domain [0 1 0 1]
+	/Range [0 1]
+	/Size [2 2]
+	/BitsPerSample 8
+	/Filter /ASCIIHexDecode
+	/Length 9
+>>
+stream
+00FF3366>
+endstream
+endobj
+`, []functionTestCase{
+		{[]float64{0, 0}, []float64{0}},
+		{[]float64{1, 0}, []float64{1}},
+		{[]float64{0, 1}, []float64{0.2}},
+		{[]float64{1, 1}, []float64{0.4}},
+		// Multilinear interpolation of the surrounding samples.
+		{[]float64{0.5, 0}, []float64{0.5}},
+		{[]float64{0, 0.5}, []float64{0.1}},
+		{[]float64{0.5, 0.5}, []float64{0.4}},
+		{[]float64{0.25, 0.5}, []float64{0.25}},
+		// The inputs are clipped to the domain.
+		{[]float64{2, -1}, []float64{1}},
+	})
+
+	// Samples of three outputs, with Encode and Decode. Only the first two samples are used.
+	testFunction(t, `
+10 0 obj
+<<
+	/FunctionType 0
+	/Domain [0 1]
+	/Range [0 1 0 10 0 1]
+	/Size [3]
+	/Encode [0 1]
+	/Decode [1 0 0 10 0 2]
+	/BitsPerSample 4
+	/Filter /ASCIIHexDecode
+	/Length 11
+>>
+stream
+000FFFF0F0>
+endstream
+endobj
+`, []functionTestCase{
+		{[]float64{0}, []float64{1, 0, 0}},
+		{[]float64{1}, []float64{0, 10, 1}},
+		{[]float64{0.2}, []float64{0.8, 2, 0.4}},
+		{[]float64{0.6}, []float64{0.4, 6, 1}},
+	})
+}
+
+func TestType2Function(t *testing.T) {
+	// f(x) = C0 + x^N * (C1 - C0)
+	testFunction(t, `
+10 0 obj
+<<
+	/FunctionType 2
+	/Domain [0 1]
+	/C0 [0 1]
+	/C1 [1 0]
+	/N 2
+>>
+endobj
+`, []functionTestCase{
+		{[]float64{0}, []float64{0, 1}},
+		{[]float64{0.5}, []float64{0.25, 0.75}},
+		{[]float64{1}, []float64{1, 0}},
+		{[]float64{2}, []float64{1, 0}},
+	})
+
+	// Default C0 and C1, with the outputs clipped to the range.
+	testFunction(t, `
+10 0 obj
+<<
+	/FunctionType 2
+	/Domain [0 2]
+	/Range [0 2]
+	/N 1
+>>
+endobj
+`, []functionTestCase{
+		{[]float64{0.5}, []float64{0.5}},
+		{[]float64{1.5}, []float64{1.5}},
+		{[]float64{3}, []float64{2}},
+	})
+}
+
+func TestType3Function(t *testing.T) {
+	// Stitching of an increasing and a decreasing linear function, the second subdomain being
+	// encoded in reverse order.
+	testFunction(t, `
+10 0 obj
+<<
+	/FunctionType 3
+	/Domain [0 2]
+	/Functions [
+		<< /FunctionType 2 /Domain [0 1] /C0 [0] /C1 [1] /N 1 >>
+		<< /FunctionType 2 /Domain [0 1] /C0 [0] /C1 [0.5] /N 1 >>
+	]
+	/Bounds [1]
+	/Encode [0 1 1 0]
+>>
+endobj
+`, []functionTestCase{
+		{[]float64{0}, []float64{0}},
+		{[]float64{0.5}, []float64{0.5}},
+		{[]float64{0.999}, []float64{0.999}},
+		// The bounds belong to the following subdomain.
+		{[]float64{1}, []float64{0.5}},
+		{[]float64{1.5}, []float64{0.25}},
+		{[]float64{2}, []float64{0}},
+		// The input is clipped to the domain.
+		{[]float64{-1}, []float64{0}},
+		{[]float64{3}, []float64{0}},
+	})
+
+	// The first subdomain is closed when it is empty.
+	testFunction(t, `
+10 0 obj
+<<
+	/FunctionType 3
+	/Domain [0 1]
+	/Functions [
+		<< /FunctionType 2 /Domain [0 1] /C0 [0.5] /C1 [0.5] /N 1 >>
+		<< /FunctionType 2 /Domain [0 1] /C0 [0] /C1 [1] /N 1 >>
+	]
+	/Bounds [0]
+	/Encode [0 1 0 1]
+>>
+endobj
+`, []functionTestCase{
+		{[]float64{0}, []float64{0.5}},
+		{[]float64{0.5}, []float64{0.5}},
+	})
+}
+
+func TestType4Function(t *testing.T) {
+	// f(x, y) = [y*y, 2*x], the inputs and outputs being clipped to [0 1].
+	testFunction(t, `
+10 0 obj
+<<
+	/FunctionType 4
+	/Domain [0 1 0 1]
+	/Range [0 1 0 1]
+	/Length 22
+>>
+stream
+{ dup mul exch 2 mul }
+endstream
+endobj
+`, []functionTestCase{
+		{[]float64{0.25, 0.5}, []float64{0.25, 0.5}},
+		{[]float64{0.6, 0.5}, []float64{0.25, 1}},
+		{[]float64{0.25, 2}, []float64{1, 0.5}},
+		{[]float64{-1, 0.1}, []float64{0.01, 0}},
+	})
+}
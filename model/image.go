@@ -15,6 +15,7 @@ import (
 	_ "image/gif"
 	_ "image/png"
 	"io"
+	"math"
 
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
@@ -37,6 +38,10 @@ type Image struct {
 	alphaData []byte // Alpha channel data.
 	hasAlpha  bool   // Indicates whether the alpha channel data is available.
 
+	// Interpolate indicates whether image interpolation should be performed when rendering the
+	// image. It is read from and written to the Interpolate entry of the image XObjects.
+	Interpolate bool
+
 	decode []float64 // [Dmin Dmax ... values for each color component]
 }
 
@@ -44,6 +49,30 @@ type Image struct {
 // thresholding the alpha channel, i.e. setting all alpha values below threshold to transparent.
 type AlphaMapFunc func(alpha byte) byte
 
+// isOpaque returns true if the image has no alpha channel or if all the
+// pixels of its 8 or 16 bit alpha channel are fully opaque.
+func (img *Image) isOpaque() bool {
+	if !img.hasAlpha {
+		return true
+	}
+	if img.BitsPerComponent != 8 && img.BitsPerComponent != 16 && !img.hasByteAlpha() {
+		return false
+	}
+	for _, a := range img.alphaData {
+		if a != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+// hasByteAlpha returns true if the alpha channel of the image has one byte
+// per pixel, as the alpha channel of the indexed images with less than 8 bits
+// per component.
+func (img *Image) hasByteAlpha() bool {
+	return img.BitsPerComponent < 8 && len(img.alphaData) == int(img.Width*img.Height)
+}
+
 // AlphaMap performs mapping of alpha data for transformations. Allows custom filtering of alpha data etc.
 func (img *Image) AlphaMap(mapFunc AlphaMapFunc) {
 	for idx, alpha := range img.alphaData {
@@ -51,6 +80,65 @@ func (img *Image) AlphaMap(mapFunc AlphaMapFunc) {
 	}
 }
 
+// AlphaData returns the alpha channel data of the image, with one component per pixel in the
+// bits per component of the image, or nil if the image has no alpha channel.
+func (img *Image) AlphaData() []byte {
+	if !img.hasAlpha {
+		return nil
+	}
+	return img.alphaData
+}
+
+// SetAlphaData sets the alpha channel data of the image to `alphaData`, with one component per
+// pixel in the bits per component of the image. nil `alphaData` removes the alpha channel.
+func (img *Image) SetAlphaData(alphaData []byte) {
+	img.alphaData = alphaData
+	img.hasAlpha = alphaData != nil
+}
+
+// DecodeArray returns the decode array of the image, [Dmin Dmax ...] for each color component,
+// mapping its samples to the ranges of the components of its colorspace, or nil if the image has
+// the default decode array of its colorspace.
+func (img *Image) DecodeArray() []float64 {
+	return img.decode
+}
+
+// SetDecodeArray sets the decode array of the image to `decode`, written to the Decode entry of
+// its image XObject, e.g. [1 0] for the inverted grayscale images. nil `decode` restores the default
+// decode array of the colorspace.
+func (img *Image) SetDecodeArray(decode []float64) {
+	img.decode = decode
+}
+
+// applyDecode returns image `img`, whose colorspace components range from 0 to 1, with its samples
+// mapped by its decode array, if set, and without decode array.
+func applyDecode(img Image) Image {
+	n := img.ColorComponents
+	if len(img.decode) != 2*n {
+		img.decode = nil
+		return img
+	}
+	isDefault := true
+	for i := 0; i < n; i++ {
+		if img.decode[2*i] != 0 || img.decode[2*i+1] != 1 {
+			isDefault = false
+		}
+	}
+	if isDefault {
+		img.decode = nil
+		return img
+	}
+
+	maxVal := uint32(1)<<uint32(img.BitsPerComponent) - 1
+	samples := img.GetSamples()
+	for i, v := range samples {
+		samples[i] = img.decodeSample(v, i%n, maxVal)
+	}
+	img.SetSamples(samples)
+	img.decode = nil
+	return img
+}
+
 // ConvertToBinary converts current image into binary (bi-level) format.
 // Binary images are composed of single bits per pixel (only black or white).
 // If provided image has more color components, then it would be converted into binary image using
@@ -91,6 +179,8 @@ func (img *Image) ConvertToBinary() error {
 	img.BitsPerComponent = 1
 	img.ColorComponents = 1
 	img.Data = unpaddedData
+	// The decode array is applied by ToGoImage.
+	img.decode = nil
 	return nil
 }
 
@@ -111,9 +201,20 @@ func (img *Image) GetParamsDict() *core.PdfObjectDictionary {
 // be avoided, when possible. It is recommended to access the Data field of the
 // image directly or use the ColorAt method to extract individual pixels.
 func (img *Image) GetSamples() []uint32 {
-	samples := sampling.ResampleBytes(img.Data, int(img.BitsPerComponent))
-
 	expectedLen := int(img.Width) * int(img.Height) * img.ColorComponents
+	rowSamples := int(img.Width) * img.ColorComponents
+	rowSize := (rowSamples*int(img.BitsPerComponent) + 7) / 8
+	if rowSize*8 != rowSamples*int(img.BitsPerComponent) && len(img.Data) >= rowSize*int(img.Height) {
+		// The rows are padded to a byte boundary: the padding bits are skipped.
+		samples := make([]uint32, 0, expectedLen)
+		for y := 0; y < int(img.Height); y++ {
+			row := sampling.ResampleBytes(img.Data[y*rowSize:(y+1)*rowSize], int(img.BitsPerComponent))
+			samples = append(samples, row[:rowSamples]...)
+		}
+		return samples
+	}
+
+	samples := sampling.ResampleBytes(img.Data, int(img.BitsPerComponent))
 	if len(samples) < expectedLen {
 		// Return error, or fill with 0s?
 		common.Log.Debug("Error: Too few samples (got %d, expecting %d)", len(samples), expectedLen)
@@ -155,33 +256,36 @@ func (img *Image) ColorAt(x, y int) (gocolor.Color, error) {
 
 			// Calculate index of byte containing the gray value
 			// in the image data, based on the specified x,y coordinates.
-			idx := (y*int(img.Width) + x) / divider
+			// The rows are padded to a byte boundary, unless the data is
+			// too short for it.
+			offset := y*int(img.Width) + x
+			if rowSize := (int(img.Width) + divider - 1) / divider; lenData >= rowSize*int(img.Height) {
+				offset = y*rowSize*divider + x
+			}
+			idx := offset / divider
 			if idx >= lenData {
 				return nil, fmt.Errorf("image coordinates out of range (%d, %d)", x, y)
 			}
 
 			// Calculate bit position at which the color data starts.
-			pos := 8 - uint(((y*int(img.Width)+x)%divider)*bpc+bpc)
+			pos := 8 - uint((offset%divider)*bpc+bpc)
 
 			// Extract gray color value starting at the calculated position.
-			val := float64(((1 << uint(img.BitsPerComponent)) - 1) & (data[idx] >> pos))
-			if len(img.decode) == 2 {
-				dMin, dMax := img.decode[0], img.decode[1]
-				val = interpolate(float64(val), 0, float64(maxVal), dMin, dMax)
-			}
+			val := img.decodeSample(uint32(data[idx]>>pos)&maxVal, 0, maxVal)
 
 			return gocolor.Gray{
-				Y: uint8(uint32(val) * 255 / maxVal & 0xff),
+				Y: uint8(val * 255 / maxVal & 0xff),
 			}, nil
 		case 16:
-			// 16 bit grayscale image.
+			// 16 bit grayscale image, with big-endian samples.
 			idx := (y*int(img.Width) + x) * 2
 			if idx+1 >= lenData {
 				return nil, fmt.Errorf("image coordinates out of range (%d, %d)", x, y)
 			}
 
+			val := img.decodeSample(uint32(data[idx])<<8|uint32(data[idx+1]), 0, maxVal)
 			return gocolor.Gray16{
-				Y: uint16(data[idx])<<8 | uint16(data[idx+1]),
+				Y: uint16(val),
 			}, nil
 		default:
 			// Assuming 8 bit grayscale image.
@@ -189,14 +293,10 @@ func (img *Image) ColorAt(x, y int) (gocolor.Color, error) {
 			if idx >= lenData {
 				return nil, fmt.Errorf("image coordinates out of range (%d, %d)", x, y)
 			}
-			val := float64(data[idx])
-			if len(img.decode) == 2 {
-				dMin, dMax := img.decode[0], img.decode[1]
-				val = interpolate(float64(val), 0, float64(maxVal), dMin, dMax)
-			}
+			val := img.decodeSample(uint32(data[idx]), 0, maxVal)
 
 			return gocolor.Gray{
-				Y: uint8(uint32(val) * 255 / maxVal & 0xff),
+				Y: uint8(val * 255 / maxVal & 0xff),
 			}, nil
 		}
 	case 3:
@@ -228,13 +328,13 @@ func (img *Image) ColorAt(x, y int) (gocolor.Color, error) {
 			}
 
 			return gocolor.RGBA{
-				R: uint8(uint32(r) * 255 / maxVal & 0xff),
-				G: uint8(uint32(g) * 255 / maxVal & 0xff),
-				B: uint8(uint32(b) * 255 / maxVal & 0xff),
+				R: uint8(img.decodeSample(uint32(r), 0, maxVal) * 255 / maxVal & 0xff),
+				G: uint8(img.decodeSample(uint32(g), 1, maxVal) * 255 / maxVal & 0xff),
+				B: uint8(img.decodeSample(uint32(b), 2, maxVal) * 255 / maxVal & 0xff),
 				A: uint8(0xff),
 			}, nil
 		case 16:
-			// 16 bit per component RGB image.
+			// 16 bit per component RGB image, with big-endian samples.
 			idx := (y*int(img.Width) + x) * 2
 
 			i := idx * 3
@@ -242,16 +342,27 @@ func (img *Image) ColorAt(x, y int) (gocolor.Color, error) {
 				return nil, fmt.Errorf("image coordinates out of range (%d, %d)", x, y)
 			}
 
-			a := uint16(0xffff)
+			var rgb [3]uint16
+			for k := range rgb {
+				val := uint32(data[i+2*k])<<8 | uint32(data[i+2*k+1])
+				rgb[k] = uint16(img.decodeSample(val, k, maxVal))
+			}
+
 			if img.alphaData != nil && len(img.alphaData) > idx+1 {
-				a = uint16(img.alphaData[idx])<<8 | uint16(img.alphaData[idx+1])
+				// The colors are not premultiplied by the alpha channel.
+				return gocolor.NRGBA64{
+					R: rgb[0],
+					G: rgb[1],
+					B: rgb[2],
+					A: uint16(img.alphaData[idx])<<8 | uint16(img.alphaData[idx+1]),
+				}, nil
 			}
 
 			return gocolor.RGBA64{
-				R: uint16(data[i])<<8 | uint16(data[i+1]),
-				G: uint16(data[i+2])<<8 | uint16(data[i+3]),
-				B: uint16(data[i+4])<<8 | uint16(data[i+5]),
-				A: a,
+				R: rgb[0],
+				G: rgb[1],
+				B: rgb[2],
+				A: 0xffff,
 			}, nil
 		default:
 			// Assuming 8 bit per component RGB image.
@@ -268,14 +379,23 @@ func (img *Image) ColorAt(x, y int) (gocolor.Color, error) {
 			}
 
 			return gocolor.RGBA{
-				R: uint8(data[i] & 0xff),
-				G: uint8(data[i+1] & 0xff),
-				B: uint8(data[i+2] & 0xff),
+				R: uint8(img.decodeSample(uint32(data[i]), 0, maxVal)),
+				G: uint8(img.decodeSample(uint32(data[i+1]), 1, maxVal)),
+				B: uint8(img.decodeSample(uint32(data[i+2]), 2, maxVal)),
 				A: a,
 			}, nil
 		}
 	case 4:
 		// CMYK image.
+		if img.BitsPerComponent == 16 {
+			// The colors of the 16 bit CMYK images are reduced to 8 bits.
+			idx := 8 * (y*int(img.Width) + x)
+			if idx+7 >= lenData {
+				return nil, fmt.Errorf("image coordinates out of range (%d, %d)", x, y)
+			}
+			return gocolor.CMYK{C: data[idx], M: data[idx+2], Y: data[idx+4], K: data[idx+6]}, nil
+		}
+
 		idx := 4 * (y*int(img.Width) + x)
 		if idx+3 >= lenData {
 			return nil, fmt.Errorf("image coordinates out of range (%d, %d)", x, y)
@@ -293,6 +413,17 @@ func (img *Image) ColorAt(x, y int) (gocolor.Color, error) {
 	return nil, errors.New("unsupported image colorspace")
 }
 
+// decodeSample maps the value `val` of color component `i` of a sample with the decode array of
+// the image, if set, returning a value in the range of the samples, from 0 to `maxVal`.
+func (img *Image) decodeSample(val uint32, i int, maxVal uint32) uint32 {
+	if len(img.decode) < 2*i+2 {
+		return val
+	}
+	decoded := interpolate(float64(val), 0, float64(maxVal), img.decode[2*i], img.decode[2*i+1])
+	decoded = math.Min(math.Max(decoded, 0), 1)
+	return uint32(decoded*float64(maxVal) + 0.5)
+}
+
 // Resample resamples the image data converting from current BitsPerComponent to a target BitsPerComponent
 // value.  Sets the image's BitsPerComponent to the target value following resampling.
 //
@@ -371,7 +502,9 @@ func (img *Image) ToGoImage() (goimage.Image, error) {
 			imgout = goimage.NewGray(bounds)
 		}
 	case 3:
-		if img.BitsPerComponent == 16 {
+		if img.BitsPerComponent == 16 && img.hasAlpha {
+			imgout = goimage.NewNRGBA64(bounds)
+		} else if img.BitsPerComponent == 16 {
 			imgout = goimage.NewRGBA64(bounds)
 		} else {
 			imgout = goimage.NewRGBA(bounds)
@@ -422,6 +555,11 @@ type DefaultImageHandler struct{}
 
 // NewImageFromGoImage creates a new RGBA unidoc Image from a golang Image.
 // If `goimg` is grayscale (*goimage.Gray) then calls NewGrayImageFromGoImage instead.
+// The 16 bit per component images (*goimage.Gray16, *goimage.RGBA64 and *goimage.NRGBA64) keep
+// their 16 bits per component, and their alpha channel is 16 bit too.
+// The alpha channel of `goimg` is kept unless it is fully opaque, and the colors of alpha
+// premultiplied images, such as *goimage.RGBA, are converted to non-premultiplied colors, which
+// are the colors expected with the alpha channel stored as a soft mask (SMask).
 func (ih DefaultImageHandler) NewImageFromGoImage(goimg goimage.Image) (*Image, error) {
 	b := goimg.Bounds()
 
@@ -429,11 +567,14 @@ func (ih DefaultImageHandler) NewImageFromGoImage(goimg goimage.Image) (*Image,
 	switch t := goimg.(type) {
 	case *goimage.Gray, *goimage.Gray16:
 		return ih.NewGrayImageFromGoImage(goimg)
+	case *goimage.RGBA64, *goimage.NRGBA64:
+		return newImageFromGoImage16(goimg), nil
 	case *goimage.NRGBA:
 		m = t
 	default:
 		// Speed up jpeg encoding by converting to NRGBA first.
 		// Will not be required once the golang image/jpeg package is optimized.
+		// The conversion also un-premultiplies the colors by alpha.
 		m = goimage.NewNRGBA(goimage.Rect(0, 0, b.Dx(), b.Dy()))
 		draw.Draw(m, m.Bounds(), goimg, b.Min, draw.Src)
 		b = m.Bounds()
@@ -479,10 +620,67 @@ func (ih DefaultImageHandler) NewImageFromGoImage(goimg goimage.Image) (*Image,
 	return &imag, nil
 }
 
+// newImageFromGoImage16 creates a new 16 bit per component RGB unidoc Image, with a 16 bit alpha
+// channel unless it is fully opaque, from the 16 bit per component golang Image `goimg`.
+func newImageFromGoImage16(goimg goimage.Image) *Image {
+	b := goimg.Bounds()
+	m, ok := goimg.(*goimage.NRGBA64)
+	if !ok {
+		// The conversion also un-premultiplies the colors by alpha.
+		m = goimage.NewNRGBA64(goimage.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(m, m.Bounds(), goimg, b.Min, draw.Src)
+		b = m.Bounds()
+	}
+
+	numPixels := b.Dx() * b.Dy()
+	data := make([]byte, 0, 6*numPixels)
+	alphaData := make([]byte, 0, 2*numPixels)
+	hasAlpha := false
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := m.Pix[m.PixOffset(b.Min.X, y):m.PixOffset(b.Max.X, y)]
+		for i := 0; i < len(row); i += 8 {
+			data = append(data, row[i:i+6]...)
+			alphaData = append(alphaData, row[i+6:i+8]...)
+			if row[i+6] != 0xff || row[i+7] != 0xff {
+				hasAlpha = true
+			}
+		}
+	}
+
+	img := &Image{
+		Width:            int64(b.Dx()),
+		Height:           int64(b.Dy()),
+		BitsPerComponent: 16,
+		ColorComponents:  3,
+		Data:             data,
+	}
+	if hasAlpha {
+		img.SetAlphaData(alphaData)
+	}
+	return img
+}
+
 // NewGrayImageFromGoImage creates a new grayscale unidoc Image from a golang Image.
+// The image has 16 bits per component if `goimg` is a *goimage.Gray16, and 8 bits otherwise.
 func (ih DefaultImageHandler) NewGrayImageFromGoImage(goimg goimage.Image) (*Image, error) {
 	b := goimg.Bounds()
 
+	if g16, ok := goimg.(*goimage.Gray16); ok {
+		// The samples are stored big-endian, as in PDF images.
+		data := make([]byte, 0, 2*b.Dx()*b.Dy())
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			i := g16.PixOffset(b.Min.X, y)
+			data = append(data, g16.Pix[i:i+2*b.Dx()]...)
+		}
+		return &Image{
+			Width:            int64(b.Dx()),
+			Height:           int64(b.Dy()),
+			BitsPerComponent: 16,
+			ColorComponents:  1,
+			Data:             data,
+		}, nil
+	}
+
 	var m *goimage.Gray
 	switch t := goimg.(type) {
 	case *goimage.Gray:
@@ -0,0 +1,237 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"errors"
+	goimage "image"
+	gocolor "image/color"
+	"image/draw"
+	"sort"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// ErrTooManyColors is returned when indexing an image with more colors than an Indexed colorspace
+// can hold.
+var ErrTooManyColors = errors.New("image has more than 256 colors")
+
+// NewIndexedImageFromGoImage creates a new indexed unidoc Image from a golang Image, along with
+// its Indexed colorspace, whose base colorspace is DeviceRGB. The palette of *goimage.Paletted
+// images is used as is. The other images are indexed if they have at most 256 colors or, if
+// `quantize` is true, after reducing their colors to 256 with the median cut algorithm; otherwise
+// ErrTooManyColors is returned.
+// The image samples have 1, 2, 4 or 8 bits per component, depending on the number of colors, and
+// each row is padded to a byte boundary. The alpha channel of `goimg` is kept as 8 bit alpha data
+// unless it is fully opaque.
+func NewIndexedImageFromGoImage(goimg goimage.Image, quantize bool) (*Image, *PdfColorspaceSpecialIndexed, error) {
+	b := goimg.Bounds()
+	width, height := b.Dx(), b.Dy()
+
+	var indices []byte
+	var palette [][3]byte
+	alphaData := make([]byte, width*height)
+	hasAlpha := false
+
+	if p, ok := goimg.(*goimage.Paletted); ok && len(p.Palette) > 0 && len(p.Palette) <= 256 {
+		colors := make([]gocolor.NRGBA, len(p.Palette))
+		for i, c := range p.Palette {
+			colors[i] = gocolor.NRGBAModel.Convert(c).(gocolor.NRGBA)
+			palette = append(palette, [3]byte{colors[i].R, colors[i].G, colors[i].B})
+		}
+		indices = make([]byte, 0, width*height)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				index := p.ColorIndexAt(x, y)
+				if int(index) >= len(colors) {
+					index = 0
+				}
+				alpha := colors[index].A
+				if alpha != 255 {
+					hasAlpha = true
+				}
+				alphaData[len(indices)] = alpha
+				indices = append(indices, index)
+			}
+		}
+	} else {
+		m, ok := goimg.(*goimage.NRGBA)
+		if !ok || b.Min != (goimage.Point{}) {
+			// The conversion also un-premultiplies the colors by alpha.
+			m = goimage.NewNRGBA(goimage.Rect(0, 0, width, height))
+			draw.Draw(m, m.Bounds(), goimg, b.Min, draw.Src)
+		}
+
+		pixels := make([][3]byte, 0, width*height)
+		for y := 0; y < height; y++ {
+			row := m.Pix[y*m.Stride : y*m.Stride+4*width]
+			for i := 0; i < len(row); i += 4 {
+				if row[i+3] != 255 {
+					hasAlpha = true
+				}
+				alphaData[len(pixels)] = row[i+3]
+				pixels = append(pixels, [3]byte{row[i], row[i+1], row[i+2]})
+			}
+		}
+
+		var err error
+		palette, indices, err = indexColors(pixels, quantize)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	bpc := 8
+	switch n := len(palette); {
+	case n <= 2:
+		bpc = 1
+	case n <= 4:
+		bpc = 2
+	case n <= 16:
+		bpc = 4
+	}
+
+	// Pack the samples, padding each row to a byte boundary.
+	rowSize := (width*bpc + 7) / 8
+	data := make([]byte, rowSize*height)
+	for y := 0; y < height; y++ {
+		row := data[y*rowSize : (y+1)*rowSize]
+		for x, index := range indices[y*width : (y+1)*width] {
+			bit := x * bpc
+			row[bit/8] |= index << uint(8-bpc-bit%8)
+		}
+	}
+
+	lookup := make([]byte, 0, 3*len(palette))
+	for _, c := range palette {
+		lookup = append(lookup, c[:]...)
+	}
+	cs := NewPdfColorspaceSpecialIndexed()
+	cs.Base = NewPdfColorspaceDeviceRGB()
+	cs.HiVal = len(palette) - 1
+	cs.Lookup = core.MakeStringFromBytes(lookup)
+	cs.colorLookup = lookup
+
+	img := &Image{
+		Width:            int64(width),
+		Height:           int64(height),
+		BitsPerComponent: int64(bpc),
+		ColorComponents:  1,
+		Data:             data,
+	}
+	if hasAlpha {
+		img.SetAlphaData(alphaData)
+	}
+	return img, cs, nil
+}
+
+// indexColors returns the palette of the colors of `pixels` and the indices of the pixels in the
+// palette. The colors are reduced to 256 colors with the median cut algorithm if they are more
+// numerous and `quantize` is true.
+func indexColors(pixels [][3]byte, quantize bool) ([][3]byte, []byte, error) {
+	histogram := make(map[[3]byte]int)
+	for _, c := range pixels {
+		histogram[c]++
+	}
+	if len(histogram) > 256 && !quantize {
+		return nil, nil, ErrTooManyColors
+	}
+
+	colors := make([]histogramColor, 0, len(histogram))
+	for c, count := range histogram {
+		colors = append(colors, histogramColor{c: c, count: count})
+	}
+	// Sorted for a deterministic palette.
+	sort.Slice(colors, func(i, j int) bool {
+		ci, cj := colors[i].c, colors[j].c
+		return ci[0] < cj[0] || ci[0] == cj[0] && (ci[1] < cj[1] || ci[1] == cj[1] && ci[2] < cj[2])
+	})
+
+	var boxes [][]histogramColor
+	if len(colors) <= 256 {
+		for i := range colors {
+			boxes = append(boxes, colors[i:i+1])
+		}
+	} else {
+		boxes = medianCut(colors, 256)
+	}
+
+	palette := make([][3]byte, len(boxes))
+	colorIndex := make(map[[3]byte]byte, len(colors))
+	for i, box := range boxes {
+		var sum [3]int
+		var total int
+		for _, hc := range box {
+			for k := range sum {
+				sum[k] += int(hc.c[k]) * hc.count
+			}
+			total += hc.count
+			colorIndex[hc.c] = byte(i)
+		}
+		for k := range sum {
+			palette[i][k] = byte((sum[k] + total/2) / total)
+		}
+	}
+
+	indices := make([]byte, len(pixels))
+	for i, c := range pixels {
+		indices[i] = colorIndex[c]
+	}
+	return palette, indices, nil
+}
+
+// histogramColor is a color of an image and its number of pixels.
+type histogramColor struct {
+	c     [3]byte
+	count int
+}
+
+// medianCut splits `colors` in up to `n` boxes of similar colors, splitting repeatedly the box with
+// the widest range of a color component at the median pixel of this component.
+func medianCut(colors []histogramColor, n int) [][]histogramColor {
+	boxes := [][]histogramColor{colors}
+	for len(boxes) < n {
+		best, bestComponent, bestRange := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			for k := 0; k < 3; k++ {
+				lo, hi := box[0].c[k], box[0].c[k]
+				for _, hc := range box[1:] {
+					if hc.c[k] < lo {
+						lo = hc.c[k]
+					}
+					if hc.c[k] > hi {
+						hi = hc.c[k]
+					}
+				}
+				if int(hi-lo) > bestRange {
+					best, bestComponent, bestRange = i, k, int(hi-lo)
+				}
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		k := bestComponent
+		sort.SliceStable(box, func(i, j int) bool { return box[i].c[k] < box[j].c[k] })
+		var total int
+		for _, hc := range box {
+			total += hc.count
+		}
+		split, count := 1, box[0].count
+		for split < len(box)-1 && 2*count < total {
+			count += box[split].count
+			split++
+		}
+		boxes[best] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+	return boxes
+}
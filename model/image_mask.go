@@ -0,0 +1,157 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"errors"
+	goimage "image"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// NewImageMaskFromGoImage creates a new 1 bit stencil mask image from a golang Image, e.g. to be
+// used as a stamp painted with the fill color. The opaque dark pixels of `goimg`, whose luminance
+// is below 50%, are painted: they have sample value 0, and the other pixels sample value 1.
+// The rows of the image are padded to a byte boundary.
+func NewImageMaskFromGoImage(goimg goimage.Image) *Image {
+	b := goimg.Bounds()
+	width, height := b.Dx(), b.Dy()
+	rowSize := (width + 7) / 8
+	data := make([]byte, rowSize*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, bl, a := goimg.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			// The colors are premultiplied by alpha.
+			luminance := (299*r + 587*g + 114*bl) / 1000
+			painted := a >= 0x8000 && 2*luminance < a
+			if !painted {
+				data[y*rowSize+x/8] |= 1 << uint(7-x%8)
+			}
+		}
+	}
+	return &Image{
+		Width:            int64(width),
+		Height:           int64(height),
+		BitsPerComponent: 1,
+		ColorComponents:  1,
+		Data:             data,
+	}
+}
+
+// NewXObjectImageMaskFromImage creates a new stencil mask (ImageMask) XObject Image from the 1 bit
+// image `img`, e.g. created by NewImageMaskFromGoImage, whose samples with value 0 are painted with
+// the fill color. If `encoder` is nil, uses raw encoding (none).
+func NewXObjectImageMaskFromImage(img *Image, encoder core.StreamEncoder) (*XObjectImage, error) {
+	if img.BitsPerComponent != 1 || img.ColorComponents != 1 {
+		return nil, errors.New("stencil masks must have 1 bit per pixel")
+	}
+	if encoder == nil {
+		encoder = core.NewRawEncoder()
+	}
+	encoder.UpdateParams(img.GetParamsDict())
+	encoded, err := encoder.EncodeBytes(img.Data)
+	if err != nil {
+		common.Log.Debug("Error with encoding: %v", err)
+		return nil, err
+	}
+
+	xobj := NewXObjectImage()
+	width, height, bpc := img.Width, img.Height, int64(1)
+	xobj.Width = &width
+	xobj.Height = &height
+	xobj.BitsPerComponent = &bpc
+	xobj.ImageMask = core.MakeBool(true)
+	xobj.Filter = encoder
+	xobj.Stream = encoded
+	return xobj, nil
+}
+
+// IsImageMask returns true if the image is a stencil mask (ImageMask), painted with the fill color.
+func (ximg *XObjectImage) IsImageMask() bool {
+	isMask, _ := core.GetBoolVal(ximg.ImageMask)
+	return isMask
+}
+
+// GetColorKeyMask returns the color key mask of the image, the ranges [min1 max1 ... minN maxN] of
+// the sample values of its N color components, or nil if the image does not have a valid color
+// key mask. The pixels whose components are all within their range are masked out.
+func (ximg *XObjectImage) GetColorKeyMask() []uint32 {
+	array, ok := core.GetArray(ximg.Mask)
+	if !ok {
+		return nil
+	}
+	values, err := core.GetNumbersAsFloat(array.Elements())
+	if err != nil || ximg.ColorSpace == nil || len(values) != 2*ximg.ColorSpace.GetNumComponents() {
+		common.Log.Debug("ERROR: invalid color key mask %s", array)
+		return nil
+	}
+	ranges := make([]uint32, len(values))
+	for i, v := range values {
+		if v < 0 {
+			v = 0
+		}
+		ranges[i] = uint32(v)
+	}
+	return ranges
+}
+
+// ColorKeyMaskAlpha returns the alpha channel of image `img` with the color key mask `ranges`, as
+// returned by XObjectImage.GetColorKeyMask, with one byte per pixel: 0 for the pixels masked out
+// and 255 for the others.
+func (img *Image) ColorKeyMaskAlpha(ranges []uint32) ([]byte, error) {
+	n := img.ColorComponents
+	if n < 1 || len(ranges) != 2*n {
+		return nil, errors.New("color key mask and image components mismatch")
+	}
+	samples := img.GetSamples()
+	numPixels := int(img.Width * img.Height)
+	if len(samples) < n*numPixels {
+		return nil, errors.New("image data too short")
+	}
+
+	alpha := make([]byte, numPixels)
+	for i := range alpha {
+		alpha[i] = 0xff
+		masked := true
+		for k, v := range samples[n*i : n*(i+1)] {
+			if v < ranges[2*k] || v > ranges[2*k+1] {
+				masked = false
+				break
+			}
+		}
+		if masked {
+			alpha[i] = 0
+		}
+	}
+	return alpha, nil
+}
+
+// StencilMaskAlpha returns the alpha channel of the 1 bit stencil mask image `img`, with one byte
+// per pixel: 255 for the pixels painted and 0 for the others. The samples with value 0 are painted,
+// or those with value 1 if `inverted` is true, i.e. for the stencil masks with Decode array [1 0].
+// It is also the alpha channel of the images whose explicit mask (Mask) is stencil mask `img`.
+func (img *Image) StencilMaskAlpha(inverted bool) []byte {
+	samples := img.GetSamples()
+	alpha := make([]byte, int(img.Width*img.Height))
+	for i := range alpha {
+		if i < len(samples) && (samples[i] == 0) != inverted {
+			alpha[i] = 0xff
+		}
+	}
+	return alpha
+}
+
+// IsDecodeInverted returns true if decode array `decode` is [1 0], which inverts the samples of
+// the one component images, e.g. of the stencil masks.
+func IsDecodeInverted(decode core.PdfObject) bool {
+	array, ok := core.GetArray(decode)
+	if !ok || array.Len() != 2 {
+		return false
+	}
+	values, err := core.GetNumbersAsFloat(array.Elements())
+	return err == nil && values[0] > values[1]
+}
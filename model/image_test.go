@@ -9,9 +9,15 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
 )
 
 func TestImageResampling(t *testing.T) {
@@ -379,3 +385,173 @@ func BenchmarkImageCopyingDirect(b *testing.B) {
 		}
 	}
 }
+
+// TestCMYKJPEGToRGB tests converting the CMYK JPEG images to RGB, with and without the Adobe marker
+// and the YCCK color transform, comparing the results with a reference image.
+func TestCMYKJPEGToRGB(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "cmyk_rgb.png"))
+	require.NoError(t, err)
+	defer f.Close()
+	ref, err := png.Decode(f)
+	require.NoError(t, err)
+
+	testcases := []struct {
+		name   string
+		decode []float64
+		invert bool // Inverted colors expected.
+	}{
+		// The samples of the images with the Adobe marker are inverted.
+		{name: "cmyk_adobe.jpg"},
+		{name: "ycck_adobe.jpg"},
+		{name: "cmyk.jpg"},
+		// Decode arrays take precedence.
+		{name: "cmyk_adobe.jpg", decode: []float64{1, 0, 1, 0, 1, 0, 1, 0}},
+		{name: "cmyk_adobe.jpg", decode: []float64{0, 1, 0, 1, 0, 1, 0, 1}, invert: true},
+		{name: "cmyk.jpg", decode: []float64{1, 0, 1, 0, 1, 0, 1, 0}, invert: true},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := ioutil.ReadFile(filepath.Join("testdata", tc.name))
+			require.NoError(t, err)
+
+			dict := core.MakeDict()
+			dict.Set("Type", core.MakeName("XObject"))
+			dict.Set("Subtype", core.MakeName("Image"))
+			dict.Set("Filter", core.MakeName("DCTDecode"))
+			dict.Set("Width", core.MakeInteger(24))
+			dict.Set("Height", core.MakeInteger(16))
+			dict.Set("BitsPerComponent", core.MakeInteger(8))
+			dict.Set("ColorSpace", core.MakeName("DeviceCMYK"))
+			if tc.decode != nil {
+				dict.Set("Decode", core.MakeArrayFromFloats(tc.decode))
+			}
+			stream := &core.PdfObjectStream{PdfObjectDictionary: dict, Stream: data}
+
+			ximg, err := NewXObjectImageFromStream(stream)
+			require.NoError(t, err)
+			img, err := ximg.ToImage()
+			require.NoError(t, err)
+			rgbImg, err := ximg.ColorSpace.ImageToRGB(*img)
+			require.NoError(t, err)
+			require.Equal(t, 3, rgbImg.ColorComponents)
+
+			// The inverted colors are not the inverse RGB colors, the inverted white and black
+			// colors both being black, only those are compared then.
+			bounds := ref.Bounds()
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				for x := bounds.Min.X; x < bounds.Max.X; x++ {
+					r, g, b, _ := ref.At(x, y).RGBA()
+					expected := []int{int(r >> 8), int(g >> 8), int(b >> 8)}
+					i := 3 * (y*bounds.Dx() + x)
+					got := rgbImg.Data[i : i+3]
+					if tc.invert {
+						if (expected[0]+expected[1]+expected[2])%765 != 0 {
+							continue
+						}
+						expected = []int{0, 0, 0}
+					}
+					for c := range expected {
+						d := expected[c] - int(got[c])
+						require.True(t, d >= -8 && d <= 8, "(%d,%d): got %v expected %v", x, y, got, expected)
+					}
+				}
+			}
+
+			// The stream is written unchanged.
+			outStream, ok := ximg.ToPdfObject().(*core.PdfObjectStream)
+			require.True(t, ok)
+			require.Equal(t, data, outStream.Stream)
+			if tc.decode == nil {
+				require.Nil(t, outStream.Get("Decode"))
+			}
+		})
+	}
+}
+
+// TestImage16Bit tests that the 16 bit per component images are written with Flate encoding and
+// read back with all their bits, and that they cannot be DCT encoded.
+func TestImage16Bit(t *testing.T) {
+	roundTrip := func(img *Image, encoder core.StreamEncoder) (*XObjectImage, *Image) {
+		ximg, err := NewXObjectImageFromImage(img, nil, encoder)
+		require.NoError(t, err)
+		stream, ok := ximg.ToPdfObject().(*core.PdfObjectStream)
+		require.True(t, ok)
+		ximg, err = NewXObjectImageFromStream(stream)
+		require.NoError(t, err)
+		decoded, err := ximg.ToImage()
+		require.NoError(t, err)
+		return ximg, decoded
+	}
+
+	// Gray gradient, with all the 16 bits varying.
+	gray := image.NewGray16(image.Rect(0, 0, 300, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 300; x++ {
+			gray.SetGray16(x, y, color.Gray16{Y: uint16(x*218 + y*7)})
+		}
+	}
+	img, err := ImageHandling.NewImageFromGoImage(gray)
+	require.NoError(t, err)
+	require.Equal(t, int64(16), img.BitsPerComponent)
+	require.Equal(t, 1, img.ColorComponents)
+
+	encoder := core.NewFlateEncoder()
+	encoder.Predictor = 15
+	ximg, decoded := roundTrip(img, encoder)
+	require.Equal(t, int64(16), *ximg.BitsPerComponent)
+	goimg, err := decoded.ToGoImage()
+	require.NoError(t, err)
+	require.IsType(t, &image.Gray16{}, goimg)
+	require.Equal(t, gray.Pix, goimg.(*image.Gray16).Pix)
+
+	// Converted to RGB for extraction, with 16 bits per component.
+	rgbImg, err := ximg.ColorSpace.ImageToRGB(*decoded)
+	require.NoError(t, err)
+	require.Equal(t, int64(16), rgbImg.BitsPerComponent)
+	goimg, err = rgbImg.ToGoImage()
+	require.NoError(t, err)
+	require.IsType(t, &image.RGBA64{}, goimg)
+	for x := 0; x < 300; x++ {
+		r, g, b, _ := goimg.At(x, 3).RGBA()
+		expected := uint32(x*218 + 21)
+		require.Equal(t, []uint32{expected, expected, expected}, []uint32{r, g, b})
+	}
+
+	// The decode array is applied to the 16 bit samples.
+	decoded.decode = []float64{1, 0}
+	c, err := decoded.ColorAt(10, 0)
+	require.NoError(t, err)
+	require.Equal(t, color.Gray16{Y: 0xffff - 2180}, c)
+
+	// RGB gradient with alpha.
+	rgba := image.NewNRGBA64(image.Rect(0, 0, 200, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 200; x++ {
+			rgba.SetNRGBA64(x, y, color.NRGBA64{R: uint16(x * 327), G: uint16(65535 - x*311), B: uint16(y*20000 + x),
+				A: uint16(x*300 + 1)})
+		}
+	}
+	img, err = ImageHandling.NewImageFromGoImage(rgba)
+	require.NoError(t, err)
+	require.Equal(t, int64(16), img.BitsPerComponent)
+	require.Equal(t, 3, img.ColorComponents)
+	require.Len(t, img.AlphaData(), 2*200*3)
+
+	ximg, decoded = roundTrip(img, core.NewFlateEncoder())
+	smask, ok := core.GetStream(ximg.SMask)
+	require.True(t, ok)
+	require.Equal(t, "16", smask.Get("BitsPerComponent").String())
+	alphaData, err := core.DecodeStream(smask)
+	require.NoError(t, err)
+	require.Equal(t, img.AlphaData(), alphaData)
+
+	decoded.SetAlphaData(alphaData)
+	goimg, err = decoded.ToGoImage()
+	require.NoError(t, err)
+	require.IsType(t, &image.NRGBA64{}, goimg)
+	require.Equal(t, rgba.Pix, goimg.(*image.NRGBA64).Pix)
+
+	// The 16 bit images cannot be DCT encoded.
+	_, err = NewXObjectImageFromImage(img, nil, core.NewDCTEncoder())
+	require.Equal(t, core.ErrDCTUnsupportedBitsPerComponent, err)
+}
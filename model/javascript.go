@@ -0,0 +1,482 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"errors"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// ScriptLocation specifies where a JavaScript action is stored in a document.
+type ScriptLocation string
+
+// Script locations.
+const (
+	ScriptLocationNames      ScriptLocation = "Names"      // Document-level JavaScript name tree
+	ScriptLocationOpenAction ScriptLocation = "OpenAction" // Action performed when opening the document
+	ScriptLocationDocumentAA ScriptLocation = "DocumentAA" // Additional actions of the document catalog
+	ScriptLocationPageAA     ScriptLocation = "PageAA"     // Additional actions of a page
+	ScriptLocationAnnotation ScriptLocation = "Annotation" // Actions of annotations, other than widgets
+	ScriptLocationField      ScriptLocation = "Field"      // Actions of form fields and their widgets
+)
+
+// Script represents a JavaScript action of a document, as returned by
+// PdfReader.GetScripts.
+type Script struct {
+	// Location specifies where the action is stored.
+	Location ScriptLocation
+
+	// Name is the name of document-level scripts (ScriptLocationNames).
+	Name string
+
+	// Page is the number of the page containing the action (starting from 1),
+	// or 0 if the action is not stored in a page, its annotations or the
+	// widgets of a field.
+	Page int
+
+	// Field is the fully qualified name of the form field containing the
+	// action (ScriptLocationField).
+	Field string
+
+	// Trigger is the additional actions entry triggering the action, such as
+	// "WC" (will close) for documents, "O" (open) for pages or "K" (keystroke)
+	// for fields. Empty for the actions which are not additional actions.
+	Trigger string
+
+	// Source is the JavaScript source code.
+	Source string
+
+	reader    *PdfReader
+	action    *core.PdfObjectDictionary // JavaScript action dictionary.
+	head      *core.PdfObjectDictionary // Dictionary containing the action chain.
+	key       core.PdfObjectName        // Entry of the action chain in `head`.
+	container core.PdfObject            // Object to update when the action changes.
+}
+
+// GetScripts returns the JavaScript actions of the document: the
+// document-level scripts of the JavaScript name tree, the open action and the
+// additional actions of the document, the page additional actions, and the
+// actions and additional actions of the annotations and form fields. The
+// actions chained by the Next entries of actions are included as well.
+func (r *PdfReader) GetScripts() ([]*Script, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return nil, err
+	}
+	c := &scriptCollector{r: r, visited: map[*core.PdfObjectDictionary]struct{}{}}
+
+	if names, ok := core.GetDict(r.catalog.Get("Names")); ok {
+		for _, entry := range getNameTreeEntries(names.Get("JavaScript")) {
+			action, ok := core.GetDict(entry.value)
+			if !ok {
+				common.Log.Debug("ERROR: invalid JavaScript name tree value (%T)", entry.value)
+				continue
+			}
+			script := Script{
+				Location: ScriptLocationNames,
+				Name:     core.MakeString(entry.key).Decoded(),
+			}
+			c.collectAction(script, action, 0)
+		}
+	}
+
+	// The open action is either a destination array or an action.
+	if _, ok := core.GetDict(r.catalog.Get("OpenAction")); ok {
+		c.collect(Script{Location: ScriptLocationOpenAction}, r.catalog, "OpenAction", r.catalog)
+	}
+	c.collectAdditional(Script{Location: ScriptLocationDocumentAA}, r.catalog, r.catalog)
+
+	// Widget annotations are collected with their fields, using the page
+	// numbers found here.
+	widgetPages := map[*core.PdfObjectDictionary]int{}
+	for i, page := range r.PageList {
+		container := page.GetContainingPdfObject()
+		pageDict, ok := core.GetDict(container)
+		if !ok {
+			continue
+		}
+		c.collectAdditional(Script{Location: ScriptLocationPageAA, Page: i + 1}, pageDict, container)
+
+		annots, _ := core.GetArray(page.Annots)
+		if annots == nil {
+			continue
+		}
+		for _, obj := range annots.Elements() {
+			annot, ok := core.GetDict(obj)
+			if !ok {
+				continue
+			}
+			if subtype, _ := core.GetNameVal(annot.Get("Subtype")); subtype == "Widget" {
+				widgetPages[annot] = i + 1
+				continue
+			}
+			annotContainer := container
+			if ind, ok := core.GetIndirect(obj); ok {
+				annotContainer = ind
+			}
+			script := Script{Location: ScriptLocationAnnotation, Page: i + 1}
+			c.collect(script, annot, "A", annotContainer)
+			c.collectAdditional(script, annot, annotContainer)
+		}
+	}
+
+	if r.AcroForm != nil {
+		for _, field := range r.AcroForm.AllFields() {
+			name, err := field.FullName()
+			if err != nil {
+				common.Log.Debug("ERROR: invalid field name: %v", err)
+			}
+
+			container := field.GetContainingPdfObject()
+			fieldDict, ok := core.GetDict(container)
+			if !ok {
+				continue
+			}
+			script := Script{Location: ScriptLocationField, Page: widgetPages[fieldDict], Field: name}
+			c.collect(script, fieldDict, "A", container)
+			c.collectAdditional(script, fieldDict, container)
+
+			for _, widget := range field.Annotations {
+				container := widget.GetContainingPdfObject()
+				widgetDict, ok := core.GetDict(container)
+				if !ok || widgetDict == fieldDict {
+					continue
+				}
+				script.Page = widgetPages[widgetDict]
+				c.collect(script, widgetDict, "A", container)
+				c.collectAdditional(script, widgetDict, container)
+			}
+		}
+	}
+
+	return c.scripts, nil
+}
+
+// scriptCollector collects the JavaScript actions of a document.
+type scriptCollector struct {
+	r       *PdfReader
+	scripts []*Script
+	visited map[*core.PdfObjectDictionary]struct{}
+}
+
+// collectAdditional collects the JavaScript actions of the additional actions
+// (AA) dictionary of `dict`.
+func (c *scriptCollector) collectAdditional(script Script, dict *core.PdfObjectDictionary, container core.PdfObject) {
+	aa, ok := core.GetDict(dict.Get("AA"))
+	if !ok {
+		return
+	}
+	for _, trigger := range aa.Keys() {
+		script.Trigger = string(trigger)
+		c.collect(script, aa, trigger, container)
+	}
+}
+
+// collect collects the JavaScript actions of the action chain stored in the
+// `key` entry of `head`.
+func (c *scriptCollector) collect(script Script, head *core.PdfObjectDictionary, key core.PdfObjectName,
+	container core.PdfObject) {
+	action, ok := core.GetDict(head.Get(key))
+	if !ok {
+		return
+	}
+	script.head = head
+	script.key = key
+	script.container = container
+	c.collectAction(script, action, 0)
+}
+
+// collectAction collects `action` if it is a JavaScript action, followed by
+// the actions of its Next entry.
+func (c *scriptCollector) collectAction(script Script, action *core.PdfObjectDictionary, depth int) {
+	if depth > 32 {
+		common.Log.Debug("ERROR: action chain too deep")
+		return
+	}
+	if _, ok := c.visited[action]; ok {
+		return
+	}
+	c.visited[action] = struct{}{}
+
+	if s, _ := core.GetNameVal(action.Get("S")); s == string(ActionTypeJavaScript) {
+		source, err := getJavaScriptSource(action.Get("JS"))
+		if err != nil {
+			common.Log.Debug("ERROR: invalid JavaScript source: %v", err)
+		}
+		found := script
+		found.Source = source
+		found.reader = c.r
+		found.action = action
+		c.scripts = append(c.scripts, &found)
+	}
+
+	switch next := core.TraceToDirectObject(action.Get("Next")).(type) {
+	case *core.PdfObjectDictionary:
+		c.collectAction(script, next, depth+1)
+	case *core.PdfObjectArray:
+		for _, obj := range next.Elements() {
+			if action, ok := core.GetDict(obj); ok {
+				c.collectAction(script, action, depth+1)
+			}
+		}
+	}
+}
+
+// getJavaScriptSource returns the source code of the JS entry of a JavaScript
+// action, which is either a text string or a text stream.
+func getJavaScriptSource(obj core.PdfObject) (string, error) {
+	switch t := core.TraceToDirectObject(obj).(type) {
+	case *core.PdfObjectString:
+		return t.Decoded(), nil
+	case *core.PdfObjectStream:
+		data, err := core.DecodeStream(t)
+		if err != nil {
+			return "", err
+		}
+		return core.MakeString(string(data)).Decoded(), nil
+	case nil:
+		return "", nil
+	}
+	return "", ErrTypeCheck
+}
+
+// makeJavaScriptAction returns a JavaScript action executing `source`. The
+// source is encoded with PDFDocEncoding if it is ASCII and with UTF-16BE
+// otherwise.
+func makeJavaScriptAction(source string) core.PdfObject {
+	action := NewPdfActionJavaScript()
+	action.JS = makeInfoString(source)
+	return action.ToPdfObject()
+}
+
+// removeAction removes `target` from the action chain stored in the `key`
+// entry of `dict`, replacing it with the actions of its Next entry. Returns
+// true if the action was found.
+func removeAction(dict *core.PdfObjectDictionary, key core.PdfObjectName, target *core.PdfObjectDictionary,
+	depth int) bool {
+	if depth > 32 {
+		return false
+	}
+
+	switch t := core.TraceToDirectObject(dict.Get(key)).(type) {
+	case *core.PdfObjectDictionary:
+		if t != target {
+			return removeAction(t, "Next", target, depth+1)
+		}
+		if next := t.Get("Next"); next != nil {
+			dict.Set(key, next)
+		} else {
+			dict.Remove(key)
+		}
+		return true
+	case *core.PdfObjectArray:
+		var elements []core.PdfObject
+		found := false
+		for _, obj := range t.Elements() {
+			action, ok := core.GetDict(obj)
+			if !found && ok && action == target {
+				found = true
+				switch next := core.TraceToDirectObject(action.Get("Next")).(type) {
+				case *core.PdfObjectArray:
+					elements = append(elements, next.Elements()...)
+				case *core.PdfObjectDictionary:
+					elements = append(elements, action.Get("Next"))
+				}
+				continue
+			}
+			if !found && ok && removeAction(action, "Next", target, depth+1) {
+				found = true
+			}
+			elements = append(elements, obj)
+		}
+		if !found {
+			return false
+		}
+		if len(elements) == 0 {
+			dict.Remove(key)
+		} else {
+			t.Clear()
+			t.Append(elements...)
+		}
+		return true
+	}
+	return false
+}
+
+// javaScriptChanges contains the pending changes to the document-level
+// scripts (JavaScript name tree) of a document.
+type javaScriptChanges struct {
+	added   []nameTreeEntry
+	removed []string
+}
+
+// add adds a script with the specified name to the changes, replacing any
+// previously added script with the same name.
+func (c *javaScriptChanges) add(name, source string) {
+	entry := nameTreeEntry{key: nameTreeKey(name), value: makeJavaScriptAction(source)}
+	for i, e := range c.added {
+		if e.key == entry.key {
+			c.added[i] = entry
+			return
+		}
+	}
+	c.added = append(c.added, entry)
+}
+
+// remove marks the script with the specified name for removal.
+func (c *javaScriptChanges) remove(name string) {
+	key := nameTreeKey(name)
+	for i, e := range c.added {
+		if e.key == key {
+			c.added = append(c.added[:i], c.added[i+1:]...)
+			break
+		}
+	}
+	c.removed = append(c.removed, key)
+}
+
+// isEmpty returns true if there are no pending changes.
+func (c *javaScriptChanges) isEmpty() bool {
+	return len(c.added) == 0 && len(c.removed) == 0
+}
+
+// apply returns a copy of the specified catalog Names dictionary, containing
+// a JavaScript name tree updated with the pending changes. The name tree is
+// removed if it has no entries. The original dictionary is not modified.
+func (c *javaScriptChanges) apply(names core.PdfObject) *core.PdfIndirectObject {
+	removed := map[string]struct{}{}
+	for _, key := range c.removed {
+		removed[key] = struct{}{}
+	}
+	return updateNamesDict(names, "JavaScript", removed, c.added)
+}
+
+// setCatalogNames sets the Names entry of `catalog`, removing the entry if the
+// Names dictionary is empty. Returns true if the entry was set.
+func setCatalogNames(catalog *core.PdfObjectDictionary, names core.PdfObject) bool {
+	if dict, ok := core.GetDict(names); ok && len(dict.Keys()) == 0 {
+		catalog.Remove("Names")
+		return false
+	}
+	catalog.Set("Names", names)
+	return true
+}
+
+// AddJavaScript adds a document-level script to the JavaScript name tree of
+// the document, replacing any script with the same name. Document-level
+// scripts are executed when the document is opened.
+func (w *PdfWriter) AddJavaScript(name, source string) error {
+	if name == "" {
+		return errors.New("script name not specified")
+	}
+	w.javaScripts.add(name, source)
+	return nil
+}
+
+// RemoveJavaScript removes the document-level script with the specified name.
+// The JavaScript name tree is removed if it has no scripts left.
+func (w *PdfWriter) RemoveJavaScript(name string) {
+	w.javaScripts.remove(name)
+}
+
+// AddJavaScript adds a document-level script to the JavaScript name tree of
+// the document, replacing any script with the same name.
+func (a *PdfAppender) AddJavaScript(name, source string) error {
+	if name == "" {
+		return errors.New("script name not specified")
+	}
+	a.javaScripts.add(name, source)
+	return nil
+}
+
+// RemoveJavaScript removes the document-level script with the specified name.
+// The JavaScript name tree is removed if it has no scripts left.
+func (a *PdfAppender) RemoveJavaScript(name string) {
+	a.javaScripts.remove(name)
+}
+
+// ReplaceScript replaces the source code of `script`, which must be one of
+// the scripts returned by the GetScripts method of the appender Reader.
+// Document-level scripts are replaced by new actions with the same name.
+func (a *PdfAppender) ReplaceScript(script *Script, source string) error {
+	if err := a.checkScript(script); err != nil {
+		return err
+	}
+	if script.Location == ScriptLocationNames {
+		a.javaScripts.add(script.Name, source)
+		script.Source = source
+		return nil
+	}
+
+	script.action.Set("JS", makeInfoString(source))
+	script.Source = source
+	a.updateScriptContainer(script)
+	return nil
+}
+
+// RemoveScript removes `script`, which must be one of the scripts returned by
+// the GetScripts method of the appender Reader. The action is replaced by the
+// actions of its Next entry, if any. Document-level scripts are removed from
+// the JavaScript name tree by name.
+func (a *PdfAppender) RemoveScript(script *Script) error {
+	if err := a.checkScript(script); err != nil {
+		return err
+	}
+	if script.Location == ScriptLocationNames {
+		a.javaScripts.remove(script.Name)
+		return nil
+	}
+
+	if !removeAction(script.head, script.key, script.action, 0) {
+		return errors.New("script not found")
+	}
+	if script.Location == ScriptLocationPageAA && script.Page <= len(a.Reader.PageList) {
+		// Keep the page model consistent with its dictionary.
+		page := a.Reader.PageList[script.Page-1]
+		if aa, ok := core.GetDict(page.AA); ok && len(aa.Keys()) == 0 {
+			page.AA = nil
+			if pageDict, ok := core.GetDict(page.GetContainingPdfObject()); ok {
+				pageDict.Remove("AA")
+			}
+		}
+	}
+	a.updateScriptContainer(script)
+	return nil
+}
+
+// checkScript checks that `script` was returned by the GetScripts method of
+// the appender Reader.
+func (a *PdfAppender) checkScript(script *Script) error {
+	if script == nil || script.action == nil {
+		return errors.New("script not specified")
+	}
+	if script.reader != a.Reader {
+		return errors.New("script not from the appender reader")
+	}
+	if script.Location != ScriptLocationNames && script.head == nil {
+		return errors.New("script location unknown")
+	}
+	return nil
+}
+
+// updateScriptContainer marks the object containing `script` for update in
+// the new revision.
+func (a *PdfAppender) updateScriptContainer(script *Script) {
+	switch script.Location {
+	case ScriptLocationOpenAction, ScriptLocationDocumentAA:
+		if a.catalogUpdates == nil {
+			a.catalogUpdates = map[core.PdfObjectName]core.PdfObject{}
+		}
+		key := core.PdfObjectName("OpenAction")
+		if script.Location == ScriptLocationDocumentAA {
+			key = "AA"
+		}
+		a.catalogUpdates[key] = core.ResolveReference(a.Reader.catalog.Get(key))
+	default:
+		a.updateObjectsDeep(script.container, nil)
+	}
+}
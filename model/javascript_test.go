@@ -0,0 +1,258 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// makeTestJavaScriptAction returns a JavaScript action dictionary executing
+// `source`, followed by the `next` actions.
+func makeTestJavaScriptAction(source string, next ...core.PdfObject) *core.PdfObjectDictionary {
+	action := core.MakeDict()
+	action.Set("S", core.MakeName("JavaScript"))
+	action.Set("JS", core.MakeString(source))
+	switch len(next) {
+	case 0:
+	case 1:
+		action.Set("Next", next[0])
+	default:
+		action.Set("Next", core.MakeArray(next...))
+	}
+	return action
+}
+
+// writeJavaScriptFixture returns a reader of a document containing scripts
+// at every supported location.
+func writeJavaScriptFixture(t *testing.T) *PdfReader {
+	w := NewPdfWriter()
+	page := NewPdfPage()
+	page.MediaBox = &PdfRectangle{Urx: 100, Ury: 100}
+
+	// Page open action followed by a GoTo action and a second script.
+	goTo := core.MakeDict()
+	goTo.Set("S", core.MakeName("GoTo"))
+	goTo.Set("D", core.MakeArray(core.MakeInteger(0), core.MakeName("Fit")))
+	goTo.Set("Next", makeTestJavaScriptAction("pageOpen2();"))
+	aa := core.MakeDict()
+	aa.Set("O", core.MakeIndirectObject(makeTestJavaScriptAction("pageOpen1();", goTo)))
+	page.AA = aa
+
+	link := NewPdfAnnotationLink()
+	link.Rect = core.MakeArrayFromFloats([]float64{10, 60, 90, 70})
+	link.A = core.MakeIndirectObject(makeTestJavaScriptAction("link();"))
+	page.AddAnnotation(link.PdfAnnotation)
+
+	field := newTestTextField("amount")
+	fieldAA := core.MakeDict()
+	fieldAA.Set("K", makeTestJavaScriptAction("AFNumber_Keystroke(2);"))
+	field.AA = fieldAA
+	widget := NewPdfAnnotationWidget()
+	widget.Rect = core.MakeArrayFromFloats([]float64{10, 10, 90, 30})
+	widget.A = makeTestJavaScriptAction("widget();")
+	widget.parent = field
+	field.Annotations = append(field.Annotations, widget)
+	page.AddAnnotation(widget.PdfAnnotation)
+	form := NewPdfAcroForm()
+	*form.Fields = append(*form.Fields, field)
+
+	require.NoError(t, w.AddPage(page))
+	require.NoError(t, w.SetForms(form))
+	openAction := NewPdfActionJavaScript()
+	openAction.JS = core.MakeString("open();")
+	require.NoError(t, w.SetOpenAction(openAction.PdfAction))
+	require.NoError(t, w.AddJavaScript("init", "init();"))
+
+	reader := writeAndRead(t, &w)
+	return reader
+}
+
+// getScriptsBySource returns the scripts of the document, by source.
+func getScriptsBySource(t *testing.T, reader *PdfReader) map[string]*Script {
+	scripts, err := reader.GetScripts()
+	require.NoError(t, err)
+	bySource := map[string]*Script{}
+	for _, script := range scripts {
+		bySource[script.Source] = script
+	}
+	require.Len(t, bySource, len(scripts))
+	return bySource
+}
+
+// appendScriptChanges applies `fn` to an appender of `reader` and returns a
+// reader of the updated document.
+func appendScriptChanges(t *testing.T, reader *PdfReader, fn func(a *PdfAppender)) *PdfReader {
+	appender, err := NewPdfAppender(reader)
+	require.NoError(t, err)
+	fn(appender)
+	reader = writeAndRead(t, appender)
+	return reader
+}
+
+func TestJavaScriptNameTree(t *testing.T) {
+	sources := map[string]string{
+		"init":    "var s = \"(unbalanced\\\\\";\r\napp.alert(s);",
+		"привет":  "app.alert(\"Привет, мир\");",
+		"another": "another();",
+	}
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(NewPdfPage()))
+	for name, source := range sources {
+		require.NoError(t, w.AddJavaScript(name, source))
+	}
+	w.RemoveJavaScript("another")
+	require.Error(t, w.AddJavaScript("", "empty();"))
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	scripts, err := reader.GetScripts()
+	require.NoError(t, err)
+	require.Len(t, scripts, 2)
+	byName := map[string]*Script{}
+	for _, script := range scripts {
+		require.Equal(t, ScriptLocationNames, script.Location)
+		byName[script.Name] = script
+	}
+	require.Equal(t, sources["init"], byName["init"].Source)
+	require.Equal(t, sources["привет"], byName["привет"].Source)
+
+	// Non-ASCII names and sources are encoded with UTF-16BE.
+	js, ok := core.GetString(byName["привет"].action.Get("JS"))
+	require.True(t, ok)
+	require.True(t, bytes.HasPrefix(js.Bytes(), []byte{0xFE, 0xFF}))
+	js, ok = core.GetString(byName["init"].action.Get("JS"))
+	require.True(t, ok)
+	require.Equal(t, sources["init"], js.Str())
+
+	// Replace a script, then remove the scripts in separate revisions.
+	reader = appendScriptChanges(t, reader, func(a *PdfAppender) {
+		require.NoError(t, a.ReplaceScript(byName["init"], "replaced();"))
+		require.NoError(t, a.AddJavaScript("added", "added();"))
+	})
+	scripts, err = reader.GetScripts()
+	require.NoError(t, err)
+	require.Len(t, scripts, 3)
+	var names []string
+	for _, script := range scripts {
+		names = append(names, script.Name)
+		if script.Name == "init" {
+			require.Equal(t, "replaced();", script.Source)
+		}
+	}
+	require.ElementsMatch(t, []string{"init", "added", "привет"}, names)
+
+	reader = appendScriptChanges(t, reader, func(a *PdfAppender) {
+		scripts, err := a.Reader.GetScripts()
+		require.NoError(t, err)
+		for _, script := range scripts {
+			if script.Name != "added" {
+				require.NoError(t, a.RemoveScript(script))
+			}
+		}
+		a.RemoveJavaScript("added")
+	})
+	scripts, err = reader.GetScripts()
+	require.NoError(t, err)
+	require.Empty(t, scripts)
+
+	// The empty name tree and Names dictionary are removed.
+	require.Nil(t, reader.catalog.Get("Names"))
+}
+
+func TestJavaScriptLocations(t *testing.T) {
+	reader := writeJavaScriptFixture(t)
+	scripts := getScriptsBySource(t, reader)
+	require.Len(t, scripts, 7)
+
+	check := func(source string, location ScriptLocation, page int, field, trigger string) {
+		script := scripts[source]
+		require.NotNil(t, script, source)
+		require.Equal(t, location, script.Location, source)
+		require.Equal(t, page, script.Page, source)
+		require.Equal(t, field, script.Field, source)
+		require.Equal(t, trigger, script.Trigger, source)
+	}
+	check("init();", ScriptLocationNames, 0, "", "")
+	check("open();", ScriptLocationOpenAction, 0, "", "")
+	check("pageOpen1();", ScriptLocationPageAA, 1, "", "O")
+	check("pageOpen2();", ScriptLocationPageAA, 1, "", "O")
+	check("link();", ScriptLocationAnnotation, 1, "", "")
+	check("AFNumber_Keystroke(2);", ScriptLocationField, 0, "amount", "K")
+	check("widget();", ScriptLocationField, 1, "amount", "")
+	require.Equal(t, "init", scripts["init();"].Name)
+
+	// Scripts can only be changed by the appender of their reader.
+	other := writeJavaScriptFixture(t)
+	appender, err := NewPdfAppender(other)
+	require.NoError(t, err)
+	require.Error(t, appender.RemoveScript(scripts["open();"]))
+	require.Error(t, appender.ReplaceScript(nil, ""))
+
+	reader = appendScriptChanges(t, reader, func(a *PdfAppender) {
+		require.NoError(t, a.ReplaceScript(scripts["open();"], "app.alert(\"Ouvert à midi\");"))
+		require.NoError(t, a.ReplaceScript(scripts["link();"], "link2();"))
+		require.NoError(t, a.RemoveScript(scripts["pageOpen1();"]))
+		require.NoError(t, a.RemoveScript(scripts["pageOpen2();"]))
+		require.NoError(t, a.RemoveScript(scripts["AFNumber_Keystroke(2);"]))
+		require.NoError(t, a.RemoveScript(scripts["widget();"]))
+	})
+	scripts = getScriptsBySource(t, reader)
+	require.Len(t, scripts, 3)
+	check("init();", ScriptLocationNames, 0, "", "")
+	check("app.alert(\"Ouvert à midi\");", ScriptLocationOpenAction, 0, "", "")
+	check("link2();", ScriptLocationAnnotation, 1, "", "")
+
+	// The GoTo action chained by the removed page scripts is kept.
+	page := reader.PageList[0]
+	aa, ok := core.GetDict(page.AA)
+	require.True(t, ok)
+	action, ok := core.GetDict(aa.Get("O"))
+	require.True(t, ok)
+	s, _ := core.GetNameVal(action.Get("S"))
+	require.Equal(t, "GoTo", s)
+	require.Nil(t, action.Get("Next"))
+
+	// The form field and its widget are kept.
+	require.NotNil(t, reader.AcroForm)
+	fields := reader.AcroForm.AllFields()
+	require.Len(t, fields, 1)
+	name, err := fields[0].FullName()
+	require.NoError(t, err)
+	require.Equal(t, "amount", name)
+}
+
+func TestRemoveAction(t *testing.T) {
+	first := makeTestJavaScriptAction("first();")
+	second := makeTestJavaScriptAction("second();")
+	third := makeTestJavaScriptAction("third();")
+	head := core.MakeDict()
+	head.Set("A", makeTestJavaScriptAction("head();", first, core.MakeIndirectObject(second)))
+	second.Set("Next", third)
+
+	// Actions removed from arrays are replaced by their Next actions.
+	require.True(t, removeAction(head, "A", second, 0))
+	action, ok := core.GetDict(head.Get("A"))
+	require.True(t, ok)
+	next, ok := core.GetArray(action.Get("Next"))
+	require.True(t, ok)
+	require.Len(t, next.Elements(), 2)
+	require.Equal(t, third, next.Get(1))
+
+	require.True(t, removeAction(head, "A", first, 0))
+	require.True(t, removeAction(head, "A", third, 0))
+	require.Nil(t, action.Get("Next"))
+	require.False(t, removeAction(head, "A", third, 0))
+
+	require.True(t, removeAction(head, "A", action, 0))
+	require.Nil(t, head.Get("A"))
+}
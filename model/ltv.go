@@ -0,0 +1,323 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+
+	"github.com/unidoc/pkcs7"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model/sigutil"
+)
+
+// oidSignatureTimeStampToken is the object identifier of the signature
+// timestamp token unsigned attribute (id-aa-signatureTimeStampToken).
+var oidSignatureTimeStampToken = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}
+
+// LTV represents an LTV (Long-Term Validation) client. It is used to LTV
+// enable the signatures of a document, by adding their validation data
+// (certificate chains, OCSP responses and CRLs) to the document security
+// store (DSS) of the document. The DSS is written in the next revision of
+// the appender, which preserves the existing signatures. In order to reach
+// the PAdES B-LTA level, a document timestamp should be applied on the
+// resulting document.
+type LTV struct {
+	// CertClient is used for retrieving the issuers of the certificates,
+	// which are not provided by the signatures or the extra certificates.
+	// If nil, no certificates are retrieved.
+	CertClient *sigutil.CertClient
+
+	// OCSPClient is used for retrieving the OCSP responses of the
+	// certificates. If nil, no OCSP responses are retrieved.
+	OCSPClient *sigutil.OCSPClient
+
+	// CRLClient is used for retrieving the CRLs of the certificates.
+	// If nil, no CRLs are retrieved.
+	CRLClient *sigutil.CRLClient
+
+	// SkipExisting specifies whether signatures which already have a VRI
+	// entry in the DSS are skipped.
+	SkipExisting bool
+
+	appender *PdfAppender
+	dss      *DSS
+}
+
+// NewLTV returns a new LTV client for the document of the specified
+// appender. The DSS of the document, if any, is loaded and the validation
+// data added by the client is merged with it. The resulting DSS is written
+// in the next revision of the appender.
+func NewLTV(appender *PdfAppender) (*LTV, error) {
+	if appender == nil {
+		return nil, errors.New("appender must not be nil")
+	}
+
+	dss := appender.dss
+	if dss == nil {
+		var err error
+		if dss, err = appender.Reader.GetDSS(); err != nil {
+			return nil, err
+		}
+		if dss == nil {
+			dss = NewDSS()
+		}
+	}
+
+	appender.SetDSS(dss)
+
+	return &LTV{
+		CertClient: sigutil.NewCertClient(),
+		OCSPClient: sigutil.NewOCSPClient(),
+		CRLClient:  sigutil.NewCRLClient(),
+		appender:   appender,
+		dss:        dss,
+	}, nil
+}
+
+// DSS returns the document security store the validation data is added to.
+func (l *LTV) DSS() *DSS {
+	return l.dss
+}
+
+// EnableAll LTV enables all the signatures of the document. The extraCerts
+// parameter can contain certificates which are used for building the
+// certificate chains of the signatures (e.g. intermediate and root
+// certificates not included in the signatures).
+func (l *LTV) EnableAll(extraCerts []*x509.Certificate) error {
+	acroForm := l.appender.Reader.AcroForm
+	if acroForm == nil {
+		return nil
+	}
+
+	for _, field := range acroForm.AllFields() {
+		sigField, ok := field.GetContext().(*PdfFieldSignature)
+		if !ok || sigField.V == nil {
+			continue
+		}
+		if err := l.Enable(sigField.V, extraCerts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Enable LTV enables the specified signature, by adding the validation data
+// of its certificate chain to the DSS and referencing it in the VRI entry of
+// the signature. The extraCerts parameter can contain certificates which are
+// used for building the certificate chain of the signature.
+func (l *LTV) Enable(sig *PdfSignature, extraCerts []*x509.Certificate) error {
+	key, err := GetVRIKey(sig)
+	if err != nil {
+		return err
+	}
+	if _, ok := l.dss.VRI[key]; ok && l.SkipExisting {
+		return nil
+	}
+
+	certs, err := getSignatureCertificates(sig)
+	if err != nil {
+		return err
+	}
+	if len(certs) == 0 {
+		return errors.New("no signature certificates found")
+	}
+
+	chains := [][]*x509.Certificate{
+		l.buildChain(certs[0], append(certs[1:], extraCerts...)),
+	}
+
+	// Timestamp tokens embedded in the signature are validated using the
+	// TSA certificate chains, so include them as well.
+	for _, tsaCert := range getSignatureTimestampCertificates(sig) {
+		chains = append(chains, l.buildChain(tsaCert, extraCerts))
+	}
+
+	var certData, ocsps, crls [][]byte
+	for _, chain := range chains {
+		c, o, r := l.getValidationData(chain)
+		certData = append(certData, c...)
+		ocsps = append(ocsps, o...)
+		crls = append(crls, r...)
+	}
+
+	return l.dss.AddValidationData(sig, certData, ocsps, crls)
+}
+
+// EnableChain adds the validation data of the specified certificate chain
+// to the DSS, without associating it with a signature.
+func (l *LTV) EnableChain(chain []*x509.Certificate) error {
+	if len(chain) == 0 {
+		return nil
+	}
+
+	certData, ocsps, crls := l.getValidationData(l.buildChain(chain[0], chain[1:]))
+	if _, err := l.dss.AddCerts(certData); err != nil {
+		return err
+	}
+	if _, err := l.dss.AddOCSPs(ocsps); err != nil {
+		return err
+	}
+	_, err := l.dss.AddCRLs(crls)
+	return err
+}
+
+// AddValidationData adds externally obtained validation data for the
+// specified signature to the DSS. The OCSP responses and CRLs must be
+// DER encoded.
+func (l *LTV) AddValidationData(sig *PdfSignature, certs []*x509.Certificate, ocsps, crls [][]byte) error {
+	certData := make([][]byte, 0, len(certs))
+	for _, cert := range certs {
+		certData = append(certData, cert.Raw)
+	}
+
+	return l.dss.AddValidationData(sig, certData, ocsps, crls)
+}
+
+// buildChain returns the certificate chain of the specified certificate.
+// The issuers are looked up in the provided certificates and retrieved
+// using the certificate client, if not found.
+func (l *LTV) buildChain(cert *x509.Certificate, certs []*x509.Certificate) []*x509.Certificate {
+	chain := []*x509.Certificate{cert}
+	for len(chain) < 16 {
+		current := chain[len(chain)-1]
+		if bytes.Equal(current.RawIssuer, current.RawSubject) {
+			break
+		}
+
+		var issuer *x509.Certificate
+		for _, c := range certs {
+			if bytes.Equal(current.RawIssuer, c.RawSubject) && current.CheckSignatureFrom(c) == nil {
+				issuer = c
+				break
+			}
+		}
+		if issuer == nil && l.CertClient != nil {
+			var err error
+			if issuer, err = l.CertClient.GetIssuer(current); err != nil {
+				common.Log.Debug("WARN: could not retrieve certificate issuer: %v", err)
+			}
+		}
+		if issuer == nil {
+			break
+		}
+		chain = append(chain, issuer)
+	}
+
+	return chain
+}
+
+// getValidationData returns the DER encoded certificates, OCSP responses and
+// CRLs of the specified certificate chain. Revocation data which cannot be
+// retrieved is skipped.
+func (l *LTV) getValidationData(chain []*x509.Certificate) (certs, ocsps, crls [][]byte) {
+	for i, cert := range chain {
+		certs = append(certs, cert.Raw)
+		if i+1 >= len(chain) {
+			// Root certificates do not have revocation data.
+			break
+		}
+		issuer := chain[i+1]
+
+		if l.OCSPClient != nil && len(cert.OCSPServer) > 0 {
+			_, data, err := l.OCSPClient.MakeRequest("", cert, issuer)
+			if err == nil {
+				ocsps = append(ocsps, data)
+				continue
+			}
+			common.Log.Debug("WARN: could not retrieve OCSP response: %v", err)
+		}
+		if l.CRLClient != nil && len(cert.CRLDistributionPoints) > 0 {
+			data, err := l.CRLClient.MakeRequest("", cert)
+			if err == nil {
+				crls = append(crls, data)
+				continue
+			}
+			common.Log.Debug("WARN: could not retrieve CRL: %v", err)
+		}
+	}
+
+	return certs, ocsps, crls
+}
+
+// getSignatureCertificates returns the certificates of the specified
+// signature. The signing certificate is the first in the list.
+func getSignatureCertificates(sig *PdfSignature) ([]*x509.Certificate, error) {
+	if sig.SubFilter != nil && *sig.SubFilter == "adbe.x509.rsa_sha1" {
+		var certData []byte
+		switch certObj := core.TraceToDirectObject(sig.Cert).(type) {
+		case *core.PdfObjectString:
+			certData = certObj.Bytes()
+		case *core.PdfObjectArray:
+			for _, obj := range certObj.Elements() {
+				certStr, ok := core.GetString(obj)
+				if !ok {
+					return nil, fmt.Errorf("invalid certificate object type: %T", obj)
+				}
+				certData = append(certData, certStr.Bytes()...)
+			}
+		default:
+			return nil, fmt.Errorf("invalid signature certificate object type: %T", certObj)
+		}
+		return x509.ParseCertificates(certData)
+	}
+
+	if sig.Contents == nil {
+		return nil, errors.New("signature contents not set")
+	}
+	p7, err := pkcs7.Parse(sig.Contents.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	// Move the signing certificate to the front of the list.
+	certs := p7.Certificates
+	if signer := p7.GetOnlySigner(); signer != nil {
+		certs = []*x509.Certificate{signer}
+		for _, cert := range p7.Certificates {
+			if cert != signer {
+				certs = append(certs, cert)
+			}
+		}
+	}
+
+	return certs, nil
+}
+
+// getSignatureTimestampCertificates returns the signing certificates of the
+// timestamp tokens embedded in the specified signature, if any.
+func getSignatureTimestampCertificates(sig *PdfSignature) []*x509.Certificate {
+	if sig.Contents == nil {
+		return nil
+	}
+	p7, err := pkcs7.Parse(sig.Contents.Bytes())
+	if err != nil || len(p7.Signers) == 0 {
+		return nil
+	}
+
+	var certs []*x509.Certificate
+	for _, attr := range p7.Signers[0].UnauthenticatedAttributes {
+		if !attr.Type.Equal(oidSignatureTimeStampToken) {
+			continue
+		}
+		token, err := pkcs7.Parse(attr.Value.Bytes)
+		if err != nil {
+			common.Log.Debug("WARN: invalid signature timestamp token: %v", err)
+			continue
+		}
+		if signer := token.GetOnlySigner(); signer != nil {
+			certs = append(certs, signer)
+		}
+	}
+
+	return certs
+}
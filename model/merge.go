@@ -0,0 +1,460 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// MergeOptions contains options for merging documents.
+type MergeOptions struct {
+	// OutlineTitles contains the titles of the outline items grouping the
+	// outline items of each document. If set, it must contain a title for
+	// each merged document. Otherwise, the outlines of the documents are
+	// concatenated.
+	OutlineTitles []string
+}
+
+// pdfMerger holds the state of the documents being merged.
+type pdfMerger struct {
+	w      *PdfWriter
+	opts   *MergeOptions
+	offset int // Number of pages added so far.
+
+	names      map[core.PdfObjectName][]nameTreeEntry
+	nameKeys   []core.PdfObjectName
+	usedNames  map[core.PdfObjectName]map[string]struct{}
+	form       *PdfAcroForm
+	fieldNames map[string]struct{}
+	ocProps    *PdfOptionalContentProperties
+	outline    *Outline
+	labels     *PdfPageLabels
+	hasLabels  bool
+}
+
+// MergeDocuments merges the documents loaded by `readers` and returns a
+// writer for the merged document. Pass nil for the opts parameter in order
+// to use the default options.
+//
+// Besides the pages, the forms, outlines, name trees (including named
+// destinations), optional content and page labels of the documents are
+// merged:
+//   - top-level form fields and name tree entries having the same names as
+//     the ones of the previous documents are renamed by appending a numeric
+//     suffix (e.g. name_2),
+//   - link annotations and GoTo actions referring to renamed destinations are
+//     updated accordingly. Destinations referring to pages by index are
+//     converted to page references, so that they point to the same pages
+//     after merging.
+//
+// NOTE: The pages of the readers are modified when added to the merged
+// document. The same reader should not be merged more than once.
+func MergeDocuments(readers []*PdfReader, opts *MergeOptions) (*PdfWriter, error) {
+	if len(readers) == 0 {
+		return nil, errors.New("no documents to merge")
+	}
+	if opts == nil {
+		opts = &MergeOptions{}
+	}
+	if len(opts.OutlineTitles) > 0 && len(opts.OutlineTitles) != len(readers) {
+		return nil, fmt.Errorf("outline titles count (%d) does not match documents count (%d)",
+			len(opts.OutlineTitles), len(readers))
+	}
+
+	w := NewPdfWriter()
+	m := &pdfMerger{
+		w:          &w,
+		opts:       opts,
+		names:      map[core.PdfObjectName][]nameTreeEntry{},
+		usedNames:  map[core.PdfObjectName]map[string]struct{}{},
+		fieldNames: map[string]struct{}{},
+		outline:    NewOutline(),
+		labels:     &PdfPageLabels{},
+	}
+	for i, r := range readers {
+		if err := m.mergeDocument(i, r); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.finalize(); err != nil {
+		return nil, err
+	}
+	return m.w, nil
+}
+
+// mergeDocument appends the document with index `i`, loaded by `r`.
+func (m *pdfMerger) mergeDocument(i int, r *PdfReader) error {
+	destRenames := m.mergeNames(r)
+
+	for _, page := range r.PageList {
+		annotations, err := page.GetAnnotations()
+		if err != nil {
+			return err
+		}
+		for _, annot := range annotations {
+			switch t := annot.GetContext().(type) {
+			case *PdfAnnotationLink:
+				if dest := fixDestination(r, t.Dest, destRenames); dest != nil {
+					t.Dest = dest
+				}
+				fixGoToAction(r, t.A, destRenames)
+			case *PdfAnnotationWidget:
+				fixGoToAction(r, t.A, destRenames)
+			}
+		}
+		if err := m.w.AddPage(page); err != nil {
+			return err
+		}
+	}
+
+	m.mergeForm(r.AcroForm)
+
+	ocProps, err := r.GetOptionalContentProperties()
+	if err != nil {
+		return err
+	}
+	if ocProps != nil {
+		if m.ocProps == nil {
+			m.ocProps = NewPdfOptionalContentProperties()
+		}
+		m.ocProps.Merge(ocProps)
+	}
+
+	m.mergeOutline(i, r)
+
+	labels, err := r.GetPageLabelRanges()
+	if err != nil {
+		return err
+	}
+	if labels != nil {
+		m.hasLabels = true
+	}
+	m.labels.Append(labels, m.offset)
+
+	m.offset += len(r.PageList)
+	return nil
+}
+
+// destRenames maps the names of the named destinations of a merged document
+// to their names in the merged Dests name tree.
+type destRenames struct {
+	// Renamed entries of the Dests name tree, referred to by strings.
+	strings map[string]string
+
+	// Entries of the catalog Dests dictionary (PDF 1.1), referred to by
+	// names. As the entries are moved to the Dests name tree, all of them are
+	// mapped, and the name destinations are converted to strings.
+	names map[string]string
+}
+
+// mergeNames adds the entries of the name trees of the document to the
+// merged name trees, renaming the entries whose names are already used.
+// The entries of the catalog Dests dictionary are added to the Dests name
+// tree. Named destinations are converted to explicit destinations referring
+// to pages by reference. Returns the names of the merged destinations.
+func (m *pdfMerger) mergeNames(r *PdfReader) destRenames {
+	trees := map[core.PdfObjectName][]nameTreeEntry{}
+	var keys []core.PdfObjectName
+	if names, ok := core.GetDict(r.catalog.Get("Names")); ok {
+		for _, key := range names.Keys() {
+			keys = append(keys, key)
+			trees[key] = getNameTreeEntries(names.Get(key))
+		}
+	}
+	numTreeDests := len(trees["Dests"])
+	if dests, ok := core.GetDict(r.catalog.Get("Dests")); ok {
+		if _, has := trees["Dests"]; !has {
+			keys = append(keys, "Dests")
+		}
+		for _, key := range dests.Keys() {
+			trees["Dests"] = append(trees["Dests"], nameTreeEntry{key: string(key), value: dests.Get(key)})
+		}
+	}
+
+	renames := destRenames{strings: map[string]string{}, names: map[string]string{}}
+	for _, key := range keys {
+		used, has := m.usedNames[key]
+		if !has {
+			used = map[string]struct{}{}
+			m.usedNames[key] = used
+			m.nameKeys = append(m.nameKeys, key)
+		}
+
+		for i, entry := range trees[key] {
+			name := uniqueName(entry.key, used)
+			used[name] = struct{}{}
+
+			value := entry.value
+			if key == "Dests" {
+				if i >= numTreeDests {
+					renames.names[entry.key] = name
+				} else if name != entry.key {
+					renames.strings[entry.key] = name
+				}
+				if dest, err := r.resolveExplicitDestination(value); err == nil {
+					value = dest.ToPdfObject()
+				} else {
+					common.Log.Debug("WARN: invalid named destination %s: %v", entry.key, err)
+				}
+			}
+			m.names[key] = append(m.names[key], nameTreeEntry{key: name, value: value})
+		}
+	}
+	return renames
+}
+
+// mergeForm adds the fields of `form` to the merged form, renaming the
+// top-level fields whose names are already used. The widget annotations
+// remain associated with their fields.
+func (m *pdfMerger) mergeForm(form *PdfAcroForm) {
+	if form == nil {
+		return
+	}
+	if m.form == nil {
+		m.form = NewPdfAcroForm()
+	}
+
+	if form.Fields != nil {
+		for _, field := range *form.Fields {
+			if field.T != nil {
+				name := field.T.Decoded()
+				unique := uniqueName(name, m.fieldNames)
+				if unique != name {
+					common.Log.Debug("Renaming form field %s to %s", name, unique)
+					field.T = core.MakeEncodedString(unique, false)
+				}
+				m.fieldNames[unique] = struct{}{}
+			}
+			*m.form.Fields = append(*m.form.Fields, field)
+		}
+	}
+
+	if form.NeedAppearances != nil && bool(*form.NeedAppearances) {
+		m.form.NeedAppearances = core.MakeBool(true)
+	}
+	if form.SigFlags != nil {
+		flags := *form.SigFlags
+		if m.form.SigFlags != nil {
+			flags |= *m.form.SigFlags
+		}
+		m.form.SigFlags = core.MakeInteger(int64(flags))
+	}
+	if form.CO != nil {
+		if m.form.CO == nil {
+			m.form.CO = core.MakeArray()
+		}
+		m.form.CO.Append(form.CO.Elements()...)
+	}
+	if form.DR != nil {
+		if m.form.DR == nil {
+			m.form.DR = NewPdfPageResources()
+		}
+		mergeFormResources(m.form.DR, form.DR)
+	}
+	if m.form.DA == nil {
+		m.form.DA = form.DA
+	}
+	if m.form.Q == nil {
+		m.form.Q = form.Q
+	}
+	if form.XFA != nil {
+		common.Log.Debug("WARN: XFA forms cannot be merged - skipping")
+	}
+}
+
+// mergeFormResources adds the default resources `src` of a merged form to
+// the merged default resources `dst`. The resources whose names are already
+// used in `dst` are kept.
+func mergeFormResources(dst, src *PdfPageResources) {
+	categories := []struct {
+		dst *core.PdfObject
+		src core.PdfObject
+	}{
+		{&dst.ExtGState, src.ExtGState},
+		{&dst.ColorSpace, src.ColorSpace},
+		{&dst.Pattern, src.Pattern},
+		{&dst.Shading, src.Shading},
+		{&dst.XObject, src.XObject},
+		{&dst.Font, src.Font},
+		{&dst.Properties, src.Properties},
+	}
+	for _, category := range categories {
+		srcDict, ok := core.GetDict(category.src)
+		if !ok {
+			continue
+		}
+		dstDict, ok := core.GetDict(*category.dst)
+		if !ok {
+			dstDict = core.MakeDict()
+			*category.dst = dstDict
+		}
+		for _, name := range srcDict.Keys() {
+			if dstDict.Get(name) == nil {
+				dstDict.Set(name, srcDict.Get(name))
+			}
+		}
+	}
+
+	if procSet, ok := core.GetArray(src.ProcSet); ok {
+		merged, ok := core.GetArray(dst.ProcSet)
+		if !ok {
+			merged = core.MakeArray()
+			dst.ProcSet = merged
+		}
+		has := map[string]struct{}{}
+		for _, obj := range merged.Elements() {
+			if name, ok := core.GetNameVal(obj); ok {
+				has[name] = struct{}{}
+			}
+		}
+		for _, obj := range procSet.Elements() {
+			if name, ok := core.GetNameVal(obj); ok {
+				if _, found := has[name]; !found {
+					has[name] = struct{}{}
+					merged.Append(core.MakeName(name))
+				}
+			}
+		}
+	}
+}
+
+// mergeOutline adds the outline items of the document with index `i` to the
+// merged outline, optionally grouped under an item titled after the
+// document.
+func (m *pdfMerger) mergeOutline(i int, r *PdfReader) {
+	if len(r.PageList) == 0 {
+		return
+	}
+
+	var items []*OutlineItem
+	if r.GetOutlineTree() != nil {
+		outline, err := r.GetOutlines()
+		if err != nil {
+			common.Log.Debug("WARN: could not load outline: %v", err)
+		} else {
+			end := len(r.PageList) - 1
+			for _, item := range outline.Entries {
+				items = append(items, rebaseOutlineItem(item, 0, end, m.offset))
+			}
+		}
+	}
+
+	if len(m.opts.OutlineTitles) == 0 {
+		for _, item := range items {
+			m.outline.Add(item)
+		}
+		return
+	}
+
+	parent := NewOutlineItem(m.opts.OutlineTitles[i], OutlineDest{
+		PageObj: r.PageList[0].GetPageAsIndirectObject(),
+		Page:    int64(m.offset),
+		Mode:    "Fit",
+	})
+	for _, item := range items {
+		parent.Add(item)
+	}
+	m.outline.Add(parent)
+}
+
+// finalize sets the merged document level entries to the writer.
+func (m *pdfMerger) finalize() error {
+	if len(m.nameKeys) > 0 {
+		names := core.MakeDict()
+		for _, key := range m.nameKeys {
+			if len(m.names[key]) > 0 {
+				names.Set(key, makeNameTree(m.names[key]))
+			}
+		}
+		if err := m.w.SetNamedDestinations(core.MakeIndirectObject(names)); err != nil {
+			return err
+		}
+	}
+	if m.form != nil {
+		if err := m.w.SetForms(m.form); err != nil {
+			return err
+		}
+	}
+	if m.ocProps != nil {
+		if err := m.w.SetOptionalContentProperties(m.ocProps); err != nil {
+			return err
+		}
+	}
+	if len(m.outline.Entries) > 0 {
+		m.w.AddOutlineTree(m.outline.ToOutlineTree())
+	}
+	if m.hasLabels {
+		if err := m.w.SetPageLabelRanges(m.labels); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fixDestination returns the destination object replacing `obj` after
+// merging: named destinations are replaced with their names in the merged
+// Dests name tree, name destinations being converted to strings as the
+// catalog Dests dictionary is not written, and explicit destinations
+// referring to pages by index are converted to page references. Returns nil
+// if `obj` does not need to be replaced.
+func fixDestination(r *PdfReader, obj core.PdfObject, renames destRenames) core.PdfObject {
+	switch t := core.TraceToDirectObject(obj).(type) {
+	case *core.PdfObjectName:
+		if name, ok := renames.names[string(*t)]; ok {
+			return core.MakeString(name)
+		}
+	case *core.PdfObjectString:
+		if name, ok := renames.strings[t.Str()]; ok {
+			return core.MakeString(name)
+		}
+	case *core.PdfObjectArray:
+		if _, ok := core.GetIntVal(t.Get(0)); !ok {
+			return nil
+		}
+		dest, err := newPdfDestinationFromArray(t, r)
+		if err != nil || dest.Page == nil {
+			common.Log.Debug("WARN: could not resolve destination %v: %v", obj, err)
+			return nil
+		}
+		return dest.ToPdfObject()
+	}
+	return nil
+}
+
+// fixGoToAction updates the destination of the GoTo action `obj`, and of its
+// chained actions, after merging.
+func fixGoToAction(r *PdfReader, obj core.PdfObject, renames destRenames) {
+	for depth := 0; depth < 32; depth++ {
+		action, ok := core.GetDict(obj)
+		if !ok {
+			return
+		}
+		if s, _ := core.GetNameVal(action.Get("S")); s == "GoTo" {
+			if dest := fixDestination(r, action.Get("D"), renames); dest != nil {
+				action.Set("D", dest)
+			}
+		}
+		obj = action.Get("Next")
+	}
+}
+
+// uniqueName returns `name` if it is not in `used`, or `name` suffixed with
+// the smallest number (starting at 2) yielding an unused name.
+func uniqueName(name string, used map[string]struct{}) string {
+	if _, ok := used[name]; !ok {
+		return name
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s_%d", name, i)
+		if _, ok := used[candidate]; !ok {
+			return candidate
+		}
+	}
+}
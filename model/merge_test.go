@@ -0,0 +1,275 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// writeMergeFixture returns a reader for an interactive document with two
+// pages: the first page contains a text field named `fieldName` and links to
+// the second page, by named destination and by page index. The outline has
+// an item named `title` pointing to the second page. If `layer` is not
+// empty, the document has an optional content group with that name.
+func writeMergeFixture(t *testing.T, fieldName, title, layer string) *PdfReader {
+	w := NewPdfWriter()
+	var pages []*PdfPage
+	for i := 0; i < 2; i++ {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 100, Ury: 100}
+		pages = append(pages, page)
+	}
+
+	field := newTestTextField(fieldName)
+	widget := NewPdfAnnotationWidget()
+	widget.Rect = core.MakeArrayFromFloats([]float64{10, 10, 90, 30})
+	widget.parent = field
+	field.Annotations = append(field.Annotations, widget)
+	pages[0].AddAnnotation(widget.PdfAnnotation)
+	form := NewPdfAcroForm()
+	*form.Fields = append(*form.Fields, field)
+
+	named := NewPdfAnnotationLink()
+	named.Rect = core.MakeArrayFromFloats([]float64{10, 40, 90, 50})
+	named.Dest = core.MakeString("details")
+	pages[0].AddAnnotation(named.PdfAnnotation)
+	action := NewPdfAnnotationLink()
+	action.Rect = core.MakeArrayFromFloats([]float64{10, 60, 90, 70})
+	goTo := core.MakeDict()
+	goTo.Set("S", core.MakeName("GoTo"))
+	goTo.Set("D", core.MakeArray(core.MakeInteger(1), core.MakeName("Fit")))
+	action.A = core.MakeIndirectObject(goTo)
+	pages[0].AddAnnotation(action.PdfAnnotation)
+
+	for _, page := range pages {
+		require.NoError(t, w.AddPage(page))
+	}
+	require.NoError(t, w.SetForms(form))
+	require.NoError(t, w.AddNamedDestination("details", NewPdfDestinationFit(pages[1].GetPageAsIndirectObject())))
+
+	outline := NewOutline()
+	outline.Add(NewOutlineItem(title, NewOutlineDest(1, 0, 100)))
+	w.AddOutlineTree(outline.ToOutlineTree())
+
+	if layer != "" {
+		props := NewPdfOptionalContentProperties()
+		props.AddGroup(NewPdfOptionalContentGroup(layer), false)
+		require.NoError(t, w.SetOptionalContentProperties(props))
+	}
+
+	reader := writeAndRead(t, &w)
+	return reader
+}
+
+func TestMergeDocuments(t *testing.T) {
+	readers := []*PdfReader{
+		writeMergeFixture(t, "name", "Details 1", "Layer 1"),
+		writeMergeFixture(t, "name", "Details 2", "Layer 2"),
+	}
+	w, err := MergeDocuments(readers, &MergeOptions{OutlineTitles: []string{"First", "Second"}})
+	require.NoError(t, err)
+
+	reader := writeAndRead(t, w)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 4, numPages)
+
+	// Fields are renamed and remain associated with their widgets.
+	require.NotNil(t, reader.AcroForm)
+	fields := reader.AcroForm.AllFields()
+	require.Len(t, fields, 2)
+	require.Equal(t, "name", fields[0].PartialName())
+	require.Equal(t, "name_2", fields[1].PartialName())
+	for i, field := range fields {
+		require.Len(t, field.Annotations, 1)
+		page, err := reader.GetPage(2*i + 1)
+		require.NoError(t, err)
+		annots, err := page.GetAnnotations()
+		require.NoError(t, err)
+		require.Equal(t, field.Annotations[0].GetContainingPdfObject(), annots[0].GetContainingPdfObject())
+	}
+	require.NoError(t, reader.AcroForm.Fill(testFieldValues{
+		"name":   core.MakeString("Alice"),
+		"name_2": core.MakeString("Bob"),
+	}))
+	require.Equal(t, "Bob", fields[1].V.(*core.PdfObjectString).Decoded())
+
+	// Links land on the second page of their document.
+	for i := 0; i < 2; i++ {
+		page, err := reader.GetPage(2*i + 1)
+		require.NoError(t, err)
+		annots, err := page.GetAnnotations()
+		require.NoError(t, err)
+		require.Len(t, annots, 3)
+		for _, annot := range annots[1:] {
+			dest, err := annot.GetContext().(*PdfAnnotationLink).GetDestination()
+			require.NoError(t, err)
+			require.NotNil(t, dest)
+			require.EqualValues(t, 2*i+1, dest.PageIndex)
+		}
+	}
+	link := func(pageNum int) *PdfAnnotationLink {
+		page, err := reader.GetPage(pageNum)
+		require.NoError(t, err)
+		annots, err := page.GetAnnotations()
+		require.NoError(t, err)
+		return annots[1].GetContext().(*PdfAnnotationLink)
+	}
+	require.Equal(t, "details", link(1).Dest.(*core.PdfObjectString).Str())
+	require.Equal(t, "details_2", link(3).Dest.(*core.PdfObjectString).Str())
+
+	// Named destinations.
+	for name, pageIndex := range map[string]int64{"details": 1, "details_2": 3} {
+		dest, err := reader.GetNamedDestination(name)
+		require.NoError(t, err)
+		require.NotNil(t, dest, name)
+		require.Equal(t, pageIndex, dest.PageIndex)
+	}
+
+	// Outlines are grouped by document.
+	outline, err := reader.GetOutlines()
+	require.NoError(t, err)
+	require.Equal(t, []string{"First", " Details 1", "Second", " Details 2"}, outlineTitles(outline.Entries, 0))
+	require.EqualValues(t, 2, outline.Entries[1].Dest.Page)
+	require.EqualValues(t, 3, outline.Entries[1].Entries[0].Dest.Page)
+
+	// Optional content.
+	props, err := reader.GetOptionalContentProperties()
+	require.NoError(t, err)
+	require.Len(t, props.OCGs, 2)
+	require.Equal(t, "Layer 1", props.OCGs[0].Name)
+	require.Equal(t, "Layer 2", props.OCGs[1].Name)
+	require.False(t, props.IsVisible(props.OCGs[0]))
+	require.False(t, props.IsVisible(props.OCGs[1]))
+	require.Equal(t, 2, props.D.Order.Len())
+}
+
+func TestMergeDocumentsFlatOutline(t *testing.T) {
+	readers := []*PdfReader{
+		writeMergeFixture(t, "a", "Details 1", ""),
+		writeMergeFixture(t, "b", "Details 2", ""),
+	}
+	w, err := MergeDocuments(readers, nil)
+	require.NoError(t, err)
+
+	reader := writeAndRead(t, w)
+
+	outline, err := reader.GetOutlines()
+	require.NoError(t, err)
+	require.Equal(t, []string{"Details 1", "Details 2"}, outlineTitles(outline.Entries, 0))
+	require.EqualValues(t, 3, outline.Entries[1].Dest.Page)
+
+	fields := reader.AcroForm.AllFields()
+	require.Len(t, fields, 2)
+	require.Equal(t, "a", fields[0].PartialName())
+	require.Equal(t, "b", fields[1].PartialName())
+
+	props, err := reader.GetOptionalContentProperties()
+	require.NoError(t, err)
+	require.Nil(t, props)
+
+	_, err = MergeDocuments(nil, nil)
+	require.Error(t, err)
+	_, err = MergeDocuments(readers, &MergeOptions{OutlineTitles: []string{"Only one"}})
+	require.Error(t, err)
+}
+
+// writeCatalogDestsFixture returns a reader of a two-page document whose
+// named destination "target", pointing to the second page, is stored in the
+// catalog Dests dictionary (PDF 1.1) and referred to by a link and a GoTo
+// action with name destinations. The form default resources contain an
+// XObject named `xobjName`.
+func writeCatalogDestsFixture(t *testing.T, xobjName string) *PdfReader {
+	w := NewPdfWriter()
+	var pages []*PdfPage
+	for i := 0; i < 2; i++ {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 100, Ury: 100}
+		pages = append(pages, page)
+	}
+
+	link := NewPdfAnnotationLink()
+	link.Rect = core.MakeArrayFromFloats([]float64{10, 10, 90, 20})
+	link.Dest = core.MakeName("target")
+	pages[0].AddAnnotation(link.PdfAnnotation)
+	action := NewPdfAnnotationLink()
+	action.Rect = core.MakeArrayFromFloats([]float64{10, 30, 90, 40})
+	goTo := core.MakeDict()
+	goTo.Set("S", core.MakeName("GoTo"))
+	goTo.Set("D", core.MakeName("target"))
+	action.A = core.MakeIndirectObject(goTo)
+	pages[0].AddAnnotation(action.PdfAnnotation)
+	for _, page := range pages {
+		require.NoError(t, w.AddPage(page))
+	}
+
+	dests := core.MakeDict()
+	dests.Set("target", core.MakeArray(pages[1].GetPageAsIndirectObject(), core.MakeName("Fit")))
+	w.catalog.Set("Dests", dests)
+
+	form := NewPdfAcroForm()
+	*form.Fields = append(*form.Fields, newTestTextField(xobjName))
+	form.DR = NewPdfPageResources()
+	xobj, err := core.MakeStream(nil, nil)
+	require.NoError(t, err)
+	xobj.Set("Type", core.MakeName("XObject"))
+	xobj.Set("Subtype", core.MakeName("Form"))
+	xobj.Set("BBox", core.MakeArrayFromFloats([]float64{0, 0, 10, 10}))
+	require.NoError(t, form.DR.SetXObjectByName(core.PdfObjectName(xobjName), xobj))
+	require.NoError(t, form.DR.SetFontByName("Helv", NewStandard14FontMustCompile(HelveticaName).ToPdfObject()))
+	form.DR.ProcSet = core.MakeArray(core.MakeName("PDF"), core.MakeName("Text"))
+	require.NoError(t, w.SetForms(form))
+
+	reader := writeAndRead(t, &w)
+	dest, err := reader.ResolveDestination(core.MakeName("target"))
+	require.NoError(t, err)
+	require.EqualValues(t, 1, dest.PageIndex)
+	return reader
+}
+
+func TestMergeDocumentsCatalogDests(t *testing.T) {
+	readers := []*PdfReader{
+		writeCatalogDestsFixture(t, "Logo1"),
+		writeCatalogDestsFixture(t, "Logo2"),
+	}
+	w, err := MergeDocuments(readers, nil)
+	require.NoError(t, err)
+
+	reader := writeAndRead(t, w)
+	require.Nil(t, reader.catalog.Get("Dests"))
+
+	// The name destinations are converted to strings referring to the Dests
+	// name tree.
+	for i, name := range []string{"target", "target_2"} {
+		page, err := reader.GetPage(2*i + 1)
+		require.NoError(t, err)
+		annots, err := page.GetAnnotations()
+		require.NoError(t, err)
+		require.Len(t, annots, 2)
+		for _, annot := range annots {
+			link := annot.GetContext().(*PdfAnnotationLink)
+			dest, err := link.GetDestination()
+			require.NoError(t, err)
+			require.NotNil(t, dest)
+			require.EqualValues(t, 2*i+1, dest.PageIndex)
+		}
+		require.Equal(t, name, annots[0].GetContext().(*PdfAnnotationLink).Dest.(*core.PdfObjectString).Str())
+	}
+
+	// All the categories of the form default resources are merged.
+	dr := reader.AcroForm.DR
+	require.NotNil(t, dr)
+	require.True(t, dr.HasXObjectByName("Logo1"))
+	require.True(t, dr.HasXObjectByName("Logo2"))
+	require.True(t, dr.HasFontByName("Helv"))
+	procSet, ok := core.GetArray(dr.ProcSet)
+	require.True(t, ok)
+	require.Equal(t, 2, procSet.Len())
+}
@@ -0,0 +1,192 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"sort"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// nameTreeMaxNodeSize is the maximum number of entries stored in a single
+// name tree node, when generating name trees. Larger trees are split into
+// intermediate nodes (Kids).
+const nameTreeMaxNodeSize = 64
+
+// nameTreeEntry represents a key-value pair of a name tree.
+type nameTreeEntry struct {
+	key   string
+	value core.PdfObject
+}
+
+// nameTreeKey returns the name tree key of the specified name, encoded with
+// UTF-16BE if the name is not ASCII.
+func nameTreeKey(name string) string {
+	for i := 0; i < len(name); i++ {
+		if name[i] >= 0x80 {
+			return core.MakeEncodedString(name, true).Str()
+		}
+	}
+	return name
+}
+
+// getNameTreeEntries returns the entries of the name tree rooted at `obj`,
+// in the order they are stored in the tree.
+// See section 7.9.6 "Name Trees" (p. 88 PDF32000_2008).
+func getNameTreeEntries(obj core.PdfObject) []nameTreeEntry {
+	var entries []nameTreeEntry
+	collectNameTreeEntries(obj, 0, &entries)
+	return entries
+}
+
+func collectNameTreeEntries(obj core.PdfObject, depth int, entries *[]nameTreeEntry) {
+	if depth > 32 {
+		common.Log.Debug("ERROR: name tree too deep")
+		return
+	}
+	node, ok := core.GetDict(obj)
+	if !ok {
+		return
+	}
+
+	if names, ok := core.GetArray(node.Get("Names")); ok {
+		elements := names.Elements()
+		for i := 0; i+1 < len(elements); i += 2 {
+			key, ok := core.GetString(elements[i])
+			if !ok {
+				common.Log.Debug("ERROR: invalid name tree key (%T)", elements[i])
+				continue
+			}
+			*entries = append(*entries, nameTreeEntry{key: key.Str(), value: elements[i+1]})
+		}
+	}
+
+	if kids, ok := core.GetArray(node.Get("Kids")); ok {
+		for _, kid := range kids.Elements() {
+			collectNameTreeEntries(kid, depth+1, entries)
+		}
+	}
+}
+
+// makeNameTree returns the root node of a name tree containing the specified
+// entries. The entries are sorted by key. Trees with more than
+// nameTreeMaxNodeSize entries are split into intermediate nodes.
+func makeNameTree(entries []nameTreeEntry) *core.PdfIndirectObject {
+	sorted := make([]nameTreeEntry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].key < sorted[j].key
+	})
+
+	if len(sorted) <= nameTreeMaxNodeSize {
+		return core.MakeIndirectObject(makeNameTreeLeaf(sorted, false))
+	}
+
+	// Leaf nodes.
+	var nodes []*core.PdfIndirectObject
+	var limits [][2]string
+	for i := 0; i < len(sorted); i += nameTreeMaxNodeSize {
+		end := i + nameTreeMaxNodeSize
+		if end > len(sorted) {
+			end = len(sorted)
+		}
+		nodes = append(nodes, core.MakeIndirectObject(makeNameTreeLeaf(sorted[i:end], true)))
+		limits = append(limits, [2]string{sorted[i].key, sorted[end-1].key})
+	}
+
+	// Intermediate nodes, until the root can hold all the nodes.
+	for len(nodes) > nameTreeMaxNodeSize {
+		var parents []*core.PdfIndirectObject
+		var parentLimits [][2]string
+		for i := 0; i < len(nodes); i += nameTreeMaxNodeSize {
+			end := i + nameTreeMaxNodeSize
+			if end > len(nodes) {
+				end = len(nodes)
+			}
+			kids := core.MakeArray()
+			for _, node := range nodes[i:end] {
+				kids.Append(node)
+			}
+			node := core.MakeDict()
+			node.Set("Kids", kids)
+			node.Set("Limits", core.MakeArray(
+				core.MakeString(limits[i][0]),
+				core.MakeString(limits[end-1][1]),
+			))
+			parents = append(parents, core.MakeIndirectObject(node))
+			parentLimits = append(parentLimits, [2]string{limits[i][0], limits[end-1][1]})
+		}
+		nodes, limits = parents, parentLimits
+	}
+
+	kids := core.MakeArray()
+	for _, node := range nodes {
+		kids.Append(node)
+	}
+	root := core.MakeDict()
+	root.Set("Kids", kids)
+	return core.MakeIndirectObject(root)
+}
+
+// makeNameTreeLeaf returns a leaf node containing the specified sorted
+// entries. The Limits entry is set for all nodes except the root.
+func makeNameTreeLeaf(entries []nameTreeEntry, setLimits bool) *core.PdfObjectDictionary {
+	names := core.MakeArray()
+	for _, entry := range entries {
+		names.Append(core.MakeString(entry.key), entry.value)
+	}
+
+	node := core.MakeDict()
+	node.Set("Names", names)
+	if setLimits && len(entries) > 0 {
+		node.Set("Limits", core.MakeArray(
+			core.MakeString(entries[0].key),
+			core.MakeString(entries[len(entries)-1].key),
+		))
+	}
+	return node
+}
+
+// updateNamesDict returns a copy of the specified catalog Names dictionary,
+// in which the name tree stored under `key` is regenerated without the
+// `removed` keys and with the `added` entries. Added entries replace existing
+// entries with the same key. The original dictionary is not modified.
+func updateNamesDict(names core.PdfObject, key core.PdfObjectName, removed map[string]struct{},
+	added []nameTreeEntry) *core.PdfIndirectObject {
+	updated := core.MakeDict()
+	var tree core.PdfObject
+	if namesDict, ok := core.GetDict(names); ok {
+		for _, k := range namesDict.Keys() {
+			updated.Set(k, namesDict.Get(k))
+		}
+		tree = namesDict.Get(key)
+	}
+
+	replaced := map[string]struct{}{}
+	for _, entry := range added {
+		replaced[entry.key] = struct{}{}
+	}
+
+	var entries []nameTreeEntry
+	for _, entry := range getNameTreeEntries(tree) {
+		if _, ok := removed[entry.key]; ok {
+			continue
+		}
+		if _, ok := replaced[entry.key]; ok {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	entries = append(entries, added...)
+
+	if len(entries) > 0 {
+		updated.Set(key, makeNameTree(entries))
+	} else {
+		updated.Remove(key)
+	}
+	return core.MakeIndirectObject(updated)
+}
@@ -0,0 +1,181 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package optcontent provides operations on the optional content (layers)
+// of PDF pages, which require processing the page content streams.
+package optcontent
+
+import (
+	"errors"
+
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// RemoveGroup removes the optional content group `ocg` from the document
+// optional content properties `props` and from `pages`. The content of the
+// pages belonging to the group is either removed or, if `keepContent` is
+// true, kept and made non-optional.
+func RemoveGroup(props *model.PdfOptionalContentProperties, pages []*model.PdfPage,
+	ocg *model.PdfOptionalContentGroup, keepContent bool) error {
+	if props == nil || ocg == nil {
+		return errors.New("optional content group not specified")
+	}
+	for _, page := range pages {
+		if err := RemovePageContent(page, ocg, keepContent); err != nil {
+			return err
+		}
+	}
+	props.RemoveGroup(ocg)
+	return nil
+}
+
+// RemovePageContent removes the association of the content of `page` with
+// the optional content group `ocg`. The marked-content sequences, XObjects
+// and annotations belonging to the group (directly or through a membership
+// dictionary) are removed if `keepContent` is false. Otherwise, the content
+// is kept and made non-optional.
+func RemovePageContent(page *model.PdfPage, ocg *model.PdfOptionalContentGroup, keepContent bool) error {
+	if page == nil || ocg == nil {
+		return errors.New("page or optional content group not specified")
+	}
+
+	// Annotations.
+	annots, err := page.GetAnnotations()
+	if err != nil {
+		return err
+	}
+	if len(annots) > 0 {
+		var kept []*model.PdfAnnotation
+		for _, annot := range annots {
+			if isMember(annot.OC, ocg) {
+				if !keepContent {
+					continue
+				}
+				annot.OC = nil
+			}
+			kept = append(kept, annot)
+		}
+		page.SetAnnotations(kept)
+	}
+
+	if page.Contents == nil {
+		return nil
+	}
+	contents, err := page.GetAllContentStreams()
+	if err != nil {
+		return err
+	}
+	ops, err := contentstream.NewContentStreamParser(contents).Parse()
+	if err != nil {
+		return err
+	}
+	resources := page.Resources
+	if resources == nil {
+		resources = model.NewPdfPageResources()
+	}
+
+	// Marked-content nesting: for each open sequence, whether it belongs to
+	// the group. The content is skipped while `skipDepth` is not negative.
+	var stack []bool
+	skipDepth := -1
+	modified := false
+	result := contentstream.ContentStreamOperations{}
+	for _, op := range *ops {
+		switch op.Operand {
+		case "BMC", "BDC":
+			member := false
+			if op.Operand == "BDC" && len(op.Params) == 2 {
+				if tag, ok := core.GetName(op.Params[0]); ok && *tag == "OC" {
+					props := op.Params[1]
+					if name, ok := core.GetName(props); ok {
+						props, _ = resources.GetPropertiesByName(*name)
+					}
+					member = isMember(props, ocg)
+				}
+			}
+			stack = append(stack, member)
+			if member {
+				modified = true
+				if !keepContent && skipDepth < 0 {
+					skipDepth = len(stack) - 1
+				}
+				continue
+			}
+		case "EMC":
+			if len(stack) > 0 {
+				member := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if skipDepth == len(stack) {
+					skipDepth = -1
+					continue
+				}
+				if member && skipDepth < 0 {
+					continue
+				}
+			}
+		case "Do":
+			if len(op.Params) == 1 && skipDepth < 0 {
+				if name, ok := core.GetName(op.Params[0]); ok {
+					stream, _ := resources.GetXObjectByName(*name)
+					if stream != nil && isMember(stream.Get("OC"), ocg) {
+						modified = true
+						if !keepContent {
+							continue
+						}
+						stream.Remove("OC")
+					}
+				}
+			}
+		}
+		if skipDepth >= 0 {
+			continue
+		}
+		result = append(result, op)
+	}
+	if !modified {
+		return nil
+	}
+	return page.SetContentStreams([]string{result.String()}, core.NewFlateEncoder())
+}
+
+// isMember returns true if the optional content group or membership
+// dictionary `obj` refers to `ocg`. Visibility expressions are not
+// evaluated: membership dictionaries are considered to refer to the groups
+// listed in their OCGs entry.
+func isMember(obj core.PdfObject, ocg *model.PdfOptionalContentGroup) bool {
+	if obj == nil {
+		return false
+	}
+	if isGroup(obj, ocg) {
+		return true
+	}
+	dict, ok := core.GetDict(obj)
+	if !ok {
+		return false
+	}
+	if typ, ok := core.GetName(dict.Get("Type")); !ok || *typ != "OCMD" {
+		return false
+	}
+	ocgs := dict.Get("OCGs")
+	if isGroup(ocgs, ocg) {
+		return true
+	}
+	if arr, ok := core.GetArray(ocgs); ok {
+		for _, elem := range arr.Elements() {
+			if isGroup(elem, ocg) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isGroup returns true if `obj` refers to `ocg`.
+func isGroup(obj core.PdfObject, ocg *model.PdfOptionalContentGroup) bool {
+	container := ocg.GetContainingPdfObject()
+	return obj == container || core.ResolveReference(obj) == container
+}
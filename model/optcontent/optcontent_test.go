@@ -0,0 +1,86 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package optcontent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// makeLayeredPage returns a page with content in the `walls` group, in a
+// membership dictionary of both groups, in the `wiring` group and outside of
+// any group, as well as an annotation in the `walls` group.
+func makeLayeredPage(t *testing.T, walls, wiring *model.PdfOptionalContentGroup) *model.PdfPage {
+	page := model.NewPdfPage()
+	page.Resources = model.NewPdfPageResources()
+	require.NoError(t, page.Resources.SetPropertiesByName("MC0", walls.ToPdfObject()))
+	ocmd := model.NewPdfOptionalContentMembership(model.OCMDVisibilityAnyOn, walls, wiring)
+	require.NoError(t, page.Resources.SetPropertiesByName("MC1", ocmd.ToPdfObject()))
+	require.NoError(t, page.Resources.SetPropertiesByName("MC2", wiring.ToPdfObject()))
+
+	cc := contentstream.NewContentCreator()
+	cc.Add_BDC("OC", core.MakeName("MC0")).
+		Add_re(0, 0, 10, 10).Add_f().
+		Add_BMC("Artifact").Add_re(0, 20, 10, 10).Add_f().Add_EMC().
+		Add_EMC().
+		Add_BDC("OC", core.MakeName("MC1")).
+		Add_re(20, 0, 10, 10).Add_f().
+		Add_EMC().
+		Add_BDC("OC", core.MakeName("MC2")).
+		Add_re(40, 0, 10, 10).Add_f().
+		Add_EMC().
+		Add_re(60, 0, 10, 10).Add_f()
+	require.NoError(t, page.SetContentStreams([]string{cc.String()}, nil))
+
+	annot := model.NewPdfAnnotationSquare()
+	annot.Rect = core.MakeArrayFromFloats([]float64{0, 0, 10, 10})
+	annot.OC = walls.ToPdfObject()
+	page.AddAnnotation(annot.PdfAnnotation)
+	return page
+}
+
+func TestRemoveGroupDropContent(t *testing.T) {
+	walls := model.NewPdfOptionalContentGroup("Walls")
+	wiring := model.NewPdfOptionalContentGroup("Wiring")
+	props := model.NewPdfOptionalContentProperties()
+	props.AddGroup(walls, true)
+	props.AddGroup(wiring, true)
+	page := makeLayeredPage(t, walls, wiring)
+
+	require.NoError(t, RemoveGroup(props, []*model.PdfPage{page}, walls, false))
+	require.Equal(t, []*model.PdfOptionalContentGroup{wiring}, props.OCGs)
+
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	require.Equal(t, "/OC /MC2 BDC\n40 0 10 10 re\nf\nEMC\n60 0 10 10 re\nf\n", contents)
+
+	annots, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Empty(t, annots)
+}
+
+func TestRemoveGroupKeepContent(t *testing.T) {
+	walls := model.NewPdfOptionalContentGroup("Walls")
+	wiring := model.NewPdfOptionalContentGroup("Wiring")
+	page := makeLayeredPage(t, walls, wiring)
+
+	require.NoError(t, RemovePageContent(page, walls, true))
+
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	require.Equal(t, "0 0 10 10 re\nf\n/Artifact BMC\n0 20 10 10 re\nf\nEMC\n20 0 10 10 re\nf\n"+
+		"/OC /MC2 BDC\n40 0 10 10 re\nf\nEMC\n60 0 10 10 re\nf\n", contents)
+
+	annots, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 1)
+	require.Nil(t, annots[0].OC)
+}
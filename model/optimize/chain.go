@@ -6,6 +6,9 @@
 package optimize
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/unidoc/unipdf/v3/core"
 	"github.com/unidoc/unipdf/v3/model"
 )
@@ -23,9 +26,23 @@ func (c *Chain) Append(optimizers ...model.Optimizer) {
 
 // Optimize optimizes PDF objects to decrease PDF size.
 func (c *Chain) Optimize(objects []core.PdfObject) (optimizedObjects []core.PdfObject, err error) {
+	return c.OptimizeWithContext(context.Background(), objects)
+}
+
+// OptimizeWithContext optimizes PDF objects as Optimize, checking `ctx` before
+// each optimizer of the chain. The optimizers implementing
+// model.ContextOptimizer are passed `ctx`.
+func (c *Chain) OptimizeWithContext(ctx context.Context, objects []core.PdfObject) (optimizedObjects []core.PdfObject, err error) {
 	optimizedObjects = objects
 	for _, optimizer := range c.optimizers {
-		optimizedObjects, err = optimizer.Optimize(optimizedObjects)
+		if err := ctx.Err(); err != nil {
+			return optimizedObjects, fmt.Errorf("optimization cancelled: %w", err)
+		}
+		if o, ok := optimizer.(model.ContextOptimizer); ok {
+			optimizedObjects, err = o.OptimizeWithContext(ctx, optimizedObjects)
+		} else {
+			optimizedObjects, err = optimizer.Optimize(optimizedObjects)
+		}
 		if err != nil {
 			return optimizedObjects, err
 		}
@@ -7,47 +7,143 @@ package optimize
 
 import (
 	"crypto/md5"
+	"sort"
 
+	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
 )
 
 // CombineDuplicateStreams combines duplicated streams by its data hash.
 // It implements interface model.Optimizer.
 type CombineDuplicateStreams struct {
+	// CompareDecoded compares the streams by their decoded data and their dictionaries, rather
+	// than by their encoded data only, so that streams encoded differently are combined and
+	// streams with the same data but different dictionaries are not. The data of images encoded
+	// with DCTDecode, JPXDecode, JBIG2Decode or CCITTFaxDecode is compared encoded.
+	CompareDecoded bool
+
+	// CombineIndirectObjects combines the identical indirect objects as well, as done by
+	// CombineIdenticalIndirectObjects, until no more streams or objects are identical. This
+	// combines the objects which become identical once the streams they refer to are combined,
+	// such as the font descriptors of identical font files, and the streams which become identical
+	// once the objects they refer to are combined, such as the form XObjects using identical fonts.
+	CombineIndirectObjects bool
+
+	stats CombineStats
+}
+
+// Stats returns the objects removed by the last optimization.
+func (dup *CombineDuplicateStreams) Stats() CombineStats {
+	return dup.stats
 }
 
 // Optimize optimizes PDF objects to decrease PDF size.
 func (dup *CombineDuplicateStreams) Optimize(objects []core.PdfObject) (optimizedObjects []core.PdfObject, err error) {
-	replaceTable := make(map[core.PdfObject]core.PdfObject)
-	toDelete := make(map[core.PdfObject]struct{})
-	streamsByHash := make(map[string][]*core.PdfObjectStream)
-	for _, obj := range objects {
-		if stream, isStreamObj := obj.(*core.PdfObjectStream); isStreamObj {
-			hasher := md5.New()
-			hasher.Write([]byte(stream.Stream))
-			hash := string(hasher.Sum(nil))
-			streamsByHash[hash] = append(streamsByHash[hash], stream)
+	dup.stats = CombineStats{}
+	dataHashes := make(map[*core.PdfObjectStream]streamDataHash)
+	key := func(obj core.PdfObject) string {
+		stream, isStreamObj := obj.(*core.PdfObjectStream)
+		if !isStreamObj {
+			if dup.CombineIndirectObjects {
+				return indirectObjectKey(obj)
+			}
+			return ""
+		}
+		if !dup.CompareDecoded {
+			hash := md5.Sum(stream.Stream)
+			return "s" + string(hash[:])
+		}
+
+		data, ok := dataHashes[stream]
+		if !ok {
+			data = hashStreamData(stream)
+			dataHashes[stream] = data
 		}
+		return "s" + hashStreamDict(stream.PdfObjectDictionary, data.skip, data.hash)
 	}
-	for _, streams := range streamsByHash {
-		if len(streams) < 2 {
-			continue
+
+	optimizedObjects = combineObjects(objects, key, &dup.stats)
+	common.Log.Debug("Combined %d streams and %d objects (%d bytes)",
+		dup.stats.Streams, dup.stats.Objects, dup.stats.Bytes)
+	return optimizedObjects, nil
+}
+
+// streamEncodingKeys are the dictionary entries of streams which are not compared when comparing
+// decoded data.
+var streamEncodingKeys = map[core.PdfObjectName]struct{}{
+	"Length":      {},
+	"Filter":      {},
+	"DecodeParms": {},
+	"DL":          {},
+}
+
+// imageEncodingFilters are the filters of the streams whose data is compared encoded.
+var imageEncodingFilters = map[string]struct{}{
+	core.StreamEncodingFilterNameDCT:      {},
+	core.StreamEncodingFilterNameJPX:      {},
+	core.StreamEncodingFilterNameJBIG2:    {},
+	core.StreamEncodingFilterNameCCITTFax: {},
+}
+
+// streamDataHash represents the hash of the data of a stream, along with the dictionary entries
+// not compared.
+type streamDataHash struct {
+	hash []byte
+	skip map[core.PdfObjectName]struct{}
+}
+
+// hashStreamData returns the hash of the data of `stream`. The data is decoded, except for images
+// encoded with image specific filters or when it cannot be decoded, in which case the encoding
+// entries of the dictionary are compared.
+func hashStreamData(stream *core.PdfObjectStream) streamDataHash {
+	data := stream.Stream
+	skip := map[core.PdfObjectName]struct{}{"Length": {}}
+	if !hasImageEncodingFilter(stream.PdfObjectDictionary) {
+		if decoded, err := core.DecodeStream(stream); err == nil {
+			data = decoded
+			skip = streamEncodingKeys
+		} else {
+			common.Log.Debug("Failed to decode stream, comparing encoded data: %v", err)
 		}
-		firstStream := streams[0]
-		for i := 1; i < len(streams); i++ {
-			stream := streams[i]
-			replaceTable[stream] = firstStream
-			toDelete[stream] = struct{}{}
+	}
+	hash := md5.Sum(data)
+	return streamDataHash{hash: hash[:], skip: skip}
+}
+
+// hasImageEncodingFilter returns true if the filters of the stream dictionary `dict` include an
+// image specific filter.
+func hasImageEncodingFilter(dict *core.PdfObjectDictionary) bool {
+	filters := []core.PdfObject{dict.Get("Filter")}
+	if arr, ok := core.GetArray(dict.Get("Filter")); ok {
+		filters = arr.Elements()
+	}
+	for _, filter := range filters {
+		if name, ok := core.GetNameVal(filter); ok {
+			if _, ok := imageEncodingFilters[name]; ok {
+				return true
+			}
 		}
 	}
+	return false
+}
+
+// hashStreamDict returns the hash of the entries of `dict` except the `skip` keys, in the order of
+// their keys, followed by the hash of the stream data `dataHash`.
+func hashStreamDict(dict *core.PdfObjectDictionary, skip map[core.PdfObjectName]struct{}, dataHash []byte) string {
+	keys := append([]core.PdfObjectName{}, dict.Keys()...)
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
 
-	optimizedObjects = make([]core.PdfObject, 0, len(objects)-len(toDelete))
-	for _, obj := range objects {
-		if _, found := toDelete[obj]; found {
+	hasher := md5.New()
+	for _, key := range keys {
+		if _, ok := skip[key]; ok {
 			continue
 		}
-		optimizedObjects = append(optimizedObjects, obj)
+		hasher.Write([]byte(key.WriteString()))
+		hasher.Write([]byte{' '})
+		hasher.Write([]byte(dict.Get(key).WriteString()))
+		hasher.Write([]byte{'\n'})
 	}
-	replaceObjectsInPlace(optimizedObjects, replaceTable)
-	return optimizedObjects, nil
+	hasher.Write([]byte("stream\n"))
+	hasher.Write(dataHash)
+	return string(hasher.Sum(nil))
 }
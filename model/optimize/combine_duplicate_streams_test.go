@@ -0,0 +1,272 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package optimize_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/core/security"
+	"github.com/unidoc/unipdf/v3/model"
+	"github.com/unidoc/unipdf/v3/model/optimize"
+)
+
+func TestCombineDuplicateStreamsDecoded(t *testing.T) {
+	const numPages = 20
+
+	// Each page embeds its own copy of the logo, the font and a form XObject using the font. The
+	// logo of every other page is encoded differently.
+	makeDoc := func(optimizer model.Optimizer, encrypt bool) []byte {
+		f, err := os.Open("../testdata/cmyk_rgb.png")
+		require.NoError(t, err)
+		defer f.Close()
+		logo, err := model.ImageHandling.Read(f)
+		require.NoError(t, err)
+
+		w := model.NewPdfWriter()
+		for i := 0; i < numPages; i++ {
+			page := model.NewPdfPage()
+
+			var encoder core.StreamEncoder = core.NewFlateEncoder()
+			if i%2 == 1 {
+				encoder = core.NewRawEncoder()
+			}
+			ximg, err := model.NewXObjectImageFromImage(logo, nil, encoder)
+			require.NoError(t, err)
+			require.NoError(t, page.Resources.SetXObjectImageByName("Logo", ximg))
+
+			font, err := model.NewPdfFontFromTTFFile("../testdata/font/OpenSans-Regular.ttf")
+			require.NoError(t, err)
+			form := model.NewXObjectForm()
+			form.Resources = model.NewPdfPageResources()
+			require.NoError(t, form.Resources.SetFontByName("F1", font.ToPdfObject()))
+			form.BBox = core.MakeArrayFromFloats([]float64{0, 0, 200, 20})
+			require.NoError(t, form.SetContentStream([]byte("BT /F1 12 Tf (Report) Tj ET"), core.NewFlateEncoder()))
+			require.NoError(t, page.Resources.SetXObjectFormByName("Header", form))
+
+			page.AddContentStreamByString("q 100 0 0 50 10 10 cm /Logo Do Q /Header Do")
+			require.NoError(t, w.AddPage(page))
+		}
+		if encrypt {
+			require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), &model.EncryptOptions{
+				Permissions: security.PermOwner,
+				Algorithm:   model.AES_128bit,
+			}))
+		}
+		w.SetOptimizer(optimizer)
+
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+		return buf.Bytes()
+	}
+
+	// countObjects returns the number of streams with each Subtype, of the fonts with each
+	// BaseFont and of the font descriptors in `data`.
+	countObjects := func(data []byte, encrypted bool) map[string]int {
+		r, err := model.NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		if encrypted {
+			ok, err := r.Decrypt([]byte("user"))
+			require.NoError(t, err)
+			require.True(t, ok)
+		}
+		n, err := r.GetNumPages()
+		require.NoError(t, err)
+		require.Equal(t, numPages, n)
+		for _, page := range r.PageList {
+			ximg, err := page.Resources.GetXObjectImageByName("Logo")
+			require.NoError(t, err)
+			img, err := ximg.ToImage()
+			require.NoError(t, err)
+			require.NotEmpty(t, img.Data)
+		}
+
+		counts := map[string]int{}
+		for _, num := range r.GetObjectNums() {
+			obj, err := r.GetIndirectObjectByNumber(num)
+			require.NoError(t, err)
+			if stream, ok := obj.(*core.PdfObjectStream); ok {
+				if subtype, ok := core.GetNameVal(stream.Get("Subtype")); ok {
+					counts[subtype]++
+				} else if stream.Get("Length1") != nil {
+					counts["FontFile2"]++
+				}
+				continue
+			}
+			if dict, ok := core.GetDict(obj); ok {
+				switch typ, _ := core.GetNameVal(dict.Get("Type")); typ {
+				case "Font":
+					baseFont, _ := core.GetNameVal(dict.Get("BaseFont"))
+					counts[baseFont]++
+				case "FontDescriptor":
+					counts[typ]++
+				}
+			}
+		}
+		return counts
+	}
+
+	for _, encrypted := range []bool{false, true} {
+		counts := countObjects(makeDoc(nil, encrypted), encrypted)
+		require.Equal(t, numPages, counts["Image"])
+		require.Equal(t, numPages, counts["Form"])
+		require.Equal(t, numPages, counts["FontFile2"])
+		require.Equal(t, numPages, counts["FontDescriptor"])
+		require.Equal(t, numPages, counts["OpenSans-Regular"])
+
+		opt := &optimize.CombineDuplicateStreams{CompareDecoded: true, CombineIndirectObjects: true}
+		data := makeDoc(opt, encrypted)
+		counts = countObjects(data, encrypted)
+		require.Equal(t, 1, counts["Image"])
+		require.Equal(t, 1, counts["Form"])
+		require.Equal(t, 1, counts["FontFile2"])
+		require.Equal(t, 1, counts["FontDescriptor"])
+		require.Equal(t, 1, counts["OpenSans-Regular"])
+
+		stats := opt.Stats()
+		// Images, forms, font files and the streams the writer adds to unlicensed pages.
+		require.True(t, stats.Streams >= 4*(numPages-1), "%d", stats.Streams)
+		require.True(t, stats.Objects >= 2*(numPages-1), "%d", stats.Objects)
+		require.True(t, stats.Bytes > 0)
+
+		// Through the optimization options.
+		opts := optimize.New(optimize.Options{DeduplicateStreams: true, CompressStreams: true})
+		counts = countObjects(makeDoc(opts, encrypted), encrypted)
+		require.Equal(t, 1, counts["Image"])
+		require.Equal(t, 1, counts["FontDescriptor"])
+	}
+}
+
+func TestCombineDuplicateStreamsDictionaries(t *testing.T) {
+	rawpdf := `
+1 0 obj
+<< /Length 4 /Subtype /Image /Width 1 /Height 1 >>
+stream
+abcd
+endstream
+endobj
+2 0 obj
+<< /Height 1 /Width 1 /Subtype /Image /Length 4 >>
+stream
+abcd
+endstream
+endobj
+3 0 obj
+<< /Length 4 /Subtype /Image /Width 2 /Height 1 >>
+stream
+abcd
+endstream
+endobj
+4 0 obj
+<< /Length 12 /Filter /ASCIIHexDecode /Subtype /Image /Width 1 /Height 1 >>
+stream
+61 62 63 64>
+endstream
+endobj
+5 0 obj
+<< /Length 4 /Filter /DCTDecode /Subtype /Image /Width 1 /Height 1 >>
+stream
+abcd
+endstream
+endobj
+`
+	objects, err := parseIndirectObjects(rawpdf)
+	require.NoError(t, err)
+	require.Len(t, objects, 5)
+	xobjects := core.MakeDict()
+	for i, obj := range objects {
+		xobjects.Set(core.PdfObjectName(fmt.Sprintf("Im%d", i+1)), obj)
+	}
+	resources := core.MakeDict()
+	resources.Set("XObject", xobjects)
+	objects = append(objects, core.MakeIndirectObject(resources))
+
+	// By default, the streams with the same encoded data are combined.
+	opt := &optimize.CombineDuplicateStreams{}
+	optObjects, err := opt.Optimize(append([]core.PdfObject{}, objects...))
+	require.NoError(t, err)
+	require.Len(t, optObjects, 3)
+	require.Equal(t, 3, opt.Stats().Streams)
+	require.Equal(t, objects[0], xobjects.Get("Im5"))
+	require.Equal(t, objects[3], xobjects.Get("Im4"))
+	for i, obj := range objects {
+		xobjects.Set(core.PdfObjectName(fmt.Sprintf("Im%d", i+1)), obj)
+	}
+
+	// Streams 2 and 4 are identical to 1 once decoded, 3 has a different width and the data of
+	// 5 is compared encoded.
+	opt = &optimize.CombineDuplicateStreams{CompareDecoded: true}
+	optObjects, err = opt.Optimize(objects)
+	require.NoError(t, err)
+	require.Len(t, optObjects, 4)
+	require.Equal(t, optimize.CombineStats{Streams: 2, Bytes: int64(len(
+		objects[1].(*core.PdfObjectStream).PdfObjectDictionary.WriteString()) + 4 + len(
+		objects[3].(*core.PdfObjectStream).PdfObjectDictionary.WriteString()) + 12)}, opt.Stats())
+
+	require.Equal(t, objects[0], xobjects.Get("Im1"))
+	require.Equal(t, objects[0], xobjects.Get("Im2"))
+	require.Equal(t, objects[2], xobjects.Get("Im3"))
+	require.Equal(t, objects[0], xobjects.Get("Im4"))
+	require.Equal(t, objects[4], xobjects.Get("Im5"))
+
+	// The order of the dictionary entries is preserved.
+	require.Equal(t, []core.PdfObjectName{"Height", "Width", "Subtype", "Length"},
+		objects[1].(*core.PdfObjectStream).Keys())
+}
+
+func TestCombineIdenticalIndirectObjectsObjectStreams(t *testing.T) {
+	rawpdf := `
+1 0 obj
+<< /Type /FontDescriptor /FontName /Foo >>
+endobj
+2 0 obj
+<< /Type /FontDescriptor /FontName /Foo >>
+endobj
+3 0 obj
+[500 600]
+endobj
+4 0 obj
+<< /Type /FontDescriptor /FontName /Bar >>
+endobj
+5 0 obj
+[500 600]
+endobj
+`
+	objects, err := parseIndirectObjects(rawpdf)
+	require.NoError(t, err)
+	require.Len(t, objects, 5)
+	var fonts []core.PdfObject
+	for _, i := range []int{0, 1} {
+		font := core.MakeDict()
+		font.Set("Type", core.MakeName("Font"))
+		font.Set("FontDescriptor", objects[i])
+		font.Set("Widths", objects[2+2*i])
+		fonts = append(fonts, core.MakeIndirectObject(font))
+	}
+	objects = append(objects, fonts...)
+
+	// The objects are packed in object streams, as by the ObjectStreams optimizer.
+	objects, err = (&optimize.ObjectStreams{}).Optimize(objects)
+	require.NoError(t, err)
+	objStm, ok := objects[0].(*core.PdfObjectStreams)
+	require.True(t, ok)
+	require.Equal(t, 7, objStm.Len())
+
+	// The second font descriptor, widths and font are merged.
+	opt := &optimize.CombineIdenticalIndirectObjects{}
+	optObjects, err := opt.Optimize(objects)
+	require.NoError(t, err)
+	require.Equal(t, 3, opt.Stats().Objects)
+	require.Len(t, optObjects, 5)
+	objStm, ok = optObjects[0].(*core.PdfObjectStreams)
+	require.True(t, ok)
+	require.Equal(t, optObjects[1:], objStm.Elements())
+}
@@ -12,54 +12,46 @@ import (
 )
 
 // CombineIdenticalIndirectObjects combines identical indirect objects.
+// The indirect dictionaries, except the pages, and the indirect arrays (e.g. the widths of fonts)
+// are combined, until no more objects are identical.
 // It implements interface model.Optimizer.
 type CombineIdenticalIndirectObjects struct {
+	stats CombineStats
+}
+
+// Stats returns the objects removed by the last optimization.
+func (c *CombineIdenticalIndirectObjects) Stats() CombineStats {
+	return c.stats
 }
 
 // Optimize optimizes PDF objects to decrease PDF size.
 func (c *CombineIdenticalIndirectObjects) Optimize(objects []core.PdfObject) (optimizedObjects []core.PdfObject, err error) {
-	updateObjectNumbers(objects)
-	replaceTable := make(map[core.PdfObject]core.PdfObject)
-	toDelete := make(map[core.PdfObject]struct{})
-
-	indWithDictByHash := make(map[string][]*core.PdfIndirectObject)
-
-	for _, obj := range objects {
-		ind, isIndirectObj := obj.(*core.PdfIndirectObject)
-		if !isIndirectObj {
-			continue
-		}
-		if dict, isDictObj := ind.PdfObject.(*core.PdfObjectDictionary); isDictObj {
-			if name, isName := dict.Get("Type").(*core.PdfObjectName); isName && *name == "Page" {
-				continue
-			}
-			hasher := md5.New()
-			hasher.Write([]byte(dict.WriteString()))
+	c.stats = CombineStats{}
+	return combineObjects(objects, indirectObjectKey, &c.stats), nil
+}
 
-			hash := string(hasher.Sum(nil))
-			indWithDictByHash[hash] = append(indWithDictByHash[hash], ind)
-		}
+// indirectObjectKey returns the key of the indirect object `obj` compared by the
+// CombineIdenticalIndirectObjects optimizer, or an empty key if it is not combined.
+func indirectObjectKey(obj core.PdfObject) string {
+	ind, isIndirectObj := obj.(*core.PdfIndirectObject)
+	if !isIndirectObj {
+		return ""
 	}
 
-	for _, dicts := range indWithDictByHash {
-		if len(dicts) < 2 {
-			continue
-		}
-		firstDict := dicts[0]
-		for i := 1; i < len(dicts); i++ {
-			dict := dicts[i]
-			replaceTable[dict] = firstDict
-			toDelete[dict] = struct{}{}
+	var prefix string
+	switch t := ind.PdfObject.(type) {
+	case *core.PdfObjectDictionary:
+		if name, isName := t.Get("Type").(*core.PdfObjectName); isName && *name == "Page" {
+			return ""
 		}
+		prefix = "d"
+	case *core.PdfObjectArray:
+		prefix = "a"
+	default:
+		return ""
 	}
 
-	optimizedObjects = make([]core.PdfObject, 0, len(objects)-len(toDelete))
-	for _, obj := range objects {
-		if _, found := toDelete[obj]; found {
-			continue
-		}
-		optimizedObjects = append(optimizedObjects, obj)
-	}
-	replaceObjectsInPlace(optimizedObjects, replaceTable)
-	return optimizedObjects, nil
+	hasher := md5.New()
+	hasher.Write([]byte(ind.PdfObject.WriteString()))
+	return prefix + string(hasher.Sum(nil))
 }
@@ -6,6 +6,9 @@
 package optimize
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/unidoc/unipdf/v3/core"
 )
 
@@ -16,9 +19,18 @@ type CompressStreams struct {
 
 // Optimize optimizes PDF objects to decrease PDF size.
 func (c *CompressStreams) Optimize(objects []core.PdfObject) (optimizedObjects []core.PdfObject, err error) {
+	return c.OptimizeWithContext(context.Background(), objects)
+}
+
+// OptimizeWithContext optimizes PDF objects as Optimize, checking `ctx` before
+// compressing each stream.
+func (c *CompressStreams) OptimizeWithContext(ctx context.Context, objects []core.PdfObject) (optimizedObjects []core.PdfObject, err error) {
 	optimizedObjects = make([]core.PdfObject, len(objects))
 	copy(optimizedObjects, objects)
 	for _, obj := range objects {
+		if err := ctx.Err(); err != nil {
+			return optimizedObjects, fmt.Errorf("stream compression cancelled: %w", err)
+		}
 		stream, isStreamObj := core.GetStream(obj)
 		if !isStreamObj {
 			continue
@@ -6,6 +6,9 @@
 package optimize
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
 	"github.com/unidoc/unipdf/v3/model"
@@ -80,6 +83,12 @@ func findImages(objects []core.PdfObject) []*imageInfo {
 
 // Optimize optimizes PDF objects to decrease PDF size.
 func (i *Image) Optimize(objects []core.PdfObject) (optimizedObjects []core.PdfObject, err error) {
+	return i.OptimizeWithContext(context.Background(), objects)
+}
+
+// OptimizeWithContext optimizes PDF objects as Optimize, checking `ctx` before
+// encoding each image. The objects are left unchanged if `ctx` is done.
+func (i *Image) OptimizeWithContext(ctx context.Context, objects []core.PdfObject) (optimizedObjects []core.PdfObject, err error) {
 	if i.ImageQuality <= 0 {
 		return objects, nil
 	}
@@ -96,6 +105,9 @@ func (i *Image) Optimize(objects []core.PdfObject) (optimizedObjects []core.PdfO
 	}
 
 	for index, img := range images {
+		if err := ctx.Err(); err != nil {
+			return objects, fmt.Errorf("image optimization cancelled: %w", err)
+		}
 		stream := img.Stream
 		if _, isMask := imageMasks[stream]; isMask {
 			continue
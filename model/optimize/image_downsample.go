@@ -0,0 +1,507 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package optimize
+
+import (
+	"errors"
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// DefaultDownsampleQuality is the quality of the photos encoded by the DownsampleImages optimizer
+// when its Quality is not set.
+const DefaultDownsampleQuality = 75
+
+// DownsampleImages reduces the size of the image XObjects. The images drawn with a higher effective
+// resolution than TargetDPI are downsampled with bicubic interpolation, and the images are
+// recompressed: the 8 bit gray and RGB images with DCT encoding, the bilevel images with CCITT
+// group 4 encoding, and the other images with Flate encoding. The soft masks of the downsampled
+// images are downsampled to the same dimensions.
+//
+// The effective resolution of an image is computed from the transforms it is drawn with in the
+// content streams of the pages and of the form XObjects they draw. An image drawn at several
+// scales is sized for its largest use. The images which are not drawn on the pages, the images in
+// indexed color spaces and the images with 2, 4 or 16 bits per component are not downsampled.
+// The images which are not downsampled are recompressed only if it makes them smaller and they
+// are not already encoded with a lossy or bilevel encoding. The inline images of the content
+// streams are left as is.
+// It implements interface model.Optimizer.
+type DownsampleImages struct {
+	// TargetDPI is the maximum effective resolution of the images, in pixels per inch. The images
+	// are only recompressed if it is 0.
+	TargetDPI float64
+
+	// Quality is the quality, 1-100, of the DCT encoded images, DefaultDownsampleQuality if 0.
+	Quality int
+
+	// BilevelFlate selects Flate encoding rather than CCITT group 4 encoding for the bilevel
+	// images.
+	BilevelFlate bool
+
+	stats []ImageStats
+}
+
+// ImageStats represents an image processed by the DownsampleImages optimizer.
+type ImageStats struct {
+	// ObjectNumber is the object number of the image stream when it is optimized.
+	ObjectNumber int64
+
+	// DPI is the effective resolution of the image at its largest use, 0 if it is not drawn.
+	DPI float64
+
+	// Width and Height are the dimensions of the image before optimization, NewWidth and
+	// NewHeight after optimization.
+	Width, Height       int
+	NewWidth, NewHeight int
+
+	// Filter is the name of the encoding filter of the image after optimization.
+	Filter string
+
+	// Bytes is the size of the encoded data of the image and of its soft mask before optimization,
+	// NewBytes after optimization.
+	Bytes, NewBytes int
+}
+
+// Stats returns the images processed by the last optimization, in the order of the objects.
+func (d *DownsampleImages) Stats() []ImageStats {
+	return d.stats
+}
+
+// Optimize optimizes PDF objects to decrease PDF size.
+func (d *DownsampleImages) Optimize(objects []core.PdfObject) (optimizedObjects []core.PdfObject, err error) {
+	d.stats = nil
+	updateObjectNumbers(objects)
+	resolutions := findImageResolutions(objects)
+
+	// The soft masks are downsampled with their images.
+	masks := make(map[*core.PdfObjectStream]struct{})
+	for _, obj := range objects {
+		if stream, ok := obj.(*core.PdfObjectStream); ok && isImageStream(stream) {
+			if mask, ok := core.GetStream(stream.Get("SMask")); ok {
+				masks[mask] = struct{}{}
+			}
+		}
+	}
+
+	for _, obj := range objects {
+		stream, ok := obj.(*core.PdfObjectStream)
+		if !ok || !isImageStream(stream) {
+			continue
+		}
+		if _, ok := masks[stream]; ok {
+			continue
+		}
+		stats, err := d.optimizeImage(stream, resolutions[stream])
+		if err != nil {
+			common.Log.Debug("Image %d not optimized: %v", stream.ObjectNumber, err)
+			continue
+		}
+		d.stats = append(d.stats, stats)
+	}
+	return objects, nil
+}
+
+// optimizeImage downsamples and recompresses image `stream`, drawn with effective resolution
+// `dpi`, and its soft mask.
+func (d *DownsampleImages) optimizeImage(stream *core.PdfObjectStream, dpi float64) (ImageStats, error) {
+	ximg, err := model.NewXObjectImageFromStream(stream)
+	if err != nil {
+		return ImageStats{}, err
+	}
+	isMask, _ := core.GetBoolVal(ximg.ImageMask)
+	if isMask && ximg.BitsPerComponent == nil {
+		bpc := int64(1)
+		ximg.BitsPerComponent = &bpc
+	}
+	img, err := ximg.ToImage()
+	if err != nil {
+		return ImageStats{}, err
+	}
+	mask, _ := core.GetStream(stream.Get("SMask"))
+
+	w, h := int(img.Width), int(img.Height)
+	stats := ImageStats{
+		ObjectNumber: stream.ObjectNumber,
+		DPI:          dpi,
+		Width:        w,
+		Height:       h,
+		NewWidth:     w,
+		NewHeight:    h,
+		Filter:       ximg.Filter.GetFilterName(),
+		Bytes:        len(stream.Stream),
+	}
+	if mask != nil {
+		stats.Bytes += len(mask.Stream)
+	}
+	stats.NewBytes = stats.Bytes
+
+	bilevel := img.ColorComponents == 1 && img.BitsPerComponent == 1
+	_, indexed := ximg.ColorSpace.(*model.PdfColorspaceSpecialIndexed)
+	newW, newH := w, h
+	if d.TargetDPI > 0 && dpi > d.TargetDPI && !indexed && (bilevel || img.BitsPerComponent == 8) {
+		scale := d.TargetDPI / dpi
+		newW = int(math.Max(1, math.Round(float64(w)*scale)))
+		newH = int(math.Max(1, math.Round(float64(h)*scale)))
+	}
+	downsampled := newW != w || newH != h
+	if !downsampled {
+		switch ximg.Filter.(type) {
+		case *core.DCTEncoder, *core.JPXEncoder, *core.JBIG2Encoder, *core.CCITTFaxEncoder:
+			return stats, nil
+		}
+	}
+
+	data := img.Data
+	if downsampled {
+		if bilevel {
+			data, err = resampleBilevel(data, w, h, newW, newH)
+		} else {
+			data, err = resample(data, w, h, img.ColorComponents, newW, newH)
+		}
+		if err != nil {
+			return ImageStats{}, err
+		}
+	}
+
+	var encoder core.StreamEncoder
+	switch {
+	case bilevel && !d.BilevelFlate:
+		ccitt := core.NewCCITTFaxEncoder()
+		ccitt.K = -1
+		ccitt.Columns = newW
+		ccitt.Rows = newH
+		ccitt.BitsPerComponent = 1
+		encoder = ccitt
+	case bilevel:
+		encoder = core.NewFlateEncoder()
+	case img.BitsPerComponent == 8 && (img.ColorComponents == 1 || img.ColorComponents == 3):
+		dct := core.NewDCTEncoder()
+		dct.Width = newW
+		dct.Height = newH
+		dct.ColorComponents = img.ColorComponents
+		dct.BitsPerComponent = 8
+		dct.Quality = d.Quality
+		if dct.Quality <= 0 {
+			dct.Quality = DefaultDownsampleQuality
+		}
+		encoder = dct
+	default:
+		encoder = newImageFlateEncoder(img.ColorComponents, int(img.BitsPerComponent), newW)
+	}
+	encoded, err := encoder.EncodeBytes(data)
+	if err != nil {
+		return ImageStats{}, err
+	}
+	if !downsampled && len(encoded) >= len(stream.Stream) {
+		return stats, nil
+	}
+	setImageStream(stream, encoder, encoded, newW, newH)
+	stats.NewWidth, stats.NewHeight = newW, newH
+	stats.Filter = encoder.GetFilterName()
+	stats.NewBytes = len(encoded)
+
+	if mask != nil {
+		if downsampled {
+			if err := downsampleMask(mask, w, h, newW, newH); err != nil {
+				common.Log.Debug("Soft mask of image %d not downsampled: %v", stream.ObjectNumber, err)
+			}
+		}
+		stats.NewBytes += len(mask.Stream)
+	}
+	return stats, nil
+}
+
+// downsampleMask downsamples soft mask `mask` of an image downsampled from `w`x`h` to
+// `newW`x`newH`. The soft masks with other dimensions than their images are left unchanged.
+func downsampleMask(mask *core.PdfObjectStream, w, h, newW, newH int) error {
+	ximg, err := model.NewXObjectImageFromStream(mask)
+	if err != nil {
+		return err
+	}
+	img, err := ximg.ToImage()
+	if err != nil {
+		return err
+	}
+	if int(img.Width) != w || int(img.Height) != h {
+		return nil
+	}
+	if img.ColorComponents != 1 || img.BitsPerComponent != 8 {
+		return errors.New("unsupported soft mask format")
+	}
+	data, err := resample(img.Data, w, h, 1, newW, newH)
+	if err != nil {
+		return err
+	}
+	encoder := newImageFlateEncoder(1, 8, newW)
+	encoded, err := encoder.EncodeBytes(data)
+	if err != nil {
+		return err
+	}
+	setImageStream(mask, encoder, encoded, newW, newH)
+	return nil
+}
+
+// newImageFlateEncoder returns a Flate encoder for images with `colors` components of `bpc` bits
+// and `columns` pixels per row, using the PNG predictors for 8 bit images.
+func newImageFlateEncoder(colors, bpc, columns int) *core.FlateEncoder {
+	encoder := core.NewFlateEncoder()
+	if bpc == 8 {
+		encoder.Predictor = 15
+		encoder.Colors = colors
+		encoder.BitsPerComponent = bpc
+		encoder.Columns = columns
+	}
+	return encoder
+}
+
+// setImageStream sets the data of image `stream` to `encoded`, encoded by `encoder`, with
+// dimensions `w`x`h`.
+func setImageStream(stream *core.PdfObjectStream, encoder core.StreamEncoder, encoded []byte, w, h int) {
+	dict := stream.PdfObjectDictionary
+	for _, key := range []core.PdfObjectName{"Filter", "DecodeParms", "DL", "SMaskInData"} {
+		dict.Remove(key)
+	}
+	dict.Merge(encoder.MakeStreamDict())
+	dict.Set("Width", core.MakeInteger(int64(w)))
+	dict.Set("Height", core.MakeInteger(int64(h)))
+	dict.Set("Length", core.MakeInteger(int64(len(encoded))))
+	stream.Stream = encoded
+}
+
+// resample resamples the 8 bit samples `data`, with `n` components, of a `w`x`h` image to
+// `newW`x`newH` samples with bicubic interpolation.
+func resample(data []byte, w, h, n, newW, newH int) ([]byte, error) {
+	if len(data) < w*h*n {
+		return nil, errors.New("image data too short")
+	}
+	src := image.NewGray(image.Rect(0, 0, w, h))
+	dst := image.NewGray(image.Rect(0, 0, newW, newH))
+	resampled := make([]byte, newW*newH*n)
+	// The components are resampled separately, as the Go color models would convert them.
+	for c := 0; c < n; c++ {
+		for i := range src.Pix {
+			src.Pix[i] = data[i*n+c]
+		}
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+		for i, v := range dst.Pix {
+			resampled[i*n+c] = v
+		}
+	}
+	return resampled, nil
+}
+
+// resampleBilevel resamples the 1 bit samples `data` of a `w`x`h` image to `newW`x`newH` samples,
+// thresholding the samples interpolated with bicubic interpolation.
+func resampleBilevel(data []byte, w, h, newW, newH int) ([]byte, error) {
+	rowSize := (w + 7) / 8
+	if len(data) < rowSize*h {
+		return nil, errors.New("image data too short")
+	}
+	gray := make([]byte, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if data[y*rowSize+x/8]>>uint(7-x%8)&1 != 0 {
+				gray[y*w+x] = 255
+			}
+		}
+	}
+	gray, err := resample(gray, w, h, 1, newW, newH)
+	if err != nil {
+		return nil, err
+	}
+
+	newRowSize := (newW + 7) / 8
+	resampled := make([]byte, newRowSize*newH)
+	for y := 0; y < newH; y++ {
+		for x := 0; x < newW; x++ {
+			if gray[y*newW+x] >= 128 {
+				resampled[y*newRowSize+x/8] |= 1 << uint(7-x%8)
+			}
+		}
+	}
+	return resampled, nil
+}
+
+// isImageStream returns true if `stream` is an image XObject.
+func isImageStream(stream *core.PdfObjectStream) bool {
+	subtype, ok := core.GetName(stream.Get("Subtype"))
+	return ok && *subtype == "Image"
+}
+
+// findImageResolutions returns the effective resolutions, in pixels per inch, of the image
+// XObjects drawn on the pages of `objects` at their largest use.
+func findImageResolutions(objects []core.PdfObject) map[*core.PdfObjectStream]float64 {
+	finder := &resolutionFinder{
+		resolutions: make(map[*core.PdfObjectStream]float64),
+		visited:     make(map[*core.PdfObjectDictionary]struct{}),
+		forms:       make(map[*core.PdfObjectStream]struct{}),
+	}
+	for _, obj := range objects {
+		dict, ok := core.GetDict(obj)
+		if !ok {
+			continue
+		}
+		if name, ok := core.GetName(dict.Get("Type")); ok && *name == "Catalog" {
+			if pages, ok := core.GetDict(dict.Get("Pages")); ok {
+				finder.processPages(pages, nil)
+			}
+			break
+		}
+	}
+	return finder.resolutions
+}
+
+// resolutionFinder finds the effective resolutions of the images drawn by content streams.
+type resolutionFinder struct {
+	resolutions map[*core.PdfObjectStream]float64
+
+	// Page tree nodes processed and form XObjects being processed, to avoid loops.
+	visited map[*core.PdfObjectDictionary]struct{}
+	forms   map[*core.PdfObjectStream]struct{}
+}
+
+// processPages processes the pages of page tree `node`, whose inherited resources are `resources`.
+func (f *resolutionFinder) processPages(node, resources *core.PdfObjectDictionary) {
+	if _, ok := f.visited[node]; ok {
+		return
+	}
+	f.visited[node] = struct{}{}
+	if dict, ok := core.GetDict(node.Get("Resources")); ok {
+		resources = dict
+	}
+
+	if kids, ok := core.GetArray(node.Get("Kids")); ok {
+		for _, kid := range kids.Elements() {
+			if dict, ok := core.GetDict(kid); ok {
+				f.processPages(dict, resources)
+			}
+		}
+		return
+	}
+
+	var streams []core.PdfObject
+	switch t := core.TraceToDirectObject(node.Get("Contents")).(type) {
+	case *core.PdfObjectStream:
+		streams = []core.PdfObject{t}
+	case *core.PdfObjectArray:
+		streams = t.Elements()
+	}
+	var content []byte
+	for _, obj := range streams {
+		stream, ok := core.GetStream(obj)
+		if !ok {
+			continue
+		}
+		data, err := core.DecodeStream(stream)
+		if err != nil {
+			common.Log.Debug("Error decoding content stream: %v", err)
+			continue
+		}
+		content = append(content, data...)
+		content = append(content, '\n')
+	}
+	f.processContent(string(content), resources, transform.IdentityMatrix())
+}
+
+// processContent processes content stream `content` with resources `resources`, drawn with
+// transform `ctm`.
+func (f *resolutionFinder) processContent(content string, resources *core.PdfObjectDictionary,
+	ctm transform.Matrix) {
+	ops, err := contentstream.NewContentStreamParser(content).Parse()
+	if err != nil {
+		common.Log.Debug("Error parsing content stream: %v", err)
+		return
+	}
+	pdfResources := model.NewPdfPageResources()
+	if resources != nil {
+		if pdfResources, err = model.NewPdfPageResourcesFromDict(resources); err != nil {
+			common.Log.Debug("Error loading resources: %v", err)
+			return
+		}
+	}
+	xobjects, _ := core.GetDict(pdfResources.XObject)
+
+	proc := contentstream.NewContentStreamProcessor(*ops)
+	proc.AddHandler(contentstream.HandlerConditionEnumOperand, "Do",
+		func(op *contentstream.ContentStreamOperation, gs contentstream.GraphicsState,
+			_ *model.PdfPageResources) error {
+			if len(op.Params) != 1 || xobjects == nil {
+				return nil
+			}
+			name, ok := core.GetName(op.Params[0])
+			if !ok {
+				return nil
+			}
+			stream, ok := core.GetStream(xobjects.Get(*name))
+			if !ok {
+				return nil
+			}
+			m := ctm.Mult(gs.CTM)
+			subtype, _ := core.GetName(stream.Get("Subtype"))
+			if subtype == nil {
+				return nil
+			}
+			switch *subtype {
+			case "Image":
+				f.addImage(stream, m)
+			case "Form":
+				f.processForm(stream, resources, m)
+			}
+			return nil
+		})
+	if err := proc.Process(pdfResources); err != nil {
+		common.Log.Debug("Error processing content stream: %v", err)
+	}
+}
+
+// processForm processes form XObject `form`, drawn with transform `ctm` by a content stream with
+// resources `resources`.
+func (f *resolutionFinder) processForm(form *core.PdfObjectStream, resources *core.PdfObjectDictionary,
+	ctm transform.Matrix) {
+	if _, ok := f.forms[form]; ok {
+		return
+	}
+	f.forms[form] = struct{}{}
+	defer delete(f.forms, form)
+
+	data, err := core.DecodeStream(form)
+	if err != nil {
+		common.Log.Debug("Error decoding form: %v", err)
+		return
+	}
+	if matrix, ok := core.GetArray(form.Get("Matrix")); ok {
+		if vals, err := matrix.ToFloat64Array(); err == nil && len(vals) == 6 {
+			ctm = ctm.Mult(transform.NewMatrix(vals[0], vals[1], vals[2], vals[3], vals[4], vals[5]))
+		}
+	}
+	if dict, ok := core.GetDict(form.Get("Resources")); ok {
+		resources = dict
+	}
+	f.processContent(string(data), resources, ctm)
+}
+
+// addImage records a use of image `stream` drawn with transform `ctm`.
+func (f *resolutionFinder) addImage(stream *core.PdfObjectStream, ctm transform.Matrix) {
+	w, _ := core.GetNumberAsFloat(stream.Get("Width"))
+	h, _ := core.GetNumberAsFloat(stream.Get("Height"))
+	sx, sy := ctm.ScalingFactorX(), ctm.ScalingFactorY()
+	if w <= 0 || h <= 0 || sx < 1e-6 || sy < 1e-6 {
+		return
+	}
+	// The image is sized for the least dense of its axes.
+	dpi := math.Min(w*72/sx, h*72/sy)
+	if prev, ok := f.resolutions[stream]; !ok || dpi < prev {
+		f.resolutions[stream] = dpi
+	}
+}
@@ -0,0 +1,213 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package optimize_test
+
+import (
+	"bytes"
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+	"github.com/unidoc/unipdf/v3/model/optimize"
+	"github.com/unidoc/unipdf/v3/render"
+)
+
+func TestDownsampleImages(t *testing.T) {
+	// A photo like 600x400 image, drawn at 600 DPI on the page and at 216 DPI by a form scaling it.
+	rnd := rand.New(rand.NewSource(1))
+	photo := &model.Image{Width: 600, Height: 400, BitsPerComponent: 8, ColorComponents: 3}
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 600; x++ {
+			v := 128 + 90*math.Sin(float64(x)/50)*math.Cos(float64(y)/40)
+			photo.Data = append(photo.Data,
+				byte(v+float64(rnd.Intn(4))),
+				byte(v/2+float64(x)/5+float64(rnd.Intn(4))),
+				byte(255-v+float64(rnd.Intn(4))))
+		}
+	}
+
+	// A bilevel 800x800 image with rings, drawn at 300 DPI.
+	bilevel := &model.Image{Width: 800, Height: 800, BitsPerComponent: 1, ColorComponents: 1}
+	bilevel.Data = make([]byte, 100*800)
+	for y := 0; y < 800; y++ {
+		for x := 0; x < 800; x++ {
+			if int(math.Hypot(float64(x-400), float64(y-400)))/20%2 == 0 {
+				bilevel.Data[y*100+x/8] |= 1 << uint(7-x%8)
+			}
+		}
+	}
+
+	// A gray 300x300 image with a soft mask, drawn at 432 DPI.
+	gray := &model.Image{Width: 300, Height: 300, BitsPerComponent: 8, ColorComponents: 1}
+	alpha := make([]byte, 300*300)
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 300; x++ {
+			gray.Data = append(gray.Data, byte(x*255/300))
+			alpha[y*300+x] = byte(y * 255 / 300)
+		}
+	}
+	gray.SetAlphaData(alpha)
+
+	// An image which is not drawn.
+	unused := &model.Image{Width: 100, Height: 100, BitsPerComponent: 8, ColorComponents: 1,
+		Data: bytes.Repeat([]byte{128}, 100*100)}
+
+	makeDoc := func(optimizer model.Optimizer) []byte {
+		w := model.NewPdfWriter()
+		page := model.NewPdfPage()
+		page.MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+		images := map[string]*model.Image{"Photo": photo, "Bilevel": bilevel, "Gray": gray, "Unused": unused}
+		for name, img := range images {
+			encoder := core.NewFlateEncoder()
+			ximg, err := model.NewXObjectImageFromImage(img, nil, encoder)
+			require.NoError(t, err)
+			require.NoError(t, page.Resources.SetXObjectImageByName(core.PdfObjectName(name), ximg))
+		}
+
+		form := model.NewXObjectForm()
+		form.Resources = model.NewPdfPageResources()
+		photoObj, _ := page.Resources.GetXObjectByName("Photo")
+		form.Resources.SetXObjectByName("Photo", photoObj)
+		form.BBox = core.MakeArrayFromFloats([]float64{0, 0, 100, 100})
+		form.Matrix = core.MakeArrayFromFloats([]float64{2, 0, 0, 2, 0, 0})
+		require.NoError(t, form.SetContentStream([]byte("q 100 0 0 66.6667 0 0 cm /Photo Do Q"), nil))
+		require.NoError(t, page.Resources.SetXObjectFormByName("Large", form))
+
+		page.AddContentStreamByString(`
+			q 72 0 0 48 50 700 cm /Photo Do Q
+			q 1 0 0 1 50 450 cm /Large Do Q
+			q 0 192 -192 0 500 100 cm /Bilevel Do Q
+			q 50 0 0 50 300 600 cm /Gray Do Q
+		`)
+		require.NoError(t, w.AddPage(page))
+		w.SetOptimizer(optimizer)
+
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+		return buf.Bytes()
+	}
+	readPage := func(data []byte) *model.PdfPage {
+		r, err := model.NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		page, err := r.GetPage(1)
+		require.NoError(t, err)
+		return page
+	}
+
+	original := makeDoc(nil)
+	opt := &optimize.DownsampleImages{TargetDPI: 150}
+	optimized := makeDoc(opt)
+	require.True(t, len(optimized) < len(original)/4, "%d -> %d bytes", len(original), len(optimized))
+
+	page := readPage(optimized)
+	testcases := []struct {
+		name          string
+		width, height int
+		filter        string
+	}{
+		// Sized for the largest use, 200x133 points.
+		{"Photo", 417, 278, core.StreamEncodingFilterNameDCT},
+		{"Bilevel", 400, 400, core.StreamEncodingFilterNameCCITTFax},
+		{"Gray", 104, 104, core.StreamEncodingFilterNameDCT},
+		{"Unused", 100, 100, core.StreamEncodingFilterNameFlate},
+	}
+	for _, tcase := range testcases {
+		ximg, err := page.Resources.GetXObjectImageByName(core.PdfObjectName(tcase.name))
+		require.NoError(t, err)
+		require.Equal(t, int64(tcase.width), *ximg.Width, tcase.name)
+		require.Equal(t, int64(tcase.height), *ximg.Height, tcase.name)
+		require.Equal(t, tcase.filter, ximg.Filter.GetFilterName(), tcase.name)
+		img, err := ximg.ToImage()
+		require.NoError(t, err)
+		require.Len(t, img.Data, tcase.height*((tcase.width*int(img.BitsPerComponent)*img.ColorComponents+7)/8))
+	}
+
+	// The soft mask is downsampled with its image.
+	ximg, err := page.Resources.GetXObjectImageByName("Gray")
+	require.NoError(t, err)
+	smask, ok := core.GetStream(ximg.SMask)
+	require.True(t, ok)
+	require.Equal(t, "104", smask.Get("Width").String())
+	require.Equal(t, "104", smask.Get("Height").String())
+	data, err := core.DecodeStream(smask)
+	require.NoError(t, err)
+	require.Len(t, data, 104*104)
+
+	stats := opt.Stats()
+	require.Len(t, stats, 4)
+	var before, after int
+	for _, s := range stats {
+		require.True(t, s.NewBytes <= s.Bytes, "%+v", s)
+		before += s.Bytes
+		after += s.NewBytes
+		if s.Width == 600 {
+			require.InDelta(t, 216, s.DPI, 0.1)
+			require.Equal(t, 417, s.NewWidth)
+		}
+		if s.Width == 100 {
+			require.Zero(t, s.DPI)
+		}
+	}
+	require.True(t, after < before/5, "%d -> %d bytes", before, after)
+
+	// The optimized page looks the same when rasterized at the target resolution.
+	device := render.NewImageDevice()
+	device.DPI = 150
+	expected, err := device.Render(readPage(original))
+	require.NoError(t, err)
+	actual, err := device.Render(readPage(optimized))
+	require.NoError(t, err)
+	require.Equal(t, expected.Bounds(), actual.Bounds())
+	mean, outliers := compareImages(expected, actual, 40)
+	require.True(t, mean < 2, "mean difference %.2f", mean)
+	require.True(t, outliers < 0.01, "%.4f of the pixels differ", outliers)
+
+	// Through the optimization options, with Flate encoded bilevel images.
+	page = readPage(makeDoc(optimize.New(optimize.Options{DownsampleImagesDPI: 150})))
+	ximg, err = page.Resources.GetXObjectImageByName("Photo")
+	require.NoError(t, err)
+	require.Equal(t, int64(417), *ximg.Width)
+
+	page = readPage(makeDoc(&optimize.DownsampleImages{TargetDPI: 150, BilevelFlate: true}))
+	ximg, err = page.Resources.GetXObjectImageByName("Bilevel")
+	require.NoError(t, err)
+	require.Equal(t, int64(400), *ximg.Width)
+	require.Equal(t, core.StreamEncodingFilterNameFlate, ximg.Filter.GetFilterName())
+}
+
+// compareImages returns the mean absolute difference of the color components of images `a` and
+// `b` and the ratio of their pixels differing by more than `threshold` in a component.
+func compareImages(a, b image.Image, threshold int) (float64, float64) {
+	bounds := a.Bounds()
+	var sum float64
+	var outliers int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r1, g1, b1, _ := a.At(x, y).RGBA()
+			r2, g2, b2, _ := b.At(x, y).RGBA()
+			outlier := false
+			for _, d := range []int{int(r1>>8) - int(r2>>8), int(g1>>8) - int(g2>>8), int(b1>>8) - int(b2>>8)} {
+				if d < 0 {
+					d = -d
+				}
+				sum += float64(d)
+				if d > threshold {
+					outlier = true
+				}
+			}
+			if outlier {
+				outliers++
+			}
+		}
+	}
+	numPixels := float64(bounds.Dx() * bounds.Dy())
+	return sum / (3 * numPixels), float64(outliers) / numPixels
+}
@@ -7,11 +7,14 @@ package optimize_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"testing"
 
 	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
 	"github.com/unidoc/unipdf/v3/model/optimize"
 )
 
@@ -210,3 +213,42 @@ endobj
 		t.Fatalf("len(optObjects) != 6 (%d)", len(optObjects))
 	}
 }
+
+// TestOptimizeWithContext checks that the optimization chain stops when the context is cancelled.
+func TestOptimizeWithContext(t *testing.T) {
+	var objects []core.PdfObject
+	for i := 0; i < 1000; i++ {
+		stream, err := core.MakeStream(bytes.Repeat([]byte("0 0 m 100 100 l S\n"), 100), nil)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		objects = append(objects, stream)
+	}
+
+	chain := new(optimize.Chain)
+	chain.Append(new(optimize.CombineDuplicateDirectObjects), new(optimize.CompressStreams))
+	var optimizer model.ContextOptimizer = chain
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	optObjects, err := optimizer.OptimizeWithContext(ctx, objects)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled. Got %v", err)
+	}
+	if len(optObjects) != len(objects) {
+		t.Fatalf("len(optObjects) != %d (%d)", len(objects), len(optObjects))
+	}
+	for _, obj := range objects {
+		if obj.(*core.PdfObjectStream).Get("Filter") != nil {
+			t.Fatalf("Stream compressed after cancellation")
+		}
+	}
+
+	optObjects, err = optimizer.OptimizeWithContext(context.Background(), objects)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if _, ok := core.GetName(optObjects[0].(*core.PdfObjectStream).Get("Filter")); !ok {
+		t.Fatalf("Stream not compressed")
+	}
+}
@@ -22,10 +22,18 @@ func New(options Options) *Chain {
 		imageOptimizer.ImageQuality = options.ImageQuality
 		chain.Append(imageOptimizer)
 	}
+	if options.DownsampleImagesDPI > 0 || options.DownsampleImagesQuality > 0 {
+		chain.Append(&DownsampleImages{
+			TargetDPI: options.DownsampleImagesDPI,
+			Quality:   options.DownsampleImagesQuality,
+		})
+	}
 	if options.CombineDuplicateDirectObjects {
 		chain.Append(new(CombineDuplicateDirectObjects))
 	}
-	if options.CombineDuplicateStreams {
+	if options.DeduplicateStreams {
+		chain.Append(&CombineDuplicateStreams{CompareDecoded: true, CombineIndirectObjects: true})
+	} else if options.CombineDuplicateStreams {
 		chain.Append(new(CombineDuplicateStreams))
 	}
 	if options.CombineIdenticalIndirectObjects {
@@ -40,6 +48,102 @@ func New(options Options) *Chain {
 	return chain
 }
 
+// CombineStats represents the objects removed by the CombineDuplicateStreams and
+// CombineIdenticalIndirectObjects optimizers.
+type CombineStats struct {
+	// Streams is the number of streams combined with identical streams.
+	Streams int
+
+	// Objects is the number of indirect objects, other than streams, combined with identical
+	// objects.
+	Objects int
+
+	// Bytes is the size of the removed objects, their streams data being encoded.
+	Bytes int64
+}
+
+// combineObjects combines the identical objects of `objects`, which have the same non-empty `key`,
+// replacing the references to the removed objects. The objects are renumbered before computing
+// their keys, so that the keys can include references. As combining objects can make the objects
+// referring to them identical (e.g. the font descriptors of combined font files), the objects are
+// combined until no more objects are identical. The objects packed in object streams are combined
+// as well. The removed objects are counted in `stats`.
+func combineObjects(objects []core.PdfObject, key func(obj core.PdfObject) string, stats *CombineStats) []core.PdfObject {
+	for {
+		updateObjectNumbers(objects)
+		replaceTable := make(map[core.PdfObject]core.PdfObject)
+		byKey := make(map[string]core.PdfObject)
+		visited := make(map[core.PdfObject]struct{})
+
+		var visit func(obj core.PdfObject)
+		visit = func(obj core.PdfObject) {
+			// The objects packed in object streams are also listed in `objects`.
+			if _, ok := visited[obj]; ok {
+				return
+			}
+			visited[obj] = struct{}{}
+
+			if objStm, ok := obj.(*core.PdfObjectStreams); ok {
+				for _, elem := range objStm.Elements() {
+					visit(elem)
+				}
+				return
+			}
+			k := key(obj)
+			if k == "" {
+				return
+			}
+			first, found := byKey[k]
+			if !found {
+				byKey[k] = obj
+				return
+			}
+			replaceTable[obj] = first
+			switch t := obj.(type) {
+			case *core.PdfObjectStream:
+				stats.Streams++
+				stats.Bytes += int64(len(t.PdfObjectDictionary.WriteString()) + len(t.Stream))
+			case *core.PdfIndirectObject:
+				stats.Objects++
+				stats.Bytes += int64(len(t.PdfObject.WriteString()))
+			}
+		}
+		for _, obj := range objects {
+			visit(obj)
+		}
+		if len(replaceTable) == 0 {
+			return objects
+		}
+
+		optimizedObjects := make([]core.PdfObject, 0, len(objects)-len(replaceTable))
+		for _, obj := range objects {
+			if _, found := replaceTable[obj]; found {
+				continue
+			}
+			if objStm, ok := obj.(*core.PdfObjectStreams); ok {
+				// The combined objects are removed from the object streams.
+				var elems []core.PdfObject
+				for _, elem := range objStm.Elements() {
+					if _, found := replaceTable[elem]; !found {
+						elems = append(elems, elem)
+					}
+				}
+				if len(elems) == 0 {
+					continue
+				}
+				if len(elems) < objStm.Len() {
+					packed := core.MakeObjectStreams(elems...)
+					packed.PdfObjectReference = objStm.PdfObjectReference
+					obj = packed
+				}
+			}
+			optimizedObjects = append(optimizedObjects, obj)
+		}
+		replaceObjectsInPlace(optimizedObjects, replaceTable)
+		objects = optimizedObjects
+	}
+}
+
 // replaceObjectsInPlace replaces objects. objTo will be modified by the process.
 func replaceObjectsInPlace(objects []core.PdfObject, objTo map[core.PdfObject]core.PdfObject) {
 	if objTo == nil || len(objTo) == 0 {
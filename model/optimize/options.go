@@ -14,4 +14,7 @@ type Options struct {
 	UseObjectStreams                bool
 	CombineIdenticalIndirectObjects bool
 	CompressStreams                 bool
+	DeduplicateStreams              bool
+	DownsampleImagesDPI             float64
+	DownsampleImagesQuality         int
 }
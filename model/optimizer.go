@@ -6,6 +6,8 @@
 package model
 
 import (
+	"context"
+
 	"github.com/unidoc/unipdf/v3/core"
 )
 
@@ -16,3 +18,14 @@ import (
 type Optimizer interface {
 	Optimize(objects []core.PdfObject) ([]core.PdfObject, error)
 }
+
+// ContextOptimizer is an Optimizer whose optimization can be cancelled with a
+// context. The writer uses OptimizeWithContext for such optimizers when
+// writing with PdfWriter.WriteWithContext.
+//
+// OptimizeWithContext optimizes `objects` as Optimize, returning an error
+// wrapping the error of `ctx` if it is done before the optimization ends.
+type ContextOptimizer interface {
+	Optimizer
+	OptimizeWithContext(ctx context.Context, objects []core.PdfObject) ([]core.PdfObject, error)
+}
@@ -0,0 +1,630 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// PdfOptionalContentGroup represents an optional content group (layer): a
+// collection of graphics that can be made visible or invisible.
+// See section 8.11.2 "Optional Content Groups" (p. 219 PDF32000_2008).
+type PdfOptionalContentGroup struct {
+	Name string
+
+	// Intent of the group: View, Design or custom intents. Defaults to View
+	// if not specified.
+	Intent []string
+	Usage  core.PdfObject
+
+	container *core.PdfIndirectObject
+}
+
+// NewPdfOptionalContentGroup returns a new optional content group with the
+// specified name and intents.
+func NewPdfOptionalContentGroup(name string, intents ...string) *PdfOptionalContentGroup {
+	return &PdfOptionalContentGroup{
+		Name:      name,
+		Intent:    intents,
+		container: core.MakeIndirectObject(core.MakeDict()),
+	}
+}
+
+func newPdfOptionalContentGroupFromIndirect(container *core.PdfIndirectObject) (*PdfOptionalContentGroup, error) {
+	dict, ok := core.GetDict(container)
+	if !ok {
+		return nil, errors.New("optional content group not a dictionary")
+	}
+	ocg := &PdfOptionalContentGroup{container: container}
+	if name, ok := core.GetString(dict.Get("Name")); ok {
+		ocg.Name = name.Decoded()
+	}
+	switch t := core.TraceToDirectObject(dict.Get("Intent")).(type) {
+	case *core.PdfObjectName:
+		ocg.Intent = []string{t.String()}
+	case *core.PdfObjectArray:
+		for _, obj := range t.Elements() {
+			if name, ok := core.GetName(obj); ok {
+				ocg.Intent = append(ocg.Intent, name.String())
+			}
+		}
+	}
+	ocg.Usage = dict.Get("Usage")
+	return ocg, nil
+}
+
+// GetContainingPdfObject implements interface PdfModel.
+func (ocg *PdfOptionalContentGroup) GetContainingPdfObject() core.PdfObject {
+	return ocg.container
+}
+
+// ToPdfObject implements interface PdfModel.
+func (ocg *PdfOptionalContentGroup) ToPdfObject() core.PdfObject {
+	d := ocg.container.PdfObject.(*core.PdfObjectDictionary)
+	d.Set("Type", core.MakeName("OCG"))
+	d.Set("Name", core.MakeEncodedString(ocg.Name, true))
+	switch len(ocg.Intent) {
+	case 0:
+		d.Remove("Intent")
+	case 1:
+		d.Set("Intent", core.MakeName(ocg.Intent[0]))
+	default:
+		intents := core.MakeArray()
+		for _, intent := range ocg.Intent {
+			intents.Append(core.MakeName(intent))
+		}
+		d.Set("Intent", intents)
+	}
+	d.SetIfNotNil("Usage", ocg.Usage)
+	return ocg.container
+}
+
+// isGroup returns true if `obj` refers to the optional content group.
+func (ocg *PdfOptionalContentGroup) isGroup(obj core.PdfObject) bool {
+	return obj != nil && (obj == ocg.container || core.ResolveReference(obj) == ocg.container)
+}
+
+// PdfOCMDVisibilityPolicy specifies the visibility of the content of an
+// optional content membership dictionary, depending on the state of its
+// optional content groups.
+type PdfOCMDVisibilityPolicy string
+
+// Optional content membership visibility policies.
+const (
+	OCMDVisibilityAllOn  PdfOCMDVisibilityPolicy = "AllOn"
+	OCMDVisibilityAnyOn  PdfOCMDVisibilityPolicy = "AnyOn"
+	OCMDVisibilityAnyOff PdfOCMDVisibilityPolicy = "AnyOff"
+	OCMDVisibilityAllOff PdfOCMDVisibilityPolicy = "AllOff"
+)
+
+// PdfOptionalContentMembership represents an optional content membership
+// dictionary (OCMD), expressing the visibility of content depending on the
+// state of several optional content groups.
+// See section 8.11.2.2 "Optional Content Membership Dictionaries" (p. 220
+// PDF32000_2008).
+type PdfOptionalContentMembership struct {
+	OCGs []*PdfOptionalContentGroup
+	P    PdfOCMDVisibilityPolicy // Defaults to AnyOn if not specified.
+
+	// VE is the visibility expression of the membership dictionary. It is
+	// preserved when reading but not evaluated.
+	VE core.PdfObject
+
+	container *core.PdfIndirectObject
+}
+
+// NewPdfOptionalContentMembership returns a new optional content membership
+// dictionary for the groups `ocgs` with the visibility policy `p`.
+func NewPdfOptionalContentMembership(p PdfOCMDVisibilityPolicy, ocgs ...*PdfOptionalContentGroup) *PdfOptionalContentMembership {
+	return &PdfOptionalContentMembership{
+		OCGs:      ocgs,
+		P:         p,
+		container: core.MakeIndirectObject(core.MakeDict()),
+	}
+}
+
+// GetContainingPdfObject implements interface PdfModel.
+func (ocmd *PdfOptionalContentMembership) GetContainingPdfObject() core.PdfObject {
+	return ocmd.container
+}
+
+// ToPdfObject implements interface PdfModel.
+func (ocmd *PdfOptionalContentMembership) ToPdfObject() core.PdfObject {
+	d := ocmd.container.PdfObject.(*core.PdfObjectDictionary)
+	d.Set("Type", core.MakeName("OCMD"))
+	switch len(ocmd.OCGs) {
+	case 0:
+		d.Remove("OCGs")
+	case 1:
+		d.Set("OCGs", ocmd.OCGs[0].ToPdfObject())
+	default:
+		ocgs := core.MakeArray()
+		for _, ocg := range ocmd.OCGs {
+			ocgs.Append(ocg.ToPdfObject())
+		}
+		d.Set("OCGs", ocgs)
+	}
+	if ocmd.P != "" {
+		d.Set("P", core.MakeName(string(ocmd.P)))
+	}
+	d.SetIfNotNil("VE", ocmd.VE)
+	return ocmd.container
+}
+
+// PdfOptionalContentConfig represents an optional content configuration
+// dictionary, specifying the initial state of the optional content groups.
+// See section 8.11.4.3 "Optional Content Configuration Dictionaries" (p. 226
+// PDF32000_2008).
+type PdfOptionalContentConfig struct {
+	Name    string
+	Creator string
+
+	// BaseState is the initial state of all groups: ON, OFF or Unchanged.
+	// Defaults to ON if not specified.
+	BaseState string
+	ON        []*PdfOptionalContentGroup // Groups turned on, if BaseState is not ON.
+	OFF       []*PdfOptionalContentGroup // Groups turned off, if BaseState is not OFF.
+
+	// Order specifies the presentation order of the groups in the user
+	// interface, as an array of groups, nested arrays and labels.
+	Order    *core.PdfObjectArray
+	Intent   core.PdfObject
+	AS       core.PdfObject
+	ListMode core.PdfObject
+	RBGroups core.PdfObject
+	Locked   core.PdfObject
+}
+
+func newPdfOptionalContentConfigFromDict(dict *core.PdfObjectDictionary,
+	groupFor func(obj core.PdfObject) *PdfOptionalContentGroup) *PdfOptionalContentConfig {
+	cfg := &PdfOptionalContentConfig{}
+	if name, ok := core.GetString(dict.Get("Name")); ok {
+		cfg.Name = name.Decoded()
+	}
+	if creator, ok := core.GetString(dict.Get("Creator")); ok {
+		cfg.Creator = creator.Decoded()
+	}
+	if state, ok := core.GetName(dict.Get("BaseState")); ok {
+		cfg.BaseState = state.String()
+	}
+	groups := func(obj core.PdfObject) []*PdfOptionalContentGroup {
+		arr, ok := core.GetArray(obj)
+		if !ok {
+			return nil
+		}
+		var ocgs []*PdfOptionalContentGroup
+		for _, obj := range arr.Elements() {
+			if ocg := groupFor(obj); ocg != nil {
+				ocgs = append(ocgs, ocg)
+			}
+		}
+		return ocgs
+	}
+	cfg.ON = groups(dict.Get("ON"))
+	cfg.OFF = groups(dict.Get("OFF"))
+	cfg.Order, _ = core.GetArray(dict.Get("Order"))
+	cfg.Intent = dict.Get("Intent")
+	cfg.AS = dict.Get("AS")
+	cfg.ListMode = dict.Get("ListMode")
+	cfg.RBGroups = dict.Get("RBGroups")
+	cfg.Locked = dict.Get("Locked")
+	return cfg
+}
+
+// ToPdfObject returns the configuration dictionary.
+func (cfg *PdfOptionalContentConfig) ToPdfObject() core.PdfObject {
+	d := core.MakeDict()
+	if cfg.Name != "" {
+		d.Set("Name", core.MakeEncodedString(cfg.Name, true))
+	}
+	if cfg.Creator != "" {
+		d.Set("Creator", core.MakeEncodedString(cfg.Creator, true))
+	}
+	if cfg.BaseState != "" {
+		d.Set("BaseState", core.MakeName(cfg.BaseState))
+	}
+	groups := func(key core.PdfObjectName, ocgs []*PdfOptionalContentGroup) {
+		if len(ocgs) == 0 {
+			return
+		}
+		arr := core.MakeArray()
+		for _, ocg := range ocgs {
+			arr.Append(ocg.ToPdfObject())
+		}
+		d.Set(key, arr)
+	}
+	groups("ON", cfg.ON)
+	groups("OFF", cfg.OFF)
+	if cfg.Order != nil {
+		d.Set("Order", cfg.Order)
+	}
+	d.SetIfNotNil("Intent", cfg.Intent)
+	d.SetIfNotNil("AS", cfg.AS)
+	d.SetIfNotNil("ListMode", cfg.ListMode)
+	d.SetIfNotNil("RBGroups", cfg.RBGroups)
+	d.SetIfNotNil("Locked", cfg.Locked)
+	return d
+}
+
+// IsVisible returns true if the group `ocg` is initially visible in the
+// configuration.
+func (cfg *PdfOptionalContentConfig) IsVisible(ocg *PdfOptionalContentGroup) bool {
+	if cfg.BaseState == "OFF" {
+		return containsGroup(cfg.ON, ocg)
+	}
+	return !containsGroup(cfg.OFF, ocg)
+}
+
+// SetVisible sets the initial visibility of the group `ocg` in the
+// configuration.
+func (cfg *PdfOptionalContentConfig) SetVisible(ocg *PdfOptionalContentGroup, visible bool) {
+	cfg.ON = removeGroup(cfg.ON, ocg)
+	cfg.OFF = removeGroup(cfg.OFF, ocg)
+	switch {
+	case visible && cfg.BaseState != "" && cfg.BaseState != "ON":
+		cfg.ON = append(cfg.ON, ocg)
+	case !visible && cfg.BaseState != "OFF":
+		cfg.OFF = append(cfg.OFF, ocg)
+	}
+}
+
+// removeGroup removes all references to `ocg` from the configuration.
+func (cfg *PdfOptionalContentConfig) removeGroup(ocg *PdfOptionalContentGroup) {
+	cfg.ON = removeGroup(cfg.ON, ocg)
+	cfg.OFF = removeGroup(cfg.OFF, ocg)
+	if cfg.Order != nil {
+		cfg.Order, _ = removeGroupReferences(cfg.Order, ocg).(*core.PdfObjectArray)
+	}
+	cfg.AS = removeGroupReferences(cfg.AS, ocg)
+	cfg.RBGroups = removeGroupReferences(cfg.RBGroups, ocg)
+	cfg.Locked = removeGroupReferences(cfg.Locked, ocg)
+}
+
+// PdfOptionalContentProperties represents the optional content properties
+// of a document (OCProperties entry of the catalog), listing its optional
+// content groups and their configurations.
+// See section 8.11.4.2 "Optional Content Properties Dictionary" (p. 225
+// PDF32000_2008).
+type PdfOptionalContentProperties struct {
+	OCGs    []*PdfOptionalContentGroup
+	D       *PdfOptionalContentConfig   // Default configuration.
+	Configs []*PdfOptionalContentConfig // Alternate configurations.
+}
+
+// NewPdfOptionalContentProperties returns new optional content properties
+// without any groups.
+func NewPdfOptionalContentProperties() *PdfOptionalContentProperties {
+	return &PdfOptionalContentProperties{
+		D: &PdfOptionalContentConfig{Order: core.MakeArray()},
+	}
+}
+
+func newPdfOptionalContentPropertiesFromObject(obj core.PdfObject) (*PdfOptionalContentProperties, error) {
+	dict, ok := core.GetDict(obj)
+	if !ok {
+		return nil, errors.New("optional content properties not a dictionary")
+	}
+
+	props := &PdfOptionalContentProperties{}
+	groups := map[*core.PdfIndirectObject]*PdfOptionalContentGroup{}
+	groupFor := func(obj core.PdfObject) *PdfOptionalContentGroup {
+		container, ok := core.GetIndirect(core.ResolveReference(obj))
+		if !ok {
+			return nil
+		}
+		return groups[container]
+	}
+
+	ocgs, ok := core.GetArray(dict.Get("OCGs"))
+	if !ok {
+		return nil, errors.New("optional content groups missing")
+	}
+	for _, obj := range ocgs.Elements() {
+		container, ok := core.GetIndirect(core.ResolveReference(obj))
+		if !ok {
+			common.Log.Debug("ERROR: optional content group not an indirect object (%T)", obj)
+			continue
+		}
+		if _, has := groups[container]; has {
+			continue
+		}
+		ocg, err := newPdfOptionalContentGroupFromIndirect(container)
+		if err != nil {
+			return nil, err
+		}
+		groups[container] = ocg
+		props.OCGs = append(props.OCGs, ocg)
+	}
+
+	if d, ok := core.GetDict(dict.Get("D")); ok {
+		props.D = newPdfOptionalContentConfigFromDict(d, groupFor)
+	} else {
+		common.Log.Debug("ERROR: optional content default configuration missing")
+		props.D = &PdfOptionalContentConfig{}
+	}
+	if configs, ok := core.GetArray(dict.Get("Configs")); ok {
+		for _, obj := range configs.Elements() {
+			if d, ok := core.GetDict(obj); ok {
+				props.Configs = append(props.Configs, newPdfOptionalContentConfigFromDict(d, groupFor))
+			}
+		}
+	}
+	return props, nil
+}
+
+// ToPdfObject returns the optional content properties dictionary.
+func (props *PdfOptionalContentProperties) ToPdfObject() core.PdfObject {
+	d := core.MakeDict()
+	ocgs := core.MakeArray()
+	for _, ocg := range props.OCGs {
+		ocgs.Append(ocg.ToPdfObject())
+	}
+	d.Set("OCGs", ocgs)
+	if props.D != nil {
+		d.Set("D", props.D.ToPdfObject())
+	} else {
+		d.Set("D", core.MakeDict())
+	}
+	if len(props.Configs) > 0 {
+		configs := core.MakeArray()
+		for _, cfg := range props.Configs {
+			configs.Append(cfg.ToPdfObject())
+		}
+		d.Set("Configs", configs)
+	}
+	return d
+}
+
+// AddGroup adds the group `ocg` to the document, appending it to the
+// presentation order of the default configuration, with the specified
+// initial visibility.
+func (props *PdfOptionalContentProperties) AddGroup(ocg *PdfOptionalContentGroup, visible bool) {
+	if !containsGroup(props.OCGs, ocg) {
+		props.OCGs = append(props.OCGs, ocg)
+	}
+	if props.D == nil {
+		props.D = &PdfOptionalContentConfig{}
+	}
+	if props.D.Order == nil {
+		props.D.Order = core.MakeArray()
+	}
+	props.D.Order.Append(ocg.ToPdfObject())
+	props.D.SetVisible(ocg, visible)
+}
+
+// GetGroupByName returns the first group with the specified name, or nil if
+// not found.
+func (props *PdfOptionalContentProperties) GetGroupByName(name string) *PdfOptionalContentGroup {
+	for _, ocg := range props.OCGs {
+		if ocg.Name == name {
+			return ocg
+		}
+	}
+	return nil
+}
+
+// IsVisible returns true if the group `ocg` is initially visible in the
+// default configuration.
+func (props *PdfOptionalContentProperties) IsVisible(ocg *PdfOptionalContentGroup) bool {
+	if props.D == nil {
+		return true
+	}
+	return props.D.IsVisible(ocg)
+}
+
+// SetVisible sets the initial visibility of the group `ocg` in the default
+// configuration.
+func (props *PdfOptionalContentProperties) SetVisible(ocg *PdfOptionalContentGroup, visible bool) {
+	if props.D == nil {
+		props.D = &PdfOptionalContentConfig{}
+	}
+	props.D.SetVisible(ocg, visible)
+}
+
+// RemoveGroup removes the group `ocg` from the document properties and
+// configurations. The content of the pages associated with the group is not
+// modified (see package optcontent).
+func (props *PdfOptionalContentProperties) RemoveGroup(ocg *PdfOptionalContentGroup) {
+	props.OCGs = removeGroup(props.OCGs, ocg)
+	if props.D != nil {
+		props.D.removeGroup(ocg)
+	}
+	for _, cfg := range props.Configs {
+		cfg.removeGroup(ocg)
+	}
+}
+
+// Merge adds the groups of `other` to the properties, preserving their
+// initial visibility and presentation order in the default configuration.
+// The alternate configurations of `other` are appended to the alternate
+// configurations of the properties.
+func (props *PdfOptionalContentProperties) Merge(other *PdfOptionalContentProperties) {
+	if other == nil {
+		return
+	}
+	if props.D == nil {
+		props.D = &PdfOptionalContentConfig{}
+	}
+	if props.D.Order == nil {
+		props.D.Order = core.MakeArray()
+	}
+
+	for _, ocg := range other.OCGs {
+		if !containsGroup(props.OCGs, ocg) {
+			props.OCGs = append(props.OCGs, ocg)
+		}
+		props.D.SetVisible(ocg, other.IsVisible(ocg))
+	}
+
+	if other.D != nil && other.D.Order != nil {
+		props.D.Order.Append(other.D.Order.Elements()...)
+	} else {
+		for _, ocg := range other.OCGs {
+			props.D.Order.Append(ocg.ToPdfObject())
+		}
+	}
+	if other.D != nil {
+		props.D.RBGroups = appendArrayElements(props.D.RBGroups, other.D.RBGroups)
+		props.D.Locked = appendArrayElements(props.D.Locked, other.D.Locked)
+	}
+	props.Configs = append(props.Configs, other.Configs...)
+}
+
+// appendArrayElements returns an array containing the elements of the
+// arrays `obj` and `other`. Returns `obj` if `other` is not an array.
+func appendArrayElements(obj, other core.PdfObject) core.PdfObject {
+	otherArr, ok := core.GetArray(other)
+	if !ok {
+		return obj
+	}
+	arr := core.MakeArray()
+	if objArr, ok := core.GetArray(obj); ok {
+		arr.Append(objArr.Elements()...)
+	}
+	arr.Append(otherArr.Elements()...)
+	return arr
+}
+
+// GetMembership returns the optional content membership dictionary `obj`,
+// with its groups resolved to the document groups.
+func (props *PdfOptionalContentProperties) GetMembership(obj core.PdfObject) (*PdfOptionalContentMembership, error) {
+	container, ok := core.GetIndirect(core.ResolveReference(obj))
+	if !ok {
+		return nil, errors.New("membership dictionary not an indirect object")
+	}
+	dict, ok := core.GetDict(container)
+	if !ok {
+		return nil, errors.New("membership dictionary not a dictionary")
+	}
+	if typ, ok := core.GetName(dict.Get("Type")); !ok || *typ != "OCMD" {
+		return nil, errors.New("not a membership dictionary")
+	}
+
+	ocmd := &PdfOptionalContentMembership{container: container, VE: dict.Get("VE")}
+	if p, ok := core.GetName(dict.Get("P")); ok {
+		ocmd.P = PdfOCMDVisibilityPolicy(*p)
+	}
+	refs := []core.PdfObject{dict.Get("OCGs")}
+	if arr, ok := core.GetArray(refs[0]); ok {
+		refs = arr.Elements()
+	}
+	for _, ref := range refs {
+		for _, ocg := range props.OCGs {
+			if ocg.isGroup(ref) {
+				ocmd.OCGs = append(ocmd.OCGs, ocg)
+				break
+			}
+		}
+	}
+	return ocmd, nil
+}
+
+// containsGroup returns true if `ocgs` contains `ocg`.
+func containsGroup(ocgs []*PdfOptionalContentGroup, ocg *PdfOptionalContentGroup) bool {
+	for _, g := range ocgs {
+		if g == ocg {
+			return true
+		}
+	}
+	return false
+}
+
+// removeGroup returns `ocgs` without `ocg`.
+func removeGroup(ocgs []*PdfOptionalContentGroup, ocg *PdfOptionalContentGroup) []*PdfOptionalContentGroup {
+	var kept []*PdfOptionalContentGroup
+	for _, g := range ocgs {
+		if g != ocg {
+			kept = append(kept, g)
+		}
+	}
+	return kept
+}
+
+// removeGroupReferences returns a copy of `obj` where the references to `ocg`
+// are removed from the arrays, recursively.
+func removeGroupReferences(obj core.PdfObject, ocg *PdfOptionalContentGroup) core.PdfObject {
+	switch t := obj.(type) {
+	case *core.PdfObjectArray:
+		arr := core.MakeArray()
+		for _, elem := range t.Elements() {
+			if ocg.isGroup(elem) {
+				continue
+			}
+			arr.Append(removeGroupReferences(elem, ocg))
+		}
+		return arr
+	case *core.PdfObjectDictionary:
+		d := core.MakeDict()
+		for _, key := range t.Keys() {
+			d.Set(key, removeGroupReferences(t.Get(key), ocg))
+		}
+		return d
+	}
+	return obj
+}
+
+// GetOptionalContentProperties returns the optional content properties of
+// the document, or nil if the document does not have optional content.
+func (r *PdfReader) GetOptionalContentProperties() (*PdfOptionalContentProperties, error) {
+	obj, err := r.GetOCProperties()
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, nil
+	}
+	return newPdfOptionalContentPropertiesFromObject(obj)
+}
+
+// SetOptionalContentProperties sets the optional content properties of the
+// document.
+func (w *PdfWriter) SetOptionalContentProperties(props *PdfOptionalContentProperties) error {
+	if props == nil {
+		return nil
+	}
+	return w.SetOCProperties(props.ToPdfObject())
+}
+
+// AddOptionalContent marks the content of the page as optional content
+// belonging to `oc`, which can be either an optional content group or a
+// membership dictionary. The content streams of the page are merged into a
+// single stream wrapped in a marked-content sequence.
+func (p *PdfPage) AddOptionalContent(oc PdfModel) error {
+	if oc == nil {
+		return errors.New("optional content not specified")
+	}
+	contents, err := p.GetAllContentStreams()
+	if err != nil {
+		return err
+	}
+	if p.Resources == nil {
+		p.Resources = NewPdfPageResources()
+	}
+	name := NextOptionalContentName(p.Resources)
+	if err := p.Resources.SetPropertiesByName(name, oc.ToPdfObject()); err != nil {
+		return err
+	}
+
+	contents = strings.TrimRight(contents, " \r\n")
+	wrapped := fmt.Sprintf("/OC /%s BDC\nq\n%s\nQ\nEMC\n", name, contents)
+	return p.SetContentStreams([]string{wrapped}, core.NewFlateEncoder())
+}
+
+// NextOptionalContentName returns the first unused property list name of
+// the form MC<N> in `resources`.
+func NextOptionalContentName(resources *PdfPageResources) core.PdfObjectName {
+	for i := 0; ; i++ {
+		name := core.PdfObjectName(fmt.Sprintf("MC%d", i))
+		if _, has := resources.GetPropertiesByName(name); !has {
+			return name
+		}
+	}
+}
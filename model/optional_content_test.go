@@ -0,0 +1,121 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+func TestOptionalContentProperties(t *testing.T) {
+	walls := NewPdfOptionalContentGroup("Walls", "Design")
+	wiring := NewPdfOptionalContentGroup("Wiring")
+	props := NewPdfOptionalContentProperties()
+	props.AddGroup(walls, true)
+	props.AddGroup(wiring, false)
+	require.True(t, props.IsVisible(walls))
+	require.False(t, props.IsVisible(wiring))
+
+	page := NewPdfPage()
+	page.MediaBox = &PdfRectangle{Urx: 100, Ury: 100}
+	require.NoError(t, page.AddContentStreamByString("0 0 10 10 re f"))
+	require.NoError(t, page.AddOptionalContent(walls))
+	require.NoError(t, page.AddOptionalContent(NewPdfOptionalContentMembership(OCMDVisibilityAllOn, walls, wiring)))
+
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(page))
+	require.NoError(t, w.SetOptionalContentProperties(props))
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, w.Write(buf))
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	// OCProperties structure.
+	obj, err := reader.GetOCProperties()
+	require.NoError(t, err)
+	dict, ok := core.GetDict(obj)
+	require.True(t, ok)
+	ocgs, ok := core.GetArray(dict.Get("OCGs"))
+	require.True(t, ok)
+	require.Equal(t, 2, ocgs.Len())
+	d, ok := core.GetDict(dict.Get("D"))
+	require.True(t, ok)
+	order, ok := core.GetArray(d.Get("Order"))
+	require.True(t, ok)
+	require.Equal(t, 2, order.Len())
+	off, ok := core.GetArray(d.Get("OFF"))
+	require.True(t, ok)
+	require.Equal(t, 1, off.Len())
+	require.Equal(t, core.ResolveReference(ocgs.Get(1)), core.ResolveReference(off.Get(0)))
+	require.Nil(t, d.Get("ON"))
+
+	// Optional content model.
+	props, err = reader.GetOptionalContentProperties()
+	require.NoError(t, err)
+	require.Len(t, props.OCGs, 2)
+	walls = props.GetGroupByName("Walls")
+	require.NotNil(t, walls)
+	require.Equal(t, []string{"Design"}, walls.Intent)
+	wiring = props.GetGroupByName("Wiring")
+	require.NotNil(t, wiring)
+	require.Empty(t, wiring.Intent)
+	require.True(t, props.IsVisible(walls))
+	require.False(t, props.IsVisible(wiring))
+
+	// Marked content wrapping.
+	page, err = reader.GetPage(1)
+	require.NoError(t, err)
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(contents, "/OC /MC1 BDC\nq\n/OC /MC0 BDC\nq\n0 0 10 10 re f"), contents)
+	require.Contains(t, contents, "0 0 10 10 re f\nQ\nEMC\nQ\nEMC\n")
+
+	mc0, found := page.Resources.GetPropertiesByName("MC0")
+	require.True(t, found)
+	require.Equal(t, walls.GetContainingPdfObject(), core.ResolveReference(mc0))
+	mc1, found := page.Resources.GetPropertiesByName("MC1")
+	require.True(t, found)
+	ocmd, err := props.GetMembership(mc1)
+	require.NoError(t, err)
+	require.Equal(t, OCMDVisibilityAllOn, ocmd.P)
+	require.Equal(t, []*PdfOptionalContentGroup{walls, wiring}, ocmd.OCGs)
+	_, err = props.GetMembership(mc0)
+	require.Error(t, err)
+
+	// Flip the default visibility.
+	props.SetVisible(walls, false)
+	props.SetVisible(wiring, true)
+	require.False(t, props.IsVisible(walls))
+	require.True(t, props.IsVisible(wiring))
+	require.Equal(t, []*PdfOptionalContentGroup{walls}, props.D.OFF)
+
+	// Remove a group.
+	props.RemoveGroup(walls)
+	require.Equal(t, []*PdfOptionalContentGroup{wiring}, props.OCGs)
+	require.Empty(t, props.D.OFF)
+	require.Equal(t, 1, props.D.Order.Len())
+	require.Equal(t, wiring.GetContainingPdfObject(), core.ResolveReference(props.D.Order.Get(0)))
+}
+
+func TestOptionalContentConfigBaseStateOff(t *testing.T) {
+	ocg := NewPdfOptionalContentGroup("Layer")
+	cfg := &PdfOptionalContentConfig{BaseState: "OFF"}
+	require.False(t, cfg.IsVisible(ocg))
+	cfg.SetVisible(ocg, true)
+	require.True(t, cfg.IsVisible(ocg))
+	require.Equal(t, []*PdfOptionalContentGroup{ocg}, cfg.ON)
+	require.Empty(t, cfg.OFF)
+	cfg.SetVisible(ocg, false)
+	require.False(t, cfg.IsVisible(ocg))
+	require.Empty(t, cfg.ON)
+	require.Empty(t, cfg.OFF)
+}
@@ -189,6 +189,19 @@ func (o *Outline) Items() []*OutlineItem {
 	return o.Entries
 }
 
+// Remove removes the top level outline item at the specified index.
+// Returns the removed item, or nil if the index is out of range.
+func (o *Outline) Remove(index uint) *OutlineItem {
+	var item *OutlineItem
+	o.Entries, item = removeOutlineItem(o.Entries, index)
+	return item
+}
+
+// Move moves the top level outline item at index `from` to index `to`.
+func (o *Outline) Move(from, to uint) error {
+	return moveOutlineItem(o.Entries, from, to)
+}
+
 // ToPdfOutline returns a low level PdfOutline object, based on the current
 // instance.
 func (o *Outline) ToPdfOutline() *PdfOutline {
@@ -243,6 +256,19 @@ type OutlineItem struct {
 	Title   string         `json:"title"`
 	Dest    OutlineDest    `json:"dest"`
 	Entries []*OutlineItem `json:"entries,omitempty"`
+
+	// Closed specifies whether the children of the item are hidden when the
+	// document is opened (negative Count entry).
+	Closed bool `json:"closed,omitempty"`
+
+	// Style flags (F entry) and color (C entry) of the title.
+	Bold   bool               `json:"bold,omitempty"`
+	Italic bool               `json:"italic,omitempty"`
+	Color  *PdfColorDeviceRGB `json:"color,omitempty"`
+
+	// Action performed when the item is activated (A entry). If specified,
+	// it is written instead of the destination.
+	Action core.PdfObject `json:"-"`
 }
 
 // NewOutlineItem returns a new outline item instance.
@@ -274,13 +300,45 @@ func (oi *OutlineItem) Items() []*OutlineItem {
 	return oi.Entries
 }
 
+// Remove removes the child outline item at the specified index.
+// Returns the removed item, or nil if the index is out of range.
+func (oi *OutlineItem) Remove(index uint) *OutlineItem {
+	var item *OutlineItem
+	oi.Entries, item = removeOutlineItem(oi.Entries, index)
+	return item
+}
+
+// Move moves the child outline item at index `from` to index `to`.
+func (oi *OutlineItem) Move(from, to uint) error {
+	return moveOutlineItem(oi.Entries, from, to)
+}
+
 // ToPdfOutlineItem returns a low level PdfOutlineItem object,
-// based on the current instance.
+// based on the current instance, along with the number of its visible
+// descendants (zero if the item is closed).
 func (oi *OutlineItem) ToPdfOutlineItem() (*PdfOutlineItem, int64) {
 	// Create outline item.
 	currItem := NewPdfOutlineItem()
 	currItem.Title = core.MakeEncodedString(oi.Title, true)
-	currItem.Dest = oi.Dest.ToPdfObject()
+	if oi.Action != nil {
+		currItem.A = oi.Action
+	} else {
+		currItem.Dest = oi.Dest.ToPdfObject()
+	}
+
+	var flags int64
+	if oi.Italic {
+		flags |= 1
+	}
+	if oi.Bold {
+		flags |= 2
+	}
+	if flags != 0 {
+		currItem.F = core.MakeInteger(flags)
+	}
+	if c := oi.Color; c != nil {
+		currItem.C = core.MakeArrayFromFloats([]float64{c.R(), c.G(), c.B()})
+	}
 
 	// Create outline items.
 	var outlineItems []*PdfOutlineItem
@@ -308,7 +366,15 @@ func (oi *OutlineItem) ToPdfOutlineItem() (*PdfOutlineItem, int64) {
 	if lenOutlineItems > 0 {
 		currItem.First = &outlineItems[0].PdfOutlineTreeNode
 		currItem.Last = &outlineItems[lenOutlineItems-1].PdfOutlineTreeNode
-		currItem.Count = &lenDescendants
+
+		// The count of closed items is the negated number of descendants
+		// which would be visible if the item was opened.
+		count := lenDescendants
+		if oi.Closed {
+			count = -count
+			lenDescendants = 0
+		}
+		currItem.Count = &count
 	}
 
 	return currItem, lenDescendants
@@ -319,3 +385,29 @@ func (oi *OutlineItem) ToPdfObject() core.PdfObject {
 	outlineItem, _ := oi.ToPdfOutlineItem()
 	return outlineItem.ToPdfObject()
 }
+
+// removeOutlineItem removes the item at the specified index from `items`.
+// Returns the updated items and the removed item, if any.
+func removeOutlineItem(items []*OutlineItem, index uint) ([]*OutlineItem, *OutlineItem) {
+	if index >= uint(len(items)) {
+		return items, nil
+	}
+	item := items[index]
+	return append(items[:index], items[index+1:]...), item
+}
+
+// moveOutlineItem moves the item of `items` at index `from` to index `to`.
+func moveOutlineItem(items []*OutlineItem, from, to uint) error {
+	l := uint(len(items))
+	if from >= l || to >= l {
+		return errors.New("outline item index out of range")
+	}
+	item := items[from]
+	if from < to {
+		copy(items[from:to], items[from+1:to+1])
+	} else {
+		copy(items[to+1:from+1], items[to:from])
+	}
+	items[to] = item
+	return nil
+}
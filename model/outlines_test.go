@@ -10,9 +10,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
 )
 
 func TestGetOutlines(t *testing.T) {
@@ -71,3 +74,177 @@ func TestGetOutlines(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, srcJson, dstJson)
 }
+
+// checkOutlineTree checks the linked list bookkeeping of the children of the
+// outline node `dict` and returns the number of its visible descendants.
+func checkOutlineTree(t *testing.T, container *core.PdfIndirectObject, open bool) int64 {
+	dict, ok := core.GetDict(container)
+	require.True(t, ok)
+
+	var visible, descendants int64
+	var prev *core.PdfIndirectObject
+	child, _ := core.GetIndirect(core.ResolveReference(dict.Get("First")))
+	for child != nil {
+		childDict, ok := core.GetDict(child)
+		require.True(t, ok)
+		require.Equal(t, container, core.ResolveReference(childDict.Get("Parent")))
+		if prev == nil {
+			require.Nil(t, childDict.Get("Prev"))
+		} else {
+			require.Equal(t, prev, core.ResolveReference(childDict.Get("Prev")))
+		}
+
+		childOpen := true
+		if count, ok := core.GetIntVal(childDict.Get("Count")); ok {
+			childOpen = count > 0
+		}
+		descendants++
+		visible += 1 + checkOutlineTree(t, child, childOpen)
+
+		prev = child
+		child, _ = core.GetIndirect(core.ResolveReference(childDict.Get("Next")))
+	}
+
+	if prev == nil {
+		require.Nil(t, dict.Get("Last"))
+		return 0
+	}
+	require.Equal(t, prev, core.ResolveReference(dict.Get("Last")))
+	count, ok := core.GetIntVal(dict.Get("Count"))
+	require.True(t, ok)
+	if !open {
+		require.Equal(t, -visible, int64(count))
+		return 0
+	}
+	require.Equal(t, visible, int64(count))
+	return visible
+}
+
+// writeOutline writes a document with 3 pages, the `chapter3` named
+// destination and the specified outline, and returns the document read back.
+func writeOutline(t *testing.T, outline *Outline) *PdfReader {
+	w := NewPdfWriter()
+	for i := 0; i < 3; i++ {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 100, Ury: 100}
+		require.NoError(t, w.AddPage(page))
+	}
+	y := 80.0
+	require.NoError(t, w.AddNamedDestination("chapter3", NewPdfDestinationFitH(nil, &y)))
+	w.namedDests["chapter3"].PageIndex = 2
+	w.AddOutlineTree(outline.ToOutlineTree())
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	outlines, ok := core.GetIndirect(core.ResolveReference(reader.catalog.Get("Outlines")))
+	require.True(t, ok)
+	checkOutlineTree(t, outlines, true)
+	return reader
+}
+
+// outlineTitles returns the titles of the outline items, indented by depth.
+func outlineTitles(items []*OutlineItem, depth int) []string {
+	var titles []string
+	for _, item := range items {
+		titles = append(titles, strings.Repeat(" ", depth)+item.Title)
+		titles = append(titles, outlineTitles(item.Entries, depth+1)...)
+	}
+	return titles
+}
+
+func TestOutlineRoundTrip(t *testing.T) {
+	// Deep outline fixture.
+	outline := NewOutline()
+	intro := NewOutlineItem("Introduction", NewOutlineDest(0, 0, 100))
+	intro.Bold = true
+	intro.Color = NewPdfColorDeviceRGB(1, 0, 0)
+	outline.Add(intro)
+
+	parent := intro
+	for i := 1; i <= 4; i++ {
+		item := NewOutlineItem(fmt.Sprintf("Level %d", i), NewOutlineDest(1, 0, float64(10*i)))
+		parent.Add(item)
+		parent = item
+	}
+	intro.Entries[0].Closed = true
+	intro.Entries[0].Italic = true
+
+	summary := NewOutlineItem("Übersicht ✓", NewOutlineDest(1, 0, 50))
+	outline.Add(summary)
+
+	action := core.MakeDict()
+	action.Set("S", core.MakeName("GoTo"))
+	action.Set("D", core.MakeString("chapter3"))
+	chapter := NewOutlineItem("Chapter 3", OutlineDest{})
+	chapter.Action = action
+	outline.Add(chapter)
+
+	reader := writeOutline(t, outline)
+	outline, err := reader.GetOutlines()
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"Introduction",
+		" Level 1",
+		"  Level 2",
+		"   Level 3",
+		"    Level 4",
+		"Übersicht ✓",
+		"Chapter 3",
+	}, outlineTitles(outline.Items(), 0))
+
+	intro = outline.Items()[0]
+	require.True(t, intro.Bold)
+	require.False(t, intro.Italic)
+	require.Equal(t, NewPdfColorDeviceRGB(1, 0, 0), intro.Color)
+	require.False(t, intro.Closed)
+	require.True(t, intro.Entries[0].Closed)
+	require.True(t, intro.Entries[0].Italic)
+	require.Equal(t, int64(1), intro.Entries[0].Entries[0].Dest.Page)
+	require.Equal(t, 20.0, intro.Entries[0].Entries[0].Dest.Y)
+
+	// The action is preserved and its named destination resolved.
+	chapter = outline.Items()[2]
+	require.NotNil(t, chapter.Action)
+	require.Equal(t, int64(2), chapter.Dest.Page)
+	require.Equal(t, "FitH", chapter.Dest.Mode)
+	require.Equal(t, 80.0, chapter.Dest.Y)
+
+	// Modify the outline: retitle, remove, insert and reorder items.
+	outline.Items()[1].Title = "Summary"
+	removed := intro.Entries[0].Remove(0)
+	require.NotNil(t, removed)
+	require.Equal(t, "Level 2", removed.Title)
+	require.Nil(t, intro.Entries[0].Remove(5))
+	removed.Closed = true
+	intro.Insert(0, NewOutlineItem("Preface", NewOutlineDest(0, 0, 90)))
+	outline.Insert(1, removed)
+	require.NoError(t, outline.Move(3, 0))
+	require.Error(t, outline.Move(0, 4))
+
+	reader = writeOutline(t, outline)
+	outline, err = reader.GetOutlines()
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"Chapter 3",
+		"Introduction",
+		" Preface",
+		" Level 1",
+		"Level 2",
+		" Level 3",
+		"  Level 4",
+		"Summary",
+	}, outlineTitles(outline.Items(), 0))
+
+	chapter = outline.Items()[0]
+	actionDict, ok := core.GetDict(chapter.Action)
+	require.True(t, ok)
+	require.Equal(t, "chapter3", actionDict.Get("D").(*core.PdfObjectString).Str())
+	require.Equal(t, int64(2), chapter.Dest.Page)
+	require.Empty(t, outline.Items()[1].Entries[1].Entries)
+	require.True(t, outline.Items()[2].Closed)
+	require.True(t, outline.Items()[1].Bold)
+}
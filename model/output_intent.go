@@ -0,0 +1,91 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"errors"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// PdfOutputIntent represents an output intent dictionary, describing the
+// color characteristics of the output device the document is intended for.
+// See section 14.11.5 "Output Intents" (p. 633 PDF32000_2008).
+type PdfOutputIntent struct {
+	// Subtype of the output intent (S entry), e.g. "GTS_PDFA1" for PDF/A.
+	Subtype string
+
+	OutputCondition           string
+	OutputConditionIdentifier string
+	RegistryName              string
+	Info                      string
+
+	// ICC profile of the output device (DestOutputProfile stream) and its
+	// number of color components.
+	DestOutputProfile []byte
+	ColorComponents   int
+}
+
+// NewPdfOutputIntentSRGB returns a PDF/A output intent for the sRGB color
+// space.
+func NewPdfOutputIntentSRGB() *PdfOutputIntent {
+	return &PdfOutputIntent{
+		Subtype:                   "GTS_PDFA1",
+		OutputCondition:           "sRGB IEC61966-2.1",
+		OutputConditionIdentifier: "sRGB IEC61966-2.1",
+		RegistryName:              "http://www.color.org",
+		Info:                      "sRGB IEC61966-2.1",
+		DestOutputProfile:         srgbICCProfile(),
+		ColorComponents:           3,
+	}
+}
+
+// ToPdfObject returns the output intent dictionary.
+func (oi *PdfOutputIntent) ToPdfObject() (core.PdfObject, error) {
+	if oi.Subtype == "" || oi.OutputConditionIdentifier == "" {
+		return nil, errors.New("output intent subtype and condition identifier required")
+	}
+	d := core.MakeDict()
+	d.Set("Type", core.MakeName("OutputIntent"))
+	d.Set("S", core.MakeName(oi.Subtype))
+	setString := func(key core.PdfObjectName, val string) {
+		if val != "" {
+			d.Set(key, core.MakeString(val))
+		}
+	}
+	setString("OutputCondition", oi.OutputCondition)
+	setString("OutputConditionIdentifier", oi.OutputConditionIdentifier)
+	setString("RegistryName", oi.RegistryName)
+	setString("Info", oi.Info)
+
+	if len(oi.DestOutputProfile) > 0 {
+		if oi.ColorComponents <= 0 {
+			return nil, errors.New("output profile number of color components required")
+		}
+		stream, err := core.MakeStream(oi.DestOutputProfile, core.NewFlateEncoder())
+		if err != nil {
+			return nil, err
+		}
+		stream.Set("N", core.MakeInteger(int64(oi.ColorComponents)))
+		d.Set("DestOutputProfile", stream)
+	}
+	return d, nil
+}
+
+// AddOutputIntent adds `intent` to the output intents of the document.
+func (w *PdfWriter) AddOutputIntent(intent *PdfOutputIntent) error {
+	obj, err := intent.ToPdfObject()
+	if err != nil {
+		return err
+	}
+	intents, ok := core.GetArray(w.catalog.Get("OutputIntents"))
+	if !ok {
+		intents = core.MakeArray()
+		w.catalog.Set("OutputIntents", intents)
+	}
+	intents.Append(obj)
+	return w.addObjects(obj)
+}
@@ -82,6 +82,20 @@ func (p *PdfPage) setContainer(container *core.PdfIndirectObject) {
 	p.primitive = container
 }
 
+// Logger returns the logger of the reader of the page (see ReaderOpts.Logger), logging the page
+// number as "page" field. The global common.Log is used for pages not loaded by a reader.
+func (p *PdfPage) Logger() common.StructuredLogger {
+	if p.reader == nil {
+		return common.StructuredOrGlobal(nil)
+	}
+	for i, page := range p.reader.PageList {
+		if page == p {
+			return common.WithFields(p.reader.logger, "page", i+1)
+		}
+	}
+	return p.reader.Logger()
+}
+
 // Duplicate creates a duplicate page based on the current one and returns it.
 func (p *PdfPage) Duplicate() *PdfPage {
 	var dup PdfPage
@@ -372,37 +386,237 @@ func (r *PdfReader) loadAnnotations(annotsObj core.PdfObject) ([]*PdfAnnotation,
 }
 
 // GetMediaBox gets the inheritable media box value, either from the page
-// or a higher up page/pages struct.
+// or a higher up page/pages struct. The returned box is normalized so that
+// its lower left corner is (Llx, Lly).
 func (p *PdfPage) GetMediaBox() (*PdfRectangle, error) {
-	if p.MediaBox != nil {
-		return p.MediaBox, nil
+	box, err := p.getInheritedBox("MediaBox")
+	if err != nil {
+		return nil, err
+	}
+	if box == nil {
+		return nil, errors.New("media box not defined")
+	}
+	return box, nil
+}
+
+// GetCropBox returns the normalized crop box of the page, either set on the
+// page or inherited from the page tree. The crop box defaults to the media
+// box if not set or degenerate (zero area).
+func (p *PdfPage) GetCropBox() (*PdfRectangle, error) {
+	box, err := p.getInheritedBox("CropBox")
+	if err != nil {
+		return nil, err
+	}
+	if box == nil || box.isDegenerate() {
+		return p.GetMediaBox()
+	}
+	return box, nil
+}
+
+// GetBleedBox returns the normalized bleed box of the page, defaulting to
+// the crop box if not set or degenerate.
+func (p *PdfPage) GetBleedBox() (*PdfRectangle, error) {
+	return p.getBoxOrCropBox(p.BleedBox)
+}
+
+// GetTrimBox returns the normalized trim box of the page, defaulting to the
+// crop box if not set or degenerate.
+func (p *PdfPage) GetTrimBox() (*PdfRectangle, error) {
+	return p.getBoxOrCropBox(p.TrimBox)
+}
+
+// GetArtBox returns the normalized art box of the page, defaulting to the
+// crop box if not set or degenerate.
+func (p *PdfPage) GetArtBox() (*PdfRectangle, error) {
+	return p.getBoxOrCropBox(p.ArtBox)
+}
+
+// GetRotation returns the rotation of the page, either set on the page or
+// inherited from the page tree, normalized to 0, 90, 180 or 270 degrees.
+// An error is returned if the rotation is not a multiple of 90.
+func (p *PdfPage) GetRotation() (int64, error) {
+	var rotate int64
+	if p.Rotate != nil {
+		rotate = *p.Rotate
+	} else {
+		node := p.Parent
+		for depth := 0; node != nil && depth < 32; depth++ {
+			dict, ok := core.GetDict(node)
+			if !ok {
+				break
+			}
+			if val, ok := core.GetIntVal(dict.Get("Rotate")); ok {
+				rotate = int64(val)
+				break
+			}
+			node = dict.Get("Parent")
+		}
+	}
+
+	rotate = (rotate%360 + 360) % 360
+	if rotate%90 != 0 {
+		return 0, fmt.Errorf("invalid page rotation: %d", rotate)
+	}
+	return rotate, nil
+}
+
+// SetMediaBox sets the media box of the page, normalized. A nil box removes
+// the media box of the page, which is then inherited from the page tree.
+func (p *PdfPage) SetMediaBox(box *PdfRectangle) {
+	p.MediaBox = normalizedBox(box)
+}
+
+// SetCropBox sets the crop box of the page, normalized. A nil box removes
+// the crop box of the page.
+func (p *PdfPage) SetCropBox(box *PdfRectangle) {
+	p.CropBox = normalizedBox(box)
+}
+
+// SetBleedBox sets the bleed box of the page, normalized. A nil box removes
+// the bleed box of the page.
+func (p *PdfPage) SetBleedBox(box *PdfRectangle) {
+	p.BleedBox = normalizedBox(box)
+}
+
+// SetTrimBox sets the trim box of the page, normalized. A nil box removes
+// the trim box of the page.
+func (p *PdfPage) SetTrimBox(box *PdfRectangle) {
+	p.TrimBox = normalizedBox(box)
+}
+
+// SetArtBox sets the art box of the page, normalized. A nil box removes the
+// art box of the page.
+func (p *PdfPage) SetArtBox(box *PdfRectangle) {
+	p.ArtBox = normalizedBox(box)
+}
+
+// ValidateBoxes checks that the media box of the page is not degenerate and
+// that its crop and trim boxes are within the media box.
+func (p *PdfPage) ValidateBoxes() error {
+	mediaBox, err := p.GetMediaBox()
+	if err != nil {
+		return err
+	}
+	if mediaBox.isDegenerate() {
+		return errors.New("degenerate media box")
+	}
+	for _, key := range []string{"CropBox", "TrimBox"} {
+		box, err := p.getBox(key)
+		if err != nil {
+			return err
+		}
+		if !mediaBox.contains(box) {
+			return fmt.Errorf("%s %v outside of media box %v", key, *box, *mediaBox)
+		}
+	}
+	return nil
+}
+
+// ClampBoxes reduces the crop and trim boxes of the page to their
+// intersection with the media box. Crop boxes not intersecting the media box
+// are replaced by the media box, and such trim boxes are removed (defaulting
+// to the crop box).
+func (p *PdfPage) ClampBoxes() error {
+	mediaBox, err := p.GetMediaBox()
+	if err != nil {
+		return err
+	}
+	if mediaBox.isDegenerate() {
+		return errors.New("degenerate media box")
+	}
+
+	cropBox, err := p.GetCropBox()
+	if err != nil {
+		return err
+	}
+	if !mediaBox.contains(cropBox) {
+		p.CropBox = mediaBox.intersect(cropBox)
+		if p.CropBox == nil {
+			p.CropBox = mediaBox
+		}
+	}
+	if p.TrimBox != nil {
+		trimBox := normalizedBox(p.TrimBox)
+		if !mediaBox.contains(trimBox) {
+			p.TrimBox = mediaBox.intersect(trimBox)
+		}
+	}
+	return nil
+}
+
+// getBox returns the effective box of the page with the specified name.
+func (p *PdfPage) getBox(key string) (*PdfRectangle, error) {
+	switch key {
+	case "MediaBox":
+		return p.GetMediaBox()
+	case "CropBox":
+		return p.GetCropBox()
+	case "BleedBox":
+		return p.GetBleedBox()
+	case "TrimBox":
+		return p.GetTrimBox()
+	case "ArtBox":
+		return p.GetArtBox()
+	}
+	return nil, fmt.Errorf("invalid page box: %s", key)
+}
+
+// getBoxOrCropBox returns `box` normalized or, if nil or degenerate, the crop
+// box of the page.
+func (p *PdfPage) getBoxOrCropBox(box *PdfRectangle) (*PdfRectangle, error) {
+	if box == nil || box.isDegenerate() {
+		return p.GetCropBox()
+	}
+	return normalizedBox(box), nil
+}
+
+// getInheritedBox returns the normalized inheritable box with the specified
+// key, either from the page or a higher up page/pages struct, or nil if the
+// box is not defined.
+func (p *PdfPage) getInheritedBox(key core.PdfObjectName) (*PdfRectangle, error) {
+	var box *PdfRectangle
+	switch key {
+	case "MediaBox":
+		box = p.MediaBox
+	case "CropBox":
+		box = p.CropBox
+	}
+	if box != nil {
+		return normalizedBox(box), nil
 	}
 
 	node := p.Parent
-	for node != nil {
+	for depth := 0; node != nil && depth < 32; depth++ {
 		dict, ok := core.GetDict(node)
 		if !ok {
 			return nil, errors.New("invalid parent objects dictionary")
 		}
 
-		if obj := dict.Get("MediaBox"); obj != nil {
+		if obj := dict.Get(key); obj != nil {
 			arr, ok := core.GetArray(obj)
 			if !ok {
-				return nil, errors.New("invalid media box")
+				return nil, fmt.Errorf("invalid %s", key)
 			}
 			rect, err := NewPdfRectangle(*arr)
-
 			if err != nil {
 				return nil, err
 			}
-
-			return rect, nil
+			return normalizedBox(rect), nil
 		}
 
 		node = dict.Get("Parent")
 	}
+	return nil, nil
+}
 
-	return nil, errors.New("media box not defined")
+// normalizedBox returns a normalized copy of `box`, or nil if `box` is nil.
+func normalizedBox(box *PdfRectangle) *PdfRectangle {
+	if box == nil {
+		return nil
+	}
+	normalized := *box
+	normalized.Normalize()
+	return &normalized
 }
 
 // getParentResources searches for page resources in the parent nodes of the page.
@@ -683,34 +897,18 @@ func (p *PdfPage) AddWatermarkImage(ximg *XObjectImage, opt WatermarkImageOption
 		yOffset = (pHeight - wHeight) / 2
 	}
 
-	if p.Resources == nil {
-		p.Resources = NewPdfPageResources()
-	}
-
-	// Find available image name for this page.
-	i := 0
-	imgName := core.PdfObjectName(fmt.Sprintf("Imw%d", i))
-	for p.Resources.HasXObjectByName(imgName) {
-		i++
-		imgName = core.PdfObjectName(fmt.Sprintf("Imw%d", i))
+	rm := p.ResourceManager()
+	stream, ok := ximg.ToPdfObject().(*core.PdfObjectStream)
+	if !ok {
+		return core.ErrTypeError
 	}
-
-	err = p.AddImageResource(imgName, ximg)
+	imgName, err := rm.AddXObject(stream)
 	if err != nil {
 		return err
 	}
 
-	i = 0
-	gsName := core.PdfObjectName(fmt.Sprintf("GS%d", i))
-	for p.HasExtGState(gsName) {
-		i++
-		gsName = core.PdfObjectName(fmt.Sprintf("GS%d", i))
-	}
-	gs0 := core.MakeDict()
-	gs0.Set("BM", core.MakeName("Normal"))
-	gs0.Set("CA", core.MakeFloat(opt.Alpha))
-	gs0.Set("ca", core.MakeFloat(opt.Alpha))
-	err = p.AddExtGState(gsName, gs0)
+	gs := NewPdfExtGState().SetBlendMode("Normal").SetAlpha(opt.Alpha)
+	gsName, err := rm.AddExtGState(gs.ToPdfObject())
 	if err != nil {
 		return err
 	}
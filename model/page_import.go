@@ -0,0 +1,282 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// PageRange represents a range of pages, specified by the numbers (1-based,
+// inclusive) of its first and last pages.
+type PageRange struct {
+	Start int
+	End   int
+}
+
+// PageImportOptions contains options for importing pages.
+type PageImportOptions struct {
+	// DropAnnotations specifies whether the annotations of the pages are
+	// removed.
+	DropAnnotations bool
+
+	// StripUnusedResources specifies whether the resources which are not
+	// referenced by the content streams of the pages are removed.
+	StripUnusedResources bool
+}
+
+// pageInheritedFields are the page attributes which can be inherited from
+// the nodes of the page tree.
+var pageInheritedFields = []core.PdfObjectName{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// ImportPages copies the pages of `r` in the specified ranges to the writer,
+// in the order of the ranges. All the pages are imported if no ranges are
+// specified. Pass nil for the opts parameter in order to use the default
+// options. Returns the imported pages.
+//
+// Only the objects reachable from the imported pages are copied. The copies
+// are cached for the lifetime of the writer, so that the objects shared by
+// the pages of a document (e.g. fonts and images) are copied once, even when
+// importing pages from multiple documents in several calls. References to
+// imported pages (e.g. annotation parents and link destinations) point to
+// the copies of the pages, while references to pages which are not imported
+// are replaced with null. The attributes inherited from the page tree
+// (Resources, MediaBox, CropBox and Rotate) are set on the imported pages.
+//
+// NOTE: The source pages are not modified, but the objects of the imported
+// pages are shared with the writer. Pages imported before other pages they
+// refer to (e.g. links) point to null destinations.
+func (w *PdfWriter) ImportPages(r *PdfReader, pageRanges []PageRange, opts *PageImportOptions) ([]*PdfPage, error) {
+	if r == nil {
+		return nil, errors.New("reader not specified")
+	}
+	if opts == nil {
+		opts = &PageImportOptions{}
+	}
+	if len(pageRanges) == 0 {
+		pageRanges = []PageRange{{Start: 1, End: len(r.pageList)}}
+	}
+
+	var sources []*core.PdfIndirectObject
+	for _, pageRange := range pageRanges {
+		if pageRange.Start < 1 || pageRange.End < pageRange.Start || pageRange.End > len(r.pageList) {
+			return nil, fmt.Errorf("invalid page range: %d-%d", pageRange.Start, pageRange.End)
+		}
+		sources = append(sources, r.pageList[pageRange.Start-1:pageRange.End]...)
+	}
+
+	if w.importedObjects == nil {
+		w.importedObjects = map[*PdfReader]map[core.PdfObject]core.PdfObject{}
+	}
+	cache, ok := w.importedObjects[r]
+	if !ok {
+		cache = map[core.PdfObject]core.PdfObject{}
+		w.importedObjects[r] = cache
+	}
+	copier := objectCopier{cache: cache}
+
+	// Map the source pages to their containers first, so that references
+	// between the imported pages are preserved. Pages imported more than once
+	// are referred to by their first copy.
+	containers := make([]*core.PdfIndirectObject, len(sources))
+	for i, src := range sources {
+		containers[i] = core.MakeIndirectObject(core.MakeDict())
+		if _, ok := cache[src]; !ok {
+			cache[src] = containers[i]
+		}
+	}
+
+	var pages []*PdfPage
+	for i, src := range sources {
+		srcDict, ok := core.GetDict(src)
+		if !ok {
+			return nil, errors.New("page object should be a dictionary")
+		}
+
+		dict := core.MakeDict()
+		for _, key := range srcDict.Keys() {
+			if key == "Parent" || (key == "Annots" && opts.DropAnnotations) {
+				continue
+			}
+			dict.Set(key, copier.copy(srcDict.Get(key)))
+		}
+
+		// Flatten inherited attributes.
+		for _, field := range pageInheritedFields {
+			if dict.Get(field) != nil {
+				continue
+			}
+			if obj := getInheritedPageAttribute(srcDict, field); obj != nil {
+				dict.Set(field, copier.copy(obj))
+			}
+		}
+
+		if opts.StripUnusedResources {
+			if used, err := getContentNames(dict.Get("Contents")); err == nil {
+				dict.Set("Resources", stripUnusedResources(dict.Get("Resources"), used))
+			} else {
+				common.Log.Debug("WARN: could not parse page contents, keeping resources: %v", err)
+			}
+		}
+
+		var noReader *PdfReader
+		page, err := noReader.newPdfPageFromDict(dict)
+		if err != nil {
+			return nil, err
+		}
+		page.setContainer(containers[i])
+		if err := w.AddPage(page); err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+// objectCopier deep copies PDF objects, mapping the copied indirect objects
+// and streams to their copies.
+type objectCopier struct {
+	cache map[core.PdfObject]core.PdfObject
+}
+
+// copy returns a deep copy of `obj`. Indirect objects and streams are copied
+// once, and the direct objects are not shared with the source document.
+// References to pages and page tree nodes which are not in the cache are
+// replaced with null.
+func (c objectCopier) copy(obj core.PdfObject) core.PdfObject {
+	switch t := obj.(type) {
+	case *core.PdfObjectReference:
+		resolved := core.ResolveReference(t)
+		if _, isRef := resolved.(*core.PdfObjectReference); isRef || resolved == nil {
+			return core.MakeNull()
+		}
+		return c.copy(resolved)
+	case *core.PdfIndirectObject:
+		if cp, ok := c.cache[t]; ok {
+			return cp
+		}
+		if dict, ok := t.PdfObject.(*core.PdfObjectDictionary); ok {
+			if typ, _ := core.GetNameVal(dict.Get("Type")); typ == "Page" || typ == "Pages" {
+				return core.MakeNull()
+			}
+		}
+		cp := core.MakeIndirectObject(core.MakeNull())
+		c.cache[t] = cp
+		cp.PdfObject = c.copy(t.PdfObject)
+		return cp
+	case *core.PdfObjectStream:
+		if cp, ok := c.cache[t]; ok {
+			return cp
+		}
+		if err := t.LoadData(); err != nil {
+			common.Log.Debug("ERROR: Unable to load stream data: %v", err)
+		}
+		cp := &core.PdfObjectStream{Stream: t.Stream}
+		c.cache[t] = cp
+		cp.PdfObjectDictionary = c.copy(t.PdfObjectDictionary).(*core.PdfObjectDictionary)
+		return cp
+	case *core.PdfObjectDictionary:
+		dict := core.MakeDict()
+		for _, key := range t.Keys() {
+			dict.Set(key, c.copy(t.Get(key)))
+		}
+		return dict
+	case *core.PdfObjectArray:
+		arr := core.MakeArray()
+		for _, elem := range t.Elements() {
+			arr.Append(c.copy(elem))
+		}
+		return arr
+	}
+	return core.DeepCopy(obj, nil)
+}
+
+// reContentName matches the name objects of content streams.
+var reContentName = regexp.MustCompile(`/([^\s/\[\]<>(){}%]+)`)
+
+// getContentNames returns the names occurring in the content streams
+// `contents` of a page. The result is a superset of the names of the
+// resources used by the content streams.
+func getContentNames(contents core.PdfObject) (map[string]struct{}, error) {
+	var streams []*core.PdfObjectStream
+	switch t := core.TraceToDirectObject(contents).(type) {
+	case *core.PdfObjectStream:
+		streams = append(streams, t)
+	case *core.PdfObjectArray:
+		for _, obj := range t.Elements() {
+			if stream, ok := core.GetStream(obj); ok {
+				streams = append(streams, stream)
+			}
+		}
+	}
+
+	names := map[string]struct{}{}
+	for _, stream := range streams {
+		data, err := core.DecodeStream(stream)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range reContentName.FindAllSubmatch(data, -1) {
+			names[decodeContentName(string(match[1]))] = struct{}{}
+		}
+	}
+	return names, nil
+}
+
+// decodeContentName decodes the #xx escape sequences of the name `name`.
+func decodeContentName(name string) string {
+	var decoded []byte
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if b, err := strconv.ParseUint(name[i+1:i+3], 16, 8); err == nil {
+				decoded = append(decoded, byte(b))
+				i += 2
+				continue
+			}
+		}
+		decoded = append(decoded, name[i])
+	}
+	return string(decoded)
+}
+
+// stripUnusedResources returns a copy of the resource dictionary
+// `resources`, without the named resources which are not in `used`.
+func stripUnusedResources(resources core.PdfObject, used map[string]struct{}) core.PdfObject {
+	resDict, ok := core.GetDict(resources)
+	if !ok {
+		return resources
+	}
+
+	stripped := core.MakeDict()
+	for _, key := range resDict.Keys() {
+		switch key {
+		case "ExtGState", "ColorSpace", "Pattern", "Shading", "XObject", "Font", "Properties":
+		default:
+			stripped.Set(key, resDict.Get(key))
+			continue
+		}
+
+		category, ok := core.GetDict(resDict.Get(key))
+		if !ok {
+			continue
+		}
+		kept := core.MakeDict()
+		for _, name := range category.Keys() {
+			if _, ok := used[string(name)]; ok {
+				kept.Set(name, category.Get(name))
+			}
+		}
+		if len(kept.Keys()) > 0 {
+			stripped.Set(key, kept)
+		}
+	}
+	return stripped
+}
@@ -0,0 +1,181 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// importImageSize is the size in bytes of the test images.
+const importImageSize = 64 * 1024
+
+// makeImportImage returns an image XObject of incompressible data.
+func makeImportImage(t *testing.T, seed int64) *core.PdfObjectStream {
+	data := make([]byte, importImageSize)
+	rand.New(rand.NewSource(seed)).Read(data)
+	stream, err := core.MakeStream(data, core.NewRawEncoder())
+	require.NoError(t, err)
+	stream.Set("Type", core.MakeName("XObject"))
+	stream.Set("Subtype", core.MakeName("Image"))
+	stream.Set("Width", core.MakeInteger(256))
+	stream.Set("Height", core.MakeInteger(256))
+	stream.Set("ColorSpace", core.MakeName("DeviceGray"))
+	stream.Set("BitsPerComponent", core.MakeInteger(8))
+	return stream
+}
+
+// writeImportFixture returns a reader for a document with `numPages` pages
+// sharing an image, which is drawn on the pages, and an unused image. The
+// first page links to the last page.
+func writeImportFixture(t *testing.T, numPages int, seed int64) *PdfReader {
+	used := makeImportImage(t, seed)
+	unused := makeImportImage(t, seed+1)
+
+	w := NewPdfWriter()
+	var pages []*PdfPage
+	for i := 0; i < numPages; i++ {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 200, Ury: 200}
+		require.NoError(t, page.Resources.SetXObjectByName("Im0", used))
+		require.NoError(t, page.Resources.SetXObjectByName("Unused", unused))
+		require.NoError(t, page.AddContentStreamByString("q 100 0 0 100 0 0 cm /Im0 Do Q"))
+		pages = append(pages, page)
+	}
+	link := NewPdfAnnotationLink()
+	link.Rect = core.MakeArrayFromFloats([]float64{0, 0, 10, 10})
+	link.Dest = NewPdfDestinationFit(pages[numPages-1].GetPageAsIndirectObject()).ToPdfObject()
+	pages[0].AddAnnotation(link.PdfAnnotation)
+	for _, page := range pages {
+		require.NoError(t, w.AddPage(page))
+	}
+
+	reader := writeAndRead(t, &w)
+	return reader
+}
+
+// writeImported writes the writer and returns the output and a reader for it.
+func writeImported(t *testing.T, w *PdfWriter) ([]byte, *PdfReader) {
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	return buf.Bytes(), reader
+}
+
+func TestImportPagesDeduplication(t *testing.T) {
+	readerA := writeImportFixture(t, 5, 1)
+	readerB := writeImportFixture(t, 3, 3)
+
+	// Interleave the pages of both documents in multiple calls.
+	w := NewPdfWriter()
+	_, err := w.ImportPages(readerA, []PageRange{{5, 5}, {4, 4}}, nil)
+	require.NoError(t, err)
+	_, err = w.ImportPages(readerB, []PageRange{{2, 2}}, nil)
+	require.NoError(t, err)
+	pages, err := w.ImportPages(readerA, []PageRange{{1, 2}}, nil)
+	require.NoError(t, err)
+	require.Len(t, pages, 2)
+
+	data, reader := writeImported(t, &w)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 5, numPages)
+
+	// Each image is written once.
+	images := map[*core.PdfObjectStream]struct{}{}
+	for _, page := range reader.PageList {
+		for _, name := range []core.PdfObjectName{"Im0", "Unused"} {
+			stream, _ := page.Resources.GetXObjectByName(name)
+			require.NotNil(t, stream)
+			images[stream] = struct{}{}
+		}
+		require.Equal(t, 200.0, page.MediaBox.Urx)
+	}
+	require.Len(t, images, 4)
+	require.True(t, len(data) < 5*importImageSize, "output size: %d", len(data))
+
+	// Links between imported pages point to the imported pages.
+	annots, err := reader.PageList[3].GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 1)
+	dest, err := annots[0].GetContext().(*PdfAnnotationLink).GetDestination()
+	require.NoError(t, err)
+	require.EqualValues(t, 0, dest.PageIndex)
+
+	annots, err = reader.PageList[2].GetAnnotations()
+	require.NoError(t, err)
+	require.Empty(t, annots)
+}
+
+func TestImportPagesOptions(t *testing.T) {
+	source := writeImportFixture(t, 3, 5)
+
+	w := NewPdfWriter()
+	_, err := w.ImportPages(source, nil, &PageImportOptions{
+		DropAnnotations:      true,
+		StripUnusedResources: true,
+	})
+	require.NoError(t, err)
+	data, reader := writeImported(t, &w)
+	require.True(t, len(data) < 2*importImageSize, "output size: %d", len(data))
+
+	for _, page := range reader.PageList {
+		require.True(t, page.Resources.HasXObjectByName("Im0"))
+		require.False(t, page.Resources.HasXObjectByName("Unused"))
+		annots, err := page.GetAnnotations()
+		require.NoError(t, err)
+		require.Empty(t, annots)
+	}
+
+	w = NewPdfWriter()
+	_, err = w.ImportPages(source, []PageRange{{2, 4}}, nil)
+	require.Error(t, err)
+	_, err = w.ImportPages(source, []PageRange{{0, 1}}, nil)
+	require.Error(t, err)
+}
+
+func TestImportPagesInheritedAttributes(t *testing.T) {
+	source := writeImportFixture(t, 2, 7)
+
+	// Move the attributes of the pages to the page tree root.
+	pageDict, ok := core.GetDict(source.pageList[0])
+	require.True(t, ok)
+	root, ok := core.GetDict(pageDict.Get("Parent"))
+	require.True(t, ok)
+	root.Set("MediaBox", pageDict.Get("MediaBox"))
+	root.Set("Resources", pageDict.Get("Resources"))
+	root.Set("Rotate", core.MakeInteger(90))
+	for _, page := range source.pageList {
+		dict, ok := core.GetDict(page)
+		require.True(t, ok)
+		dict.Remove("MediaBox")
+		dict.Remove("Resources")
+		dict.Remove("Rotate")
+	}
+
+	w := NewPdfWriter()
+	_, err := w.ImportPages(source, []PageRange{{2, 2}}, nil)
+	require.NoError(t, err)
+	_, reader := writeImported(t, &w)
+
+	page := reader.PageList[0]
+	require.NotNil(t, page.MediaBox)
+	require.Equal(t, 200.0, page.MediaBox.Urx)
+	require.NotNil(t, page.Rotate)
+	require.EqualValues(t, 90, *page.Rotate)
+	require.True(t, page.Resources.HasXObjectByName("Im0"))
+
+	pageDict, ok = core.GetDict(page.GetPageAsIndirectObject())
+	require.True(t, ok)
+	require.NotNil(t, pageDict.Get("MediaBox"))
+	require.NotNil(t, pageDict.Get("Rotate"))
+}
@@ -0,0 +1,300 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// PdfPageLabelStyle represents the numbering style of page labels.
+// See section 12.4.2 "Page Labels" (p. 382 PDF32000_2008).
+type PdfPageLabelStyle string
+
+// Page label numbering styles. PageLabelStyleNone labels pages with the
+// prefix only.
+const (
+	PageLabelStyleNone         PdfPageLabelStyle = ""
+	PageLabelStyleDecimal      PdfPageLabelStyle = "D"
+	PageLabelStyleUpperRoman   PdfPageLabelStyle = "R"
+	PageLabelStyleLowerRoman   PdfPageLabelStyle = "r"
+	PageLabelStyleUpperLetters PdfPageLabelStyle = "A"
+	PageLabelStyleLowerLetters PdfPageLabelStyle = "a"
+)
+
+// PdfPageLabelRange represents a range of pages sharing the same labeling
+// style, starting at the page with index StartIndex (zero based) and ending
+// before the start of the next range.
+type PdfPageLabelRange struct {
+	StartIndex int
+	Style      PdfPageLabelStyle
+	Prefix     string
+	Start      int // Numeric value of the first page label. Defaults to 1.
+}
+
+// PdfPageLabels represents the page labels of a document, as a list of
+// ranges ordered by start index.
+type PdfPageLabels struct {
+	Ranges []PdfPageLabelRange
+}
+
+// NewPdfPageLabelsFromObject returns the page labels represented by the
+// PageLabels number tree `obj`.
+func NewPdfPageLabelsFromObject(obj core.PdfObject) (*PdfPageLabels, error) {
+	if _, ok := core.GetDict(obj); !ok {
+		return nil, errors.New("page labels not a dictionary")
+	}
+
+	labels := &PdfPageLabels{}
+	var collect func(obj core.PdfObject, depth int) error
+	collect = func(obj core.PdfObject, depth int) error {
+		if depth > 32 {
+			return errors.New("page labels number tree too deep")
+		}
+		node, ok := core.GetDict(obj)
+		if !ok {
+			return nil
+		}
+		if nums, ok := core.GetArray(node.Get("Nums")); ok {
+			elements := nums.Elements()
+			for i := 0; i+1 < len(elements); i += 2 {
+				key, ok := core.GetIntVal(elements[i])
+				if !ok {
+					common.Log.Debug("ERROR: invalid page labels key (%T)", elements[i])
+					continue
+				}
+				labelRange, err := newPdfPageLabelRangeFromObject(key, elements[i+1])
+				if err != nil {
+					return err
+				}
+				labels.Ranges = append(labels.Ranges, labelRange)
+			}
+		}
+		if kids, ok := core.GetArray(node.Get("Kids")); ok {
+			for _, kid := range kids.Elements() {
+				if err := collect(kid, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := collect(obj, 0); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(labels.Ranges, func(i, j int) bool {
+		return labels.Ranges[i].StartIndex < labels.Ranges[j].StartIndex
+	})
+	return labels, nil
+}
+
+func newPdfPageLabelRangeFromObject(startIndex int, obj core.PdfObject) (PdfPageLabelRange, error) {
+	labelRange := PdfPageLabelRange{StartIndex: startIndex, Start: 1}
+	dict, ok := core.GetDict(obj)
+	if !ok {
+		return labelRange, fmt.Errorf("invalid page label dictionary (%T)", obj)
+	}
+	if style, ok := core.GetName(dict.Get("S")); ok {
+		labelRange.Style = PdfPageLabelStyle(*style)
+	}
+	if prefix, ok := core.GetString(dict.Get("P")); ok {
+		labelRange.Prefix = prefix.Decoded()
+	}
+	if start, ok := core.GetIntVal(dict.Get("St")); ok && start > 0 {
+		labelRange.Start = start
+	}
+	return labelRange, nil
+}
+
+// Validate checks that the page labels cover the first page of the document
+// and that the ranges are valid and ordered by start index.
+func (l *PdfPageLabels) Validate() error {
+	if len(l.Ranges) == 0 || l.Ranges[0].StartIndex != 0 {
+		return errors.New("page labels must cover the first page (index 0)")
+	}
+	for i, r := range l.Ranges {
+		if i > 0 && r.StartIndex <= l.Ranges[i-1].StartIndex {
+			return errors.New("page label ranges must be ordered by start index")
+		}
+		if r.Start < 0 {
+			return errors.New("page label start value must be positive")
+		}
+		switch r.Style {
+		case PageLabelStyleNone, PageLabelStyleDecimal, PageLabelStyleUpperRoman,
+			PageLabelStyleLowerRoman, PageLabelStyleUpperLetters, PageLabelStyleLowerLetters:
+		default:
+			return fmt.Errorf("invalid page label style: %s", r.Style)
+		}
+	}
+	return nil
+}
+
+// ToPdfObject returns the PageLabels number tree representing the page
+// labels.
+func (l *PdfPageLabels) ToPdfObject() core.PdfObject {
+	nums := core.MakeArray()
+	for _, r := range l.Ranges {
+		dict := core.MakeDict()
+		dict.Set("Type", core.MakeName("PageLabel"))
+		if r.Style != PageLabelStyleNone {
+			dict.Set("S", core.MakeName(string(r.Style)))
+		}
+		if r.Prefix != "" {
+			dict.Set("P", core.MakeEncodedString(r.Prefix, true))
+		}
+		if r.Start > 1 {
+			dict.Set("St", core.MakeInteger(int64(r.Start)))
+		}
+		nums.Append(core.MakeInteger(int64(r.StartIndex)), dict)
+	}
+
+	root := core.MakeDict()
+	root.Set("Nums", nums)
+	return core.MakeIndirectObject(root)
+}
+
+// Label returns the label of the page with the specified index (zero based).
+// Pages which are not covered by any range are labeled with their page
+// number.
+func (l *PdfPageLabels) Label(pageIndex int) string {
+	var labelRange *PdfPageLabelRange
+	for i := range l.Ranges {
+		if l.Ranges[i].StartIndex > pageIndex {
+			break
+		}
+		labelRange = &l.Ranges[i]
+	}
+	if labelRange == nil {
+		return strconv.Itoa(pageIndex + 1)
+	}
+
+	start := labelRange.Start
+	if start < 1 {
+		start = 1
+	}
+	num := start + pageIndex - labelRange.StartIndex
+
+	var label string
+	switch labelRange.Style {
+	case PageLabelStyleDecimal:
+		label = strconv.Itoa(num)
+	case PageLabelStyleUpperRoman:
+		label = formatRoman(num)
+	case PageLabelStyleLowerRoman:
+		label = strings.ToLower(formatRoman(num))
+	case PageLabelStyleUpperLetters:
+		label = formatLetters(num)
+	case PageLabelStyleLowerLetters:
+		label = strings.ToLower(formatLetters(num))
+	}
+	return labelRange.Prefix + label
+}
+
+// Append appends the ranges of `other` to the page labels, offset by
+// `offset` pages. This is used when merging documents: `offset` is the
+// number of pages of the documents preceding the document labeled by
+// `other`. If `other` is nil, the appended pages are labeled with decimal
+// numbers starting at 1. The ranges of `l` starting at or after `offset` are
+// replaced.
+func (l *PdfPageLabels) Append(other *PdfPageLabels, offset int) {
+	kept := l.Ranges[:0]
+	for _, r := range l.Ranges {
+		if r.StartIndex < offset {
+			kept = append(kept, r)
+		}
+	}
+	l.Ranges = kept
+
+	if other == nil || len(other.Ranges) == 0 || other.Ranges[0].StartIndex != 0 {
+		l.Ranges = append(l.Ranges, PdfPageLabelRange{
+			StartIndex: offset,
+			Style:      PageLabelStyleDecimal,
+			Start:      1,
+		})
+	}
+	if other == nil {
+		return
+	}
+	for _, r := range other.Ranges {
+		r.StartIndex += offset
+		l.Ranges = append(l.Ranges, r)
+	}
+}
+
+// formatRoman returns the uppercase roman numeral representation of `num`.
+func formatRoman(num int) string {
+	if num <= 0 {
+		return strconv.Itoa(num)
+	}
+	numerals := []struct {
+		value  int
+		symbol string
+	}{
+		{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"},
+		{100, "C"}, {90, "XC"}, {50, "L"}, {40, "XL"},
+		{10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+	}
+	var b strings.Builder
+	for _, n := range numerals {
+		for num >= n.value {
+			b.WriteString(n.symbol)
+			num -= n.value
+		}
+	}
+	return b.String()
+}
+
+// formatLetters returns the uppercase letters representation of `num`:
+// A to Z for the first 26 numbers, AA to ZZ for the next 26, and so on.
+func formatLetters(num int) string {
+	if num <= 0 {
+		return strconv.Itoa(num)
+	}
+	letter := byte('A' + (num-1)%26)
+	return strings.Repeat(string(letter), (num-1)/26+1)
+}
+
+// GetPageLabelRanges returns the page labels of the document, or nil if the
+// document does not have page labels.
+func (r *PdfReader) GetPageLabelRanges() (*PdfPageLabels, error) {
+	obj, err := r.GetPageLabels()
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	return NewPdfPageLabelsFromObject(obj)
+}
+
+// GetPageLabel returns the label of the page with the specified index (zero
+// based). Documents without page labels use the page numbers as labels.
+func (r *PdfReader) GetPageLabel(pageIndex int) (string, error) {
+	labels, err := r.GetPageLabelRanges()
+	if err != nil {
+		return "", err
+	}
+	if labels == nil {
+		return strconv.Itoa(pageIndex + 1), nil
+	}
+	return labels.Label(pageIndex), nil
+}
+
+// SetPageLabelRanges sets the page labels of the document, written as a new
+// PageLabels number tree. The labels must cover the first page.
+func (w *PdfWriter) SetPageLabelRanges(labels *PdfPageLabels) error {
+	if labels == nil {
+		return errors.New("page labels not specified")
+	}
+	if err := labels.Validate(); err != nil {
+		return err
+	}
+	return w.SetPageLabels(labels.ToPdfObject())
+}
@@ -0,0 +1,160 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// makePageLabel returns a page label dictionary.
+func makePageLabel(style, prefix string, start int64) *core.PdfObjectDictionary {
+	dict := core.MakeDict()
+	if style != "" {
+		dict.Set("S", core.MakeName(style))
+	}
+	if prefix != "" {
+		dict.Set("P", core.MakeString(prefix))
+	}
+	if start != 0 {
+		dict.Set("St", core.MakeInteger(start))
+	}
+	return dict
+}
+
+func TestPageLabelsFromNumberTree(t *testing.T) {
+	// Number tree with intermediate nodes.
+	leaf1 := core.MakeDict()
+	leaf1.Set("Limits", core.MakeArray(core.MakeInteger(0), core.MakeInteger(4)))
+	leaf1.Set("Nums", core.MakeArray(
+		core.MakeInteger(0), makePageLabel("r", "", 0),
+		core.MakeInteger(4), makePageLabel("D", "", 0),
+	))
+	leaf2 := core.MakeDict()
+	leaf2.Set("Limits", core.MakeArray(core.MakeInteger(10), core.MakeInteger(15)))
+	leaf2.Set("Nums", core.MakeArray(
+		core.MakeInteger(10), makePageLabel("D", "A-", 8),
+		core.MakeInteger(12), makePageLabel("", "Appendix", 0),
+		core.MakeInteger(13), makePageLabel("A", "", 0),
+		core.MakeInteger(15), makePageLabel("a", "x", 27),
+	))
+	root := core.MakeDict()
+	root.Set("Kids", core.MakeArray(core.MakeIndirectObject(leaf1), core.MakeIndirectObject(leaf2)))
+
+	labels, err := NewPdfPageLabelsFromObject(root)
+	require.NoError(t, err)
+	require.NoError(t, labels.Validate())
+	require.Equal(t, []PdfPageLabelRange{
+		{StartIndex: 0, Style: PageLabelStyleLowerRoman, Start: 1},
+		{StartIndex: 4, Style: PageLabelStyleDecimal, Start: 1},
+		{StartIndex: 10, Style: PageLabelStyleDecimal, Prefix: "A-", Start: 8},
+		{StartIndex: 12, Style: PageLabelStyleNone, Prefix: "Appendix", Start: 1},
+		{StartIndex: 13, Style: PageLabelStyleUpperLetters, Start: 1},
+		{StartIndex: 15, Style: PageLabelStyleLowerLetters, Prefix: "x", Start: 27},
+	}, labels.Ranges)
+
+	expected := map[int]string{
+		0:  "i",
+		3:  "iv",
+		4:  "1",
+		9:  "6",
+		10: "A-8",
+		11: "A-9",
+		12: "Appendix",
+		13: "A",
+		14: "B",
+		15: "xaa",
+		16: "xbb",
+	}
+	for idx, label := range expected {
+		require.Equal(t, label, labels.Label(idx), "page index %d", idx)
+	}
+}
+
+func TestPageLabelsRoundTrip(t *testing.T) {
+	labels := &PdfPageLabels{Ranges: []PdfPageLabelRange{
+		{StartIndex: 0, Style: PageLabelStyleUpperRoman},
+		{StartIndex: 2, Style: PageLabelStyleDecimal, Prefix: "Page ", Start: 1},
+		{StartIndex: 4, Prefix: "Cover"},
+	}}
+
+	w := NewPdfWriter()
+	for i := 0; i < 5; i++ {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 100, Ury: 100}
+		require.NoError(t, w.AddPage(page))
+	}
+	require.NoError(t, w.SetPageLabelRanges(labels))
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	var actual []string
+	for i := 0; i < 5; i++ {
+		label, err := reader.GetPageLabel(i)
+		require.NoError(t, err)
+		actual = append(actual, label)
+	}
+	require.Equal(t, []string{"I", "II", "Page 1", "Page 2", "Cover"}, actual)
+
+	// Validation.
+	require.Error(t, w.SetPageLabelRanges(&PdfPageLabels{}))
+	require.Error(t, w.SetPageLabelRanges(&PdfPageLabels{Ranges: []PdfPageLabelRange{
+		{StartIndex: 1, Style: PageLabelStyleDecimal},
+	}}))
+	require.Error(t, w.SetPageLabelRanges(&PdfPageLabels{Ranges: []PdfPageLabelRange{
+		{StartIndex: 0, Style: "X"},
+	}}))
+	require.Error(t, w.SetPageLabelRanges(&PdfPageLabels{Ranges: []PdfPageLabelRange{
+		{StartIndex: 0, Style: PageLabelStyleDecimal},
+		{StartIndex: 0, Style: PageLabelStyleLowerRoman},
+	}}))
+
+	// Documents without page labels.
+	w = NewPdfWriter()
+	require.NoError(t, w.AddPage(NewPdfPage()))
+	buf.Reset()
+	require.NoError(t, w.Write(&buf))
+	reader, err = NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	noLabels, err := reader.GetPageLabelRanges()
+	require.NoError(t, err)
+	require.Nil(t, noLabels)
+	label, err := reader.GetPageLabel(0)
+	require.NoError(t, err)
+	require.Equal(t, "1", label)
+}
+
+func TestPageLabelsAppend(t *testing.T) {
+	// First document: 3 pages of front matter and 5 pages of content.
+	merged := &PdfPageLabels{Ranges: []PdfPageLabelRange{
+		{StartIndex: 0, Style: PageLabelStyleLowerRoman, Start: 1},
+		{StartIndex: 3, Style: PageLabelStyleDecimal, Start: 1},
+	}}
+	// Second document: 2 pages without labels.
+	merged.Append(nil, 8)
+	// Third document: appendix.
+	merged.Append(&PdfPageLabels{Ranges: []PdfPageLabelRange{
+		{StartIndex: 0, Prefix: "A-", Style: PageLabelStyleDecimal, Start: 1},
+	}}, 10)
+
+	require.NoError(t, merged.Validate())
+	require.Equal(t, []PdfPageLabelRange{
+		{StartIndex: 0, Style: PageLabelStyleLowerRoman, Start: 1},
+		{StartIndex: 3, Style: PageLabelStyleDecimal, Start: 1},
+		{StartIndex: 8, Style: PageLabelStyleDecimal, Start: 1},
+		{StartIndex: 10, Style: PageLabelStyleDecimal, Prefix: "A-", Start: 1},
+	}, merged.Ranges)
+	require.Equal(t, "iii", merged.Label(2))
+	require.Equal(t, "5", merged.Label(7))
+	require.Equal(t, "2", merged.Label(9))
+	require.Equal(t, "A-2", merged.Label(11))
+}
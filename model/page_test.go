@@ -6,11 +6,15 @@
 package model
 
 import (
+	"bytes"
 	"io"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/transform"
 )
 
 // ParseIndObjSeries loads a series of indirect objects until it runs into an error or EOF.
@@ -382,3 +386,141 @@ func TestRect(t *testing.T) {
 		return
 	}
 }
+
+func TestRectOperations(t *testing.T) {
+	rect := PdfRectangle{Llx: 300, Lly: 200, Urx: 100, Ury: 50}
+	require.Equal(t, PdfRectangle{Llx: 100, Lly: 50, Urx: 300, Ury: 200}, rect.Normalized())
+	require.Equal(t, PdfRectangle{Llx: 300, Lly: 200, Urx: 100, Ury: 50}, rect)
+
+	union := PdfRectangle{Llx: 0, Lly: 10, Urx: 20, Ury: 30}.Union(PdfRectangle{Llx: 5, Lly: -5, Urx: 40, Ury: 25})
+	require.Equal(t, PdfRectangle{Llx: 0, Lly: -5, Urx: 40, Ury: 30}, union)
+
+	// Rotation by 90 degrees counterclockwise, then translation.
+	m := transform.NewMatrix(0, 1, -1, 0, 100, 0)
+	require.Equal(t, PdfRectangle{Llx: -100, Lly: 100, Urx: 50, Ury: 300}, rect.Transform(m))
+}
+
+func TestPageBoxes(t *testing.T) {
+	// Boxes inherited from the page tree.
+	parent := core.MakeDict()
+	parent.Set("MediaBox", core.MakeArrayFromFloats([]float64{612, 792, 0, 0}))
+	parent.Set("CropBox", core.MakeArrayFromFloats([]float64{10, 20, 600, 780}))
+	root := core.MakeDict()
+	parent.Set("Parent", root)
+	page := NewPdfPage()
+	page.Parent = parent
+
+	mediaBox, err := page.GetMediaBox()
+	require.NoError(t, err)
+	require.Equal(t, PdfRectangle{Urx: 612, Ury: 792}, *mediaBox)
+	cropBox, err := page.GetCropBox()
+	require.NoError(t, err)
+	require.Equal(t, PdfRectangle{Llx: 10, Lly: 20, Urx: 600, Ury: 780}, *cropBox)
+	for _, get := range []func() (*PdfRectangle, error){page.GetBleedBox, page.GetTrimBox, page.GetArtBox} {
+		box, err := get()
+		require.NoError(t, err)
+		require.Equal(t, *cropBox, *box)
+	}
+
+	// Page boxes take precedence over inherited boxes and are normalized.
+	page.SetCropBox(&PdfRectangle{Llx: 500, Lly: 700, Urx: 100, Ury: 50})
+	require.Equal(t, PdfRectangle{Llx: 100, Lly: 50, Urx: 500, Ury: 700}, *page.CropBox)
+	page.SetTrimBox(&PdfRectangle{Llx: 120, Lly: 60, Urx: 480, Ury: 690})
+	trimBox, err := page.GetTrimBox()
+	require.NoError(t, err)
+	require.Equal(t, PdfRectangle{Llx: 120, Lly: 60, Urx: 480, Ury: 690}, *trimBox)
+	bleedBox, err := page.GetBleedBox()
+	require.NoError(t, err)
+	require.Equal(t, *page.CropBox, *bleedBox)
+
+	// Degenerate boxes default to the media box and crop box.
+	page.SetCropBox(&PdfRectangle{Llx: 100, Lly: 100, Urx: 100, Ury: 300})
+	page.SetArtBox(&PdfRectangle{Llx: 50, Lly: 50, Urx: 300, Ury: 50})
+	cropBox, err = page.GetCropBox()
+	require.NoError(t, err)
+	require.Equal(t, *mediaBox, *cropBox)
+	artBox, err := page.GetArtBox()
+	require.NoError(t, err)
+	require.Equal(t, *mediaBox, *artBox)
+
+	// Pages without media box.
+	_, err = NewPdfPage().GetMediaBox()
+	require.Error(t, err)
+	_, err = NewPdfPage().GetCropBox()
+	require.Error(t, err)
+}
+
+func TestPageRotation(t *testing.T) {
+	// Rotation inherited from the page tree.
+	parent := core.MakeDict()
+	parent.Set("Rotate", core.MakeInteger(-90))
+	root := core.MakeDict()
+	root.Set("Rotate", core.MakeInteger(180))
+	parent.Set("Parent", root)
+	page := NewPdfPage()
+	page.Parent = parent
+	rotate, err := page.GetRotation()
+	require.NoError(t, err)
+	require.Equal(t, int64(270), rotate)
+
+	// Page rotation takes precedence over the inherited rotation.
+	for _, tc := range []struct{ rotate, expected int64 }{{0, 0}, {450, 90}, {-180, 180}} {
+		page.Rotate = &tc.rotate
+		rotate, err := page.GetRotation()
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, rotate)
+	}
+
+	invalid := int64(45)
+	page.Rotate = &invalid
+	_, err = page.GetRotation()
+	require.Error(t, err)
+
+	rotate, err = NewPdfPage().GetRotation()
+	require.NoError(t, err)
+	require.Zero(t, rotate)
+}
+
+func TestPageBoxesValidation(t *testing.T) {
+	newPage := func() *PdfPage {
+		page := NewPdfPage()
+		page.SetMediaBox(&PdfRectangle{Urx: 200, Ury: 200})
+		page.SetCropBox(&PdfRectangle{Llx: -10, Lly: 50, Urx: 150, Ury: 250})
+		page.SetTrimBox(&PdfRectangle{Llx: 300, Lly: 300, Urx: 400, Ury: 400})
+		return page
+	}
+
+	page := newPage()
+	require.Error(t, page.ValidateBoxes())
+	w := NewPdfWriter()
+	require.Error(t, w.AddPage(page))
+
+	page.SetTrimBox(nil)
+	require.Error(t, page.ValidateBoxes())
+	page.SetCropBox(&PdfRectangle{Llx: 10, Lly: 10, Urx: 190, Ury: 190})
+	require.NoError(t, page.ValidateBoxes())
+
+	page = newPage()
+	page.SetMediaBox(&PdfRectangle{Urx: 200})
+	require.Error(t, page.ValidateBoxes())
+	require.Error(t, page.ClampBoxes())
+
+	// Clamped boxes.
+	w = NewPdfWriter()
+	w.SetClampPageBoxes(true)
+	require.NoError(t, w.AddPage(newPage()))
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	page, err = reader.GetPage(1)
+	require.NoError(t, err)
+	require.NoError(t, page.ValidateBoxes())
+	cropBox, err := page.GetCropBox()
+	require.NoError(t, err)
+	require.Equal(t, PdfRectangle{Llx: 0, Lly: 50, Urx: 150, Ury: 200}, *cropBox)
+	require.Nil(t, page.TrimBox)
+	trimBox, err := page.GetTrimBox()
+	require.NoError(t, err)
+	require.Equal(t, *cropBox, *trimBox)
+}
@@ -299,11 +299,11 @@ func newPdfTilingPatternFromDictionary(dict *core.PdfObjectDictionary) (*PdfTili
 		common.Log.Debug("Resources missing")
 		return nil, ErrRequiredAttributeMissing
 	}
-	dict, ok = core.TraceToDirectObject(obj).(*core.PdfObjectDictionary)
+	resourcesDict, ok := core.TraceToDirectObject(obj).(*core.PdfObjectDictionary)
 	if !ok {
 		return nil, fmt.Errorf("invalid resource dictionary (%T)", obj)
 	}
-	resources, err := NewPdfPageResourcesFromDict(dict)
+	resources, err := NewPdfPageResourcesFromDict(resourcesDict)
 	if err != nil {
 		return nil, err
 	}
@@ -311,7 +311,7 @@ func newPdfTilingPatternFromDictionary(dict *core.PdfObjectDictionary) (*PdfTili
 
 	// Matrix (optional).
 	if obj := dict.Get("Matrix"); obj != nil {
-		arr, ok := obj.(*core.PdfObjectArray)
+		arr, ok := core.GetArray(obj)
 		if !ok {
 			common.Log.Debug("Matrix not an array (got %T)", obj)
 			return nil, core.ErrTypeError
@@ -0,0 +1,877 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// PdfARule identifies a group of PDF/A requirements (ISO 19005-2) checked by
+// the PDF/A validator.
+type PdfARule string
+
+// PDF/A requirements checked by the validator.
+const (
+	// PdfARuleFileStructure covers the file header, the trailer ID and the
+	// end-of-file marker.
+	PdfARuleFileStructure PdfARule = "file-structure"
+
+	// PdfARuleEncryption forbids encryption.
+	PdfARuleEncryption PdfARule = "encryption"
+
+	// PdfARuleStreams forbids the LZW and Crypt stream filters.
+	PdfARuleStreams PdfARule = "streams"
+
+	// PdfARuleMetadata requires XMP metadata with the PDF/A identification
+	// schema, consistent with the document information dictionary.
+	PdfARuleMetadata PdfARule = "metadata"
+
+	// PdfARuleOutputIntent requires a PDF/A output intent with an ICC
+	// profile.
+	PdfARuleOutputIntent PdfARule = "output-intent"
+
+	// PdfARuleFonts requires the font programs of all fonts to be embedded.
+	PdfARuleFonts PdfARule = "fonts"
+
+	// PdfARuleActions forbids JavaScript, Launch and other interactive
+	// actions, as well as additional actions (AA entries).
+	PdfARuleActions PdfARule = "actions"
+
+	// PdfARuleTransparency requires transparency groups to declare their
+	// color space when the document has no output intent, and forbids
+	// non-standard blend modes.
+	PdfARuleTransparency PdfARule = "transparency"
+)
+
+// PdfAViolation represents a violation of a PDF/A requirement.
+type PdfAViolation struct {
+	Rule        PdfARule
+	Description string
+
+	// Number of the object violating the requirement, or 0 if the violation
+	// does not concern a specific object.
+	ObjectNumber int64
+}
+
+// String returns a string describing the violation.
+func (v PdfAViolation) String() string {
+	if v.ObjectNumber > 0 {
+		return fmt.Sprintf("%s: %s (object %d)", v.Rule, v.Description, v.ObjectNumber)
+	}
+	return fmt.Sprintf("%s: %s", v.Rule, v.Description)
+}
+
+// pdfaForbiddenActions are the action types not permitted by PDF/A-2.
+var pdfaForbiddenActions = map[string]bool{
+	"Launch": true, "Sound": true, "Movie": true, "ResetForm": true,
+	"ImportData": true, "Hide": true, "SetOCGState": true, "Rendition": true,
+	"Trans": true, "GoTo3DView": true, "JavaScript": true,
+}
+
+// pdfaNamedActions are the named actions permitted by PDF/A-2.
+var pdfaNamedActions = map[string]bool{
+	"NextPage": true, "PrevPage": true, "FirstPage": true, "LastPage": true,
+}
+
+// pdfaBlendModes are the standard blend modes.
+var pdfaBlendModes = map[string]bool{
+	"Normal": true, "Compatible": true, "Multiply": true, "Screen": true,
+	"Overlay": true, "Darken": true, "Lighten": true, "ColorDodge": true,
+	"ColorBurn": true, "HardLight": true, "SoftLight": true, "Difference": true,
+	"Exclusion": true, "Hue": true, "Saturation": true, "Color": true,
+	"Luminosity": true,
+}
+
+// isPdfAForbiddenAction returns true if `d` is an action dictionary of a
+// type not permitted by PDF/A-2.
+func isPdfAForbiddenAction(d *core.PdfObjectDictionary) bool {
+	if typ, ok := core.GetNameVal(d.Get("Type")); ok && typ != "Action" {
+		return false
+	}
+	s, ok := core.GetNameVal(d.Get("S"))
+	if !ok {
+		return false
+	}
+	if s == "Named" {
+		name, _ := core.GetNameVal(d.Get("N"))
+		return !pdfaNamedActions[name]
+	}
+	return pdfaForbiddenActions[s]
+}
+
+// pdfaValidator checks a document against the PDF/A-2b requirements.
+type pdfaValidator struct {
+	r          *PdfReader
+	violations []PdfAViolation
+
+	// Whether the document has a valid PDF/A output intent.
+	hasOutputIntent bool
+}
+
+// addViolation records a violation of `rule` by the object `objNum`.
+func (v *pdfaValidator) addViolation(rule PdfARule, objNum int64, format string, args ...interface{}) {
+	v.violations = append(v.violations, PdfAViolation{
+		Rule:         rule,
+		Description:  fmt.Sprintf(format, args...),
+		ObjectNumber: objNum,
+	})
+}
+
+// ValidatePdfA2b checks the document against the core requirements of
+// PDF/A-2b (ISO 19005-2, level B conformance) which can be verified on the
+// document structure: file structure, encryption, stream filters, XMP
+// metadata and PDF/A identification, output intent, font embedding, actions
+// and transparency. Returns the violations found, which is empty if the
+// document passes all the checks.
+// NOTE: The contents of the content streams and of the embedded font
+// programs and ICC profiles are not checked, so passing the validation does
+// not guarantee full compliance.
+func (r *PdfReader) ValidatePdfA2b() ([]PdfAViolation, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return nil, err
+	}
+	v := &pdfaValidator{r: r}
+	if err := v.checkFileStructure(); err != nil {
+		return nil, err
+	}
+	v.checkMetadata()
+	v.checkOutputIntent()
+	if names, ok := core.GetDict(r.catalog.Get("Names")); ok && names.Get("JavaScript") != nil {
+		v.addViolation(PdfARuleActions, 0, "document-level JavaScript")
+	}
+	v.checkObjects()
+	return v.violations, nil
+}
+
+// checkFileStructure checks the file header, the end-of-file marker and the
+// trailer of the document.
+func (v *pdfaValidator) checkFileStructure() error {
+	rs := v.r.rs
+	offset, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	defer rs.Seek(offset, io.SeekStart)
+
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	readAt := func(off int64, n int64) ([]byte, error) {
+		if off < 0 {
+			n += off
+			off = 0
+		}
+		if off+n > size {
+			n = size - off
+		}
+		if _, err := rs.Seek(off, io.SeekStart); err != nil {
+			return nil, err
+		}
+		b := make([]byte, n)
+		_, err := io.ReadFull(rs, b)
+		return b, err
+	}
+
+	// The header must be followed by a comment containing at least four
+	// binary characters.
+	head, err := readAt(0, 32)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(head, []byte("%PDF-1.")) || len(head) < 9 || head[7] < '0' || head[7] > '7' {
+		v.addViolation(PdfARuleFileStructure, 0, "invalid file header")
+	} else {
+		comment := bytes.TrimLeft(head[8:], "\r\n")
+		if len(head)-len(comment) == 8 || len(comment) < 5 || comment[0] != '%' ||
+			comment[1] < 128 || comment[2] < 128 || comment[3] < 128 || comment[4] < 128 {
+			v.addViolation(PdfARuleFileStructure, 0, "file header not followed by a binary comment")
+		}
+	}
+
+	// Only a single end-of-line marker can follow the last end-of-file
+	// marker.
+	tail, err := readAt(size-32, 32)
+	if err != nil {
+		return err
+	}
+	for _, eol := range []string{"\r\n", "\n", "\r"} {
+		if bytes.HasSuffix(tail, []byte(eol)) {
+			tail = tail[:len(tail)-len(eol)]
+			break
+		}
+	}
+	if !bytes.HasSuffix(tail, []byte("%%EOF")) {
+		v.addViolation(PdfARuleFileStructure, 0, "data after the end-of-file marker")
+	}
+
+	trailer, err := v.r.GetTrailer()
+	if err != nil {
+		return err
+	}
+	if ids, ok := core.GetArray(trailer.Get("ID")); !ok || ids.Len() != 2 {
+		v.addViolation(PdfARuleFileStructure, 0, "trailer without file identifier")
+	}
+	if trailer.Get("Encrypt") != nil {
+		v.addViolation(PdfARuleEncryption, 0, "document is encrypted")
+	}
+	return nil
+}
+
+// checkMetadata checks the XMP metadata of the document.
+func (v *pdfaValidator) checkMetadata() {
+	m, err := v.r.GetXMPMetadata()
+	if err != nil {
+		v.addViolation(PdfARuleMetadata, 0, "invalid XMP metadata: %v", err)
+		return
+	}
+	if m == nil {
+		v.addViolation(PdfARuleMetadata, 0, "missing XMP metadata")
+		return
+	}
+	if m.PDFAPart != 2 {
+		v.addViolation(PdfARuleMetadata, 0, "PDF/A identification part %d, expected 2", m.PDFAPart)
+	}
+	switch m.PDFAConformance {
+	case "A", "B", "U":
+	default:
+		v.addViolation(PdfARuleMetadata, 0, "invalid PDF/A identification conformance %q", m.PDFAConformance)
+	}
+
+	// The entries of the document information dictionary must be equivalent
+	// to the metadata properties.
+	info, err := v.r.GetInfoDict()
+	if err != nil || info == nil {
+		return
+	}
+	for _, entry := range []struct {
+		key  core.PdfObjectName
+		prop string
+	}{
+		{"Title", m.Title},
+		{"Subject", m.Description},
+		{"Keywords", m.Keywords},
+		{"Creator", m.CreatorTool},
+		{"Producer", m.Producer},
+	} {
+		if s, ok := core.GetString(info.Get(entry.key)); ok && s.Decoded() != entry.prop {
+			v.addViolation(PdfARuleMetadata, 0, "document information %s not consistent with XMP metadata", entry.key)
+		}
+	}
+}
+
+// checkOutputIntent checks the PDF/A output intents of the document.
+func (v *pdfaValidator) checkOutputIntent() {
+	intents, _ := core.GetArray(v.r.catalog.Get("OutputIntents"))
+	var profile core.PdfObject
+	var found bool
+	for _, obj := range intents.Elements() {
+		intent, ok := core.GetDict(obj)
+		if !ok {
+			continue
+		}
+		if s, _ := core.GetNameVal(intent.Get("S")); s != "GTS_PDFA1" {
+			continue
+		}
+		found = true
+		dest := core.ResolveReference(intent.Get("DestOutputProfile"))
+		if _, ok := dest.(*core.PdfObjectStream); !ok {
+			v.addViolation(PdfARuleOutputIntent, 0, "PDF/A output intent without ICC profile")
+			return
+		}
+		if profile != nil && profile != dest {
+			v.addViolation(PdfARuleOutputIntent, 0, "PDF/A output intents with different ICC profiles")
+			return
+		}
+		profile = dest
+	}
+	if !found {
+		v.addViolation(PdfARuleOutputIntent, 0, "missing PDF/A output intent")
+		return
+	}
+	v.hasOutputIntent = true
+}
+
+// checkObjects checks the objects of the document.
+func (v *pdfaValidator) checkObjects() {
+	for _, num := range v.r.GetObjectNums() {
+		obj, err := v.r.GetIndirectObjectByNumber(num)
+		if err != nil {
+			common.Log.Debug("ERROR: unable to load object %d: %v", num, err)
+			continue
+		}
+		switch t := obj.(type) {
+		case *core.PdfIndirectObject:
+			v.checkObject(t.PdfObject, int64(num), 0)
+		case *core.PdfObjectStream:
+			v.checkStream(t, int64(num))
+			v.checkObject(t.PdfObjectDictionary, int64(num), 0)
+		}
+	}
+}
+
+// checkStream checks the filters of the stream `stream`.
+func (v *pdfaValidator) checkStream(stream *core.PdfObjectStream, objNum int64) {
+	for _, filter := range streamFilterNames(stream) {
+		switch filter {
+		case core.StreamEncodingFilterNameLZW:
+			v.addViolation(PdfARuleStreams, objNum, "LZW compressed stream")
+		case "Crypt":
+			v.addViolation(PdfARuleStreams, objNum, "stream with Crypt filter")
+		}
+	}
+}
+
+// streamFilterNames returns the names of the filters of `stream`.
+func streamFilterNames(stream *core.PdfObjectStream) []string {
+	filter := core.TraceToDirectObject(stream.Get("Filter"))
+	if name, ok := core.GetNameVal(filter); ok {
+		return []string{name}
+	}
+	var names []string
+	if arr, ok := filter.(*core.PdfObjectArray); ok {
+		for _, obj := range arr.Elements() {
+			if name, ok := core.GetNameVal(obj); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// checkObject checks the direct object `obj`, contained in the object
+// `objNum`, and the direct objects it contains.
+func (v *pdfaValidator) checkObject(obj core.PdfObject, objNum int64, depth int) {
+	if depth > 50 {
+		return
+	}
+	switch t := obj.(type) {
+	case *core.PdfObjectArray:
+		for _, elem := range t.Elements() {
+			v.checkObject(elem, objNum, depth+1)
+		}
+	case *core.PdfObjectDictionary:
+		v.checkDict(t, objNum)
+		for _, key := range t.Keys() {
+			v.checkObject(t.Get(key), objNum, depth+1)
+		}
+	}
+}
+
+// checkDict checks the dictionary `d`, contained in the object `objNum`.
+func (v *pdfaValidator) checkDict(d *core.PdfObjectDictionary, objNum int64) {
+	if typ, _ := core.GetNameVal(d.Get("Type")); typ == "Font" {
+		v.checkFont(d, objNum)
+	}
+	if isPdfAForbiddenAction(d) {
+		s, _ := core.GetNameVal(d.Get("S"))
+		v.addViolation(PdfARuleActions, objNum, "forbidden %s action", s)
+	}
+	if d.Get("AA") != nil {
+		v.addViolation(PdfARuleActions, objNum, "additional actions")
+	}
+
+	if s, _ := core.GetNameVal(d.Get("S")); s == "Transparency" && !v.hasOutputIntent && d.Get("CS") == nil {
+		v.addViolation(PdfARuleTransparency, objNum, "transparency group without color space")
+	}
+	if bm := d.Get("BM"); bm != nil && !isPdfABlendMode(bm) {
+		v.addViolation(PdfARuleTransparency, objNum, "non-standard blend mode %s", core.TraceToDirectObject(bm))
+	}
+}
+
+// isPdfABlendMode returns true if the blend mode `bm` (BM entry of a graphics
+// state parameter dictionary), which can be an array of blend modes, only
+// contains standard blend modes.
+func isPdfABlendMode(bm core.PdfObject) bool {
+	modes := []core.PdfObject{bm}
+	if arr, ok := core.GetArray(bm); ok {
+		modes = arr.Elements()
+	}
+	for _, mode := range modes {
+		if name, _ := core.GetNameVal(mode); !pdfaBlendModes[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkFont checks that the font program of the font dictionary `d` is
+// embedded. Type 0 fonts are checked through their descendant fonts, and
+// Type 3 fonts do not use font programs.
+func (v *pdfaValidator) checkFont(d *core.PdfObjectDictionary, objNum int64) {
+	switch subtype, _ := core.GetNameVal(d.Get("Subtype")); subtype {
+	case "Type0", "Type3":
+		return
+	}
+	desc, ok := core.GetDict(d.Get("FontDescriptor"))
+	if ok && (desc.Get("FontFile") != nil || desc.Get("FontFile2") != nil || desc.Get("FontFile3") != nil) {
+		return
+	}
+	name, _ := core.GetNameVal(d.Get("BaseFont"))
+	v.addViolation(PdfARuleFonts, objNum, "font %s not embedded", name)
+}
+
+// PdfAConversionReport describes the result of a PDF/A conversion.
+type PdfAConversionReport struct {
+	// Fixes describes the changes made to the document.
+	Fixes []string
+
+	// Violations are the violations found in the converted document, which
+	// could not be fixed (e.g. fonts which are not embedded).
+	Violations []PdfAViolation
+}
+
+// pdfaConverter fixes the PDF/A violations of a document.
+type pdfaConverter struct {
+	r *PdfReader
+
+	removedActions    int
+	removedAddActions int
+	reencodedStreams  int
+	blendModes        int
+}
+
+// ConvertToPdfA2b writes the document to `ws`, converted to PDF/A-2b where
+// possible. The conversion removes the encryption and adds a file
+// identifier, adds an sRGB PDF/A output intent unless the document has one,
+// adds or repairs the XMP metadata (including the PDF/A identification) and
+// makes it consistent with the document information dictionary, removes the
+// forbidden actions, additional actions and document-level JavaScript,
+// re-encodes the LZW compressed streams with Flate and replaces non-standard
+// blend modes with the Normal blend mode.
+// The pages and the catalog entries of the document are written, except the
+// entries forbidden by PDF/A-2 which are listed in the report. The
+// converted document is validated and the remaining
+// violations, which could not be fixed, are returned in the report.
+// The reader must be decrypted if the document is encrypted.
+func (r *PdfReader) ConvertToPdfA2b(ws io.Writer) (*PdfAConversionReport, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return nil, err
+	}
+
+	c := &pdfaConverter{r: r}
+	report := &PdfAConversionReport{}
+	if r.parser.GetCrypter() != nil {
+		report.Fixes = append(report.Fixes, "removed encryption")
+	}
+	c.fixObjects()
+
+	w := NewPdfWriter()
+	w.SetVersion(1, 7)
+	numPages, err := r.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i <= numPages; i++ {
+		page, err := r.GetPage(i)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.fixPage(page); err != nil {
+			return nil, err
+		}
+		if err := w.AddPage(page); err != nil {
+			return nil, err
+		}
+	}
+
+	if outlineTree := r.GetOutlineTree(); outlineTree != nil {
+		c.fixOutlines(outlineTree, map[*PdfOutlineTreeNode]struct{}{})
+		w.AddOutlineTree(outlineTree)
+	}
+	if r.AcroForm != nil {
+		for _, field := range r.AcroForm.AllFields() {
+			field.AA = nil
+		}
+		if err := w.SetForms(r.AcroForm); err != nil {
+			return nil, err
+		}
+	}
+	if labels, err := r.GetPageLabels(); err == nil && labels != nil {
+		if err := w.SetPageLabels(labels); err != nil {
+			return nil, err
+		}
+	}
+	dest, action, err := r.GetOpenAction()
+	if err != nil {
+		common.Log.Debug("ERROR: invalid open action: %v", err)
+	}
+	switch {
+	case dest != nil:
+		err = w.SetOpenActionDestination(dest)
+	case action != nil:
+		if c.fixAction(action.GetContext().ToPdfObject()) != nil {
+			err = w.SetOpenAction(action)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Document-level actions are not written.
+	if r.catalog.Get("AA") != nil {
+		c.removedAddActions++
+	}
+	if names, ok := core.GetDict(r.catalog.Get("Names")); ok && names.Get("JavaScript") != nil {
+		report.Fixes = append(report.Fixes, "removed document-level JavaScript")
+	}
+
+	fixes, err := c.setDocumentProperties(&w)
+	if err != nil {
+		return nil, err
+	}
+	report.Fixes = append(report.Fixes, fixes...)
+	fixes, err = c.copyCatalog(&w)
+	if err != nil {
+		return nil, err
+	}
+	report.Fixes = append(report.Fixes, fixes...)
+	if c.removedActions > 0 {
+		report.Fixes = append(report.Fixes, fmt.Sprintf("removed %d forbidden actions", c.removedActions))
+	}
+	if c.removedAddActions > 0 {
+		report.Fixes = append(report.Fixes, fmt.Sprintf("removed %d additional actions", c.removedAddActions))
+	}
+	if c.reencodedStreams > 0 {
+		report.Fixes = append(report.Fixes, fmt.Sprintf("re-encoded %d LZW streams with Flate", c.reencodedStreams))
+	}
+	if c.blendModes > 0 {
+		report.Fixes = append(report.Fixes, fmt.Sprintf("replaced %d non-standard blend modes with Normal", c.blendModes))
+	}
+
+	// Validate the converted document.
+	var buf bytes.Buffer
+	if err := w.Write(&buf); err != nil {
+		return nil, err
+	}
+	converted, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	report.Violations, err = converted.ValidatePdfA2b()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ws.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// fixObjects fixes the actions and the streams of the objects of the
+// document, which are shared with the converted document.
+func (c *pdfaConverter) fixObjects() {
+	for _, num := range c.r.GetObjectNums() {
+		obj, err := c.r.GetIndirectObjectByNumber(num)
+		if err != nil {
+			common.Log.Debug("ERROR: unable to load object %d: %v", num, err)
+			continue
+		}
+		switch t := obj.(type) {
+		case *core.PdfIndirectObject:
+			c.fixObject(t.PdfObject, 0)
+		case *core.PdfObjectStream:
+			c.fixStream(t, num)
+			c.fixObject(t.PdfObjectDictionary, 0)
+		}
+	}
+}
+
+// fixStream re-encodes `stream` with Flate if it is LZW compressed. Streams
+// combining LZW with other filters are left unchanged.
+func (c *pdfaConverter) fixStream(stream *core.PdfObjectStream, objNum int) {
+	filters := streamFilterNames(stream)
+	if len(filters) != 1 || filters[0] != core.StreamEncodingFilterNameLZW {
+		return
+	}
+	data, err := core.DecodeStream(stream)
+	if err != nil {
+		common.Log.Debug("ERROR: unable to decode stream %d: %v", objNum, err)
+		return
+	}
+	encoder := core.NewFlateEncoder()
+	encoded, err := encoder.EncodeBytes(data)
+	if err != nil {
+		common.Log.Debug("ERROR: unable to encode stream %d: %v", objNum, err)
+		return
+	}
+	stream.Stream = encoded
+	stream.Set("Filter", core.MakeName(encoder.GetFilterName()))
+	stream.Remove("DecodeParms")
+	stream.Set("Length", core.MakeInteger(int64(len(encoded))))
+	c.reencodedStreams++
+}
+
+// fixObject removes the forbidden actions and the additional actions of the
+// direct object `obj` and of the direct objects it contains.
+func (c *pdfaConverter) fixObject(obj core.PdfObject, depth int) {
+	if depth > 50 {
+		return
+	}
+	switch t := obj.(type) {
+	case *core.PdfObjectArray:
+		for _, elem := range t.Elements() {
+			c.fixObject(elem, depth+1)
+		}
+	case *core.PdfObjectDictionary:
+		if t.Get("AA") != nil {
+			t.Remove("AA")
+			c.removedAddActions++
+		}
+		if a := t.Get("A"); a != nil && c.fixAction(a) == nil {
+			t.Remove("A")
+		}
+		if bm := t.Get("BM"); bm != nil && !isPdfABlendMode(bm) {
+			t.Set("BM", core.MakeName("Normal"))
+			c.blendModes++
+		}
+		for _, key := range t.Keys() {
+			c.fixObject(t.Get(key), depth+1)
+		}
+	}
+}
+
+// fixAction removes the forbidden actions from the chain of actions started
+// by the action `obj`. Returns nil if the action itself is forbidden.
+func (c *pdfaConverter) fixAction(obj core.PdfObject) core.PdfObject {
+	d, ok := core.GetDict(obj)
+	if !ok {
+		return obj
+	}
+	if isPdfAForbiddenAction(d) {
+		c.removedActions++
+		return nil
+	}
+	switch next := core.TraceToDirectObject(d.Get("Next")).(type) {
+	case *core.PdfObjectDictionary:
+		if c.fixAction(next) == nil {
+			d.Remove("Next")
+		}
+	case *core.PdfObjectArray:
+		var actions []core.PdfObject
+		for _, action := range next.Elements() {
+			if c.fixAction(action) != nil {
+				actions = append(actions, action)
+			}
+		}
+		next.Clear()
+		next.Append(actions...)
+	}
+	return obj
+}
+
+// fixPage removes the forbidden actions and the additional actions of
+// `page` and of its annotations. The actions loaded in the page and
+// annotation models were already counted when fixing the objects.
+func (c *pdfaConverter) fixPage(page *PdfPage) error {
+	page.AA = nil
+	annotations, err := page.GetAnnotations()
+	if err != nil {
+		return err
+	}
+	for _, annot := range annotations {
+		var action, addActions *core.PdfObject
+		switch t := annot.GetContext().(type) {
+		case *PdfAnnotationLink:
+			action = &t.A
+		case *PdfAnnotationMovie:
+			action = &t.A
+		case *PdfAnnotationScreen:
+			action, addActions = &t.A, &t.AA
+		case *PdfAnnotationWidget:
+			action, addActions = &t.A, &t.AA
+		}
+		if action != nil {
+			if d, ok := core.GetDict(*action); ok && isPdfAForbiddenAction(d) {
+				*action = nil
+			}
+		}
+		if addActions != nil {
+			*addActions = nil
+		}
+	}
+	return nil
+}
+
+// fixOutlines removes the forbidden actions of the outline items of `node`.
+func (c *pdfaConverter) fixOutlines(node *PdfOutlineTreeNode, visited map[*PdfOutlineTreeNode]struct{}) {
+	for n := node.First; n != nil; {
+		if _, ok := visited[n]; ok {
+			return
+		}
+		visited[n] = struct{}{}
+
+		item, ok := n.context.(*PdfOutlineItem)
+		if !ok {
+			return
+		}
+		if item.A != nil && c.fixAction(item.A) == nil {
+			item.A = nil
+		}
+		c.fixObject(item.A, 0)
+		c.fixOutlines(&item.PdfOutlineTreeNode, visited)
+		n = item.Next
+	}
+}
+
+// copyCatalog copies the entries of the document catalog which are not
+// already set in the catalog of the converted document written by `w`, e.g.
+// the structure tree, the names and the optional content properties.
+// The entries forbidden by PDF/A-2 are removed. Returns the descriptions of
+// the fixes made.
+func (c *pdfaConverter) copyCatalog(w *PdfWriter) ([]string, error) {
+	var fixes []string
+	for _, key := range c.r.catalog.Keys() {
+		switch key {
+		case "Type", "Pages", "Version", "Metadata", "OutputIntents", "Outlines", "AcroForm",
+			"PageLabels", "OpenAction":
+			// Written with the models of the document.
+			continue
+		case "AA":
+			// Counted with the additional actions.
+			continue
+		case "NeedsRendering", "Requirements":
+			fixes = append(fixes, fmt.Sprintf("removed catalog entry %s", key))
+			continue
+		}
+		if w.catalog.Get(key) != nil {
+			continue
+		}
+
+		obj := core.ResolveReference(c.r.catalog.Get(key))
+		if err := c.r.traverseObjectData(obj); err != nil {
+			return nil, err
+		}
+		switch key {
+		case "Names":
+			// The JavaScript name tree is counted with the document-level
+			// JavaScript.
+			names, ok := core.GetDict(obj)
+			if !ok {
+				continue
+			}
+			copied := core.MakeDict()
+			for _, name := range names.Keys() {
+				if name != "JavaScript" {
+					copied.Set(name, names.Get(name))
+				}
+			}
+			if len(copied.Keys()) == 0 {
+				continue
+			}
+			obj = copied
+		case "Perms":
+			// Only the UR3 and DocMDP permissions are permitted.
+			perms, ok := core.GetDict(obj)
+			if !ok {
+				continue
+			}
+			copied := core.MakeDict()
+			for _, name := range perms.Keys() {
+				if name == "UR3" || name == "DocMDP" {
+					copied.Set(name, perms.Get(name))
+				} else {
+					fixes = append(fixes, fmt.Sprintf("removed %s permission", name))
+				}
+			}
+			if len(copied.Keys()) == 0 {
+				continue
+			}
+			obj = copied
+		case "OCProperties":
+			if n := removeOptionalContentAutoStates(obj); n > 0 {
+				fixes = append(fixes, fmt.Sprintf("removed %d optional content AS entries", n))
+			}
+		}
+
+		w.catalog.Set(key, obj)
+		if err := w.addObjects(obj); err != nil {
+			return nil, err
+		}
+	}
+	return fixes, nil
+}
+
+// removeOptionalContentAutoStates removes the AS entries, which are forbidden
+// by PDF/A-2, from the optional content configurations of the optional
+// content properties `ocProperties`. Returns the number of removed entries.
+func removeOptionalContentAutoStates(ocProperties core.PdfObject) int {
+	props, ok := core.GetDict(ocProperties)
+	if !ok {
+		return 0
+	}
+	configs := []core.PdfObject{props.Get("D")}
+	if arr, ok := core.GetArray(props.Get("Configs")); ok {
+		configs = append(configs, arr.Elements()...)
+	}
+	removed := 0
+	for _, obj := range configs {
+		if config, ok := core.GetDict(obj); ok && config.Get("AS") != nil {
+			config.Remove("AS")
+			removed++
+		}
+	}
+	return removed
+}
+
+// setDocumentProperties sets the file identifier, the output intent and the
+// XMP metadata of the converted document written by `w`. Returns the
+// descriptions of the fixes made.
+func (c *pdfaConverter) setDocumentProperties(w *PdfWriter) ([]string, error) {
+	var fixes []string
+	id := md5.Sum([]byte(fmt.Sprintf("%d-%p", time.Now().UnixNano(), w)))
+	w.ids = core.MakeArray(core.MakeHexString(string(id[:])), core.MakeHexString(string(id[:])))
+
+	// Output intent.
+	v := &pdfaValidator{r: c.r}
+	v.checkOutputIntent()
+	if v.hasOutputIntent {
+		intents := c.r.catalog.Get("OutputIntents")
+		w.catalog.Set("OutputIntents", intents)
+		if err := w.addObjects(intents); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := w.AddOutputIntent(NewPdfOutputIntentSRGB()); err != nil {
+			return nil, err
+		}
+		fixes = append(fixes, "added sRGB output intent")
+	}
+
+	// XMP metadata, made consistent with the information dictionary.
+	m, err := c.r.GetXMPMetadata()
+	if err != nil {
+		common.Log.Debug("ERROR: invalid XMP metadata: %v", err)
+		m = nil
+	}
+	if m == nil {
+		m = NewXMPMetadata()
+		fixes = append(fixes, "added XMP metadata")
+	}
+	if m.PDFAPart != 2 || m.PDFAConformance != "B" {
+		m.PDFAPart = 2
+		m.PDFAConformance = "B"
+		fixes = append(fixes, "set PDF/A-2b identification")
+	}
+	info, err := c.r.GetInfoDict()
+	if err != nil {
+		return nil, err
+	}
+	m.SyncFromInfo(info)
+	m.SyncToInfo(w.GetInfoDict())
+	m.SyncFromInfo(w.GetInfoDict())
+	if err := w.SetXMPMetadata(m); err != nil {
+		return nil, err
+	}
+	return fixes, nil
+}
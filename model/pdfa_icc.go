@@ -0,0 +1,106 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// srgbICCProfile returns an ICC (version 2.1) display profile describing the
+// sRGB IEC61966-2.1 color space, used as the destination profile of sRGB
+// output intents.
+func srgbICCProfile() []byte {
+	type iccTag struct {
+		sig  string
+		data []byte
+	}
+
+	xyz := func(x, y, z float64) []byte {
+		var b bytes.Buffer
+		b.WriteString("XYZ \x00\x00\x00\x00")
+		for _, v := range []float64{x, y, z} {
+			binary.Write(&b, binary.BigEndian, int32(math.Round(v*65536)))
+		}
+		return b.Bytes()
+	}
+
+	// The tone reproduction curve is sampled from the sRGB transfer function.
+	var trc bytes.Buffer
+	trc.WriteString("curv\x00\x00\x00\x00")
+	const samples = 1024
+	binary.Write(&trc, binary.BigEndian, uint32(samples))
+	for i := 0; i < samples; i++ {
+		v := float64(i) / (samples - 1)
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		binary.Write(&trc, binary.BigEndian, uint16(math.Round(v*65535)))
+	}
+
+	const name = "sRGB IEC61966-2.1"
+	var desc bytes.Buffer
+	desc.WriteString("desc\x00\x00\x00\x00")
+	binary.Write(&desc, binary.BigEndian, uint32(len(name)+1))
+	desc.WriteString(name + "\x00")
+	// Empty Unicode and ScriptCode descriptions.
+	desc.Write(make([]byte, 4+4+2+1+67))
+
+	tags := []iccTag{
+		{"desc", desc.Bytes()},
+		{"cprt", []byte("text\x00\x00\x00\x00No copyright, use freely\x00")},
+		{"wtpt", xyz(0.9642, 1.0, 0.8249)},
+		{"rXYZ", xyz(0.4360747, 0.2225045, 0.0139322)},
+		{"gXYZ", xyz(0.3850649, 0.7168786, 0.0971045)},
+		{"bXYZ", xyz(0.1430804, 0.0606169, 0.7141733)},
+		{"rTRC", trc.Bytes()},
+		{"gTRC", trc.Bytes()},
+		{"bTRC", trc.Bytes()},
+	}
+
+	// Lay out the tag data after the header and the tag table, aligned on
+	// 4 bytes. The tone reproduction curves share the same data.
+	offset := 128 + 4 + 12*len(tags)
+	offsets := make([]int, len(tags))
+	var data bytes.Buffer
+	for i, tag := range tags {
+		if i > 0 && bytes.Equal(tag.data, tags[i-1].data) {
+			offsets[i] = offsets[i-1]
+			continue
+		}
+		offsets[i] = offset + data.Len()
+		data.Write(tag.data)
+		for data.Len()%4 != 0 {
+			data.WriteByte(0)
+		}
+	}
+	size := offset + data.Len()
+
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, uint32(size))
+	b.WriteString("\x00\x00\x00\x00") // Preferred CMM.
+	b.WriteString("\x02\x10\x00\x00") // Version 2.1.
+	b.WriteString("mntrRGB XYZ ")     // Class, color space and PCS.
+	// Creation date: 2020-01-01.
+	b.Write([]byte{0x07, 0xe4, 0, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+	b.WriteString("acsp")
+	b.Write(make([]byte, 24)) // Platform, flags, device manufacturer, model and attributes.
+	b.Write(make([]byte, 4))  // Perceptual rendering intent.
+	b.Write(xyz(0.9642, 1.0, 0.8249)[8:])
+	b.Write(make([]byte, 128-b.Len()))
+
+	binary.Write(&b, binary.BigEndian, uint32(len(tags)))
+	for i, tag := range tags {
+		b.WriteString(tag.sig)
+		binary.Write(&b, binary.BigEndian, uint32(offsets[i]))
+		binary.Write(&b, binary.BigEndian, uint32(len(tag.data)))
+	}
+	b.Write(data.Bytes())
+	return b.Bytes()
+}
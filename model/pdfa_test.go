@@ -0,0 +1,178 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/testutils"
+)
+
+// pdfaViolationRules returns the rules of `violations`.
+func pdfaViolationRules(violations []PdfAViolation) map[PdfARule]bool {
+	rules := map[PdfARule]bool{}
+	for _, v := range violations {
+		rules[v.Rule] = true
+	}
+	return rules
+}
+
+func TestValidatePdfA2bCompliant(t *testing.T) {
+	m := NewXMPMetadata()
+	m.PDFAPart = 2
+	m.PDFAConformance = "B"
+	m.Title = "Archive"
+	xmp, err := m.Bytes()
+	require.NoError(t, err)
+
+	data := testutils.BuildRawPdf([]string{
+		"<< /Type /Catalog /Pages 2 0 R /Metadata 5 0 R /OutputIntents [<< /Type /OutputIntent " +
+			"/S /GTS_PDFA1 /OutputConditionIdentifier (sRGB) /DestOutputProfile 6 0 R >>] >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Contents 4 0 R /Resources << >> " +
+			"/Group << /S /Transparency >> >>",
+		testutils.RawStream("", []byte("0 0 1 rg 10 10 100 100 re f")),
+		testutils.RawStream("/Type /Metadata /Subtype /XML", xmp),
+		testutils.RawStream("/N 3", srgbICCProfile()),
+		"<< /Title (Archive) >>",
+	}, &testutils.RawPdfOptions{
+		Header:         "%PDF-1.7\n%\xe2\xe3\xcf\xd3\n",
+		TrailerEntries: "/Info 7 0 R /ID [<0102> <0102>]",
+	})
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	violations, err := reader.ValidatePdfA2b()
+	require.NoError(t, err)
+	require.Empty(t, violations)
+}
+
+func TestPdfA2bConversion(t *testing.T) {
+	lzw := core.NewLZWEncoder()
+	lzw.EarlyChange = 0
+	content, err := lzw.EncodeBytes([]byte("BT /F1 12 Tf 10 10 Td (Hello) Tj ET"))
+	require.NoError(t, err)
+
+	data := testutils.BuildRawPdf([]string{
+		"<< /Type /Catalog /Pages 2 0 R /OpenAction << /S /JavaScript /JS (app.alert(1)) >> " +
+			"/Names << /JavaScript << /Names [] >> /Dests << /Names [(first) [3 0 R /Fit]] >> >> " +
+			"/StructTreeRoot 7 0 R /MarkInfo << /Marked true >> /Lang (en-US) " +
+			"/ViewerPreferences << /DisplayDocTitle true >> /NeedsRendering false " +
+			"/OCProperties << /OCGs [8 0 R] /D << /Name (Default) /AS [] >> >> >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Contents 4 0 R " +
+			"/Resources << /Font << /F1 5 0 R >> /ExtGState << /GS1 << /BM /Custom >> >> >> " +
+			"/Group << /S /Transparency >> /AA << /O << /S /Named /N /Print >> >> /Annots [6 0 R] >>",
+		testutils.RawStream("/Filter /LZWDecode /DecodeParms << /EarlyChange 0 >>", content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Annot /Subtype /Link /Rect [0 0 50 50] /A << /S /Launch /F (calc.exe) >> >>",
+		"<< /Type /StructTreeRoot /K << /Type /StructElem /S /Document /P 7 0 R /K [] >> >>",
+		"<< /Type /OCG /Name (Layer) >>",
+	}, &testutils.RawPdfOptions{Header: "%PDF-1.4\n"})
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	violations, err := reader.ValidatePdfA2b()
+	require.NoError(t, err)
+	require.Equal(t, map[PdfARule]bool{
+		PdfARuleFileStructure: true,
+		PdfARuleStreams:       true,
+		PdfARuleMetadata:      true,
+		PdfARuleOutputIntent:  true,
+		PdfARuleFonts:         true,
+		PdfARuleActions:       true,
+		PdfARuleTransparency:  true,
+	}, pdfaViolationRules(violations))
+
+	// Everything but the font embedding is fixed by the conversion.
+	var buf bytes.Buffer
+	report, err := reader.ConvertToPdfA2b(&buf)
+	require.NoError(t, err)
+	fixes := strings.Join(report.Fixes, "\n")
+	for _, fix := range []string{
+		"added sRGB output intent",
+		"added XMP metadata",
+		"set PDF/A-2b identification",
+		"removed document-level JavaScript",
+		"removed 2 forbidden actions",
+		"removed 1 additional actions",
+		"re-encoded 1 LZW streams with Flate",
+		"replaced 1 non-standard blend modes with Normal",
+		"removed catalog entry NeedsRendering",
+		"removed 1 optional content AS entries",
+	} {
+		require.Contains(t, fixes, fix)
+	}
+	require.NotEmpty(t, report.Violations)
+	for _, v := range report.Violations {
+		require.Equal(t, PdfARuleFonts, v.Rule, v.String())
+	}
+
+	converted, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	m, err := converted.GetXMPMetadata()
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	require.Equal(t, 2, m.PDFAPart)
+	require.Equal(t, "B", m.PDFAConformance)
+	dest, action, err := converted.GetOpenAction()
+	require.NoError(t, err)
+	require.Nil(t, dest)
+	require.Nil(t, action)
+
+	// The other catalog entries are kept, without the forbidden ones.
+	for _, key := range []core.PdfObjectName{"StructTreeRoot", "MarkInfo", "Lang", "ViewerPreferences", "OCProperties"} {
+		require.NotNil(t, converted.catalog.Get(key), key)
+	}
+	require.Nil(t, converted.catalog.Get("NeedsRendering"))
+	ocProperties, ok := core.GetDict(converted.catalog.Get("OCProperties"))
+	require.True(t, ok)
+	config, ok := core.GetDict(ocProperties.Get("D"))
+	require.True(t, ok)
+	require.Nil(t, config.Get("AS"))
+	names, ok := core.GetDict(converted.catalog.Get("Names"))
+	require.True(t, ok)
+	require.Equal(t, []core.PdfObjectName{"Dests"}, names.Keys())
+	firstDest, err := converted.GetNamedDestination("first")
+	require.NoError(t, err)
+	require.NotNil(t, firstDest)
+
+	page, err := converted.GetPage(1)
+	require.NoError(t, err)
+	require.Equal(t, page.GetPageAsIndirectObject(), firstDest.Page)
+	annots, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 1)
+	require.Nil(t, annots[0].GetContext().(*PdfAnnotationLink).A)
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	require.Contains(t, contents, "(Hello) Tj")
+}
+
+func TestSRGBICCProfile(t *testing.T) {
+	profile := srgbICCProfile()
+	require.Equal(t, "acsp", string(profile[36:40]))
+	size := int(profile[0])<<24 | int(profile[1])<<16 | int(profile[2])<<8 | int(profile[3])
+	require.Equal(t, len(profile), size)
+
+	cs, err := newPdfColorspaceICCBasedFromPdfObject(core.MakeArray(core.MakeName("ICCBased"),
+		mustMakeICCStream(t, profile)))
+	require.NoError(t, err)
+	require.Equal(t, 3, cs.GetNumComponents())
+}
+
+// mustMakeICCStream returns an ICC profile stream containing `profile`.
+func mustMakeICCStream(t *testing.T, profile []byte) *core.PdfObjectStream {
+	stream, err := core.MakeStream(profile, core.NewRawEncoder())
+	require.NoError(t, err)
+	stream.Set("N", core.MakeInteger(3))
+	return stream
+}
@@ -0,0 +1,150 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"fmt"
+
+	"github.com/unidoc/unipdf/v3/common"
+)
+
+// ProgressStage is the operation whose progress is reported.
+type ProgressStage int
+
+// Operations whose progress is reported.
+const (
+	// ProgressLoading is the loading of the objects of a document by a PdfReader. Done and Total
+	// are numbers of objects, Total being the number of entries of the cross-reference table.
+	ProgressLoading ProgressStage = iota
+
+	// ProgressExtracting is the extraction of the content of the pages of a document. Done and
+	// Total are numbers of pages.
+	ProgressExtracting
+
+	// ProgressWriting is the serialization of the objects of a document by a PdfWriter. Done and
+	// Total are numbers of objects and Bytes is the number of bytes written.
+	ProgressWriting
+)
+
+// String returns the name of the stage.
+func (s ProgressStage) String() string {
+	switch s {
+	case ProgressLoading:
+		return "loading"
+	case ProgressExtracting:
+		return "extracting"
+	case ProgressWriting:
+		return "writing"
+	}
+	return fmt.Sprintf("ProgressStage(%d)", int(s))
+}
+
+// Progress is a progress report. Done increases monotonically up to Total, the last report of an
+// operation completed successfully having Done equal to Total.
+type Progress struct {
+	Stage ProgressStage
+	Done  int64
+	Total int64
+	Bytes int64 // Bytes written, for ProgressWriting.
+}
+
+// ProgressFunc is called to report the progress of long operations, e.g. to update progress bars.
+// It is called synchronously by the goroutine doing the operation, at most about 100 times per
+// operation, and must return quickly. It must not use the reader or writer reporting the progress.
+// A ProgressFunc that panics is not called anymore, the panic being recovered and logged.
+type ProgressFunc func(progress Progress)
+
+// progressSteps is the maximum number of intermediate reports per operation.
+const progressSteps = 100
+
+// ProgressReporter calls a ProgressFunc at a bounded frequency. A nil *ProgressReporter reports
+// nothing.
+type ProgressReporter struct {
+	fn       ProgressFunc
+	progress Progress
+	next     int64 // Done value of the next intermediate report.
+	logger   common.StructuredLogger
+}
+
+// NewProgressReporter returns a ProgressReporter calling `fn` with the progress of the operation
+// `stage` of `total` units, or nil if `fn` is nil. The progress is reported when at least 1% of
+// `total` has been done since the last report. Panics of `fn` are logged to `logger`, or to the
+// global common.Log if nil.
+func NewProgressReporter(fn ProgressFunc, stage ProgressStage, total int64,
+	logger common.StructuredLogger) *ProgressReporter {
+	if fn == nil {
+		return nil
+	}
+	if total < 0 {
+		total = 0
+	}
+	return &ProgressReporter{
+		fn:       fn,
+		progress: Progress{Stage: stage, Total: total},
+		logger:   logger,
+	}
+}
+
+// Update sets the number of units done to `done`, and the number of bytes written to `bytes` if
+// positive, reporting the progress if enough has been done since the last report. `done` is
+// clamped to the range between the previous value and the total.
+func (p *ProgressReporter) Update(done, bytes int64) {
+	if p == nil || p.fn == nil {
+		return
+	}
+	if done > p.progress.Total {
+		done = p.progress.Total
+	}
+	if done > p.progress.Done {
+		p.progress.Done = done
+	}
+	if bytes > p.progress.Bytes {
+		p.progress.Bytes = bytes
+	}
+	if p.progress.Done < p.next || p.progress.Done == p.progress.Total {
+		// The completion is reported by Complete.
+		return
+	}
+	step := p.progress.Total / progressSteps
+	if step < 1 {
+		step = 1
+	}
+	p.next = p.progress.Done + step
+	p.call()
+}
+
+// Add increments the number of units done by `n`, see Update.
+func (p *ProgressReporter) Add(n, bytes int64) {
+	if p == nil {
+		return
+	}
+	p.Update(p.progress.Done+n, bytes)
+}
+
+// Complete reports the completion of the operation, with `bytes` bytes written if positive.
+func (p *ProgressReporter) Complete(bytes int64) {
+	if p == nil || p.fn == nil {
+		return
+	}
+	p.progress.Done = p.progress.Total
+	if bytes > p.progress.Bytes {
+		p.progress.Bytes = bytes
+	}
+	p.call()
+	p.fn = nil
+}
+
+// call calls the ProgressFunc, disabling it if it panics.
+func (p *ProgressReporter) call() {
+	defer func() {
+		if r := recover(); r != nil {
+			p.fn = nil
+			common.StructuredOrGlobal(p.logger).Error("progress callback panicked, disabling it",
+				"stage", p.progress.Stage.String(), "panic", fmt.Sprint(r))
+		}
+	}()
+	p.fn(p.progress)
+}
@@ -0,0 +1,129 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// progressRecorder records the progress reports.
+type progressRecorder struct {
+	reports []Progress
+}
+
+func (r *progressRecorder) report(p Progress) {
+	r.reports = append(r.reports, p)
+}
+
+// check checks that the progress of `stage` increases monotonically up to its completion, with at
+// most `max` reports.
+func (r *progressRecorder) check(t *testing.T, stage ProgressStage, max int) Progress {
+	require.NotEmpty(t, r.reports)
+	require.LessOrEqual(t, len(r.reports), max)
+	for i, p := range r.reports {
+		require.Equal(t, stage, p.Stage)
+		require.True(t, p.Done <= p.Total, "%+v", p)
+		if i > 0 {
+			prev := r.reports[i-1]
+			require.Equal(t, prev.Total, p.Total)
+			require.True(t, p.Done >= prev.Done && p.Bytes >= prev.Bytes, "%+v %+v", prev, p)
+		}
+	}
+	last := r.reports[len(r.reports)-1]
+	require.Equal(t, last.Total, last.Done)
+	return last
+}
+
+func TestProgressReporter(t *testing.T) {
+	var rec progressRecorder
+	p := NewProgressReporter(rec.report, ProgressWriting, 10000, nil)
+	for i := int64(0); i <= 10000; i++ {
+		p.Update(i, i*10)
+	}
+	// Values going backwards or beyond the total are clamped.
+	p.Update(5, 0)
+	p.Add(20000, 0)
+	p.Complete(200000)
+	last := rec.check(t, ProgressWriting, progressSteps+2)
+	require.Equal(t, Progress{Stage: ProgressWriting, Done: 10000, Total: 10000, Bytes: 200000}, last)
+
+	// Reporting is disabled after completion.
+	p.Update(10000, 0)
+	p.Complete(0)
+	require.Equal(t, last, rec.reports[len(rec.reports)-1])
+
+	// Nil reporters report nothing.
+	require.Nil(t, NewProgressReporter(nil, ProgressLoading, 10, nil))
+	var nilReporter *ProgressReporter
+	nilReporter.Update(1, 0)
+	nilReporter.Add(1, 0)
+	nilReporter.Complete(0)
+}
+
+// TestProgressPanic checks that a panicking callback is disabled and the panic logged.
+func TestProgressPanic(t *testing.T) {
+	logger := common.NewRecordingLogger()
+	var calls int
+	p := NewProgressReporter(func(Progress) {
+		calls++
+		panic("broken progress bar")
+	}, ProgressLoading, 10, logger)
+	for i := int64(0); i <= 10; i++ {
+		p.Update(i, 0)
+	}
+	p.Complete(0)
+	require.Equal(t, 1, calls)
+	require.Equal(t, []common.LogRecord{{
+		Level:   common.LogLevelError,
+		Message: "progress callback panicked, disabling it",
+		Fields:  []interface{}{"stage", "loading", "panic", "broken progress bar"},
+	}}, logger.Records())
+
+	// A panic does not interrupt writing.
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(NewPdfPage()))
+	w.SetProgress(func(Progress) { panic("broken") })
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	_, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+}
+
+// TestReadWriteProgress checks the progress reported when writing and reading a multi-page
+// document.
+func TestReadWriteProgress(t *testing.T) {
+	const numPages = 300
+	for _, useObjectStreams := range []bool{false, true} {
+		w := NewPdfWriter()
+		for i := 0; i < numPages; i++ {
+			page := NewPdfPage()
+			require.NoError(t, page.SetContentStreams([]string{"0 0 m 100 100 l S"}, core.NewRawEncoder()))
+			require.NoError(t, w.AddPage(page))
+		}
+		w.SetObjectStreams(useObjectStreams)
+		var writeProgress progressRecorder
+		w.SetProgress(writeProgress.report)
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+		last := writeProgress.check(t, ProgressWriting, progressSteps+2)
+		require.True(t, last.Total > 2*numPages, "%+v", last)
+		require.Equal(t, int64(buf.Len()), last.Bytes)
+		require.True(t, len(writeProgress.reports) > 10)
+
+		var readProgress progressRecorder
+		reader, err := NewPdfReaderWithOpts(bytes.NewReader(buf.Bytes()), &ReaderOpts{Progress: readProgress.report})
+		require.NoError(t, err)
+		last = readProgress.check(t, ProgressLoading, progressSteps+2)
+		require.Equal(t, int64(len(reader.GetObjectNums())), last.Total)
+		require.True(t, len(readProgress.reports) > 10)
+	}
+}
@@ -6,6 +6,8 @@
 package model
 
 import (
+	"crypto"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -18,6 +20,11 @@ import (
 
 // PdfReader represents a PDF file reader. It is a frontend to the lower level parsing mechanism and provides
 // a higher level access to work with PDF structure and information, such as the page structure etc.
+//
+// Once the reader is created and the document decrypted if needed, its pages can be processed
+// concurrently, e.g. to extract their text in parallel goroutines, including in lazy-loading mode:
+// the objects are looked up and cached safely by the underlying parser. A page, and the objects it
+// uses, must not be modified while other goroutines read them.
 type PdfReader struct {
 	parser         *core.PdfParser
 	root           core.PdfObject
@@ -36,45 +43,71 @@ type PdfReader struct {
 	// than loading entire document into memory on load.
 	isLazy bool
 
+	// Callback returning the password of encrypted documents, if any.
+	passwordCallback PasswordCallback
+
 	// For tracking traversal (cache).
 	traversed map[core.PdfObject]struct{}
 	rs        io.ReadSeeker
+
+	// Depth of the page tree node being loaded.
+	pageTreeDepth int
+
+	logger common.StructuredLogger // See ReaderOpts.Logger.
+
+	// Reports the progress of the loading of the structure, see ReaderOpts.Progress.
+	progress *ProgressReporter
 }
 
+// ReaderOpts defines the options of PdfReader.
+type ReaderOpts struct {
+	// Password is the password of encrypted documents, tried as user password and then as
+	// owner password. The empty password is tried as well.
+	Password string
+
+	// LazyLoad enables the lazy-loading mode (see NewPdfReaderLazy).
+	LazyLoad bool
+
+	// PasswordCallback is called to obtain the password of encrypted documents which cannot be
+	// decrypted with Password, when their protected objects are first accessed.
+	PasswordCallback PasswordCallback
+
+	// ParserConfig defines the limits enforced when parsing the document, its zero fields being
+	// set to the default limits (see core.ParserConfig).
+	ParserConfig core.ParserConfig
+
+	// Logger receives the logs of the reader and of its parser, unless ParserConfig.Logger is set,
+	// and of the extractors of its pages. The global common.Log is used if nil.
+	Logger common.StructuredLogger
+
+	// Progress, if set, is called with the number of objects loaded out of the number of objects
+	// of the cross-reference table while the document structure is loaded, i.e. when creating
+	// the reader or when decrypting encrypted documents. In lazy-loading mode, the objects are
+	// loaded on demand and the loading completes with fewer objects loaded.
+	Progress ProgressFunc
+}
+
+// NewReaderOpts returns a new instance of ReaderOpts with the default options.
+func NewReaderOpts() *ReaderOpts {
+	return &ReaderOpts{}
+}
+
+// PasswordCallback returns the password to decrypt a document with, `attempt` being 1 at the first
+// call and increasing when the passwords returned do not decrypt the document. Each password is
+// tried as user password and then as owner password. Returning false gives up decrypting.
+type PasswordCallback func(attempt int) (password []byte, ok bool)
+
 // NewPdfReader returns a new PdfReader for an input io.ReadSeeker interface. Can be used to read PDF from
 // memory or file. Immediately loads and traverses the PDF structure including pages and page contents (if
 // not encrypted). Loads entire document structure into memory.
 // Alternatively a lazy-loading reader can be created with NewPdfReaderLazy which loads only references,
 // and references are loaded from disk into memory on an as-needed basis.
+//
+// Encrypted documents with an empty user password are decrypted automatically. Other encrypted
+// documents need to be decrypted with Decrypt before accessing their contents, which fails with
+// ErrEncrypted otherwise.
 func NewPdfReader(rs io.ReadSeeker) (*PdfReader, error) {
-	pdfReader := &PdfReader{
-		rs:           rs,
-		traversed:    map[core.PdfObject]struct{}{},
-		modelManager: newModelManager(),
-		isLazy:       false,
-	}
-
-	// Create the parser, loads the cross reference table and trailer.
-	parser, err := core.NewParser(rs)
-	if err != nil {
-		return nil, err
-	}
-	pdfReader.parser = parser
-
-	isEncrypted, err := pdfReader.IsEncrypted()
-	if err != nil {
-		return nil, err
-	}
-
-	// Load pdf doc structure if not encrypted.
-	if !isEncrypted {
-		err = pdfReader.loadStructure()
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	return pdfReader, nil
+	return NewPdfReaderWithOpts(rs, nil)
 }
 
 // NewPdfReaderLazy creates a new PdfReader for `rs` in lazy-loading mode. The difference
@@ -82,25 +115,56 @@ func NewPdfReader(rs io.ReadSeeker) (*PdfReader, error) {
 // rather than entire structure being loaded into memory on reader creation.
 // Note that it may make sense to use the lazy-load reader when processing only parts of files,
 // rather than loading entire file into memory. Example: splitting a few pages from a large PDF file.
+// The page dictionaries are loaded by walking the page tree without loading the page contents,
+// and the data of the stream objects of unencrypted documents is only read from `rs` when needed.
 func NewPdfReaderLazy(rs io.ReadSeeker) (*PdfReader, error) {
+	return NewPdfReaderWithOpts(rs, &ReaderOpts{LazyLoad: true})
+}
+
+// NewPdfReaderWithOpts creates a new PdfReader for `rs` with the options `opts` (default options
+// if nil). Encrypted documents are decrypted with opts.Password, or the empty password, if
+// possible. Otherwise the reader is created without decrypting the document, which is decrypted
+// with the passwords returned by opts.PasswordCallback when its contents are first accessed, or
+// can be decrypted with Decrypt.
+func NewPdfReaderWithOpts(rs io.ReadSeeker, opts *ReaderOpts) (*PdfReader, error) {
+	if opts == nil {
+		opts = NewReaderOpts()
+	}
 	pdfReader := &PdfReader{
-		rs:           rs,
-		traversed:    map[core.PdfObject]struct{}{},
-		modelManager: newModelManager(),
-		isLazy:       true,
+		rs:               rs,
+		traversed:        map[core.PdfObject]struct{}{},
+		modelManager:     newModelManager(),
+		isLazy:           opts.LazyLoad,
+		passwordCallback: opts.PasswordCallback,
+		logger:           opts.Logger,
 	}
 
 	// Create the parser, loads the cross reference table and trailer.
-	parser, err := core.NewParser(rs)
+	config := opts.ParserConfig
+	if config.Logger == nil {
+		config.Logger = opts.Logger
+	}
+	parser, err := core.NewParserWithConfig(rs, config)
 	if err != nil {
 		return nil, err
 	}
 	pdfReader.parser = parser
+	pdfReader.progress = NewProgressReporter(opts.Progress, ProgressLoading,
+		int64(len(parser.GetObjectNums())), pdfReader.logger)
+	if pdfReader.progress != nil {
+		pdfReader.progress.Update(0, 0)
+		parser.SetObjectLoadedCallback(func(numLoaded int) {
+			pdfReader.progress.Update(int64(numLoaded), 0)
+		})
+	}
 
 	isEncrypted, err := pdfReader.IsEncrypted()
 	if err != nil {
 		return nil, err
 	}
+	if opts.LazyLoad {
+		parser.SetLazyLoading(true)
+	}
 
 	// Load pdf doc structure if not encrypted.
 	if !isEncrypted {
@@ -108,16 +172,60 @@ func NewPdfReaderLazy(rs io.ReadSeeker) (*PdfReader, error) {
 		if err != nil {
 			return nil, err
 		}
+		return pdfReader, nil
 	}
 
+	// Decrypt if possible without asking for a password.
+	if pdfReader.isPubKeyEncrypted() {
+		return pdfReader, nil
+	}
+	success, err := parser.Decrypt([]byte(opts.Password))
+	if err != nil {
+		common.Log.Debug("ERROR: Failed to decrypt: %v", err)
+		return pdfReader, nil
+	}
+	if success {
+		err = pdfReader.loadStructure()
+		if err != nil {
+			return nil, err
+		}
+	}
 	return pdfReader, nil
 }
 
+// Logger returns the logger of the reader, see ReaderOpts.Logger.
+func (r *PdfReader) Logger() common.StructuredLogger {
+	return common.StructuredOrGlobal(r.logger)
+}
+
+// NewPdfReaderFromReaderAt creates a new PdfReader for a PDF file of `size` bytes read via the
+// ReaderAt `r`, e.g. a file in an object storage accessed with range requests. The reader is in
+// lazy-loading mode (see NewPdfReaderLazy), so that only the byte ranges of the objects needed are
+// read from `r`.
+func NewPdfReaderFromReaderAt(r io.ReaderAt, size int64) (*PdfReader, error) {
+	return NewPdfReaderLazy(io.NewSectionReader(r, 0, size))
+}
+
 // PdfVersion returns version of the PDF file.
 func (r *PdfReader) PdfVersion() core.Version {
 	return r.parser.PdfVersion()
 }
 
+// WasRepaired returns true if the cross-reference table of the PDF file was
+// missing or broken and has been rebuilt. Repairs can also happen when
+// objects are loaded, so the status can change while the document is read.
+func (r *PdfReader) WasRepaired() bool {
+	return r.parser.WasRepaired()
+}
+
+// GetRecoveries returns the number of syntax errors recovered from when
+// parsing the PDF file, by kind (e.g. wrong stream lengths or missing endobj
+// keywords). Objects are loaded on demand, so the counts can increase while
+// the document is read.
+func (r *PdfReader) GetRecoveries() map[core.RecoveryType]int {
+	return r.parser.GetRecoveries()
+}
+
 // IsEncrypted returns true if the PDF file is encrypted.
 func (r *PdfReader) IsEncrypted() (bool, error) {
 	return r.parser.IsEncrypted()
@@ -129,10 +237,67 @@ func (r *PdfReader) GetEncryptionMethod() string {
 	return crypter.String()
 }
 
+// GetEncryptionDetails returns the details of the encryption method used (security handler, key
+// length, permissions...), which are available before the document is decrypted. Returns nil if
+// the document is not encrypted.
+func (r *PdfReader) GetEncryptionDetails() *core.EncryptionDetails {
+	crypter := r.parser.GetCrypter()
+	if crypter == nil {
+		return nil
+	}
+	details := crypter.GetEncryptionDetails()
+	return &details
+}
+
+// IsDecrypted returns true if the PDF file is not encrypted, or has been decrypted.
+func (r *PdfReader) IsDecrypted() bool {
+	return r.parser.GetCrypter() == nil || r.parser.IsAuthenticated()
+}
+
+// isPubKeyEncrypted returns true if the PDF file is encrypted with the public-key security
+// handler, which is decrypted with certificates rather than passwords.
+func (r *PdfReader) isPubKeyEncrypted() bool {
+	crypter := r.parser.GetCrypter()
+	return crypter != nil && crypter.GetEncryptionDetails().Filter == "Adobe.PubSec"
+}
+
+// checkDecrypted returns ErrEncrypted if the PDF file is encrypted and cannot be decrypted, the
+// passwords returned by the password callback of the reader being tried if not decrypted yet.
+func (r *PdfReader) checkDecrypted() error {
+	if r.IsDecrypted() {
+		return nil
+	}
+	if r.passwordCallback == nil || r.isPubKeyEncrypted() {
+		return ErrEncrypted
+	}
+	for attempt := 1; ; attempt++ {
+		password, ok := r.passwordCallback(attempt)
+		if !ok {
+			if attempt > 1 {
+				return ErrWrongPassword
+			}
+			return ErrEncrypted
+		}
+		success, err := r.Decrypt(password)
+		if err != nil {
+			return err
+		}
+		if success {
+			return nil
+		}
+		common.Log.Debug("Wrong password (attempt %d)", attempt)
+	}
+}
+
 // Decrypt decrypts the PDF file with a specified password.  Also tries to
 // decrypt with an empty password.  Returns true if successful,
 // false otherwise.
+// Documents which are decrypted already are not decrypted again, but the
+// password is checked.
 func (r *PdfReader) Decrypt(password []byte) (bool, error) {
+	if r.parser.GetCrypter() != nil && r.parser.IsAuthenticated() && r.catalog != nil {
+		return r.checkPassword(password)
+	}
 	success, err := r.parser.Decrypt(password)
 	if err != nil {
 		return false, err
@@ -150,6 +315,39 @@ func (r *PdfReader) Decrypt(password []byte) (bool, error) {
 	return true, nil
 }
 
+// checkPassword returns true if `password`, or the empty password, grants access to the PDF file.
+func (r *PdfReader) checkPassword(password []byte) (bool, error) {
+	for _, p := range [][]byte{password, nil} {
+		canView, _, err := r.parser.CheckAccessRights(p)
+		if err != nil || canView {
+			return canView, err
+		}
+	}
+	return false, nil
+}
+
+// DecryptWithCertificate decrypts a PDF file encrypted with the public-key security handler
+// (Adobe.PubSec), with the recipient certificate `cert` and its private key `key` (for example
+// an *rsa.PrivateKey, or a crypto.Decrypter backed by a hardware token). Returns true if
+// successful, false if `cert` is not a recipient of the document.
+func (r *PdfReader) DecryptWithCertificate(cert *x509.Certificate, key crypto.Decrypter) (bool, error) {
+	success, err := r.parser.DecryptWithCertificate(cert, key)
+	if err != nil {
+		return false, err
+	}
+	if !success {
+		return false, nil
+	}
+
+	err = r.loadStructure()
+	if err != nil {
+		common.Log.Debug("ERROR: Fail to load structure (%s)", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
 // CheckAccessRights checks access rights and permissions for a specified password.  If either user/owner
 // password is specified,  full rights are granted, otherwise the access rights are specified by the
 // Permissions flag.
@@ -163,19 +361,19 @@ func (r *PdfReader) CheckAccessRights(password []byte) (bool, security.Permissio
 
 // Loads the structure of the pdf file: pages, outlines, etc.
 func (r *PdfReader) loadStructure() error {
-	if r.parser.GetCrypter() != nil && !r.parser.IsAuthenticated() {
-		return fmt.Errorf("file need to be decrypted first")
+	if !r.IsDecrypted() {
+		return ErrEncrypted
 	}
 
 	trailerDict := r.parser.GetTrailer()
 	if trailerDict == nil {
-		return fmt.Errorf("missing trailer")
+		return fmt.Errorf("missing trailer: %w", ErrRequiredAttributeMissing)
 	}
 
 	// Catalog.
 	root, ok := trailerDict.Get("Root").(*core.PdfObjectReference)
 	if !ok {
-		return fmt.Errorf("invalid Root (trailer: %s)", trailerDict)
+		return fmt.Errorf("invalid Root (trailer: %s): %w", trailerDict, ErrTypeCheck)
 	}
 	oc, err := r.parser.LookupByReference(*root)
 	if err != nil {
@@ -185,19 +383,19 @@ func (r *PdfReader) loadStructure() error {
 	pcatalog, ok := oc.(*core.PdfIndirectObject)
 	if !ok {
 		common.Log.Debug("ERROR: Missing catalog: (root %q) (trailer %s)", oc, *trailerDict)
-		return errors.New("missing catalog")
+		return fmt.Errorf("missing catalog: %w", ErrTypeCheck)
 	}
 	catalog, ok := (*pcatalog).PdfObject.(*core.PdfObjectDictionary)
 	if !ok {
 		common.Log.Debug("ERROR: Invalid catalog (%s)", pcatalog.PdfObject)
-		return errors.New("invalid catalog")
+		return fmt.Errorf("invalid catalog: %w", ErrTypeCheck)
 	}
 	common.Log.Trace("Catalog: %s", catalog)
 
 	// Pages.
 	pagesRef, ok := catalog.Get("Pages").(*core.PdfObjectReference)
 	if !ok {
-		return errors.New("pages in catalog should be a reference")
+		return fmt.Errorf("pages in catalog should be a reference: %w", ErrTypeCheck)
 	}
 	op, err := r.parser.LookupByReference(*pagesRef)
 	if err != nil {
@@ -208,20 +406,20 @@ func (r *PdfReader) loadStructure() error {
 	if !ok {
 		common.Log.Debug("ERROR: Pages object invalid")
 		common.Log.Debug("op: %p", ppages)
-		return errors.New("pages object invalid")
+		return fmt.Errorf("pages object invalid: %w", ErrTypeCheck)
 	}
 	pages, ok := ppages.PdfObject.(*core.PdfObjectDictionary)
 	if !ok {
 		common.Log.Debug("ERROR: Pages object invalid (%s)", ppages)
-		return errors.New("pages object invalid")
+		return fmt.Errorf("pages object invalid: %w", ErrTypeCheck)
 	}
 	pageCount, ok := core.GetInt(pages.Get("Count"))
 	if !ok {
 		common.Log.Debug("ERROR: Pages count object invalid")
-		return errors.New("pages count invalid")
+		return fmt.Errorf("pages count invalid: %w", ErrTypeCheck)
 	}
 	if _, ok = core.GetName(pages.Get("Type")); !ok {
-		common.Log.Debug("Pages dict Type field not set. Setting Type to Pages.")
+		r.Logger().Debug("page tree node with Kids missing Type, assuming Pages", "object", ppages.ObjectNumber)
 		pages.Set("Type", core.MakeName("Pages"))
 	}
 
@@ -231,6 +429,7 @@ func (r *PdfReader) loadStructure() error {
 	r.pagesContainer = ppages
 	r.pageCount = int(*pageCount)
 	r.pageList = []*core.PdfIndirectObject{}
+	r.PageList = nil
 
 	traversedPageNodes := map[core.PdfObject]struct{}{}
 	err = r.buildPageList(ppages, nil, traversedPageNodes)
@@ -254,13 +453,14 @@ func (r *PdfReader) loadStructure() error {
 	if err != nil {
 		return err
 	}
+	r.progress.Complete(0)
 
 	return nil
 }
 
 func (r *PdfReader) loadOutlines() (*PdfOutlineTreeNode, error) {
-	if r.parser.GetCrypter() != nil && !r.parser.IsAuthenticated() {
-		return nil, fmt.Errorf("file need to be decrypted first")
+	if !r.IsDecrypted() {
+		return nil, ErrEncrypted
 	}
 
 	// Has outlines? Otherwise return an empty outlines structure.
@@ -440,11 +640,16 @@ func (r *PdfReader) GetOutlinesFlattened() ([]*PdfOutlineTreeNode, []string, err
 }
 
 // GetOutlines returns a high-level Outline object, based on the outline tree
-// of the reader.
+// of the reader. Named destinations are resolved and the actions of the items
+// are preserved. The outline can be modified and written back using
+// PdfWriter.AddOutlineTree.
 func (r *PdfReader) GetOutlines() (*Outline, error) {
 	if r == nil {
 		return nil, errors.New("cannot create outline from nil reader")
 	}
+	if err := r.checkDecrypted(); err != nil {
+		return nil, err
+	}
 
 	outlineTree := r.GetOutlineTree()
 	if outlineTree == nil {
@@ -468,7 +673,20 @@ func (r *PdfReader) GetOutlines() (*Outline, error) {
 			destObj := item.Dest
 			if (destObj == nil || core.IsNullObject(destObj)) && item.A != nil {
 				if actionDict, ok := core.GetDict(item.A); ok {
-					destObj, _ = core.GetArray(actionDict.Get("D"))
+					if s, _ := core.GetNameVal(actionDict.Get("S")); s == "GoTo" {
+						destObj = actionDict.Get("D")
+					}
+				}
+			}
+
+			// Resolve named destinations.
+			switch core.TraceToDirectObject(destObj).(type) {
+			case *core.PdfObjectName, *core.PdfObjectString:
+				if d, err := r.ResolveDestination(destObj); err == nil {
+					destObj = d.ToPdfObject()
+				} else {
+					common.Log.Debug("WARN: could not resolve outline dest (%v): %v", destObj, err)
+					destObj = nil
 				}
 			}
 
@@ -483,6 +701,17 @@ func (r *PdfReader) GetOutlines() (*Outline, error) {
 			}
 
 			entry = NewOutlineItem(item.Title.Decoded(), dest)
+			entry.Closed = item.Count != nil && *item.Count < 0
+			entry.Action = item.A
+			if flags, ok := core.GetIntVal(item.F); ok {
+				entry.Italic = flags&1 != 0
+				entry.Bold = flags&2 != 0
+			}
+			if c, ok := core.GetArray(item.C); ok && c.Len() == 3 {
+				if rgb, err := c.ToFloat64Array(); err == nil {
+					entry.Color = NewPdfColorDeviceRGB(rgb[0], rgb[1], rgb[2])
+				}
+			}
 			*entries = append(*entries, entry)
 
 			// Traverse next node.
@@ -518,6 +747,9 @@ type AcroFormRepairOptions struct {
 // future options, but passing nil will always result in the default options
 // being used.
 func (r *PdfReader) RepairAcroForm(opts *AcroFormRepairOptions) error {
+	if err := r.checkDecrypted(); err != nil {
+		return err
+	}
 	var fields []*PdfField
 	fieldCache := map[*core.PdfIndirectObject]struct{}{}
 	for _, page := range r.PageList {
@@ -574,6 +806,9 @@ func (r *PdfReader) RepairAcroForm(opts *AcroFormRepairOptions) error {
 // linked to fields which are not referenced in the AcroForm. The AcroForm can
 // be repaired using the RepairAcroForm method of the reader.
 func (r *PdfReader) AcroFormNeedsRepair() (bool, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return false, err
+	}
 	var fields []*PdfField
 	if r.AcroForm != nil {
 		fields = r.AcroForm.AllFields()
@@ -611,8 +846,8 @@ func (r *PdfReader) AcroFormNeedsRepair() (bool, error) {
 
 // loadForms loads the AcroForm.
 func (r *PdfReader) loadForms() (*PdfAcroForm, error) {
-	if r.parser.GetCrypter() != nil && !r.parser.IsAuthenticated() {
-		return nil, fmt.Errorf("file need to be decrypted first")
+	if !r.IsDecrypted() {
+		return nil, ErrEncrypted
 	}
 
 	// Has forms?
@@ -674,23 +909,30 @@ func (r *PdfReader) buildPageList(node *core.PdfIndirectObject, parent *core.Pdf
 	}
 
 	if _, alreadyTraversed := traversedPageNodes[node]; alreadyTraversed {
-		common.Log.Debug("Cyclic recursion, skipping (%v)", node.ObjectNumber)
+		r.Logger().Debug("cyclic page tree node skipped", "object", node.ObjectNumber)
 		return nil
 	}
 	traversedPageNodes[node] = struct{}{}
 
+	r.pageTreeDepth++
+	defer func() { r.pageTreeDepth-- }()
+	if max := r.parser.GetConfig().MaxReferenceDepth; r.pageTreeDepth > max {
+		r.Logger().Error("page tree depth exceeds the limit", "object", node.ObjectNumber, "limit", max)
+		return core.ErrLimitExceeded{Limit: "MaxReferenceDepth", Max: int64(max)}
+	}
+
 	nodeDict, ok := node.PdfObject.(*core.PdfObjectDictionary)
 	if !ok {
-		return errors.New("node not a dictionary")
+		return fmt.Errorf("node not a dictionary: %w", ErrTypeCheck)
 	}
 
 	objType, ok := (*nodeDict).Get("Type").(*core.PdfObjectName)
 	if !ok {
 		if nodeDict.Get("Kids") == nil {
-			return errors.New("node missing Type (Required)")
+			return fmt.Errorf("node missing Type: %w", ErrRequiredAttributeMissing)
 		}
 
-		common.Log.Debug("ERROR: node missing Type, but has Kids. Assuming Pages node.")
+		r.Logger().Debug("page tree node with Kids missing Type, assuming Pages", "object", node.ObjectNumber)
 		objType = core.MakeName("Pages")
 		nodeDict.Set("Type", objType)
 	}
@@ -712,8 +954,9 @@ func (r *PdfReader) buildPageList(node *core.PdfIndirectObject, parent *core.Pdf
 		return nil
 	}
 	if *objType != "Pages" {
-		common.Log.Debug("ERROR: Table of content containing non Page/Pages object! (%s)", objType)
-		return errors.New("table of content containing non Page/Pages object")
+		r.Logger().Error("page tree node not a Page or Pages object", "object", node.ObjectNumber,
+			"type", objType.String())
+		return fmt.Errorf("table of content containing non Page/Pages object: %w", ErrTypeCheck)
 	}
 
 	// A Pages object.  Update the parent.
@@ -731,7 +974,7 @@ func (r *PdfReader) buildPageList(node *core.PdfIndirectObject, parent *core.Pdf
 
 	kidsObj, err := r.parser.Resolve(nodeDict.Get("Kids"))
 	if err != nil {
-		common.Log.Debug("ERROR: Failed loading Kids object")
+		r.Logger().Error("unable to load page tree Kids", "object", node.ObjectNumber, "error", err)
 		return err
 	}
 
@@ -740,19 +983,19 @@ func (r *PdfReader) buildPageList(node *core.PdfIndirectObject, parent *core.Pdf
 	if !ok {
 		kidsIndirect, isIndirect := kidsObj.(*core.PdfIndirectObject)
 		if !isIndirect {
-			return errors.New("invalid Kids object")
+			return fmt.Errorf("invalid Kids object: %w", ErrTypeCheck)
 		}
 		kids, ok = kidsIndirect.PdfObject.(*core.PdfObjectArray)
 		if !ok {
-			return errors.New("invalid Kids indirect object")
+			return fmt.Errorf("invalid Kids indirect object: %w", ErrTypeCheck)
 		}
 	}
 	common.Log.Trace("Kids: %s", kids)
 	for idx, child := range kids.Elements() {
 		child, ok := core.GetIndirect(child)
 		if !ok {
-			common.Log.Debug("ERROR: Page not indirect object - (%s)", child)
-			return errors.New("page not indirect object")
+			r.Logger().Error("page tree kid not an indirect object", "object", node.ObjectNumber, "index", idx)
+			return fmt.Errorf("page not indirect object: %w", ErrTypeCheck)
 		}
 		kids.Set(idx, child)
 		err = r.buildPageList(child, node, traversedPageNodes)
@@ -766,8 +1009,8 @@ func (r *PdfReader) buildPageList(node *core.PdfIndirectObject, parent *core.Pdf
 
 // GetNumPages returns the number of pages in the document.
 func (r *PdfReader) GetNumPages() (int, error) {
-	if r.parser.GetCrypter() != nil && !r.parser.IsAuthenticated() {
-		return 0, fmt.Errorf("file need to be decrypted first")
+	if err := r.checkDecrypted(); err != nil {
+		return 0, err
 	}
 	return len(r.pageList), nil
 }
@@ -814,15 +1057,15 @@ func (r *PdfReader) PageFromIndirectObject(ind *core.PdfIndirectObject) (*PdfPag
 
 // GetPage returns the PdfPage model for the specified page number.
 func (r *PdfReader) GetPage(pageNumber int) (*PdfPage, error) {
-	if r.parser.GetCrypter() != nil && !r.parser.IsAuthenticated() {
-		return nil, fmt.Errorf("file needs to be decrypted first")
+	if err := r.checkDecrypted(); err != nil {
+		return nil, err
 	}
 	if len(r.pageList) < pageNumber {
-		return nil, errors.New("invalid page number (page count too short)")
+		return nil, fmt.Errorf("invalid page number %d (page count too short): %w", pageNumber, errRangeError)
 	}
 	idx := pageNumber - 1
 	if idx < 0 {
-		return nil, fmt.Errorf("page numbering must start at 1")
+		return nil, fmt.Errorf("page numbering must start at 1: %w", errRangeError)
 	}
 	page := r.PageList[idx]
 	return page, nil
@@ -830,6 +1073,9 @@ func (r *PdfReader) GetPage(pageNumber int) (*PdfPage, error) {
 
 // GetOCProperties returns the optional content properties PdfObject.
 func (r *PdfReader) GetOCProperties() (core.PdfObject, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return nil, err
+	}
 	dict := r.catalog
 	obj := dict.Get("OCProperties")
 	obj = core.ResolveReference(obj)
@@ -851,6 +1097,9 @@ func (r *PdfReader) GetOCProperties() (core.PdfObject, error) {
 // GetNamedDestinations returns the Names entry in the PDF catalog.
 // See section 12.3.2.3 "Named Destinations" (p. 367 PDF32000_2008).
 func (r *PdfReader) GetNamedDestinations() (core.PdfObject, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return nil, err
+	}
 	obj := core.ResolveReference(r.catalog.Get("Names"))
 	if obj == nil {
 		return nil, nil
@@ -870,6 +1119,9 @@ func (r *PdfReader) GetNamedDestinations() (core.PdfObject, error) {
 // GetPageLabels returns the PageLabels entry in the PDF catalog.
 // See section 12.4.2 "Page Labels" (p. 382 PDF32000_2008).
 func (r *PdfReader) GetPageLabels() (core.PdfObject, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return nil, err
+	}
 	obj := core.ResolveReference(r.catalog.Get("PageLabels"))
 	if obj == nil {
 		return nil, nil
@@ -886,6 +1138,20 @@ func (r *PdfReader) GetPageLabels() (core.PdfObject, error) {
 	return obj, nil
 }
 
+// GetDSS returns the document security store (DSS) of the document, or nil
+// if the document does not contain one.
+func (r *PdfReader) GetDSS() (*DSS, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return nil, err
+	}
+	obj := r.catalog.Get("DSS")
+	if obj == nil || core.IsNullObject(core.ResolveReference(obj)) {
+		return nil, nil
+	}
+
+	return newDSSFromObject(obj)
+}
+
 // Inspect inspects the object types, subtypes and content in the PDF file returning a map of
 // object type to number of instances of each.
 func (r *PdfReader) Inspect() (map[string]int, error) {
@@ -7,12 +7,20 @@ package model
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/core/security"
 )
 
 func TestReaderLazy(t *testing.T) {
@@ -47,3 +55,482 @@ func TestReaderLazy(t *testing.T) {
 	err = writer.Write(&buf)
 	require.NoError(t, err)
 }
+
+// makeLargeTestPDF returns a PDF document of `numPages` pages, each with a content stream of
+// approximately `size` bytes.
+func makeLargeTestPDF(t testing.TB, numPages, size int) []byte {
+	const op = "0 0 m 100 100 l S\n"
+	content := strings.Repeat(op, size/len(op))
+	w := NewPdfWriter()
+	for i := 0; i < numPages; i++ {
+		page := NewPdfPage()
+		require.NoError(t, page.SetContentStreams([]string{content}, core.NewRawEncoder()))
+		require.NoError(t, w.AddPage(page))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	return buf.Bytes()
+}
+
+// lazyTestContentStreams returns the content stream objects of `page`.
+func lazyTestContentStreams(t *testing.T, page *PdfPage) []*core.PdfObjectStream {
+	var streams []*core.PdfObjectStream
+	objs := []core.PdfObject{page.Contents}
+	if arr, ok := core.GetArray(page.Contents); ok {
+		objs = arr.Elements()
+	}
+	for _, obj := range objs {
+		stream, ok := core.GetStream(obj)
+		require.True(t, ok)
+		streams = append(streams, stream)
+	}
+	return streams
+}
+
+func TestReaderLazyStreams(t *testing.T) {
+	data := makeLargeTestPDF(t, 20, 10000)
+	reader, err := NewPdfReaderLazy(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, reader.PageList, 20)
+
+	// The page contents are not loaded when opening the document.
+	for _, page := range reader.PageList {
+		streams := lazyTestContentStreams(t, page)
+		require.NotEmpty(t, streams)
+		for _, stream := range streams {
+			require.False(t, stream.IsDataLoaded())
+		}
+	}
+
+	page, err := reader.GetPage(3)
+	require.NoError(t, err)
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(contents, "0 0 m 100 100 l S\n"))
+	for _, stream := range lazyTestContentStreams(t, page) {
+		require.True(t, stream.IsDataLoaded())
+	}
+
+	// Data assigned prior to loading is not replaced by the file data.
+	other := lazyTestContentStreams(t, reader.PageList[4])[0]
+	other.Stream = []byte("q Q")
+	require.NoError(t, other.LoadData())
+	require.Equal(t, "q Q", string(other.Stream))
+
+	// Writing loads the remaining streams.
+	w := NewPdfWriter()
+	for _, p := range reader.PageList {
+		require.NoError(t, w.AddPage(p))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	written, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	for i, p := range written.PageList {
+		str, err := p.GetAllContentStreams()
+		require.NoError(t, err)
+		if i == 4 {
+			require.True(t, strings.HasPrefix(str, "q Q"))
+			continue
+		}
+		require.True(t, strings.HasPrefix(str, "0 0 m 100 100 l S\n"), "page %d", i+1)
+	}
+}
+
+// TestReaderLazyObjectStreams checks loading a document with more object streams than are kept
+// in the cache of the lazy reader.
+func TestReaderLazyObjectStreams(t *testing.T) {
+	w := NewPdfWriter()
+	w.SetVersion(1, 5)
+	for i := 0; i < 2000; i++ {
+		page := NewPdfPage()
+		require.NoError(t, page.AddContentStreamByString(fmt.Sprintf("%d 0 0 1 0 0 cm", i)))
+		require.NoError(t, w.AddPage(page))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	reader, err := NewPdfReaderLazy(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Len(t, reader.PageList, 2000)
+	for _, i := range []int{1999, 0, 1000, 1999} {
+		contents, err := reader.PageList[i].GetAllContentStreams()
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(contents, fmt.Sprintf("%d 0 0 1 0 0 cm", i)), "page %d", i+1)
+	}
+}
+
+// TestReaderLazyCorpus checks that the lazy and the default readers load the same page contents
+// from the test documents.
+func TestReaderLazyCorpus(t *testing.T) {
+	files, err := filepath.Glob("./testdata/*.pdf")
+	require.NoError(t, err)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+		reader, err := NewPdfReader(bytes.NewReader(data))
+		if err != nil {
+			continue
+		}
+		if encrypted, _ := reader.IsEncrypted(); encrypted {
+			continue
+		}
+		lazy, err := NewPdfReaderLazy(bytes.NewReader(data))
+		require.NoError(t, err, file)
+		require.Len(t, lazy.PageList, len(reader.PageList), file)
+		for i, page := range reader.PageList {
+			expected, err := page.GetAllContentStreams()
+			require.NoError(t, err, file)
+			contents, err := lazy.PageList[i].GetAllContentStreams()
+			require.NoError(t, err, file)
+			require.Equal(t, expected, contents, "%s page %d", file, i+1)
+		}
+	}
+}
+
+// benchmarkReaderOpen measures opening a large document and reading the contents of one of its
+// pages. The allocation statistics show the memory used by the reader.
+func benchmarkReaderOpen(b *testing.B, lazy bool) {
+	data := makeLargeTestPDF(b, 500, 50000)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var reader *PdfReader
+		var err error
+		if lazy {
+			reader, err = NewPdfReaderLazy(bytes.NewReader(data))
+		} else {
+			reader, err = NewPdfReader(bytes.NewReader(data))
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+		page, err := reader.GetPage(250)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := page.GetAllContentStreams(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReaderOpen(b *testing.B)     { benchmarkReaderOpen(b, false) }
+func BenchmarkReaderOpenLazy(b *testing.B) { benchmarkReaderOpen(b, true) }
+
+// countingReaderAt is an io.ReaderAt which records the number and the extent of the reads.
+type countingReaderAt struct {
+	r       io.ReaderAt
+	reads   int
+	total   int64
+	maxRead int
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.reads++
+	c.total += int64(n)
+	if n > c.maxRead {
+		c.maxRead = n
+	}
+	return n, err
+}
+
+func TestReaderFromReaderAt(t *testing.T) {
+	const numPages, size = 200, 50000
+	data := makeLargeTestPDF(t, numPages, size)
+	r := &countingReaderAt{r: bytes.NewReader(data)}
+	reader, err := NewPdfReaderFromReaderAt(r, int64(len(data)))
+	require.NoError(t, err)
+	require.Len(t, reader.PageList, numPages)
+
+	page, err := reader.GetPage(numPages / 2)
+	require.NoError(t, err)
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	require.True(t, len(contents) >= size*9/10)
+
+	// Only the page tree and the contents of a single page are read.
+	require.True(t, r.total < int64(len(data))/10, "read %d of %d bytes", r.total, len(data))
+	require.True(t, r.maxRead <= size+4096, "read %d bytes at once", r.maxRead)
+	require.True(t, r.reads < 10*numPages, "%d reads", r.reads)
+}
+
+// makeEncryptedPdf returns a single-page document encrypted with `userPass` and `ownerPass`.
+func makeEncryptedPdf(t *testing.T, userPass, ownerPass string, algo EncryptionAlgorithm,
+	perm security.Permissions) []byte {
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(NewPdfPage()))
+	require.NoError(t, w.Encrypt([]byte(userPass), []byte(ownerPass), &EncryptOptions{
+		Permissions: perm,
+		Algorithm:   algo,
+	}))
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, w.Write(buf))
+	return buf.Bytes()
+}
+
+func TestReaderEncryptedDeferredDecrypt(t *testing.T) {
+	data := makeEncryptedPdf(t, "user", "owner", AES_128bit, security.PermOwner)
+	for _, lazy := range []bool{false, true} {
+		reader, err := NewPdfReaderWithOpts(bytes.NewReader(data), &ReaderOpts{LazyLoad: lazy})
+		require.NoError(t, err)
+		encrypted, err := reader.IsEncrypted()
+		require.NoError(t, err)
+		require.True(t, encrypted)
+		require.False(t, reader.IsDecrypted())
+
+		_, err = reader.GetNumPages()
+		require.Equal(t, ErrEncrypted, err)
+		_, err = reader.GetPage(1)
+		require.Equal(t, ErrEncrypted, err)
+		_, err = reader.GetOutlines()
+		require.Equal(t, ErrEncrypted, err)
+
+		// Retry after a wrong password.
+		ok, err := reader.Decrypt([]byte("wrong"))
+		require.NoError(t, err)
+		require.False(t, ok)
+		_, err = reader.GetNumPages()
+		require.Equal(t, ErrEncrypted, err)
+
+		ok, err = reader.Decrypt([]byte("user"))
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.True(t, reader.IsDecrypted())
+		numPages, err := reader.GetNumPages()
+		require.NoError(t, err)
+		require.Equal(t, 1, numPages)
+
+		// Decrypting again neither reloads the document nor loses the decryption.
+		ok, err = reader.Decrypt([]byte("wrong"))
+		require.NoError(t, err)
+		require.False(t, ok)
+		ok, err = reader.Decrypt([]byte("owner"))
+		require.NoError(t, err)
+		require.True(t, ok)
+		numPages, err = reader.GetNumPages()
+		require.NoError(t, err)
+		require.Equal(t, 1, numPages)
+		require.Len(t, reader.PageList, 1)
+	}
+
+	// The password of the options is tried when creating the reader.
+	reader, err := NewPdfReaderWithOpts(bytes.NewReader(data), &ReaderOpts{Password: "owner"})
+	require.NoError(t, err)
+	require.True(t, reader.IsDecrypted())
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 1, numPages)
+}
+
+func TestReaderEncryptedEmptyUserPassword(t *testing.T) {
+	data := makeEncryptedPdf(t, "", "owner", RC4_128bit, security.PermOwner)
+	for _, newReader := range []func(io.ReadSeeker) (*PdfReader, error){NewPdfReader, NewPdfReaderLazy} {
+		reader, err := newReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		require.True(t, reader.IsDecrypted())
+		numPages, err := reader.GetNumPages()
+		require.NoError(t, err)
+		require.Equal(t, 1, numPages)
+
+		// Decrypting explicitly as before keeps working.
+		ok, err := reader.Decrypt([]byte(""))
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Len(t, reader.PageList, 1)
+	}
+}
+
+func TestReaderPasswordCallback(t *testing.T) {
+	data := makeEncryptedPdf(t, "user", "owner", AES_256bit, security.PermOwner)
+
+	// Wrong passwords are retried until the callback gives up.
+	var attempts []int
+	reader, err := NewPdfReaderWithOpts(bytes.NewReader(data), &ReaderOpts{
+		PasswordCallback: func(attempt int) ([]byte, bool) {
+			attempts = append(attempts, attempt)
+			return []byte("wrong"), attempt < 3
+		},
+	})
+	require.NoError(t, err)
+	require.Empty(t, attempts, "the password should be asked for when needed only")
+	_, err = reader.GetNumPages()
+	require.Equal(t, ErrWrongPassword, err)
+	require.True(t, errors.Is(err, ErrEncrypted))
+	require.Equal(t, []int{1, 2, 3}, attempts)
+
+	// Both the user and owner passwords decrypt the document.
+	for _, password := range []string{"user", "owner"} {
+		attempts = nil
+		passwords := []string{"wrong", password}
+		reader, err = NewPdfReaderWithOpts(bytes.NewReader(data), &ReaderOpts{
+			PasswordCallback: func(attempt int) ([]byte, bool) {
+				attempts = append(attempts, attempt)
+				return []byte(passwords[attempt-1]), true
+			},
+		})
+		require.NoError(t, err)
+		page, err := reader.GetPage(1)
+		require.NoError(t, err)
+		require.NotNil(t, page)
+		require.Equal(t, []int{1, 2}, attempts)
+
+		_, err = reader.GetNumPages()
+		require.NoError(t, err)
+		require.Equal(t, []int{1, 2}, attempts, "the password should be asked for once")
+	}
+}
+
+func TestReaderEncryptionDetails(t *testing.T) {
+	perm := security.PermPrinting | security.PermFillForms
+	testcases := []struct {
+		algo         EncryptionAlgorithm
+		v, r         int
+		streamFilter string
+		keyLength    int
+	}{
+		{RC4_128bit, 2, 3, "V2", 128},
+		{AES_128bit, 4, 4, "AESV2", 128},
+		{AES_256bit, 5, 6, "AESV3", 256},
+	}
+	for _, tcase := range testcases {
+		data := makeEncryptedPdf(t, "user", "owner", tcase.algo, perm)
+		reader, err := NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+
+		// The details are available before decrypting.
+		require.False(t, reader.IsDecrypted())
+		details := reader.GetEncryptionDetails()
+		require.NotNil(t, details)
+		require.Equal(t, "Standard", details.Filter)
+		require.Equal(t, tcase.v, details.V)
+		require.Equal(t, tcase.r, details.R)
+		require.Equal(t, tcase.streamFilter, details.StreamFilter)
+		require.Equal(t, tcase.keyLength, details.KeyLength)
+		for _, p := range []security.Permissions{security.PermPrinting, security.PermFillForms} {
+			require.True(t, details.Permissions.Allowed(p), "%v: %v", tcase.algo, p)
+		}
+		for _, p := range []security.Permissions{security.PermModify, security.PermExtractGraphics} {
+			require.False(t, details.Permissions.Allowed(p), "%v: %v", tcase.algo, p)
+		}
+	}
+
+	reader, err := NewPdfReader(bytes.NewReader(makeLargeTestPDF(t, 1, 1)))
+	require.NoError(t, err)
+	require.Nil(t, reader.GetEncryptionDetails())
+}
+
+func TestReaderErrors(t *testing.T) {
+	_, err := NewPdfReader(strings.NewReader("%PDF-1.4\nnot a pdf file\n%%EOF\n"))
+	require.True(t, errors.Is(err, core.ErrInvalidXref), "%v", err)
+
+	reader, err := NewPdfReader(bytes.NewReader(makeLargeTestPDF(t, 2, 1)))
+	require.NoError(t, err)
+	for _, pageNum := range []int{0, 3} {
+		_, err = reader.GetPage(pageNum)
+		require.True(t, errors.Is(err, core.ErrRangeError), "%d: %v", pageNum, err)
+	}
+
+	data := makeEncryptedPdf(t, "user", "owner", AES_128bit, security.PermOwner)
+	reader, err = NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	_, err = reader.GetIndirectObjectByNumber(1)
+	require.True(t, errors.Is(err, ErrEncrypted), "%v", err)
+	require.True(t, errors.Is(err, core.ErrEncrypted))
+
+	w := NewPdfWriter()
+	err = w.Encrypt([]byte("user"), []byte("owner"), &EncryptOptions{Algorithm: EncryptionAlgorithm(100)})
+	require.True(t, errors.Is(err, core.ErrNotSupported), "%v", err)
+	err = w.AddEmbeddedFile(&EmbeddedFile{})
+	require.True(t, errors.Is(err, ErrRequiredAttributeMissing), "%v", err)
+}
+
+func TestReaderLimits(t *testing.T) {
+	// makePageTree returns a document whose page tree has `depth` Pages nodes chained by their
+	// Kids, the last one referring to the first one if `circular`. The cross-reference table is
+	// rebuilt.
+	makePageTree := func(depth int, circular bool) string {
+		var sb strings.Builder
+		sb.WriteString("%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+		for i := 2; i <= depth+1; i++ {
+			kid := i + 1
+			if i == depth+1 && circular {
+				kid = 2
+			}
+			fmt.Fprintf(&sb, "%d 0 obj\n<< /Type /Pages /Kids [%d 0 R] /Count 1 >>\nendobj\n", i, kid)
+		}
+		fmt.Fprintf(&sb, "%d 0 obj\n<< /Type /Page /MediaBox [0 0 100 100] >>\nendobj\n%%%%EOF\n", depth+2)
+		return sb.String()
+	}
+
+	// Minimized crasher: self-referential page tree.
+	reader, err := NewPdfReader(strings.NewReader(makePageTree(3, true)))
+	require.NoError(t, err)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 0, numPages)
+
+	// Deep page tree.
+	data := makePageTree(20, false)
+	reader, err = NewPdfReader(strings.NewReader(data))
+	require.NoError(t, err)
+	numPages, err = reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 1, numPages)
+
+	for _, lazy := range []bool{false, true} {
+		opts := &ReaderOpts{LazyLoad: lazy, ParserConfig: core.ParserConfig{MaxReferenceDepth: 10}}
+		_, err = NewPdfReaderWithOpts(strings.NewReader(data), opts)
+		var limitErr core.ErrLimitExceeded
+		require.True(t, errors.As(err, &limitErr), "%v", err)
+		require.Equal(t, "MaxReferenceDepth", limitErr.Limit)
+	}
+
+	// Minimized crasher: image with absurd dimensions.
+	ximg := NewXObjectImage()
+	width, height := int64(1<<31), int64(1<<31)
+	ximg.Width, ximg.Height = &width, &height
+	_, err = ximg.ToImage()
+	require.Equal(t, core.ErrLimitExceeded{Limit: "MaxImagePixels", Max: core.DefaultMaxImagePixels}, err)
+}
+
+// TestReaderLogger checks that the logs of the reader and of its parser are written to the logger
+// of the reader, and that the page loggers add the page number.
+func TestReaderLogger(t *testing.T) {
+	// No cross-reference table and a Pages node missing Type.
+	data := "%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+		"2 0 obj\n<< /Kids [3 0 R 4 0 R] /Count 2 >>\nendobj\n" +
+		"3 0 obj\n<< /Type /Page /MediaBox [0 0 100 100] >>\nendobj\n" +
+		"4 0 obj\n<< /Type /Page /MediaBox [0 0 100 100] >>\nendobj\n%%EOF\n"
+	logger := common.NewRecordingLogger()
+	reader, err := NewPdfReaderWithOpts(strings.NewReader(data), &ReaderOpts{Logger: logger})
+	require.NoError(t, err)
+	require.Equal(t, logger, reader.Logger())
+	require.Len(t, logger.Find("repaired broken cross-reference table by scanning the file"), 1)
+	records := logger.Find("page tree node with Kids missing Type, assuming Pages")
+	require.Len(t, records, 1)
+	require.Equal(t, []interface{}{"object", int64(2)}, records[0].Fields)
+
+	page, err := reader.GetPage(2)
+	require.NoError(t, err)
+	page.Logger().Warn("message", "object", 4)
+	require.Equal(t, common.LogRecord{
+		Level:   common.LogLevelWarning,
+		Message: "message",
+		Fields:  []interface{}{"page", 2, "object", 4},
+	}, logger.Find("message")[0])
+
+	// The parser logger of the options takes precedence.
+	parserLogger := common.NewRecordingLogger()
+	logger = common.NewRecordingLogger()
+	_, err = NewPdfReaderWithOpts(strings.NewReader(data), &ReaderOpts{
+		Logger:       logger,
+		ParserConfig: core.ParserConfig{Logger: parserLogger},
+	})
+	require.NoError(t, err)
+	require.Len(t, parserLogger.Find("repaired broken cross-reference table by scanning the file"), 1)
+	require.Empty(t, logger.Find("repaired broken cross-reference table by scanning the file"))
+	require.Len(t, logger.Find("page tree node with Kids missing Type, assuming Pages"), 1)
+}
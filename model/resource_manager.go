@@ -0,0 +1,112 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"fmt"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// ResourceManager adds resources to the resources of a page or of a form XObject under generated
+// names which do not collide with the names in use. Adding a resource which is in the resources
+// already returns its name rather than adding it again: graphics state parameter dictionaries
+// are reused when they are equal (see core.Equal), the other resources when they are the same
+// objects. So identical graphics states set by multiple drawing operations share a single entry.
+type ResourceManager struct {
+	resources *PdfPageResources
+}
+
+// NewResourceManager returns a ResourceManager adding resources to `resources`.
+func NewResourceManager(resources *PdfPageResources) *ResourceManager {
+	return &ResourceManager{resources: resources}
+}
+
+// ResourceManager returns a ResourceManager adding resources to the resources of the page,
+// creating them if the page has none.
+func (p *PdfPage) ResourceManager() *ResourceManager {
+	if p.Resources == nil {
+		p.Resources = NewPdfPageResources()
+	}
+	return NewResourceManager(p.Resources)
+}
+
+// AddExtGState adds the graphics state parameter dictionary `gs`, which can be a dictionary or an
+// indirect object containing one, and returns its name, e.g. GS0.
+func (rm *ResourceManager) AddExtGState(gs core.PdfObject) (core.PdfObjectName, error) {
+	if _, ok := core.GetDict(gs); !ok {
+		common.Log.Debug("ERROR: ExtGState not a dictionary (%T)", gs)
+		return "", core.ErrTypeError
+	}
+	return rm.add(&rm.resources.ExtGState, "GS", 0, gs, equalDicts)
+}
+
+// AddFont adds the font dictionary `font` and returns its name, e.g. Font1.
+func (rm *ResourceManager) AddFont(font core.PdfObject) (core.PdfObjectName, error) {
+	return rm.add(&rm.resources.Font, "Font", 1, font, sameObject)
+}
+
+// AddXObject adds the XObject `stream` and returns its name: Im0, Im1... for images, Fm0, Fm1...
+// for forms and XObj0, XObj1... for the other XObjects.
+func (rm *ResourceManager) AddXObject(stream *core.PdfObjectStream) (core.PdfObjectName, error) {
+	prefix := "XObj"
+	if subtype, ok := core.GetName(stream.Get("Subtype")); ok {
+		switch *subtype {
+		case "Image":
+			prefix = "Im"
+		case "Form":
+			prefix = "Fm"
+		}
+	}
+	return rm.add(&rm.resources.XObject, prefix, 0, stream, sameObject)
+}
+
+// AddShading adds the shading `shading`, which can be a dictionary or a stream, and returns its
+// name, e.g. Sh0.
+func (rm *ResourceManager) AddShading(shading core.PdfObject) (core.PdfObjectName, error) {
+	return rm.add(&rm.resources.Shading, "Sh", 0, shading, sameObject)
+}
+
+// add adds `obj` to the resource dictionary `category`, created if nil, and returns its name. If
+// the dictionary has an entry which is the same as `obj` according to `same`, its name is
+// returned. Otherwise `obj` is added under the first unused name made of `prefix` and a number
+// starting at `first`.
+func (rm *ResourceManager) add(category *core.PdfObject, prefix string, first int, obj core.PdfObject,
+	same func(a, b core.PdfObject) bool) (core.PdfObjectName, error) {
+	if *category == nil {
+		*category = core.MakeDict()
+	}
+	dict, ok := core.GetDict(*category)
+	if !ok {
+		common.Log.Debug("ERROR: resources %s not a dictionary (%T)", prefix, *category)
+		return "", core.ErrTypeError
+	}
+
+	for _, name := range dict.Keys() {
+		if same(dict.Get(name), obj) {
+			return name, nil
+		}
+	}
+	for i := first; ; i++ {
+		name := core.PdfObjectName(fmt.Sprintf("%s%d", prefix, i))
+		if dict.Get(name) == nil {
+			dict.Set(name, obj)
+			return name, nil
+		}
+	}
+}
+
+// equalDicts returns true if the dictionaries `a` and `b`, or the indirect objects containing them,
+// are equal.
+func equalDicts(a, b core.PdfObject) bool {
+	return core.Equal(core.TraceToDirectObject(a), core.TraceToDirectObject(b))
+}
+
+// sameObject returns true if `a` and `b` are the same object, or references to it.
+func sameObject(a, b core.PdfObject) bool {
+	return a == b || core.ResolveReference(a) == core.ResolveReference(b)
+}
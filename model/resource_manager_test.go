@@ -0,0 +1,118 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+func TestPdfExtGState(t *testing.T) {
+	font := NewStandard14FontMustCompile(HelveticaName)
+	gs := NewPdfExtGState().
+		SetAlpha(0.5).
+		SetBlendMode("Multiply").
+		SetSoftMask(nil).
+		SetLineWidth(2).
+		SetLineCap(1).
+		SetLineJoin(2).
+		SetDash([]float64{3, 1}, 0.5).
+		SetFont(font, 12)
+
+	dict, ok := core.GetDict(gs.ToPdfObject())
+	require.True(t, ok)
+	require.Equal(t, "<</CA 0.5/ca 0.5/BM /Multiply/SMask /None/LW 2/LC 1/LJ 2/D [[3 1] 0.5]/Font [0 0 R 12]>>",
+		dict.WriteString())
+	fontArr, ok := core.GetArray(dict.Get("Font"))
+	require.True(t, ok)
+	require.Equal(t, font.ToPdfObject(), fontArr.Get(0))
+}
+
+func TestResourceManager(t *testing.T) {
+	resources := NewPdfPageResources()
+	// Resources in use are not overwritten.
+	require.NoError(t, resources.AddExtGState("GS0", core.MakeDict()))
+	require.True(t, resources.HasExtGState("GS0"))
+	require.False(t, resources.HasExtGState("GS1"))
+	helvetica := NewStandard14FontMustCompile(HelveticaName).ToPdfObject()
+	require.NoError(t, resources.SetFontByName("Font1", helvetica))
+
+	// Identical graphics states added by many drawing operations share a single entry, whether
+	// they are direct or indirect objects.
+	for i := 0; i < 100; i++ {
+		rm := NewResourceManager(resources)
+		half := NewPdfExtGState().SetBlendMode("Normal").SetAlpha(0.5).ToPdfObject()
+		if i%2 == 1 {
+			half = core.MakeIndirectObject(half)
+		}
+		name, err := rm.AddExtGState(half)
+		require.NoError(t, err)
+		require.Equal(t, core.PdfObjectName("GS1"), name)
+
+		opaque := NewPdfExtGState().SetAlpha(1).SetBlendMode("Normal")
+		name, err = rm.AddExtGState(opaque.ToPdfObject())
+		require.NoError(t, err)
+		require.Equal(t, core.PdfObjectName("GS2"), name)
+	}
+	dict, ok := core.GetDict(resources.ExtGState)
+	require.True(t, ok)
+	require.Equal(t, []core.PdfObjectName{"GS0", "GS1", "GS2"}, dict.Keys())
+
+	rm := NewResourceManager(resources)
+	_, err := rm.AddExtGState(core.MakeName("GS0"))
+	require.Error(t, err)
+
+	// Fonts, XObjects and shadings are reused when they are the same objects.
+	name, err := rm.AddFont(helvetica)
+	require.NoError(t, err)
+	require.Equal(t, core.PdfObjectName("Font1"), name)
+	courier := NewStandard14FontMustCompile(CourierName).ToPdfObject()
+	name, err = rm.AddFont(courier)
+	require.NoError(t, err)
+	require.Equal(t, core.PdfObjectName("Font2"), name)
+
+	form := NewXObjectForm()
+	form.BBox = core.MakeArrayFromFloats([]float64{0, 0, 1, 1})
+	formStream := form.ToPdfObject().(*core.PdfObjectStream)
+	img, err := NewXObjectImageFromImage(&Image{Width: 1, Height: 1, BitsPerComponent: 8,
+		ColorComponents: 1, Data: []byte{0}}, nil, nil)
+	require.NoError(t, err)
+	imgStream := img.ToPdfObject().(*core.PdfObjectStream)
+	for i := 0; i < 3; i++ {
+		name, err = rm.AddXObject(formStream)
+		require.NoError(t, err)
+		require.Equal(t, core.PdfObjectName("Fm0"), name)
+		name, err = rm.AddXObject(imgStream)
+		require.NoError(t, err)
+		require.Equal(t, core.PdfObjectName("Im0"), name)
+	}
+	otherImg, err := NewXObjectImageFromImage(&Image{Width: 1, Height: 1, BitsPerComponent: 8,
+		ColorComponents: 1, Data: []byte{0}}, nil, nil)
+	require.NoError(t, err)
+	name, err = rm.AddXObject(otherImg.ToPdfObject().(*core.PdfObjectStream))
+	require.NoError(t, err)
+	require.Equal(t, core.PdfObjectName("Im1"), name)
+
+	shading := core.MakeDict()
+	shading.Set("ShadingType", core.MakeInteger(2))
+	name, err = rm.AddShading(shading)
+	require.NoError(t, err)
+	require.Equal(t, core.PdfObjectName("Sh0"), name)
+	shadings, ok := core.GetDict(resources.Shading)
+	require.True(t, ok)
+	require.Equal(t, shading, shadings.Get("Sh0"))
+}
+
+func TestPageResourceManager(t *testing.T) {
+	page := &PdfPage{}
+	name, err := page.ResourceManager().AddExtGState(NewPdfExtGState().SetLineWidth(2).ToPdfObject())
+	require.NoError(t, err)
+	require.Equal(t, core.PdfObjectName("GS0"), name)
+	require.True(t, page.HasExtGState("GS0"))
+}
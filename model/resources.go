@@ -154,12 +154,48 @@ func (r *PdfPageResources) GetExtGState(keyName core.PdfObjectName) (core.PdfObj
 	return nil, false
 }
 
-// HasExtGState checks whether a font is defined by the specified keyName.
+// HasExtGState checks whether an ExtGState is defined by the specified keyName.
 func (r *PdfPageResources) HasExtGState(keyName core.PdfObjectName) bool {
-	_, has := r.GetFontByName(keyName)
+	_, has := r.GetExtGState(keyName)
 	return has
 }
 
+// GetPropertiesByName gets the marked content property list specified by
+// keyName. Returns a bool value indicating whether or not the entry was found.
+func (r *PdfPageResources) GetPropertiesByName(keyName core.PdfObjectName) (core.PdfObject, bool) {
+	if r.Properties == nil {
+		return nil, false
+	}
+
+	dict, ok := core.TraceToDirectObject(r.Properties).(*core.PdfObjectDictionary)
+	if !ok {
+		common.Log.Debug("ERROR: Invalid Properties entry - not a dict (got %T)", r.Properties)
+		return nil, false
+	}
+	if obj := dict.Get(keyName); obj != nil {
+		return obj, true
+	}
+
+	return nil, false
+}
+
+// SetPropertiesByName sets the marked content property list specified by
+// keyName to the given object.
+func (r *PdfPageResources) SetPropertiesByName(keyName core.PdfObjectName, obj core.PdfObject) error {
+	if r.Properties == nil {
+		r.Properties = core.MakeDict()
+	}
+
+	dict, ok := core.TraceToDirectObject(r.Properties).(*core.PdfObjectDictionary)
+	if !ok {
+		common.Log.Debug("ERROR: Invalid Properties entry - not a dict (got %T)", r.Properties)
+		return core.ErrTypeError
+	}
+
+	dict.Set(keyName, obj)
+	return nil
+}
+
 // GetShadingByName gets the shading specified by keyName. Returns nil if not existing.
 // The bool flag indicated whether it was found or not.
 func (r *PdfPageResources) GetShadingByName(keyName core.PdfObjectName) (*PdfShading, bool) {
@@ -7,6 +7,7 @@ package model
 
 import (
 	"errors"
+	"math"
 
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
@@ -68,6 +69,260 @@ func (s *PdfShading) getShadingDict() (*core.PdfObjectDictionary, error) {
 	}
 }
 
+// AverageColor returns the average color of the shading `s`, converted to RGB. The colors of
+// shadings of types 1-3, and of mesh shadings with a Function, are sampled uniformly over the
+// domain of the functions. Otherwise, the Background color is used, if set.
+func (s *PdfShading) AverageColor() (*PdfColorDeviceRGB, error) {
+	if s.ColorSpace == nil {
+		return nil, errors.New("shading colorspace undefined")
+	}
+
+	// The functions of the shading and the inputs they are evaluated at.
+	var functions []PdfFunction
+	var inputs [][]float64
+	switch t := s.context.(type) {
+	case *PdfShadingType1:
+		domain := shadingDomain(t.Domain, []float64{0, 1, 0, 1})
+		for _, x := range sampleRange(domain[0], domain[1]) {
+			for _, y := range sampleRange(domain[2], domain[3]) {
+				inputs = append(inputs, []float64{x, y})
+			}
+		}
+		functions = t.Function
+	case *PdfShadingType2:
+		domain := shadingDomain(t.Domain, []float64{0, 1})
+		inputs = sampleInputs(domain[0], domain[1])
+		functions = t.Function
+	case *PdfShadingType3:
+		domain := shadingDomain(t.Domain, []float64{0, 1})
+		inputs = sampleInputs(domain[0], domain[1])
+		functions = t.Function
+	case *PdfShadingType4:
+		functions, inputs = t.Function, meshSampleInputs(t.Function, t.Decode)
+	case *PdfShadingType5:
+		functions, inputs = t.Function, meshSampleInputs(t.Function, t.Decode)
+	case *PdfShadingType6:
+		functions, inputs = t.Function, meshSampleInputs(t.Function, t.Decode)
+	case *PdfShadingType7:
+		functions, inputs = t.Function, meshSampleInputs(t.Function, t.Decode)
+	}
+
+	var colors [][]float64
+	if len(functions) > 0 && len(inputs) > 0 {
+		for _, in := range inputs {
+			var vals []float64
+			for _, f := range functions {
+				outputs, err := f.Evaluate(in)
+				if err != nil {
+					return nil, err
+				}
+				vals = append(vals, outputs...)
+			}
+			colors = append(colors, vals)
+		}
+	} else if s.Background != nil {
+		vals, err := s.Background.ToFloat64Array()
+		if err != nil {
+			return nil, err
+		}
+		colors = append(colors, vals)
+	} else {
+		return nil, errors.New("shading colors undefined")
+	}
+
+	var r, g, b float64
+	for _, vals := range colors {
+		rgb, err := s.toRGB(vals)
+		if err != nil {
+			return nil, err
+		}
+		r += rgb.R()
+		g += rgb.G()
+		b += rgb.B()
+	}
+	n := float64(len(colors))
+	return NewPdfColorDeviceRGB(r/n, g/n, b/n), nil
+}
+
+// ColorAt returns the color of the shading `s` at point (`x`, `y`) of the shading space,
+// converted to RGB, or nil if the shading does not paint the point. The colors of shadings of
+// types 1-3 are evaluated at the point. The colors of mesh shadings are approximated by their
+// average color.
+func (s *PdfShading) ColorAt(x, y float64) (*PdfColorDeviceRGB, error) {
+	if s.ColorSpace == nil {
+		return nil, errors.New("shading colorspace undefined")
+	}
+
+	var functions []PdfFunction
+	var in []float64
+	switch t := s.context.(type) {
+	case *PdfShadingType1:
+		// The shading Matrix maps the domain to the shading space.
+		domain := shadingDomain(t.Domain, []float64{0, 1, 0, 1})
+		m := shadingDomain(t.Matrix, []float64{1, 0, 0, 1, 0, 0})
+		det := m[0]*m[3] - m[1]*m[2]
+		if det == 0 {
+			return nil, errors.New("singular shading matrix")
+		}
+		x, y = x-m[4], y-m[5]
+		u, v := (m[3]*x-m[2]*y)/det, (m[0]*y-m[1]*x)/det
+		if u < domain[0] || u > domain[1] || v < domain[2] || v > domain[3] {
+			return nil, nil
+		}
+		functions, in = t.Function, []float64{u, v}
+	case *PdfShadingType2:
+		coords := shadingDomain(t.Coords, []float64{0, 0, 0, 0})
+		dx, dy := coords[2]-coords[0], coords[3]-coords[1]
+		var st float64
+		if d := dx*dx + dy*dy; d != 0 {
+			st = ((x-coords[0])*dx + (y-coords[1])*dy) / d
+		}
+		var ok bool
+		if st, ok = extendParameter(st, t.Extend); !ok {
+			return nil, nil
+		}
+		domain := shadingDomain(t.Domain, []float64{0, 1})
+		functions, in = t.Function, []float64{domain[0] + st*(domain[1]-domain[0])}
+	case *PdfShadingType3:
+		coords := shadingDomain(t.Coords, []float64{0, 0, 0, 0, 0, 0})
+		st, ok := radialParameter(x, y, coords, t.Extend)
+		if !ok {
+			return nil, nil
+		}
+		domain := shadingDomain(t.Domain, []float64{0, 1})
+		functions, in = t.Function, []float64{domain[0] + st*(domain[1]-domain[0])}
+	default:
+		return s.AverageColor()
+	}
+
+	var vals []float64
+	for _, f := range functions {
+		outputs, err := f.Evaluate(in)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, outputs...)
+	}
+	return s.toRGB(vals)
+}
+
+// extendParameter returns the parametric variable `st` of axial and radial shadings clipped to
+// [0, 1] and true, or false if `st` is outside [0, 1] and the shading is not extended beyond the
+// corresponding end as specified by `extend`.
+func extendParameter(st float64, extend *core.PdfObjectArray) (float64, bool) {
+	var ext [2]bool
+	if extend != nil && extend.Len() == 2 {
+		ext[0], _ = core.GetBoolVal(extend.Get(0))
+		ext[1], _ = core.GetBoolVal(extend.Get(1))
+	}
+	switch {
+	case st < 0:
+		return 0, ext[0]
+	case st > 1:
+		return 1, ext[1]
+	}
+	return st, true
+}
+
+// radialParameter returns the parametric variable of radial shading with Coords `coords`
+// [x0 y0 r0 x1 y1 r1] at point (`x`, `y`), i.e. the largest s such that the point is on the circle
+// of center (1-s)*(x0, y0) + s*(x1, y1) and radius (1-s)*r0 + s*r1 >= 0, clipped to [0, 1].
+// It returns false if there is no such circle within the extension of the shading.
+func radialParameter(x, y float64, coords []float64, extend *core.PdfObjectArray) (float64, bool) {
+	x0, y0, r0 := coords[0], coords[1], coords[2]
+	cdx, cdy, dr := coords[3]-x0, coords[4]-y0, coords[5]-r0
+	pdx, pdy := x-x0, y-y0
+
+	// The circle of parameter s contains the point for a*s^2 - 2*b*s + c = 0.
+	a := cdx*cdx + cdy*cdy - dr*dr
+	b := pdx*cdx + pdy*cdy + r0*dr
+	c := pdx*pdx + pdy*pdy - r0*r0
+	var candidates []float64
+	if a == 0 {
+		if b != 0 {
+			candidates = append(candidates, c/(2*b))
+		}
+	} else if disc := b*b - a*c; disc >= 0 {
+		s1, s2 := (b+math.Sqrt(disc))/a, (b-math.Sqrt(disc))/a
+		candidates = append(candidates, math.Max(s1, s2), math.Min(s1, s2))
+	}
+	for _, st := range candidates {
+		if r0+st*dr < 0 {
+			continue
+		}
+		if st, ok := extendParameter(st, extend); ok {
+			return st, true
+		}
+	}
+	return 0, false
+}
+
+// toRGB returns the color of the shading colorspace components `vals` converted to RGB.
+func (s *PdfShading) toRGB(vals []float64) (*PdfColorDeviceRGB, error) {
+	color, err := s.ColorSpace.ColorFromFloats(vals)
+	if err != nil {
+		return nil, err
+	}
+	rgbColor, err := s.ColorSpace.ColorToRGB(color)
+	if err != nil {
+		return nil, err
+	}
+	rgb, ok := rgbColor.(*PdfColorDeviceRGB)
+	if !ok {
+		return nil, ErrTypeCheck
+	}
+	return rgb, nil
+}
+
+// shadingSamples is the number of samples of each input of shading functions used to compute
+// their average color.
+const shadingSamples = 11
+
+// sampleRange returns `shadingSamples` values uniformly distributed over [`x0`, `x1`].
+func sampleRange(x0, x1 float64) []float64 {
+	vals := make([]float64, shadingSamples)
+	for i := range vals {
+		vals[i] = x0 + (x1-x0)*float64(i)/(shadingSamples-1)
+	}
+	return vals
+}
+
+// sampleInputs returns the single-valued function inputs uniformly distributed over [`x0`, `x1`].
+func sampleInputs(x0, x1 float64) [][]float64 {
+	var inputs [][]float64
+	for _, x := range sampleRange(x0, x1) {
+		inputs = append(inputs, []float64{x})
+	}
+	return inputs
+}
+
+// shadingDomain returns the values of `domain`, or `defaults` if not set or invalid.
+func shadingDomain(domain *core.PdfObjectArray, defaults []float64) []float64 {
+	if domain == nil {
+		return defaults
+	}
+	vals, err := domain.ToFloat64Array()
+	if err != nil || len(vals) != len(defaults) {
+		common.Log.Debug("Invalid shading Domain %s", domain)
+		return defaults
+	}
+	return vals
+}
+
+// meshSampleInputs returns the function inputs of mesh shadings, uniformly distributed over the
+// range of the parametric variable t given by the Decode array [xmin xmax ymin ymax t0 t1].
+func meshSampleInputs(functions []PdfFunction, decode *core.PdfObjectArray) [][]float64 {
+	if len(functions) == 0 || decode == nil {
+		return nil
+	}
+	vals, err := decode.ToFloat64Array()
+	if err != nil || len(vals) < 6 {
+		common.Log.Debug("Invalid mesh shading Decode %s", decode)
+		return nil
+	}
+	return sampleInputs(vals[4], vals[5])
+}
+
 // PdfShadingType1 is a Function-based shading.
 type PdfShadingType1 struct {
 	*PdfShading
@@ -0,0 +1,136 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package sighandler
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+
+	"github.com/unidoc/pkcs7"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// ExternalSigner produces detached CMS/PKCS#7 signature containers for signing
+// keys which are not available in memory, such as keys stored in an HSM, a
+// PKCS#11 token, a cloud key management service or a remote signing service.
+// The signature handler returned by NewAdobePKCS7External takes care of the
+// ByteRange computation, the Contents placeholder sizing and the injection of
+// the signature container in the output file.
+type ExternalSigner interface {
+	// HashAlgorithm returns the hash algorithm used for computing the digest
+	// of the signed byte ranges.
+	HashAlgorithm() crypto.Hash
+
+	// EstimateSize returns the maximum size in bytes of the signature container
+	// returned by Sign. The value is used for reserving the space of the
+	// signature Contents entry and it must be deterministic, as the size of the
+	// placeholder is fixed before the document is hashed.
+	EstimateSize() int
+
+	// Sign returns the DER encoded detached CMS signature container for the
+	// signed byte ranges of the document. The data parameter contains the
+	// concatenated signed byte ranges and digest contains the hash of the data
+	// computed using the algorithm returned by HashAlgorithm. Implementations
+	// which only sign digests can ignore the data parameter.
+	Sign(data []byte, digest []byte) ([]byte, error)
+}
+
+// Adobe PKCS7 detached signature handler backed by an external signer.
+type adobePKCS7External struct {
+	signer ExternalSigner
+}
+
+// NewAdobePKCS7External creates a new Adobe.PPKMS/Adobe.PPKLite adbe.pkcs7.detached
+// signature handler which delegates the creation of the signature container
+// to the specified external signer.
+func NewAdobePKCS7External(signer ExternalSigner) (model.SignatureHandler, error) {
+	if signer == nil {
+		return nil, errors.New("external signer must not be nil")
+	}
+	if signer.EstimateSize() <= 0 {
+		return nil, errors.New("external signer estimated size must be positive")
+	}
+	if !signer.HashAlgorithm().Available() {
+		return nil, fmt.Errorf("unavailable external signer hash algorithm: %v", signer.HashAlgorithm())
+	}
+
+	return &adobePKCS7External{signer: signer}, nil
+}
+
+// InitSignature initialises the PdfSignature.
+func (a *adobePKCS7External) InitSignature(sig *model.PdfSignature) error {
+	handler := *a
+	sig.Handler = &handler
+	sig.Filter = core.MakeName("Adobe.PPKLite")
+	sig.SubFilter = core.MakeName("adbe.pkcs7.detached")
+	sig.Reference = nil
+
+	// Reserve the space of the signature container. The external signer is
+	// not invoked until the document data is available.
+	sig.Contents = core.MakeHexString(string(make([]byte, a.signer.EstimateSize())))
+	return nil
+}
+
+// NewDigest creates a new digest.
+func (a *adobePKCS7External) NewDigest(sig *model.PdfSignature) (model.Hasher, error) {
+	return bytes.NewBuffer(nil), nil
+}
+
+// Validate validates PdfSignature.
+func (a *adobePKCS7External) Validate(sig *model.PdfSignature, digest model.Hasher) (model.SignatureValidationResult, error) {
+	p7, err := pkcs7.Parse(sig.Contents.Bytes())
+	if err != nil {
+		return model.SignatureValidationResult{}, err
+	}
+
+	buffer := digest.(*bytes.Buffer)
+	p7.Content = buffer.Bytes()
+	if err = p7.Verify(); err != nil {
+		return model.SignatureValidationResult{}, err
+	}
+
+	return model.SignatureValidationResult{
+		IsSigned:   true,
+		IsVerified: true,
+	}, nil
+}
+
+// Sign sets the Contents fields.
+func (a *adobePKCS7External) Sign(sig *model.PdfSignature, digest model.Hasher) error {
+	buffer := digest.(*bytes.Buffer)
+	data := buffer.Bytes()
+
+	h := a.signer.HashAlgorithm().New()
+	h.Write(data)
+
+	signature, err := a.signer.Sign(data, h.Sum(nil))
+	if err != nil {
+		return err
+	}
+
+	size := a.signer.EstimateSize()
+	if len(signature) > size {
+		return fmt.Errorf("external signature size %d exceeds estimated size %d", len(signature), size)
+	}
+
+	contents := make([]byte, size)
+	copy(contents, signature)
+
+	sig.Contents = core.MakeHexString(string(contents))
+	return nil
+}
+
+// IsApplicable returns true if the signature handler is applicable for the PdfSignature
+func (a *adobePKCS7External) IsApplicable(sig *model.PdfSignature) bool {
+	if sig == nil || sig.Filter == nil || sig.SubFilter == nil {
+		return false
+	}
+	return (*sig.Filter == "Adobe.PPKMS" || *sig.Filter == "Adobe.PPKLite") && *sig.SubFilter == "adbe.pkcs7.detached"
+}
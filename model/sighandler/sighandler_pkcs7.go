@@ -7,15 +7,19 @@ package sighandler
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/asn1"
 	"errors"
 	"fmt"
 
 	"github.com/unidoc/pkcs7"
 
+	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
 	"github.com/unidoc/unipdf/v3/model"
+	"github.com/unidoc/unipdf/v3/model/sigutil"
 )
 
 // Adobe PKCS7 detached signature handler.
@@ -25,6 +29,40 @@ type adobePKCS7Detached struct {
 
 	emptySignature    bool
 	emptySignatureLen int
+
+	signatureLen           int
+	timestampServerURL     string
+	timestampClient        *sigutil.TimestampClient
+	timestampHashAlgorithm crypto.Hash
+	revocationData         *sigutil.RevocationData
+}
+
+// AdobePKCS7DetachedOpts defines options for configuring the Adobe PKCS7
+// detached signature handler.
+type AdobePKCS7DetachedOpts struct {
+	// TimestampServerURL is the URL of the RFC 3161 timestamp server. If set,
+	// a timestamp token of the signature value is requested from the server
+	// and embedded in the signature as an unsigned attribute.
+	TimestampServerURL string
+
+	// TimestampClient is the client used for requesting the timestamp tokens.
+	// If not set, a client created using sigutil.NewTimestampClient is used.
+	TimestampClient *sigutil.TimestampClient
+
+	// TimestampHashAlgorithm is the hash algorithm used for the timestamp
+	// requests. Defaults to crypto.SHA256.
+	TimestampHashAlgorithm crypto.Hash
+
+	// RevocationData is the revocation data of the signing certificate
+	// chain (see sigutil.RevocationClient). If set, it is embedded in the
+	// signature as an adbe-revocationInfoArchival signed attribute.
+	RevocationData *sigutil.RevocationData
+
+	// SignatureSize is the size in bytes reserved for the signature. If 0,
+	// 8192 bytes are reserved, unless the signature is timestamped or embeds
+	// revocation data, in which case the size is estimated when the
+	// signature is initialized.
+	SignatureSize int
 }
 
 // NewEmptyAdobePKCS7Detached creates a new Adobe.PPKMS/Adobe.PPKLite adbe.pkcs7.detached
@@ -41,11 +79,41 @@ func NewEmptyAdobePKCS7Detached(signatureLen int) (model.SignatureHandler, error
 // Both parameters may be nil for the signature validation.
 func NewAdobePKCS7Detached(privateKey *rsa.PrivateKey, certificate *x509.Certificate) (model.SignatureHandler, error) {
 	return &adobePKCS7Detached{
-		certificate: certificate,
-		privateKey:  privateKey,
+		certificate:  certificate,
+		privateKey:   privateKey,
+		signatureLen: 8192,
 	}, nil
 }
 
+// NewAdobePKCS7DetachedWithOpts creates a new Adobe.PPKMS/Adobe.PPKLite
+// adbe.pkcs7.detached signature handler, configured using the specified options.
+func NewAdobePKCS7DetachedWithOpts(privateKey *rsa.PrivateKey, certificate *x509.Certificate, opts *AdobePKCS7DetachedOpts) (model.SignatureHandler, error) {
+	if opts == nil {
+		opts = &AdobePKCS7DetachedOpts{}
+	}
+
+	handler := &adobePKCS7Detached{
+		certificate:            certificate,
+		privateKey:             privateKey,
+		signatureLen:           opts.SignatureSize,
+		timestampServerURL:     opts.TimestampServerURL,
+		timestampClient:        opts.TimestampClient,
+		timestampHashAlgorithm: opts.TimestampHashAlgorithm,
+		revocationData:         opts.RevocationData,
+	}
+	if handler.timestampClient == nil {
+		handler.timestampClient = sigutil.NewTimestampClient()
+	}
+	if handler.timestampHashAlgorithm == 0 {
+		handler.timestampHashAlgorithm = crypto.SHA256
+	}
+	if handler.signatureLen <= 0 && handler.timestampServerURL == "" && handler.revocationData == nil {
+		handler.signatureLen = 8192
+	}
+
+	return handler, nil
+}
+
 // InitSignature initialises the PdfSignature.
 func (a *adobePKCS7Detached) InitSignature(sig *model.PdfSignature) error {
 	if !a.emptySignature {
@@ -57,12 +125,29 @@ func (a *adobePKCS7Detached) InitSignature(sig *model.PdfSignature) error {
 		}
 	}
 
+	if !a.emptySignature && a.signatureLen <= 0 {
+		// Estimate the size of the Contents field using a sample signature.
+		// The estimate is stored on the original handler, which is the one
+		// used for signing the document.
+		sample, err := a.createSignature([]byte("calculate the Contents field size"))
+		if err != nil {
+			return err
+		}
+		a.signatureLen = len(sample) + timestampTokenPadding
+	}
+
 	handler := *a
 	sig.Handler = &handler
 	sig.Filter = core.MakeName("Adobe.PPKLite")
 	sig.SubFilter = core.MakeName("adbe.pkcs7.detached")
 	sig.Reference = nil
 
+	if handler.timestampServerURL != "" {
+		// Avoid requesting another timestamp token for the placeholder.
+		sig.Contents = core.MakeHexString(string(make([]byte, handler.signatureLen)))
+		return nil
+	}
+
 	digest, err := handler.NewDigest(sig)
 	if err != nil {
 		return err
@@ -122,10 +207,28 @@ func (a *adobePKCS7Detached) Validate(sig *model.PdfSignature, digest model.Hash
 		return model.SignatureValidationResult{}, err
 	}
 
-	return model.SignatureValidationResult{
+	res := model.SignatureValidationResult{
 		IsSigned:   true,
 		IsVerified: true,
-	}, nil
+	}
+
+	// Validate the timestamp token embedded in the signature, if any.
+	for _, attr := range p7.Signers[0].UnauthenticatedAttributes {
+		if !attr.Type.Equal(oidAttributeTimeStampToken) {
+			continue
+		}
+
+		ts, err := validateTimestampToken(attr.Value.Bytes, p7.Signers[0].EncryptedDigest)
+		if err != nil {
+			res.IsVerified = false
+			res.Errors = append(res.Errors, fmt.Sprintf("invalid signature timestamp: %v", err))
+			break
+		}
+		res.GeneralizedTime = ts.Time
+		break
+	}
+
+	return res, nil
 }
 
 // Sign sets the Contents fields.
@@ -141,30 +244,82 @@ func (a *adobePKCS7Detached) Sign(sig *model.PdfSignature, digest model.Hasher)
 	}
 
 	buffer := digest.(*bytes.Buffer)
-	signedData, err := pkcs7.NewSignedData(buffer.Bytes())
+	attempts := 1
+	if a.timestampServerURL != "" {
+		attempts = timestampMaxAttempts
+	}
+
+	var detachedSignature []byte
+	for i := 0; i < attempts; i++ {
+		var err error
+		if detachedSignature, err = a.createSignature(buffer.Bytes()); err != nil {
+			return err
+		}
+		if len(detachedSignature) <= a.signatureLen {
+			break
+		}
+		common.Log.Debug("Signature size %d exceeds reserved size %d (attempt %d)",
+			len(detachedSignature), a.signatureLen, i+1)
+	}
+	if len(detachedSignature) > a.signatureLen {
+		return fmt.Errorf("signature size %d exceeds reserved size %d", len(detachedSignature), a.signatureLen)
+	}
+
+	data := make([]byte, a.signatureLen)
+	copy(data, detachedSignature)
+
+	sig.Contents = core.MakeHexString(string(data))
+	return nil
+}
+
+// createSignature returns the detached signature of the specified data.
+// If a timestamp server is configured, a timestamp token of the signature
+// value is embedded in the signature as an unsigned attribute. The
+// revocation data, if any, is embedded as a signed attribute.
+func (a *adobePKCS7Detached) createSignature(data []byte) ([]byte, error) {
+	signedData, err := pkcs7.NewSignedData(data)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	var config pkcs7.SignerInfoConfig
+	if a.revocationData != nil {
+		archival, err := a.revocationData.RevocationInfoArchival()
+		if err != nil {
+			return nil, err
+		}
+		config.ExtraSignedAttributes = append(config.ExtraSignedAttributes, pkcs7.Attribute{
+			Type:  sigutil.OIDRevocationInfoArchival,
+			Value: asn1.RawValue{FullBytes: archival},
+		})
 	}
 
 	// Add the signing cert and private key
-	if err := signedData.AddSigner(a.certificate, a.privateKey, pkcs7.SignerInfoConfig{}); err != nil {
-		return err
+	if err := signedData.AddSigner(a.certificate, a.privateKey, config); err != nil {
+		return nil, err
+	}
+
+	if a.timestampServerURL != "" {
+		signerInfo := &signedData.GetSignedData().SignerInfos[0]
+		token, err := requestTimestampToken(a.timestampClient, a.timestampServerURL,
+			signerInfo.EncryptedDigest, a.timestampHashAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+
+		err = signerInfo.SetUnauthenticatedAttributes([]pkcs7.Attribute{
+			{Type: oidAttributeTimeStampToken, Value: asn1.RawValue{FullBytes: token}},
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Call Detach() is you want to remove content from the signature
 	// and generate an S/MIME detached signature
 	signedData.Detach()
 	// Finish() to obtain the signature bytes
-	detachedSignature, err := signedData.Finish()
-	if err != nil {
-		return err
-	}
-
-	data := make([]byte, 8192)
-	copy(data, detachedSignature)
-
-	sig.Contents = core.MakeHexString(string(data))
-	return nil
+	return signedData.Finish()
 }
 
 // IsApplicable returns true if the signature handler is applicable for the PdfSignature
@@ -13,46 +13,103 @@ import (
 	"encoding/asn1"
 	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"net/http"
 	"time"
 
 	"github.com/unidoc/pkcs7"
 	"github.com/unidoc/timestamp"
+
+	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
 	"github.com/unidoc/unipdf/v3/model"
+	"github.com/unidoc/unipdf/v3/model/sigutil"
+)
+
+// oidAttributeTimeStampToken is the object identifier of the signature
+// timestamp token unsigned attribute (id-aa-signatureTimeStampToken).
+var oidAttributeTimeStampToken = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}
+
+const (
+	// timestampTokenPadding is the number of bytes reserved in addition to the
+	// estimated size of the timestamp tokens, as the size of the tokens
+	// returned by a timestamp server can vary between requests.
+	timestampTokenPadding = 1024
+
+	// timestampMaxAttempts is the maximum number of timestamp requests made
+	// when the returned tokens do not fit in the reserved space.
+	timestampMaxAttempts = 3
 )
 
 // docTimeStamp DocTimeStamp signature handler.
 type docTimeStamp struct {
 	timestampServerURL string
 	hashAlgorithm      crypto.Hash
+	client             *sigutil.TimestampClient
+	signatureLen       int
+}
+
+// DocTimeStampOpts defines options for configuring the DocTimeStamp
+// signature handler.
+type DocTimeStampOpts struct {
+	// Client is the client used for requesting the timestamp tokens.
+	// If not set, a client created using sigutil.NewTimestampClient is used.
+	Client *sigutil.TimestampClient
+
+	// SignatureSize is the size in bytes reserved for the timestamp token.
+	// If 0, the size is estimated by requesting a token from the timestamp
+	// server when the signature is initialized.
+	SignatureSize int
 }
 
 // NewDocTimeStamp creates a new DocTimeStamp signature handler.
 // The timestampServerURL parameter can be empty string for the signature validation.
 // The hashAlgorithm parameter can be crypto.SHA1, crypto.SHA256, crypto.SHA384, crypto.SHA512.
 func NewDocTimeStamp(timestampServerURL string, hashAlgorithm crypto.Hash) (model.SignatureHandler, error) {
+	return NewDocTimeStampWithOpts(timestampServerURL, hashAlgorithm, nil)
+}
+
+// NewDocTimeStampWithOpts creates a new DocTimeStamp signature handler,
+// configured using the specified options.
+// The timestampServerURL parameter can be empty string for the signature validation.
+// The hashAlgorithm parameter can be crypto.SHA1, crypto.SHA256, crypto.SHA384, crypto.SHA512.
+func NewDocTimeStampWithOpts(timestampServerURL string, hashAlgorithm crypto.Hash, opts *DocTimeStampOpts) (model.SignatureHandler, error) {
+	if opts == nil {
+		opts = &DocTimeStampOpts{}
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = sigutil.NewTimestampClient()
+	}
+
 	return &docTimeStamp{
 		timestampServerURL: timestampServerURL,
 		hashAlgorithm:      hashAlgorithm,
+		client:             client,
+		signatureLen:       opts.SignatureSize,
 	}, nil
 }
 
 // InitSignature initialises the PdfSignature.
 func (a *docTimeStamp) InitSignature(sig *model.PdfSignature) error {
+	if a.signatureLen <= 0 {
+		// Estimate the size of the Contents field using a sample token.
+		// The estimate is stored on the original handler, which is the one
+		// used for signing the document.
+		token, err := requestTimestampToken(a.client, a.timestampServerURL,
+			[]byte("calculate the Contents field size"), a.hashAlgorithm)
+		if err != nil {
+			return err
+		}
+		a.signatureLen = len(token) + timestampTokenPadding
+	}
+
 	handler := *a
 	sig.Handler = &handler
 	sig.Filter = core.MakeName("Adobe.PPKLite")
 	sig.SubFilter = core.MakeName("ETSI.RFC3161")
 	sig.Reference = nil
-	digest, err := a.NewDigest(sig)
-	if err != nil {
-		return err
-	}
-	digest.Write([]byte("calculate the Contents field size"))
-	return handler.Sign(sig, digest)
+	sig.Contents = core.MakeHexString(string(make([]byte, handler.signatureLen)))
+	return nil
 }
 
 func (a *docTimeStamp) getCertificate(sig *model.PdfSignature) (*x509.Certificate, error) {
@@ -154,52 +211,68 @@ func (a *docTimeStamp) Validate(sig *model.PdfSignature, digest model.Hasher) (m
 // Sign sets the Contents fields for the PdfSignature.
 func (a *docTimeStamp) Sign(sig *model.PdfSignature, digest model.Hasher) error {
 	buffer := digest.(*bytes.Buffer)
-	h := a.hashAlgorithm.New()
 
-	if _, err := io.Copy(h, buffer); err != nil {
-		return err
+	var token []byte
+	for i := 0; i < timestampMaxAttempts; i++ {
+		var err error
+		token, err = requestTimestampToken(a.client, a.timestampServerURL, buffer.Bytes(), a.hashAlgorithm)
+		if err != nil {
+			return err
+		}
+		if a.signatureLen <= 0 || len(token) <= a.signatureLen {
+			break
+		}
+		common.Log.Debug("Timestamp token size %d exceeds reserved size %d (attempt %d)",
+			len(token), a.signatureLen, i+1)
 	}
 
-	s := h.Sum(nil)
-	r := timestamp.Request{
-		HashAlgorithm:   a.hashAlgorithm,
-		HashedMessage:   s,
-		Certificates:    true,
-		Extensions:      nil,
-		ExtraExtensions: nil,
+	sigLen := a.signatureLen
+	if sigLen <= 0 {
+		sigLen = len(token)
 	}
-	data, err := r.Marshal()
-	if err != nil {
-		return err
+	if len(token) > sigLen {
+		return fmt.Errorf("timestamp token size %d exceeds reserved size %d", len(token), sigLen)
 	}
 
-	resp, err := http.Post(a.timestampServerURL, "application/timestamp-query", bytes.NewBuffer(data))
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+	data := make([]byte, sigLen)
+	copy(data, token)
+
+	sig.Contents = core.MakeHexString(string(data))
+	return nil
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+// requestTimestampToken requests a timestamp token for the specified data
+// from the timestamp server and returns its DER encoded form.
+func requestTimestampToken(client *sigutil.TimestampClient, serverURL string, data []byte, hashAlgorithm crypto.Hash) ([]byte, error) {
+	req, err := sigutil.NewTimestampRequest(bytes.NewReader(data), hashAlgorithm)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("http status code not ok (got %d)", resp.StatusCode)
+	if client == nil {
+		client = sigutil.NewTimestampClient()
 	}
 
-	var ci struct {
-		Version asn1.RawValue
-		Content asn1.RawValue
-	}
+	return client.GetEncodedToken(serverURL, req)
+}
 
-	_, err = asn1.Unmarshal(body, &ci)
+// validateTimestampToken parses the specified DER encoded timestamp token and
+// checks that it has been issued for the specified data.
+func validateTimestampToken(token []byte, data []byte) (*timestamp.Timestamp, error) {
+	ts, err := timestamp.Parse(token)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if !ts.HashAlgorithm.Available() {
+		return nil, fmt.Errorf("unavailable timestamp hash algorithm: %v", ts.HashAlgorithm)
 	}
 
-	sig.Contents = core.MakeHexString(string(ci.Content.FullBytes))
-	return nil
+	h := ts.HashAlgorithm.New()
+	h.Write(data)
+	if !bytes.Equal(h.Sum(nil), ts.HashedMessage) {
+		return nil, errors.New("timestamp message imprint mismatch")
+	}
+
+	return ts, nil
 }
 
 // IsApplicable returns true if the signature handler is applicable for the PdfSignature.
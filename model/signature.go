@@ -8,6 +8,7 @@ package model
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/unidoc/unipdf/v3/common"
@@ -150,6 +151,65 @@ func (sig *PdfSignature) SetLocation(location string) {
 	sig.Location = core.MakeString(location)
 }
 
+// DocMDP access permissions of certification signatures, specifying the
+// changes permitted after certifying a document (Table 254 - Entries in the
+// DocMDP transform parameters dictionary).
+const (
+	// DocMDPNoChanges permits no changes to the document.
+	DocMDPNoChanges = 1
+
+	// DocMDPFillForms permits filling in forms, instantiating page templates
+	// and signing.
+	DocMDPFillForms = 2
+
+	// DocMDPAnnotate permits the changes of DocMDPFillForms, as well as
+	// creating, deleting and modifying annotations.
+	DocMDPAnnotate = 3
+)
+
+// SetCertification makes the signature a certification signature, which
+// declares the changes permitted after signing by the DocMDP access
+// permission `p` (DocMDPNoChanges, DocMDPFillForms or DocMDPAnnotate).
+// A certification signature must be the first signature of the document.
+// NOTE: Must be called after Initialize, as the signature handlers may reset
+// the signature references.
+func (sig *PdfSignature) SetCertification(p int) error {
+	if p < DocMDPNoChanges || p > DocMDPAnnotate {
+		return fmt.Errorf("invalid DocMDP permission: %d", p)
+	}
+
+	params := core.MakeDict()
+	params.Set("Type", core.MakeName("TransformParams"))
+	params.Set("P", core.MakeInteger(int64(p)))
+	params.Set("V", core.MakeName("1.2"))
+	sig.setReference("DocMDP", params)
+	return nil
+}
+
+// setReference sets the signature reference dictionary with the specified
+// transform method and parameters, replacing any reference with the same
+// transform method.
+func (sig *PdfSignature) setReference(method string, params *core.PdfObjectDictionary) {
+	ref := core.MakeDict()
+	ref.Set("Type", core.MakeName("SigRef"))
+	ref.Set("TransformMethod", core.MakeName(method))
+	ref.Set("TransformParams", params)
+
+	refs := core.MakeArray()
+	if sig.Reference != nil {
+		for _, obj := range sig.Reference.Elements() {
+			if dict, ok := core.GetDict(obj); ok {
+				if name, _ := core.GetNameVal(dict.Get("TransformMethod")); name == method {
+					continue
+				}
+			}
+			refs.Append(obj)
+		}
+	}
+	refs.Append(ref)
+	sig.Reference = refs
+}
+
 // Initialize initializes the PdfSignature.
 func (sig *PdfSignature) Initialize() error {
 	if sig.Handler == nil {
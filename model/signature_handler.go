@@ -100,6 +100,9 @@ func (v SignatureValidationResult) String() string {
 
 // ValidateSignatures validates digital signatures in the document.
 func (r *PdfReader) ValidateSignatures(handlers []SignatureHandler) ([]SignatureValidationResult, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return nil, err
+	}
 	if r.AcroForm == nil {
 		return nil, nil
 	}
@@ -0,0 +1,916 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// RevocationStatus represents the revocation status of a signing certificate chain.
+type RevocationStatus int
+
+const (
+	// RevocationUnknown indicates that no revocation data was found for
+	// at least one of the certificates of the chain.
+	RevocationUnknown RevocationStatus = iota
+
+	// RevocationGood indicates that none of the certificates of the chain
+	// have been revoked.
+	RevocationGood
+
+	// RevocationRevoked indicates that at least one of the certificates of
+	// the chain has been revoked.
+	RevocationRevoked
+)
+
+// String returns a string representation of the revocation status.
+func (s RevocationStatus) String() string {
+	switch s {
+	case RevocationGood:
+		return "good"
+	case RevocationRevoked:
+		return "revoked"
+	}
+	return "unknown"
+}
+
+// ModificationKind represents the kind of a change made to a document after
+// it has been signed.
+type ModificationKind int
+
+const (
+	// ModificationStructure represents changes of the file structure,
+	// such as cross-reference streams, the document information dictionary
+	// or new objects which are not referenced by the other modifications.
+	ModificationStructure ModificationKind = iota
+
+	// ModificationDSS represents changes of the document security store,
+	// as well as document timestamps.
+	ModificationDSS
+
+	// ModificationSignature represents new signatures.
+	ModificationSignature
+
+	// ModificationForm represents changes of the form fields, such as
+	// filling in field values and updating their appearances.
+	ModificationForm
+
+	// ModificationAnnotation represents changes of the annotations.
+	ModificationAnnotation
+
+	// ModificationOther represents any other changes, such as changes of
+	// the page contents.
+	ModificationOther
+)
+
+// String returns a string representation of the modification kind.
+func (k ModificationKind) String() string {
+	switch k {
+	case ModificationStructure:
+		return "structure"
+	case ModificationDSS:
+		return "dss"
+	case ModificationSignature:
+		return "signature"
+	case ModificationForm:
+		return "form"
+	case ModificationAnnotation:
+		return "annotation"
+	}
+	return "other"
+}
+
+// SignatureModification represents an object which has been added or changed
+// in the incremental updates following a signature.
+type SignatureModification struct {
+	ObjectNumber int64
+	Kind         ModificationKind
+	Added        bool
+	Permitted    bool
+
+	// FieldName is the full name of the modified field, if the object
+	// represents a form field or a widget annotation.
+	FieldName string
+}
+
+// SignatureReport contains the validation results of a signature.
+type SignatureReport struct {
+	// FieldName is the full name of the signature field.
+	FieldName string
+	Signature *PdfSignature
+
+	// Result is the result of the signature handler validation.
+	Result SignatureValidationResult
+
+	// ByteRangeValid specifies whether the ByteRange of the signature starts
+	// at the beginning of the file and excludes only the Contents hex string.
+	ByteRangeValid bool
+
+	// CoversWholeDocument specifies whether the signature covers the whole
+	// file, i.e. no incremental updates have been made after signing.
+	CoversWholeDocument bool
+
+	// DigestValid specifies whether the signature value and the digest of
+	// the signed byte ranges have been successfully verified.
+	DigestValid bool
+
+	// Certificates contains the certificates of the signature. The signing
+	// certificate is the first in the list.
+	Certificates []*x509.Certificate
+
+	// Chain is the verified certificate chain of the signing certificate.
+	// Only set if the certificate is trusted.
+	Chain []*x509.Certificate
+
+	// IsTrusted specifies whether the signing certificate chains up to one
+	// of the trusted root certificates of the validator.
+	IsTrusted bool
+
+	// Revocation is the revocation status of the verified certificate chain.
+	Revocation RevocationStatus
+
+	// ValidationTime is the time the certificate chain was validated at:
+	// the timestamp time, if any, or the signing time.
+	ValidationTime time.Time
+
+	// DocMDPPermission is the access permission of the certification
+	// signature of the document (1-3) or 0 if the document is not certified.
+	DocMDPPermission int
+
+	// Modifications contains the changes made after signing.
+	Modifications []*SignatureModification
+
+	// ModificationsPermitted specifies whether all the changes made after
+	// signing are permitted by the DocMDP and FieldMDP restrictions.
+	ModificationsPermitted bool
+
+	Errors []string
+}
+
+// IsValid returns true if the signature is valid: the byte range and the
+// digest have been verified, the certificates are not revoked and no
+// forbidden changes have been made after signing. If requireTrust is true,
+// the signing certificate must also be trusted.
+func (r *SignatureReport) IsValid(requireTrust bool) bool {
+	if !r.ByteRangeValid || !r.DigestValid || !r.ModificationsPermitted {
+		return false
+	}
+	if requireTrust && !r.IsTrusted {
+		return false
+	}
+	return r.Revocation != RevocationRevoked
+}
+
+// String returns a string representation of the report.
+func (r *SignatureReport) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("Field: %s\n", r.FieldName))
+	buf.WriteString(fmt.Sprintf("ByteRange valid: %t\n", r.ByteRangeValid))
+	buf.WriteString(fmt.Sprintf("Covers whole document: %t\n", r.CoversWholeDocument))
+	buf.WriteString(fmt.Sprintf("Digest valid: %t\n", r.DigestValid))
+	buf.WriteString(fmt.Sprintf("Trusted: %t\n", r.IsTrusted))
+	buf.WriteString(fmt.Sprintf("Revocation: %s\n", r.Revocation))
+	if !r.ValidationTime.IsZero() {
+		buf.WriteString(fmt.Sprintf("Validation time: %s\n", r.ValidationTime))
+	}
+	if r.DocMDPPermission > 0 {
+		buf.WriteString(fmt.Sprintf("DocMDP permission: %d\n", r.DocMDPPermission))
+	}
+	buf.WriteString(fmt.Sprintf("Modifications: %d (permitted: %t)\n", len(r.Modifications), r.ModificationsPermitted))
+	for _, err := range r.Errors {
+		buf.WriteString(fmt.Sprintf("Error: %s\n", err))
+	}
+	return buf.String()
+}
+
+// SignatureValidator validates the digital signatures of a document.
+type SignatureValidator struct {
+	// Handlers are used for verifying the signature values.
+	Handlers []SignatureHandler
+
+	// TrustedRoots contains the trusted root certificates. If nil, the
+	// certificate chains are not validated.
+	TrustedRoots *x509.CertPool
+
+	// Intermediates contains additional intermediate certificates used for
+	// building the certificate chains, besides the certificates of the
+	// signatures and of the document security store.
+	Intermediates []*x509.Certificate
+
+	// CheckRevocation specifies whether the revocation status of the
+	// certificate chains is checked using the OCSP responses and CRLs
+	// embedded in the document security store.
+	CheckRevocation bool
+}
+
+// NewSignatureValidator returns a new signature validator which uses the
+// specified handlers for verifying the signature values.
+func NewSignatureValidator(handlers ...SignatureHandler) *SignatureValidator {
+	return &SignatureValidator{
+		Handlers: handlers,
+	}
+}
+
+// sigValidationContext contains the data shared by the validation of the
+// signatures of a document.
+type sigValidationContext struct {
+	reader *PdfReader
+	data   []byte
+	dss    *DSS
+
+	// Object numbers of the field and widget annotation containers, mapped
+	// to the full names of the fields.
+	fieldNames map[int64]string
+	pageNums   map[int64]struct{}
+
+	docMDPPermission int
+}
+
+// Validate validates all the signatures of the document loaded by the
+// specified reader and returns a report for each of them.
+func (v *SignatureValidator) Validate(reader *PdfReader) ([]*SignatureReport, error) {
+	if reader.AcroForm == nil {
+		return nil, nil
+	}
+
+	if _, err := reader.rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(reader.rs)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := &sigValidationContext{
+		reader:     reader,
+		data:       data,
+		fieldNames: map[int64]string{},
+		pageNums:   map[int64]struct{}{},
+	}
+	if ctx.dss, err = reader.GetDSS(); err != nil {
+		common.Log.Debug("ERROR: invalid DSS: %v", err)
+	}
+	for _, page := range reader.PageList {
+		if ind := page.GetPageAsIndirectObject(); ind != nil {
+			ctx.pageNums[ind.ObjectNumber] = struct{}{}
+		}
+	}
+
+	type sigField struct {
+		name  string
+		field *PdfFieldSignature
+		sig   *PdfSignature
+	}
+
+	var fields []sigField
+	for _, f := range reader.AcroForm.AllFields() {
+		name, _ := f.FullName()
+		if ind, ok := core.GetIndirect(f.GetContainingPdfObject()); ok {
+			ctx.fieldNames[ind.ObjectNumber] = name
+		}
+		for _, wa := range f.Annotations {
+			if ind, ok := core.GetIndirect(wa.GetContainingPdfObject()); ok {
+				ctx.fieldNames[ind.ObjectNumber] = name
+			}
+		}
+
+		sf, ok := f.GetContext().(*PdfFieldSignature)
+		if !ok || sf.V == nil {
+			continue
+		}
+		fields = append(fields, sigField{name: name, field: sf, sig: sf.V})
+
+		if p := getDocMDPPermission(sf.V); p > 0 {
+			ctx.docMDPPermission = p
+		}
+	}
+
+	var reports []*SignatureReport
+	for _, f := range fields {
+		report := &SignatureReport{
+			FieldName:        f.name,
+			Signature:        f.sig,
+			Revocation:       RevocationUnknown,
+			DocMDPPermission: ctx.docMDPPermission,
+		}
+		v.validateSignature(ctx, f.field, report)
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// validateSignature validates the signature of the specified field and
+// populates the report with the results.
+func (v *SignatureValidator) validateSignature(ctx *sigValidationContext, field *PdfFieldSignature, report *SignatureReport) {
+	sig := field.V
+	addError := func(format string, args ...interface{}) {
+		report.Errors = append(report.Errors, fmt.Sprintf(format, args...))
+	}
+
+	// Check the byte range.
+	ranges, err := checkSignatureByteRange(sig, ctx.data)
+	if err != nil {
+		addError("invalid ByteRange: %v", err)
+		return
+	}
+	report.ByteRangeValid = true
+	revisionEnd := ranges[3]
+	report.CoversWholeDocument = len(bytes.TrimSpace(ctx.data[revisionEnd:])) == 0
+
+	// Verify the signature value.
+	var handler SignatureHandler
+	for _, h := range v.Handlers {
+		if h.IsApplicable(sig) {
+			handler = h
+			break
+		}
+	}
+	if handler == nil {
+		addError("signature handler not found")
+	} else {
+		digest, err := handler.NewDigest(sig)
+		if err != nil {
+			addError("digest error: %v", err)
+		} else {
+			digest.Write(ctx.data[ranges[0]:ranges[1]])
+			digest.Write(ctx.data[ranges[2]:ranges[3]])
+
+			result, err := handler.Validate(sig, digest)
+			if err != nil {
+				addError("signature verification failed: %v", err)
+			} else {
+				report.Result = result
+				report.DigestValid = result.IsVerified
+				report.Errors = append(report.Errors, result.Errors...)
+			}
+		}
+	}
+
+	// Determine the validation time.
+	report.ValidationTime = report.Result.GeneralizedTime
+	if report.ValidationTime.IsZero() && sig.M != nil {
+		if date, err := NewPdfDate(sig.M.Decoded()); err == nil {
+			report.ValidationTime = date.ToGoTime()
+		}
+	}
+	if report.ValidationTime.IsZero() {
+		report.ValidationTime = time.Now()
+	}
+
+	// Validate the certificate chain.
+	certs, err := getSignatureCertificates(sig)
+	if err != nil {
+		addError("invalid signature certificates: %v", err)
+	}
+	report.Certificates = certs
+	if len(certs) > 0 && v.TrustedRoots != nil {
+		v.validateChain(ctx, report)
+	}
+
+	// Analyze the changes made after signing.
+	report.ModificationsPermitted = true
+	if !report.CoversWholeDocument {
+		if err := v.checkModifications(ctx, field, revisionEnd, report); err != nil {
+			report.ModificationsPermitted = false
+			addError("could not analyze modifications: %v", err)
+		}
+	}
+}
+
+// validateChain validates the certificate chain of the signing certificate
+// and checks its revocation status.
+func (v *SignatureValidator) validateChain(ctx *sigValidationContext, report *SignatureReport) {
+	intermediates := x509.NewCertPool()
+	for _, cert := range v.Intermediates {
+		intermediates.AddCert(cert)
+	}
+	for _, cert := range report.Certificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	if ctx.dss != nil {
+		for _, stream := range ctx.dss.Certs {
+			data, err := core.DecodeStream(stream)
+			if err != nil {
+				continue
+			}
+			if cert, err := x509.ParseCertificate(data); err == nil {
+				intermediates.AddCert(cert)
+			}
+		}
+	}
+
+	chains, err := report.Certificates[0].Verify(x509.VerifyOptions{
+		Roots:         v.TrustedRoots,
+		Intermediates: intermediates,
+		CurrentTime:   report.ValidationTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("untrusted certificate: %v", err))
+		return
+	}
+	report.IsTrusted = true
+	report.Chain = chains[0]
+
+	if v.CheckRevocation {
+		report.Revocation = checkChainRevocation(ctx.dss, report.Chain, report.ValidationTime)
+	}
+}
+
+// checkChainRevocation returns the revocation status of the specified
+// certificate chain, using the OCSP responses and CRLs of the DSS.
+func checkChainRevocation(dss *DSS, chain []*x509.Certificate, validationTime time.Time) RevocationStatus {
+	if dss == nil {
+		return RevocationUnknown
+	}
+
+	decode := func(streams []*core.PdfObjectStream) [][]byte {
+		var items [][]byte
+		for _, stream := range streams {
+			if data, err := core.DecodeStream(stream); err == nil {
+				items = append(items, data)
+			}
+		}
+		return items
+	}
+	ocsps := decode(dss.OCSPs)
+	crls := decode(dss.CRLs)
+
+	status := RevocationGood
+	for i := 0; i < len(chain)-1; i++ {
+		cert, issuer := chain[i], chain[i+1]
+		certStatus := RevocationUnknown
+
+		for _, data := range ocsps {
+			resp, err := ocsp.ParseResponseForCert(data, cert, issuer)
+			if err != nil {
+				continue
+			}
+			switch resp.Status {
+			case ocsp.Good:
+				certStatus = RevocationGood
+			case ocsp.Revoked:
+				if resp.RevokedAt.Before(validationTime) {
+					certStatus = RevocationRevoked
+				} else {
+					certStatus = RevocationGood
+				}
+			}
+			break
+		}
+
+		if certStatus == RevocationUnknown {
+			for _, data := range crls {
+				crl, err := x509.ParseDERCRL(data)
+				if err != nil || issuer.CheckCRLSignature(crl) != nil {
+					continue
+				}
+				certStatus = RevocationGood
+				for _, revoked := range crl.TBSCertList.RevokedCertificates {
+					if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 &&
+						revoked.RevocationTime.Before(validationTime) {
+						certStatus = RevocationRevoked
+						break
+					}
+				}
+				break
+			}
+		}
+
+		switch certStatus {
+		case RevocationRevoked:
+			return RevocationRevoked
+		case RevocationUnknown:
+			status = RevocationUnknown
+		}
+	}
+
+	return status
+}
+
+// checkSignatureByteRange checks that the ByteRange of the signature starts
+// at the beginning of the file and excludes only the Contents hex string of
+// the signature. Returns the start and end offsets of the two ranges.
+func checkSignatureByteRange(sig *PdfSignature, data []byte) ([4]int64, error) {
+	var ranges [4]int64
+	if sig.ByteRange == nil || sig.ByteRange.Len() != 4 {
+		return ranges, errors.New("ByteRange must contain 4 values")
+	}
+
+	var values [4]int64
+	for i := range values {
+		val, err := core.GetNumberAsInt64(sig.ByteRange.Get(i))
+		if err != nil {
+			return ranges, err
+		}
+		if val < 0 {
+			return ranges, errors.New("negative ByteRange value")
+		}
+		values[i] = val
+	}
+
+	ranges = [4]int64{values[0], values[0] + values[1], values[2], values[2] + values[3]}
+	switch {
+	case ranges[0] != 0:
+		return ranges, errors.New("signed data does not start at the beginning of the file")
+	case ranges[2] <= ranges[1]:
+		return ranges, errors.New("overlapping byte ranges")
+	case ranges[3] > int64(len(data)):
+		return ranges, errors.New("byte range exceeds the file size")
+	}
+
+	// The gap must contain exactly the Contents hex string.
+	gap := data[ranges[1]:ranges[2]]
+	if len(gap) < 2 || gap[0] != '<' || gap[len(gap)-1] != '>' {
+		return ranges, errors.New("excluded range is not a hex string")
+	}
+	contents, err := hex.DecodeString(string(gap[1 : len(gap)-1]))
+	if err != nil {
+		return ranges, errors.New("excluded range is not a hex string")
+	}
+	if sig.Contents == nil || !bytes.Equal(contents, sig.Contents.Bytes()) {
+		return ranges, errors.New("excluded range does not match the signature contents")
+	}
+
+	return ranges, nil
+}
+
+// getDocMDPPermission returns the DocMDP access permission of the specified
+// signature, or 0 if the signature is not a certification signature.
+func getDocMDPPermission(sig *PdfSignature) int {
+	if sig.Reference == nil {
+		return 0
+	}
+
+	for _, obj := range sig.Reference.Elements() {
+		ref, ok := core.GetDict(obj)
+		if !ok {
+			continue
+		}
+		if method, _ := core.GetNameVal(ref.Get("TransformMethod")); method != "DocMDP" {
+			continue
+		}
+
+		p := 2
+		if params, ok := core.GetDict(ref.Get("TransformParams")); ok {
+			if val, ok := core.GetIntVal(params.Get("P")); ok && val >= 1 && val <= 3 {
+				p = val
+			}
+		}
+		return p
+	}
+
+	return 0
+}
+
+// fieldLocks represents the fields locked by a signature (FieldMDP).
+type fieldLocks struct {
+	action string
+	fields map[string]struct{}
+}
+
+// isLocked returns true if the field with the specified name is locked.
+func (l *fieldLocks) isLocked(name string) bool {
+	if l == nil {
+		return false
+	}
+	_, found := l.fields[name]
+	switch l.action {
+	case "All":
+		return true
+	case "Include":
+		return found
+	case "Exclude":
+		return !found
+	}
+	return false
+}
+
+// getFieldLocks returns the fields locked by the signature of the specified
+// field, using the signature FieldMDP references and the field Lock entry.
+func getFieldLocks(field *PdfFieldSignature) *fieldLocks {
+	parse := func(obj core.PdfObject) *fieldLocks {
+		dict, ok := core.GetDict(obj)
+		if !ok {
+			return nil
+		}
+		action, ok := core.GetNameVal(dict.Get("Action"))
+		if !ok {
+			return nil
+		}
+		locks := &fieldLocks{action: action, fields: map[string]struct{}{}}
+		if arr, ok := core.GetArray(dict.Get("Fields")); ok {
+			for _, o := range arr.Elements() {
+				if name, ok := core.GetString(o); ok {
+					locks.fields[name.Decoded()] = struct{}{}
+				}
+			}
+		}
+		return locks
+	}
+
+	if field.V != nil && field.V.Reference != nil {
+		for _, obj := range field.V.Reference.Elements() {
+			ref, ok := core.GetDict(obj)
+			if !ok {
+				continue
+			}
+			if method, _ := core.GetNameVal(ref.Get("TransformMethod")); method == "FieldMDP" {
+				if locks := parse(ref.Get("TransformParams")); locks != nil {
+					return locks
+				}
+			}
+		}
+	}
+	if field.Lock != nil {
+		return parse(field.Lock)
+	}
+
+	return nil
+}
+
+// checkModifications analyzes the objects added or changed after the
+// revision ending at the specified offset and checks whether the changes
+// are permitted.
+func (v *SignatureValidator) checkModifications(ctx *sigValidationContext, field *PdfFieldSignature, revisionEnd int64, report *SignatureReport) error {
+	revReader, err := NewPdfReaderLazy(bytes.NewReader(ctx.data[:revisionEnd]))
+	if err != nil {
+		return err
+	}
+	finalReader, err := NewPdfReaderLazy(bytes.NewReader(ctx.data))
+	if err != nil {
+		return err
+	}
+
+	trailer, err := finalReader.GetTrailer()
+	if err != nil {
+		return err
+	}
+	revTrailer, err := revReader.GetTrailer()
+	if err != nil {
+		return err
+	}
+	refNum := func(obj core.PdfObject) int64 {
+		if ref, ok := obj.(*core.PdfObjectReference); ok {
+			return ref.ObjectNumber
+		}
+		if ind, ok := obj.(*core.PdfIndirectObject); ok {
+			return ind.ObjectNumber
+		}
+		return -1
+	}
+	catalogNum := refNum(trailer.Get("Root"))
+	infoNum := refNum(trailer.Get("Info"))
+	revCatalogNum := refNum(revTrailer.Get("Root"))
+
+	locks := getFieldLocks(field)
+	for _, num := range finalReader.GetObjectNums() {
+		newObj, err := finalReader.GetIndirectObjectByNumber(num)
+		if err != nil || newObj == nil {
+			continue
+		}
+		oldNum := num
+		if int64(num) == catalogNum && revCatalogNum >= 0 {
+			// The catalog may have been written as a new object.
+			oldNum = int(revCatalogNum)
+		}
+		oldObj, err := revReader.GetIndirectObjectByNumber(oldNum)
+		if err != nil || core.IsNullObject(oldObj) {
+			oldObj = nil
+		}
+		if oldObj != nil && !isObjectChanged(oldObj, newObj) {
+			continue
+		}
+
+		mod := &SignatureModification{
+			ObjectNumber: int64(num),
+			Added:        oldObj == nil,
+		}
+		switch int64(num) {
+		case catalogNum:
+			mod.Kind = classifyCatalogChange(oldObj, newObj)
+		case infoNum:
+			mod.Kind = ModificationStructure
+		default:
+			mod.Kind = ctx.classifyObjectChange(int64(num), oldObj, newObj)
+		}
+		mod.FieldName = ctx.fieldNames[int64(num)]
+		mod.Permitted = isModificationPermitted(mod.Kind, ctx.docMDPPermission)
+		if mod.Permitted && mod.Kind == ModificationForm && mod.FieldName != "" && locks.isLocked(mod.FieldName) {
+			mod.Permitted = false
+		}
+
+		report.Modifications = append(report.Modifications, mod)
+		if !mod.Permitted {
+			report.ModificationsPermitted = false
+		}
+	}
+
+	return nil
+}
+
+// isModificationPermitted returns true if the specified kind of changes is
+// permitted by the DocMDP access permission (0 if the document is not
+// certified). Changes other than filling in forms, signing and adding
+// annotations are never permitted.
+func isModificationPermitted(kind ModificationKind, p int) bool {
+	switch kind {
+	case ModificationStructure, ModificationDSS:
+		return true
+	case ModificationSignature, ModificationForm:
+		return p == 0 || p >= 2
+	case ModificationAnnotation:
+		return p == 0 || p == 3
+	}
+	return false
+}
+
+// isObjectChanged returns true if the specified objects differ semantically.
+func isObjectChanged(oldObj, newObj core.PdfObject) bool {
+	return !core.Equal(core.TraceToDirectObject(oldObj), core.TraceToDirectObject(newObj))
+}
+
+// classifyCatalogChange returns the kind of the changes made to the catalog.
+// Only the AcroForm, DSS, Extensions and Version entries are expected to
+// change.
+func classifyCatalogChange(oldObj, newObj core.PdfObject) ModificationKind {
+	oldDict, ok1 := core.GetDict(oldObj)
+	newDict, ok2 := core.GetDict(newObj)
+	if !ok1 || !ok2 {
+		return ModificationOther
+	}
+
+	kind := ModificationStructure
+	for _, key := range changedDictKeys(oldDict, newDict) {
+		switch key {
+		case "AcroForm":
+			kind = ModificationForm
+		case "DSS", "Extensions", "Version":
+		default:
+			return ModificationOther
+		}
+	}
+	return kind
+}
+
+// classifyObjectChange returns the kind of the change of the specified object.
+func (ctx *sigValidationContext) classifyObjectChange(num int64, oldObj, newObj core.PdfObject) ModificationKind {
+	if stream, ok := newObj.(*core.PdfObjectStream); ok {
+		if name, _ := core.GetNameVal(stream.Get("Type")); name == "XRef" || name == "ObjStm" {
+			return ModificationStructure
+		}
+		if oldObj == nil {
+			// New streams (e.g. appearance streams or DSS data) are only
+			// relevant through the objects which reference them.
+			return ModificationStructure
+		}
+		return ModificationOther
+	}
+
+	dict, ok := core.GetDict(newObj)
+	if !ok {
+		if oldObj == nil {
+			return ModificationStructure
+		}
+		return ModificationOther
+	}
+
+	typeName, _ := core.GetNameVal(dict.Get("Type"))
+	subtype, _ := core.GetNameVal(dict.Get("Subtype"))
+	_, isField := ctx.fieldNames[num]
+	switch {
+	case typeName == "DocTimeStamp":
+		return ModificationDSS
+	case typeName == "Sig":
+		return ModificationSignature
+	case isField || dict.Get("FT") != nil || subtype == "Widget":
+		return ModificationForm
+	case typeName == "Annot" || (subtype != "" && dict.Get("Rect") != nil):
+		return ModificationAnnotation
+	case dict.Get("Fields") != nil:
+		return ModificationForm
+	case dict.Get("VRI") != nil || dict.Get("Certs") != nil || dict.Get("OCSPs") != nil || dict.Get("CRLs") != nil:
+		return ModificationDSS
+	}
+
+	if _, isPage := ctx.pageNums[num]; isPage && oldObj != nil {
+		return classifyPageChange(oldObj, newObj)
+	}
+	if oldObj == nil {
+		return ModificationStructure
+	}
+	return ModificationOther
+}
+
+// classifyPageChange returns the kind of the changes made to a page. Only
+// the annotations are expected to change. Inheritable attributes copied from
+// the parent page tree nodes are not considered as changes.
+func classifyPageChange(oldObj, newObj core.PdfObject) ModificationKind {
+	oldDict, ok1 := core.GetDict(oldObj)
+	newDict, ok2 := core.GetDict(newObj)
+	if !ok1 || !ok2 {
+		return ModificationOther
+	}
+
+	kind := ModificationStructure
+	for _, key := range changedDictKeys(oldDict, newDict) {
+		switch key {
+		case "Annots":
+			kind = classifyAnnotsChange(oldDict.Get("Annots"), newDict.Get("Annots"))
+		case "Resources", "MediaBox", "CropBox", "Rotate":
+			inherited := getInheritedPageAttribute(oldDict, key)
+			if inherited == nil || !core.Equal(inherited, core.TraceToDirectObject(newDict.Get(key))) {
+				return ModificationOther
+			}
+		default:
+			return ModificationOther
+		}
+		if kind == ModificationOther {
+			return kind
+		}
+	}
+	return kind
+}
+
+// classifyAnnotsChange returns the kind of the changes made to a page
+// annotation array. Removing annotations or adding annotations other than
+// widgets is considered as an annotation change.
+func classifyAnnotsChange(oldObj, newObj core.PdfObject) ModificationKind {
+	oldArr, _ := core.GetArray(oldObj)
+	newArr, ok := core.GetArray(newObj)
+	if !ok {
+		return ModificationAnnotation
+	}
+
+	existing := map[string]struct{}{}
+	if oldArr != nil {
+		for _, o := range oldArr.Elements() {
+			existing[o.WriteString()] = struct{}{}
+		}
+	}
+
+	kind := ModificationForm
+	for _, o := range newArr.Elements() {
+		if _, ok := existing[o.WriteString()]; ok {
+			delete(existing, o.WriteString())
+			continue
+		}
+		dict, ok := core.GetDict(o)
+		if !ok {
+			continue
+		}
+		if subtype, _ := core.GetNameVal(dict.Get("Subtype")); subtype != "Widget" {
+			kind = ModificationAnnotation
+		}
+	}
+	if len(existing) > 0 {
+		kind = ModificationAnnotation
+	}
+	return kind
+}
+
+// getInheritedPageAttribute returns the value of the specified inheritable
+// attribute of the page dictionary, looking up the parent page tree nodes.
+func getInheritedPageAttribute(dict *core.PdfObjectDictionary, key core.PdfObjectName) core.PdfObject {
+	for depth := 0; dict != nil && depth < 32; depth++ {
+		if obj := dict.Get(key); obj != nil {
+			return core.TraceToDirectObject(obj)
+		}
+		dict, _ = core.GetDict(dict.Get("Parent"))
+	}
+	return nil
+}
+
+// changedDictKeys returns the keys whose values differ between the
+// specified dictionaries.
+func changedDictKeys(oldDict, newDict *core.PdfObjectDictionary) []core.PdfObjectName {
+	var keys []core.PdfObjectName
+	for _, key := range newDict.Keys() {
+		oldVal := oldDict.Get(key)
+		if oldVal == nil || !core.Equal(oldVal, newDict.Get(key)) {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range oldDict.Keys() {
+		if newDict.Get(key) == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,425 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/unidoc/pkcs7"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+	"github.com/unidoc/unipdf/v3/model/sighandler"
+	"github.com/unidoc/unipdf/v3/model/sigutil"
+)
+
+// signTestDocument applies a signature on a new revision of the specified
+// document. The prepare function, if specified, is invoked on the
+// initialized signature.
+func signTestDocument(t *testing.T, data []byte, handler model.SignatureHandler, prepare func(sig *model.PdfSignature)) []byte {
+	reader, err := model.NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	appender, err := model.NewPdfAppender(reader)
+	require.NoError(t, err)
+
+	signature := model.NewPdfSignature(handler)
+	signature.SetName("Test Validator")
+	signature.SetDate(time.Now(), "")
+	require.NoError(t, signature.Initialize())
+	if prepare != nil {
+		prepare(signature)
+	}
+
+	numFields := 0
+	if reader.AcroForm != nil {
+		numFields = len(reader.AcroForm.AllFields())
+	}
+	sigField := model.NewPdfFieldSignature(signature)
+	sigField.T = core.MakeString(fmt.Sprintf("Signature%d", numFields+1))
+	sigField.Rect = core.MakeArray(
+		core.MakeInteger(0),
+		core.MakeInteger(0),
+		core.MakeInteger(0),
+		core.MakeInteger(0),
+	)
+	require.NoError(t, appender.Sign(1, sigField))
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, appender.Write(buf))
+	return buf.Bytes()
+}
+
+func TestSignatureValidator(t *testing.T) {
+	server, cert, privateKey := newTestLTVServer(t)
+	defer server.Close()
+	tsaServer := newTestTimestampServer(t, nil)
+	defer tsaServer.Close()
+
+	root, err := sigutil.NewCertClient().GetIssuer(cert)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	original, err := ioutil.ReadFile(testPdfFile1)
+	require.NoError(t, err)
+
+	validate := func(data []byte, trusted bool) []*model.SignatureReport {
+		reader, err := model.NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+
+		handler, _ := sighandler.NewAdobePKCS7Detached(nil, nil)
+		handler2, _ := sighandler.NewDocTimeStamp("", 0)
+		validator := model.NewSignatureValidator(handler, handler2)
+		validator.CheckRevocation = true
+		if trusted {
+			validator.TrustedRoots = roots
+		} else {
+			validator.TrustedRoots = x509.NewCertPool()
+		}
+
+		reports, err := validator.Validate(reader)
+		require.NoError(t, err)
+		for _, report := range reports {
+			t.Logf("%s", report)
+		}
+		return reports
+	}
+
+	handler, err := sighandler.NewAdobePKCS7Detached(privateKey, cert)
+	require.NoError(t, err)
+	signed := signTestDocument(t, original, handler, nil)
+
+	t.Run("valid", func(t *testing.T) {
+		reports := validate(signed, true)
+		require.Len(t, reports, 1)
+		report := reports[0]
+		require.Equal(t, "Signature1", report.FieldName)
+		require.True(t, report.ByteRangeValid)
+		require.True(t, report.CoversWholeDocument)
+		require.True(t, report.DigestValid)
+		require.True(t, report.IsTrusted)
+		require.Len(t, report.Chain, 2)
+		require.Equal(t, model.RevocationUnknown, report.Revocation)
+		require.True(t, report.ModificationsPermitted)
+		require.Empty(t, report.Modifications)
+		require.True(t, report.IsValid(true))
+
+		// Untrusted root.
+		reports = validate(signed, false)
+		require.False(t, reports[0].IsTrusted)
+		require.True(t, reports[0].IsValid(false))
+		require.False(t, reports[0].IsValid(true))
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := bytes.Replace(signed, []byte("(Hello World)"), []byte("(Hellx World)"), 1)
+		require.NotEqual(t, signed, tampered)
+
+		reports := validate(tampered, true)
+		require.Len(t, reports, 1)
+		require.True(t, reports[0].ByteRangeValid)
+		require.False(t, reports[0].DigestValid)
+		require.False(t, reports[0].IsValid(false))
+	})
+
+	t.Run("timestamped", func(t *testing.T) {
+		handler, err := sighandler.NewAdobePKCS7DetachedWithOpts(privateKey, cert,
+			&sighandler.AdobePKCS7DetachedOpts{TimestampServerURL: tsaServer.URL})
+		require.NoError(t, err)
+		data := signTestDocument(t, original, handler, nil)
+
+		// Add a document timestamp.
+		tsHandler, err := sighandler.NewDocTimeStamp(tsaServer.URL, crypto.SHA256)
+		require.NoError(t, err)
+		data = signTestDocument(t, data, tsHandler, nil)
+
+		reports := validate(data, true)
+		require.Len(t, reports, 2)
+
+		report := reports[0]
+		require.True(t, report.DigestValid)
+		require.False(t, report.CoversWholeDocument)
+		require.False(t, report.Result.GeneralizedTime.IsZero())
+		require.Equal(t, report.Result.GeneralizedTime, report.ValidationTime)
+		require.True(t, report.ModificationsPermitted)
+		require.True(t, report.IsValid(true))
+
+		report = reports[1]
+		require.True(t, report.DigestValid)
+		require.True(t, report.CoversWholeDocument)
+		require.False(t, report.Result.GeneralizedTime.IsZero())
+	})
+
+	t.Run("revocation", func(t *testing.T) {
+		reader, err := model.NewPdfReader(bytes.NewReader(signed))
+		require.NoError(t, err)
+		appender, err := model.NewPdfAppender(reader)
+		require.NoError(t, err)
+		ltv, err := model.NewLTV(appender)
+		require.NoError(t, err)
+		require.NoError(t, ltv.EnableAll(nil))
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, appender.Write(buf))
+
+		reports := validate(buf.Bytes(), true)
+		require.Len(t, reports, 1)
+		require.Equal(t, model.RevocationGood, reports[0].Revocation)
+		require.False(t, reports[0].CoversWholeDocument)
+		require.True(t, reports[0].ModificationsPermitted)
+		require.True(t, reports[0].IsValid(true))
+	})
+
+	t.Run("revocation info archival", func(t *testing.T) {
+		data, err := sigutil.NewRevocationClient().GetRevocationData(context.Background(),
+			[]*x509.Certificate{cert, root})
+		require.NoError(t, err)
+		require.Len(t, data.OCSPs, 1)
+
+		handler, err := sighandler.NewAdobePKCS7DetachedWithOpts(privateKey, cert,
+			&sighandler.AdobePKCS7DetachedOpts{RevocationData: data})
+		require.NoError(t, err)
+		reports := validate(signTestDocument(t, original, handler, nil), true)
+		require.Len(t, reports, 1)
+		require.True(t, reports[0].IsValid(true))
+
+		p7, err := pkcs7.Parse(reports[0].Signature.Contents.Bytes())
+		require.NoError(t, err)
+		var archival asn1.RawValue
+		require.NoError(t, p7.UnmarshalSignedAttribute(sigutil.OIDRevocationInfoArchival, &archival))
+		expected, err := data.RevocationInfoArchival()
+		require.NoError(t, err)
+		require.Equal(t, expected, archival.FullBytes)
+	})
+
+	t.Run("modified content", func(t *testing.T) {
+		reader, err := model.NewPdfReader(bytes.NewReader(signed))
+		require.NoError(t, err)
+		appender, err := model.NewPdfAppender(reader)
+		require.NoError(t, err)
+
+		page := reader.PageList[0]
+		err = page.SetContentStreams([]string{"BT /F1 18 Tf 0 0 Td (Changed) Tj ET"}, core.NewRawEncoder())
+		require.NoError(t, err)
+		appender.UpdatePage(page)
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, appender.Write(buf))
+
+		reports := validate(buf.Bytes(), true)
+		require.Len(t, reports, 1)
+		require.True(t, reports[0].DigestValid)
+		require.False(t, reports[0].ModificationsPermitted)
+		require.False(t, reports[0].IsValid(true))
+
+		var hasOther bool
+		for _, mod := range reports[0].Modifications {
+			if mod.Kind == model.ModificationOther && !mod.Permitted {
+				hasOther = true
+			}
+		}
+		require.True(t, hasOther)
+	})
+
+	t.Run("docmdp", func(t *testing.T) {
+		// Certification signature which does not permit any changes.
+		data := signTestDocument(t, original, handler, func(sig *model.PdfSignature) {
+			params := core.MakeDict()
+			params.Set("Type", core.MakeName("TransformParams"))
+			params.Set("P", core.MakeInteger(1))
+			params.Set("V", core.MakeName("1.2"))
+
+			ref := core.MakeDict()
+			ref.Set("Type", core.MakeName("SigRef"))
+			ref.Set("TransformMethod", core.MakeName("DocMDP"))
+			ref.Set("TransformParams", params)
+			sig.Reference = core.MakeArray(ref)
+		})
+		data = signTestDocument(t, data, handler, nil)
+
+		reports := validate(data, true)
+		require.Len(t, reports, 2)
+		require.Equal(t, 1, reports[0].DocMDPPermission)
+		require.True(t, reports[0].DigestValid)
+		require.False(t, reports[0].ModificationsPermitted)
+		require.True(t, reports[1].ModificationsPermitted)
+	})
+}
+
+// TestSignatureCertification checks the validation of the changes made after
+// certifying a document and locking form fields.
+func TestSignatureCertification(t *testing.T) {
+	server, cert, privateKey := newTestLTVServer(t)
+	defer server.Close()
+
+	original, err := ioutil.ReadFile(testPdfAcroFormFile1)
+	require.NoError(t, err)
+	handler, err := sighandler.NewAdobePKCS7Detached(privateKey, cert)
+	require.NoError(t, err)
+
+	certify := func(p int, lock model.FieldMDPAction, fields ...string) []byte {
+		reader, err := model.NewPdfReader(bytes.NewReader(original))
+		require.NoError(t, err)
+		appender, err := model.NewPdfAppender(reader)
+		require.NoError(t, err)
+
+		signature := model.NewPdfSignature(handler)
+		signature.SetName("Test Certification")
+		signature.SetDate(time.Now(), "")
+		require.NoError(t, signature.Initialize())
+		require.NoError(t, signature.SetCertification(p))
+
+		sigField := model.NewPdfFieldSignature(signature)
+		sigField.T = core.MakeString("Certification")
+		sigField.Rect = core.MakeArray(core.MakeInteger(0), core.MakeInteger(0), core.MakeInteger(0), core.MakeInteger(0))
+		if lock != "" {
+			require.NoError(t, sigField.SetLock(lock, fields...))
+		}
+		require.NoError(t, appender.Sign(1, sigField))
+
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, appender.Write(buf))
+		return buf.Bytes()
+	}
+
+	fill := func(data []byte, name, value string) []byte {
+		reader, err := model.NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		appender, err := model.NewPdfAppender(reader)
+		require.NoError(t, err)
+
+		var filled bool
+		for _, field := range reader.AcroForm.AllFields() {
+			if textField, ok := field.GetContext().(*model.PdfFieldText); ok && field.PartialName() == name {
+				textField.V = core.MakeString(value)
+				filled = true
+			}
+		}
+		require.True(t, filled)
+		appender.ReplaceAcroForm(reader.AcroForm)
+
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, appender.Write(buf))
+		return buf.Bytes()
+	}
+
+	validate := func(data []byte) *model.SignatureReport {
+		reader, err := model.NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		handler, _ := sighandler.NewAdobePKCS7Detached(nil, nil)
+		reports, err := model.NewSignatureValidator(handler).Validate(reader)
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		t.Logf("%s", reports[0])
+		require.Equal(t, "Certification", reports[0].FieldName)
+		require.True(t, reports[0].DigestValid)
+		return reports[0]
+	}
+
+	certified := certify(model.DocMDPFillForms, model.FieldMDPInclude, "Family Name Text Box")
+
+	// The certification signature is referenced by the permissions of the
+	// document.
+	reader, err := model.NewPdfReader(bytes.NewReader(certified))
+	require.NoError(t, err)
+	trailer, err := reader.GetTrailer()
+	require.NoError(t, err)
+	catalog, ok := core.GetDict(trailer.Get("Root"))
+	require.True(t, ok)
+	perms, ok := core.GetDict(catalog.Get("Perms"))
+	require.True(t, ok)
+	docMDP, ok := core.GetDict(perms.Get("DocMDP"))
+	require.True(t, ok)
+	require.Equal(t, "Test Certification", docMDP.Get("Name").(*core.PdfObjectString).Decoded())
+
+	report := validate(certified)
+	require.Equal(t, model.DocMDPFillForms, report.DocMDPPermission)
+	require.True(t, report.CoversWholeDocument)
+	require.True(t, report.IsValid(false))
+
+	t.Run("permitted form fill", func(t *testing.T) {
+		report := validate(fill(certified, "Given Name Text Box", "John"))
+		require.False(t, report.CoversWholeDocument)
+		require.True(t, report.ModificationsPermitted)
+		require.True(t, report.IsValid(false))
+
+		var hasForm bool
+		for _, mod := range report.Modifications {
+			if mod.Kind == model.ModificationForm && mod.FieldName == "Given Name Text Box" {
+				hasForm = true
+				require.True(t, mod.Permitted)
+			}
+		}
+		require.True(t, hasForm)
+	})
+
+	t.Run("locked field", func(t *testing.T) {
+		report := validate(fill(certified, "Family Name Text Box", "Doe"))
+		require.False(t, report.ModificationsPermitted)
+		require.False(t, report.IsValid(false))
+
+		var locked bool
+		for _, mod := range report.Modifications {
+			if mod.FieldName == "Family Name Text Box" && !mod.Permitted {
+				locked = true
+			}
+		}
+		require.True(t, locked)
+	})
+
+	t.Run("page content change", func(t *testing.T) {
+		reader, err := model.NewPdfReader(bytes.NewReader(certified))
+		require.NoError(t, err)
+		appender, err := model.NewPdfAppender(reader)
+		require.NoError(t, err)
+		page := reader.PageList[0]
+		err = page.SetContentStreams([]string{"BT /F1 18 Tf 0 0 Td (Changed) Tj ET"}, core.NewRawEncoder())
+		require.NoError(t, err)
+		appender.UpdatePage(page)
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, appender.Write(buf))
+
+		report := validate(buf.Bytes())
+		require.False(t, report.ModificationsPermitted)
+
+		var hasOther bool
+		for _, mod := range report.Modifications {
+			if mod.Kind == model.ModificationOther && !mod.Permitted {
+				hasOther = true
+			}
+		}
+		require.True(t, hasOther)
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		report := validate(fill(certify(model.DocMDPNoChanges, ""), "Given Name Text Box", "John"))
+		require.Equal(t, model.DocMDPNoChanges, report.DocMDPPermission)
+		require.False(t, report.ModificationsPermitted)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		signature := model.NewPdfSignature(handler)
+		require.Error(t, signature.SetCertification(0))
+		require.Error(t, model.NewPdfFieldSignature(signature).SetLock(model.FieldMDPInclude))
+		require.Error(t, model.NewPdfFieldSignature(signature).SetLock("None"))
+
+		// Certification signatures must be the first signatures.
+		reader, err := model.NewPdfReader(bytes.NewReader(certified))
+		require.NoError(t, err)
+		appender, err := model.NewPdfAppender(reader)
+		require.NoError(t, err)
+		require.NoError(t, signature.Initialize())
+		require.NoError(t, signature.SetCertification(model.DocMDPAnnotate))
+		require.Error(t, appender.Sign(1, model.NewPdfFieldSignature(signature)))
+	})
+}
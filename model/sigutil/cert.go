@@ -0,0 +1,116 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package sigutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// CertClient represents a X.509 certificate client. Its primary purpose
+// is to download certificates, such as the issuers of the certificates
+// which make up a signature certificate chain.
+type CertClient struct {
+	// HTTPClient is the HTTP client used for downloading the certificates.
+	// If not set, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// NewCertClient returns a new certificate client.
+func NewCertClient() *CertClient {
+	return &CertClient{
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Get retrieves the certificate at the specified URL. The certificate can be
+// either DER or PEM encoded.
+func (c *CertClient) Get(url string) (*x509.Certificate, error) {
+	data, err := httpGet(context.Background(), c.HTTPClient, url)
+	if err != nil {
+		return nil, err
+	}
+
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+
+	return x509.ParseCertificate(data)
+}
+
+// GetIssuer retrieves the issuer of the specified certificate, using the
+// issuing certificate URLs of its authority information access extension.
+func (c *CertClient) GetIssuer(cert *x509.Certificate) (*x509.Certificate, error) {
+	if cert == nil {
+		return nil, errors.New("certificate must not be nil")
+	}
+
+	var lastErr error
+	for _, url := range cert.IssuingCertificateURL {
+		issuer, err := c.Get(url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if err := cert.CheckSignatureFrom(issuer); err != nil {
+			lastErr = err
+			continue
+		}
+		return issuer, nil
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+
+	return nil, errors.New("certificate does not specify issuer URLs")
+}
+
+// IsCA returns true if the specified certificate is a certificate authority.
+func (c *CertClient) IsCA(cert *x509.Certificate) bool {
+	return cert.IsCA && bytes.Equal(cert.RawIssuer, cert.RawSubject)
+}
+
+// httpGet retrieves the data at the specified URL using the provided
+// HTTP client, or http.DefaultClient if the client is nil.
+func httpGet(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return httpDo(ctx, client, req)
+}
+
+// httpDo sends the specified request using the provided HTTP client, or
+// http.DefaultClient if the client is nil, and returns the response body.
+// An error is returned if the response status code is not 200 (OK).
+func httpDo(ctx context.Context, client *http.Client, req *http.Request) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http status code not ok (got %d)", resp.StatusCode)
+	}
+
+	return data, nil
+}
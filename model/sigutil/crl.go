@@ -0,0 +1,59 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package sigutil
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// CRLClient represents a CRL (Certificate Revocation List) client.
+// It is used to request revocation data from CRL servers.
+type CRLClient struct {
+	// HTTPClient is the HTTP client used for downloading the CRLs.
+	// If not set, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// NewCRLClient returns a new CRL client.
+func NewCRLClient() *CRLClient {
+	return &CRLClient{
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// MakeRequest downloads the CRL from the specified server URL and returns
+// its DER encoded form. If serverURL is empty, the first CRL distribution
+// point of the certificate is used.
+func (c *CRLClient) MakeRequest(serverURL string, cert *x509.Certificate) ([]byte, error) {
+	return c.MakeRequestContext(context.Background(), serverURL, cert)
+}
+
+// MakeRequestContext is like MakeRequest, with the request being sent using
+// the specified context.
+func (c *CRLClient) MakeRequestContext(ctx context.Context, serverURL string, cert *x509.Certificate) ([]byte, error) {
+	if serverURL == "" {
+		if cert == nil || len(cert.CRLDistributionPoints) == 0 {
+			return nil, errors.New("certificate does not specify any CRL servers")
+		}
+		serverURL = cert.CRLDistributionPoints[0]
+	}
+
+	data, err := httpGet(ctx, c.HTTPClient, serverURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure the response is a valid CRL.
+	if _, err := x509.ParseDERCRL(data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
@@ -0,0 +1,10 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package sigutil implements utilities used by the digital signature handlers,
+// such as clients for communicating with the timestamp authority servers and
+// for retrieving the revocation data (OCSP responses and CRLs) of
+// certificate chains.
+package sigutil
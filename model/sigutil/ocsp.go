@@ -0,0 +1,186 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package sigutil
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// OCSPClient represents an OCSP (Online Certificate Status Protocol) client.
+// It is used to request revocation data from OCSP servers.
+type OCSPClient struct {
+	// HTTPClient is the HTTP client used for sending the OCSP requests.
+	// If not set, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// Hash is the hash function used for identifying the certificates in
+	// the OCSP requests. Defaults to crypto.SHA1.
+	Hash crypto.Hash
+}
+
+// NewOCSPClient returns a new OCSP client.
+func NewOCSPClient() *OCSPClient {
+	return &OCSPClient{
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		Hash:       crypto.SHA1,
+	}
+}
+
+// MakeRequest sends an OCSP request for the specified certificate to the
+// specified server URL and returns the parsed response, along with its DER
+// encoded form. If serverURL is empty, the first OCSP server of the
+// certificate is used. The response signature is checked against the issuer
+// and the certificate identifier of the response must match the certificate.
+func (c *OCSPClient) MakeRequest(serverURL string, cert, issuer *x509.Certificate) (*ocsp.Response, []byte, error) {
+	return c.MakeRequestContext(context.Background(), serverURL, cert, issuer)
+}
+
+// MakeRequestContext is like MakeRequest, with the request being sent using
+// the specified context.
+func (c *OCSPClient) MakeRequestContext(ctx context.Context, serverURL string, cert, issuer *x509.Certificate) (*ocsp.Response, []byte, error) {
+	if cert == nil || issuer == nil {
+		return nil, nil, errors.New("certificate and issuer must not be nil")
+	}
+	if serverURL == "" {
+		if len(cert.OCSPServer) == 0 {
+			return nil, nil, errors.New("certificate does not specify any OCSP servers")
+		}
+		serverURL = cert.OCSPServer[0]
+	}
+
+	hash := c.Hash
+	if hash == 0 {
+		hash = crypto.SHA1
+	}
+
+	ocspReq, err := ocsp.CreateRequest(cert, issuer, &ocsp.RequestOptions{Hash: hash})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, serverURL, bytes.NewReader(ocspReq))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+
+	data, err := httpDo(ctx, c.HTTPClient, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ocspResp, err := ocsp.ParseResponseForCert(data, cert, issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkOCSPResponse(data, ocspResp, cert, issuer); err != nil {
+		return nil, nil, err
+	}
+
+	return ocspResp, data, nil
+}
+
+// ASN.1 structures of the OCSP responses (RFC 6960, section 4.2.1), used for
+// checking the certificate identifiers of the responses.
+type ocspCertID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+type ocspSingleResponse struct {
+	CertID ocspCertID
+}
+
+type ocspResponseData struct {
+	Version     int `asn1:"optional,default:0,explicit,tag:0"`
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []ocspSingleResponse
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData ocspResponseData
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+// checkOCSPResponse checks that the parsed OCSP response contains a response
+// whose certificate identifier matches the certificate and its issuer, and
+// that the response is signed either by the issuer or by a responder
+// certificate authorized for signing OCSP responses.
+// The response signature is checked by ocsp.ParseResponseForCert, which
+// only matches the certificate identifiers by serial number.
+func checkOCSPResponse(data []byte, resp *ocsp.Response, cert, issuer *x509.Certificate) error {
+	if resp.Certificate != nil && !bytes.Equal(resp.Certificate.Raw, issuer.Raw) {
+		var authorized bool
+		for _, usage := range resp.Certificate.ExtKeyUsage {
+			if usage == x509.ExtKeyUsageOCSPSigning {
+				authorized = true
+				break
+			}
+		}
+		if !authorized {
+			return errors.New("OCSP responder certificate not authorized for signing OCSP responses")
+		}
+	}
+
+	var outer ocspResponse
+	if _, err := asn1.Unmarshal(data, &outer); err != nil {
+		return err
+	}
+	var basic ocspBasicResponse
+	if _, err := asn1.Unmarshal(outer.Response.Response, &basic); err != nil {
+		return err
+	}
+
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return err
+	}
+	if !resp.IssuerHash.Available() {
+		return fmt.Errorf("unavailable OCSP hash algorithm: %v", resp.IssuerHash)
+	}
+	h := resp.IssuerHash.New()
+	h.Write(issuer.RawSubject)
+	nameHash := h.Sum(nil)
+	h.Reset()
+	h.Write(spki.PublicKey.RightAlign())
+	keyHash := h.Sum(nil)
+
+	for _, single := range basic.TBSResponseData.Responses {
+		id := single.CertID
+		if id.SerialNumber != nil && id.SerialNumber.Cmp(cert.SerialNumber) == 0 &&
+			bytes.Equal(id.NameHash, nameHash) && bytes.Equal(id.IssuerKeyHash, keyHash) {
+			return nil
+		}
+	}
+	return errors.New("OCSP response certificate ID does not match the certificate")
+}
@@ -0,0 +1,242 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package sigutil
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/unidoc/unipdf/v3/common"
+)
+
+// ErrCertificateRevoked is returned when the revocation data of a
+// certificate indicates that it has been revoked.
+var ErrCertificateRevoked = errors.New("certificate revoked")
+
+// OIDRevocationInfoArchival is the object identifier of the Adobe revocation
+// information signed attribute (adbe-revocationInfoArchival), which embeds
+// revocation data in CMS signatures.
+var OIDRevocationInfoArchival = asn1.ObjectIdentifier{1, 2, 840, 113583, 1, 1, 8}
+
+// RevocationData contains the DER encoded revocation data of a certificate
+// chain. It can be embedded either in the document security store (see
+// model.DSS.AddOCSPs and model.DSS.AddCRLs) or in CMS signatures, as an
+// adbe-revocationInfoArchival signed attribute (see RevocationInfoArchival).
+type RevocationData struct {
+	OCSPs [][]byte
+	CRLs  [][]byte
+}
+
+// revocationInfoArchival represents the adbe-revocationInfoArchival
+// attribute value.
+type revocationInfoArchival struct {
+	CRLs  []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+	OCSPs []asn1.RawValue `asn1:"explicit,tag:1,optional"`
+}
+
+// RevocationInfoArchival returns the DER encoded value of the
+// adbe-revocationInfoArchival signed attribute (OIDRevocationInfoArchival)
+// containing the revocation data.
+func (d *RevocationData) RevocationInfoArchival() ([]byte, error) {
+	var archival revocationInfoArchival
+	for _, data := range d.CRLs {
+		archival.CRLs = append(archival.CRLs, asn1.RawValue{FullBytes: data})
+	}
+	for _, data := range d.OCSPs {
+		archival.OCSPs = append(archival.OCSPs, asn1.RawValue{FullBytes: data})
+	}
+	return asn1.Marshal(archival)
+}
+
+// RevocationClient retrieves the revocation data of certificate chains,
+// using the OCSP servers specified by the authority information access
+// extensions of the certificates and, as a fallback, their CRL distribution
+// points. The revocation data is cached, so that it is retrieved only once
+// when signing several documents. The client is safe for concurrent use.
+type RevocationClient struct {
+	// OCSPClient is used for retrieving the OCSP responses. If nil, no OCSP
+	// requests are made.
+	OCSPClient *OCSPClient
+
+	// CRLClient is used for retrieving the CRLs. If nil, no CRLs are
+	// retrieved.
+	CRLClient *CRLClient
+
+	mu    sync.Mutex
+	ocsps map[string]*ocspCacheEntry
+	crls  map[string]*crlCacheEntry
+}
+
+// ocspCacheEntry is an OCSP response cached by issuer and serial number.
+type ocspCacheEntry struct {
+	resp *ocsp.Response
+	data []byte
+}
+
+// crlCacheEntry is a CRL cached by distribution point URL.
+type crlCacheEntry struct {
+	crl  *pkix.CertificateList
+	data []byte
+}
+
+// NewRevocationClient returns a new revocation client, which uses the
+// default OCSP and CRL clients.
+func NewRevocationClient() *RevocationClient {
+	return &RevocationClient{
+		OCSPClient: NewOCSPClient(),
+		CRLClient:  NewCRLClient(),
+	}
+}
+
+// GetRevocationData returns the revocation data of the specified certificate
+// chain, starting with the signing certificate, each certificate followed by
+// its issuer. The revocation data of the root certificate is not retrieved.
+// For each certificate, the OCSP servers are tried first, then the CRL
+// distribution points. An error is returned if the revocation data of a
+// certificate cannot be retrieved or if a certificate is revoked, in which
+// case the error wraps ErrCertificateRevoked.
+func (c *RevocationClient) GetRevocationData(ctx context.Context, chain []*x509.Certificate) (*RevocationData, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("empty certificate chain")
+	}
+
+	data := &RevocationData{}
+	for i := 0; i+1 < len(chain); i++ {
+		cert, issuer := chain[i], chain[i+1]
+		if cert == nil || issuer == nil {
+			return nil, errors.New("certificate chain must not contain nil certificates")
+		}
+		if err := cert.CheckSignatureFrom(issuer); err != nil {
+			return nil, fmt.Errorf("certificate %q not issued by %q: %w",
+				cert.Subject.CommonName, issuer.Subject.CommonName, err)
+		}
+
+		ocspData, crlData, err := c.getCertRevocationData(ctx, cert, issuer)
+		if err != nil {
+			return nil, fmt.Errorf("certificate %q: %w", cert.Subject.CommonName, err)
+		}
+		if ocspData != nil {
+			data.OCSPs = append(data.OCSPs, ocspData)
+		}
+		if crlData != nil {
+			data.CRLs = append(data.CRLs, crlData)
+		}
+	}
+
+	return data, nil
+}
+
+// getCertRevocationData returns either the DER encoded OCSP response or the
+// CRL of the specified certificate.
+func (c *RevocationClient) getCertRevocationData(ctx context.Context, cert, issuer *x509.Certificate) ([]byte, []byte, error) {
+	var lastErr error
+	if c.OCSPClient != nil {
+		for _, url := range cert.OCSPServer {
+			resp, data, err := c.getOCSPResponse(ctx, url, cert, issuer)
+			if err != nil {
+				common.Log.Debug("WARN: could not retrieve OCSP response from %s: %v", url, err)
+				lastErr = err
+				continue
+			}
+			if resp.Status == ocsp.Revoked {
+				return nil, nil, fmt.Errorf("%w (OCSP) at %s", ErrCertificateRevoked, resp.RevokedAt)
+			}
+			if resp.Status == ocsp.Good {
+				return data, nil, nil
+			}
+			lastErr = errors.New("unknown OCSP certificate status")
+		}
+	}
+
+	if c.CRLClient != nil {
+		for _, url := range cert.CRLDistributionPoints {
+			crl, data, err := c.getCRL(ctx, url, issuer)
+			if err != nil {
+				common.Log.Debug("WARN: could not retrieve CRL from %s: %v", url, err)
+				lastErr = err
+				continue
+			}
+			for _, revoked := range crl.TBSCertList.RevokedCertificates {
+				if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+					return nil, nil, fmt.Errorf("%w (CRL) at %s", ErrCertificateRevoked, revoked.RevocationTime)
+				}
+			}
+			return nil, data, nil
+		}
+	}
+
+	if lastErr != nil {
+		return nil, nil, lastErr
+	}
+	return nil, nil, errors.New("no revocation data sources available")
+}
+
+// getOCSPResponse returns the OCSP response of the specified certificate,
+// cached by issuer and serial number until its next update time.
+func (c *RevocationClient) getOCSPResponse(ctx context.Context, url string, cert, issuer *x509.Certificate) (*ocsp.Response, []byte, error) {
+	key := string(issuer.RawSubject) + "/" + string(issuer.RawSubjectPublicKeyInfo) + "/" + cert.SerialNumber.String()
+
+	c.mu.Lock()
+	entry, ok := c.ocsps[key]
+	c.mu.Unlock()
+	if ok && (entry.resp.NextUpdate.IsZero() || time.Now().Before(entry.resp.NextUpdate)) {
+		return entry.resp, entry.data, nil
+	}
+
+	resp, data, err := c.OCSPClient.MakeRequestContext(ctx, url, cert, issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	if c.ocsps == nil {
+		c.ocsps = map[string]*ocspCacheEntry{}
+	}
+	c.ocsps[key] = &ocspCacheEntry{resp: resp, data: data}
+	c.mu.Unlock()
+	return resp, data, nil
+}
+
+// getCRL returns the CRL at the specified URL, which must be signed by the
+// issuer, cached by URL until its next update time.
+func (c *RevocationClient) getCRL(ctx context.Context, url string, issuer *x509.Certificate) (*pkix.CertificateList, []byte, error) {
+	c.mu.Lock()
+	entry, ok := c.crls[url]
+	c.mu.Unlock()
+	if ok && !entry.crl.HasExpired(time.Now()) {
+		if err := issuer.CheckCRLSignature(entry.crl); err == nil {
+			return entry.crl, entry.data, nil
+		}
+	}
+
+	data, err := c.CRLClient.MakeRequestContext(ctx, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	crl, err := x509.ParseDERCRL(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := issuer.CheckCRLSignature(crl); err != nil {
+		return nil, nil, fmt.Errorf("invalid CRL signature: %w", err)
+	}
+
+	c.mu.Lock()
+	if c.crls == nil {
+		c.crls = map[string]*crlCacheEntry{}
+	}
+	c.crls[url] = &crlCacheEntry{crl: crl, data: data}
+	c.mu.Unlock()
+	return crl, data, nil
+}
@@ -0,0 +1,269 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package sigutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+)
+
+// testCA is a certificate authority with OCSP and CRL responders.
+type testCA struct {
+	t      *testing.T
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	server *httptest.Server
+
+	mu       sync.Mutex
+	revoked  map[string]bool // Revoked serial numbers.
+	requests map[string]int  // Number of requests by path.
+
+	// ocspIssuer, if set, is the issuer the OCSP responses are made for,
+	// instead of the CA certificate.
+	ocspIssuer *x509.Certificate
+}
+
+// newTestCert returns a certificate signed by `parent` (self-signed if nil).
+func newTestCert(t *testing.T, template *x509.Certificate, parent *x509.Certificate,
+	parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+// newTestCA returns a certificate authority whose responders are served at
+// /ocsp and /crl. The /unavailable path always fails.
+func newTestCA(t *testing.T) *testCA {
+	ca := &testCA{t: t, revoked: map[string]bool{}, requests: map[string]int{}}
+	ca.cert, ca.key = newTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}, nil, nil)
+	ca.server = httptest.NewServer(http.HandlerFunc(ca.serveHTTP))
+	return ca
+}
+
+func (ca *testCA) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	ca.mu.Lock()
+	ca.requests[r.URL.Path]++
+	ca.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/ocsp":
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(ca.t, err)
+		req, err := ocsp.ParseRequest(body)
+		require.NoError(ca.t, err)
+
+		template := ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}
+		ca.mu.Lock()
+		if ca.revoked[req.SerialNumber.String()] {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = time.Now().Add(-time.Minute)
+		}
+		issuer := ca.cert
+		if ca.ocspIssuer != nil {
+			issuer = ca.ocspIssuer
+		}
+		ca.mu.Unlock()
+
+		resp, err := ocsp.CreateResponse(issuer, ca.cert, template, ca.key)
+		require.NoError(ca.t, err)
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(resp)
+	case "/crl":
+		var revoked []pkix.RevokedCertificate
+		ca.mu.Lock()
+		for serial := range ca.revoked {
+			n, _ := new(big.Int).SetString(serial, 10)
+			revoked = append(revoked, pkix.RevokedCertificate{
+				SerialNumber:   n,
+				RevocationTime: time.Now().Add(-time.Minute),
+			})
+		}
+		ca.mu.Unlock()
+		crl, err := ca.cert.CreateCRL(rand.Reader, ca.key, revoked, time.Now(), time.Now().Add(time.Hour))
+		require.NoError(ca.t, err)
+		w.Write(crl)
+	default:
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}
+}
+
+// issue returns a certificate issued by the CA, with the specified OCSP
+// and CRL paths of the CA server.
+func (ca *testCA) issue(serial int64, ocspPath, crlPath string) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "Signer"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if ocspPath != "" {
+		template.OCSPServer = []string{ca.server.URL + ocspPath}
+	}
+	if crlPath != "" {
+		template.CRLDistributionPoints = []string{ca.server.URL + crlPath}
+	}
+	cert, _ := newTestCert(ca.t, template, ca.cert, ca.key)
+	return cert
+}
+
+// numRequests returns the number of requests made to the specified path.
+func (ca *testCA) numRequests(path string) int {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	return ca.requests[path]
+}
+
+func TestRevocationClient(t *testing.T) {
+	ca := newTestCA(t)
+	defer ca.server.Close()
+	ctx := context.Background()
+
+	t.Run("good", func(t *testing.T) {
+		client := NewRevocationClient()
+		cert := ca.issue(100, "/ocsp", "/crl")
+		data, err := client.GetRevocationData(ctx, []*x509.Certificate{cert, ca.cert})
+		require.NoError(t, err)
+		require.Len(t, data.OCSPs, 1)
+		require.Empty(t, data.CRLs)
+
+		resp, err := ocsp.ParseResponseForCert(data.OCSPs[0], cert, ca.cert)
+		require.NoError(t, err)
+		require.Equal(t, ocsp.Good, resp.Status)
+
+		// The cached response is used for the following requests.
+		numRequests := ca.numRequests("/ocsp")
+		cached, err := client.GetRevocationData(ctx, []*x509.Certificate{cert, ca.cert})
+		require.NoError(t, err)
+		require.Equal(t, data, cached)
+		require.Equal(t, numRequests, ca.numRequests("/ocsp"))
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		cert := ca.issue(101, "/ocsp", "/crl")
+		ca.mu.Lock()
+		ca.revoked["101"] = true
+		ca.mu.Unlock()
+
+		_, err := NewRevocationClient().GetRevocationData(ctx, []*x509.Certificate{cert, ca.cert})
+		require.Error(t, err)
+		require.True(t, errors.Is(err, ErrCertificateRevoked), "%v", err)
+
+		// Revoked according to the CRL.
+		cert = ca.issue(101, "/unavailable", "/crl")
+		_, err = NewRevocationClient().GetRevocationData(ctx, []*x509.Certificate{cert, ca.cert})
+		require.True(t, errors.Is(err, ErrCertificateRevoked), "%v", err)
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		// The CRL is used if the OCSP responder is unavailable.
+		client := NewRevocationClient()
+		cert := ca.issue(102, "/unavailable", "/crl")
+		data, err := client.GetRevocationData(ctx, []*x509.Certificate{cert, ca.cert})
+		require.NoError(t, err)
+		require.Empty(t, data.OCSPs)
+		require.Len(t, data.CRLs, 1)
+
+		// The CRL is cached by URL for the certificates of the same issuer.
+		numRequests := ca.numRequests("/crl")
+		cert = ca.issue(103, "/unavailable", "/crl")
+		_, err = client.GetRevocationData(ctx, []*x509.Certificate{cert, ca.cert})
+		require.NoError(t, err)
+		require.Equal(t, numRequests, ca.numRequests("/crl"))
+
+		cert = ca.issue(104, "/unavailable", "/unavailable")
+		_, err = client.GetRevocationData(ctx, []*x509.Certificate{cert, ca.cert})
+		require.Error(t, err)
+		require.False(t, errors.Is(err, ErrCertificateRevoked))
+
+		cert = ca.issue(105, "", "")
+		_, err = client.GetRevocationData(ctx, []*x509.Certificate{cert, ca.cert})
+		require.Error(t, err)
+
+		// Canceled requests.
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		cert = ca.issue(106, "/ocsp", "")
+		_, err = client.GetRevocationData(canceled, []*x509.Certificate{cert, ca.cert})
+		require.True(t, errors.Is(err, context.Canceled), "%v", err)
+	})
+
+	t.Run("mismatched certificate ID", func(t *testing.T) {
+		// The responses are signed by the CA but made for another issuer.
+		other, _ := newTestCert(t, &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      pkix.Name{CommonName: "Other CA"},
+		}, nil, nil)
+		ca.mu.Lock()
+		ca.ocspIssuer = other
+		ca.mu.Unlock()
+		defer func() {
+			ca.mu.Lock()
+			ca.ocspIssuer = nil
+			ca.mu.Unlock()
+		}()
+
+		cert := ca.issue(107, "/ocsp", "")
+		_, _, err := NewOCSPClient().MakeRequestContext(ctx, "", cert, ca.cert)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "certificate ID")
+	})
+}
+
+func TestRevocationInfoArchival(t *testing.T) {
+	data := &RevocationData{
+		OCSPs: [][]byte{{0x04, 0x01, 0x01}},
+		CRLs:  [][]byte{{0x04, 0x01, 0x02}, {0x04, 0x01, 0x03}},
+	}
+	der, err := data.RevocationInfoArchival()
+	require.NoError(t, err)
+
+	var archival struct {
+		CRLs  []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+		OCSPs []asn1.RawValue `asn1:"explicit,tag:1,optional"`
+	}
+	rest, err := asn1.Unmarshal(der, &archival)
+	require.NoError(t, err)
+	require.Empty(t, rest)
+	require.Len(t, archival.CRLs, 2)
+	require.Equal(t, []byte{0x04, 0x01, 0x03}, archival.CRLs[1].FullBytes)
+	require.Len(t, archival.OCSPs, 1)
+	require.Equal(t, []byte{0x04, 0x01, 0x01}, archival.OCSPs[0].FullBytes)
+}
@@ -0,0 +1,174 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package sigutil
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/unidoc/timestamp"
+
+	"github.com/unidoc/unipdf/v3/common"
+)
+
+// TimestampClient represents a RFC 3161 timestamp authority (TSA) client.
+// It is used for requesting timestamp tokens from TSA servers.
+type TimestampClient struct {
+	// HTTPClient is the HTTP client used for sending the timestamp requests.
+	// If not set, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// Username and Password are used for HTTP basic authentication with the
+	// timestamp server. The credentials are sent only if Username is set.
+	Username string
+	Password string
+
+	// Header contains additional HTTP headers which are sent along with the
+	// timestamp requests (e.g. an Authorization header for token based
+	// authentication).
+	Header http.Header
+}
+
+// NewTimestampClient returns a new timestamp client.
+func NewTimestampClient() *TimestampClient {
+	return &TimestampClient{
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewTimestampRequest returns a timestamp request for the data read from body.
+// The data is hashed using the specified hash algorithm. The request contains
+// a random nonce which is checked against the nonce of the timestamp response.
+func NewTimestampRequest(body io.Reader, hashAlgorithm crypto.Hash) (*timestamp.Request, error) {
+	if !hashAlgorithm.Available() {
+		return nil, fmt.Errorf("unavailable timestamp hash algorithm: %v", hashAlgorithm)
+	}
+
+	h := hashAlgorithm.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return nil, err
+	}
+
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+
+	return &timestamp.Request{
+		HashAlgorithm: hashAlgorithm,
+		HashedMessage: h.Sum(nil),
+		Certificates:  true,
+		Nonce:         nonce,
+	}, nil
+}
+
+// GetEncodedToken sends the timestamp request to the specified timestamp
+// server and returns the DER encoded timestamp token of the response.
+// The response is checked to match the message imprint and the nonce of the
+// request.
+func (c *TimestampClient) GetEncodedToken(serverURL string, req *timestamp.Request) ([]byte, error) {
+	if serverURL == "" {
+		return nil, errors.New("timestamp server URL not specified")
+	}
+	if req == nil {
+		return nil, errors.New("timestamp request must not be nil")
+	}
+
+	data, err := req.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequest("POST", serverURL, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.Header {
+		for _, value := range values {
+			httpReq.Header.Add(key, value)
+		}
+	}
+	httpReq.Header.Set("Content-Type", "application/timestamp-query")
+	if c.Username != "" {
+		httpReq.SetBasicAuth(c.Username, c.Password)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http status code not ok (got %d)", resp.StatusCode)
+	}
+
+	return parseTimestampResponse(body, req)
+}
+
+// parseTimestampResponse parses the specified DER encoded timestamp response
+// and returns the timestamp token it contains, after checking that it
+// corresponds to the specified request.
+func parseTimestampResponse(data []byte, req *timestamp.Request) ([]byte, error) {
+	var resp struct {
+		Status struct {
+			Status       int
+			StatusString asn1.RawValue  `asn1:"optional"`
+			FailInfo     asn1.BitString `asn1:"optional"`
+		}
+		TimeStampToken asn1.RawValue `asn1:"optional"`
+	}
+	if _, err := asn1.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+
+	// Only the granted (0) and granted with modifications (1) statuses
+	// contain a timestamp token.
+	if status := resp.Status.Status; status != timestamp.Granted && status != timestamp.GrantedWithMods {
+		return nil, fmt.Errorf("timestamp request rejected (status %d)", status)
+	}
+	if len(resp.TimeStampToken.FullBytes) == 0 {
+		return nil, errors.New("timestamp response does not contain a token")
+	}
+	token := resp.TimeStampToken.FullBytes
+
+	ts, err := timestamp.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if ts.HashAlgorithm != req.HashAlgorithm {
+		return nil, errors.New("timestamp response hash algorithm mismatch")
+	}
+	if !bytes.Equal(ts.HashedMessage, req.HashedMessage) {
+		return nil, errors.New("timestamp response message imprint mismatch")
+	}
+	if req.Nonce != nil && (ts.Nonce == nil || ts.Nonce.Cmp(req.Nonce) != 0) {
+		return nil, errors.New("timestamp response nonce mismatch")
+	}
+	if resp.Status.Status == timestamp.GrantedWithMods {
+		common.Log.Debug("Timestamp request granted with modifications")
+	}
+
+	return token, nil
+}
@@ -0,0 +1,257 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// OutlineSplitOptions contains options for splitting a document by its
+// outline.
+type OutlineSplitOptions struct {
+	// Depth is the depth of the outline items the document is split by.
+	// Top-level items have a depth of 1, which is the default.
+	Depth int
+
+	// KeepOutline specifies whether the outline items are retained in the
+	// output documents, along with their descendants, rebased to the pages
+	// of the documents.
+	KeepOutline bool
+}
+
+// OutlineSplitPart represents a document produced by splitting a document
+// by its outline.
+type OutlineSplitPart struct {
+	// Title is the title of the first outline item of the part.
+	Title string
+
+	// Items contains the outline items of the part. Items pointing to the
+	// same page share the same part.
+	Items []*OutlineItem
+
+	// StartPage and EndPage are the page numbers (1-based, inclusive) of the
+	// pages of the part in the source document.
+	StartPage int
+	EndPage   int
+
+	// Writer contains the pages of the part, ready to be written.
+	Writer *PdfWriter
+}
+
+// outlineSplitItem represents an outline item and its destination page index.
+type outlineSplitItem struct {
+	item      *OutlineItem
+	pageIndex int
+}
+
+// SplitByOutline splits the document in multiple documents, one for each
+// outline item at the depth specified in the options (top-level items by
+// default). Pass nil for the opts parameter in order to use the default
+// options.
+//
+// Each part contains the pages from the destination page of its item up to
+// the page preceding the destination page of the next item, in page order.
+// As a result, the parts are returned in page order, regardless of the order
+// of the items in the outline. Items pointing to the same page share a part,
+// titled after the first of them. Items without a destination in the
+// document are ignored and the pages preceding the first destination are not
+// included in any part.
+//
+// The pages are added to the writers of the parts along with their
+// resources and annotations. Link annotations pointing to pages outside of a
+// part are removed. Named destinations are carried over to the part
+// containing their target page.
+//
+// NOTE: The pages of the reader are modified when added to the writers of
+// the parts. The reader should not be used to generate other documents
+// afterwards.
+func (r *PdfReader) SplitByOutline(opts *OutlineSplitOptions) ([]*OutlineSplitPart, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &OutlineSplitOptions{}
+	}
+	depth := opts.Depth
+	if depth <= 0 {
+		depth = 1
+	}
+
+	outline, err := r.GetOutlines()
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect outline items at the specified depth.
+	var splitItems []outlineSplitItem
+	var collect func(items []*OutlineItem, level int)
+	collect = func(items []*OutlineItem, level int) {
+		for _, item := range items {
+			if level < depth {
+				collect(item.Entries, level+1)
+				continue
+			}
+			if item.Dest.PageObj == nil {
+				common.Log.Debug("WARN: skipping outline item without destination: %s", item.Title)
+				continue
+			}
+			_, pageNum, err := r.PageFromIndirectObject(item.Dest.PageObj)
+			if err != nil {
+				common.Log.Debug("WARN: skipping outline item %s: %v", item.Title, err)
+				continue
+			}
+			splitItems = append(splitItems, outlineSplitItem{item: item, pageIndex: pageNum - 1})
+		}
+	}
+	collect(outline.Entries, 1)
+	if len(splitItems) == 0 {
+		return nil, errors.New("no outline items with destinations at the specified depth")
+	}
+
+	sort.SliceStable(splitItems, func(i, j int) bool {
+		return splitItems[i].pageIndex < splitItems[j].pageIndex
+	})
+
+	// Group items pointing to the same page and compute the page ranges.
+	var parts []*OutlineSplitPart
+	for _, splitItem := range splitItems {
+		if len(parts) > 0 && parts[len(parts)-1].StartPage == splitItem.pageIndex+1 {
+			last := parts[len(parts)-1]
+			last.Items = append(last.Items, splitItem.item)
+			continue
+		}
+		if len(parts) > 0 {
+			parts[len(parts)-1].EndPage = splitItem.pageIndex
+		}
+		parts = append(parts, &OutlineSplitPart{
+			Title:     splitItem.item.Title,
+			Items:     []*OutlineItem{splitItem.item},
+			StartPage: splitItem.pageIndex + 1,
+		})
+	}
+	parts[len(parts)-1].EndPage = len(r.PageList)
+
+	namedDests := r.getNamedDestinationList()
+	for _, part := range parts {
+		if err := r.writeOutlineSplitPart(part, namedDests, opts.KeepOutline); err != nil {
+			return nil, err
+		}
+	}
+	return parts, nil
+}
+
+// writeOutlineSplitPart creates the writer of the specified part.
+func (r *PdfReader) writeOutlineSplitPart(part *OutlineSplitPart, namedDests []nameTreeEntry, keepOutline bool) error {
+	start, end := part.StartPage-1, part.EndPage-1
+	inPart := func(dest *PdfDestination) bool {
+		if dest.Page != nil {
+			_, pageNum, err := r.PageFromIndirectObject(dest.Page)
+			return err == nil && pageNum-1 >= start && pageNum-1 <= end
+		}
+		return int(dest.PageIndex) >= start && int(dest.PageIndex) <= end
+	}
+
+	w := NewPdfWriter()
+	for _, page := range r.PageList[start : end+1] {
+		annotations, err := page.GetAnnotations()
+		if err != nil {
+			return err
+		}
+
+		// Remove links pointing to pages outside of the part.
+		var kept []*PdfAnnotation
+		for _, annot := range annotations {
+			if link, ok := annot.GetContext().(*PdfAnnotationLink); ok {
+				dest, err := link.GetDestination()
+				if err != nil {
+					common.Log.Debug("WARN: could not resolve link destination: %v", err)
+				}
+				if dest != nil && !inPart(dest) {
+					continue
+				}
+			}
+			kept = append(kept, annot)
+		}
+		if len(kept) != len(annotations) {
+			page.SetAnnotations(kept)
+		}
+
+		if err := w.AddPage(page); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range namedDests {
+		dest, err := r.resolveExplicitDestination(entry.value)
+		if err != nil {
+			common.Log.Debug("WARN: skipping named destination %s: %v", entry.key, err)
+			continue
+		}
+		if !inPart(dest) {
+			continue
+		}
+		dest.PageIndex -= int64(start)
+		if err := w.AddNamedDestination(entry.key, dest); err != nil {
+			return err
+		}
+	}
+
+	if keepOutline {
+		outline := NewOutline()
+		for _, item := range part.Items {
+			outline.Add(rebaseOutlineItem(item, start, end, 0))
+		}
+		w.AddOutlineTree(outline.ToOutlineTree())
+	}
+
+	part.Writer = &w
+	return nil
+}
+
+// getNamedDestinationList returns the named destinations of the document,
+// from the Dests name tree and the Dests dictionary of the catalog (PDF 1.1).
+func (r *PdfReader) getNamedDestinationList() []nameTreeEntry {
+	var entries []nameTreeEntry
+	if names, ok := core.GetDict(r.catalog.Get("Names")); ok {
+		entries = append(entries, getNameTreeEntries(names.Get("Dests"))...)
+	}
+	if dests, ok := core.GetDict(r.catalog.Get("Dests")); ok {
+		for _, key := range dests.Keys() {
+			entries = append(entries, nameTreeEntry{key: string(key), value: dests.Get(key)})
+		}
+	}
+	return entries
+}
+
+// rebaseOutlineItem returns a copy of the specified outline item and its
+// descendants, with the destination pages from `start` to `end` (zero based,
+// inclusive) moved to the page index `offset` of the output document.
+// Destinations outside of the page range are removed.
+func rebaseOutlineItem(item *OutlineItem, start, end, offset int) *OutlineItem {
+	rebased := *item
+	rebased.Entries = nil
+	if rebased.Dest.Page >= int64(start) && rebased.Dest.Page <= int64(end) && rebased.Dest.PageObj != nil {
+		rebased.Dest.Page += int64(offset - start)
+	} else {
+		rebased.Dest = OutlineDest{}
+	}
+
+	// GoTo actions are resolved to the destination of the item.
+	if actionDict, ok := core.GetDict(rebased.Action); ok {
+		if s, _ := core.GetNameVal(actionDict.Get("S")); s == "GoTo" {
+			rebased.Action = nil
+		}
+	}
+
+	for _, entry := range item.Entries {
+		rebased.Add(rebaseOutlineItem(entry, start, end, offset))
+	}
+	return &rebased
+}
@@ -0,0 +1,149 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// writeSplitFixture returns a reader for a document containing a cover page
+// followed by the pages of three customers. The outline items of the
+// customers are not in page order and two of them point to the same page.
+func writeSplitFixture(t *testing.T) *PdfReader {
+	w := NewPdfWriter()
+	var pages []*PdfPage
+	for i := 0; i < 7; i++ {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 100, Ury: 100}
+		pages = append(pages, page)
+	}
+
+	// Links from the pages of customer A to pages inside and outside of the
+	// part of customer A.
+	for _, target := range []int{2, 4} {
+		link := NewPdfAnnotationLink()
+		link.Rect = core.MakeArrayFromFloats([]float64{0, 0, 10, 10})
+		link.Dest = NewPdfDestinationFit(pages[target].GetPageAsIndirectObject()).ToPdfObject()
+		pages[1].AddAnnotation(link.PdfAnnotation)
+	}
+	for _, page := range pages {
+		require.NoError(t, w.AddPage(page))
+	}
+	require.NoError(t, w.AddNamedDestination("b-summary", NewPdfDestinationFit(pages[4].GetPageAsIndirectObject())))
+
+	outline := NewOutline()
+	customerA := NewOutlineItem("Customer A", NewOutlineDest(1, 0, 100))
+	customerA.Add(NewOutlineItem("A details", NewOutlineDest(2, 0, 100)))
+	customerA.Add(NewOutlineItem("B summary", NewOutlineDest(4, 0, 100)))
+	outline.Add(customerA)
+	outline.Add(NewOutlineItem("Customer C", NewOutlineDest(5, 0, 100)))
+	customerB := NewOutlineItem("Customer B", NewOutlineDest(3, 0, 100))
+	customerB.Add(NewOutlineItem("B summary", NewOutlineDest(4, 0, 100)))
+	outline.Add(customerB)
+	outline.Add(NewOutlineItem("Customer C (continued)", NewOutlineDest(5, 0, 50)))
+	w.AddOutlineTree(outline.ToOutlineTree())
+
+	reader := writeAndRead(t, &w)
+	return reader
+}
+
+// readSplitPart writes the specified part and returns a reader for it.
+func readSplitPart(t *testing.T, part *OutlineSplitPart) *PdfReader {
+	reader := writeAndRead(t, part.Writer)
+	return reader
+}
+
+func TestSplitByOutline(t *testing.T) {
+	reader := writeSplitFixture(t)
+	parts, err := reader.SplitByOutline(&OutlineSplitOptions{KeepOutline: true})
+	require.NoError(t, err)
+	require.Len(t, parts, 3)
+
+	type expectedPart struct {
+		title      string
+		start, end int
+		outline    []string
+	}
+	expected := []expectedPart{
+		{"Customer A", 2, 3, []string{"Customer A", " A details", " B summary"}},
+		{"Customer B", 4, 5, []string{"Customer B", " B summary"}},
+		{"Customer C", 6, 7, []string{"Customer C", "Customer C (continued)"}},
+	}
+	var partReaders []*PdfReader
+	for i, part := range parts {
+		require.Equal(t, expected[i].title, part.Title)
+		require.Equal(t, expected[i].start, part.StartPage)
+		require.Equal(t, expected[i].end, part.EndPage)
+
+		partReader := readSplitPart(t, part)
+		numPages, err := partReader.GetNumPages()
+		require.NoError(t, err)
+		require.Equal(t, expected[i].end-expected[i].start+1, numPages)
+
+		outline, err := partReader.GetOutlines()
+		require.NoError(t, err)
+		require.Equal(t, expected[i].outline, outlineTitles(outline.Entries, 0))
+		partReaders = append(partReaders, partReader)
+	}
+
+	// Outline destinations are rebased, destinations outside of the part
+	// are removed.
+	outline, err := partReaders[0].GetOutlines()
+	require.NoError(t, err)
+	require.EqualValues(t, 0, outline.Entries[0].Dest.Page)
+	require.EqualValues(t, 1, outline.Entries[0].Entries[0].Dest.Page)
+	require.Nil(t, outline.Entries[0].Entries[1].Dest.PageObj)
+	outline, err = partReaders[1].GetOutlines()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, outline.Entries[0].Entries[0].Dest.Page)
+
+	// Links pointing outside of the part are removed.
+	page, err := partReaders[0].GetPage(1)
+	require.NoError(t, err)
+	annots, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 1)
+	dest, err := annots[0].GetContext().(*PdfAnnotationLink).GetDestination()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, dest.PageIndex)
+
+	// Named destinations are carried over to the part of their page.
+	dest, err = partReaders[0].GetNamedDestination("b-summary")
+	require.NoError(t, err)
+	require.Nil(t, dest)
+	dest, err = partReaders[1].GetNamedDestination("b-summary")
+	require.NoError(t, err)
+	require.NotNil(t, dest)
+	require.EqualValues(t, 1, dest.PageIndex)
+}
+
+func TestSplitByOutlineDepth(t *testing.T) {
+	reader := writeSplitFixture(t)
+	parts, err := reader.SplitByOutline(&OutlineSplitOptions{Depth: 2})
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	require.Equal(t, "A details", parts[0].Title)
+	require.Equal(t, 3, parts[0].StartPage)
+	require.Equal(t, 4, parts[0].EndPage)
+	require.Equal(t, "B summary", parts[1].Title)
+	require.Len(t, parts[1].Items, 2)
+	require.Equal(t, 5, parts[1].StartPage)
+	require.Equal(t, 7, parts[1].EndPage)
+
+	partReader := readSplitPart(t, parts[1])
+	numPages, err := partReader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 3, numPages)
+	_, err = partReader.GetOutlines()
+	require.Error(t, err)
+
+	_, err = reader.SplitByOutline(&OutlineSplitOptions{Depth: 3})
+	require.Error(t, err)
+}
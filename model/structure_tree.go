@@ -0,0 +1,353 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"errors"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// PdfStructTreeRoot represents the root of the structure tree of a tagged
+// document, describing the logical structure of its contents.
+// See section 14.7 "Logical Structure" (p. 578 PDF32000_2008).
+type PdfStructTreeRoot struct {
+	Kids []*PdfStructElem
+}
+
+// PdfStructElem represents a structure element, such as a heading, a
+// paragraph or a table.
+type PdfStructElem struct {
+	// Type is the structure type of the element (e.g. "H1", "P" or "Table").
+	Type       string
+	Title      string
+	Lang       string
+	Alt        string
+	ActualText string
+	Kids       []*PdfStructKid
+}
+
+// PdfStructKid represents a kid of a structure element: either a structure
+// element (Elem is set) or a marked-content sequence of a page, identified by
+// its marked-content identifier (MCID).
+type PdfStructKid struct {
+	Elem *PdfStructElem
+	Page *core.PdfIndirectObject
+	MCID int
+}
+
+// NewPdfStructTreeRoot returns a new empty structure tree root.
+func NewPdfStructTreeRoot() *PdfStructTreeRoot {
+	return &PdfStructTreeRoot{}
+}
+
+// NewPdfStructElem returns a new structure element of the specified type.
+func NewPdfStructElem(structType string) *PdfStructElem {
+	return &PdfStructElem{Type: structType}
+}
+
+// AddElem appends `elem` to the kids of the structure element.
+func (e *PdfStructElem) AddElem(elem *PdfStructElem) {
+	e.Kids = append(e.Kids, &PdfStructKid{Elem: elem})
+}
+
+// AddMarkedContent appends the marked-content sequence with the specified
+// MCID of `page` to the kids of the structure element.
+func (e *PdfStructElem) AddMarkedContent(page *core.PdfIndirectObject, mcid int) {
+	e.Kids = append(e.Kids, &PdfStructKid{Page: page, MCID: mcid})
+}
+
+// IsEmpty returns true if the structure element does not contain any
+// marked-content sequence, directly or through its descendants.
+func (e *PdfStructElem) IsEmpty() bool {
+	for _, kid := range e.Kids {
+		if kid.Elem == nil || !kid.Elem.IsEmpty() {
+			return false
+		}
+	}
+	return true
+}
+
+// structTreeWriter builds the objects of a structure tree.
+type structTreeWriter struct {
+	// Structure elements of the marked-content sequences of the pages, by
+	// page and MCID.
+	pageElems map[*core.PdfIndirectObject][]core.PdfObject
+	pages     []*core.PdfIndirectObject
+}
+
+// ToPdfObject returns the StructTreeRoot dictionary representing the
+// structure tree, including its ParentTree. Empty structure elements are
+// omitted. The StructParents entries of the pages containing marked content
+// are set.
+func (root *PdfStructTreeRoot) ToPdfObject() core.PdfObject {
+	container := core.MakeIndirectObject(core.MakeDict())
+	dict := container.PdfObject.(*core.PdfObjectDictionary)
+	dict.Set("Type", core.MakeName("StructTreeRoot"))
+
+	sw := &structTreeWriter{pageElems: map[*core.PdfIndirectObject][]core.PdfObject{}}
+	kids := core.MakeArray()
+	for _, elem := range root.Kids {
+		if !elem.IsEmpty() {
+			kids.Append(sw.writeElem(elem, container))
+		}
+	}
+	dict.Set("K", kids)
+
+	// The ParentTree maps the StructParents keys of the pages to the arrays
+	// of the structure elements of their marked-content sequences.
+	nums := core.MakeArray()
+	for i, page := range sw.pages {
+		if pageDict, ok := core.GetDict(page); ok {
+			pageDict.Set("StructParents", core.MakeInteger(int64(i)))
+		}
+		elems := core.MakeArray()
+		for _, elem := range sw.pageElems[page] {
+			if elem == nil {
+				elem = core.MakeNull()
+			}
+			elems.Append(elem)
+		}
+		nums.Append(core.MakeInteger(int64(i)), core.MakeIndirectObject(elems))
+	}
+	parentTree := core.MakeDict()
+	parentTree.Set("Nums", nums)
+	dict.Set("ParentTree", core.MakeIndirectObject(parentTree))
+	dict.Set("ParentTreeNextKey", core.MakeInteger(int64(len(sw.pages))))
+	return container
+}
+
+// writeElem returns the indirect object of the StructElem dictionary
+// representing `elem`, with the parent `parent`.
+func (sw *structTreeWriter) writeElem(elem *PdfStructElem, parent core.PdfObject) *core.PdfIndirectObject {
+	container := core.MakeIndirectObject(core.MakeDict())
+	dict := container.PdfObject.(*core.PdfObjectDictionary)
+	dict.Set("Type", core.MakeName("StructElem"))
+	dict.Set("S", core.MakeName(elem.Type))
+	dict.Set("P", parent)
+	setString := func(key core.PdfObjectName, val string) {
+		if val != "" {
+			dict.Set(key, core.MakeEncodedString(val, true))
+		}
+	}
+	setString("T", elem.Title)
+	setString("Lang", elem.Lang)
+	setString("Alt", elem.Alt)
+	setString("ActualText", elem.ActualText)
+
+	// The page of the marked content kids is set on the element if they all
+	// belong to the same page.
+	var page *core.PdfIndirectObject
+	for _, kid := range elem.Kids {
+		if kid.Elem != nil {
+			continue
+		}
+		if page == nil {
+			page = kid.Page
+		} else if page != kid.Page {
+			page = nil
+			break
+		}
+	}
+	if page != nil {
+		dict.Set("Pg", page)
+	}
+
+	kids := core.MakeArray()
+	for _, kid := range elem.Kids {
+		if kid.Elem != nil {
+			if !kid.Elem.IsEmpty() {
+				kids.Append(sw.writeElem(kid.Elem, container))
+			}
+			continue
+		}
+
+		if page != nil {
+			kids.Append(core.MakeInteger(int64(kid.MCID)))
+		} else {
+			mcr := core.MakeDict()
+			mcr.Set("Type", core.MakeName("MCR"))
+			mcr.Set("Pg", kid.Page)
+			mcr.Set("MCID", core.MakeInteger(int64(kid.MCID)))
+			kids.Append(mcr)
+		}
+
+		elems, ok := sw.pageElems[kid.Page]
+		if !ok {
+			sw.pages = append(sw.pages, kid.Page)
+		}
+		for len(elems) <= kid.MCID {
+			elems = append(elems, nil)
+		}
+		elems[kid.MCID] = container
+		sw.pageElems[kid.Page] = elems
+	}
+	if kids.Len() == 1 {
+		dict.Set("K", kids.Get(0))
+	} else {
+		dict.Set("K", kids)
+	}
+	return container
+}
+
+// newPdfStructTreeRootFromDict returns the structure tree represented by the
+// StructTreeRoot dictionary `dict`.
+func newPdfStructTreeRootFromDict(dict *core.PdfObjectDictionary) (*PdfStructTreeRoot, error) {
+	root := NewPdfStructTreeRoot()
+	visited := map[core.PdfObject]struct{}{}
+	for _, obj := range structKidObjects(dict.Get("K")) {
+		elemDict, ok := core.GetDict(obj)
+		if !ok {
+			common.Log.Debug("ERROR: invalid structure tree root kid (%T)", obj)
+			continue
+		}
+		elem, err := newPdfStructElemFromDict(elemDict, visited, 0)
+		if err != nil {
+			return nil, err
+		}
+		root.Kids = append(root.Kids, elem)
+	}
+	return root, nil
+}
+
+// newPdfStructElemFromDict returns the structure element represented by the
+// StructElem dictionary `dict`. Object references (OBJR) are skipped.
+func newPdfStructElemFromDict(dict *core.PdfObjectDictionary, visited map[core.PdfObject]struct{}, depth int) (*PdfStructElem, error) {
+	if depth > 100 {
+		return nil, errors.New("structure tree too deep")
+	}
+	if _, ok := visited[dict]; ok {
+		return nil, errors.New("structure tree contains a cycle")
+	}
+	visited[dict] = struct{}{}
+
+	structType, ok := core.GetNameVal(dict.Get("S"))
+	if !ok {
+		return nil, errors.New("structure element type not specified")
+	}
+	elem := NewPdfStructElem(structType)
+	for key, field := range map[core.PdfObjectName]*string{
+		"T": &elem.Title, "Lang": &elem.Lang, "Alt": &elem.Alt, "ActualText": &elem.ActualText,
+	} {
+		if str, ok := core.GetString(dict.Get(key)); ok {
+			*field = str.Decoded()
+		}
+	}
+
+	page, _ := core.GetIndirect(dict.Get("Pg"))
+	for _, obj := range structKidObjects(dict.Get("K")) {
+		if mcid, ok := core.GetIntVal(obj); ok {
+			elem.AddMarkedContent(page, mcid)
+			continue
+		}
+		kidDict, ok := core.GetDict(obj)
+		if !ok {
+			common.Log.Debug("ERROR: invalid structure element kid (%T)", obj)
+			continue
+		}
+		switch kidType, _ := core.GetNameVal(kidDict.Get("Type")); kidType {
+		case "MCR":
+			mcid, ok := core.GetIntVal(kidDict.Get("MCID"))
+			if !ok {
+				common.Log.Debug("ERROR: marked-content reference without MCID")
+				continue
+			}
+			kidPage := page
+			if pg, ok := core.GetIndirect(kidDict.Get("Pg")); ok {
+				kidPage = pg
+			}
+			elem.AddMarkedContent(kidPage, mcid)
+		case "OBJR":
+		default:
+			kid, err := newPdfStructElemFromDict(kidDict, visited, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			elem.AddElem(kid)
+		}
+	}
+	return elem, nil
+}
+
+// structKidObjects returns the kids of the K entry `obj` of a structure tree
+// node, which is either a single kid or an array of kids.
+func structKidObjects(obj core.PdfObject) []core.PdfObject {
+	if obj == nil {
+		return nil
+	}
+	if arr, ok := core.GetArray(obj); ok {
+		return arr.Elements()
+	}
+	return []core.PdfObject{obj}
+}
+
+// GetStructTreeRoot returns the structure tree of the document, or nil if
+// the document does not have one.
+func (r *PdfReader) GetStructTreeRoot() (*PdfStructTreeRoot, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return nil, err
+	}
+	obj := r.catalog.Get("StructTreeRoot")
+	if obj == nil || core.IsNullObject(core.ResolveReference(obj)) {
+		return nil, nil
+	}
+	dict, ok := core.GetDict(obj)
+	if !ok {
+		return nil, errors.New("structure tree root not a dictionary")
+	}
+	return newPdfStructTreeRootFromDict(dict)
+}
+
+// IsTagged returns true if the document is a tagged document, as specified
+// by the Marked entry of its MarkInfo dictionary.
+func (r *PdfReader) IsTagged() bool {
+	if r.checkDecrypted() != nil {
+		return false
+	}
+	markInfo, ok := core.GetDict(r.catalog.Get("MarkInfo"))
+	if !ok {
+		return false
+	}
+	marked, _ := core.GetBoolVal(markInfo.Get("Marked"))
+	return marked
+}
+
+// GetLanguage returns the natural language of the document, specified by the
+// Lang entry of the catalog, or an empty string if not specified.
+func (r *PdfReader) GetLanguage() string {
+	if r.checkDecrypted() != nil {
+		return ""
+	}
+	lang, ok := core.GetString(r.catalog.Get("Lang"))
+	if !ok {
+		return ""
+	}
+	return lang.Decoded()
+}
+
+// SetStructTreeRoot sets the structure tree of the document and marks it as
+// a tagged document. The tree is written along with the document, and the
+// pages referred to by its marked-content kids must be added to the writer.
+func (w *PdfWriter) SetStructTreeRoot(root *PdfStructTreeRoot) error {
+	if root == nil {
+		return errors.New("structure tree root not specified")
+	}
+	w.structTreeRoot = root
+	markInfo := core.MakeDict()
+	markInfo.Set("Marked", core.MakeBool(true))
+	w.catalog.Set("MarkInfo", markInfo)
+	return nil
+}
+
+// SetLanguage sets the natural language of the document (e.g. "en-US").
+func (w *PdfWriter) SetLanguage(lang string) {
+	if lang == "" {
+		w.catalog.Remove("Lang")
+		return
+	}
+	w.catalog.Set("Lang", core.MakeString(lang))
+}
@@ -12,11 +12,11 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"regexp"
 	"strconv"
 	"time"
 
 	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/transform"
 )
 
 // PdfRectangle is a definition of a rectangle.
@@ -70,6 +70,75 @@ func (rect *PdfRectangle) Width() float64 {
 	return math.Abs(rect.Urx - rect.Llx)
 }
 
+// Normalize swaps the coordinates of `rect` if needed, so that (Llx, Lly)
+// is its lower left corner and (Urx, Ury) its upper right corner.
+func (rect *PdfRectangle) Normalize() {
+	if rect.Llx > rect.Urx {
+		rect.Llx, rect.Urx = rect.Urx, rect.Llx
+	}
+	if rect.Lly > rect.Ury {
+		rect.Lly, rect.Ury = rect.Ury, rect.Lly
+	}
+}
+
+// Normalized returns a copy of `rect` normalized (see Normalize).
+func (rect PdfRectangle) Normalized() PdfRectangle {
+	rect.Normalize()
+	return rect
+}
+
+// Union returns the smallest rectangle containing the normalized rectangles
+// `rect` and `other`.
+func (rect PdfRectangle) Union(other PdfRectangle) PdfRectangle {
+	return PdfRectangle{
+		Llx: math.Min(rect.Llx, other.Llx),
+		Lly: math.Min(rect.Lly, other.Lly),
+		Urx: math.Max(rect.Urx, other.Urx),
+		Ury: math.Max(rect.Ury, other.Ury),
+	}
+}
+
+// Transform returns the bounding box of `rect` transformed by `m`.
+func (rect PdfRectangle) Transform(m transform.Matrix) PdfRectangle {
+	bbox := PdfRectangle{Llx: math.Inf(1), Lly: math.Inf(1), Urx: math.Inf(-1), Ury: math.Inf(-1)}
+	for _, p := range [][2]float64{
+		{rect.Llx, rect.Lly}, {rect.Urx, rect.Lly}, {rect.Urx, rect.Ury}, {rect.Llx, rect.Ury},
+	} {
+		x := p[0]*m[0] + p[1]*m[3] + m[6]
+		y := p[0]*m[1] + p[1]*m[4] + m[7]
+		bbox.Llx, bbox.Lly = math.Min(bbox.Llx, x), math.Min(bbox.Lly, y)
+		bbox.Urx, bbox.Ury = math.Max(bbox.Urx, x), math.Max(bbox.Ury, y)
+	}
+	return bbox
+}
+
+// isDegenerate returns true if the normalized rectangle `rect` has no area.
+func (rect *PdfRectangle) isDegenerate() bool {
+	return rect.Width() == 0 || rect.Height() == 0
+}
+
+// contains returns true if the normalized rectangle `other` is within the
+// normalized rectangle `rect`.
+func (rect *PdfRectangle) contains(other *PdfRectangle) bool {
+	return other.Llx >= rect.Llx && other.Lly >= rect.Lly &&
+		other.Urx <= rect.Urx && other.Ury <= rect.Ury
+}
+
+// intersect returns the intersection of the normalized rectangles `rect`
+// and `other`, or nil if they do not intersect.
+func (rect *PdfRectangle) intersect(other *PdfRectangle) *PdfRectangle {
+	r := &PdfRectangle{
+		Llx: math.Max(rect.Llx, other.Llx),
+		Lly: math.Max(rect.Lly, other.Lly),
+		Urx: math.Min(rect.Urx, other.Urx),
+		Ury: math.Min(rect.Ury, other.Ury),
+	}
+	if r.Llx >= r.Urx || r.Lly >= r.Ury {
+		return nil
+	}
+	return r
+}
+
 // ToPdfObject converts rectangle to a PDF object.
 func (rect *PdfRectangle) ToPdfObject() core.PdfObject {
 	return core.MakeArray(
@@ -109,46 +178,15 @@ func (d PdfDate) ToGoTime() time.Time {
 	return time.Date(int(d.year), time.Month(d.month), int(d.day), int(d.hour), int(d.minute), int(d.second), 0, tz)
 }
 
-var reDate = regexp.MustCompile(`\s*D\s*:\s*(\d{4})(\d{2})(\d{2})(\d{2})(\d{2})(\d{2})([+-Z])?(\d{2})?'?(\d{2})?`)
-
 // NewPdfDate returns a new PdfDate object from a PDF date string (see 7.9.4 Dates).
-// format: "D: YYYYMMDDHHmmSSOHH'mm"
+// format: "D: YYYYMMDDHHmmSSOHH'mm" (see core.ParsePdfDate for the optional fields and the
+// deviations tolerated).
 func NewPdfDate(dateStr string) (PdfDate, error) {
-	d := PdfDate{}
-
-	matches := reDate.FindAllStringSubmatch(dateStr, 1)
-	if len(matches) < 1 {
-		return d, fmt.Errorf("invalid date string (%s)", dateStr)
-	}
-	if len(matches[0]) != 10 {
-		return d, errors.New("invalid regexp group match length != 10")
-	}
-
-	// No need to handle err from ParseInt, as pre-validated via regexp.
-	d.year, _ = strconv.ParseInt(matches[0][1], 10, 32)
-	d.month, _ = strconv.ParseInt(matches[0][2], 10, 32)
-	d.day, _ = strconv.ParseInt(matches[0][3], 10, 32)
-	d.hour, _ = strconv.ParseInt(matches[0][4], 10, 32)
-	d.minute, _ = strconv.ParseInt(matches[0][5], 10, 32)
-	d.second, _ = strconv.ParseInt(matches[0][6], 10, 32)
-	// Some poor implementations do not include the offset.
-	if len(matches[0][7]) > 0 {
-		d.utOffsetSign = matches[0][7][0]
-	} else {
-		d.utOffsetSign = '+'
-	}
-	if len(matches[0][8]) > 0 {
-		d.utOffsetHours, _ = strconv.ParseInt(matches[0][8], 10, 32)
-	} else {
-		d.utOffsetHours = 0
-	}
-	if len(matches[0][9]) > 0 {
-		d.utOffsetMins, _ = strconv.ParseInt(matches[0][9], 10, 32)
-	} else {
-		d.utOffsetMins = 0
+	t, err := core.ParsePdfDate(dateStr)
+	if err != nil {
+		return PdfDate{}, err
 	}
-
-	return d, nil
+	return NewPdfDateFromTime(t)
 }
 
 // NewPdfDateFromTime will create a PdfDate based on the given time
@@ -177,3 +215,24 @@ func (d *PdfDate) ToPdfObject() core.PdfObject {
 		d.utOffsetSign, d.utOffsetHours, d.utOffsetMins)
 	return core.MakeString(str)
 }
+
+// getDate returns the date of the date string `obj`, or a zero time if `obj` is nil.
+func getDate(obj core.PdfObject) (time.Time, error) {
+	obj = core.ResolveReference(obj)
+	if obj == nil || core.IsNullObject(obj) {
+		return time.Time{}, nil
+	}
+	str, ok := core.GetString(obj)
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid date type %T: %w", obj, core.ErrTypeError)
+	}
+	return core.ParsePdfDate(str.Decoded())
+}
+
+// makeDate returns the PDF date string of `t`, or nil if `t` is zero.
+func makeDate(t time.Time) core.PdfObject {
+	if t.IsZero() {
+		return nil
+	}
+	return core.MakeString(core.FormatPdfDate(t))
+}
@@ -0,0 +1,152 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"errors"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// PdfSoftMask represents a soft-mask dictionary (section 11.6.5.2 "Soft-Mask Dictionaries" p. 339).
+// The mask values are derived from the transparency group XObject G, from the alpha of the group
+// or from its luminosity.
+type PdfSoftMask struct {
+	S  core.PdfObjectName // Alpha or Luminosity.
+	G  *XObjectForm       // The transparency group XObject.
+	BC []float64          // Backdrop color of luminosity masks, in the group colorspace, or nil.
+	TR PdfFunction        // Transfer function, nil for the identity.
+
+	// Group is the transparency group attributes dictionary of G.
+	Group *PdfTransparencyGroup
+
+	container core.PdfObject
+}
+
+// NewPdfSoftMaskFromPdfObject loads the soft-mask dictionary `obj`.
+func NewPdfSoftMaskFromPdfObject(obj core.PdfObject) (*PdfSoftMask, error) {
+	dict, ok := core.GetDict(obj)
+	if !ok {
+		common.Log.Debug("ERROR: soft mask not a dictionary (%T)", obj)
+		return nil, core.ErrTypeError
+	}
+	smask := &PdfSoftMask{container: obj}
+
+	s, ok := core.GetName(dict.Get("S"))
+	if !ok {
+		common.Log.Debug("ERROR: soft mask S missing")
+		return nil, ErrRequiredAttributeMissing
+	}
+	if *s != "Alpha" && *s != "Luminosity" {
+		common.Log.Debug("ERROR: invalid soft mask subtype %s", *s)
+		return nil, errors.New("invalid soft mask subtype")
+	}
+	smask.S = *s
+
+	stream, ok := core.GetStream(dict.Get("G"))
+	if !ok {
+		common.Log.Debug("ERROR: soft mask G missing")
+		return nil, ErrRequiredAttributeMissing
+	}
+	form, err := NewXObjectFormFromStream(stream)
+	if err != nil {
+		return nil, err
+	}
+	smask.G = form
+	group, err := form.GetTransparencyGroup()
+	if err != nil {
+		return nil, err
+	}
+	smask.Group = group
+
+	if obj := dict.Get("BC"); obj != nil {
+		arr, ok := core.GetArray(obj)
+		if !ok {
+			common.Log.Debug("ERROR: soft mask BC not an array (%T)", obj)
+			return nil, core.ErrTypeError
+		}
+		bc, err := arr.ToFloat64Array()
+		if err != nil {
+			return nil, err
+		}
+		smask.BC = bc
+	}
+
+	if obj := dict.Get("TR"); obj != nil {
+		if name, ok := core.GetName(obj); !ok || *name != "Identity" {
+			fun, err := newPdfFunctionFromPdfObject(obj)
+			if err != nil {
+				return nil, err
+			}
+			smask.TR = fun
+		}
+	}
+
+	return smask, nil
+}
+
+// GetContainingPdfObject returns the soft-mask dictionary object.
+func (smask *PdfSoftMask) GetContainingPdfObject() core.PdfObject {
+	return smask.container
+}
+
+// PdfTransparencyGroup represents the group attributes dictionary of a transparency group XObject
+// (section 11.6.6 "Transparency Group XObjects" p. 342).
+type PdfTransparencyGroup struct {
+	// CS is the group colorspace, or nil if not specified.
+	CS PdfColorspace
+
+	// Isolated groups are composited on a fully transparent backdrop rather than on the group
+	// backdrop.
+	Isolated bool
+
+	// Knockout groups composite their elements with the group backdrop rather than with each
+	// other.
+	Knockout bool
+}
+
+// NewPdfTransparencyGroupFromPdfObject loads the group attributes dictionary `obj`.
+func NewPdfTransparencyGroupFromPdfObject(obj core.PdfObject) (*PdfTransparencyGroup, error) {
+	dict, ok := core.GetDict(obj)
+	if !ok {
+		common.Log.Debug("ERROR: group not a dictionary (%T)", obj)
+		return nil, core.ErrTypeError
+	}
+	if s, ok := core.GetName(dict.Get("S")); !ok || *s != "Transparency" {
+		common.Log.Debug("ERROR: not a transparency group: %v", dict.Get("S"))
+		return nil, errors.New("not a transparency group")
+	}
+
+	group := &PdfTransparencyGroup{}
+	if obj := dict.Get("CS"); obj != nil {
+		cs, err := NewPdfColorspaceFromPdfObject(obj)
+		if err != nil {
+			return nil, err
+		}
+		group.CS = cs
+	}
+	if isolated, ok := core.GetBoolVal(dict.Get("I")); ok {
+		group.Isolated = isolated
+	}
+	if knockout, ok := core.GetBoolVal(dict.Get("K")); ok {
+		group.Knockout = knockout
+	}
+	return group, nil
+}
+
+// GetTransparencyGroup returns the transparency group attributes of the form, or nil if the form
+// is not a transparency group XObject.
+func (xform *XObjectForm) GetTransparencyGroup() (*PdfTransparencyGroup, error) {
+	dict, ok := core.GetDict(xform.Group)
+	if !ok {
+		return nil, nil
+	}
+	if s, ok := core.GetName(dict.Get("S")); !ok || *s != "Transparency" {
+		return nil, nil
+	}
+	return NewPdfTransparencyGroupFromPdfObject(dict)
+}
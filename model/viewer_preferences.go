@@ -0,0 +1,436 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// PdfPageLayout represents the page layout used when a document is opened.
+// See section 7.7.2 "Document Catalog" (p. 73 PDF32000_2008).
+type PdfPageLayout string
+
+// Page layouts.
+const (
+	PageLayoutSinglePage     PdfPageLayout = "SinglePage"
+	PageLayoutOneColumn      PdfPageLayout = "OneColumn"
+	PageLayoutTwoColumnLeft  PdfPageLayout = "TwoColumnLeft"
+	PageLayoutTwoColumnRight PdfPageLayout = "TwoColumnRight"
+	PageLayoutTwoPageLeft    PdfPageLayout = "TwoPageLeft"
+	PageLayoutTwoPageRight   PdfPageLayout = "TwoPageRight"
+)
+
+// PdfPageMode represents the way a document is displayed when opened.
+// See section 7.7.2 "Document Catalog" (p. 73 PDF32000_2008).
+type PdfPageMode string
+
+// Page modes.
+const (
+	PageModeUseNone        PdfPageMode = "UseNone"
+	PageModeUseOutlines    PdfPageMode = "UseOutlines"
+	PageModeUseThumbs      PdfPageMode = "UseThumbs"
+	PageModeFullScreen     PdfPageMode = "FullScreen"
+	PageModeUseOC          PdfPageMode = "UseOC"
+	PageModeUseAttachments PdfPageMode = "UseAttachments"
+)
+
+// PdfPageBoundary represents the page boundaries used by the ViewArea,
+// ViewClip, PrintArea and PrintClip viewer preferences.
+type PdfPageBoundary string
+
+// Page boundaries.
+const (
+	PageBoundaryMediaBox PdfPageBoundary = "MediaBox"
+	PageBoundaryCropBox  PdfPageBoundary = "CropBox"
+	PageBoundaryBleedBox PdfPageBoundary = "BleedBox"
+	PageBoundaryTrimBox  PdfPageBoundary = "TrimBox"
+	PageBoundaryArtBox   PdfPageBoundary = "ArtBox"
+)
+
+// PdfReadingDirection represents the predominant reading order of the text
+// of a document.
+type PdfReadingDirection string
+
+// Reading directions.
+const (
+	ReadingDirectionL2R PdfReadingDirection = "L2R"
+	ReadingDirectionR2L PdfReadingDirection = "R2L"
+)
+
+// PdfPrintScaling represents the page scaling option of the print dialog.
+type PdfPrintScaling string
+
+// Print scaling options.
+const (
+	PrintScalingNone       PdfPrintScaling = "None"
+	PrintScalingAppDefault PdfPrintScaling = "AppDefault"
+)
+
+// PdfDuplex represents the paper handling option of the print dialog.
+type PdfDuplex string
+
+// Duplex options.
+const (
+	DuplexSimplex       PdfDuplex = "Simplex"
+	DuplexFlipShortEdge PdfDuplex = "DuplexFlipShortEdge"
+	DuplexFlipLongEdge  PdfDuplex = "DuplexFlipLongEdge"
+)
+
+// PdfViewerPreferences represents the viewer preferences of a document,
+// controlling the way the document is presented on the screen or in print.
+// Unset (nil or empty) preferences are not written, viewers using their
+// default values instead.
+// See section 12.2 "Viewer Preferences" (p. 362 PDF32000_2008).
+type PdfViewerPreferences struct {
+	HideToolbar     *bool
+	HideMenubar     *bool
+	HideWindowUI    *bool
+	FitWindow       *bool
+	CenterWindow    *bool
+	DisplayDocTitle *bool
+
+	// NonFullScreenPageMode is the page mode used when exiting full screen
+	// mode. FullScreen and UseAttachments are not allowed.
+	NonFullScreenPageMode PdfPageMode
+	Direction             PdfReadingDirection
+
+	ViewArea  PdfPageBoundary
+	ViewClip  PdfPageBoundary
+	PrintArea PdfPageBoundary
+	PrintClip PdfPageBoundary
+
+	PrintScaling      PdfPrintScaling
+	Duplex            PdfDuplex
+	PickTrayByPDFSize *bool
+	// PrintPageRange contains the page ranges initially selected in the print
+	// dialog.
+	PrintPageRange []PageRange
+	// NumCopies is the number of copies initially selected in the print
+	// dialog (1 to 5). 0 means unset.
+	NumCopies int
+	// Enforce contains the names of the preferences that shall be enforced by
+	// viewers, i.e. not overridden by user settings. Only PrintScaling may be
+	// enforced (ISO 32000-2).
+	Enforce []string
+}
+
+// NewPdfViewerPreferencesFromObject returns the viewer preferences
+// represented by the ViewerPreferences dictionary `obj`. Invalid entries
+// are ignored.
+func NewPdfViewerPreferencesFromObject(obj core.PdfObject) (*PdfViewerPreferences, error) {
+	dict, ok := core.GetDict(obj)
+	if !ok {
+		return nil, fmt.Errorf("viewer preferences not a dictionary (%T)", obj)
+	}
+
+	prefs := &PdfViewerPreferences{}
+	for key, field := range prefs.boolFields() {
+		if val, ok := core.GetBool(dict.Get(key)); ok {
+			b := bool(*val)
+			*field = &b
+		}
+	}
+	for key, field := range prefs.boundaryFields() {
+		*field = PdfPageBoundary(nameVal(dict.Get(key)))
+	}
+	prefs.NonFullScreenPageMode = PdfPageMode(nameVal(dict.Get("NonFullScreenPageMode")))
+	prefs.Direction = PdfReadingDirection(nameVal(dict.Get("Direction")))
+	prefs.PrintScaling = PdfPrintScaling(nameVal(dict.Get("PrintScaling")))
+	prefs.Duplex = PdfDuplex(nameVal(dict.Get("Duplex")))
+
+	if arr, ok := core.GetArray(dict.Get("PrintPageRange")); ok {
+		elements := arr.Elements()
+		for i := 0; i+1 < len(elements); i += 2 {
+			start, ok1 := core.GetIntVal(elements[i])
+			end, ok2 := core.GetIntVal(elements[i+1])
+			if !ok1 || !ok2 {
+				common.Log.Debug("ERROR: invalid print page range: %v", arr)
+				prefs.PrintPageRange = nil
+				break
+			}
+			prefs.PrintPageRange = append(prefs.PrintPageRange, PageRange{Start: start, End: end})
+		}
+	}
+	if numCopies, ok := core.GetIntVal(dict.Get("NumCopies")); ok {
+		prefs.NumCopies = numCopies
+	}
+	if arr, ok := core.GetArray(dict.Get("Enforce")); ok {
+		for _, elem := range arr.Elements() {
+			if name, ok := core.GetNameVal(elem); ok {
+				prefs.Enforce = append(prefs.Enforce, name)
+			}
+		}
+	}
+	return prefs, nil
+}
+
+// nameVal returns the value of the name `obj`, or an empty string if `obj` is
+// not a name.
+func nameVal(obj core.PdfObject) string {
+	name, _ := core.GetNameVal(obj)
+	return name
+}
+
+// boolFields returns the boolean preferences, by key.
+func (p *PdfViewerPreferences) boolFields() map[core.PdfObjectName]**bool {
+	return map[core.PdfObjectName]**bool{
+		"HideToolbar":       &p.HideToolbar,
+		"HideMenubar":       &p.HideMenubar,
+		"HideWindowUI":      &p.HideWindowUI,
+		"FitWindow":         &p.FitWindow,
+		"CenterWindow":      &p.CenterWindow,
+		"DisplayDocTitle":   &p.DisplayDocTitle,
+		"PickTrayByPDFSize": &p.PickTrayByPDFSize,
+	}
+}
+
+// boundaryFields returns the page boundary preferences, by key.
+func (p *PdfViewerPreferences) boundaryFields() map[core.PdfObjectName]*PdfPageBoundary {
+	return map[core.PdfObjectName]*PdfPageBoundary{
+		"ViewArea":  &p.ViewArea,
+		"ViewClip":  &p.ViewClip,
+		"PrintArea": &p.PrintArea,
+		"PrintClip": &p.PrintClip,
+	}
+}
+
+// Validate checks that the values of the viewer preferences are allowed.
+func (p *PdfViewerPreferences) Validate() error {
+	switch p.NonFullScreenPageMode {
+	case "", PageModeUseNone, PageModeUseOutlines, PageModeUseThumbs, PageModeUseOC:
+	default:
+		return fmt.Errorf("invalid non full screen page mode: %s", p.NonFullScreenPageMode)
+	}
+	switch p.Direction {
+	case "", ReadingDirectionL2R, ReadingDirectionR2L:
+	default:
+		return fmt.Errorf("invalid reading direction: %s", p.Direction)
+	}
+	for key, field := range p.boundaryFields() {
+		switch *field {
+		case "", PageBoundaryMediaBox, PageBoundaryCropBox, PageBoundaryBleedBox,
+			PageBoundaryTrimBox, PageBoundaryArtBox:
+		default:
+			return fmt.Errorf("invalid %s page boundary: %s", key, *field)
+		}
+	}
+	switch p.PrintScaling {
+	case "", PrintScalingNone, PrintScalingAppDefault:
+	default:
+		return fmt.Errorf("invalid print scaling: %s", p.PrintScaling)
+	}
+	switch p.Duplex {
+	case "", DuplexSimplex, DuplexFlipShortEdge, DuplexFlipLongEdge:
+	default:
+		return fmt.Errorf("invalid duplex: %s", p.Duplex)
+	}
+	for i, r := range p.PrintPageRange {
+		if r.Start < 1 || r.End < r.Start {
+			return fmt.Errorf("invalid print page range: %d-%d", r.Start, r.End)
+		}
+		if i > 0 && r.Start <= p.PrintPageRange[i-1].End {
+			return errors.New("print page ranges must be ordered and not overlap")
+		}
+	}
+	if p.NumCopies < 0 || p.NumCopies > 5 {
+		return fmt.Errorf("invalid number of copies: %d", p.NumCopies)
+	}
+	for _, name := range p.Enforce {
+		if name != "PrintScaling" {
+			return fmt.Errorf("preference cannot be enforced: %s", name)
+		}
+	}
+	return nil
+}
+
+// ToPdfObject returns the ViewerPreferences dictionary representing the
+// viewer preferences.
+func (p *PdfViewerPreferences) ToPdfObject() core.PdfObject {
+	dict := core.MakeDict()
+	setBool := func(key core.PdfObjectName, val *bool) {
+		if val != nil {
+			dict.Set(key, core.MakeBool(*val))
+		}
+	}
+	setBool("HideToolbar", p.HideToolbar)
+	setBool("HideMenubar", p.HideMenubar)
+	setBool("HideWindowUI", p.HideWindowUI)
+	setBool("FitWindow", p.FitWindow)
+	setBool("CenterWindow", p.CenterWindow)
+	setBool("DisplayDocTitle", p.DisplayDocTitle)
+
+	setName := func(key core.PdfObjectName, val string) {
+		if val != "" {
+			dict.Set(key, core.MakeName(val))
+		}
+	}
+	setName("NonFullScreenPageMode", string(p.NonFullScreenPageMode))
+	setName("Direction", string(p.Direction))
+	setName("ViewArea", string(p.ViewArea))
+	setName("ViewClip", string(p.ViewClip))
+	setName("PrintArea", string(p.PrintArea))
+	setName("PrintClip", string(p.PrintClip))
+	setName("PrintScaling", string(p.PrintScaling))
+	setName("Duplex", string(p.Duplex))
+	setBool("PickTrayByPDFSize", p.PickTrayByPDFSize)
+
+	if len(p.PrintPageRange) > 0 {
+		arr := core.MakeArray()
+		for _, r := range p.PrintPageRange {
+			arr.Append(core.MakeInteger(int64(r.Start)), core.MakeInteger(int64(r.End)))
+		}
+		dict.Set("PrintPageRange", arr)
+	}
+	if p.NumCopies > 0 {
+		dict.Set("NumCopies", core.MakeInteger(int64(p.NumCopies)))
+	}
+	if len(p.Enforce) > 0 {
+		arr := core.MakeArray()
+		for _, name := range p.Enforce {
+			arr.Append(core.MakeName(name))
+		}
+		dict.Set("Enforce", arr)
+	}
+	return dict
+}
+
+// GetPageLayout returns the page layout of the document. Documents without
+// a PageLayout entry use the SinglePage layout.
+func (r *PdfReader) GetPageLayout() (PdfPageLayout, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return PageLayoutSinglePage, err
+	}
+	obj := r.catalog.Get("PageLayout")
+	if obj == nil {
+		return PageLayoutSinglePage, nil
+	}
+	layout, ok := core.GetNameVal(obj)
+	if !ok {
+		return "", fmt.Errorf("page layout not a name (%T)", obj)
+	}
+	return PdfPageLayout(layout), nil
+}
+
+// GetPageMode returns the page mode of the document. Documents without a
+// PageMode entry use the UseNone mode.
+func (r *PdfReader) GetPageMode() (PdfPageMode, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return PageModeUseNone, err
+	}
+	obj := r.catalog.Get("PageMode")
+	if obj == nil {
+		return PageModeUseNone, nil
+	}
+	mode, ok := core.GetNameVal(obj)
+	if !ok {
+		return "", fmt.Errorf("page mode not a name (%T)", obj)
+	}
+	return PdfPageMode(mode), nil
+}
+
+// GetViewerPreferences returns the viewer preferences of the document, or nil
+// if the document does not have viewer preferences.
+func (r *PdfReader) GetViewerPreferences() (*PdfViewerPreferences, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return nil, err
+	}
+	obj := core.ResolveReference(r.catalog.Get("ViewerPreferences"))
+	if obj == nil || core.IsNullObject(obj) {
+		return nil, nil
+	}
+	return NewPdfViewerPreferencesFromObject(obj)
+}
+
+// GetOpenAction returns the destination displayed or the action performed
+// when the document is opened, as specified by the OpenAction entry of the
+// catalog. At most one of the returned values is not nil.
+func (r *PdfReader) GetOpenAction() (*PdfDestination, *PdfAction, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return nil, nil, err
+	}
+	obj := r.catalog.Get("OpenAction")
+	switch t := core.TraceToDirectObject(obj).(type) {
+	case nil, *core.PdfObjectNull:
+		return nil, nil, nil
+	case *core.PdfObjectArray:
+		dest, err := newPdfDestinationFromArray(t, r)
+		return dest, nil, err
+	case *core.PdfObjectDictionary:
+		container, ok := core.GetIndirect(obj)
+		if !ok {
+			container = core.MakeIndirectObject(t)
+		}
+		action, err := r.newPdfActionFromIndirectObject(container)
+		return nil, action, err
+	}
+	return nil, nil, fmt.Errorf("invalid open action (%T)", obj)
+}
+
+// SetPageLayout sets the page layout used when the document is opened.
+func (w *PdfWriter) SetPageLayout(layout PdfPageLayout) error {
+	switch layout {
+	case PageLayoutSinglePage, PageLayoutOneColumn, PageLayoutTwoColumnLeft,
+		PageLayoutTwoColumnRight, PageLayoutTwoPageLeft, PageLayoutTwoPageRight:
+	default:
+		return fmt.Errorf("invalid page layout: %s", layout)
+	}
+	w.catalog.Set("PageLayout", core.MakeName(string(layout)))
+	return nil
+}
+
+// SetPageMode sets the way the document is displayed when opened.
+func (w *PdfWriter) SetPageMode(mode PdfPageMode) error {
+	switch mode {
+	case PageModeUseNone, PageModeUseOutlines, PageModeUseThumbs, PageModeFullScreen,
+		PageModeUseOC, PageModeUseAttachments:
+	default:
+		return fmt.Errorf("invalid page mode: %s", mode)
+	}
+	w.catalog.Set("PageMode", core.MakeName(string(mode)))
+	return nil
+}
+
+// SetViewerPreferences sets the viewer preferences of the document.
+func (w *PdfWriter) SetViewerPreferences(prefs *PdfViewerPreferences) error {
+	if prefs == nil {
+		return errors.New("viewer preferences not specified")
+	}
+	if err := prefs.Validate(); err != nil {
+		return err
+	}
+	w.catalog.Set("ViewerPreferences", prefs.ToPdfObject())
+	return nil
+}
+
+// SetOpenActionDestination sets the destination displayed when the document
+// is opened. The page of the destination must be one of the pages of the
+// document.
+func (w *PdfWriter) SetOpenActionDestination(dest *PdfDestination) error {
+	if dest == nil || dest.Page == nil {
+		return errors.New("open action destination page not specified")
+	}
+	w.catalog.Set("OpenAction", dest.ToPdfObject())
+	return nil
+}
+
+// SetOpenAction sets the action performed when the document is opened.
+func (w *PdfWriter) SetOpenAction(action *PdfAction) error {
+	if action == nil {
+		return errors.New("open action not specified")
+	}
+	var obj core.PdfObject
+	if ctx := action.GetContext(); ctx != nil {
+		obj = ctx.ToPdfObject()
+	} else {
+		obj = action.ToPdfObject()
+	}
+	w.catalog.Set("OpenAction", obj)
+	return w.addObjects(obj)
+}
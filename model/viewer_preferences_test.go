@@ -0,0 +1,143 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+func TestViewerPreferencesRoundTrip(t *testing.T) {
+	yes, no := true, false
+	prefs := &PdfViewerPreferences{
+		HideToolbar:           &yes,
+		HideMenubar:           &no,
+		HideWindowUI:          &yes,
+		FitWindow:             &yes,
+		CenterWindow:          &no,
+		DisplayDocTitle:       &yes,
+		NonFullScreenPageMode: PageModeUseOutlines,
+		Direction:             ReadingDirectionR2L,
+		ViewArea:              PageBoundaryCropBox,
+		ViewClip:              PageBoundaryMediaBox,
+		PrintArea:             PageBoundaryBleedBox,
+		PrintClip:             PageBoundaryTrimBox,
+		PrintScaling:          PrintScalingNone,
+		Duplex:                DuplexFlipLongEdge,
+		PickTrayByPDFSize:     &yes,
+		PrintPageRange:        []PageRange{{Start: 1, End: 1}, {Start: 3, End: 4}},
+		NumCopies:             2,
+		Enforce:               []string{"PrintScaling"},
+	}
+
+	w := NewPdfWriter()
+	for i := 0; i < 4; i++ {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 100, Ury: 100}
+		require.NoError(t, w.AddPage(page))
+	}
+	require.NoError(t, w.SetPageLayout(PageLayoutTwoPageRight))
+	require.NoError(t, w.SetPageMode(PageModeFullScreen))
+	require.NoError(t, w.SetViewerPreferences(prefs))
+	reader := writeAndRead(t, &w)
+
+	layout, err := reader.GetPageLayout()
+	require.NoError(t, err)
+	require.Equal(t, PageLayoutTwoPageRight, layout)
+	mode, err := reader.GetPageMode()
+	require.NoError(t, err)
+	require.Equal(t, PageModeFullScreen, mode)
+	readPrefs, err := reader.GetViewerPreferences()
+	require.NoError(t, err)
+	require.Equal(t, prefs, readPrefs)
+
+	// Validation.
+	require.Error(t, w.SetPageLayout("ThreeColumns"))
+	require.Error(t, w.SetPageMode(""))
+	require.Error(t, w.SetViewerPreferences(nil))
+	for _, invalid := range []*PdfViewerPreferences{
+		{NonFullScreenPageMode: PageModeFullScreen},
+		{Direction: "T2B"},
+		{PrintClip: "Box"},
+		{PrintScaling: "Fit"},
+		{Duplex: "Duplex"},
+		{PrintPageRange: []PageRange{{Start: 0, End: 1}}},
+		{PrintPageRange: []PageRange{{Start: 3, End: 2}}},
+		{PrintPageRange: []PageRange{{Start: 1, End: 3}, {Start: 2, End: 4}}},
+		{NumCopies: 6},
+		{Enforce: []string{"Duplex"}},
+	} {
+		require.Error(t, w.SetViewerPreferences(invalid), "%+v", invalid)
+	}
+
+	// Documents without display settings.
+	w = NewPdfWriter()
+	require.NoError(t, w.AddPage(NewPdfPage()))
+	reader = writeAndRead(t, &w)
+	layout, err = reader.GetPageLayout()
+	require.NoError(t, err)
+	require.Equal(t, PageLayoutSinglePage, layout)
+	mode, err = reader.GetPageMode()
+	require.NoError(t, err)
+	require.Equal(t, PageModeUseNone, mode)
+	readPrefs, err = reader.GetViewerPreferences()
+	require.NoError(t, err)
+	require.Nil(t, readPrefs)
+	dest, action, err := reader.GetOpenAction()
+	require.NoError(t, err)
+	require.Nil(t, dest)
+	require.Nil(t, action)
+}
+
+func TestOpenAction(t *testing.T) {
+	newWriter := func() (*PdfWriter, []*PdfPage) {
+		w := NewPdfWriter()
+		var pages []*PdfPage
+		for i := 0; i < 3; i++ {
+			page := NewPdfPage()
+			page.MediaBox = &PdfRectangle{Urx: 100, Ury: 100}
+			require.NoError(t, w.AddPage(page))
+			pages = append(pages, page)
+		}
+		return &w, pages
+	}
+
+	// Destination.
+	w, pages := newWriter()
+	top, zoom := 80.0, 2.0
+	require.NoError(t, w.SetOpenActionDestination(
+		NewPdfDestinationXYZ(pages[1].GetPageAsIndirectObject(), nil, &top, &zoom)))
+	require.Error(t, w.SetOpenActionDestination(NewPdfDestinationFit(nil)))
+	reader := writeAndRead(t, w)
+	dest, action, err := reader.GetOpenAction()
+	require.NoError(t, err)
+	require.Nil(t, action)
+	require.NotNil(t, dest)
+	require.Equal(t, int64(1), dest.PageIndex)
+	require.Equal(t, DestinationModeXYZ, dest.Mode)
+	require.Equal(t, &top, dest.Top)
+	require.Equal(t, &zoom, dest.Zoom)
+	require.Nil(t, dest.Left)
+
+	// Action.
+	w, _ = newWriter()
+	named := NewPdfActionNamed()
+	named.N = core.MakeName("LastPage")
+	require.NoError(t, w.SetOpenAction(named.PdfAction))
+	reader = writeAndRead(t, w)
+	dest, action, err = reader.GetOpenAction()
+	require.NoError(t, err)
+	require.Nil(t, dest)
+	require.NotNil(t, action)
+	readNamed, ok := action.GetContext().(*PdfActionNamed)
+	require.True(t, ok)
+	name, ok := core.GetNameVal(readNamed.N)
+	require.True(t, ok)
+	require.Equal(t, "LastPage", name)
+}
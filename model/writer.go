@@ -10,8 +10,7 @@ package model
 
 import (
 	"bufio"
-	"bytes"
-	"encoding/binary"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -46,6 +45,53 @@ type crossReference struct {
 	Index        int
 }
 
+// maxXrefTableOffset is the largest offset of the cross-reference table entries, written with 10
+// digits. Larger offsets require a cross-reference stream.
+const maxXrefTableOffset = 9999999999
+
+// xrefStreamWidths returns the widths in bytes of the fields of the cross-reference stream
+// entries of `refs` (W entry). The offsets and object numbers are written with 4 bytes, or up to 8
+// bytes for large files, and the generation numbers and indices with 2 bytes.
+func xrefStreamWidths(refs map[int]crossReference) [3]int {
+	widths := [3]int{1, 4, 2}
+	for _, ref := range refs {
+		var field2, field3 uint64
+		switch ref.Type {
+		case 1:
+			field2, field3 = uint64(ref.Offset), uint64(ref.Generation)
+		case 2:
+			field2, field3 = uint64(ref.ObjectNumber), uint64(ref.Index)
+		}
+		for widths[1] < 8 && field2>>(8*uint(widths[1])) != 0 {
+			widths[1]++
+		}
+		for widths[2] < 8 && field3>>(8*uint(widths[2])) != 0 {
+			widths[2]++
+		}
+	}
+	return widths
+}
+
+// appendXrefStreamEntry appends the cross-reference stream entry of `ref`, with the field widths
+// `widths` (see xrefStreamWidths), to `data`.
+func appendXrefStreamEntry(data []byte, ref crossReference, widths [3]int) []byte {
+	fields := [3]uint64{uint64(ref.Type)}
+	switch ref.Type {
+	case 0:
+		fields[2] = 0xFFFF
+	case 1:
+		fields[1], fields[2] = uint64(ref.Offset), uint64(ref.Generation)
+	case 2:
+		fields[1], fields[2] = uint64(ref.ObjectNumber), uint64(ref.Index)
+	}
+	for i, field := range fields {
+		for shift := 8 * (widths[i] - 1); shift >= 0; shift -= 8 {
+			data = append(data, byte(field>>uint(shift)))
+		}
+	}
+	return data
+}
+
 func getPdfAuthor() string {
 	return pdfAuthor
 }
@@ -93,7 +139,7 @@ func getPdfModifiedDate() time.Time {
 
 // SetPdfModifiedDate sets the ModDate attribute of the output PDF.
 func SetPdfModifiedDate(modifiedDate time.Time) {
-	pdfCreationDate = modifiedDate
+	pdfModifiedDate = modifiedDate
 }
 
 func getPdfProducer() string {
@@ -152,7 +198,7 @@ type PdfWriter struct {
 	crypter     *core.PdfCrypt
 	encryptDict *core.PdfObjectDictionary
 	encryptObj  *core.PdfIndirectObject
-	ids         *core.PdfObjectArray
+	ids         *core.PdfObjectArray // Trailer ID, only set when required (encryption, PDF/A, incremental updates).
 
 	// PDF version
 	majorVersion int
@@ -162,6 +208,10 @@ type PdfWriter struct {
 	// Otherwise is used/not used depending on the PDF version (1.5 and above).
 	useCrossReferenceStream *bool
 
+	// Force whether or not to pack objects into object streams.
+	// Otherwise is used/not used depending on the PDF version (1.5 and above).
+	useObjectStreams *bool
+
 	// Objects to be followed up on prior to writing.
 	// These are objects that are added and reference objects that are not included
 	// for writing.
@@ -172,6 +222,42 @@ type PdfWriter struct {
 	// Forms.
 	acroForm *PdfAcroForm
 
+	// Pending changes of the document level embedded files.
+	embeddedFiles embeddedFileChanges
+
+	// Named destinations added to the Dests name tree.
+	namedDests map[string]*PdfDestination
+
+	// Pending changes of the document-level scripts.
+	javaScripts javaScriptChanges
+
+	// Copies of the objects of the documents pages were imported from.
+	importedObjects map[*PdfReader]map[core.PdfObject]core.PdfObject
+
+	// Clamp the page boxes to the media box instead of failing validation.
+	clampPageBoxes bool
+
+	// Structure tree of tagged documents.
+	structTreeRoot *PdfStructTreeRoot
+
+	// Write the document linearized (fast web view).
+	linearized bool
+
+	// Garbage collection of the unreachable objects, the numbers of the removed objects marked
+	// as free and the stats of the last collection.
+	gcOptions     *GarbageCollectionOptions
+	gcFreeNumbers map[int64]struct{}
+	gcStats       GarbageCollectionStats
+
+	// Formatting of the real numbers of the objects written.
+	numberFormat *NumberFormatOptions
+
+	// Logger of the writer, see SetLogger.
+	logger common.StructuredLogger
+
+	// Progress callback, see SetProgress.
+	progressFunc ProgressFunc
+
 	optimizer              Optimizer
 	crossReferenceMap      map[int]crossReference
 	writeOffset            int64 // used by PdfAppender
@@ -291,6 +377,9 @@ func copyObject(obj core.PdfObject, objectToObjectCopyMap map[core.PdfObject]cor
 		}
 		return newObj
 	case *core.PdfObjectStream:
+		if err := t.LoadData(); err != nil {
+			common.Log.Debug("ERROR: Unable to load stream data: %v", err)
+		}
 		newObj := &core.PdfObjectStream{
 			Stream:             t.Stream,
 			PdfObjectReference: t.PdfObjectReference,
@@ -426,6 +515,39 @@ func (w *PdfWriter) SetNamedDestinations(names core.PdfObject) error {
 	return w.addObjects(names)
 }
 
+// AddEmbeddedFile adds `file` to the document level embedded files
+// (EmbeddedFiles name tree), replacing any embedded file with the same name.
+// The name tree is updated when writing the document, preserving the other
+// entries of the Names dictionary.
+func (w *PdfWriter) AddEmbeddedFile(file *EmbeddedFile) error {
+	if file == nil || file.Name == "" {
+		return fmt.Errorf("embedded file name not specified: %w", ErrRequiredAttributeMissing)
+	}
+	w.embeddedFiles.add(file)
+	return nil
+}
+
+// RemoveEmbeddedFile removes the document level embedded file with the
+// specified name.
+func (w *PdfWriter) RemoveEmbeddedFile(name string) {
+	w.embeddedFiles.remove(name)
+}
+
+// AddNamedDestination adds a named destination to the Dests name tree of the
+// document, replacing any destination with the same name. Link annotations
+// and GoTo actions can refer to the destination by name, in which case the
+// destination is stored only once regardless of the number of references.
+func (w *PdfWriter) AddNamedDestination(name string, dest *PdfDestination) error {
+	if name == "" || dest == nil {
+		return fmt.Errorf("named destination not specified: %w", ErrRequiredAttributeMissing)
+	}
+	if w.namedDests == nil {
+		w.namedDests = map[string]*PdfDestination{}
+	}
+	w.namedDests[name] = dest
+	return nil
+}
+
 // SetPageLabels sets the PageLabels entry in the PDF catalog.
 // See section 12.4.2 "Page Labels" (p. 382 PDF32000_2008).
 func (w *PdfWriter) SetPageLabels(pageLabels core.PdfObject) error {
@@ -438,6 +560,44 @@ func (w *PdfWriter) SetPageLabels(pageLabels core.PdfObject) error {
 	return w.addObjects(pageLabels)
 }
 
+// SetClampPageBoxes sets whether the crop and trim boxes of the added pages
+// are reduced to their intersection with the media box (see
+// PdfPage.ClampBoxes). By default, adding pages with boxes outside of the
+// media box fails.
+func (w *PdfWriter) SetClampPageBoxes(clamp bool) {
+	w.clampPageBoxes = clamp
+}
+
+// checkPageBoxes validates or clamps the boxes of `page`, depending on the
+// clamping setting of the writer. Pages without a media box are not checked.
+func (w *PdfWriter) checkPageBoxes(page *PdfPage) error {
+	if box, err := page.getInheritedBox("MediaBox"); err != nil || box == nil {
+		return nil
+	}
+	if w.clampPageBoxes {
+		return page.ClampBoxes()
+	}
+	return page.ValidateBoxes()
+}
+
+// SetLogger sets the logger receiving the logs of the writer. The global common.Log is used if
+// `logger` is nil, which is the default.
+func (w *PdfWriter) SetLogger(logger common.StructuredLogger) {
+	w.logger = logger
+}
+
+// SetProgress sets the function called with the number of objects serialized out of the number of
+// objects of the document, and the number of bytes written, when writing the document.
+// Linearized documents are serialized in memory first, their progress being reported once written.
+func (w *PdfWriter) SetProgress(fn ProgressFunc) {
+	w.progressFunc = fn
+}
+
+// log returns the logger of the writer, see SetLogger.
+func (w *PdfWriter) log() common.StructuredLogger {
+	return common.StructuredOrGlobal(w.logger)
+}
+
 // SetOptimizer sets the optimizer to optimize PDF before writing.
 func (w *PdfWriter) SetOptimizer(optimizer Optimizer) {
 	w.optimizer = optimizer
@@ -460,7 +620,7 @@ func (w *PdfWriter) addObject(obj core.PdfObject) bool {
 	if !hasObj {
 		err := core.ResolveReferencesDeep(obj, w.traversed)
 		if err != nil {
-			common.Log.Debug("ERROR: %v - skipping", err)
+			w.log().Debug("unable to resolve references of object", "error", err)
 		}
 
 		w.objects = append(w.objects, obj)
@@ -560,6 +720,9 @@ func (w *PdfWriter) addObjects(obj core.PdfObject) error {
 
 // AddPage adds a page to the PDF file. The new page should be an indirect object.
 func (w *PdfWriter) AddPage(page *PdfPage) error {
+	if err := w.checkPageBoxes(page); err != nil {
+		return err
+	}
 	procPage(page)
 	obj := page.ToPdfObject()
 
@@ -568,23 +731,23 @@ func (w *PdfWriter) AddPage(page *PdfPage) error {
 
 	pageObj, ok := core.GetIndirect(obj)
 	if !ok {
-		return errors.New("page should be an indirect object")
+		return fmt.Errorf("page should be an indirect object: %w", ErrTypeCheck)
 	}
 	common.Log.Trace("%s", pageObj)
 	common.Log.Trace("%s", pageObj.PdfObject)
 
 	pDict, ok := core.GetDict(pageObj.PdfObject)
 	if !ok {
-		return errors.New("page object should be a dictionary")
+		return fmt.Errorf("page object should be a dictionary: %w", ErrTypeCheck)
 	}
 
 	otype, ok := core.GetName(pDict.Get("Type"))
 	if !ok {
-		return fmt.Errorf("page should have a Type key with a value of type name (%T)", pDict.Get("Type"))
+		return fmt.Errorf("page should have a Type key with a value of type name (%T): %w", pDict.Get("Type"), ErrTypeCheck)
 
 	}
 	if otype.String() != "Page" {
-		return errors.New("field Type != Page (Required)")
+		return fmt.Errorf("field Type != Page (Required): %w", ErrTypeCheck)
 	}
 
 	// Copy inherited fields if missing.
@@ -595,7 +758,7 @@ func (w *PdfWriter) AddPage(page *PdfPage) error {
 		common.Log.Trace("Page Parent: %T", parent)
 		parentDict, ok := core.GetDict(parent.PdfObject)
 		if !ok {
-			return errors.New("invalid Parent object")
+			return fmt.Errorf("invalid Parent object: %w", ErrTypeCheck)
 		}
 		for _, field := range inheritedFields {
 			common.Log.Trace("Field %s", field)
@@ -624,16 +787,16 @@ func (w *PdfWriter) AddPage(page *PdfPage) error {
 	// Add to Pages.
 	pagesDict, ok := core.GetDict(w.pages.PdfObject)
 	if !ok {
-		return errors.New("invalid Pages obj (not a dict)")
+		return fmt.Errorf("invalid Pages obj (not a dict): %w", ErrTypeCheck)
 	}
 	kids, ok := core.GetArray(pagesDict.Get("Kids"))
 	if !ok {
-		return errors.New("invalid Pages Kids obj (not an array)")
+		return fmt.Errorf("invalid Pages Kids obj (not an array): %w", ErrTypeCheck)
 	}
 	kids.Append(pageObj)
 	pageCount, ok := core.GetInt(pagesDict.Get("Count"))
 	if !ok {
-		return errors.New("invalid Pages Count object (not an integer)")
+		return fmt.Errorf("invalid Pages Count object (not an integer): %w", ErrTypeCheck)
 	}
 	// Update the count.
 	*pageCount = *pageCount + 1
@@ -742,7 +905,7 @@ func (w *PdfWriter) writeObject(num int, obj core.PdfObject) {
 			sDict.fileOffset = w.writePos + int64(len(outStr))
 		}
 		if pobj.PdfObject == nil {
-			common.Log.Debug("Error: indirect object's PdfObject should never be nil - setting to PdfObjectNull")
+			w.log().Debug("nil indirect object written as null", "object", num)
 			pobj.PdfObject = core.MakeNull()
 		}
 		outStr += pobj.PdfObject.WriteString()
@@ -765,8 +928,6 @@ func (w *PdfWriter) writeObject(num int, obj core.PdfObject) {
 	}
 
 	if ostreams, isObjStreams := obj.(*core.PdfObjectStreams); isObjStreams {
-		w.crossReferenceMap[num] = crossReference{Type: 1, Offset: w.writePos, Generation: ostreams.GenerationNumber}
-		outStr := fmt.Sprintf("%d 0 obj\n", num)
 		var offsets []string
 		var objData string
 		var offset int64
@@ -795,14 +956,23 @@ func (w *PdfWriter) writeObject(num int, obj core.PdfObject) {
 		dict.Set(core.PdfObjectName("First"), core.MakeInteger(first))
 
 		data, _ := encoder.EncodeBytes([]byte(offsetsStr + objData))
-		length := int64(len(data))
+		stream := &core.PdfObjectStream{PdfObjectDictionary: dict, Stream: data}
+		stream.ObjectNumber = int64(num)
+		stream.GenerationNumber = ostreams.GenerationNumber
+		dict.Set(core.PdfObjectName("Length"), core.MakeInteger(int64(len(data))))
 
-		dict.Set(core.PdfObjectName("Length"), core.MakeInteger(length))
-		outStr += dict.WriteString()
-		outStr += "\nstream\n"
-		w.writeString(outStr)
-		w.writeBytes(data)
-		w.writeString("\nendstream\nendobj\n")
+		// The objects of the stream are not encrypted individually, the
+		// stream is encrypted as a whole.
+		if w.crypter != nil {
+			if err := w.crypter.Encrypt(stream, int64(num), 0); err != nil {
+				w.log().Error("unable to encrypt object stream", "object", num, "error", err)
+				if w.werr == nil {
+					w.werr = err
+				}
+				return
+			}
+		}
+		w.writeObject(num, stream)
 		return
 	}
 
@@ -817,6 +987,11 @@ func (w *PdfWriter) updateObjectNumbers() {
 	i := 0
 	for _, obj := range w.objects {
 		objNum := int64(i + 1 + offset)
+		for _, isFree := w.gcFreeNumbers[objNum]; isFree; _, isFree = w.gcFreeNumbers[objNum] {
+			// Skip the numbers of the objects removed by the garbage collection.
+			i++
+			objNum++
+		}
 		increase := true
 		if w.appendMode {
 			if replaceNum, has := w.appendReplaceMap[obj]; has {
@@ -836,7 +1011,7 @@ func (w *PdfWriter) updateObjectNumbers() {
 			o.ObjectNumber = objNum
 			o.GenerationNumber = 0
 		default:
-			common.Log.Debug("ERROR: Unknown type %T - skipping", o)
+			w.log().Debug("object of unknown type skipped", "type", fmt.Sprintf("%T", o))
 			continue
 		}
 
@@ -866,6 +1041,10 @@ func (w *PdfWriter) updateObjectNumbers() {
 type EncryptOptions struct {
 	Permissions security.Permissions
 	Algorithm   EncryptionAlgorithm
+
+	// UnencryptedMetadata leaves the XMP metadata streams of the document unencrypted, so that
+	// they can be read without decrypting the document. Requires an AES algorithm.
+	UnencryptedMetadata bool
 }
 
 // EncryptionAlgorithm is used in EncryptOptions to change the default algorithm used to encrypt the document.
@@ -887,8 +1066,10 @@ func (w *PdfWriter) Encrypt(userPass, ownerPass []byte, options *EncryptOptions)
 		algo = options.Algorithm
 	}
 	perm := security.PermOwner
+	encryptMetadata := true
 	if options != nil {
 		perm = options.Permissions
+		encryptMetadata = !options.UnencryptedMetadata
 	}
 
 	var cf crypt.Filter
@@ -900,9 +1081,59 @@ func (w *PdfWriter) Encrypt(userPass, ownerPass []byte, options *EncryptOptions)
 	case AES_256bit:
 		cf = crypt.NewFilterAESV3()
 	default:
-		return fmt.Errorf("unsupported algorithm: %v", options.Algorithm)
+		return fmt.Errorf("unsupported algorithm %v: %w", options.Algorithm, core.ErrNotSupported)
+	}
+	crypter, info, err := core.PdfCryptNewEncryptMetadata(cf, userPass, ownerPass, perm, encryptMetadata)
+	if err != nil {
+		return err
+	}
+	w.crypter = crypter
+	if info.Major != 0 {
+		w.SetVersion(info.Major, info.Minor)
+	}
+	w.encryptDict = info.Encrypt
+
+	w.ids = core.MakeArray(core.MakeHexString(info.ID0), core.MakeHexString(info.ID1))
+
+	// Make an object to contain the encryption dictionary.
+	io := core.MakeIndirectObject(info.Encrypt)
+	w.encryptObj = io
+	w.addObject(io)
+
+	return nil
+}
+
+// EncryptWithCertificates encrypts the output file to the certificates of the recipient `groups`
+// with the public-key security handler (Adobe.PubSec). The recipients of each group are granted
+// the permissions of the group. Only the AES_128bit and AES_256bit algorithms are supported.
+func (w *PdfWriter) EncryptWithCertificates(groups []security.PubKeyRecipientGroup, algo EncryptionAlgorithm) error {
+	return w.encryptWithCertificates(groups, algo, true)
+}
+
+// EncryptWithCertificatesOptions encrypts the output file to the certificates of the recipient
+// `groups`, as EncryptWithCertificates, with the Algorithm and UnencryptedMetadata `options`. The
+// Permissions of `options` are not used. AES_256bit is used if `options` is nil.
+func (w *PdfWriter) EncryptWithCertificatesOptions(groups []security.PubKeyRecipientGroup, options *EncryptOptions) error {
+	if options == nil {
+		return w.encryptWithCertificates(groups, AES_256bit, true)
+	}
+	return w.encryptWithCertificates(groups, options.Algorithm, !options.UnencryptedMetadata)
+}
+
+// encryptWithCertificates encrypts the output file to the certificates of the recipient `groups`
+// with the algorithm `algo`, leaving the metadata streams unencrypted if `encryptMetadata` is false.
+func (w *PdfWriter) encryptWithCertificates(groups []security.PubKeyRecipientGroup, algo EncryptionAlgorithm,
+	encryptMetadata bool) error {
+	var cf crypt.Filter
+	switch algo {
+	case AES_128bit:
+		cf = crypt.NewFilterAESV2()
+	case AES_256bit:
+		cf = crypt.NewFilterAESV3()
+	default:
+		return fmt.Errorf("unsupported algorithm for public-key encryption %v: %w", algo, core.ErrNotSupported)
 	}
-	crypter, info, err := core.PdfCryptNewEncrypt(cf, userPass, ownerPass, perm)
+	crypter, info, err := core.PdfCryptNewEncryptPubKeyMetadata(cf, groups, encryptMetadata)
 	if err != nil {
 		return err
 	}
@@ -944,7 +1175,18 @@ func (w *PdfWriter) writeBytes(bb []byte) {
 
 // Write writes out the PDF.
 func (w *PdfWriter) Write(writer io.Writer) error {
+	return w.WriteWithContext(context.Background(), writer)
+}
+
+// WriteWithContext writes out the PDF as Write, checking `ctx` before the
+// optimization passes and the objects written. Returns an error wrapping the
+// error of `ctx` if it is cancelled or its deadline is exceeded, in which case
+// the output written so far is incomplete.
+func (w *PdfWriter) WriteWithContext(ctx context.Context, writer io.Writer) error {
 	common.Log.Trace("Write()")
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("writing cancelled: %w", err)
+	}
 
 	lk := license.GetLicenseKey()
 	if lk == nil || !lk.IsLicensed() {
@@ -976,10 +1218,44 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 		}
 	}
 
+	// Embedded files, named destinations and document-level scripts.
+	if !w.embeddedFiles.isEmpty() || len(w.namedDests) > 0 || !w.javaScripts.isEmpty() {
+		names := w.catalog.Get("Names")
+		if !w.embeddedFiles.isEmpty() {
+			updated, err := w.embeddedFiles.apply(names)
+			if err != nil {
+				return err
+			}
+			names = updated
+		}
+		if len(w.namedDests) > 0 {
+			names = applyNamedDestinations(names, w.namedDests)
+		}
+		if !w.javaScripts.isEmpty() {
+			names = w.javaScripts.apply(names)
+		}
+		if setCatalogNames(w.catalog, names) {
+			if err := w.addObjects(names); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Structure tree. Written after the pages have been added, as it sets
+	// their StructParents entries.
+	if w.structTreeRoot != nil {
+		structTree := w.structTreeRoot.ToPdfObject()
+		w.catalog.Set("StructTreeRoot", structTree)
+		if err := w.addObjects(structTree); err != nil {
+			return err
+		}
+	}
+
 	// Check pending objects prior to write.
 	for pendingObj, pendingObjDicts := range w.pendingObjects {
 		if !w.hasObject(pendingObj) {
-			common.Log.Debug("WARN Pending object %+v %T (%p) never added for writing", pendingObj, pendingObj, pendingObj)
+			w.log().Debug("pending object never added for writing, replaced with null",
+				"type", fmt.Sprintf("%T", pendingObj))
 			for _, pendingObjDict := range pendingObjDicts {
 				for _, key := range pendingObjDict.Keys() {
 					val := pendingObjDict.Get(key)
@@ -999,10 +1275,15 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 	// TODO: Copying wastes memory. Might be worth making user responsible for handling properly.
 	//       Is copy needed for optimization?
 	w.copyObjects()
+	w.formatNumbers()
 
 	if w.optimizer != nil {
 		var err error
-		w.objects, err = w.optimizer.Optimize(w.objects)
+		if optimizer, ok := w.optimizer.(ContextOptimizer); ok {
+			w.objects, err = optimizer.OptimizeWithContext(ctx, w.objects)
+		} else {
+			w.objects, err = w.optimizer.Optimize(w.objects)
+		}
 		if err != nil {
 			return err
 		}
@@ -1013,6 +1294,29 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 		w.objectsMap = objMap
 	}
 
+	w.collectGarbage()
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("writing cancelled: %w", err)
+	}
+
+	progress := NewProgressReporter(w.progressFunc, ProgressWriting, int64(len(w.objects)), w.logger)
+	progress.Update(0, 0)
+	if w.linearized {
+		if err := w.writeLinearized(writer); err != nil {
+			return err
+		}
+		progress.Complete(w.writePos)
+		return nil
+	}
+
+	useObjectStreams := !w.appendMode && (w.majorVersion > 1 || (w.majorVersion == 1 && w.minorVersion > 4))
+	if w.useObjectStreams != nil {
+		useObjectStreams = *w.useObjectStreams
+	}
+	if useObjectStreams {
+		w.packObjectStreams()
+	}
+
 	w.writePos = w.writeOffset
 	w.writer = bufio.NewWriter(writer)
 	useCrossReferenceStream := w.majorVersion > 1 || (w.majorVersion == 1 && w.minorVersion > 4)
@@ -1052,6 +1356,9 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 	common.Log.Trace("Writing %d obj", len(w.objects))
 	w.crossReferenceMap = make(map[int]crossReference)
 	w.crossReferenceMap[0] = crossReference{Type: 0, ObjectNumber: 0, Generation: 0xFFFF}
+	for num := range w.gcFreeNumbers {
+		w.crossReferenceMap[int(num)] = crossReference{Type: 0, ObjectNumber: 0, Generation: 0xFFFF}
+	}
 	if w.appendToXrefs.ObjectMap != nil {
 		for idx, xref := range w.appendToXrefs.ObjectMap {
 			if idx == 0 {
@@ -1069,10 +1376,15 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 	}
 
 	// Write out indirect/stream objects that are not in object streams.
-	for _, obj := range w.objects {
+	for i, obj := range w.objects {
+		// The objects of object streams have been serialized with their stream.
+		progress.Update(int64(i), w.writePos)
 		if skip := objectsInObjectStreams[obj]; skip {
 			continue
 		}
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("writing cancelled: %w", err)
+		}
 
 		objectNumber := int64(0)
 		switch t := obj.(type) {
@@ -1083,7 +1395,7 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 		case *core.PdfObjectStreams:
 			objectNumber = t.ObjectNumber
 		default:
-			common.Log.Debug("ERROR: Unsupported type in writer objects: %T", obj)
+			w.log().Error("unsupported type in writer objects", "type", fmt.Sprintf("%T", obj))
 			return ErrTypeCheck
 		}
 
@@ -1092,7 +1404,7 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 		if w.crypter != nil && obj != w.encryptObj {
 			err := w.crypter.Encrypt(obj, int64(objectNumber), 0)
 			if err != nil {
-				common.Log.Debug("ERROR: Failed encrypting (%s)", err)
+				w.log().Error("unable to encrypt object", "object", objectNumber, "error", err)
 				return err
 			}
 		}
@@ -1100,6 +1412,11 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 	}
 
 	xrefOffset := w.writePos
+	if !useCrossReferenceStream && xrefOffset > maxXrefTableOffset {
+		// The offsets of the cross-reference table entries are limited to 10 digits.
+		common.Log.Debug("Offsets exceed 10 digits (%d) - writing a cross-reference stream", xrefOffset)
+		useCrossReferenceStream = true
+	}
 	var maxIndex int
 	for idx := range w.crossReferenceMap {
 		if idx > maxIndex {
@@ -1111,7 +1428,8 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 	if useCrossReferenceStream {
 		crossObjNumber := maxIndex + 1
 		w.crossReferenceMap[crossObjNumber] = crossReference{Type: 1, ObjectNumber: crossObjNumber, Offset: xrefOffset}
-		crossReferenceData := bytes.NewBuffer(nil)
+		widths := xrefStreamWidths(w.crossReferenceMap)
+		var crossReferenceData []byte
 
 		index := core.MakeArray()
 		for idx := 0; idx <= maxIndex; {
@@ -1134,33 +1452,23 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 			index.Append(core.MakeInteger(int64(idx)), core.MakeInteger(int64(j-idx)))
 
 			for k := idx; k < j; k++ {
-				ref := w.crossReferenceMap[k]
-				switch ref.Type {
-				case 0:
-					binary.Write(crossReferenceData, binary.BigEndian, byte(0))
-					binary.Write(crossReferenceData, binary.BigEndian, uint32(0))
-					binary.Write(crossReferenceData, binary.BigEndian, uint16(0xFFFF))
-				case 1:
-					binary.Write(crossReferenceData, binary.BigEndian, byte(1))
-					binary.Write(crossReferenceData, binary.BigEndian, uint32(ref.Offset))
-					binary.Write(crossReferenceData, binary.BigEndian, uint16(ref.Generation))
-				case 2:
-					binary.Write(crossReferenceData, binary.BigEndian, byte(2))
-					binary.Write(crossReferenceData, binary.BigEndian, uint32(ref.ObjectNumber))
-					binary.Write(crossReferenceData, binary.BigEndian, uint16(ref.Index))
-				}
+				crossReferenceData = appendXrefStreamEntry(crossReferenceData, w.crossReferenceMap[k], widths)
 			}
 
 			idx = j + 1
 		}
 
-		crossReferenceStream, err := core.MakeStream(crossReferenceData.Bytes(), core.NewFlateEncoder())
+		// The PNG Up predictor makes the consecutive entries compress well.
+		encoder := core.NewFlateEncoder()
+		encoder.Predictor = 12
+		encoder.Columns = widths[0] + widths[1] + widths[2]
+		crossReferenceStream, err := core.MakeStream(crossReferenceData, encoder)
 		if err != nil {
 			return err
 		}
 		crossReferenceStream.ObjectNumber = int64(crossObjNumber)
 		crossReferenceStream.PdfObjectDictionary.Set("Type", core.MakeName("XRef"))
-		crossReferenceStream.PdfObjectDictionary.Set("W", core.MakeArray(core.MakeInteger(1), core.MakeInteger(4), core.MakeInteger(2)))
+		crossReferenceStream.PdfObjectDictionary.Set("W", core.MakeArrayFromIntegers(widths[:]))
 		crossReferenceStream.PdfObjectDictionary.Set("Index", index)
 		crossReferenceStream.PdfObjectDictionary.Set("Size", core.MakeInteger(int64(crossObjNumber+1)))
 		crossReferenceStream.PdfObjectDictionary.Set("Info", w.infoObj)
@@ -1171,6 +1479,8 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 		// If encrypted!
 		if w.crypter != nil {
 			crossReferenceStream.Set("Encrypt", w.encryptObj)
+		}
+		if w.ids != nil {
 			crossReferenceStream.Set("ID", w.ids)
 			common.Log.Trace("Ids: %s", w.ids)
 		}
@@ -1224,6 +1534,8 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 		// If encrypted!
 		if w.crypter != nil {
 			trailer.Set("Encrypt", w.encryptObj)
+		}
+		if w.ids != nil {
 			trailer.Set("ID", w.ids)
 			common.Log.Trace("Ids: %s", w.ids)
 		}
@@ -1240,6 +1552,9 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 	if w.werr == nil {
 		w.werr = w.writer.Flush()
 	}
+	if w.werr == nil {
+		progress.Complete(w.writePos)
+	}
 
 	return w.werr
 }
@@ -0,0 +1,79 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// NumberFormatOptions represents the formatting of the real numbers of the objects written by the
+// writer (see PdfWriter.SetNumberFormat).
+type NumberFormatOptions struct {
+	// Precision is the maximum number of decimal places of the real numbers. The numbers are
+	// rounded, without trailing zeros, and numbers equal to integers are written as integers.
+	Precision int
+
+	// Normalize applies the precision to the objects copied from source documents as well.
+	// Otherwise, only the objects created for the output, e.g. new pages, annotations and form
+	// XObjects, are affected and the numbers of the objects of source documents are written as
+	// they were read.
+	Normalize bool
+}
+
+// SetNumberFormat sets the formatting of the real numbers of the objects written, such as
+// matrices, rectangles and annotation coordinates. The numbers are written as they are if
+// `options` is nil, which is the default. The data of streams, including content streams, is
+// never modified: the precision of generated content streams is set with
+// contentstream.ContentCreator.SetPrecision.
+func (w *PdfWriter) SetNumberFormat(options *NumberFormatOptions) {
+	w.numberFormat = options
+}
+
+// formatNumbers rounds the real numbers of the objects to write to the configured precision. The
+// objects copied from source documents, i.e. read by a parser, are skipped unless normalizing.
+// The writer objects being copies, the objects of the caller are not modified.
+func (w *PdfWriter) formatNumbers() {
+	if w.numberFormat == nil || w.numberFormat.Precision < 0 {
+		return
+	}
+	precision := w.numberFormat.Precision
+
+	var round func(obj core.PdfObject)
+	round = func(obj core.PdfObject) {
+		switch t := obj.(type) {
+		case *core.PdfObjectFloat:
+			*t = core.PdfObjectFloat(core.RoundFloat(float64(*t), precision))
+		case *core.PdfObjectDictionary:
+			for _, key := range t.Keys() {
+				round(t.Get(key))
+			}
+		case *core.PdfObjectArray:
+			for _, elem := range t.Elements() {
+				round(elem)
+			}
+		}
+	}
+	var format func(obj core.PdfObject)
+	format = func(obj core.PdfObject) {
+		switch t := obj.(type) {
+		case *core.PdfObjectStreams:
+			for _, elem := range t.Elements() {
+				format(elem)
+			}
+		case *core.PdfIndirectObject:
+			if t.GetParser() == nil || w.numberFormat.Normalize {
+				round(t.PdfObject)
+			}
+		case *core.PdfObjectStream:
+			if t.GetParser() == nil || w.numberFormat.Normalize {
+				round(t.PdfObjectDictionary)
+			}
+		}
+	}
+	for _, obj := range w.objects {
+		format(obj)
+	}
+}
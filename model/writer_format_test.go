@@ -0,0 +1,117 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+func TestWriteNumberFormat(t *testing.T) {
+	const numPages = 10
+	const numAnnots = 50
+	// A4 in points.
+	mediaBox := []float64{0, 0, 595.2755905511812, 841.8897637795276}
+	angle := math.Pi / 6
+	matrix := []float64{math.Cos(angle), math.Sin(angle), -math.Sin(angle), math.Cos(angle), 100.0 / 3, 0.30000000000000004}
+	rect := func(i int) []float64 {
+		x := float64(i) * 10 / 3
+		return []float64{x, x / 7, x + 100.0/7, x/7 + 0.30000000000000004}
+	}
+
+	write := func(w *PdfWriter, options *NumberFormatOptions) []byte {
+		w.SetNumberFormat(options)
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+		return buf.Bytes()
+	}
+	generate := func(options *NumberFormatOptions) []byte {
+		w := NewPdfWriter()
+		for i := 0; i < numPages; i++ {
+			page := NewPdfPage()
+			page.MediaBox, _ = NewPdfRectangle(*core.MakeArrayFromFloats(mediaBox))
+			for j := 0; j < numAnnots; j++ {
+				annot := NewPdfAnnotationSquare()
+				annot.Rect = core.MakeArrayFromFloats(rect(j))
+				page.AddAnnotation(annot.PdfAnnotation)
+			}
+			form := NewXObjectForm()
+			form.Matrix = core.MakeArrayFromFloats(matrix)
+			form.BBox = core.MakeArrayFromFloats(mediaBox)
+			require.NoError(t, form.SetContentStream([]byte("0 0 m 10 10 l S"), nil))
+			require.NoError(t, page.Resources.SetXObjectFormByName("X0", form))
+			page.AddContentStreamByString("/X0 Do")
+			require.NoError(t, w.AddPage(page))
+		}
+		return write(&w, options)
+	}
+	// check checks that the numbers of the document `data` are within `precision` of the
+	// generated ones, or identical if `precision` is negative.
+	check := func(data []byte, precision int) *PdfReader {
+		requireNumbers := func(expected []float64, obj core.PdfObject) {
+			arr, ok := core.GetArray(obj)
+			require.True(t, ok)
+			vals, err := arr.ToFloat64Array()
+			require.NoError(t, err)
+			require.Len(t, vals, len(expected))
+			for i := range vals {
+				if precision < 0 {
+					require.Equal(t, expected[i], vals[i])
+				} else {
+					require.InDelta(t, expected[i], vals[i], 0.5*math.Pow10(-precision)+1e-9)
+					require.Equal(t, core.RoundFloat(expected[i], precision), vals[i])
+				}
+			}
+		}
+
+		r, err := NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		require.Len(t, r.PageList, numPages)
+		for _, page := range r.PageList {
+			requireNumbers(mediaBox, page.MediaBox.ToPdfObject())
+			annots, err := page.GetAnnotations()
+			require.NoError(t, err)
+			require.Len(t, annots, numAnnots)
+			for j, annot := range annots {
+				requireNumbers(rect(j), annot.Rect)
+			}
+			form, err := page.Resources.GetXObjectFormByName("X0")
+			require.NoError(t, err)
+			requireNumbers(matrix, form.Matrix)
+			requireNumbers(mediaBox, form.BBox)
+		}
+		return r
+	}
+
+	full := generate(nil)
+	check(full, -1)
+	for _, precision := range []int{0, 2, 4} {
+		rounded := generate(&NumberFormatOptions{Precision: precision})
+		check(rounded, precision)
+		require.True(t, len(rounded) < len(full)-numPages*numAnnots*10, "%d >= %d", len(rounded), len(full))
+	}
+
+	// The numbers copied from source documents are written as they are, unless normalizing.
+	for _, normalize := range []bool{false, true} {
+		r := check(full, -1)
+		w := NewPdfWriter()
+		for _, page := range r.PageList {
+			require.NoError(t, w.AddPage(page))
+		}
+		data := write(&w, &NumberFormatOptions{Precision: 2, Normalize: normalize})
+		if normalize {
+			check(data, 2)
+			require.True(t, len(data) < len(full), "%d >= %d", len(data), len(full))
+		} else {
+			check(data, -1)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// GarbageCollectionOptions represents the options of the garbage collection of the objects of
+// the writer (see PdfWriter.SetGarbageCollection).
+type GarbageCollectionOptions struct {
+	// CompactObjectNumbers numbers the remaining objects consecutively. Otherwise, the objects are
+	// numbered as if no object had been removed and the numbers of the removed objects are marked
+	// as free in the cross-reference table.
+	CompactObjectNumbers bool
+}
+
+// GarbageCollectionStats represents the objects removed by the garbage collection of the writer.
+type GarbageCollectionStats struct {
+	// Objects is the number of unreachable indirect and stream objects removed.
+	Objects int
+
+	// Bytes is the size of the removed objects, as they would have been written without
+	// encryption.
+	Bytes int64
+}
+
+// SetGarbageCollection enables the removal of the objects which are not reachable from the
+// document catalog, the document information dictionary or the encryption dictionary, such as
+// objects replaced when editing the document, prior to writing. The garbage collection is
+// disabled if `options` is nil, which is the default. It does not apply to incremental updates,
+// which keep the objects of the previous revisions, and linearized documents are always numbered
+// consecutively. The removed objects are reported by GetGarbageCollectionStats.
+func (w *PdfWriter) SetGarbageCollection(options *GarbageCollectionOptions) {
+	w.gcOptions = options
+}
+
+// GetGarbageCollectionStats returns the objects removed by the garbage collection when the
+// document was last written.
+func (w *PdfWriter) GetGarbageCollectionStats() GarbageCollectionStats {
+	return w.gcStats
+}
+
+// collectGarbage removes the objects of the writer which are not reachable from the catalog, the
+// document information dictionary or the encryption dictionary. All dictionary entries are
+// followed, including Parent entries. Unless compacting the object numbers, the numbers the
+// removed objects would have been written with are recorded as free.
+func (w *PdfWriter) collectGarbage() {
+	w.gcStats = GarbageCollectionStats{}
+	w.gcFreeNumbers = nil
+	if w.gcOptions == nil {
+		return
+	}
+	if w.appendMode {
+		common.Log.Debug("Garbage collection not supported for incremental updates - skipping")
+		return
+	}
+
+	reachable := map[core.PdfObject]struct{}{}
+	var queue []core.PdfObject
+	var walk func(obj core.PdfObject)
+	walk = func(obj core.PdfObject) {
+		switch t := obj.(type) {
+		case *core.PdfIndirectObject, *core.PdfObjectStream:
+			if _, ok := reachable[t]; !ok {
+				reachable[t] = struct{}{}
+				queue = append(queue, t)
+			}
+		case *core.PdfObjectDictionary:
+			for _, key := range t.Keys() {
+				walk(t.Get(key))
+			}
+		case *pdfSignDictionary:
+			walk(t.PdfObjectDictionary)
+		case *core.PdfObjectArray:
+			for _, elem := range t.Elements() {
+				walk(elem)
+			}
+		}
+	}
+	for _, root := range []*core.PdfIndirectObject{w.root, w.infoObj, w.encryptObj} {
+		if root != nil {
+			walk(root)
+		}
+	}
+	for i := 0; i < len(queue); i++ {
+		switch t := queue[i].(type) {
+		case *core.PdfIndirectObject:
+			walk(t.PdfObject)
+		case *core.PdfObjectStream:
+			walk(t.PdfObjectDictionary)
+		}
+	}
+
+	objects := make([]core.PdfObject, 0, len(w.objects))
+	objectsMap := make(map[core.PdfObject]struct{}, len(w.objects))
+	num := int64(w.ObjNumOffset)
+	for _, obj := range w.objects {
+		keep := true
+		switch t := obj.(type) {
+		case *core.PdfObjectStreams:
+			// Object streams are kept if they contain reachable objects.
+			var elems []core.PdfObject
+			for _, elem := range t.Elements() {
+				if _, ok := reachable[elem]; ok {
+					elems = append(elems, elem)
+				}
+			}
+			if len(elems) < t.Len() {
+				objStm := core.MakeObjectStreams(elems...)
+				objStm.PdfObjectReference = t.PdfObjectReference
+				obj = objStm
+			}
+			keep = len(elems) > 0
+			num++
+		case *core.PdfIndirectObject, *core.PdfObjectStream:
+			_, keep = reachable[obj]
+			if !keep {
+				w.gcStats.Objects++
+				w.gcStats.Bytes += gcObjectSize(obj)
+			}
+			num++
+		default:
+			// Not written (see updateObjectNumbers).
+		}
+
+		if keep {
+			objects = append(objects, obj)
+			objectsMap[obj] = struct{}{}
+		} else if !w.gcOptions.CompactObjectNumbers {
+			if w.gcFreeNumbers == nil {
+				w.gcFreeNumbers = map[int64]struct{}{}
+			}
+			w.gcFreeNumbers[num] = struct{}{}
+		}
+	}
+	common.Log.Debug("Garbage collection: removed %d objects (%d bytes)", w.gcStats.Objects, w.gcStats.Bytes)
+
+	w.objects = objects
+	w.objectsMap = objectsMap
+}
+
+// gcObjectSize returns the size of the written contents of `obj`.
+func gcObjectSize(obj core.PdfObject) int64 {
+	switch t := obj.(type) {
+	case *core.PdfIndirectObject:
+		if t.PdfObject == nil {
+			return 0
+		}
+		return int64(len(t.PdfObject.WriteString()))
+	case *core.PdfObjectStream:
+		return int64(len(t.PdfObjectDictionary.WriteString()) + len(t.Stream))
+	}
+	return 0
+}
@@ -0,0 +1,141 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/core/security"
+)
+
+func TestWriteGarbageCollection(t *testing.T) {
+	data, err := ioutil.ReadFile("./testdata/OoPdfFormExample.pdf")
+	require.NoError(t, err)
+	// The pages are read for each output, as adding them to writers can modify them.
+	read := func() *PdfReader {
+		reader, err := NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		return reader
+	}
+	reader := read()
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+
+	// The objects of the replaced Names and OCProperties entries of the catalog are orphaned.
+	const orphans = 4
+	bloat := bytes.Repeat([]byte("orphaned data "), 1000)
+
+	type options struct {
+		version   int
+		gc        *GarbageCollectionOptions
+		bloated   bool
+		encrypted bool
+	}
+	write := func(opts options) ([]byte, GarbageCollectionStats) {
+		reader := read()
+		w := NewPdfWriter()
+		w.SetVersion(1, opts.version)
+		for _, page := range reader.PageList {
+			require.NoError(t, w.AddPage(page))
+		}
+		require.NoError(t, w.SetForms(reader.AcroForm))
+		if opts.bloated {
+			stream, err := core.MakeStream(bloat, core.NewRawEncoder())
+			require.NoError(t, err)
+			names := core.MakeDict()
+			names.Set("Data", stream)
+			require.NoError(t, w.SetNamedDestinations(core.MakeIndirectObject(names)))
+			ocProperties := core.MakeDict()
+			ocProperties.Set("OCGs", core.MakeArray(core.MakeIndirectObject(core.MakeDict())))
+			require.NoError(t, w.SetOCProperties(core.MakeIndirectObject(ocProperties)))
+		}
+		dests := core.MakeDict()
+		dests.Set("Names", core.MakeArray(core.MakeString("first"),
+			core.MakeArray(w.pages.PdfObject.(*core.PdfObjectDictionary).Get("Kids").(*core.PdfObjectArray).Get(0),
+				core.MakeName("Fit"))))
+		names := core.MakeDict()
+		names.Set("Dests", core.MakeIndirectObject(dests))
+		require.NoError(t, w.SetNamedDestinations(names))
+		require.NoError(t, w.SetOCProperties(core.MakeDict()))
+		if opts.encrypted {
+			require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), &EncryptOptions{
+				Permissions: security.PermOwner,
+				Algorithm:   AES_128bit,
+			}))
+		}
+		w.SetGarbageCollection(opts.gc)
+
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+		return buf.Bytes(), w.GetGarbageCollectionStats()
+	}
+	check := func(data []byte, encrypted bool) *PdfReader {
+		r, err := NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		if encrypted {
+			ok, err := r.Decrypt([]byte("user"))
+			require.NoError(t, err)
+			require.True(t, ok)
+		}
+		n, err := r.GetNumPages()
+		require.NoError(t, err)
+		require.Equal(t, numPages, n)
+		require.NotNil(t, r.AcroForm)
+		require.Len(t, r.AcroForm.AllFields(), len(reader.AcroForm.AllFields()))
+		dest, err := r.GetNamedDestination("first")
+		require.NoError(t, err)
+		require.NotNil(t, dest)
+		for _, page := range r.PageList {
+			contents, err := page.GetAllContentStreams()
+			require.NoError(t, err)
+			require.NotEmpty(t, contents)
+		}
+		return r
+	}
+	trailerSize := func(r *PdfReader) int64 {
+		size, ok := core.GetIntVal(r.parser.GetTrailer().Get("Size"))
+		require.True(t, ok)
+		return int64(size)
+	}
+
+	for _, version := range []int{3, 5} {
+		for _, encrypted := range []bool{false, true} {
+			clean, stats := write(options{version: version, gc: &GarbageCollectionOptions{}, encrypted: encrypted})
+			baseline := stats.Objects
+
+			bloated, stats := write(options{version: version, bloated: true, encrypted: encrypted})
+			require.Equal(t, GarbageCollectionStats{}, stats)
+			if !encrypted {
+				require.Contains(t, string(bloated), "orphaned data")
+			}
+			r := check(bloated, encrypted)
+			bloatedSize := trailerSize(r)
+
+			// The numbers of the removed objects are free.
+			collected, stats := write(options{version: version, gc: &GarbageCollectionOptions{},
+				bloated: true, encrypted: encrypted})
+			require.Equal(t, baseline+orphans, stats.Objects)
+			require.True(t, stats.Bytes > int64(len(bloat)), "%d", stats.Bytes)
+			require.NotContains(t, string(collected), "orphaned data")
+			require.True(t, len(collected) < len(bloated)-len(bloat)/2, "%d >= %d", len(collected), len(bloated))
+			r = check(collected, encrypted)
+			require.Equal(t, bloatedSize, trailerSize(r))
+
+			// The remaining objects are numbered consecutively.
+			compacted, stats := write(options{version: version, gc: &GarbageCollectionOptions{CompactObjectNumbers: true},
+				bloated: true, encrypted: encrypted})
+			require.Equal(t, baseline+orphans, stats.Objects)
+			require.InDelta(t, len(clean), len(compacted), 16)
+			r = check(compacted, encrypted)
+			require.Equal(t, bloatedSize-int64(stats.Objects), trailerSize(r))
+		}
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+var largeFiles = flag.Bool("large-files", false, "run tests writing sparse files larger than 4 GB")
+
+func TestXrefStreamEntries(t *testing.T) {
+	refs := map[int]crossReference{
+		0: {Type: 0, Generation: 0xFFFF},
+		1: {Type: 1, Offset: 15},
+		2: {Type: 2, ObjectNumber: 3, Index: 1},
+	}
+	widths := xrefStreamWidths(refs)
+	require.Equal(t, [3]int{1, 4, 2}, widths)
+	var data []byte
+	for i := 0; i < len(refs); i++ {
+		data = appendXrefStreamEntry(data, refs[i], widths)
+	}
+	require.Equal(t, []byte{
+		0, 0, 0, 0, 0, 0xFF, 0xFF,
+		1, 0, 0, 0, 15, 0, 0,
+		2, 0, 0, 0, 3, 0, 1,
+	}, data)
+
+	// Offsets of large files.
+	refs[3] = crossReference{Type: 1, Offset: 1<<32 + 1}
+	require.Equal(t, [3]int{1, 5, 2}, xrefStreamWidths(refs))
+	refs[4] = crossReference{Type: 1, Offset: 1<<56 + 2, Generation: 1}
+	widths = xrefStreamWidths(refs)
+	require.Equal(t, [3]int{1, 8, 2}, widths)
+	require.Equal(t, []byte{1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0},
+		appendXrefStreamEntry(nil, refs[3], widths))
+	require.Equal(t, []byte{1, 1, 0, 0, 0, 0, 0, 0, 2, 0, 1},
+		appendXrefStreamEntry(nil, refs[4], widths))
+}
+
+// sparseReadSeeker represents a file made of `head`, followed by zeros up to the offset `tail`
+// starts at.
+type sparseReadSeeker struct {
+	head       []byte
+	tail       []byte
+	tailOffset int64
+	pos        int64
+}
+
+// Read implements io.Reader.
+func (r *sparseReadSeeker) Read(p []byte) (int, error) {
+	size := r.tailOffset + int64(len(r.tail))
+	if r.pos >= size {
+		return 0, io.EOF
+	}
+	n := 0
+	for n < len(p) && r.pos < size {
+		switch {
+		case r.pos < int64(len(r.head)):
+			p[n] = r.head[r.pos]
+		case r.pos < r.tailOffset:
+			p[n] = 0
+		default:
+			p[n] = r.tail[r.pos-r.tailOffset]
+		}
+		n++
+		r.pos++
+	}
+	return n, nil
+}
+
+// Seek implements io.Seeker.
+func (r *sparseReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.tailOffset + int64(len(r.tail))
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+// writeLargeTestDoc writes a document whose objects are written at `offset`, the space before
+// them being made of a PDF header followed by null bytes (white space).
+func writeLargeTestDoc(t *testing.T, offset int64, minorVersion int, out io.Writer) []byte {
+	page := NewPdfPage()
+	page.AddContentStreamByString("BT /F1 12 Tf (Large) Tj ET")
+	w := NewPdfWriter()
+	w.SetVersion(1, minorVersion)
+	require.NoError(t, w.AddPage(page))
+	w.writeOffset = offset
+	require.NoError(t, w.Write(out))
+	return []byte("%PDF-1.7\n%âãÏÓ\n")
+}
+
+// checkLargeTestDoc checks the document written by writeLargeTestDoc.
+func checkLargeTestDoc(t *testing.T, rs io.ReadSeeker) *PdfReader {
+	reader, err := NewPdfReader(rs)
+	require.NoError(t, err)
+	require.Empty(t, reader.GetRecoveries())
+	page, err := reader.GetPage(1)
+	require.NoError(t, err)
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	require.Contains(t, contents, "(Large) Tj")
+	return reader
+}
+
+func TestWriteLargeOffsets(t *testing.T) {
+	for _, tc := range []struct {
+		offset       int64
+		minorVersion int
+		width        int64
+	}{
+		// Xref stream with 5 bytes wide offsets.
+		{5 << 30, 5, 5},
+		// Xref table written as an xref stream as the offsets exceed 10 digits.
+		{maxXrefTableOffset, 3, 5},
+		// Xref table.
+		{maxXrefTableOffset - 1<<20, 3, 0},
+	} {
+		var buf bytes.Buffer
+		head := writeLargeTestDoc(t, tc.offset, tc.minorVersion, &buf)
+		reader := checkLargeTestDoc(t, &sparseReadSeeker{head: head, tail: buf.Bytes(), tailOffset: tc.offset})
+
+		trailer, err := reader.GetTrailer()
+		require.NoError(t, err)
+		if tc.width == 0 {
+			require.Nil(t, trailer.Get("W"))
+			require.Contains(t, buf.String(), "\nxref\r\n")
+			continue
+		}
+		widths, ok := core.GetArray(trailer.Get("W"))
+		require.True(t, ok)
+		vals, err := widths.ToInt64Slice()
+		require.NoError(t, err)
+		require.Equal(t, []int64{1, tc.width, 2}, vals)
+	}
+}
+
+// Writes and reads a sparse file larger than 4 GB, with the -large-files flag.
+func TestWriteLargeSparseFile(t *testing.T) {
+	if !*largeFiles {
+		t.Skip("Use -large-files to run")
+	}
+	dir, err := ioutil.TempDir("", "unipdf-large")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	const offset = 5 << 30
+	f, err := os.Create(filepath.Join(dir, "large.pdf"))
+	require.NoError(t, err)
+	defer f.Close()
+	var buf bytes.Buffer
+	head := writeLargeTestDoc(t, offset, 5, &buf)
+	_, err = f.Write(head)
+	require.NoError(t, err)
+	_, err = f.WriteAt(buf.Bytes(), offset)
+	require.NoError(t, err)
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	checkLargeTestDoc(t, f)
+}
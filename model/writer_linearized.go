@@ -0,0 +1,619 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// SetLinearized sets whether the document is written linearized ("fast web
+// view"): the objects of the first page are written at the beginning of the
+// file, along with hint tables locating the objects of the other pages, so
+// that viewers can display the first page before the whole file is
+// downloaded. Linearized documents are written with cross-reference tables
+// and cannot be combined with object streams or incremental updates.
+// See Annex F "Linearized PDF" (p. 683 PDF32000_2008).
+func (w *PdfWriter) SetLinearized(linearized bool) {
+	w.linearized = linearized
+}
+
+// linearizedLayout represents the grouping of the objects of a linearized
+// file, in file order.
+type linearizedLayout struct {
+	// Catalog and document-level objects.
+	doc []core.PdfObject
+	// Objects of each page, the page object first. The objects of the first
+	// page include the objects it shares with other pages.
+	pages [][]core.PdfObject
+	// Objects shared by pages other than the first.
+	shared []core.PdfObject
+	// Remaining objects (page tree, outlines, document information, ...).
+	other []core.PdfObject
+
+	// Objects shared by the first page and other pages.
+	firstShared []core.PdfObject
+	// Shared object identifiers referenced by each page.
+	pageShared [][]int
+}
+
+// linearizedRefs returns the objects referenced by the contents of `obj`.
+// The Parent entries are not followed.
+func linearizedRefs(obj core.PdfObject) []core.PdfObject {
+	var refs []core.PdfObject
+	var walk func(o core.PdfObject)
+	walk = func(o core.PdfObject) {
+		switch t := o.(type) {
+		case *core.PdfIndirectObject, *core.PdfObjectStream:
+			refs = append(refs, t)
+		case *core.PdfObjectDictionary:
+			for _, key := range t.Keys() {
+				if key != "Parent" {
+					walk(t.Get(key))
+				}
+			}
+		case *core.PdfObjectArray:
+			for _, elem := range t.Elements() {
+				walk(elem)
+			}
+		}
+	}
+
+	switch t := obj.(type) {
+	case *core.PdfIndirectObject:
+		walk(t.PdfObject)
+	case *core.PdfObjectStream:
+		walk(t.PdfObjectDictionary)
+	default:
+		walk(obj)
+	}
+	return refs
+}
+
+// linearizedReach returns the objects to write reachable from `start`, in
+// breadth-first order. The traversal does not go through the objects for
+// which `stop` returns true, except `start` itself.
+func (w *PdfWriter) linearizedReach(start core.PdfObject, stop func(core.PdfObject) bool) []core.PdfObject {
+	var objs []core.PdfObject
+	visited := map[core.PdfObject]bool{}
+	visit := func(obj core.PdfObject) {
+		if w.hasObject(obj) && !visited[obj] && !stop(obj) {
+			visited[obj] = true
+			objs = append(objs, obj)
+		}
+	}
+
+	if w.hasObject(start) {
+		visited[start] = true
+		objs = append(objs, start)
+	} else {
+		for _, obj := range linearizedRefs(start) {
+			visit(obj)
+		}
+	}
+	for i := 0; i < len(objs); i++ {
+		for _, obj := range linearizedRefs(objs[i]) {
+			visit(obj)
+		}
+	}
+	return objs
+}
+
+// linearizedPages returns the page objects of the document, in order.
+func (w *PdfWriter) linearizedPages() ([]*core.PdfIndirectObject, error) {
+	catalog, ok := core.GetDict(w.root)
+	if !ok {
+		return nil, errors.New("invalid catalog")
+	}
+
+	var pages []*core.PdfIndirectObject
+	var collect func(node core.PdfObject) error
+	collect = func(node core.PdfObject) error {
+		nodeObj, ok := node.(*core.PdfIndirectObject)
+		if !ok {
+			return errors.New("page tree node not an indirect object")
+		}
+		dict, ok := core.GetDict(nodeObj)
+		if !ok {
+			return ErrTypeCheck
+		}
+		if name, _ := core.GetNameVal(dict.Get("Type")); name == "Page" {
+			pages = append(pages, nodeObj)
+			return nil
+		}
+		kids, _ := core.GetArray(dict.Get("Kids"))
+		if kids == nil {
+			return nil
+		}
+		for _, kid := range kids.Elements() {
+			if err := collect(kid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := collect(catalog.Get("Pages")); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// linearizedLayout groups the objects to write in the parts of a linearized
+// file.
+func (w *PdfWriter) linearizedLayout(pages []*core.PdfIndirectObject) *linearizedLayout {
+	// Page traversals stop at the document-level objects and the page tree
+	// nodes.
+	stop := func(obj core.PdfObject) bool {
+		if obj == w.root || obj == w.infoObj || obj == w.encryptObj {
+			return true
+		}
+		dict, ok := obj.(*core.PdfIndirectObject)
+		if !ok {
+			return false
+		}
+		d, ok := dict.PdfObject.(*core.PdfObjectDictionary)
+		if !ok {
+			return false
+		}
+		name, _ := core.GetNameVal(d.Get("Type"))
+		return name == "Page" || name == "Pages"
+	}
+
+	l := &linearizedLayout{}
+	assigned := map[core.PdfObject]bool{}
+	add := func(list *[]core.PdfObject, obj core.PdfObject) {
+		if !assigned[obj] {
+			assigned[obj] = true
+			*list = append(*list, obj)
+		}
+	}
+
+	add(&l.doc, w.root)
+	if w.encryptObj != nil {
+		add(&l.doc, w.encryptObj)
+	}
+	if catalog, ok := core.GetDict(w.root); ok {
+		for _, key := range []core.PdfObjectName{"ViewerPreferences", "OpenAction"} {
+			if val := catalog.Get(key); val != nil {
+				for _, obj := range w.linearizedReach(val, stop) {
+					add(&l.doc, obj)
+				}
+			}
+		}
+	}
+
+	used := make([][]core.PdfObject, len(pages))
+	count := map[core.PdfObject]int{}
+	for i, page := range pages {
+		used[i] = w.linearizedReach(page, stop)
+		for _, obj := range used[i] {
+			count[obj]++
+		}
+	}
+
+	l.pages = make([][]core.PdfObject, len(pages))
+	for _, obj := range used[0] {
+		add(&l.pages[0], obj)
+	}
+	for i := 1; i < len(pages); i++ {
+		for _, obj := range used[i] {
+			if count[obj] == 1 {
+				add(&l.pages[i], obj)
+			}
+		}
+	}
+	for i := 1; i < len(pages); i++ {
+		for _, obj := range used[i] {
+			add(&l.shared, obj)
+		}
+	}
+	for _, obj := range w.objects {
+		add(&l.other, obj)
+	}
+
+	// The shared objects of the first page are identified first, followed by
+	// those of the shared objects section.
+	sharedIDs := map[core.PdfObject]int{}
+	for _, obj := range l.pages[0] {
+		if count[obj] > 1 {
+			sharedIDs[obj] = len(sharedIDs)
+			l.firstShared = append(l.firstShared, obj)
+		}
+	}
+	for _, obj := range l.shared {
+		sharedIDs[obj] = len(sharedIDs)
+	}
+	l.pageShared = make([][]int, len(pages))
+	for i := 1; i < len(pages); i++ {
+		for _, obj := range used[i] {
+			if id, ok := sharedIDs[obj]; ok {
+				l.pageShared[i] = append(l.pageShared[i], id)
+			}
+		}
+	}
+	return l
+}
+
+// linearizedObjectNumber returns the object number of `obj`.
+func linearizedObjectNumber(obj core.PdfObject) int64 {
+	switch t := obj.(type) {
+	case *core.PdfIndirectObject:
+		return t.ObjectNumber
+	case *core.PdfObjectStream:
+		return t.ObjectNumber
+	}
+	return 0
+}
+
+// linearizedObjectBytes returns the serialized object `obj`.
+func (w *PdfWriter) linearizedObjectBytes(obj core.PdfObject) ([]byte, error) {
+	var buf bytes.Buffer
+	w.writer = bufio.NewWriter(&buf)
+	w.writePos = 0
+	w.writeObject(int(linearizedObjectNumber(obj)), obj)
+	if w.werr == nil {
+		w.werr = w.writer.Flush()
+	}
+	return buf.Bytes(), w.werr
+}
+
+// hintBitWriter packs the fields of hint tables, most significant bits first.
+type hintBitWriter struct {
+	buf   bytes.Buffer
+	cur   byte
+	nbits uint
+}
+
+// write writes the `bits` low-order bits of `val`.
+func (bw *hintBitWriter) write(val int64, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		bw.cur = bw.cur<<1 | byte(val>>uint(i)&1)
+		bw.nbits++
+		if bw.nbits == 8 {
+			bw.buf.WriteByte(bw.cur)
+			bw.cur, bw.nbits = 0, 0
+		}
+	}
+}
+
+// flush pads the current byte with zeros.
+func (bw *hintBitWriter) flush() {
+	if bw.nbits > 0 {
+		bw.write(0, int(8-bw.nbits))
+	}
+}
+
+// hintBits returns the number of bits needed to represent `val`.
+func hintBits(val int64) int {
+	n := 0
+	for ; val > 0; val >>= 1 {
+		n++
+	}
+	return n
+}
+
+// linearizedHints returns the page offset and shared object hint tables of
+// the layout `l`, and the offset of the shared object hint table. The
+// offsets of the objects are given as if the hint stream was not present,
+// `firstPageEnd` being the end of the first page section.
+// See section F.4 "Hint Tables" (p. 691 PDF32000_2008).
+func linearizedHints(l *linearizedLayout, offsets, lengths map[core.PdfObject]int64,
+	firstPageEnd int64) ([]byte, int) {
+	var bw hintBitWriter
+
+	numPages := len(l.pages)
+	nobjs := make([]int64, numPages)
+	plens := make([]int64, numPages)
+	for i, objs := range l.pages {
+		nobjs[i] = int64(len(objs))
+		if i == 0 {
+			plens[i] = firstPageEnd - offsets[objs[0]]
+			continue
+		}
+		for _, obj := range objs {
+			plens[i] += lengths[obj]
+		}
+	}
+	minMax := func(vals []int64) (int64, int64) {
+		min, max := vals[0], vals[0]
+		for _, v := range vals {
+			if v < min {
+				min = v
+			}
+			if v > max {
+				max = v
+			}
+		}
+		return min, max
+	}
+	minObjs, maxObjs := minMax(nobjs)
+	minLen, maxLen := minMax(plens)
+	objsBits := hintBits(maxObjs - minObjs)
+	lenBits := hintBits(maxLen - minLen)
+	var maxShared, maxID int64
+	for _, ids := range l.pageShared {
+		if int64(len(ids)) > maxShared {
+			maxShared = int64(len(ids))
+		}
+		for _, id := range ids {
+			if int64(id) > maxID {
+				maxID = int64(id)
+			}
+		}
+	}
+	sharedBits := hintBits(maxShared)
+	idBits := hintBits(maxID)
+
+	// Page offset hint table header. The content streams are described as
+	// spanning the whole page.
+	bw.write(minObjs, 32)
+	bw.write(offsets[l.pages[0][0]], 32)
+	bw.write(int64(objsBits), 16)
+	bw.write(minLen, 32)
+	bw.write(int64(lenBits), 16)
+	bw.write(0, 32)
+	bw.write(0, 16)
+	bw.write(minLen, 32)
+	bw.write(int64(lenBits), 16)
+	bw.write(int64(sharedBits), 16)
+	bw.write(int64(idBits), 16)
+	bw.write(0, 16)
+	bw.write(1, 16)
+
+	// Each item of the page entries starts on a byte boundary.
+	for i := range l.pages {
+		bw.write(nobjs[i]-minObjs, objsBits)
+	}
+	bw.flush()
+	for i := range l.pages {
+		bw.write(plens[i]-minLen, lenBits)
+	}
+	bw.flush()
+	for _, ids := range l.pageShared {
+		bw.write(int64(len(ids)), sharedBits)
+	}
+	bw.flush()
+	for _, ids := range l.pageShared {
+		for _, id := range ids {
+			bw.write(int64(id), idBits)
+		}
+	}
+	bw.flush()
+	for i := range l.pages {
+		bw.write(plens[i]-minLen, lenBits)
+	}
+	bw.flush()
+
+	sharedOffset := bw.buf.Len()
+
+	// Shared object hint table, with one object per group.
+	groups := append(append([]core.PdfObject{}, l.firstShared...), l.shared...)
+	var minGroup, maxGroup int64
+	if len(groups) > 0 {
+		glens := make([]int64, len(groups))
+		for i, obj := range groups {
+			glens[i] = lengths[obj]
+		}
+		minGroup, maxGroup = minMax(glens)
+	}
+	groupBits := hintBits(maxGroup - minGroup)
+	var firstNum, firstOffset int64
+	if len(l.shared) > 0 {
+		firstNum = linearizedObjectNumber(l.shared[0])
+		firstOffset = offsets[l.shared[0]]
+	}
+	bw.write(firstNum, 32)
+	bw.write(firstOffset, 32)
+	bw.write(int64(len(l.firstShared)), 32)
+	bw.write(int64(len(groups)), 32)
+	bw.write(0, 16)
+	bw.write(minGroup, 32)
+	bw.write(int64(groupBits), 16)
+	for _, obj := range groups {
+		bw.write(lengths[obj]-minGroup, groupBits)
+	}
+	bw.flush()
+	// No MD5 signatures.
+	bw.write(0, len(groups))
+	bw.flush()
+
+	return bw.buf.Bytes(), sharedOffset
+}
+
+// writeLinearized writes out the document as a linearized file.
+func (w *PdfWriter) writeLinearized(writer io.Writer) error {
+	if w.appendMode {
+		return errors.New("linearization not supported for incremental updates")
+	}
+	for _, obj := range w.objects {
+		if _, ok := obj.(*core.PdfObjectStreams); ok {
+			return errors.New("linearization not supported with object streams")
+		}
+	}
+	pages, err := w.linearizedPages()
+	if err != nil {
+		return err
+	}
+	if len(pages) == 0 {
+		return errors.New("linearization requires at least one page")
+	}
+	l := w.linearizedLayout(pages)
+
+	// The objects following the first page section are numbered first, so
+	// that the first page cross-reference section covers the last numbers:
+	// linearization dictionary, document-level objects, first page objects
+	// and hint stream.
+	var rest []core.PdfObject
+	for _, objs := range l.pages[1:] {
+		rest = append(rest, objs...)
+	}
+	rest = append(rest, l.shared...)
+	rest = append(rest, l.other...)
+
+	var num int64
+	setNumber := func(obj core.PdfObject) {
+		num++
+		switch t := obj.(type) {
+		case *core.PdfIndirectObject:
+			t.ObjectNumber, t.GenerationNumber = num, 0
+		case *core.PdfObjectStream:
+			t.ObjectNumber, t.GenerationNumber = num, 0
+		}
+	}
+	for _, obj := range rest {
+		setNumber(obj)
+	}
+	restSize := num + 1
+	linObj := core.MakeIndirectObject(core.MakeDict())
+	setNumber(linObj)
+	first := append(append([]core.PdfObject{}, l.doc...), l.pages[0]...)
+	for _, obj := range first {
+		setNumber(obj)
+	}
+	hintNum := num + 1
+
+	w.crossReferenceMap = make(map[int]crossReference)
+	lengths := map[core.PdfObject]int64{}
+	data := map[core.PdfObject][]byte{}
+	serialize := func(obj core.PdfObject) error {
+		// Encrypt dictionary should not be encrypted.
+		if w.crypter != nil && obj != w.encryptObj {
+			if err := w.crypter.Encrypt(obj, linearizedObjectNumber(obj), 0); err != nil {
+				return err
+			}
+		}
+		b, err := w.linearizedObjectBytes(obj)
+		if err != nil {
+			return err
+		}
+		data[obj] = b
+		lengths[obj] = int64(len(b))
+		return nil
+	}
+	for _, obj := range w.objects {
+		if err := serialize(obj); err != nil {
+			return err
+		}
+	}
+
+	header := fmt.Sprintf("%%PDF-%d.%d\n%%âãÏÓ\n", w.majorVersion, w.minorVersion)
+	linDict := func(fileLen, hintOffset, hintLen, firstPageEnd, mainXrefEntry int64) string {
+		return fmt.Sprintf("%d 0 obj\n<< /Linearized 1 /L %010d /H [%010d %010d] /O %d /E %010d /N %d /T %010d >>\nendobj\n",
+			linObj.ObjectNumber, fileLen, hintOffset, hintLen, linearizedObjectNumber(l.pages[0][0]),
+			firstPageEnd, len(pages), mainXrefEntry)
+	}
+	xrefEntries := func(objs []core.PdfObject, offsets map[core.PdfObject]int64) string {
+		var b strings.Builder
+		for _, obj := range objs {
+			b.WriteString(fmt.Sprintf("%.10d %.5d n\r\n", offsets[obj], 0))
+		}
+		return b.String()
+	}
+
+	// The first page trailer has a fixed width Prev entry, as it precedes the
+	// main cross-reference table.
+	var hintObj *core.PdfObjectStream
+	firstXref := func(offsets map[core.PdfObject]int64, mainXref int64) string {
+		objs := append([]core.PdfObject{linObj}, first...)
+		objs = append(objs, hintObj)
+		trailer := core.MakeDict()
+		trailer.Set("Size", core.MakeInteger(hintNum+1))
+		trailer.Set("Root", w.root)
+		trailer.Set("Info", w.infoObj)
+		if w.crypter != nil {
+			trailer.Set("Encrypt", w.encryptObj)
+		}
+		if w.ids != nil {
+			trailer.Set("ID", w.ids)
+		}
+		return fmt.Sprintf("xref\r\n%d %d\r\n", restSize, len(objs)) + xrefEntries(objs, offsets) +
+			"trailer\n" + strings.TrimSuffix(trailer.WriteString(), ">>") +
+			fmt.Sprintf("/Prev %010d>>\nstartxref\n0\n%%%%EOF\n", mainXref)
+	}
+
+	// Computes the offsets of the objects for a hint stream of `hintLen`
+	// bytes. Returns the end of the first page section and the offset of the
+	// main cross-reference table.
+	offsets := map[core.PdfObject]int64{}
+	layout := func(hintLen int64) (int64, int64) {
+		pos := int64(len(header))
+		offsets[linObj] = pos
+		pos += int64(len(linDict(0, 0, 0, 0, 0)))
+		pos += int64(len(firstXref(offsets, 0)))
+		for _, obj := range l.doc {
+			offsets[obj] = pos
+			pos += lengths[obj]
+		}
+		offsets[hintObj] = pos
+		pos += hintLen
+		for _, obj := range l.pages[0] {
+			offsets[obj] = pos
+			pos += lengths[obj]
+		}
+		firstPageEnd := pos
+		for _, obj := range rest {
+			offsets[obj] = pos
+			pos += lengths[obj]
+		}
+		return firstPageEnd, pos
+	}
+
+	// The offsets in the hint tables are computed as if the hint stream was
+	// not present.
+	hintObj = &core.PdfObjectStream{}
+	firstPageEnd, _ := layout(0)
+	hints, sharedOffset := linearizedHints(l, offsets, lengths, firstPageEnd)
+	hintObj, err = core.MakeStream(hints, core.NewFlateEncoder())
+	if err != nil {
+		return err
+	}
+	hintObj.Set("S", core.MakeInteger(int64(sharedOffset)))
+	setNumber(hintObj)
+	if err := serialize(hintObj); err != nil {
+		return err
+	}
+
+	firstPageEnd, mainXref := layout(lengths[hintObj])
+	mainXrefHeader := fmt.Sprintf("xref\r\n0 %d\r\n", restSize)
+	mainXrefStr := mainXrefHeader + fmt.Sprintf("%.10d %.5d f\r\n", 0, 65535) + xrefEntries(rest, offsets) +
+		fmt.Sprintf("trailer\n<</Size %d>>\nstartxref\n%d\n%%%%EOF\n", restSize, offsets[linObj]+int64(len(linDict(0, 0, 0, 0, 0))))
+	fileLen := mainXref + int64(len(mainXrefStr))
+	if fileLen > maxXrefTableOffset {
+		// The linearization dictionary and the cross-reference tables have fixed width offsets.
+		return fmt.Errorf("linearized file too large (%d bytes): %w", fileLen, core.ErrNotSupported)
+	}
+	// The main cross-reference entry offset is the one of the white-space
+	// preceding its first entry.
+	mainXrefEntry := mainXref + int64(len(mainXrefHeader)) - 1
+
+	w.writePos = 0
+	w.writer = bufio.NewWriter(writer)
+	w.writeString(header)
+	w.writeString(linDict(fileLen, offsets[hintObj], lengths[hintObj], firstPageEnd, mainXrefEntry))
+	w.writeString(firstXref(offsets, mainXref))
+	for _, obj := range l.doc {
+		w.writeBytes(data[obj])
+	}
+	w.writeBytes(data[hintObj])
+	for _, obj := range l.pages[0] {
+		w.writeBytes(data[obj])
+	}
+	for _, obj := range rest {
+		w.writeBytes(data[obj])
+	}
+	w.writeString(mainXrefStr)
+
+	if w.werr == nil {
+		w.werr = w.writer.Flush()
+	}
+	return w.werr
+}
@@ -0,0 +1,138 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+var reLinearizationDict = regexp.MustCompile(`^%PDF-1\.\d\n%\S+\n(\d+) 0 obj\n<< /Linearized 1 /L (\d+) ` +
+	`/H \[(\d+) (\d+)\] /O (\d+) /E (\d+) /N (\d+) /T (\d+) >>`)
+
+func TestWriteLinearized(t *testing.T) {
+	const numPages = 4
+	all := makeImportImage(t, 1)
+	others := makeImportImage(t, 2)
+
+	w := NewPdfWriter()
+	w.SetLinearized(true)
+	for i := 0; i < numPages; i++ {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 200, Ury: 200}
+		require.NoError(t, page.Resources.SetXObjectByName("Im0", all))
+		content := "q 100 0 0 100 0 0 cm /Im0 Do Q"
+		if i > 0 {
+			require.NoError(t, page.Resources.SetXObjectByName("Im1", others))
+			content += " /Im1 Do"
+		}
+		require.NoError(t, page.AddContentStreamByString(fmt.Sprintf("%s %% page %d", content, i+1)))
+		require.NoError(t, w.AddPage(page))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	data := buf.Bytes()
+
+	// Linearization parameters.
+	match := reLinearizationDict.FindSubmatch(data)
+	require.NotNil(t, match, string(data[:200]))
+	params := make([]int64, len(match)-1)
+	for i, m := range match[1:] {
+		params[i], _ = strconv.ParseInt(string(m), 10, 64)
+	}
+	linNum, fileLen, hintOffset, hintLen, firstPageNum, firstPageEnd, pageCount, mainXrefEntry :=
+		params[0], params[1], params[2], params[3], params[4], params[5], params[6], params[7]
+	require.Equal(t, int64(len(data)), fileLen)
+	require.Equal(t, int64(numPages), pageCount)
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	numPagesRead, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, numPages, numPagesRead)
+	for i := 0; i < numPages; i++ {
+		page, err := reader.GetPage(i + 1)
+		require.NoError(t, err)
+		contents, err := page.GetAllContentStreams()
+		require.NoError(t, err)
+		require.Contains(t, contents, fmt.Sprintf("%% page %d", i+1))
+	}
+
+	// The first page object and the objects it uses precede the end of the
+	// first page section, the other pages follow it.
+	xrefs := reader.parser.GetXrefTable().ObjectMap
+	offset := func(obj core.PdfObject) int64 {
+		var num int
+		switch t := obj.(type) {
+		case *core.PdfIndirectObject:
+			num = int(t.ObjectNumber)
+		case *core.PdfObjectStream:
+			num = int(t.ObjectNumber)
+		}
+		xref, ok := xrefs[num]
+		require.True(t, ok, "object %d", num)
+		return xref.Offset
+	}
+	firstPage := reader.PageList[0].GetContainingPdfObject()
+	require.Equal(t, firstPageNum, firstPage.(*core.PdfIndirectObject).ObjectNumber)
+	require.Equal(t, hintOffset+hintLen, offset(firstPage))
+	firstImage, ok := core.GetStream(reader.PageList[0].Resources.XObject.(*core.PdfObjectDictionary).Get("Im0"))
+	require.True(t, ok)
+	require.True(t, offset(firstImage) < firstPageEnd)
+	for _, page := range reader.PageList[1:] {
+		require.True(t, offset(page.GetContainingPdfObject()) >= firstPageEnd)
+	}
+
+	// The first page cross-reference section covers the linearization
+	// dictionary, the main one starts at the T offset.
+	linObj, err := reader.GetIndirectObjectByNumber(int(linNum))
+	require.NoError(t, err)
+	linDict, ok := core.GetDict(linObj)
+	require.True(t, ok)
+	l, ok := core.GetIntVal(linDict.Get("L"))
+	require.True(t, ok)
+	require.Equal(t, fileLen, int64(l))
+	require.Equal(t, int64(bytes.Index(data, []byte(" 0 obj\n"))-len(strconv.Itoa(int(linNum)))), xrefs[int(linNum)].Offset)
+	require.Equal(t, "\n0000000000 65535 f\r\n", string(data[mainXrefEntry:mainXrefEntry+21]))
+
+	// Hint stream.
+	require.Regexp(t, `^\d+ 0 obj\n`, string(data[hintOffset:]))
+	require.Equal(t, "endstream\nendobj\n", string(data[hintOffset+hintLen-17:hintOffset+hintLen]))
+	hintNum, err := strconv.Atoi(regexp.MustCompile(`^\d+`).FindString(string(data[hintOffset:])))
+	require.NoError(t, err)
+	hintStream, err := reader.GetIndirectObjectByNumber(hintNum)
+	require.NoError(t, err)
+	stream, ok := hintStream.(*core.PdfObjectStream)
+	require.True(t, ok)
+	hints, err := core.DecodeStream(stream)
+	require.NoError(t, err)
+	sharedOffset, ok := core.GetIntVal(stream.Get("S"))
+	require.True(t, ok)
+	require.True(t, sharedOffset > 36 && sharedOffset < len(hints))
+
+	// Page offset hint table header: least number of objects of a page and
+	// location of the first page object, as if the hint stream was absent.
+	require.True(t, binary.BigEndian.Uint32(hints[0:4]) >= 2)
+	require.Equal(t, uint32(offset(firstPage)-hintLen), binary.BigEndian.Uint32(hints[4:8]))
+
+	// Shared object hint table: the image drawn on all pages is shared by the
+	// first page, the other one is in the shared objects section.
+	shared := hints[sharedOffset:]
+	sharedImage, ok := core.GetStream(reader.PageList[1].Resources.XObject.(*core.PdfObjectDictionary).Get("Im1"))
+	require.True(t, ok)
+	require.Equal(t, uint32(sharedImage.ObjectNumber), binary.BigEndian.Uint32(shared[0:4]))
+	require.Equal(t, uint32(offset(sharedImage)-hintLen), binary.BigEndian.Uint32(shared[4:8]))
+	require.Equal(t, uint32(1), binary.BigEndian.Uint32(shared[8:12]))
+	require.Equal(t, uint32(2), binary.BigEndian.Uint32(shared[12:16]))
+}
@@ -0,0 +1,64 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// maxObjectStreamObjects is the maximum number of objects packed into an object stream, so that
+// lazy readers do not need to decompress large streams to access a single object.
+const maxObjectStreamObjects = 100
+
+// SetObjectStreams sets whether the objects of the document which are not streams are packed
+// into compressed object streams, along with a cross-reference stream instead of the
+// cross-reference table, in order to reduce the size of the output. By default, object streams
+// are used when writing PDF 1.5 and later documents, except for incremental updates.
+// See 7.5.7 "Object Streams" (p. 45 PDF32000_2008).
+func (w *PdfWriter) SetObjectStreams(enable bool) {
+	w.useObjectStreams = &enable
+}
+
+// packObjectStreams packs the eligible objects of the writer into object streams, which are
+// added after the other objects. Streams, objects with a non-zero generation number, the
+// encryption dictionary, signature dictionaries and objects which are already in object streams
+// are not eligible.
+func (w *PdfWriter) packObjectStreams() {
+	packed := make(map[core.PdfObject]struct{})
+	for _, obj := range w.objects {
+		if objStm, ok := obj.(*core.PdfObjectStreams); ok {
+			for _, o := range objStm.Elements() {
+				packed[o] = struct{}{}
+			}
+		}
+	}
+
+	var streams []core.PdfObject
+	var objStm *core.PdfObjectStreams
+	for _, obj := range w.objects {
+		io, ok := obj.(*core.PdfIndirectObject)
+		if !ok || io.GenerationNumber != 0 || io == w.encryptObj {
+			continue
+		}
+		if _, ok := packed[io]; ok {
+			continue
+		}
+		if _, isSig := io.PdfObject.(*pdfSignDictionary); isSig {
+			// The signature is written at a known offset of the file.
+			continue
+		}
+		if objStm == nil || objStm.Len() == maxObjectStreamObjects {
+			objStm = core.MakeObjectStreams()
+			streams = append(streams, objStm)
+		}
+		objStm.Append(io)
+	}
+
+	for _, objStm := range streams {
+		w.objects = append(w.objects, objStm)
+		w.objectsMap[objStm] = struct{}{}
+	}
+}
@@ -0,0 +1,108 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/core/security"
+)
+
+func TestWriteObjectStreams(t *testing.T) {
+	const title = "Object streams title"
+	f, err := os.Open("./testdata/OoPdfFormExample.pdf")
+	require.NoError(t, err)
+	defer f.Close()
+	reader, err := NewPdfReader(f)
+	require.NoError(t, err)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+
+	write := func(objectStreams bool, encrypt bool) []byte {
+		w := NewPdfWriter()
+		w.SetVersion(1, 5)
+		w.SetObjectStreams(objectStreams)
+		w.GetInfoDict().Set("Title", core.MakeString(title))
+		for _, page := range reader.PageList {
+			require.NoError(t, w.AddPage(page))
+		}
+		require.NoError(t, w.SetForms(reader.AcroForm))
+		if encrypt {
+			require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), &EncryptOptions{
+				Permissions: security.PermOwner,
+				Algorithm:   AES_128bit,
+			}))
+		}
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+		return buf.Bytes()
+	}
+	check := func(data []byte, encrypted bool) {
+		r, err := NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		if encrypted {
+			ok, err := r.Decrypt([]byte("user"))
+			require.NoError(t, err)
+			require.True(t, ok)
+		}
+		n, err := r.GetNumPages()
+		require.NoError(t, err)
+		require.Equal(t, numPages, n)
+		require.NotNil(t, r.AcroForm)
+		require.Len(t, r.AcroForm.AllFields(), len(reader.AcroForm.AllFields()))
+		info, err := r.GetInfoDict()
+		require.NoError(t, err)
+		s, ok := core.GetString(info.Get("Title"))
+		require.True(t, ok)
+		require.Equal(t, title, s.Decoded())
+
+		// The cross-reference stream is written with the PNG Up predictor.
+		decodeParams, ok := core.GetDict(r.parser.GetTrailer().Get("DecodeParms"))
+		require.True(t, ok)
+		predictor, _ := core.GetIntVal(decodeParams.Get("Predictor"))
+		require.Equal(t, 12, predictor)
+
+		// Objects are referenced from object streams by type 2 entries.
+		var inStreams int
+		for _, xref := range r.parser.GetXrefTable().ObjectMap {
+			if xref.XType == core.XrefTypeObjectStream {
+				inStreams++
+				obj, err := r.GetIndirectObjectByNumber(xref.ObjectNumber)
+				require.NoError(t, err)
+				_, isStream := obj.(*core.PdfObjectStream)
+				require.False(t, isStream)
+			}
+		}
+		require.True(t, inStreams > 0)
+	}
+
+	plain := write(false, false)
+	packed := write(true, false)
+	require.NotContains(t, string(plain), "/ObjStm")
+	require.NotContains(t, string(packed), "\nxref\r\n")
+	require.Contains(t, string(packed), "/Type /ObjStm")
+	require.Contains(t, string(packed), "/Type /XRef")
+	require.True(t, len(packed) < len(plain)*9/10, "%d >= %d", len(packed), len(plain))
+	check(packed, false)
+
+	// The objects in object streams are encrypted with their stream.
+	encrypted := write(true, true)
+	require.NotContains(t, string(encrypted), title)
+	check(encrypted, true)
+
+	// Object streams are used by default for PDF 1.5.
+	w := NewPdfWriter()
+	w.SetVersion(1, 5)
+	require.NoError(t, w.AddPage(NewPdfPage()))
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	require.Contains(t, buf.String(), "/Type /ObjStm")
+}
@@ -7,13 +7,33 @@ package model
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"io"
 	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/core/security"
 )
 
+// documentWriter is implemented by the writers whose output the tests read
+// back, such as PdfWriter and PdfAppender.
+type documentWriter interface {
+	Write(w io.Writer) error
+}
+
+// writeAndRead writes the document of `w` and reads it back.
+func writeAndRead(t *testing.T, w documentWriter) *PdfReader {
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	return reader
+}
+
 // Tests loading annotations from file, writing back out and reloading.
 func TestReadWriteAnnotations(t *testing.T) {
 	f, err := os.Open(`testdata/OoPdfFormExample.pdf`)
@@ -127,3 +147,115 @@ func TestWriterErrorHandling(t *testing.T) {
 	err = w.Write(&out)
 	require.Error(t, err)
 }
+
+// cancellingWriter cancels a context on the first write.
+type cancellingWriter struct {
+	bytes.Buffer
+	cancel context.CancelFunc
+}
+
+// Write cancels the context and writes `p` to the buffer.
+func (w *cancellingWriter) Write(p []byte) (int, error) {
+	w.cancel()
+	return w.Buffer.Write(p)
+}
+
+// TestWriteWithContext checks that writing stops when the context is cancelled.
+func TestWriteWithContext(t *testing.T) {
+	w := NewPdfWriter()
+	for i := 0; i < 2000; i++ {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 200, Ury: 200}
+		stream, err := core.MakeStream([]byte("BT /F1 12 Tf 10 10 Td (Page content) Tj ET"), nil)
+		require.NoError(t, err)
+		page.Contents = stream
+		require.NoError(t, w.AddPage(page))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var buf bytes.Buffer
+	err := w.WriteWithContext(ctx, &buf)
+	require.True(t, errors.Is(err, context.Canceled), "err=%v", err)
+	require.Zero(t, buf.Len())
+
+	// Cancelling while writing stops before the remaining objects.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	out := &cancellingWriter{cancel: cancel}
+	err = w.WriteWithContext(ctx, out)
+	require.True(t, errors.Is(err, context.Canceled), "err=%v", err)
+	require.NotContains(t, out.String(), "%%EOF")
+
+	require.NoError(t, w.WriteWithContext(context.Background(), &buf))
+	r, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	numPages, err := r.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 2000, numPages)
+}
+
+// Tests that hexadecimal and literal strings keep their form and contents when a document is
+// read and written back, including binary strings of encrypted documents.
+func TestReadWriteStringForms(t *testing.T) {
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	binary := string(all) + "((\r\n)\\"
+
+	check := func(data []byte, encrypted bool) *PdfReader {
+		reader, err := NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		if encrypted {
+			ok, err := reader.Decrypt([]byte("user"))
+			require.NoError(t, err)
+			require.True(t, ok)
+		}
+		page, err := reader.GetPage(1)
+		require.NoError(t, err)
+		annots, err := page.GetAnnotations()
+		require.NoError(t, err)
+		require.Len(t, annots, 1)
+
+		hexStr, ok := core.GetString(annots[0].Contents)
+		require.True(t, ok)
+		require.True(t, hexStr.IsHex())
+		require.Equal(t, binary, hexStr.Str())
+		litStr, ok := core.GetString(annots[0].NM)
+		require.True(t, ok)
+		require.False(t, litStr.IsHex())
+		require.Equal(t, binary, litStr.Str())
+		return reader
+	}
+	write := func(page *PdfPage, encrypted bool) []byte {
+		w := NewPdfWriter()
+		require.NoError(t, w.AddPage(page))
+		if encrypted {
+			require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), &EncryptOptions{
+				Permissions: security.PermOwner,
+				Algorithm:   AES_128bit,
+			}))
+		}
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+		return buf.Bytes()
+	}
+
+	for _, encrypted := range []bool{false, true} {
+		page := NewPdfPage()
+		annot := NewPdfAnnotationText()
+		annot.Rect = core.MakeArrayFromIntegers([]int{10, 10, 50, 50})
+		annot.Contents = core.MakeHexString(binary)
+		annot.NM = core.MakeString(binary)
+		page.AddAnnotation(annot.PdfAnnotation)
+
+		data := write(page, encrypted)
+		reader := check(data, encrypted)
+
+		// Written back unchanged.
+		page, err := reader.GetPage(1)
+		require.NoError(t, err)
+		check(write(page, encrypted), encrypted)
+	}
+}
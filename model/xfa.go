@@ -0,0 +1,513 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// XFAType represents the type of the XFA (XML Forms Architecture) form of
+// a document.
+type XFAType int
+
+const (
+	// XFATypeNone represents documents without an XFA form.
+	XFATypeNone XFAType = iota
+
+	// XFATypeStatic represents static XFA forms. The layout of static
+	// forms is fixed and their fields are also represented as AcroForm
+	// fields, which are rendered by the PDF viewer.
+	XFATypeStatic
+
+	// XFATypeDynamic represents dynamic XFA forms. Dynamic forms are
+	// rendered from the XFA template and data by the viewer, so the
+	// AcroForm fields, if any, are not displayed.
+	XFATypeDynamic
+)
+
+// String returns a string representation of the XFA type.
+func (t XFAType) String() string {
+	switch t {
+	case XFATypeStatic:
+		return "static"
+	case XFATypeDynamic:
+		return "dynamic"
+	}
+	return "none"
+}
+
+// XFAPacket represents a packet of an XFA form, such as the template, the
+// config or the datasets packet.
+type XFAPacket struct {
+	Name   string
+	Stream *core.PdfObjectStream
+}
+
+// XFAForm represents the XFA resource of an interactive form (XFA entry of
+// the AcroForm dictionary). The resource is either an array of packets or a
+// single stream containing the complete XDP document.
+// See section 12.7.8 "XFA Forms" (pp. 456-457 PDF32000_2008).
+type XFAForm struct {
+	// Packets contains the XFA packets, if the XFA resource is an array.
+	Packets []*XFAPacket
+
+	// stream is the XDP stream, if the XFA resource is a single stream.
+	stream *core.PdfObjectStream
+}
+
+// newXFAFormFromObject loads an XFA form from the XFA entry of the AcroForm
+// dictionary.
+func newXFAFormFromObject(obj core.PdfObject) (*XFAForm, error) {
+	switch t := core.ResolveReference(obj).(type) {
+	case *core.PdfObjectStream:
+		return &XFAForm{stream: t}, nil
+	case *core.PdfObjectArray:
+		if t.Len()%2 != 0 {
+			return nil, errors.New("invalid XFA packet array length")
+		}
+
+		xfa := &XFAForm{}
+		for i := 0; i < t.Len(); i += 2 {
+			name, ok := core.GetString(t.Get(i))
+			if !ok {
+				return nil, fmt.Errorf("invalid XFA packet name type: %T", t.Get(i))
+			}
+			stream, ok := core.GetStream(t.Get(i + 1))
+			if !ok {
+				return nil, fmt.Errorf("invalid XFA packet stream type: %T", t.Get(i+1))
+			}
+			xfa.Packets = append(xfa.Packets, &XFAPacket{Name: name.Str(), Stream: stream})
+		}
+		return xfa, nil
+	}
+
+	return nil, fmt.Errorf("invalid XFA object type: %T", obj)
+}
+
+// GetXFA returns the XFA form of the AcroForm, or nil if the form does not
+// have an XFA resource.
+func (form *PdfAcroForm) GetXFA() (*XFAForm, error) {
+	if form == nil || form.XFA == nil || core.IsNullObject(core.ResolveReference(form.XFA)) {
+		return nil, nil
+	}
+	return newXFAFormFromObject(form.XFA)
+}
+
+// SetXFA sets the XFA resource of the AcroForm. Passing a nil XFA form
+// removes the XFA resource, converting the document to a pure AcroForm.
+func (form *PdfAcroForm) SetXFA(xfa *XFAForm) {
+	if xfa == nil {
+		form.XFA = nil
+		return
+	}
+	form.XFA = xfa.ToPdfObject()
+}
+
+// FillFromXFA fills the AcroForm fields using the values of the leaf
+// elements of the XFA datasets. Fields are matched by their partial names,
+// ignoring the XFA index suffix (e.g. field "FirstName[0]" is filled with
+// the value of the "FirstName" data element). If not nil, `appGen` is used
+// to generate the appearances of the filled fields.
+func (form *PdfAcroForm) FillFromXFA(xfa *XFAForm, appGen FieldAppearanceGenerator) error {
+	if form == nil || xfa == nil {
+		return nil
+	}
+
+	values, err := xfa.DatasetValues()
+	if err != nil {
+		return err
+	}
+
+	objMap := xfaFieldValues{}
+	for _, field := range form.AllFields() {
+		if len(field.Kids) > 0 {
+			continue
+		}
+		partialName := field.PartialName()
+		if val, ok := values[xfaIndexRegexp.ReplaceAllString(partialName, "")]; ok {
+			objMap[partialName] = core.MakeString(val)
+		}
+	}
+
+	return form.fill(objMap, appGen)
+}
+
+// xfaIndexRegexp matches the index suffix of XFA field names.
+var xfaIndexRegexp = regexp.MustCompile(`\[\d+\]$`)
+
+// xfaFieldValues is a field value provider built from XFA dataset values.
+type xfaFieldValues map[string]core.PdfObject
+
+// FieldValues implements interface FieldValueProvider.
+func (v xfaFieldValues) FieldValues() (map[string]core.PdfObject, error) {
+	return v, nil
+}
+
+// GetXFAType returns the type of the XFA form of the document. Documents
+// requesting the viewer to render the XFA form (NeedsRendering catalog
+// entry) or whose XFA configuration requires dynamic rendering are
+// considered dynamic.
+func (r *PdfReader) GetXFAType() (XFAType, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return XFATypeNone, err
+	}
+	xfa, err := r.AcroForm.GetXFA()
+	if err != nil || xfa == nil {
+		return XFATypeNone, err
+	}
+
+	if needsRendering, ok := core.GetBoolVal(r.catalog.Get("NeedsRendering")); ok && needsRendering {
+		return XFATypeDynamic, nil
+	}
+	return xfa.Type()
+}
+
+// Type returns the type of the XFA form based on the dynamicRender setting
+// of the config packet.
+func (xfa *XFAForm) Type() (XFAType, error) {
+	config, err := xfa.GetPacket("config")
+	if err != nil || config == nil {
+		return XFATypeStatic, err
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(config))
+	var inDynamicRender bool
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return XFATypeStatic, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			inDynamicRender = t.Name.Local == "dynamicRender"
+		case xml.EndElement:
+			inDynamicRender = false
+		case xml.CharData:
+			if inDynamicRender && strings.TrimSpace(string(t)) == "required" {
+				return XFATypeDynamic, nil
+			}
+		}
+	}
+
+	return XFATypeStatic, nil
+}
+
+// ToPdfObject returns the PDF representation of the XFA form.
+func (xfa *XFAForm) ToPdfObject() core.PdfObject {
+	if xfa.stream != nil {
+		return xfa.stream
+	}
+
+	arr := core.MakeArray()
+	for _, packet := range xfa.Packets {
+		arr.Append(core.MakeString(packet.Name), packet.Stream)
+	}
+	return arr
+}
+
+// GetPacket returns the decoded XML data of the packet with the specified
+// name (e.g. "template", "config" or "datasets"). If the XFA resource is a
+// single stream, the packet is extracted from the XDP document. Nil is
+// returned if the packet is not found.
+func (xfa *XFAForm) GetPacket(name string) ([]byte, error) {
+	if xfa.stream != nil {
+		data, err := core.DecodeStream(xfa.stream)
+		if err != nil {
+			return nil, err
+		}
+		start, end, _, err := findXDPPacket(data, name)
+		if err != nil || start < 0 {
+			return nil, err
+		}
+		return data[start:end], nil
+	}
+
+	for _, packet := range xfa.Packets {
+		if packet.Name == name {
+			return core.DecodeStream(packet.Stream)
+		}
+	}
+	return nil, nil
+}
+
+// Datasets returns the XML data of the datasets packet, which contains the
+// form data. Nil is returned if the form does not have a datasets packet.
+func (xfa *XFAForm) Datasets() ([]byte, error) {
+	return xfa.GetPacket("datasets")
+}
+
+// SetDatasets replaces the datasets packet of the XFA form with the
+// specified XML data. The datasets packet is added if not present. The
+// other packets are left unchanged.
+func (xfa *XFAForm) SetDatasets(data []byte) error {
+	if xfa.stream != nil {
+		xdp, err := core.DecodeStream(xfa.stream)
+		if err != nil {
+			return err
+		}
+		start, end, rootEnd, err := findXDPPacket(xdp, "datasets")
+		if err != nil {
+			return err
+		}
+		if start < 0 {
+			if rootEnd < 0 {
+				return errors.New("XDP root element not found")
+			}
+			start, end = rootEnd, rootEnd
+		}
+
+		var buf bytes.Buffer
+		buf.Write(xdp[:start])
+		buf.Write(data)
+		buf.Write(xdp[end:])
+		xfa.stream.Stream = buf.Bytes()
+		return core.EncodeStream(xfa.stream)
+	}
+
+	for _, packet := range xfa.Packets {
+		if packet.Name == "datasets" {
+			packet.Stream.Stream = data
+			return core.EncodeStream(packet.Stream)
+		}
+	}
+
+	stream, err := core.MakeStream(data, core.NewFlateEncoder())
+	if err != nil {
+		return err
+	}
+
+	// Insert the datasets packet before the postamble, if any.
+	packet := &XFAPacket{Name: "datasets", Stream: stream}
+	pos := len(xfa.Packets)
+	if pos > 0 && xfa.Packets[pos-1].Name == "postamble" {
+		pos--
+	}
+	xfa.Packets = append(xfa.Packets, nil)
+	copy(xfa.Packets[pos+1:], xfa.Packets[pos:])
+	xfa.Packets[pos] = packet
+	return nil
+}
+
+// DatasetValues returns the values of the leaf elements of the form data
+// (data element of the datasets packet), keyed by their local names. If
+// multiple elements have the same name, the value of the first one is
+// returned.
+func (xfa *XFAForm) DatasetValues() (map[string]string, error) {
+	datasets, err := xfa.Datasets()
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	if datasets == nil {
+		return values, nil
+	}
+
+	leaves, err := findXFADataLeaves(datasets)
+	if err != nil {
+		return nil, err
+	}
+	for _, leaf := range leaves {
+		if _, ok := values[leaf.name]; !ok {
+			values[leaf.name] = leaf.value
+		}
+	}
+	return values, nil
+}
+
+// MergeDatasetValues sets the values of the leaf elements of the form data
+// whose local names are keys of the specified map. All the elements with
+// the same name are updated. The rest of the datasets packet is preserved.
+func (xfa *XFAForm) MergeDatasetValues(values map[string]string) error {
+	datasets, err := xfa.Datasets()
+	if err != nil {
+		return err
+	}
+	if datasets == nil {
+		return errors.New("XFA datasets packet not found")
+	}
+
+	leaves, err := findXFADataLeaves(datasets)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	var pos int64
+	for _, leaf := range leaves {
+		val, ok := values[leaf.name]
+		if !ok {
+			continue
+		}
+
+		var escaped bytes.Buffer
+		if err := xml.EscapeText(&escaped, []byte(val)); err != nil {
+			return err
+		}
+
+		if leaf.contentStart == leaf.end {
+			// Expand self-closing element.
+			startTag := bytes.TrimRight(datasets[leaf.start:leaf.end-2], " \t\r\n")
+			buf.Write(datasets[pos:leaf.start])
+			buf.Write(startTag)
+			buf.WriteString(">")
+			buf.Write(escaped.Bytes())
+			buf.WriteString("</" + leaf.rawName + ">")
+		} else {
+			buf.Write(datasets[pos:leaf.contentStart])
+			buf.Write(escaped.Bytes())
+			buf.Write(datasets[leaf.contentEnd:leaf.end])
+		}
+		pos = leaf.end
+	}
+	buf.Write(datasets[pos:])
+
+	return xfa.SetDatasets(buf.Bytes())
+}
+
+// xfaDataLeaf represents a leaf element of the XFA form data.
+type xfaDataLeaf struct {
+	name    string
+	rawName string
+	value   string
+
+	// Byte offsets of the element and of its content.
+	start, contentStart, contentEnd, end int64
+}
+
+// findXFADataLeaves returns the leaf elements contained by the data element
+// of the specified datasets packet, in document order.
+func findXFADataLeaves(datasets []byte) ([]*xfaDataLeaf, error) {
+	type element struct {
+		leaf        *xfaDataLeaf
+		hasChildren bool
+		value       bytes.Buffer
+	}
+
+	var leaves []*xfaDataLeaf
+	var stack []*element
+	dataDepth := -1
+
+	decoder := xml.NewDecoder(bytes.NewReader(datasets))
+	for {
+		offset := decoder.InputOffset()
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(stack) > 0 {
+				stack[len(stack)-1].hasChildren = true
+			}
+			if dataDepth < 0 && t.Name.Local == "data" {
+				dataDepth = len(stack)
+			}
+			stack = append(stack, &element{leaf: &xfaDataLeaf{
+				name:         t.Name.Local,
+				rawName:      xmlRawName(datasets[offset:]),
+				start:        offset,
+				contentStart: decoder.InputOffset(),
+			}})
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].value.Write(t)
+			}
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, errors.New("invalid XFA datasets structure")
+			}
+			elem := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			if dataDepth >= 0 && len(stack) > dataDepth && !elem.hasChildren {
+				elem.leaf.contentEnd = offset
+				elem.leaf.end = decoder.InputOffset()
+				elem.leaf.value = elem.value.String()
+				if elem.leaf.end == elem.leaf.contentStart {
+					elem.leaf.contentEnd = elem.leaf.end
+				}
+				leaves = append(leaves, elem.leaf)
+			}
+			if len(stack) == dataDepth {
+				dataDepth = -1
+			}
+		}
+	}
+
+	return leaves, nil
+}
+
+// findXDPPacket returns the byte offsets of the packet with the specified
+// local name in the XDP document. The start offset is -1 if the packet is
+// not found. The offset of the closing tag of the root element is also
+// returned, which is -1 if not found.
+func findXDPPacket(xdp []byte, name string) (start, end, rootEnd int64, err error) {
+	start, end, rootEnd = -1, -1, -1
+
+	decoder := xml.NewDecoder(bytes.NewReader(xdp))
+	depth := 0
+	for {
+		offset := decoder.InputOffset()
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return -1, -1, -1, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth == 1 && start < 0 && t.Name.Local == name {
+				start = offset
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+			if depth == 1 && start >= 0 && end < 0 {
+				end = decoder.InputOffset()
+			}
+			if depth == 0 {
+				rootEnd = offset
+			}
+		}
+	}
+
+	if start >= 0 && end < 0 {
+		return -1, -1, -1, fmt.Errorf("unterminated XDP packet %s", name)
+	}
+	return start, end, rootEnd, nil
+}
+
+// xmlRawName returns the qualified name of the element starting at the
+// beginning of the specified data, as it appears in the data.
+func xmlRawName(data []byte) string {
+	if len(data) == 0 || data[0] != '<' {
+		return ""
+	}
+	end := bytes.IndexAny(data[1:], " \t\r\n/>")
+	if end < 0 {
+		return ""
+	}
+	return string(data[1 : end+1])
+}
@@ -0,0 +1,183 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+var testXFAPackets = []struct {
+	name string
+	data string
+}{
+	{"preamble", `<xdp:xdp xmlns:xdp="http://ns.adobe.com/xdp/">`},
+	{"config", `<config xmlns="http://www.xfa.org/schema/xci/2.8/"><present><pdf><dynamicRender>forbidden</dynamicRender></pdf></present></config>`},
+	{"template", `<template xmlns="http://www.xfa.org/schema/xfa-template/2.8/"><subform name="form1"><field name="FirstName"/><field name="LastName"/></subform></template>`},
+	{"datasets", `<xfa:datasets xmlns:xfa="http://www.xfa.org/schema/xfa-data/1.0/"><xfa:data><form1><FirstName>John</FirstName><LastName/><Notes>a &amp; b</Notes></form1></xfa:data></xfa:datasets>`},
+	{"postamble", `</xdp:xdp>`},
+}
+
+// newTestXFAForm returns a static XFA form containing the test packets.
+func newTestXFAForm(t *testing.T) *XFAForm {
+	xfa := &XFAForm{}
+	for _, p := range testXFAPackets {
+		stream, err := core.MakeStream([]byte(p.data), core.NewFlateEncoder())
+		require.NoError(t, err)
+		xfa.Packets = append(xfa.Packets, &XFAPacket{Name: p.name, Stream: stream})
+	}
+	return xfa
+}
+
+// newTestTextField returns a text field with the specified name.
+func newTestTextField(name string) *PdfField {
+	text := &PdfFieldText{}
+	text.PdfField = NewPdfField()
+	text.PdfField.SetContext(text)
+	text.T = core.MakeString(name)
+	return text.PdfField
+}
+
+func TestXFADatasetsRoundTrip(t *testing.T) {
+	// Write a document with a static XFA form.
+	acroForm := NewPdfAcroForm()
+	*acroForm.Fields = append(*acroForm.Fields,
+		newTestTextField("FirstName[0]"),
+		newTestTextField("LastName[0]"),
+	)
+	acroForm.SetXFA(newTestXFAForm(t))
+
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(NewPdfPage()))
+	require.NoError(t, w.SetForms(acroForm))
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, w.Write(buf))
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	xfaType, err := reader.GetXFAType()
+	require.NoError(t, err)
+	require.Equal(t, XFATypeStatic, xfaType)
+
+	xfa, err := reader.AcroForm.GetXFA()
+	require.NoError(t, err)
+	require.Len(t, xfa.Packets, len(testXFAPackets))
+	original := map[string][]byte{}
+	for _, packet := range xfa.Packets {
+		original[packet.Name] = append([]byte{}, packet.Stream.Stream...)
+	}
+
+	datasets, err := xfa.Datasets()
+	require.NoError(t, err)
+	require.Equal(t, testXFAPackets[3].data, string(datasets))
+
+	values, err := xfa.DatasetValues()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"FirstName": "John",
+		"LastName":  "",
+		"Notes":     "a & b",
+	}, values)
+
+	// Update the datasets and sync the AcroForm fields.
+	require.NoError(t, xfa.MergeDatasetValues(map[string]string{
+		"LastName": "Smith <Jr>",
+		"Notes":    "c",
+		"Missing":  "ignored",
+	}))
+	require.NoError(t, reader.AcroForm.FillFromXFA(xfa, nil))
+	reader.AcroForm.SetXFA(xfa)
+
+	appender, err := NewPdfAppender(reader)
+	require.NoError(t, err)
+	appender.ReplaceAcroForm(reader.AcroForm)
+	buf = bytes.NewBuffer(nil)
+	require.NoError(t, appender.Write(buf))
+
+	reader, err = NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	xfa, err = reader.AcroForm.GetXFA()
+	require.NoError(t, err)
+	require.Len(t, xfa.Packets, len(testXFAPackets))
+
+	// The other packets are preserved byte for byte.
+	for _, packet := range xfa.Packets {
+		if packet.Name != "datasets" {
+			require.Equal(t, original[packet.Name], packet.Stream.Stream, packet.Name)
+		}
+	}
+
+	datasets, err = xfa.Datasets()
+	require.NoError(t, err)
+	require.Equal(t, `<xfa:datasets xmlns:xfa="http://www.xfa.org/schema/xfa-data/1.0/"><xfa:data><form1><FirstName>John</FirstName><LastName>Smith &lt;Jr&gt;</LastName><Notes>c</Notes></form1></xfa:data></xfa:datasets>`, string(datasets))
+
+	fieldValues := map[string]string{}
+	for _, field := range reader.AcroForm.AllFields() {
+		if str, ok := core.GetString(field.V); ok {
+			fieldValues[field.PartialName()] = str.Decoded()
+		}
+	}
+	require.Equal(t, map[string]string{
+		"FirstName[0]": "John",
+		"LastName[0]":  "Smith <Jr>",
+	}, fieldValues)
+}
+
+func TestXFASingleStream(t *testing.T) {
+	var xdp bytes.Buffer
+	xdp.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	for _, p := range testXFAPackets {
+		if p.name != "datasets" {
+			xdp.WriteString(p.data + "\n")
+		}
+	}
+
+	stream, err := core.MakeStream(xdp.Bytes(), core.NewFlateEncoder())
+	require.NoError(t, err)
+	xfa, err := newXFAFormFromObject(stream)
+	require.NoError(t, err)
+
+	datasets, err := xfa.Datasets()
+	require.NoError(t, err)
+	require.Nil(t, datasets)
+	require.Error(t, xfa.MergeDatasetValues(map[string]string{"FirstName": "Jane"}))
+
+	template, err := xfa.GetPacket("template")
+	require.NoError(t, err)
+	require.Equal(t, testXFAPackets[2].data, string(template))
+
+	// Add the datasets packet before the closing tag of the XDP document.
+	require.NoError(t, xfa.SetDatasets([]byte(testXFAPackets[3].data)))
+	require.NoError(t, xfa.MergeDatasetValues(map[string]string{"FirstName": "Jane"}))
+
+	data, err := core.DecodeStream(stream)
+	require.NoError(t, err)
+	prefix := bytes.TrimSuffix(xdp.Bytes(), []byte("</xdp:xdp>\n"))
+	require.True(t, bytes.HasPrefix(data, prefix))
+	require.True(t, bytes.HasSuffix(data, []byte("</xfa:datasets></xdp:xdp>\n")))
+
+	values, err := xfa.DatasetValues()
+	require.NoError(t, err)
+	require.Equal(t, "Jane", values["FirstName"])
+
+	// Dynamic rendering.
+	xfaType, err := xfa.Type()
+	require.NoError(t, err)
+	require.Equal(t, XFATypeStatic, xfaType)
+
+	config := bytes.Replace(data, []byte("forbidden"), []byte("required"), 1)
+	stream, err = core.MakeStream(config, core.NewFlateEncoder())
+	require.NoError(t, err)
+	xfa, err = newXFAFormFromObject(stream)
+	require.NoError(t, err)
+	xfaType, err = xfa.Type()
+	require.NoError(t, err)
+	require.Equal(t, XFATypeDynamic, xfaType)
+}
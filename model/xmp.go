@@ -0,0 +1,832 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// XMP namespaces.
+const (
+	xmpNamespaceRDF    = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xmpNamespaceXML    = "http://www.w3.org/XML/1998/namespace"
+	xmpNamespaceDC     = "http://purl.org/dc/elements/1.1/"
+	xmpNamespaceXMP    = "http://ns.adobe.com/xap/1.0/"
+	xmpNamespacePDF    = "http://ns.adobe.com/pdf/1.3/"
+	xmpNamespaceXMPMM  = "http://ns.adobe.com/xap/1.0/mm/"
+	xmpNamespacePDFAID = "http://www.aiim.org/pdfa/ns/id/"
+)
+
+// xmpPacketPadding is the amount of whitespace padding the serialized XMP
+// packets, allowing to edit them in place.
+const xmpPacketPadding = 2048
+
+// XMPMetadata represents the XMP metadata of a document (catalog Metadata
+// stream). The properties of the common schemas are exposed as fields, while
+// the other properties of the packet are preserved when serializing it.
+// Unset fields correspond to missing properties.
+// See section 14.3.2 "Metadata Streams" (p. 556 PDF32000_2008).
+type XMPMetadata struct {
+	Title       string    // dc:title (x-default language).
+	Creators    []string  // dc:creator.
+	Description string    // dc:description (x-default language).
+	Keywords    string    // pdf:Keywords.
+	Producer    string    // pdf:Producer.
+	CreatorTool string    // xmp:CreatorTool.
+	CreateDate  time.Time // xmp:CreateDate.
+	ModifyDate  time.Time // xmp:ModifyDate.
+	DocumentID  string    // xmpMM:DocumentID.
+	InstanceID  string    // xmpMM:InstanceID.
+
+	// PDFAPart and PDFAConformance are the PDF/A identification properties
+	// (pdfaid:part and pdfaid:conformance), e.g. 2 and "B" for PDF/A-2b.
+	PDFAPart        int
+	PDFAConformance string
+
+	root   *xmlNode     // Root element of the packet.
+	parsed *XMPMetadata // Values of the properties when parsed.
+}
+
+// xmpValueType represents the type of the value of an XMP property.
+type xmpValueType int
+
+const (
+	xmpText xmpValueType = iota // Simple text value.
+	xmpAlt                      // Language alternative (x-default only).
+	xmpSeq                      // Ordered array.
+)
+
+// xmpProperty describes an XMP property mapped to a field of XMPMetadata.
+type xmpProperty struct {
+	ns     string
+	prefix string // Prefix used when declaring the namespace.
+	name   string
+	typ    xmpValueType
+	get    func(m *XMPMetadata) []string
+	set    func(m *XMPMetadata, values []string)
+}
+
+// xmpTextProperty returns the property `name` with the text value `field`.
+func xmpTextProperty(ns, prefix, name string, typ xmpValueType, field func(m *XMPMetadata) *string) xmpProperty {
+	return xmpProperty{
+		ns: ns, prefix: prefix, name: name, typ: typ,
+		get: func(m *XMPMetadata) []string {
+			if s := *field(m); s != "" {
+				return []string{s}
+			}
+			return nil
+		},
+		set: func(m *XMPMetadata, values []string) {
+			if len(values) > 0 {
+				*field(m) = values[0]
+			}
+		},
+	}
+}
+
+// xmpDateProperty returns the property `name` with the date value `field`.
+func xmpDateProperty(name string, field func(m *XMPMetadata) *time.Time) xmpProperty {
+	return xmpProperty{
+		ns: xmpNamespaceXMP, prefix: "xmp", name: name, typ: xmpText,
+		get: func(m *XMPMetadata) []string {
+			if t := *field(m); !t.IsZero() {
+				return []string{t.Format(time.RFC3339)}
+			}
+			return nil
+		},
+		set: func(m *XMPMetadata, values []string) {
+			if len(values) == 0 {
+				return
+			}
+			t, err := parseXMPDate(values[0])
+			if err != nil {
+				common.Log.Debug("WARN: invalid XMP date %s: %v", name, err)
+				return
+			}
+			*field(m) = t
+		},
+	}
+}
+
+// xmpProperties are the XMP properties mapped to the fields of XMPMetadata.
+var xmpProperties = []xmpProperty{
+	xmpTextProperty(xmpNamespaceDC, "dc", "title", xmpAlt, func(m *XMPMetadata) *string { return &m.Title }),
+	{
+		ns: xmpNamespaceDC, prefix: "dc", name: "creator", typ: xmpSeq,
+		get: func(m *XMPMetadata) []string { return m.Creators },
+		set: func(m *XMPMetadata, values []string) { m.Creators = values },
+	},
+	xmpTextProperty(xmpNamespaceDC, "dc", "description", xmpAlt, func(m *XMPMetadata) *string { return &m.Description }),
+	xmpTextProperty(xmpNamespacePDF, "pdf", "Keywords", xmpText, func(m *XMPMetadata) *string { return &m.Keywords }),
+	xmpTextProperty(xmpNamespacePDF, "pdf", "Producer", xmpText, func(m *XMPMetadata) *string { return &m.Producer }),
+	xmpTextProperty(xmpNamespaceXMP, "xmp", "CreatorTool", xmpText, func(m *XMPMetadata) *string { return &m.CreatorTool }),
+	xmpDateProperty("CreateDate", func(m *XMPMetadata) *time.Time { return &m.CreateDate }),
+	xmpDateProperty("ModifyDate", func(m *XMPMetadata) *time.Time { return &m.ModifyDate }),
+	xmpTextProperty(xmpNamespaceXMPMM, "xmpMM", "DocumentID", xmpText, func(m *XMPMetadata) *string { return &m.DocumentID }),
+	xmpTextProperty(xmpNamespaceXMPMM, "xmpMM", "InstanceID", xmpText, func(m *XMPMetadata) *string { return &m.InstanceID }),
+	{
+		ns: xmpNamespacePDFAID, prefix: "pdfaid", name: "part", typ: xmpText,
+		get: func(m *XMPMetadata) []string {
+			if m.PDFAPart > 0 {
+				return []string{strconv.Itoa(m.PDFAPart)}
+			}
+			return nil
+		},
+		set: func(m *XMPMetadata, values []string) {
+			if len(values) > 0 {
+				m.PDFAPart, _ = strconv.Atoi(values[0])
+			}
+		},
+	},
+	xmpTextProperty(xmpNamespacePDFAID, "pdfaid", "conformance", xmpText, func(m *XMPMetadata) *string { return &m.PDFAConformance }),
+}
+
+// emptyXMPPacket is the packet of new XMP metadata.
+const emptyXMPPacket = `<x:xmpmeta xmlns:x="adobe:ns:meta/">` +
+	`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+	`<rdf:Description rdf:about=""/>` +
+	`</rdf:RDF></x:xmpmeta>`
+
+// NewXMPMetadata returns new empty XMP metadata.
+func NewXMPMetadata() *XMPMetadata {
+	m, err := NewXMPMetadataFromBytes([]byte(emptyXMPPacket))
+	if err != nil {
+		// Should never happen.
+		common.Log.Debug("ERROR: invalid empty XMP packet: %v", err)
+		return &XMPMetadata{}
+	}
+	return m
+}
+
+// NewXMPMetadataFromBytes parses the XMP packet `data`.
+func NewXMPMetadataFromBytes(data []byte) (*XMPMetadata, error) {
+	root, err := parseXMLNode(data)
+	if err != nil {
+		return nil, err
+	}
+	if findXMPNode(root, xmpNamespaceRDF, "RDF") == nil {
+		return nil, errors.New("XMP packet without rdf:RDF element")
+	}
+
+	m := &XMPMetadata{root: root}
+	for _, prop := range xmpProperties {
+		if values, ok := m.getProperty(prop); ok {
+			prop.set(m, values)
+		}
+	}
+	m.parsed = m.snapshot()
+	return m, nil
+}
+
+// snapshot returns a copy of the values of the properties of `m`.
+func (m *XMPMetadata) snapshot() *XMPMetadata {
+	c := &XMPMetadata{}
+	for _, prop := range xmpProperties {
+		prop.set(c, append([]string(nil), prop.get(m)...))
+	}
+	return c
+}
+
+// Bytes returns the XMP packet, including the xpacket wrapper and padding.
+// Only the properties of the fields modified since parsing are rewritten,
+// the other content of the packet is preserved.
+func (m *XMPMetadata) Bytes() ([]byte, error) {
+	if m.root == nil {
+		return nil, errors.New("XMP metadata not initialized")
+	}
+	for _, prop := range xmpProperties {
+		values := prop.get(m)
+		if m.parsed != nil && reflect.DeepEqual(values, prop.get(m.parsed)) {
+			continue
+		}
+		m.setProperty(prop, values)
+	}
+	m.parsed = m.snapshot()
+
+	var buf bytes.Buffer
+	buf.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	m.root.write(&buf)
+	buf.WriteString("\n")
+	for i := 0; i < xmpPacketPadding/100; i++ {
+		buf.WriteString(strings.Repeat(" ", 99))
+		buf.WriteString("\n")
+	}
+	buf.WriteString("<?xpacket end=\"w\"?>")
+	return buf.Bytes(), nil
+}
+
+// ToPdfObject returns the metadata stream of the packet. The stream is not
+// compressed, so that the metadata is readable by tools not supporting PDF.
+func (m *XMPMetadata) ToPdfObject() (*core.PdfObjectStream, error) {
+	data, err := m.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	stream, err := core.MakeStream(data, core.NewRawEncoder())
+	if err != nil {
+		return nil, err
+	}
+	stream.Set("Type", core.MakeName("Metadata"))
+	stream.Set("Subtype", core.MakeName("XML"))
+	return stream, nil
+}
+
+// SyncFromInfo sets the properties of `m` from the corresponding entries of
+// the document information dictionary `info`. The properties of missing
+// entries are left unchanged.
+func (m *XMPMetadata) SyncFromInfo(info *core.PdfObjectDictionary) {
+	if info == nil {
+		return
+	}
+	text := func(key core.PdfObjectName, field *string) {
+		if s, ok := core.GetString(info.Get(key)); ok {
+			*field = s.Decoded()
+		}
+	}
+	date := func(key core.PdfObjectName, field *time.Time) {
+		if t, err := getDate(info.Get(key)); err == nil && !t.IsZero() {
+			*field = t
+		}
+	}
+
+	text("Title", &m.Title)
+	if s, ok := core.GetString(info.Get("Author")); ok {
+		m.Creators = []string{s.Decoded()}
+	}
+	text("Subject", &m.Description)
+	text("Keywords", &m.Keywords)
+	text("Creator", &m.CreatorTool)
+	text("Producer", &m.Producer)
+	date("CreationDate", &m.CreateDate)
+	date("ModDate", &m.ModifyDate)
+}
+
+// SyncToInfo sets the entries of the document information dictionary `info`
+// from the corresponding properties of `m`. The entries of unset properties
+// are left unchanged.
+func (m *XMPMetadata) SyncToInfo(info *core.PdfObjectDictionary) {
+	if info == nil {
+		return
+	}
+	text := func(key core.PdfObjectName, value string) {
+		if value != "" {
+			info.Set(key, makeInfoString(value))
+		}
+	}
+	date := func(key core.PdfObjectName, value time.Time) {
+		if value.IsZero() {
+			return
+		}
+		if d, err := NewPdfDateFromTime(value); err == nil {
+			info.Set(key, d.ToPdfObject())
+		}
+	}
+
+	text("Title", m.Title)
+	text("Author", strings.Join(m.Creators, ", "))
+	text("Subject", m.Description)
+	text("Keywords", m.Keywords)
+	text("Creator", m.CreatorTool)
+	text("Producer", m.Producer)
+	date("CreationDate", m.CreateDate)
+	date("ModDate", m.ModifyDate)
+}
+
+// makeInfoString returns a document information string, encoded with
+// PDFDocEncoding if `s` is ASCII and UTF-16BE otherwise.
+func makeInfoString(s string) *core.PdfObjectString {
+	for _, r := range s {
+		if r >= utf8.RuneSelf {
+			return core.MakeEncodedString(s, true)
+		}
+	}
+	return core.MakeString(s)
+}
+
+// GetXMPMetadata returns the XMP metadata of the document (catalog Metadata
+// stream), or nil if the document does not have XMP metadata.
+func (r *PdfReader) GetXMPMetadata() (*XMPMetadata, error) {
+	if err := r.checkDecrypted(); err != nil {
+		return nil, err
+	}
+	obj := r.catalog.Get("Metadata")
+	if obj == nil || core.IsNullObject(core.ResolveReference(obj)) {
+		return nil, nil
+	}
+	stream, ok := core.GetStream(obj)
+	if !ok {
+		return nil, errors.New("metadata should be a stream")
+	}
+	data, err := core.DecodeStream(stream)
+	if err != nil {
+		return nil, err
+	}
+	return NewXMPMetadataFromBytes(data)
+}
+
+// GetInfoDict returns the document information dictionary of the document,
+// or nil if the document does not have one.
+func (r *PdfReader) GetInfoDict() (*core.PdfObjectDictionary, error) {
+	trailer, err := r.GetTrailer()
+	if err != nil {
+		return nil, err
+	}
+	info, _ := core.GetDict(trailer.Get("Info"))
+	return info, nil
+}
+
+// GetCreationDate returns the date and time the document was created (CreationDate entry of
+// the document information dictionary). A zero time is returned if the entry is missing, and an
+// error if it is not a valid date.
+func (r *PdfReader) GetCreationDate() (time.Time, error) {
+	return r.getInfoDate("CreationDate")
+}
+
+// GetModDate returns the date and time the document was most recently modified (ModDate entry of
+// the document information dictionary). A zero time is returned if the entry is missing, and an
+// error if it is not a valid date.
+func (r *PdfReader) GetModDate() (time.Time, error) {
+	return r.getInfoDate("ModDate")
+}
+
+// getInfoDate returns the date of the entry `key` of the document information dictionary.
+func (r *PdfReader) getInfoDate(key core.PdfObjectName) (time.Time, error) {
+	info, err := r.GetInfoDict()
+	if err != nil || info == nil {
+		return time.Time{}, err
+	}
+	return getDate(info.Get(key))
+}
+
+// SetXMPMetadata sets the XMP metadata of the document (catalog Metadata
+// stream). Pass nil in order to remove the metadata.
+func (w *PdfWriter) SetXMPMetadata(m *XMPMetadata) error {
+	if m == nil {
+		w.catalog.Remove("Metadata")
+		return nil
+	}
+	stream, err := m.ToPdfObject()
+	if err != nil {
+		return err
+	}
+	w.catalog.Set("Metadata", stream)
+	return w.addObjects(stream)
+}
+
+// GetInfoDict returns the document information dictionary written to the
+// document trailer.
+func (w *PdfWriter) GetInfoDict() *core.PdfObjectDictionary {
+	info, _ := core.GetDict(w.infoObj)
+	return info
+}
+
+// SetCreationDate sets the date and time the document was created (CreationDate entry of the
+// document information dictionary). The entry is removed if `t` is zero.
+func (w *PdfWriter) SetCreationDate(t time.Time) {
+	w.setInfoDate("CreationDate", t)
+}
+
+// SetModDate sets the date and time the document was most recently modified (ModDate entry of the
+// document information dictionary). The entry is removed if `t` is zero.
+func (w *PdfWriter) SetModDate(t time.Time) {
+	w.setInfoDate("ModDate", t)
+}
+
+// setInfoDate sets the entry `key` of the document information dictionary to the date `t`.
+func (w *PdfWriter) setInfoDate(key core.PdfObjectName, t time.Time) {
+	info := w.GetInfoDict()
+	if info == nil {
+		return
+	}
+	if t.IsZero() {
+		info.Remove(key)
+		return
+	}
+	info.Set(key, makeDate(t))
+}
+
+// getProperty returns the values of the property `prop`, and whether the
+// property is present.
+func (m *XMPMetadata) getProperty(prop xmpProperty) ([]string, bool) {
+	rdf := findXMPNode(m.root, xmpNamespaceRDF, "RDF")
+	for _, desc := range rdf.elements() {
+		if desc.ns != xmpNamespaceRDF || desc.local() != "Description" {
+			continue
+		}
+		for _, attr := range desc.attrs {
+			if desc.resolve(attr.Name.Space) == prop.ns && attr.Name.Space != "" && attr.Name.Local == prop.name {
+				return []string{attr.Value}, true
+			}
+		}
+		for _, elem := range desc.elements() {
+			if elem.ns == prop.ns && elem.local() == prop.name {
+				return elem.propertyValues(), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// setProperty replaces the property `prop` with `values`, or removes it if
+// `values` is empty.
+func (m *XMPMetadata) setProperty(prop xmpProperty, values []string) {
+	rdf := findXMPNode(m.root, xmpNamespaceRDF, "RDF")
+
+	var target *xmlNode
+	for _, desc := range rdf.elements() {
+		if desc.ns != xmpNamespaceRDF || desc.local() != "Description" {
+			continue
+		}
+		var attrs []xml.Attr
+		for _, attr := range desc.attrs {
+			if attr.Name.Space != "" && attr.Name.Local == prop.name && desc.resolve(attr.Name.Space) == prop.ns {
+				continue
+			}
+			attrs = append(attrs, attr)
+		}
+		desc.attrs = attrs
+		var nodes []*xmlNode
+		for _, node := range desc.nodes {
+			if node.kind == xmlElement && node.ns == prop.ns && node.local() == prop.name {
+				continue
+			}
+			nodes = append(nodes, node)
+		}
+		desc.nodes = nodes
+
+		if target == nil || (desc.declares(prop.ns) && !target.declares(prop.ns)) {
+			target = desc
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+
+	if target == nil {
+		rdfPrefix := rdf.name.Space
+		target = rdf.appendElement(rdfPrefix, "Description")
+		target.attrs = append(target.attrs, xml.Attr{
+			Name: xml.Name{Space: rdfPrefix, Local: "about"},
+		})
+	}
+	prefix := target.prefixFor(prop.ns, prop.prefix)
+	rdfPrefix := target.prefixFor(xmpNamespaceRDF, "rdf")
+
+	elem := target.appendElement(prefix, prop.name)
+	switch prop.typ {
+	case xmpText:
+		elem.appendText(values[0])
+	case xmpAlt:
+		li := elem.appendElement(rdfPrefix, "Alt").appendElement(rdfPrefix, "li")
+		li.attrs = append(li.attrs, xml.Attr{Name: xml.Name{Space: "xml", Local: "lang"}, Value: "x-default"})
+		li.appendText(values[0])
+	case xmpSeq:
+		seq := elem.appendElement(rdfPrefix, "Seq")
+		for _, value := range values {
+			seq.appendElement(rdfPrefix, "li").appendText(value)
+		}
+	}
+}
+
+// parseXMPDate parses the XMP date `s`, which can omit the least significant
+// components (e.g. 2020-01-02 or 2020-01-02T10:20+01:00).
+func parseXMPDate(s string) (time.Time, error) {
+	layouts := []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04",
+		"2006-01-02",
+		"2006-01",
+		"2006",
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %s", s)
+}
+
+// xmlNodeKind represents the kind of an XML node.
+type xmlNodeKind int
+
+const (
+	xmlElement xmlNodeKind = iota
+	xmlCharData
+	xmlComment
+	xmlProcInst
+	xmlDirective
+)
+
+// xmlNode represents a node of an XML document. The names of the elements
+// and attributes keep their original prefixes, so that the document can be
+// written back as it was parsed.
+type xmlNode struct {
+	kind   xmlNodeKind
+	name   xml.Name // Prefix (Space) and local name.
+	ns     string   // Namespace of the element.
+	attrs  []xml.Attr
+	nodes  []*xmlNode
+	parent *xmlNode
+	data   string // Text, comment, processing instruction or directive.
+}
+
+// parseXMLNode parses the XML document `data` and returns its root element.
+// The content outside of the root element (e.g. the xpacket processing
+// instructions) is discarded.
+func parseXMLNode(data []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var root, current *xmlNode
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var node *xmlNode
+		switch t := token.(type) {
+		case xml.StartElement:
+			node = &xmlNode{kind: xmlElement, name: t.Name, attrs: append([]xml.Attr(nil), t.Attr...)}
+		case xml.EndElement:
+			if current == nil {
+				return nil, errors.New("unexpected XML end element")
+			}
+			current = current.parent
+			continue
+		case xml.CharData:
+			node = &xmlNode{kind: xmlCharData, data: string(t)}
+		case xml.Comment:
+			node = &xmlNode{kind: xmlComment, data: string(t)}
+		case xml.ProcInst:
+			node = &xmlNode{kind: xmlProcInst, name: xml.Name{Local: t.Target}, data: string(t.Inst)}
+		case xml.Directive:
+			node = &xmlNode{kind: xmlDirective, data: string(t)}
+		}
+		if node == nil {
+			continue
+		}
+
+		if current == nil {
+			// Content outside of the root element.
+			if node.kind == xmlElement && root == nil {
+				root = node
+				current = node
+				node.ns = node.resolve(node.name.Space)
+			}
+			continue
+		}
+		node.parent = current
+		current.nodes = append(current.nodes, node)
+		if node.kind == xmlElement {
+			node.ns = node.resolve(node.name.Space)
+			current = node
+		}
+	}
+	if root == nil {
+		return nil, errors.New("XML document without root element")
+	}
+	if current != nil {
+		return nil, errors.New("unterminated XML element")
+	}
+	return root, nil
+}
+
+// findXMPNode returns the first element named `local` in the namespace `ns`
+// in the subtree of `node`, or nil if not found.
+func findXMPNode(node *xmlNode, ns, local string) *xmlNode {
+	if node.kind != xmlElement {
+		return nil
+	}
+	if node.ns == ns && node.local() == local {
+		return node
+	}
+	for _, child := range node.nodes {
+		if found := findXMPNode(child, ns, local); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// local returns the local name of the element.
+func (n *xmlNode) local() string {
+	return n.name.Local
+}
+
+// elements returns the child elements of the element.
+func (n *xmlNode) elements() []*xmlNode {
+	var elems []*xmlNode
+	for _, node := range n.nodes {
+		if node.kind == xmlElement {
+			elems = append(elems, node)
+		}
+	}
+	return elems
+}
+
+// text returns the character data of the element.
+func (n *xmlNode) text() string {
+	var buf bytes.Buffer
+	for _, node := range n.nodes {
+		if node.kind == xmlCharData {
+			buf.WriteString(node.data)
+		}
+	}
+	return buf.String()
+}
+
+// resolve returns the namespace bound to `prefix` in the scope of the
+// element.
+func (n *xmlNode) resolve(prefix string) string {
+	switch prefix {
+	case "xml":
+		return xmpNamespaceXML
+	case "xmlns":
+		return ""
+	}
+	for node := n; node != nil; node = node.parent {
+		for _, attr := range node.attrs {
+			if (prefix != "" && attr.Name.Space == "xmlns" && attr.Name.Local == prefix) ||
+				(prefix == "" && attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+				return attr.Value
+			}
+		}
+	}
+	return ""
+}
+
+// declares returns true if the element declares the namespace `ns`.
+func (n *xmlNode) declares(ns string) bool {
+	for _, attr := range n.attrs {
+		if attr.Name.Space == "xmlns" && attr.Value == ns {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixFor returns a prefix bound to the namespace `ns` in the scope of the
+// element. If there is none, the namespace is declared on the element, using
+// `preferred` as prefix, suffixed with a number if already used.
+func (n *xmlNode) prefixFor(ns, preferred string) string {
+	for node := n; node != nil; node = node.parent {
+		for _, attr := range node.attrs {
+			if attr.Name.Space == "xmlns" && attr.Value == ns && n.resolve(attr.Name.Local) == ns {
+				return attr.Name.Local
+			}
+		}
+	}
+	prefix := preferred
+	for i := 2; n.resolve(prefix) != ""; i++ {
+		prefix = fmt.Sprintf("%s%d", preferred, i)
+	}
+	n.attrs = append(n.attrs, xml.Attr{Name: xml.Name{Space: "xmlns", Local: prefix}, Value: ns})
+	return prefix
+}
+
+// appendElement appends a new element named `prefix`:`local` to the element
+// and returns it.
+func (n *xmlNode) appendElement(prefix, local string) *xmlNode {
+	elem := &xmlNode{kind: xmlElement, name: xml.Name{Space: prefix, Local: local}, parent: n}
+	elem.ns = elem.resolve(prefix)
+	n.nodes = append(n.nodes, elem)
+	return elem
+}
+
+// appendText appends the character data `text` to the element.
+func (n *xmlNode) appendText(text string) {
+	n.nodes = append(n.nodes, &xmlNode{kind: xmlCharData, data: text, parent: n})
+}
+
+// propertyValues returns the values of the XMP property element: the items of
+// arrays (the x-default item of language alternatives) or the text of
+// simple properties.
+func (n *xmlNode) propertyValues() []string {
+	for _, elem := range n.elements() {
+		if elem.ns != xmpNamespaceRDF {
+			continue
+		}
+		var items []*xmlNode
+		for _, li := range elem.elements() {
+			if li.ns == xmpNamespaceRDF && li.local() == "li" {
+				items = append(items, li)
+			}
+		}
+
+		switch elem.local() {
+		case "Alt":
+			for _, li := range items {
+				for _, attr := range li.attrs {
+					if attr.Name.Space == "xml" && attr.Name.Local == "lang" && attr.Value == "x-default" {
+						return []string{li.text()}
+					}
+				}
+			}
+			if len(items) > 0 {
+				return []string{items[0].text()}
+			}
+			return nil
+		case "Seq", "Bag":
+			var values []string
+			for _, li := range items {
+				values = append(values, li.text())
+			}
+			return values
+		}
+	}
+	return []string{strings.TrimSpace(n.text())}
+}
+
+// write writes the node and its subtree to `buf`.
+func (n *xmlNode) write(buf *bytes.Buffer) {
+	switch n.kind {
+	case xmlCharData:
+		writeXMLEscaped(buf, n.data, false)
+	case xmlComment:
+		buf.WriteString("<!--")
+		buf.WriteString(n.data)
+		buf.WriteString("-->")
+	case xmlProcInst:
+		buf.WriteString("<?")
+		buf.WriteString(n.name.Local)
+		if n.data != "" {
+			buf.WriteString(" ")
+			buf.WriteString(n.data)
+		}
+		buf.WriteString("?>")
+	case xmlDirective:
+		buf.WriteString("<!")
+		buf.WriteString(n.data)
+		buf.WriteString(">")
+	case xmlElement:
+		name := xmlQualifiedName(n.name)
+		buf.WriteString("<")
+		buf.WriteString(name)
+		for _, attr := range n.attrs {
+			buf.WriteString(" ")
+			buf.WriteString(xmlQualifiedName(attr.Name))
+			buf.WriteString(`="`)
+			writeXMLEscaped(buf, attr.Value, true)
+			buf.WriteString(`"`)
+		}
+		if len(n.nodes) == 0 {
+			buf.WriteString("/>")
+			return
+		}
+		buf.WriteString(">")
+		for _, node := range n.nodes {
+			node.write(buf)
+		}
+		buf.WriteString("</")
+		buf.WriteString(name)
+		buf.WriteString(">")
+	}
+}
+
+// writeXMLEscaped writes `s` to `buf`, escaping the XML markup characters.
+// Whitespace is written as is, unless in attribute values.
+func writeXMLEscaped(buf *bytes.Buffer, s string, attr bool) {
+	for _, r := range s {
+		switch {
+		case r == '&':
+			buf.WriteString("&amp;")
+		case r == '<':
+			buf.WriteString("&lt;")
+		case r == '>':
+			buf.WriteString("&gt;")
+		case r == '"' && attr:
+			buf.WriteString("&quot;")
+		case (r == '\n' || r == '\r' || r == '\t') && attr:
+			fmt.Fprintf(buf, "&#%d;", r)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
+
+// xmlQualifiedName returns the qualified name `name`, i.e. prefix:local.
+func xmlQualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
@@ -0,0 +1,214 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// testXMPPacket is an XMP packet as written by Acrobat, with a custom
+// property and properties in the abbreviated (attribute) form.
+const testXMPPacket = `<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/" x:xmptk="Adobe XMP Core 5.6-c015 81.157285, 2014/12/12-00:43:15        ">
+   <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+      <rdf:Description rdf:about=""
+            xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+            xmlns:dc="http://purl.org/dc/elements/1.1/"
+            xmlns:xmpMM="http://ns.adobe.com/xap/1.0/mm/"
+            xmlns:pdf="http://ns.adobe.com/pdf/1.3/"
+            xmlns:pdfx="http://ns.adobe.com/pdfx/1.3/"
+            pdf:Producer="Adobe PDF Library 15.0">
+         <xmp:CreateDate>2020-03-04T10:20:30+01:00</xmp:CreateDate>
+         <xmp:ModifyDate>2020-03-05T11:00:00+01:00</xmp:ModifyDate>
+         <xmp:CreatorTool>Adobe InDesign CC 2015 (Macintosh)</xmp:CreatorTool>
+         <dc:format>application/pdf</dc:format>
+         <dc:title>
+            <rdf:Alt>
+               <rdf:li xml:lang="x-default">Annual Report &amp; Accounts</rdf:li>
+               <rdf:li xml:lang="fr-FR">Rapport annuel</rdf:li>
+            </rdf:Alt>
+         </dc:title>
+         <dc:creator>
+            <rdf:Seq>
+               <rdf:li>Alice</rdf:li>
+               <rdf:li>Bob</rdf:li>
+            </rdf:Seq>
+         </dc:creator>
+         <xmpMM:DocumentID>uuid:5a1f9f4c-1d7e-4e4a-9b1b-8d8c1c2f0a11</xmpMM:DocumentID>
+         <xmpMM:InstanceID>uuid:0b6e2d3a-8f2c-4c7a-a0d5-3e6f0e9b7c22</xmpMM:InstanceID>
+         <pdfx:CustomField>Custom &lt;value&gt;</pdfx:CustomField>
+      </rdf:Description>
+      <rdf:Description rdf:about="" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/">
+         <pdfaid:part>2</pdfaid:part>
+         <pdfaid:conformance>B</pdfaid:conformance>
+      </rdf:Description>
+   </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+func TestXMPMetadataParse(t *testing.T) {
+	m, err := NewXMPMetadataFromBytes([]byte(testXMPPacket))
+	require.NoError(t, err)
+	require.Equal(t, "Annual Report & Accounts", m.Title)
+	require.Equal(t, []string{"Alice", "Bob"}, m.Creators)
+	require.Equal(t, "Adobe PDF Library 15.0", m.Producer)
+	require.Equal(t, "Adobe InDesign CC 2015 (Macintosh)", m.CreatorTool)
+	require.True(t, m.CreateDate.Equal(time.Date(2020, 3, 4, 9, 20, 30, 0, time.UTC)))
+	require.True(t, m.ModifyDate.Equal(time.Date(2020, 3, 5, 10, 0, 0, 0, time.UTC)))
+	require.Equal(t, "uuid:5a1f9f4c-1d7e-4e4a-9b1b-8d8c1c2f0a11", m.DocumentID)
+	require.Equal(t, "uuid:0b6e2d3a-8f2c-4c7a-a0d5-3e6f0e9b7c22", m.InstanceID)
+	require.Equal(t, 2, m.PDFAPart)
+	require.Equal(t, "B", m.PDFAConformance)
+	require.Empty(t, m.Description)
+	require.Empty(t, m.Keywords)
+
+	_, err = NewXMPMetadataFromBytes([]byte("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\"/>"))
+	require.Error(t, err)
+	_, err = NewXMPMetadataFromBytes([]byte("<x:xmpmeta"))
+	require.Error(t, err)
+}
+
+func TestXMPMetadataRoundTrip(t *testing.T) {
+	m, err := NewXMPMetadataFromBytes([]byte(testXMPPacket))
+	require.NoError(t, err)
+
+	// The properties of unmodified packets are preserved. Only the whitespace
+	// between the attributes is normalized.
+	data, err := m.Bytes()
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(data, []byte("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>")))
+	require.True(t, bytes.HasSuffix(data, []byte("<?xpacket end=\"w\"?>")))
+	start := strings.Index(testXMPPacket, "<xmp:CreateDate>")
+	end := strings.Index(testXMPPacket, "</x:xmpmeta>") + len("</x:xmpmeta>")
+	require.Contains(t, string(data), testXMPPacket[start:end])
+	require.True(t, len(data) > len(testXMPPacket)+xmpPacketPadding/2)
+
+	m.Title = "Annual Report 2020"
+	m.Producer = "UniPDF"
+	m.Keywords = "report, finance"
+	m.PDFAPart = 0
+	m.PDFAConformance = ""
+	data, err = m.Bytes()
+	require.NoError(t, err)
+	packet := string(data)
+
+	reparsed, err := NewXMPMetadataFromBytes(data)
+	require.NoError(t, err)
+	require.Equal(t, "Annual Report 2020", reparsed.Title)
+	require.Equal(t, "UniPDF", reparsed.Producer)
+	require.Equal(t, "report, finance", reparsed.Keywords)
+	require.Zero(t, reparsed.PDFAPart)
+	require.Empty(t, reparsed.PDFAConformance)
+
+	// The other properties are unchanged.
+	require.Equal(t, m.Creators, reparsed.Creators)
+	require.Equal(t, m.CreatorTool, reparsed.CreatorTool)
+	require.True(t, m.CreateDate.Equal(reparsed.CreateDate))
+	require.Equal(t, m.DocumentID, reparsed.DocumentID)
+	for _, unchanged := range []string{
+		`x:xmptk="Adobe XMP Core 5.6-c015 81.157285, 2014/12/12-00:43:15        "`,
+		"<xmp:CreateDate>2020-03-04T10:20:30+01:00</xmp:CreateDate>",
+		"<dc:format>application/pdf</dc:format>",
+		"<pdfx:CustomField>Custom &lt;value&gt;</pdfx:CustomField>",
+		`<rdf:li xml:lang="x-default">`,
+	} {
+		require.Contains(t, packet, unchanged)
+	}
+	require.NotContains(t, packet, "Rapport annuel")
+	require.NotContains(t, packet, "Adobe PDF Library")
+	require.NotContains(t, packet, "pdfaid:part>")
+	require.Equal(t, 1, strings.Count(packet, "<pdf:Keywords>report, finance</pdf:Keywords>"))
+}
+
+func TestXMPMetadataNew(t *testing.T) {
+	m := NewXMPMetadata()
+	m.Title = "Title"
+	m.Creators = []string{"Alice"}
+	m.PDFAPart = 1
+	m.PDFAConformance = "A"
+	data, err := m.Bytes()
+	require.NoError(t, err)
+	require.Contains(t, string(data), `xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/"`)
+
+	reparsed, err := NewXMPMetadataFromBytes(data)
+	require.NoError(t, err)
+	require.Equal(t, "Title", reparsed.Title)
+	require.Equal(t, []string{"Alice"}, reparsed.Creators)
+	require.Equal(t, 1, reparsed.PDFAPart)
+	require.Equal(t, "A", reparsed.PDFAConformance)
+}
+
+func TestXMPMetadataInfoSync(t *testing.T) {
+	w := NewPdfWriter()
+	page := NewPdfPage()
+	page.MediaBox = &PdfRectangle{Urx: 100, Ury: 100}
+	require.NoError(t, w.AddPage(page))
+
+	// Info dictionary to XMP.
+	info := w.GetInfoDict()
+	require.NotNil(t, info)
+	info.Set("Title", core.MakeEncodedString("Résumé", true))
+	info.Set("Author", core.MakeString("Alice"))
+	info.Remove("Creator")
+	created := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+	date, err := NewPdfDateFromTime(created)
+	require.NoError(t, err)
+	info.Set("CreationDate", date.ToPdfObject())
+
+	m, err := NewXMPMetadataFromBytes([]byte(testXMPPacket))
+	require.NoError(t, err)
+	m.SyncFromInfo(info)
+	require.Equal(t, "Résumé", m.Title)
+	require.Equal(t, []string{"Alice"}, m.Creators)
+	require.True(t, m.CreateDate.Equal(created))
+	require.Equal(t, "Adobe InDesign CC 2015 (Macintosh)", m.CreatorTool)
+
+	// XMP to Info dictionary.
+	m.Producer = "Producer 2"
+	m.ModifyDate = created.Add(time.Hour)
+	m.SyncToInfo(info)
+	require.NoError(t, w.SetXMPMetadata(m))
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	readInfo, err := reader.GetInfoDict()
+	require.NoError(t, err)
+	require.NotNil(t, readInfo)
+	title, ok := core.GetString(readInfo.Get("Title"))
+	require.True(t, ok)
+	require.Equal(t, "Résumé", title.Decoded())
+	producer, ok := core.GetString(readInfo.Get("Producer"))
+	require.True(t, ok)
+	require.Equal(t, "Producer 2", producer.Decoded())
+	modDate, ok := core.GetString(readInfo.Get("ModDate"))
+	require.True(t, ok)
+	d, err := NewPdfDate(modDate.Str())
+	require.NoError(t, err)
+	require.True(t, d.ToGoTime().Equal(created.Add(time.Hour)))
+
+	readXMP, err := reader.GetXMPMetadata()
+	require.NoError(t, err)
+	require.NotNil(t, readXMP)
+	require.Equal(t, "Résumé", readXMP.Title)
+	require.Equal(t, "Producer 2", readXMP.Producer)
+	require.Equal(t, "B", readXMP.PDFAConformance)
+
+	stream, ok := core.GetStream(reader.catalog.Get("Metadata"))
+	require.True(t, ok)
+	subtype, _ := core.GetNameVal(stream.Get("Subtype"))
+	require.Equal(t, "XML", subtype)
+	require.Nil(t, stream.Get("Filter"))
+}
@@ -236,7 +236,9 @@ func NewXObjectImageFromImage(img *Image, cs PdfColorspace, encoder core.StreamE
 
 // UpdateXObjectImageFromImage creates a new XObject Image from an
 // Image object `img` and default masks from xobjIn.
-// The default masks are overriden if img.hasAlpha
+// The default masks are overriden if img.hasAlpha and the alpha channel is
+// not fully opaque. The Decode and Interpolate entries are set from the
+// decode array and the Interpolate flag of `img`.
 // If `encoder` is nil, uses raw encoding (none).
 func UpdateXObjectImageFromImage(xobjIn *XObjectImage, img *Image, cs PdfColorspace,
 	encoder core.StreamEncoder) (*XObjectImage, error) {
@@ -265,6 +267,13 @@ func UpdateXObjectImageFromImage(xobjIn *XObjectImage, img *Image, cs PdfColorsp
 	xobj.Filter = encoder
 	xobj.Stream = encoded
 
+	if img.decode != nil {
+		xobj.Decode = core.MakeArrayFromFloats(img.decode)
+	}
+	if img.Interpolate {
+		xobj.Interpolate = core.MakeBool(true)
+	}
+
 	// Guess colorspace if not explicitly set.
 	if cs == nil {
 		if img.ColorComponents == 1 {
@@ -280,20 +289,38 @@ func UpdateXObjectImageFromImage(xobjIn *XObjectImage, img *Image, cs PdfColorsp
 		xobj.ColorSpace = cs
 	}
 
-	if img.hasAlpha {
+	if img.hasAlpha && !img.isOpaque() {
 		// Add the alpha channel information as a stencil mask (SMask).
 		// Has same width and height as original and stored in same
 		// bits per component (1 component, hence the DeviceGray channel).
+		// The alpha channel is always Flate encoded, as the lossy and
+		// bilevel encoders of the image are not suited to it.
 		smask := NewXObjectImage()
 
-		smask.Filter = encoder
-		encoded, err := encoder.EncodeBytes(img.alphaData)
+		var smaskEncoder *core.FlateEncoder
+		if flate, ok := encoder.(*core.FlateEncoder); ok {
+			// The predictors depend on the number of color components.
+			e := *flate
+			e.Colors = 1
+			if img.hasByteAlpha() {
+				e.BitsPerComponent = 8
+			}
+			smaskEncoder = &e
+		} else {
+			smaskEncoder = core.NewFlateEncoder()
+		}
+		smask.Filter = smaskEncoder
+		encoded, err := smaskEncoder.EncodeBytes(img.alphaData)
 		if err != nil {
 			common.Log.Debug("Error with encoding: %v", err)
 			return nil, err
 		}
 		smask.Stream = encoded
 		smask.BitsPerComponent = xobj.BitsPerComponent
+		if img.hasByteAlpha() {
+			bpc := int64(8)
+			smask.BitsPerComponent = &bpc
+		}
 		smask.Width = &img.Width
 		smask.Height = &img.Height
 		smask.ColorSpace = NewPdfColorspaceDeviceGray()
@@ -412,6 +439,13 @@ func NewXObjectImageFromStream(stream *core.PdfObjectStream) (*XObjectImage, err
 			return nil, err
 		}
 		img.ColorSpace = cs
+	} else if jpx, ok := encoder.(*core.JPXEncoder); ok && jpx.ColorSpace != "" {
+		// The colorspace specified by the JPEG 2000 data is used.
+		cs, err := NewPdfColorspaceFromPdfObject(core.MakeName(string(jpx.ColorSpace)))
+		if err != nil {
+			return nil, err
+		}
+		img.ColorSpace = cs
 	} else {
 		// If not specified, assume gray..
 		common.Log.Debug("XObject Image colorspace not specified - assuming 1 color component")
@@ -425,10 +459,19 @@ func NewXObjectImageFromStream(stream *core.PdfObjectStream) (*XObjectImage, err
 		}
 		iVal := int64(*iObj)
 		img.BitsPerComponent = &iVal
+	} else if jpx, ok := encoder.(*core.JPXEncoder); ok && jpx.BitsPerComponent > 0 {
+		// Optional for JPEG 2000 images.
+		iVal := int64(jpx.BitsPerComponent)
+		img.BitsPerComponent = &iVal
 	}
 
 	img.Intent = dict.Get("Intent")
 	img.ImageMask = dict.Get("ImageMask")
+	if img.BitsPerComponent == nil && img.IsImageMask() {
+		// Optional for stencil masks, which have 1 bit per component.
+		iVal := int64(1)
+		img.BitsPerComponent = &iVal
+	}
 	img.Mask = dict.Get("Mask")
 	img.Decode = dict.Get("Decode")
 	img.Interpolate = dict.Get("Interpolate")
@@ -522,18 +565,40 @@ func (ximg *XObjectImage) ToImage() (*Image, error) {
 	}
 	image.Width = *ximg.Width
 
-	if ximg.BitsPerComponent == nil {
-		return nil, errors.New("bits per component missing")
+	maxPixels := int64(core.DefaultMaxImagePixels)
+	if ximg.primitive != nil {
+		maxPixels = ximg.primitive.GetParser().GetConfig().MaxImagePixels
+	}
+	if err := core.CheckImagePixels(image.Width, image.Height, maxPixels); err != nil {
+		return nil, err
 	}
-	image.BitsPerComponent = *ximg.BitsPerComponent
 
-	image.ColorComponents = ximg.ColorSpace.GetNumComponents()
+	if jpx, ok := ximg.Filter.(*core.JPXEncoder); ok {
+		// The format of the decoded JPEG 2000 data is determined by the encoded data, and may
+		// differ from the one of the image dictionary, e.g. when decoding at a reduced resolution.
+		decoded, err := jpx.DecodeStream(ximg.primitive)
+		if err != nil {
+			return nil, err
+		}
+		image.Data = decoded
+		image.Width = int64(jpx.Width)
+		image.Height = int64(jpx.Height)
+		image.BitsPerComponent = int64(jpx.BitsPerComponent)
+		image.ColorComponents = jpx.ColorComponents
+	} else {
+		if ximg.BitsPerComponent == nil {
+			return nil, errors.New("bits per component missing")
+		}
+		image.BitsPerComponent = *ximg.BitsPerComponent
 
-	decoded, err := core.DecodeStream(ximg.primitive)
-	if err != nil {
-		return nil, err
+		image.ColorComponents = ximg.ColorSpace.GetNumComponents()
+
+		decoded, err := core.DecodeStream(ximg.primitive)
+		if err != nil {
+			return nil, err
+		}
+		image.Data = decoded
 	}
-	image.Data = decoded
 
 	if ximg.Decode != nil {
 		darr, ok := ximg.Decode.(*core.PdfObjectArray)
@@ -546,7 +611,11 @@ func (ximg *XObjectImage) ToImage() (*Image, error) {
 			return nil, err
 		}
 		image.decode = decode
+	} else if dct, ok := ximg.Filter.(*core.DCTEncoder); ok && dct.AdobeInverted && image.ColorComponents == 4 {
+		// The CMYK samples of the JPEG images with the Adobe marker are inverted.
+		image.decode = []float64{1, 0, 1, 0, 1, 0, 1, 0}
 	}
+	image.Interpolate, _ = core.GetBoolVal(ximg.Interpolate)
 
 	return image, nil
 }
@@ -577,7 +646,8 @@ func (ximg *XObjectImage) ToPdfObject() core.PdfObject {
 		dict.Set("BitsPerComponent", core.MakeInteger(*(ximg.BitsPerComponent)))
 	}
 
-	if ximg.ColorSpace != nil {
+	if ximg.ColorSpace != nil && !ximg.IsImageMask() {
+		// The stencil masks do not have a colorspace.
 		dict.SetIfNotNil("ColorSpace", ximg.ColorSpace.ToPdfObject())
 	}
 	dict.SetIfNotNil("Intent", ximg.Intent)
@@ -0,0 +1,598 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package redactor
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/textencoding"
+	"github.com/unidoc/unipdf/v3/internal/tjarray"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// maxFormDepth is the maximum nesting level of form XObjects processed when
+// redacting content.
+const maxFormDepth = 10
+
+// textState represents the text state parameters.
+// See section 9.3 "Text State Parameters and Operators" (p. 243 PDF32000_2008).
+type textState struct {
+	tc    float64 // Character spacing.
+	tw    float64 // Word spacing.
+	th    float64 // Horizontal scaling (percent).
+	tl    float64 // Leading.
+	tfs   float64 // Font size.
+	trise float64 // Text rise.
+	font  *model.PdfFont
+}
+
+// graphicsState represents the parts of the graphics state used for
+// locating the content of a page.
+type graphicsState struct {
+	ctm  transform.Matrix
+	text textState
+}
+
+// redactionContext contains the state of the redaction of a page.
+type redactionContext struct {
+	regions []model.PdfRectangle
+	report  *Report
+}
+
+func newRedactionContext(regions []model.PdfRectangle, report *Report) *redactionContext {
+	return &redactionContext{
+		regions: regions,
+		report:  report,
+	}
+}
+
+// redactContent returns the operations of `contents` with the content
+// intersecting the redacted regions removed. `ctm` is the transformation
+// from the content space to page space.
+func (rc *redactionContext) redactContent(contents string, resources *model.PdfPageResources,
+	ctm transform.Matrix, depth int) (*contentstream.ContentStreamOperations, error) {
+	ops, err := contentstream.NewContentStreamParser(contents).Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	gs := graphicsState{ctm: ctm, text: textState{th: 100}}
+	var stack []graphicsState
+	var tm, tlm transform.Matrix
+	fonts := map[core.PdfObjectName]*model.PdfFont{}
+
+	var out contentstream.ContentStreamOperations
+	for _, op := range *ops {
+		vals, _ := core.GetNumbersAsFloat(op.Params)
+
+		switch op.Operand {
+		case "q":
+			stack = append(stack, gs)
+		case "Q":
+			if len(stack) > 0 {
+				gs = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			if len(vals) == 6 {
+				gs.ctm.Concat(transform.NewMatrix(vals[0], vals[1], vals[2], vals[3], vals[4], vals[5]))
+			}
+		case "BT":
+			tm, tlm = identityMatrix(), identityMatrix()
+		case "Tc":
+			if len(vals) == 1 {
+				gs.text.tc = vals[0]
+			}
+		case "Tw":
+			if len(vals) == 1 {
+				gs.text.tw = vals[0]
+			}
+		case "Tz":
+			if len(vals) == 1 {
+				gs.text.th = vals[0]
+			}
+		case "TL":
+			if len(vals) == 1 {
+				gs.text.tl = vals[0]
+			}
+		case "Ts":
+			if len(vals) == 1 {
+				gs.text.trise = vals[0]
+			}
+		case "Tf":
+			if len(op.Params) == 2 {
+				name, _ := core.GetName(op.Params[0])
+				size, err := core.GetNumberAsFloat(op.Params[1])
+				if name != nil && err == nil {
+					gs.text.font = rc.loadFont(*name, resources, fonts)
+					gs.text.tfs = size
+				}
+			}
+		case "Td", "TD":
+			if len(vals) == 2 {
+				if op.Operand == "TD" {
+					gs.text.tl = -vals[1]
+				}
+				tlm.Concat(transform.TranslationMatrix(vals[0], vals[1]))
+				tm = tlm
+			}
+		case "Tm":
+			if len(vals) == 6 {
+				tm = transform.NewMatrix(vals[0], vals[1], vals[2], vals[3], vals[4], vals[5])
+				tlm = tm
+			}
+		case "T*":
+			tlm.Concat(transform.TranslationMatrix(0, -gs.text.tl))
+			tm = tlm
+		case "Tj", "TJ", "'", "\"":
+			out = append(out, rc.redactText(op, &gs, &tm, &tlm)...)
+			continue
+		case "Do":
+			if len(op.Params) == 1 {
+				name, ok := core.GetName(op.Params[0])
+				if ok {
+					redacted, err := rc.redactXObject(op, *name, resources, gs.ctm, depth)
+					if err != nil {
+						return nil, err
+					}
+					out = append(out, redacted...)
+					continue
+				}
+			}
+		case "BI":
+			bbox := model.PdfRectangle{Urx: 1, Ury: 1}.Transform(gs.ctm)
+			if intersectsAny(bbox, rc.regions) {
+				rc.report.Items = append(rc.report.Items, RedactedItem{
+					Type: RedactedItemInlineImage,
+					BBox: bbox,
+				})
+				continue
+			}
+		}
+		out = append(out, op)
+	}
+
+	return &out, nil
+}
+
+// loadFont returns the font with the specified resource name. The default
+// font is returned if the font cannot be loaded.
+func (rc *redactionContext) loadFont(name core.PdfObjectName, resources *model.PdfPageResources,
+	fonts map[core.PdfObjectName]*model.PdfFont) *model.PdfFont {
+	if font, ok := fonts[name]; ok {
+		return font
+	}
+
+	var font *model.PdfFont
+	if resources != nil {
+		if obj, ok := resources.GetFontByName(name); ok {
+			var err error
+			font, err = model.NewPdfFontFromPdfObject(obj)
+			if err != nil {
+				common.Log.Debug("ERROR: unable to load font %s: %v", name, err)
+				font = nil
+			}
+		}
+	}
+	if font == nil {
+		common.Log.Debug("WARN: font %s not found. Using default font metrics", name)
+		font = model.DefaultFont()
+	}
+	fonts[name] = font
+	return font
+}
+
+// textChunk represents a part of a text string, whose glyphs are either all
+// kept or all removed.
+type textChunk struct {
+	data    []byte
+	removed bool
+	advance float64 // Horizontal displacement in unscaled text space units.
+}
+
+// redactText returns the operations replacing the text showing operation
+// `op`, with the glyphs intersecting the redacted regions removed. The
+// text matrices are updated with the displacement of the shown text.
+func (rc *redactionContext) redactText(op *contentstream.ContentStreamOperation, gs *graphicsState,
+	tm, tlm *transform.Matrix) []*contentstream.ContentStreamOperation {
+	var prefix []*contentstream.ContentStreamOperation
+	var elements []core.PdfObject
+
+	switch op.Operand {
+	case "Tj", "'":
+		if len(op.Params) != 1 {
+			return []*contentstream.ContentStreamOperation{op}
+		}
+		elements = op.Params
+	case "\"":
+		if len(op.Params) != 3 {
+			return []*contentstream.ContentStreamOperation{op}
+		}
+		aw, err1 := core.GetNumberAsFloat(op.Params[0])
+		ac, err2 := core.GetNumberAsFloat(op.Params[1])
+		if err1 != nil || err2 != nil {
+			return []*contentstream.ContentStreamOperation{op}
+		}
+		gs.text.tw, gs.text.tc = aw, ac
+		prefix = append(prefix,
+			&contentstream.ContentStreamOperation{Operand: "Tw", Params: []core.PdfObject{op.Params[0]}},
+			&contentstream.ContentStreamOperation{Operand: "Tc", Params: []core.PdfObject{op.Params[1]}},
+		)
+		elements = op.Params[2:]
+	case "TJ":
+		if len(op.Params) != 1 {
+			return []*contentstream.ContentStreamOperation{op}
+		}
+		arr, ok := core.GetArray(op.Params[0])
+		if !ok {
+			return []*contentstream.ContentStreamOperation{op}
+		}
+		elements = arr.Elements()
+	}
+	if op.Operand == "'" || op.Operand == "\"" {
+		tlm.Concat(transform.TranslationMatrix(0, -gs.text.tl))
+		*tm = *tlm
+		prefix = append(prefix, &contentstream.ContentStreamOperation{Operand: "T*"})
+	}
+
+	state := gs.text
+	th := state.th / 100
+	var removedText []string
+	var removedBBox *model.PdfRectangle
+
+	changed := false
+	var result []core.PdfObject
+	for _, element := range elements {
+		if str, ok := core.GetString(element); ok {
+			chunks, text, bbox := rc.splitText(str.Bytes(), gs, tm)
+			for _, chunk := range chunks {
+				if chunk.removed {
+					changed = true
+					if state.tfs != 0 {
+						result = append(result, core.MakeFloat(-chunk.advance*1000/state.tfs))
+					}
+				} else {
+					result = append(result, core.MakeStringFromBytes(chunk.data))
+				}
+			}
+			if text != "" {
+				removedText = append(removedText, text)
+			}
+			if bbox != nil {
+				if removedBBox == nil {
+					removedBBox = bbox
+				} else {
+					*removedBBox = removedBBox.Union(*bbox)
+				}
+			}
+			continue
+		}
+
+		adjust, err := core.GetNumberAsFloat(element)
+		if err != nil {
+			common.Log.Debug("ERROR: invalid TJ element (%T)", element)
+			continue
+		}
+		tm.Concat(transform.TranslationMatrix(-adjust/1000*state.tfs*th, 0))
+		result = append(result, element)
+	}
+
+	if !changed {
+		return []*contentstream.ContentStreamOperation{op}
+	}
+
+	if removedBBox != nil {
+		rc.report.Items = append(rc.report.Items, RedactedItem{
+			Type: RedactedItemText,
+			Text: strings.Join(removedText, ""),
+			BBox: *removedBBox,
+		})
+	}
+
+	return append(prefix, &contentstream.ContentStreamOperation{
+		Operand: "TJ",
+		Params:  []core.PdfObject{core.MakeArray(tjarray.Merge(result)...)},
+	})
+}
+
+// splitText splits the string `data` shown with the current text state into
+// chunks of kept and removed glyphs. Returns the chunks, the removed text and
+// the bounding box of the removed glyphs. The text matrix `tm` is updated
+// with the displacement of the string.
+func (rc *redactionContext) splitText(data []byte, gs *graphicsState, tm *transform.Matrix) (
+	[]textChunk, string, *model.PdfRectangle) {
+	state := gs.text
+	font := state.font
+	if font == nil {
+		font = model.DefaultFont()
+	}
+	th := state.th / 100
+	descent, ascent := fontVerticalExtent(font)
+
+	codes := font.BytesToCharcodes(data)
+	codeLen := 0
+	switch {
+	case len(codes) == 0:
+		return nil, "", nil
+	case len(data) == len(codes):
+		codeLen = 1
+	case len(data) == 2*len(codes):
+		codeLen = 2
+	}
+
+	var chunks []textChunk
+	var removedCodes []textencoding.CharCode
+	var removedBBox *model.PdfRectangle
+	for i, code := range codes {
+		w := 0.0
+		if m, ok := font.GetCharMetrics(code); ok {
+			w = m.Wx / 1000
+		}
+		tw := 0.0
+		if codeLen == 1 && code == 32 {
+			tw = state.tw
+		}
+		advance := w*state.tfs + state.tc + tw
+
+		// Glyph box in text space.
+		glyph := model.PdfRectangle{
+			Llx: 0, Lly: state.trise + descent*state.tfs,
+			Urx: w * state.tfs * th, Ury: state.trise + ascent*state.tfs,
+		}
+		bbox := glyph.Transform(gs.ctm.Mult(*tm))
+		removed := state.tfs != 0 && intersectsAny(bbox, rc.regions)
+		tm.Concat(transform.TranslationMatrix(advance*th, 0))
+
+		if removed {
+			removedCodes = append(removedCodes, code)
+			if removedBBox == nil {
+				removedBBox = &bbox
+			} else {
+				*removedBBox = removedBBox.Union(bbox)
+			}
+		}
+
+		var codeData []byte
+		if codeLen > 0 {
+			codeData = data[i*codeLen : (i+1)*codeLen]
+		}
+		if n := len(chunks); n > 0 && chunks[n-1].removed == removed {
+			chunks[n-1].data = append(chunks[n-1].data, codeData...)
+			chunks[n-1].advance += advance
+			continue
+		}
+		chunks = append(chunks, textChunk{data: append([]byte{}, codeData...), removed: removed, advance: advance})
+	}
+
+	if codeLen == 0 && removedBBox != nil {
+		// The code boundaries are unknown: remove the whole string.
+		advance := 0.0
+		for _, chunk := range chunks {
+			advance += chunk.advance
+		}
+		chunks = []textChunk{{removed: true, advance: advance}}
+		removedCodes = codes
+	}
+
+	var text string
+	if len(removedCodes) > 0 {
+		strs, _, _ := font.CharcodesToStrings(removedCodes)
+		text = strings.Join(strs, "")
+	}
+	return chunks, text, removedBBox
+}
+
+// fontVerticalExtent returns the descent and the ascent of `font`, in text
+// space units for a font size of 1.
+func fontVerticalExtent(font *model.PdfFont) (float64, float64) {
+	descent, ascent := -0.2, 0.8
+	if desc := font.FontDescriptor(); desc != nil {
+		if val, err := core.GetNumberAsFloat(core.TraceToDirectObject(desc.Descent)); err == nil && val < 0 {
+			descent = val / 1000
+		}
+		if val, err := core.GetNumberAsFloat(core.TraceToDirectObject(desc.Ascent)); err == nil && val > 0 {
+			ascent = val / 1000
+		}
+	}
+	return descent, ascent
+}
+
+// redactXObject returns the operations replacing the Do operation `op`
+// painting the XObject `name`. Images intersecting the redacted regions are
+// replaced with masked copies and forms are redacted recursively.
+func (rc *redactionContext) redactXObject(op *contentstream.ContentStreamOperation, name core.PdfObjectName,
+	resources *model.PdfPageResources, ctm transform.Matrix, depth int) ([]*contentstream.ContentStreamOperation, error) {
+	keep := []*contentstream.ContentStreamOperation{op}
+	if resources == nil {
+		return keep, nil
+	}
+
+	stream, xtype := resources.GetXObjectByName(name)
+	if stream == nil {
+		return keep, nil
+	}
+	var redacted *core.PdfObjectStream
+	switch xtype {
+	case model.XObjectTypeImage:
+		bbox := model.PdfRectangle{Urx: 1, Ury: 1}.Transform(ctm)
+		if !intersectsAny(bbox, rc.regions) {
+			return keep, nil
+		}
+		ximg, err := model.NewXObjectImageFromStream(stream)
+		if err != nil {
+			return nil, err
+		}
+		masked, err := rc.maskImage(ximg, ctm)
+		if err != nil {
+			return nil, err
+		}
+		if masked == nil {
+			return keep, nil
+		}
+		rc.report.Items = append(rc.report.Items, RedactedItem{
+			Type: RedactedItemImage,
+			Name: string(name),
+			BBox: bbox,
+		})
+		redacted = masked
+	case model.XObjectTypeForm:
+		if depth >= maxFormDepth {
+			common.Log.Debug("ERROR: form XObjects nested too deep")
+			return keep, nil
+		}
+		xform, err := model.NewXObjectFormFromStream(stream)
+		if err != nil {
+			return nil, err
+		}
+		formCTM := ctm
+		if matrix, ok := core.GetArray(xform.Matrix); ok {
+			if vals, err := matrix.ToFloat64Array(); err == nil && len(vals) == 6 {
+				formCTM = ctm.Mult(transform.NewMatrix(vals[0], vals[1], vals[2], vals[3], vals[4], vals[5]))
+			}
+		}
+		if arr, ok := core.GetArray(xform.BBox); ok {
+			if bbox, err := model.NewPdfRectangle(*arr); err == nil {
+				if !intersectsAny(bbox.Transform(formCTM), rc.regions) {
+					return keep, nil
+				}
+			}
+		}
+
+		content, err := xform.GetContentStream()
+		if err != nil {
+			return nil, err
+		}
+		formResources := xform.Resources
+		if formResources == nil {
+			formResources = resources
+		}
+
+		numItems := len(rc.report.Items)
+		ops, err := rc.redactContent(string(content), formResources, formCTM, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(rc.report.Items) == numItems {
+			return keep, nil
+		}
+
+		copied := model.NewXObjectForm()
+		copied.FormType = xform.FormType
+		copied.BBox = xform.BBox
+		copied.Matrix = xform.Matrix
+		copied.Resources = formResources
+		copied.Group = xform.Group
+		copied.OC = xform.OC
+		if err := copied.SetContentStream(ops.Bytes(), core.NewFlateEncoder()); err != nil {
+			return nil, err
+		}
+		redacted, _ = core.GetStream(copied.ToPdfObject())
+	default:
+		return keep, nil
+	}
+
+	// The redacted copy is added under a new name, as the original XObject
+	// can be painted elsewhere in the document.
+	if redacted == nil {
+		return nil, errors.New("invalid redacted XObject")
+	}
+	copyName := resources.GenerateXObjectName()
+	if err := resources.SetXObjectByName(copyName, redacted); err != nil {
+		return nil, err
+	}
+	return []*contentstream.ContentStreamOperation{makeDoOperation(copyName)}, nil
+}
+
+// maskImage returns a copy of `ximg` with the pixels located in the redacted
+// regions zeroed (or made transparent for stencil masks), or nil if no pixel
+// is covered. `ctm` maps the unit square of the image to page space.
+func (rc *redactionContext) maskImage(ximg *model.XObjectImage, ctm transform.Matrix) (*core.PdfObjectStream, error) {
+	img, err := ximg.ToImage()
+	if err != nil {
+		return nil, err
+	}
+	width, height := int(img.Width), int(img.Height)
+	if width <= 0 || height <= 0 {
+		return nil, nil
+	}
+
+	isMask := false
+	if val, ok := core.GetBoolVal(ximg.ImageMask); ok {
+		isMask = val
+	}
+	value := uint32(0)
+	if isMask {
+		// Stencil mask samples equal to 1 are not painted.
+		value = uint32(1)<<uint(img.BitsPerComponent) - 1
+	}
+
+	samples := img.GetSamples()
+	masked := false
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// Image space maps the first row to the top of the unit square.
+			px, py := ctm.Transform((float64(x)+0.5)/float64(width), 1-(float64(y)+0.5)/float64(height))
+			if !containsPoint(rc.regions, px, py) {
+				continue
+			}
+			masked = true
+			idx := (y*width + x) * img.ColorComponents
+			for c := 0; c < img.ColorComponents && idx+c < len(samples); c++ {
+				samples[idx+c] = value
+			}
+		}
+	}
+	if !masked {
+		return nil, nil
+	}
+	img.SetSamples(samples)
+
+	updated, err := model.UpdateXObjectImageFromImage(ximg, img, ximg.ColorSpace, core.NewFlateEncoder())
+	if err != nil {
+		return nil, err
+	}
+	updated.ImageMask = ximg.ImageMask
+	updated.Decode = ximg.Decode
+	updated.Intent = ximg.Intent
+	updated.Interpolate = ximg.Interpolate
+	if isMask {
+		updated.ColorSpace = nil
+	}
+	stream, ok := core.GetStream(updated.ToPdfObject())
+	if !ok {
+		return nil, errors.New("invalid image stream")
+	}
+	return stream, nil
+}
+
+// makeDoOperation returns an operation painting the XObject `name`.
+func makeDoOperation(name core.PdfObjectName) *contentstream.ContentStreamOperation {
+	return &contentstream.ContentStreamOperation{
+		Operand: "Do",
+		Params:  []core.PdfObject{core.MakeName(string(name))},
+	}
+}
+
+// identityMatrix returns the identity matrix.
+func identityMatrix() transform.Matrix {
+	return transform.IdentityMatrix()
+}
+
+// containsPoint returns true if the point (`x`,`y`) is located in any of
+// `regions`.
+func containsPoint(regions []model.PdfRectangle, x, y float64) bool {
+	for _, region := range regions {
+		if x >= region.Llx && x <= region.Urx && y >= region.Lly && y <= region.Ury {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,9 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package redactor is used for applying redactions to PDF pages. The content
+// located under the redacted regions (text, images and annotations) is
+// removed from the page, not only covered.
+package redactor
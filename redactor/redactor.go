@@ -0,0 +1,407 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package redactor
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/pageutil"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// Options defines the redactions applied to a page in addition to the Redact
+// annotations of the page.
+type Options struct {
+	// Regions are additional regions to redact, in page coordinates.
+	Regions []model.PdfRectangle
+
+	// FillColor is the color of the overlay drawn over Regions. No overlay is
+	// drawn if not specified. The overlay of Redact annotations is specified
+	// by their IC, OverlayText and RO entries.
+	FillColor *model.PdfColorDeviceRGB
+}
+
+// RedactedItemType represents the type of content removed by a redaction.
+type RedactedItemType int
+
+// Types of redacted items.
+const (
+	RedactedItemText RedactedItemType = iota
+	RedactedItemImage
+	RedactedItemInlineImage
+	RedactedItemAnnotation
+)
+
+// String returns a string representation of the item type.
+func (t RedactedItemType) String() string {
+	switch t {
+	case RedactedItemText:
+		return "Text"
+	case RedactedItemImage:
+		return "Image"
+	case RedactedItemInlineImage:
+		return "InlineImage"
+	case RedactedItemAnnotation:
+		return "Annotation"
+	}
+	return "Unknown"
+}
+
+// RedactedItem represents content removed from a page by a redaction.
+type RedactedItem struct {
+	Type RedactedItemType
+	// Text is the removed text, for text items.
+	Text string
+	// Name is the resource name of images and the subtype of annotations.
+	Name string
+	// BBox is the bounding box of the removed content, in page coordinates.
+	// For images, it is the bounding box of the whole image, of which only
+	// the redacted pixels are removed.
+	BBox model.PdfRectangle
+}
+
+// Report lists the content removed by applying redactions to a page.
+type Report struct {
+	Items []RedactedItem
+}
+
+// Text returns the removed text, one line per text item.
+func (r *Report) Text() string {
+	var lines []string
+	for _, item := range r.Items {
+		if item.Type == RedactedItemText {
+			lines = append(lines, item.Text)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// redaction represents a redaction applied to a page.
+type redaction struct {
+	regions []model.PdfRectangle
+
+	// Overlay.
+	fillColor   *model.PdfColorDeviceRGB
+	overlayText string
+	textColor   *model.PdfColorDeviceRGB
+	fontSize    float64
+	overlay     *core.PdfObjectStream // Overlay form XObject (RO).
+}
+
+// ApplyRedactions applies the Redact annotations of `page` and the
+// redactions specified by `opts`, which can be nil. Text showing operators
+// are rewritten to remove the glyphs intersecting the redacted regions,
+// images are masked (the covered pixels are zeroed), inline images and
+// annotations intersecting the regions are removed. Then the overlays of the
+// redactions are drawn and the Redact annotations are removed from the page.
+// Returns a report of the removed content. Redacted images and form XObjects
+// are added as new XObjects, as the originals can be shared with other pages.
+func ApplyRedactions(page *model.PdfPage, opts *Options) (*Report, error) {
+	if page == nil {
+		return nil, errors.New("page not specified")
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	annotations, err := page.GetAnnotations()
+	if err != nil {
+		return nil, err
+	}
+
+	var redactions []*redaction
+	redactAnnots := map[*model.PdfAnnotation]struct{}{}
+	for _, annot := range annotations {
+		redact, ok := annot.GetContext().(*model.PdfAnnotationRedact)
+		if !ok {
+			continue
+		}
+		redactAnnots[annot] = struct{}{}
+		if r := newAnnotationRedaction(redact); r != nil {
+			redactions = append(redactions, r)
+		}
+	}
+	if len(opts.Regions) > 0 {
+		r := &redaction{fillColor: opts.FillColor}
+		for _, region := range opts.Regions {
+			r.regions = append(r.regions, region.Normalized())
+		}
+		redactions = append(redactions, r)
+	}
+
+	var regions []model.PdfRectangle
+	for _, r := range redactions {
+		regions = append(regions, r.regions...)
+	}
+
+	report := &Report{}
+	if len(regions) > 0 {
+		if page.Resources == nil {
+			page.Resources = model.NewPdfPageResources()
+		}
+		contents, err := page.GetAllContentStreams()
+		if err != nil {
+			return nil, err
+		}
+
+		rc := newRedactionContext(regions, report)
+		ops, err := rc.redactContent(contents, page.Resources, identityMatrix(), 0)
+		if err != nil {
+			return nil, err
+		}
+
+		content := "q\n" + ops.String() + "\nQ\n"
+		overlay, err := makeOverlay(redactions, page.Resources)
+		if err != nil {
+			return nil, err
+		}
+		content += overlay
+		if err := page.SetContentStreams([]string{content}, core.NewFlateEncoder()); err != nil {
+			return nil, err
+		}
+	}
+
+	// Remove the annotations intersecting the redacted regions and the
+	// applied Redact annotations, along with their popups.
+	removed := map[core.PdfObject]struct{}{}
+	var kept []*model.PdfAnnotation
+	for _, annot := range annotations {
+		_, isRedact := redactAnnots[annot]
+		if !isRedact {
+			rect, ok := annotationRect(annot)
+			if !ok || !intersectsAny(rect, regions) {
+				kept = append(kept, annot)
+				continue
+			}
+			report.Items = append(report.Items, RedactedItem{
+				Type: RedactedItemAnnotation,
+				Name: pageutil.AnnotationSubtype(annot),
+				BBox: rect,
+			})
+		}
+		removed[annot.GetContainingPdfObject()] = struct{}{}
+	}
+
+	var annots []*model.PdfAnnotation
+	for _, annot := range kept {
+		if popup, ok := annot.GetContext().(*model.PdfAnnotationPopup); ok && popup.Parent != nil {
+			if _, ok := removed[popup.Parent]; ok {
+				continue
+			}
+		}
+		annots = append(annots, annot)
+	}
+	if len(annots) != len(annotations) {
+		page.SetAnnotations(annots)
+	}
+
+	return report, nil
+}
+
+// newAnnotationRedaction returns the redaction defined by a Redact
+// annotation. The regions are specified by the QuadPoints entry or, if
+// missing, by the Rect entry of the annotation.
+func newAnnotationRedaction(annot *model.PdfAnnotationRedact) *redaction {
+	r := &redaction{}
+	if quads, ok := core.GetArray(annot.QuadPoints); ok {
+		coords, err := quads.ToFloat64Array()
+		if err != nil {
+			common.Log.Debug("ERROR: invalid redact annotation quad points: %v", err)
+		}
+		for i := 0; i+8 <= len(coords); i += 8 {
+			region := model.PdfRectangle{
+				Llx: math.Inf(1), Lly: math.Inf(1),
+				Urx: math.Inf(-1), Ury: math.Inf(-1),
+			}
+			for j := i; j < i+8; j += 2 {
+				region.Llx = math.Min(region.Llx, coords[j])
+				region.Lly = math.Min(region.Lly, coords[j+1])
+				region.Urx = math.Max(region.Urx, coords[j])
+				region.Ury = math.Max(region.Ury, coords[j+1])
+			}
+			r.regions = append(r.regions, region)
+		}
+	}
+	if len(r.regions) == 0 {
+		rect, ok := annotationRect(annot.PdfAnnotation)
+		if !ok {
+			common.Log.Debug("ERROR: redact annotation without regions")
+			return nil
+		}
+		r.regions = append(r.regions, rect)
+	}
+
+	if ic, ok := core.GetArray(annot.IC); ok {
+		if color, ok := arrayToRGB(ic); ok {
+			r.fillColor = color
+		}
+	}
+	if str, ok := core.GetString(annot.OverlayText); ok {
+		r.overlayText = str.Decoded()
+		r.fontSize, r.textColor = parseDefaultAppearance(annot.DA)
+	}
+	if stream, ok := core.GetStream(annot.RO); ok {
+		r.overlay = stream
+	}
+	return r
+}
+
+// parseDefaultAppearance returns the font size and the text color specified
+// by a default appearance string (DA).
+func parseDefaultAppearance(obj core.PdfObject) (float64, *model.PdfColorDeviceRGB) {
+	fontSize := 10.0
+	color := model.NewPdfColorDeviceRGB(0, 0, 0)
+
+	da, ok := core.GetString(obj)
+	if !ok {
+		return fontSize, color
+	}
+	ops, err := contentstream.NewContentStreamParser(da.Str()).Parse()
+	if err != nil {
+		common.Log.Debug("ERROR: invalid default appearance: %v", err)
+		return fontSize, color
+	}
+
+	for _, op := range *ops {
+		vals, err := core.GetNumbersAsFloat(op.Params)
+		if err != nil {
+			continue
+		}
+		switch {
+		case op.Operand == "Tf" && len(op.Params) == 2:
+			if size, err := core.GetNumberAsFloat(op.Params[1]); err == nil && size > 0 {
+				fontSize = size
+			}
+		case op.Operand == "g" && len(vals) == 1:
+			color = model.NewPdfColorDeviceRGB(vals[0], vals[0], vals[0])
+		case op.Operand == "rg" && len(vals) == 3:
+			color = model.NewPdfColorDeviceRGB(vals[0], vals[1], vals[2])
+		}
+	}
+	return fontSize, color
+}
+
+// makeOverlay returns the content stream drawing the overlays of the
+// redactions. The resources used by the overlays are added to `resources`.
+func makeOverlay(redactions []*redaction, resources *model.PdfPageResources) (string, error) {
+	var fontName core.PdfObjectName
+	cc := contentstream.NewContentCreator()
+
+	for _, r := range redactions {
+		if r.overlay != nil {
+			form, err := model.NewXObjectFormFromStream(r.overlay)
+			if err != nil {
+				return "", err
+			}
+			bbox := model.PdfRectangle{Urx: 1, Ury: 1}
+			if arr, ok := core.GetArray(form.BBox); ok {
+				if rect, err := model.NewPdfRectangle(*arr); err == nil {
+					bbox = rect.Normalized()
+				}
+			}
+			name := resources.GenerateXObjectName()
+			if err := resources.SetXObjectByName(name, r.overlay); err != nil {
+				return "", err
+			}
+			for _, region := range r.regions {
+				sx, sy := 1.0, 1.0
+				if bbox.Width() > 0 && bbox.Height() > 0 {
+					sx, sy = region.Width()/bbox.Width(), region.Height()/bbox.Height()
+				}
+				cc.Add_q().
+					Add_cm(sx, 0, 0, sy, region.Llx-bbox.Llx*sx, region.Lly-bbox.Lly*sy).
+					Add_Do(name).
+					Add_Q()
+			}
+			continue
+		}
+
+		if r.fillColor != nil {
+			cc.Add_q().SetNonStrokingColor(r.fillColor)
+			for _, region := range r.regions {
+				cc.Add_re(region.Llx, region.Lly, region.Width(), region.Height())
+			}
+			cc.Add_f().Add_Q()
+		}
+
+		if r.overlayText != "" {
+			if fontName == "" {
+				fontName = pageutil.GenerateFontName(resources, "RedactFont")
+				font := model.DefaultFont()
+				if err := resources.SetFontByName(fontName, font.ToPdfObject()); err != nil {
+					return "", err
+				}
+			}
+			encoded, _ := model.DefaultFont().StringToCharcodeBytes(r.overlayText)
+			for _, region := range r.regions {
+				cc.Add_q().
+					Add_re(region.Llx, region.Lly, region.Width(), region.Height()).
+					Add_W().Add_n().
+					SetNonStrokingColor(r.textColor).
+					Add_BT().
+					Add_Tf(fontName, r.fontSize).
+					Add_Td(region.Llx+1, region.Ury-r.fontSize).
+					Add_Tj(*core.MakeStringFromBytes(encoded)).
+					Add_ET().
+					Add_Q()
+			}
+		}
+	}
+
+	return cc.String(), nil
+}
+
+// annotationRect returns the normalized rectangle of `annot`.
+func annotationRect(annot *model.PdfAnnotation) (model.PdfRectangle, bool) {
+	arr, ok := core.GetArray(annot.Rect)
+	if !ok {
+		return model.PdfRectangle{}, false
+	}
+	rect, err := model.NewPdfRectangle(*arr)
+	if err != nil {
+		return model.PdfRectangle{}, false
+	}
+	return rect.Normalized(), true
+}
+
+// arrayToRGB returns the color specified by a color array of 1 (gray),
+// 3 (RGB) or 4 (CMYK) components.
+func arrayToRGB(arr *core.PdfObjectArray) (*model.PdfColorDeviceRGB, bool) {
+	vals, err := arr.ToFloat64Array()
+	if err != nil {
+		return nil, false
+	}
+	switch len(vals) {
+	case 1:
+		return model.NewPdfColorDeviceRGB(vals[0], vals[0], vals[0]), true
+	case 3:
+		return model.NewPdfColorDeviceRGB(vals[0], vals[1], vals[2]), true
+	case 4:
+		k := 1 - vals[3]
+		return model.NewPdfColorDeviceRGB((1-vals[0])*k, (1-vals[1])*k, (1-vals[2])*k), true
+	}
+	return nil, false
+}
+
+// intersects returns true if the rectangles overlap with a non zero area.
+func intersects(a, b model.PdfRectangle) bool {
+	return a.Llx < b.Urx && a.Urx > b.Llx && a.Lly < b.Ury && a.Ury > b.Lly
+}
+
+// intersectsAny returns true if `rect` intersects any of `regions`.
+func intersectsAny(rect model.PdfRectangle, regions []model.PdfRectangle) bool {
+	for _, region := range regions {
+		if intersects(rect, region) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package redactor
+
+import (
+	goimage "image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/creator"
+	"github.com/unidoc/unipdf/v3/extractor"
+	"github.com/unidoc/unipdf/v3/internal/pdftest"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// extractMarks returns the text of `page` and its text marks.
+func extractMarks(t *testing.T, page *model.PdfPage) (string, []extractor.TextMark) {
+	ex, err := extractor.New(page)
+	require.NoError(t, err)
+	pageText, _, _, err := ex.ExtractPageText()
+	require.NoError(t, err)
+	return pageText.Text(), pageText.Marks().Elements()
+}
+
+// phraseBBox returns the bounding box of the first occurrence of `phrase` in
+// the text of `page`.
+func phraseBBox(t *testing.T, page *model.PdfPage, phrase string) model.PdfRectangle {
+	text, marks := extractMarks(t, page)
+	start := strings.Index(text, phrase)
+	require.NotEqual(t, -1, start, text)
+
+	var bbox *model.PdfRectangle
+	for _, mark := range marks {
+		if mark.Meta || mark.Offset < start || mark.Offset >= start+len(phrase) {
+			continue
+		}
+		if bbox == nil {
+			b := mark.BBox
+			bbox = &b
+			continue
+		}
+		*bbox = bbox.Union(mark.BBox)
+	}
+	require.NotNil(t, bbox)
+	return *bbox
+}
+
+func TestApplyRedactionsText(t *testing.T) {
+	c := creator.New()
+	c.NewPage()
+	p := c.NewParagraph("Account 123456789 belongs to Alice")
+	p.SetPos(100, 100)
+	require.NoError(t, c.Draw(p))
+	page := pdftest.FirstPage(t, c)
+
+	region := phraseBBox(t, page, "123456789")
+	keptBefore := phraseBBox(t, page, "belongs")
+
+	// Redact annotation over the account number and a note overlapping it.
+	redact := model.NewPdfAnnotationRedact()
+	redact.Rect = region.ToPdfObject()
+	redact.IC = core.MakeArrayFromFloats([]float64{0, 0, 0})
+	page.AddAnnotation(redact.PdfAnnotation)
+
+	note := model.NewPdfAnnotationText()
+	note.Rect = (&model.PdfRectangle{
+		Llx: region.Llx, Lly: region.Lly, Urx: region.Llx + 10, Ury: region.Lly + 10,
+	}).ToPdfObject()
+	page.AddAnnotation(note.PdfAnnotation)
+
+	report, err := ApplyRedactions(page, nil)
+	require.NoError(t, err)
+	require.Equal(t, "123456789", report.Text())
+	var types []RedactedItemType
+	for _, item := range report.Items {
+		types = append(types, item.Type)
+	}
+	require.Equal(t, []RedactedItemType{RedactedItemText, RedactedItemAnnotation}, types)
+	require.Equal(t, "Text", report.Items[1].Name)
+
+	page = pdftest.ReloadPage(t, page)
+	annots, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 0)
+
+	text, marks := extractMarks(t, page)
+	require.NotContains(t, text, "123456789")
+	require.Contains(t, text, "Account")
+	require.Contains(t, text, "belongs to Alice")
+	for _, mark := range marks {
+		if mark.Meta || strings.TrimSpace(mark.Text) == "" {
+			continue
+		}
+		require.False(t, intersects(mark.BBox, region), "mark %q in redacted region", mark.Text)
+	}
+
+	// The remaining text is not moved.
+	keptAfter := phraseBBox(t, page, "belongs")
+	require.InDelta(t, keptBefore.Llx, keptAfter.Llx, 1e-3)
+	require.InDelta(t, keptBefore.Lly, keptAfter.Lly, 1e-3)
+}
+
+func TestApplyRedactionsImage(t *testing.T) {
+	goimg := goimage.NewRGBA(goimage.Rect(0, 0, 20, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			goimg.Set(x, y, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+		}
+	}
+
+	c := creator.New()
+	c.NewPage()
+	img, err := c.NewImageFromGoImage(goimg)
+	require.NoError(t, err)
+	img.SetPos(100, 100)
+	img.Scale(10, 10)
+	require.NoError(t, c.Draw(img))
+	page := pdftest.FirstPage(t, c)
+
+	ex, err := extractor.New(page)
+	require.NoError(t, err)
+	images, err := ex.ExtractPageImages(nil)
+	require.NoError(t, err)
+	require.Len(t, images.Images, 1)
+	mark := images.Images[0]
+
+	// Redact the left half of the image.
+	region := model.PdfRectangle{
+		Llx: mark.X - 10, Lly: mark.Y - 10,
+		Urx: mark.X + mark.Width/2, Ury: mark.Y + mark.Height + 10,
+	}
+	report, err := ApplyRedactions(page, &Options{
+		Regions:   []model.PdfRectangle{region},
+		FillColor: model.NewPdfColorDeviceRGB(0, 0, 0),
+	})
+	require.NoError(t, err)
+	require.Len(t, report.Items, 1)
+	require.Equal(t, RedactedItemImage, report.Items[0].Type)
+
+	page = pdftest.ReloadPage(t, page)
+	ex, err = extractor.New(page)
+	require.NoError(t, err)
+	images, err = ex.ExtractPageImages(nil)
+	require.NoError(t, err)
+	require.Len(t, images.Images, 1)
+	redacted := images.Images[0].Image
+	require.Equal(t, int64(20), redacted.Width)
+
+	for _, x := range []int{0, 5, 9} {
+		c, err := redacted.ColorAt(x, 10)
+		require.NoError(t, err)
+		r, g, b, _ := c.RGBA()
+		require.Equal(t, []uint32{0, 0, 0}, []uint32{r, g, b}, "x=%d", x)
+	}
+	for _, x := range []int{10, 15, 19} {
+		c, err := redacted.ColorAt(x, 10)
+		require.NoError(t, err)
+		r, _, _, _ := c.RGBA()
+		require.Equal(t, uint32(math.MaxUint16), r, "x=%d", x)
+	}
+}
+
+func TestApplyRedactionsNoRegions(t *testing.T) {
+	page := model.NewPdfPage()
+	report, err := ApplyRedactions(page, nil)
+	require.NoError(t, err)
+	require.Empty(t, report.Items)
+
+	_, err = ApplyRedactions(nil, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,11 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package remover is used for removing classes of content from PDF pages,
+// e.g. all the images of a document, all its text or its vector graphics.
+// The content streams of the pages and of the form XObjects they paint are
+// rewritten without the selected operations and the resources which are no
+// longer used are dropped.
+package remover
@@ -0,0 +1,618 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package remover
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/pageutil"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// Class represents a class of content. Classes can be combined with |.
+type Class int
+
+// Classes of content.
+const (
+	// ClassText is the text shown by the Tj, TJ, ' and " operators.
+	ClassText Class = 1 << iota
+
+	// ClassImage is the images painted as image XObjects or inline images.
+	ClassImage
+
+	// ClassPath is the paths stroked or filled. Clipping paths are kept.
+	ClassPath
+
+	// ClassShading is the shadings painted by the sh operator.
+	ClassShading
+
+	// ClassAnnotation is the annotations, of the subtypes specified by
+	// Options.AnnotationSubtypes.
+	ClassAnnotation
+
+	// ClassVector is the vector graphics: paths and shadings.
+	ClassVector = ClassPath | ClassShading
+)
+
+// String returns a string representation of the class.
+func (c Class) String() string {
+	names := []struct {
+		class Class
+		name  string
+	}{
+		{ClassText, "Text"},
+		{ClassImage, "Image"},
+		{ClassPath, "Path"},
+		{ClassShading, "Shading"},
+		{ClassAnnotation, "Annotation"},
+	}
+	var parts []string
+	for _, n := range names {
+		if c&n.class != 0 {
+			parts = append(parts, n.name)
+		}
+	}
+	if len(parts) == 0 {
+		return "None"
+	}
+	return strings.Join(parts, "|")
+}
+
+// Options defines the content removed from pages.
+type Options struct {
+	// Classes are the classes of the content removed.
+	Classes Class
+
+	// Filter, if not nil, is called for the operations of Classes and
+	// returns whether operation `op` of class `class` is removed. Paths are
+	// represented by their painting operation and images by their Do or BI
+	// operation.
+	Filter func(class Class, op *contentstream.ContentStreamOperation) bool
+
+	// AnnotationSubtypes are the subtypes of the annotations removed with
+	// ClassAnnotation, e.g. "Link" or "Popup". All the annotations are
+	// removed if not specified. The fields of removed widget annotations are
+	// not removed from the interactive form of the document.
+	AnnotationSubtypes []string
+}
+
+// maxFormDepth is the maximum nesting level of the form XObjects processed.
+const maxFormDepth = 10
+
+// RemoveContent removes the content of `page` selected by `opts`, from the
+// page content streams, the form XObjects they paint and the appearance
+// streams of the page annotations. Returns the number of operations and
+// annotations removed.
+// The graphics state operators are kept, so that the q and Q operators
+// remain balanced. The rewritten form XObjects are new objects, as the
+// originals can be painted elsewhere in the document, and the page and the
+// rewritten form XObjects are given new resource dictionaries without the
+// resources which are no longer used.
+func RemoveContent(page *model.PdfPage, opts *Options) (int, error) {
+	return RemoveDocumentContent([]*model.PdfPage{page}, opts)
+}
+
+// RemoveDocumentContent removes the content selected by `opts` from `pages`,
+// as RemoveContent. The form XObjects painted by several pages are rewritten
+// once. Returns the number of operations and annotations removed.
+func RemoveDocumentContent(pages []*model.PdfPage, opts *Options) (int, error) {
+	if opts == nil {
+		return 0, errors.New("options not specified")
+	}
+	rc := &removalContext{
+		opts:  opts,
+		forms: map[*core.PdfObjectStream]*core.PdfObjectStream{},
+	}
+	for _, page := range pages {
+		if page == nil {
+			return rc.count, errors.New("page not specified")
+		}
+		if err := rc.removePageContent(page); err != nil {
+			return rc.count, err
+		}
+	}
+	return rc.count, nil
+}
+
+// removalContext contains the state of the removal of content from pages.
+type removalContext struct {
+	opts *Options
+
+	// Rewritten form XObjects with their own resources, by original stream.
+	// Unchanged forms are mapped to themselves.
+	forms map[*core.PdfObjectStream]*core.PdfObjectStream
+
+	count int // Number of operations and annotations removed.
+}
+
+// remove returns true if operation `op` of class `class` is removed.
+func (rc *removalContext) remove(class Class, op *contentstream.ContentStreamOperation) bool {
+	if rc.opts.Classes&class == 0 {
+		return false
+	}
+	if rc.opts.Filter != nil && !rc.opts.Filter(class, op) {
+		return false
+	}
+	rc.count++
+	return true
+}
+
+// removePageContent removes the selected content of `page`.
+func (rc *removalContext) removePageContent(page *model.PdfPage) error {
+	if err := rc.removeAnnotations(page); err != nil {
+		return err
+	}
+	if page.Contents == nil {
+		return nil
+	}
+
+	contents, err := page.GetAllContentStreams()
+	if err != nil {
+		return err
+	}
+	resources := page.Resources
+	if resources == nil {
+		resources = model.NewPdfPageResources()
+	}
+	fc, err := rc.filterContent(contents, resources, 0)
+	if err != nil {
+		return err
+	}
+	if fc.removed {
+		err := page.SetContentStreams([]string{fc.ops.String()}, core.NewFlateEncoder())
+		if err != nil {
+			return err
+		}
+	}
+	if (fc.removed || len(fc.forms) > 0) && page.Resources != nil {
+		page.Resources, err = fc.pruneResources(page.Resources)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeAnnotations removes the selected annotations of `page` and the
+// selected content of the appearance streams of the other annotations.
+func (rc *removalContext) removeAnnotations(page *model.PdfPage) error {
+	annotations, err := page.GetAnnotations()
+	if err != nil {
+		return err
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	var kept []*model.PdfAnnotation
+	removed := map[core.PdfObject]struct{}{}
+	if rc.opts.Classes&ClassAnnotation != 0 {
+		for _, annot := range annotations {
+			if rc.isAnnotationRemoved(annot) {
+				rc.count++
+				removed[annot.GetContainingPdfObject()] = struct{}{}
+				continue
+			}
+			kept = append(kept, annot)
+		}
+	} else {
+		kept = annotations
+	}
+
+	// The popups of the removed annotations are removed along with them.
+	var annots []*model.PdfAnnotation
+	for _, annot := range kept {
+		if popup, ok := annot.GetContext().(*model.PdfAnnotationPopup); ok && popup.Parent != nil {
+			if _, ok := removed[popup.Parent]; ok {
+				continue
+			}
+		}
+		if err := rc.filterAppearances(annot); err != nil {
+			return err
+		}
+		annots = append(annots, annot)
+	}
+	if len(annots) != len(annotations) {
+		page.SetAnnotations(annots)
+	}
+	return nil
+}
+
+// isAnnotationRemoved returns true if `annot` has one of the selected
+// subtypes.
+func (rc *removalContext) isAnnotationRemoved(annot *model.PdfAnnotation) bool {
+	if len(rc.opts.AnnotationSubtypes) == 0 {
+		return true
+	}
+	subtype := pageutil.AnnotationSubtype(annot)
+	for _, s := range rc.opts.AnnotationSubtypes {
+		if s == subtype {
+			return true
+		}
+	}
+	return false
+}
+
+// filterAppearances removes the selected content of the appearance streams
+// of `annot`.
+func (rc *removalContext) filterAppearances(annot *model.PdfAnnotation) error {
+	if rc.opts.Classes&^ClassAnnotation == 0 {
+		return nil
+	}
+	ap, ok := core.GetDict(annot.AP)
+	if !ok {
+		return nil
+	}
+	resources := model.NewPdfPageResources()
+
+	// filter replaces the appearance stream `obj` of `dict`, under `key`.
+	filter := func(dict *core.PdfObjectDictionary, key core.PdfObjectName, obj core.PdfObject) error {
+		stream, ok := core.GetStream(obj)
+		if !ok {
+			return nil
+		}
+		filtered, _, err := rc.filterForm(stream, resources, 0)
+		if err != nil {
+			return err
+		}
+		if filtered != stream {
+			dict.Set(key, filtered)
+		}
+		return nil
+	}
+
+	for _, key := range []core.PdfObjectName{"N", "R", "D"} {
+		obj := ap.Get(key)
+		if states, ok := core.GetDict(obj); ok {
+			// Appearance subdictionary, by appearance state.
+			for _, state := range states.Keys() {
+				if err := filter(states, state, states.Get(state)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := filter(ap, key, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filteredContent is a content stream with the selected content removed.
+type filteredContent struct {
+	ops     contentstream.ContentStreamOperations
+	removed bool // Whether operations were removed.
+
+	// Names of the resources used, by resource category. All the resources
+	// are used if `usesAll` is true.
+	used    map[core.PdfObjectName]map[core.PdfObjectName]struct{}
+	usesAll bool
+
+	// Rewritten form XObjects, by resource name.
+	forms map[core.PdfObjectName]*core.PdfObjectStream
+}
+
+// filterContent returns the operations of `contents` without the selected
+// content. `resources` are the resources of the content stream.
+func (rc *removalContext) filterContent(contents string, resources *model.PdfPageResources,
+	depth int) (*filteredContent, error) {
+	ops, err := contentstream.NewContentStreamParser(contents).Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	fc := &filteredContent{
+		used:  map[core.PdfObjectName]map[core.PdfObjectName]struct{}{},
+		forms: map[core.PdfObjectName]*core.PdfObjectStream{},
+	}
+
+	// Path object being constructed and whether it is used for clipping.
+	var path contentstream.ContentStreamOperations
+	clipping := false
+	endPath := func() {
+		fc.ops = append(fc.ops, path...)
+		path = nil
+		clipping = false
+	}
+
+	for _, op := range *ops {
+		switch op.Operand {
+		case "m", "l", "c", "v", "y", "h", "re":
+			path = append(path, op)
+			continue
+		case "W", "W*":
+			path = append(path, op)
+			clipping = true
+			continue
+		case "S", "s", "f", "F", "f*", "B", "B*", "b", "b*":
+			if rc.remove(ClassPath, op) {
+				fc.removed = true
+				if !clipping {
+					path = nil
+					continue
+				}
+				// The path still clips the following content.
+				op = &contentstream.ContentStreamOperation{Operand: "n"}
+			}
+			path = append(path, op)
+			endPath()
+			continue
+		case "n":
+			path = append(path, op)
+			endPath()
+			continue
+		}
+		// Path objects are ended by painting operators, but be lenient.
+		endPath()
+
+		switch op.Operand {
+		case "Tj", "TJ":
+			if rc.remove(ClassText, op) {
+				fc.removed = true
+				continue
+			}
+		case "'", "\"":
+			if rc.remove(ClassText, op) {
+				// Move to the next line, and set the spacing for ".
+				fc.removed = true
+				if op.Operand == "\"" && len(op.Params) == 3 {
+					fc.ops = append(fc.ops,
+						&contentstream.ContentStreamOperation{Operand: "Tw", Params: op.Params[:1]},
+						&contentstream.ContentStreamOperation{Operand: "Tc", Params: op.Params[1:2]})
+				}
+				fc.ops = append(fc.ops, &contentstream.ContentStreamOperation{Operand: "T*"})
+				continue
+			}
+		case "sh":
+			if rc.remove(ClassShading, op) {
+				fc.removed = true
+				continue
+			}
+		case "BI":
+			if rc.remove(ClassImage, op) {
+				fc.removed = true
+				continue
+			}
+		case "Do":
+			if len(op.Params) != 1 {
+				break
+			}
+			name, ok := core.GetName(op.Params[0])
+			if !ok {
+				break
+			}
+			stream, xtype := resources.GetXObjectByName(*name)
+			switch xtype {
+			case model.XObjectTypeImage:
+				if rc.remove(ClassImage, op) {
+					fc.removed = true
+					continue
+				}
+			case model.XObjectTypeForm:
+				filtered, inherited, err := rc.filterForm(stream, resources, depth)
+				if err != nil {
+					return nil, err
+				}
+				if filtered != stream {
+					fc.forms[*name] = filtered
+				}
+				if inherited != nil {
+					// The form uses the resources of the content stream.
+					fc.merge(inherited)
+				}
+			}
+		}
+		fc.use(op)
+		fc.ops = append(fc.ops, op)
+	}
+	endPath()
+
+	return fc, nil
+}
+
+// filterForm returns form XObject `stream` without the selected content, or
+// `stream` if nothing is removed. `resources` are the resources of the
+// content stream painting the form. If the form does not have resources, the
+// filtered content of the form is returned as well, as it uses `resources`.
+func (rc *removalContext) filterForm(stream *core.PdfObjectStream, resources *model.PdfPageResources,
+	depth int) (*core.PdfObjectStream, *filteredContent, error) {
+	if filtered, ok := rc.forms[stream]; ok {
+		return filtered, nil, nil
+	}
+
+	xform, err := model.NewXObjectFormFromStream(stream)
+	if err != nil {
+		return nil, nil, err
+	}
+	inherited := xform.Resources == nil
+	if depth >= maxFormDepth {
+		common.Log.Debug("ERROR: form XObjects nested too deep")
+		if inherited {
+			return stream, &filteredContent{usesAll: true}, nil
+		}
+		return stream, nil, nil
+	}
+
+	content, err := xform.GetContentStream()
+	if err != nil {
+		return nil, nil, err
+	}
+	formResources := xform.Resources
+	if inherited {
+		formResources = resources
+	}
+	fc, err := rc.filterContent(string(content), formResources, depth+1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filtered := stream
+	if fc.removed || (!inherited && len(fc.forms) > 0) {
+		// The entries of the form dictionary are kept, except those of the
+		// encoding of the original content.
+		data := content
+		if fc.removed {
+			data = fc.ops.Bytes()
+		}
+		filtered, err = core.MakeStream(data, core.NewFlateEncoder())
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, key := range stream.Keys() {
+			switch key {
+			case "Length", "Filter", "DecodeParms", "DL":
+			default:
+				filtered.Set(key, stream.Get(key))
+			}
+		}
+		if !inherited {
+			pruned, err := fc.pruneResources(xform.Resources)
+			if err != nil {
+				return nil, nil, err
+			}
+			filtered.Set("Resources", pruned.ToPdfObject())
+		}
+	}
+
+	if inherited {
+		// The result depends on the resources of the content stream.
+		return filtered, fc, nil
+	}
+	rc.forms[stream] = filtered
+	return filtered, nil, nil
+}
+
+// use records the resources used by `op`.
+func (fc *filteredContent) use(op *contentstream.ContentStreamOperation) {
+	var category core.PdfObjectName
+	var param core.PdfObject
+	switch op.Operand {
+	case "Tf":
+		category = "Font"
+		if len(op.Params) == 2 {
+			param = op.Params[0]
+		}
+	case "Do", "gs", "sh", "cs", "CS":
+		category = resourceOperators[op.Operand]
+		if len(op.Params) == 1 {
+			param = op.Params[0]
+		}
+	case "scn", "SCN":
+		// Pattern colors.
+		category = "Pattern"
+		if len(op.Params) > 0 {
+			param = op.Params[len(op.Params)-1]
+		}
+	case "BDC", "DP":
+		category = "Properties"
+		if len(op.Params) == 2 {
+			param = op.Params[1]
+		}
+	case "BI":
+		category = "ColorSpace"
+		if len(op.Params) == 1 {
+			if img, ok := op.Params[0].(*contentstream.ContentStreamInlineImage); ok {
+				param = img.ColorSpace
+			}
+		}
+	}
+	if name, ok := core.GetName(param); ok {
+		fc.addUsed(category, *name)
+	}
+}
+
+// resourceOperators are the categories of the resources named by the operand
+// of operators.
+var resourceOperators = map[string]core.PdfObjectName{
+	"Do": "XObject",
+	"gs": "ExtGState",
+	"sh": "Shading",
+	"cs": "ColorSpace",
+	"CS": "ColorSpace",
+}
+
+// addUsed records that the resource `name` of category `category` is used.
+func (fc *filteredContent) addUsed(category, name core.PdfObjectName) {
+	names, ok := fc.used[category]
+	if !ok {
+		names = map[core.PdfObjectName]struct{}{}
+		fc.used[category] = names
+	}
+	names[name] = struct{}{}
+}
+
+// merge records the resources used and the forms rewritten by `other`,
+// which uses the same resources as `fc`.
+func (fc *filteredContent) merge(other *filteredContent) {
+	fc.usesAll = fc.usesAll || other.usesAll
+	for category, names := range other.used {
+		for name := range names {
+			fc.addUsed(category, name)
+		}
+	}
+	for name, form := range other.forms {
+		fc.forms[name] = form
+	}
+}
+
+// resourceCategories are the categories of resources which are dropped when
+// not used.
+var resourceCategories = []core.PdfObjectName{
+	"ExtGState", "ColorSpace", "Pattern", "Shading", "XObject", "Font", "Properties",
+}
+
+// pruneResources returns new resources, made of the resources of `resources`
+// used by the content and the rewritten form XObjects.
+func (fc *filteredContent) pruneResources(resources *model.PdfPageResources) (*model.PdfPageResources, error) {
+	dict, ok := core.GetDict(resources.ToPdfObject())
+	if !ok {
+		return resources, nil
+	}
+	pruned := core.MakeDict()
+	for _, key := range dict.Keys() {
+		obj := dict.Get(key)
+		isCategory := false
+		for _, category := range resourceCategories {
+			isCategory = isCategory || key == category
+		}
+		entries, ok := core.GetDict(obj)
+		if !isCategory || !ok || fc.usesAll {
+			pruned.Set(key, obj)
+			continue
+		}
+		kept := core.MakeDict()
+		for _, name := range entries.Keys() {
+			if _, ok := fc.used[key][name]; ok {
+				kept.Set(name, entries.Get(name))
+			}
+		}
+		if len(kept.Keys()) > 0 {
+			pruned.Set(key, kept)
+		}
+	}
+
+	// The rewritten forms replace the originals.
+	if len(fc.forms) > 0 {
+		xobjects, ok := core.GetDict(pruned.Get("XObject"))
+		if !ok {
+			return nil, errors.New("invalid XObject resources")
+		}
+		if fc.usesAll {
+			// The dictionary of the original resources is shared.
+			xobjects = core.MakeDict().Merge(xobjects)
+			pruned.Set("XObject", xobjects)
+		}
+		for name, form := range fc.forms {
+			xobjects.Set(name, form)
+		}
+	}
+	return model.NewPdfPageResourcesFromDict(pruned)
+}
@@ -0,0 +1,302 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package remover
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/extractor"
+	"github.com/unidoc/unipdf/v3/internal/pageutil"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// testContents is the content stream of the test pages: a stroked path, text clipped by a filled
+// path, an image XObject, an inline image, a form XObject and a shading.
+const testContents = `q 1 0 0 RG 0 0 m 10 10 l S Q
+q 0 0 50 50 re W f
+BT /F1 12 Tf 10 10 Td (Hello) Tj (Next) ' 1 2 (Third) " ET
+Q
+q 20 0 0 20 0 0 cm /Im0 Do Q
+q BI /W 1 /H 1 /CS /G /BPC 8 ID ` + "\x80" + ` EI Q
+/Fm0 Do
+/Sh0 sh
+`
+
+// testForm returns a form XObject painting the image `img` and text with font `font`.
+func testForm(t *testing.T, img, font core.PdfObject) *core.PdfObjectStream {
+	form, err := core.MakeStream([]byte("q 10 0 0 10 0 0 cm /Im1 Do Q BT /F1 12 Tf (Form) Tj ET"), nil)
+	require.NoError(t, err)
+	form.Set("Type", core.MakeName("XObject"))
+	form.Set("Subtype", core.MakeName("Form"))
+	form.Set("BBox", core.MakeArrayFromFloats([]float64{0, 0, 100, 100}))
+	xobjects := core.MakeDict()
+	xobjects.Set("Im1", img)
+	fonts := core.MakeDict()
+	fonts.Set("F1", font)
+	resources := core.MakeDict()
+	resources.Set("XObject", xobjects)
+	resources.Set("Font", fonts)
+	form.Set("Resources", resources)
+	return form
+}
+
+// testPage returns a page with content `testContents` and form XObject `form`.
+func testPage(t *testing.T, form *core.PdfObjectStream) *model.PdfPage {
+	img, err := core.MakeStream([]byte{0x80}, nil)
+	require.NoError(t, err)
+	img.Set("Type", core.MakeName("XObject"))
+	img.Set("Subtype", core.MakeName("Image"))
+	img.Set("Width", core.MakeInteger(1))
+	img.Set("Height", core.MakeInteger(1))
+	img.Set("ColorSpace", core.MakeName("DeviceGray"))
+	img.Set("BitsPerComponent", core.MakeInteger(8))
+	font := model.NewStandard14FontMustCompile(model.HelveticaName).ToPdfObject()
+	if form == nil {
+		form = testForm(t, img, font)
+	}
+
+	shading := core.MakeDict()
+	shading.Set("ShadingType", core.MakeInteger(2))
+	shading.Set("ColorSpace", core.MakeName("DeviceGray"))
+	shading.Set("Coords", core.MakeArrayFromFloats([]float64{0, 0, 100, 0}))
+
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 200, Ury: 200}
+	require.NoError(t, page.Resources.SetFontByName("F1", font))
+	require.NoError(t, page.Resources.SetXObjectByName("Im0", img))
+	require.NoError(t, page.Resources.SetXObjectByName("Fm0", form))
+	require.NoError(t, page.Resources.SetShadingByName("Sh0", shading))
+	require.NoError(t, page.SetContentStreams([]string{testContents}, nil))
+	return page
+}
+
+// pageOperators returns the operators of the content streams of `page`.
+func pageOperators(t *testing.T, page *model.PdfPage) string {
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	return contentOperators(t, contents)
+}
+
+// contentOperators returns the operators of `contents`, separated by spaces.
+func contentOperators(t *testing.T, contents string) string {
+	ops, err := contentstream.NewContentStreamParser(contents).Parse()
+	require.NoError(t, err)
+	var operators []string
+	for _, op := range *ops {
+		operators = append(operators, op.Operand)
+	}
+	return strings.Join(operators, " ")
+}
+
+// resourceNames returns the names of the resources of `category` of `resources`.
+func resourceNames(resources *model.PdfPageResources, category core.PdfObjectName) []core.PdfObjectName {
+	dict, _ := core.GetDict(resources.ToPdfObject())
+	entries, ok := core.GetDict(dict.Get(category))
+	if !ok {
+		return nil
+	}
+	return entries.Keys()
+}
+
+// formContent returns the operators of form XObject `name` of `resources` and the form.
+func formContent(t *testing.T, resources *model.PdfPageResources, name core.PdfObjectName) (string, *model.XObjectForm) {
+	xform, err := resources.GetXObjectFormByName(name)
+	require.NoError(t, err)
+	require.NotNil(t, xform)
+	content, err := xform.GetContentStream()
+	require.NoError(t, err)
+	return contentOperators(t, string(content)), xform
+}
+
+// TestRemoveContent tests the removal of the classes of content from a page and its form XObject.
+func TestRemoveContent(t *testing.T) {
+	testCases := []struct {
+		classes  Class
+		count    int
+		expected string
+		form     string
+		xobjects []core.PdfObjectName
+	}{
+		{
+			classes:  ClassImage,
+			count:    3,
+			expected: "q RG m l S Q q re W f BT Tf Td Tj ' \" ET Q q cm Q q Q Do sh",
+			form:     "q cm Q BT Tf Tj ET",
+			xobjects: []core.PdfObjectName{"Fm0"},
+		},
+		{
+			classes:  ClassText,
+			count:    4,
+			expected: "q RG m l S Q q re W f BT Tf Td T* Tw Tc T* ET Q q cm Do Q q BI Q Do sh",
+			form:     "q cm Do Q BT Tf ET",
+			xobjects: []core.PdfObjectName{"Im0", "Fm0"},
+		},
+		{
+			// The clipping path is kept.
+			classes:  ClassVector,
+			count:    3,
+			expected: "q RG Q q re W n BT Tf Td Tj ' \" ET Q q cm Do Q q BI Q Do",
+			form:     "q cm Do Q BT Tf Tj ET",
+			xobjects: []core.PdfObjectName{"Im0", "Fm0"},
+		},
+	}
+
+	for _, tc := range testCases {
+		page := testPage(t, nil)
+		count, err := RemoveContent(page, &Options{Classes: tc.classes})
+		require.NoError(t, err)
+		require.Equal(t, tc.count, count, tc.classes)
+		operators := pageOperators(t, page)
+		require.Equal(t, tc.expected, operators, tc.classes)
+		require.Equal(t, strings.Count(operators, "q"), strings.Count(operators, "Q"), tc.classes)
+
+		require.Equal(t, tc.xobjects, resourceNames(page.Resources, "XObject"), tc.classes)
+		form, xform := formContent(t, page.Resources, "Fm0")
+		require.Equal(t, tc.form, form, tc.classes)
+		if tc.classes&ClassImage != 0 {
+			require.Nil(t, resourceNames(xform.Resources, "XObject"))
+		}
+		shadings := resourceNames(page.Resources, "Shading")
+		require.Equal(t, tc.classes&ClassShading == 0, len(shadings) == 1, tc.classes)
+	}
+}
+
+// TestRemoveContentFilter tests the removal of the operations selected by a filter.
+func TestRemoveContentFilter(t *testing.T) {
+	page := testPage(t, nil)
+	opts := &Options{
+		Classes: ClassText | ClassImage,
+		Filter: func(class Class, op *contentstream.ContentStreamOperation) bool {
+			// Inline images and the text shown by Tj.
+			return op.Operand == "BI" || op.Operand == "Tj"
+		},
+	}
+	count, err := RemoveContent(page, opts)
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+	require.Equal(t, "q RG m l S Q q re W f BT Tf Td ' \" ET Q q cm Do Q q Q Do sh", pageOperators(t, page))
+	form, _ := formContent(t, page.Resources, "Fm0")
+	require.Equal(t, "q cm Do Q BT Tf ET", form)
+}
+
+// TestRemoveDocumentContent tests that the form XObjects shared by pages are rewritten once.
+func TestRemoveDocumentContent(t *testing.T) {
+	page1 := testPage(t, nil)
+	form, _ := page1.Resources.GetXObjectByName("Fm0")
+	page2 := testPage(t, form)
+
+	count, err := RemoveDocumentContent([]*model.PdfPage{page1, page2}, &Options{Classes: ClassImage})
+	require.NoError(t, err)
+	// The image of the form is removed once.
+	require.Equal(t, 5, count)
+
+	form1, _ := page1.Resources.GetXObjectByName("Fm0")
+	form2, _ := page2.Resources.GetXObjectByName("Fm0")
+	require.True(t, form1 != form)
+	require.True(t, form1 == form2)
+	// The original form is unchanged.
+	content, err := core.DecodeStream(form)
+	require.NoError(t, err)
+	require.Equal(t, "q cm Do Q BT Tf Tj ET", contentOperators(t, string(content)))
+}
+
+// TestRemoveAnnotations tests the removal of annotations by subtype.
+func TestRemoveAnnotations(t *testing.T) {
+	page := testPage(t, nil)
+	link := model.NewPdfAnnotationLink()
+	link.Rect = core.MakeArrayFromFloats([]float64{0, 0, 10, 10})
+	text := model.NewPdfAnnotationText()
+	text.Rect = core.MakeArrayFromFloats([]float64{20, 20, 30, 30})
+	page.AddAnnotation(link.PdfAnnotation)
+	page.AddAnnotation(text.PdfAnnotation)
+
+	count, err := RemoveContent(page, &Options{Classes: ClassAnnotation, AnnotationSubtypes: []string{"Link"}})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	annotations, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annotations, 1)
+	require.Equal(t, "Text", pageutil.AnnotationSubtype(annotations[0]))
+	require.Equal(t, "q RG m l S Q q re W f BT Tf Td Tj ' \" ET Q q cm Do Q q BI Q Do sh",
+		pageOperators(t, page))
+
+	count, err = RemoveContent(page, &Options{Classes: ClassAnnotation})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	annotations, err = page.GetAnnotations()
+	require.NoError(t, err)
+	require.Empty(t, annotations)
+}
+
+// TestRemoveImagesFixture tests that removing the images of a document keeps its text and
+// shrinks it.
+func TestRemoveImagesFixture(t *testing.T) {
+	f, err := os.Open("../extractor/testdata/multi.pdf")
+	require.NoError(t, err)
+	defer f.Close()
+	reader, err := model.NewPdfReader(f)
+	require.NoError(t, err)
+	pages := reader.PageList
+
+	var texts []string
+	numImages := 0
+	for _, page := range pages {
+		text, images := extractPage(t, page)
+		texts = append(texts, text)
+		numImages += images
+	}
+	require.NotZero(t, numImages)
+	original := writePages(t, pages)
+
+	count, err := RemoveDocumentContent(pages, &Options{Classes: ClassImage})
+	require.NoError(t, err)
+	require.Equal(t, numImages, count)
+	stripped := writePages(t, pages)
+	require.Less(t, len(stripped), len(original))
+
+	reader, err = model.NewPdfReader(bytes.NewReader(stripped))
+	require.NoError(t, err)
+	require.Len(t, reader.PageList, len(pages))
+	for i, page := range reader.PageList {
+		text, images := extractPage(t, page)
+		require.Equal(t, texts[i], text, "page %d", i+1)
+		require.Zero(t, images, "page %d", i+1)
+	}
+}
+
+// extractPage returns the text of `page`, made of its text marks as the text of unlicensed
+// documents is truncated, and its number of images.
+func extractPage(t *testing.T, page *model.PdfPage) (string, int) {
+	ex, err := extractor.New(page)
+	require.NoError(t, err)
+	pageText, _, _, err := ex.ExtractPageText()
+	require.NoError(t, err)
+	var text strings.Builder
+	for _, mark := range pageText.Marks().Elements() {
+		text.WriteString(mark.Text)
+	}
+	images, err := ex.ExtractPageImages(nil)
+	require.NoError(t, err)
+	return text.String(), len(images.Images)
+}
+
+// writePages returns a document made of `pages`.
+func writePages(t *testing.T, pages []*model.PdfPage) []byte {
+	w := model.NewPdfWriter()
+	for _, page := range pages {
+		require.NoError(t, w.AddPage(page))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	return buf.Bytes()
+}
@@ -0,0 +1,95 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"github.com/adrg/sysfont"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+	"github.com/unidoc/unipdf/v3/render/internal/context"
+)
+
+// fontCache loads the text fonts of the fonts used by the content streams of a page, once per
+// font dictionary.
+type fontCache struct {
+	fonts  map[core.PdfObject]*context.TextFont
+	finder *sysfont.Finder
+}
+
+// newFontCache returns a new empty font cache.
+func newFontCache() *fontCache {
+	return &fontCache{fonts: map[core.PdfObject]*context.TextFont{}}
+}
+
+// textFont returns the text font of font dictionary `fontObj`. The glyphs of the fonts without
+// embedded program are drawn with those of a substitute font. nil is returned for Type3 fonts,
+// whose glyph descriptions are not drawn.
+func (c *fontCache) textFont(fontObj core.PdfObject) (*context.TextFont, error) {
+	if textFont, ok := c.fonts[fontObj]; ok {
+		return textFont, nil
+	}
+
+	fontDict, ok := core.GetDict(fontObj)
+	if !ok {
+		common.Log.Debug("ERROR: could not get font dict")
+		return nil, errType
+	}
+	pdfFont, err := model.NewPdfFontFromPdfObject(fontDict)
+	if pdfFont == nil {
+		common.Log.Debug("ERROR: could not load font from object")
+		return nil, err
+	}
+	if err != nil {
+		// Fonts not fully supported by the model, e.g. Type1C fonts, are returned with an error.
+		common.Log.Debug("Font %s loaded with error: %v", pdfFont, err)
+	}
+
+	var textFont *context.TextFont
+	if subtype, _ := core.GetNameVal(fontDict.Get("Subtype")); subtype != "Type3" {
+		textFont, err = context.NewTextFont(pdfFont, fontDict)
+		if err != nil {
+			common.Log.Debug("Could not load font program of %s: %v. Substituting it.", pdfFont, err)
+			if textFont, err = c.substitute(pdfFont); err != nil {
+				return nil, err
+			}
+		}
+	}
+	c.fonts[fontObj] = textFont
+	return textFont, nil
+}
+
+// substitute returns a text font drawing the glyphs of `font` with those of a system font
+// matching its name or, for the standard 14 fonts and the fonts which cannot be found, of a
+// bundled font.
+func (c *fontCache) substitute(font *model.PdfFont) (*context.TextFont, error) {
+	baseFont := font.BaseFont()
+	// Treat cases such as: OPEIOA+ArialMT
+	if len(baseFont) > 7 && baseFont[6] == '+' {
+		baseFont = baseFont[7:]
+	}
+
+	_, err := model.NewStandard14Font(model.StdFontName(baseFont))
+	if isStandard := err == nil; !isStandard && baseFont != "" {
+		if c.finder == nil {
+			c.finder = sysfont.NewFinder(&sysfont.FinderOpts{
+				Extensions: []string{".ttf", ".ttc"},
+			})
+		}
+		if fontInfo := c.finder.Match(baseFont); fontInfo != nil {
+			textFont, err := context.NewTextFontFromPath(font, fontInfo.Filename)
+			if err == nil {
+				common.Log.Debug("Substituting font %s with %s (%s)", baseFont, fontInfo.Name, fontInfo.Filename)
+				return textFont, nil
+			}
+			common.Log.Debug("could not load font file %s: %v", fontInfo.Filename, err)
+		}
+	}
+
+	common.Log.Debug("Substituting font %s with a bundled font", baseFont)
+	return context.NewBundledTextFont(font)
+}
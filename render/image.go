@@ -0,0 +1,176 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// xObjectToGoImage converts image XObject `ximg` to an RGB Go image, whose alpha channel is given
+// by the soft mask (SMask), the explicit stencil mask (Mask) or the color key mask (Mask) of the
+// image. Stencil mask (ImageMask) images are converted to images of color `fillColor`, whose alpha
+// channel is the stencil mask.
+func xObjectToGoImage(ximg *model.XObjectImage, fillColor color.NRGBA) (*image.NRGBA, error) {
+	img, err := ximg.ToImage()
+	if err != nil {
+		return nil, err
+	}
+	if ximg.IsImageMask() {
+		// The samples of stencil masks with value 0 are painted, unless inverted by the Decode
+		// array [1 0].
+		alpha := img.StencilMaskAlpha(model.IsDecodeInverted(ximg.Decode))
+		goImg := image.NewNRGBA(image.Rect(0, 0, int(img.Width), int(img.Height)))
+		for i, a := range alpha {
+			fillColor.A = a
+			goImg.SetNRGBA(i%int(img.Width), i/int(img.Width), fillColor)
+		}
+		return goImg, nil
+	}
+
+	goImg, err := imageToNRGBA(img, ximg.ColorSpace)
+	if err != nil {
+		return nil, err
+	}
+
+	if stream, ok := core.GetStream(ximg.SMask); ok {
+		if mask, err := streamToImage(stream); err == nil {
+			applyImageMask(goImg, mask, false)
+		} else {
+			common.Log.Debug("ERROR: could not load the image soft mask: %v", err)
+		}
+	} else if stream, ok := core.GetStream(ximg.Mask); ok {
+		// The samples of stencil masks with value 1 are masked out, unless inverted by the
+		// Decode array [1 0].
+		inverted := model.IsDecodeInverted(stream.Get("Decode"))
+		if mask, err := streamToImage(stream); err == nil {
+			applyImageMask(goImg, mask, !inverted)
+		} else {
+			common.Log.Debug("ERROR: could not load the image stencil mask: %v", err)
+		}
+	} else if ranges := ximg.GetColorKeyMask(); ranges != nil {
+		// The pixels whose samples match the color key mask are masked out.
+		alpha, err := img.ColorKeyMaskAlpha(ranges)
+		if err != nil {
+			common.Log.Debug("ERROR: could not apply the image color key mask: %v", err)
+			return goImg, nil
+		}
+		for i, a := range alpha {
+			if a == 0 {
+				goImg.Pix[4*i+3] = 0
+			}
+		}
+	}
+	return goImg, nil
+}
+
+// toNRGBA returns the opaque color of RGB components `r`, `g`, `b`.
+func toNRGBA(r, g, b float64) color.NRGBA {
+	toByte := func(v float64) uint8 {
+		return uint8(math.Round(255 * math.Max(0, math.Min(1, v))))
+	}
+	return color.NRGBA{R: toByte(r), G: toByte(g), B: toByte(b), A: 0xff}
+}
+
+// streamToImage returns the image of image XObject stream `stream`.
+func streamToImage(stream *core.PdfObjectStream) (*model.Image, error) {
+	ximg, err := model.NewXObjectImageFromStream(stream)
+	if err != nil {
+		return nil, err
+	}
+	if ximg.BitsPerComponent == nil {
+		// Stencil masks may omit BitsPerComponent, which is always 1.
+		bpc := int64(1)
+		ximg.BitsPerComponent = &bpc
+	}
+	return ximg.ToImage()
+}
+
+// imageToNRGBA converts image `img` of colorspace `cs` to an opaque RGB Go image. The colorspace
+// of the images without one is inferred from their number of color components.
+func imageToNRGBA(img *model.Image, cs model.PdfColorspace) (*image.NRGBA, error) {
+	if cs == nil {
+		switch img.ColorComponents {
+		case 1:
+			cs = model.NewPdfColorspaceDeviceGray()
+		case 4:
+			cs = model.NewPdfColorspaceDeviceCMYK()
+		default:
+			cs = model.NewPdfColorspaceDeviceRGB()
+		}
+	}
+	rgbImg, err := cs.ImageToRGB(*img)
+	if err != nil {
+		return nil, err
+	}
+	goImg, err := rgbImg.ToGoImage()
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := goImg.Bounds()
+	nrgba := image.NewNRGBA(bounds)
+	draw.Draw(nrgba, bounds, goImg, bounds.Min, draw.Src)
+	return nrgba, nil
+}
+
+// applyImageMask multiplies the alpha channel of `dst` by the samples of the one component image
+// `mask`, stretched to the size of `dst`. The samples are inverted for stencil masks, i.e. if
+// `stencil` is true, whose samples with value 1 are masked out.
+func applyImageMask(dst *image.NRGBA, mask *model.Image, stencil bool) {
+	bounds := dst.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	mw, mh := int(mask.Width), int(mask.Height)
+	if w == 0 || h == 0 || mw == 0 || mh == 0 {
+		return
+	}
+	for y := 0; y < h; y++ {
+		my := y * mh / h
+		for x := 0; x < w; x++ {
+			alpha := maskSample(mask, x*mw/w, my)
+			if stencil {
+				alpha = 1 - alpha
+			}
+			i := dst.PixOffset(bounds.Min.X+x, bounds.Min.Y+y) + 3
+			dst.Pix[i] = uint8(float64(dst.Pix[i])*alpha + 0.5)
+		}
+	}
+}
+
+// maskSample returns the value, in range 0-1, of the sample at `x`,`y` of the one component image
+// `img`, whose rows start at byte boundaries.
+func maskSample(img *model.Image, x, y int) float64 {
+	bpc := int(img.BitsPerComponent)
+	rowBytes := (int(img.Width)*bpc + 7) / 8
+	bit := x * bpc
+	i := y*rowBytes + bit/8
+	switch {
+	case bpc == 16:
+		if i+1 >= len(img.Data) {
+			return 1
+		}
+		return float64(uint16(img.Data[i])<<8|uint16(img.Data[i+1])) / 0xffff
+	case bpc == 8:
+		if i >= len(img.Data) {
+			return 1
+		}
+		return float64(img.Data[i]) / 0xff
+	case bpc == 1 || bpc == 2 || bpc == 4:
+		if i >= len(img.Data) {
+			return 1
+		}
+		maxVal := 1<<uint(bpc) - 1
+		shift := uint(8 - bit%8 - bpc)
+		return float64(int(img.Data[i]>>shift)&maxVal) / float64(maxVal)
+	}
+	return 1
+}
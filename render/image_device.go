@@ -9,20 +9,27 @@ import (
 	"errors"
 	"fmt"
 	"image"
-	"image/draw"
 	"image/jpeg"
 	"image/png"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/unidoc/unipdf/v3/model"
 	"github.com/unidoc/unipdf/v3/render/internal/context/imagerender"
+
+	"github.com/unidoc/unipdf/v3/internal/transform"
 )
 
 // ImageDevice is used to render PDF pages to image targets.
 type ImageDevice struct {
 	renderer
+
+	// DPI is the resolution of the rendered images, in pixels per inch. The
+	// default resolution of 72 DPI, i.e. one pixel per point, is used if it
+	// is not positive.
+	DPI float64
 }
 
 // NewImageDevice returns a new image device.
@@ -31,35 +38,62 @@ func NewImageDevice() *ImageDevice {
 }
 
 // Render converts the specified PDF page into an image and returns the result.
+// The visible region of the page, i.e. its crop box, is rendered at the
+// resolution of the device, rotated as specified by the page.
 func (d *ImageDevice) Render(page *model.PdfPage) (image.Image, error) {
 	// Get page dimensions.
-	mbox, err := page.GetMediaBox()
+	box, err := page.GetCropBox()
 	if err != nil {
 		return nil, err
 	}
 
-	// Render page.
-	width, height := mbox.Llx+mbox.Width(), mbox.Lly+mbox.Height()
+	scale := 1.0
+	if d.DPI > 0 {
+		scale = d.DPI / 72.0
+	}
 
-	ctx := imagerender.NewContext(int(width), int(height))
-	if err := d.renderPage(ctx, page); err != nil {
-		return nil, err
+	rotate := int64(0)
+	if page.Rotate != nil {
+		rotate = *page.Rotate % 360
+		if rotate < 0 {
+			rotate += 360
+		}
+	}
+
+	// Calculate the image size and the matrix mapping the default user space
+	// of the page to the pixels of the image, whose origin is at the top
+	// left corner.
+	width, height := box.Width()*scale, box.Height()*scale
+	var m transform.Matrix
+	switch rotate {
+	case 90:
+		width, height = height, width
+		m = transform.NewMatrix(0, scale, scale, 0, -box.Lly*scale, -box.Llx*scale)
+	case 180:
+		m = transform.NewMatrix(-scale, 0, 0, scale, box.Urx*scale, -box.Lly*scale)
+	case 270:
+		width, height = height, width
+		m = transform.NewMatrix(0, -scale, -scale, 0, box.Ury*scale, box.Urx*scale)
+	default:
+		m = transform.NewMatrix(scale, 0, 0, -scale, -box.Llx*scale, box.Ury*scale)
 	}
 
-	// Apply crop box, if one exists.
-	img := ctx.Image()
-	if box := page.CropBox; box != nil {
-		// Calculate crop bounds and crop start position.
-		cropBounds := image.Rect(0, 0, int(box.Width()), int(box.Height()))
-		cropStart := image.Pt(int(box.Llx), int(height-box.Ury))
-
-		// Crop image.
-		cropImg := image.NewRGBA(cropBounds)
-		draw.Draw(cropImg, cropBounds, img, cropStart, draw.Src)
-		img = cropImg
+	// Render page.
+	ctx := imagerender.NewContext(imageSize(width), imageSize(height))
+	if err := d.renderPage(ctx, page, m); err != nil {
+		return nil, err
 	}
 
-	return img, nil
+	return ctx.Image(), nil
+}
+
+// imageSize rounds image dimension `size` to a whole number of pixels, which
+// is at least 1.
+func imageSize(size float64) int {
+	if n := int(math.Round(size)); n > 1 {
+		return n
+	}
+	return 1
 }
 
 // RenderToPath converts the specified PDF page into an image and saves the
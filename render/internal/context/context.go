@@ -157,15 +157,10 @@ type Context interface {
 	// Text operations
 	//
 
-	// TextState returns the current text state.
+	// TextState returns the current text state. The text is drawn with the
+	// path operations.
 	TextState() *TextState
 
-	// DrawString renders the specified string and the specified position.
-	DrawString(s string, x, y float64)
-
-	// Measure string returns the width and height of the specified string.
-	MeasureString(s string) (w, h float64)
-
 	//
 	// Draw operations
 	//
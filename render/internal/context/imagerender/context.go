@@ -13,7 +13,6 @@ import (
 
 	"github.com/golang/freetype/raster"
 	"golang.org/x/image/draw"
-	"golang.org/x/image/font"
 	"golang.org/x/image/math/f64"
 
 	"github.com/unidoc/unipdf/v3/internal/transform"
@@ -78,6 +77,8 @@ func NewContextForRGBA(im *image.RGBA) *Context {
 		fillPattern:   defaultFillStyle,
 		strokePattern: defaultStrokeStyle,
 		lineWidth:     1,
+		lineCap:       context.LineCapButt,
+		lineJoin:      context.LineJoinBevel,
 		fillRule:      context.FillRuleWinding,
 		matrix:        transform.IdentityMatrix(),
 		textState:     context.NewTextState(),
@@ -369,10 +370,22 @@ func (dc *Context) joiner() raster.Joiner {
 	return nil
 }
 
+// strokeScale returns the factor scaling the line widths and dash lengths, which are in user
+// space units, to device pixels.
+func (dc *Context) strokeScale() float64 {
+	m := dc.matrix
+	return math.Sqrt(math.Abs(m[0]*m[4] - m[1]*m[3]))
+}
+
 func (dc *Context) stroke(painter raster.Painter) {
 	path := dc.strokePath
+	scale := dc.strokeScale()
 	if len(dc.dashes) > 0 {
-		path = dashed(path, dc.dashes, dc.dashOffset)
+		dashes := make([]float64, len(dc.dashes))
+		for i, dash := range dc.dashes {
+			dashes[i] = dash * scale
+		}
+		path = dashed(path, dashes, dc.dashOffset*scale)
 	} else {
 		// TODO: this is a temporary workaround to remove tiny segments
 		// that result in rendering issues
@@ -381,7 +394,10 @@ func (dc *Context) stroke(painter raster.Painter) {
 	r := dc.rasterizer
 	r.UseNonZeroWinding = true
 	r.Clear()
-	r.AddStroke(path, fix(dc.lineWidth), dc.capper(), dc.joiner())
+	// Lines are at least one pixel wide, which is also the width of the thinnest lines drawn
+	// for line width 0.
+	lineWidth := math.Max(dc.lineWidth*scale, 1)
+	r.AddStroke(path, fix(lineWidth), dc.capper(), dc.joiner())
 	r.Rasterize(painter)
 }
 
@@ -646,10 +662,16 @@ func (dc *Context) DrawImageAnchored(im image.Image, x, y int, ax, ay float64) {
 	s := im.Bounds().Size()
 	x -= int(ax * float64(s.X))
 	y -= int(ay * float64(s.Y))
-	transformer := draw.BiLinear
 	m := dc.matrix.Clone()
 	m.Translate(float64(x), float64(y))
 	s2d := f64.Aff3{m[0], m[3], m[6], m[1], m[4], m[7]}
+
+	// Enlarged images are drawn with sharp sample edges, as by PDF viewers for images which do
+	// not request interpolation.
+	var transformer draw.Transformer = draw.BiLinear
+	if math.Abs(m[0]*m[4]-m[1]*m[3]) > 1 {
+		transformer = draw.NearestNeighbor
+	}
 	if dc.mask == nil {
 		transformer.Transform(dc.im, s2d, im, im.Bounds(), draw.Over, nil)
 	} else {
@@ -669,71 +691,6 @@ func (dc *Context) TextState() *context.TextState {
 	return dc.textState
 }
 
-func (dc *Context) drawString(im *image.RGBA, s string, x, y float64) {
-	d := &font.Drawer{
-		Dst:  im,
-		Src:  image.NewUniform(dc.color),
-		Face: dc.textState.Tf.Face,
-		Dot:  fixedPoint(transform.NewPoint(x, y)),
-	}
-	// based on Drawer.DrawString() in golang.org/x/image/font/font.go
-	prevC := rune(-1)
-	for _, c := range s {
-		if prevC >= 0 {
-			d.Dot.X += d.Face.Kern(prevC, c)
-		}
-		dr, mask, maskp, advance, ok := d.Face.Glyph(d.Dot, c)
-		if !ok {
-			// TODO: is falling back on the U+FFFD glyph the responsibility of
-			// the Drawer or the Face?
-			// TODO: set prevC = '\ufffd'?
-			continue
-		}
-		sr := dr.Sub(dr.Min)
-		transformer := draw.BiLinear
-		m := dc.matrix.Clone()
-		m.Translate(float64(dr.Min.X), float64(dr.Min.Y))
-		s2d := f64.Aff3{m[0], m[3], m[6], m[1], m[4], m[7]}
-		transformer.Transform(d.Dst, s2d, d.Src, sr, draw.Over, &draw.Options{
-			SrcMask:  mask,
-			SrcMaskP: maskp,
-		})
-		d.Dot.X += advance
-		prevC = c
-	}
-}
-
-// DrawString draws the specified text at the specified point.
-func (dc *Context) DrawString(s string, x, y float64) {
-	dc.DrawStringAnchored(s, x, y, 0, 0)
-}
-
-// DrawStringAnchored draws the specified text at the specified anchor point.
-// The anchor point is x - w * ax, y - h * ay, where w, h is the size of the
-// text. Use ax=0.5, ay=0.5 to center the text at the specified point.
-func (dc *Context) DrawStringAnchored(s string, x, y, ax, ay float64) {
-	w, h := dc.MeasureString(s)
-	x -= ax * w
-	y += ay * h
-	if dc.mask == nil {
-		dc.drawString(dc.im, s, x, y)
-	} else {
-		im := image.NewRGBA(image.Rect(0, 0, dc.width, dc.height))
-		dc.drawString(im, s, x, y)
-		draw.DrawMask(dc.im, dc.im.Bounds(), im, image.ZP, dc.mask, image.ZP, draw.Over)
-	}
-}
-
-// MeasureString returns the rendered width and height of the specified text
-// given the current font face.
-func (dc *Context) MeasureString(s string) (w, h float64) {
-	d := &font.Drawer{
-		Face: dc.textState.Tf.Face,
-	}
-	a := d.MeasureString(s)
-	return float64(a >> 6), dc.textState.Tf.Size
-}
-
 //
 // Transformation matrix operations
 //
@@ -804,7 +761,9 @@ func (dc *Context) ShearAbout(sx, sy, x, y float64) {
 // Transform multiplies the specified point by the current matrix,
 // returning a transformed position.
 func (dc *Context) Transform(x, y float64) (tx, ty float64) {
-	return dc.matrix.Transform(x, y)
+	// The coordinates are transformed as the images drawn by DrawImageAnchored.
+	m := dc.matrix
+	return m[0]*x + m[3]*y + m[6], m[1]*x + m[4]*y + m[7]
 }
 
 //
@@ -815,11 +774,16 @@ func (dc *Context) Transform(x, y float64) (tx, ty float64) {
 // can be nested.
 func (dc *Context) Push() {
 	x := *dc
+	textState := *dc.textState
+	x.textState = &textState
 	dc.stack = append(dc.stack, &x)
 }
 
 // Pop restores the last saved context state from the stack.
 func (dc *Context) Pop() {
+	if len(dc.stack) == 0 {
+		return
+	}
 	before := *dc
 	s := dc.stack
 	x, s := s[len(s)-1], s[:len(s)-1]
@@ -830,5 +794,9 @@ func (dc *Context) Pop() {
 	dc.start = before.start
 	dc.current = before.current
 	dc.hasCurrent = before.hasCurrent
+
+	// The text state parameters are restored in place, the text state being shared with its
+	// users.
+	*before.textState = *dc.textState
 	dc.textState = before.textState
 }
@@ -6,134 +6,294 @@
 package context
 
 import (
+	"encoding/binary"
 	"errors"
+	"io/ioutil"
+	"strings"
 
-	"github.com/golang/freetype/truetype"
-	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
 
+	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
 	"github.com/unidoc/unipdf/v3/model"
 
 	"github.com/unidoc/unipdf/v3/internal/textencoding"
+	"github.com/unidoc/unipdf/v3/render/internal/fontfile"
 )
 
 // TextFont represents a font used to draw text to a target, through a
-// rendering context.
+// rendering context. The glyphs are drawn with the outlines of the font
+// program embedded in the PDF font or, for fonts without embedded program, of
+// a substitute font.
 type TextFont struct {
 	Font *model.PdfFont
-	Face font.Face
-	Size float64
 
-	ttf      *truetype.Font
-	origFont *model.PdfFont
+	sfnt      *fontfile.SFNT
+	cff       *fontfile.CFF
+	composite bool
+
+	// cidToGID maps the CIDs of composite TrueType fonts to glyphs. nil for
+	// the identity mapping.
+	cidToGID []fontfile.GID
+
+	// substitute is true if the glyphs are those of a substitute font,
+	// selected by the Unicode values of the character codes.
+	substitute bool
+
+	// glyphs caches the outlines of the glyphs drawn.
+	glyphs map[fontfile.GID]fontfile.Path
 }
 
-// NewTextFont returns a new text font instance based on the specified PDF font
-// and the specified font size.
-func NewTextFont(font *model.PdfFont, size float64) (*TextFont, error) {
-	descriptor := font.FontDescriptor()
-	if descriptor == nil {
-		return nil, errors.New("could not get font descriptor")
+// NewTextFont returns a new text font instance drawing the glyphs of the font
+// program embedded in PDF font `font` of font dictionary `fontDict`: TrueType
+// (FontFile2), CFF or OpenType (FontFile3) programs.
+func NewTextFont(font *model.PdfFont, fontDict *core.PdfObjectDictionary) (*TextFont, error) {
+	tf := &TextFont{Font: font}
+
+	// The font descriptor of composite fonts is that of their descendant
+	// CIDFont.
+	descendantDict := fontDict
+	if subtype, _ := core.GetNameVal(fontDict.Get("Subtype")); subtype == "Type0" {
+		descendants, ok := core.GetArray(fontDict.Get("DescendantFonts"))
+		if !ok || descendants.Len() == 0 {
+			return nil, errors.New("missing descendant font")
+		}
+		if descendantDict, ok = core.GetDict(descendants.Get(0)); !ok {
+			return nil, errors.New("invalid descendant font")
+		}
+		tf.composite = true
+
+		if stream, ok := core.GetStream(descendantDict.Get("CIDToGIDMap")); ok {
+			data, err := core.DecodeStream(stream)
+			if err != nil {
+				return nil, err
+			}
+			tf.cidToGID = make([]fontfile.GID, len(data)/2)
+			for i := range tf.cidToGID {
+				tf.cidToGID[i] = fontfile.GID(binary.BigEndian.Uint16(data[2*i:]))
+			}
+		}
 	}
 
-	fontStream, ok := core.GetStream(descriptor.FontFile2)
+	descriptor, ok := core.GetDict(descendantDict.Get("FontDescriptor"))
 	if !ok {
-		return nil, errors.New("missing font file stream")
+		return nil, errors.New("could not get font descriptor")
 	}
 
-	fontData, err := core.DecodeStream(fontStream)
-	if err != nil {
-		return nil, err
+	if stream, ok := core.GetStream(descriptor.Get("FontFile2")); ok {
+		data, err := core.DecodeStream(stream)
+		if err != nil {
+			return nil, err
+		}
+		if tf.sfnt, err = fontfile.ParseSFNT(data); err != nil {
+			return nil, err
+		}
+	} else if stream, ok := core.GetStream(descriptor.Get("FontFile3")); ok {
+		data, err := core.DecodeStream(stream)
+		if err != nil {
+			return nil, err
+		}
+		switch subtype, _ := core.GetNameVal(stream.Get("Subtype")); subtype {
+		case "OpenType":
+			tf.sfnt, err = fontfile.ParseSFNT(data)
+			if err == nil && tf.sfnt.CFF() != nil {
+				// The glyphs of OpenType fonts with PostScript outlines are
+				// selected as those of CFF fonts.
+				tf.cff, tf.sfnt = tf.sfnt.CFF(), nil
+			}
+		default:
+			// Type1C and CIDFontType0C.
+			tf.cff, err = fontfile.ParseCFF(data)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, errors.New("missing font file stream")
 	}
+	return tf, nil
+}
 
-	ttfFont, err := truetype.Parse(fontData)
+// NewTextFontFromPath returns a new text font instance drawing the glyphs of
+// PDF font `font` with those of the TrueType font file at `filePath`.
+func NewTextFontFromPath(font *model.PdfFont, filePath string) (*TextFont, error) {
+	data, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
+	return newSubstituteTextFont(font, data)
+}
 
-	if size <= 1 {
-		size = 10
-	}
+// NewBundledTextFont returns a new text font instance drawing the glyphs of
+// PDF font `font` with those of a font bundled with the renderer, of the same
+// style. It is used for the standard 14 fonts and the fonts without embedded
+// program which cannot be found on the system.
+func NewBundledTextFont(font *model.PdfFont) (*TextFont, error) {
+	name := strings.ToLower(font.BaseFont())
+	bold := strings.Contains(name, "bold")
+	italic := strings.Contains(name, "italic") || strings.Contains(name, "oblique")
 
-	return &TextFont{
-		Font: font,
-		Face: truetype.NewFace(ttfFont, &truetype.Options{Size: size}),
-		Size: size,
-		ttf:  ttfFont,
-	}, nil
+	var data []byte
+	switch mono := strings.Contains(name, "courier") || strings.Contains(name, "mono"); {
+	case mono && bold && italic:
+		data = gomonobolditalic.TTF
+	case mono && bold:
+		data = gomonobold.TTF
+	case mono && italic:
+		data = gomonoitalic.TTF
+	case mono:
+		data = gomono.TTF
+	case bold && italic:
+		data = gobolditalic.TTF
+	case bold:
+		data = gobold.TTF
+	case italic:
+		data = goitalic.TTF
+	default:
+		data = goregular.TTF
+	}
+	return newSubstituteTextFont(font, data)
 }
 
-// NewTextFontFromPath returns a new text font instance based on the specified
-// font file and the specified font size.
-func NewTextFontFromPath(filePath string, size float64) (*TextFont, error) {
-	font, err := model.NewPdfFontFromTTFFile(filePath)
+// newSubstituteTextFont returns a new text font instance drawing the glyphs of
+// PDF font `font` with those of TrueType font program `data`.
+func newSubstituteTextFont(font *model.PdfFont, data []byte) (*TextFont, error) {
+	sfnt, err := fontfile.ParseSFNT(data)
 	if err != nil {
 		return nil, err
 	}
-
-	return NewTextFont(font, size)
-}
-
-// WithSize returns a new text font instance based on the current text font,
-// with the specified font size.
-func (tf *TextFont) WithSize(size float64, originalFont *model.PdfFont) *TextFont {
-	if size <= 1 {
-		size = 10
-	}
-
 	return &TextFont{
-		Font:     tf.Font,
-		Face:     truetype.NewFace(tf.ttf, &truetype.Options{Size: size}),
-		Size:     size,
-		ttf:      tf.ttf,
-		origFont: originalFont,
-	}
+		Font:       font,
+		sfnt:       sfnt,
+		composite:  strings.HasPrefix(font.Subtype(), "Type0"),
+		substitute: true,
+	}, nil
 }
 
 // BytesToCharcodes converts the specified byte data to character codes, using
 // the encapsulated PDF font instance.
 func (tf *TextFont) BytesToCharcodes(data []byte) []textencoding.CharCode {
-	if tf.origFont != nil {
-		return tf.origFont.BytesToCharcodes(data)
-	}
-
 	return tf.Font.BytesToCharcodes(data)
 }
 
-// CharcodesToUnicode converts the specified character codes to a slice of
-// runes, using the encapsulated PDF font instance.
-func (tf *TextFont) CharcodesToUnicode(charcodes []textencoding.CharCode) []rune {
-	if tf.origFont != nil {
-		return tf.origFont.CharcodesToUnicode(charcodes)
-	}
-
-	return tf.Font.CharcodesToUnicode(charcodes)
+// IsComposite returns true if the encapsulated PDF font is a composite
+// (Type0) font.
+func (tf *TextFont) IsComposite() bool {
+	return tf.composite
 }
 
-// GetCharMetrics returns the metrics of the specified character code. The
-// character metrics are calculated by the internal PDF font.
-func (tf *TextFont) GetCharMetrics(code textencoding.CharCode) (float64, float64, bool) {
-	if metrics, ok := tf.Font.GetCharMetrics(code); ok && metrics.Wx != 0 {
-		return metrics.Wx, metrics.Wy, ok
+// Glyph returns the outline of the glyph of character code `code` in text
+// space units, i.e. for a font size of 1, and its advance width. The advance
+// width is that of the PDF font, or that of the font program if the PDF font
+// does not define it. The path is empty for the codes which have no glyph.
+func (tf *TextFont) Glyph(code textencoding.CharCode) (fontfile.Path, float64) {
+	gid, found := tf.gid(code)
+	var path fontfile.Path
+	if found {
+		path = tf.glyphPath(gid)
 	}
-	if tf.origFont == nil {
-		return 0, 0, false
+	if metrics, ok := tf.Font.GetCharMetrics(code); ok && metrics.Wx > 0 {
+		return path, metrics.Wx / 1000
 	}
+	switch {
+	case !found:
+		return path, 0
+	case tf.cff != nil:
+		return path, tf.cff.Advance(gid)
+	}
+	return path, tf.sfnt.Advance(gid)
+}
 
-	metrics, ok := tf.origFont.GetCharMetrics(code)
-	return metrics.Wx, metrics.Wy, ok && metrics.Wx != 0
+// glyphPath returns the outline of glyph `gid` of the font program, in text
+// space units.
+func (tf *TextFont) glyphPath(gid fontfile.GID) fontfile.Path {
+	if path, ok := tf.glyphs[gid]; ok {
+		return path
+	}
+	var path fontfile.Path
+	var err error
+	if tf.cff != nil {
+		path, err = tf.cff.GlyphPath(gid)
+	} else {
+		path, err = tf.sfnt.GlyphPath(gid)
+	}
+	if err != nil {
+		common.Log.Debug("ERROR: could not get glyph %d of %s: %v", gid, tf.Font, err)
+		path = nil
+	}
+	if tf.glyphs == nil {
+		tf.glyphs = map[fontfile.GID]fontfile.Path{}
+	}
+	tf.glyphs[gid] = path
+	return path
 }
 
-// GetRuneMetrics returns the metrics of the specified rune. The character
-// metrics are calculated by the internal PDF font.
-func (tf *TextFont) GetRuneMetrics(r rune) (float64, float64, bool) {
-	if metrics, ok := tf.Font.GetRuneMetrics(r); ok && metrics.Wx != 0 {
-		return metrics.Wx, metrics.Wy, ok
+// gid returns the glyph of character code `code` in the font program.
+// See section 9.6.6.4 "Encodings for TrueType Fonts" and 9.7.4.2 "Glyph
+// Selection in CIDFonts" (PDF32000_2008).
+func (tf *TextFont) gid(code textencoding.CharCode) (fontfile.GID, bool) {
+	switch {
+	case tf.substitute:
+		runes := tf.Font.CharcodesToUnicode([]textencoding.CharCode{code})
+		if len(runes) == 0 {
+			return 0, false
+		}
+		if gid, ok := tf.sfnt.Lookup(fontfile.CmapID{Platform: 3, Encoding: 1}, uint32(runes[0])); ok {
+			return gid, true
+		}
+		return tf.sfnt.Lookup(fontfile.CmapID{Platform: 0, Encoding: 3}, uint32(runes[0]))
+	case tf.cff != nil && tf.composite:
+		return tf.cff.GIDByCID(uint16(code))
+	case tf.cff != nil:
+		if r, ok := tf.codeToRune(code); ok {
+			if name, ok := textencoding.RuneToGlyph(r); ok {
+				if gid, ok := tf.cff.GIDByName(string(name)); ok {
+					return gid, true
+				}
+			}
+		}
+		return tf.cff.GIDByCode(byte(code))
+	case tf.composite:
+		if tf.cidToGID == nil {
+			return fontfile.GID(code), int(code) < tf.sfnt.NumGlyphs()
+		}
+		if int(code) < len(tf.cidToGID) {
+			return tf.cidToGID[code], true
+		}
+		return 0, false
 	}
-	if tf.origFont == nil {
-		return 0, 0, false
+
+	// Simple TrueType fonts.
+	symbol := fontfile.CmapID{Platform: 3, Encoding: 0}
+	for _, c := range []uint32{uint32(code), 0xf000 | uint32(code)} {
+		if gid, ok := tf.sfnt.Lookup(symbol, c); ok {
+			return gid, true
+		}
+	}
+	if r, ok := tf.codeToRune(code); ok {
+		if gid, ok := tf.sfnt.Lookup(fontfile.CmapID{Platform: 3, Encoding: 1}, uint32(r)); ok {
+			return gid, true
+		}
+	}
+	if gid, ok := tf.sfnt.Lookup(fontfile.CmapID{Platform: 1, Encoding: 0}, uint32(code)); ok {
+		return gid, true
 	}
+	return fontfile.GID(code), int(code) < tf.sfnt.NumGlyphs()
+}
 
-	metrics, ok := tf.origFont.GetRuneMetrics(r)
-	return metrics.Wx, metrics.Wy, ok && metrics.Wx != 0
+// codeToRune returns the rune of character code `code` in the encoding of the
+// encapsulated simple PDF font.
+func (tf *TextFont) codeToRune(code textencoding.CharCode) (rune, bool) {
+	encoder := tf.Font.Encoder()
+	if encoder == nil {
+		return 0, false
+	}
+	return encoder.CharcodeToRune(code)
 }
@@ -7,21 +7,62 @@ package context
 
 import (
 	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/render/internal/fontfile"
 )
 
+// TextRenderingMode determines whether the glyphs of the text are filled,
+// stroked and/or added to the clipping path.
+//
+// See section 9.3.6 "Text Rendering Mode" and Table 106 (p. 254 PDF32000_2008).
+type TextRenderingMode int
+
+// Text rendering modes.
+const (
+	TextRenderingModeFill TextRenderingMode = iota
+	TextRenderingModeStroke
+	TextRenderingModeFillStroke
+	TextRenderingModeInvisible
+	TextRenderingModeFillClip
+	TextRenderingModeStrokeClip
+	TextRenderingModeFillStrokeClip
+	TextRenderingModeClip
+)
+
+// fills returns true if the glyphs are filled in rendering mode `mode`.
+func (mode TextRenderingMode) fills() bool {
+	switch mode {
+	case TextRenderingModeFill, TextRenderingModeFillStroke,
+		TextRenderingModeFillClip, TextRenderingModeFillStrokeClip:
+		return true
+	}
+	return false
+}
+
+// strokes returns true if the glyphs are stroked in rendering mode `mode`.
+func (mode TextRenderingMode) strokes() bool {
+	switch mode {
+	case TextRenderingModeStroke, TextRenderingModeFillStroke,
+		TextRenderingModeStrokeClip, TextRenderingModeFillStrokeClip:
+		return true
+	}
+	return false
+}
+
 // TextState holds a representation of a PDF text state. The text state
 // processes different text related operations which may occur in PDF content
 // streams. It is used as a part of a renderding context in order to manipulate
 // and display text.
 type TextState struct {
-	Tc  float64          // Character spacing.
-	Tw  float64          // Word spacing.
-	Th  float64          // Horizontal scaling.
-	Tl  float64          // Leading.
-	Tf  *TextFont        // Font
-	Ts  float64          // Text rise.
-	Tm  transform.Matrix // Text matrix.
-	Tlm transform.Matrix // Text line matrix.
+	Tc  float64           // Character spacing.
+	Tw  float64           // Word spacing.
+	Th  float64           // Horizontal scaling.
+	Tl  float64           // Leading.
+	Tf  *TextFont         // Font
+	Tfs float64           // Font size.
+	Tr  TextRenderingMode // Text rendering mode.
+	Ts  float64           // Text rise.
+	Tm  transform.Matrix  // Text matrix.
+	Tlm transform.Matrix  // Text line matrix.
 }
 
 // NewTextState returns a new TextState instance.
@@ -38,7 +79,7 @@ func NewTextState() *TextState {
 // See section 9.4.2 "Text Positioning Operators" and
 // Table 108 (pp. 257-258 PDF32000_2008).
 func (ts *TextState) ProcTm(a, b, c, d, e, f float64) {
-	ts.Tm = transform.NewMatrix(a, b, c, d, e, -f)
+	ts.Tm = transform.NewMatrix(a, b, c, d, e, f)
 	ts.Tlm = ts.Tm.Clone()
 }
 
@@ -48,7 +89,7 @@ func (ts *TextState) ProcTm(a, b, c, d, e, f float64) {
 // See section 9.4.2 "Text Positioning Operators" and
 // Table 108 (pp. 257-258 PDF32000_2008).
 func (ts *TextState) ProcTd(tx, ty float64) {
-	ts.Tlm.Concat(transform.TranslationMatrix(tx, -ty))
+	ts.Tlm.Concat(transform.TranslationMatrix(tx, ty))
 	ts.Tm = ts.Tlm.Clone()
 }
 
@@ -71,51 +112,79 @@ func (ts *TextState) ProcTStar() {
 	ts.ProcTd(0, -ts.Tl)
 }
 
-// ProcTj processes a `Tj` operation, which displays a text string.
+// ProcTj processes a `Tj` operation, which displays a text string. The glyphs
+// are drawn as paths, filled and/or stroked with the current colors of `ctx`
+// according to the text rendering mode.
 //
 // See section 9.4.3 "Text Showing Operators" and
 // Table 209 (pp. 258-259 PDF32000_2008).
 func (ts *TextState) ProcTj(data []byte, ctx Context) {
-	tfs := ts.Tf.Size
+	if ts.Tf == nil {
+		return
+	}
 	th := ts.Th / 100.0
-	stateMatrix := transform.NewMatrix(tfs*th, 0, 0, tfs, 0, ts.Ts)
-
-	runes := ts.Tf.CharcodesToUnicode(ts.Tf.BytesToCharcodes(data))
-	for _, r := range runes {
-		if r == '\x00' {
-			continue
-		}
+	stateMatrix := transform.NewMatrix(ts.Tfs*th, 0, 0, ts.Tfs, 0, ts.Ts)
+	paint := ts.Tr.fills() || ts.Tr.strokes()
 
+	ctx.ClearPath()
+	drawn := false
+	for _, code := range ts.Tf.BytesToCharcodes(data) {
 		// Calculate text rendering matrix.
-		tm := ts.Tm.Clone()
-		ts.Tm.Concat(stateMatrix)
+		trm := ts.Tm.Mult(stateMatrix)
 
-		// Draw rune.
-		x, y := ts.Tm.Transform(0, 0)
-		ctx.Scale(1, -1)
-		ctx.DrawString(string(r), x, y)
-		ctx.Scale(1, -1)
+		path, w := ts.Tf.Glyph(code)
+		if paint && len(path) > 0 {
+			addGlyphPath(ctx, path.Transform(trm))
+			drawn = true
+		}
 
-		// Calculate word spacing.
+		// Calculate word spacing, which applies to the single byte code 32.
 		tw := 0.0
-		if r == ' ' {
+		if code == 32 && !ts.Tf.IsComposite() {
 			tw = ts.Tw
 		}
 
-		// Calculate rune spacing.
-		var w float64
-		if wX, _, ok := ts.Tf.GetRuneMetrics(r); ok {
-			w = wX * 0.001 * tfs
-		} else {
-			w, _ = ctx.MeasureString(string(r))
-		}
-
 		// Calculate displacement offset.
-		tx := (w + ts.Tc + tw) * th
+		tx := (w*ts.Tfs + ts.Tc + tw) * th
+		ts.Translate(tx, 0)
+	}
+	if !drawn {
+		return
+	}
 
-		// Generate new text matrix.
-		ts.Tm = transform.TranslationMatrix(tx, 0).Mult(tm)
+	// Glyphs are filled with the nonzero winding number rule.
+	ctx.Push()
+	ctx.SetFillRule(FillRuleWinding)
+	if ts.Tr.fills() {
+		ctx.FillPreserve()
+	}
+	if ts.Tr.strokes() {
+		ctx.StrokePreserve()
+	}
+	ctx.ClearPath()
+	ctx.Pop()
+}
+
+// addGlyphPath adds the outline of a glyph `path`, in user space, to the
+// current path of `ctx`.
+func addGlyphPath(ctx Context, path fontfile.Path) {
+	for _, seg := range path {
+		p := seg.Points
+		switch seg.Op {
+		case fontfile.SegmentMoveTo:
+			ctx.ClosePath()
+			ctx.NewSubPath()
+			ctx.MoveTo(p[0].X, p[0].Y)
+		case fontfile.SegmentLineTo:
+			ctx.LineTo(p[0].X, p[0].Y)
+		case fontfile.SegmentQuadTo:
+			ctx.QuadraticTo(p[0].X, p[0].Y, p[1].X, p[1].Y)
+		case fontfile.SegmentCubeTo:
+			ctx.CubicTo(p[0].X, p[0].Y, p[1].X, p[1].Y, p[2].X, p[2].Y)
+		}
 	}
+	ctx.ClosePath()
+	ctx.NewSubPath()
 }
 
 // ProcQ processes a `'` operation, which advances the text state to a new line
@@ -144,13 +213,15 @@ func (ts *TextState) ProcDQ(data []byte, aw, ac float64, ctx Context) {
 //
 // See section 9.3 "Text State Parameters and Operators" and
 // Table 105 (pp. 251-252 PDF32000_2008).
-func (ts *TextState) ProcTf(font *TextFont) {
+func (ts *TextState) ProcTf(font *TextFont, size float64) {
 	ts.Tf = font
+	ts.Tfs = size
 }
 
-// Translate translates the current text matrix with `tx`,`ty`.
+// Translate translates the current text matrix with `tx`,`ty`, in text space
+// units.
 func (ts *TextState) Translate(tx, ty float64) {
-	ts.Tm = transform.TranslationMatrix(tx, ty).Mult(ts.Tm)
+	ts.Tm.Concat(transform.TranslationMatrix(tx, ty))
 }
 
 // Reset resets both the text matrix and the line matrix.
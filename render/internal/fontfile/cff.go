@@ -0,0 +1,556 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fontfile
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/unidoc/unipdf/v3/internal/textencoding"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+)
+
+// CFF DICT operators. The two byte operators starting with escape byte 12 are numbered 1200 + the
+// second byte.
+const (
+	cffOpCharset     = 15
+	cffOpEncoding    = 16
+	cffOpCharStrings = 17
+	cffOpPrivate     = 18
+	cffOpSubrs       = 19
+	cffOpDefaultWX   = 20
+	cffOpNominalWX   = 21
+	cffOpFontMatrix  = 1207
+	cffOpROS         = 1230
+	cffOpFDArray     = 1236
+	cffOpFDSelect    = 1237
+)
+
+// CFF is a Compact Font Format font program (Adobe Technical Note #5176), as embedded in FontFile3
+// streams with subtypes Type1C and CIDFontType0C. Only the first font of the FontSet is parsed.
+type CFF struct {
+	charStrings [][]byte
+	globalSubrs [][]byte
+	strings     [][]byte // String INDEX.
+	fontMatrix  transform.Matrix
+
+	// isCID is true for CIDFonts, whose charset maps the glyphs to CIDs instead of names.
+	isCID    bool
+	charset  []uint16 // SID, or CID for CIDFonts, of each glyph. nil if unknown.
+	encoding map[byte]GID
+	byName   map[string]GID
+	byCID    map[uint16]GID
+
+	private  *cffPrivate
+	fdArray  []*cffPrivate
+	fdSelect []byte // Index in fdArray of each glyph.
+}
+
+// cffPrivate is the information of a Private DICT needed to run the charstrings.
+type cffPrivate struct {
+	subrs         [][]byte
+	defaultWidthX float64
+	nominalWidthX float64
+}
+
+// cffDict is a parsed CFF DICT, mapping the operators to their operands.
+type cffDict map[int][]float64
+
+func (d cffDict) number(op int, def float64) float64 {
+	if operands := d[op]; len(operands) > 0 {
+		return operands[0]
+	}
+	return def
+}
+
+// ParseCFF parses the CFF font program `data`.
+func ParseCFF(data []byte) (*CFF, error) {
+	r := reader{data: data}
+	pos := int(r.u8(2)) // hdrSize
+	if r.err != nil || r.u8(0) != 1 {
+		return nil, fmt.Errorf("%w: unsupported CFF header", errInvalidFont)
+	}
+	_, pos, err := parseCFFIndex(data, pos) // Name INDEX
+	if err != nil {
+		return nil, err
+	}
+	topDicts, pos, err := parseCFFIndex(data, pos)
+	if err != nil {
+		return nil, err
+	}
+	if len(topDicts) == 0 {
+		return nil, fmt.Errorf("%w: no Top DICT", errInvalidFont)
+	}
+	font := &CFF{}
+	font.strings, pos, err = parseCFFIndex(data, pos)
+	if err != nil {
+		return nil, err
+	}
+	font.globalSubrs, _, err = parseCFFIndex(data, pos)
+	if err != nil {
+		return nil, err
+	}
+
+	top, err := parseCFFDict(topDicts[0])
+	if err != nil {
+		return nil, err
+	}
+	font.fontMatrix = transform.ScaleMatrix(0.001, 0.001)
+	if m := top[cffOpFontMatrix]; len(m) == 6 {
+		font.fontMatrix = transform.NewMatrix(m[0], m[1], m[2], m[3], m[4], m[5])
+	}
+	font.charStrings, _, err = parseCFFIndex(data, int(top.number(cffOpCharStrings, -1)))
+	if err != nil {
+		return nil, err
+	}
+	if len(font.charStrings) == 0 {
+		return nil, fmt.Errorf("%w: no CharStrings", errInvalidFont)
+	}
+	font.charset, err = font.parseCharset(data, int(top.number(cffOpCharset, 0)))
+	if err != nil {
+		return nil, err
+	}
+
+	_, font.isCID = top[cffOpROS]
+	if font.isCID {
+		font.byCID = make(map[uint16]GID, len(font.charset))
+		for gid, cid := range font.charset {
+			font.byCID[cid] = GID(gid)
+		}
+		if err := font.parseFDs(data, top); err != nil {
+			return nil, err
+		}
+		return font, nil
+	}
+
+	font.byName = make(map[string]GID, len(font.charset))
+	for gid, sid := range font.charset {
+		if name, ok := font.sidString(sid); ok {
+			font.byName[name] = GID(gid)
+		}
+	}
+	font.encoding, err = font.parseEncoding(data, int(top.number(cffOpEncoding, 0)))
+	if err != nil {
+		return nil, err
+	}
+	font.private, err = parseCFFPrivate(data, top)
+	if err != nil {
+		return nil, err
+	}
+	return font, nil
+}
+
+// NumGlyphs returns the number of glyphs of `font`.
+func (font *CFF) NumGlyphs() int {
+	return len(font.charStrings)
+}
+
+// IsCID returns true if `font` is a CIDFont, whose glyphs are selected by CID.
+func (font *CFF) IsCID() bool {
+	return font.isCID
+}
+
+// GIDByName returns the glyph named `name`. The bool return flag is false if there is no such
+// glyph.
+func (font *CFF) GIDByName(name string) (GID, bool) {
+	gid, ok := font.byName[name]
+	return gid, ok
+}
+
+// GIDByCID returns the glyph of CID `cid` of a CIDFont. For other fonts, the CID is the glyph
+// index.
+func (font *CFF) GIDByCID(cid uint16) (GID, bool) {
+	if !font.isCID {
+		return GID(cid), int(cid) < len(font.charStrings)
+	}
+	gid, ok := font.byCID[cid]
+	return gid, ok
+}
+
+// GIDByCode returns the glyph of character code `code` in the built-in encoding of the font,
+// falling back to StandardEncoding for the codes it does not map.
+func (font *CFF) GIDByCode(code byte) (GID, bool) {
+	if gid, ok := font.encoding[code]; ok {
+		return gid, true
+	}
+	return font.gidByStandardCode(code)
+}
+
+// gidByStandardCode returns the glyph of character code `code` in StandardEncoding.
+func (font *CFF) gidByStandardCode(code byte) (GID, bool) {
+	r, ok := textencoding.NewStandardEncoder().CharcodeToRune(textencoding.CharCode(code))
+	if !ok {
+		return 0, false
+	}
+	name, ok := textencoding.RuneToGlyph(r)
+	if !ok {
+		return 0, false
+	}
+	return font.GIDByName(string(name))
+}
+
+// GlyphPath returns the outline of glyph `gid` in text space units.
+func (font *CFF) GlyphPath(gid GID) (Path, error) {
+	path, _, err := font.runCharString(gid, font.fontMatrix, 0)
+	return path, err
+}
+
+// Advance returns the advance width of glyph `gid` in text space units.
+func (font *CFF) Advance(gid GID) float64 {
+	_, width, err := font.runCharString(gid, font.fontMatrix, 0)
+	if err != nil {
+		return 0
+	}
+	return transformPoint(font.fontMatrix, width, 0).X - transformPoint(font.fontMatrix, 0, 0).X
+}
+
+// runCharString runs the charstring of glyph `gid` and returns its outline transformed by `m`
+// and its advance width in glyph space units. `depth` is the nesting level of the accented
+// characters.
+func (font *CFF) runCharString(gid GID, m transform.Matrix, depth int) (Path, float64, error) {
+	if int(gid) >= len(font.charStrings) {
+		return nil, 0, fmt.Errorf("%w: gid %d out of range", errInvalidGlyph, gid)
+	}
+	private := font.private
+	if font.isCID {
+		if int(gid) >= len(font.fdSelect) || int(font.fdSelect[gid]) >= len(font.fdArray) {
+			return nil, 0, fmt.Errorf("%w: no FD for gid %d", errInvalidGlyph, gid)
+		}
+		private = font.fdArray[font.fdSelect[gid]]
+	}
+	interp := &charStringInterp{
+		font:    font,
+		private: private,
+		b:       &pathBuilder{scale: m},
+		width:   private.defaultWidthX,
+	}
+	if err := interp.run(font.charStrings[gid], 0); err != nil {
+		return nil, 0, err
+	}
+	path := interp.b.path
+	if interp.seac != nil {
+		// Accented character made of a base and an accent glyphs of StandardEncoding.
+		if depth > 0 {
+			return nil, 0, fmt.Errorf("%w: nested seac", errInvalidGlyph)
+		}
+		adx, ady := interp.seac[0], interp.seac[1]
+		base, ok1 := font.gidByStandardCode(byte(interp.seac[2]))
+		accent, ok2 := font.gidByStandardCode(byte(interp.seac[3]))
+		if !ok1 || !ok2 {
+			return nil, 0, fmt.Errorf("%w: invalid seac components", errInvalidGlyph)
+		}
+		basePath, _, err := font.runCharString(base, m, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		accentPath, _, err := font.runCharString(accent, m.Mult(transform.TranslationMatrix(adx, ady)), depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		path = append(append(path, basePath...), accentPath...)
+	}
+	return path, interp.width, nil
+}
+
+// sidString returns the string identified by `sid`.
+func (font *CFF) sidString(sid uint16) (string, bool) {
+	if int(sid) < len(cffStandardStrings) {
+		return cffStandardStrings[sid], true
+	}
+	i := int(sid) - len(cffStandardStrings)
+	if i < len(font.strings) {
+		return string(font.strings[i]), true
+	}
+	return "", false
+}
+
+// parseCharset parses the charset at `offset` in `data`, or the predefined charset identified by
+// `offset`.
+func (font *CFF) parseCharset(data []byte, offset int) ([]uint16, error) {
+	numGlyphs := len(font.charStrings)
+	switch offset {
+	case 0: // ISOAdobe
+		charset := make([]uint16, numGlyphs)
+		for gid := range charset {
+			charset[gid] = uint16(gid)
+		}
+		return charset, nil
+	case 1, 2: // Expert and ExpertSubset are not supported.
+		return nil, nil
+	}
+
+	r := reader{data: data}
+	charset := make([]uint16, 1, numGlyphs) // .notdef
+	format := r.u8(offset)
+	pos := offset + 1
+	for len(charset) < numGlyphs && r.err == nil {
+		switch format {
+		case 0:
+			charset = append(charset, r.u16(pos))
+			pos += 2
+		case 1, 2:
+			first := int(r.u16(pos))
+			var nLeft int
+			if format == 1 {
+				nLeft = int(r.u8(pos + 2))
+				pos += 3
+			} else {
+				nLeft = int(r.u16(pos + 2))
+				pos += 4
+			}
+			for i := 0; i <= nLeft && len(charset) < numGlyphs; i++ {
+				charset = append(charset, uint16(first+i))
+			}
+		default:
+			return nil, fmt.Errorf("%w: unsupported charset format %d", errInvalidFont, format)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return charset, nil
+}
+
+// parseEncoding parses the encoding at `offset` in `data`. nil is returned for the predefined
+// encodings identified by `offset`: StandardEncoding, and ExpertEncoding which is not supported.
+func (font *CFF) parseEncoding(data []byte, offset int) (map[byte]GID, error) {
+	if offset <= 1 {
+		return nil, nil
+	}
+	r := reader{data: data}
+	encoding := map[byte]GID{}
+	format := r.u8(offset)
+	pos := offset + 1
+	switch format & 0x7f {
+	case 0:
+		nCodes := int(r.u8(pos))
+		for i := 0; i < nCodes; i++ {
+			encoding[r.u8(pos+1+i)] = GID(i + 1)
+		}
+		pos += 1 + nCodes
+	case 1:
+		nRanges := int(r.u8(pos))
+		gid := 1
+		for i := 0; i < nRanges; i++ {
+			first, nLeft := int(r.u8(pos+1+2*i)), int(r.u8(pos+2+2*i))
+			for code := first; code <= first+nLeft && code < 256; code++ {
+				encoding[byte(code)] = GID(gid)
+				gid++
+			}
+		}
+		pos += 1 + 2*nRanges
+	default:
+		return nil, fmt.Errorf("%w: unsupported encoding format %d", errInvalidFont, format)
+	}
+	if format&0x80 != 0 {
+		// Supplements encoding additional codes by glyph name.
+		nSups := int(r.u8(pos))
+		for i := 0; i < nSups; i++ {
+			code, sid := r.u8(pos+1+3*i), r.u16(pos+2+3*i)
+			if name, ok := font.sidString(sid); ok {
+				if gid, ok := font.byName[name]; ok {
+					encoding[code] = gid
+				}
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return encoding, nil
+}
+
+// parseFDs parses the FDArray and FDSelect of CIDFont `font` with Top DICT `top`.
+func (font *CFF) parseFDs(data []byte, top cffDict) error {
+	fdDicts, _, err := parseCFFIndex(data, int(top.number(cffOpFDArray, -1)))
+	if err != nil {
+		return err
+	}
+	for _, fdData := range fdDicts {
+		fd, err := parseCFFDict(fdData)
+		if err != nil {
+			return err
+		}
+		private, err := parseCFFPrivate(data, fd)
+		if err != nil {
+			return err
+		}
+		font.fdArray = append(font.fdArray, private)
+	}
+
+	numGlyphs := len(font.charStrings)
+	offset := int(top.number(cffOpFDSelect, -1))
+	r := reader{data: data}
+	font.fdSelect = make([]byte, numGlyphs)
+	switch format := r.u8(offset); format {
+	case 0:
+		copy(font.fdSelect, r.bytes(offset+1, numGlyphs))
+	case 3:
+		nRanges := int(r.u16(offset + 1))
+		for i := 0; i < nRanges; i++ {
+			first, fd := int(r.u16(offset+3+3*i)), r.u8(offset+5+3*i)
+			end := int(r.u16(offset + 6 + 3*i)) // First glyph of the next range or sentinel.
+			for gid := first; gid < end && gid < numGlyphs; gid++ {
+				font.fdSelect[gid] = fd
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unsupported FDSelect format %d", errInvalidFont, format)
+	}
+	return r.err
+}
+
+// parseCFFPrivate parses the Private DICT referenced by the Top or Font DICT `dict`, and its
+// local subroutines.
+func parseCFFPrivate(data []byte, dict cffDict) (*cffPrivate, error) {
+	private := &cffPrivate{}
+	operands := dict[cffOpPrivate]
+	if len(operands) != 2 {
+		return private, nil
+	}
+	size, offset := int(operands[0]), int(operands[1])
+	if size < 0 || offset < 0 || offset+size > len(data) {
+		return nil, fmt.Errorf("%w: invalid Private DICT", errInvalidFont)
+	}
+	pd, err := parseCFFDict(data[offset : offset+size])
+	if err != nil {
+		return nil, err
+	}
+	private.defaultWidthX = pd.number(cffOpDefaultWX, 0)
+	private.nominalWidthX = pd.number(cffOpNominalWX, 0)
+	if subrs, ok := pd[cffOpSubrs]; ok && len(subrs) > 0 {
+		// The offset of the local subroutines is relative to the Private DICT.
+		private.subrs, _, err = parseCFFIndex(data, offset+int(subrs[0]))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return private, nil
+}
+
+// parseCFFIndex parses the INDEX at `pos` in `data` and returns its objects and the position
+// following it.
+func parseCFFIndex(data []byte, pos int) ([][]byte, int, error) {
+	r := reader{data: data}
+	count := int(r.u16(pos))
+	if r.err != nil {
+		return nil, 0, fmt.Errorf("%w: invalid INDEX at %d", errInvalidFont, pos)
+	}
+	if count == 0 {
+		return nil, pos + 2, nil
+	}
+	offSize := int(r.u8(pos + 2))
+	if offSize < 1 || offSize > 4 {
+		return nil, 0, fmt.Errorf("%w: invalid INDEX offSize %d", errInvalidFont, offSize)
+	}
+	offset := func(i int) int {
+		v := 0
+		for _, b := range r.bytes(pos+3+i*offSize, offSize) {
+			v = v<<8 | int(b)
+		}
+		return v
+	}
+	// The offsets are relative to the byte preceding the object data.
+	base := pos + 2 + (count+1)*offSize
+	objects := make([][]byte, count)
+	start := offset(0)
+	for i := range objects {
+		end := offset(i + 1)
+		if r.err != nil || start < 1 || end < start || base+end > len(data) {
+			return nil, 0, fmt.Errorf("%w: invalid INDEX offsets at %d", errInvalidFont, pos)
+		}
+		objects[i] = data[base+start : base+end]
+		start = end
+	}
+	return objects, base + start, nil
+}
+
+// parseCFFDict parses DICT data `data`.
+func parseCFFDict(data []byte) (cffDict, error) {
+	dict := cffDict{}
+	var operands []float64
+	for i := 0; i < len(data); {
+		b0 := data[i]
+		switch {
+		case b0 <= 21:
+			op := int(b0)
+			i++
+			if b0 == 12 {
+				if i >= len(data) {
+					return nil, fmt.Errorf("%w: truncated DICT operator", errInvalidFont)
+				}
+				op = 1200 + int(data[i])
+				i++
+			}
+			dict[op] = operands
+			operands = nil
+		case b0 == 30:
+			v, n, err := parseCFFReal(data[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, v)
+			i += 1 + n
+		default:
+			v, n, ok := parseCFFInteger(data[i:])
+			if !ok {
+				return nil, fmt.Errorf("%w: invalid DICT operand 0x%02x", errInvalidFont, b0)
+			}
+			operands = append(operands, float64(v))
+			i += n
+		}
+	}
+	return dict, nil
+}
+
+// parseCFFInteger parses the DICT integer at the start of `data` and returns it with its length.
+func parseCFFInteger(data []byte) (int, int, bool) {
+	b0 := int(data[0])
+	switch {
+	case b0 >= 32 && b0 <= 246:
+		return b0 - 139, 1, true
+	case b0 >= 247 && b0 <= 250 && len(data) >= 2:
+		return (b0-247)*256 + int(data[1]) + 108, 2, true
+	case b0 >= 251 && b0 <= 254 && len(data) >= 2:
+		return -(b0-251)*256 - int(data[1]) - 108, 2, true
+	case b0 == 28 && len(data) >= 3:
+		return int(int16(uint16(data[1])<<8 | uint16(data[2]))), 3, true
+	case b0 == 29 && len(data) >= 5:
+		v := uint32(data[1])<<24 | uint32(data[2])<<16 | uint32(data[3])<<8 | uint32(data[4])
+		return int(int32(v)), 5, true
+	}
+	return 0, 0, false
+}
+
+// parseCFFReal parses the nibbles of the DICT real number at the start of `data` and returns the
+// number with the count of bytes read.
+func parseCFFReal(data []byte) (float64, int, error) {
+	var s []byte
+	for i, b := range data {
+		for _, nibble := range [2]byte{b >> 4, b & 0x0f} {
+			switch {
+			case nibble <= 9:
+				s = append(s, '0'+nibble)
+			case nibble == 0xa:
+				s = append(s, '.')
+			case nibble == 0xb:
+				s = append(s, 'E')
+			case nibble == 0xc:
+				s = append(s, 'E', '-')
+			case nibble == 0xe:
+				s = append(s, '-')
+			case nibble == 0xf:
+				v, err := strconv.ParseFloat(string(s), 64)
+				if err != nil || math.IsInf(v, 0) {
+					return 0, 0, fmt.Errorf("%w: invalid DICT real %q", errInvalidFont, s)
+				}
+				return v, i + 1, nil
+			}
+		}
+	}
+	return 0, 0, fmt.Errorf("%w: truncated DICT real", errInvalidFont)
+}
@@ -0,0 +1,450 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fontfile
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// maxSubrDepth is the maximum nesting level of subroutine calls (Appendix B of Adobe Technical
+	// Note #5177).
+	maxSubrDepth = 10
+
+	// maxStackSize is the maximum size of the argument stack of Type 2 charstrings.
+	maxStackSize = 48
+)
+
+// charStringInterp is an interpreter of Type 2 charstrings (Adobe Technical Note #5177), building
+// the outline of a glyph. Hints are skipped.
+type charStringInterp struct {
+	font    *CFF
+	private *cffPrivate
+	b       *pathBuilder
+
+	stack     []float64
+	transient [32]float64
+	x, y      float64
+	nStems    int
+
+	// haveWidth is true once the optional width argument of the first stack-clearing operator
+	// has been handled.
+	haveWidth bool
+	width     float64
+
+	// seac holds the arguments adx ady bchar achar of an endchar operator describing an accented
+	// character.
+	seac   []float64
+	ended  bool
+	hasPos bool
+}
+
+// subrBias returns the bias added to the subroutine numbers of charstrings for `subrs`.
+func subrBias(subrs [][]byte) int {
+	switch n := len(subrs); {
+	case n < 1240:
+		return 107
+	case n < 33900:
+		return 1131
+	}
+	return 32768
+}
+
+// run interprets charstring `code`. `depth` is the nesting level of subroutine calls.
+func (c *charStringInterp) run(code []byte, depth int) error {
+	if depth > maxSubrDepth {
+		return fmt.Errorf("%w: subroutines nested too deeply", errInvalidGlyph)
+	}
+	for i := 0; i < len(code) && !c.ended; {
+		b0 := code[i]
+		i++
+		switch {
+		case b0 == 28:
+			if i+2 > len(code) {
+				return fmt.Errorf("%w: truncated charstring", errInvalidGlyph)
+			}
+			c.push(float64(int16(uint16(code[i])<<8 | uint16(code[i+1]))))
+			i += 2
+			continue
+		case b0 >= 32 && b0 <= 246:
+			c.push(float64(int(b0) - 139))
+			continue
+		case b0 >= 247 && b0 <= 254:
+			if i >= len(code) {
+				return fmt.Errorf("%w: truncated charstring", errInvalidGlyph)
+			}
+			v := (int(b0)-247)*256 + int(code[i]) + 108
+			if b0 >= 251 {
+				v = -(int(b0)-251)*256 - int(code[i]) - 108
+			}
+			c.push(float64(v))
+			i++
+			continue
+		case b0 == 255:
+			if i+4 > len(code) {
+				return fmt.Errorf("%w: truncated charstring", errInvalidGlyph)
+			}
+			v := int32(uint32(code[i])<<24 | uint32(code[i+1])<<16 | uint32(code[i+2])<<8 | uint32(code[i+3]))
+			c.push(float64(v) / (1 << 16))
+			i += 4
+			continue
+		}
+		if len(c.stack) > maxStackSize {
+			return fmt.Errorf("%w: charstring stack overflow", errInvalidGlyph)
+		}
+
+		args := c.stack
+		switch b0 {
+		case 1, 3, 18, 23: // hstem, vstem, hstemhm, vstemhm
+			args = c.takeWidth(args, len(args)%2 == 1)
+			c.nStems += len(args) / 2
+		case 19, 20: // hintmask, cntrmask
+			// The arguments are the values of an implicit vstem.
+			args = c.takeWidth(args, len(args)%2 == 1)
+			c.nStems += len(args) / 2
+			i += (c.nStems + 7) / 8
+		case 21: // rmoveto
+			args = c.takeWidth(args, len(args) > 2)
+			if len(args) < 2 {
+				return errStackUnderflow
+			}
+			c.moveTo(args[0], args[1])
+		case 22: // hmoveto
+			args = c.takeWidth(args, len(args) > 1)
+			if len(args) < 1 {
+				return errStackUnderflow
+			}
+			c.moveTo(args[0], 0)
+		case 4: // vmoveto
+			args = c.takeWidth(args, len(args) > 1)
+			if len(args) < 1 {
+				return errStackUnderflow
+			}
+			c.moveTo(0, args[0])
+		case 5: // rlineto
+			for ; len(args) >= 2; args = args[2:] {
+				c.lineTo(args[0], args[1])
+			}
+		case 6, 7: // hlineto, vlineto
+			horizontal := b0 == 6
+			for ; len(args) >= 1; args = args[1:] {
+				if horizontal {
+					c.lineTo(args[0], 0)
+				} else {
+					c.lineTo(0, args[0])
+				}
+				horizontal = !horizontal
+			}
+		case 8: // rrcurveto
+			for ; len(args) >= 6; args = args[6:] {
+				c.curveTo(args[0], args[1], args[2], args[3], args[4], args[5])
+			}
+		case 24: // rcurveline
+			for ; len(args) >= 8; args = args[6:] {
+				c.curveTo(args[0], args[1], args[2], args[3], args[4], args[5])
+			}
+			if len(args) >= 2 {
+				c.lineTo(args[0], args[1])
+			}
+		case 25: // rlinecurve
+			for ; len(args) >= 8; args = args[2:] {
+				c.lineTo(args[0], args[1])
+			}
+			if len(args) >= 6 {
+				c.curveTo(args[0], args[1], args[2], args[3], args[4], args[5])
+			}
+		case 26: // vvcurveto
+			dx1 := 0.0
+			if len(args)%2 == 1 {
+				dx1, args = args[0], args[1:]
+			}
+			for ; len(args) >= 4; args = args[4:] {
+				c.curveTo(dx1, args[0], args[1], args[2], 0, args[3])
+				dx1 = 0
+			}
+		case 27: // hhcurveto
+			dy1 := 0.0
+			if len(args)%2 == 1 {
+				dy1, args = args[0], args[1:]
+			}
+			for ; len(args) >= 4; args = args[4:] {
+				c.curveTo(args[0], dy1, args[1], args[2], args[3], 0)
+				dy1 = 0
+			}
+		case 30, 31: // vhcurveto, hvcurveto
+			horizontal := b0 == 31
+			for len(args) >= 4 {
+				last := 0.0
+				n := 4
+				if len(args) == 5 {
+					last, n = args[4], 5
+				}
+				if horizontal {
+					c.curveTo(args[0], 0, args[1], args[2], last, args[3])
+				} else {
+					c.curveTo(0, args[0], args[1], args[2], args[3], last)
+				}
+				args = args[n:]
+				horizontal = !horizontal
+			}
+		case 10, 29: // callsubr, callgsubr
+			if len(c.stack) < 1 {
+				return errStackUnderflow
+			}
+			subrs := c.private.subrs
+			if b0 == 29 {
+				subrs = c.font.globalSubrs
+			}
+			n := int(c.stack[len(c.stack)-1]) + subrBias(subrs)
+			c.stack = c.stack[:len(c.stack)-1]
+			if n < 0 || n >= len(subrs) {
+				return fmt.Errorf("%w: invalid subroutine %d", errInvalidGlyph, n)
+			}
+			if err := c.run(subrs[n], depth+1); err != nil {
+				return err
+			}
+			continue
+		case 11: // return
+			return nil
+		case 14: // endchar
+			args = c.takeWidth(args, len(args) == 1 || len(args) == 5)
+			if len(args) == 4 {
+				c.seac = append([]float64(nil), args...)
+			}
+			c.ended = true
+		case 12: // escape
+			if i >= len(code) {
+				return fmt.Errorf("%w: truncated charstring", errInvalidGlyph)
+			}
+			b1 := code[i]
+			i++
+			if err := c.escape(b1); err != nil {
+				return err
+			}
+			continue
+		default:
+			return fmt.Errorf("%w: unknown charstring operator %d", errInvalidGlyph, b0)
+		}
+		c.stack = c.stack[:0]
+	}
+	return nil
+}
+
+var errStackUnderflow = fmt.Errorf("%w: charstring stack underflow", errInvalidGlyph)
+
+// escape runs the two byte operator 12 `b1`: the flex and arithmetic operators.
+func (c *charStringInterp) escape(b1 byte) error {
+	args := c.stack
+	// need checks that the stack holds at least `n` arguments.
+	need := func(n int) bool { return len(args) >= n }
+	clearStack := true
+	switch b1 {
+	case 35: // flex
+		if !need(13) {
+			return errStackUnderflow
+		}
+		c.curveTo(args[0], args[1], args[2], args[3], args[4], args[5])
+		c.curveTo(args[6], args[7], args[8], args[9], args[10], args[11])
+	case 34: // hflex
+		if !need(7) {
+			return errStackUnderflow
+		}
+		c.curveTo(args[0], 0, args[1], args[2], args[3], 0)
+		c.curveTo(args[4], 0, args[5], -args[2], args[6], 0)
+	case 36: // hflex1
+		if !need(9) {
+			return errStackUnderflow
+		}
+		c.curveTo(args[0], args[1], args[2], args[3], args[4], 0)
+		c.curveTo(args[5], 0, args[6], args[7], args[8], -(args[1] + args[3] + args[7]))
+	case 37: // flex1
+		if !need(11) {
+			return errStackUnderflow
+		}
+		dx := args[0] + args[2] + args[4] + args[6] + args[8]
+		dy := args[1] + args[3] + args[5] + args[7] + args[9]
+		dx6, dy6 := args[10], -dy
+		if math.Abs(dx) <= math.Abs(dy) {
+			dx6, dy6 = -dx, args[10]
+		}
+		c.curveTo(args[0], args[1], args[2], args[3], args[4], args[5])
+		c.curveTo(args[6], args[7], args[8], args[9], dx6, dy6)
+	case 0: // dotsection (deprecated)
+	default:
+		clearStack = false
+		if err := c.arithmetic(b1); err != nil {
+			return err
+		}
+	}
+	if clearStack {
+		c.stack = c.stack[:0]
+	}
+	return nil
+}
+
+// arithmeticOperands are the numbers of operands of the arithmetic, storage and conditional
+// operators.
+var arithmeticOperands = map[byte]int{3: 2, 4: 2, 5: 1, 9: 1, 10: 2, 11: 2, 12: 2, 14: 1, 15: 2,
+	18: 1, 20: 2, 21: 1, 22: 4, 23: 0, 24: 2, 26: 1, 27: 1, 28: 2, 29: 1, 30: 2}
+
+// arithmetic runs the arithmetic, storage and conditional operator 12 `b1`.
+func (c *charStringInterp) arithmetic(b1 byte) error {
+	pop := func() float64 {
+		v := c.stack[len(c.stack)-1]
+		c.stack = c.stack[:len(c.stack)-1]
+		return v
+	}
+	boolean := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	n, ok := arithmeticOperands[b1]
+	if !ok {
+		return fmt.Errorf("%w: unknown charstring operator 12 %d", errInvalidGlyph, b1)
+	}
+	if len(c.stack) < n {
+		return errStackUnderflow
+	}
+	switch b1 {
+	case 3: // and
+		b, a := pop(), pop()
+		c.push(boolean(a != 0 && b != 0))
+	case 4: // or
+		b, a := pop(), pop()
+		c.push(boolean(a != 0 || b != 0))
+	case 5: // not
+		c.push(boolean(pop() == 0))
+	case 9: // abs
+		c.push(math.Abs(pop()))
+	case 10: // add
+		b, a := pop(), pop()
+		c.push(a + b)
+	case 11: // sub
+		b, a := pop(), pop()
+		c.push(a - b)
+	case 12: // div
+		b, a := pop(), pop()
+		if b == 0 {
+			return fmt.Errorf("%w: division by zero", errInvalidGlyph)
+		}
+		c.push(a / b)
+	case 14: // neg
+		c.push(-pop())
+	case 15: // eq
+		b, a := pop(), pop()
+		c.push(boolean(a == b))
+	case 18: // drop
+		pop()
+	case 20: // put
+		i, v := int(pop()), pop()
+		if i >= 0 && i < len(c.transient) {
+			c.transient[i] = v
+		}
+	case 21: // get
+		i := int(pop())
+		v := 0.0
+		if i >= 0 && i < len(c.transient) {
+			v = c.transient[i]
+		}
+		c.push(v)
+	case 22: // ifelse
+		v2, v1, s2, s1 := pop(), pop(), pop(), pop()
+		if v1 > v2 {
+			s1 = s2
+		}
+		c.push(s1)
+	case 23: // random
+		// A fixed value in (0, 1] keeps the rendering deterministic.
+		c.push(0.5)
+	case 24: // mul
+		b, a := pop(), pop()
+		c.push(a * b)
+	case 26: // sqrt
+		c.push(math.Sqrt(math.Abs(pop())))
+	case 27: // dup
+		v := pop()
+		c.push(v)
+		c.push(v)
+	case 28: // exch
+		b, a := pop(), pop()
+		c.push(b)
+		c.push(a)
+	case 29: // index
+		i := int(pop())
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(c.stack) {
+			return errStackUnderflow
+		}
+		c.push(c.stack[len(c.stack)-1-i])
+	case 30: // roll
+		j, n := int(pop()), int(pop())
+		if n <= 0 || n > len(c.stack) {
+			return errStackUnderflow
+		}
+		elems := c.stack[len(c.stack)-n:]
+		rolled := make([]float64, n)
+		for k, v := range elems {
+			rolled[((k+j)%n+n)%n] = v
+		}
+		copy(elems, rolled)
+	}
+	return nil
+}
+
+// push pushes `v` on the argument stack.
+func (c *charStringInterp) push(v float64) {
+	c.stack = append(c.stack, v)
+}
+
+// takeWidth handles the optional width argument preceding the arguments `args` of the first
+// stack-clearing operator, present if `hasWidth` is true, and returns the other arguments.
+func (c *charStringInterp) takeWidth(args []float64, hasWidth bool) []float64 {
+	if c.haveWidth {
+		return args
+	}
+	c.haveWidth = true
+	if hasWidth && len(args) > 0 {
+		c.width = c.private.nominalWidthX + args[0]
+		return args[1:]
+	}
+	return args
+}
+
+func (c *charStringInterp) moveTo(dx, dy float64) {
+	c.x += dx
+	c.y += dy
+	c.b.moveTo(c.x, c.y)
+	c.hasPos = true
+}
+
+func (c *charStringInterp) lineTo(dx, dy float64) {
+	c.ensureContour()
+	c.x += dx
+	c.y += dy
+	c.b.lineTo(c.x, c.y)
+}
+
+func (c *charStringInterp) curveTo(dx1, dy1, dx2, dy2, dx3, dy3 float64) {
+	c.ensureContour()
+	x1, y1 := c.x+dx1, c.y+dy1
+	x2, y2 := x1+dx2, y1+dy2
+	c.x, c.y = x2+dx3, y2+dy3
+	c.b.cubeTo(x1, y1, x2, y2, c.x, c.y)
+}
+
+// ensureContour starts a contour at the origin for the malformed charstrings drawing before
+// their first moveto.
+func (c *charStringInterp) ensureContour() {
+	if !c.hasPos {
+		c.b.moveTo(c.x, c.y)
+		c.hasPos = true
+	}
+}
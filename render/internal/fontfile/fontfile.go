@@ -0,0 +1,103 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package fontfile parses the font programs embedded in PDF files, TrueType (FontFile2), CFF and
+// OpenType (FontFile3), to provide the outlines of their glyphs to the renderer.
+package fontfile
+
+import (
+	"errors"
+
+	"github.com/unidoc/unipdf/v3/internal/transform"
+)
+
+var (
+	errInvalidFont  = errors.New("invalid font program")
+	errInvalidGlyph = errors.New("invalid glyph")
+)
+
+// GID is a glyph index.
+type GID uint16
+
+// Font is a font program providing the outlines of its glyphs.
+type Font interface {
+	// NumGlyphs returns the number of glyphs of the font.
+	NumGlyphs() int
+
+	// GlyphPath returns the outline of glyph `gid` in text space units, i.e. the glyph space units
+	// transformed by the font matrix.
+	GlyphPath(gid GID) (Path, error)
+}
+
+// SegmentOp is the operation of a path segment.
+type SegmentOp int
+
+// Path segment operations.
+const (
+	// SegmentMoveTo starts a new contour at Points[0].
+	SegmentMoveTo SegmentOp = iota
+	// SegmentLineTo adds a line to Points[0].
+	SegmentLineTo
+	// SegmentQuadTo adds a quadratic Bézier curve with control point Points[0] to Points[1].
+	SegmentQuadTo
+	// SegmentCubeTo adds a cubic Bézier curve with control points Points[0] and Points[1] to
+	// Points[2].
+	SegmentCubeTo
+)
+
+// Segment is a segment of a glyph outline.
+type Segment struct {
+	Op     SegmentOp
+	Points [3]transform.Point
+}
+
+// Path is the outline of a glyph, made of contours which are implicitly closed.
+type Path []Segment
+
+// Transform returns the path `p` transformed by matrix `m`.
+func (p Path) Transform(m transform.Matrix) Path {
+	path := make(Path, len(p))
+	for i, seg := range p {
+		for j := range seg.Points {
+			seg.Points[j] = transformPoint(m, seg.Points[j].X, seg.Points[j].Y)
+		}
+		path[i] = seg
+	}
+	return path
+}
+
+// pathBuilder builds a path, scaling the glyph space coordinates to text space units.
+type pathBuilder struct {
+	path  Path
+	scale transform.Matrix
+}
+
+func (b *pathBuilder) point(x, y float64) transform.Point {
+	return transformPoint(b.scale, x, y)
+}
+
+// transformPoint returns the point `x`,`y` transformed by the affine transform `m`, i.e.
+// (a*x + c*y + tx, b*x + d*y + ty) with `m` laid out as in transform.NewMatrix.
+func transformPoint(m transform.Matrix, x, y float64) transform.Point {
+	return transform.NewPoint(m[0]*x+m[3]*y+m[6], m[1]*x+m[4]*y+m[7])
+}
+
+func (b *pathBuilder) moveTo(x, y float64) {
+	b.path = append(b.path, Segment{Op: SegmentMoveTo, Points: [3]transform.Point{b.point(x, y)}})
+}
+
+func (b *pathBuilder) lineTo(x, y float64) {
+	b.path = append(b.path, Segment{Op: SegmentLineTo, Points: [3]transform.Point{b.point(x, y)}})
+}
+
+func (b *pathBuilder) quadTo(cx, cy, x, y float64) {
+	b.path = append(b.path, Segment{Op: SegmentQuadTo,
+		Points: [3]transform.Point{b.point(cx, cy), b.point(x, y)}})
+}
+
+func (b *pathBuilder) cubeTo(c1x, c1y, c2x, c2y, x, y float64) {
+	b.path = append(b.path, Segment{Op: SegmentCubeTo,
+		Points: [3]transform.Point{b.point(c1x, c1y), b.point(c2x, c2y), b.point(x, y)}})
+}
@@ -0,0 +1,64 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fontfile
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestParseSFNT(t *testing.T) {
+	font, err := ParseSFNT(goregular.TTF)
+	require.NoError(t, err)
+	require.Nil(t, font.CFF())
+
+	gid, ok := font.Lookup(CmapID{Platform: 3, Encoding: 1}, 'A')
+	require.True(t, ok)
+	require.Equal(t, GID(36), gid)
+	require.InDelta(t, 0.667, font.Advance(gid), 0.001)
+
+	path, err := font.GlyphPath(gid)
+	require.NoError(t, err)
+	require.NotEmpty(t, path)
+	require.Equal(t, SegmentMoveTo, path[0].Op)
+
+	_, err = ParseSFNT([]byte("not a font"))
+	require.Error(t, err)
+}
+
+func TestParseCFF(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("..", "..", "testdata", "subset.cff"))
+	require.NoError(t, err)
+	font, err := ParseCFF(data)
+	require.NoError(t, err)
+	require.False(t, font.IsCID())
+	require.Equal(t, 29, font.NumGlyphs())
+
+	// Glyphs selected by name and by code in the standard encoding.
+	gidName, ok := font.GIDByName("A")
+	require.True(t, ok)
+	gidCode, ok := font.GIDByCode('A')
+	require.True(t, ok)
+	require.Equal(t, gidName, gidCode)
+	_, ok = font.GIDByName("B")
+	require.False(t, ok)
+
+	require.InDelta(t, 0.722, font.Advance(gidName), 0.001)
+	path, err := font.GlyphPath(gidName)
+	require.NoError(t, err)
+	require.NotEmpty(t, path)
+
+	// Glyph outlines are in text space units.
+	for _, seg := range path {
+		for _, p := range seg.Points {
+			require.True(t, p.X > -0.2 && p.X < 1 && p.Y > -0.3 && p.Y < 1, "%+v", p)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fontfile
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/unidoc/unipdf/v3/internal/transform"
+)
+
+// maxCompositeDepth is the maximum nesting level of the components of composite glyphs, guarding
+// against self-referencing glyphs.
+const maxCompositeDepth = 8
+
+// SFNT is a TrueType or OpenType font program. The outlines of its glyphs are given by its glyf
+// table or, for OpenType fonts with PostScript outlines, by its CFF table.
+// Only the tables needed to draw the glyphs are parsed, so that the subsets of fonts embedded in
+// PDF files, which often lack some of the tables required by the specification, can be used.
+type SFNT struct {
+	tables      map[string][]byte
+	unitsPerEm  float64
+	numGlyphs   int
+	longLoca    bool
+	numHMetrics int
+	cmaps       map[CmapID][]byte // cmap subtables.
+	cff         *CFF
+}
+
+// CmapID identifies a cmap subtable by its platform and encoding IDs, e.g. {3, 1} for Windows
+// Unicode BMP and {3, 0} for Windows Symbol.
+type CmapID struct {
+	Platform uint16
+	Encoding uint16
+}
+
+// ParseSFNT parses the TrueType or OpenType font program `data`. The first font of TrueType
+// collections is parsed.
+func ParseSFNT(data []byte) (*SFNT, error) {
+	r := reader{data: data}
+	offset := 0
+	if r.u32(0) == 0x74746366 { // "ttcf"
+		offset = int(r.u32(12))
+	}
+	switch version := r.u32(offset); version {
+	case 0x00010000, 0x74727565, 0x4f54544f: // TrueType, "true" and "OTTO".
+	default:
+		return nil, fmt.Errorf("%w: unsupported sfnt version 0x%08x", errInvalidFont, version)
+	}
+
+	font := &SFNT{tables: map[string][]byte{}, cmaps: map[CmapID][]byte{}}
+	numTables := int(r.u16(offset + 4))
+	for i := 0; i < numTables; i++ {
+		rec := offset + 12 + 16*i
+		tag := string(r.bytes(rec, 4))
+		tableOffset, length := int(r.u32(rec+8)), int(r.u32(rec+12))
+		if r.err != nil || tableOffset < 0 || length < 0 || tableOffset+length > len(data) {
+			return nil, fmt.Errorf("%w: invalid table %q", errInvalidFont, tag)
+		}
+		font.tables[tag] = data[tableOffset : tableOffset+length]
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	head := reader{data: font.tables["head"]}
+	font.unitsPerEm = float64(head.u16(18))
+	font.longLoca = head.u16(50) != 0
+	if head.err != nil || font.unitsPerEm == 0 {
+		return nil, fmt.Errorf("%w: invalid head table", errInvalidFont)
+	}
+	maxp := reader{data: font.tables["maxp"]}
+	font.numGlyphs = int(maxp.u16(4))
+	if maxp.err != nil {
+		return nil, fmt.Errorf("%w: invalid maxp table", errInvalidFont)
+	}
+	if hhea := (reader{data: font.tables["hhea"]}); len(hhea.data) >= 36 {
+		font.numHMetrics = int(hhea.u16(34))
+	}
+
+	if data, ok := font.tables["CFF "]; ok {
+		cff, err := ParseCFF(data)
+		if err != nil {
+			return nil, err
+		}
+		font.cff = cff
+	} else if _, ok := font.tables["glyf"]; !ok {
+		return nil, fmt.Errorf("%w: no glyf or CFF table", errInvalidFont)
+	}
+
+	font.parseCmap()
+	return font, nil
+}
+
+// parseCmap records the cmap subtables of `font`.
+func (font *SFNT) parseCmap() {
+	r := reader{data: font.tables["cmap"]}
+	numTables := int(r.u16(2))
+	for i := 0; i < numTables && r.err == nil; i++ {
+		rec := 4 + 8*i
+		id := CmapID{Platform: r.u16(rec), Encoding: r.u16(rec + 2)}
+		offset := int(r.u32(rec + 4))
+		if r.err == nil && offset < len(r.data) {
+			font.cmaps[id] = r.data[offset:]
+		}
+	}
+}
+
+// CFF returns the CFF font program of OpenType font `font` with PostScript outlines, nil for
+// fonts with TrueType outlines.
+func (font *SFNT) CFF() *CFF {
+	return font.cff
+}
+
+// NumGlyphs returns the number of glyphs of `font`.
+func (font *SFNT) NumGlyphs() int {
+	return font.numGlyphs
+}
+
+// HasCmap returns true if `font` has a cmap subtable identified by `id`.
+func (font *SFNT) HasCmap(id CmapID) bool {
+	_, ok := font.cmaps[id]
+	return ok
+}
+
+// Lookup returns the glyph mapped to character code `code` by the cmap subtable identified by
+// `id`. The bool return flag is false if there is no such subtable or if it does not map `code`.
+func (font *SFNT) Lookup(id CmapID, code uint32) (GID, bool) {
+	data, ok := font.cmaps[id]
+	if !ok {
+		return 0, false
+	}
+	r := reader{data: data}
+	var gid uint32
+	switch r.u16(0) {
+	case 0:
+		if code < 256 {
+			gid = uint32(r.u8(6 + int(code)))
+		}
+	case 4:
+		segCount := int(r.u16(6)) / 2
+		endCodes, startCodes := 14, 16+2*segCount
+		deltas, rangeOffsets := 16+4*segCount, 16+6*segCount
+		for i := 0; i < segCount && r.err == nil; i++ {
+			if code > uint32(r.u16(endCodes+2*i)) {
+				continue
+			}
+			start := uint32(r.u16(startCodes + 2*i))
+			if code < start {
+				break
+			}
+			delta := uint32(r.u16(deltas + 2*i))
+			rangeOffset := int(r.u16(rangeOffsets + 2*i))
+			if rangeOffset == 0 {
+				gid = (code + delta) & 0xffff
+				break
+			}
+			gid = uint32(r.u16(rangeOffsets + 2*i + rangeOffset + 2*int(code-start)))
+			if gid != 0 {
+				gid = (gid + delta) & 0xffff
+			}
+			break
+		}
+	case 6:
+		first, count := uint32(r.u16(6)), uint32(r.u16(8))
+		if code >= first && code < first+count {
+			gid = uint32(r.u16(10 + 2*int(code-first)))
+		}
+	case 12:
+		numGroups := int(r.u32(12))
+		for i := 0; i < numGroups && r.err == nil; i++ {
+			group := 16 + 12*i
+			start, end := r.u32(group), r.u32(group+4)
+			if code >= start && code <= end {
+				gid = r.u32(group+8) + code - start
+				break
+			}
+		}
+	}
+	if r.err != nil || gid == 0 || int(gid) >= font.numGlyphs {
+		return 0, false
+	}
+	return GID(gid), true
+}
+
+// Advance returns the advance width of glyph `gid` in text space units, 0 if unknown.
+func (font *SFNT) Advance(gid GID) float64 {
+	if font.numHMetrics == 0 {
+		return 0
+	}
+	i := int(gid)
+	if i >= font.numHMetrics {
+		i = font.numHMetrics - 1
+	}
+	r := reader{data: font.tables["hmtx"]}
+	advance := r.u16(4 * i)
+	if r.err != nil {
+		return 0
+	}
+	return float64(advance) / font.unitsPerEm
+}
+
+// GlyphPath returns the outline of glyph `gid` in text space units.
+func (font *SFNT) GlyphPath(gid GID) (Path, error) {
+	if font.cff != nil {
+		return font.cff.GlyphPath(gid)
+	}
+	path, err := font.glyfPath(gid, 0)
+	if err != nil {
+		return nil, err
+	}
+	return path.Transform(transform.ScaleMatrix(1/font.unitsPerEm, 1/font.unitsPerEm)), nil
+}
+
+// glyfPath returns the outline of glyph `gid` of the glyf table in glyph space units.
+// `depth` is the nesting level of the glyph in composite glyphs.
+func (font *SFNT) glyfPath(gid GID, depth int) (Path, error) {
+	if int(gid) >= font.numGlyphs {
+		return nil, fmt.Errorf("%w: gid %d out of range", errInvalidGlyph, gid)
+	}
+	loca := reader{data: font.tables["loca"]}
+	var start, end int
+	if font.longLoca {
+		start, end = int(loca.u32(4*int(gid))), int(loca.u32(4*int(gid)+4))
+	} else {
+		start, end = 2*int(loca.u16(2*int(gid))), 2*int(loca.u16(2*int(gid)+2))
+	}
+	glyf := font.tables["glyf"]
+	if loca.err != nil || start > end || end > len(glyf) {
+		return nil, fmt.Errorf("%w: invalid loca entry for gid %d", errInvalidGlyph, gid)
+	}
+	if start == end {
+		// Glyphs without outlines, e.g. spaces.
+		return nil, nil
+	}
+
+	r := reader{data: glyf[start:end]}
+	numContours := int(int16(r.u16(0)))
+	if numContours >= 0 {
+		return simpleGlyphPath(r, numContours)
+	}
+	if depth >= maxCompositeDepth {
+		return nil, fmt.Errorf("%w: composite glyph %d nested too deeply", errInvalidGlyph, gid)
+	}
+	return font.compositeGlyphPath(r, depth)
+}
+
+// simpleGlyphPath returns the outline of the simple glyph read by `r` with `numContours`
+// contours. The contours are made of quadratic Bézier curves through the points on the curve,
+// with an implicit point on the curve between consecutive control points.
+func simpleGlyphPath(r reader, numContours int) (Path, error) {
+	endPoints := make([]int, numContours)
+	for i := range endPoints {
+		endPoints[i] = int(r.u16(10 + 2*i))
+	}
+	if numContours == 0 {
+		return nil, nil
+	}
+	numPoints := endPoints[numContours-1] + 1
+	pos := 10 + 2*numContours
+	pos += 2 + int(r.u16(pos)) // Skip the instructions.
+
+	flags := make([]byte, 0, numPoints)
+	for len(flags) < numPoints && r.err == nil {
+		flag := r.u8(pos)
+		pos++
+		flags = append(flags, flag)
+		if flag&0x08 != 0 {
+			repeat := int(r.u8(pos))
+			pos++
+			for i := 0; i < repeat && len(flags) < numPoints; i++ {
+				flags = append(flags, flag)
+			}
+		}
+	}
+	points := make([]transform.Point, numPoints)
+	readCoordinates := func(shortFlag, sameFlag byte, set func(i int, v float64)) {
+		v := 0
+		for i, flag := range flags {
+			switch {
+			case flag&shortFlag != 0:
+				d := int(r.u8(pos))
+				pos++
+				if flag&sameFlag == 0 {
+					d = -d
+				}
+				v += d
+			case flag&sameFlag == 0:
+				v += int(int16(r.u16(pos)))
+				pos += 2
+			}
+			set(i, float64(v))
+		}
+	}
+	readCoordinates(0x02, 0x10, func(i int, v float64) { points[i].X = v })
+	readCoordinates(0x04, 0x20, func(i int, v float64) { points[i].Y = v })
+	if r.err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidGlyph, r.err)
+	}
+
+	b := &pathBuilder{scale: transform.IdentityMatrix()}
+	start := 0
+	for _, end := range endPoints {
+		if end < start || end >= numPoints {
+			return nil, fmt.Errorf("%w: invalid contour end point %d", errInvalidGlyph, end)
+		}
+		addQuadContour(b, points[start:end+1], flags[start:end+1])
+		start = end + 1
+	}
+	return b.path, nil
+}
+
+// addQuadContour adds the contour of TrueType points `points` to `b`, `flags` telling which
+// points are on the curve.
+func addQuadContour(b *pathBuilder, points []transform.Point, flags []byte) {
+	n := len(points)
+	if n == 0 {
+		return
+	}
+	onCurve := func(i int) bool { return flags[i]&0x01 != 0 }
+	mid := func(p, q transform.Point) transform.Point {
+		return transform.NewPoint((p.X+q.X)/2, (p.Y+q.Y)/2)
+	}
+
+	// Start at a point on the curve and end with it, so that the contour is closed.
+	var startPt transform.Point
+	var seq []transform.Point
+	var seqOn []bool
+	switch {
+	case onCurve(0):
+		startPt = points[0]
+		for i := 1; i < n; i++ {
+			seq, seqOn = append(seq, points[i]), append(seqOn, onCurve(i))
+		}
+	case onCurve(n - 1):
+		startPt = points[n-1]
+		for i := 0; i < n-1; i++ {
+			seq, seqOn = append(seq, points[i]), append(seqOn, onCurve(i))
+		}
+	default:
+		startPt = mid(points[n-1], points[0])
+		seq, seqOn = append(seq, points...), make([]bool, n)
+	}
+	seq, seqOn = append(seq, startPt), append(seqOn, true)
+
+	b.moveTo(startPt.X, startPt.Y)
+	var ctrl *transform.Point
+	for i, p := range seq {
+		p := p
+		switch {
+		case seqOn[i] && ctrl == nil:
+			b.lineTo(p.X, p.Y)
+		case seqOn[i]:
+			b.quadTo(ctrl.X, ctrl.Y, p.X, p.Y)
+			ctrl = nil
+		case ctrl == nil:
+			ctrl = &p
+		default:
+			m := mid(*ctrl, p)
+			b.quadTo(ctrl.X, ctrl.Y, m.X, m.Y)
+			ctrl = &p
+		}
+	}
+}
+
+// compositeGlyphPath returns the outline of the composite glyph read by `r`, made of the
+// transformed outlines of its components.
+func (font *SFNT) compositeGlyphPath(r reader, depth int) (Path, error) {
+	const (
+		argsAreWords   = 0x0001
+		argsAreXY      = 0x0002
+		haveScale      = 0x0008
+		moreComponents = 0x0020
+		haveXYScale    = 0x0040
+		haveTwoByTwo   = 0x0080
+	)
+	f2dot14 := func(pos int) float64 { return float64(int16(r.u16(pos))) / (1 << 14) }
+
+	var path Path
+	pos := 10
+	for {
+		flags := r.u16(pos)
+		gid := GID(r.u16(pos + 2))
+		pos += 4
+		var dx, dy float64
+		if flags&argsAreWords != 0 {
+			dx, dy = float64(int16(r.u16(pos))), float64(int16(r.u16(pos+2)))
+			pos += 4
+		} else {
+			dx, dy = float64(int8(r.u8(pos))), float64(int8(r.u8(pos+1)))
+			pos += 2
+		}
+		if flags&argsAreXY == 0 {
+			// The components positioned by matching points are not supported.
+			dx, dy = 0, 0
+		}
+		a, b, c, d := 1.0, 0.0, 0.0, 1.0
+		switch {
+		case flags&haveScale != 0:
+			a = f2dot14(pos)
+			d = a
+			pos += 2
+		case flags&haveXYScale != 0:
+			a, d = f2dot14(pos), f2dot14(pos+2)
+			pos += 4
+		case flags&haveTwoByTwo != 0:
+			a, b, c, d = f2dot14(pos), f2dot14(pos+2), f2dot14(pos+4), f2dot14(pos+6)
+			pos += 8
+		}
+		if r.err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidGlyph, r.err)
+		}
+
+		component, err := font.glyfPath(gid, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, component.Transform(transform.NewMatrix(a, b, c, d, dx, dy))...)
+		if flags&moreComponents == 0 {
+			break
+		}
+	}
+	return path, nil
+}
+
+// reader reads big-endian values from font data, recording the first out of bounds access.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) check(pos, n int) bool {
+	if pos < 0 || pos+n > len(r.data) {
+		if r.err == nil {
+			r.err = fmt.Errorf("%w: read out of bounds at %d", errInvalidFont, pos)
+		}
+		return false
+	}
+	return true
+}
+
+func (r *reader) u8(pos int) byte {
+	if !r.check(pos, 1) {
+		return 0
+	}
+	return r.data[pos]
+}
+
+func (r *reader) u16(pos int) uint16 {
+	if !r.check(pos, 2) {
+		return 0
+	}
+	return binary.BigEndian.Uint16(r.data[pos:])
+}
+
+func (r *reader) u32(pos int) uint32 {
+	if !r.check(pos, 4) {
+		return 0
+	}
+	return binary.BigEndian.Uint32(r.data[pos:])
+}
+
+func (r *reader) bytes(pos, n int) []byte {
+	if !r.check(pos, n) {
+		return make([]byte, n)
+	}
+	return r.data[pos : pos+n]
+}
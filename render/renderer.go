@@ -8,8 +8,6 @@ package render
 import (
 	"errors"
 
-	"github.com/adrg/sysfont"
-
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/contentstream"
 	"github.com/unidoc/unipdf/v3/core"
@@ -27,16 +25,14 @@ var (
 type renderer struct {
 }
 
-func (r renderer) renderPage(ctx context.Context, page *model.PdfPage) error {
+// renderPage renders `page` to `ctx`. Matrix `m` maps the default user space
+// of the page to the pixels of `ctx`.
+func (r renderer) renderPage(ctx context.Context, page *model.PdfPage, m transform.Matrix) error {
 	contents, err := page.GetAllContentStreams()
 	if err != nil {
 		return err
 	}
 
-	// Change coordinate system.
-	ctx.Translate(0, float64(ctx.Height()))
-	ctx.Scale(1, -1)
-
 	// Create white background.
 	ctx.Push()
 	ctx.SetRGBA(1, 1, 1, 1)
@@ -44,28 +40,64 @@ func (r renderer) renderPage(ctx context.Context, page *model.PdfPage) error {
 	ctx.Fill()
 	ctx.Pop()
 
+	// Change coordinate system.
+	ctx.SetMatrix(m)
+
 	// Set defaults.
 	ctx.SetLineWidth(1.0)
 	ctx.SetRGBA(0, 0, 0, 1)
 
-	return r.renderContentStream(ctx, contents, page.Resources)
+	return r.renderContentStream(ctx, contents, page.Resources, newFontCache())
 }
 
-func (r renderer) renderContentStream(ctx context.Context, contents string, resources *model.PdfPageResources) error {
+func (r renderer) renderContentStream(ctx context.Context, contents string,
+	resources *model.PdfPageResources, fonts *fontCache) error {
 	operations, err := contentstream.NewContentStreamParser(contents).Parse()
 	if err != nil {
 		return err
 	}
 
 	textState := ctx.TextState()
-	fontCache := map[string]*context.TextFont{}
-	fontFinder := sysfont.NewFinder(&sysfont.FinderOpts{
-		Extensions: []string{".ttf", ".ttc"},
-	})
+
+	// Start and current points of the current path in user space, used by
+	// the `v` and `y` operators.
+	var pathStart, pathCurrent transform.Point
+
+	// Fill rule of the clipping path set by the `W` and `W*` operators,
+	// which takes effect after the next path painting operator.
+	var clipRule *context.FillRule
+
+	// paint paints the current path and then applies the pending clipping
+	// path, if any, and ends the path.
+	paint := func(gs contentstream.GraphicsState, close, fill, stroke bool, rule context.FillRule) {
+		if close {
+			ctx.ClosePath()
+			ctx.NewSubPath()
+		}
+		if fill {
+			setFillColor(ctx, gs)
+			ctx.SetFillRule(rule)
+			ctx.FillPreserve()
+		}
+		if stroke {
+			setStrokeColor(ctx, gs)
+			ctx.StrokePreserve()
+		}
+		if clipRule != nil {
+			ctx.SetFillRule(*clipRule)
+			ctx.ClipPreserve()
+			clipRule = nil
+		}
+		ctx.ClearPath()
+	}
 
 	processor := contentstream.NewContentStreamProcessor(*operations)
 	processor.AddHandler(contentstream.HandlerConditionEnumAllOperands, "",
 		func(op *contentstream.ContentStreamOperation, gs contentstream.GraphicsState, resources *model.PdfPageResources) error {
+			if gs.InCharProc {
+				// The glyph descriptions of Type3 fonts are not drawn.
+				return nil
+			}
 			common.Log.Debug("Processing %s", op.Operand)
 			switch op.Operand {
 			//
@@ -92,10 +124,6 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				m := transform.NewMatrix(fv[0], fv[1], fv[2], fv[3], fv[4], fv[5])
 				common.Log.Debug("Graphics state matrix: %+v", m)
 				ctx.SetMatrix(ctx.Matrix().Mult(m))
-
-				// TODO: Take angle into account for line widths (8.4.3.2 Line Width).
-				s := (gs.CTM.ScalingFactorX() + gs.CTM.ScalingFactorY()) / 2.0
-				ctx.SetLineWidth(s * ctx.LineWidth())
 			// Set line width.
 			case "w":
 				if len(op.Params) != 1 {
@@ -107,9 +135,9 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 					return err
 				}
 
-				// TODO: Take angle into account for line widths (8.4.3.2 Line Width).
-				s := (gs.CTM.ScalingFactorX() + gs.CTM.ScalingFactorY()) / 2.0
-				ctx.SetLineWidth(s * fw[0])
+				// The line width is in user space units and is scaled by the
+				// context when stroking.
+				ctx.SetLineWidth(fw[0])
 			// Set line cap style.
 			case "J":
 				if len(op.Params) != 1 {
@@ -120,21 +148,7 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				if !ok {
 					return errType
 				}
-
-				switch val {
-				// Butt cap.
-				case 0:
-					ctx.SetLineCap(context.LineCapButt)
-				// Round cap.
-				case 1:
-					ctx.SetLineCap(context.LineCapRound)
-				// Projecting square cap.
-				case 2:
-					ctx.SetLineCap(context.LineCapSquare)
-				default:
-					common.Log.Debug("Invalid line cap style: %d", val)
-					return errRange
-				}
+				return setLineCap(ctx, val)
 			// Set line join style.
 			case "j":
 				if len(op.Params) != 1 {
@@ -145,62 +159,17 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				if !ok {
 					return errType
 				}
-
-				switch val {
-				// Miter join.
-				case 0:
-					ctx.SetLineJoin(context.LineJoinBevel)
-				// Round join.
-				case 1:
-					ctx.SetLineJoin(context.LineJoinRound)
-				// Bevel join.
-				case 2:
-					ctx.SetLineJoin(context.LineJoinBevel)
-				default:
-					common.Log.Debug("Invalid line join style: %d", val)
-					return errRange
-				}
+				return setLineJoin(ctx, val)
 			// Set miter limit.
 			case "M":
-				if len(op.Params) != 1 {
-					return errRange
-				}
-
-				fw, err := core.GetNumbersAsFloat(op.Params)
-				if err != nil {
-					return err
-				}
-
 				// TODO: Add miter support in context.
-				// ctx.SetMiterLimit(fw[0])
-				_ = fw
 				common.Log.Debug("Miter limit not supported")
 			// Set line dash pattern.
 			case "d":
 				if len(op.Params) != 2 {
 					return errRange
 				}
-
-				dashArray, ok := core.GetArray(op.Params[0])
-				if !ok {
-					return errType
-				}
-
-				phase, ok := core.GetIntVal(op.Params[1])
-				if !ok {
-					return errType
-				}
-
-				dashes, err := core.GetNumbersAsFloat(dashArray.Elements())
-				if err != nil {
-					return err
-				}
-				ctx.SetDash(dashes...)
-
-				// TODO: Add support for dash phase in context.
-				//ctx.SetDashPhase(phase)
-				_ = phase
-				common.Log.Debug("Line dash phase not supported")
+				return setDash(ctx, op.Params[0], op.Params[1])
 			// Set color rendering intent.
 			case "ri":
 				// TODO: Add rendering intent support.
@@ -226,16 +195,19 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				extobj, ok := resources.GetExtGState(*rname)
 				if !ok {
 					common.Log.Debug("ERROR: could not find resource: %s", *rname)
-					return errors.New("resource not found")
+					return nil
 				}
 
 				extdict, ok := core.GetDict(extobj)
 				if !ok {
 					common.Log.Debug("ERROR: could get graphics state dict")
-					return errType
+					return nil
 				}
 				common.Log.Debug("GS dict: %s", extdict.String())
 
+				// The transparency parameters are tracked by the processor.
+				applyExtGState(ctx, extdict)
+
 			//
 			// Path operators
 			//
@@ -255,6 +227,8 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				common.Log.Debug("Move to: %v", xy)
 				ctx.NewSubPath()
 				ctx.MoveTo(xy[0], xy[1])
+				pathStart = transform.NewPoint(xy[0], xy[1])
+				pathCurrent = pathStart
 			// Line to.
 			case "l":
 				if len(op.Params) != 2 {
@@ -268,6 +242,7 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				}
 
 				ctx.LineTo(xy[0], xy[1])
+				pathCurrent = transform.NewPoint(xy[0], xy[1])
 			// Cubic bezier.
 			case "c":
 				if len(op.Params) != 6 {
@@ -281,8 +256,23 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 
 				common.Log.Debug("Cubic bezier params: %+v", cbp)
 				ctx.CubicTo(cbp[0], cbp[1], cbp[2], cbp[3], cbp[4], cbp[5])
-			// Cubic bezier.
-			case "v", "y":
+				pathCurrent = transform.NewPoint(cbp[4], cbp[5])
+			// Cubic bezier with the current point as first control point.
+			case "v":
+				if len(op.Params) != 4 {
+					return errRange
+				}
+
+				cbp, err := core.GetNumbersAsFloat(op.Params)
+				if err != nil {
+					return err
+				}
+
+				common.Log.Debug("Cubic bezier params: %+v", cbp)
+				ctx.CubicTo(pathCurrent.X, pathCurrent.Y, cbp[0], cbp[1], cbp[2], cbp[3])
+				pathCurrent = transform.NewPoint(cbp[2], cbp[3])
+			// Cubic bezier with the end point as second control point.
+			case "y":
 				if len(op.Params) != 4 {
 					return errRange
 				}
@@ -293,11 +283,13 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				}
 
 				common.Log.Debug("Cubic bezier params: %+v", cbp)
-				ctx.QuadraticTo(cbp[0], cbp[1], cbp[2], cbp[3])
+				ctx.CubicTo(cbp[0], cbp[1], cbp[2], cbp[3], cbp[2], cbp[3])
+				pathCurrent = transform.NewPoint(cbp[2], cbp[3])
 			// Close current subpath.
 			case "h":
 				ctx.ClosePath()
 				ctx.NewSubPath()
+				pathCurrent = pathStart
 			// Rectangle.
 			case "re":
 				if len(op.Params) != 4 {
@@ -311,6 +303,8 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 
 				ctx.DrawRectangle(xywh[0], xywh[1], xywh[2], xywh[3])
 				ctx.NewSubPath()
+				pathStart = transform.NewPoint(xywh[0], xywh[1])
+				pathCurrent = pathStart
 
 			//
 			// Path painting operators
@@ -318,177 +312,31 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 
 			// Set path stroke.
 			case "S":
-				color, err := gs.ColorspaceStroking.ColorToRGB(gs.ColorStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor, ok := color.(*model.PdfColorDeviceRGB)
-				if !ok {
-					common.Log.Debug("Error converting color")
-					return err
-				}
-
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.Stroke()
+				paint(gs, false, false, true, context.FillRuleWinding)
 			// Close and stroke.
 			case "s":
-				color, err := gs.ColorspaceStroking.ColorToRGB(gs.ColorStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor, ok := color.(*model.PdfColorDeviceRGB)
-				if !ok {
-					common.Log.Debug("Error converting color")
-					return err
-				}
-
-				ctx.ClosePath()
-				ctx.NewSubPath()
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.Stroke()
+				paint(gs, true, false, true, context.FillRuleWinding)
 			// Fill path using non-zero winding number rule.
 			case "f", "F":
-				color, err := gs.ColorspaceNonStroking.ColorToRGB(gs.ColorNonStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor, ok := color.(*model.PdfColorDeviceRGB)
-				if !ok {
-					common.Log.Debug("Error converting color")
-					return err
-				}
-
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.SetFillRule(context.FillRuleWinding)
-				ctx.Fill()
+				paint(gs, false, true, false, context.FillRuleWinding)
 			// Fill path using even-odd rule.
 			case "f*":
-				color, err := gs.ColorspaceNonStroking.ColorToRGB(gs.ColorNonStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor, ok := color.(*model.PdfColorDeviceRGB)
-				if !ok {
-					common.Log.Debug("Error converting color")
-					return err
-				}
-
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.SetFillRule(context.FillRuleEvenOdd)
-				ctx.Fill()
+				paint(gs, false, true, false, context.FillRuleEvenOdd)
 			// Fill then stroke the path using non-zero winding rule.
 			case "B":
-				// Fill path.
-				color, err := gs.ColorspaceNonStroking.ColorToRGB(gs.ColorNonStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor := color.(*model.PdfColorDeviceRGB)
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.SetFillRule(context.FillRuleWinding)
-				ctx.FillPreserve()
-
-				// Stroke path.
-				color, err = gs.ColorspaceStroking.ColorToRGB(gs.ColorStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor = color.(*model.PdfColorDeviceRGB)
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.Stroke()
+				paint(gs, false, true, true, context.FillRuleWinding)
 			// Fill then stroke the path using even-odd rule.
 			case "B*":
-				// Fill path.
-				color, err := gs.ColorspaceNonStroking.ColorToRGB(gs.ColorNonStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor := color.(*model.PdfColorDeviceRGB)
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.SetFillRule(context.FillRuleEvenOdd)
-				ctx.FillPreserve()
-
-				// Stroke path.
-				color, err = gs.ColorspaceStroking.ColorToRGB(gs.ColorStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor = color.(*model.PdfColorDeviceRGB)
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.Stroke()
+				paint(gs, false, true, true, context.FillRuleEvenOdd)
 			// Close, fill and stroke the path using non-zero winding rule.
 			case "b":
-				// Fill path.
-				color, err := gs.ColorspaceNonStroking.ColorToRGB(gs.ColorNonStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor := color.(*model.PdfColorDeviceRGB)
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.ClosePath()
-				ctx.NewSubPath()
-				ctx.SetFillRule(context.FillRuleWinding)
-				ctx.FillPreserve()
-
-				// Stroke path.
-				color, err = gs.ColorspaceStroking.ColorToRGB(gs.ColorStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor = color.(*model.PdfColorDeviceRGB)
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.Stroke()
+				paint(gs, true, true, true, context.FillRuleWinding)
 			// Close, fill and stroke the path using even-odd rule.
 			case "b*":
-				// Close current subpath.
-				ctx.ClosePath()
-
-				// Fill path.
-				color, err := gs.ColorspaceNonStroking.ColorToRGB(gs.ColorNonStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor := color.(*model.PdfColorDeviceRGB)
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.NewSubPath()
-				ctx.SetFillRule(context.FillRuleEvenOdd)
-				ctx.FillPreserve()
-
-				// Stroke path.
-				color, err = gs.ColorspaceStroking.ColorToRGB(gs.ColorStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor = color.(*model.PdfColorDeviceRGB)
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.Stroke()
+				paint(gs, true, true, true, context.FillRuleEvenOdd)
 			// End the current path without filling or stroking.
 			case "n":
-				ctx.ClearPath()
+				paint(gs, false, false, false, context.FillRuleWinding)
 
 			//
 			// Path clipping operators
@@ -496,135 +344,12 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 
 			// Modify current clipping path using non-zero winding rule.
 			case "W":
-				// TODO: fix clipping.
-				//ctx.StrokePreserve()
-				//ctx.Clip()
-				ctx.SetFillRule(context.FillRuleWinding)
-				ctx.ClipPreserve()
+				rule := context.FillRuleWinding
+				clipRule = &rule
 			// Modify current clipping path using even-odd rule.
 			case "W*":
-				// TODO: fix clipping.
-				//ctx.StrokePreserve()
-				//ctx.Clip()
-				ctx.SetFillRule(context.FillRuleEvenOdd)
-				ctx.ClipPreserve()
-
-			//
-			// Color operators
-			//
-
-			// Set RGB non-stroking color.
-			case "rg":
-				rgbColor, ok := gs.ColorNonStroking.(*model.PdfColorDeviceRGB)
-				if !ok {
-					common.Log.Debug("Error converting color: %v", gs.ColorNonStroking)
-					return nil
-				}
-				ctx.SetFillRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-			// Set RGB stroking color.
-			case "RG":
-				rgbColor, ok := gs.ColorStroking.(*model.PdfColorDeviceRGB)
-				if !ok {
-					common.Log.Debug("Error converting color: %v", gs.ColorStroking)
-					return nil
-				}
-				ctx.SetStrokeRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-			// Set CMYK non-stroking color.
-			case "k":
-				cmykColor, ok := gs.ColorNonStroking.(*model.PdfColorDeviceCMYK)
-				if !ok {
-					common.Log.Debug("Error converting color: %v", gs.ColorNonStroking)
-					return nil
-				}
-				color, err := gs.ColorspaceNonStroking.ColorToRGB(cmykColor)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", gs.ColorNonStroking)
-					return nil
-				}
-				rgbColor, ok := color.(*model.PdfColorDeviceRGB)
-				if !ok {
-					common.Log.Debug("Error converting color: %v", color)
-					return nil
-				}
-				ctx.SetFillRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-			// Set CMYK stroking color.
-			case "K":
-				cmykColor, ok := gs.ColorStroking.(*model.PdfColorDeviceCMYK)
-				if !ok {
-					common.Log.Debug("Error converting color: %v", gs.ColorStroking)
-					return nil
-				}
-				color, err := gs.ColorspaceStroking.ColorToRGB(cmykColor)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", gs.ColorStroking)
-					return nil
-				}
-				rgbColor, ok := color.(*model.PdfColorDeviceRGB)
-				if !ok {
-					common.Log.Debug("Error converting color: %v", color)
-					return nil
-				}
-				ctx.SetStrokeRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-			// Set Grayscale non-stroking color.
-			case "g":
-				grayColor, ok := gs.ColorNonStroking.(*model.PdfColorDeviceGray)
-				if !ok {
-					common.Log.Debug("Error converting color: %v", gs.ColorNonStroking)
-					return nil
-				}
-				color, err := gs.ColorspaceNonStroking.ColorToRGB(grayColor)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", gs.ColorNonStroking)
-					return nil
-				}
-				rgbColor, ok := color.(*model.PdfColorDeviceRGB)
-				if !ok {
-					common.Log.Debug("Error converting color: %v", color)
-					return nil
-				}
-				ctx.SetFillRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-			// Set Grayscale stroking color.
-			case "G":
-				grayColor, ok := gs.ColorStroking.(*model.PdfColorDeviceGray)
-				if !ok {
-					common.Log.Debug("Error converting color: %v", gs.ColorStroking)
-					return nil
-				}
-				color, err := gs.ColorspaceStroking.ColorToRGB(grayColor)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", gs.ColorStroking)
-					return nil
-				}
-				rgbColor, ok := color.(*model.PdfColorDeviceRGB)
-				if !ok {
-					common.Log.Debug("Error converting color: %v", color)
-					return nil
-				}
-				ctx.SetStrokeRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-			case "cs", "sc", "scn":
-				color, err := gs.ColorspaceNonStroking.ColorToRGB(gs.ColorNonStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", gs.ColorNonStroking)
-					return nil
-				}
-				rgbColor, ok := color.(*model.PdfColorDeviceRGB)
-				if !ok {
-					common.Log.Debug("Error converting color: %v", color)
-					return nil
-				}
-				ctx.SetFillRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-			case "CS", "SC", "SCN":
-				color, err := gs.ColorspaceStroking.ColorToRGB(gs.ColorStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", gs.ColorStroking)
-					return nil
-				}
-				rgbColor, ok := color.(*model.PdfColorDeviceRGB)
-				if !ok {
-					common.Log.Debug("Error converting color: %v", color)
-					return nil
-				}
-				ctx.SetStrokeRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
+				rule := context.FillRuleEvenOdd
+				clipRule = &rule
 
 			//
 			// Image operators
@@ -650,23 +375,7 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 					if err != nil {
 						return err
 					}
-
-					img, err := ximg.ToImage()
-					if err != nil {
-						return err
-					}
-
-					goImg, err := img.ToGoImage()
-					if err != nil {
-						return err
-					}
-					bounds := goImg.Bounds()
-
-					// TODO: Handle soft masks.
-					ctx.Push()
-					ctx.Scale(1.0/float64(bounds.Dx()), -1.0/float64(bounds.Dy()))
-					ctx.DrawImageAnchored(goImg, 0, 0, 0, 1)
-					ctx.Pop()
+					return drawImage(ctx, ximg, gs)
 				case model.XObjectTypeForm:
 					common.Log.Debug("XObject form: %s", name.String())
 
@@ -687,6 +396,7 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 					}
 
 					ctx.Push()
+					defer ctx.Pop()
 					if xform.Matrix != nil {
 						array, ok := core.GetArray(xform.Matrix)
 						if !ok {
@@ -722,18 +432,14 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 
 						// Set clipping region.
 						ctx.DrawRectangle(bf[0], bf[1], bf[2]-bf[0], bf[3]-bf[1])
-						ctx.SetRGBA(1, 0, 0, 1)
+						ctx.SetFillRule(context.FillRuleWinding)
 						ctx.Clip()
 					} else {
 						common.Log.Debug("ERROR: Required BBox missing on XObject Form")
 					}
 
 					// Process the content stream in the Form object.
-					err = r.renderContentStream(ctx, string(formContent), formResources)
-					if err != nil {
-						return err
-					}
-					ctx.Pop()
+					return r.renderContentStream(ctx, string(formContent), formResources, fonts)
 				}
 			// Display inline image.
 			case "BI":
@@ -746,21 +452,11 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 					return nil
 				}
 
-				img, err := iimg.ToImage(resources)
-				if err != nil {
-					return err
-				}
-
-				goImg, err := img.ToGoImage()
+				ximg, err := iimg.ToXObject(resources)
 				if err != nil {
 					return err
 				}
-				bounds := goImg.Bounds()
-
-				ctx.Push()
-				ctx.Scale(1.0/float64(bounds.Dx()), -1.0/float64(bounds.Dy()))
-				ctx.DrawImageAnchored(goImg, 0, 0, 0, 1)
-				ctx.Pop()
+				return drawImage(ctx, ximg, gs)
 
 			//
 			// Text operators
@@ -832,6 +528,21 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				}
 
 				textState.Ts = ts
+			// Set text rendering mode.
+			case "Tr":
+				if len(op.Params) != 1 {
+					return errRange
+				}
+
+				tr, ok := core.GetIntVal(op.Params[0])
+				if !ok {
+					return errType
+				}
+				if tr < 0 || tr > int(context.TextRenderingModeClip) {
+					return errRange
+				}
+
+				textState.Tr = context.TextRenderingMode(tr)
 			// Move to the next line with specified offsets.
 			case "Td":
 				if len(op.Params) != 2 {
@@ -885,6 +596,7 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				}
 				common.Log.Debug("' string: %s", string(charcodes))
 
+				setTextColors(ctx, gs)
 				textState.ProcQ(charcodes, ctx)
 			// Move to the next line and show text string.
 			case `"`:
@@ -907,6 +619,7 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 					return errType
 				}
 
+				setTextColors(ctx, gs)
 				textState.ProcDQ(charcodes, aw, ac, ctx)
 			// Show text string.
 			case "Tj":
@@ -920,6 +633,7 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				}
 				common.Log.Debug("Tj string: `%s`", string(charcodes))
 
+				setTextColors(ctx, gs)
 				textState.ProcTj(charcodes, ctx)
 			// Show array of text strings.
 			case "TJ":
@@ -934,6 +648,7 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				}
 				common.Log.Debug("TJ array: %+v", array)
 
+				setTextColors(ctx, gs)
 				for _, obj := range array.Elements() {
 					switch t := obj.(type) {
 					case *core.PdfObjectString:
@@ -943,7 +658,8 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 					case *core.PdfObjectFloat, *core.PdfObjectInteger:
 						val, err := core.GetNumberAsFloat(t)
 						if err == nil {
-							textState.Translate(-val*0.001*textState.Tf.Size, 0)
+							th := textState.Th / 100.0
+							textState.Translate(-val*0.001*textState.Tfs*th, 0)
 						}
 					}
 				}
@@ -970,82 +686,21 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				}
 				common.Log.Debug("Font size: %v", fontSize)
 
-				// Search font in resources.
-				fObj, has := resources.GetFontByName(*fontName)
-				if !has {
-					common.Log.Debug("ERROR: Font %s not found", fontName.String())
-					return errors.New("font not found")
-				}
-				common.Log.Debug("Font: %T", fObj)
-
-				fontDict, ok := core.GetDict(fObj)
-				if !ok {
-					common.Log.Debug("ERROR: could not get font dict")
-					return errType
-				}
-
-				pdfFont, err := model.NewPdfFontFromPdfObject(fontDict)
-				if err != nil {
-					common.Log.Debug("ERROR: could not load font from object")
-					return err
-				}
-
-				baseFont := pdfFont.BaseFont()
-				if baseFont == "" {
-					baseFont = fontName.String()
-				}
-
-				textFont, ok := fontCache[baseFont]
-				if !ok {
-					textFont, err = context.NewTextFont(pdfFont, fontSize)
+				// Search font in resources. The text shown with fonts which
+				// cannot be loaded is not drawn but still advances the text
+				// matrix.
+				var textFont *context.TextFont
+				if fObj, has := resources.GetFontByName(*fontName); has {
+					textFont, err = fonts.textFont(fObj)
 					if err != nil {
-						common.Log.Debug("ERROR: %v", err)
-					}
-				}
-
-				if textFont == nil {
-					// Treat cases such as: OPEIOA+ArialMT
-					if len(baseFont) > 7 && baseFont[6] == '+' {
-						baseFont = baseFont[7:]
-					}
-
-					substitutes := []string{baseFont, "Times New Roman", "Arial", "DejaVu Sans"}
-					for _, name := range substitutes {
-						common.Log.Debug("DEBUG: searching system font `%s`", name)
-
-						// Check if font is cached.
-						if textFont, ok = fontCache[name]; ok {
-							break
-						}
-
-						// Find font or suitable alternative.
-						fontInfo := fontFinder.Match(name)
-						if fontInfo == nil {
-							common.Log.Debug("could not find font file %s", name)
-							continue
-						}
-
-						// Load matched font.
-						textFont, err = context.NewTextFontFromPath(fontInfo.Filename, fontSize)
-						if err != nil {
-							common.Log.Debug("could not load font file %s", fontInfo.Filename)
-							continue
-						}
-
-						// Update font cache.
-						common.Log.Debug("Substituting font %s with %s (%s)", baseFont, fontInfo.Name, fontInfo.Filename)
-						fontCache[name] = textFont
-						break
+						common.Log.Debug("ERROR: could not load font %s: %v", fontName, err)
 					}
-				}
-
-				if textFont == nil {
-					common.Log.Debug("ERROR: could not find any suitable font")
-					return errors.New("could not find any suitable font")
+				} else {
+					common.Log.Debug("ERROR: Font %s not found", fontName.String())
 				}
 
 				// Set font.
-				textState.ProcTf(textFont.WithSize(fontSize, pdfFont))
+				textState.ProcTf(textFont, fontSize)
 
 			//
 			// Marked content operators
@@ -1069,3 +724,141 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 
 	return nil
 }
+
+// drawImage draws image XObject `ximg` in the unit square of the user space
+// of `ctx`. The stencil masks are painted with the fill color of `gs`.
+func drawImage(ctx context.Context, ximg *model.XObjectImage, gs contentstream.GraphicsState) error {
+	goImg, err := xObjectToGoImage(ximg, toNRGBA(toRGB(gs.ColorspaceNonStroking, gs.ColorNonStroking)))
+	if err != nil {
+		return err
+	}
+	bounds := goImg.Bounds()
+
+	ctx.Push()
+	ctx.Scale(1.0/float64(bounds.Dx()), -1.0/float64(bounds.Dy()))
+	ctx.DrawImageAnchored(goImg, 0, 0, 0, 1)
+	ctx.Pop()
+	return nil
+}
+
+// setFillColor sets the fill color of `ctx` to the non-stroking color of `gs`.
+func setFillColor(ctx context.Context, gs contentstream.GraphicsState) {
+	r, g, b := toRGB(gs.ColorspaceNonStroking, gs.ColorNonStroking)
+	ctx.SetFillRGBA(r, g, b, gs.AlphaNonStroking)
+}
+
+// setStrokeColor sets the stroke color of `ctx` to the stroking color of `gs`.
+func setStrokeColor(ctx context.Context, gs contentstream.GraphicsState) {
+	r, g, b := toRGB(gs.ColorspaceStroking, gs.ColorStroking)
+	ctx.SetStrokeRGBA(r, g, b, gs.AlphaStroking)
+}
+
+// setTextColors sets the colors used to fill and stroke glyphs to those of
+// `gs`.
+func setTextColors(ctx context.Context, gs contentstream.GraphicsState) {
+	setFillColor(ctx, gs)
+	setStrokeColor(ctx, gs)
+}
+
+// toRGB returns the RGB components of `color` in colorspace `cs`. Colors
+// which cannot be converted, e.g. patterns, are rendered black.
+func toRGB(cs model.PdfColorspace, color model.PdfColor) (float64, float64, float64) {
+	if cs == nil || color == nil {
+		return 0, 0, 0
+	}
+	converted, err := cs.ColorToRGB(color)
+	if err != nil {
+		common.Log.Debug("Error converting color: %v", err)
+		return 0, 0, 0
+	}
+	rgbColor, ok := converted.(*model.PdfColorDeviceRGB)
+	if !ok {
+		common.Log.Debug("Error converting color: %v", converted)
+		return 0, 0, 0
+	}
+	return rgbColor.R(), rgbColor.G(), rgbColor.B()
+}
+
+// setLineCap sets the line cap style of `ctx` from PDF line cap style `val`.
+func setLineCap(ctx context.Context, val int) error {
+	switch val {
+	// Butt cap.
+	case 0:
+		ctx.SetLineCap(context.LineCapButt)
+	// Round cap.
+	case 1:
+		ctx.SetLineCap(context.LineCapRound)
+	// Projecting square cap.
+	case 2:
+		ctx.SetLineCap(context.LineCapSquare)
+	default:
+		common.Log.Debug("Invalid line cap style: %d", val)
+		return errRange
+	}
+	return nil
+}
+
+// setLineJoin sets the line join style of `ctx` from PDF line join style
+// `val`.
+func setLineJoin(ctx context.Context, val int) error {
+	switch val {
+	// Miter join.
+	case 0:
+		ctx.SetLineJoin(context.LineJoinBevel)
+	// Round join.
+	case 1:
+		ctx.SetLineJoin(context.LineJoinRound)
+	// Bevel join.
+	case 2:
+		ctx.SetLineJoin(context.LineJoinBevel)
+	default:
+		common.Log.Debug("Invalid line join style: %d", val)
+		return errRange
+	}
+	return nil
+}
+
+// setDash sets the line dash pattern of `ctx` from dash array `arrayObj` and
+// dash phase `phaseObj`.
+func setDash(ctx context.Context, arrayObj, phaseObj core.PdfObject) error {
+	dashArray, ok := core.GetArray(arrayObj)
+	if !ok {
+		return errType
+	}
+
+	phase, err := core.GetNumberAsFloat(phaseObj)
+	if err != nil {
+		return errType
+	}
+
+	dashes, err := core.GetNumbersAsFloat(dashArray.Elements())
+	if err != nil {
+		return err
+	}
+	ctx.SetDash(dashes...)
+	ctx.SetDashOffset(phase)
+	return nil
+}
+
+// applyExtGState applies the line style parameters of graphics state
+// parameter dictionary `extdict` to `ctx`.
+func applyExtGState(ctx context.Context, extdict *core.PdfObjectDictionary) {
+	if lw, err := core.GetNumberAsFloat(extdict.Get("LW")); err == nil {
+		ctx.SetLineWidth(lw)
+	}
+	if lc, ok := core.GetIntVal(extdict.Get("LC")); ok {
+		if err := setLineCap(ctx, lc); err != nil {
+			common.Log.Debug("ERROR: invalid LC: %v", err)
+		}
+	}
+	if lj, ok := core.GetIntVal(extdict.Get("LJ")); ok {
+		if err := setLineJoin(ctx, lj); err != nil {
+			common.Log.Debug("ERROR: invalid LJ: %v", err)
+		}
+	}
+	if d, ok := core.GetArray(extdict.Get("D")); ok && d.Len() == 2 {
+		if err := setDash(ctx, d.Get(0), d.Get(1)); err != nil {
+			common.Log.Debug("ERROR: invalid D: %v", err)
+		}
+	}
+}
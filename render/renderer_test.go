@@ -0,0 +1,304 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/pdftest"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// updateGolden is set to regenerate the golden images of the rendering tests
+// with `go test ./render -render-update-goldens`.
+var updateGolden = flag.Bool("render-update-goldens", false, "update the golden images")
+
+const (
+	// goldenThreshold is the per channel difference above which a pixel is
+	// considered to differ from the golden image.
+	goldenThreshold = 48
+	// goldenMaxDiffRatio is the maximum fraction of the pixels which may
+	// differ from the golden image.
+	goldenMaxDiffRatio = 0.01
+	// goldenMaxMeanDiff is the maximum mean channel difference over all
+	// the pixels.
+	goldenMaxMeanDiff = 2.0
+)
+
+func TestRenderGolden(t *testing.T) {
+	testcases := []struct {
+		name  string
+		dpi   float64
+		setup func(t *testing.T, page *model.PdfPage) string
+	}{
+		{"shapes", 0, setupShapes},
+		{"clipping", 0, setupClipping},
+		{"image", 0, setupImage},
+		{"text_standard", 144, setupStandardText},
+		{"text_truetype", 0, setupTrueTypeText},
+		{"text_cff", 0, setupCFFText},
+	}
+	for _, tcase := range testcases {
+		t.Run(tcase.name, func(t *testing.T) {
+			page := model.NewPdfPage()
+			page.MediaBox = &model.PdfRectangle{Urx: 200, Ury: 150}
+			// The content is wrapped in q/Q so that the state it sets does
+			// not apply to the content added by the writer.
+			content := "q\n" + tcase.setup(t, page) + "\nQ"
+			require.NoError(t, page.AddContentStreamByString(content))
+			page = pdftest.ReloadPage(t, page)
+
+			device := NewImageDevice()
+			device.DPI = tcase.dpi
+			img, err := device.Render(page)
+			require.NoError(t, err)
+			compareGolden(t, tcase.name, img)
+		})
+	}
+}
+
+func TestRenderRotatedCropBox(t *testing.T) {
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 200, Ury: 100}
+	page.CropBox = &model.PdfRectangle{Llx: 100, Lly: 0, Urx: 200, Ury: 100}
+	rotate := int64(90)
+	page.Rotate = &rotate
+	// Red top half and blue bottom half of the crop box.
+	require.NoError(t, page.AddContentStreamByString(
+		"1 0 0 rg 100 50 100 50 re f 0 0 1 rg 100 0 100 50 re f"))
+	page = pdftest.ReloadPage(t, page)
+
+	device := NewImageDevice()
+	device.DPI = 36
+	img, err := device.Render(page)
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 50, 50), img.Bounds())
+
+	// The top of the page is on the right side of the image.
+	require.Equal(t, [3]uint32{0, 0, 255}, pixelRGB(img, 20, 25))
+	require.Equal(t, [3]uint32{255, 0, 0}, pixelRGB(img, 45, 25))
+}
+
+func TestRenderImageMasks(t *testing.T) {
+	// Stencil mask painting its left pixel.
+	stamp := image.NewGray(image.Rect(0, 0, 2, 1))
+	stamp.SetGray(1, 0, color.Gray{Y: 255})
+	stencil, err := model.NewXObjectImageMaskFromImage(model.NewImageMaskFromGoImage(stamp), nil)
+	require.NoError(t, err)
+	// Blue and red image whose red pixel is masked out by the color key mask.
+	goImg := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	goImg.SetNRGBA(0, 0, color.NRGBA{B: 255, A: 255})
+	goImg.SetNRGBA(1, 0, color.NRGBA{R: 255, A: 255})
+	img, err := model.ImageHandling.NewImageFromGoImage(goImg)
+	require.NoError(t, err)
+	colorKey, err := model.NewXObjectImageFromImage(img, nil, core.NewFlateEncoder())
+	require.NoError(t, err)
+	colorKey.Mask = core.MakeArrayFromIntegers([]int{200, 255, 0, 0, 0, 0})
+
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 200, Ury: 100}
+	page.Resources = model.NewPdfPageResources()
+	require.NoError(t, page.Resources.SetXObjectImageByName("Im1", stencil))
+	require.NoError(t, page.Resources.SetXObjectImageByName("Im2", colorKey))
+	require.NoError(t, page.AddContentStreamByString(`q 0 1 0 rg 0 0 200 100 re f
+1 0 0 rg q 100 0 0 50 0 0 cm /Im1 Do Q
+q 100 0 0 50 100 0 cm /Im2 Do Q Q`))
+	page = pdftest.ReloadPage(t, page)
+
+	rendered, err := NewImageDevice().Render(page)
+	require.NoError(t, err)
+	require.Equal(t, [3]uint32{255, 0, 0}, pixelRGB(rendered, 25, 75))
+	require.Equal(t, [3]uint32{0, 255, 0}, pixelRGB(rendered, 75, 75))
+	require.Equal(t, [3]uint32{0, 0, 255}, pixelRGB(rendered, 125, 75))
+	require.Equal(t, [3]uint32{0, 255, 0}, pixelRGB(rendered, 175, 75))
+}
+
+func setupShapes(t *testing.T, page *model.PdfPage) string {
+	return `
+1 0 0 rg 10 10 50 40 re f
+0.5 g 70 10 50 40 re f
+0 1 0 0 k 130 10 50 40 re f
+0 0 1 RG 4 w 1 J 10 70 m 60 130 l 110 70 l S
+0 0.5 0 rg 1 0 0 RG 2 w 120 70 m 190 70 190 140 120 140 c 150 120 150 90 v h B
+0 0 0 rg 20 100 m 50 100 l 50 130 l 20 130 l h 28 108 m 42 108 l 42 122 l 28 122 l h f*
+`
+}
+
+func setupClipping(t *testing.T, page *model.PdfPage) string {
+	return `
+q 40 40 120 60 re W n
+q 50 0 0 50 80 70 cm 1 0 0 1 0 0 cm 0 1 m 0.55 1 1 0.55 1 0 c 1 -0.55 0.55 -1 0 -1 c
+-0.55 -1 -1 -0.55 -1 0 c -1 0.55 -0.55 1 0 1 c h W n
+0 0 1 rg -1 -1 2 2 re f Q
+1 0 0 rg 0 0 60 60 re f Q
+0 1 0 rg 150 10 40 40 re f
+`
+}
+
+func setupImage(t *testing.T, page *model.PdfPage) string {
+	// Gradient image with a transparent left half.
+	goImg := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			alpha := uint8(255)
+			if x < 20 {
+				alpha = 0
+			}
+			goImg.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 6), G: uint8(y * 12), B: 200, A: alpha})
+		}
+	}
+	img, err := model.ImageHandling.NewImageFromGoImage(goImg)
+	require.NoError(t, err)
+	ximg, err := model.NewXObjectImageFromImage(img, nil, core.NewFlateEncoder())
+	require.NoError(t, err)
+	require.NotNil(t, ximg.SMask)
+
+	page.Resources = model.NewPdfPageResources()
+	require.NoError(t, page.Resources.SetXObjectImageByName("Im1", ximg))
+	return `
+0 g 0 0 200 150 re f
+q 80 0 0 40 10 10 cm /Im1 Do Q
+q 0.7071 0.7071 -0.7071 0.7071 120 40 cm 60 0 0 30 0 0 cm /Im1 Do Q
+`
+}
+
+func setupStandardText(t *testing.T, page *model.PdfPage) string {
+	font, err := model.NewStandard14Font(model.HelveticaName)
+	require.NoError(t, err)
+	page.Resources = model.NewPdfPageResources()
+	require.NoError(t, page.Resources.SetFontByName("F1", font.ToPdfObject()))
+	return `
+BT /F1 24 Tf 10 110 Td (Hello, World!) Tj ET
+BT 0 0 1 rg /F1 14 Tf 2 Tc 10 70 Td [(Spaced) -500 (text)] TJ ET
+BT 1 0 0 RG 1 Tr /F1 30 Tf 10 20 Td (Outline) Tj ET
+`
+}
+
+func setupTrueTypeText(t *testing.T, page *model.PdfPage) string {
+	font, err := model.NewPdfFontFromTTFFile(filepath.Join("..", "model", "testdata", "font", "OpenSans-Regular.ttf"))
+	require.NoError(t, err)
+	page.Resources = model.NewPdfPageResources()
+	require.NoError(t, page.Resources.SetFontByName("F1", font.ToPdfObject()))
+	return `
+BT /F1 28 Tf 10 100 Td (TrueType) Tj ET
+BT 0 0.5 0 rg /F1 16 Tf 0.8660 0.5 -0.5 0.8660 30 20 Tm (Rotated glyphs) Tj ET
+`
+}
+
+func setupCFFText(t *testing.T, page *model.PdfPage) string {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "subset.cff"))
+	require.NoError(t, err)
+	fontFile, err := core.MakeStream(data, core.NewFlateEncoder())
+	require.NoError(t, err)
+	fontFile.Set("Subtype", core.MakeName("Type1C"))
+
+	descriptor := core.MakeDict()
+	descriptor.Set("Type", core.MakeName("FontDescriptor"))
+	descriptor.Set("FontName", core.MakeName("ABCDEF+Times-Roman"))
+	descriptor.Set("Flags", core.MakeInteger(34))
+	descriptor.Set("FontBBox", core.MakeArrayFromFloats([]float64{-168, -218, 1000, 898}))
+	descriptor.Set("ItalicAngle", core.MakeInteger(0))
+	descriptor.Set("Ascent", core.MakeInteger(683))
+	descriptor.Set("Descent", core.MakeInteger(-217))
+	descriptor.Set("CapHeight", core.MakeInteger(662))
+	descriptor.Set("StemV", core.MakeInteger(84))
+	descriptor.Set("FontFile3", fontFile)
+
+	// The advance widths are those of the font program.
+	fontDict := core.MakeDict()
+	fontDict.Set("Type", core.MakeName("Font"))
+	fontDict.Set("Subtype", core.MakeName("Type1"))
+	fontDict.Set("BaseFont", core.MakeName("ABCDEF+Times-Roman"))
+	fontDict.Set("FontDescriptor", descriptor)
+
+	page.Resources = model.NewPdfPageResources()
+	require.NoError(t, page.Resources.SetFontByName("F1", fontDict))
+	return `
+BT /F1 36 Tf 10 90 Td (Sample 2017) Tj ET
+BT 0.6 0 0 rg /F1 20 Tf 10 40 Td (Subset outlines) Tj ET
+`
+}
+
+// compareGolden compares `img` to the golden image `name` in testdata/golden,
+// or updates the golden image if the -render-update-goldens flag is set.
+func compareGolden(t *testing.T, name string, img image.Image) {
+	goldenPath := filepath.Join("testdata", "golden", name+".png")
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(goldenPath), 0755))
+		require.NoError(t, savePNG(goldenPath, img))
+		return
+	}
+
+	f, err := os.Open(goldenPath)
+	require.NoError(t, err)
+	defer f.Close()
+	golden, err := png.Decode(f)
+	require.NoError(t, err)
+	require.Equal(t, golden.Bounds(), img.Bounds())
+
+	ratio, mean := imageDiff(golden, img)
+	if ratio > goldenMaxDiffRatio || mean > goldenMaxMeanDiff {
+		failPath := filepath.Join(os.TempDir(), fmt.Sprintf("render_%s.png", name))
+		if err := savePNG(failPath, img); err == nil {
+			t.Logf("Rendered image saved to %s", failPath)
+		}
+		t.Fatalf("%s differs from the golden image: %.2f%% pixels differ, mean difference %.2f",
+			name, 100*ratio, mean)
+	}
+}
+
+// imageDiff returns the fraction of the pixels of `a` and `b` which differ
+// by more than goldenThreshold in any channel, and the mean channel
+// difference of the images, which must have the same bounds.
+func imageDiff(a, b image.Image) (float64, float64) {
+	bounds := a.Bounds()
+	var diffPixels int
+	var sum float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ca := color.NRGBAModel.Convert(a.At(x, y)).(color.NRGBA)
+			cb := color.NRGBAModel.Convert(b.At(x, y)).(color.NRGBA)
+			maxDiff := 0
+			for _, d := range []int{
+				absDiff(ca.R, cb.R), absDiff(ca.G, cb.G), absDiff(ca.B, cb.B), absDiff(ca.A, cb.A),
+			} {
+				sum += float64(d)
+				if d > maxDiff {
+					maxDiff = d
+				}
+			}
+			if maxDiff > goldenThreshold {
+				diffPixels++
+			}
+		}
+	}
+	n := float64(bounds.Dx() * bounds.Dy())
+	return float64(diffPixels) / n, sum / (4 * n)
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// pixelRGB returns the 8 bit RGB components of the pixel of `img` at `x`,`y`.
+func pixelRGB(img image.Image, x, y int) [3]uint32 {
+	r, g, b, _ := img.At(x, y).RGBA()
+	return [3]uint32{r >> 8, g >> 8, b >> 8}
+}
@@ -0,0 +1,10 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package replacer is used for replacing text directly in the content streams
+// of PDF pages, e.g. placeholder tokens of templates, keeping the fonts and
+// the positioning of the page content, and for replacing images, e.g. logos,
+// keeping their placement.
+package replacer
@@ -0,0 +1,276 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package replacer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// ImageFit specifies how a replacement image is placed when its aspect ratio
+// differs from the one of the replaced image.
+type ImageFit int
+
+// Image fit modes.
+const (
+	// ImageFitStretch stretches the replacement image to the placement of the
+	// replaced image.
+	ImageFitStretch ImageFit = iota
+
+	// ImageFitContain scales the replacement image, keeping its aspect ratio,
+	// to the largest size fitting in the placement of the replaced image and
+	// centers it.
+	ImageFitContain
+
+	// ImageFitCover scales the replacement image, keeping its aspect ratio,
+	// to the smallest size covering the placement of the replaced image and
+	// centers it. The parts of the image outside the placement are clipped.
+	ImageFitCover
+)
+
+// ImageSelector selects the image XObjects to replace. The images must match
+// all the criteria which are set.
+type ImageSelector struct {
+	// Name is the name of the image in the XObject resources of the page.
+	Name core.PdfObjectName
+
+	// ObjectNumber is the object number of the image stream, for images read
+	// from a document.
+	ObjectNumber int64
+
+	// Match returns true for the images to replace, given their names in the
+	// XObject resources of the page, e.g. to select images by dimensions.
+	Match func(name core.PdfObjectName, ximg *model.XObjectImage) bool
+}
+
+// ImageOptions defines the options of image replacements.
+type ImageOptions struct {
+	// Encoder is the encoder of the replacement image (FlateDecode when nil).
+	Encoder core.StreamEncoder
+
+	// Fit specifies how the replacement image is placed when its aspect
+	// ratio differs from the one of the replaced image (ImageFitStretch by
+	// default).
+	Fit ImageFit
+
+	// CopyShared replaces the images in the resources of the page only. By
+	// default, the image streams are replaced in place and the images are
+	// replaced in all the pages and form XObjects which paint them.
+	CopyShared bool
+}
+
+// imageKeys are the keys of the image dictionaries which are kept in the
+// dictionaries of their replacements, as they relate to their placement
+// rather than to their data.
+var imageKeys = []core.PdfObjectName{"Interpolate", "StructParent", "OC"}
+
+// ReplaceImage replaces the image XObjects of the resources of `page` matching
+// `selector` with `img`, with `opts` which can be nil. The image streams are
+// replaced with the encoded replacement, whose dictionary has its own Width,
+// Height, ColorSpace, BitsPerComponent and SMask, so that the content streams
+// painting the images are unchanged and the replacements have the same
+// placement as the replaced images.
+// With ImageFitContain or ImageFitCover fits, replaced images whose aspect
+// ratios differ from the one of `img` are replaced with form XObjects painting
+// `img` scaled in the unit square, where images are painted.
+// Images painted by form XObjects are not replaced, unless they share the
+// streams of replaced images of the page.
+// Returns the number of replaced images.
+func ReplaceImage(page *model.PdfPage, selector ImageSelector, img *model.Image, opts *ImageOptions) (int, error) {
+	if page == nil {
+		return 0, errors.New("page not specified")
+	}
+	if img == nil || img.Width <= 0 || img.Height <= 0 {
+		return 0, errors.New("invalid replacement image")
+	}
+	if opts == nil {
+		opts = &ImageOptions{}
+	}
+	if page.Resources == nil {
+		return 0, nil
+	}
+	xobjects, ok := core.GetDict(page.Resources.XObject)
+	if !ok {
+		return 0, nil
+	}
+
+	ic := &imageContext{
+		img:          img,
+		opts:         opts,
+		replacements: map[*core.PdfObjectStream]*core.PdfObjectStream{},
+	}
+	replaced := map[core.PdfObjectName]*core.PdfObjectStream{}
+	var names []core.PdfObjectName
+	for _, name := range xobjects.Keys() {
+		stream, xtype := page.Resources.GetXObjectByName(name)
+		if xtype != model.XObjectTypeImage {
+			continue
+		}
+		match, err := selector.matches(name, stream)
+		if err != nil {
+			return 0, err
+		}
+		if !match {
+			continue
+		}
+		replacement, err := ic.replace(stream)
+		if err != nil {
+			return 0, err
+		}
+		replaced[name] = replacement
+		names = append(names, name)
+	}
+	if len(names) == 0 || !opts.CopyShared {
+		return len(names), nil
+	}
+
+	// The XObject dictionary can be shared by pages inheriting their resources.
+	copied := core.MakeDict()
+	for _, name := range xobjects.Keys() {
+		copied.Set(name, xobjects.Get(name))
+	}
+	for _, name := range names {
+		copied.Set(name, replaced[name])
+	}
+	page.Resources.XObject = copied
+	return len(names), nil
+}
+
+// matches returns true if the image `stream` named `name` matches the criteria
+// of `sel`.
+func (sel ImageSelector) matches(name core.PdfObjectName, stream *core.PdfObjectStream) (bool, error) {
+	if sel.Name != "" && sel.Name != name {
+		return false, nil
+	}
+	if sel.ObjectNumber != 0 && sel.ObjectNumber != stream.ObjectNumber {
+		return false, nil
+	}
+	if sel.Match == nil {
+		return true, nil
+	}
+	ximg, err := model.NewXObjectImageFromStream(stream)
+	if err != nil {
+		return false, fmt.Errorf("image %s: %w", name, err)
+	}
+	return sel.Match(name, ximg), nil
+}
+
+// imageContext represents the replacement of images of a page.
+type imageContext struct {
+	img  *model.Image
+	opts *ImageOptions
+
+	// Replacements of the image streams, so that images named multiple times
+	// are replaced once.
+	replacements map[*core.PdfObjectStream]*core.PdfObjectStream
+}
+
+// replace returns the replacement of the image `stream`, which is `stream`
+// itself unless copies are replaced.
+func (ic *imageContext) replace(stream *core.PdfObjectStream) (*core.PdfObjectStream, error) {
+	if replacement, ok := ic.replacements[stream]; ok {
+		return replacement, nil
+	}
+
+	ximg, err := model.NewXObjectImageFromImage(ic.img, nil, ic.encoder())
+	if err != nil {
+		return nil, err
+	}
+	encoded, ok := ximg.ToPdfObject().(*core.PdfObjectStream)
+	if !ok {
+		return nil, core.ErrTypeError
+	}
+	for _, key := range imageKeys {
+		encoded.SetIfNotNil(key, stream.Get(key))
+	}
+	if m, ok := ic.fitMatrix(stream); ok {
+		encoded, err = fitForm(encoded, m)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	replacement := encoded
+	if !ic.opts.CopyShared {
+		// Replace the image in place, so that all its references are updated.
+		replacement = stream
+		replacement.PdfObjectDictionary = encoded.PdfObjectDictionary
+		replacement.Stream = encoded.Stream
+	}
+	ic.replacements[stream] = replacement
+	return replacement, nil
+}
+
+// encoder returns the encoder of the replacement image.
+func (ic *imageContext) encoder() core.StreamEncoder {
+	if ic.opts.Encoder != nil {
+		return ic.opts.Encoder
+	}
+	return core.NewFlateEncoder()
+}
+
+// fitMatrix returns the matrix mapping the unit square to the placement of the
+// replacement image in the unit square where the image `stream` is painted,
+// and false if the replacement image is stretched to the unit square.
+func (ic *imageContext) fitMatrix(stream *core.PdfObjectStream) ([6]float64, bool) {
+	if ic.opts.Fit == ImageFitStretch {
+		return [6]float64{}, false
+	}
+	width, err := core.GetNumberAsFloat(core.TraceToDirectObject(stream.Get("Width")))
+	if err != nil || width <= 0 {
+		common.Log.Debug("Invalid image width, stretching replacement: %v", stream.Get("Width"))
+		return [6]float64{}, false
+	}
+	height, err := core.GetNumberAsFloat(core.TraceToDirectObject(stream.Get("Height")))
+	if err != nil || height <= 0 {
+		common.Log.Debug("Invalid image height, stretching replacement: %v", stream.Get("Height"))
+		return [6]float64{}, false
+	}
+
+	// The ratio of the aspect ratios of the replacement and of the replaced
+	// image, which is stretched to the unit square.
+	ratio := (float64(ic.img.Width) / float64(ic.img.Height)) / (width / height)
+	if math.Abs(ratio-1) < 1e-6 {
+		return [6]float64{}, false
+	}
+	sx, sy := 1.0, 1.0
+	if (ratio > 1) == (ic.opts.Fit == ImageFitContain) {
+		sy = 1 / ratio
+	} else {
+		sx = ratio
+	}
+	return [6]float64{sx, 0, 0, sy, (1 - sx) / 2, (1 - sy) / 2}, true
+}
+
+// fitForm returns a form XObject painting the image `ximg` in the unit square
+// transformed by `m`, clipped to the unit square.
+func fitForm(ximg *core.PdfObjectStream, m [6]float64) (*core.PdfObjectStream, error) {
+	xform := model.NewXObjectForm()
+	xform.BBox = core.MakeArrayFromFloats([]float64{0, 0, 1, 1})
+	xform.Resources = model.NewPdfPageResources()
+	if err := xform.Resources.SetXObjectByName("Im0", ximg); err != nil {
+		return nil, err
+	}
+	content := fmt.Sprintf("q %.6f %.6f %.6f %.6f %.6f %.6f cm /Im0 Do Q", m[0], m[1], m[2], m[3], m[4], m[5])
+	if err := xform.SetContentStream([]byte(content), core.NewFlateEncoder()); err != nil {
+		return nil, err
+	}
+	form, ok := xform.ToPdfObject().(*core.PdfObjectStream)
+	if !ok {
+		return nil, core.ErrTypeError
+	}
+	// The form is the structure element or optional content member in place of the image.
+	for _, key := range []core.PdfObjectName{"StructParent", "OC"} {
+		form.SetIfNotNil(key, ximg.Get(key))
+		ximg.Remove(key)
+	}
+	return form, nil
+}
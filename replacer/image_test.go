@@ -0,0 +1,174 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package replacer
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/extractor"
+	"github.com/unidoc/unipdf/v3/internal/pdftest"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// solidImage returns a `width` by `height` image of color `c`.
+func solidImage(t *testing.T, width, height int, c color.Color) *model.Image {
+	goimg := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			goimg.Set(x, y, c)
+		}
+	}
+	img, err := model.ImageHandling.NewImageFromGoImage(goimg)
+	require.NoError(t, err)
+	return img
+}
+
+// logoPages returns the pages of a document whose pages paint a shared 2x1 red logo image
+// named Logo, the first page also painting a 1x1 gray image named Dot.
+func logoPages(t *testing.T) []*model.PdfPage {
+	logo, err := model.NewXObjectImageFromImage(solidImage(t, 2, 1, color.RGBA{R: 255, A: 255}), nil,
+		core.NewFlateEncoder())
+	require.NoError(t, err)
+	dot, err := model.NewXObjectImageFromImage(solidImage(t, 1, 1, color.Gray{Y: 128}), nil, nil)
+	require.NoError(t, err)
+
+	w := model.NewPdfWriter()
+	for i := 0; i < 2; i++ {
+		page := model.NewPdfPage()
+		page.MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+		require.NoError(t, page.Resources.SetXObjectImageByName("Logo", logo))
+		contents := "q 100 0 0 50 72 600 cm /Logo Do Q\n"
+		if i == 0 {
+			require.NoError(t, page.Resources.SetXObjectImageByName("Dot", dot))
+			contents += "q 10 0 0 10 300 300 cm /Dot Do Q\n"
+		}
+		require.NoError(t, page.SetContentStreams([]string{contents}, nil))
+		require.NoError(t, w.AddPage(page))
+	}
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, w.Write(buf))
+
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	return reader.PageList
+}
+
+// extractImages returns the images of `page`, read back after writing it to a new document.
+func extractImages(t *testing.T, page *model.PdfPage) []extractor.ImageMark {
+	ex, err := extractor.New(pdftest.ReloadPage(t, page))
+	require.NoError(t, err)
+	images, err := ex.ExtractPageImages(nil)
+	require.NoError(t, err)
+	return images.Images
+}
+
+// requirePlacement requires image mark `mark` to be placed in the rectangle of width `width`
+// and height `height` whose lower left corner is (`x`, `y`).
+func requirePlacement(t *testing.T, mark extractor.ImageMark, x, y, width, height float64) {
+	require.InDelta(t, x, mark.X, 0.01)
+	require.InDelta(t, y, mark.Y, 0.01)
+	require.InDelta(t, width, mark.Width, 0.01)
+	require.InDelta(t, height, mark.Height, 0.01)
+}
+
+func TestReplaceImage(t *testing.T) {
+	pages := logoPages(t)
+	original := extractImages(t, pages[0])
+	require.Len(t, original, 2)
+	red := original[0].Image.Data
+	requirePlacement(t, original[0], 72, 600, 100, 50)
+
+	// The logo is selected by its dimensions and replaced on both pages.
+	isLogo := func(name core.PdfObjectName, ximg *model.XObjectImage) bool {
+		return *ximg.Width == 2 && *ximg.Height == 1
+	}
+	blue := solidImage(t, 4, 2, color.RGBA{B: 255, A: 255})
+	count, err := ReplaceImage(pages[0], ImageSelector{Match: isLogo}, blue, &ImageOptions{Fit: ImageFitContain})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	for i, page := range pages {
+		images := extractImages(t, page)
+		require.Len(t, images, 2-i)
+		logo := images[0]
+		requirePlacement(t, logo, 72, 600, 100, 50)
+		require.Equal(t, int64(4), logo.Image.Width)
+		require.Equal(t, int64(2), logo.Image.Height)
+		require.NotEqual(t, red, logo.Image.Data)
+		require.Equal(t, []byte{0, 0, 255}, logo.Image.Data[:3])
+	}
+	dot := extractImages(t, pages[0])[1]
+	require.Equal(t, original[1].Image.Data, dot.Image.Data)
+	requirePlacement(t, dot, 300, 300, 10, 10)
+
+	// Nothing is replaced.
+	count, err = ReplaceImage(pages[0], ImageSelector{Name: "Missing"}, blue, nil)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	_, err = ReplaceImage(pages[0], ImageSelector{}, nil, nil)
+	require.Error(t, err)
+}
+
+func TestReplaceImageCopyShared(t *testing.T) {
+	pages := logoPages(t)
+	stream, _ := pages[1].Resources.GetXObjectByName("Logo")
+	require.NotZero(t, stream.ObjectNumber)
+	original := extractImages(t, pages[1])
+
+	green := solidImage(t, 3, 3, color.RGBA{G: 255, A: 255})
+	selector := ImageSelector{ObjectNumber: stream.ObjectNumber}
+	count, err := ReplaceImage(pages[1], selector, green, &ImageOptions{CopyShared: true})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	// The image is stretched to the placement of the logo on the second page only.
+	images := extractImages(t, pages[1])
+	require.Len(t, images, 1)
+	requirePlacement(t, images[0], 72, 600, 100, 50)
+	require.Equal(t, int64(3), images[0].Image.Width)
+	require.Equal(t, []byte{0, 255, 0}, images[0].Image.Data[:3])
+	require.NotEqual(t, original[0].Image.Data, images[0].Image.Data)
+
+	images = extractImages(t, pages[0])
+	require.Len(t, images, 2)
+	require.Equal(t, original[0].Image.Data, images[0].Image.Data)
+	unchanged, _ := pages[0].Resources.GetXObjectByName("Logo")
+	require.True(t, unchanged == stream)
+}
+
+func TestReplaceImageFit(t *testing.T) {
+	square := solidImage(t, 2, 2, color.RGBA{B: 255, A: 255})
+	for _, tc := range []struct {
+		fit                 ImageFit
+		x, y, width, height float64
+	}{
+		{ImageFitStretch, 72, 600, 100, 50},
+		{ImageFitContain, 97, 600, 50, 50},
+		{ImageFitCover, 72, 575, 100, 100},
+	} {
+		pages := logoPages(t)
+		count, err := ReplaceImage(pages[0], ImageSelector{Name: "Logo"}, square, &ImageOptions{Fit: tc.fit})
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+
+		images := extractImages(t, pages[0])
+		require.Len(t, images, 2)
+		requirePlacement(t, images[0], tc.x, tc.y, tc.width, tc.height)
+		require.Equal(t, int64(2), images[0].Image.Width)
+
+		// The content streams paint the replacement as they painted the logo.
+		contents, err := pages[0].GetAllContentStreams()
+		require.NoError(t, err)
+		require.Contains(t, contents, "/Logo Do")
+	}
+}
@@ -0,0 +1,549 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package replacer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/tjarray"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// Reflow specifies how the difference between the widths of the replaced text
+// and of its replacement is handled.
+type Reflow int
+
+// Reflow modes.
+const (
+	// ReflowNone keeps the text following a replacement at its original
+	// position. A replacement wider than the replaced text can overlap the
+	// following text.
+	ReflowNone Reflow = iota
+
+	// ReflowCompressSpacing fits the replacement in the width of the replaced
+	// text by adjusting the spacing between its glyphs, compressing it for
+	// wider replacements and expanding it for narrower ones. The text
+	// following the replacement keeps its original position.
+	ReflowCompressSpacing
+)
+
+// Replacement represents the replacement of the occurrences of Find with
+// Replace.
+type Replacement struct {
+	Find    string
+	Replace string
+}
+
+// Options defines the options of text replacements.
+type Options struct {
+	// Reflow specifies how width differences are handled (ReflowNone by
+	// default).
+	Reflow Reflow
+}
+
+// ReplaceText replaces the occurrences of the Find strings of `replacements`
+// in the text shown by the content streams of `page`, with `opts` which can be
+// nil. The text is decoded using the fonts of the page and the replacements
+// are encoded with the font of the first replaced glyph, which must be able to
+// encode them, e.g. subset fonts only have the glyphs of the original text.
+// Occurrences can span multiple strings of a TJ array or multiple text showing
+// operators of a text object (BT/ET). When multiple Find strings occur at the
+// same position, the first one of `replacements` is replaced.
+// The text showing operators are rewritten: the replaced glyphs are removed,
+// the replacement is shown at the position of the first replaced glyph and
+// kerning adjustments keep the following text at its position.
+// Text shown by form XObjects is not replaced.
+// Returns the number of replaced occurrences.
+func ReplaceText(page *model.PdfPage, replacements []Replacement, opts *Options) (int, error) {
+	if page == nil {
+		return 0, errors.New("page not specified")
+	}
+	for _, r := range replacements {
+		if r.Find == "" {
+			return 0, errors.New("empty text to find")
+		}
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	contents, err := page.GetAllContentStreams()
+	if err != nil {
+		return 0, err
+	}
+	ops, err := contentstream.NewContentStreamParser(contents).Parse()
+	if err != nil {
+		return 0, err
+	}
+
+	runs := collectTextRuns(*ops, page.Resources)
+	rc := &replaceContext{
+		opts:    opts,
+		removed: map[*glyph]struct{}{},
+		inserts: map[*glyph]*insertion{},
+	}
+	count := 0
+	for _, run := range runs {
+		n, err := rc.matchRun(run, replacements)
+		if err != nil {
+			return 0, err
+		}
+		count += n
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	var out contentstream.ContentStreamOperations
+	edited := map[int][]*contentstream.ContentStreamOperation{}
+	for _, run := range runs {
+		for _, top := range run.ops {
+			if rc.isEdited(top) {
+				edited[top.index] = rc.rewrite(top)
+			}
+		}
+	}
+	for i, op := range *ops {
+		if replaced, ok := edited[i]; ok {
+			out = append(out, replaced...)
+			continue
+		}
+		out = append(out, op)
+	}
+
+	if err := page.SetContentStreams([]string{out.String()}, core.NewFlateEncoder()); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// textState represents the text state parameters affecting the horizontal
+// displacement of glyphs.
+// See section 9.3 "Text State Parameters and Operators" (p. 243 PDF32000_2008).
+type textState struct {
+	tc   float64 // Character spacing.
+	tw   float64 // Word spacing.
+	th   float64 // Horizontal scaling (percent).
+	tfs  float64 // Font size.
+	font *model.PdfFont
+}
+
+// textOp represents a text showing operation.
+type textOp struct {
+	index    int // Index of the operation in the content stream.
+	op       *contentstream.ContentStreamOperation
+	elements []core.PdfObject // Strings and adjustments shown, as in a TJ array.
+	glyphs   [][]*glyph       // Glyphs of the string elements, by element.
+	state    textState
+}
+
+// glyph represents a glyph shown by a text showing operation.
+type glyph struct {
+	top     *textOp
+	data    []byte // Character code bytes.
+	text    string
+	advance float64 // Horizontal displacement in unscaled text space units.
+	fixed   bool    // The code boundaries of the string are unknown.
+}
+
+// textRun represents the glyphs shown by the text showing operations of a
+// text object.
+type textRun struct {
+	ops    []*textOp
+	glyphs []*glyph
+}
+
+// collectTextRuns returns the glyphs shown by the operations `ops`, by text
+// object. The text objects are split at the operations using fonts which
+// cannot be loaded.
+func collectTextRuns(ops contentstream.ContentStreamOperations, resources *model.PdfPageResources) []*textRun {
+	var runs []*textRun
+	var run *textRun
+	state := textState{th: 100}
+	var stack []textState
+	fonts := map[core.PdfObjectName]*model.PdfFont{}
+
+	endRun := func() {
+		if run != nil && len(run.glyphs) > 0 {
+			runs = append(runs, run)
+		}
+		run = nil
+	}
+
+	for i, op := range ops {
+		vals, _ := core.GetNumbersAsFloat(op.Params)
+		switch op.Operand {
+		case "q":
+			stack = append(stack, state)
+		case "Q":
+			if len(stack) > 0 {
+				state = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "BT", "ET":
+			endRun()
+		case "Tc":
+			if len(vals) == 1 {
+				state.tc = vals[0]
+			}
+		case "Tw":
+			if len(vals) == 1 {
+				state.tw = vals[0]
+			}
+		case "Tz":
+			if len(vals) == 1 {
+				state.th = vals[0]
+			}
+		case "Tf":
+			if len(op.Params) == 2 {
+				name, _ := core.GetName(op.Params[0])
+				size, err := core.GetNumberAsFloat(op.Params[1])
+				if name != nil && err == nil {
+					state.font = loadFont(*name, resources, fonts)
+					state.tfs = size
+				}
+			}
+		case "Tj", "TJ", "'", "\"":
+			top := newTextOp(i, op, &state)
+			if top == nil || state.font == nil || state.tfs == 0 {
+				endRun()
+				continue
+			}
+			if run == nil {
+				run = &textRun{}
+			}
+			run.ops = append(run.ops, top)
+			for _, glyphs := range top.glyphs {
+				run.glyphs = append(run.glyphs, glyphs...)
+			}
+		}
+	}
+	endRun()
+	return runs
+}
+
+// loadFont returns the font with the specified resource name, or nil if the
+// font cannot be loaded.
+func loadFont(name core.PdfObjectName, resources *model.PdfPageResources,
+	fonts map[core.PdfObjectName]*model.PdfFont) *model.PdfFont {
+	if font, ok := fonts[name]; ok {
+		return font
+	}
+
+	var font *model.PdfFont
+	if resources != nil {
+		if obj, ok := resources.GetFontByName(name); ok {
+			var err error
+			font, err = model.NewPdfFontFromPdfObject(obj)
+			if err != nil {
+				common.Log.Debug("ERROR: unable to load font %s: %v", name, err)
+				font = nil
+			}
+		}
+	}
+	if font == nil {
+		common.Log.Debug("WARN: font %s not found. Its text is not replaced", name)
+	}
+	fonts[name] = font
+	return font
+}
+
+// newTextOp returns the text showing operation `op`, the index of the
+// operation being `index`, with the glyphs it shows. `state` is updated with
+// the spacing set by the " operator. Returns nil if the operation is invalid.
+func newTextOp(index int, op *contentstream.ContentStreamOperation, state *textState) *textOp {
+	var elements []core.PdfObject
+	switch op.Operand {
+	case "Tj", "'":
+		if len(op.Params) != 1 {
+			return nil
+		}
+		elements = op.Params
+	case "\"":
+		if len(op.Params) != 3 {
+			return nil
+		}
+		aw, err1 := core.GetNumberAsFloat(op.Params[0])
+		ac, err2 := core.GetNumberAsFloat(op.Params[1])
+		if err1 != nil || err2 != nil {
+			return nil
+		}
+		state.tw, state.tc = aw, ac
+		elements = op.Params[2:]
+	case "TJ":
+		if len(op.Params) != 1 {
+			return nil
+		}
+		arr, ok := core.GetArray(op.Params[0])
+		if !ok {
+			return nil
+		}
+		elements = arr.Elements()
+	}
+
+	top := &textOp{index: index, op: op, elements: elements, state: *state}
+	top.glyphs = make([][]*glyph, len(elements))
+	if state.font == nil {
+		return top
+	}
+	for i, element := range elements {
+		if str, ok := core.GetString(element); ok {
+			top.glyphs[i] = top.splitGlyphs(str.Bytes())
+		}
+	}
+	return top
+}
+
+// splitGlyphs returns the glyphs of the string `data` shown by the operation.
+// The whole string is returned as a single fixed glyph if the code boundaries
+// are unknown.
+func (top *textOp) splitGlyphs(data []byte) []*glyph {
+	font := top.state.font
+	codes := font.BytesToCharcodes(data)
+	codeLen := 0
+	switch {
+	case len(codes) == 0:
+		return nil
+	case len(data) == len(codes):
+		codeLen = 1
+	case len(data) == 2*len(codes):
+		codeLen = 2
+	}
+	texts, _, _ := font.CharcodesToStrings(codes)
+
+	var glyphs []*glyph
+	for i, code := range codes {
+		w := 0.0
+		if m, ok := font.GetCharMetrics(code); ok {
+			w = m.Wx / 1000
+		}
+		tw := 0.0
+		if codeLen == 1 && code == 32 {
+			tw = top.state.tw
+		}
+		g := &glyph{top: top, advance: w*top.state.tfs + top.state.tc + tw}
+		if i < len(texts) {
+			g.text = texts[i]
+		}
+		if codeLen > 0 {
+			g.data = data[i*codeLen : (i+1)*codeLen]
+		}
+		glyphs = append(glyphs, g)
+	}
+
+	if codeLen == 0 {
+		fixed := &glyph{top: top, data: data, fixed: true}
+		for _, g := range glyphs {
+			fixed.text += g.text
+			fixed.advance += g.advance
+		}
+		return []*glyph{fixed}
+	}
+	return glyphs
+}
+
+// insertion represents a replacement shown at the position of a replaced
+// glyph.
+type insertion struct {
+	data  []byte  // Character code bytes of the replacement.
+	width float64 // Width of the replaced glyphs, in unscaled text space units.
+}
+
+// replaceContext contains the state of the replacements in a page.
+type replaceContext struct {
+	opts    *Options
+	removed map[*glyph]struct{}
+	inserts map[*glyph]*insertion
+}
+
+// matchRun locates the occurrences of the `replacements` in the text of
+// `run` and records the edits replacing them. Returns the number of
+// occurrences found.
+func (rc *replaceContext) matchRun(run *textRun, replacements []Replacement) (int, error) {
+	// Offsets of the glyphs in the text of the run.
+	var text strings.Builder
+	starts := make(map[int]int, len(run.glyphs))
+	ends := make(map[int]int, len(run.glyphs))
+	for i, g := range run.glyphs {
+		if _, ok := starts[text.Len()]; !ok {
+			starts[text.Len()] = i
+		}
+		text.WriteString(g.text)
+		ends[text.Len()] = i
+	}
+	runText := text.String()
+
+	count := 0
+	for pos := 0; pos < len(runText); {
+		first, ok := starts[pos]
+		if !ok {
+			pos++
+			continue
+		}
+		matched := false
+		for _, r := range replacements {
+			if !strings.HasPrefix(runText[pos:], r.Find) {
+				continue
+			}
+			last, ok := ends[pos+len(r.Find)]
+			if !ok || last < first {
+				continue
+			}
+			glyphs := run.glyphs[first : last+1]
+			if !canReplace(glyphs) {
+				continue
+			}
+			if err := rc.replace(glyphs, r.Replace); err != nil {
+				return 0, err
+			}
+			count++
+			matched = true
+			pos += len(r.Find)
+			break
+		}
+		if !matched {
+			pos += len(run.glyphs[first].text)
+			if run.glyphs[first].text == "" {
+				pos++
+			}
+		}
+	}
+	return count, nil
+}
+
+// canReplace returns true if the glyphs can be removed.
+func canReplace(glyphs []*glyph) bool {
+	for _, g := range glyphs {
+		if g.fixed {
+			return false
+		}
+	}
+	return true
+}
+
+// replace records the replacement of `glyphs` with the text `replacement`,
+// encoded with the font of the first glyph.
+func (rc *replaceContext) replace(glyphs []*glyph, replacement string) error {
+	first := glyphs[0]
+	font := first.top.state.font
+	// Some encoders map the runes they cannot encode to code 0, so the encoded replacement is
+	// checked by decoding it.
+	data, misses := font.StringToCharcodeBytes(replacement)
+	texts, _, _ := font.CharcodesToStrings(font.BytesToCharcodes(data))
+	if misses > 0 || strings.Join(texts, "") != replacement {
+		return fmt.Errorf("unable to encode replacement %q with font %s", replacement, font.BaseFont())
+	}
+
+	ins := &insertion{data: data}
+	for _, g := range glyphs {
+		rc.removed[g] = struct{}{}
+		ins.width += g.advance
+	}
+	rc.inserts[first] = ins
+	return nil
+}
+
+// isEdited returns true if glyphs of `top` are replaced.
+func (rc *replaceContext) isEdited(top *textOp) bool {
+	for _, glyphs := range top.glyphs {
+		for _, g := range glyphs {
+			if _, ok := rc.removed[g]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rewrite returns the operations replacing the text showing operation `top`,
+// with the replaced glyphs removed and the replacements inserted.
+func (rc *replaceContext) rewrite(top *textOp) []*contentstream.ContentStreamOperation {
+	var prefix []*contentstream.ContentStreamOperation
+	switch top.op.Operand {
+	case "\"":
+		prefix = append(prefix,
+			&contentstream.ContentStreamOperation{Operand: "Tw", Params: []core.PdfObject{top.op.Params[0]}},
+			&contentstream.ContentStreamOperation{Operand: "Tc", Params: []core.PdfObject{top.op.Params[1]}},
+		)
+		fallthrough
+	case "'":
+		prefix = append(prefix, &contentstream.ContentStreamOperation{Operand: "T*"})
+	}
+
+	// Displacements are converted to TJ adjustments, in thousandths of text
+	// space units.
+	tfs := top.state.tfs
+	adjustment := func(displacement float64) core.PdfObject {
+		return core.MakeFloat(-displacement * 1000 / tfs)
+	}
+
+	var result []core.PdfObject
+	for i, element := range top.elements {
+		if _, ok := core.GetString(element); !ok {
+			result = append(result, element)
+			continue
+		}
+		var kept []byte
+		flush := func() {
+			if len(kept) > 0 {
+				result = append(result, core.MakeStringFromBytes(kept))
+				kept = nil
+			}
+		}
+		for _, g := range top.glyphs[i] {
+			if ins, ok := rc.inserts[g]; ok {
+				flush()
+				elements, width := rc.showReplacement(top, ins)
+				result = append(result, elements...)
+				result = append(result, adjustment(-width))
+			}
+			if _, ok := rc.removed[g]; ok {
+				flush()
+				result = append(result, adjustment(g.advance))
+				continue
+			}
+			kept = append(kept, g.data...)
+		}
+		flush()
+	}
+
+	return append(prefix, &contentstream.ContentStreamOperation{
+		Operand: "TJ",
+		Params:  []core.PdfObject{core.MakeArray(tjarray.Merge(result)...)},
+	})
+}
+
+// showReplacement returns the TJ elements showing the replacement `ins` with
+// the font of `top`, and their width in unscaled text space units.
+func (rc *replaceContext) showReplacement(top *textOp, ins *insertion) ([]core.PdfObject, float64) {
+	glyphs := top.splitGlyphs(ins.data)
+	if len(glyphs) == 0 {
+		return nil, 0
+	}
+	width := 0.0
+	for _, g := range glyphs {
+		width += g.advance
+	}
+	if rc.opts.Reflow != ReflowCompressSpacing || len(glyphs) < 2 || glyphs[0].fixed {
+		return []core.PdfObject{core.MakeStringFromBytes(ins.data)}, width
+	}
+
+	// The spacing difference is distributed between the glyphs.
+	spacing := (ins.width - width) / float64(len(glyphs)-1)
+	var elements []core.PdfObject
+	for i, g := range glyphs {
+		if i > 0 {
+			elements = append(elements, core.MakeFloat(-spacing*1000/top.state.tfs))
+		}
+		elements = append(elements, core.MakeStringFromBytes(g.data))
+	}
+	return elements, ins.width
+}
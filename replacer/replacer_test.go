@@ -0,0 +1,169 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package replacer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/extractor"
+	"github.com/unidoc/unipdf/v3/internal/pdftest"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// templatePage returns a page whose text contains placeholders, in single strings, spanning the
+// strings of TJ arrays and spanning text showing operators.
+func templatePage(t *testing.T) *model.PdfPage {
+	helvetica := model.NewStandard14FontMustCompile(model.HelveticaName)
+	openSans, err := model.NewPdfFontFromTTFFile("../model/testdata/font/OpenSans-Regular.ttf")
+	require.NoError(t, err)
+
+	// str returns the hexadecimal string of `text` encoded with `font`.
+	str := func(font *model.PdfFont, text string) string {
+		data, misses := font.StringToCharcodeBytes(text)
+		require.Zero(t, misses)
+		return core.MakeHexStringFromBytes(data).WriteString()
+	}
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "BT /F1 12 Tf 72 700 Td %s Tj ET\n", str(helvetica, "Dear «NAME», welcome."))
+	fmt.Fprintf(&content, "BT /F1 12 Tf 72 680 Td [%s 20 %s -50 %s] TJ ET\n",
+		str(helvetica, "Order n"), str(helvetica, "o. «NUM"), str(helvetica, "BER» shipped"))
+	fmt.Fprintf(&content, "BT /F2 12 Tf 72 660 Td %s Tj 0.2 Tc %s Tj %s Tj 0 Tc ET\n",
+		str(openSans, "Signed: «SIG"), str(openSans, "NER»"), str(openSans, ", today"))
+	fmt.Fprintf(&content, "BT /F1 12 Tf 72 640 Td %s Tj ET\n", str(helvetica, "Unchanged «OTHER» line"))
+
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+	require.NoError(t, page.Resources.SetFontByName("F1", helvetica.ToPdfObject()))
+	require.NoError(t, page.Resources.SetFontByName("F2", openSans.ToPdfObject()))
+	require.NoError(t, page.SetContentStreams([]string{content.String()}, core.NewFlateEncoder()))
+	return pdftest.ReloadPage(t, page)
+}
+
+// extractLines returns the lines of text of `page`, except the lines added to unlicensed
+// documents, and the text marks of the page. The lines are made of the text marks, as the text
+// of unlicensed documents is truncated.
+func extractLines(t *testing.T, page *model.PdfPage) ([]string, []extractor.TextMark) {
+	ex, err := extractor.New(page)
+	require.NoError(t, err)
+	pageText, _, _, err := ex.ExtractPageText()
+	require.NoError(t, err)
+	marks := pageText.Marks().Elements()
+	var text strings.Builder
+	for _, mark := range marks {
+		text.WriteString(mark.Text)
+	}
+	var lines []string
+	for _, line := range strings.Split(text.String(), "\n") {
+		if !strings.Contains(line, "Unlicensed") {
+			lines = append(lines, line)
+		}
+	}
+	return lines, marks
+}
+
+// markBBox returns the bounding box of the first mark with text `text` on the line at height
+// `y`.
+func markBBox(t *testing.T, marks []extractor.TextMark, text string, y float64) model.PdfRectangle {
+	for _, mark := range marks {
+		if mark.Text == text && mark.BBox.Lly < y+1 && mark.BBox.Ury > y {
+			return mark.BBox
+		}
+	}
+	require.Fail(t, "mark not found", "%q at %g", text, y)
+	return model.PdfRectangle{}
+}
+
+func TestReplaceText(t *testing.T) {
+	lines, marks := extractLines(t, templatePage(t))
+	require.Equal(t, []string{
+		"Dear «NAME», welcome.",
+		"Order no. «NUMBER» shipped",
+		"Signed: «SIGNER», today",
+		"Unchanged «OTHER» line",
+	}, lines)
+
+	// Without reflow, the replacements have about the width of the placeholders, so that the
+	// extracted text is not interleaved. With compressed spacing, the replacements are wider.
+	for _, tc := range []struct {
+		reflow       Reflow
+		name, signer string
+	}{
+		{ReflowNone, "Margaret", "Sebastian"},
+		{ReflowCompressSpacing, "Maximilian", "Christopher"},
+	} {
+		reflow, name := tc.reflow, tc.name
+		replacements := []Replacement{
+			{Find: "«NAME»", Replace: name},
+			{Find: "«SIGNER»", Replace: tc.signer},
+			{Find: "«NUM", Replace: "#4217"},
+			{Find: "«NUMBER»", Replace: "unused"},
+			{Find: "«NAME»", Replace: "unused"},
+		}
+		page := templatePage(t)
+		count, err := ReplaceText(page, replacements, &Options{Reflow: reflow})
+		require.NoError(t, err)
+		require.Equal(t, 3, count)
+
+		page = pdftest.ReloadPage(t, page)
+		replacedLines, replacedMarks := extractLines(t, page)
+		require.Equal(t, []string{
+			"Dear " + name + ", welcome.",
+			// «NUM is first in the replacements.
+			"Order no. #4217BER» shipped",
+			"Signed: " + tc.signer + ", today",
+			"Unchanged «OTHER» line",
+		}, replacedLines, "reflow: %d", reflow)
+
+		// The text following the replacements keeps its position.
+		for _, m := range []struct {
+			text string
+			y    float64
+		}{{"w", 700}, {"s", 680}, {"y", 660}, {"l", 640}} {
+			expected := markBBox(t, marks, m.text, m.y)
+			bbox := markBBox(t, replacedMarks, m.text, m.y)
+			require.InDelta(t, expected.Llx, bbox.Llx, 0.01, "%q (reflow: %d)", m.text, reflow)
+		}
+
+		// With compressed spacing, the replacements fit the width of the replaced text.
+		end := markBBox(t, marks, "»", 700).Urx
+		replacedEnd := markBBox(t, replacedMarks, name[len(name)-1:], 700).Urx
+		require.InDelta(t, end, replacedEnd, 0.01)
+		end = markBBox(t, marks, "M", 680).Urx
+		replacedEnd = markBBox(t, replacedMarks, "7", 680).Urx
+		if reflow == ReflowCompressSpacing {
+			require.InDelta(t, end, replacedEnd, 0.01)
+		} else {
+			require.True(t, replacedEnd < end-0.5, "%g %g", end, replacedEnd)
+		}
+	}
+}
+
+func TestReplaceTextErrors(t *testing.T) {
+	page := templatePage(t)
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+
+	_, err = ReplaceText(page, []Replacement{{Find: ""}}, nil)
+	require.Error(t, err)
+
+	// The replacement cannot be encoded with the font of the placeholder.
+	_, err = ReplaceText(page, []Replacement{{Find: "«NAME»", Replace: "漢字"}}, nil)
+	require.Error(t, err)
+
+	// Nothing is replaced.
+	count, err := ReplaceText(page, []Replacement{{Find: "«MISSING»", Replace: "x"}}, nil)
+	require.NoError(t, err)
+	require.Zero(t, count)
+	replaced, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	require.Equal(t, contents, replaced)
+}
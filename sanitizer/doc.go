@@ -0,0 +1,12 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package sanitizer is used for removing active and hidden content from PDF
+// documents before publishing them, e.g. JavaScript, actions launching
+// applications or submitting data, embedded files, the content of hidden
+// layers and the document metadata. The sanitized document is written along
+// with a report of the removed content, locating each item by object number
+// and page.
+package sanitizer
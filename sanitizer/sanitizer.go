@@ -0,0 +1,665 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package sanitizer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/filespec"
+	"github.com/unidoc/unipdf/v3/model"
+	"github.com/unidoc/unipdf/v3/model/optcontent"
+)
+
+// Category represents a category of content removed by the sanitizer.
+// Categories can be combined with |.
+type Category int
+
+// Categories of content.
+const (
+	// CategoryJavaScript is the JavaScript of the document: the
+	// document-level scripts (JavaScript name tree) and the JavaScript
+	// actions of the pages, annotations, form fields, outlines and open
+	// action, including their additional actions (AA).
+	CategoryJavaScript Category = 1 << iota
+
+	// CategoryExternalActions is the actions acting outside of the
+	// document: Launch, GoToR, GoToE, SubmitForm and ImportData actions.
+	CategoryExternalActions
+
+	// CategoryEmbeddedFiles is the embedded files: the document-level
+	// embedded files (EmbeddedFiles name tree), the file streams of the file
+	// specifications and the file attachment annotations.
+	CategoryEmbeddedFiles
+
+	// CategoryHiddenLayers is the optional content groups hidden in the
+	// default configuration of the document, along with the content of the
+	// pages belonging to them.
+	CategoryHiddenLayers
+
+	// CategoryMetadata is the document metadata: the entries of the document
+	// information dictionary specified by Options.InfoKeys and the
+	// corresponding XMP metadata properties.
+	CategoryMetadata
+
+	// CategoryActive is the active content: JavaScript, external actions and
+	// embedded files.
+	CategoryActive = CategoryJavaScript | CategoryExternalActions | CategoryEmbeddedFiles
+)
+
+// String returns a string representation of the category.
+func (c Category) String() string {
+	names := []struct {
+		category Category
+		name     string
+	}{
+		{CategoryJavaScript, "JavaScript"},
+		{CategoryExternalActions, "ExternalActions"},
+		{CategoryEmbeddedFiles, "EmbeddedFiles"},
+		{CategoryHiddenLayers, "HiddenLayers"},
+		{CategoryMetadata, "Metadata"},
+	}
+	var parts []string
+	for _, n := range names {
+		if c&n.category != 0 {
+			parts = append(parts, n.name)
+		}
+	}
+	if len(parts) == 0 {
+		return "None"
+	}
+	return strings.Join(parts, "|")
+}
+
+// Options defines the content removed by the sanitizer.
+type Options struct {
+	// Categories are the categories of the content removed. Defaults to
+	// CategoryActive if the options are not specified.
+	Categories Category
+
+	// InfoKeys are the keys of the document information dictionary entries
+	// removed with CategoryMetadata, e.g. "Author" or "Producer". The XMP
+	// properties corresponding to the entries are removed as well. All the
+	// entries and the XMP metadata are removed if not specified.
+	InfoKeys []string
+}
+
+// Removal describes an item of content removed from the document.
+type Removal struct {
+	// Category is the category of the removed item.
+	Category Category
+	// Description describes the item, e.g. `JavaScript action` or
+	// `embedded file "report.xlsx"`.
+	Description string
+	// ObjectNumber is the number of the removed object, or of the object
+	// containing it if it is a direct object. It is 0 for the items which
+	// are not objects, e.g. metadata entries.
+	ObjectNumber int64
+	// Page is the number of the page the item belongs to, or 0 for the
+	// document-level items.
+	Page int
+}
+
+// Report lists the content removed from a document.
+type Report struct {
+	Removals []Removal
+}
+
+// Count returns the number of items of the categories `c` removed.
+func (r *Report) Count(c Category) int {
+	count := 0
+	for _, removal := range r.Removals {
+		if removal.Category&c != 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// maxDepth is the maximum nesting level of the objects walked.
+const maxDepth = 100
+
+// externalActions are the action types of CategoryExternalActions.
+var externalActions = map[string]bool{
+	"Launch":     true,
+	"GoToR":      true,
+	"GoToE":      true,
+	"SubmitForm": true,
+	"ImportData": true,
+}
+
+// infoXMPFields maps the document information dictionary keys to functions
+// clearing the corresponding XMP metadata properties.
+var infoXMPFields = map[string]func(m *model.XMPMetadata){
+	"Title":        func(m *model.XMPMetadata) { m.Title = "" },
+	"Author":       func(m *model.XMPMetadata) { m.Creators = nil },
+	"Subject":      func(m *model.XMPMetadata) { m.Description = "" },
+	"Keywords":     func(m *model.XMPMetadata) { m.Keywords = "" },
+	"Creator":      func(m *model.XMPMetadata) { m.CreatorTool = "" },
+	"Producer":     func(m *model.XMPMetadata) { m.Producer = "" },
+	"CreationDate": func(m *model.XMPMetadata) { m.CreateDate = time.Time{} },
+	"ModDate":      func(m *model.XMPMetadata) { m.ModifyDate = time.Time{} },
+}
+
+// Sanitize writes the document read by `r` to `ws`, without the content
+// selected by `opts`, which can be nil. Returns a report of the removed
+// content.
+// The objects reachable from the pages and the catalog are walked: the
+// removed actions are dropped from the chains of actions (Next entries) and
+// from the additional actions dictionaries, the embedded file streams are
+// dropped from the file specifications, and the file attachment annotations
+// are removed from the pages. The pages, outlines, forms, named
+// destinations, optional content, page labels, open action, viewer
+// preferences, structure tree and the remaining metadata of the document are
+// written. The document-level additional actions are not written.
+// The reader must be decrypted if the document is encrypted. The objects of
+// the document are modified.
+func Sanitize(r *model.PdfReader, ws io.Writer, opts *Options) (*Report, error) {
+	if r == nil {
+		return nil, errors.New("reader not specified")
+	}
+	if opts == nil {
+		opts = &Options{Categories: CategoryActive}
+	}
+	numPages, err := r.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+	var pages []*model.PdfPage
+	for i := 1; i <= numPages; i++ {
+		page, err := r.GetPage(i)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+	}
+	trailer, err := r.GetTrailer()
+	if err != nil {
+		return nil, err
+	}
+	catalog, ok := core.GetDict(trailer.Get("Root"))
+	if !ok {
+		return nil, errors.New("catalog missing")
+	}
+
+	s := &sanitizer{
+		opts:    opts,
+		report:  &Report{},
+		visited: map[core.PdfObject]struct{}{},
+		files:   map[*core.PdfObjectDictionary]struct{}{},
+		annots:  map[*core.PdfObjectDictionary]struct{}{},
+	}
+
+	// The pages are marked first, so that the pages reached from other pages,
+	// e.g. through destinations, are attributed to their own page numbers.
+	for _, page := range pages {
+		s.visited[page.GetContainingPdfObject()] = struct{}{}
+	}
+	for i, page := range pages {
+		container := page.GetContainingPdfObject()
+		if d, ok := core.GetDict(container); ok {
+			s.walkDict(d, i+1, objectNumber(container, 0), 0)
+		}
+	}
+	s.visited[core.ResolveReference(trailer.Get("Root"))] = struct{}{}
+	catalogNum := objectNumber(trailer.Get("Root"), 0)
+	if names, ok := core.GetDict(catalog.Get("Names")); ok {
+		s.sanitizeNames(names, objectNumber(catalog.Get("Names"), catalogNum))
+	}
+	s.walkDict(catalog, 0, catalogNum, 0)
+
+	for _, page := range pages {
+		if err := s.syncPage(page); err != nil {
+			return nil, err
+		}
+	}
+	if r.AcroForm != nil {
+		for _, field := range r.AcroForm.AllFields() {
+			if d, ok := core.GetDict(field.GetContainingPdfObject()); ok {
+				field.AA = d.Get("AA")
+			}
+		}
+	}
+	outlineTree := r.GetOutlineTree()
+	if outlineTree != nil {
+		syncOutlines(outlineTree, map[*model.PdfOutlineTreeNode]struct{}{})
+	}
+
+	props, err := r.GetOptionalContentProperties()
+	if err != nil {
+		return nil, err
+	}
+	if props != nil && opts.Categories&CategoryHiddenLayers != 0 {
+		for _, ocg := range append([]*model.PdfOptionalContentGroup(nil), props.OCGs...) {
+			if props.IsVisible(ocg) {
+				continue
+			}
+			if err := optcontent.RemoveGroup(props, pages, ocg, false); err != nil {
+				return nil, err
+			}
+			s.add(CategoryHiddenLayers, fmt.Sprintf("hidden layer %q", ocg.Name),
+				objectNumber(ocg.GetContainingPdfObject(), 0), 0)
+		}
+	}
+
+	w := model.NewPdfWriter()
+	for _, page := range pages {
+		if err := w.AddPage(page); err != nil {
+			return nil, err
+		}
+	}
+	if outlineTree != nil {
+		w.AddOutlineTree(outlineTree)
+	}
+	if r.AcroForm != nil {
+		if err := w.SetForms(r.AcroForm); err != nil {
+			return nil, err
+		}
+	}
+	if names, ok := core.GetDict(catalog.Get("Names")); ok && len(names.Keys()) > 0 {
+		if err := w.SetNamedDestinations(catalog.Get("Names")); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.SetOptionalContentProperties(props); err != nil {
+		return nil, err
+	}
+	if labels, err := r.GetPageLabels(); err == nil && labels != nil {
+		if err := w.SetPageLabels(labels); err != nil {
+			return nil, err
+		}
+	}
+	if err := setDocumentProperties(r, &w); err != nil {
+		return nil, err
+	}
+	if err := s.setMetadata(r, &w); err != nil {
+		return nil, err
+	}
+
+	if err := w.Write(ws); err != nil {
+		return nil, err
+	}
+	return s.report, nil
+}
+
+// sanitizer contains the state of the sanitization of a document.
+type sanitizer struct {
+	opts    *Options
+	report  *Report
+	visited map[core.PdfObject]struct{}
+
+	// File specifications whose embedded files were removed.
+	files map[*core.PdfObjectDictionary]struct{}
+
+	// Removed file attachment annotations.
+	annots map[*core.PdfObjectDictionary]struct{}
+}
+
+// add adds the removal of the item described by `desc` to the report.
+func (s *sanitizer) add(c Category, desc string, objNum int64, page int) {
+	s.report.Removals = append(s.report.Removals, Removal{
+		Category:     c,
+		Description:  desc,
+		ObjectNumber: objNum,
+		Page:         page,
+	})
+}
+
+// walk removes the content selected by the options from `obj` and the
+// objects it refers to. `page` is the number of the page the objects belong
+// to and `objNum` the number of the object containing `obj`.
+func (s *sanitizer) walk(obj core.PdfObject, page int, objNum int64, depth int) {
+	if depth > maxDepth {
+		return
+	}
+	switch t := obj.(type) {
+	case *core.PdfObjectReference:
+		s.walk(core.ResolveReference(t), page, objNum, depth+1)
+	case *core.PdfIndirectObject:
+		if _, ok := s.visited[t]; ok {
+			return
+		}
+		s.visited[t] = struct{}{}
+		s.walk(t.PdfObject, page, t.ObjectNumber, depth+1)
+	case *core.PdfObjectStream:
+		if _, ok := s.visited[t]; ok {
+			return
+		}
+		s.visited[t] = struct{}{}
+		s.walkDict(t.PdfObjectDictionary, page, t.ObjectNumber, depth+1)
+	case *core.PdfObjectArray:
+		for _, elem := range t.Elements() {
+			s.walk(elem, page, objNum, depth+1)
+		}
+	case *core.PdfObjectDictionary:
+		s.walkDict(t, page, objNum, depth)
+	}
+}
+
+// walkDict removes the content selected by the options from the dictionary
+// `d` and the objects it refers to, as walk.
+func (s *sanitizer) walkDict(d *core.PdfObjectDictionary, page int, objNum int64, depth int) {
+	if depth > maxDepth {
+		return
+	}
+	if s.opts.Categories&CategoryEmbeddedFiles != 0 {
+		if subtype, _ := core.GetNameVal(d.Get("Subtype")); subtype == "FileAttachment" {
+			s.annots[d] = struct{}{}
+			desc := "file attachment annotation"
+			if fs, ok := core.GetDict(d.Get("FS")); ok {
+				desc = fmt.Sprintf("%s %q", desc, filespec.FileName(fs))
+			}
+			s.add(CategoryEmbeddedFiles, desc, objNum, page)
+			return
+		}
+		if d.Get("EF") != nil || d.Get("RF") != nil {
+			d.Remove("EF")
+			d.Remove("RF")
+			if _, ok := s.files[d]; !ok {
+				s.files[d] = struct{}{}
+				s.add(CategoryEmbeddedFiles, fmt.Sprintf("embedded file %q", filespec.FileName(d)), objNum, page)
+			}
+		}
+	}
+
+	for _, key := range []core.PdfObjectName{"A", "OpenAction"} {
+		if action := d.Get(key); action != nil && s.sanitizeAction(action, "", page, objNum) == nil {
+			d.Remove(key)
+		}
+	}
+	if aa, ok := core.GetDict(d.Get("AA")); ok {
+		aaNum := objectNumber(d.Get("AA"), objNum)
+		for _, trigger := range append([]core.PdfObjectName(nil), aa.Keys()...) {
+			if s.sanitizeAction(aa.Get(trigger), string(trigger), page, aaNum) == nil {
+				aa.Remove(trigger)
+			}
+		}
+		if len(aa.Keys()) == 0 {
+			d.Remove("AA")
+		}
+	}
+
+	for _, key := range d.Keys() {
+		switch key {
+		case "Parent", "P":
+			continue
+		}
+		s.walk(d.Get(key), page, objNum, depth+1)
+	}
+}
+
+// sanitizeAction removes the actions selected by the options from the chain
+// of actions started by the action `obj`, performed on `trigger` if it is an
+// additional action. Returns nil if the action itself is removed.
+func (s *sanitizer) sanitizeAction(obj core.PdfObject, trigger string, page int, objNum int64) core.PdfObject {
+	d, ok := core.GetDict(obj)
+	if !ok {
+		return obj
+	}
+	actionType, _ := core.GetNameVal(d.Get("S"))
+	var c Category
+	switch {
+	case actionType == "JavaScript":
+		c = CategoryJavaScript
+	case externalActions[actionType]:
+		c = CategoryExternalActions
+	}
+	if s.opts.Categories&c != 0 {
+		desc := actionType + " action"
+		if trigger != "" {
+			desc = fmt.Sprintf("%s (additional action %s)", desc, trigger)
+		}
+		s.add(c, desc, objectNumber(obj, objNum), page)
+		return nil
+	}
+
+	objNum = objectNumber(obj, objNum)
+	switch next := core.TraceToDirectObject(d.Get("Next")).(type) {
+	case *core.PdfObjectDictionary:
+		if s.sanitizeAction(d.Get("Next"), trigger, page, objNum) == nil {
+			d.Remove("Next")
+		}
+	case *core.PdfObjectArray:
+		var actions []core.PdfObject
+		for _, action := range next.Elements() {
+			if s.sanitizeAction(action, trigger, page, objNum) != nil {
+				actions = append(actions, action)
+			}
+		}
+		next.Clear()
+		next.Append(actions...)
+	}
+	return obj
+}
+
+// sanitizeNames removes the document-level JavaScript and embedded files
+// from the Names dictionary `names` of the catalog, which is object `objNum`.
+func (s *sanitizer) sanitizeNames(names *core.PdfObjectDictionary, objNum int64) {
+	if s.opts.Categories&CategoryJavaScript != 0 && names.Get("JavaScript") != nil {
+		walkNameTree(names.Get("JavaScript"), objNum, func(name string, value core.PdfObject, objNum int64) {
+			s.add(CategoryJavaScript, fmt.Sprintf("document-level JavaScript %q", name),
+				objectNumber(value, objNum), 0)
+		}, 0)
+		names.Remove("JavaScript")
+	}
+	if s.opts.Categories&CategoryEmbeddedFiles != 0 && names.Get("EmbeddedFiles") != nil {
+		walkNameTree(names.Get("EmbeddedFiles"), objNum, func(name string, value core.PdfObject, objNum int64) {
+			if fs, ok := core.GetDict(value); ok {
+				s.files[fs] = struct{}{}
+			}
+			s.add(CategoryEmbeddedFiles, fmt.Sprintf("embedded file %q", name),
+				objectNumber(value, objNum), 0)
+		}, 0)
+		names.Remove("EmbeddedFiles")
+	}
+}
+
+// syncPage updates the models of `page` and of its annotations with the
+// actions left in their dictionaries and removes the file attachment
+// annotations marked for removal.
+func (s *sanitizer) syncPage(page *model.PdfPage) error {
+	if d, ok := core.GetDict(page.GetContainingPdfObject()); ok {
+		page.AA = d.Get("AA")
+	}
+	annotations, err := page.GetAnnotations()
+	if err != nil {
+		return err
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	var kept []*model.PdfAnnotation
+	for _, annot := range annotations {
+		d, ok := core.GetDict(annot.GetContainingPdfObject())
+		if !ok {
+			kept = append(kept, annot)
+			continue
+		}
+		if _, ok := s.annots[d]; ok {
+			continue
+		}
+		switch t := annot.GetContext().(type) {
+		case *model.PdfAnnotationLink:
+			t.A = d.Get("A")
+		case *model.PdfAnnotationMovie:
+			t.A = d.Get("A")
+		case *model.PdfAnnotationScreen:
+			t.A, t.AA = d.Get("A"), d.Get("AA")
+		case *model.PdfAnnotationWidget:
+			t.A, t.AA = d.Get("A"), d.Get("AA")
+		}
+		kept = append(kept, annot)
+	}
+	page.SetAnnotations(kept)
+	return nil
+}
+
+// setMetadata sets the document information dictionary and the XMP metadata
+// of the document written by `w`, without the metadata selected by the
+// options.
+func (s *sanitizer) setMetadata(r *model.PdfReader, w *model.PdfWriter) error {
+	info := w.GetInfoDict()
+	srcInfo, err := r.GetInfoDict()
+	if err != nil {
+		return err
+	}
+	if srcInfo != nil {
+		for _, key := range srcInfo.Keys() {
+			info.Set(key, srcInfo.Get(key))
+		}
+	}
+	xmp, err := r.GetXMPMetadata()
+	if err != nil {
+		common.Log.Debug("ERROR: invalid XMP metadata: %v", err)
+		xmp = nil
+	}
+
+	if s.opts.Categories&CategoryMetadata != 0 {
+		infoNum := objectNumber(trailerInfo(r), 0)
+		if len(s.opts.InfoKeys) == 0 {
+			if srcInfo != nil {
+				for _, key := range srcInfo.Keys() {
+					s.add(CategoryMetadata, fmt.Sprintf("information entry %s", key), infoNum, 0)
+				}
+			}
+			for _, key := range append([]core.PdfObjectName(nil), info.Keys()...) {
+				info.Remove(key)
+			}
+			if xmp != nil {
+				s.add(CategoryMetadata, "XMP metadata", 0, 0)
+				xmp = nil
+			}
+		} else {
+			for _, key := range s.opts.InfoKeys {
+				if srcInfo != nil && srcInfo.Get(core.PdfObjectName(key)) != nil {
+					s.add(CategoryMetadata, fmt.Sprintf("information entry %s", key), infoNum, 0)
+				}
+				info.Remove(core.PdfObjectName(key))
+				if clear, ok := infoXMPFields[key]; ok && xmp != nil {
+					clear(xmp)
+				}
+			}
+		}
+	}
+	return w.SetXMPMetadata(xmp)
+}
+
+// setDocumentProperties sets the open action, the viewer preferences, the
+// structure tree and the language of the document read by `r` to the
+// document written by `w`.
+func setDocumentProperties(r *model.PdfReader, w *model.PdfWriter) error {
+	dest, action, err := r.GetOpenAction()
+	if err != nil {
+		common.Log.Debug("ERROR: invalid open action: %v", err)
+	}
+	switch {
+	case dest != nil:
+		err = w.SetOpenActionDestination(dest)
+	case action != nil:
+		err = w.SetOpenAction(action)
+	}
+	if err != nil {
+		return err
+	}
+
+	if mode, err := r.GetPageMode(); err == nil && mode != model.PageModeUseNone {
+		if err := w.SetPageMode(mode); err != nil {
+			common.Log.Debug("ERROR: invalid page mode: %v", err)
+		}
+	}
+	if layout, err := r.GetPageLayout(); err == nil && layout != model.PageLayoutSinglePage {
+		if err := w.SetPageLayout(layout); err != nil {
+			common.Log.Debug("ERROR: invalid page layout: %v", err)
+		}
+	}
+	if prefs, err := r.GetViewerPreferences(); err == nil && prefs != nil {
+		if err := w.SetViewerPreferences(prefs); err != nil {
+			common.Log.Debug("ERROR: invalid viewer preferences: %v", err)
+		}
+	}
+	if root, err := r.GetStructTreeRoot(); err == nil && root != nil {
+		if err := w.SetStructTreeRoot(root); err != nil {
+			return err
+		}
+	}
+	w.SetLanguage(r.GetLanguage())
+	return nil
+}
+
+// syncOutlines updates the models of the outline items of `node` with the
+// actions left in their dictionaries.
+func syncOutlines(node *model.PdfOutlineTreeNode, visited map[*model.PdfOutlineTreeNode]struct{}) {
+	for n := node.First; n != nil; {
+		if _, ok := visited[n]; ok {
+			return
+		}
+		visited[n] = struct{}{}
+
+		item, ok := n.GetContext().(*model.PdfOutlineItem)
+		if !ok {
+			return
+		}
+		if d, ok := core.GetDict(item.GetContainingPdfObject()); ok {
+			item.A = d.Get("A")
+		}
+		syncOutlines(&item.PdfOutlineTreeNode, visited)
+		n = item.Next
+	}
+}
+
+// walkNameTree calls `visit` for the entries of the name tree `obj`, with the
+// number of the object containing them. `objNum` is the number of the object
+// containing `obj`.
+func walkNameTree(obj core.PdfObject, objNum int64, visit func(name string, value core.PdfObject, objNum int64), depth int) {
+	d, ok := core.GetDict(obj)
+	if !ok || depth > maxDepth {
+		return
+	}
+	objNum = objectNumber(obj, objNum)
+	if names, ok := core.GetArray(d.Get("Names")); ok {
+		for i := 0; i+1 < names.Len(); i += 2 {
+			var name string
+			if s, ok := core.GetString(names.Get(i)); ok {
+				name = s.Decoded()
+			}
+			visit(name, names.Get(i+1), objNum)
+		}
+	}
+	if kids, ok := core.GetArray(d.Get("Kids")); ok {
+		for _, kid := range kids.Elements() {
+			walkNameTree(kid, objNum, visit, depth+1)
+		}
+	}
+}
+
+// trailerInfo returns the Info entry of the trailer of the document read by
+// `r`.
+func trailerInfo(r *model.PdfReader) core.PdfObject {
+	trailer, err := r.GetTrailer()
+	if err != nil {
+		return nil
+	}
+	return trailer.Get("Info")
+}
+
+// objectNumber returns the object number of `obj`, or `objNum` if it is a
+// direct object.
+func objectNumber(obj core.PdfObject, objNum int64) int64 {
+	switch t := obj.(type) {
+	case *core.PdfObjectReference:
+		return t.ObjectNumber
+	case *core.PdfIndirectObject:
+		return t.ObjectNumber
+	case *core.PdfObjectStream:
+		return t.ObjectNumber
+	}
+	return objNum
+}
@@ -0,0 +1,213 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package sanitizer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/testutils"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// testDocument returns a document with content of each category: document-level and page,
+// annotation, field and open action JavaScript, Launch, GoToR and SubmitForm actions, embedded
+// files and a file attachment annotation, a hidden layer and metadata.
+func testDocument(t *testing.T) []byte {
+	xmp := model.NewXMPMetadata()
+	xmp.Title = "Quarterly"
+	xmp.Creators = []string{"Jane"}
+	xmpData, err := xmp.Bytes()
+	require.NoError(t, err)
+
+	contents := "BT /F1 12 Tf 10 150 Td (Public) Tj ET\n" +
+		"/OC /Shown BDC BT /F1 12 Tf 10 120 Td (Layer) Tj ET EMC\n" +
+		"/OC /Hidden BDC BT /F1 12 Tf 10 90 Td (Secret) Tj ET EMC"
+
+	return testutils.BuildRawPdf([]string{
+		"<< /Type /Catalog /Pages 2 0 R /OpenAction 9 0 R " +
+			"/Names << /JavaScript 10 0 R /EmbeddedFiles << /Names [(data.csv) 12 0 R] >> >> " +
+			"/AcroForm << /Fields [7 0 R] >> " +
+			"/OCProperties << /OCGs [14 0 R 15 0 R] /D << /OFF [15 0 R] /Order [14 0 R 15 0 R] >> >> " +
+			"/Metadata 16 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 17 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Contents 4 0 R " +
+			"/Resources << /Font << /F1 5 0 R >> /Properties << /Shown 14 0 R /Hidden 15 0 R >> >> " +
+			"/Annots [6 0 R 7 0 R 8 0 R 13 0 R] /AA << /O << /S /JavaScript /JS (open) >> >> >>",
+		testutils.RawStream("", []byte(contents)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Annot /Subtype /Link /Rect [0 0 50 20] " +
+			"/A << /S /URI /URI (https://example.com) /Next 11 0 R >> >>",
+		"<< /Type /Annot /Subtype /Widget /FT /Tx /T (name) /Rect [0 30 50 50] /P 3 0 R " +
+			"/A << /S /SubmitForm /F << /FS /URL /F (https://example.com/submit) >> >> " +
+			"/AA << /K << /S /JavaScript /JS (format) >> >> >>",
+		"<< /Type /Annot /Subtype /Link /Rect [0 60 50 80] /A << /S /Launch /F (calc.exe) >> >>",
+		"<< /S /JavaScript /JS (app.alert\\(1\\)) >>",
+		"<< /Names [(init) << /S /JavaScript /JS (init) >>] >>",
+		"<< /S /JavaScript /JS (next) >>",
+		"<< /Type /Filespec /F (data.csv) /EF << /F 18 0 R >> >>",
+		"<< /Type /Annot /Subtype /FileAttachment /Rect [60 0 80 20] " +
+			"/FS << /Type /Filespec /F (notes.txt) /EF << /F 18 0 R >> >> >>",
+		"<< /Type /OCG /Name (Shown) >>",
+		"<< /Type /OCG /Name (Hidden) >>",
+		testutils.RawStream("/Type /Metadata /Subtype /XML", xmpData),
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Resources << >> /Annots [19 0 R] >>",
+		testutils.RawStream("/Type /EmbeddedFile", []byte("a,b")),
+		"<< /Type /Annot /Subtype /Link /Rect [0 0 50 20] /A << /S /GoToR /F (other.pdf) /D [0 /Fit] >> >>",
+		"<< /Title (Quarterly) /Author (Jane) /Producer (Tool) >>",
+	}, &testutils.RawPdfOptions{TrailerEntries: "/Info 20 0 R"})
+}
+
+// TestSanitize checks that the content of all the categories is removed from the test document
+// and reported, and that the sanitized document is valid.
+func TestSanitize(t *testing.T) {
+	reader, err := model.NewPdfReader(bytes.NewReader(testDocument(t)))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	report, err := Sanitize(reader, &buf, &Options{
+		Categories: CategoryActive | CategoryHiddenLayers | CategoryMetadata,
+		InfoKeys:   []string{"Author"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []Removal{
+		{CategoryJavaScript, "JavaScript action (additional action O)", 3, 1},
+		{CategoryJavaScript, "JavaScript action", 11, 1},
+		{CategoryExternalActions, "SubmitForm action", 7, 1},
+		{CategoryJavaScript, "JavaScript action (additional action K)", 7, 1},
+		{CategoryExternalActions, "Launch action", 8, 1},
+		{CategoryEmbeddedFiles, `file attachment annotation "notes.txt"`, 13, 1},
+		{CategoryExternalActions, "GoToR action", 19, 2},
+		{CategoryJavaScript, `document-level JavaScript "init"`, 10, 0},
+		{CategoryEmbeddedFiles, `embedded file "data.csv"`, 12, 0},
+		{CategoryJavaScript, "JavaScript action", 9, 0},
+		{CategoryHiddenLayers, `hidden layer "Hidden"`, 15, 0},
+		{CategoryMetadata, "information entry Author", 20, 0},
+	}, report.Removals)
+	require.Equal(t, 5, report.Count(CategoryJavaScript))
+
+	data := buf.Bytes()
+	for _, s := range []string{"JavaScript", "Launch", "GoToR", "SubmitForm", "EmbeddedFile", "FileAttachment"} {
+		require.NotContains(t, string(data), s)
+	}
+
+	sanitized, err := model.NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	numPages, err := sanitized.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 2, numPages)
+
+	page, err := sanitized.GetPage(1)
+	require.NoError(t, err)
+	require.Nil(t, page.AA)
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	require.Contains(t, contents, "(Public) Tj")
+	require.Contains(t, contents, "(Layer) Tj")
+	require.NotContains(t, contents, "(Secret) Tj")
+
+	annots, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 3)
+	link := annots[0].GetContext().(*model.PdfAnnotationLink)
+	action, ok := core.GetDict(link.A)
+	require.True(t, ok)
+	require.Equal(t, "https://example.com", action.Get("URI").(*core.PdfObjectString).Str())
+	require.Nil(t, action.Get("Next"))
+	widget := annots[1].GetContext().(*model.PdfAnnotationWidget)
+	require.Nil(t, widget.A)
+	require.Nil(t, widget.AA)
+	require.Nil(t, annots[2].GetContext().(*model.PdfAnnotationLink).A)
+
+	page, err = sanitized.GetPage(2)
+	require.NoError(t, err)
+	annots, err = page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 1)
+	require.Nil(t, annots[0].GetContext().(*model.PdfAnnotationLink).A)
+
+	require.NotNil(t, sanitized.AcroForm)
+	fields := sanitized.AcroForm.AllFields()
+	require.Len(t, fields, 1)
+	require.Nil(t, fields[0].AA)
+
+	dest, openAction, err := sanitized.GetOpenAction()
+	require.NoError(t, err)
+	require.Nil(t, dest)
+	require.Nil(t, openAction)
+
+	props, err := sanitized.GetOptionalContentProperties()
+	require.NoError(t, err)
+	require.Len(t, props.OCGs, 1)
+	require.Equal(t, "Shown", props.OCGs[0].Name)
+
+	info, err := sanitized.GetInfoDict()
+	require.NoError(t, err)
+	require.Nil(t, info.Get("Author"))
+	title, ok := core.GetString(info.Get("Title"))
+	require.True(t, ok)
+	require.Equal(t, "Quarterly", title.Decoded())
+	xmp, err := sanitized.GetXMPMetadata()
+	require.NoError(t, err)
+	require.NotNil(t, xmp)
+	require.Equal(t, "Quarterly", xmp.Title)
+	require.Empty(t, xmp.Creators)
+}
+
+// TestSanitizeDefault checks that only the active content is removed by default and that all
+// the metadata is removed when no information keys are specified.
+func TestSanitizeDefault(t *testing.T) {
+	reader, err := model.NewPdfReader(bytes.NewReader(testDocument(t)))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	report, err := Sanitize(reader, &buf, nil)
+	require.NoError(t, err)
+	require.Equal(t, 10, report.Count(CategoryActive))
+	require.Zero(t, report.Count(CategoryHiddenLayers|CategoryMetadata))
+
+	sanitized, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	page, err := sanitized.GetPage(1)
+	require.NoError(t, err)
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	require.Contains(t, contents, "(Secret) Tj")
+	info, err := sanitized.GetInfoDict()
+	require.NoError(t, err)
+	require.NotNil(t, info.Get("Author"))
+
+	reader, err = model.NewPdfReader(bytes.NewReader(testDocument(t)))
+	require.NoError(t, err)
+	buf.Reset()
+	report, err = Sanitize(reader, &buf, &Options{Categories: CategoryMetadata})
+	require.NoError(t, err)
+	require.Equal(t, []Removal{
+		{CategoryMetadata, "information entry Title", 20, 0},
+		{CategoryMetadata, "information entry Author", 20, 0},
+		{CategoryMetadata, "information entry Producer", 20, 0},
+		{CategoryMetadata, "XMP metadata", 0, 0},
+	}, report.Removals)
+
+	sanitized, err = model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	info, err = sanitized.GetInfoDict()
+	require.NoError(t, err)
+	require.Empty(t, info.Keys())
+	xmp, err := sanitized.GetXMPMetadata()
+	require.NoError(t, err)
+	require.Nil(t, xmp)
+	require.Contains(t, buf.String(), "JavaScript")
+}
+
+// TestCategoryString checks the string representation of the categories.
+func TestCategoryString(t *testing.T) {
+	require.Equal(t, "JavaScript|ExternalActions|EmbeddedFiles", CategoryActive.String())
+	require.Equal(t, "Metadata", CategoryMetadata.String())
+	require.Equal(t, "None", Category(0).String())
+}